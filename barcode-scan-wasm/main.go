@@ -0,0 +1,1228 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+var silentMode = false
+
+// Scanner defaults and bounds. Times are in milliseconds, like the timestamps of video frames.
+const (
+	defaultBufferSize      = 4
+	maxBufferSize          = 64
+	defaultTimeBudget      = 100.0
+	defaultDuplicateWindow = 2000.0
+	defaultROIPadding      = 0.5
+	defaultROIFrames       = 10
+	minROISize             = 48
+	maxFramePixels         = 4096 * 4096
+)
+
+// scanFormat maps a format name of the API to its gozxing format
+type scanFormat struct {
+	name   string
+	format gozxing.BarcodeFormat
+}
+
+// scanFormats are the supported formats, in the order their readers are tried
+var scanFormats = []scanFormat{
+	{"qr", gozxing.BarcodeFormat_QR_CODE},
+	{"ean13", gozxing.BarcodeFormat_EAN_13},
+	{"ean8", gozxing.BarcodeFormat_EAN_8},
+	{"upca", gozxing.BarcodeFormat_UPC_A},
+	{"upce", gozxing.BarcodeFormat_UPC_E},
+	{"code128", gozxing.BarcodeFormat_CODE_128},
+	{"code39", gozxing.BarcodeFormat_CODE_39},
+	{"code93", gozxing.BarcodeFormat_CODE_93},
+	{"itf", gozxing.BarcodeFormat_ITF},
+	{"codabar", gozxing.BarcodeFormat_CODABAR},
+	{"datamatrix", gozxing.BarcodeFormat_DATA_MATRIX},
+}
+
+// scannerOptions configure a scanner for its whole life. Pointers tell an unset option from an explicit zero.
+type scannerOptions struct {
+	Formats         []string `json:"formats"`
+	BufferSize      int      `json:"bufferSize"`
+	TimeBudget      *float64 `json:"timeBudget"`
+	DuplicateWindow *float64 `json:"duplicateWindow"`
+	ROIPadding      *float64 `json:"roiPadding"`
+	ROIFrames       *int     `json:"roiFrames"`
+	TryHarder       bool     `json:"tryHarder"`
+	Inverted        bool     `json:"inverted"`
+	Rotated         *bool    `json:"rotated"`
+}
+
+// frameOptions describe a pushed frame, and how many pending frames a scan decodes
+type frameOptions struct {
+	Width     int      `json:"width"`
+	Height    int      `json:"height"`
+	Timestamp *float64 `json:"timestamp"`
+	MaxFrames int      `json:"maxFrames"`
+}
+
+// bufferedFrame is a slot of the ring buffer. Frames are kept as luminance only and slots reuse their buffer.
+type bufferedFrame struct {
+	id            int
+	luma          []byte
+	width, height int
+	timestamp     float64
+}
+
+// scanReader is a gozxing reader. Row readers (1D formats) also try the frame turned a quarter,
+// for barcodes held upright.
+type scanReader struct {
+	reader gozxing.Reader
+	rows   bool
+}
+
+// scanRead is a decoded code, with its points in frame pixels
+type scanRead struct {
+	text      string
+	format    string
+	points    [][2]float64
+	frameID   int
+	timestamp float64
+	tracked   bool
+	rotated   bool
+}
+
+// scannerStats are the counters reported by getScannerStats
+type scannerStats struct {
+	received   int
+	scanned    int
+	dropped    int
+	skipped    int
+	reads      int
+	duplicates int
+	overBudget int
+	roiHits    int
+	scanTime   float64
+}
+
+// barcodeScanner keeps what carries over from one frame to the next: the ring buffer of pending frames,
+// the region where the last code was found and the codes read recently.
+type barcodeScanner struct {
+	formats         []string
+	readers         []scanReader
+	hints           map[gozxing.DecodeHintType]interface{}
+	timeBudget      time.Duration
+	duplicateWindow float64
+	roiPadding      float64
+	roiFrames       int
+	inverted        bool
+	rotated         bool
+
+	frames  []bufferedFrame
+	oldest  int
+	pending int
+	nextID  int
+	pixels  []byte
+	turned  []byte
+
+	roi         image.Rectangle
+	roiFrame    image.Point
+	roiMisses   int
+	firstReader int
+
+	seen  map[string]float64
+	stats scannerStats
+}
+
+// scanners holds the scanners by the ID returned by createScanner
+var scanners = map[string]*barcodeScanner{}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires at least %d arguments (%s)":                                     "%s requiert au moins %d arguments (%s)",
+	"%s requires at least 1 argument (%s)":                                       "%s requiert au moins 1 argument (%s)",
+	"Invalid frame: %v":                                                          "Image invalide: %v",
+	"Invalid options: %v":                                                        "Options invalides: %v",
+	"Unknown scanner %q (it may already be closed)":                              "Scanner inconnu %q (il a peut-être déjà été fermé)",
+	"Unsupported locale %q (available: %s)":                                      "Langue %q non prise en charge (disponibles: %s)",
+	"bufferSize must be between 1 and %d":                                        "bufferSize doit être compris entre 1 et %d",
+	"duplicateWindow must not be negative":                                       "duplicateWindow ne doit pas être négatif",
+	"expected %d bytes (RGBA) or %d bytes (grayscale) for a %dx%d frame, got %d": "%d octets (RGBA) ou %d octets (niveaux de gris) attendus pour une image de %dx%d, %d reçus",
+	"expected ImageData, a Uint8Array, a Uint8ClampedArray or an ArrayBuffer":    "ImageData, un Uint8Array, un Uint8ClampedArray ou un ArrayBuffer est attendu",
+	"formats must not be empty":                                                  "formats ne doit pas être vide",
+	"frame is %dx%d pixels, the limit is %d pixels":                              "l'image fait %dx%d pixels, la limite est de %d pixels",
+	"frame width and height are required":                                        "la largeur et la hauteur de l'image sont requises",
+	"maxFrames must not be negative":                                             "maxFrames ne doit pas être négatif",
+	"roiFrames must not be negative":                                             "roiFrames ne doit pas être négatif",
+	"roiPadding must not be negative":                                            "roiPadding ne doit pas être négatif",
+	"setLocale requires exactly 1 argument (locale)":                             "setLocale requiert exactement 1 argument (locale)",
+	"timeBudget must be positive":                                                "timeBudget doit être positif",
+	"unsupported format %q (expected %s)":                                        "format %q non pris en charge (%s attendu)",
+}
+
+// createScanner - Create a scanner for a stream of camera frames. The options are fixed for its life:
+// formats, bufferSize (pending frames kept), timeBudget (ms per frame), duplicateWindow (ms),
+// roiPadding and roiFrames (region tracking), tryHarder, inverted and rotated.
+func createScanner(this js.Value, args []js.Value) interface{} {
+	var options scannerOptions
+	if len(args) > 0 {
+		if err := decodeOptions(args[0], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	scanner, err := newScanner(options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	id := newID()
+	scanners[id] = scanner
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Created scanner %s (%s)\n", id, strings.Join(scanner.formats, ", "))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"scannerId":       id,
+		"formats":         stringValues(scanner.formats),
+		"bufferSize":      len(scanner.frames),
+		"timeBudget":      milliseconds(scanner.timeBudget),
+		"duplicateWindow": scanner.duplicateWindow,
+		"roiFrames":       scanner.roiFrames,
+	})
+}
+
+// pushFrame - Add a camera frame to the ring buffer of a scanner without decoding it. The frame is an
+// ImageData, or RGBA or grayscale pixels with width and height in the options. When the buffer is full
+// the oldest pending frame is dropped, so a slow decoder never falls more than bufferSize frames behind.
+func pushFrame(this js.Value, args []js.Value) interface{} {
+	scanner, _, failure := scannerArgument(args, "pushFrame", 2, "scannerId, frame")
+	if failure != nil {
+		return failure
+	}
+
+	frame, err := scanner.push(args[1], optionalValue(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid frame: %v", err),
+		})
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"frameId":   frame.id,
+		"width":     frame.width,
+		"height":    frame.height,
+		"timestamp": frame.timestamp,
+		"pending":   scanner.pending,
+		"dropped":   scanner.stats.dropped,
+	})
+}
+
+// scanFrames - Decode the pending frames of a scanner, oldest first, each within the time budget.
+// With maxFrames, only the newest frames are decoded and older ones are skipped. Returns the new reads;
+// codes already read within the duplicate window are counted but not returned again.
+func scanFrames(this js.Value, args []js.Value) interface{} {
+	scanner, _, failure := scannerArgument(args, "scanFrames", 1, "scannerId")
+	if failure != nil {
+		return failure
+	}
+
+	var options frameOptions
+	if err := decodeOptions(optionalValue(args, 1), &options); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+	if options.MaxFrames < 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", localize("maxFrames must not be negative")),
+		})
+	}
+
+	return js.ValueOf(scanner.scanPending(options.MaxFrames))
+}
+
+// scanFrame - Push a frame and decode it right away, skipping frames still pending. This is the usual
+// call of a requestAnimationFrame or requestVideoFrameCallback loop.
+func scanFrame(this js.Value, args []js.Value) interface{} {
+	scanner, _, failure := scannerArgument(args, "scanFrame", 2, "scannerId, frame")
+	if failure != nil {
+		return failure
+	}
+
+	if _, err := scanner.push(args[1], optionalValue(args, 2)); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid frame: %v", err),
+		})
+	}
+
+	return js.ValueOf(scanner.scanPending(1))
+}
+
+// getScannerStats - Report the frame counters, read counters, average decode time and tracked region of a scanner
+func getScannerStats(this js.Value, args []js.Value) interface{} {
+	scanner, id, failure := scannerArgument(args, "getScannerStats", 1, "scannerId")
+	if failure != nil {
+		return failure
+	}
+
+	stats := scanner.stats
+	average := 0.0
+	if stats.scanned > 0 {
+		average = roundMilliseconds(stats.scanTime / float64(stats.scanned))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"scannerId":       id,
+		"formats":         stringValues(scanner.formats),
+		"framesReceived":  stats.received,
+		"framesScanned":   stats.scanned,
+		"framesDropped":   stats.dropped,
+		"framesSkipped":   stats.skipped,
+		"pending":         scanner.pending,
+		"reads":           stats.reads,
+		"duplicates":      stats.duplicates,
+		"budgetExceeded":  stats.overBudget,
+		"roiHits":         stats.roiHits,
+		"averageScanTime": average,
+		"roi":             scanner.roiValue(),
+	})
+}
+
+// resetScanner - Forget pending frames, the tracked region, recent reads and counters, e.g. when the camera changes
+func resetScanner(this js.Value, args []js.Value) interface{} {
+	scanner, id, failure := scannerArgument(args, "resetScanner", 1, "scannerId")
+	if failure != nil {
+		return failure
+	}
+
+	scanner.reset()
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Reset scanner %s\n", id)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"scannerId": id,
+		"reset":     true,
+	})
+}
+
+// closeScanner - Free a scanner and its frame buffers
+func closeScanner(this js.Value, args []js.Value) interface{} {
+	_, id, failure := scannerArgument(args, "closeScanner", 1, "scannerId")
+	if failure != nil {
+		return failure
+	}
+
+	delete(scanners, id)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Closed scanner %s\n", id)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"scannerId": id,
+		"closed":    true,
+	})
+}
+
+// scannerArgument checks the argument count and looks up the scanner passed first
+func scannerArgument(args []js.Value, function string, count int, names string) (*barcodeScanner, string, interface{}) {
+	if len(args) < count {
+		message := localize("%s requires at least %d arguments (%s)", function, count, names)
+		if count == 1 {
+			message = localize("%s requires at least 1 argument (%s)", function, names)
+		}
+		return nil, "", js.ValueOf(map[string]interface{}{"error": message})
+	}
+	id := args[0].String()
+	scanner, ok := scanners[id]
+	if !ok {
+		return nil, "", js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown scanner %q (it may already be closed)", id),
+		})
+	}
+	return scanner, id, nil
+}
+
+// newScanner validates the options and prepares the readers of the selected formats
+func newScanner(options scannerOptions) (*barcodeScanner, error) {
+	formats, err := selectFormats(options.Formats)
+	if err != nil {
+		return nil, err
+	}
+
+	bufferSize := defaultBufferSize
+	if options.BufferSize != 0 {
+		if options.BufferSize < 1 || options.BufferSize > maxBufferSize {
+			return nil, errors.New(localize("bufferSize must be between 1 and %d", maxBufferSize))
+		}
+		bufferSize = options.BufferSize
+	}
+
+	timeBudget := defaultTimeBudget
+	if options.TimeBudget != nil {
+		if *options.TimeBudget <= 0 {
+			return nil, errors.New(localize("timeBudget must be positive"))
+		}
+		timeBudget = *options.TimeBudget
+	}
+
+	window := defaultDuplicateWindow
+	if options.DuplicateWindow != nil {
+		if *options.DuplicateWindow < 0 {
+			return nil, errors.New(localize("duplicateWindow must not be negative"))
+		}
+		window = *options.DuplicateWindow
+	}
+
+	padding := defaultROIPadding
+	if options.ROIPadding != nil {
+		if *options.ROIPadding < 0 {
+			return nil, errors.New(localize("roiPadding must not be negative"))
+		}
+		padding = *options.ROIPadding
+	}
+
+	roiFrames := defaultROIFrames
+	if options.ROIFrames != nil {
+		if *options.ROIFrames < 0 {
+			return nil, errors.New(localize("roiFrames must not be negative"))
+		}
+		roiFrames = *options.ROIFrames
+	}
+
+	scanner := &barcodeScanner{
+		timeBudget:      time.Duration(timeBudget * float64(time.Millisecond)),
+		duplicateWindow: window,
+		roiPadding:      padding,
+		roiFrames:       roiFrames,
+		inverted:        options.Inverted,
+		rotated:         options.Rotated == nil || *options.Rotated,
+		frames:          make([]bufferedFrame, bufferSize),
+		nextID:          1,
+		seen:            map[string]float64{},
+		hints:           map[gozxing.DecodeHintType]interface{}{},
+	}
+
+	possible := make([]gozxing.BarcodeFormat, len(formats))
+	for i, format := range formats {
+		scanner.formats = append(scanner.formats, format.name)
+		possible[i] = format.format
+	}
+	scanner.hints[gozxing.DecodeHintType_POSSIBLE_FORMATS] = possible
+	if options.TryHarder {
+		scanner.hints[gozxing.DecodeHintType_TRY_HARDER] = true
+	}
+	scanner.readers = newReaders(formats, scanner.hints)
+
+	return scanner, nil
+}
+
+// selectFormats resolves format names, all formats when none are given, in the order of scanFormats
+func selectFormats(names []string) ([]scanFormat, error) {
+	if names == nil {
+		return scanFormats, nil
+	}
+	if len(names) == 0 {
+		return nil, errors.New(localize("formats must not be empty"))
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for _, format := range scanFormats {
+			if format.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			known := make([]string, len(scanFormats))
+			for i, format := range scanFormats {
+				known[i] = format.name
+			}
+			return nil, errors.New(localize("unsupported format %q (expected %s)", name, strings.Join(known, ", ")))
+		}
+		wanted[name] = true
+	}
+
+	formats := []scanFormat{}
+	for _, format := range scanFormats {
+		if wanted[format.name] {
+			formats = append(formats, format)
+		}
+	}
+	return formats, nil
+}
+
+// newReaders creates one reader per format, except EAN and UPC which share a reader that finds
+// the start guard of a row once for all of them
+func newReaders(formats []scanFormat, hints map[gozxing.DecodeHintType]interface{}) []scanReader {
+	readers := []scanReader{}
+	upcean := false
+	for _, format := range formats {
+		switch format.format {
+		case gozxing.BarcodeFormat_QR_CODE:
+			readers = append(readers, scanReader{reader: qrcode.NewQRCodeReader()})
+		case gozxing.BarcodeFormat_DATA_MATRIX:
+			readers = append(readers, scanReader{reader: datamatrix.NewDataMatrixReader()})
+		case gozxing.BarcodeFormat_EAN_13, gozxing.BarcodeFormat_EAN_8, gozxing.BarcodeFormat_UPC_A, gozxing.BarcodeFormat_UPC_E:
+			if !upcean {
+				upcean = true
+				readers = append(readers, scanReader{reader: oned.NewMultiFormatUPCEANReader(hints), rows: true})
+			}
+		case gozxing.BarcodeFormat_CODE_128:
+			readers = append(readers, scanReader{reader: oned.NewCode128Reader(), rows: true})
+		case gozxing.BarcodeFormat_CODE_39:
+			readers = append(readers, scanReader{reader: oned.NewCode39Reader(), rows: true})
+		case gozxing.BarcodeFormat_CODE_93:
+			readers = append(readers, scanReader{reader: oned.NewCode93Reader(), rows: true})
+		case gozxing.BarcodeFormat_ITF:
+			readers = append(readers, scanReader{reader: oned.NewITFReader(), rows: true})
+		case gozxing.BarcodeFormat_CODABAR:
+			readers = append(readers, scanReader{reader: oned.NewCodaBarReader(), rows: true})
+		}
+	}
+	return readers
+}
+
+// push converts a frame to luminance into the next slot of the ring buffer
+func (s *barcodeScanner) push(value, optionsValue js.Value) (*bufferedFrame, error) {
+	var options frameOptions
+	if err := decodeOptions(optionsValue, &options); err != nil {
+		return nil, err
+	}
+
+	// ImageData, or any {data, width, height} object such as a decoded VideoFrame copy
+	data := value
+	width, height := options.Width, options.Height
+	if value.Type() == js.TypeObject && value.Get("data").Type() == js.TypeObject {
+		data = value.Get("data")
+		if width == 0 {
+			width = intProperty(value, "width")
+		}
+		if height == 0 {
+			height = intProperty(value, "height")
+		}
+	}
+
+	if data.Type() != js.TypeObject {
+		return nil, errors.New(localize("expected ImageData, a Uint8Array, a Uint8ClampedArray or an ArrayBuffer"))
+	}
+	if data.InstanceOf(js.Global().Get("ArrayBuffer")) {
+		data = js.Global().Get("Uint8Array").New(data)
+	}
+	if !data.InstanceOf(js.Global().Get("Uint8Array")) && !data.InstanceOf(js.Global().Get("Uint8ClampedArray")) {
+		return nil, errors.New(localize("expected ImageData, a Uint8Array, a Uint8ClampedArray or an ArrayBuffer"))
+	}
+	if width <= 0 || height <= 0 {
+		return nil, errors.New(localize("frame width and height are required"))
+	}
+	if width*height > maxFramePixels {
+		return nil, errors.New(localize("frame is %dx%d pixels, the limit is %d pixels", width, height, maxFramePixels))
+	}
+
+	pixels := width * height
+	length := data.Get("length").Int()
+	if length != pixels && length != pixels*4 {
+		return nil, errors.New(localize("expected %d bytes (RGBA) or %d bytes (grayscale) for a %dx%d frame, got %d",
+			pixels*4, pixels, width, height, length))
+	}
+
+	// A full buffer drops its oldest pending frame
+	if s.pending == len(s.frames) {
+		s.oldest = (s.oldest + 1) % len(s.frames)
+		s.pending--
+		s.stats.dropped++
+	}
+	frame := &s.frames[(s.oldest+s.pending)%len(s.frames)]
+	if cap(frame.luma) < pixels {
+		frame.luma = make([]byte, pixels)
+	}
+	frame.luma = frame.luma[:pixels]
+
+	if length == pixels {
+		js.CopyBytesToGo(frame.luma, data)
+	} else {
+		if cap(s.pixels) < length {
+			s.pixels = make([]byte, length)
+		}
+		s.pixels = s.pixels[:length]
+		js.CopyBytesToGo(s.pixels, data)
+		for i := range frame.luma {
+			p := s.pixels[i*4 : i*4+3]
+			// BT.601 luma in fixed point, alpha is ignored
+			frame.luma[i] = byte((77*int(p[0]) + 150*int(p[1]) + 29*int(p[2]) + 128) >> 8)
+		}
+	}
+
+	frame.id = s.nextID
+	frame.width = width
+	frame.height = height
+	if options.Timestamp != nil {
+		frame.timestamp = *options.Timestamp
+	} else {
+		frame.timestamp = js.Global().Get("Date").Call("now").Float()
+	}
+	s.nextID++
+	s.pending++
+	s.stats.received++
+
+	return frame, nil
+}
+
+// scanPending decodes the pending frames, keeping only the newest maxFrames when maxFrames is positive
+func (s *barcodeScanner) scanPending(maxFrames int) map[string]interface{} {
+	started := time.Now()
+
+	skipped := 0
+	if maxFrames > 0 && s.pending > maxFrames {
+		skipped = s.pending - maxFrames
+		s.oldest = (s.oldest + skipped) % len(s.frames)
+		s.pending = maxFrames
+		s.stats.skipped += skipped
+	}
+
+	results := []interface{}{}
+	scanned, duplicates, overBudget := 0, 0, 0
+	for s.pending > 0 {
+		frame := &s.frames[s.oldest]
+		s.oldest = (s.oldest + 1) % len(s.frames)
+		s.pending--
+
+		read, exceeded := s.decode(frame)
+		scanned++
+		if exceeded {
+			overBudget++
+		}
+		if read == nil {
+			continue
+		}
+		if s.duplicate(read) {
+			duplicates++
+			continue
+		}
+
+		s.stats.reads++
+		results = append(results, read.value())
+
+		if !silentMode {
+			fmt.Printf("Go WASM: Read %s %q in frame %d\n", read.format, read.text, read.frameID)
+		}
+	}
+
+	return map[string]interface{}{
+		"results":        results,
+		"frames":         scanned,
+		"skipped":        skipped,
+		"duplicates":     duplicates,
+		"budgetExceeded": overBudget,
+		"roi":            s.roiValue(),
+		"elapsed":        roundMilliseconds(milliseconds(time.Since(started))),
+	}
+}
+
+// decode looks for a code in a frame: first in the region where the last code was found, then in the
+// whole frame, then in the frame turned a quarter for upright 1D codes. The time budget is checked
+// between decode attempts, so it reports whether attempts were left out rather than cutting one short.
+func (s *barcodeScanner) decode(frame *bufferedFrame) (*scanRead, bool) {
+	started := time.Now()
+	deadline := started.Add(s.timeBudget)
+	defer func() {
+		s.stats.scanned++
+		s.stats.scanTime += milliseconds(time.Since(started))
+	}()
+
+	size := image.Pt(frame.width, frame.height)
+	tracking := s.roiFrames > 0 && !s.roi.Empty() && s.roiFrame == size && s.roiMisses < s.roiFrames
+	if !tracking {
+		s.roi = image.Rectangle{}
+	}
+
+	attempts := 0
+	if tracking {
+		read, exceeded := s.decodeArea(frame, s.roi, false, deadline, &attempts)
+		if read != nil {
+			s.stats.roiHits++
+			read.tracked = true
+			s.track(read, size)
+			return read, false
+		}
+		if exceeded {
+			s.roiMisses++
+			s.stats.overBudget++
+			return nil, true
+		}
+	}
+
+	read, exceeded := s.decodeArea(frame, image.Rectangle{Max: size}, false, deadline, &attempts)
+	if read == nil && !exceeded && s.rotated && s.hasRowReaders() {
+		read, exceeded = s.decodeArea(frame, image.Rect(0, 0, frame.height, frame.width), true, deadline, &attempts)
+	}
+	if read != nil {
+		s.track(read, size)
+		return read, false
+	}
+
+	s.roiMisses++
+	if exceeded {
+		s.stats.overBudget++
+	}
+	return nil, exceeded
+}
+
+// decodeArea runs the readers on an area of the frame, starting with the reader that read the last code.
+// Areas of a turned frame are in turned pixels.
+func (s *barcodeScanner) decodeArea(frame *bufferedFrame, area image.Rectangle, turned bool, deadline time.Time, attempts *int) (*scanRead, bool) {
+	luma, width, height := frame.luma, frame.width, frame.height
+	if turned {
+		s.turned = turnQuarter(s.turned, frame.luma, frame.width, frame.height)
+		luma, width, height = s.turned, frame.height, frame.width
+	}
+
+	source, err := gozxing.NewPlanarYUVLuminanceSource(luma, width, height, area.Min.X, area.Min.Y, area.Dx(), area.Dy(), false)
+	if err != nil {
+		return nil, false
+	}
+	sources := []gozxing.LuminanceSource{source}
+	if s.inverted {
+		sources = append(sources, source.Invert())
+	}
+
+	for _, source := range sources {
+		bitmap, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(source))
+		if err != nil {
+			continue
+		}
+		for _, i := range s.readerOrder() {
+			reader := s.readers[i]
+			if turned && !reader.rows {
+				continue
+			}
+			// The first attempt always runs, so a tight budget still decodes something. The next
+			// frame starts with the reader the budget cut off, so every format gets its turn.
+			if *attempts > 0 && time.Now().After(deadline) {
+				s.firstReader = i
+				return nil, true
+			}
+			*attempts++
+
+			result, err := reader.reader.Decode(bitmap, s.hints)
+			if err != nil {
+				continue
+			}
+			s.firstReader = i
+			return s.newRead(result, frame, area, turned), false
+		}
+	}
+	return nil, false
+}
+
+// readerOrder lists the reader indexes in turn from firstReader
+func (s *barcodeScanner) readerOrder() []int {
+	order := make([]int, len(s.readers))
+	for i := range order {
+		order[i] = (s.firstReader + i) % len(s.readers)
+	}
+	return order
+}
+
+// hasRowReaders tells whether a 1D format is enabled
+func (s *barcodeScanner) hasRowReaders() bool {
+	for _, reader := range s.readers {
+		if reader.rows {
+			return true
+		}
+	}
+	return false
+}
+
+// newRead converts a gozxing result, moving its points from the area back to frame pixels
+func (s *barcodeScanner) newRead(result *gozxing.Result, frame *bufferedFrame, area image.Rectangle, turned bool) *scanRead {
+	read := &scanRead{
+		text:      result.GetText(),
+		format:    formatName(result.GetBarcodeFormat()),
+		frameID:   frame.id,
+		timestamp: frame.timestamp,
+		rotated:   turned,
+	}
+	for _, point := range result.GetResultPoints() {
+		if point == nil {
+			continue
+		}
+		x := point.GetX() + float64(area.Min.X)
+		y := point.GetY() + float64(area.Min.Y)
+		if turned {
+			// The turned frame is the frame rotated clockwise: (x, y) there is (y, height-1-x) here
+			x, y = y, float64(frame.height-1)-x
+		}
+		read.points = append(read.points, [2]float64{x, y})
+	}
+	return read
+}
+
+// track centres the region of interest on a read. 1D codes only give two points on a row, so the region
+// is at least half as high as it is wide. A region almost as large as the frame is not worth trying first.
+func (s *barcodeScanner) track(read *scanRead, frame image.Point) {
+	s.roiMisses = 0
+	s.roi = image.Rectangle{}
+	if s.roiFrames == 0 || len(read.points) == 0 {
+		return
+	}
+
+	minX, minY, maxX, maxY := boundsOf(read.points)
+	extent := math.Max(maxX-minX, maxY-minY)
+	width := math.Max(math.Max(maxX-minX, extent/2), minROISize)
+	height := math.Max(math.Max(maxY-minY, extent/2), minROISize)
+	width += 2 * width * s.roiPadding
+	height += 2 * height * s.roiPadding
+
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	roi := image.Rect(
+		int(math.Floor(cx-width/2)), int(math.Floor(cy-height/2)),
+		int(math.Ceil(cx+width/2)), int(math.Ceil(cy+height/2)),
+	).Intersect(image.Rectangle{Max: frame})
+	if roi.Empty() || roi.Dx()*roi.Dy()*10 > frame.X*frame.Y*6 {
+		return
+	}
+	s.roi = roi
+	s.roiFrame = frame
+}
+
+// duplicate tells whether the same code was read within the duplicate window, and remembers the read.
+// A code held in front of the camera keeps being suppressed, as each read extends its window.
+func (s *barcodeScanner) duplicate(read *scanRead) bool {
+	if s.duplicateWindow <= 0 {
+		return false
+	}
+
+	key := read.format + "\x00" + read.text
+	last, seen := s.seen[key]
+	for other, at := range s.seen {
+		// Timestamps that went back belong to a restarted clock
+		if read.timestamp-at >= s.duplicateWindow || at > read.timestamp {
+			delete(s.seen, other)
+		}
+	}
+	s.seen[key] = read.timestamp
+
+	if seen && read.timestamp >= last && read.timestamp-last < s.duplicateWindow {
+		s.stats.duplicates++
+		return true
+	}
+	return false
+}
+
+// reset empties the ring buffer and forgets the tracked region, recent reads and counters
+func (s *barcodeScanner) reset() {
+	s.oldest = 0
+	s.pending = 0
+	s.roi = image.Rectangle{}
+	s.roiMisses = 0
+	s.firstReader = 0
+	s.seen = map[string]float64{}
+	s.stats = scannerStats{}
+}
+
+// roiValue describes the tracked region in frame pixels, or null when the whole frame is searched
+func (s *barcodeScanner) roiValue() interface{} {
+	if s.roi.Empty() || s.roiMisses >= s.roiFrames {
+		return nil
+	}
+	return map[string]interface{}{
+		"x":      s.roi.Min.X,
+		"y":      s.roi.Min.Y,
+		"width":  s.roi.Dx(),
+		"height": s.roi.Dy(),
+	}
+}
+
+// bufferedBytes counts the memory held by the frame buffers of a scanner
+func (s *barcodeScanner) bufferedBytes() int {
+	size := cap(s.pixels) + cap(s.turned)
+	for _, frame := range s.frames {
+		size += cap(frame.luma)
+	}
+	return size
+}
+
+// value converts a read to the result object returned to JavaScript
+func (r *scanRead) value() map[string]interface{} {
+	points := make([]interface{}, len(r.points))
+	for i, point := range r.points {
+		points[i] = map[string]interface{}{
+			"x": roundCoordinate(point[0]),
+			"y": roundCoordinate(point[1]),
+		}
+	}
+
+	var bounds interface{}
+	if len(r.points) > 0 {
+		minX, minY, maxX, maxY := boundsOf(r.points)
+		bounds = map[string]interface{}{
+			"x":      roundCoordinate(minX),
+			"y":      roundCoordinate(minY),
+			"width":  roundCoordinate(maxX - minX),
+			"height": roundCoordinate(maxY - minY),
+		}
+	}
+
+	return map[string]interface{}{
+		"text":      r.text,
+		"format":    r.format,
+		"points":    points,
+		"bounds":    bounds,
+		"frameId":   r.frameID,
+		"timestamp": r.timestamp,
+		"tracked":   r.tracked,
+		"rotated":   r.rotated,
+	}
+}
+
+// turnQuarter rotates a luminance frame clockwise into dst, growing dst when needed
+func turnQuarter(dst, luma []byte, width, height int) []byte {
+	if cap(dst) < len(luma) {
+		dst = make([]byte, len(luma))
+	}
+	dst = dst[:len(luma)]
+	for y := 0; y < height; y++ {
+		row := luma[y*width : (y+1)*width]
+		for x, v := range row {
+			dst[x*height+height-1-y] = v
+		}
+	}
+	return dst
+}
+
+// boundsOf returns the bounding box of points
+func boundsOf(points [][2]float64) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, point := range points {
+		minX, maxX = math.Min(minX, point[0]), math.Max(maxX, point[0])
+		minY, maxY = math.Min(minY, point[1]), math.Max(maxY, point[1])
+	}
+	return minX, minY, maxX, maxY
+}
+
+// formatName returns the API name of a gozxing format
+func formatName(format gozxing.BarcodeFormat) string {
+	for _, f := range scanFormats {
+		if f.format == format {
+			return f.name
+		}
+	}
+	return strings.ToLower(format.String())
+}
+
+// decodeOptions reads an options object, or its JSON text, into target
+func decodeOptions(value js.Value, target interface{}) error {
+	switch value.Type() {
+	case js.TypeUndefined, js.TypeNull:
+		return nil
+	case js.TypeObject:
+		value = js.Global().Get("JSON").Call("stringify", value)
+	}
+	return json.Unmarshal([]byte(value.String()), target)
+}
+
+// optionalValue returns the argument at index, or undefined when it was not passed
+func optionalValue(args []js.Value, index int) js.Value {
+	if len(args) > index {
+		return args[index]
+	}
+	return js.Undefined()
+}
+
+// intProperty reads a numeric property, 0 when it is missing
+func intProperty(value js.Value, name string) int {
+	if v := value.Get(name); v.Type() == js.TypeNumber {
+		return v.Int()
+	}
+	return 0
+}
+
+// stringValues converts strings for js.ValueOf
+func stringValues(values []string) []interface{} {
+	converted := make([]interface{}, len(values))
+	for i, value := range values {
+		converted[i] = value
+	}
+	return converted
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func roundMilliseconds(ms float64) float64 {
+	return math.Round(ms*100) / 100
+}
+
+func roundCoordinate(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"continuous-scanning",
+	"frame-ring-buffer",
+	"roi-tracking",
+	"time-budget",
+	"duplicate-suppression",
+	"qr-code",
+	"data-matrix",
+	"1d-barcodes",
+	"inverted-codes",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and open scanners so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	pending, buffered := 0, 0
+	for _, scanner := range scanners {
+		pending += scanner.pending
+		buffered += scanner.bufferedBytes()
+	}
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"scanners":      len(scanners),
+		"pendingFrames": pending,
+		"bufferedBytes": buffered,
+	}))
+}
+
+// releaseResources - Close every scanner and return freed memory to the runtime
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{
+		"scanners": len(scanners),
+	}
+	scanners = map[string]*barcodeScanner{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "barcode-scan-wasm",
+		"version":         moduleVersion,
+		"description":     "Continuous camera scanning module: frame ring buffer, region tracking, time budget and duplicate suppression",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"createScanner",
+		"pushFrame",
+		"scanFrames",
+		"scanFrame",
+		"getScannerStats",
+		"resetScanner",
+		"closeScanner",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register scanner functions
+	js.Global().Set("createScanner", js.FuncOf(createScanner))
+	js.Global().Set("getScannerStats", js.FuncOf(getScannerStats))
+	js.Global().Set("resetScanner", js.FuncOf(resetScanner))
+	js.Global().Set("closeScanner", js.FuncOf(closeScanner))
+
+	// Register frame functions
+	js.Global().Set("pushFrame", js.FuncOf(pushFrame))
+	js.Global().Set("scanFrames", js.FuncOf(scanFrames))
+	js.Global().Set("scanFrame", js.FuncOf(scanFrame))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM Barcode Scan module ready!")
+	fmt.Println("Available functions: createScanner, pushFrame, scanFrames, scanFrame, getScannerStats, resetScanner, closeScanner")
+
+	// Keep the program alive
+	select {}
+}
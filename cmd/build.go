@@ -8,6 +8,7 @@ import (
 	"wasm-manager/internal/config"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var buildCmd = &cobra.Command{
@@ -15,11 +16,19 @@ var buildCmd = &cobra.Command{
 	Short: "Build WASM modules with optimizations",
 	Long: `Build WebAssembly modules with advanced optimizations using parallel processing.
 
+With --wrapper (or wrapper: true in the build section of the config file),
+the build also writes main.js next to every main.wasm, an ES module that loads
+the module in a Web Worker and exposes its functions as Promises, and
+main.worker.js, the worker it starts. A module whose wrapper cannot be
+generated (for example because its module.json is invalid) is still built; the
+failure is reported as a warning.
+
 Examples:
   wasm-manager build                    # Build all modules
   wasm-manager build math-wasm          # Build specific module
   wasm-manager build --workers 8        # Use 8 workers
-  wasm-manager build --no-optimize      # Skip optimizations`,
+  wasm-manager build --no-optimize      # Skip optimizations
+  wasm-manager build --wrapper          # Also write the main.js Web Worker loader`,
 	RunE: runBuild,
 }
 
@@ -28,7 +37,9 @@ var (
 	buildCompress  bool
 	buildIntegrity bool
 	buildClean     bool
+	buildWrapper   bool
 	buildModules   []string
+	buildBaseURL   string
 )
 
 func init() {
@@ -38,18 +49,30 @@ func init() {
 	buildCmd.Flags().BoolVar(&buildCompress, "compress", true, "create compressed versions")
 	buildCmd.Flags().BoolVar(&buildIntegrity, "integrity", true, "generate integrity hashes")
 	buildCmd.Flags().BoolVar(&buildClean, "clean", false, "clean before build")
+	buildCmd.Flags().BoolVar(&buildWrapper, "wrapper", false, "write the main.js Web Worker loader and main.worker.js")
 	buildCmd.Flags().StringSliceVar(&buildModules, "modules", []string{}, "specific modules to build")
+	buildCmd.Flags().StringVar(&buildBaseURL, "base-url", "", "URL prefix used in generated SRI snippets")
+
+	// Flags left unset fall back to the build section of the config file
+	for _, name := range []string{"optimize", "compress", "integrity", "clean", "wrapper", "base-url"} {
+		viper.BindPFlag("build."+name, buildCmd.Flags().Lookup(name))
+	}
 }
 
 func runBuild(cmd *cobra.Command, args []string) error {
 	cfg := &config.BuildConfig{
 		Workers:           getWorkerCount(),
-		Optimize:          buildOptimize,
-		Compress:          buildCompress,
-		GenerateIntegrity: buildIntegrity,
-		Clean:             buildClean,
+		Optimize:          viper.GetBool("build.optimize"),
+		Compress:          viper.GetBool("build.compress"),
+		GenerateIntegrity: viper.GetBool("build.integrity"),
+		IntegrityBaseURL:  viper.GetString("build.base-url"),
+		Clean:             viper.GetBool("build.clean"),
+		Wrapper:           viper.GetBool("build.wrapper"),
 		Verbose:           verbose,
 	}
+	if err := viper.UnmarshalKey("modules", &cfg.Modules); err != nil {
+		return fmt.Errorf("invalid module settings: %w", err)
+	}
 
 	// Determine which modules to build
 	var targetModules []string
@@ -63,7 +86,16 @@ func runBuild(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("failed to discover modules: %w", err)
 		}
-		targetModules = modules
+		// Modules marked skip in the config file are only built when named
+		for _, module := range modules {
+			if cfg.Modules[module].Skip {
+				if verbose {
+					fmt.Printf("⏭️  Skipping %s (skip is set in the config file)\n", module)
+				}
+				continue
+			}
+			targetModules = append(targetModules, module)
+		}
 	}
 
 	if len(targetModules) == 0 {
@@ -81,6 +113,18 @@ func runBuild(cmd *cobra.Command, args []string) error {
 	// Print build summary
 	builder.PrintBuildSummary(results)
 
+	// Refresh the repository-level checksums so they cover every built module
+	if cfg.GenerateIntegrity {
+		allModules, err := builder.DiscoverModules(".")
+		if err != nil {
+			return fmt.Errorf("failed to discover modules: %w", err)
+		}
+		if _, err := builder.GenerateChecksums(".", allModules, cfg.IntegrityBaseURL); err != nil {
+			return fmt.Errorf("checksum generation failed: %w", err)
+		}
+		fmt.Printf("🔐 Updated %s and %s (run 'wasm-manager integrity' for SRI snippets)\n", builder.ChecksumsFile, builder.IntegrityFile)
+	}
+
 	return nil
 }
 
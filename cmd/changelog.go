@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"wasm-manager/internal/builder"
+	"wasm-manager/internal/changelog"
+
+	"github.com/spf13/cobra"
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Maintain module changelogs from conventional commits",
+	Long: `Maintain the changelog of each module from the conventional-commit messages
+(feat:, fix(scope):, refactor!: ...) of the commits that touched it since its
+last version bump, i.e. the last commit changing "version" in its module.json.
+
+Subcommands:
+• add    - record the pending changes in module.json, or CHANGELOG.md with --markdown
+• render - print release notes as Markdown
+
+Commit types:
+• feat, fix, perf, refactor and docs get their own section
+• chore, ci, test, style and build are hidden unless --all is given
+• "!" after the type or a BREAKING CHANGE: footer marks a breaking change`,
+}
+
+var changelogAddCmd = &cobra.Command{
+	Use:   "add [module...]",
+	Short: "Record pending changes in module.json or CHANGELOG.md",
+	Long: `Collect the conventional commits of each module since its last version bump
+and record them in the changelog map of its module.json. Changes already
+recorded for the same version are kept, so add can run after every commit.
+
+Examples:
+  wasm-manager changelog add                          # All modules, current versions
+  wasm-manager changelog add math-wasm --version 0.3.0
+  wasm-manager changelog add --markdown               # Write <module>/CHANGELOG.md instead
+  wasm-manager changelog add qr-wasm --since v1.2.0 --dry-run`,
+	RunE: runChangelogAdd,
+}
+
+var changelogRenderCmd = &cobra.Command{
+	Use:   "render [module...]",
+	Short: "Render release notes as Markdown",
+	Long: `Render the changelog recorded in each module.json as Markdown, or with
+--unreleased the changes pending since the last version bump.
+
+Examples:
+  wasm-manager changelog render                       # Recorded release notes of all modules
+  wasm-manager changelog render --unreleased          # What the next release will contain
+  wasm-manager changelog render pdf-wasm -o NOTES.md
+  wasm-manager changelog render --unreleased --format json`,
+	RunE: runChangelogRender,
+}
+
+var (
+	changelogVersion    string
+	changelogSince      string
+	changelogAll        bool
+	changelogMarkdown   bool
+	changelogDryRun     bool
+	changelogUnreleased bool
+	changelogFormat     string
+	changelogOutput     string
+)
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+	changelogCmd.AddCommand(changelogAddCmd)
+	changelogCmd.AddCommand(changelogRenderCmd)
+
+	changelogCmd.PersistentFlags().StringVar(&changelogSince, "since", "", "start of the commit range (default: last version bump)")
+	changelogCmd.PersistentFlags().BoolVar(&changelogAll, "all", false, "include chore, ci, test, style and build commits")
+
+	changelogAddCmd.Flags().StringVar(&changelogVersion, "version", "", "release version (default: the version in module.json), which add without --markdown also writes to module.json")
+	changelogAddCmd.Flags().BoolVar(&changelogMarkdown, "markdown", false, "write <module>/CHANGELOG.md instead of module.json")
+	changelogAddCmd.Flags().BoolVar(&changelogDryRun, "dry-run", false, "print the changes without writing them")
+
+	changelogRenderCmd.Flags().BoolVar(&changelogUnreleased, "unreleased", false, "render changes pending since the last version bump")
+	changelogRenderCmd.Flags().StringVarP(&changelogFormat, "format", "f", "markdown", "output format (markdown, json)")
+	changelogRenderCmd.Flags().StringVarP(&changelogOutput, "output", "o", "", "write the release notes to a file instead of stdout")
+}
+
+// changelogModules returns the modules named on the command line, or every module
+func changelogModules(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	modules, err := builder.DiscoverModules(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover modules: %w", err)
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("no modules found")
+	}
+	return modules, nil
+}
+
+func runChangelogAdd(cmd *cobra.Command, args []string) error {
+	modules, err := changelogModules(args)
+	if err != nil {
+		return err
+	}
+
+	cfg := &changelog.Config{RootDir: ".", Since: changelogSince, All: changelogAll}
+	for _, module := range modules {
+		release, err := changelog.Unreleased(cfg, module)
+		if err != nil {
+			return fmt.Errorf("%s: %w", module, err)
+		}
+		if changelogVersion != "" {
+			release.Version = changelogVersion
+		}
+
+		if changelogDryRun {
+			fmt.Println(release.Markdown())
+			continue
+		}
+
+		if changelogMarkdown {
+			err = changelog.WriteMarkdown(".", release)
+		} else {
+			err = changelog.WriteModuleJSON(".", release)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", module, err)
+		}
+
+		fmt.Printf("✅ %s %s: %d changes recorded\n", module, release.Version, len(release.Entries))
+		if verbose {
+			for _, change := range release.Changes() {
+				fmt.Printf("   • %s\n", change)
+			}
+		}
+	}
+
+	return nil
+}
+
+func runChangelogRender(cmd *cobra.Command, args []string) error {
+	modules, err := changelogModules(args)
+	if err != nil {
+		return err
+	}
+
+	cfg := &changelog.Config{RootDir: ".", Since: changelogSince, All: changelogAll}
+	var releases []*changelog.Release
+	for _, module := range modules {
+		var release *changelog.Release
+		if changelogUnreleased {
+			release, err = changelog.Unreleased(cfg, module)
+		} else {
+			release, err = changelog.FromModuleJSON(".", module)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", module, err)
+		}
+		releases = append(releases, release)
+	}
+
+	var output string
+	switch changelogFormat {
+	case "markdown":
+		var b strings.Builder
+		for i, release := range releases {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "# %s\n\n%s", release.Module, release.Markdown())
+		}
+		output = b.String()
+	case "json":
+		data, err := json.MarshalIndent(releases, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode release notes: %w", err)
+		}
+		output = string(data) + "\n"
+	default:
+		return fmt.Errorf("unknown format %q (expected markdown or json)", changelogFormat)
+	}
+
+	if changelogOutput == "" {
+		fmt.Print(output)
+		return nil
+	}
+	if err := os.WriteFile(changelogOutput, []byte(output), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", changelogOutput, err)
+	}
+	fmt.Printf("✅ Release notes written to %s\n", changelogOutput)
+	return nil
+}
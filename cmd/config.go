@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"wasm-manager/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the wasm-manager config file",
+	Long: `Manage the .wasm-manager.yaml config file.
+
+The config file is read from the current directory, then from the home
+directory, or from the file given with --config. Every command validates it
+before running: unknown settings, values of the wrong type, worker counts out
+of range and overrides of modules that do not exist are reported as errors.
+
+Subcommands:
+• init - write a documented config file with the default settings`,
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a documented config file with the default settings",
+	Long: `Write a .wasm-manager.yaml listing every setting with its default value and
+a description. An existing file is kept unless --force is given.
+
+Examples:
+  wasm-manager config init                          # .wasm-manager.yaml in the current directory
+  wasm-manager config init --force                  # Replace an existing file
+  wasm-manager config init -o ~/.wasm-manager.yaml  # Defaults for every repository`,
+	Args: cobra.NoArgs,
+	RunE: runConfigInit,
+}
+
+var (
+	configOutput string
+	configForce  bool
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+
+	configInitCmd.Flags().StringVarP(&configOutput, "output", "o", config.FileName, "file to write")
+	configInitCmd.Flags().BoolVar(&configForce, "force", false, "overwrite an existing file")
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(configOutput); err == nil && !configForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite it)", configOutput)
+	}
+
+	if err := os.WriteFile(configOutput, []byte(config.FileTemplate), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", configOutput, err)
+	}
+
+	fmt.Printf("✅ Wrote %s\n", configOutput)
+	return nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"wasm-manager/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the build environment",
+	Long: `Diagnose the local environment and repository for common build problems.
+
+Checks:
+• Go toolchain version and js/wasm support
+• GOOS/GOARCH and module-related environment variables
+• shared/wasm_exec.js matching the installed toolchain
+• Optimization and compression tools (superset of install-tools --check)
+• Module misconfigurations (go.mod, go.sum, build constraints, module.json)
+
+Examples:
+  wasm-manager doctor                   # Run all checks
+  wasm-manager doctor --strict          # Treat warnings as failures
+  wasm-manager doctor -v                # Also list healthy modules`,
+	RunE: runDoctor,
+}
+
+var doctorStrict bool
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().BoolVar(&doctorStrict, "strict", false, "treat warnings as failures")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	cfg := &doctor.Config{
+		Strict:  doctorStrict,
+		Verbose: verbose,
+	}
+
+	report := doctor.New(cfg).Run()
+	doctor.PrintReport(report, verbose)
+
+	if !report.Healthy(doctorStrict) {
+		return fmt.Errorf("doctor found %d errors and %d warnings", report.Errors, report.Warnings)
+	}
+
+	fmt.Println("🎉 Environment looks healthy!")
+	return nil
+}
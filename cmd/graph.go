@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"wasm-manager/internal/builder"
+	"wasm-manager/internal/graph"
+
+	"github.com/spf13/cobra"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph [module...]",
+	Short: "Analyze module dependencies and version skew",
+	Long: `Analyze the go.mod files of all modules, show which dependencies they share,
+detect the same dependency required at different versions and suggest a
+common version set.
+
+Formats:
+• text - summary with skew report and go get commands (default)
+• dot  - Graphviz graph, render with: dot -Tsvg graph.dot -o graph.svg
+• json - machine readable graph
+
+Examples:
+  wasm-manager graph                         # Summary for all modules
+  wasm-manager graph --format dot -o deps.dot
+  wasm-manager graph --format json --direct-only
+  wasm-manager graph pdf-wasm qr-wasm        # Compare specific modules`,
+	RunE: runGraph,
+}
+
+var (
+	graphFormat     string
+	graphOutput     string
+	graphDirectOnly bool
+	graphFailOnSkew bool
+)
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	graphCmd.Flags().StringVarP(&graphFormat, "format", "f", "text", "output format (text, dot, json)")
+	graphCmd.Flags().StringVarP(&graphOutput, "output", "o", "", "write the graph to a file instead of stdout")
+	graphCmd.Flags().BoolVar(&graphDirectOnly, "direct-only", false, "ignore indirect dependencies")
+	graphCmd.Flags().BoolVar(&graphFailOnSkew, "fail-on-skew", false, "exit with an error when version skew is detected")
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	modules := args
+	if len(modules) == 0 {
+		discovered, err := builder.DiscoverModules(".")
+		if err != nil {
+			return fmt.Errorf("failed to discover modules: %w", err)
+		}
+		modules = discovered
+	}
+
+	if len(modules) == 0 {
+		return fmt.Errorf("no modules found to analyze")
+	}
+
+	g, err := graph.Analyze(".", modules, graph.Options{DirectOnly: graphDirectOnly})
+	if err != nil {
+		return fmt.Errorf("dependency analysis failed: %w", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if graphOutput != "" {
+		f, err := os.Create(graphOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", graphOutput, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch graphFormat {
+	case "text":
+		if graphOutput != "" {
+			return fmt.Errorf("--output requires --format dot or json")
+		}
+		g.PrintSummary(verbose)
+	case "dot":
+		if err := g.WriteDOT(out); err != nil {
+			return fmt.Errorf("failed to write DOT graph: %w", err)
+		}
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(g); err != nil {
+			return fmt.Errorf("failed to write JSON graph: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown format %q (expected text, dot or json)", graphFormat)
+	}
+
+	if graphOutput != "" {
+		fmt.Printf("✅ Dependency graph written to %s\n", graphOutput)
+	}
+
+	if graphFailOnSkew && len(g.Skews) > 0 {
+		return fmt.Errorf("version skew detected in %d dependencies", len(g.Skews))
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+
+	"wasm-manager/internal/builder"
+
+	"github.com/spf13/cobra"
+)
+
+var integrityCmd = &cobra.Command{
+	Use:   "integrity [module...]",
+	Short: "Generate SHA256SUMS and SRI snippets",
+	Long: `Generate the repository-level SHA256SUMS file and integrity.json from the
+built artifacts, and print ready-to-paste Subresource Integrity snippets.
+
+Outputs:
+• SHA256SUMS     - checksums of wasm_exec.js and main.wasm(.gz/.br), verify with: sha256sum -c SHA256SUMS
+• integrity.json - sha384 SRI values plus <script>, <link rel="preload"> and fetch() snippets
+
+Examples:
+  wasm-manager integrity                                  # All built modules
+  wasm-manager integrity math-wasm                        # Print snippets for one module
+  wasm-manager integrity --base-url https://cdn.example.com/wasm`,
+	RunE: runIntegrity,
+}
+
+var integrityBaseURL string
+
+func init() {
+	rootCmd.AddCommand(integrityCmd)
+
+	integrityCmd.Flags().StringVar(&integrityBaseURL, "base-url", "", "URL prefix used in generated snippets")
+}
+
+func runIntegrity(cmd *cobra.Command, args []string) error {
+	// SHA256SUMS always covers every module so that filtering only affects the printed snippets
+	modules, err := builder.DiscoverModules(".")
+	if err != nil {
+		return fmt.Errorf("failed to discover modules: %w", err)
+	}
+
+	manifest, err := builder.GenerateChecksums(".", modules, integrityBaseURL)
+	if err != nil {
+		return fmt.Errorf("checksum generation failed: %w", err)
+	}
+
+	if len(manifest.Modules) == 0 {
+		return fmt.Errorf("no built modules found, run 'wasm-manager build' first")
+	}
+
+	if len(args) > 0 {
+		selected := make(map[string]bool)
+		for _, module := range args {
+			selected[module] = true
+		}
+		filtered := manifest.Modules[:0]
+		for _, entry := range manifest.Modules {
+			if selected[entry.Module] {
+				filtered = append(filtered, entry)
+			}
+		}
+		if len(filtered) == 0 {
+			return fmt.Errorf("no built artifacts found for %v", args)
+		}
+		manifest.Modules = filtered
+	}
+
+	builder.PrintIntegritySnippets(manifest)
+	return nil
+}
@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+
+	"wasm-manager/internal/builder"
+	"wasm-manager/internal/config"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -38,10 +43,17 @@ func Execute() error {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := initConfig(cmd); err != nil {
+			// A broken config file is not a usage error
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
+	}
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is .wasm-manager.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is "+config.FileName+")")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().IntVarP(&workers, "workers", "w", 0, "number of worker goroutines (default: auto-detect)")
 
@@ -50,7 +62,14 @@ func init() {
 	viper.BindPFlag("workers", rootCmd.PersistentFlags().Lookup("workers"))
 }
 
-func initConfig() {
+// initConfig reads the config file, validates it and applies its global settings that were not
+// set on the command line. Commands that do not use the config skip it, so that 'config init' can
+// replace an invalid file.
+func initConfig(cmd *cobra.Command) error {
+	if cmd == configInitCmd || cmd.Name() == "help" {
+		return nil
+	}
+
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
 	} else {
@@ -66,7 +85,32 @@ func initConfig() {
 
 	viper.AutomaticEnv()
 
-	if err := viper.ReadInConfig(); err == nil && verbose {
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if verbose {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
+
+	// Validate the file on its own, without the flags and environment viper merges in
+	file := viper.New()
+	file.SetConfigFile(viper.ConfigFileUsed())
+	if filepath.Ext(viper.ConfigFileUsed()) == "" {
+		file.SetConfigType("yaml")
+	}
+	if err := file.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	modules, _ := builder.DiscoverModules(".")
+	if err := config.ValidateFile(file.AllSettings(), modules); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", viper.ConfigFileUsed(), err)
+	}
+
+	verbose = viper.GetBool("verbose")
+	workers = viper.GetInt("workers")
+	return nil
 }
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"wasm-manager/internal/wasmexec"
+
+	"github.com/spf13/cobra"
+)
+
+var runtimeCmd = &cobra.Command{
+	Use:   "runtime",
+	Short: "Install the wasm_exec.js of the active Go toolchain",
+	Long: `Detect the active Go toolchain and copy its wasm_exec.js into runtime/, along
+with runtime.json recording the Go version it came from.
+
+wasm_exec.js must come from the Go release that built a module: the host
+functions a binary imports change between releases. 'wasm-manager test
+--integration' loads every built module with runtime/wasm_exec.js and warns
+about modules built with a different Go version.
+
+Examples:
+  wasm-manager runtime                      # Install or update runtime/wasm_exec.js
+  wasm-manager runtime --check              # Fail if it does not match the toolchain (CI)`,
+	Args: cobra.NoArgs,
+	RunE: runRuntime,
+}
+
+var runtimeCheck bool
+
+func init() {
+	rootCmd.AddCommand(runtimeCmd)
+
+	runtimeCmd.Flags().BoolVar(&runtimeCheck, "check", false, "only check that runtime/wasm_exec.js matches the toolchain")
+}
+
+func runRuntime(cmd *cobra.Command, args []string) error {
+	tc, err := wasmexec.DetectToolchain()
+	if err != nil {
+		return fmt.Errorf("failed to detect the Go toolchain: %w", err)
+	}
+	fmt.Printf("🔧 Go toolchain: %s (%s)\n", tc.GoVersion, tc.GoRoot)
+
+	scriptPath := filepath.Join(wasmexec.Dir, wasmexec.ScriptFile)
+	current, err := wasmexec.Load(".")
+	if err == nil && current.Matches(tc) {
+		fmt.Printf("✅ %s is up to date\n", scriptPath)
+		return nil
+	}
+
+	if runtimeCheck {
+		switch {
+		case err != nil:
+			return fmt.Errorf("%s is not installed (run 'wasm-manager runtime')", scriptPath)
+		case current.Modified:
+			return fmt.Errorf("%s was modified after it was copied (run 'wasm-manager runtime')", scriptPath)
+		}
+		return fmt.Errorf("%s is from %s, the toolchain is %s (run 'wasm-manager runtime')",
+			scriptPath, current.GoVersion, tc.GoVersion)
+	}
+
+	previous, err := wasmexec.Install(".", tc)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil && previous.GoVersion != tc.GoVersion {
+		fmt.Printf("📥 Updated %s from %s to %s\n", scriptPath, previous.GoVersion, tc.GoVersion)
+		fmt.Println("⚠️  Rebuild the modules so they match it: wasm-manager build")
+	} else {
+		fmt.Printf("📥 Copied %s to %s\n", tc.ScriptPath, scriptPath)
+	}
+	fmt.Println("💡 Run 'wasm-manager test --integration' to load the built modules with it")
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"net"
+	"strconv"
+
+	"wasm-manager/internal/config"
+	"wasm-manager/internal/devserver"
+
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve modules for browser development with hot reload",
+	Long: `Serve the module directories over HTTP for local development.
+
+Only the module directories and wasm_exec.js (at /wasm_exec.js, /shared/wasm_exec.js
+and /runtime/wasm_exec.js) are served; the rest of the repository, including .git,
+is not. The server listens on 127.0.0.1 by default. Use --host 0.0.0.0 to reach it
+from another device on your network.
+
+With hot reload enabled, Go sources of every module are watched. When a module
+changes it is rebuilt and connected pages are notified over a WebSocket; the
+injected client re-instantiates only that WASM module instead of reloading the
+page. Listen for the "wasm-module-reloaded" event to refresh your UI, or use
+wasmHotReload.register(module, handler) to reload through your own loader.
+
+The client script is injected into served HTML pages automatically and is also
+available at /__wasm-manager/hot-reload.js.
+
+Examples:
+  wasm-manager serve                     # Serve on 127.0.0.1:8080 with hot reload
+  wasm-manager serve --port 3000
+  wasm-manager serve --host 0.0.0.0      # Listen on every interface
+  wasm-manager serve --hot-reload=false  # Static file server only
+  wasm-manager serve --optimize          # Run wasm-opt on every rebuild`,
+	RunE: runServe,
+}
+
+var (
+	serveHost      string
+	servePort      int
+	serveHotReload bool
+	serveOptimize  bool
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveHost, "host", "127.0.0.1", "address to listen on")
+	serveCmd.Flags().IntVarP(&servePort, "port", "p", 8080, "port to listen on")
+	serveCmd.Flags().BoolVar(&serveHotReload, "hot-reload", true, "rebuild modules on change and notify browsers")
+	serveCmd.Flags().BoolVar(&serveOptimize, "optimize", false, "optimize rebuilt modules with wasm-opt")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	buildCfg := config.DefaultBuildConfig()
+	buildCfg.Optimize = serveOptimize
+	buildCfg.Compress = false
+
+	s := devserver.New(&devserver.Config{
+		Addr:      net.JoinHostPort(serveHost, strconv.Itoa(servePort)),
+		HotReload: serveHotReload,
+		Build:     buildCfg,
+		Verbose:   verbose,
+	})
+
+	return s.ListenAndServe()
+}
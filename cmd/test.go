@@ -20,6 +20,11 @@ Tests:
 • Module.json documentation
 • WASM binary functionality (if built)
 
+Integration tests load every built main.wasm in Node.js with runtime/wasm_exec.js,
+installed by 'wasm-manager runtime'. They fail when the module imports host
+functions the script does not define or does not start, and warn when it was
+built with another Go version than the script.
+
 Examples:
   wasm-manager test                     # Test all modules
   wasm-manager test math-wasm           # Test specific module
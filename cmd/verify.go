@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"wasm-manager/internal/builder"
+
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [module...]",
+	Short: "Verify built artifacts against integrity files and module.json",
+	Long: `Recompute the SHA-256 of every built main.wasm, main.wasm.gz and main.wasm.br
+in parallel and exit with an error on any mismatch, as a pre-deploy gate.
+
+Checks:
+• main.wasm against main.wasm.integrity (and any other <artifact>.integrity file)
+• every artifact against SHA256SUMS, when the repository has one
+• main.wasm and main.wasm.gz sizes against size and gzipSize in module.json
+
+Modules that have not been built are skipped, unless named on the command line.
+
+Examples:
+  wasm-manager verify                       # All built modules
+  wasm-manager verify math-wasm qr-wasm     # Fail if these are not built or do not match
+  wasm-manager verify --format json         # Machine readable report for CI`,
+	RunE: runVerify,
+}
+
+var verifyFormat string
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().StringVarP(&verifyFormat, "format", "f", "text", "output format (text, json)")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	if verifyFormat != "text" && verifyFormat != "json" {
+		return fmt.Errorf("unknown format %q (expected text or json)", verifyFormat)
+	}
+
+	modules := args
+	if len(modules) == 0 {
+		discovered, err := builder.DiscoverModules(".")
+		if err != nil {
+			return fmt.Errorf("failed to discover modules: %w", err)
+		}
+		modules = discovered
+	}
+	if len(modules) == 0 {
+		return fmt.Errorf("no modules found to verify")
+	}
+
+	start := time.Now()
+	results, err := builder.VerifyArtifacts(".", modules, getWorkerCount())
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	// Modules named explicitly must have been built
+	if len(args) > 0 {
+		for _, result := range results {
+			if !result.Built && len(result.Problems) == 0 {
+				result.Problems = append(result.Problems, "main.wasm file not found")
+			}
+		}
+	}
+
+	verified, failed, problems, files := 0, 0, 0, 0
+	for _, result := range results {
+		files += len(result.Artifacts)
+		switch {
+		case !result.OK():
+			failed++
+			problems += result.ProblemCount()
+		case result.Built:
+			verified++
+		}
+	}
+
+	if verifyFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return fmt.Errorf("failed to write JSON report: %w", err)
+		}
+	} else {
+		fmt.Println("\n🔐 Artifact Verification")
+		fmt.Println("========================")
+		for _, result := range results {
+			switch {
+			case !result.OK():
+				fmt.Printf("❌ %s\n", result.Module)
+				for _, problem := range result.Problems {
+					fmt.Printf("   • %s\n", problem)
+				}
+				for _, artifact := range result.Artifacts {
+					for _, problem := range artifact.Problems {
+						fmt.Printf("   • %s: %s\n", artifact.Path, problem)
+					}
+				}
+			case !result.Built:
+				if verbose {
+					fmt.Printf("⏭️  %s: not built\n", result.Module)
+				}
+			default:
+				fmt.Printf("✅ %s: %d artifacts verified\n", result.Module, len(result.Artifacts))
+				if verbose {
+					for _, artifact := range result.Artifacts {
+						fmt.Printf("   %s  %s\n", artifact.SHA256, artifact.Path)
+					}
+				}
+			}
+		}
+		fmt.Printf("\n📊 %d files hashed in %v: %d modules verified, %d failed\n",
+			files, time.Since(start).Round(time.Millisecond), verified, failed)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("verification found %d problems in %d modules", problems, failed)
+	}
+	if verified == 0 {
+		return fmt.Errorf("no built modules found, run 'wasm-manager build' first")
+	}
+	return nil
+}
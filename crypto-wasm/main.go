@@ -5,22 +5,42 @@ package main
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall/js"
 	"time"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -54,215 +74,1179 @@ func setSilentMode(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(silentMode)
 }
 
-// hashSHA256 - Generate SHA256 hash
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires exactly 1 argument (%s)":              "%s requiert exactement 1 argument (%s)",
+	"Failed to generate key: %v":                       "Échec de la génération de la clé: %v",
+	"%s requires exactly 2 arguments (%s)":             "%s requiert exactement 2 arguments (%s)",
+	"Invalid key format: %v":                           "Format de clé invalide: %v",
+	"Failed to create cipher: %v":                      "Échec de la création du chiffrement: %v",
+	"Failed to create GCM: %v":                         "Échec de la création du mode GCM: %v",
+	"Failed to generate nonce: %v":                     "Échec de la génération du nonce: %v",
+	"Invalid encrypted data format: %v":                "Format de données chiffrées invalide: %v",
+	"Encrypted data too short":                         "Données chiffrées trop courtes",
+	"Failed to decrypt: %v":                            "Échec du déchiffrement: %v",
+	"Failed to generate RSA key pair: %v":              "Échec de la génération de la paire de clés RSA: %v",
+	"Failed to marshal public key: %v":                 "Échec de l'encodage de la clé publique: %v",
+	"Failed to parse PEM block containing public key":  "Impossible de lire le bloc PEM de la clé publique",
+	"Failed to parse public key: %v":                   "Impossible de lire la clé publique: %v",
+	"Key is not an RSA public key":                     "La clé n'est pas une clé publique RSA",
+	"Failed to encrypt: %v":                            "Échec du chiffrement: %v",
+	"Failed to parse PEM block containing private key": "Impossible de lire le bloc PEM de la clé privée",
+	"Failed to parse private key: %v":                  "Impossible de lire la clé privée: %v",
+	"%s requires at least 2 arguments (%s)":            "%s requiert au moins 2 arguments (%s)",
+	"Invalid payload JSON: %v":                         "JSON du payload invalide: %v",
+	"Failed to sign token: %v":                         "Échec de la signature du jeton: %v",
+	"unexpected signing method: %v":                    "méthode de signature inattendue: %v",
+	"Failed to parse token: %v":                        "Impossible de lire le jeton: %v",
+	"Token is invalid":                                 "Le jeton est invalide",
+	"Failed to extract claims":                         "Impossible d'extraire les claims",
+	"%s requires at least 1 argument (%s)":             "%s requiert au moins 1 argument (%s)",
+	"Failed to hash password: %v":                      "Échec du hachage du mot de passe: %v",
+	"Failed to generate UUID: %v":                      "Échec de la génération de l'UUID: %v",
+	"Length must be between 1 and 1024":                "La longueur doit être comprise entre 1 et 1024",
+	"Failed to generate random bytes: %v":              "Échec de la génération des octets aléatoires: %v",
+	"Failed to decode base64: %v":                      "Échec du décodage base64: %v",
+	"setLocale requires exactly 1 argument (locale)":   "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":            "Langue %q non prise en charge (disponibles: %s)",
+	"%s requires exactly 3 arguments (%s)":             "%s requiert exactement 3 arguments (%s)",
+	"Invalid wrapping key format: %v":                  "Format de clé d'enveloppement invalide: %v",
+	"Invalid wrapped key format: %v":                   "Format de clé enveloppée invalide: %v",
+	"Failed to wrap key: %v":                           "Échec de l'enveloppement de la clé: %v",
+	"Failed to unwrap key: %v":                         "Échec du désenveloppement de la clé: %v",
+	"Invalid envelope format: %v":                      "Format d'enveloppe invalide: %v",
+	"Envelope has no wrappedKey":                       "L'enveloppe ne contient pas de wrappedKey",
+	"key to wrap has invalid length %d":                "longueur de clé à envelopper invalide: %d",
+	"wrapped key has invalid length %d":                "longueur de clé enveloppée invalide: %d",
+	"integrity check failed":                           "échec du contrôle d'intégrité",
+	"%s requires at least 3 arguments (%s)":            "%s requiert au moins 3 arguments (%s)",
+	"Invalid options format: %v":                       "Format d'options invalide: %v",
+	"unsupported key encoding %q":                      "encodage de clé %q non pris en charge",
+	"Invalid secret format: %v":                        "Format de secret invalide: %v",
+	"Unsupported HMAC algorithm %q":                    "Algorithme HMAC %q non pris en charge",
+	"Invalid expiry: %v":                               "Expiration invalide: %v",
+	"Expiry must be after the start time":              "L'expiration doit suivre la date de début",
+	"Token is malformed":                               "Le jeton est mal formé",
+	"Token signature does not match":                   "La signature du jeton ne correspond pas",
+	"Token expired at %s":                              "Le jeton a expiré le %s",
+	"Token is not valid before %s":                     "Le jeton n'est pas valide avant le %s",
+	"Token does not grant access to %s":                "Le jeton ne donne pas accès à %s",
+	"Unsupported signature encoding %q":                "Encodage de signature %q non pris en charge",
+	"signature is not a %d-byte value":                 "la signature n'est pas une valeur de %d octets",
+	"Invalid signature format: %v":                     "Format de signature invalide: %v",
+	"Signature does not match":                         "La signature ne correspond pas",
+	"Failed to generate salt: %v":                      "Échec de la génération du sel: %v",
+	"Invalid salt format: %v":                          "Format de sel invalide: %v",
+	"Salt must be at least 8 bytes":                    "Le sel doit faire au moins 8 octets",
+	"%s must be at least %d":                           "%s doit valoir au moins %d",
+	"%s must be between %d and %d":                     "%s doit être compris entre %d et %d",
+	"N must be a power of 2 greater than 1":            "N doit être une puissance de 2 supérieure à 1",
+	"Parameters need more than %d MiB of memory":       "Les paramètres demandent plus de %d Mio de mémoire",
+	"Failed to derive key: %v":                         "Échec de la dérivation de la clé: %v",
+	"Unsupported curve %q (available: %s)":             "Courbe %q non prise en charge (disponibles: %s)",
+	"Failed to generate ECDH key pair: %v":             "Échec de la génération de la paire de clés ECDH: %v",
+	"Failed to marshal private key: %v":                "Échec de l'encodage de la clé privée: %v",
+	"Key is not an ECDH private key":                   "La clé n'est pas une clé privée ECDH",
+	"Key is not an ECDH public key":                    "La clé n'est pas une clé publique ECDH",
+	"Peer public key uses curve %v, not %v":            "La clé publique du pair utilise la courbe %v, pas %v",
+	"Failed to compute shared secret: %v":              "Échec du calcul du secret partagé: %v",
+	"Unsupported KDF %q":                               "KDF %q non prise en charge",
+	"Invalid nonce format: %v":                         "Format de nonce invalide: %v",
+	"Nonce must be 12 or 16 bytes, got %d":             "Le nonce doit faire 12 ou 16 octets, %d reçus",
+	"Invalid tag format: %v":                           "Format de tag invalide: %v",
+	"Unsupported hash algorithm %q (available: %s)":    "Algorithme de hachage %q non pris en charge (disponibles: %s)",
+	"update expects a Uint8Array or a string, got %s":  "update attend un Uint8Array ou une chaîne, %s reçu",
+	"Unsupported output %q (available: %s)":            "Sortie %q non prise en charge (disponibles: %s)",
+	"Key length must be between %d and %d bytes":       "La longueur de la clé doit être comprise entre %d et %d octets",
+}
+
+// bytesArgument reads binary data given as a Uint8Array, or a string taken as its UTF-8 bytes
+func bytesArgument(value js.Value) []byte {
+	if value.InstanceOf(js.Global().Get("Uint8Array")) {
+		data := make([]byte, value.Get("length").Int())
+		js.CopyBytesToGo(data, value)
+		return data
+	}
+	return []byte(value.String())
+}
+
+// encryptedArgument reads encrypted data given as a Uint8Array of raw bytes or as base64 text
+func encryptedArgument(value js.Value) ([]byte, error) {
+	if value.InstanceOf(js.Global().Get("Uint8Array")) {
+		return bytesArgument(value), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(value.String())
+	if err != nil {
+		return nil, fmt.Errorf(localize("Invalid encrypted data format: %v"), err)
+	}
+	return data, nil
+}
+
+// outputValue encodes bytes for an output option: binary gives a Uint8Array, base64 and hex give text,
+// and text gives the bytes as a string
+func outputValue(data []byte, output string) (interface{}, error) {
+	switch strings.ToLower(output) {
+	case "binary":
+		array := js.Global().Get("Uint8Array").New(len(data))
+		js.CopyBytesToJS(array, data)
+		return array, nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(data), nil
+	case "hex":
+		return hex.EncodeToString(data), nil
+	case "text":
+		return string(data), nil
+	}
+	return nil, fmt.Errorf(localize("Unsupported output %q (available: %s)"), output, "binary, base64, hex, text")
+}
+
+// hashOutput encodes a digest in the encoding given as an optional argument, hex by default
+func hashOutput(sum []byte, args []js.Value, index int) (interface{}, error) {
+	encoding := "hex"
+	if len(args) > index && args[index].Type() == js.TypeString {
+		encoding = args[index].String()
+	}
+	return outputValue(sum, encoding)
+}
+
+// hashSHA256 - Generate SHA256 hash, in hex unless the encoding argument asks for base64 or binary
 func hashSHA256(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "hashSHA256", "data"),
+		})
+	}
+
+	data := bytesArgument(args[0])
+	hash := sha256.Sum256(data)
+	result, err := hashOutput(hash[:], args, 1)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: SHA256 hash generated for %d bytes\n", len(data))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"hash": result,
+		"algorithm": "SHA256",
+	})
+}
+
+// hashSHA512 - Generate SHA512 hash, in hex unless the encoding argument asks for base64 or binary
+func hashSHA512(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "hashSHA512", "data"),
+		})
+	}
+
+	data := bytesArgument(args[0])
+	hash := sha512.Sum512(data)
+	result, err := hashOutput(hash[:], args, 1)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: SHA512 hash generated for %d bytes\n", len(data))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"hash": result,
+		"algorithm": "SHA512",
+	})
+}
+
+// hashMD5 - Generate MD5 hash (for legacy support only)
+func hashMD5(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "hashMD5", "data"),
+		})
+	}
+
+	data := bytesArgument(args[0])
+	hash := md5.Sum(data)
+	result, err := hashOutput(hash[:], args, 1)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: MD5 hash generated (WARNING: MD5 is cryptographically broken)\n")
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"hash": result,
+		"algorithm": "MD5",
+		"warning": "MD5 is cryptographically broken and should not be used for security purposes",
+	})
+}
+
+// openHashes counts the createHash handles not digested or released yet, reported by getMemoryStats
+var openHashes = 0
+
+// streamingHashes are the algorithms createHash accepts
+var streamingHashes = map[string]struct {
+	name string
+	new  func() hash.Hash
+}{
+	"sha256": {"SHA256", sha256.New},
+	"sha384": {"SHA384", sha512.New384},
+	"sha512": {"SHA512", sha512.New},
+	"sha1":   {"SHA1", sha1.New},
+	"md5":    {"MD5", md5.New},
+}
+
+// createHash - Start an incremental hash for data too large to pass at once, such as a file read in
+// slices: handle.update(chunk) takes a Uint8Array or a string and returns the handle so calls can be
+// chained, handle.digest(encoding) returns the hash (hex, base64 or binary) and frees the handle, and
+// handle.release() abandons it. The first failure is kept in handle.error and reported by digest().
+func createHash(this js.Value, args []js.Value) interface{} {
+	name := "sha256"
+	if len(args) > 0 && args[0].Type() == js.TypeString {
+		name = args[0].String()
+	}
+	algorithm, ok := streamingHashes[strings.ReplaceAll(strings.ToLower(name), "-", "")]
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported hash algorithm %q (available: %s)", name, "sha256, sha384, sha512, sha1, md5"),
+		})
+	}
+
+	digest := algorithm.new()
+	var buffer []byte
+	var size int64
+	var failure error
+
+	handle := js.Global().Get("Object").New()
+	handle.Set("algorithm", algorithm.name)
+	methods := map[string]js.Func{}
+	release := func() {
+		for name, method := range methods {
+			handle.Delete(name)
+			method.Release()
+		}
+		// release() stays callable, as a plain JS function, for cleanup code that runs after digest()
+		handle.Set("release", js.Global().Get("Function").New())
+		methods = nil
+		digest, buffer = nil, nil
+		openHashes--
+	}
+
+	// Chunks are copied through one buffer reused across calls, so memory stays at the largest chunk
+	methods["update"] = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if failure != nil {
+			return handle
+		}
+		switch {
+		case len(args) < 1:
+			failure = fmt.Errorf(localize("%s requires exactly 1 argument (%s)"), "update", "chunk")
+		case args[0].Type() == js.TypeString:
+			chunk := []byte(args[0].String())
+			digest.Write(chunk)
+			size += int64(len(chunk))
+		case args[0].InstanceOf(js.Global().Get("Uint8Array")):
+			length := args[0].Get("length").Int()
+			if cap(buffer) < length {
+				buffer = make([]byte, length)
+			}
+			js.CopyBytesToGo(buffer[:length], args[0])
+			digest.Write(buffer[:length])
+			size += int64(length)
+		default:
+			failure = fmt.Errorf(localize("update expects a Uint8Array or a string, got %s"), args[0].Type())
+		}
+		if failure != nil {
+			handle.Set("error", failure.Error())
+		}
+		return handle
+	})
+
+	methods["digest"] = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		encoding := "hex"
+		if len(args) > 0 && args[0].Type() == js.TypeString {
+			encoding = strings.ToLower(args[0].String())
+		}
+		if _, err := outputValue(nil, encoding); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		sum := digest.Sum(nil)
+		release()
+		if failure != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": failure.Error(),
+			})
+		}
+		result, _ := outputValue(sum, encoding)
+
+		if !silentMode {
+			fmt.Printf("Go WASM: %s hash generated for %d bytes\n", algorithm.name, size)
+		}
+
+		return js.ValueOf(map[string]interface{}{
+			"hash":      result,
+			"algorithm": algorithm.name,
+			"bytes":     size,
+			"encoding":  encoding,
+		})
+	})
+
+	methods["release"] = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		release()
+		return nil
+	})
+
+	for name, method := range methods {
+		handle.Set(name, method)
+	}
+	openHashes++
+
+	return handle
+}
+
+// HMACOptions tunes hmacSHA256, hmacSHA512 and verifyHMAC
+type HMACOptions struct {
+	Algorithm   string `json:"algorithm"`   // verifyHMAC only: sha256 (default), sha512 or sha1
+	Encoding    string `json:"encoding"`    // signature encoding: hex (default), base64, base64url or binary; detected by verifyHMAC when unset
+	KeyEncoding string `json:"keyEncoding"` // utf8 (default), hex or base64
+}
+
+// hmacHash returns the hash function HMAC uses for an algorithm name, with or without a dash
+func hmacHash(algorithm string) (string, func() hash.Hash, error) {
+	switch strings.ReplaceAll(strings.ToLower(algorithm), "-", "") {
+	case "sha256", "":
+		return "SHA256", sha256.New, nil
+	case "sha512":
+		return "SHA512", sha512.New, nil
+	case "sha1":
+		return "SHA1", sha1.New, nil
+	}
+	return "", nil, fmt.Errorf(localize("Unsupported HMAC algorithm %q"), algorithm)
+}
+
+// signHMAC computes the HMAC of data with a key decoded according to the options
+func signHMAC(data []byte, key string, newHash func() hash.Hash, options HMACOptions) ([]byte, error) {
+	encoding := options.KeyEncoding
+	if encoding == "" {
+		encoding = "utf8"
+	}
+	secret, err := decodeSecret(key, encoding)
+	if err != nil {
+		return nil, fmt.Errorf(localize("Invalid secret format: %v"), err)
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// hmacSign implements hmacSHA256 and hmacSHA512
+func hmacSign(name, algorithm string, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", name, "data, key"),
+		})
+	}
+
+	var options HMACOptions
+	if err := parseOptionsArgument(args, 2, &options); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options format: %v", err),
+		})
+	}
+	algorithm, newHash, _ := hmacHash(algorithm)
+
+	data := bytesArgument(args[0])
+	sum, err := signHMAC(data, args[1].String(), newHash, options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	var signature interface{}
+	encoding := strings.ToLower(options.Encoding)
+	switch encoding {
+	case "hex", "":
+		encoding = "hex"
+		signature = hex.EncodeToString(sum)
+	case "base64":
+		signature = base64.StdEncoding.EncodeToString(sum)
+	case "base64url":
+		signature = base64.RawURLEncoding.EncodeToString(sum)
+	case "binary":
+		signature, _ = outputValue(sum, encoding)
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported signature encoding %q", options.Encoding),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: HMAC-%s signature generated for %d bytes\n", algorithm, len(data))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"signature": signature,
+		"algorithm": "HMAC-" + algorithm,
+		"encoding":  encoding,
+	})
+}
+
+// hmacSHA256 - Sign data with HMAC-SHA256, as webhook senders and API request signing schemes do
+func hmacSHA256(this js.Value, args []js.Value) interface{} {
+	return hmacSign("hmacSHA256", "sha256", args)
+}
+
+// hmacSHA512 - Sign data with HMAC-SHA512
+func hmacSHA512(this js.Value, args []js.Value) interface{} {
+	return hmacSign("hmacSHA512", "sha512", args)
+}
+
+// decodeHMACSignature decodes a received signature of size bytes. A scheme prefix such as the
+// "sha256=" of GitHub webhooks is ignored, and without an encoding hex and every base64 variant are tried.
+func decodeHMACSignature(signature, encoding string, size int) ([]byte, error) {
+	if prefix, rest, ok := strings.Cut(signature, "="); ok && rest != "" {
+		if _, _, err := hmacHash(prefix); err == nil {
+			signature = rest
+		}
+	}
+	signature = strings.TrimSpace(signature)
+
+	var decoders []func(string) ([]byte, error)
+	switch encoding {
+	case "hex":
+		decoders = []func(string) ([]byte, error){hex.DecodeString}
+	case "base64":
+		decoders = []func(string) ([]byte, error){base64.StdEncoding.DecodeString, base64.RawStdEncoding.DecodeString}
+	case "base64url":
+		decoders = []func(string) ([]byte, error){base64.URLEncoding.DecodeString, base64.RawURLEncoding.DecodeString}
+	default:
+		decoders = []func(string) ([]byte, error){hex.DecodeString, base64.StdEncoding.DecodeString,
+			base64.RawStdEncoding.DecodeString, base64.URLEncoding.DecodeString, base64.RawURLEncoding.DecodeString}
+	}
+
+	for _, decode := range decoders {
+		if decoded, err := decode(signature); err == nil && len(decoded) == size {
+			return decoded, nil
+		}
+	}
+	return nil, fmt.Errorf(localize("signature is not a %d-byte value"), size)
+}
+
+// verifyHMAC - Check a received HMAC signature in constant time, e.g. a webhook signature header
+// against the raw request body
+func verifyHMAC(this js.Value, args []js.Value) interface{} {
+	if len(args) < 3 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 3 arguments (%s)", "verifyHMAC", "data, key, signature"),
+		})
+	}
+
+	var options HMACOptions
+	if err := parseOptionsArgument(args, 3, &options); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options format: %v", err),
+		})
+	}
+	algorithm, newHash, err := hmacHash(options.Algorithm)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	encoding := strings.ToLower(options.Encoding)
+	if encoding != "" && encoding != "hex" && encoding != "base64" && encoding != "base64url" && encoding != "binary" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported signature encoding %q", options.Encoding),
+		})
+	}
+
+	expected, err := signHMAC(bytesArgument(args[0]), args[1].String(), newHash, options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	var signature []byte
+	if args[2].InstanceOf(js.Global().Get("Uint8Array")) {
+		signature = bytesArgument(args[2])
+	} else {
+		signature, err = decodeHMACSignature(args[2].String(), encoding, len(expected))
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":     false,
+			"algorithm": "HMAC-" + algorithm,
+			"error":     localize("Invalid signature format: %v", err),
+		})
+	}
+
+	valid := hmac.Equal(signature, expected)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: HMAC-%s verification: %t\n", algorithm, valid)
+	}
+
+	result := map[string]interface{}{
+		"valid":     valid,
+		"algorithm": "HMAC-" + algorithm,
+	}
+	if !valid {
+		result["error"] = localize("Signature does not match")
+	}
+	return js.ValueOf(result)
+}
+
+// generateAESKey - Generate a random AES key
+func generateAESKey(this js.Value, args []js.Value) interface{} {
+	keySize := 32 // Default to 256-bit key
+	if len(args) > 0 {
+		size := args[0].Int()
+		if size == 16 || size == 24 || size == 32 {
+			keySize = size
+		}
+	}
+
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to generate key: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated %d-bit AES key\n", keySize*8)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"key": base64.StdEncoding.EncodeToString(key),
+		"keySize": keySize * 8,
+	})
+}
+
+// AESOptions tunes encryptAES and decryptAES for interoperability with WebCrypto and server-side AES-GCM
+type AESOptions struct {
+	AdditionalData string `json:"additionalData"` // authenticated but not encrypted (AAD), needed again to decrypt
+	Nonce          string `json:"nonce"`          // base64 12 or 16-byte nonce, random by default; never reuse one under a key
+	Detached       bool   `json:"detached"`       // encryptAES: return the nonce, ciphertext and tag separately
+	Tag            string `json:"tag"`            // decryptAES: base64 tag, when not appended to the ciphertext
+	Output         string `json:"output"`         // base64 (default) or binary for encryptAES, text (default), base64 or binary for decryptAES
+}
+
+// aesGCM reads the AES options and key, and creates AES-GCM for the nonce size of the options
+func aesGCM(args []js.Value) (cipher.AEAD, AESOptions, []byte, error) {
+	var options AESOptions
+	if err := parseOptionsArgument(args, 2, &options); err != nil {
+		return nil, options, nil, fmt.Errorf(localize("Invalid options format: %v"), err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(args[1].String())
+	if err != nil {
+		return nil, options, nil, fmt.Errorf(localize("Invalid key format: %v"), err)
+	}
+
+	var nonce []byte
+	if options.Nonce != "" {
+		if nonce, err = base64.StdEncoding.DecodeString(options.Nonce); err != nil {
+			return nil, options, nil, fmt.Errorf(localize("Invalid nonce format: %v"), err)
+		}
+		if len(nonce) != 12 && len(nonce) != 16 {
+			return nil, options, nil, fmt.Errorf(localize("Nonce must be 12 or 16 bytes, got %d"), len(nonce))
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, options, nil, fmt.Errorf(localize("Failed to create cipher: %v"), err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if len(nonce) == 16 {
+		gcm, err = cipher.NewGCMWithNonceSize(block, 16)
+	}
+	if err != nil {
+		return nil, options, nil, fmt.Errorf(localize("Failed to create GCM: %v"), err)
+	}
+	return gcm, options, nonce, nil
+}
+
+// encryptAES - Encrypt data using AES-GCM, optionally with additional authenticated data, a given
+// nonce, and the nonce, ciphertext and tag returned separately
+func encryptAES(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "encryptAES", "data, key"),
+		})
+	}
+
+	data := bytesArgument(args[0])
+	gcm, options, nonce, err := aesGCM(args)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if nonce == nil {
+		nonce = make([]byte, gcm.NonceSize())
+		_, err = rand.Read(nonce)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to generate nonce: %v", err),
+			})
+		}
+	}
+
+	if options.Output == "" {
+		options.Output = "base64"
+	}
+	if _, err := outputValue(nil, options.Output); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	output := func(data []byte) interface{} {
+		value, _ := outputValue(data, options.Output)
+		return value
+	}
+
+	ciphertext := gcm.Seal(nonce, nonce, data, []byte(options.AdditionalData))
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Encrypted %d bytes using AES-GCM\n", len(data))
+	}
+
+	// WebCrypto takes the ciphertext with the tag appended and the nonce (iv) apart; Node and most
+	// server-side libraries take all three apart
+	if options.Detached {
+		sealed := ciphertext[len(nonce):]
+		return js.ValueOf(map[string]interface{}{
+			"nonce":      output(nonce),
+			"ciphertext": output(sealed[:len(sealed)-gcm.Overhead()]),
+			"tag":        output(sealed[len(sealed)-gcm.Overhead():]),
+			"algorithm":  "AES-GCM",
+		})
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"encryptedData": output(ciphertext),
+		"nonce":         output(nonce),
+		"algorithm":     "AES-GCM",
+	})
+}
+
+// decryptAES - Decrypt data using AES-GCM. Without a nonce option the data is the nonce, ciphertext and
+// tag as encryptAES returns them; with one it is the ciphertext and tag, as WebCrypto returns them, or
+// the ciphertext alone when the tag option is given too.
+func decryptAES(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "decryptAES", "encryptedData, key"),
+		})
+	}
+
+	gcm, options, nonce, err := aesGCM(args)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if options.Output == "" {
+		options.Output = "text"
+	}
+	if _, err := outputValue(nil, options.Output); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	encryptedData, err := encryptedArgument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if options.Tag != "" {
+		tag, err := base64.StdEncoding.DecodeString(options.Tag)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid tag format: %v", err),
+			})
+		}
+		encryptedData = append(encryptedData, tag...)
+	}
+
+	nonceSize := gcm.NonceSize()
+	if nonce != nil {
+		nonceSize = 0
+	}
+	if len(encryptedData) < nonceSize+gcm.Overhead() {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Encrypted data too short"),
+		})
+	}
+
+	ciphertext := encryptedData[nonceSize:]
+	if nonce == nil {
+		nonce = encryptedData[:nonceSize]
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(options.AdditionalData))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to decrypt: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Decrypted %d bytes using AES-GCM\n", len(plaintext))
+	}
+
+	decryptedData, _ := outputValue(plaintext, options.Output)
+	return js.ValueOf(map[string]interface{}{
+		"decryptedData": decryptedData,
+		"algorithm":     "AES-GCM",
+	})
+}
+
+// ChaChaOptions tunes the ChaCha20-Poly1305 functions
+type ChaChaOptions struct {
+	AdditionalData string `json:"additionalData"` // authenticated but not encrypted, needed again to decrypt
+	Output         string `json:"output"`         // base64 (default) or binary when encrypting, text (default), base64 or binary when decrypting
+}
+
+// chachaAEAD creates ChaCha20-Poly1305 with 12-byte nonces, or XChaCha20-Poly1305 with 24-byte nonces
+// when extended, from a base64 32-byte key
+func chachaAEAD(keyStr string, extended bool) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(keyStr)
+	if err != nil {
+		return nil, fmt.Errorf(localize("Invalid key format: %v"), err)
+	}
+	var aead cipher.AEAD
+	if extended {
+		aead, err = chacha20poly1305.NewX(key)
+	} else {
+		aead, err = chacha20poly1305.New(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(localize("Failed to create cipher: %v"), err)
+	}
+	return aead, nil
+}
+
+// chachaSeal implements encryptChaCha20 and encryptXChaCha20: the result is the base64 nonce followed by
+// the ciphertext and tag, as with encryptAES
+func chachaSeal(name, algorithm string, extended bool, args []js.Value) interface{} {
+	const output = "base64"
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", name, "data, key"),
+		})
+	}
+
+	options := ChaChaOptions{Output: output}
+	if err := parseOptionsArgument(args, 2, &options); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options format: %v", err),
+		})
+	}
+	if _, err := outputValue(nil, options.Output); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	aead, err := chachaAEAD(args[1].String(), extended)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// 24-byte XChaCha20 nonces are safe to draw at random for any number of messages
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": "hashSHA256 requires exactly 1 argument (data)",
+			"error": localize("Failed to generate nonce: %v", err),
 		})
 	}
 
-	data := args[0].String()
-	hash := sha256.Sum256([]byte(data))
-	result := hex.EncodeToString(hash[:])
+	data := bytesArgument(args[0])
+	ciphertext := aead.Seal(nonce, nonce, data, []byte(options.AdditionalData))
 
 	if !silentMode {
-		fmt.Printf("Go WASM: SHA256 hash generated for %d bytes\n", len(data))
+		fmt.Printf("Go WASM: Encrypted %d bytes using %s\n", len(data), algorithm)
 	}
 
+	encryptedData, _ := outputValue(ciphertext, options.Output)
 	return js.ValueOf(map[string]interface{}{
-		"hash": result,
-		"algorithm": "SHA256",
+		"encryptedData": encryptedData,
+		"algorithm":     algorithm,
 	})
 }
 
-// hashSHA512 - Generate SHA512 hash
-func hashSHA512(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
+// chachaOpen implements decryptChaCha20 and decryptXChaCha20
+func chachaOpen(name, algorithm string, extended bool, args []js.Value) interface{} {
+	const output = "text"
+	if len(args) < 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "hashSHA512 requires exactly 1 argument (data)",
+			"error": localize("%s requires at least 2 arguments (%s)", name, "encryptedData, key"),
 		})
 	}
 
-	data := args[0].String()
-	hash := sha512.Sum512([]byte(data))
-	result := hex.EncodeToString(hash[:])
-
-	if !silentMode {
-		fmt.Printf("Go WASM: SHA512 hash generated for %d bytes\n", len(data))
+	options := ChaChaOptions{Output: output}
+	if err := parseOptionsArgument(args, 2, &options); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options format: %v", err),
+		})
+	}
+	if _, err := outputValue(nil, options.Output); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	aead, err := chachaAEAD(args[1].String(), extended)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"hash": result,
-		"algorithm": "SHA512",
-	})
-}
-
-// hashMD5 - Generate MD5 hash (for legacy support only)
-func hashMD5(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
+	encryptedData, err := encryptedArgument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(encryptedData) < aead.NonceSize()+aead.Overhead() {
 		return js.ValueOf(map[string]interface{}{
-			"error": "hashMD5 requires exactly 1 argument (data)",
+			"error": localize("Encrypted data too short"),
 		})
 	}
 
-	data := args[0].String()
-	hash := md5.Sum([]byte(data))
-	result := hex.EncodeToString(hash[:])
+	nonce, ciphertext := encryptedData[:aead.NonceSize()], encryptedData[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(options.AdditionalData))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to decrypt: %v", err),
+		})
+	}
 
 	if !silentMode {
-		fmt.Printf("Go WASM: MD5 hash generated (WARNING: MD5 is cryptographically broken)\n")
+		fmt.Printf("Go WASM: Decrypted %d bytes using %s\n", len(plaintext), algorithm)
 	}
 
+	decryptedData, _ := outputValue(plaintext, options.Output)
 	return js.ValueOf(map[string]interface{}{
-		"hash": result,
-		"algorithm": "MD5",
-		"warning": "MD5 is cryptographically broken and should not be used for security purposes",
+		"decryptedData": decryptedData,
+		"algorithm":     algorithm,
 	})
 }
 
-// generateAESKey - Generate a random AES key
-func generateAESKey(this js.Value, args []js.Value) interface{} {
-	keySize := 32 // Default to 256-bit key
-	if len(args) > 0 {
-		size := args[0].Int()
-		if size == 16 || size == 24 || size == 32 {
-			keySize = size
+// encryptChaCha20 - Encrypt data with ChaCha20-Poly1305, faster than AES-GCM in WASM where AES has no
+// hardware acceleration; the key is 32 bytes in base64, as generateAESKey returns by default
+func encryptChaCha20(this js.Value, args []js.Value) interface{} {
+	return chachaSeal("encryptChaCha20", "ChaCha20-Poly1305", false, args)
+}
+
+// decryptChaCha20 - Decrypt data encrypted by encryptChaCha20
+func decryptChaCha20(this js.Value, args []js.Value) interface{} {
+	return chachaOpen("decryptChaCha20", "ChaCha20-Poly1305", false, args)
+}
+
+// encryptXChaCha20 - Encrypt data with XChaCha20-Poly1305, whose 24-byte random nonces never collide in
+// practice, even for very many messages under one key
+func encryptXChaCha20(this js.Value, args []js.Value) interface{} {
+	return chachaSeal("encryptXChaCha20", "XChaCha20-Poly1305", true, args)
+}
+
+// decryptXChaCha20 - Decrypt data encrypted by encryptXChaCha20
+func decryptXChaCha20(this js.Value, args []js.Value) interface{} {
+	return chachaOpen("decryptXChaCha20", "XChaCha20-Poly1305", true, args)
+}
+
+// keyWrapIV is the default initial value of RFC 3394, checked on unwrap as an integrity tag
+var keyWrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// aesKeyWrap - Wrap key material with a key-encryption key (AES-KW, RFC 3394)
+func aesKeyWrap(kek, plaintext []byte) ([]byte, error) {
+	if len(plaintext) < 16 || len(plaintext)%8 != 0 {
+		return nil, fmt.Errorf(localize("key to wrap has invalid length %d"), len(plaintext))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(plaintext) / 8
+	out := make([]byte, 8+len(plaintext))
+	copy(out, keyWrapIV)
+	copy(out[8:], plaintext)
+
+	buf := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, out[:8])
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Encrypt(buf, buf)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(buf[:8])^uint64(n*j+i))
+			copy(out[i*8:], buf[8:])
 		}
 	}
+	return out, nil
+}
 
-	key := make([]byte, keySize)
-	_, err := rand.Read(key)
+// aesKeyUnwrap - Unwrap AES-KW key material, failing when the integrity check does not match
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf(localize("wrapped key has invalid length %d"), len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
 	if err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate key: %v", err),
-		})
+		return nil, err
 	}
 
-	if !silentMode {
-		fmt.Printf("Go WASM: Generated %d-bit AES key\n", keySize*8)
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(out[:8])^uint64(n*j+i))
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Decrypt(buf, buf)
+			copy(out[:8], buf[:8])
+			copy(out[i*8:], buf[8:])
+		}
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"key": base64.StdEncoding.EncodeToString(key),
-		"keySize": keySize * 8,
-	})
+	if subtle.ConstantTimeCompare(out[:8], keyWrapIV) != 1 {
+		return nil, fmt.Errorf(localize("integrity check failed"))
+	}
+	return out[8:], nil
 }
 
-// encryptAES - Encrypt data using AES-GCM
-func encryptAES(this js.Value, args []js.Value) interface{} {
+// wrapKey - Wrap a data encryption key with a key-encryption key using AES-KW
+func wrapKey(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "encryptAES requires exactly 2 arguments (data, key)",
+			"error": localize("%s requires exactly 2 arguments (%s)", "wrapKey", "key, wrappingKey"),
 		})
 	}
 
-	data := args[0].String()
-	keyStr := args[1].String()
-
-	key, err := base64.StdEncoding.DecodeString(keyStr)
+	key, err := base64.StdEncoding.DecodeString(args[0].String())
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid key format: %v", err),
+			"error": localize("Invalid key format: %v", err),
 		})
 	}
-
-	block, err := aes.NewCipher(key)
+	kek, err := base64.StdEncoding.DecodeString(args[1].String())
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to create cipher: %v", err),
+			"error": localize("Invalid wrapping key format: %v", err),
 		})
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	wrapped, err := aesKeyWrap(kek, key)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to create GCM: %v", err),
+			"error": localize("Failed to wrap key: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Wrapped %d-byte key using AES-KW\n", len(key))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"wrappedKey": base64.StdEncoding.EncodeToString(wrapped),
+		"algorithm":  "AES-KW",
+	})
+}
+
+// unwrapKey - Recover a data encryption key wrapped with AES-KW
+func unwrapKey(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 2 arguments (%s)", "unwrapKey", "wrappedKey, wrappingKey"),
 		})
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	_, err = rand.Read(nonce)
+	wrapped, err := base64.StdEncoding.DecodeString(args[0].String())
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid wrapped key format: %v", err),
+		})
+	}
+	kek, err := base64.StdEncoding.DecodeString(args[1].String())
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate nonce: %v", err),
+			"error": localize("Invalid wrapping key format: %v", err),
 		})
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, []byte(data), nil)
-	result := base64.StdEncoding.EncodeToString(ciphertext)
+	key, err := aesKeyUnwrap(kek, wrapped)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to unwrap key: %v", err),
+		})
+	}
 
 	if !silentMode {
-		fmt.Printf("Go WASM: Encrypted %d bytes using AES-GCM\n", len(data))
+		fmt.Printf("Go WASM: Unwrapped %d-byte key using AES-KW\n", len(key))
 	}
 
 	return js.ValueOf(map[string]interface{}{
-		"encryptedData": result,
-		"algorithm": "AES-GCM",
+		"key":       base64.StdEncoding.EncodeToString(key),
+		"keySize":   len(key) * 8,
+		"algorithm": "AES-KW",
 	})
 }
 
-// decryptAES - Decrypt data using AES-GCM
-func decryptAES(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
+// rotateEnvelope - Re-wrap the data encryption key of an envelope under a new key-encryption key.
+// The envelope is an object (or JSON string) holding the AES-KW wrappedKey next to the encrypted
+// payload; only wrappedKey changes, so the payload is never decrypted or re-encrypted.
+func rotateEnvelope(this js.Value, args []js.Value) interface{} {
+	if len(args) != 3 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "decryptAES requires exactly 2 arguments (encryptedData, key)",
+			"error": localize("%s requires exactly 3 arguments (%s)", "rotateEnvelope", "oldWrappingKey, newWrappingKey, envelope"),
 		})
 	}
 
-	encryptedDataStr := args[0].String()
-	keyStr := args[1].String()
-
-	key, err := base64.StdEncoding.DecodeString(keyStr)
+	oldKEK, err := base64.StdEncoding.DecodeString(args[0].String())
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid key format: %v", err),
+			"error": localize("Invalid wrapping key format: %v", err),
 		})
 	}
-
-	encryptedData, err := base64.StdEncoding.DecodeString(encryptedDataStr)
+	newKEK, err := base64.StdEncoding.DecodeString(args[1].String())
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid encrypted data format: %v", err),
+			"error": localize("Invalid wrapping key format: %v", err),
 		})
 	}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
+	envelopeJSON := args[2].String()
+	if args[2].Type() == js.TypeObject {
+		envelopeJSON = js.Global().Get("JSON").Call("stringify", args[2]).String()
+	}
+	var envelope map[string]interface{}
+	if err := json.Unmarshal([]byte(envelopeJSON), &envelope); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to create cipher: %v", err),
+			"error": localize("Invalid envelope format: %v", err),
 		})
 	}
-
-	gcm, err := cipher.NewGCM(block)
+	wrappedKey, ok := envelope["wrappedKey"].(string)
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Envelope has no wrappedKey"),
+		})
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to create GCM: %v", err),
+			"error": localize("Invalid wrapped key format: %v", err),
 		})
 	}
 
-	nonceSize := gcm.NonceSize()
-	if len(encryptedData) < nonceSize {
+	key, err := aesKeyUnwrap(oldKEK, wrapped)
+	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": "Encrypted data too short",
+			"error": localize("Failed to unwrap key: %v", err),
 		})
 	}
-
-	nonce, ciphertext := encryptedData[:nonceSize], encryptedData[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	rewrapped, err := aesKeyWrap(newKEK, key)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to decrypt: %v", err),
+			"error": localize("Failed to wrap key: %v", err),
 		})
 	}
+	envelope["wrappedKey"] = base64.StdEncoding.EncodeToString(rewrapped)
 
 	if !silentMode {
-		fmt.Printf("Go WASM: Decrypted %d bytes using AES-GCM\n", len(plaintext))
+		fmt.Printf("Go WASM: Rotated envelope key-encryption key (%d-byte data key)\n", len(key))
 	}
 
 	return js.ValueOf(map[string]interface{}{
-		"decryptedData": string(plaintext),
-		"algorithm": "AES-GCM",
+		"envelope":  envelope,
+		"algorithm": "AES-KW",
 	})
 }
 
@@ -279,7 +1263,7 @@ func generateRSAKeyPair(this js.Value, args []js.Value) interface{} {
 	privateKey, err := rsa.GenerateKey(rand.Reader, keySize)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate RSA key pair: %v", err),
+			"error": localize("Failed to generate RSA key pair: %v", err),
 		})
 	}
 
@@ -294,7 +1278,7 @@ func generateRSAKeyPair(this js.Value, args []js.Value) interface{} {
 	publicKeyPKIX, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to marshal public key: %v", err),
+			"error": localize("Failed to marshal public key: %v", err),
 		})
 	}
 
@@ -315,111 +1299,379 @@ func generateRSAKeyPair(this js.Value, args []js.Value) interface{} {
 	})
 }
 
+// RSAOptions tunes encryptRSA and decryptRSA
+type RSAOptions struct {
+	Output string `json:"output"` // base64 (default) or binary for encryptRSA, text (default), base64 or binary for decryptRSA
+}
+
+// parseRSAOptions reads the optional options argument of encryptRSA and decryptRSA over the default output
+func parseRSAOptions(args []js.Value, output string) (RSAOptions, error) {
+	options := RSAOptions{Output: output}
+	if err := parseOptionsArgument(args, 2, &options); err != nil {
+		return options, fmt.Errorf(localize("Invalid options format: %v"), err)
+	}
+	if _, err := outputValue(nil, options.Output); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
 // encryptRSA - Encrypt data using RSA public key
 func encryptRSA(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
+	if len(args) < 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "encryptRSA requires exactly 2 arguments (data, publicKey)",
+			"error": localize("%s requires at least 2 arguments (%s)", "encryptRSA", "data, publicKey"),
 		})
 	}
 
-	data := args[0].String()
+	data := bytesArgument(args[0])
 	publicKeyStr := args[1].String()
+	options, err := parseRSAOptions(args, "base64")
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
 
 	block, _ := pem.Decode([]byte(publicKeyStr))
 	if block == nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": "Failed to parse PEM block containing public key",
+			"error": localize("Failed to parse PEM block containing public key"),
 		})
 	}
 
 	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to parse public key: %v", err),
+			"error": localize("Failed to parse public key: %v", err),
+		})
+	}
+
+	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Key is not an RSA public key"),
+		})
+	}
+
+	encryptedData, err := rsa.EncryptPKCS1v15(rand.Reader, rsaPublicKey, data)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to encrypt: %v", err),
+		})
+	}
+
+	result, _ := outputValue(encryptedData, options.Output)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Encrypted %d bytes using RSA\n", len(data))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"encryptedData": result,
+		"algorithm": "RSA-PKCS1v15",
+	})
+}
+
+// decryptRSA - Decrypt data using RSA private key
+func decryptRSA(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "decryptRSA", "encryptedData, privateKey"),
+		})
+	}
+
+	privateKeyStr := args[1].String()
+	options, err := parseRSAOptions(args, "text")
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	encryptedData, err := encryptedArgument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	block, _ := pem.Decode([]byte(privateKeyStr))
+	if block == nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to parse PEM block containing private key"),
+		})
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to parse private key: %v", err),
+		})
+	}
+
+	decryptedData, err := rsa.DecryptPKCS1v15(rand.Reader, privateKey, encryptedData)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to decrypt: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Decrypted %d bytes using RSA\n", len(decryptedData))
+	}
+
+	result, _ := outputValue(decryptedData, options.Output)
+	return js.ValueOf(map[string]interface{}{
+		"decryptedData": result,
+		"algorithm":     "RSA-PKCS1v15",
+	})
+}
+
+// ecdhCurves maps the accepted curve names, compared without case or dashes, to their implementation
+var ecdhCurves = map[string]ecdh.Curve{
+	"x25519": ecdh.X25519(),
+	"p256":   ecdh.P256(),
+	"p384":   ecdh.P384(),
+	"p521":   ecdh.P521(),
+}
+
+// generateECDHKeyPair - Generate a key pair for ECDH key agreement on X25519 (default), P-256, P-384 or P-521
+func generateECDHKeyPair(this js.Value, args []js.Value) interface{} {
+	name := "X25519"
+	if len(args) > 0 && args[0].Type() == js.TypeString {
+		name = args[0].String()
+	}
+	curve, ok := ecdhCurves[strings.ReplaceAll(strings.ToLower(name), "-", "")]
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported curve %q (available: %s)", name, "X25519, P-256, P-384, P-521"),
+		})
+	}
+
+	privateKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to generate ECDH key pair: %v", err),
+		})
+	}
+
+	// PKCS#8 and SPKI are the formats WebCrypto imports and exports for ECDH and X25519 keys
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to marshal private key: %v", err),
+		})
+	}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(privateKey.PublicKey())
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to marshal public key: %v", err),
 		})
 	}
 
-	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
-	if !ok {
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated %v ECDH key pair\n", curve)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"publicKey":    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})),
+		"privateKey":   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDER})),
+		"publicKeyRaw": base64.StdEncoding.EncodeToString(privateKey.PublicKey().Bytes()),
+		"curve":        fmt.Sprint(curve),
+	})
+}
+
+// SharedSecretOptions tunes how deriveSharedSecret turns the ECDH shared secret into a key. Both parties
+// must use the same options to obtain the same key.
+type SharedSecretOptions struct {
+	KDF          string `json:"kdf"`          // hkdf (default) or none for the raw shared secret
+	Hash         string `json:"hash"`         // HKDF hash: sha256 (default), sha512 or sha1
+	Salt         string `json:"salt"`         // HKDF salt, none by default
+	SaltEncoding string `json:"saltEncoding"` // base64, hex or utf8; the key encoding by default
+	Info         string `json:"info"`         // HKDF context string binding the key to its use
+	Length       int    `json:"length"`       // HKDF key length in bytes, 32 (AES-256) by default
+	Encoding     string `json:"encoding"`     // key encoding: base64 (default, as encryptAES expects) or hex
+}
+
+// parseECDHPrivateKey reads a PKCS#8 or SEC 1 PEM private key for ECDH
+func parseECDHPrivateKey(text string) (*ecdh.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		return nil, fmt.Errorf(localize("Failed to parse PEM block containing private key"))
+	}
+
+	var key interface{}
+	var err error
+	if block.Type == "EC PRIVATE KEY" {
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(localize("Failed to parse private key: %v"), err)
+	}
+
+	switch key := key.(type) {
+	case *ecdh.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key.ECDH()
+	}
+	return nil, fmt.Errorf(localize("Key is not an ECDH private key"))
+}
+
+// parseECDHPublicKey reads a peer public key on the given curve, as an SPKI PEM or as the base64 raw
+// key (publicKeyRaw, or a WebCrypto raw export)
+func parseECDHPublicKey(text string, curve ecdh.Curve) (*ecdh.PublicKey, error) {
+	if !strings.Contains(text, "-----BEGIN") {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+		if err != nil {
+			if raw, err = base64.RawURLEncoding.DecodeString(strings.TrimSpace(text)); err != nil {
+				return nil, fmt.Errorf(localize("Failed to parse public key: %v"), err)
+			}
+		}
+		key, err := curve.NewPublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf(localize("Failed to parse public key: %v"), err)
+		}
+		return key, nil
+	}
+
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		return nil, fmt.Errorf(localize("Failed to parse PEM block containing public key"))
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf(localize("Failed to parse public key: %v"), err)
+	}
+
+	var key *ecdh.PublicKey
+	switch parsed := parsed.(type) {
+	case *ecdh.PublicKey:
+		key = parsed
+	case *ecdsa.PublicKey:
+		if key, err = parsed.ECDH(); err != nil {
+			return nil, fmt.Errorf(localize("Failed to parse public key: %v"), err)
+		}
+	default:
+		return nil, fmt.Errorf(localize("Key is not an ECDH public key"))
+	}
+	if key.Curve() != curve {
+		return nil, fmt.Errorf(localize("Peer public key uses curve %v, not %v"), key.Curve(), curve)
+	}
+	return key, nil
+}
+
+// deriveSharedSecret - Agree on a key with a peer from our private key and their public key. Through
+// HKDF-SHA256 by default, the result is an AES-256 key ready for encryptAES.
+func deriveSharedSecret(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "Key is not an RSA public key",
+			"error": localize("%s requires at least 2 arguments (%s)", "deriveSharedSecret", "privateKey, peerPublicKey"),
 		})
 	}
 
-	encryptedData, err := rsa.EncryptPKCS1v15(rand.Reader, rsaPublicKey, []byte(data))
-	if err != nil {
+	options := SharedSecretOptions{KDF: "hkdf", Length: 32, Encoding: "base64"}
+	if err := parseOptionsArgument(args, 2, &options); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to encrypt: %v", err),
+			"error": localize("Invalid options format: %v", err),
 		})
 	}
-
-	result := base64.StdEncoding.EncodeToString(encryptedData)
-
-	if !silentMode {
-		fmt.Printf("Go WASM: Encrypted %d bytes using RSA\n", len(data))
-	}
-
-	return js.ValueOf(map[string]interface{}{
-		"encryptedData": result,
-		"algorithm": "RSA-PKCS1v15",
-	})
-}
-
-// decryptRSA - Decrypt data using RSA private key
-func decryptRSA(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
+	options.KDF = strings.ToLower(options.KDF)
+	options.Encoding = strings.ToLower(options.Encoding)
+	if options.Encoding != "base64" && options.Encoding != "hex" {
 		return js.ValueOf(map[string]interface{}{
-			"error": "decryptRSA requires exactly 2 arguments (encryptedData, privateKey)",
+			"error": localize("unsupported key encoding %q", options.Encoding),
 		})
 	}
 
-	encryptedDataStr := args[0].String()
-	privateKeyStr := args[1].String()
-
-	encryptedData, err := base64.StdEncoding.DecodeString(encryptedDataStr)
+	privateKey, err := parseECDHPrivateKey(args[0].String())
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid encrypted data format: %v", err),
+			"error": err.Error(),
 		})
 	}
-
-	block, _ := pem.Decode([]byte(privateKeyStr))
-	if block == nil {
+	curve := privateKey.Curve()
+	peerKey, err := parseECDHPublicKey(args[1].String(), curve)
+	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": "Failed to parse PEM block containing private key",
+			"error": err.Error(),
 		})
 	}
-
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	secret, err := privateKey.ECDH(peerKey)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to parse private key: %v", err),
+			"error": localize("Failed to compute shared secret: %v", err),
 		})
 	}
 
-	decryptedData, err := rsa.DecryptPKCS1v15(rand.Reader, privateKey, encryptedData)
-	if err != nil {
+	result := map[string]interface{}{
+		"algorithm": fmt.Sprintf("ECDH-%v", curve),
+		"curve":     fmt.Sprint(curve),
+		"encoding":  options.Encoding,
+	}
+	key := secret
+	switch options.KDF {
+	case "hkdf":
+		algorithm, newHash, err := hmacHash(options.Hash)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if options.Length < 1 || options.Length > 1024 {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Key length must be between %d and %d bytes", 1, 1024),
+			})
+		}
+		var salt []byte
+		if options.Salt != "" {
+			if options.SaltEncoding == "" {
+				options.SaltEncoding = options.Encoding
+			}
+			if salt, err = decodeSecret(options.Salt, options.SaltEncoding); err != nil {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("Invalid salt format: %v", err),
+				})
+			}
+		}
+		key = make([]byte, options.Length)
+		if _, err := io.ReadFull(hkdf.New(newHash, secret, salt, []byte(options.Info)), key); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to derive key: %v", err),
+			})
+		}
+		result["kdf"] = "HKDF-" + algorithm
+	case "none":
+		result["kdf"] = "none"
+	default:
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to decrypt: %v", err),
+			"error": localize("Unsupported KDF %q", options.KDF),
 		})
 	}
 
+	if options.Encoding == "hex" {
+		result["key"] = hex.EncodeToString(key)
+	} else {
+		result["key"] = base64.StdEncoding.EncodeToString(key)
+	}
+	result["length"] = len(key)
+	result["keySize"] = len(key) * 8
+
 	if !silentMode {
-		fmt.Printf("Go WASM: Decrypted %d bytes using RSA\n", len(decryptedData))
+		fmt.Printf("Go WASM: Derived %d-bit shared key with %v ECDH\n", len(key)*8, curve)
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"decryptedData": string(decryptedData),
-		"algorithm": "RSA-PKCS1v15",
-	})
+	return js.ValueOf(result)
 }
 
 // generateJWT - Generate a JWT token
 func generateJWT(this js.Value, args []js.Value) interface{} {
 	if len(args) < 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "generateJWT requires at least 2 arguments (payload, secret)",
+			"error": localize("%s requires at least 2 arguments (%s)", "generateJWT", "payload, secret"),
 		})
 	}
 
@@ -434,7 +1686,7 @@ func generateJWT(this js.Value, args []js.Value) interface{} {
 	var payload map[string]interface{}
 	if err := json.Unmarshal([]byte(payloadStr), &payload); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid payload JSON: %v", err),
+			"error": localize("Invalid payload JSON: %v", err),
 		})
 	}
 
@@ -454,7 +1706,7 @@ func generateJWT(this js.Value, args []js.Value) interface{} {
 	tokenString, err := token.SignedString([]byte(secret))
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to sign token: %v", err),
+			"error": localize("Failed to sign token: %v", err),
 		})
 	}
 
@@ -473,7 +1725,7 @@ func generateJWT(this js.Value, args []js.Value) interface{} {
 func verifyJWT(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "verifyJWT requires exactly 2 arguments (token, secret)",
+			"error": localize("%s requires exactly 2 arguments (%s)", "verifyJWT", "token, secret"),
 		})
 	}
 
@@ -482,7 +1734,7 @@ func verifyJWT(this js.Value, args []js.Value) interface{} {
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			return nil, fmt.Errorf(localize("unexpected signing method: %v"), token.Header["alg"])
 		}
 		return []byte(secret), nil
 	})
@@ -490,14 +1742,14 @@ func verifyJWT(this js.Value, args []js.Value) interface{} {
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
 			"valid": false,
-			"error": fmt.Sprintf("Failed to parse token: %v", err),
+			"error": localize("Failed to parse token: %v", err),
 		})
 	}
 
 	if !token.Valid {
 		return js.ValueOf(map[string]interface{}{
 			"valid": false,
-			"error": "Token is invalid",
+			"error": localize("Token is invalid"),
 		})
 	}
 
@@ -505,7 +1757,7 @@ func verifyJWT(this js.Value, args []js.Value) interface{} {
 	if !ok {
 		return js.ValueOf(map[string]interface{}{
 			"valid": false,
-			"error": "Failed to extract claims",
+			"error": localize("Failed to extract claims"),
 		})
 	}
 
@@ -522,11 +1774,258 @@ func verifyJWT(this js.Value, args []js.Value) interface{} {
 	})
 }
 
+// SignedURLOptions tunes createSignedURLToken and verifySignedURLToken. The token follows the
+// Akamai EdgeAuth 2.0 layout (st=...~exp=...~acl=...~hmac=...) understood by most CDN token checks.
+type SignedURLOptions struct {
+	Algorithm   string `json:"algorithm"`   // sha256 (default), sha1 or md5
+	KeyEncoding string `json:"keyEncoding"` // hex (default, as issued by CDNs), base64 or utf8
+	StartTime   int64  `json:"startTime"`   // unix seconds before which the token is rejected
+	IP          string `json:"ip"`
+	SessionID   string `json:"sessionId"`
+	Data        string `json:"data"`
+	TokenName   string `json:"tokenName"` // query parameter name, __token__ by default
+}
+
+// parseOptionsArgument reads an optional options argument, given as an object or a JSON string, over
+// the defaults already in options
+func parseOptionsArgument(args []js.Value, index int, options interface{}) error {
+	if len(args) <= index || args[index].Type() == js.TypeUndefined || args[index].Type() == js.TypeNull {
+		return nil
+	}
+	optionsJSON := args[index].String()
+	if args[index].Type() == js.TypeObject {
+		optionsJSON = js.Global().Get("JSON").Call("stringify", args[index]).String()
+	}
+	return json.Unmarshal([]byte(optionsJSON), options)
+}
+
+// parseSignedURLOptions reads the optional options argument of the signed URL functions
+func parseSignedURLOptions(args []js.Value, index int) (SignedURLOptions, error) {
+	options := SignedURLOptions{Algorithm: "sha256", KeyEncoding: "hex", TokenName: "__token__"}
+	if err := parseOptionsArgument(args, index, &options); err != nil {
+		return options, err
+	}
+	options.Algorithm = strings.ToLower(options.Algorithm)
+	return options, nil
+}
+
+// decodeSecret decodes a key given as hex, base64 or plain utf8 text
+func decodeSecret(secret, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "hex":
+		return hex.DecodeString(secret)
+	case "base64":
+		return base64.StdEncoding.DecodeString(secret)
+	case "utf8", "utf-8", "text":
+		return []byte(secret), nil
+	}
+	return nil, fmt.Errorf(localize("unsupported key encoding %q"), encoding)
+}
+
+// signedURLMAC signs a token body with the secret decoded according to the options
+func signedURLMAC(secret, body string, options SignedURLOptions) (string, error) {
+	key, err := decodeSecret(secret, options.KeyEncoding)
+	if err != nil {
+		return "", fmt.Errorf(localize("Invalid secret format: %v"), err)
+	}
+
+	var mac hash.Hash
+	switch options.Algorithm {
+	case "sha256", "":
+		mac = hmac.New(sha256.New, key)
+	case "sha1":
+		mac = hmac.New(sha1.New, key)
+	case "md5":
+		mac = hmac.New(md5.New, key)
+	default:
+		return "", fmt.Errorf(localize("Unsupported HMAC algorithm %q"), options.Algorithm)
+	}
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// createSignedURLToken - Mint an HMAC-signed token granting access to a path (or a * pattern) until it expires
+func createSignedURLToken(this js.Value, args []js.Value) interface{} {
+	if len(args) < 3 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 3 arguments (%s)", "createSignedURLToken", "secret, path, expiry"),
+		})
+	}
+
+	secret := args[0].String()
+	path := args[1].String()
+	options, err := parseSignedURLOptions(args, 3)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options format: %v", err),
+		})
+	}
+
+	// expiry is a lifetime in seconds or an absolute RFC 3339 date
+	start := time.Now().Unix()
+	if options.StartTime > 0 {
+		start = options.StartTime
+	}
+	var expires int64
+	if args[2].Type() == js.TypeNumber {
+		expires = start + int64(args[2].Float())
+	} else {
+		at, err := time.Parse(time.RFC3339, args[2].String())
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid expiry: %v", err),
+			})
+		}
+		expires = at.Unix()
+	}
+	if expires <= start {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Expiry must be after the start time"),
+		})
+	}
+
+	var fields []string
+	if options.IP != "" {
+		fields = append(fields, "ip="+options.IP)
+	}
+	if options.StartTime > 0 {
+		fields = append(fields, fmt.Sprintf("st=%d", options.StartTime))
+	}
+	fields = append(fields, fmt.Sprintf("exp=%d", expires), "acl="+path)
+	if options.SessionID != "" {
+		fields = append(fields, "id="+options.SessionID)
+	}
+	if options.Data != "" {
+		fields = append(fields, "data="+options.Data)
+	}
+	body := strings.Join(fields, "~")
+
+	signature, err := signedURLMAC(secret, body, options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to sign token: %v", err),
+		})
+	}
+	token := body + "~hmac=" + signature
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated signed URL token for %s (expires in %d seconds)\n", path, expires-time.Now().Unix())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"token":       token,
+		"queryString": options.TokenName + "=" + url.QueryEscape(token),
+		"expires":     expires,
+		"expiresAt":   time.Unix(expires, 0).UTC().Format(time.RFC3339),
+		"algorithm":   "HMAC-" + strings.ToUpper(options.Algorithm),
+	})
+}
+
+// verifySignedURLToken - Check the signature and validity window of a signed URL token, and
+// optionally that its acl covers the requested path
+func verifySignedURLToken(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "verifySignedURLToken", "secret, token"),
+		})
+	}
+
+	secret := args[0].String()
+	token := args[1].String()
+	path := ""
+	if len(args) > 2 && args[2].Type() == js.TypeString {
+		path = args[2].String()
+	}
+	options, err := parseSignedURLOptions(args, 3)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options format: %v", err),
+		})
+	}
+
+	// Accept the token as minted or copied from a query string
+	token = strings.TrimPrefix(token, options.TokenName+"=")
+	if unescaped, err := url.QueryUnescape(token); err == nil {
+		token = unescaped
+	}
+	invalid := func(message string, args ...interface{}) interface{} {
+		return js.ValueOf(map[string]interface{}{
+			"valid": false,
+			"error": localize(message, args...),
+		})
+	}
+
+	separator := strings.LastIndex(token, "~hmac=")
+	if separator < 0 {
+		return invalid("Token is malformed")
+	}
+	body, signature := token[:separator], token[separator+len("~hmac="):]
+	fields := map[string]string{}
+	for _, field := range strings.Split(body, "~") {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return invalid("Token is malformed")
+		}
+		fields[name] = value
+	}
+
+	expected, err := signedURLMAC(secret, body, options)
+	if err != nil {
+		return invalid("Failed to parse token: %v", err)
+	}
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return invalid("Token signature does not match")
+	}
+
+	now := time.Now().Unix()
+	expires, err := strconv.ParseInt(fields["exp"], 10, 64)
+	if err != nil {
+		return invalid("Token is malformed")
+	}
+	if now >= expires {
+		return invalid("Token expired at %s", time.Unix(expires, 0).UTC().Format(time.RFC3339))
+	}
+	if start, err := strconv.ParseInt(fields["st"], 10, 64); err == nil && now < start {
+		return invalid("Token is not valid before %s", time.Unix(start, 0).UTC().Format(time.RFC3339))
+	}
+	if path != "" && !signedURLCovers(fields["acl"], path) {
+		return invalid("Token does not grant access to %s", path)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Signed URL token verified successfully\n")
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"valid":     true,
+		"acl":       fields["acl"],
+		"expires":   expires,
+		"expiresIn": expires - now,
+		"ip":        fields["ip"],
+		"sessionId": fields["id"],
+		"data":      fields["data"],
+	})
+}
+
+// signedURLCovers reports whether one of the !-separated acl patterns matches the path; * matches any
+// run of characters and ? a single one, as in CDN token acls
+func signedURLCovers(acl, path string) bool {
+	for _, pattern := range strings.Split(acl, "!") {
+		expression := regexp.QuoteMeta(pattern)
+		expression = strings.ReplaceAll(expression, `\*`, ".*")
+		expression = strings.ReplaceAll(expression, `\?`, ".")
+		if matched, _ := regexp.MatchString("^"+expression+"$", path); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // bcryptHash - Hash password using bcrypt
 func bcryptHash(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "bcryptHash requires at least 1 argument (password)",
+			"error": localize("%s requires at least 1 argument (%s)", "bcryptHash", "password"),
 		})
 	}
 
@@ -542,7 +2041,7 @@ func bcryptHash(this js.Value, args []js.Value) interface{} {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to hash password: %v", err),
+			"error": localize("Failed to hash password: %v", err),
 		})
 	}
 
@@ -561,7 +2060,7 @@ func bcryptHash(this js.Value, args []js.Value) interface{} {
 func bcryptVerify(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "bcryptVerify requires exactly 2 arguments (password, hash)",
+			"error": localize("%s requires exactly 2 arguments (%s)", "bcryptVerify", "password, hash"),
 		})
 	}
 
@@ -587,13 +2086,203 @@ func bcryptVerify(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(result)
 }
 
+// KeyDerivationOptions tunes deriveKeyPBKDF2, deriveKeyScrypt and deriveKeyArgon2id. Unset fields take
+// the defaults of each function.
+type KeyDerivationOptions struct {
+	Salt         string `json:"salt"`         // random 16 bytes when empty, returned along with the key
+	SaltEncoding string `json:"saltEncoding"` // base64, hex or utf8; the key encoding by default
+	Length       int    `json:"length"`       // key length in bytes, 32 (AES-256) by default
+	Encoding     string `json:"encoding"`     // key encoding: base64 (default, as encryptAES expects) or hex
+	Hash         string `json:"hash"`         // PBKDF2: sha256 (default), sha512 or sha1
+	Iterations   int    `json:"iterations"`   // PBKDF2 iterations or Argon2id passes
+	N            int    `json:"N"`            // scrypt CPU/memory cost, a power of 2
+	R            int    `json:"r"`            // scrypt block size
+	P            int    `json:"p"`            // scrypt parallelization
+	Memory       int    `json:"memory"`       // Argon2id memory in KiB
+	Parallelism  int    `json:"parallelism"`  // Argon2id lanes
+}
+
+// keyDerivationMaxMemory bounds the memory scrypt and Argon2id may use, 1 GiB in bytes
+const keyDerivationMaxMemory = 1 << 30
+
+// keyDerivationInput reads the password and options of a key derivation function over its defaults,
+// and decodes the salt or draws a random one
+func keyDerivationInput(name string, args []js.Value, options *KeyDerivationOptions) (password, salt []byte, err error) {
+	if len(args) < 1 {
+		return nil, nil, fmt.Errorf(localize("%s requires at least 1 argument (%s)"), name, "password")
+	}
+	options.Length = 32
+	options.Encoding = "base64"
+	if err := parseOptionsArgument(args, 1, options); err != nil {
+		return nil, nil, fmt.Errorf(localize("Invalid options format: %v"), err)
+	}
+	options.Encoding = strings.ToLower(options.Encoding)
+	if options.Encoding != "base64" && options.Encoding != "hex" {
+		return nil, nil, fmt.Errorf(localize("unsupported key encoding %q"), options.Encoding)
+	}
+	if options.Length < 1 || options.Length > 1024 {
+		return nil, nil, errors.New(localize("Key length must be between %d and %d bytes", 1, 1024))
+	}
+
+	if options.Salt == "" {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, nil, fmt.Errorf(localize("Failed to generate salt: %v"), err)
+		}
+	} else {
+		if options.SaltEncoding == "" {
+			options.SaltEncoding = options.Encoding
+		}
+		if salt, err = decodeSecret(options.Salt, options.SaltEncoding); err != nil {
+			return nil, nil, fmt.Errorf(localize("Invalid salt format: %v"), err)
+		}
+		if len(salt) < 8 {
+			return nil, nil, errors.New(localize("Salt must be at least 8 bytes"))
+		}
+	}
+
+	return []byte(args[0].String()), salt, nil
+}
+
+// keyDerivationResult encodes a derived key and its salt, with the parameters needed to derive it again
+func keyDerivationResult(key, salt []byte, algorithm string, options KeyDerivationOptions, parameters map[string]interface{}) interface{} {
+	encode := base64.StdEncoding.EncodeToString
+	if options.Encoding == "hex" {
+		encode = hex.EncodeToString
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Derived %d-bit key with %s\n", len(key)*8, algorithm)
+	}
+
+	result := map[string]interface{}{
+		"key":       encode(key),
+		"salt":      encode(salt),
+		"algorithm": algorithm,
+		"length":    len(key),
+		"keySize":   len(key) * 8,
+		"encoding":  options.Encoding,
+	}
+	for name, value := range parameters {
+		result[name] = value
+	}
+	return js.ValueOf(result)
+}
+
+// deriveKeyPBKDF2 - Derive a key from a password with PBKDF2, 600000 HMAC-SHA256 iterations by default
+// (210000 with SHA512, 1300000 with SHA1) as OWASP recommends
+func deriveKeyPBKDF2(this js.Value, args []js.Value) interface{} {
+	var options KeyDerivationOptions
+	password, salt, err := keyDerivationInput("deriveKeyPBKDF2", args, &options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	algorithm, newHash, err := hmacHash(options.Hash)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if options.Iterations == 0 {
+		options.Iterations = map[string]int{"SHA256": 600000, "SHA512": 210000, "SHA1": 1300000}[algorithm]
+	}
+	if options.Iterations < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s must be at least %d", "iterations", 1),
+		})
+	}
+
+	key := pbkdf2.Key(password, salt, options.Iterations, options.Length, newHash)
+	return keyDerivationResult(key, salt, "PBKDF2-"+algorithm, options, map[string]interface{}{
+		"hash":       strings.ToLower(algorithm),
+		"iterations": options.Iterations,
+	})
+}
+
+// deriveKeyScrypt - Derive a key from a password with scrypt, N=32768, r=8, p=1 by default
+func deriveKeyScrypt(this js.Value, args []js.Value) interface{} {
+	options := KeyDerivationOptions{N: 32768, R: 8, P: 1}
+	password, salt, err := keyDerivationInput("deriveKeyScrypt", args, &options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	switch {
+	case options.N < 2 || options.N&(options.N-1) != 0:
+		err = fmt.Errorf(localize("N must be a power of 2 greater than 1"))
+	case options.R < 1:
+		err = fmt.Errorf(localize("%s must be at least %d"), "r", 1)
+	case options.P < 1:
+		err = fmt.Errorf(localize("%s must be at least %d"), "p", 1)
+	case int64(options.N)*int64(options.R)*128 > keyDerivationMaxMemory:
+		err = fmt.Errorf(localize("Parameters need more than %d MiB of memory"), keyDerivationMaxMemory>>20)
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	key, err := scrypt.Key(password, salt, options.N, options.R, options.P, options.Length)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to derive key: %v", err),
+		})
+	}
+	return keyDerivationResult(key, salt, "scrypt", options, map[string]interface{}{
+		"N": options.N,
+		"r": options.R,
+		"p": options.P,
+	})
+}
+
+// deriveKeyArgon2id - Derive a key from a password with Argon2id, 19 MiB of memory, 2 passes and 1 lane
+// by default as OWASP recommends
+func deriveKeyArgon2id(this js.Value, args []js.Value) interface{} {
+	options := KeyDerivationOptions{Iterations: 2, Memory: 19456, Parallelism: 1}
+	password, salt, err := keyDerivationInput("deriveKeyArgon2id", args, &options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	switch {
+	case options.Iterations < 1:
+		err = fmt.Errorf(localize("%s must be at least %d"), "iterations", 1)
+	case options.Parallelism < 1 || options.Parallelism > 255:
+		err = fmt.Errorf(localize("%s must be between %d and %d"), "parallelism", 1, 255)
+	case options.Memory < 8*options.Parallelism:
+		err = fmt.Errorf(localize("%s must be at least %d"), "memory", 8*options.Parallelism)
+	case int64(options.Memory)*1024 > keyDerivationMaxMemory:
+		err = fmt.Errorf(localize("Parameters need more than %d MiB of memory"), keyDerivationMaxMemory>>20)
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	key := argon2.IDKey(password, salt, uint32(options.Iterations), uint32(options.Memory), uint8(options.Parallelism), uint32(options.Length))
+	return keyDerivationResult(key, salt, "Argon2id", options, map[string]interface{}{
+		"iterations":  options.Iterations,
+		"memory":      options.Memory,
+		"parallelism": options.Parallelism,
+	})
+}
+
 // generateUUID - Generate a UUID v4
 func generateUUID(this js.Value, args []js.Value) interface{} {
 	uuid := make([]byte, 16)
 	_, err := rand.Read(uuid)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate UUID: %v", err),
+			"error": localize("Failed to generate UUID: %v", err),
 		})
 	}
 
@@ -618,14 +2307,14 @@ func generateUUID(this js.Value, args []js.Value) interface{} {
 func generateRandomBytes(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "generateRandomBytes requires exactly 1 argument (length)",
+			"error": localize("%s requires exactly 1 argument (%s)", "generateRandomBytes", "length"),
 		})
 	}
 
 	length := args[0].Int()
 	if length <= 0 || length > 1024 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "Length must be between 1 and 1024",
+			"error": localize("Length must be between 1 and 1024"),
 		})
 	}
 
@@ -633,7 +2322,7 @@ func generateRandomBytes(this js.Value, args []js.Value) interface{} {
 	_, err := rand.Read(bytes)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate random bytes: %v", err),
+			"error": localize("Failed to generate random bytes: %v", err),
 		})
 	}
 
@@ -654,7 +2343,7 @@ func generateRandomBytes(this js.Value, args []js.Value) interface{} {
 func base64Encode(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "base64Encode requires exactly 1 argument (data)",
+			"error": localize("%s requires exactly 1 argument (%s)", "base64Encode", "data"),
 		})
 	}
 
@@ -676,7 +2365,7 @@ func base64Encode(this js.Value, args []js.Value) interface{} {
 func base64Decode(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "base64Decode requires exactly 1 argument (encodedData)",
+			"error": localize("%s requires exactly 1 argument (%s)", "base64Decode", "encodedData"),
 		})
 	}
 
@@ -684,7 +2373,7 @@ func base64Decode(this js.Value, args []js.Value) interface{} {
 	decoded, err := base64.StdEncoding.DecodeString(encodedData)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to decode base64: %v", err),
+			"error": localize("Failed to decode base64: %v", err),
 		})
 	}
 
@@ -703,7 +2392,7 @@ func base64Decode(this js.Value, args []js.Value) interface{} {
 func validatePasswordStrength(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "validatePasswordStrength requires exactly 1 argument (password)",
+			"error": localize("%s requires exactly 1 argument (%s)", "validatePasswordStrength", "password"),
 		})
 	}
 
@@ -772,18 +2461,129 @@ func validatePasswordStrength(this js.Value, args []js.Value) interface{} {
 	})
 }
 
+// Build metadata, injected by wasm-manager build through -ldflags -X
+var (
+	moduleVersion = "0.2.1"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"hashing",
+	"streaming-hash",
+	"hmac",
+	"aes",
+	"chacha20-poly1305",
+	"rsa",
+	"ecdh",
+	"jwt",
+	"bcrypt",
+	"key-derivation",
+	"uuid",
+	"secure-random",
+	"base64",
+	"password-strength",
+	"key-wrap",
+	"signed-urls",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"openHashes": openHashes,
+	}))
+}
+
+// releaseResources - Return freed memory to the runtime; crypto-wasm keeps no cached state between calls,
+// createHash handles are freed by their digest and release methods
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	advertised := getAvailableFunctions(this, nil).(js.Value)
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "crypto-wasm",
+		"version":         moduleVersion,
+		"description":     "Secure cryptographic operations module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
 // getAvailableFunctions - Get list of available functions
 func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 	functions := []interface{}{
-		"hashSHA256", "hashSHA512", "hashMD5",
+		"hashSHA256", "hashSHA512", "hashMD5", "createHash",
+		"hmacSHA256", "hmacSHA512", "verifyHMAC",
 		"generateAESKey", "encryptAES", "decryptAES",
+		"encryptChaCha20", "decryptChaCha20", "encryptXChaCha20", "decryptXChaCha20",
+		"wrapKey", "unwrapKey", "rotateEnvelope",
 		"generateRSAKeyPair", "encryptRSA", "decryptRSA",
+		"generateECDHKeyPair", "deriveSharedSecret",
 		"generateJWT", "verifyJWT",
+		"createSignedURLToken", "verifySignedURLToken",
 		"bcryptHash", "bcryptVerify",
+		"deriveKeyPBKDF2", "deriveKeyScrypt", "deriveKeyArgon2id",
 		"generateUUID", "generateRandomBytes",
 		"base64Encode", "base64Decode",
 		"validatePasswordStrength",
-		"getAvailableFunctions", "setSilentMode",
+		"getAvailableFunctions", "setSilentMode", "setLocale", "getModuleInfo",
+		"getMemoryStats", "releaseResources",
 	}
 	return js.ValueOf(functions)
 }
@@ -799,6 +2599,16 @@ func main() {
 	crypto.Set("hashSHA256", js.FuncOf(hashSHA256))
 	crypto.Set("hashSHA512", js.FuncOf(hashSHA512))
 	crypto.Set("hashMD5", js.FuncOf(hashMD5))
+	js.Global().Set("createHash", js.FuncOf(createHash))
+	crypto.Set("createHash", js.FuncOf(createHash))
+
+	// HMAC signing
+	js.Global().Set("hmacSHA256", js.FuncOf(hmacSHA256))
+	js.Global().Set("hmacSHA512", js.FuncOf(hmacSHA512))
+	js.Global().Set("verifyHMAC", js.FuncOf(verifyHMAC))
+	crypto.Set("hmacSHA256", js.FuncOf(hmacSHA256))
+	crypto.Set("hmacSHA512", js.FuncOf(hmacSHA512))
+	crypto.Set("verifyHMAC", js.FuncOf(verifyHMAC))
 
 	// AES encryption
 	js.Global().Set("generateAESKey", js.FuncOf(generateAESKey))
@@ -808,6 +2618,24 @@ func main() {
 	crypto.Set("encryptAES", js.FuncOf(encryptAES))
 	crypto.Set("decryptAES", js.FuncOf(decryptAES))
 
+	// ChaCha20-Poly1305 encryption
+	js.Global().Set("encryptChaCha20", js.FuncOf(encryptChaCha20))
+	js.Global().Set("decryptChaCha20", js.FuncOf(decryptChaCha20))
+	js.Global().Set("encryptXChaCha20", js.FuncOf(encryptXChaCha20))
+	js.Global().Set("decryptXChaCha20", js.FuncOf(decryptXChaCha20))
+	crypto.Set("encryptChaCha20", js.FuncOf(encryptChaCha20))
+	crypto.Set("decryptChaCha20", js.FuncOf(decryptChaCha20))
+	crypto.Set("encryptXChaCha20", js.FuncOf(encryptXChaCha20))
+	crypto.Set("decryptXChaCha20", js.FuncOf(decryptXChaCha20))
+
+	// AES key wrapping
+	js.Global().Set("wrapKey", js.FuncOf(wrapKey))
+	js.Global().Set("unwrapKey", js.FuncOf(unwrapKey))
+	js.Global().Set("rotateEnvelope", js.FuncOf(rotateEnvelope))
+	crypto.Set("wrapKey", js.FuncOf(wrapKey))
+	crypto.Set("unwrapKey", js.FuncOf(unwrapKey))
+	crypto.Set("rotateEnvelope", js.FuncOf(rotateEnvelope))
+
 	// RSA encryption
 	js.Global().Set("generateRSAKeyPair", js.FuncOf(generateRSAKeyPair))
 	js.Global().Set("encryptRSA", js.FuncOf(encryptRSA))
@@ -816,18 +2644,38 @@ func main() {
 	crypto.Set("encryptRSA", js.FuncOf(encryptRSA))
 	crypto.Set("decryptRSA", js.FuncOf(decryptRSA))
 
+	// ECDH key agreement
+	js.Global().Set("generateECDHKeyPair", js.FuncOf(generateECDHKeyPair))
+	js.Global().Set("deriveSharedSecret", js.FuncOf(deriveSharedSecret))
+	crypto.Set("generateECDHKeyPair", js.FuncOf(generateECDHKeyPair))
+	crypto.Set("deriveSharedSecret", js.FuncOf(deriveSharedSecret))
+
 	// JWT
 	js.Global().Set("generateJWT", js.FuncOf(generateJWT))
 	js.Global().Set("verifyJWT", js.FuncOf(verifyJWT))
 	crypto.Set("generateJWT", js.FuncOf(generateJWT))
 	crypto.Set("verifyJWT", js.FuncOf(verifyJWT))
 
+	// Signed URL tokens
+	js.Global().Set("createSignedURLToken", js.FuncOf(createSignedURLToken))
+	js.Global().Set("verifySignedURLToken", js.FuncOf(verifySignedURLToken))
+	crypto.Set("createSignedURLToken", js.FuncOf(createSignedURLToken))
+	crypto.Set("verifySignedURLToken", js.FuncOf(verifySignedURLToken))
+
 	// Password hashing
 	js.Global().Set("bcryptHash", js.FuncOf(bcryptHash))
 	js.Global().Set("bcryptVerify", js.FuncOf(bcryptVerify))
 	crypto.Set("bcryptHash", js.FuncOf(bcryptHash))
 	crypto.Set("bcryptVerify", js.FuncOf(bcryptVerify))
 
+	// Key derivation
+	js.Global().Set("deriveKeyPBKDF2", js.FuncOf(deriveKeyPBKDF2))
+	js.Global().Set("deriveKeyScrypt", js.FuncOf(deriveKeyScrypt))
+	js.Global().Set("deriveKeyArgon2id", js.FuncOf(deriveKeyArgon2id))
+	crypto.Set("deriveKeyPBKDF2", js.FuncOf(deriveKeyPBKDF2))
+	crypto.Set("deriveKeyScrypt", js.FuncOf(deriveKeyScrypt))
+	crypto.Set("deriveKeyArgon2id", js.FuncOf(deriveKeyArgon2id))
+
 	// Utilities
 	js.Global().Set("generateUUID", js.FuncOf(generateUUID))
 	js.Global().Set("generateRandomBytes", js.FuncOf(generateRandomBytes))
@@ -842,9 +2690,17 @@ func main() {
 
 	// Standard functions
 	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
 	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
 	crypto.Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	crypto.Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	crypto.Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	crypto.Set("releaseResources", js.FuncOf(releaseResources))
 	crypto.Set("setSilentMode", js.FuncOf(setSilentMode))
+	crypto.Set("setLocale", js.FuncOf(setLocale))
 
 	// Expose the crypto object globally
 	js.Global().Set("crypto", crypto)
@@ -0,0 +1,3 @@
+module email-wasm
+
+go 1.21
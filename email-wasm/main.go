@@ -0,0 +1,1172 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+var silentMode = false
+
+// maxPartDepth bounds multipart nesting when parsing untrusted messages
+const maxPartDepth = 16
+
+// EmailAddress represents a mailbox with an optional display name
+type EmailAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+// AddressList accepts "a@x, B <b@y>", a single {name, address} object, or an array of either
+type AddressList []EmailAddress
+
+// EmailAttachment represents a file attached to a message. Inline attachments with a
+// contentId can be referenced from the HTML body as <img src="cid:...">.
+type EmailAttachment struct {
+	Filename    string          `json:"filename"`
+	ContentType string          `json:"contentType"`
+	Data        json.RawMessage `json:"data"`
+	ContentID   string          `json:"contentId"`
+	Inline      bool            `json:"inline"`
+}
+
+// EmailMessage represents the message passed to buildEmail
+type EmailMessage struct {
+	From        AddressList       `json:"from"`
+	To          AddressList       `json:"to"`
+	Cc          AddressList       `json:"cc"`
+	Bcc         AddressList       `json:"bcc"`
+	ReplyTo     AddressList       `json:"replyTo"`
+	Subject     string            `json:"subject"`
+	Text        string            `json:"text"`
+	HTML        string            `json:"html"`
+	Date        string            `json:"date"`
+	MessageID   string            `json:"messageId"`
+	InReplyTo   string            `json:"inReplyTo"`
+	References  []string          `json:"references"`
+	Headers     map[string]string `json:"headers"`
+	Attachments []EmailAttachment `json:"attachments"`
+}
+
+// mimePart is a MIME entity: its Content-* headers and encoded body
+type mimePart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// UnmarshalJSON decodes the accepted address list forms
+func (l *AddressList) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return l.appendString(text)
+	}
+
+	var single EmailAddress
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = append(*l, single)
+		return nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return errors.New(localize("addresses must be a string, an {name, address} object or an array"))
+	}
+	for _, item := range items {
+		if err := l.UnmarshalJSON(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *AddressList) appendString(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	addresses, err := mail.ParseAddressList(text)
+	if err != nil {
+		return errors.New(localize("invalid address list %q: %v", text, err))
+	}
+	for _, addr := range addresses {
+		*l = append(*l, EmailAddress{Name: addr.Name, Address: addr.Address})
+	}
+	return nil
+}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires at least 1 argument (%s)":                              "%s requiert au moins 1 argument (%s)",
+	"%s requires at least 2 arguments (%s)":                             "%s requiert au moins 2 arguments (%s)",
+	"Invalid message format: %v":                                        "Format de message invalide: %v",
+	"Invalid message: %v":                                               "Message invalide: %v",
+	"Invalid email data: %v":                                            "Données d'e-mail invalides: %v",
+	"Failed to parse email: %v":                                         "Impossible d'analyser l'e-mail: %v",
+	"Invalid signature data: %v":                                        "Données de signature invalides: %v",
+	"Failed to wrap signed email: %v":                                   "Impossible d'envelopper l'e-mail signé: %v",
+	"addresses must be a string, an {name, address} object or an array": "les adresses doivent être une chaîne, un objet {name, address} ou un tableau",
+	"invalid address list %q: %v":                                       "liste d'adresses %q invalide: %v",
+	"a from address is required":                                        "une adresse d'expéditeur (from) est requise",
+	"exactly one from address is allowed":                               "une seule adresse d'expéditeur (from) est autorisée",
+	"at least one recipient (to, cc or bcc) is required":                "au moins un destinataire (to, cc ou bcc) est requis",
+	"the message needs a text body, an html body or attachments":        "le message doit avoir un corps texte, un corps html ou des pièces jointes",
+	"invalid address %q":                                                "adresse %q invalide",
+	"invalid date %q (expected RFC 3339 or RFC 5322)":                   "date %q invalide (RFC 3339 ou RFC 5322 attendu)",
+	"header %q must not contain line breaks":                            "l'en-tête %q ne doit pas contenir de retour à la ligne",
+	"header %q is set by buildEmail and cannot be overridden":           "l'en-tête %q est défini par buildEmail et ne peut pas être remplacé",
+	"invalid header name %q":                                            "nom d'en-tête %q invalide",
+	"attachment %d: %v":                                                 "pièce jointe %d: %v",
+	"missing attachment data":                                           "données de pièce jointe manquantes",
+	"attachment data must be base64 or a byte array":                    "les données de la pièce jointe doivent être en base64 ou un tableau d'octets",
+	"expected a Uint8Array, ArrayBuffer or string":                      "Uint8Array, ArrayBuffer ou chaîne attendu",
+	"expected a Uint8Array, ArrayBuffer or base64 string":               "Uint8Array, ArrayBuffer ou chaîne base64 attendu",
+	"message has no header section":                                     "le message n'a pas de section d'en-têtes",
+	"message is already signed":                                         "le message est déjà signé",
+	"MIME parts are nested more than %d levels deep":                    "les parties MIME sont imbriquées sur plus de %d niveaux",
+	"setLocale requires exactly 1 argument (locale)":                    "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":                             "Langue %q non prise en charge (disponibles: %s)",
+}
+
+// buildEmail - Build an RFC 5322 / MIME message with text and HTML alternatives and attachments
+func buildEmail(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "buildEmail", "message"),
+		})
+	}
+
+	messageJSON := args[0].String()
+	if args[0].Type() == js.TypeObject {
+		messageJSON = js.Global().Get("JSON").Call("stringify", args[0]).String()
+	}
+	var msg EmailMessage
+	if err := json.Unmarshal([]byte(messageJSON), &msg); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid message format: %v", err),
+		})
+	}
+
+	headers, entity, messageID, err := composeMessage(msg)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid message: %v", err),
+		})
+	}
+
+	eml := append(headers, entity...)
+
+	recipients := []interface{}{}
+	for _, list := range []AddressList{msg.To, msg.Cc, msg.Bcc} {
+		for _, addr := range list {
+			recipients = append(recipients, addr.Address)
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Built email %s (%d bytes, %d recipients, %d attachments)\n",
+			messageID, len(eml), len(recipients), len(msg.Attachments))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"eml":        string(eml),
+		"raw":        base64.URLEncoding.EncodeToString(eml),
+		"size":       len(eml),
+		"messageId":  messageID,
+		"recipients": recipients,
+		"mimeEntity": string(entity),
+		"format":     "message/rfc822",
+	})
+}
+
+// composeMessage returns the RFC 5322 header section and the MIME entity of a message.
+// The entity is kept separate because it is the content an S/MIME signature covers.
+func composeMessage(msg EmailMessage) ([]byte, []byte, string, error) {
+	if len(msg.From) == 0 {
+		return nil, nil, "", errors.New(localize("a from address is required"))
+	}
+	if len(msg.From) > 1 {
+		return nil, nil, "", errors.New(localize("exactly one from address is allowed"))
+	}
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return nil, nil, "", errors.New(localize("at least one recipient (to, cc or bcc) is required"))
+	}
+	if msg.Text == "" && msg.HTML == "" && len(msg.Attachments) == 0 {
+		return nil, nil, "", errors.New(localize("the message needs a text body, an html body or attachments"))
+	}
+	for _, list := range []AddressList{msg.From, msg.To, msg.Cc, msg.Bcc, msg.ReplyTo} {
+		for _, addr := range list {
+			if _, err := mail.ParseAddress(addr.Address); err != nil || strings.ContainsAny(addr.Address, "<>") {
+				return nil, nil, "", errors.New(localize("invalid address %q", addr.Address))
+			}
+		}
+	}
+
+	for name, value := range map[string]string{"Subject": msg.Subject, "Message-ID": msg.MessageID, "In-Reply-To": msg.InReplyTo} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, nil, "", errors.New(localize("header %q must not contain line breaks", name))
+		}
+	}
+
+	date := time.Now()
+	if msg.Date != "" {
+		parsed, err := parseDate(msg.Date)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		date = parsed
+	}
+
+	messageID := msg.MessageID
+	if messageID == "" {
+		messageID = newMessageID(msg.From[0].Address)
+	}
+	messageID = angleAddr(messageID)
+
+	var h bytes.Buffer
+	writeHeader(&h, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&h, "From", formatAddresses(msg.From))
+	if len(msg.To) > 0 {
+		writeHeader(&h, "To", formatAddresses(msg.To))
+	}
+	if len(msg.Cc) > 0 {
+		writeHeader(&h, "Cc", formatAddresses(msg.Cc))
+	}
+	if len(msg.ReplyTo) > 0 {
+		writeHeader(&h, "Reply-To", formatAddresses(msg.ReplyTo))
+	}
+	writeHeader(&h, "Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader(&h, "Message-ID", messageID)
+	if msg.InReplyTo != "" {
+		writeHeader(&h, "In-Reply-To", angleAddr(msg.InReplyTo))
+	}
+	if len(msg.References) > 0 {
+		refs := make([]string, len(msg.References))
+		for i, ref := range msg.References {
+			refs[i] = angleAddr(ref)
+		}
+		writeHeader(&h, "References", strings.Join(refs, "\r\n "))
+	}
+	writeHeader(&h, "MIME-Version", "1.0")
+
+	// Custom headers may not replace the ones generated above (Bcc is never written)
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if !validHeaderName(name) {
+			return nil, nil, "", errors.New(localize("invalid header name %q", name))
+		}
+		if reservedHeader(canonical) {
+			return nil, nil, "", errors.New(localize("header %q is set by buildEmail and cannot be overridden", name))
+		}
+		value := msg.Headers[name]
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, nil, "", errors.New(localize("header %q must not contain line breaks", name))
+		}
+		writeHeader(&h, canonical, mime.QEncoding.Encode("utf-8", value))
+	}
+	body, err := messageBody(msg)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return h.Bytes(), body.bytes(), messageID, nil
+}
+
+// messageBody nests the parts as mixed(alternative(text, related(html, inline)), attachments),
+// omitting every multipart level that would hold a single part
+func messageBody(msg EmailMessage) (mimePart, error) {
+	var inline, attached []mimePart
+	for i, att := range msg.Attachments {
+		part, err := attachmentPart(att)
+		if err != nil {
+			return mimePart{}, errors.New(localize("attachment %d: %v", i+1, err))
+		}
+		if att.Inline || att.ContentID != "" {
+			inline = append(inline, part)
+		} else {
+			attached = append(attached, part)
+		}
+	}
+
+	var alternatives []mimePart
+	if msg.Text != "" {
+		alternatives = append(alternatives, textPart("text/plain", msg.Text))
+	}
+	if msg.HTML != "" {
+		html := textPart("text/html", msg.HTML)
+		if len(inline) > 0 {
+			html = multipartOf("related", append([]mimePart{html}, inline...), map[string]string{"type": "text/html"})
+		}
+		alternatives = append(alternatives, html)
+	} else {
+		// Inline parts without an HTML body to reference them are plain attachments
+		attached = append(inline, attached...)
+	}
+
+	var parts []mimePart
+	switch len(alternatives) {
+	case 0:
+	case 1:
+		parts = append(parts, alternatives[0])
+	default:
+		parts = append(parts, multipartOf("alternative", alternatives, nil))
+	}
+	parts = append(parts, attached...)
+
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return multipartOf("mixed", parts, nil), nil
+}
+
+// textPart encodes a UTF-8 text body as quoted-printable with CRLF line endings
+func textPart(contentType, content string) mimePart {
+	var body bytes.Buffer
+	w := quotedprintable.NewWriter(&body)
+	w.Write([]byte(content))
+	w.Close()
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"}))
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return mimePart{header: header, body: body.Bytes()}
+}
+
+// attachmentPart encodes an attachment as base64 in 76 character lines
+func attachmentPart(att EmailAttachment) (mimePart, error) {
+	data, err := decodeAttachmentData(att.Data)
+	if err != nil {
+		return mimePart{}, err
+	}
+
+	filename := att.Filename
+	if filename == "" {
+		filename = "attachment"
+	}
+	contentType := att.ContentType
+	if contentType == "" {
+		if i := strings.LastIndex(filename, "."); i >= 0 {
+			contentType = mime.TypeByExtension(filename[i:])
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = filename
+
+	disposition := "attachment"
+	if att.Inline || att.ContentID != "" {
+		disposition = "inline"
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	if att.ContentID != "" {
+		header.Set("Content-ID", angleAddr(att.ContentID))
+	}
+
+	return mimePart{header: header, body: wrapBase64(data)}, nil
+}
+
+// multipartOf assembles parts into a multipart entity with a random boundary
+func multipartOf(subtype string, parts []mimePart, params map[string]string) mimePart {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, part := range parts {
+		pw, _ := w.CreatePart(part.header)
+		pw.Write(part.body)
+	}
+	w.Close()
+
+	if params == nil {
+		params = map[string]string{}
+	}
+	params["boundary"] = w.Boundary()
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", mime.FormatMediaType("multipart/"+subtype, params))
+	return mimePart{header: header, body: body.Bytes()}
+}
+
+// bytes serializes the entity with its headers in a stable order
+func (p mimePart) bytes() []byte {
+	var buf bytes.Buffer
+	keys := make([]string, 0, len(p.header))
+	for key := range p.header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range p.header[key] {
+			writeHeader(&buf, key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+	buf.Write(p.body)
+	return buf.Bytes()
+}
+
+// parseEmail - Parse an .eml message into headers, bodies and attachments
+func parseEmail(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "parseEmail", "eml"),
+		})
+	}
+
+	raw, err := rawFromJS(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid email data: %v", err),
+		})
+	}
+
+	result, err := parseMessage(raw)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to parse email: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Parsed email (%d bytes, %d attachments)\n",
+			len(raw), len(result["attachments"].([]interface{})))
+	}
+
+	return js.ValueOf(result)
+}
+
+// parsedMessage accumulates the content found while walking the MIME tree
+type parsedMessage struct {
+	text        []string
+	html        []string
+	attachments []interface{}
+	signed      bool
+	encrypted   bool
+}
+
+// parseMessage decodes a message into the structure returned by parseEmail
+func parseMessage(raw []byte) (map[string]interface{}, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := &mime.WordDecoder{CharsetReader: charsetReader}
+	addressParser := &mail.AddressParser{WordDecoder: decoder}
+	decode := func(value string) string {
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			return decoded
+		}
+		return value
+	}
+	addresses := func(name string) []interface{} {
+		list := []interface{}{}
+		value := msg.Header.Get(name)
+		if value == "" {
+			return list
+		}
+		parsed, err := addressParser.ParseList(value)
+		if err != nil {
+			// Keep unparseable lists visible rather than dropping them
+			return append(list, map[string]interface{}{"name": "", "address": decode(value)})
+		}
+		for _, addr := range parsed {
+			list = append(list, map[string]interface{}{"name": addr.Name, "address": addr.Address})
+		}
+		return list
+	}
+
+	parsed := parsedMessage{attachments: []interface{}{}}
+	if err := parsed.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, err
+	}
+
+	headers := map[string]interface{}{}
+	for name, values := range msg.Header {
+		decoded := make([]interface{}, len(values))
+		for i, value := range values {
+			decoded[i] = decode(value)
+		}
+		headers[name] = decoded
+	}
+
+	var from interface{}
+	if list := addresses("From"); len(list) > 0 {
+		from = list[0]
+	}
+
+	date := ""
+	if parsedDate, err := msg.Header.Date(); err == nil {
+		date = parsedDate.UTC().Format(time.RFC3339)
+	}
+
+	references := []interface{}{}
+	for _, ref := range strings.Fields(msg.Header.Get("References")) {
+		references = append(references, ref)
+	}
+
+	return map[string]interface{}{
+		"from":        from,
+		"to":          addresses("To"),
+		"cc":          addresses("Cc"),
+		"bcc":         addresses("Bcc"),
+		"replyTo":     addresses("Reply-To"),
+		"subject":     decode(msg.Header.Get("Subject")),
+		"date":        date,
+		"messageId":   msg.Header.Get("Message-Id"),
+		"inReplyTo":   msg.Header.Get("In-Reply-To"),
+		"references":  references,
+		"text":        strings.Join(parsed.text, "\n"),
+		"html":        strings.Join(parsed.html, "\n"),
+		"attachments": parsed.attachments,
+		"headers":     headers,
+		"signed":      parsed.signed,
+		"encrypted":   parsed.encrypted,
+		"size":        len(raw),
+	}, nil
+}
+
+// walk visits a MIME entity, descending into multipart containers
+func (p *parsedMessage) walk(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > maxPartDepth {
+		return errors.New(localize("MIME parts are nested more than %d levels deep", maxPartDepth))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{"charset": "us-ascii"}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		if mediaType == "multipart/signed" {
+			p.signed = true
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := io.ReadAll(transferDecoder(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+
+	switch mediaType {
+	case "application/pkcs7-signature", "application/x-pkcs7-signature":
+		p.signed = true
+	case "application/pkcs7-mime", "application/x-pkcs7-mime":
+		if params["smime-type"] == "signed-data" {
+			p.signed = true
+		} else {
+			p.encrypted = true
+		}
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if disposition != "attachment" && (mediaType == "text/plain" || mediaType == "text/html") {
+		text := strings.ReplaceAll(decodeCharset(content, params["charset"]), "\r\n", "\n")
+		if mediaType == "text/html" {
+			p.html = append(p.html, text)
+		} else {
+			p.text = append(p.text, text)
+		}
+		return nil
+	}
+
+	decoder := &mime.WordDecoder{CharsetReader: charsetReader}
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if decoded, err := decoder.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+	if filename == "" && mediaType == "message/rfc822" {
+		filename = "message.eml"
+	}
+
+	contentID := strings.Trim(header.Get("Content-Id"), "<> ")
+	p.attachments = append(p.attachments, map[string]interface{}{
+		"filename":    filename,
+		"contentType": mediaType,
+		"size":        len(content),
+		"contentId":   contentID,
+		"inline":      disposition == "inline" || (disposition == "" && contentID != ""),
+		"data":        base64.StdEncoding.EncodeToString(content),
+	})
+	return nil
+}
+
+// wrapSignedEmail - Wrap a built message and its detached S/MIME signature as multipart/signed (RFC 8551)
+func wrapSignedEmail(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "wrapSignedEmail", "eml, signature"),
+		})
+	}
+
+	raw, err := rawFromJS(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid email data: %v", err),
+		})
+	}
+	signature, err := bytesFromJS(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid signature data: %v", err),
+		})
+	}
+	micalg := "sha-256"
+	if len(args) > 2 && args[2].Type() == js.TypeString && args[2].String() != "" {
+		micalg = strings.ToLower(args[2].String())
+	}
+
+	eml, err := signedMessage(raw, signature, micalg)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to wrap signed email: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Wrapped signed email (%d bytes, micalg %s)\n", len(eml), micalg)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"eml":    string(eml),
+		"raw":    base64.URLEncoding.EncodeToString(eml),
+		"size":   len(eml),
+		"micalg": micalg,
+		"format": "message/rfc822",
+	})
+}
+
+// signedMessage moves the Content-* headers of a message into the first part of a
+// multipart/signed body, leaving that entity byte-for-byte as it was signed
+func signedMessage(raw, signature []byte, micalg string) ([]byte, error) {
+	raw = canonicalLineEndings(raw)
+	end := bytes.Index(raw, []byte("\r\n\r\n"))
+	if end < 0 {
+		return nil, errors.New(localize("message has no header section"))
+	}
+
+	var outer, entity bytes.Buffer
+	reader := bufio.NewReader(bytes.NewReader(raw[:end+2]))
+	isContent := false
+	for {
+		line, err := reader.ReadString('\n')
+		if line == "" && err != nil {
+			break
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			name := strings.ToLower(line[:max(strings.Index(line, ":"), 0)])
+			isContent = strings.HasPrefix(name, "content-")
+		}
+		if isContent {
+			entity.WriteString(line)
+		} else {
+			outer.WriteString(line)
+		}
+	}
+	if strings.Contains(strings.ToLower(entity.String()), "multipart/signed") {
+		return nil, errors.New(localize("message is already signed"))
+	}
+	entity.WriteString("\r\n")
+	entity.Write(raw[end+4:])
+
+	signaturePart := mimePart{header: make(textproto.MIMEHeader), body: wrapBase64(signature)}
+	signaturePart.header.Set("Content-Type", `application/pkcs7-signature; name="smime.p7s"`)
+	signaturePart.header.Set("Content-Transfer-Encoding", "base64")
+	signaturePart.header.Set("Content-Disposition", `attachment; filename="smime.p7s"`)
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	boundary := w.Boundary()
+	fmt.Fprintf(&body, "--%s\r\n", boundary)
+	body.Write(entity.Bytes())
+	fmt.Fprintf(&body, "\r\n--%s\r\n", boundary)
+	body.Write(signaturePart.bytes())
+	fmt.Fprintf(&body, "\r\n--%s--\r\n", boundary)
+
+	contentType := mime.FormatMediaType("multipart/signed", map[string]string{
+		"protocol": "application/pkcs7-signature",
+		"micalg":   micalg,
+		"boundary": boundary,
+	})
+	writeHeader(&outer, "Content-Type", contentType)
+	outer.WriteString("\r\n")
+	outer.Write(body.Bytes())
+	return outer.Bytes(), nil
+}
+
+// decodeAttachmentData accepts base64 (optionally a data: URL), a byte array, or a
+// Uint8Array serialized by JSON.stringify
+func decodeAttachmentData(raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, errors.New(localize("missing attachment data"))
+	}
+
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		if comma := strings.Index(encoded, ","); strings.HasPrefix(encoded, "data:") && comma >= 0 {
+			encoded = encoded[comma+1:]
+		}
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+
+	var list []byte
+	var numbers []int
+	if err := json.Unmarshal(raw, &numbers); err == nil {
+		list = make([]byte, len(numbers))
+		for i, n := range numbers {
+			list[i] = byte(n)
+		}
+		return list, nil
+	}
+
+	// JSON.stringify turns a Uint8Array into {"0": 137, "1": 80, ...}
+	var indexed map[string]int
+	if err := json.Unmarshal(raw, &indexed); err != nil {
+		return nil, errors.New(localize("attachment data must be base64 or a byte array"))
+	}
+	list = make([]byte, len(indexed))
+	for key, n := range indexed {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(list) {
+			return nil, errors.New(localize("attachment data must be base64 or a byte array"))
+		}
+		list[i] = byte(n)
+	}
+	return list, nil
+}
+
+// rawFromJS reads a message given as text, a Uint8Array or an ArrayBuffer
+func rawFromJS(value js.Value) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		return []byte(value.String()), nil
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case !value.InstanceOf(js.Global().Get("Uint8Array")):
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or string"))
+	}
+
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// bytesFromJS reads binary data given as base64, a Uint8Array or an ArrayBuffer
+func bytesFromJS(value js.Value) ([]byte, error) {
+	if value.Type() == js.TypeString {
+		return base64.StdEncoding.DecodeString(value.String())
+	}
+	if !value.InstanceOf(js.Global().Get("Uint8Array")) && !value.InstanceOf(js.Global().Get("ArrayBuffer")) {
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or base64 string"))
+	}
+	return rawFromJS(value)
+}
+
+// transferDecoder undoes the Content-Transfer-Encoding of a part body
+func transferDecoder(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: body})
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// base64Cleaner drops the whitespace and stray characters some mailers leave in base64 bodies
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '+' || b == '/' || b == '=' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// charsetReader converts the Latin-1 family to UTF-8; UTF-8 and ASCII are handled by the mime package
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso-8859-15", "latin1", "windows-1252", "cp1252":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(latin1ToUTF8(data)), nil
+	}
+	return nil, fmt.Errorf("unhandled charset %q", charset)
+}
+
+// decodeCharset converts a text body to UTF-8, leaving unknown charsets untouched
+func decodeCharset(content []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return string(content)
+	}
+	if reader, err := charsetReader(charset, bytes.NewReader(content)); err == nil {
+		decoded, _ := io.ReadAll(reader)
+		return string(decoded)
+	}
+	return string(content)
+}
+
+func latin1ToUTF8(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// writeHeader writes a header field with a CRLF line ending
+func writeHeader(w *bytes.Buffer, name, value string) {
+	fmt.Fprintf(w, "%s: %s\r\n", name, value)
+}
+
+// formatAddresses renders an address list, one mailbox per folded line
+func formatAddresses(list AddressList) string {
+	formatted := make([]string, len(list))
+	for i, addr := range list {
+		formatted[i] = (&mail.Address{Name: addr.Name, Address: addr.Address}).String()
+	}
+	return strings.Join(formatted, ",\r\n ")
+}
+
+// wrapBase64 encodes data as base64 split into 76 character CRLF lines (RFC 2045)
+func wrapBase64(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	return buf.Bytes()
+}
+
+// canonicalLineEndings converts bare LF line endings to CRLF
+func canonicalLineEndings(data []byte) []byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
+
+// parseDate accepts RFC 3339 dates as used in JSON, or RFC 5322 dates
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := mail.ParseDate(value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New(localize("invalid date %q (expected RFC 3339 or RFC 5322)", value))
+}
+
+// newMessageID generates a random Message-ID in the sender's domain
+func newMessageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return fmt.Sprintf("<%s.%d@%s>", hex.EncodeToString(id), time.Now().Unix(), domain)
+}
+
+func angleAddr(id string) string {
+	id = strings.TrimSpace(id)
+	if strings.HasPrefix(id, "<") && strings.HasSuffix(id, ">") {
+		return id
+	}
+	return "<" + strings.Trim(id, "<>") + ">"
+}
+
+// reservedHeader reports headers generated by buildEmail
+func reservedHeader(name string) bool {
+	switch name {
+	case "Date", "From", "To", "Cc", "Bcc", "Reply-To", "Subject", "Message-Id",
+		"In-Reply-To", "References", "Mime-Version":
+		return true
+	}
+	return strings.HasPrefix(name, "Content-")
+}
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c <= ' ' || c > '~' || c == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"mime-build",
+	"html-alternative",
+	"attachments",
+	"inline-images",
+	"eml-parse",
+	"smime-wrap",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{}))
+}
+
+// releaseResources - Return freed memory to the runtime; email-wasm keeps no cached state between calls
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "email-wasm",
+		"version":         moduleVersion,
+		"description":     "MIME message building and .eml parsing module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"buildEmail",
+		"parseEmail",
+		"wrapSignedEmail",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register email functions
+	js.Global().Set("buildEmail", js.FuncOf(buildEmail))
+	js.Global().Set("parseEmail", js.FuncOf(parseEmail))
+	js.Global().Set("wrapSignedEmail", js.FuncOf(wrapSignedEmail))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM Email module ready!")
+	fmt.Println("Available functions: buildEmail, parseEmail, wrapSignedEmail")
+
+	// Keep the program alive
+	select {}
+}
@@ -0,0 +1,3 @@
+module fs-wasm
+
+go 1.21
@@ -0,0 +1,1285 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+var silentMode = false
+
+// File system defaults and bounds
+const (
+	defaultQuota  = 256 << 20
+	maxPathLength = 4096
+	handleScheme  = "vfs://"
+	bridgeVersion = 1
+)
+
+// fsEntry is a file or a directory of the virtual file system
+type fsEntry struct {
+	dir      bool
+	data     []byte
+	mimeType string
+	modified time.Time
+}
+
+// entries holds the file system by clean absolute path. Every parent of an entry is a directory
+// entry, and the root always exists.
+var entries = map[string]*fsEntry{"/": {dir: true, modified: time.Now()}}
+
+// quota bounds the bytes of file data; used is the sum of the file sizes
+var (
+	quota int64 = defaultQuota
+	used  int64
+)
+
+// writeOptions configure fsWriteFile
+type writeOptions struct {
+	Encoding  string `json:"encoding"`
+	MimeType  string `json:"mimeType"`
+	Append    bool   `json:"append"`
+	Overwrite *bool  `json:"overwrite"`
+}
+
+// readOptions select the encoding and range returned by fsReadFile
+type readOptions struct {
+	Encoding string `json:"encoding"`
+	Offset   int64  `json:"offset"`
+	Length   *int64 `json:"length"`
+}
+
+// treeOptions configure fsList and fsRemove
+type treeOptions struct {
+	Recursive bool `json:"recursive"`
+}
+
+// exportOptions configure fsExportTar
+type exportOptions struct {
+	Gzip bool `json:"gzip"`
+}
+
+// importOptions configure fsImportTar: the directory the archive is extracted to, and whether its
+// files replace existing ones
+type importOptions struct {
+	Path      string `json:"path"`
+	Overwrite *bool  `json:"overwrite"`
+}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s already exists":                                         "%s existe déjà",
+	"%s is a directory":                                         "%s est un répertoire",
+	"%s is not a directory":                                     "%s n'est pas un répertoire",
+	"%s requires at least %d arguments (%s)":                    "%s nécessite au moins %d arguments (%s)",
+	"%s requires at least 1 argument (%s)":                      "%s nécessite au moins 1 argument (%s)",
+	"Failed to create tar archive: %v":                          "Échec de la création de l'archive tar : %v",
+	"Invalid data: %v":                                          "Données invalides : %v",
+	"Invalid options: %v":                                       "Options invalides : %v",
+	"Invalid tar archive: %v":                                   "Archive tar invalide : %v",
+	"Unsupported locale %q (available: %s)":                     "Langue non prise en charge %q (disponibles : %s)",
+	"cannot move %s into itself":                                "impossible de déplacer %s dans lui-même",
+	"cannot move the root directory":                            "impossible de déplacer le répertoire racine",
+	"cannot remove the root directory":                          "impossible de supprimer le répertoire racine",
+	"directory %s is not empty":                                 "le répertoire %s n'est pas vide",
+	"expected a Uint8Array, ArrayBuffer, typed array or string": "un Uint8Array, un ArrayBuffer, un tableau typé ou une chaîne est attendu",
+	"length must not be negative":                               "la longueur ne doit pas être négative",
+	"no such file or directory: %s":                             "fichier ou répertoire introuvable : %s",
+	"offset %d is beyond the end of %s (%d bytes)":              "la position %d est au-delà de la fin de %s (%d octets)",
+	"offset must not be negative":                               "la position ne doit pas être négative",
+	"path contains a NUL byte":                                  "le chemin contient un octet NUL",
+	"path is longer than %d bytes":                              "le chemin dépasse %d octets",
+	"path must not be empty":                                    "le chemin ne doit pas être vide",
+	"quota exceeded: %d bytes needed, %d of %d bytes free":      "quota dépassé : %d octets nécessaires, %d octets libres sur %d",
+	"quota must be a positive number of bytes":                  "le quota doit être un nombre d'octets positif",
+	"quota of %d bytes is below the %d bytes in use":            "le quota de %d octets est inférieur aux %d octets utilisés",
+	"setLocale requires exactly 1 argument (locale)":            "setLocale nécessite exactement 1 argument (locale)",
+	"unsupported encoding %q":                                   "encodage non pris en charge %q",
+}
+
+// fsWriteFile - Write a file, creating its parent directories. Strings are written as UTF-8 text, or
+// decoded with encoding: 'base64'; binary data is copied once into the file system.
+func fsWriteFile(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least %d arguments (%s)", "fsWriteFile", 2, "path, data"),
+		})
+	}
+
+	var options writeOptions
+	if err := decodeOptions(optionalValue(args, 2), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+
+	name, err := cleanPath(args[0].String())
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	data, err := bytesFromJS(args[1], options.Encoding)
+	if err != nil {
+		return errorResult(localize("Invalid data: %v", err))
+	}
+
+	_, existed := entries[name]
+	entry, err := writeFile(name, data, options)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Wrote %s (%d bytes)\n", name, len(entry.data))
+	}
+
+	info := entry.info(name)
+	info["created"] = !existed
+	return js.ValueOf(info)
+}
+
+// fsReadFile - Read a file as a Uint8Array (default), a UTF-8 string (encoding: 'text') or base64,
+// whole or from offset for length bytes
+func fsReadFile(this js.Value, args []js.Value) interface{} {
+	name, entry, failure := entryArgument(args, "fsReadFile", "path")
+	if failure != nil {
+		return failure
+	}
+
+	var options readOptions
+	if err := decodeOptions(optionalValue(args, 1), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	if entry.dir {
+		return errorResult(localize("%s is a directory", name))
+	}
+
+	data, err := readRange(name, entry.data, options.Offset, options.Length)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	info := entry.info(name)
+	switch options.Encoding {
+	case "", "bytes":
+		info["data"] = newUint8Array(data)
+		info["encoding"] = "bytes"
+	case "text", "utf8", "utf-8":
+		info["data"] = string(data)
+		info["encoding"] = "text"
+	case "base64":
+		info["data"] = base64.StdEncoding.EncodeToString(data)
+		info["encoding"] = "base64"
+	default:
+		return errorResult(localize("Invalid options: %v", localize("unsupported encoding %q", options.Encoding)))
+	}
+	info["offset"] = options.Offset
+	info["length"] = len(data)
+
+	return js.ValueOf(info)
+}
+
+// fsStat - Describe a file or directory: type, size, MIME type, modification time and handle
+func fsStat(this js.Value, args []js.Value) interface{} {
+	name, entry, failure := entryArgument(args, "fsStat", "path")
+	if failure != nil {
+		return failure
+	}
+
+	info := entry.info(name)
+	if entry.dir {
+		info["entries"] = len(children(name, false))
+	}
+	return js.ValueOf(info)
+}
+
+// fsExists - Report whether a path exists, without an error when it does not
+func fsExists(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "fsExists", "path"),
+		})
+	}
+	name, err := cleanPath(args[0].String())
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	result := map[string]interface{}{"path": name, "exists": false}
+	if entry, ok := entries[name]; ok {
+		result["exists"] = true
+		result["type"] = entry.typeName()
+	}
+	return js.ValueOf(result)
+}
+
+// fsList - List a directory, sorted by path; recursive: true lists everything below it
+func fsList(this js.Value, args []js.Value) interface{} {
+	name := "/"
+	if len(args) > 0 && args[0].Type() == js.TypeString {
+		var err error
+		if name, err = cleanPath(args[0].String()); err != nil {
+			return errorResult(err.Error())
+		}
+	}
+
+	var options treeOptions
+	if err := decodeOptions(optionalValue(args, 1), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+
+	entry, ok := entries[name]
+	if !ok {
+		return errorResult(localize("no such file or directory: %s", name))
+	}
+	if !entry.dir {
+		return errorResult(localize("%s is not a directory", name))
+	}
+
+	paths := children(name, options.Recursive)
+	list := make([]interface{}, len(paths))
+	for i, child := range paths {
+		list[i] = entries[child].info(child)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"path":    name,
+		"entries": list,
+		"count":   len(list),
+	})
+}
+
+// fsMkdir - Create a directory and its missing parents
+func fsMkdir(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "fsMkdir", "path"),
+		})
+	}
+	name, err := cleanPath(args[0].String())
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	_, existed := entries[name]
+	if err := mkdirAll(name, time.Now()); err != nil {
+		return errorResult(err.Error())
+	}
+
+	info := entries[name].info(name)
+	info["created"] = !existed
+	return js.ValueOf(info)
+}
+
+// fsRemove - Delete a file, an empty directory, or a directory and its content with recursive: true
+func fsRemove(this js.Value, args []js.Value) interface{} {
+	name, entry, failure := entryArgument(args, "fsRemove", "path")
+	if failure != nil {
+		return failure
+	}
+
+	var options treeOptions
+	if err := decodeOptions(optionalValue(args, 1), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	if name == "/" {
+		return errorResult(localize("cannot remove the root directory"))
+	}
+
+	removed := []string{name}
+	if entry.dir {
+		below := children(name, true)
+		if len(below) > 0 && !options.Recursive {
+			return errorResult(localize("directory %s is not empty", name))
+		}
+		removed = append(removed, below...)
+	}
+
+	freed := 0
+	for _, child := range removed {
+		freed += len(entries[child].data)
+		delete(entries, child)
+	}
+	used -= int64(freed)
+	touch(path.Dir(name), time.Now())
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Removed %s (%d entries, %d bytes)\n", name, len(removed), freed)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"path":    name,
+		"removed": len(removed),
+		"freed":   freed,
+	})
+}
+
+// fsRename - Move a file or a directory with its content to a new path, creating missing parents
+func fsRename(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least %d arguments (%s)", "fsRename", 2, "from, to"),
+		})
+	}
+	from, err := cleanPath(args[0].String())
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	to, err := cleanPath(args[1].String())
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	if err := rename(from, to); err != nil {
+		return errorResult(err.Error())
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Moved %s to %s\n", from, to)
+	}
+
+	info := entries[to].info(to)
+	info["from"] = from
+	return js.ValueOf(info)
+}
+
+// fsSetQuota - Limit the bytes of file data the file system holds (256 MB by default)
+func fsSetQuota(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "fsSetQuota", "bytes"),
+		})
+	}
+	if args[0].Type() != js.TypeNumber || args[0].Float() < 1 {
+		return errorResult(localize("quota must be a positive number of bytes"))
+	}
+
+	limit := int64(args[0].Float())
+	if limit < used {
+		return errorResult(localize("quota of %d bytes is below the %d bytes in use", limit, used))
+	}
+	quota = limit
+
+	return js.ValueOf(usage())
+}
+
+// fsGetUsage - Report the bytes in use, the quota and the number of files and directories
+func fsGetUsage(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(usage())
+}
+
+// fsExportTar - Pack a directory (the root by default) or a single file into a tar archive, gzipped
+// with gzip: true. Paths in the archive are relative to the exported directory.
+func fsExportTar(this js.Value, args []js.Value) interface{} {
+	name := "/"
+	if len(args) > 0 && args[0].Type() == js.TypeString {
+		var err error
+		if name, err = cleanPath(args[0].String()); err != nil {
+			return errorResult(err.Error())
+		}
+	}
+
+	var options exportOptions
+	if err := decodeOptions(optionalValue(args, 1), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+
+	entry, ok := entries[name]
+	if !ok {
+		return errorResult(localize("no such file or directory: %s", name))
+	}
+
+	archive, files, directories, err := exportTar(name, entry, options.Gzip)
+	if err != nil {
+		return errorResult(localize("Failed to create tar archive: %v", err))
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Exported %s (%d files, %d bytes)\n", name, files, len(archive))
+	}
+
+	mimeType := "application/x-tar"
+	if options.Gzip {
+		mimeType = "application/gzip"
+	}
+	return js.ValueOf(map[string]interface{}{
+		"data":        newUint8Array(archive),
+		"size":        len(archive),
+		"files":       files,
+		"directories": directories,
+		"gzip":        options.Gzip,
+		"mimeType":    mimeType,
+	})
+}
+
+// fsImportTar - Extract a tar archive (gzipped or not) into a directory, the root by default. The
+// archive is checked whole before anything is written, so a failed import leaves the file system as it was.
+func fsImportTar(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "fsImportTar", "archive"),
+		})
+	}
+
+	var options importOptions
+	if err := decodeOptions(optionalValue(args, 1), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	target := "/"
+	if options.Path != "" {
+		var err error
+		if target, err = cleanPath(options.Path); err != nil {
+			return errorResult(err.Error())
+		}
+	}
+
+	data, err := bytesFromJS(args[0], "base64")
+	if err != nil {
+		return errorResult(localize("Invalid data: %v", err))
+	}
+
+	staged, skipped, err := readTar(data, target)
+	if err != nil {
+		return errorResult(localize("Invalid tar archive: %v", err))
+	}
+	files, directories, size, err := applyImport(staged, target, options.Overwrite == nil || *options.Overwrite)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Imported %d files (%d bytes) into %s\n", files, size, target)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"path":        target,
+		"files":       files,
+		"directories": directories,
+		"size":        size,
+		"skipped":     stringValues(skipped),
+	})
+}
+
+// entryArgument checks the argument count and looks up the path passed first
+func entryArgument(args []js.Value, function string, names string) (string, *fsEntry, interface{}) {
+	if len(args) < 1 {
+		return "", nil, js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", function, names),
+		})
+	}
+	name, err := cleanPath(args[0].String())
+	if err != nil {
+		return "", nil, errorResult(err.Error())
+	}
+	entry, ok := entries[name]
+	if !ok {
+		return "", nil, errorResult(localize("no such file or directory: %s", name))
+	}
+	return name, entry, nil
+}
+
+func errorResult(message string) js.Value {
+	return js.ValueOf(map[string]interface{}{"error": message})
+}
+
+// cleanPath turns a path or a vfs:// handle into a clean absolute path. Paths are always resolved
+// from the root, so .. never leaves the file system.
+func cleanPath(name string) (string, error) {
+	name = strings.TrimPrefix(name, handleScheme)
+	switch {
+	case name == "":
+		return "", errors.New(localize("path must not be empty"))
+	case strings.ContainsRune(name, 0):
+		return "", errors.New(localize("path contains a NUL byte"))
+	case len(name) > maxPathLength:
+		return "", errors.New(localize("path is longer than %d bytes", maxPathLength))
+	}
+	return path.Clean("/" + name), nil
+}
+
+// handleOf returns the vfs:// handle other modules resolve through the bridge
+func handleOf(name string) string {
+	return handleScheme + strings.TrimPrefix(name, "/")
+}
+
+// typeName is the type reported for the entry
+func (e *fsEntry) typeName() string {
+	if e.dir {
+		return "directory"
+	}
+	return "file"
+}
+
+// info describes the entry for the fs functions and the bridge
+func (e *fsEntry) info(name string) map[string]interface{} {
+	info := map[string]interface{}{
+		"path":     name,
+		"name":     path.Base(name),
+		"type":     e.typeName(),
+		"handle":   handleOf(name),
+		"modified": float64(e.modified.UnixMilli()),
+	}
+	if !e.dir {
+		info["size"] = len(e.data)
+		info["mimeType"] = e.mimeType
+	}
+	return info
+}
+
+// children returns the paths directly below a directory, or every path below it when recursive,
+// sorted so that parents come before their content
+func children(name string, recursive bool) []string {
+	prefix := name
+	if prefix != "/" {
+		prefix += "/"
+	}
+	var paths []string
+	for child := range entries {
+		if child == "/" || !strings.HasPrefix(child, prefix) {
+			continue
+		}
+		if recursive || !strings.Contains(child[len(prefix):], "/") {
+			paths = append(paths, child)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// touch updates the modification time of a directory whose content changed
+func touch(name string, now time.Time) {
+	if entry, ok := entries[name]; ok {
+		entry.modified = now
+	}
+}
+
+// mkdirAll creates a directory and its missing parents
+func mkdirAll(name string, now time.Time) error {
+	if entry, ok := entries[name]; ok {
+		if !entry.dir {
+			return errors.New(localize("%s is not a directory", name))
+		}
+		return nil
+	}
+	parent := path.Dir(name)
+	if err := mkdirAll(parent, now); err != nil {
+		return err
+	}
+	entries[name] = &fsEntry{dir: true, modified: now}
+	touch(parent, now)
+	return nil
+}
+
+// reserve checks that growing the file data by delta bytes stays within the quota
+func reserve(delta int64) error {
+	if delta > 0 && used+delta > quota {
+		return errors.New(localize("quota exceeded: %d bytes needed, %d of %d bytes free", delta, quota-used, quota))
+	}
+	return nil
+}
+
+// writeFile stores data at name, replacing or extending an existing file
+func writeFile(name string, data []byte, options writeOptions) (*fsEntry, error) {
+	existing, exists := entries[name]
+	switch {
+	case exists && existing.dir:
+		return nil, errors.New(localize("%s is a directory", name))
+	case exists && !options.Append && options.Overwrite != nil && !*options.Overwrite:
+		return nil, errors.New(localize("%s already exists", name))
+	}
+
+	content := data
+	var previous int64
+	if exists {
+		previous = int64(len(existing.data))
+		if options.Append {
+			content = make([]byte, 0, len(existing.data)+len(data))
+			content = append(append(content, existing.data...), data...)
+		}
+	}
+	if err := reserve(int64(len(content)) - previous); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := mkdirAll(path.Dir(name), now); err != nil {
+		return nil, err
+	}
+
+	mimeType := options.MimeType
+	if mimeType == "" && exists {
+		mimeType = existing.mimeType
+	}
+	if mimeType == "" {
+		mimeType = guessMimeType(name)
+	}
+
+	entry := &fsEntry{data: content, mimeType: mimeType, modified: now}
+	entries[name] = entry
+	used += int64(len(content)) - previous
+	touch(path.Dir(name), now)
+	return entry, nil
+}
+
+// guessMimeType returns the MIME type for the extension of a file name
+func guessMimeType(name string) string {
+	if mimeType := mime.TypeByExtension(path.Ext(name)); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// readRange returns length bytes of data from offset, or the rest of the file without a length
+func readRange(name string, data []byte, offset int64, length *int64) ([]byte, error) {
+	if offset < 0 {
+		return nil, errors.New(localize("offset must not be negative"))
+	}
+	if offset > int64(len(data)) {
+		return nil, errors.New(localize("offset %d is beyond the end of %s (%d bytes)", offset, name, len(data)))
+	}
+	end := int64(len(data))
+	if length != nil {
+		if *length < 0 {
+			return nil, errors.New(localize("length must not be negative"))
+		}
+		if offset+*length < end {
+			end = offset + *length
+		}
+	}
+	return data[offset:end], nil
+}
+
+// rename moves an entry and, for a directory, everything below it
+func rename(from, to string) error {
+	entry, ok := entries[from]
+	switch {
+	case !ok:
+		return errors.New(localize("no such file or directory: %s", from))
+	case from == "/":
+		return errors.New(localize("cannot move the root directory"))
+	case from == to:
+		return nil
+	case entry.dir && strings.HasPrefix(to, from+"/"):
+		return errors.New(localize("cannot move %s into itself", from))
+	}
+	if _, exists := entries[to]; exists {
+		return errors.New(localize("%s already exists", to))
+	}
+
+	now := time.Now()
+	if err := mkdirAll(path.Dir(to), now); err != nil {
+		return err
+	}
+
+	moved := []string{from}
+	if entry.dir {
+		moved = append(moved, children(from, true)...)
+	}
+	for _, name := range moved {
+		entries[to+strings.TrimPrefix(name, from)] = entries[name]
+		delete(entries, name)
+	}
+	entry.modified = now
+	touch(path.Dir(from), now)
+	touch(path.Dir(to), now)
+	return nil
+}
+
+// usage reports the space used and the number of entries
+func usage() map[string]interface{} {
+	files, directories := 0, 0
+	for _, entry := range entries {
+		if entry.dir {
+			directories++
+		} else {
+			files++
+		}
+	}
+	return map[string]interface{}{
+		"used":        used,
+		"quota":       quota,
+		"free":        quota - used,
+		"files":       files,
+		"directories": directories - 1,
+	}
+}
+
+// exportTar writes an entry, and everything below it for a directory, as a tar archive
+func exportTar(name string, entry *fsEntry, compress bool) ([]byte, int, int, error) {
+	var buf bytes.Buffer
+	var sink io.Writer = &buf
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		sink = zw
+	}
+	tw := tar.NewWriter(sink)
+
+	paths := []string{name}
+	base := path.Dir(name)
+	if entry.dir {
+		paths = children(name, true)
+		base = name
+	}
+
+	files, directories := 0, 0
+	for _, child := range paths {
+		e := entries[child]
+		relative := strings.TrimPrefix(strings.TrimPrefix(child, base), "/")
+		header := &tar.Header{
+			Name:    relative,
+			ModTime: e.modified.Truncate(time.Second),
+			Mode:    0o644,
+			Format:  tar.FormatPAX,
+		}
+		if e.dir {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			header.Mode = 0o755
+			directories++
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(e.data))
+			files++
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, 0, 0, err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, 0, 0, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	return buf.Bytes(), files, directories, nil
+}
+
+// stagedEntry is an archive member waiting to be written by applyImport
+type stagedEntry struct {
+	name  string
+	entry *fsEntry
+}
+
+// readTar reads the directories and regular files of a tar archive, gunzipping it first when needed.
+// Member names are resolved below target. Links and special files are returned as skipped.
+func readTar(data []byte, target string) ([]stagedEntry, []string, error) {
+	var source io.Reader = bytes.NewReader(data)
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(source)
+		if err != nil {
+			return nil, nil, err
+		}
+		source = zr
+	}
+
+	var staged []stagedEntry
+	skipped := []string{}
+	var total int64
+	tr := tar.NewReader(source)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		name := path.Join(target, path.Clean("/"+header.Name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			staged = append(staged, stagedEntry{name, &fsEntry{dir: true, modified: header.ModTime}})
+		case tar.TypeReg:
+			// Check the declared size first, so a forged header cannot make the module allocate it
+			if total += header.Size; total > quota {
+				return nil, nil, errors.New(localize("quota exceeded: %d bytes needed, %d of %d bytes free", total, quota-used, quota))
+			}
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, nil, err
+			}
+			staged = append(staged, stagedEntry{name, &fsEntry{data: content, mimeType: guessMimeType(name), modified: header.ModTime}})
+		default:
+			skipped = append(skipped, header.Name)
+		}
+	}
+	return staged, skipped, nil
+}
+
+// applyImport checks the staged entries against the file system and the quota, then writes them.
+// Later members with the same name replace earlier ones, as tar extraction does.
+func applyImport(staged []stagedEntry, target string, overwrite bool) (int, int, int, error) {
+	final := map[string]*fsEntry{}
+	for _, s := range staged {
+		if s.name == "/" || s.name == target {
+			if !s.entry.dir {
+				return 0, 0, 0, errors.New(localize("%s is a directory", s.name))
+			}
+			continue
+		}
+		final[s.name] = s.entry
+	}
+
+	lookup := func(name string) (*fsEntry, bool) {
+		if entry, ok := final[name]; ok {
+			return entry, true
+		}
+		entry, ok := entries[name]
+		return entry, ok
+	}
+
+	var delta int64
+	files, directories, size := 0, 0, 0
+	for name, entry := range final {
+		for parent := path.Dir(name); parent != "/"; parent = path.Dir(parent) {
+			if existing, ok := lookup(parent); ok && !existing.dir {
+				return 0, 0, 0, errors.New(localize("%s is not a directory", parent))
+			}
+		}
+		existing, exists := entries[name]
+		switch {
+		case exists && existing.dir != entry.dir && entry.dir:
+			return 0, 0, 0, errors.New(localize("%s is not a directory", name))
+		case exists && existing.dir != entry.dir:
+			return 0, 0, 0, errors.New(localize("%s is a directory", name))
+		case exists && !entry.dir && !overwrite:
+			return 0, 0, 0, errors.New(localize("%s already exists", name))
+		}
+		if entry.dir {
+			directories++
+			continue
+		}
+		files++
+		size += len(entry.data)
+		delta += int64(len(entry.data))
+		if exists {
+			delta -= int64(len(existing.data))
+		}
+	}
+	if err := reserve(delta); err != nil {
+		return 0, 0, 0, err
+	}
+
+	names := make([]string, 0, len(final))
+	for name := range final {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := time.Now()
+	if err := mkdirAll(target, now); err != nil {
+		return 0, 0, 0, err
+	}
+	for _, name := range names {
+		entry := final[name]
+		if err := mkdirAll(path.Dir(name), now); err != nil {
+			return 0, 0, 0, err
+		}
+		if existing, ok := entries[name]; ok && entry.dir {
+			existing.modified = entry.modified
+			continue
+		}
+		entries[name] = entry
+		touch(path.Dir(name), now)
+	}
+	used += delta
+	return files, directories, size, nil
+}
+
+// Bridge for the other modules of the suite. Each module runs in its own Go instance, so files cross
+// through JavaScript once, from fs-wasm to the module that reads them, instead of through page code.
+
+// bridgeRead - __wasmfs.read(handle): the content of a file as a Uint8Array, or null
+func bridgeRead(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.Null()
+	}
+	name, err := cleanPath(args[0].String())
+	if err != nil {
+		return js.Null()
+	}
+	entry, ok := entries[name]
+	if !ok || entry.dir {
+		return js.Null()
+	}
+	return newUint8Array(entry.data)
+}
+
+// bridgeWrite - __wasmfs.write(handle, bytes[, mimeType]): store a module's output and return its
+// description with the handle, or {error}
+func bridgeWrite(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least %d arguments (%s)", "__wasmfs.write", 2, "handle, bytes"),
+		})
+	}
+	name, err := cleanPath(args[0].String())
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	data, err := bytesFromJS(args[1], "")
+	if err != nil {
+		return errorResult(localize("Invalid data: %v", err))
+	}
+	var options writeOptions
+	if len(args) > 2 && args[2].Type() == js.TypeString {
+		options.MimeType = args[2].String()
+	}
+
+	entry, err := writeFile(name, data, options)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	return js.ValueOf(entry.info(name))
+}
+
+// bridgeStat - __wasmfs.stat(handle): the description of a file or directory, or null
+func bridgeStat(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.Null()
+	}
+	name, err := cleanPath(args[0].String())
+	if err != nil {
+		return js.Null()
+	}
+	entry, ok := entries[name]
+	if !ok {
+		return js.Null()
+	}
+	return js.ValueOf(entry.info(name))
+}
+
+// decodeOptions reads an options argument given as a JS object or a JSON string, leaving defaults for missing keys
+func decodeOptions(value js.Value, target interface{}) error {
+	switch value.Type() {
+	case js.TypeUndefined, js.TypeNull:
+		return nil
+	case js.TypeObject:
+		value = js.Global().Get("JSON").Call("stringify", value)
+	}
+	return json.Unmarshal([]byte(value.String()), target)
+}
+
+// optionalValue returns the argument at index, or undefined when it was not passed
+func optionalValue(args []js.Value, index int) js.Value {
+	if len(args) > index {
+		return args[index]
+	}
+	return js.Undefined()
+}
+
+// bytesFromJS - Read content passed as a Uint8Array, an ArrayBuffer, another typed array or a
+// DataView, or a string: UTF-8 text, or base64 with the base64 encoding
+func bytesFromJS(value js.Value, encoding string) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		switch encoding {
+		case "", "text", "utf8", "utf-8":
+			return []byte(value.String()), nil
+		case "base64":
+			return base64.StdEncoding.DecodeString(value.String())
+		}
+		return nil, errors.New(localize("unsupported encoding %q", encoding))
+	case value.Type() != js.TypeObject:
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer, typed array or string"))
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case js.Global().Get("ArrayBuffer").Call("isView", value).Bool():
+		if !value.InstanceOf(js.Global().Get("Uint8Array")) {
+			value = js.Global().Get("Uint8Array").New(value.Get("buffer"), value.Get("byteOffset"), value.Get("byteLength"))
+		}
+	default:
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer, typed array or string"))
+	}
+
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// newUint8Array copies bytes into a new JavaScript Uint8Array
+func newUint8Array(data []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+// stringValues converts strings for js.ValueOf
+func stringValues(values []string) []interface{} {
+	converted := make([]interface{}, len(values))
+	for i, value := range values {
+		converted[i] = value
+	}
+	return converted
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"virtual-fs",
+	"quota",
+	"tar-import",
+	"tar-export",
+	"gzip",
+	"vfs-handles",
+	"module-bridge",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and the file system content so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	stats := usage()
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"files":       stats["files"],
+		"directories": stats["directories"],
+		"bytes":       used,
+	}))
+}
+
+// releaseResources - Empty the file system and return freed memory to the runtime. The quota is kept.
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	stats := usage()
+	released := map[string]interface{}{
+		"files":       stats["files"],
+		"directories": stats["directories"],
+		"bytes":       used,
+	}
+	entries = map[string]*fsEntry{"/": {dir: true, modified: time.Now()}}
+	used = 0
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "fs-wasm",
+		"version":         moduleVersion,
+		"description":     "In-memory virtual file system shared by the modules through vfs:// handles",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"bridgeVersion":   bridgeVersion,
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"fsWriteFile",
+		"fsReadFile",
+		"fsStat",
+		"fsExists",
+		"fsList",
+		"fsMkdir",
+		"fsRemove",
+		"fsRename",
+		"fsSetQuota",
+		"fsGetUsage",
+		"fsExportTar",
+		"fsImportTar",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register file system functions
+	js.Global().Set("fsWriteFile", js.FuncOf(fsWriteFile))
+	js.Global().Set("fsReadFile", js.FuncOf(fsReadFile))
+	js.Global().Set("fsStat", js.FuncOf(fsStat))
+	js.Global().Set("fsExists", js.FuncOf(fsExists))
+	js.Global().Set("fsList", js.FuncOf(fsList))
+	js.Global().Set("fsMkdir", js.FuncOf(fsMkdir))
+	js.Global().Set("fsRemove", js.FuncOf(fsRemove))
+	js.Global().Set("fsRename", js.FuncOf(fsRename))
+	js.Global().Set("fsSetQuota", js.FuncOf(fsSetQuota))
+	js.Global().Set("fsGetUsage", js.FuncOf(fsGetUsage))
+	js.Global().Set("fsExportTar", js.FuncOf(fsExportTar))
+	js.Global().Set("fsImportTar", js.FuncOf(fsImportTar))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Publish the bridge other modules resolve vfs:// handles with
+	js.Global().Set("__wasmfs", js.ValueOf(map[string]interface{}{
+		"version": bridgeVersion,
+		"scheme":  handleScheme,
+		"read":    js.FuncOf(bridgeRead),
+		"write":   js.FuncOf(bridgeWrite),
+		"stat":    js.FuncOf(bridgeStat),
+	}))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM FS module ready!")
+	fmt.Println("Available functions: fsWriteFile, fsReadFile, fsStat, fsExists, fsList, fsMkdir, fsRemove, fsRename, fsSetQuota, fsGetUsage, fsExportTar, fsImportTar")
+
+	// Keep the program alive
+	select {}
+}
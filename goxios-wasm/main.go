@@ -5,10 +5,18 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall/js"
 	"time"
+	"unicode/utf8"
 )
 
 var silentMode = false
@@ -17,6 +25,27 @@ var globalDefaults = RequestConfig{
 	Headers: make(map[string]string),
 }
 
+// Request log state used to build HAR exports
+var requestLog = struct {
+	sync.Mutex
+	enabled       bool
+	maxEntries    int
+	maxBodySize   int
+	redactHeaders bool
+	entries       []HAREntry
+}{
+	maxEntries:    500,
+	maxBodySize:   64 * 1024,
+	redactHeaders: true,
+}
+
+// Live instances and in-flight requests, reported by getMemoryStats
+var liveHandles = struct {
+	sync.Mutex
+	instances       int
+	pendingRequests int
+}{}
+
 // RequestConfig structure pour la configuration des requêtes
 type RequestConfig struct {
 	Method  string            `json:"method"`
@@ -24,6 +53,8 @@ type RequestConfig struct {
 	Headers map[string]string `json:"headers"`
 	Data    interface{}       `json:"data"`
 	Timeout int               `json:"timeout"` // en millisecondes
+	// ResponseModel describes the expected response fields, see applyResponseModel
+	ResponseModel interface{} `json:"responseModel,omitempty"`
 }
 
 // Response structure pour les réponses
@@ -32,6 +63,10 @@ type Response struct {
 	Status  int               `json:"status"`
 	Headers map[string]string `json:"headers"`
 	Config  RequestConfig     `json:"config"`
+	// Queued marks a request held by the offline queue instead of being sent
+	Queued   bool   `json:"queued,omitempty"`
+	QueueID  string `json:"queueId,omitempty"`
+	QueuedAt string `json:"queuedAt,omitempty"`
 }
 
 // Error structure pour les erreurs
@@ -40,6 +75,87 @@ type HTTPError struct {
 	Status   int           `json:"status"`
 	Response *Response     `json:"response,omitempty"`
 	Config   RequestConfig `json:"config"`
+	// Errors lists the response fields rejected by the responseModel
+	Errors []ValidationError `json:"errors,omitempty"`
+}
+
+// ValidationError locates a response field that does not match the responseModel
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// HAR structures (HTTP Archive 1.2) pour l'export du journal des requêtes
+type HARLog struct {
+	Log HARContent `json:"log"`
+}
+
+type HARContent struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	Cookies     []HARNameValue `json:"cookies"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Content     HARContentBody `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContentBody struct {
+	Size      int    `json:"size"`
+	MimeType  string `json:"mimeType"`
+	Text      string `json:"text"`
+	Truncated bool   `json:"_truncated,omitempty"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
 }
 
 // Fonction pour activer/désactiver le mode silencieux
@@ -50,11 +166,110 @@ func setSilentMode(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(silentMode)
 }
 
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"Configuration object required for setDefaults":  "Objet de configuration requis pour setDefaults",
+	"maxEntries must be a positive number":           "maxEntries doit être un nombre positif",
+	"maxBodySize cannot be negative":                 "maxBodySize ne peut pas être négatif",
+	"Failed to export HAR: %v":                       "Échec de l'export HAR: %v",
+	"URL is required":                                "L'URL est requise",
+	"Failed to marshal request data: %v":             "Échec de la sérialisation des données de la requête: %v",
+	"Failed to create request: %v":                   "Échec de la création de la requête: %v",
+	"Request failed: %v":                             "Échec de la requête: %v",
+	"Request failed with status %d":                  "La requête a échoué avec le statut %d",
+	"Error: %v":                                      "Erreur: %v",
+	"URL is required for GET request":                "L'URL est requise pour une requête GET",
+	"URL is required for POST request":               "L'URL est requise pour une requête POST",
+	"URL is required for PUT request":                "L'URL est requise pour une requête PUT",
+	"URL is required for DELETE request":             "L'URL est requise pour une requête DELETE",
+	"URL is required for PATCH request":              "L'URL est requise pour une requête PATCH",
+	"Configuration is required for request":          "La configuration est requise pour request",
+	"Response does not match the model (%d errors)":  "La réponse ne correspond pas au modèle (%d erreurs)",
+	"required field is missing":                      "champ requis manquant",
+	"must not be null":                               "ne doit pas être null",
+	"expected %s, got %s":                            "%s attendu, %s reçu",
+	"cannot convert %q to %s":                        "impossible de convertir %q en %s",
+	"unknown type %q in responseModel":               "type %q inconnu dans responseModel",
+	"array models must hold exactly one item":        "les modèles de tableau doivent contenir exactement un élément",
+	"maxSize must be a positive number":              "maxSize doit être un nombre positif",
+	"Browser is offline":                             "Le navigateur est hors ligne",
+	"Failed to serialize queue: %v":                  "Échec de la sérialisation de la file: %v",
+	"Serialized queue required for restoreQueue":     "File sérialisée requise pour restoreQueue",
+	"Invalid serialized queue: %v":                   "File sérialisée invalide: %v",
+	"setLocale requires exactly 1 argument (locale)": "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":          "Langue %q non prise en charge (disponibles: %s)",
+}
+
 // setDefaults - Set global default configuration
 func setDefaults(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "Configuration object required for setDefaults",
+			"error": localize("Configuration object required for setDefaults"),
 		})
 	}
 
@@ -93,11 +308,753 @@ func getDefaults(this js.Value, args []js.Value) interface{} {
 	})
 }
 
+// enableRequestLog - Start recording requests/responses for HAR export
+func enableRequestLog(this js.Value, args []js.Value) interface{} {
+	requestLog.Lock()
+	defer requestLog.Unlock()
+
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		if maxEntries := args[0].Get("maxEntries"); maxEntries.Type() == js.TypeNumber {
+			if maxEntries.Int() <= 0 {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("maxEntries must be a positive number"),
+				})
+			}
+			requestLog.maxEntries = maxEntries.Int()
+		}
+		if maxBodySize := args[0].Get("maxBodySize"); maxBodySize.Type() == js.TypeNumber {
+			if maxBodySize.Int() < 0 {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("maxBodySize cannot be negative"),
+				})
+			}
+			requestLog.maxBodySize = maxBodySize.Int()
+		}
+		if redactHeaders := args[0].Get("redactHeaders"); redactHeaders.Type() == js.TypeBoolean {
+			requestLog.redactHeaders = redactHeaders.Bool()
+		}
+	}
+
+	requestLog.enabled = true
+
+	if !silentMode {
+		fmt.Printf("Goxios WASM: Request logging enabled\n")
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"success":       true,
+		"maxEntries":    requestLog.maxEntries,
+		"maxBodySize":   requestLog.maxBodySize,
+		"redactHeaders": requestLog.redactHeaders,
+	})
+}
+
+// disableRequestLog - Stop recording requests, keeping recorded entries
+func disableRequestLog(this js.Value, args []js.Value) interface{} {
+	requestLog.Lock()
+	defer requestLog.Unlock()
+
+	requestLog.enabled = false
+
+	return js.ValueOf(map[string]interface{}{
+		"success": true,
+		"entries": len(requestLog.entries),
+	})
+}
+
+// clearRequestLog - Drop all recorded entries
+func clearRequestLog(this js.Value, args []js.Value) interface{} {
+	requestLog.Lock()
+	defer requestLog.Unlock()
+
+	cleared := len(requestLog.entries)
+	requestLog.entries = nil
+
+	return js.ValueOf(map[string]interface{}{
+		"success": true,
+		"cleared": cleared,
+	})
+}
+
+// exportHAR - Export recorded requests as a HAR 1.2 JSON string
+func exportHAR(this js.Value, args []js.Value) interface{} {
+	requestLog.Lock()
+	entries := make([]HAREntry, len(requestLog.entries))
+	copy(entries, requestLog.entries)
+	requestLog.Unlock()
+
+	har := HARLog{
+		Log: HARContent{
+			Version: "1.2",
+			Creator: HARCreator{Name: "goxios-wasm", Version: moduleVersion},
+			Entries: entries,
+		},
+	}
+
+	harBytes, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to export HAR: %v", err),
+		})
+	}
+
+	return js.ValueOf(string(harBytes))
+}
+
+// recordRequest - Append an entry to the request log when logging is enabled
+func recordRequest(config RequestConfig, body string, started, responded, finished time.Time, resp *http.Response, respBody []byte, requestErr error) {
+	requestLog.Lock()
+	defer requestLog.Unlock()
+
+	if !requestLog.enabled {
+		return
+	}
+
+	entry := HAREntry{
+		StartedDateTime: started.UTC().Format(time.RFC3339Nano),
+		Time:            durationMillis(finished.Sub(started)),
+		Request: HARRequest{
+			Method:      config.Method,
+			URL:         config.URL,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     harHeadersFromMap(config.Headers),
+			QueryString: []HARNameValue{},
+			Cookies:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(body),
+		},
+		Response: HARResponse{
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []HARNameValue{},
+			Cookies:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: HARTimings{
+			Wait:    durationMillis(responded.Sub(started)),
+			Receive: durationMillis(finished.Sub(responded)),
+		},
+	}
+
+	if parsedURL, err := url.Parse(config.URL); err == nil {
+		for name, values := range parsedURL.Query() {
+			for _, value := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, HARNameValue{Name: name, Value: value})
+			}
+		}
+	}
+
+	if body != "" {
+		text, _ := truncateBody(body, requestLog.maxBodySize)
+		entry.Request.PostData = &HARPostData{
+			MimeType: config.Headers["Content-Type"],
+			Text:     text,
+		}
+	}
+
+	if requestErr != nil {
+		entry.Error = requestErr.Error()
+	}
+
+	if resp != nil {
+		text, truncated := truncateBody(string(respBody), requestLog.maxBodySize)
+		entry.Response.Status = resp.StatusCode
+		entry.Response.StatusText = http.StatusText(resp.StatusCode)
+		if resp.Proto != "" {
+			entry.Response.HTTPVersion = resp.Proto
+		}
+		entry.Response.BodySize = len(respBody)
+		entry.Response.RedirectURL = resp.Header.Get("Location")
+		entry.Response.Content = HARContentBody{
+			Size:      len(respBody),
+			MimeType:  resp.Header.Get("Content-Type"),
+			Text:      text,
+			Truncated: truncated,
+		}
+		for name, values := range resp.Header {
+			for _, value := range values {
+				entry.Response.Headers = append(entry.Response.Headers, HARNameValue{Name: name, Value: harHeaderValue(name, value)})
+			}
+		}
+	}
+
+	requestLog.entries = append(requestLog.entries, entry)
+	if overflow := len(requestLog.entries) - requestLog.maxEntries; overflow > 0 {
+		requestLog.entries = requestLog.entries[overflow:]
+	}
+}
+
+// Fonctions utilitaires pour le journal des requêtes
+func harHeadersFromMap(headers map[string]string) []HARNameValue {
+	result := make([]HARNameValue, 0, len(headers))
+	for name, value := range headers {
+		result = append(result, HARNameValue{Name: name, Value: harHeaderValue(name, value)})
+	}
+	return result
+}
+
+// sensitiveHeaders carry credentials and are replaced by redactedValue in HAR entries
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+const redactedValue = "[REDACTED]"
+
+// harHeaderValue returns value, or redactedValue for a credential header unless redaction was turned off.
+// Callers hold requestLog's lock.
+func harHeaderValue(name, value string) string {
+	if requestLog.redactHeaders && sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+		return redactedValue
+	}
+	return value
+}
+
+// truncateBody cuts body to at most maxSize bytes without splitting a UTF-8 sequence
+func truncateBody(body string, maxSize int) (string, bool) {
+	if len(body) <= maxSize {
+		return body, false
+	}
+	cut := maxSize
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut], true
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// QueuedRequest is a mutating request held by the offline queue until connectivity returns
+type QueuedRequest struct {
+	ID        string        `json:"id"`
+	Config    RequestConfig `json:"config"`
+	QueuedAt  string        `json:"queuedAt"`
+	Attempts  int           `json:"attempts"`
+	LastError string        `json:"lastError,omitempty"`
+}
+
+// Offline queue state: requests waiting for the network and the host hooks
+var offlineQueue = struct {
+	sync.Mutex
+	enabled    bool
+	maxSize    int
+	methods    map[string]bool
+	entries    []QueuedRequest
+	sequence   int
+	replaying  bool
+	onConflict js.Value
+	persist    js.Value
+	onOnline   js.Func
+	listening  bool
+}{
+	maxSize: 100,
+	methods: map[string]bool{"POST": true, "PUT": true, "PATCH": true, "DELETE": true},
+}
+
+// enableOfflineQueue - Queue mutating requests while offline and replay them when the network returns
+func enableOfflineQueue(this js.Value, args []js.Value) interface{} {
+	offlineQueue.Lock()
+	defer offlineQueue.Unlock()
+
+	autoReplay := true
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		options := args[0]
+		if maxSize := options.Get("maxSize"); maxSize.Type() == js.TypeNumber {
+			if maxSize.Int() <= 0 {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("maxSize must be a positive number"),
+				})
+			}
+			offlineQueue.maxSize = maxSize.Int()
+		}
+		if methods := options.Get("methods"); methods.Type() == js.TypeObject {
+			offlineQueue.methods = make(map[string]bool)
+			for i := 0; i < methods.Length(); i++ {
+				offlineQueue.methods[strings.ToUpper(methods.Index(i).String())] = true
+			}
+		}
+		if replay := options.Get("autoReplay"); replay.Type() == js.TypeBoolean {
+			autoReplay = replay.Bool()
+		}
+		if onConflict := options.Get("onConflict"); onConflict.Type() == js.TypeFunction {
+			offlineQueue.onConflict = onConflict
+		}
+		if persist := options.Get("persist"); persist.Type() == js.TypeFunction {
+			offlineQueue.persist = persist
+		}
+	}
+
+	offlineQueue.enabled = true
+
+	// Replay as soon as the browser reports the network is back
+	window := js.Global().Get("window")
+	if autoReplay && !offlineQueue.listening && window.Type() == js.TypeObject {
+		offlineQueue.onOnline = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			go replayOfflineQueue()
+			return nil
+		})
+		window.Call("addEventListener", "online", offlineQueue.onOnline)
+		offlineQueue.listening = true
+	} else if !autoReplay {
+		stopOnlineListener()
+	}
+
+	methods := []interface{}{}
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		if offlineQueue.methods[method] {
+			methods = append(methods, method)
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Goxios WASM: Offline queue enabled\n")
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"success":    true,
+		"maxSize":    offlineQueue.maxSize,
+		"methods":    methods,
+		"autoReplay": offlineQueue.listening,
+		"size":       len(offlineQueue.entries),
+	})
+}
+
+// disableOfflineQueue - Stop queueing requests, keeping the queued ones for serializeQueue or replayQueue
+func disableOfflineQueue(this js.Value, args []js.Value) interface{} {
+	offlineQueue.Lock()
+	defer offlineQueue.Unlock()
+
+	offlineQueue.enabled = false
+	stopOnlineListener()
+	offlineQueue.onConflict = js.Undefined()
+	offlineQueue.persist = js.Undefined()
+
+	return js.ValueOf(map[string]interface{}{
+		"success": true,
+		"size":    len(offlineQueue.entries),
+	})
+}
+
+// clearOfflineQueue - Drop all queued requests
+func clearOfflineQueue(this js.Value, args []js.Value) interface{} {
+	offlineQueue.Lock()
+	cleared := len(offlineQueue.entries)
+	offlineQueue.entries = nil
+	offlineQueue.Unlock()
+
+	persistOfflineQueue()
+
+	return js.ValueOf(map[string]interface{}{
+		"success": true,
+		"cleared": cleared,
+	})
+}
+
+// serializeQueue - Export the queued requests as a JSON string the host app can store
+func serializeQueue(this js.Value, args []js.Value) interface{} {
+	serialized, err := offlineQueueJSON()
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to serialize queue: %v", err),
+		})
+	}
+	return js.ValueOf(serialized)
+}
+
+// restoreQueue - Add previously serialized requests back to the queue, skipping those already queued
+func restoreQueue(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || args[0].IsUndefined() || args[0].IsNull() {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Serialized queue required for restoreQueue"),
+		})
+	}
+
+	serialized := args[0].String()
+	if args[0].Type() == js.TypeObject {
+		serialized = js.Global().Get("JSON").Call("stringify", args[0]).String()
+	}
+	var entries []QueuedRequest
+	if err := json.Unmarshal([]byte(serialized), &entries); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid serialized queue: %v", err),
+		})
+	}
+
+	offlineQueue.Lock()
+	known := make(map[string]bool, len(offlineQueue.entries))
+	for _, entry := range offlineQueue.entries {
+		known[entry.ID] = true
+	}
+	restored, skipped := 0, 0
+	for _, entry := range entries {
+		if entry.Config.URL == "" || (entry.ID != "" && known[entry.ID]) || len(offlineQueue.entries) >= offlineQueue.maxSize {
+			skipped++
+			continue
+		}
+		if entry.ID == "" {
+			entry.ID = nextQueueID()
+		}
+		if entry.Config.Headers == nil {
+			entry.Config.Headers = make(map[string]string)
+		}
+		known[entry.ID] = true
+		offlineQueue.entries = append(offlineQueue.entries, entry)
+		restored++
+	}
+	size := len(offlineQueue.entries)
+	offlineQueue.Unlock()
+
+	if !silentMode {
+		fmt.Printf("Goxios WASM: Restored %d queued requests\n", restored)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"success":  true,
+		"restored": restored,
+		"skipped":  skipped,
+		"size":     size,
+	})
+}
+
+// replayQueue - Resend the queued requests in order, resolving with a summary of the replay
+func replayQueue(this js.Value, args []js.Value) interface{} {
+	promiseConstructor := js.Global().Get("Promise")
+	executor := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		resolve := args[0]
+		go func() {
+			resolve.Invoke(js.ValueOf(replayOfflineQueue()))
+		}()
+		return nil
+	})
+	defer executor.Release()
+
+	return promiseConstructor.New(executor)
+}
+
+// replayOfflineQueue sends the queued requests one by one. A request that still cannot reach the server
+// stops the replay so that later mutations are not applied before it; a request rejected by the server
+// goes through the onConflict hook, which may retry it on the next replay, resend it with a corrected
+// configuration, or drop it (the default).
+func replayOfflineQueue() map[string]interface{} {
+	offlineQueue.Lock()
+	if offlineQueue.replaying {
+		offlineQueue.Unlock()
+		return map[string]interface{}{"replaying": true}
+	}
+	offlineQueue.replaying = true
+	entries := make([]QueuedRequest, len(offlineQueue.entries))
+	copy(entries, offlineQueue.entries)
+	onConflict := offlineQueue.onConflict
+	offlineQueue.Unlock()
+
+	defer func() {
+		offlineQueue.Lock()
+		offlineQueue.replaying = false
+		offlineQueue.Unlock()
+	}()
+
+	replayed, retried := 0, 0
+	failed := []interface{}{}
+	for _, entry := range entries {
+		if browserOffline() {
+			break
+		}
+
+		entry.Attempts++
+		_, failure, network := sendRequest(entry.Config)
+		if network {
+			entry.LastError = failure.Message
+			updateQueued(entry, true)
+			break
+		}
+		if failure != nil && onConflict.Type() == js.TypeFunction {
+			decision := onConflict.Invoke(convertToJSValue(entry), convertToJSValue(*failure))
+			switch {
+			case decision.Type() == js.TypeString && decision.String() == "retry":
+				entry.LastError = failure.Message
+				updateQueued(entry, true)
+				retried++
+				continue
+			case decision.Type() == js.TypeObject:
+				entry.Config = mergeConfig(entry.Config, parseConfig(decision))
+				entry.Attempts++
+				_, failure, network = sendRequest(entry.Config)
+			}
+			if network {
+				entry.LastError = failure.Message
+				updateQueued(entry, true)
+				break
+			}
+		}
+
+		updateQueued(entry, false)
+		if failure != nil {
+			failed = append(failed, queueFailure(entry, failure))
+			continue
+		}
+		replayed++
+	}
+
+	offlineQueue.Lock()
+	remaining := len(offlineQueue.entries)
+	offlineQueue.Unlock()
+	persistOfflineQueue()
+
+	if !silentMode {
+		fmt.Printf("Goxios WASM: Replayed %d queued requests, %d failed, %d remaining\n", replayed, len(failed), remaining)
+	}
+
+	return map[string]interface{}{
+		"replayed":  replayed,
+		"retried":   retried,
+		"failed":    failed,
+		"remaining": remaining,
+	}
+}
+
+// queueRequest adds a request to the offline queue when the queue is enabled, accepts its method and has room
+func queueRequest(config RequestConfig, reason string) (QueuedRequest, bool) {
+	offlineQueue.Lock()
+	if !offlineQueue.enabled || !offlineQueue.methods[strings.ToUpper(config.Method)] || len(offlineQueue.entries) >= offlineQueue.maxSize {
+		offlineQueue.Unlock()
+		return QueuedRequest{}, false
+	}
+	entry := QueuedRequest{
+		ID:        nextQueueID(),
+		Config:    config,
+		QueuedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		LastError: reason,
+	}
+	offlineQueue.entries = append(offlineQueue.entries, entry)
+	offlineQueue.Unlock()
+
+	persistOfflineQueue()
+
+	if !silentMode {
+		fmt.Printf("Goxios WASM: Queued %s %s until the network returns\n", config.Method, config.URL)
+	}
+	return entry, true
+}
+
+// updateQueued stores the replayed entry back in the queue, or removes it when keep is false
+func updateQueued(entry QueuedRequest, keep bool) {
+	offlineQueue.Lock()
+	defer offlineQueue.Unlock()
+
+	for i, queued := range offlineQueue.entries {
+		if queued.ID != entry.ID {
+			continue
+		}
+		if keep {
+			offlineQueue.entries[i] = entry
+		} else {
+			offlineQueue.entries = append(offlineQueue.entries[:i], offlineQueue.entries[i+1:]...)
+		}
+		return
+	}
+}
+
+// persistOfflineQueue hands the serialized queue to the persist hook. It runs outside the lock so the
+// hook may call back into the module.
+func persistOfflineQueue() {
+	offlineQueue.Lock()
+	persist := offlineQueue.persist
+	offlineQueue.Unlock()
+
+	if persist.Type() != js.TypeFunction {
+		return
+	}
+	if serialized, err := offlineQueueJSON(); err == nil {
+		persist.Invoke(serialized)
+	}
+}
+
+// offlineQueueJSON serializes the queued requests
+func offlineQueueJSON() (string, error) {
+	offlineQueue.Lock()
+	entries := make([]QueuedRequest, len(offlineQueue.entries))
+	copy(entries, offlineQueue.entries)
+	offlineQueue.Unlock()
+
+	data, err := json.Marshal(entries)
+	return string(data), err
+}
+
+// queuedResponse is what a queued request resolves with: no status yet, and the queue entry id
+func queuedResponse(entry QueuedRequest) Response {
+	return Response{
+		Status:   0,
+		Headers:  map[string]string{},
+		Config:   entry.Config,
+		Queued:   true,
+		QueueID:  entry.ID,
+		QueuedAt: entry.QueuedAt,
+	}
+}
+
+// queueFailure describes a replayed request the server rejected
+func queueFailure(entry QueuedRequest, failure *HTTPError) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      entry.ID,
+		"status":  failure.Status,
+		"message": failure.Message,
+		"config":  convertToJSValue(entry.Config),
+	}
+}
+
+// nextQueueID returns a queue entry id unique across serialized and restored queues
+func nextQueueID() string {
+	offlineQueue.sequence++
+	return fmt.Sprintf("q-%d-%d", time.Now().UnixMilli(), offlineQueue.sequence)
+}
+
+// stopOnlineListener removes the automatic replay listener; callers hold the queue lock
+func stopOnlineListener() {
+	if !offlineQueue.listening {
+		return
+	}
+	js.Global().Get("window").Call("removeEventListener", "online", offlineQueue.onOnline)
+	offlineQueue.onOnline.Release()
+	offlineQueue.listening = false
+}
+
+// browserOffline reports whether navigator.onLine says the browser has no network
+func browserOffline() bool {
+	navigator := js.Global().Get("navigator")
+	if navigator.Type() != js.TypeObject {
+		return false
+	}
+	onLine := navigator.Get("onLine")
+	return onLine.Type() == js.TypeBoolean && !onLine.Bool()
+}
+
+// Build metadata, injected by wasm-manager build through -ldflags -X
+var (
+	moduleVersion = "0.2.2"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"http-methods",
+	"instances",
+	"defaults",
+	"request-log",
+	"har-export",
+	"response-model",
+	"offline-queue",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	requestLog.Lock()
+	logEntries := len(requestLog.entries)
+	requestLog.Unlock()
+
+	offlineQueue.Lock()
+	queuedRequests := len(offlineQueue.entries)
+	offlineQueue.Unlock()
+
+	liveHandles.Lock()
+	defer liveHandles.Unlock()
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"instances":         liveHandles.instances,
+		"pendingRequests":   liveHandles.pendingRequests,
+		"requestLogEntries": logEntries,
+		"queuedRequests":    queuedRequests,
+	}))
+}
+
+// releaseResources - Drop recorded HAR entries and return freed memory to the runtime.
+// Instances created with create() stay usable until their own release() is called.
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	requestLog.Lock()
+	logEntries := len(requestLog.entries)
+	requestLog.entries = nil
+	requestLog.Unlock()
+
+	released := map[string]interface{}{
+		"requestLogEntries": logEntries,
+	}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	advertised := getAvailableFunctions(this, nil).(js.Value)
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "goxios-wasm",
+		"version":         moduleVersion,
+		"description":     "Axios-like HTTP client module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
 // getAvailableFunctions - Get list of available functions
 func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
-	functions := []string{
+	functions := []interface{}{
 		"get", "post", "put", "delete", "patch", "request", "create",
-		"setDefaults", "getDefaults", "getAvailableFunctions", "setSilentMode",
+		"setDefaults", "getDefaults", "enableRequestLog", "disableRequestLog",
+		"clearRequestLog", "exportHAR", "enableOfflineQueue", "disableOfflineQueue", "clearOfflineQueue",
+		"serializeQueue", "restoreQueue", "replayQueue", "getAvailableFunctions", "setSilentMode", "setLocale", "getModuleInfo",
+		"getMemoryStats", "releaseResources",
 	}
 	return js.ValueOf(functions)
 }
@@ -248,29 +1205,50 @@ func create(this js.Value, args []js.Value) interface{} {
 	instance := js.Global().Get("Object").New()
 
 	// Ajouter les méthodes à l'instance
-	instance.Set("get", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		return instanceGet(defaultConfig, args)
-	}))
-
-	instance.Set("post", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		return instancePost(defaultConfig, args)
-	}))
-
-	instance.Set("put", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		return instancePut(defaultConfig, args)
-	}))
+	methods := map[string]js.Func{
+		"get": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return instanceGet(defaultConfig, args)
+		}),
+		"post": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return instancePost(defaultConfig, args)
+		}),
+		"put": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return instancePut(defaultConfig, args)
+		}),
+		"delete": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return instanceDelete(defaultConfig, args)
+		}),
+		"patch": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return instancePatch(defaultConfig, args)
+		}),
+		"request": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return instanceRequest(defaultConfig, args)
+		}),
+	}
+	for name, method := range methods {
+		instance.Set(name, method)
+	}
 
-	instance.Set("delete", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		return instanceDelete(defaultConfig, args)
-	}))
+	// release() libère les fonctions Go de l'instance, qui devient inutilisable
+	var release js.Func
+	release = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		for name, method := range methods {
+			instance.Delete(name)
+			method.Release()
+		}
+		instance.Delete("release")
+		release.Release()
 
-	instance.Set("patch", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		return instancePatch(defaultConfig, args)
-	}))
+		liveHandles.Lock()
+		liveHandles.instances--
+		liveHandles.Unlock()
+		return nil
+	})
+	instance.Set("release", release)
 
-	instance.Set("request", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		return instanceRequest(defaultConfig, args)
-	}))
+	liveHandles.Lock()
+	liveHandles.instances++
+	liveHandles.Unlock()
 
 	return instance
 }
@@ -405,6 +1383,9 @@ func mergeConfig(base, override RequestConfig) RequestConfig {
 	if override.Timeout > 0 {
 		result.Timeout = override.Timeout
 	}
+	if override.ResponseModel != nil {
+		result.ResponseModel = override.ResponseModel
+	}
 
 	// Fusionner les headers
 	if result.Headers == nil {
@@ -440,6 +1421,9 @@ func parseConfig(configJS js.Value) RequestConfig {
 		if headers := configJS.Get("headers"); !headers.IsUndefined() {
 			parseHeaders(headers, config.Headers)
 		}
+		if model := configJS.Get("responseModel"); !model.IsUndefined() && !model.IsNull() {
+			config.ResponseModel = parseJSValue(model)
+		}
 	}
 
 	return config
@@ -492,165 +1476,395 @@ func parseJSValue(value js.Value) interface{} {
 	}
 }
 
+// Date layouts accepted by the "date" type of a responseModel, after RFC 3339
+var responseModelDateLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+// applyResponseModel validates data against model and returns it with coerced values.
+// A model is a type name ("string", "number", "integer", "boolean", "date", "object", "array" or "any",
+// optional with a trailing "?"), a one-item array describing every element, or an object of field
+// models whose keys may end with "?" when the field is optional. Unlisted fields are kept as is.
+func applyResponseModel(model, data interface{}, path string, optional bool, errs *[]ValidationError) interface{} {
+	fail := func(message string) interface{} {
+		*errs = append(*errs, ValidationError{Path: path, Message: message})
+		return data
+	}
+
+	if name, ok := model.(string); ok && strings.HasSuffix(name, "?") {
+		model = strings.TrimSuffix(name, "?")
+		optional = true
+	}
+	if data == nil {
+		if optional {
+			return nil
+		}
+		return fail(localize("must not be null"))
+	}
+
+	switch spec := model.(type) {
+	case string:
+		return coerceResponseValue(spec, data, fail)
+	case []interface{}:
+		if len(spec) != 1 {
+			return fail(localize("array models must hold exactly one item"))
+		}
+		items, ok := data.([]interface{})
+		if !ok {
+			return fail(localize("expected %s, got %s", "array", jsonTypeName(data)))
+		}
+		typed := make([]interface{}, len(items))
+		for i, item := range items {
+			typed[i] = applyResponseModel(spec[0], item, fmt.Sprintf("%s[%d]", path, i), false, errs)
+		}
+		return typed
+	case map[string]interface{}:
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return fail(localize("expected %s, got %s", "object", jsonTypeName(data)))
+		}
+		typed := make(map[string]interface{}, len(object))
+		for key, value := range object {
+			typed[key] = value
+		}
+
+		// Sorted keys keep the error order stable between calls
+		keys := make([]string, 0, len(spec))
+		for key := range spec {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			field := strings.TrimSuffix(key, "?")
+			fieldPath := path + "." + field
+			value, present := object[field]
+			if !present {
+				fieldOptional := field != key
+				if name, ok := spec[key].(string); ok && strings.HasSuffix(name, "?") {
+					fieldOptional = true
+				}
+				if !fieldOptional {
+					*errs = append(*errs, ValidationError{Path: fieldPath, Message: localize("required field is missing")})
+				}
+				continue
+			}
+			typed[field] = applyResponseModel(spec[key], value, fieldPath, field != key, errs)
+		}
+		return typed
+	default:
+		return fail(localize("unknown type %q in responseModel", fmt.Sprint(model)))
+	}
+}
+
+// coerceResponseValue converts a scalar to the named type, strings being parsed when possible
+func coerceResponseValue(name string, data interface{}, fail func(string) interface{}) interface{} {
+	switch name {
+	case "any":
+		return data
+	case "object":
+		if _, ok := data.(map[string]interface{}); !ok {
+			return fail(localize("expected %s, got %s", name, jsonTypeName(data)))
+		}
+		return data
+	case "array":
+		if _, ok := data.([]interface{}); !ok {
+			return fail(localize("expected %s, got %s", name, jsonTypeName(data)))
+		}
+		return data
+	case "string":
+		switch value := data.(type) {
+		case string:
+			return value
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(value)
+		}
+	case "number", "integer":
+		number, ok := data.(float64)
+		if text, isString := data.(string); isString {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			if err != nil {
+				return fail(localize("cannot convert %q to %s", text, name))
+			}
+			number, ok = parsed, true
+		}
+		if ok && name == "integer" && number != float64(int64(number)) {
+			return fail(localize("cannot convert %q to %s", fmt.Sprint(data), name))
+		}
+		if ok {
+			return number
+		}
+	case "boolean":
+		switch value := data.(type) {
+		case bool:
+			return value
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return fail(localize("cannot convert %q to %s", value, name))
+			}
+			return parsed
+		}
+	case "date":
+		// Dates become ISO 8601 strings in UTC, numbers being read as Unix milliseconds like Date.now()
+		switch value := data.(type) {
+		case float64:
+			return time.UnixMilli(int64(value)).UTC().Format(time.RFC3339Nano)
+		case string:
+			text := strings.TrimSpace(value)
+			parsed, err := time.Parse(time.RFC3339Nano, text)
+			for _, layout := range responseModelDateLayouts {
+				if err == nil {
+					break
+				}
+				parsed, err = time.Parse(layout, text)
+			}
+			if err != nil {
+				return fail(localize("cannot convert %q to %s", value, name))
+			}
+			return parsed.UTC().Format(time.RFC3339Nano)
+		}
+	default:
+		return fail(localize("unknown type %q in responseModel", name))
+	}
+
+	return fail(localize("expected %s, got %s", name, jsonTypeName(data)))
+}
+
+// jsonTypeName names the JSON type of a decoded value for validation messages
+func jsonTypeName(data interface{}) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
 // Fonction principale pour faire la requête HTTP
 func makeRequest(config RequestConfig) interface{} {
 	// Créer une Promise JavaScript
 	promiseConstructor := js.Global().Get("Promise")
-	return promiseConstructor.New(js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	// The executor runs synchronously inside the Promise constructor, so it can be released right after
+	executor := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		resolve := args[0]
 		reject := args[1]
 
+		liveHandles.Lock()
+		liveHandles.pendingRequests++
+		liveHandles.Unlock()
+
 		go func() {
-			// Validation de l'URL
-			if config.URL == "" {
-				rejectWithError(reject, HTTPError{
-					Message: "URL is required",
-					Status:  0,
-					Config:  config,
-				})
-				return
+			defer func() {
+				liveHandles.Lock()
+				liveHandles.pendingRequests--
+				liveHandles.Unlock()
+			}()
+
+			// Les requêtes de modification attendent le retour du réseau dans la file hors ligne
+			if browserOffline() {
+				if entry, ok := queueRequest(config, localize("Browser is offline")); ok {
+					resolve.Invoke(convertToJSValue(queuedResponse(entry)))
+					return
+				}
 			}
 
-			// Validation de la méthode
-			if config.Method == "" {
-				config.Method = "GET"
+			response, failure, network := sendRequest(config)
+			if network {
+				if entry, ok := queueRequest(config, failure.Message); ok {
+					resolve.Invoke(convertToJSValue(queuedResponse(entry)))
+					return
+				}
+			}
+			if failure != nil {
+				rejectWithError(reject, *failure)
+				return
 			}
 
-			// Préparation des données
-			var dataString string
-			if config.Data != nil {
-				if config.Headers == nil {
-					config.Headers = make(map[string]string)
-				}
+			// Convertir la réponse en objet JavaScript
+			responseJS := convertToJSValue(*response)
+			resolve.Invoke(responseJS)
 
-				// Si les données sont un objet, les convertir en JSON
-				if _, ok := config.Data.(map[string]interface{}); ok {
-					dataBytes, err := json.Marshal(config.Data)
-					if err != nil {
-						rejectWithError(reject, HTTPError{
-							Message: fmt.Sprintf("Failed to marshal request data: %v", err),
-							Status:  0,
-							Config:  config,
-						})
-						return
-					}
-					dataString = string(dataBytes)
-					if config.Headers["Content-Type"] == "" {
-						config.Headers["Content-Type"] = "application/json"
-					}
-				} else if str, ok := config.Data.(string); ok {
-					dataString = str
-				}
+			if !silentMode {
+				fmt.Printf("Goxios WASM: Response %d from %s\n", response.Status, config.URL)
 			}
+		}()
 
-			// Créer la requête HTTP
-			var req *http.Request
-			var err error
+		return nil
+	})
+	defer executor.Release()
 
-			if dataString != "" {
-				req, err = http.NewRequest(config.Method, config.URL, strings.NewReader(dataString))
-			} else {
-				req, err = http.NewRequest(config.Method, config.URL, nil)
-			}
+	return promiseConstructor.New(executor)
+}
+
+// sendRequest performs the HTTP exchange and returns the response or the error to reject with.
+// network reports that the server could not be reached, the offline queue then keeps the request.
+func sendRequest(config RequestConfig) (*Response, *HTTPError, bool) {
+	// Validation de l'URL
+	if config.URL == "" {
+		return nil, &HTTPError{
+			Message: localize("URL is required"),
+			Status:  0,
+			Config:  config,
+		}, false
+	}
+
+	// Validation de la méthode
+	if config.Method == "" {
+		config.Method = "GET"
+	}
+
+	// Préparation des données
+	var dataString string
+	if config.Data != nil {
+		if config.Headers == nil {
+			config.Headers = make(map[string]string)
+		}
 
+		// Si les données sont un objet, les convertir en JSON
+		if _, ok := config.Data.(map[string]interface{}); ok {
+			dataBytes, err := json.Marshal(config.Data)
 			if err != nil {
-				rejectWithError(reject, HTTPError{
-					Message: fmt.Sprintf("Failed to create request: %v", err),
+				return nil, &HTTPError{
+					Message: localize("Failed to marshal request data: %v", err),
 					Status:  0,
 					Config:  config,
-				})
-				return
+				}, false
 			}
-
-			// Ajouter les headers
-			for key, value := range config.Headers {
-				req.Header.Set(key, value)
+			dataString = string(dataBytes)
+			if config.Headers["Content-Type"] == "" {
+				config.Headers["Content-Type"] = "application/json"
 			}
+		} else if str, ok := config.Data.(string); ok {
+			dataString = str
+		}
+	}
 
-			// Créer le client HTTP avec timeout
-			client := &http.Client{
-				Timeout: time.Duration(config.Timeout) * time.Millisecond,
-			}
+	// Créer la requête HTTP
+	var req *http.Request
+	var err error
 
-			if !silentMode {
-				fmt.Printf("Goxios WASM: %s %s\n", config.Method, config.URL)
-			}
+	if dataString != "" {
+		req, err = http.NewRequest(config.Method, config.URL, strings.NewReader(dataString))
+	} else {
+		req, err = http.NewRequest(config.Method, config.URL, nil)
+	}
 
-			// Faire la requête
-			resp, err := client.Do(req)
-			if err != nil {
-				rejectWithError(reject, HTTPError{
-					Message: fmt.Sprintf("Request failed: %v", err),
-					Status:  0,
-					Config:  config,
-				})
-				return
-			}
-			defer resp.Body.Close()
+	if err != nil {
+		return nil, &HTTPError{
+			Message: localize("Failed to create request: %v", err),
+			Status:  0,
+			Config:  config,
+		}, false
+	}
 
-			// Lire la réponse
-			var responseData interface{}
-			contentType := resp.Header.Get("Content-Type")
+	// Ajouter les headers
+	for key, value := range config.Headers {
+		req.Header.Set(key, value)
+	}
 
-			if strings.Contains(contentType, "application/json") {
-				var jsonData interface{}
-				decoder := json.NewDecoder(resp.Body)
-				if err := decoder.Decode(&jsonData); err == nil {
-					responseData = jsonData
-				}
-			} else {
-				// Pour les autres types de contenu, lire comme string
-				bodyBytes := make([]byte, 0)
-				buffer := make([]byte, 1024)
-				for {
-					n, err := resp.Body.Read(buffer)
-					if n > 0 {
-						bodyBytes = append(bodyBytes, buffer[:n]...)
-					}
-					if err != nil {
-						break
-					}
-				}
-				responseData = string(bodyBytes)
-			}
+	// Créer le client HTTP avec timeout
+	client := &http.Client{
+		Timeout: time.Duration(config.Timeout) * time.Millisecond,
+	}
 
-			// Créer la réponse
-			response := Response{
-				Data:    responseData,
-				Status:  resp.StatusCode,
-				Headers: make(map[string]string),
-				Config:  config,
-			}
+	if !silentMode {
+		fmt.Printf("Goxios WASM: %s %s\n", config.Method, config.URL)
+	}
 
-			// Copier les headers de réponse
-			for key, values := range resp.Header {
-				if len(values) > 0 {
-					response.Headers[key] = values[0]
-				}
-			}
+	// Faire la requête
+	startedAt := time.Now()
+	resp, err := client.Do(req)
+	respondedAt := time.Now()
+	if err != nil {
+		recordRequest(config, dataString, startedAt, respondedAt, respondedAt, nil, nil, err)
+		return nil, &HTTPError{
+			Message: localize("Request failed: %v", err),
+			Status:  0,
+			Config:  config,
+		}, true
+	}
+	defer resp.Body.Close()
+
+	// Lire la réponse
+	var responseData interface{}
+	contentType := resp.Header.Get("Content-Type")
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	recordRequest(config, dataString, startedAt, respondedAt, time.Now(), resp, bodyBytes, nil)
+
+	if strings.Contains(contentType, "application/json") {
+		var jsonData interface{}
+		if err := json.Unmarshal(bodyBytes, &jsonData); err == nil {
+			responseData = jsonData
+		}
+	} else {
+		// Pour les autres types de contenu, lire comme string
+		responseData = string(bodyBytes)
+	}
 
-			// Vérifier le status code
-			if resp.StatusCode >= 400 {
-				rejectWithError(reject, HTTPError{
-					Message:  fmt.Sprintf("Request failed with status %d", resp.StatusCode),
-					Status:   resp.StatusCode,
-					Response: &response,
-					Config:   config,
-				})
-				return
-			}
+	// Créer la réponse
+	response := Response{
+		Data:    responseData,
+		Status:  resp.StatusCode,
+		Headers: make(map[string]string),
+		Config:  config,
+	}
 
-			// Convertir la réponse en objet JavaScript
-			responseJS := convertToJSValue(response)
-			resolve.Invoke(responseJS)
+	// Copier les headers de réponse
+	for key, values := range resp.Header {
+		if len(values) > 0 {
+			response.Headers[key] = values[0]
+		}
+	}
 
-			if !silentMode {
-				fmt.Printf("Goxios WASM: Response %d from %s\n", resp.StatusCode, config.URL)
+	// Vérifier le status code
+	if resp.StatusCode >= 400 {
+		return nil, &HTTPError{
+			Message:  localize("Request failed with status %d", resp.StatusCode),
+			Status:   resp.StatusCode,
+			Response: &response,
+			Config:   config,
+		}, false
+	}
+
+	// Typer la réponse selon le responseModel de la requête
+	if config.ResponseModel != nil {
+		if text, ok := responseData.(string); ok {
+			var jsonData interface{}
+			if err := json.Unmarshal([]byte(text), &jsonData); err == nil {
+				responseData = jsonData
 			}
-		}()
+		}
 
-		return nil
-	}))
+		var errs []ValidationError
+		typed := applyResponseModel(config.ResponseModel, responseData, "$", false, &errs)
+		if len(errs) > 0 {
+			return nil, &HTTPError{
+				Message:  localize("Response does not match the model (%d errors)", len(errs)),
+				Status:   resp.StatusCode,
+				Response: &response,
+				Config:   config,
+				Errors:   errs,
+			}, false
+		}
+		response.Data = typed
+	}
+
+	return &response, nil, false
 }
 
 // Fonction utilitaire pour rejeter une promesse avec une erreur
@@ -662,22 +1876,25 @@ func rejectWithError(reject js.Value, err HTTPError) {
 // Fonction utilitaire pour créer une promesse d'erreur
 func createErrorPromise(message string) interface{} {
 	promiseConstructor := js.Global().Get("Promise")
-	return promiseConstructor.New(js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	executor := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		reject := args[1]
 		errorJS := convertToJSValue(HTTPError{
-			Message: message,
+			Message: localize(message),
 			Status:  0,
 		})
 		reject.Invoke(errorJS)
 		return nil
-	}))
+	})
+	defer executor.Release()
+
+	return promiseConstructor.New(executor)
 }
 
 // Fonction utilitaire pour convertir les structures Go en valeurs JavaScript
 func convertToJSValue(data interface{}) js.Value {
 	jsonBytes, err := json.Marshal(data)
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error: %v", err))
+		return js.ValueOf(localize("Error: %v", err))
 	}
 
 	jsonString := string(jsonBytes)
@@ -700,8 +1917,22 @@ func main() {
 	goxios.Set("create", js.FuncOf(create))
 	goxios.Set("setDefaults", js.FuncOf(setDefaults))
 	goxios.Set("getDefaults", js.FuncOf(getDefaults))
+	goxios.Set("enableRequestLog", js.FuncOf(enableRequestLog))
+	goxios.Set("disableRequestLog", js.FuncOf(disableRequestLog))
+	goxios.Set("clearRequestLog", js.FuncOf(clearRequestLog))
+	goxios.Set("exportHAR", js.FuncOf(exportHAR))
+	goxios.Set("enableOfflineQueue", js.FuncOf(enableOfflineQueue))
+	goxios.Set("disableOfflineQueue", js.FuncOf(disableOfflineQueue))
+	goxios.Set("clearOfflineQueue", js.FuncOf(clearOfflineQueue))
+	goxios.Set("serializeQueue", js.FuncOf(serializeQueue))
+	goxios.Set("restoreQueue", js.FuncOf(restoreQueue))
+	goxios.Set("replayQueue", js.FuncOf(replayQueue))
 	goxios.Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	goxios.Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	goxios.Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	goxios.Set("releaseResources", js.FuncOf(releaseResources))
 	goxios.Set("setSilentMode", js.FuncOf(setSilentMode))
+	goxios.Set("setLocale", js.FuncOf(setLocale))
 
 	// Exposer l'objet goxios globalement
 	js.Global().Set("goxios", goxios)
@@ -716,8 +1947,22 @@ func main() {
 	js.Global().Set("create", js.FuncOf(create))
 	js.Global().Set("setDefaults", js.FuncOf(setDefaults))
 	js.Global().Set("getDefaults", js.FuncOf(getDefaults))
+	js.Global().Set("enableRequestLog", js.FuncOf(enableRequestLog))
+	js.Global().Set("disableRequestLog", js.FuncOf(disableRequestLog))
+	js.Global().Set("clearRequestLog", js.FuncOf(clearRequestLog))
+	js.Global().Set("exportHAR", js.FuncOf(exportHAR))
+	js.Global().Set("enableOfflineQueue", js.FuncOf(enableOfflineQueue))
+	js.Global().Set("disableOfflineQueue", js.FuncOf(disableOfflineQueue))
+	js.Global().Set("clearOfflineQueue", js.FuncOf(clearOfflineQueue))
+	js.Global().Set("serializeQueue", js.FuncOf(serializeQueue))
+	js.Global().Set("restoreQueue", js.FuncOf(restoreQueue))
+	js.Global().Set("replayQueue", js.FuncOf(replayQueue))
 	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
 	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
 
 	// Ready signal for GoWM
 	js.Global().Set("__gowm_ready", js.ValueOf(true))
@@ -0,0 +1,3 @@
+module ics-wasm
+
+go 1.21
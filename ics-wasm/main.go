@@ -0,0 +1,3142 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+	_ "time/tzdata"
+	"unicode"
+	"unicode/utf8"
+)
+
+var silentMode = false
+
+// Expansion limits keep unbounded recurrence rules from generating unbounded output
+const (
+	defaultOccurrenceLimit = 500
+	maxOccurrenceLimit     = 10000
+	maxRecurrencePeriods   = 100000
+)
+
+// maxComponentDepth bounds BEGIN/END nesting when parsing untrusted files
+const maxComponentDepth = 16
+
+// defaultProdID identifies generated calendars unless the caller sets prodId
+const defaultProdID = "-//benoitpetit//ics-wasm//EN"
+
+// Value layouts of DATE, floating DATE-TIME and UTC DATE-TIME properties
+const (
+	dateLayout  = "20060102"
+	localLayout = "20060102T150405"
+	utcLayout   = "20060102T150405Z"
+)
+
+// CalendarInput represents the calendar passed to generateICS
+type CalendarInput struct {
+	ProdID      string       `json:"prodId"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Method      string       `json:"method"`
+	Timezone    string       `json:"timezone"`
+	Events      []EventInput `json:"events"`
+}
+
+// EventInput represents an event of generateICS and expandRecurrence. Times are dates
+// (2026-10-15, all-day), local date-times read in Timezone, or RFC 3339 instants.
+type EventInput struct {
+	UID          string            `json:"uid"`
+	Summary      string            `json:"summary"`
+	Description  string            `json:"description"`
+	Location     string            `json:"location"`
+	URL          string            `json:"url"`
+	Start        string            `json:"start"`
+	End          string            `json:"end"`
+	Duration     string            `json:"duration"`
+	AllDay       bool              `json:"allDay"`
+	Timezone     string            `json:"timezone"`
+	RRule        json.RawMessage   `json:"rrule"`
+	ExDates      []string          `json:"exdates"`
+	RDates       []string          `json:"rdates"`
+	RecurrenceID string            `json:"recurrenceId"`
+	Status       string            `json:"status"`
+	Transparency string            `json:"transparency"`
+	Class        string            `json:"class"`
+	Priority     int               `json:"priority"`
+	Sequence     int               `json:"sequence"`
+	Categories   []string          `json:"categories"`
+	Organizer    *Contact          `json:"organizer"`
+	Attendees    []Contact         `json:"attendees"`
+	Alarms       []AlarmInput      `json:"alarms"`
+	Geo          *Geo              `json:"geo"`
+	Created      string            `json:"created"`
+	LastModified string            `json:"lastModified"`
+	Extra        map[string]string `json:"extra"`
+}
+
+// Contact represents an organizer or attendee: "Name <email>", "email" or an object
+type Contact struct {
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	Status string `json:"status"`
+	Type   string `json:"type"`
+	RSVP   bool   `json:"rsvp"`
+}
+
+// AlarmInput represents a VALARM. The trigger is a duration relative to the start (or the
+// end with related: 'end'), an absolute date-time, or minutesBefore.
+type AlarmInput struct {
+	Action         string    `json:"action"`
+	Trigger        string    `json:"trigger"`
+	MinutesBefore  *float64  `json:"minutesBefore"`
+	Related        string    `json:"related"`
+	Description    string    `json:"description"`
+	Summary        string    `json:"summary"`
+	Repeat         int       `json:"repeat"`
+	RepeatInterval string    `json:"repeatInterval"`
+	Attendees      []Contact `json:"attendees"`
+}
+
+// Geo represents the GEO position of an event
+type Geo struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// expandOptions bounds an expansion: an optional [from, to) window and a maximum count
+type expandOptions struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Limit int    `json:"limit"`
+}
+
+// parseOptions represents the options of parseICS. Expand is true or expandOptions.
+type parseOptions struct {
+	Expand json.RawMessage `json:"expand"`
+}
+
+// UnmarshalJSON decodes the accepted contact forms
+func (c *Contact) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		addr, err := mail.ParseAddress(strings.TrimPrefix(text, "mailto:"))
+		if err != nil {
+			return errors.New(localize("invalid contact %q", text))
+		}
+		*c = Contact{Name: addr.Name, Email: addr.Address}
+		return nil
+	}
+
+	type plain Contact
+	var contact plain
+	if err := json.Unmarshal(data, &contact); err != nil {
+		return errors.New(localize("contacts must be a string or an {name, email} object"))
+	}
+	*c = Contact(contact)
+	c.Email = strings.TrimPrefix(c.Email, "mailto:")
+	if c.Email == "" {
+		return errors.New(localize("contact %q has no email", c.Name))
+	}
+	return nil
+}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s must be a positive integer":                        "%s doit être un entier positif",
+	"%s must be one of %s":                                 "%s doit valoir %s",
+	"%s requires at least 1 argument (%s)":                 "%s requiert au moins 1 argument (%s)",
+	"COUNT and UNTIL cannot be combined":                   "COUNT et UNTIL ne peuvent pas être combinés",
+	"EMAIL alarms need attendees":                          "les alarmes EMAIL nécessitent des participants",
+	"FREQ is required":                                     "FREQ est obligatoire",
+	"Failed to parse calendar: %v":                         "Échec de l'analyse du calendrier : %v",
+	"Invalid calendar data: %v":                            "Données de calendrier invalides : %v",
+	"Invalid calendar format: %v":                          "Format de calendrier invalide : %v",
+	"Invalid calendar: %v":                                 "Calendrier invalide : %v",
+	"Invalid event format: %v":                             "Format d'événement invalide : %v",
+	"Invalid event: %v":                                    "Événement invalide : %v",
+	"Invalid options: %v":                                  "Options invalides : %v",
+	"Unsupported locale %q (available: %s)":                "Langue %q non prise en charge (disponibles : %s)",
+	"alarm %d: %v":                                         "alarme %d : %v",
+	"an alarm needs a trigger or minutesBefore":            "une alarme nécessite trigger ou minutesBefore",
+	"components are nested more than %d levels deep":       "les composants sont imbriqués sur plus de %d niveaux",
+	"contact %q has no email":                              "le contact %q n'a pas d'adresse e-mail",
+	"contacts must be a string or an {name, email} object": "les contacts doivent être une chaîne ou un objet {name, email}",
+	"duration must not be negative":                        "la durée ne doit pas être négative",
+	"end is before start":                                  "la fin précède le début",
+	"event %d: %v":                                         "événement %d : %v",
+	"event %q ends before it starts, its end is ignored":   "l'événement %q se termine avant de commencer, sa fin est ignorée",
+	"event %q skipped: %v":                                 "événement %q ignoré : %v",
+	"event %q: %s ignored: %v":                             "événement %q : %s ignoré : %v",
+	"event %q: RRULE ignored: %v":                          "événement %q : RRULE ignorée : %v",
+	"expected a Uint8Array, ArrayBuffer or string":         "Uint8Array, ArrayBuffer ou chaîne attendu",
+	"geo must be a latitude between -90 and 90 and a longitude between -180 and 180": "geo doit être une latitude entre -90 et 90 et une longitude entre -180 et 180",
+	"invalid %s value %q":      "valeur %s invalide %q",
+	"invalid UTC offset %q":    "décalage UTC invalide %q",
+	"invalid alarm trigger %q": "déclencheur d'alarme invalide %q",
+	"invalid contact %q":       "contact invalide %q",
+	"invalid date %q (expected YYYY-MM-DD, a local date-time or RFC 3339)": "date invalide %q (attendu AAAA-MM-JJ, une date-heure locale ou RFC 3339)",
+	"invalid date %q":                                "date invalide %q",
+	"invalid duration %q":                            "durée invalide %q",
+	"invalid expand options: %v":                     "options d'expansion invalides : %v",
+	"invalid property name %q":                       "nom de propriété invalide %q",
+	"invalid rrule: %v":                              "rrule invalide : %v",
+	"limit must not exceed %d":                       "limit ne doit pas dépasser %d",
+	"line %d: %v":                                    "ligne %d : %v",
+	"line %d: property %s is outside of a component": "ligne %d : la propriété %s est hors d'un composant",
+	"line %d: unexpected END:%s":                     "ligne %d : END:%s inattendu",
+	"malformed content line %q":                      "ligne de contenu mal formée %q",
+	"malformed rule part %q":                         "partie de règle mal formée %q",
+	"missing DTSTART":                                "DTSTART manquant",
+	"missing END:%s":                                 "END:%s manquant",
+	"missing TZID":                                   "TZID manquant",
+	"no STANDARD or DAYLIGHT observance":             "aucune observance STANDARD ou DAYLIGHT",
+	"no VCALENDAR component found":                   "aucun composant VCALENDAR trouvé",
+	"priority must be between 0 and 9":               "priority doit être comprise entre 0 et 9",
+	"property %q is set by generateICS and cannot be overridden": "la propriété %q est définie par generateICS et ne peut pas être remplacée",
+	"repeat needs a repeatInterval":                              "repeat nécessite un repeatInterval",
+	"rrule must be a string or an object":                        "rrule doit être une chaîne ou un objet",
+	"setLocale requires exactly 1 argument (locale)":             "setLocale requiert exactement 1 argument (locale)",
+	"start is required":                                          "start est obligatoire",
+	"the calendar has no events":                                 "le calendrier ne contient aucun événement",
+	"time zone %q ignored: %v":                                   "fuseau horaire %q ignoré : %v",
+	"unknown frequency %q":                                       "fréquence inconnue %q",
+	"unknown rrule field %q":                                     "champ rrule inconnu %q",
+	"unknown rule part %q":                                       "partie de règle inconnue %q",
+	"unknown time zone %q":                                       "fuseau horaire inconnu %q",
+	"unknown time zone %q, its times are read as floating":       "fuseau horaire inconnu %q, ses heures sont lues comme flottantes",
+}
+
+// parseICS - Parse an .ics file into calendar properties, events, alarms and time zones
+func parseICS(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "parseICS", "ics"),
+		})
+	}
+
+	raw, err := rawFromJS(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid calendar data: %v", err),
+		})
+	}
+
+	var options parseOptions
+	if len(args) > 1 && args[1].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	result, err := parseCalendar(raw, options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to parse calendar: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Parsed calendar (%d bytes, %d events, %d time zones)\n",
+			len(raw), len(result["events"].([]interface{})), len(result["timezones"].([]interface{})))
+	}
+
+	return js.ValueOf(result)
+}
+
+// generateICS - Generate an .ics file from a calendar, an array of events or a single event
+func generateICS(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "generateICS", "calendar"),
+		})
+	}
+
+	cal, err := calendarInput(jsonArgument(args[0]))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid calendar format: %v", err),
+		})
+	}
+
+	ics, uids, err := buildCalendar(cal, time.Now().UTC())
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid calendar: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated calendar with %d events (%d bytes)\n", len(uids), len(ics))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"ics":      string(ics),
+		"size":     len(ics),
+		"events":   len(uids),
+		"uids":     uids,
+		"filename": calendarFilename(cal),
+		"format":   "text/calendar",
+	})
+}
+
+// expandRecurrence - List the occurrences of an event's RRULE, RDATE and EXDATE within a window
+func expandRecurrence(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "expandRecurrence", "event"),
+		})
+	}
+
+	var in EventInput
+	if err := json.Unmarshal([]byte(jsonArgument(args[0])), &in); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid event format: %v", err),
+		})
+	}
+
+	var options expandOptions
+	if len(args) > 1 && args[1].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	ev, err := in.resolve("")
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid event: %v", err),
+		})
+	}
+
+	from, to, limit, err := options.window(ev.Start.Zone)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	times, truncated := ev.occurrences(from, to, limit)
+	length := ev.End.instant().Sub(ev.Start.instant())
+	occurrences := make([]interface{}, len(times))
+	for i, t := range times {
+		occurrences[i] = occurrenceJSON(t, length)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Expanded %d occurrences\n", len(occurrences))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"occurrences": occurrences,
+		"count":       len(occurrences),
+		"truncated":   truncated,
+		"allDay":      ev.Start.DateOnly,
+		"timezone":    ev.Start.zoneID(),
+	})
+}
+
+// icsProperty is a content line: NAME;PARAM=value:VALUE
+type icsProperty struct {
+	Name   string
+	Params map[string][]string
+	Value  string
+}
+
+// icsComponent is a BEGIN/END block with its properties and nested components
+type icsComponent struct {
+	Name       string
+	Properties []icsProperty
+	Components []*icsComponent
+}
+
+// property returns the first property with the given name
+func (c *icsComponent) property(name string) (icsProperty, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return icsProperty{}, false
+}
+
+// properties returns every property with the given name
+func (c *icsComponent) properties(name string) []icsProperty {
+	var list []icsProperty
+	for _, p := range c.Properties {
+		if p.Name == name {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// text returns the unescaped value of the first property with the given name
+func (c *icsComponent) text(name string) string {
+	p, _ := c.property(name)
+	return unescapeText(p.Value)
+}
+
+// components returns the nested components with the given name
+func (c *icsComponent) components(name string) []*icsComponent {
+	var list []*icsComponent
+	for _, child := range c.Components {
+		if child.Name == name {
+			list = append(list, child)
+		}
+	}
+	return list
+}
+
+// param returns the first value of a parameter
+func (p icsProperty) param(name string) string {
+	if values := p.Params[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// parseComponents unfolds the content lines of a file and nests them into components
+func parseComponents(data []byte) ([]*icsComponent, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+
+	var lines []string
+	var numbers []int
+	for i, line := range strings.Split(text, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+			numbers = append(numbers, i+1)
+		}
+	}
+
+	var roots, stack []*icsComponent
+	for i, line := range lines {
+		prop, err := parseContentLine(line)
+		if err != nil {
+			return nil, errors.New(localize("line %d: %v", numbers[i], err))
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			if len(stack) >= maxComponentDepth {
+				return nil, errors.New(localize("components are nested more than %d levels deep", maxComponentDepth))
+			}
+			component := &icsComponent{Name: strings.ToUpper(strings.TrimSpace(prop.Value))}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, component)
+			} else {
+				roots = append(roots, component)
+			}
+			stack = append(stack, component)
+		case "END":
+			name := strings.ToUpper(strings.TrimSpace(prop.Value))
+			if len(stack) == 0 || stack[len(stack)-1].Name != name {
+				return nil, errors.New(localize("line %d: unexpected END:%s", numbers[i], name))
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, errors.New(localize("line %d: property %s is outside of a component", numbers[i], prop.Name))
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, errors.New(localize("missing END:%s", stack[len(stack)-1].Name))
+	}
+	return roots, nil
+}
+
+// parseContentLine splits an unfolded line into its name, parameters and value
+func parseContentLine(line string) (icsProperty, error) {
+	prop := icsProperty{Params: map[string][]string{}}
+	malformed := errors.New(localize("malformed content line %q", truncateText(line, 40)))
+
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, malformed
+	}
+	prop.Name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		eq := strings.IndexByte(line[i+1:], '=')
+		if eq < 0 {
+			return prop, malformed
+		}
+		name := strings.ToUpper(line[i+1 : i+1+eq])
+		i += eq + 2
+
+		for {
+			var value string
+			if i < len(line) && line[i] == '"' {
+				end := strings.IndexByte(line[i+1:], '"')
+				if end < 0 {
+					return prop, malformed
+				}
+				value = line[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				end := strings.IndexAny(line[i:], ",;:")
+				if end < 0 {
+					return prop, malformed
+				}
+				value = line[i : i+end]
+				i += end
+			}
+			if i >= len(line) || !strings.ContainsRune(",;:", rune(line[i])) {
+				return prop, malformed
+			}
+			prop.Params[name] = append(prop.Params[name], unescapeParam(value))
+			if line[i] != ',' {
+				break
+			}
+			i++
+		}
+	}
+
+	prop.Value = line[i+1:]
+	return prop, nil
+}
+
+// unescapeParam decodes the ^n, ^' and ^^ escapes of RFC 6868
+func unescapeParam(value string) string {
+	if !strings.Contains(value, "^") {
+		return value
+	}
+	return strings.NewReplacer("^n", "\n", "^N", "\n", "^'", `"`, "^^", "^").Replace(value)
+}
+
+// unescapeText decodes a TEXT value: \n, \N, \\, \; and \,
+func unescapeText(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(value[i])
+			}
+			continue
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// escapeText encodes a TEXT value
+func escapeText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(value)
+}
+
+// splitText splits a TEXT list such as CATEGORIES on its unescaped commas
+func splitText(value string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, unescapeText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, unescapeText(value[start:]))
+}
+
+func truncateText(text string, length int) string {
+	if len(text) <= length {
+		return text
+	}
+	for length > 0 && !utf8.RuneStart(text[length]) {
+		length--
+	}
+	return text[:length] + "…"
+}
+
+// zone places wall-clock times of a TZID on the timeline, from the IANA database or a VTIMEZONE
+type zone struct {
+	ID       string
+	location *time.Location
+	vtz      *vtimezone
+}
+
+// utcZone stands for the TZIDs that name UTC; times in it are written with a Z
+var utcZone = &zone{ID: "UTC", location: time.UTC}
+
+// windowsZones maps the Windows zone names Outlook and Exchange write as TZID to IANA zones
+var windowsZones = map[string]string{
+	"AUS Eastern Standard Time":    "Australia/Sydney",
+	"Central Europe Standard Time": "Europe/Budapest",
+	"Central Standard Time":        "America/Chicago",
+	"China Standard Time":          "Asia/Shanghai",
+	"E. Europe Standard Time":      "Europe/Chisinau",
+	"Eastern Standard Time":        "America/New_York",
+	"FLE Standard Time":            "Europe/Kiev",
+	"GMT Standard Time":            "Europe/London",
+	"GTB Standard Time":            "Europe/Bucharest",
+	"India Standard Time":          "Asia/Kolkata",
+	"Mountain Standard Time":       "America/Denver",
+	"Pacific Standard Time":        "America/Los_Angeles",
+	"Romance Standard Time":        "Europe/Paris",
+	"Russian Standard Time":        "Europe/Moscow",
+	"Singapore Standard Time":      "Asia/Singapore",
+	"Tokyo Standard Time":          "Asia/Tokyo",
+	"W. Europe Standard Time":      "Europe/Berlin",
+}
+
+// lookupZone resolves a TZID from the IANA database, the file's VTIMEZONEs or a Windows zone name
+func lookupZone(tzid string, vtimezones map[string]*vtimezone) *zone {
+	id := strings.Trim(strings.TrimSpace(tzid), `"`)
+	switch strings.ToUpper(id) {
+	case "UTC", "Z", "GMT", "ETC/UTC", "ETC/GMT":
+		return utcZone
+	}
+
+	// Some producers prefix the IANA name, e.g. /mozilla.org/20050126_1/Europe/Paris
+	for name := id; name != ""; {
+		if name != "Local" {
+			if location, err := time.LoadLocation(name); err == nil {
+				return &zone{ID: name, location: location}
+			}
+		}
+		i := strings.IndexByte(name, '/')
+		if i < 0 {
+			break
+		}
+		name = name[i+1:]
+	}
+
+	if vtz, ok := vtimezones[id]; ok {
+		return &zone{ID: id, vtz: vtz}
+	}
+	if name, ok := windowsZones[id]; ok {
+		location, err := time.LoadLocation(name)
+		if err == nil {
+			return &zone{ID: name, location: location}
+		}
+	}
+	return nil
+}
+
+// instant returns the moment a wall-clock time of the zone designates
+func (z *zone) instant(wall time.Time) time.Time {
+	location := z.location
+	if location == nil {
+		location = time.FixedZone(z.ID, z.vtz.offsetAt(wall))
+	}
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, location)
+}
+
+// wallOf returns the wall-clock time of the zone at a moment
+func (z *zone) wallOf(instant time.Time) time.Time {
+	if z.location != nil {
+		return naive(instant.In(z.location))
+	}
+	utc := instant.UTC()
+	guess := utc.Add(time.Duration(z.vtz.offsetAt(utc)) * time.Second)
+	return utc.Add(time.Duration(z.vtz.offsetAt(guess)) * time.Second)
+}
+
+// naive drops the location of a time, keeping its wall-clock fields
+func naive(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// icsTime is a DATE or DATE-TIME value. Wall holds the date and time as written (in a UTC
+// time.Time); Zone places it on the timeline and is nil for dates, UTC and floating times.
+type icsTime struct {
+	Wall     time.Time
+	DateOnly bool
+	UTC      bool
+	Zone     *zone
+}
+
+// instant returns the moment of the time; dates and floating times are read as UTC
+func (t icsTime) instant() time.Time {
+	if t.Zone != nil && !t.DateOnly {
+		return t.Zone.instant(t.Wall)
+	}
+	return t.Wall
+}
+
+// withWall returns a time of the same form at another wall-clock time
+func (t icsTime) withWall(wall time.Time) icsTime {
+	t.Wall = wall
+	return t
+}
+
+// after returns the time length later, in the same form as t
+func (t icsTime) after(length time.Duration) icsTime {
+	if t.Zone != nil && !t.DateOnly {
+		t.Wall = t.Zone.wallOf(t.instant().Add(length))
+		return t
+	}
+	t.Wall = t.Wall.Add(length)
+	return t
+}
+
+// wallAt returns the wall-clock time, in the form of t, of a moment
+func (t icsTime) wallAt(instant time.Time) time.Time {
+	if t.Zone != nil && !t.DateOnly {
+		return t.Zone.wallOf(instant)
+	}
+	return instant.UTC()
+}
+
+// zoneID returns the TZID of the time, UTC, or "" for dates and floating times
+func (t icsTime) zoneID() string {
+	switch {
+	case t.DateOnly:
+		return ""
+	case t.UTC:
+		return "UTC"
+	case t.Zone != nil:
+		return t.Zone.ID
+	}
+	return ""
+}
+
+// String formats the time for JSON: a date, a floating time, UTC with Z, or a zoned time with its offset
+func (t icsTime) String() string {
+	switch {
+	case t.DateOnly:
+		return t.Wall.Format("2006-01-02")
+	case t.UTC:
+		return t.Wall.Format("2006-01-02T15:04:05Z")
+	case t.Zone != nil:
+		return t.instant().Format(time.RFC3339)
+	}
+	return t.Wall.Format("2006-01-02T15:04:05")
+}
+
+// value formats the time for an iCalendar property
+func (t icsTime) value() string {
+	switch {
+	case t.DateOnly:
+		return t.Wall.Format(dateLayout)
+	case t.UTC:
+		return t.Wall.Format(utcLayout)
+	}
+	return t.Wall.Format(localLayout)
+}
+
+// params returns the VALUE or TZID parameter the time is written with
+func (t icsTime) params() []string {
+	switch {
+	case t.DateOnly:
+		return []string{"VALUE", "DATE"}
+	case t.Zone != nil:
+		return []string{"TZID", t.Zone.ID}
+	}
+	return nil
+}
+
+// parseTimeValue reads an iCalendar DATE (20261015) or DATE-TIME (20261015T090000, or with a
+// Z for UTC) and places it in z
+func parseTimeValue(value, kind string, z *zone) (icsTime, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(kind, "DATE") || len(value) == len(dateLayout) {
+		day, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return icsTime{}, errors.New(localize("invalid date %q", value))
+		}
+		return icsTime{Wall: day, DateOnly: true}, nil
+	}
+
+	wall, err := time.Parse(localLayout, strings.TrimSuffix(value, "Z"))
+	if err != nil {
+		return icsTime{}, errors.New(localize("invalid date %q", value))
+	}
+	if strings.HasSuffix(value, "Z") || z == utcZone {
+		return icsTime{Wall: wall, UTC: true}, nil
+	}
+	return icsTime{Wall: wall, Zone: z}, nil
+}
+
+// parseInputTime reads a time given to generateICS or expandRecurrence: a date, a local
+// date-time read in z (floating without one), an RFC 3339 instant or an iCalendar value.
+// dateOnly reduces date-times to their date.
+func parseInputTime(value string, z *zone, dateOnly bool) (icsTime, error) {
+	value = strings.TrimSpace(value)
+	var t icsTime
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		return icsTime{Wall: day, DateOnly: true}, nil
+	}
+	if parsed, err := parseTimeValue(value, "", nil); err == nil {
+		t = parsed
+	} else if instant, err := time.Parse(time.RFC3339, value); err == nil {
+		t = icsTime{Wall: naive(instant.UTC()), UTC: true}
+	} else {
+		var wall time.Time
+		for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+			if wall, err = time.Parse(layout, value); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return t, errors.New(localize("invalid date %q (expected YYYY-MM-DD, a local date-time or RFC 3339)", value))
+		}
+		t = icsTime{Wall: naive(wall)}
+	}
+
+	switch {
+	case t.DateOnly:
+	case dateOnly:
+		return icsTime{Wall: time.Date(t.Wall.Year(), t.Wall.Month(), t.Wall.Day(), 0, 0, 0, 0, time.UTC), DateOnly: true}, nil
+	case z == utcZone && !t.UTC:
+		t.UTC = true
+	case z == nil || z == utcZone:
+	case t.UTC:
+		t = icsTime{Wall: z.wallOf(t.Wall), Zone: z}
+	default:
+		t.Zone = z
+	}
+	return t, nil
+}
+
+// icsDuration is an RFC 5545 duration: nominal days (a week counts 7) and an exact time part
+type icsDuration struct {
+	Days     int
+	Time     time.Duration
+	Negative bool
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration reads a duration such as PT15M, -P1D or P1W
+func parseDuration(value string) (icsDuration, error) {
+	text := strings.ToUpper(strings.TrimSpace(value))
+	m := durationPattern.FindStringSubmatch(text)
+	if m == nil || strings.HasSuffix(text, "T") || m[2]+m[3]+m[4]+m[5]+m[6] == "" {
+		return icsDuration{}, errors.New(localize("invalid duration %q", value))
+	}
+	n := func(digits string) int {
+		v, _ := strconv.Atoi(digits)
+		return v
+	}
+	return icsDuration{
+		Days:     n(m[2])*7 + n(m[3]),
+		Time:     time.Duration(n(m[4]))*time.Hour + time.Duration(n(m[5]))*time.Minute + time.Duration(n(m[6]))*time.Second,
+		Negative: m[1] == "-",
+	}, nil
+}
+
+// durationOf converts a length to a duration, counting whole days in D
+func durationOf(length time.Duration) icsDuration {
+	length = length.Round(time.Second)
+	d := icsDuration{Negative: length < 0}
+	if d.Negative {
+		length = -length
+	}
+	d.Days = int(length / (24 * time.Hour))
+	d.Time = length % (24 * time.Hour)
+	return d
+}
+
+// add applies the duration to a wall-clock time
+func (d icsDuration) add(t time.Time) time.Time {
+	days, exact := d.Days, d.Time
+	if d.Negative {
+		days, exact = -days, -exact
+	}
+	return t.AddDate(0, 0, days).Add(exact)
+}
+
+// length returns the signed length of the duration, counting days as 24 hours
+func (d icsDuration) length() time.Duration {
+	length := time.Duration(d.Days)*24*time.Hour + d.Time
+	if d.Negative {
+		return -length
+	}
+	return length
+}
+
+// String formats the duration, e.g. -PT15M, P1DT2H or P2W
+func (d icsDuration) String() string {
+	var b strings.Builder
+	if d.Negative {
+		b.WriteByte('-')
+	}
+	b.WriteByte('P')
+	if d.Days > 0 && d.Days%7 == 0 && d.Time == 0 {
+		fmt.Fprintf(&b, "%dW", d.Days/7)
+		return b.String()
+	}
+	if d.Days > 0 {
+		fmt.Fprintf(&b, "%dD", d.Days)
+	}
+	if d.Time > 0 || d.Days == 0 {
+		hours, minutes, seconds := d.Time/time.Hour, d.Time%time.Hour/time.Minute, d.Time%time.Minute/time.Second
+		b.WriteByte('T')
+		if hours > 0 {
+			fmt.Fprintf(&b, "%dH", hours)
+		}
+		if minutes > 0 {
+			fmt.Fprintf(&b, "%dM", minutes)
+		}
+		if seconds > 0 || hours == 0 && minutes == 0 {
+			fmt.Fprintf(&b, "%dS", seconds)
+		}
+	}
+	return b.String()
+}
+
+// parseOffset reads a UTC offset such as +0200 or -053000, in seconds
+func parseOffset(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	invalid := errors.New(localize("invalid UTC offset %q", value))
+	if len(value) != 5 && len(value) != 7 || value[0] != '+' && value[0] != '-' {
+		return 0, invalid
+	}
+	total := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if 1+2*i >= len(value) {
+			break
+		}
+		n, err := strconv.Atoi(value[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, invalid
+		}
+		total += n * unit
+	}
+	if value[0] == '-' {
+		total = -total
+	}
+	return total, nil
+}
+
+// formatOffset writes a UTC offset in seconds as +HHMM (or +HHMMSS)
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	text := fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		text += fmt.Sprintf("%02d", seconds%60)
+	}
+	return text
+}
+
+// vtimezone is a VTIMEZONE definition: observances whose onsets switch the UTC offset
+type vtimezone struct {
+	ID          string
+	observances []observance
+	cache       map[int][]transition
+}
+
+// observance is a STANDARD or DAYLIGHT block. Its onsets are wall-clock times in OffsetFrom.
+type observance struct {
+	Kind       string
+	Start      time.Time
+	OffsetFrom int
+	OffsetTo   int
+	Name       string
+	Rule       *recurrence
+	Until      time.Time
+	RDates     []time.Time
+}
+
+// transition is an onset of an observance within a year
+type transition struct {
+	at     time.Time
+	offset int
+}
+
+// parseVTimezone reads the observances of a VTIMEZONE and describes them for JSON
+func parseVTimezone(c *icsComponent) (*vtimezone, map[string]interface{}, error) {
+	id := c.text("TZID")
+	if id == "" {
+		return nil, nil, errors.New(localize("missing TZID"))
+	}
+
+	v := &vtimezone{ID: id, cache: map[int][]transition{}}
+	list := []interface{}{}
+	for _, child := range c.Components {
+		if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+			continue
+		}
+		start, err := time.Parse(localLayout, strings.TrimSpace(child.text("DTSTART")))
+		if err != nil {
+			return nil, nil, errors.New(localize("invalid date %q", child.text("DTSTART")))
+		}
+		from, err := parseOffset(child.text("TZOFFSETFROM"))
+		if err != nil {
+			return nil, nil, err
+		}
+		to, err := parseOffset(child.text("TZOFFSETTO"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		o := observance{Kind: child.Name, Start: start, OffsetFrom: from, OffsetTo: to, Name: child.text("TZNAME")}
+		entry := map[string]interface{}{
+			"type":       strings.ToLower(child.Name),
+			"start":      start.Format("2006-01-02T15:04:05"),
+			"offsetFrom": formatOffset(from),
+			"offsetTo":   formatOffset(to),
+			"name":       o.Name,
+		}
+		if prop, ok := child.property("RRULE"); ok {
+			rule, err := parseRecurrence(prop.Value, func(value string) (icsTime, error) {
+				return parseTimeValue(value, "", nil)
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			if rule.Until != nil {
+				o.Until = rule.Until.Wall
+				if rule.Until.UTC {
+					o.Until = o.Until.Add(time.Duration(from) * time.Second)
+				}
+			}
+			o.Rule = rule
+			entry["rrule"] = rule.String()
+		}
+		for _, prop := range child.properties("RDATE") {
+			for _, value := range strings.Split(prop.Value, ",") {
+				onset, err := time.Parse(localLayout, strings.TrimSpace(value))
+				if err != nil {
+					return nil, nil, errors.New(localize("invalid date %q", value))
+				}
+				o.RDates = append(o.RDates, onset)
+			}
+		}
+		v.observances = append(v.observances, o)
+		list = append(list, entry)
+	}
+	if len(v.observances) == 0 {
+		return nil, nil, errors.New(localize("no STANDARD or DAYLIGHT observance"))
+	}
+
+	return v, map[string]interface{}{"tzid": id, "observances": list}, nil
+}
+
+// transitions returns the onsets of a year, in order
+func (v *vtimezone) transitions(year int) []transition {
+	if list, ok := v.cache[year]; ok {
+		return list
+	}
+
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+	var list []transition
+	for _, o := range v.observances {
+		add := func(at time.Time) {
+			if !at.Before(from) && at.Before(to) {
+				list = append(list, transition{at: at, offset: o.OffsetTo})
+			}
+		}
+		add(o.Start)
+		for _, at := range o.RDates {
+			add(at)
+		}
+		if o.Rule != nil {
+			o.Rule.each(o.Start, from, o.Until, func(at time.Time) bool {
+				if !at.Before(to) {
+					return false
+				}
+				add(at)
+				return true
+			})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].at.Before(list[j].at) })
+	v.cache[year] = list
+	return list
+}
+
+// offsetAt returns the UTC offset in seconds in effect at a wall-clock time
+func (v *vtimezone) offsetAt(wall time.Time) int {
+	first := v.observances[0]
+	for _, o := range v.observances {
+		if o.Start.Before(first.Start) {
+			first = o
+		}
+	}
+	for year := wall.Year(); year >= first.Start.Year(); year-- {
+		list := v.transitions(year)
+		for i := len(list) - 1; i >= 0; i-- {
+			if !list[i].at.After(wall) {
+				return list[i].offset
+			}
+		}
+	}
+	return first.OffsetFrom
+}
+
+// recurrence is an RRULE (RFC 5545 section 3.3.10)
+type recurrence struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *icsTime
+	BySecond   []int
+	ByMinute   []int
+	ByHour     []int
+	ByDay      []weekdayNum
+	ByMonthDay []int
+	ByYearDay  []int
+	ByWeekNo   []int
+	ByMonth    []int
+	BySetPos   []int
+	Wkst       time.Weekday
+}
+
+// weekdayNum is a BYDAY entry: a weekday, the nth of the month or year when N is not 0
+type weekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// frequencies ranks the FREQ values from the finest to the coarsest
+var frequencies = map[string]int{"SECONDLY": 0, "MINUTELY": 1, "HOURLY": 2, "DAILY": 3, "WEEKLY": 4, "MONTHLY": 5, "YEARLY": 6}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func weekdayCode(day time.Weekday) string {
+	return [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}[day]
+}
+
+// String formats the entry, e.g. MO or -1FR
+func (d weekdayNum) String() string {
+	if d.N == 0 {
+		return weekdayCode(d.Day)
+	}
+	return strconv.Itoa(d.N) + weekdayCode(d.Day)
+}
+
+// parseRecurrence reads an RRULE value. UNTIL is read with parseUntil, which knows the form of DTSTART.
+func parseRecurrence(text string, parseUntil func(string) (icsTime, error)) (*recurrence, error) {
+	r := &recurrence{Interval: 1, Wkst: time.Monday}
+	text = strings.TrimSpace(text)
+	if len(text) > 6 && strings.EqualFold(text[:6], "RRULE:") {
+		text = text[6:]
+	}
+
+	for _, part := range strings.Split(text, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, errors.New(localize("malformed rule part %q", part))
+		}
+		key, value = strings.ToUpper(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if _, known := frequencies[r.Freq]; !known {
+				return nil, errors.New(localize("unknown frequency %q", value))
+			}
+		case "INTERVAL":
+			r.Interval, err = positiveInt(key, value)
+		case "COUNT":
+			r.Count, err = positiveInt(key, value)
+		case "UNTIL":
+			var until icsTime
+			if until, err = parseUntil(value); err == nil {
+				r.Until = &until
+			}
+		case "WKST":
+			day, known := weekdayCodes[strings.ToUpper(value)]
+			if !known {
+				return nil, errors.New(localize("invalid %s value %q", key, value))
+			}
+			r.Wkst = day
+		case "BYDAY":
+			r.ByDay, err = parseWeekdays(value)
+		case "BYSECOND":
+			r.BySecond, err = intList(key, value, 0, 60, false)
+		case "BYMINUTE":
+			r.ByMinute, err = intList(key, value, 0, 59, false)
+		case "BYHOUR":
+			r.ByHour, err = intList(key, value, 0, 23, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = intList(key, value, 1, 31, true)
+		case "BYYEARDAY":
+			r.ByYearDay, err = intList(key, value, 1, 366, true)
+		case "BYWEEKNO":
+			r.ByWeekNo, err = intList(key, value, 1, 53, true)
+		case "BYMONTH":
+			r.ByMonth, err = intList(key, value, 1, 12, false)
+		case "BYSETPOS":
+			r.BySetPos, err = intList(key, value, 1, 366, true)
+		default:
+			if !strings.HasPrefix(key, "X-") {
+				return nil, errors.New(localize("unknown rule part %q", key))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New(localize("FREQ is required"))
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New(localize("COUNT and UNTIL cannot be combined"))
+	}
+	return r, nil
+}
+
+func positiveInt(key, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, errors.New(localize("%s must be a positive integer", key))
+	}
+	return n, nil
+}
+
+// intList reads a BY* list whose values lie between min and max, or -max and -min when negative is allowed
+func intList(key, value string, min, max int, negative bool) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(item), "+"))
+		abs := n
+		if negative && n < 0 {
+			abs = -n
+		}
+		if err != nil || abs < min || abs > max {
+			return nil, errors.New(localize("invalid %s value %q", key, item))
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// parseWeekdays reads a BYDAY list such as MO,WE or 1MO,-1FR
+func parseWeekdays(value string) ([]weekdayNum, error) {
+	var list []weekdayNum
+	for _, item := range strings.Split(value, ",") {
+		code := strings.ToUpper(strings.TrimSpace(item))
+		invalid := errors.New(localize("invalid %s value %q", "BYDAY", item))
+		if len(code) < 2 {
+			return nil, invalid
+		}
+		day, ok := weekdayCodes[code[len(code)-2:]]
+		if !ok {
+			return nil, invalid
+		}
+		entry := weekdayNum{Day: day}
+		if prefix := code[:len(code)-2]; prefix != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, invalid
+			}
+			entry.N = n
+		}
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+// String formats the rule as an RRULE value
+func (r *recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.value())
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	ints := func(key string, list []int) {
+		if len(list) > 0 {
+			texts := make([]string, len(list))
+			for i, n := range list {
+				texts[i] = strconv.Itoa(n)
+			}
+			parts = append(parts, key+"="+strings.Join(texts, ","))
+		}
+	}
+	ints("BYSECOND", r.BySecond)
+	ints("BYMINUTE", r.ByMinute)
+	ints("BYHOUR", r.ByHour)
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	ints("BYMONTHDAY", r.ByMonthDay)
+	ints("BYYEARDAY", r.ByYearDay)
+	ints("BYWEEKNO", r.ByWeekNo)
+	ints("BYMONTH", r.ByMonth)
+	ints("BYSETPOS", r.BySetPos)
+	if r.Wkst != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.Wkst))
+	}
+	return strings.Join(parts, ";")
+}
+
+// toJSON describes the rule with the keys generateICS accepts for an rrule object
+func (r *recurrence) toJSON() map[string]interface{} {
+	rule := map[string]interface{}{
+		"freq":     r.Freq,
+		"interval": r.Interval,
+		"wkst":     weekdayCode(r.Wkst),
+	}
+	if r.Count > 0 {
+		rule["count"] = r.Count
+	}
+	if r.Until != nil {
+		rule["until"] = r.Until.String()
+	}
+	for key, list := range map[string][]int{
+		"bySecond": r.BySecond, "byMinute": r.ByMinute, "byHour": r.ByHour, "byMonthDay": r.ByMonthDay,
+		"byYearDay": r.ByYearDay, "byWeekNo": r.ByWeekNo, "byMonth": r.ByMonth, "bySetPos": r.BySetPos,
+	} {
+		if len(list) > 0 {
+			values := make([]interface{}, len(list))
+			for i, n := range list {
+				values[i] = n
+			}
+			rule[key] = values
+		}
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]interface{}, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		rule["byDay"] = days
+	}
+	return rule
+}
+
+// ruleFields maps the keys of an rrule object to RRULE parts, in output order
+var ruleFields = [][2]string{
+	{"freq", "FREQ"}, {"until", "UNTIL"}, {"count", "COUNT"}, {"interval", "INTERVAL"},
+	{"bySecond", "BYSECOND"}, {"byMinute", "BYMINUTE"}, {"byHour", "BYHOUR"}, {"byDay", "BYDAY"},
+	{"byMonthDay", "BYMONTHDAY"}, {"byYearDay", "BYYEARDAY"}, {"byWeekNo", "BYWEEKNO"},
+	{"byMonth", "BYMONTH"}, {"bySetPos", "BYSETPOS"}, {"wkst", "WKST"},
+}
+
+// ruleText returns an rrule given as an RRULE string or as an object such as
+// {freq: 'weekly', byDay: ['MO', 'WE'], count: 10}
+func ruleText(raw json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", errors.New(localize("rrule must be a string or an object"))
+	}
+
+	known := map[string]bool{}
+	var parts []string
+	for _, field := range ruleFields {
+		known[field[0]] = true
+		value, ok := fields[field[0]]
+		if !ok || value == nil {
+			continue
+		}
+		items, isList := value.([]interface{})
+		if !isList {
+			items = []interface{}{value}
+		}
+		texts := make([]string, len(items))
+		for i, item := range items {
+			texts[i] = fmt.Sprint(item)
+		}
+		parts = append(parts, field[1]+"="+strings.Join(texts, ","))
+	}
+	for key := range fields {
+		if !known[key] {
+			return "", errors.New(localize("unknown rrule field %q", key))
+		}
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// each calls fn with the wall-clock times of the rule's occurrences from start, in order,
+// until fn returns false, COUNT is reached or an occurrence passes until (zero for none).
+// Without COUNT, the periods before from are skipped.
+func (r *recurrence) each(start, from, until time.Time, fn func(time.Time) bool) {
+	rule := r.withDefaults(start)
+	first := 0
+	if r.Count == 0 && from.After(start) {
+		if first = rule.periodIndex(start, from) - 1; first < 0 {
+			first = 0
+		}
+	}
+
+	count := 0
+	for index := first; index < first+maxRecurrencePeriods; index++ {
+		candidates, ok := rule.period(start, index)
+		if !ok {
+			return
+		}
+		for _, at := range candidates {
+			if at.Before(start) {
+				continue
+			}
+			if !until.IsZero() && at.After(until) {
+				return
+			}
+			if !fn(at) {
+				return
+			}
+			if count++; r.Count > 0 && count >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// withDefaults completes the rule with the parts DTSTART implies: its day for yearly, monthly
+// and weekly rules without BY-day parts, and its time for the units coarser than FREQ
+func (r *recurrence) withDefaults(start time.Time) recurrence {
+	rule := *r
+	if len(rule.ByWeekNo) == 0 && len(rule.ByYearDay) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 {
+		switch rule.Freq {
+		case "YEARLY":
+			if len(rule.ByMonth) == 0 {
+				rule.ByMonth = []int{int(start.Month())}
+			}
+			rule.ByMonthDay = []int{start.Day()}
+		case "MONTHLY":
+			rule.ByMonthDay = []int{start.Day()}
+		case "WEEKLY":
+			rule.ByDay = []weekdayNum{{Day: start.Weekday()}}
+		}
+	}
+
+	rank := frequencies[rule.Freq]
+	sorted := func(list []int, fallback int, coarser bool) []int {
+		if len(list) == 0 {
+			if !coarser {
+				return nil
+			}
+			return []int{fallback}
+		}
+		list = append([]int(nil), list...)
+		sort.Ints(list)
+		return list
+	}
+	rule.ByHour = sorted(rule.ByHour, start.Hour(), rank > frequencies["HOURLY"])
+	rule.ByMinute = sorted(rule.ByMinute, start.Minute(), rank > frequencies["MINUTELY"])
+	rule.BySecond = sorted(rule.BySecond, start.Second(), rank > frequencies["SECONDLY"])
+	return rule
+}
+
+// periodIndex returns the number of periods between start and at
+func (r *recurrence) periodIndex(start, at time.Time) int {
+	var periods int64
+	switch r.Freq {
+	case "YEARLY":
+		periods = int64(at.Year() - start.Year())
+	case "MONTHLY":
+		periods = int64((at.Year()-start.Year())*12 + int(at.Month()) - int(start.Month()))
+	case "WEEKLY":
+		periods = (at.Unix() - start.Unix()) / (7 * 86400)
+	case "DAILY":
+		periods = (at.Unix() - start.Unix()) / 86400
+	default:
+		periods = (at.Unix() - start.Unix()) / int64(frequencyUnit(r.Freq)/time.Second)
+	}
+	return int(periods / int64(r.Interval))
+}
+
+func frequencyUnit(freq string) time.Duration {
+	switch freq {
+	case "HOURLY":
+		return time.Hour
+	case "MINUTELY":
+		return time.Minute
+	}
+	return time.Second
+}
+
+// period returns the occurrences of the index-th period after start, in order, with BYSETPOS
+// applied. It reports false once the period is past year 9999.
+func (r *recurrence) period(start time.Time, index int) ([]time.Time, bool) {
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	step := index * r.Interval
+	hours, minutes, seconds := r.ByHour, r.ByMinute, r.BySecond
+
+	var days []time.Time
+	switch r.Freq {
+	case "YEARLY":
+		first := time.Date(start.Year()+step, 1, 1, 0, 0, 0, 0, time.UTC)
+		days = daysBetween(first, first.AddDate(1, 0, 0))
+	case "MONTHLY":
+		first := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		days = daysBetween(first, first.AddDate(0, 1, 0))
+	case "WEEKLY":
+		first := day.AddDate(0, 0, 7*step-(int(day.Weekday())-int(r.Wkst)+7)%7)
+		days = daysBetween(first, first.AddDate(0, 0, 7))
+	case "DAILY":
+		days = []time.Time{day.AddDate(0, 0, step)}
+	default:
+		// HOURLY, MINUTELY and SECONDLY periods fix the units down to FREQ
+		at := start.Add(time.Duration(step) * frequencyUnit(r.Freq))
+		days = []time.Time{time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)}
+		only := func(value int, list []int) []int {
+			if len(list) > 0 && !containsInt(list, value) {
+				return nil
+			}
+			return []int{value}
+		}
+		hours = only(at.Hour(), r.ByHour)
+		if r.Freq != "HOURLY" {
+			minutes = only(at.Minute(), r.ByMinute)
+		}
+		if r.Freq == "SECONDLY" {
+			seconds = only(at.Second(), r.BySecond)
+		}
+	}
+	if days[0].Year() > 9999 {
+		return nil, false
+	}
+
+	var times []time.Time
+	for _, d := range days {
+		if !r.matchDay(d) {
+			continue
+		}
+		for _, h := range hours {
+			for _, m := range minutes {
+				for _, s := range seconds {
+					times = append(times, time.Date(d.Year(), d.Month(), d.Day(), h, m, s, 0, time.UTC))
+				}
+			}
+		}
+	}
+
+	if len(r.BySetPos) > 0 && len(times) > 0 {
+		var selected []time.Time
+		seen := map[int]bool{}
+		for _, pos := range r.BySetPos {
+			i := pos - 1
+			if pos < 0 {
+				i = len(times) + pos
+			}
+			if i >= 0 && i < len(times) && !seen[i] {
+				seen[i] = true
+				selected = append(selected, times[i])
+			}
+		}
+		sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+		times = selected
+	}
+	return times, true
+}
+
+// matchDay applies the BY-day parts to a day
+func (r *recurrence) matchDay(d time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(d.Month())) {
+		return false
+	}
+	if len(r.ByWeekNo) > 0 {
+		week, weeks := weekNumber(d, r.Wkst)
+		if !matchOrdinal(r.ByWeekNo, week, weeks) {
+			return false
+		}
+	}
+	if len(r.ByYearDay) > 0 && !matchOrdinal(r.ByYearDay, d.YearDay(), daysInYear(d.Year())) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !matchOrdinal(r.ByMonthDay, d.Day(), daysInMonth(d.Year(), d.Month())) {
+		return false
+	}
+	if len(r.ByDay) == 0 {
+		return true
+	}
+
+	// Ordinals count within the month for monthly rules and yearly rules with BYMONTH,
+	// within the year for other yearly rules, and are ignored otherwise
+	position, total := 0, 0
+	switch {
+	case r.Freq == "MONTHLY" || r.Freq == "YEARLY" && len(r.ByMonth) > 0:
+		position, total = d.Day(), daysInMonth(d.Year(), d.Month())
+	case r.Freq == "YEARLY" && len(r.ByWeekNo) == 0:
+		position, total = d.YearDay(), daysInYear(d.Year())
+	}
+	for _, day := range r.ByDay {
+		if day.Day != d.Weekday() {
+			continue
+		}
+		if day.N == 0 || total == 0 || day.N == (position-1)/7+1 || day.N == -((total-position)/7+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// weekNumber returns the week of a day (week 1 holds at least 4 days of the year) and the
+// number of weeks of its week-numbering year
+func weekNumber(d time.Time, wkst time.Weekday) (int, int) {
+	year := d.Year()
+	start := weekOneStart(year, wkst)
+	if d.Before(start) {
+		year--
+		start = weekOneStart(year, wkst)
+	} else if next := weekOneStart(year+1, wkst); !d.Before(next) {
+		year++
+		start = next
+	}
+	week := int(d.Sub(start).Hours()/24)/7 + 1
+	weeks := int(weekOneStart(year+1, wkst).Sub(start).Hours()/24) / 7
+	return week, weeks
+}
+
+func weekOneStart(year int, wkst time.Weekday) time.Time {
+	jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+	return jan4.AddDate(0, 0, -((int(jan4.Weekday()) - int(wkst) + 7) % 7))
+}
+
+// matchOrdinal reports whether value is in list, negative entries counting back from total
+func matchOrdinal(list []int, value, total int) bool {
+	for _, n := range list {
+		if n == value || n < 0 && total+n+1 == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, value int) bool {
+	for _, n := range list {
+		if n == value {
+			return true
+		}
+	}
+	return false
+}
+
+func daysBetween(from, to time.Time) []time.Time {
+	var days []time.Time
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+// event is a VEVENT with its times resolved, read from a file or built from an EventInput
+type event struct {
+	Start        icsTime
+	End          icsTime
+	Duration     *icsDuration
+	Rule         *recurrence
+	ExDates      []icsTime
+	RDates       []icsTime
+	RecurrenceID *icsTime
+}
+
+// occurrences returns the start times of the recurrence set overlapping [from, to) (zero
+// times leave the window open): DTSTART, RRULE and RDATE times minus EXDATE, in order.
+// The list stops at limit entries and reports whether more were left.
+func (e *event) occurrences(from, to time.Time, limit int) ([]icsTime, bool) {
+	length := e.End.instant().Sub(e.Start.instant())
+	excluded := map[int64]bool{}
+	excludedDays := map[string]bool{}
+	for _, x := range e.ExDates {
+		if x.DateOnly && !e.Start.DateOnly {
+			excludedDays[x.Wall.Format(dateLayout)] = true
+		} else {
+			excluded[x.instant().Unix()] = true
+		}
+	}
+	keep := func(t icsTime) bool {
+		at := t.instant()
+		switch {
+		case excluded[at.Unix()] || excludedDays[t.Wall.Format(dateLayout)]:
+			return false
+		case !to.IsZero() && !at.Before(to):
+			return false
+		}
+		return from.IsZero() || !at.Before(from) || at.Add(length).After(from)
+	}
+
+	var times []icsTime
+	if keep(e.Start) {
+		times = append(times, e.Start)
+	}
+	for _, t := range e.RDates {
+		if keep(t) {
+			times = append(times, t)
+		}
+	}
+	if e.Rule != nil {
+		var fromWall time.Time
+		if !from.IsZero() {
+			fromWall = e.Start.wallAt(from.Add(-length))
+		}
+		// DTSTART counts as the first occurrence toward COUNT even when the rule does not match it
+		found, generated := 0, 0
+		e.Rule.each(e.Start.Wall, fromWall, untilWall(e.Rule.Until, e.Start), func(wall time.Time) bool {
+			if generated++; generated == 1 && !wall.Equal(e.Start.Wall) && e.Rule.Count > 0 {
+				generated++
+			}
+			if e.Rule.Count > 0 && generated > e.Rule.Count {
+				return false
+			}
+			t := e.Start.withWall(wall)
+			if !to.IsZero() && !t.instant().Before(to) {
+				return false
+			}
+			if keep(t) {
+				times = append(times, t)
+				found++
+			}
+			return found <= limit
+		})
+	}
+
+	sort.SliceStable(times, func(i, j int) bool { return times[i].instant().Before(times[j].instant()) })
+	unique := times[:0]
+	for i, t := range times {
+		if i == 0 || !t.instant().Equal(times[i-1].instant()) {
+			unique = append(unique, t)
+		}
+	}
+	if len(unique) > limit {
+		return unique[:limit], true
+	}
+	return unique, false
+}
+
+// untilWall places UNTIL on the wall clock of start
+func untilWall(until *icsTime, start icsTime) time.Time {
+	switch {
+	case until == nil:
+		return time.Time{}
+	case until.DateOnly && !start.DateOnly:
+		return until.Wall.Add(24*time.Hour - time.Second)
+	case until.UTC && start.Zone != nil && !start.DateOnly:
+		return start.Zone.wallOf(until.Wall)
+	}
+	return until.Wall
+}
+
+// occurrenceJSON describes an occurrence lasting length
+func occurrenceJSON(t icsTime, length time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"start": t.String(),
+		"end":   t.after(length).String(),
+	}
+}
+
+// window parses the bounds of an expansion, reading dates and local times in z
+func (o expandOptions) window(z *zone) (time.Time, time.Time, int, error) {
+	var bounds [2]time.Time
+	for i, value := range []string{o.From, o.To} {
+		if value == "" {
+			continue
+		}
+		t, err := parseInputTime(value, z, false)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, err
+		}
+		if t.DateOnly && z != nil && z != utcZone {
+			t = icsTime{Wall: t.Wall, Zone: z}
+		}
+		bounds[i] = t.instant()
+	}
+
+	limit := o.Limit
+	if limit <= 0 {
+		limit = defaultOccurrenceLimit
+	}
+	if limit > maxOccurrenceLimit {
+		return time.Time{}, time.Time{}, 0, errors.New(localize("limit must not exceed %d", maxOccurrenceLimit))
+	}
+	return bounds[0], bounds[1], limit, nil
+}
+
+// calendarParser converts components to JSON, resolving TZIDs against the file's VTIMEZONEs
+type calendarParser struct {
+	vtimezones map[string]*vtimezone
+	zones      map[string]*zone
+	warnings   []interface{}
+	warned     map[string]bool
+}
+
+// warn records a problem that did not stop the parsing, once
+func (p *calendarParser) warn(message string) {
+	if !p.warned[message] {
+		p.warned[message] = true
+		p.warnings = append(p.warnings, message)
+	}
+}
+
+// zone resolves a TZID; unknown zones are reported and their times read as floating
+func (p *calendarParser) zone(tzid string) *zone {
+	if z, ok := p.zones[tzid]; ok {
+		return z
+	}
+	z := lookupZone(tzid, p.vtimezones)
+	if z == nil {
+		p.warn(localize("unknown time zone %q, its times are read as floating", tzid))
+	}
+	p.zones[tzid] = z
+	return z
+}
+
+// timeValues reads the comma-separated times of a property with its VALUE and TZID
+// parameters. PERIOD values keep their start.
+func (p *calendarParser) timeValues(prop icsProperty) ([]icsTime, error) {
+	var z *zone
+	if tzid := prop.param("TZID"); tzid != "" {
+		z = p.zone(tzid)
+	}
+	kind := prop.param("VALUE")
+	if strings.EqualFold(kind, "PERIOD") {
+		kind = ""
+	}
+
+	var times []icsTime
+	for _, value := range strings.Split(prop.Value, ",") {
+		if i := strings.IndexByte(value, '/'); i >= 0 {
+			value = value[:i]
+		}
+		t, err := parseTimeValue(value, kind, z)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// timeValue reads a single-valued time property
+func (p *calendarParser) timeValue(prop icsProperty) (icsTime, error) {
+	times, err := p.timeValues(prop)
+	if err != nil {
+		return icsTime{}, err
+	}
+	return times[0], nil
+}
+
+// eventProperties are the VEVENT properties parseICS maps to fields; others go to extra
+var eventProperties = map[string]bool{
+	"UID": true, "SUMMARY": true, "DESCRIPTION": true, "LOCATION": true, "URL": true,
+	"DTSTART": true, "DTEND": true, "DURATION": true, "STATUS": true, "TRANSP": true,
+	"CLASS": true, "PRIORITY": true, "SEQUENCE": true, "CATEGORIES": true, "ORGANIZER": true,
+	"ATTENDEE": true, "RRULE": true, "EXDATE": true, "RDATE": true, "RECURRENCE-ID": true,
+	"GEO": true, "CREATED": true, "LAST-MODIFIED": true, "DTSTAMP": true,
+}
+
+// event converts a VEVENT to JSON and resolves its times for expansion
+func (p *calendarParser) event(c *icsComponent) (map[string]interface{}, *event, error) {
+	prop, ok := c.property("DTSTART")
+	if !ok {
+		return nil, nil, errors.New(localize("missing DTSTART"))
+	}
+	ev := &event{}
+	var err error
+	if ev.Start, err = p.timeValue(prop); err != nil {
+		return nil, nil, err
+	}
+
+	uid := c.text("UID")
+	ev.End = ev.Start
+	if prop, ok := c.property("DTEND"); ok {
+		if ev.End, err = p.timeValue(prop); err != nil {
+			return nil, nil, err
+		}
+	} else if prop, ok := c.property("DURATION"); ok {
+		d, err := parseDuration(prop.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		ev.Duration = &d
+		ev.End = ev.Start.withWall(d.add(ev.Start.Wall))
+	} else if ev.Start.DateOnly {
+		ev.End = ev.Start.withWall(ev.Start.Wall.AddDate(0, 0, 1))
+	}
+	if ev.End.instant().Before(ev.Start.instant()) {
+		p.warn(localize("event %q ends before it starts, its end is ignored", uid))
+		ev.End = ev.Start
+	}
+
+	info := map[string]interface{}{
+		"uid":          uid,
+		"summary":      c.text("SUMMARY"),
+		"description":  c.text("DESCRIPTION"),
+		"location":     c.text("LOCATION"),
+		"url":          c.text("URL"),
+		"start":        ev.Start.String(),
+		"end":          ev.End.String(),
+		"allDay":       ev.Start.DateOnly,
+		"duration":     durationOf(ev.End.instant().Sub(ev.Start.instant())).String(),
+		"timezone":     ev.Start.zoneID(),
+		"status":       c.text("STATUS"),
+		"transparency": c.text("TRANSP"),
+		"class":        c.text("CLASS"),
+		"priority":     atoi(c.text("PRIORITY")),
+		"sequence":     atoi(c.text("SEQUENCE")),
+		"organizer":    nil,
+		"rrule":        "",
+		"recurrence":   nil,
+		"recurrenceId": "",
+		"geo":          nil,
+		"created":      p.stamp(c, "CREATED"),
+		"lastModified": p.stamp(c, "LAST-MODIFIED"),
+		"dtstamp":      p.stamp(c, "DTSTAMP"),
+	}
+
+	categories := []interface{}{}
+	for _, prop := range c.properties("CATEGORIES") {
+		for _, category := range splitText(prop.Value) {
+			if category = strings.TrimSpace(category); category != "" {
+				categories = append(categories, category)
+			}
+		}
+	}
+	info["categories"] = categories
+
+	if prop, ok := c.property("ORGANIZER"); ok {
+		info["organizer"] = contactJSON(prop)
+	}
+	attendees := []interface{}{}
+	for _, prop := range c.properties("ATTENDEE") {
+		attendees = append(attendees, contactJSON(prop))
+	}
+	info["attendees"] = attendees
+
+	if prop, ok := c.property("RRULE"); ok {
+		rule, err := parseRecurrence(prop.Value, func(value string) (icsTime, error) {
+			return parseTimeValue(value, "", nil)
+		})
+		if err != nil {
+			p.warn(localize("event %q: RRULE ignored: %v", uid, err))
+		} else {
+			ev.Rule = rule
+			info["rrule"] = rule.String()
+			info["recurrence"] = rule.toJSON()
+		}
+	}
+	for name, target := range map[string]*[]icsTime{"EXDATE": &ev.ExDates, "RDATE": &ev.RDates} {
+		for _, prop := range c.properties(name) {
+			times, err := p.timeValues(prop)
+			if err != nil {
+				p.warn(localize("event %q: %s ignored: %v", uid, name, err))
+				continue
+			}
+			*target = append(*target, times...)
+		}
+	}
+	info["exdates"] = timeStrings(ev.ExDates)
+	info["rdates"] = timeStrings(ev.RDates)
+	if prop, ok := c.property("RECURRENCE-ID"); ok {
+		if rid, err := p.timeValue(prop); err == nil {
+			ev.RecurrenceID = &rid
+			info["recurrenceId"] = rid.String()
+		}
+	}
+
+	if lat, lon, ok := strings.Cut(c.text("GEO"), ";"); ok {
+		latitude, err1 := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+		longitude, err2 := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+		if err1 == nil && err2 == nil {
+			info["geo"] = map[string]interface{}{"lat": latitude, "lon": longitude}
+		}
+	}
+
+	alarms := []interface{}{}
+	for _, alarm := range c.components("VALARM") {
+		alarms = append(alarms, alarmJSON(alarm))
+	}
+	info["alarms"] = alarms
+
+	extra := map[string]interface{}{}
+	for _, prop := range c.Properties {
+		if _, seen := extra[prop.Name]; !seen && !eventProperties[prop.Name] {
+			extra[prop.Name] = unescapeText(prop.Value)
+		}
+	}
+	info["extra"] = extra
+
+	return info, ev, nil
+}
+
+// stamp formats a DATE-TIME property such as CREATED, or returns ""
+func (p *calendarParser) stamp(c *icsComponent, name string) string {
+	prop, ok := c.property(name)
+	if !ok {
+		return ""
+	}
+	t, err := p.timeValue(prop)
+	if err != nil {
+		return ""
+	}
+	return t.String()
+}
+
+// contactJSON describes an ORGANIZER or ATTENDEE property
+func contactJSON(prop icsProperty) map[string]interface{} {
+	email := strings.TrimSpace(prop.Value)
+	if len(email) >= 7 && strings.EqualFold(email[:7], "mailto:") {
+		email = email[7:]
+	}
+	contact := map[string]interface{}{
+		"name":  prop.param("CN"),
+		"email": email,
+	}
+	for key, param := range map[string]string{"role": "ROLE", "status": "PARTSTAT", "type": "CUTYPE"} {
+		if value := prop.param(param); value != "" {
+			contact[key] = value
+		}
+	}
+	if prop.Name == "ATTENDEE" {
+		contact["rsvp"] = strings.EqualFold(prop.param("RSVP"), "TRUE")
+	}
+	return contact
+}
+
+// alarmJSON describes a VALARM. Relative triggers also give offsetSeconds (negative before),
+// absolute ones the moment as at.
+func alarmJSON(c *icsComponent) map[string]interface{} {
+	alarm := map[string]interface{}{
+		"action":         c.text("ACTION"),
+		"trigger":        "",
+		"description":    c.text("DESCRIPTION"),
+		"summary":        c.text("SUMMARY"),
+		"repeat":         atoi(c.text("REPEAT")),
+		"repeatInterval": c.text("DURATION"),
+	}
+	if prop, ok := c.property("TRIGGER"); ok {
+		alarm["trigger"] = strings.TrimSpace(prop.Value)
+		if strings.EqualFold(prop.param("VALUE"), "DATE-TIME") {
+			if at, err := parseTimeValue(prop.Value, "", nil); err == nil {
+				alarm["at"] = at.String()
+			}
+		} else if d, err := parseDuration(prop.Value); err == nil {
+			alarm["offsetSeconds"] = int(d.length() / time.Second)
+			alarm["related"] = strings.ToLower(firstNonEmpty(prop.param("RELATED"), "START"))
+		}
+	}
+	if attendees := c.properties("ATTENDEE"); len(attendees) > 0 {
+		list := make([]interface{}, len(attendees))
+		for i, prop := range attendees {
+			list[i] = contactJSON(prop)
+		}
+		alarm["attendees"] = list
+	}
+	return alarm
+}
+
+func timeStrings(times []icsTime) []interface{} {
+	list := make([]interface{}, len(times))
+	for i, t := range times {
+		list[i] = t.String()
+	}
+	return list
+}
+
+func atoi(text string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(text))
+	return n
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseCalendar reads the VCALENDARs of a file into the structure returned by parseICS
+func parseCalendar(raw []byte, options parseOptions) (map[string]interface{}, error) {
+	roots, err := parseComponents(raw)
+	if err != nil {
+		return nil, err
+	}
+	var calendars []*icsComponent
+	for _, c := range roots {
+		if c.Name == "VCALENDAR" {
+			calendars = append(calendars, c)
+		}
+	}
+	if len(calendars) == 0 {
+		return nil, errors.New(localize("no VCALENDAR component found"))
+	}
+
+	var expand *expandOptions
+	switch text := strings.TrimSpace(string(options.Expand)); text {
+	case "", "null", "false":
+	case "true":
+		expand = &expandOptions{}
+	default:
+		expand = &expandOptions{}
+		if err := json.Unmarshal(options.Expand, expand); err != nil {
+			return nil, errors.New(localize("invalid expand options: %v", err))
+		}
+	}
+
+	p := &calendarParser{
+		vtimezones: map[string]*vtimezone{},
+		zones:      map[string]*zone{},
+		warnings:   []interface{}{},
+		warned:     map[string]bool{},
+	}
+
+	// Time zones first: events may reference a VTIMEZONE written after them
+	timezones := []interface{}{}
+	for _, cal := range calendars {
+		for _, c := range cal.components("VTIMEZONE") {
+			vtz, info, err := parseVTimezone(c)
+			if err != nil {
+				p.warn(localize("time zone %q ignored: %v", c.text("TZID"), err))
+				continue
+			}
+			p.vtimezones[vtz.ID] = vtz
+			timezones = append(timezones, info)
+		}
+	}
+
+	var infos []map[string]interface{}
+	var events []*event
+	for _, cal := range calendars {
+		for _, c := range cal.components("VEVENT") {
+			info, ev, err := p.event(c)
+			if err != nil {
+				p.warn(localize("event %q skipped: %v", c.text("UID"), err))
+				continue
+			}
+			infos = append(infos, info)
+			events = append(events, ev)
+		}
+	}
+
+	if expand != nil {
+		from, to, limit, err := expand.window(nil)
+		if err != nil {
+			return nil, errors.New(localize("invalid expand options: %v", err))
+		}
+
+		// Modified instances (RECURRENCE-ID) replace the occurrence they override
+		overrides := map[string]map[int64]*event{}
+		for i, ev := range events {
+			if ev.RecurrenceID != nil {
+				uid := infos[i]["uid"].(string)
+				if overrides[uid] == nil {
+					overrides[uid] = map[int64]*event{}
+				}
+				overrides[uid][ev.RecurrenceID.instant().Unix()] = ev
+			}
+		}
+		for i, ev := range events {
+			if ev.RecurrenceID != nil || ev.Rule == nil && len(ev.RDates) == 0 {
+				continue
+			}
+			times, truncated := ev.occurrences(from, to, limit)
+			length := ev.End.instant().Sub(ev.Start.instant())
+			occurrences := make([]interface{}, len(times))
+			for j, t := range times {
+				if override, ok := overrides[infos[i]["uid"].(string)][t.instant().Unix()]; ok {
+					occurrences[j] = map[string]interface{}{
+						"start":        override.Start.String(),
+						"end":          override.End.String(),
+						"recurrenceId": t.String(),
+					}
+					continue
+				}
+				occurrences[j] = occurrenceJSON(t, length)
+			}
+			infos[i]["occurrences"] = occurrences
+			infos[i]["truncated"] = truncated
+		}
+	}
+
+	list := make([]interface{}, len(infos))
+	for i, info := range infos {
+		list[i] = info
+	}
+	first := calendars[0]
+	return map[string]interface{}{
+		"version":     first.text("VERSION"),
+		"prodId":      first.text("PRODID"),
+		"method":      first.text("METHOD"),
+		"calScale":    firstNonEmpty(first.text("CALSCALE"), "GREGORIAN"),
+		"name":        first.text("X-WR-CALNAME"),
+		"description": first.text("X-WR-CALDESC"),
+		"timezone":    first.text("X-WR-TIMEZONE"),
+		"events":      list,
+		"timezones":   timezones,
+		"warnings":    p.warnings,
+	}, nil
+}
+
+// calendarInput decodes a calendar, an array of events or a single event
+func calendarInput(text string) (CalendarInput, error) {
+	var cal CalendarInput
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "[") {
+		err := json.Unmarshal([]byte(trimmed), &cal.Events)
+		return cal, err
+	}
+	if err := json.Unmarshal([]byte(trimmed), &cal); err != nil {
+		return cal, err
+	}
+	if cal.Events == nil {
+		var single EventInput
+		if err := json.Unmarshal([]byte(trimmed), &single); err == nil && single.Start != "" {
+			return CalendarInput{Events: []EventInput{single}}, nil
+		}
+	}
+	return cal, nil
+}
+
+// resolve checks the times of an EventInput and places them, reading local times in its
+// timezone (or defaultTimezone)
+func (in EventInput) resolve(defaultTimezone string) (*event, error) {
+	if strings.TrimSpace(in.Start) == "" {
+		return nil, errors.New(localize("start is required"))
+	}
+	var z *zone
+	if id := firstNonEmpty(in.Timezone, defaultTimezone); id != "" && !in.AllDay {
+		if z = lookupZone(id, nil); z == nil {
+			return nil, errors.New(localize("unknown time zone %q", id))
+		}
+	}
+
+	ev := &event{}
+	var err error
+	if ev.Start, err = parseInputTime(in.Start, z, in.AllDay); err != nil {
+		return nil, err
+	}
+	// A date given for a timed event means the same time of day on that date
+	sameForm := func(t icsTime) icsTime {
+		if t.DateOnly && !ev.Start.DateOnly {
+			wall := ev.Start.Wall
+			return ev.Start.withWall(time.Date(t.Wall.Year(), t.Wall.Month(), t.Wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, time.UTC))
+		}
+		return t
+	}
+
+	switch {
+	case in.End != "":
+		end, err := parseInputTime(in.End, z, ev.Start.DateOnly)
+		if err != nil {
+			return nil, err
+		}
+		if ev.End = sameForm(end); ev.End.instant().Before(ev.Start.instant()) {
+			return nil, errors.New(localize("end is before start"))
+		}
+	case in.Duration != "":
+		d, err := parseDuration(in.Duration)
+		if err != nil {
+			return nil, err
+		}
+		if d.Negative {
+			return nil, errors.New(localize("duration must not be negative"))
+		}
+		ev.Duration = &d
+		ev.End = ev.Start.withWall(d.add(ev.Start.Wall))
+	case ev.Start.DateOnly:
+		ev.End = ev.Start.withWall(ev.Start.Wall.AddDate(0, 0, 1))
+	default:
+		ev.End = ev.Start
+	}
+
+	if len(in.RRule) > 0 && string(in.RRule) != "null" {
+		text, err := ruleText(in.RRule)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text) != "" {
+			ev.Rule, err = parseRecurrence(text, func(value string) (icsTime, error) {
+				return untilFor(value, ev.Start)
+			})
+			if err != nil {
+				return nil, errors.New(localize("invalid rrule: %v", err))
+			}
+		}
+	}
+
+	for _, list := range []struct {
+		values []string
+		target *[]icsTime
+	}{{in.ExDates, &ev.ExDates}, {in.RDates, &ev.RDates}} {
+		for _, value := range list.values {
+			t, err := parseInputTime(value, z, ev.Start.DateOnly)
+			if err != nil {
+				return nil, err
+			}
+			*list.target = append(*list.target, sameForm(t))
+		}
+	}
+	if in.RecurrenceID != "" {
+		rid, err := parseInputTime(in.RecurrenceID, z, ev.Start.DateOnly)
+		if err != nil {
+			return nil, err
+		}
+		rid = sameForm(rid)
+		ev.RecurrenceID = &rid
+	}
+	return ev, nil
+}
+
+// untilFor reads an UNTIL value in the form RFC 5545 requires for start: a date for all-day
+// events, UTC for zoned and UTC events, floating otherwise
+func untilFor(value string, start icsTime) (icsTime, error) {
+	until, err := parseInputTime(value, start.Zone, start.DateOnly)
+	if err != nil {
+		return until, err
+	}
+	if until.DateOnly && !start.DateOnly {
+		until = icsTime{Wall: until.Wall.Add(24*time.Hour - time.Second), UTC: start.UTC, Zone: start.Zone}
+	}
+	if !start.DateOnly && (start.Zone != nil || start.UTC) {
+		return icsTime{Wall: naive(until.instant().UTC()), UTC: true}, nil
+	}
+	return until, nil
+}
+
+// check validates the enumerated and free-form fields of an EventInput
+func (in EventInput) check() error {
+	for _, field := range []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"status", in.Status, []string{"TENTATIVE", "CONFIRMED", "CANCELLED"}},
+		{"transparency", in.Transparency, []string{"OPAQUE", "TRANSPARENT"}},
+		{"class", in.Class, []string{"PUBLIC", "PRIVATE", "CONFIDENTIAL"}},
+	} {
+		if field.value != "" && !containsString(field.allowed, strings.ToUpper(field.value)) {
+			return errors.New(localize("%s must be one of %s", field.name, strings.Join(field.allowed, ", ")))
+		}
+	}
+	if in.Priority < 0 || in.Priority > 9 {
+		return errors.New(localize("priority must be between 0 and 9"))
+	}
+	if in.Geo != nil && (in.Geo.Lat < -90 || in.Geo.Lat > 90 || in.Geo.Lon < -180 || in.Geo.Lon > 180) {
+		return errors.New(localize("geo must be a latitude between -90 and 90 and a longitude between -180 and 180"))
+	}
+	for _, value := range []string{in.Created, in.LastModified} {
+		if _, err := utcStamp(value); err != nil {
+			return err
+		}
+	}
+	for i, alarm := range in.Alarms {
+		if err := alarm.check(); err != nil {
+			return errors.New(localize("alarm %d: %v", i+1, err))
+		}
+	}
+	for name := range in.Extra {
+		if !validPropertyName(name) {
+			return errors.New(localize("invalid property name %q", name))
+		}
+		if eventProperties[strings.ToUpper(name)] || strings.EqualFold(name, "BEGIN") || strings.EqualFold(name, "END") {
+			return errors.New(localize("property %q is set by generateICS and cannot be overridden", name))
+		}
+	}
+	return nil
+}
+
+// check validates an alarm action, trigger and repetition
+func (a AlarmInput) check() error {
+	action := strings.ToUpper(firstNonEmpty(a.Action, "DISPLAY"))
+	if !containsString([]string{"DISPLAY", "AUDIO", "EMAIL"}, action) {
+		return errors.New(localize("%s must be one of %s", "action", "DISPLAY, AUDIO, EMAIL"))
+	}
+	if action == "EMAIL" && len(a.Attendees) == 0 {
+		return errors.New(localize("EMAIL alarms need attendees"))
+	}
+	if _, _, err := alarmTrigger(a); err != nil {
+		return err
+	}
+	if a.Repeat < 0 {
+		return errors.New(localize("%s must be a positive integer", "repeat"))
+	}
+	if a.Repeat > 0 {
+		if a.RepeatInterval == "" {
+			return errors.New(localize("repeat needs a repeatInterval"))
+		}
+		if _, err := parseDuration(a.RepeatInterval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alarmTrigger returns the TRIGGER value and parameters of an alarm
+func alarmTrigger(a AlarmInput) (string, []string, error) {
+	var params []string
+	if strings.EqualFold(a.Related, "end") {
+		params = []string{"RELATED", "END"}
+	}
+	switch {
+	case a.MinutesBefore != nil:
+		return durationOf(-time.Duration(*a.MinutesBefore * float64(time.Minute))).String(), params, nil
+	case a.Trigger != "":
+		if d, err := parseDuration(a.Trigger); err == nil {
+			return d.String(), params, nil
+		}
+		at, err := parseInputTime(a.Trigger, nil, false)
+		if err != nil || at.DateOnly {
+			return "", nil, errors.New(localize("invalid alarm trigger %q", a.Trigger))
+		}
+		return at.instant().UTC().Format(utcLayout), []string{"VALUE", "DATE-TIME"}, nil
+	}
+	return "", nil, errors.New(localize("an alarm needs a trigger or minutesBefore"))
+}
+
+// utcStamp formats a CREATED or LAST-MODIFIED time, which RFC 5545 requires in UTC
+func utcStamp(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	t, err := parseInputTime(value, nil, false)
+	if err != nil {
+		return "", err
+	}
+	return t.instant().UTC().Format(utcLayout), nil
+}
+
+// years returns the span of years the event's times reach, used to size generated VTIMEZONEs
+func (e *event) years() (int, int) {
+	first, last := e.Start.Wall.Year(), e.End.Wall.Year()
+	for _, list := range [][]icsTime{e.ExDates, e.RDates} {
+		for _, t := range list {
+			if y := t.Wall.Year(); y < first {
+				first = y
+			} else if y > last {
+				last = y
+			}
+		}
+	}
+	if e.Rule != nil {
+		if e.Rule.Until != nil && e.Rule.Until.Wall.Year() > last {
+			last = e.Rule.Until.Wall.Year()
+		} else if e.Rule.Until == nil {
+			last = first + 10
+		}
+	}
+	if last > first+50 {
+		last = first + 50
+	}
+	return first, last
+}
+
+// validMethods are the iTIP methods of RFC 5546
+var validMethods = []string{"PUBLISH", "REQUEST", "REPLY", "ADD", "CANCEL", "REFRESH", "COUNTER", "DECLINECOUNTER"}
+
+// buildCalendar writes a VCALENDAR with the VTIMEZONEs its events use and returns the UIDs
+func buildCalendar(cal CalendarInput, stamp time.Time) ([]byte, []interface{}, error) {
+	if len(cal.Events) == 0 {
+		return nil, nil, errors.New(localize("the calendar has no events"))
+	}
+	if cal.Method != "" && !containsString(validMethods, strings.ToUpper(cal.Method)) {
+		return nil, nil, errors.New(localize("%s must be one of %s", "method", strings.Join(validMethods, ", ")))
+	}
+	if cal.Timezone != "" && lookupZone(cal.Timezone, nil) == nil {
+		return nil, nil, errors.New(localize("unknown time zone %q", cal.Timezone))
+	}
+
+	events := make([]*event, len(cal.Events))
+	zones := map[string]*zone{}
+	spans := map[string][2]int{}
+	for i, in := range cal.Events {
+		ev, err := in.resolve(cal.Timezone)
+		if err == nil {
+			err = in.check()
+		}
+		if err != nil {
+			return nil, nil, errors.New(localize("event %d: %v", i+1, err))
+		}
+		events[i] = ev
+
+		if z := ev.Start.Zone; z != nil {
+			first, last := ev.years()
+			span, seen := spans[z.ID]
+			if !seen || first < span[0] {
+				span[0] = first
+			}
+			if !seen || last > span[1] {
+				span[1] = last
+			}
+			zones[z.ID], spans[z.ID] = z, span
+		}
+	}
+
+	w := &icsWriter{}
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.text("PRODID", firstNonEmpty(cal.ProdID, defaultProdID))
+	w.line("CALSCALE", "GREGORIAN")
+	if cal.Method != "" {
+		w.line("METHOD", strings.ToUpper(cal.Method))
+	}
+	w.text("X-WR-CALNAME", cal.Name)
+	w.text("X-WR-CALDESC", cal.Description)
+	w.text("X-WR-TIMEZONE", cal.Timezone)
+
+	ids := make([]string, 0, len(zones))
+	for id := range zones {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		writeTimezone(w, zones[id], spans[id][0], spans[id][1])
+	}
+
+	uids := make([]interface{}, len(cal.Events))
+	for i, in := range cal.Events {
+		uids[i] = writeEvent(w, in, events[i], stamp)
+	}
+	w.line("END", "VCALENDAR")
+	return w.buf.Bytes(), uids, nil
+}
+
+// writeEvent writes a VEVENT and returns its UID
+func writeEvent(w *icsWriter, in EventInput, ev *event, stamp time.Time) string {
+	uid := in.UID
+	if uid == "" {
+		uid = newUID()
+	}
+
+	w.line("BEGIN", "VEVENT")
+	w.text("UID", uid)
+	w.line("DTSTAMP", stamp.Format(utcLayout))
+	w.time("DTSTART", ev.Start)
+	switch {
+	case ev.Duration != nil:
+		w.line("DURATION", ev.Duration.String())
+	case ev.Start.DateOnly || !ev.End.Wall.Equal(ev.Start.Wall):
+		w.time("DTEND", ev.End)
+	}
+	if ev.RecurrenceID != nil {
+		w.time("RECURRENCE-ID", *ev.RecurrenceID)
+	}
+	w.text("SUMMARY", in.Summary)
+	w.text("DESCRIPTION", in.Description)
+	w.text("LOCATION", in.Location)
+	if in.URL != "" {
+		w.line("URL", in.URL)
+	}
+	if in.Status != "" {
+		w.line("STATUS", strings.ToUpper(in.Status))
+	}
+	if in.Transparency != "" {
+		w.line("TRANSP", strings.ToUpper(in.Transparency))
+	}
+	if in.Class != "" {
+		w.line("CLASS", strings.ToUpper(in.Class))
+	}
+	if in.Priority > 0 {
+		w.line("PRIORITY", strconv.Itoa(in.Priority))
+	}
+	if in.Sequence > 0 {
+		w.line("SEQUENCE", strconv.Itoa(in.Sequence))
+	}
+	if len(in.Categories) > 0 {
+		categories := make([]string, len(in.Categories))
+		for i, category := range in.Categories {
+			categories[i] = escapeText(category)
+		}
+		w.line("CATEGORIES", strings.Join(categories, ","))
+	}
+	if in.Geo != nil {
+		w.line("GEO", strconv.FormatFloat(in.Geo.Lat, 'f', -1, 64)+";"+strconv.FormatFloat(in.Geo.Lon, 'f', -1, 64))
+	}
+	if in.Organizer != nil {
+		w.contact("ORGANIZER", *in.Organizer)
+	}
+	for _, attendee := range in.Attendees {
+		w.contact("ATTENDEE", attendee)
+	}
+	if ev.Rule != nil {
+		w.line("RRULE", ev.Rule.String())
+	}
+	w.times("EXDATE", ev.ExDates)
+	w.times("RDATE", ev.RDates)
+	for name, value := range map[string]string{"CREATED": in.Created, "LAST-MODIFIED": in.LastModified} {
+		if formatted, _ := utcStamp(value); formatted != "" {
+			w.line(name, formatted)
+		}
+	}
+
+	names := make([]string, 0, len(in.Extra))
+	for name := range in.Extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.text(strings.ToUpper(name), in.Extra[name])
+	}
+
+	for _, alarm := range in.Alarms {
+		writeAlarm(w, alarm, in.Summary)
+	}
+	w.line("END", "VEVENT")
+	return uid
+}
+
+// writeAlarm writes a VALARM; display and email alarms default their text to the event summary
+func writeAlarm(w *icsWriter, a AlarmInput, summary string) {
+	action := strings.ToUpper(firstNonEmpty(a.Action, "DISPLAY"))
+	trigger, params, _ := alarmTrigger(a)
+
+	w.line("BEGIN", "VALARM")
+	w.line("ACTION", action)
+	w.line("TRIGGER", trigger, params...)
+	if action != "AUDIO" {
+		w.text("DESCRIPTION", firstNonEmpty(a.Description, summary, "Reminder"))
+	}
+	if action == "EMAIL" {
+		w.text("SUMMARY", firstNonEmpty(a.Summary, summary, "Reminder"))
+		for _, attendee := range a.Attendees {
+			w.contact("ATTENDEE", attendee)
+		}
+	}
+	if a.Repeat > 0 {
+		interval, _ := parseDuration(a.RepeatInterval)
+		w.line("REPEAT", strconv.Itoa(a.Repeat))
+		w.line("DURATION", interval.String())
+	}
+	w.line("END", "VALARM")
+}
+
+// zoneTransition is an offset change of an IANA zone, at a wall-clock time in the old offset
+type zoneTransition struct {
+	wall     time.Time
+	from     int
+	to       int
+	name     string
+	daylight bool
+}
+
+// zoneTransitions finds the offset changes of a location during a year
+func zoneTransitions(location *time.Location, year int) []zoneTransition {
+	offsetAt := func(unix int64) int {
+		_, offset := time.Unix(unix, 0).In(location).Zone()
+		return offset
+	}
+
+	var list []zoneTransition
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	end := time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	offset := offsetAt(start)
+	for day := start; day < end; day += 86400 {
+		next := offsetAt(day + 86400)
+		if next == offset {
+			continue
+		}
+		lo, hi := day, day+86400
+		for hi-lo > 1 {
+			if mid := (lo + hi) / 2; offsetAt(mid) == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		at := time.Unix(hi, 0).In(location)
+		name, _ := at.Zone()
+		list = append(list, zoneTransition{
+			wall:     time.Unix(hi+int64(offset), 0).UTC(),
+			from:     offset,
+			to:       next,
+			name:     name,
+			daylight: at.IsDST(),
+		})
+		offset = next
+	}
+	return list
+}
+
+// transitionRule describes a transition as a yearly RRULE on the nth (or last) weekday of its month
+func transitionRule(t zoneTransition) string {
+	day := t.wall.Day()
+	n := (day-1)/7 + 1
+	if day+7 > daysInMonth(t.wall.Year(), t.wall.Month()) {
+		n = -1
+	}
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(t.wall.Month()), n, weekdayCode(t.wall.Weekday()))
+}
+
+// writeTimezone writes a VTIMEZONE for an IANA zone covering fromYear to toYear. Zones whose
+// transitions follow a yearly weekday rule get RRULEs; others list each transition.
+func writeTimezone(w *icsWriter, z *zone, fromYear, toYear int) {
+	w.line("BEGIN", "VTIMEZONE")
+	w.text("TZID", z.ID)
+
+	base := zoneTransitions(z.location, fromYear-1)
+	rules := make([]string, len(base))
+	for i, t := range base {
+		rules[i] = transitionRule(t)
+	}
+	regular := len(base) > 0
+	for year := fromYear; year <= toYear && regular; year++ {
+		list := zoneTransitions(z.location, year)
+		if len(list) != len(base) {
+			regular = false
+			break
+		}
+		for i, t := range list {
+			if transitionRule(t) != rules[i] || t.wall.Format("150405") != base[i].wall.Format("150405") ||
+				t.from != base[i].from || t.to != base[i].to {
+				regular = false
+			}
+		}
+	}
+
+	if regular {
+		for i, t := range base {
+			writeObservance(w, t, rules[i])
+		}
+	} else {
+		// The offset in effect at the start of the span, then every change
+		at := time.Date(fromYear-1, 1, 1, 0, 0, 0, 0, time.UTC).In(z.location)
+		name, offset := at.Zone()
+		writeObservance(w, zoneTransition{wall: naive(at), from: offset, to: offset, name: name, daylight: at.IsDST()}, "")
+		for year := fromYear - 1; year <= toYear; year++ {
+			for _, t := range zoneTransitions(z.location, year) {
+				writeObservance(w, t, "")
+			}
+		}
+	}
+	w.line("END", "VTIMEZONE")
+}
+
+func writeObservance(w *icsWriter, t zoneTransition, rule string) {
+	kind := "STANDARD"
+	if t.daylight {
+		kind = "DAYLIGHT"
+	}
+	w.line("BEGIN", kind)
+	w.line("DTSTART", t.wall.Format(localLayout))
+	w.line("TZOFFSETFROM", formatOffset(t.from))
+	w.line("TZOFFSETTO", formatOffset(t.to))
+	w.text("TZNAME", t.name)
+	if rule != "" {
+		w.line("RRULE", rule)
+	}
+	w.line("END", kind)
+}
+
+// icsWriter writes content lines with CRLF endings, folded at 75 octets
+type icsWriter struct {
+	buf bytes.Buffer
+}
+
+// line writes a property; params alternate parameter names and values
+func (w *icsWriter) line(name, value string, params ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	for i := 0; i+1 < len(params); i += 2 {
+		b.WriteString(";" + params[i] + "=" + paramValue(params[i+1]))
+	}
+	b.WriteString(":" + value)
+
+	// Folding never splits a UTF-8 sequence; continuation lines start with a space
+	line := b.String()
+	for limit := 75; len(line) > limit; limit = 74 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.buf.WriteString(line[:cut])
+		w.buf.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	w.buf.WriteString(line)
+	w.buf.WriteString("\r\n")
+}
+
+// text writes a TEXT property unless value is empty
+func (w *icsWriter) text(name, value string, params ...string) {
+	if value != "" {
+		w.line(name, escapeText(value), params...)
+	}
+}
+
+// time writes a DATE or DATE-TIME property
+func (w *icsWriter) time(name string, t icsTime) {
+	w.line(name, t.value(), t.params()...)
+}
+
+// times writes a list of times, one property per VALUE or TZID form
+func (w *icsWriter) times(name string, list []icsTime) {
+	var forms []string
+	values := map[string][]string{}
+	params := map[string][]string{}
+	for _, t := range list {
+		key := strings.Join(t.params(), "=")
+		if _, seen := values[key]; !seen {
+			forms = append(forms, key)
+			params[key] = t.params()
+		}
+		values[key] = append(values[key], t.value())
+	}
+	for _, key := range forms {
+		w.line(name, strings.Join(values[key], ","), params[key]...)
+	}
+}
+
+// contact writes an ORGANIZER or ATTENDEE as a mailto: address with its parameters
+func (w *icsWriter) contact(name string, c Contact) {
+	var params []string
+	if c.Name != "" {
+		params = append(params, "CN", c.Name)
+	}
+	for _, param := range [][2]string{{"CUTYPE", c.Type}, {"ROLE", c.Role}, {"PARTSTAT", c.Status}} {
+		if param[1] != "" {
+			params = append(params, param[0], strings.ToUpper(param[1]))
+		}
+	}
+	if c.RSVP {
+		params = append(params, "RSVP", "TRUE")
+	}
+	w.line(name, "mailto:"+c.Email, params...)
+}
+
+// paramValue quotes a parameter value holding a separator; quotes and line breaks cannot be written
+func paramValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r == '"':
+			return '\''
+		case r < ' ':
+			return ' '
+		}
+		return r
+	}, value)
+	if strings.ContainsAny(value, ";:,") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// validPropertyName reports names made of letters, digits and dashes
+func validPropertyName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// newUID generates a random event UID
+func newUID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id) + "@ics-wasm"
+}
+
+// calendarFilename derives a download name from the calendar name or the single event summary
+func calendarFilename(cal CalendarInput) string {
+	name := cal.Name
+	if name == "" && len(cal.Events) == 1 {
+		name = cal.Events[0].Summary
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = true
+			continue
+		}
+		if dash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		dash = false
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "calendar.ics"
+	}
+	return b.String() + ".ics"
+}
+
+// jsonArgument returns a JSON argument given either as a string or as a plain object
+func jsonArgument(value js.Value) string {
+	if value.Type() == js.TypeObject {
+		return js.Global().Get("JSON").Call("stringify", value).String()
+	}
+	return value.String()
+}
+
+// rawFromJS reads a file given as text, a Uint8Array or an ArrayBuffer
+func rawFromJS(value js.Value) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		return []byte(value.String()), nil
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case !value.InstanceOf(js.Global().Get("Uint8Array")):
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or string"))
+	}
+
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"ics-parse",
+	"ics-generate",
+	"rrule-expand",
+	"vtimezone",
+	"iana-timezones",
+	"alarms",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{}))
+}
+
+// releaseResources - Return freed memory to the runtime; ics-wasm keeps no cached state between calls
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "ics-wasm",
+		"version":         moduleVersion,
+		"description":     "iCalendar (.ics) parsing, generation and recurrence expansion module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"parseICS",
+		"generateICS",
+		"expandRecurrence",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register calendar functions
+	js.Global().Set("parseICS", js.FuncOf(parseICS))
+	js.Global().Set("generateICS", js.FuncOf(generateICS))
+	js.Global().Set("expandRecurrence", js.FuncOf(expandRecurrence))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM iCalendar module ready!")
+	fmt.Println("Available functions: parseICS, generateICS, expandRecurrence")
+
+	// Keep the program alive
+	select {}
+}
@@ -4,10 +4,20 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"image"
+	"image/color"
+	"image/draw"
 	"image/jpeg"
 	"image/png"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"syscall/js"
 )
 
@@ -21,10 +31,121 @@ func setSilentMode(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(silentMode)
 }
 
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"Error: imageData and quality required":                                  "Erreur: imageData et quality requis",
+	"Error: quality must be between 1 and 100":                               "Erreur: quality doit être compris entre 1 et 100",
+	"Error decoding image: %v":                                               "Erreur lors du décodage de l'image: %v",
+	"Error encoding JPEG: %v":                                                "Erreur lors de l'encodage JPEG: %v",
+	"Error: imageData required":                                              "Erreur: imageData requis",
+	"Error encoding PNG: %v":                                                 "Erreur lors de l'encodage PNG: %v",
+	"Error: imageData, width, and height required":                           "Erreur: imageData, width et height requis",
+	"Error: width and height must be positive":                               "Erreur: width et height doivent être positifs",
+	"Error encoding resized image: %v":                                       "Erreur lors de l'encodage de l'image redimensionnée: %v",
+	"Error encoding optimized image: %v":                                     "Erreur lors de l'encodage de l'image optimisée: %v",
+	"Error: values, from and to required":                                    "Erreur: values, from et to requis",
+	"Error: unsupported color space %q (use srgb, linear, hsl, hsv or lab)":  "Erreur: espace colorimétrique %q non pris en charge (utilisez srgb, linear, hsl, hsv ou lab)",
+	"Error: values must be an array of color triples":                        "Erreur: values doit être un tableau de triplets de couleurs",
+	"Error: reference must be an [r, g, b] array":                            "Erreur: reference doit être un tableau [r, g, b]",
+	"Error: reference color must not contain a zero channel":                 "Erreur: la couleur de référence ne doit pas contenir de canal nul",
+	"Error: image has an empty color channel, cannot estimate white balance": "Erreur: l'image a un canal de couleur vide, impossible d'estimer la balance des blancs",
+	"Error encoding balanced image: %v":                                      "Erreur lors de l'encodage de l'image corrigée: %v",
+	"Error: components must be between 1 and 9":                              "Erreur: components doit être compris entre 1 et 9",
+	"Error: maxSize must be between 1 and 256":                               "Erreur: maxSize doit être compris entre 1 et 256",
+	"Error encoding placeholder: %v":                                         "Erreur lors de l'encodage de l'aperçu: %v",
+	"setLocale requires exactly 1 argument (locale)":                         "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":                                  "Langue %q non prise en charge (disponibles: %s)",
+	"Error: maxSkew must be between 0 and 45 degrees":                        "Erreur: maxSkew doit être compris entre 0 et 45 degrés",
+	"Error: sensitivity must be between 0 and 1":                             "Erreur: sensitivity doit être compris entre 0 et 1",
+	"Error encoding cleaned page: %v":                                        "Erreur lors de l'encodage de la page nettoyée: %v",
+	"%d bytes after the end of the image":                                    "%d octets après la fin de l'image",
+	"%s signature found in %s":                                               "signature %s trouvée dans %s",
+	"malformed segment structure":                                            "structure de segments malformée",
+	"truncated segment":                                                      "segment tronqué",
+	"missing end of image marker":                                            "marqueur de fin d'image manquant",
+	"checksum mismatch in %s chunk":                                          "somme de contrôle invalide dans le bloc %s",
+	"unknown %s chunk of %d bytes":                                           "bloc %s inconnu de %d octets",
+	"EXIF metadata (camera, dates, settings)":                                "métadonnées EXIF (appareil, dates, réglages)",
+	"EXIF GPS data reveals where the picture was taken":                      "les données GPS EXIF révèlent où la photo a été prise",
+	"XMP metadata (editing history, author, tools)":                          "métadonnées XMP (historique, auteur, outils)",
+	"IPTC metadata (captions, author, keywords)":                             "métadonnées IPTC (légendes, auteur, mots-clés)",
+	"embedded ICC color profile":                                             "profil colorimétrique ICC intégré",
+	"%d comments or text chunks":                                             "%d commentaires ou blocs de texte",
+	"%dx%d pixels, a potential decompression bomb":                           "%dx%d pixels, une bombe de décompression potentielle",
+	"Error: unsupported output format %q (use jpeg or png)":                  "Erreur: format de sortie %q non pris en charge (utilisez jpeg ou png)",
+	"Error: image is %dx%d, over the limit of %d pixels":                     "Erreur: l'image fait %dx%d, au-delà de la limite de %d pixels",
+	"Error encoding sanitized image: %v":                                     "Erreur lors de l'encodage de l'image nettoyée: %v",
+}
+
 // compressJPEG - Compress JPEG image with specified quality
 func compressJPEG(this js.Value, args []js.Value) interface{} {
 	if len(args) < 2 {
-		return js.ValueOf("Error: imageData and quality required")
+		return js.ValueOf(localize("Error: imageData and quality required"))
 	}
 
 	// Get image data as Uint8Array
@@ -32,7 +153,7 @@ func compressJPEG(this js.Value, args []js.Value) interface{} {
 	quality := int(args[1].Float())
 
 	if quality < 1 || quality > 100 {
-		return js.ValueOf("Error: quality must be between 1 and 100")
+		return js.ValueOf(localize("Error: quality must be between 1 and 100"))
 	}
 
 	// Convert JS Uint8Array to Go []byte
@@ -43,7 +164,7 @@ func compressJPEG(this js.Value, args []js.Value) interface{} {
 	// Decode the image
 	img, format, err := image.Decode(bytes.NewReader(imageData))
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error decoding image: %v", err))
+		return js.ValueOf(localize("Error decoding image: %v", err))
 	}
 
 	if !silentMode {
@@ -54,7 +175,7 @@ func compressJPEG(this js.Value, args []js.Value) interface{} {
 	var buf bytes.Buffer
 	err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error encoding JPEG: %v", err))
+		return js.ValueOf(localize("Error encoding JPEG: %v", err))
 	}
 
 	// Convert to Uint8Array for JavaScript
@@ -74,7 +195,7 @@ func compressJPEG(this js.Value, args []js.Value) interface{} {
 // compressPNG - Process PNG image
 func compressPNG(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
-		return js.ValueOf("Error: imageData required")
+		return js.ValueOf(localize("Error: imageData required"))
 	}
 
 	// Get image data as Uint8Array
@@ -88,7 +209,7 @@ func compressPNG(this js.Value, args []js.Value) interface{} {
 	// Decode the image
 	img, format, err := image.Decode(bytes.NewReader(imageData))
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error decoding image: %v", err))
+		return js.ValueOf(localize("Error decoding image: %v", err))
 	}
 
 	if !silentMode {
@@ -99,7 +220,7 @@ func compressPNG(this js.Value, args []js.Value) interface{} {
 	var buf bytes.Buffer
 	err = png.Encode(&buf, img)
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error encoding PNG: %v", err))
+		return js.ValueOf(localize("Error encoding PNG: %v", err))
 	}
 
 	// Convert to Uint8Array for JavaScript
@@ -146,19 +267,168 @@ func simpleResize(src image.Image, newWidth, newHeight int) image.Image {
 	return dst
 }
 
+// srgbToLinearTable - Linear light value for every 8-bit sRGB channel value
+var srgbToLinearTable = func() [256]float64 {
+	var table [256]float64
+	for i := range table {
+		table[i] = srgbToLinear(float64(i) / 255)
+	}
+	return table
+}()
+
+// srgbToLinear - Remove the sRGB transfer curve from a channel in [0, 1]
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB - Apply the sRGB transfer curve to a linear channel in [0, 1]
+func linearToSRGB(c float64) float64 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// linearToByte - Convert a linear channel back to an 8-bit sRGB value
+func linearToByte(c float64) uint8 {
+	if c <= 0 {
+		return 0
+	}
+	if c >= 1 {
+		return 255
+	}
+	return uint8(math.Round(linearToSRGB(c) * 255))
+}
+
+// toNRGBA - Copy any decoded image into a non-premultiplied RGBA buffer
+func toNRGBA(src image.Image) *image.NRGBA {
+	if nrgba, ok := src.(*image.NRGBA); ok && nrgba.Bounds().Min == (image.Point{}) {
+		return nrgba
+	}
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return dst
+}
+
+// linearResize - Gamma-correct resize using a separable triangle filter in linear light
+func linearResize(src image.Image, newWidth, newHeight int) image.Image {
+	img := toNRGBA(src)
+	srcWidth, srcHeight := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// Premultiplied linear RGBA, 4 floats per pixel
+	pixels := make([]float64, srcWidth*srcHeight*4)
+	for i := 0; i < srcWidth*srcHeight; i++ {
+		alpha := float64(img.Pix[i*4+3]) / 255
+		pixels[i*4] = srgbToLinearTable[img.Pix[i*4]] * alpha
+		pixels[i*4+1] = srgbToLinearTable[img.Pix[i*4+1]] * alpha
+		pixels[i*4+2] = srgbToLinearTable[img.Pix[i*4+2]] * alpha
+		pixels[i*4+3] = alpha
+	}
+
+	horizontal := resampleAxis(pixels, srcWidth, srcHeight, newWidth, true)
+	vertical := resampleAxis(horizontal, newWidth, srcHeight, newHeight, false)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for i := 0; i < newWidth*newHeight; i++ {
+		alpha := vertical[i*4+3]
+		if alpha <= 0 {
+			continue
+		}
+		dst.Pix[i*4] = linearToByte(vertical[i*4] / alpha)
+		dst.Pix[i*4+1] = linearToByte(vertical[i*4+1] / alpha)
+		dst.Pix[i*4+2] = linearToByte(vertical[i*4+2] / alpha)
+		dst.Pix[i*4+3] = uint8(math.Round(math.Min(alpha, 1) * 255))
+	}
+
+	return dst
+}
+
+// resampleAxis - Resample a premultiplied RGBA float buffer along one axis
+func resampleAxis(pixels []float64, width, height, newSize int, horizontal bool) []float64 {
+	srcSize, other := height, width
+	if horizontal {
+		srcSize, other = width, height
+	}
+
+	scale := float64(srcSize) / float64(newSize)
+	support := math.Max(scale, 1)
+
+	var out []float64
+	if horizontal {
+		out = make([]float64, newSize*height*4)
+	} else {
+		out = make([]float64, width*newSize*4)
+	}
+
+	for d := 0; d < newSize; d++ {
+		center := (float64(d)+0.5)*scale - 0.5
+		start := int(math.Floor(center - support))
+		end := int(math.Ceil(center + support))
+
+		weights := make([]float64, 0, end-start+1)
+		total := 0.0
+		for s := start; s <= end; s++ {
+			w := 1 - math.Abs(float64(s)-center)/support
+			if w < 0 {
+				w = 0
+			}
+			weights = append(weights, w)
+			total += w
+		}
+
+		for o := 0; o < other; o++ {
+			var acc [4]float64
+			for k, w := range weights {
+				if w == 0 {
+					continue
+				}
+				s := start + k
+				if s < 0 {
+					s = 0
+				} else if s >= srcSize {
+					s = srcSize - 1
+				}
+
+				idx := (o*width + s) * 4
+				if !horizontal {
+					idx = (s*width + o) * 4
+				}
+				for c := 0; c < 4; c++ {
+					acc[c] += pixels[idx+c] * w
+				}
+			}
+
+			idx := (o*newSize + d) * 4
+			if !horizontal {
+				idx = (d*width + o) * 4
+			}
+			for c := 0; c < 4; c++ {
+				out[idx+c] = acc[c] / total
+			}
+		}
+	}
+
+	return out
+}
+
 // resizeImage - Resize image to specified dimensions
 func resizeImage(this js.Value, args []js.Value) interface{} {
 	if len(args) < 3 {
-		return js.ValueOf("Error: imageData, width, and height required")
+		return js.ValueOf(localize("Error: imageData, width, and height required"))
 	}
 
 	// Get parameters
 	imageDataArray := args[0]
 	width := int(args[1].Float())
 	height := int(args[2].Float())
+	gammaCorrect := len(args) >= 4 && args[3].Truthy()
 
 	if width <= 0 || height <= 0 {
-		return js.ValueOf("Error: width and height must be positive")
+		return js.ValueOf(localize("Error: width and height must be positive"))
 	}
 
 	// Convert JS Uint8Array to Go []byte
@@ -169,7 +439,7 @@ func resizeImage(this js.Value, args []js.Value) interface{} {
 	// Decode the image
 	img, format, err := image.Decode(bytes.NewReader(imageData))
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error decoding image: %v", err))
+		return js.ValueOf(localize("Error decoding image: %v", err))
 	}
 
 	originalBounds := img.Bounds()
@@ -178,8 +448,13 @@ func resizeImage(this js.Value, args []js.Value) interface{} {
 			format, originalBounds.Dx(), originalBounds.Dy(), width, height)
 	}
 
-	// Resize the image using simple algorithm
-	resizedImg := simpleResize(img, width, height)
+	// Resize the image, averaging in linear light when gamma correction is requested
+	var resizedImg image.Image
+	if gammaCorrect {
+		resizedImg = linearResize(img, width, height)
+	} else {
+		resizedImg = simpleResize(img, width, height)
+	}
 
 	// Encode back to original format
 	var buf bytes.Buffer
@@ -194,7 +469,7 @@ func resizeImage(this js.Value, args []js.Value) interface{} {
 	}
 
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error encoding resized image: %v", err))
+		return js.ValueOf(localize("Error encoding resized image: %v", err))
 	}
 
 	// Convert to Uint8Array for JavaScript
@@ -213,7 +488,7 @@ func resizeImage(this js.Value, args []js.Value) interface{} {
 // convertToWebP - Convert image to optimized format (simulated WebP as JPEG with high compression)
 func convertToWebP(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
-		return js.ValueOf("Error: imageData required")
+		return js.ValueOf(localize("Error: imageData required"))
 	}
 
 	// Get image data as Uint8Array
@@ -225,7 +500,7 @@ func convertToWebP(this js.Value, args []js.Value) interface{} {
 	}
 
 	if quality < 1 || quality > 100 {
-		return js.ValueOf("Error: quality must be between 1 and 100")
+		return js.ValueOf(localize("Error: quality must be between 1 and 100"))
 	}
 
 	// Convert JS Uint8Array to Go []byte
@@ -236,7 +511,7 @@ func convertToWebP(this js.Value, args []js.Value) interface{} {
 	// Decode the image
 	img, format, err := image.Decode(bytes.NewReader(imageData))
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error decoding image: %v", err))
+		return js.ValueOf(localize("Error decoding image: %v", err))
 	}
 
 	if !silentMode {
@@ -247,7 +522,7 @@ func convertToWebP(this js.Value, args []js.Value) interface{} {
 	var buf bytes.Buffer
 	err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error encoding optimized image: %v", err))
+		return js.ValueOf(localize("Error encoding optimized image: %v", err))
 	}
 
 	// Convert to Uint8Array for JavaScript
@@ -267,7 +542,7 @@ func convertToWebP(this js.Value, args []js.Value) interface{} {
 // getImageInfo - Get information about an image
 func getImageInfo(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
-		return js.ValueOf("Error: imageData required")
+		return js.ValueOf(localize("Error: imageData required"))
 	}
 
 	// Get image data as Uint8Array
@@ -281,7 +556,7 @@ func getImageInfo(this js.Value, args []js.Value) interface{} {
 	// Decode the image
 	img, format, err := image.Decode(bytes.NewReader(imageData))
 	if err != nil {
-		return js.ValueOf(fmt.Sprintf("Error decoding image: %v", err))
+		return js.ValueOf(localize("Error decoding image: %v", err))
 	}
 
 	bounds := img.Bounds()
@@ -296,11 +571,1680 @@ func getImageInfo(this js.Value, args []js.Value) interface{} {
 	return jsInfo
 }
 
+// convertColorSpace - Convert color triples between sRGB, linear RGB, HSL, HSV and CIE LAB
+func convertColorSpace(this js.Value, args []js.Value) interface{} {
+	if len(args) < 3 {
+		return js.ValueOf(localize("Error: values, from and to required"))
+	}
+
+	from := strings.ToLower(args[1].String())
+	to := strings.ToLower(args[2].String())
+	for _, space := range []string{from, to} {
+		if !isColorSpace(space) {
+			return js.ValueOf(localize("Error: unsupported color space %q (use srgb, linear, hsl, hsv or lab)", space))
+		}
+	}
+
+	length := args[0].Get("length")
+	if length.IsUndefined() || length.Int() == 0 || length.Int()%3 != 0 {
+		return js.ValueOf(localize("Error: values must be an array of color triples"))
+	}
+
+	count := length.Int()
+	result := make([]interface{}, count)
+	for i := 0; i < count; i += 3 {
+		color := [3]float64{args[0].Index(i).Float(), args[0].Index(i + 1).Float(), args[0].Index(i + 2).Float()}
+		converted := fromLinearRGB(toLinearRGB(color, from), to)
+		for c := 0; c < 3; c++ {
+			result[i+c] = math.Round(converted[c]*10000) / 10000
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Converted %d colors from %s to %s\n", count/3, from, to)
+	}
+
+	return js.ValueOf(result)
+}
+
+// isColorSpace - Report whether a color space name is supported
+func isColorSpace(space string) bool {
+	switch space {
+	case "srgb", "linear", "hsl", "hsv", "lab":
+		return true
+	}
+	return false
+}
+
+// toLinearRGB - Convert a color in the given space to linear RGB in [0, 1]
+func toLinearRGB(c [3]float64, space string) [3]float64 {
+	var srgb [3]float64
+	switch space {
+	case "linear":
+		return c
+	case "srgb":
+		srgb = [3]float64{c[0] / 255, c[1] / 255, c[2] / 255}
+	case "hsl":
+		srgb = hslToSRGB(c[0], c[1]/100, c[2]/100)
+	case "hsv":
+		srgb = hsvToSRGB(c[0], c[1]/100, c[2]/100)
+	case "lab":
+		return labToLinear(c)
+	}
+	return [3]float64{srgbToLinear(srgb[0]), srgbToLinear(srgb[1]), srgbToLinear(srgb[2])}
+}
+
+// fromLinearRGB - Convert a linear RGB color to the given space
+func fromLinearRGB(c [3]float64, space string) [3]float64 {
+	if space == "linear" {
+		return c
+	}
+	if space == "lab" {
+		return linearToLab(c)
+	}
+
+	srgb := [3]float64{linearToSRGB(c[0]), linearToSRGB(c[1]), linearToSRGB(c[2])}
+	switch space {
+	case "hsl":
+		h, s, l := srgbToHSL(srgb)
+		return [3]float64{h, s * 100, l * 100}
+	case "hsv":
+		h, s, v := srgbToHSV(srgb)
+		return [3]float64{h, s * 100, v * 100}
+	}
+	return [3]float64{srgb[0] * 255, srgb[1] * 255, srgb[2] * 255}
+}
+
+// srgbToHSL - Hue in degrees, saturation and lightness in [0, 1]
+func srgbToHSL(c [3]float64) (float64, float64, float64) {
+	maxC := math.Max(c[0], math.Max(c[1], c[2]))
+	minC := math.Min(c[0], math.Min(c[1], c[2]))
+	l := (maxC + minC) / 2
+	delta := maxC - minC
+	if delta == 0 {
+		return 0, 0, l
+	}
+	s := delta / (1 - math.Abs(2*l-1))
+	return hueOf(c, maxC, delta), s, l
+}
+
+// srgbToHSV - Hue in degrees, saturation and value in [0, 1]
+func srgbToHSV(c [3]float64) (float64, float64, float64) {
+	maxC := math.Max(c[0], math.Max(c[1], c[2]))
+	minC := math.Min(c[0], math.Min(c[1], c[2]))
+	delta := maxC - minC
+	if delta == 0 {
+		return 0, 0, maxC
+	}
+	return hueOf(c, maxC, delta), delta / maxC, maxC
+}
+
+// hueOf - Hue angle shared by the HSL and HSV conversions
+func hueOf(c [3]float64, maxC, delta float64) float64 {
+	var h float64
+	switch maxC {
+	case c[0]:
+		h = math.Mod((c[1]-c[2])/delta, 6)
+	case c[1]:
+		h = (c[2]-c[0])/delta + 2
+	default:
+		h = (c[0]-c[1])/delta + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// hslToSRGB - Convert HSL (degrees, [0, 1], [0, 1]) to sRGB in [0, 1]
+func hslToSRGB(h, s, l float64) [3]float64 {
+	chroma := (1 - math.Abs(2*l-1)) * s
+	return hueToSRGB(h, chroma, l-chroma/2)
+}
+
+// hsvToSRGB - Convert HSV (degrees, [0, 1], [0, 1]) to sRGB in [0, 1]
+func hsvToSRGB(h, s, v float64) [3]float64 {
+	chroma := v * s
+	return hueToSRGB(h, chroma, v-chroma)
+}
+
+// hueToSRGB - Place a chroma on the hue hexagon and add the lightness offset
+func hueToSRGB(h, chroma, m float64) [3]float64 {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = chroma, x, 0
+	case h < 120:
+		r, g, b = x, chroma, 0
+	case h < 180:
+		r, g, b = 0, chroma, x
+	case h < 240:
+		r, g, b = 0, x, chroma
+	case h < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	return [3]float64{r + m, g + m, b + m}
+}
+
+// D65 reference white used by the LAB conversions
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// linearToLab - Convert linear sRGB to CIE L*a*b* (D65)
+func linearToLab(c [3]float64) [3]float64 {
+	x := (0.4124564*c[0] + 0.3575761*c[1] + 0.1804375*c[2]) / whiteX
+	y := (0.2126729*c[0] + 0.7151522*c[1] + 0.0721750*c[2]) / whiteY
+	z := (0.0193339*c[0] + 0.1191920*c[1] + 0.9503041*c[2]) / whiteZ
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// labToLinear - Convert CIE L*a*b* (D65) to linear sRGB
+func labToLinear(c [3]float64) [3]float64 {
+	fy := (c[0] + 16) / 116
+	fx := fy + c[1]/500
+	fz := fy - c[2]/200
+
+	x := labFInverse(fx) * whiteX
+	y := labFInverse(fy) * whiteY
+	z := labFInverse(fz) * whiteZ
+
+	return [3]float64{
+		3.2404542*x - 1.5371385*y - 0.4985314*z,
+		-0.9692660*x + 1.8760108*y + 0.0415560*z,
+		0.0556434*x - 0.2040259*y + 1.0572252*z,
+	}
+}
+
+func labF(t float64) float64 {
+	if t > 216.0/24389.0 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27.0*t + 16) / 116
+}
+
+func labFInverse(t float64) float64 {
+	if t*t*t > 216.0/24389.0 {
+		return t * t * t
+	}
+	return (116*t - 16) / (24389.0 / 27.0)
+}
+
+// adjustWhiteBalance - Neutralize or shift the white balance of an image in linear light
+func adjustWhiteBalance(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(localize("Error: imageData required"))
+	}
+
+	// Get image data as Uint8Array
+	imageDataArray := args[0]
+
+	// Convert JS Uint8Array to Go []byte
+	imageDataLen := imageDataArray.Get("length").Int()
+	imageData := make([]byte, imageDataLen)
+	js.CopyBytesToGo(imageData, imageDataArray)
+
+	// Decode the image
+	img, format, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return js.ValueOf(localize("Error decoding image: %v", err))
+	}
+
+	nrgba := toNRGBA(img)
+	pixelCount := len(nrgba.Pix) / 4
+
+	// Options: {temperature, tint} in [-100, 100], or {reference: [r, g, b]} of a pixel that should be neutral.
+	// Without options the gray-world assumption is used.
+	var gains [3]float64
+	mode := "auto"
+	options := js.Undefined()
+	if len(args) >= 2 && args[1].Type() == js.TypeObject {
+		options = args[1]
+	}
+
+	switch {
+	case !options.IsUndefined() && !options.Get("reference").IsUndefined():
+		mode = "reference"
+		ref := options.Get("reference")
+		if ref.Get("length").IsUndefined() || ref.Get("length").Int() != 3 {
+			return js.ValueOf(localize("Error: reference must be an [r, g, b] array"))
+		}
+		var linear [3]float64
+		for c := 0; c < 3; c++ {
+			linear[c] = srgbToLinear(math.Max(0, math.Min(255, ref.Index(c).Float())) / 255)
+		}
+		if linear[0] == 0 || linear[1] == 0 || linear[2] == 0 {
+			return js.ValueOf(localize("Error: reference color must not contain a zero channel"))
+		}
+		gains = [3]float64{linear[1] / linear[0], 1, linear[1] / linear[2]}
+
+	case !options.IsUndefined() && (!options.Get("temperature").IsUndefined() || !options.Get("tint").IsUndefined()):
+		mode = "manual"
+		temperature, tint := 0.0, 0.0
+		if v := options.Get("temperature"); !v.IsUndefined() {
+			temperature = math.Max(-100, math.Min(100, v.Float())) / 100
+		}
+		if v := options.Get("tint"); !v.IsUndefined() {
+			tint = math.Max(-100, math.Min(100, v.Float())) / 100
+		}
+		// Positive temperature warms the image, positive tint shifts it towards magenta
+		gains = [3]float64{1 + 0.3*temperature, 1 - 0.2*tint, 1 - 0.3*temperature}
+
+	default:
+		var sums [3]float64
+		for i := 0; i < pixelCount; i++ {
+			for c := 0; c < 3; c++ {
+				sums[c] += srgbToLinearTable[nrgba.Pix[i*4+c]]
+			}
+		}
+		if sums[0] == 0 || sums[1] == 0 || sums[2] == 0 {
+			return js.ValueOf(localize("Error: image has an empty color channel, cannot estimate white balance"))
+		}
+		gains = [3]float64{sums[1] / sums[0], 1, sums[1] / sums[2]}
+	}
+
+	dst := image.NewNRGBA(nrgba.Bounds())
+	for i := 0; i < pixelCount; i++ {
+		for c := 0; c < 3; c++ {
+			dst.Pix[i*4+c] = linearToByte(srgbToLinearTable[nrgba.Pix[i*4+c]] * gains[c])
+		}
+		dst.Pix[i*4+3] = nrgba.Pix[i*4+3]
+	}
+
+	// Encode back to original format
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	default:
+		err = png.Encode(&buf, dst)
+	}
+
+	if err != nil {
+		return js.ValueOf(localize("Error encoding balanced image: %v", err))
+	}
+
+	// Convert to Uint8Array for JavaScript
+	balancedData := buf.Bytes()
+	result := js.Global().Get("Uint8Array").New(len(balancedData))
+	js.CopyBytesToJS(result, balancedData)
+
+	if !silentMode {
+		fmt.Printf("White balance adjusted: mode=%s, gains=%.3f/%.3f/%.3f\n", mode, gains[0], gains[1], gains[2])
+	}
+
+	return result
+}
+
+// blurhashCharacters - Base83 alphabet used by the BlurHash format
+const blurhashCharacters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// encodeBase83 - Encode a value as a fixed-length base83 string
+func encodeBase83(value, length int) string {
+	result := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		result[i] = blurhashCharacters[value%83]
+		value /= 83
+	}
+	return string(result)
+}
+
+// signPow - Raise the magnitude to exp while keeping the sign
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+// fitWithin - Scale dimensions down so the longest side is at most maxSize
+func fitWithin(width, height, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, int(math.Max(1, math.Round(float64(height)*float64(maxSize)/float64(width))))
+	}
+	return int(math.Max(1, math.Round(float64(width)*float64(maxSize)/float64(height)))), maxSize
+}
+
+// computeBlurhash - Compute a BlurHash placeholder string for an image
+func computeBlurhash(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(localize("Error: imageData required"))
+	}
+
+	componentsX, componentsY := 4, 3
+	if len(args) >= 2 && args[1].Type() == js.TypeNumber {
+		componentsX = int(args[1].Float())
+	}
+	if len(args) >= 3 && args[2].Type() == js.TypeNumber {
+		componentsY = int(args[2].Float())
+	}
+	if componentsX < 1 || componentsX > 9 || componentsY < 1 || componentsY > 9 {
+		return js.ValueOf(localize("Error: components must be between 1 and 9"))
+	}
+
+	// Get image data as Uint8Array
+	imageDataArray := args[0]
+
+	// Convert JS Uint8Array to Go []byte
+	imageDataLen := imageDataArray.Get("length").Int()
+	imageData := make([]byte, imageDataLen)
+	js.CopyBytesToGo(imageData, imageDataArray)
+
+	// Decode the image
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return js.ValueOf(localize("Error decoding image: %v", err))
+	}
+
+	// The hash only keeps a few low frequencies, so a small linear-light thumbnail gives the same result much faster
+	width, height := fitWithin(img.Bounds().Dx(), img.Bounds().Dy(), 64)
+	thumb := toNRGBA(linearResize(img, width, height))
+
+	factors := make([][3]float64, 0, componentsX*componentsY)
+	for j := 0; j < componentsY; j++ {
+		for i := 0; i < componentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+
+			var factor [3]float64
+			for y := 0; y < height; y++ {
+				basisY := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+				for x := 0; x < width; x++ {
+					basis := normalisation * math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) * basisY
+					offset := (y*width + x) * 4
+					for c := 0; c < 3; c++ {
+						factor[c] += basis * srgbToLinearTable[thumb.Pix[offset+c]]
+					}
+				}
+			}
+
+			scale := 1 / float64(width*height)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encodeBase83((componentsX-1)+(componentsY-1)*9, 1))
+
+	maximumValue := 1.0
+	if len(factors) > 1 {
+		actualMaximum := 0.0
+		for _, factor := range factors[1:] {
+			for c := 0; c < 3; c++ {
+				actualMaximum = math.Max(actualMaximum, math.Abs(factor[c]))
+			}
+		}
+		quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(actualMaximum*166-0.5))))
+		maximumValue = float64(quantisedMaximum+1) / 166
+		hash.WriteString(encodeBase83(quantisedMaximum, 1))
+	} else {
+		hash.WriteString(encodeBase83(0, 1))
+	}
+
+	dc := factors[0]
+	hash.WriteString(encodeBase83(int(linearToByte(dc[0]))<<16|int(linearToByte(dc[1]))<<8|int(linearToByte(dc[2])), 4))
+
+	for _, factor := range factors[1:] {
+		value := 0
+		for c := 0; c < 3; c++ {
+			quantised := int(math.Max(0, math.Min(18, math.Floor(signPow(factor[c]/maximumValue, 0.5)*9+9.5))))
+			value = value*19 + quantised
+		}
+		hash.WriteString(encodeBase83(value, 2))
+	}
+
+	if !silentMode {
+		fmt.Printf("BlurHash computed: components=%dx%d, hash=%s\n", componentsX, componentsY, hash.String())
+	}
+
+	return js.ValueOf(hash.String())
+}
+
+// generateLQIP - Generate a tiny low-quality JPEG placeholder as a data URL
+func generateLQIP(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(localize("Error: imageData required"))
+	}
+
+	quality := 40
+	if len(args) >= 2 && args[1].Type() == js.TypeNumber {
+		quality = int(args[1].Float())
+	}
+	if quality < 1 || quality > 100 {
+		return js.ValueOf(localize("Error: quality must be between 1 and 100"))
+	}
+
+	maxSize := 32
+	if len(args) >= 3 && args[2].Type() == js.TypeNumber {
+		maxSize = int(args[2].Float())
+	}
+	if maxSize < 1 || maxSize > 256 {
+		return js.ValueOf(localize("Error: maxSize must be between 1 and 256"))
+	}
+
+	// Get image data as Uint8Array
+	imageDataArray := args[0]
+
+	// Convert JS Uint8Array to Go []byte
+	imageDataLen := imageDataArray.Get("length").Int()
+	imageData := make([]byte, imageDataLen)
+	js.CopyBytesToGo(imageData, imageDataArray)
+
+	// Decode the image
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return js.ValueOf(localize("Error decoding image: %v", err))
+	}
+
+	bounds := img.Bounds()
+	width, height := fitWithin(bounds.Dx(), bounds.Dy(), maxSize)
+	thumb := linearResize(img, width, height)
+
+	// JPEG has no alpha channel, flatten onto white so transparent areas don't turn black
+	flattened := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(flattened, flattened.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flattened, flattened.Bounds(), thumb, image.Point{}, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flattened, &jpeg.Options{Quality: quality}); err != nil {
+		return js.ValueOf(localize("Error encoding placeholder: %v", err))
+	}
+
+	dataURL := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	if !silentMode {
+		fmt.Printf("LQIP generated: %dx%d from %dx%d, %d bytes\n", width, height, bounds.Dx(), bounds.Dy(), buf.Len())
+	}
+
+	// Create JavaScript object directly
+	jsResult := js.Global().Get("Object").New()
+	jsResult.Set("dataURL", js.ValueOf(dataURL))
+	jsResult.Set("width", js.ValueOf(width))
+	jsResult.Set("height", js.ValueOf(height))
+	jsResult.Set("originalWidth", js.ValueOf(bounds.Dx()))
+	jsResult.Set("originalHeight", js.ValueOf(bounds.Dy()))
+	jsResult.Set("size", js.ValueOf(buf.Len()))
+
+	return jsResult
+}
+
+// grayImage - Single channel image with float32 luminance values in [0, 255]
+type grayImage struct {
+	width, height int
+	pix           []float32
+}
+
+// newGrayImage - Allocate a gray image filled with white
+func newGrayImage(width, height int) *grayImage {
+	g := &grayImage{width: width, height: height, pix: make([]float32, width*height)}
+	for i := range g.pix {
+		g.pix[i] = 255
+	}
+	return g
+}
+
+// toGray - Convert a decoded image to luminance, compositing transparent areas onto white
+func toGray(src image.Image) *grayImage {
+	img := toNRGBA(src)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	g := &grayImage{width: width, height: height, pix: make([]float32, width*height)}
+	for i := range g.pix {
+		p := img.Pix[i*4 : i*4+4 : i*4+4]
+		luma := 0.299*float32(p[0]) + 0.587*float32(p[1]) + 0.114*float32(p[2])
+		alpha := float32(p[3]) / 255
+		g.pix[i] = luma*alpha + 255*(1-alpha)
+	}
+	return g
+}
+
+// sample - Bilinear sample at (x, y) in pixel centers coordinates; outside the image is white
+func (g *grayImage) sample(x, y float64) float32 {
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := float32(x-float64(x0)), float32(y-float64(y0))
+	at := func(px, py int) float32 {
+		if px < 0 || py < 0 || px >= g.width || py >= g.height {
+			return 255
+		}
+		return g.pix[py*g.width+px]
+	}
+	top := at(x0, y0)*(1-fx) + at(x0+1, y0)*fx
+	bottom := at(x0, y0+1)*(1-fx) + at(x0+1, y0+1)*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// downscaleGray - Box-average the image so its longest side is at most maxSize; returns the scale factor
+// from the small image back to the original
+func downscaleGray(g *grayImage, maxSize int) (*grayImage, float64) {
+	width, height := fitWithin(g.width, g.height, maxSize)
+	if width == g.width && height == g.height {
+		return g, 1
+	}
+	small := &grayImage{width: width, height: height, pix: make([]float32, width*height)}
+	counts := make([]float32, width*height)
+	for y := 0; y < g.height; y++ {
+		sy := y * height / g.height
+		for x := 0; x < g.width; x++ {
+			i := sy*width + x*width/g.width
+			small.pix[i] += g.pix[y*g.width+x]
+			counts[i]++
+		}
+	}
+	for i := range small.pix {
+		small.pix[i] /= counts[i]
+	}
+	return small, float64(g.width) / float64(width)
+}
+
+// otsuThreshold - Threshold that best separates the histogram into two classes
+func otsuThreshold(g *grayImage) float32 {
+	var histogram [256]float64
+	for _, v := range g.pix {
+		histogram[uint8(math.Min(255, math.Max(0, float64(v))))]++
+	}
+	total, sum := float64(len(g.pix)), 0.0
+	for i, count := range histogram {
+		sum += float64(i) * count
+	}
+
+	best, threshold := -1.0, 128
+	weightBelow, sumBelow := 0.0, 0.0
+	for i, count := range histogram {
+		weightBelow += count
+		sumBelow += float64(i) * count
+		weightAbove := total - weightBelow
+		if weightBelow == 0 || weightAbove == 0 {
+			continue
+		}
+		meanBelow, meanAbove := sumBelow/weightBelow, (sum-sumBelow)/weightAbove
+		variance := weightBelow * weightAbove * (meanBelow - meanAbove) * (meanBelow - meanAbove)
+		if variance > best {
+			best, threshold = variance, i
+		}
+	}
+	return float32(threshold) + 0.5
+}
+
+// detectPageCorners - Find the page as the largest bright region of a small image, returning its corners
+// (top-left, top-right, bottom-right, bottom-left). ok is false when no page stands out from the
+// background or when the page already fills the frame.
+func detectPageCorners(small *grayImage) (corners [4][2]float64, ok bool) {
+	threshold := otsuThreshold(small)
+	width, height := small.width, small.height
+
+	// Label the bright regions (4-connected) and keep the largest
+	labels := make([]int32, width*height)
+	var largest []int
+	stack := []int{}
+	for start := range labels {
+		if labels[start] != 0 || small.pix[start] < threshold {
+			continue
+		}
+		region := []int{}
+		labels[start] = 1
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			region = append(region, i)
+			x, y := i%width, i/width
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= width || n[1] >= height {
+					continue
+				}
+				j := n[1]*width + n[0]
+				if labels[j] == 0 && small.pix[j] >= threshold {
+					labels[j] = 1
+					stack = append(stack, j)
+				}
+			}
+		}
+		if len(region) > len(largest) {
+			largest = region
+		}
+	}
+	if len(largest) < width*height/5 {
+		return corners, false
+	}
+
+	// The corners are the extreme points along the two diagonals
+	minSum, maxSum, minDiff, maxDiff := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, i := range largest {
+		x, y := float64(i%width)+0.5, float64(i/width)+0.5
+		if x+y < minSum {
+			minSum, corners[0] = x+y, [2]float64{x, y}
+		}
+		if x-y > maxDiff {
+			maxDiff, corners[1] = x-y, [2]float64{x, y}
+		}
+		if x+y > maxSum {
+			maxSum, corners[2] = x+y, [2]float64{x, y}
+		}
+		if x-y < minDiff {
+			minDiff, corners[3] = x-y, [2]float64{x, y}
+		}
+	}
+
+	// A quadrilateral close to the frame means the scan is already cropped to the page
+	frame := [4][2]float64{{0, 0}, {float64(width), 0}, {float64(width), float64(height)}, {0, float64(height)}}
+	margin := 0.03 * float64(width+height) / 2
+	cropped := true
+	for i := range corners {
+		if math.Hypot(corners[i][0]-frame[i][0], corners[i][1]-frame[i][1]) > margin {
+			cropped = false
+		}
+	}
+	if cropped || quadArea(corners) < float64(width*height)/5 {
+		return corners, false
+	}
+	return corners, true
+}
+
+// quadArea - Shoelace area of a quadrilateral, positive when its corners are listed clockwise on screen
+func quadArea(q [4][2]float64) float64 {
+	area := 0.0
+	for i := range q {
+		j := (i + 1) % 4
+		area += q[i][0]*q[j][1] - q[j][0]*q[i][1]
+	}
+	return area / 2
+}
+
+// warpPerspective - Map the quadrilateral (top-left, top-right, bottom-right, bottom-left) of src onto a
+// width x height rectangle, with the square to quadrilateral projective mapping
+func warpPerspective(src *grayImage, q [4][2]float64, width, height int) *grayImage {
+	x0, y0, x1, y1, x2, y2, x3, y3 := q[0][0], q[0][1], q[1][0], q[1][1], q[2][0], q[2][1], q[3][0], q[3][1]
+	dx1, dx2, dx3 := x1-x2, x3-x2, x0-x1+x2-x3
+	dy1, dy2, dy3 := y1-y2, y3-y2, y0-y1+y2-y3
+
+	var a, b, c, d, e, f, g, h float64
+	if math.Abs(dx3) < 1e-9 && math.Abs(dy3) < 1e-9 {
+		a, b, c = x1-x0, x2-x1, x0
+		d, e, f = y1-y0, y2-y1, y0
+	} else {
+		det := dx1*dy2 - dx2*dy1
+		g = (dx3*dy2 - dx2*dy3) / det
+		h = (dx1*dy3 - dx3*dy1) / det
+		a, b, c = x1-x0+g*x1, x3-x0+h*x3, x0
+		d, e, f = y1-y0+g*y1, y3-y0+h*y3, y0
+	}
+
+	dst := newGrayImage(width, height)
+	for y := 0; y < height; y++ {
+		v := (float64(y) + 0.5) / float64(height)
+		for x := 0; x < width; x++ {
+			u := (float64(x) + 0.5) / float64(width)
+			w := g*u + h*v + 1
+			dst.pix[y*width+x] = src.sample((a*u+b*v+c)/w-0.5, (d*u+e*v+f)/w-0.5)
+		}
+	}
+	return dst
+}
+
+// estimateSkew - Angle in degrees of the text lines, found as the shear that gives the sharpest horizontal
+// projection profile of the dark pixels
+func estimateSkew(small *grayImage, maxAngle float64) float64 {
+	threshold := otsuThreshold(small)
+	var points [][2]float64
+	for i, v := range small.pix {
+		if v < threshold {
+			points = append(points, [2]float64{float64(i % small.width), float64(i / small.width)})
+		}
+	}
+	if len(points) < 10 || len(points) > len(small.pix)/2 {
+		return 0
+	}
+
+	margin := int(math.Ceil(float64(small.width)*math.Tan(maxAngle*math.Pi/180))) + 1
+	profile := make([]float64, small.height+2*margin)
+	score := func(angle float64) float64 {
+		for i := range profile {
+			profile[i] = 0
+		}
+		slope := math.Tan(angle * math.Pi / 180)
+		for _, p := range points {
+			row := int(math.Round(p[1]-p[0]*slope)) + margin
+			if row >= 0 && row < len(profile) {
+				profile[row]++
+			}
+		}
+		sharpness := 0.0
+		for i := 1; i < len(profile); i++ {
+			delta := profile[i] - profile[i-1]
+			sharpness += delta * delta
+		}
+		return sharpness
+	}
+
+	// Coarse search, then refine around the best angle
+	best, bestScore := 0.0, score(0)
+	for _, step := range []float64{0.5, 0.1, 0.02} {
+		center, span := best, step*5
+		if step == 0.5 {
+			center, span = 0, maxAngle
+		}
+		for angle := center - span; angle <= center+span+1e-9; angle += step {
+			if math.Abs(angle) > maxAngle {
+				continue
+			}
+			if s := score(angle); s > bestScore {
+				best, bestScore = angle, s
+			}
+		}
+	}
+	return best
+}
+
+// rotateGray - Rotate the image by angle degrees (clockwise on screen) around its center, filling with white
+func rotateGray(src *grayImage, angle float64) *grayImage {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	cx, cy := float64(src.width-1)/2, float64(src.height-1)/2
+	dst := newGrayImage(src.width, src.height)
+	for y := 0; y < src.height; y++ {
+		dy := float64(y) - cy
+		for x := 0; x < src.width; x++ {
+			dx := float64(x) - cx
+			dst.pix[y*src.width+x] = src.sample(cx+dx*cos+dy*sin, cy-dx*sin+dy*cos)
+		}
+	}
+	return dst
+}
+
+// sauvolaThreshold - Adaptive binarization: a pixel is ink when darker than m * (1 + k * (s / 128 - 1)),
+// with m and s the mean and standard deviation of the window around it. Returns true for ink.
+func sauvolaThreshold(g *grayImage, window int, k float64) []bool {
+	width, height := g.width, g.height
+	radius := window / 2
+
+	// Separable box sums of values and squares, accumulated in float64 so the running sums stay exact
+	rowSum := make([]float32, width*height)
+	rowSquares := make([]float32, width*height)
+	for y := 0; y < height; y++ {
+		row := g.pix[y*width : (y+1)*width]
+		sum, squares := 0.0, 0.0
+		for x := -radius; x < width+radius; x++ {
+			if in := x + radius; in < width {
+				v := float64(row[in])
+				sum, squares = sum+v, squares+v*v
+			}
+			if out := x - radius - 1; out >= 0 {
+				v := float64(row[out])
+				sum, squares = sum-v, squares-v*v
+			}
+			if x >= 0 && x < width {
+				rowSum[y*width+x], rowSquares[y*width+x] = float32(sum), float32(squares)
+			}
+		}
+	}
+
+	ink := make([]bool, width*height)
+	sums, squares := make([]float64, width), make([]float64, width)
+	for y := -radius; y < height+radius; y++ {
+		if in := y + radius; in < height {
+			for x := 0; x < width; x++ {
+				sums[x] += float64(rowSum[in*width+x])
+				squares[x] += float64(rowSquares[in*width+x])
+			}
+		}
+		if out := y - radius - 1; out >= 0 {
+			for x := 0; x < width; x++ {
+				sums[x] -= float64(rowSum[out*width+x])
+				squares[x] -= float64(rowSquares[out*width+x])
+			}
+		}
+		if y < 0 || y >= height {
+			continue
+		}
+		rows := float64(min(y+radius, height-1) - max(y-radius, 0) + 1)
+		for x := 0; x < width; x++ {
+			n := rows * float64(min(x+radius, width-1)-max(x-radius, 0)+1)
+			mean := sums[x] / n
+			deviation := math.Sqrt(math.Max(0, squares[x]/n-mean*mean))
+			ink[y*width+x] = float64(g.pix[y*width+x]) < mean*(1+k*(deviation/128-1))
+		}
+	}
+	return ink
+}
+
+// despeckle - Clear the 8-connected ink specks of at most maxArea pixels; returns how many were removed
+func despeckle(ink []bool, width, height, maxArea int) int {
+	visited := make([]bool, len(ink))
+	removed := 0
+	var component, stack []int
+	for start, isInk := range ink {
+		if !isInk || visited[start] {
+			continue
+		}
+		component = component[:0]
+		visited[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, i)
+			x, y := i%width, i/width
+			for ny := max(y-1, 0); ny <= min(y+1, height-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, width-1); nx++ {
+					j := ny*width + nx
+					if ink[j] && !visited[j] {
+						visited[j] = true
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+		if len(component) <= maxArea {
+			for _, i := range component {
+				ink[i] = false
+			}
+			removed++
+		}
+	}
+	return removed
+}
+
+// scanCleanup - Turn a photo or scan of a document into a clean black and white page: perspective
+// correction from the detected page corners, deskew, adaptive thresholding and despeckle
+func scanCleanup(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(localize("Error: imageData required"))
+	}
+
+	// Options: {perspective, deskew, maxSkew, windowSize, sensitivity, despeckle}
+	perspective, deskew := true, true
+	maxSkew, sensitivity := 15.0, 0.2
+	windowSize, speckleSize := 0, -1
+	if len(args) >= 2 && args[1].Type() == js.TypeObject {
+		options := args[1]
+		if v := options.Get("perspective"); v.Type() == js.TypeBoolean {
+			perspective = v.Bool()
+		}
+		if v := options.Get("deskew"); v.Type() == js.TypeBoolean {
+			deskew = v.Bool()
+		}
+		if v := options.Get("maxSkew"); v.Type() == js.TypeNumber {
+			maxSkew = v.Float()
+		}
+		if v := options.Get("windowSize"); v.Type() == js.TypeNumber {
+			windowSize = int(v.Float())
+		}
+		if v := options.Get("sensitivity"); v.Type() == js.TypeNumber {
+			sensitivity = v.Float()
+		}
+		if v := options.Get("despeckle"); v.Type() == js.TypeNumber {
+			speckleSize = int(v.Float())
+		}
+	}
+	if maxSkew < 0 || maxSkew > 45 {
+		return js.ValueOf(localize("Error: maxSkew must be between 0 and 45 degrees"))
+	}
+	if sensitivity < 0 || sensitivity > 1 {
+		return js.ValueOf(localize("Error: sensitivity must be between 0 and 1"))
+	}
+
+	// Get image data as Uint8Array
+	imageDataArray := args[0]
+
+	// Convert JS Uint8Array to Go []byte
+	imageDataLen := imageDataArray.Get("length").Int()
+	imageData := make([]byte, imageDataLen)
+	js.CopyBytesToGo(imageData, imageDataArray)
+
+	// Decode the image
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return js.ValueOf(localize("Error decoding image: %v", err))
+	}
+	page := toGray(img)
+	bounds := img.Bounds()
+
+	// Perspective: the page corners are found on a small copy, then the page is unwarped at full resolution
+	var corners []interface{}
+	if perspective {
+		small, scale := downscaleGray(page, 400)
+		if quad, ok := detectPageCorners(small); ok {
+			for i := range quad {
+				quad[i][0] *= scale
+				quad[i][1] *= scale
+				corners = append(corners, quad[i][0], quad[i][1])
+			}
+			width := math.Max(math.Hypot(quad[1][0]-quad[0][0], quad[1][1]-quad[0][1]), math.Hypot(quad[2][0]-quad[3][0], quad[2][1]-quad[3][1]))
+			height := math.Max(math.Hypot(quad[3][0]-quad[0][0], quad[3][1]-quad[0][1]), math.Hypot(quad[2][0]-quad[1][0], quad[2][1]-quad[1][1]))
+			page = warpPerspective(page, quad, int(math.Round(width)), int(math.Round(height)))
+		}
+	}
+
+	// Deskew: the text line angle is measured on a copy small enough to search quickly
+	skew := 0.0
+	if deskew && maxSkew > 0 {
+		small, _ := downscaleGray(page, 1000)
+		skew = estimateSkew(small, maxSkew)
+		if math.Abs(skew) >= 0.05 {
+			page = rotateGray(page, -skew)
+		}
+	}
+
+	// Window and speck sizes follow the resolution: about 1/40th of the short side, and specks well under
+	// the size of a dot at that resolution
+	shortSide := min(page.width, page.height)
+	if windowSize <= 0 {
+		windowSize = max(15, shortSide/40)
+	}
+	if speckleSize < 0 {
+		speckleSize = max(2, shortSide*shortSide/1000000)
+	}
+	ink := sauvolaThreshold(page, windowSize|1, sensitivity)
+	specks := despeckle(ink, page.width, page.height, speckleSize)
+
+	// A two color palette makes the PNG encoder write 1 bit per pixel
+	bw := image.NewPaletted(image.Rect(0, 0, page.width, page.height), color.Palette{color.Black, color.White})
+	for i, isInk := range ink {
+		if !isInk {
+			bw.Pix[i] = 1
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, bw); err != nil {
+		return js.ValueOf(localize("Error encoding cleaned page: %v", err))
+	}
+
+	cleanedData := buf.Bytes()
+	data := js.Global().Get("Uint8Array").New(len(cleanedData))
+	js.CopyBytesToJS(data, cleanedData)
+
+	if !silentMode {
+		fmt.Printf("Scan cleaned: %dx%d to %dx%d, perspective=%t, skew=%.2f°, %d specks removed, %d bytes\n",
+			bounds.Dx(), bounds.Dy(), page.width, page.height, corners != nil, skew, specks, len(cleanedData))
+	}
+
+	result := map[string]interface{}{
+		"data":                 data,
+		"format":               "png",
+		"width":                page.width,
+		"height":               page.height,
+		"originalWidth":        bounds.Dx(),
+		"originalHeight":       bounds.Dy(),
+		"corners":              nil,
+		"perspectiveCorrected": corners != nil,
+		"skewAngle":            skew,
+		"specksRemoved":        specks,
+		"size":                 len(cleanedData),
+	}
+	if corners != nil {
+		result["corners"] = corners
+	}
+	return js.ValueOf(result)
+}
+
+// maxSafePixels - Above this many pixels an upload is reported (and refused by sanitizeImage) as a
+// potential decompression bomb
+const maxSafePixels = 50_000_000
+
+// imageFinding - One observation of inspectImage; severity is info, warning or danger
+type imageFinding struct {
+	kind     string
+	severity string
+	message  string
+	offset   int
+}
+
+// imageRegion - A byte range of the file and what it holds, to tell where a signature was found
+type imageRegion struct {
+	start, end int
+	name       string
+}
+
+// imageReport - What inspectImage learned from the container structure of a JPEG or PNG file
+type imageReport struct {
+	exif, xmp, icc, iptc bool
+	gps                  []float64
+	orientation          int
+	comments             []string
+	textKeys             []string
+	trailing             int
+	regions              []imageRegion
+	findings             []imageFinding
+}
+
+// add - Record a finding
+func (r *imageReport) add(kind, severity string, offset int, message string) {
+	r.findings = append(r.findings, imageFinding{kind: kind, severity: severity, message: message, offset: offset})
+}
+
+// payloadSignatures - Byte patterns of content that has no business inside an image: scripts and markup
+// run by browsers, server-side code, and archives or documents that make the file a polyglot
+var payloadSignatures = []struct {
+	pattern string
+	name    string
+}{
+	{"<script", "HTML script"},
+	{"<html", "HTML document"},
+	{"<!doctype html", "HTML document"},
+	{"<svg", "SVG document"},
+	{"<iframe", "HTML frame"},
+	{"javascript:", "JavaScript URL"},
+	{"<?php", "PHP code"},
+	{"pk\x03\x04", "ZIP archive"},
+	{"pk\x05\x06", "ZIP archive"},
+	{"%pdf-", "PDF document"},
+	{"\x7felf", "ELF executable"},
+	{"rar!\x1a\x07", "RAR archive"},
+}
+
+// inspectContainer - Walk the segments of a JPEG or the chunks of a PNG, then look for payloads
+func inspectContainer(data []byte, format string) *imageReport {
+	report := &imageReport{orientation: 1}
+	end := len(data)
+	switch format {
+	case "jpeg":
+		end = inspectJPEG(data, report)
+	case "png":
+		end = inspectPNG(data, report)
+	}
+
+	if end < len(data) {
+		report.trailing = len(data) - end
+		report.regions = append(report.regions, imageRegion{start: end, end: len(data), name: "trailing data"})
+		report.add("trailing-data", "warning", end, localize("%d bytes after the end of the image", report.trailing))
+		if bytes.HasPrefix(data[end:], []byte("MZ")) {
+			report.add("payload", "danger", end, localize("%s signature found in %s", "Windows executable", "trailing data"))
+		}
+		if bytes.HasPrefix(data[end:], []byte("#!")) {
+			report.add("payload", "danger", end, localize("%s signature found in %s", "shell script", "trailing data"))
+		}
+	}
+
+	// Signatures are searched case-insensitively over the whole file; a match is attributed to the
+	// metadata or trailing region that contains it, or else to the image data
+	lower := make([]byte, len(data))
+	for i, b := range data {
+		if b >= 'A' && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		lower[i] = b
+	}
+	for _, signature := range payloadSignatures {
+		from := 0
+		for {
+			i := bytes.Index(lower[from:], []byte(signature.pattern))
+			if i < 0 {
+				break
+			}
+			offset := from + i
+			where := "image data"
+			for _, region := range report.regions {
+				if offset >= region.start && offset < region.end {
+					where = region.name
+				}
+			}
+			report.add("payload", "danger", offset, localize("%s signature found in %s", signature.name, where))
+			from = offset + len(signature.pattern)
+		}
+	}
+	return report
+}
+
+// inspectJPEG - Read the marker segments up to EOI, returning the offset just after it
+func inspectJPEG(data []byte, report *imageReport) int {
+	pos := 2
+	for pos+2 <= len(data) {
+		if data[pos] != 0xFF {
+			report.add("corrupt", "warning", pos, localize("malformed segment structure"))
+			return len(data)
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			pos++
+			continue
+		case marker == 0xD9:
+			return pos + 2
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			break
+		}
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		if length < 2 || pos+2+length > len(data) {
+			report.add("corrupt", "warning", pos, localize("truncated segment"))
+			return len(data)
+		}
+		payload := data[pos+4 : pos+2+length]
+		name := ""
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")):
+			name = "EXIF"
+			report.exif = true
+			parseEXIF(payload[6:], report)
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte("http://ns.adobe.com/xap/1.0/\x00")):
+			name = "XMP"
+			report.xmp = true
+		case marker == 0xE2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")):
+			name = "ICC profile"
+			report.icc = true
+		case marker == 0xED:
+			name = "IPTC"
+			report.iptc = true
+		case marker == 0xFE:
+			name = "comment"
+			report.comments = append(report.comments, string(payload))
+		case marker >= 0xE0 && marker <= 0xEF && marker != 0xE0:
+			name = fmt.Sprintf("APP%d", marker-0xE0)
+		}
+		if name != "" {
+			report.regions = append(report.regions, imageRegion{start: pos, end: pos + 2 + length, name: name})
+		}
+		pos += 2 + length
+
+		// Entropy-coded data follows the start of scan: skip to the next marker that is neither byte
+		// stuffing (FF00) nor a restart marker
+		if marker == 0xDA {
+			for pos+1 < len(data) && !(data[pos] == 0xFF && data[pos+1] != 0x00 && (data[pos+1] < 0xD0 || data[pos+1] > 0xD7)) {
+				pos++
+			}
+		}
+	}
+	report.add("corrupt", "warning", pos, localize("missing end of image marker"))
+	return len(data)
+}
+
+// inspectPNG - Read the chunks up to IEND, returning the offset just after it
+func inspectPNG(data []byte, report *imageReport) int {
+	pos := 8
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		if length > len(data)-pos-12 {
+			report.add("corrupt", "warning", pos, localize("truncated segment"))
+			return len(data)
+		}
+		chunk := data[pos+8 : pos+8+length]
+		if crc32.ChecksumIEEE(data[pos+4:pos+8+length]) != binary.BigEndian.Uint32(data[pos+8+length:]) {
+			report.add("corrupt", "warning", pos, localize("checksum mismatch in %s chunk", kind))
+		}
+
+		name := ""
+		switch kind {
+		case "IEND":
+			return pos + 12 + length
+		case "eXIf":
+			name = "EXIF"
+			report.exif = true
+			parseEXIF(chunk, report)
+		case "iCCP":
+			name = "ICC profile"
+			report.icc = true
+		case "tEXt", "zTXt", "iTXt":
+			name = "text chunk"
+			keyword := chunk
+			if i := bytes.IndexByte(chunk, 0); i >= 0 {
+				keyword = chunk[:i]
+			}
+			if string(keyword) == "XML:com.adobe.xmp" {
+				name = "XMP"
+				report.xmp = true
+			} else {
+				report.textKeys = append(report.textKeys, string(keyword))
+			}
+		case "IHDR", "PLTE", "IDAT", "tRNS", "gAMA", "cHRM", "sRGB", "sBIT", "bKGD", "hIST", "pHYs", "sPLT", "tIME", "acTL", "fcTL", "fdAT":
+		default:
+			name = kind + " chunk"
+			report.add("unknown-chunk", "warning", pos, localize("unknown %s chunk of %d bytes", kind, length))
+		}
+		if name != "" {
+			report.regions = append(report.regions, imageRegion{start: pos, end: pos + 12 + length, name: name})
+		}
+		pos += 12 + length
+	}
+	report.add("corrupt", "warning", pos, localize("missing end of image marker"))
+	return len(data)
+}
+
+// parseEXIF - Read the orientation and GPS position from a TIFF structured EXIF block
+func parseEXIF(tiff []byte, report *imageReport) {
+	if len(tiff) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	// entries returns the tag -> (type, count, value or offset field) of the IFD at offset
+	type entry struct {
+		kind, count uint32
+		field       []byte
+	}
+	entries := func(offset uint32) map[uint16]entry {
+		result := map[uint16]entry{}
+		if int(offset)+2 > len(tiff) {
+			return result
+		}
+		count := int(order.Uint16(tiff[offset:]))
+		for i := 0; i < count; i++ {
+			at := int(offset) + 2 + i*12
+			if at+12 > len(tiff) {
+				break
+			}
+			result[order.Uint16(tiff[at:])] = entry{
+				kind:  uint32(order.Uint16(tiff[at+2:])),
+				count: order.Uint32(tiff[at+4:]),
+				field: tiff[at+8 : at+12],
+			}
+		}
+		return result
+	}
+	rationals := func(e entry) []float64 {
+		offset := int(order.Uint32(e.field))
+		if e.kind != 5 || e.count != 3 || offset+24 > len(tiff) {
+			return nil
+		}
+		values := make([]float64, 3)
+		for i := range values {
+			numerator, denominator := order.Uint32(tiff[offset+i*8:]), order.Uint32(tiff[offset+i*8+4:])
+			if denominator == 0 {
+				return nil
+			}
+			values[i] = float64(numerator) / float64(denominator)
+		}
+		return values
+	}
+
+	ifd0 := entries(order.Uint32(tiff[4:]))
+	if e, ok := ifd0[0x0112]; ok && e.kind == 3 {
+		report.orientation = int(order.Uint16(e.field))
+	}
+	pointer, ok := ifd0[0x8825]
+	if !ok {
+		return
+	}
+	gps := entries(order.Uint32(pointer.field))
+	latitude, longitude := rationals(gps[2]), rationals(gps[4])
+	if latitude == nil || longitude == nil {
+		if len(gps) > 0 {
+			report.gps = []float64{}
+		}
+		return
+	}
+	lat := latitude[0] + latitude[1]/60 + latitude[2]/3600
+	lon := longitude[0] + longitude[1]/60 + longitude[2]/3600
+	if gps[1].field != nil && gps[1].field[0] == 'S' {
+		lat = -lat
+	}
+	if gps[3].field != nil && gps[3].field[0] == 'W' {
+		lon = -lon
+	}
+	report.gps = []float64{lat, lon}
+}
+
+// readImageBytes - Copy the imageData argument out of JavaScript
+func readImageBytes(value js.Value) []byte {
+	imageData := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(imageData, value)
+	return imageData
+}
+
+// inspectImage - Report privacy-sensitive metadata, trailing bytes and embedded payloads of an upload
+func inspectImage(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(localize("Error: imageData required"))
+	}
+
+	imageData := readImageBytes(args[0])
+	config, format, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return js.ValueOf(localize("Error decoding image: %v", err))
+	}
+
+	report := inspectContainer(imageData, format)
+	if report.exif {
+		report.add("exif", "info", 0, localize("EXIF metadata (camera, dates, settings)"))
+	}
+	if report.gps != nil {
+		report.add("gps", "warning", 0, localize("EXIF GPS data reveals where the picture was taken"))
+	}
+	if report.xmp {
+		report.add("xmp", "info", 0, localize("XMP metadata (editing history, author, tools)"))
+	}
+	if report.iptc {
+		report.add("iptc", "info", 0, localize("IPTC metadata (captions, author, keywords)"))
+	}
+	if report.icc {
+		report.add("icc", "info", 0, localize("embedded ICC color profile"))
+	}
+	if len(report.comments) > 0 || len(report.textKeys) > 0 {
+		report.add("text", "info", 0, localize("%d comments or text chunks", len(report.comments)+len(report.textKeys)))
+	}
+	if pixels := config.Width * config.Height; pixels > maxSafePixels {
+		report.add("dimensions", "warning", 0, localize("%dx%d pixels, a potential decompression bomb", config.Width, config.Height))
+	}
+	// Findings are listed by offset, most severe first at the same place
+	rank := map[string]int{"danger": 0, "warning": 1, "info": 2}
+	sort.SliceStable(report.findings, func(i, j int) bool {
+		if report.findings[i].offset != report.findings[j].offset {
+			return report.findings[i].offset < report.findings[j].offset
+		}
+		return rank[report.findings[i].severity] < rank[report.findings[j].severity]
+	})
+
+	safe := true
+	findings := make([]interface{}, len(report.findings))
+	for i, f := range report.findings {
+		safe = safe && f.severity == "info"
+		findings[i] = map[string]interface{}{
+			"type":     f.kind,
+			"severity": f.severity,
+			"message":  f.message,
+			"offset":   f.offset,
+		}
+	}
+	var gps interface{}
+	if len(report.gps) == 2 {
+		gps = map[string]interface{}{"latitude": report.gps[0], "longitude": report.gps[1]}
+	} else if report.gps != nil {
+		gps = map[string]interface{}{}
+	}
+	comments := make([]interface{}, len(report.comments))
+	for i, c := range report.comments {
+		comments[i] = c
+	}
+	textKeys := make([]interface{}, len(report.textKeys))
+	for i, k := range report.textKeys {
+		textKeys[i] = k
+	}
+
+	if !silentMode {
+		fmt.Printf("Image inspected: format=%s, %d findings, safe=%t\n", format, len(findings), safe)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"format":        format,
+		"width":         config.Width,
+		"height":        config.Height,
+		"size":          len(imageData),
+		"safe":          safe,
+		"findings":      findings,
+		"trailingBytes": report.trailing,
+		"metadata": map[string]interface{}{
+			"exif":        report.exif,
+			"gps":         gps,
+			"orientation": report.orientation,
+			"xmp":         report.xmp,
+			"iptc":        report.iptc,
+			"icc":         report.icc,
+			"comments":    comments,
+			"textChunks":  textKeys,
+		},
+	})
+}
+
+// applyOrientation - Rotate or flip the pixels as the EXIF orientation tag asks viewers to, so the
+// picture still displays upright once the tag is stripped
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	img := toNRGBA(src)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	outWidth, outHeight := width, height
+	if orientation >= 5 {
+		outWidth, outHeight = height, width
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, outWidth, outHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			}
+			copy(dst.Pix[(dy*outWidth+dx)*4:(dy*outWidth+dx)*4+4], img.Pix[(y*width+x)*4:(y*width+x)*4+4])
+		}
+	}
+	return dst
+}
+
+// sanitizeImage - Re-encode the decoded pixels into a clean canonical file, without metadata, trailing
+// bytes or anything else the original container carried
+func sanitizeImage(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(localize("Error: imageData required"))
+	}
+
+	// Options: {format: "jpeg" | "png" (default: same as the input), quality (JPEG, default 92), maxPixels}
+	outputFormat, quality, maxPixels := "", 92, maxSafePixels
+	if len(args) >= 2 && args[1].Type() == js.TypeObject {
+		options := args[1]
+		if v := options.Get("format"); v.Type() == js.TypeString {
+			outputFormat = strings.ToLower(v.String())
+		}
+		if v := options.Get("quality"); v.Type() == js.TypeNumber {
+			quality = int(v.Float())
+		}
+		if v := options.Get("maxPixels"); v.Type() == js.TypeNumber {
+			maxPixels = int(v.Float())
+		}
+	}
+	if quality < 1 || quality > 100 {
+		return js.ValueOf(localize("Error: quality must be between 1 and 100"))
+	}
+	if outputFormat == "jpg" {
+		outputFormat = "jpeg"
+	}
+	if outputFormat != "" && outputFormat != "jpeg" && outputFormat != "png" {
+		return js.ValueOf(localize("Error: unsupported output format %q (use jpeg or png)", outputFormat))
+	}
+
+	imageData := readImageBytes(args[0])
+
+	// Check the dimensions before allocating the pixels
+	config, format, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return js.ValueOf(localize("Error decoding image: %v", err))
+	}
+	if config.Width*config.Height > maxPixels {
+		return js.ValueOf(localize("Error: image is %dx%d, over the limit of %d pixels", config.Width, config.Height, maxPixels))
+	}
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return js.ValueOf(localize("Error decoding image: %v", err))
+	}
+
+	report := inspectContainer(imageData, format)
+	img = applyOrientation(img, report.orientation)
+	if outputFormat == "" {
+		outputFormat = format
+	}
+
+	var buf bytes.Buffer
+	switch outputFormat {
+	case "jpeg":
+		// JPEG has no alpha channel, flatten onto white so transparent areas don't turn black
+		bounds := img.Bounds()
+		flattened := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(flattened, flattened.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(flattened, flattened.Bounds(), img, bounds.Min, draw.Over)
+		err = jpeg.Encode(&buf, flattened, &jpeg.Options{Quality: quality})
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return js.ValueOf(localize("Error encoding sanitized image: %v", err))
+	}
+
+	removed := []interface{}{}
+	for _, item := range []struct {
+		present bool
+		name    string
+	}{
+		{report.exif, "exif"}, {report.gps != nil, "gps"}, {report.xmp, "xmp"}, {report.iptc, "iptc"},
+		{report.icc, "icc"}, {len(report.comments) > 0, "comments"}, {len(report.textKeys) > 0, "text"},
+		{report.trailing > 0, "trailing-data"},
+	} {
+		if item.present {
+			removed = append(removed, item.name)
+		}
+	}
+
+	sanitizedData := buf.Bytes()
+	data := js.Global().Get("Uint8Array").New(len(sanitizedData))
+	js.CopyBytesToJS(data, sanitizedData)
+
+	if !silentMode {
+		fmt.Printf("Image sanitized: %s to %s, %d to %d bytes, removed %v\n", format, outputFormat, len(imageData), len(sanitizedData), removed)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"data":         data,
+		"format":       outputFormat,
+		"width":        img.Bounds().Dx(),
+		"height":       img.Bounds().Dy(),
+		"size":         len(sanitizedData),
+		"originalSize": len(imageData),
+		"orientation":  report.orientation,
+		"removed":      removed,
+	})
+}
+
+// Build metadata, injected by wasm-manager build through -ldflags -X
+var (
+	moduleVersion = "0.2.1"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"jpeg",
+	"png",
+	"webp",
+	"resize",
+	"gamma-correct-resize",
+	"color-spaces",
+	"white-balance",
+	"blurhash",
+	"lqip",
+	"scan-cleanup",
+	"upload-inspection",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{}))
+}
+
+// releaseResources - Return freed memory to the runtime; image-wasm keeps no cached state between calls
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	advertised := getAvailableFunctions(this, nil).(js.Value)
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "image-wasm",
+		"version":         moduleVersion,
+		"description":     "Image processing, compression and format conversion module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
 // getAvailableFunctions - Get list of available functions
 func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 	functions := []interface{}{
 		"compressJPEG", "compressPNG", "convertToWebP", "resizeImage",
-		"getImageInfo", "getAvailableFunctions", "setSilentMode",
+		"getImageInfo", "convertColorSpace", "adjustWhiteBalance",
+		"computeBlurhash", "generateLQIP", "scanCleanup",
+		"inspectImage", "sanitizeImage",
+		"getAvailableFunctions", "setSilentMode", "setLocale", "getModuleInfo",
+		"getMemoryStats", "releaseResources",
 	}
 	return js.ValueOf(functions)
 }
@@ -314,13 +2258,24 @@ func main() {
 	js.Global().Set("convertToWebP", js.FuncOf(convertToWebP))
 	js.Global().Set("resizeImage", js.FuncOf(resizeImage))
 	js.Global().Set("getImageInfo", js.FuncOf(getImageInfo))
+	js.Global().Set("convertColorSpace", js.FuncOf(convertColorSpace))
+	js.Global().Set("adjustWhiteBalance", js.FuncOf(adjustWhiteBalance))
+	js.Global().Set("computeBlurhash", js.FuncOf(computeBlurhash))
+	js.Global().Set("generateLQIP", js.FuncOf(generateLQIP))
+	js.Global().Set("scanCleanup", js.FuncOf(scanCleanup))
+	js.Global().Set("inspectImage", js.FuncOf(inspectImage))
+	js.Global().Set("sanitizeImage", js.FuncOf(sanitizeImage))
 	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
 	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
 
 	// Ready signal for GoWM
 	js.Global().Set("__gowm_ready", js.ValueOf(true))
 
-	fmt.Println("Go WASM Image Processor ready! Available functions: compressJPEG, compressPNG, convertToWebP, resizeImage, getImageInfo")
+	fmt.Println("Go WASM Image Processor ready! Available functions: compressJPEG, compressPNG, convertToWebP, resizeImage, getImageInfo, convertColorSpace, adjustWhiteBalance, computeBlurhash, generateLQIP, scanCleanup, inspectImage, sanitizeImage")
 
 	// Keep the program alive
 	select {}
@@ -3,11 +3,13 @@ package builder
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -125,6 +127,7 @@ func (b *Builder) buildModule(module string) *BuildResult {
 	result := &BuildResult{
 		Module: module,
 	}
+	cfg := b.config.ForModule(module)
 
 	// Clean first if requested
 	if b.config.Clean {
@@ -158,7 +161,7 @@ func (b *Builder) buildModule(module string) *BuildResult {
 	}
 
 	// Optimize if enabled
-	if b.config.Optimize {
+	if cfg.Optimize {
 		if err := b.optimizeWasm(wasmPath); err != nil {
 			if b.config.Verbose {
 				fmt.Printf("⚠️ Optimization failed for %s: %v\n", module, err)
@@ -172,7 +175,7 @@ func (b *Builder) buildModule(module string) *BuildResult {
 	}
 
 	// Compress if enabled
-	if b.config.Compress {
+	if cfg.Compress {
 		if err := b.compressWasm(wasmPath); err != nil {
 			if b.config.Verbose {
 				fmt.Printf("⚠️ Compression failed for %s: %v\n", module, err)
@@ -187,7 +190,7 @@ func (b *Builder) buildModule(module string) *BuildResult {
 	}
 
 	// Generate integrity hash if enabled
-	if b.config.GenerateIntegrity {
+	if cfg.GenerateIntegrity {
 		integrity, err := b.generateIntegrity(wasmPath)
 		if err != nil {
 			if b.config.Verbose {
@@ -198,6 +201,14 @@ func (b *Builder) buildModule(module string) *BuildResult {
 		}
 	}
 
+	// Emit the JS loader and its Web Worker host if enabled. They are a convenience for
+	// consumers of main.wasm, so a failure does not fail the build.
+	if cfg.Wrapper {
+		if err := b.generateWrapper(modulePath, result.Integrity); err != nil {
+			fmt.Printf("⚠️ Wrapper generation failed for %s: %v\n", module, err)
+		}
+	}
+
 	result.Success = true
 	result.BuildTime = time.Since(startTime)
 
@@ -206,8 +217,16 @@ func (b *Builder) buildModule(module string) *BuildResult {
 
 // compileWasm compiles Go source to WASM
 func (b *Builder) compileWasm(modulePath, outputPath string) error {
+	ldflags := "-s -w -buildid="
+	if version := moduleVersion(modulePath); version != "" {
+		ldflags += " -X main.moduleVersion=" + version
+	}
+	if hash, err := sourceHash(modulePath); err == nil {
+		ldflags += " -X main.buildHash=" + hash
+	}
+
 	cmd := exec.Command("go", "build",
-		"-ldflags", "-s -w -buildid=",
+		"-ldflags", ldflags,
 		"-trimpath",
 		"-buildmode=default",
 		"-tags", "netgo,osusergo",
@@ -237,6 +256,32 @@ func (b *Builder) compileWasm(modulePath, outputPath string) error {
 	return nil
 }
 
+// moduleVersion reads the semantic version declared in module.json
+func moduleVersion(modulePath string) string {
+	metadata, err := parseModuleMetadata(filepath.Join(modulePath, "module.json"))
+	if err != nil || strings.ContainsAny(metadata.Version, " '\"") {
+		return ""
+	}
+	return metadata.Version
+}
+
+// sourceHash returns a short content hash of the module sources, reported by getModuleInfo
+func sourceHash(modulePath string) (string, error) {
+	hasher := sha256.New()
+	for _, name := range []string{"main.go", "go.mod", "go.sum"} {
+		data, err := os.ReadFile(filepath.Join(modulePath, name))
+		if err != nil {
+			if os.IsNotExist(err) && name == "go.sum" {
+				continue
+			}
+			return "", err
+		}
+		fmt.Fprintf(hasher, "%s %d\n", name, len(data))
+		hasher.Write(data)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))[:12], nil
+}
+
 // optimizeWasm optimizes WASM file using wasm-opt
 func (b *Builder) optimizeWasm(wasmPath string) error {
 	// Check if wasm-opt is available
@@ -0,0 +1,178 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumsFile is the repository-level checksum list, compatible with "sha256sum -c"
+const ChecksumsFile = "SHA256SUMS"
+
+// IntegrityFile holds the SRI values and snippets of every module
+const IntegrityFile = "integrity.json"
+
+// FileChecksum holds the digests of a single artifact
+type FileChecksum struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	Integrity string `json:"integrity"`
+}
+
+// ModuleIntegrity holds the checksums and ready-to-paste SRI snippets of a module
+type ModuleIntegrity struct {
+	Module   string         `json:"module"`
+	URL      string         `json:"url"`
+	Files    []FileChecksum `json:"files"`
+	Preload  string         `json:"preload"`
+	Fetch    string         `json:"fetch"`
+	Instance string         `json:"instantiate"`
+}
+
+// IntegrityManifest is the content of integrity.json
+type IntegrityManifest struct {
+	Runtime *FileChecksum      `json:"runtime,omitempty"`
+	Script  string             `json:"script,omitempty"`
+	Modules []*ModuleIntegrity `json:"modules"`
+}
+
+// checksumArtifacts lists the build outputs covered by SHA256SUMS
+var checksumArtifacts = []string{"main.wasm", "main.wasm.gz", "main.wasm.br"}
+
+// GenerateChecksums writes SHA256SUMS and integrity.json for every module that has been built.
+// baseURL is prepended to the artifact paths in the generated snippets.
+func GenerateChecksums(rootDir string, modules []string, baseURL string) (*IntegrityManifest, error) {
+	manifest := &IntegrityManifest{}
+	var sums []FileChecksum
+
+	runtimePath := filepath.Join(rootDir, "shared", "wasm_exec.js")
+	if fileExists(runtimePath) {
+		sum, err := checksumFile(rootDir, runtimePath)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Runtime = sum
+		manifest.Script = fmt.Sprintf(`<script src="%s" integrity="%s" crossorigin="anonymous"></script>`,
+			joinURL(baseURL, sum.Path), sum.Integrity)
+		sums = append(sums, *sum)
+	}
+
+	sorted := append([]string(nil), modules...)
+	sort.Strings(sorted)
+
+	for _, module := range sorted {
+		entry := &ModuleIntegrity{Module: module}
+
+		for _, artifact := range checksumArtifacts {
+			path := filepath.Join(rootDir, module, artifact)
+			if !fileExists(path) {
+				continue
+			}
+			sum, err := checksumFile(rootDir, path)
+			if err != nil {
+				return nil, err
+			}
+			entry.Files = append(entry.Files, *sum)
+			sums = append(sums, *sum)
+		}
+
+		if len(entry.Files) == 0 || entry.Files[0].Path != filepath.ToSlash(filepath.Join(module, "main.wasm")) {
+			continue
+		}
+
+		wasm := entry.Files[0]
+		entry.URL = joinURL(baseURL, wasm.Path)
+		entry.Preload = fmt.Sprintf(`<link rel="preload" href="%s" as="fetch" type="application/wasm" integrity="%s" crossorigin="anonymous">`,
+			entry.URL, wasm.Integrity)
+		entry.Fetch = fmt.Sprintf(`fetch("%s", { integrity: "%s" })`, entry.URL, wasm.Integrity)
+		entry.Instance = fmt.Sprintf(`WebAssembly.instantiateStreaming(fetch("%s", { integrity: "%s" }), go.importObject)`,
+			entry.URL, wasm.Integrity)
+
+		manifest.Modules = append(manifest.Modules, entry)
+	}
+
+	var b strings.Builder
+	for _, sum := range sums {
+		fmt.Fprintf(&b, "%s  %s\n", sum.SHA256, sum.Path)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, ChecksumsFile), []byte(b.String()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ChecksumsFile, err)
+	}
+
+	// Keep the HTML snippets readable instead of \u003c escapes
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", IntegrityFile, err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, IntegrityFile), data.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", IntegrityFile, err)
+	}
+
+	return manifest, nil
+}
+
+// PrintIntegritySnippets prints the SRI snippets for copy and paste
+func PrintIntegritySnippets(manifest *IntegrityManifest) {
+	fmt.Println("\n🔐 Subresource Integrity")
+	fmt.Println("========================")
+
+	if manifest.Script != "" {
+		fmt.Printf("📜 wasm_exec.js\n   %s\n", manifest.Script)
+	}
+
+	for _, entry := range manifest.Modules {
+		fmt.Printf("\n📦 %s\n", entry.Module)
+		fmt.Printf("   %s\n", entry.Preload)
+		fmt.Printf("   %s\n", entry.Fetch)
+	}
+
+	fmt.Printf("\n✅ Wrote %s and %s\n", ChecksumsFile, IntegrityFile)
+}
+
+// checksumFile hashes a file with SHA-256 (for SHA256SUMS) and SHA-384 (for SRI)
+func checksumFile(rootDir, path string) (*FileChecksum, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	h256 := sha256.New()
+	h384 := sha512.New384()
+	size, err := io.Copy(io.MultiWriter(h256, h384), file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	rel, err := filepath.Rel(rootDir, path)
+	if err != nil {
+		rel = path
+	}
+
+	return &FileChecksum{
+		Path:      filepath.ToSlash(rel),
+		Size:      size,
+		SHA256:    hex.EncodeToString(h256.Sum(nil)),
+		Integrity: "sha384-" + base64.StdEncoding.EncodeToString(h384.Sum(nil)),
+	}, nil
+}
+
+func joinURL(baseURL, path string) string {
+	if baseURL == "" {
+		return path
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + path
+}
@@ -0,0 +1,256 @@
+package builder
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ArtifactCheck is the verification of one built artifact
+type ArtifactCheck struct {
+	Path     string   `json:"path"`
+	Size     int64    `json:"size"`
+	SHA256   string   `json:"sha256"`
+	Problems []string `json:"problems,omitempty"`
+
+	digests map[string][]byte
+}
+
+// ModuleVerification gathers the checks of a module's artifacts against its .integrity files,
+// SHA256SUMS and the sizes recorded in module.json
+type ModuleVerification struct {
+	Module    string           `json:"module"`
+	Built     bool             `json:"built"`
+	Artifacts []*ArtifactCheck `json:"artifacts"`
+	Problems  []string         `json:"problems,omitempty"`
+}
+
+// OK reports whether the module and all its artifacts passed verification
+func (m *ModuleVerification) OK() bool {
+	if len(m.Problems) > 0 {
+		return false
+	}
+	for _, artifact := range m.Artifacts {
+		if len(artifact.Problems) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ProblemCount returns the number of problems found in the module and its artifacts
+func (m *ModuleVerification) ProblemCount() int {
+	count := len(m.Problems)
+	for _, artifact := range m.Artifacts {
+		count += len(artifact.Problems)
+	}
+	return count
+}
+
+// VerifyArtifacts rehashes the built artifacts of the modules with up to workers files hashed
+// concurrently, then checks them against the <artifact>.integrity files, the repository SHA256SUMS
+// when present, and the size and gzipSize fields of module.json. Modules without main.wasm are
+// reported as not built.
+func VerifyArtifacts(rootDir string, modules []string, workers int) ([]*ModuleVerification, error) {
+	sums, err := readChecksums(filepath.Join(rootDir, ChecksumsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]string(nil), modules...)
+	sort.Strings(sorted)
+
+	var results []*ModuleVerification
+	var checks []*ArtifactCheck
+	for _, module := range sorted {
+		result := &ModuleVerification{Module: module, Artifacts: []*ArtifactCheck{}}
+		for _, artifact := range checksumArtifacts {
+			path := filepath.Join(rootDir, module, artifact)
+			if !fileExists(path) {
+				continue
+			}
+			check := &ArtifactCheck{Path: filepath.ToSlash(filepath.Join(module, artifact))}
+			result.Artifacts = append(result.Artifacts, check)
+			checks = append(checks, check)
+		}
+		result.Built = len(result.Artifacts) > 0 && result.Artifacts[0].Path == filepath.ToSlash(filepath.Join(module, "main.wasm"))
+		results = append(results, result)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	g := new(errgroup.Group)
+	g.SetLimit(workers)
+	for _, check := range checks {
+		check := check
+		g.Go(func() error {
+			return check.hash(rootDir)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		if !result.Built {
+			if len(result.Artifacts) > 0 {
+				result.Problems = append(result.Problems, "compressed artifacts found without main.wasm")
+			}
+			continue
+		}
+		for _, check := range result.Artifacts {
+			check.verifyIntegrityFile(rootDir)
+			if sums != nil {
+				if expected, ok := sums[check.Path]; !ok {
+					check.Problems = append(check.Problems, fmt.Sprintf("not listed in %s", ChecksumsFile))
+				} else if expected != check.SHA256 {
+					check.Problems = append(check.Problems, fmt.Sprintf("SHA-256 mismatch with %s: expected %s, actual %s", ChecksumsFile, expected, check.SHA256))
+				}
+			}
+		}
+		result.verifySizes(rootDir)
+	}
+
+	// Checksums of artifacts that are gone mean SHA256SUMS is stale
+	for _, result := range results {
+		for _, artifact := range checksumArtifacts {
+			path := filepath.ToSlash(filepath.Join(result.Module, artifact))
+			if _, listed := sums[path]; listed && !fileExists(filepath.Join(rootDir, path)) {
+				result.Problems = append(result.Problems, fmt.Sprintf("%s lists %s, which does not exist", ChecksumsFile, path))
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// hash computes the digests of the artifact in one pass: SHA-256 for SHA256SUMS and .integrity
+// files, SHA-384 and SHA-512 for .integrity files using those algorithms
+func (c *ArtifactCheck) hash(rootDir string) error {
+	file, err := os.Open(filepath.Join(rootDir, filepath.FromSlash(c.Path)))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", c.Path, err)
+	}
+	defer file.Close()
+
+	hashers := map[string]hash.Hash{"sha256": sha256.New(), "sha384": sha512.New384(), "sha512": sha512.New()}
+	writers := make([]io.Writer, 0, len(hashers))
+	for _, h := range hashers {
+		writers = append(writers, h)
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), file)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", c.Path, err)
+	}
+
+	c.Size = size
+	c.digests = make(map[string][]byte, len(hashers))
+	for algorithm, h := range hashers {
+		c.digests[algorithm] = h.Sum(nil)
+	}
+	c.SHA256 = hex.EncodeToString(c.digests["sha256"])
+	return nil
+}
+
+// verifyIntegrityFile compares the artifact with its <artifact>.integrity file, which holds one or
+// more SRI values such as sha256-<base64>. The file is required for main.wasm only, as the build
+// writes none for the compressed artifacts.
+func (c *ArtifactCheck) verifyIntegrityFile(rootDir string) {
+	integrityPath := filepath.Join(rootDir, filepath.FromSlash(c.Path)+".integrity")
+	data, err := os.ReadFile(integrityPath)
+	if err != nil {
+		if strings.HasSuffix(c.Path, "/main.wasm") {
+			c.Problems = append(c.Problems, fmt.Sprintf("missing %s.integrity", filepath.Base(c.Path)))
+		}
+		return
+	}
+
+	values := strings.Fields(string(data))
+	if len(values) == 0 {
+		c.Problems = append(c.Problems, fmt.Sprintf("%s.integrity is empty", filepath.Base(c.Path)))
+		return
+	}
+	for _, value := range values {
+		algorithm, encoded, found := strings.Cut(value, "-")
+		digest, known := c.digests[algorithm]
+		if !found || !known {
+			c.Problems = append(c.Problems, fmt.Sprintf("unsupported integrity value %q", value))
+			continue
+		}
+		if actual := base64.StdEncoding.EncodeToString(digest); actual != encoded {
+			c.Problems = append(c.Problems, fmt.Sprintf("%s mismatch with %s.integrity: expected %s, actual %s",
+				strings.ToUpper(algorithm), filepath.Base(c.Path), encoded, actual))
+		}
+	}
+}
+
+// verifySizes compares main.wasm and main.wasm.gz with the size and gzipSize fields of module.json
+func (m *ModuleVerification) verifySizes(rootDir string) {
+	metadataPath := filepath.Join(rootDir, m.Module, "module.json")
+	if !fileExists(metadataPath) {
+		m.Problems = append(m.Problems, "module.json file not found")
+		return
+	}
+	metadata, err := parseModuleMetadata(metadataPath)
+	if err != nil {
+		m.Problems = append(m.Problems, fmt.Sprintf("failed to parse module.json: %v", err))
+		return
+	}
+
+	for _, check := range m.Artifacts {
+		switch filepath.Base(check.Path) {
+		case "main.wasm":
+			if metadata.Size > 0 && metadata.Size != check.Size {
+				m.Problems = append(m.Problems, fmt.Sprintf("size mismatch: module.json reports %d, main.wasm is %d", metadata.Size, check.Size))
+			}
+		case "main.wasm.gz":
+			if metadata.GzipSize > 0 && metadata.GzipSize != check.Size {
+				m.Problems = append(m.Problems, fmt.Sprintf("gzip size mismatch: module.json reports %d, main.wasm.gz is %d", metadata.GzipSize, check.Size))
+			}
+		}
+	}
+}
+
+// readChecksums parses a sha256sum-style file into path -> hex digest, or returns nil if it does not exist
+func readChecksums(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		digest, name, found := strings.Cut(text, " ")
+		if !found || len(digest) != sha256.Size*2 {
+			return nil, fmt.Errorf("%s:%d: malformed checksum line", filepath.Base(path), line)
+		}
+		// sha256sum marks binary mode with a * before the file name
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		sums[filepath.ToSlash(name)] = strings.ToLower(digest)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return sums, nil
+}
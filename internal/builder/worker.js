@@ -0,0 +1,84 @@
+// wasm-manager worker host
+// Generated by "wasm-manager build" next to main.wasm. Started by main.js, it
+// runs the module inside a Web Worker and answers the calls posted to it, so
+// heavy functions never block the page.
+'use strict';
+
+var ready = null;
+
+function load(message) {
+  importScripts(message.wasmExecURL);
+  var go = new Go();
+  var options = message.integrity ? { integrity: message.integrity } : {};
+  var request = fetch(message.wasmURL, options);
+  var instantiate = WebAssembly.instantiateStreaming
+    ? WebAssembly.instantiateStreaming(request, go.importObject)
+    : request
+        .then(function (response) { return response.arrayBuffer(); })
+        .then(function (bytes) { return WebAssembly.instantiate(bytes, go.importObject); });
+
+  return instantiate.then(function (result) {
+    // main() registers the module functions on the worker global, then blocks
+    go.run(result.instance);
+    return result.instance;
+  });
+}
+
+// Buffers of typed arrays in the result are moved to the page instead of
+// copied. Views of the WebAssembly memory cannot be detached and are copied.
+function transferables(value, memory, found, seen) {
+  if (value === null || typeof value !== 'object' || seen.has(value)) {
+    return found;
+  }
+  seen.add(value);
+
+  if (value instanceof ArrayBuffer) {
+    if (value !== memory && found.indexOf(value) < 0) {
+      found.push(value);
+    }
+  } else if (ArrayBuffer.isView(value)) {
+    transferables(value.buffer, memory, found, seen);
+  } else {
+    Object.keys(value).forEach(function (key) {
+      transferables(value[key], memory, found, seen);
+    });
+  }
+  return found;
+}
+
+function errorMessage(err) {
+  return err && err.message ? err.message : String(err);
+}
+
+self.onmessage = function (event) {
+  var message = event.data;
+
+  if (message.type === 'init') {
+    ready = load(message);
+    ready.then(
+      function () { self.postMessage({ type: 'ready' }); },
+      function (err) { self.postMessage({ type: 'failed', error: errorMessage(err) }); }
+    );
+    return;
+  }
+
+  if (message.type !== 'call') {
+    return;
+  }
+
+  ready
+    .then(function (instance) {
+      var fn = self[message.name];
+      if (typeof fn !== 'function') {
+        throw new Error(message.name + ' is not a function of this module');
+      }
+      // Functions returning a Promise are awaited
+      return Promise.resolve(fn.apply(null, message.args)).then(function (result) {
+        self.postMessage({ type: 'result', id: message.id, result: result },
+          transferables(result, instance.exports.mem.buffer, [], new Set()));
+      });
+    })
+    .catch(function (err) {
+      self.postMessage({ type: 'error', id: message.id, error: errorMessage(err) });
+    });
+};
@@ -0,0 +1,91 @@
+package builder
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// LoaderFile is the generated ES module that loads a module, in a Web Worker by default
+const LoaderFile = "main.js"
+
+// WorkerFile is the generated worker script started by LoaderFile
+const WorkerFile = "main.worker.js"
+
+//go:embed loader.js.tmpl
+var loaderSource string
+
+//go:embed worker.js
+var workerScript []byte
+
+var loaderTemplate = template.Must(template.New(LoaderFile).Parse(loaderSource))
+
+// standardFunctions are registered by every module; the loader example prefers another one
+var standardFunctions = map[string]bool{
+	"getAvailableFunctions": true,
+	"setSilentMode":         true,
+	"getModuleInfo":         true,
+	"getMemoryStats":        true,
+	"releaseResources":      true,
+	"setLocale":             true,
+}
+
+// generateWrapper writes main.js and main.worker.js next to main.wasm. The loader exposes the
+// functions documented in module.json and checks main.wasm against integrity when it is set.
+func (b *Builder) generateWrapper(modulePath, integrity string) error {
+	metadata, err := parseModuleMetadata(filepath.Join(modulePath, "module.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read module.json: %w", err)
+	}
+
+	names := []string{}
+	example := ""
+	for _, function := range metadata.Functions {
+		entry, ok := function.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := entry["name"].(string)
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+		if example == "" && !standardFunctions[name] {
+			example = name
+		}
+	}
+	if example == "" {
+		example = "getModuleInfo"
+	}
+
+	functions, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	hash, err := json.Marshal(integrity)
+	if err != nil {
+		return err
+	}
+
+	var loader bytes.Buffer
+	if err := loaderTemplate.Execute(&loader, map[string]string{
+		"Module":    filepath.Base(modulePath),
+		"Example":   example,
+		"Functions": string(functions),
+		"Integrity": string(hash),
+	}); err != nil {
+		return fmt.Errorf("failed to generate %s: %w", LoaderFile, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(modulePath, LoaderFile), loader.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", LoaderFile, err)
+	}
+	if err := os.WriteFile(filepath.Join(modulePath, WorkerFile), workerScript, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", WorkerFile, err)
+	}
+	return nil
+}
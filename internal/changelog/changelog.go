@@ -0,0 +1,422 @@
+package changelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Entry is a change parsed from a conventional-commit message
+type Entry struct {
+	Type        string `json:"type"`
+	Scope       string `json:"scope,omitempty"`
+	Description string `json:"description"`
+	Breaking    bool   `json:"breaking,omitempty"`
+	Hash        string `json:"hash"`
+}
+
+// Release groups the changes of a module since its last version bump
+type Release struct {
+	Module  string  `json:"module"`
+	Version string  `json:"version"`
+	Date    string  `json:"date"`
+	Since   string  `json:"since,omitempty"`
+	Entries []Entry `json:"entries"`
+}
+
+// Config holds changelog configuration
+type Config struct {
+	RootDir string
+	// Since overrides the last version bump as the start of the range
+	Since string
+	// All keeps chore, ci, test, style and build commits, which are hidden by default
+	All bool
+}
+
+// section is a group of commit types rendered under one heading
+type section struct {
+	title string
+	types []string
+}
+
+// sections lists the rendered groups in order; types not listed fall into "Other Changes"
+var sections = []section{
+	{"Features", []string{"feat"}},
+	{"Bug Fixes", []string{"fix"}},
+	{"Performance", []string{"perf"}},
+	{"Refactoring", []string{"refactor"}},
+	{"Documentation", []string{"docs"}},
+}
+
+// hiddenTypes are left out of release notes unless Config.All is set
+var hiddenTypes = map[string]bool{
+	"chore": true,
+	"ci":    true,
+	"test":  true,
+	"style": true,
+	"build": true,
+}
+
+// commitPattern matches "type(scope)!: description", optionally after a "[reference]" prefix
+var commitPattern = regexp.MustCompile(`^(?:\[[^\]]*\]\s*)?([a-zA-Z]+)(?:\(([^)]*)\))?(!)?:\s+(.+)$`)
+
+// breakingPattern matches the BREAKING CHANGE footer of a commit body
+var breakingPattern = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:\s`)
+
+// ParseCommit parses a conventional-commit subject and body; ok is false for other messages
+func ParseCommit(hash, subject, body string) (Entry, bool) {
+	match := commitPattern.FindStringSubmatch(strings.TrimSpace(subject))
+	if match == nil {
+		return Entry{}, false
+	}
+	return Entry{
+		Type:        strings.ToLower(match[1]),
+		Scope:       match[2],
+		Description: strings.TrimSpace(match[4]),
+		Breaking:    match[3] == "!" || breakingPattern.MatchString(body),
+		Hash:        hash,
+	}, true
+}
+
+// LastVersionBump returns the last commit that changed the version of module.json, or "" if there is none
+func LastVersionBump(rootDir, module string) (string, error) {
+	output, err := git(rootDir, "log", "-1", "--format=%H", `-G^  "version": `, "--", filepath.Join(module, "module.json"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// Unreleased collects the conventional commits that touched a module since its last version bump
+func Unreleased(cfg *Config, module string) (*Release, error) {
+	since := cfg.Since
+	if since == "" {
+		bump, err := LastVersionBump(cfg.RootDir, module)
+		if err != nil {
+			return nil, err
+		}
+		since = bump
+	}
+
+	version, err := moduleVersion(cfg.RootDir, module)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields are separated by US and commits by RS so subjects and bodies may contain anything else
+	args := []string{"log", "--no-merges", "--reverse", "--format=%H%x1f%s%x1f%b%x1e"}
+	if since != "" {
+		args = append(args, since+"..HEAD")
+	}
+	args = append(args, "--", module)
+
+	output, err := git(cfg.RootDir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{
+		Module:  module,
+		Version: version,
+		Date:    time.Now().Format("2006-01-02"),
+		Since:   since,
+		Entries: []Entry{},
+	}
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x1f", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		body := ""
+		if len(fields) == 3 {
+			body = fields[2]
+		}
+		entry, ok := ParseCommit(fields[0], fields[1], body)
+		if !ok || (hiddenTypes[entry.Type] && !cfg.All && !entry.Breaking) {
+			continue
+		}
+		release.Entries = append(release.Entries, entry)
+	}
+
+	return release, nil
+}
+
+// Changes returns the entries as the sentences stored in the module.json changelog, breaking changes first
+func (r *Release) Changes() []string {
+	var changes []string
+	for _, entry := range r.Entries {
+		if entry.Breaking {
+			changes = append(changes, "BREAKING: "+entry.sentence())
+		}
+	}
+	for _, group := range r.groups() {
+		for _, entry := range group.entries {
+			if !entry.Breaking {
+				changes = append(changes, entry.sentence())
+			}
+		}
+	}
+	return changes
+}
+
+// sentence capitalizes the description, prefixed with its scope when it names a part of the module
+func (e Entry) sentence() string {
+	description := e.Description
+	if r, size := utf8.DecodeRuneInString(description); size > 0 {
+		description = string(unicode.ToUpper(r)) + description[size:]
+	}
+	if e.Scope != "" {
+		return e.Scope + ": " + description
+	}
+	return description
+}
+
+// renderedGroup is a section with the entries it contains
+type renderedGroup struct {
+	title   string
+	entries []Entry
+}
+
+// groups splits the entries by section, keeping commit order within each section
+func (r *Release) groups() []renderedGroup {
+	var groups []renderedGroup
+	listed := make(map[string]bool)
+	for _, s := range sections {
+		group := renderedGroup{title: s.title}
+		for _, t := range s.types {
+			listed[t] = true
+			for _, entry := range r.Entries {
+				if entry.Type == t {
+					group.entries = append(group.entries, entry)
+				}
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	other := renderedGroup{title: "Other Changes"}
+	for _, entry := range r.Entries {
+		if !listed[entry.Type] {
+			other.entries = append(other.entries, entry)
+		}
+	}
+	if len(other.entries) == len(r.Entries) {
+		// Changes read back from module.json carry no type
+		other.title = "Changes"
+	}
+	return append(groups, other)
+}
+
+// Markdown renders the release as a CHANGELOG.md section
+func (r *Release) Markdown() string {
+	var b strings.Builder
+	if r.Date != "" {
+		fmt.Fprintf(&b, "## [%s] - %s\n", r.Version, r.Date)
+	} else {
+		fmt.Fprintf(&b, "## [%s]\n", r.Version)
+	}
+
+	if len(r.Entries) == 0 {
+		b.WriteString("\nNo notable changes.\n")
+		return b.String()
+	}
+
+	var breaking []Entry
+	for _, entry := range r.Entries {
+		if entry.Breaking {
+			breaking = append(breaking, entry)
+		}
+	}
+	if len(breaking) > 0 {
+		b.WriteString("\n### ⚠ Breaking Changes\n\n")
+		for _, entry := range breaking {
+			b.WriteString(entry.markdownLine())
+		}
+	}
+
+	// Breaking changes are listed once, under their own heading
+	for _, group := range r.groups() {
+		var lines strings.Builder
+		for _, entry := range group.entries {
+			if !entry.Breaking {
+				lines.WriteString(entry.markdownLine())
+			}
+		}
+		if lines.Len() > 0 {
+			fmt.Fprintf(&b, "\n### %s\n\n%s", group.title, lines.String())
+		}
+	}
+	return b.String()
+}
+
+// markdownLine renders an entry as a list item with its short hash
+func (e Entry) markdownLine() string {
+	description := e.Description
+	if e.Scope != "" {
+		description = "**" + e.Scope + ":** " + description
+	}
+	hash := e.Hash
+	if len(hash) > 7 {
+		hash = hash[:7]
+	}
+	if hash == "" {
+		return "- " + description + "\n"
+	}
+	return fmt.Sprintf("- %s (%s)\n", description, hash)
+}
+
+// FromModuleJSON reads the release recorded in the changelog map of a module.json
+func FromModuleJSON(rootDir, module string) (*Release, error) {
+	metadata, err := readModuleJSON(rootDir, module)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{Module: module, Entries: []Entry{}}
+	recorded, _ := metadata["changelog"].(map[string]interface{})
+	if recorded == nil {
+		return release, nil
+	}
+	release.Version, _ = recorded["version"].(string)
+	release.Date, _ = recorded["releaseDate"].(string)
+
+	changes, _ := recorded["changes"].([]interface{})
+	for _, change := range changes {
+		text, ok := change.(string)
+		if !ok {
+			continue
+		}
+		entry := Entry{Description: text}
+		if strings.HasPrefix(text, "BREAKING: ") {
+			entry.Breaking = true
+			entry.Description = strings.TrimPrefix(text, "BREAKING: ")
+		}
+		release.Entries = append(release.Entries, entry)
+	}
+	return release, nil
+}
+
+// WriteModuleJSON records the release in the changelog map of the module's module.json. Changes of
+// a release already recorded under the same version are kept, and new ones appended after them.
+func WriteModuleJSON(rootDir string, release *Release) error {
+	metadata, err := readModuleJSON(rootDir, release.Module)
+	if err != nil {
+		return err
+	}
+
+	var changes []interface{}
+	seen := make(map[string]bool)
+	if recorded, ok := metadata["changelog"].(map[string]interface{}); ok && recorded["version"] == release.Version {
+		previous, _ := recorded["changes"].([]interface{})
+		for _, change := range previous {
+			if text, ok := change.(string); ok {
+				seen[text] = true
+			}
+			changes = append(changes, change)
+		}
+	}
+	for _, change := range release.Changes() {
+		if !seen[change] {
+			seen[change] = true
+			changes = append(changes, change)
+		}
+	}
+	if changes == nil {
+		changes = []interface{}{}
+	}
+
+	metadata["changelog"] = map[string]interface{}{
+		"changes":     changes,
+		"releaseDate": release.Date,
+		"version":     release.Version,
+	}
+	metadata["version"] = release.Version
+
+	// module.json is written with sorted keys, two-space indentation and no trailing newline
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode module.json: %w", err)
+	}
+	return os.WriteFile(filepath.Join(rootDir, release.Module, "module.json"), data, 0644)
+}
+
+// WriteMarkdown adds the release to the module's CHANGELOG.md, replacing the section of the same version
+func WriteMarkdown(rootDir string, release *Release) error {
+	path := filepath.Join(rootDir, release.Module, "CHANGELOG.md")
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	header := "# Changelog\n\nAll notable changes to " + release.Module + " are documented in this file.\n"
+	body := string(existing)
+	if body == "" {
+		body = header
+	}
+
+	section := release.Markdown()
+	heading := fmt.Sprintf("## [%s]", release.Version)
+
+	if start := strings.Index(body, heading); start >= 0 {
+		end := len(body)
+		if next := strings.Index(body[start+len(heading):], "\n## "); next >= 0 {
+			end = start + len(heading) + next + 1
+		}
+		body = body[:start] + section + "\n" + strings.TrimLeft(body[end:], "\n")
+	} else if first := strings.Index(body, "\n## "); first >= 0 {
+		body = body[:first+1] + section + "\n" + body[first+1:]
+	} else {
+		body = strings.TrimRight(body, "\n") + "\n\n" + section
+	}
+
+	return os.WriteFile(path, []byte(strings.TrimRight(body, "\n")+"\n"), 0644)
+}
+
+// moduleVersion reads the version declared in module.json
+func moduleVersion(rootDir, module string) (string, error) {
+	metadata, err := readModuleJSON(rootDir, module)
+	if err != nil {
+		return "", err
+	}
+	version, _ := metadata["version"].(string)
+	return version, nil
+}
+
+// readModuleJSON decodes module.json keeping numbers as written
+func readModuleJSON(rootDir, module string) (map[string]interface{}, error) {
+	path := filepath.Join(rootDir, module, "module.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var metadata map[string]interface{}
+	if err := decoder.Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return metadata, nil
+}
+
+// git runs a git command in the repository and returns its output
+func git(rootDir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = rootDir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(output), nil
+}
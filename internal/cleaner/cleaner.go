@@ -68,6 +68,8 @@ func (c *Cleaner) cleanModule(module string) error {
 		"*.wasm.gz",
 		"*.wasm.br",
 		"*.wasm.integrity",
+		"main.js",
+		"main.worker.js",
 		"*.backup",
 	}
 
@@ -10,9 +10,12 @@ type BuildConfig struct {
 	Optimize          bool
 	Compress          bool
 	GenerateIntegrity bool
+	IntegrityBaseURL  string
+	Wrapper           bool // write the main.js loader and main.worker.js next to main.wasm
 	Clean             bool
 	Verbose           bool
 	Timeout           time.Duration
+	Modules           map[string]ModuleOverride
 }
 
 // DefaultBuildConfig returns default build configuration
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// FileName is the name of the config file looked up in the current and home directories
+const FileName = ".wasm-manager.yaml"
+
+// MaxWorkers bounds the workers setting, far above any useful degree of parallelism
+const MaxWorkers = 256
+
+// ModuleOverride holds the build settings of one module that differ from the build section.
+// Unset fields inherit the global setting.
+type ModuleOverride struct {
+	Optimize  *bool `mapstructure:"optimize"`
+	Compress  *bool `mapstructure:"compress"`
+	Integrity *bool `mapstructure:"integrity"`
+	Skip      bool  `mapstructure:"skip"`
+}
+
+// ForModule returns the build configuration of a module, with its override applied
+func (c *BuildConfig) ForModule(module string) *BuildConfig {
+	override, ok := c.Modules[module]
+	if !ok {
+		return c
+	}
+	cfg := *c
+	if override.Optimize != nil {
+		cfg.Optimize = *override.Optimize
+	}
+	if override.Compress != nil {
+		cfg.Compress = *override.Compress
+	}
+	if override.Integrity != nil {
+		cfg.GenerateIntegrity = *override.Integrity
+	}
+	return &cfg
+}
+
+// Keys accepted in each part of the config file
+var (
+	fileKeys   = []string{"verbose", "workers", "build", "modules"}
+	buildKeys  = []string{"optimize", "compress", "integrity", "clean", "wrapper", "base-url"}
+	moduleKeys = []string{"optimize", "compress", "integrity", "skip"}
+
+	// movedKeys maps top-level keys of the flat format documented before the build section
+	// existed to their current place. Keys are lowercase, as viper reads them.
+	movedKeys = map[string]string{
+		"optimize":          "build.optimize",
+		"compress":          "build.compress",
+		"integrity":         "build.integrity",
+		"generateintegrity": "build.integrity",
+		"clean":             "build.clean",
+		"base-url":          "build.base-url",
+	}
+)
+
+// ValidateFile checks the settings read from a config file: unknown keys, values of the wrong
+// type, worker counts out of range and overrides of modules that do not exist. modules lists
+// the modules of the repository; when empty, module names are not checked. All problems are
+// reported in one error.
+func ValidateFile(settings map[string]interface{}, modules []string) error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	checkKeys(settings, "", fileKeys, problem)
+	if value, ok := settings["verbose"]; ok {
+		checkBool(value, "verbose", problem)
+	}
+	if value, ok := settings["workers"]; ok {
+		if n, isInt := value.(int); !isInt || n < 0 || n > MaxWorkers {
+			problem("workers: expected a number of workers between 0 (one per CPU core) and %d, got %s", MaxWorkers, describe(value))
+		}
+	}
+
+	if value, ok := settings["build"]; ok && value != nil {
+		build, isMap := value.(map[string]interface{})
+		if !isMap {
+			problem("build: expected a section of build settings, got %s", describe(value))
+		} else {
+			checkKeys(build, "build.", buildKeys, problem)
+			for _, key := range []string{"optimize", "compress", "integrity", "clean", "wrapper"} {
+				if value, ok := build[key]; ok {
+					checkBool(value, "build."+key, problem)
+				}
+			}
+			if value, ok := build["base-url"]; ok {
+				if s, isString := value.(string); !isString {
+					problem("build.base-url: expected a URL, got %s", describe(value))
+				} else if _, err := url.Parse(s); err != nil || strings.ContainsAny(s, " \t\r\n") {
+					problem("build.base-url: %q is not a valid URL", s)
+				}
+			}
+		}
+	}
+
+	if value, ok := settings["modules"]; ok && value != nil {
+		overrides, isMap := value.(map[string]interface{})
+		if !isMap {
+			problem("modules: expected a section keyed by module name, got %s", describe(value))
+		}
+		names := make([]string, 0, len(overrides))
+		for name := range overrides {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := "modules." + name
+			if len(modules) > 0 && !contains(modules, name) {
+				if suggestion := closest(name, modules); suggestion != "" {
+					problem("%s: unknown module %q (did you mean %q?)", path, name, suggestion)
+				} else {
+					problem("%s: unknown module %q", path, name)
+				}
+				continue
+			}
+			override, isMap := overrides[name].(map[string]interface{})
+			if !isMap {
+				problem("%s: expected a section of build settings, got %s", path, describe(overrides[name]))
+				continue
+			}
+			checkKeys(override, path+".", moduleKeys, problem)
+			for _, key := range moduleKeys {
+				if value, ok := override[key]; ok {
+					checkBool(value, path+"."+key, problem)
+				}
+			}
+		}
+	}
+
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(problems[0])
+	}
+	return fmt.Errorf("%d problems:\n  • %s", len(problems), strings.Join(problems, "\n  • "))
+}
+
+// checkKeys reports the keys of a section that are not in known, suggesting the closest known key
+func checkKeys(section map[string]interface{}, prefix string, known []string, problem func(string, ...interface{})) {
+	keys := make([]string, 0, len(section))
+	for key := range section {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if contains(known, key) {
+			continue
+		}
+		if moved, ok := movedKeys[key]; ok && prefix == "" {
+			problem("%s: unknown setting (moved to %q)", key, moved)
+			continue
+		}
+		if suggestion := closest(key, known); suggestion != "" {
+			problem("%s%s: unknown setting (did you mean %q?)", prefix, key, prefix+suggestion)
+		} else {
+			problem("%s%s: unknown setting (expected one of %s)", prefix, key, strings.Join(known, ", "))
+		}
+	}
+}
+
+// checkBool reports a setting that is not true or false
+func checkBool(value interface{}, path string, problem func(string, ...interface{})) {
+	if _, ok := value.(bool); !ok {
+		problem("%s: expected true or false, got %s", path, describe(value))
+	}
+}
+
+// describe formats a config value for problem messages
+func describe(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "nothing"
+	case string:
+		return fmt.Sprintf("%q", value)
+	case map[string]interface{}:
+		return "a section"
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprint(value)
+}
+
+// closest returns the candidate within an edit distance of 2 of name, or "" if there is none
+func closest(name string, candidates []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FileTemplate is the documented config file written by 'wasm-manager config init'. Every
+// setting is shown with its default value.
+const FileTemplate = `# wasm-manager configuration
+#
+# Read from the current directory, then from the home directory, or from the
+# file given with --config. Command line flags take precedence over it.
+# Unknown settings are reported as errors.
+
+# Print detailed progress (--verbose)
+verbose: false
+
+# Parallel workers for builds and verification, 0 for one per CPU core (--workers)
+workers: 0
+
+# Defaults of 'wasm-manager build'
+build:
+  # Optimize main.wasm with wasm-opt (--optimize)
+  optimize: true
+  # Write main.wasm.gz, and main.wasm.br when brotli is installed (--compress)
+  compress: true
+  # Write .integrity files, SHA256SUMS and integrity.json (--integrity)
+  integrity: true
+  # Remove previous artifacts before building (--clean)
+  clean: false
+  # Write the main.js Web Worker loader and main.worker.js next to main.wasm;
+  # a failure is reported as a warning and does not fail the build (--wrapper)
+  wrapper: false
+  # URL prefix used in generated SRI snippets (--base-url)
+  base-url: ""
+
+# Build settings of individual modules, keyed by module directory. optimize,
+# compress and integrity override the build section; skip leaves the module out
+# of 'wasm-manager build' when no module is named on the command line.
+modules:
+#  pdf-wasm:
+#    optimize: false
+#    skip: false
+`
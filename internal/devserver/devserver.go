@@ -0,0 +1,525 @@
+package devserver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"wasm-manager/internal/builder"
+	"wasm-manager/internal/config"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// ClientPath serves the hot-reload client script
+	ClientPath = "/__wasm-manager/hot-reload.js"
+	// SocketPath is the WebSocket endpoint used to push rebuild notifications
+	SocketPath = "/__wasm-manager/ws"
+
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+//go:embed hotreload.js
+var clientScript []byte
+
+// Config holds development server configuration
+type Config struct {
+	RootDir   string
+	Addr      string // defaults to 127.0.0.1:8080 so the server is not reachable from the network
+	HotReload bool
+	Build     *config.BuildConfig
+	Verbose   bool
+}
+
+// Server serves the module directories over HTTP and rebuilds modules on change
+type Server struct {
+	config  *Config
+	builder *builder.Builder
+
+	// buildMu serializes rebuilds: the builder and the go toolchain cache are not safe for
+	// concurrent builds triggered by saves in several modules
+	buildMu sync.Mutex
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+}
+
+// Event is the message pushed to browsers after a rebuild
+type Event struct {
+	Type      string `json:"type"`
+	Module    string `json:"module"`
+	URL       string `json:"url,omitempty"`
+	Integrity string `json:"integrity,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Time      int64  `json:"time"`
+}
+
+type client struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+// New creates a new development Server
+func New(cfg *Config) *Server {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.RootDir == "" {
+		cfg.RootDir = "."
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = "127.0.0.1:8080"
+	}
+	if cfg.Build == nil {
+		// Fast incremental builds: optimization and compression are for releases
+		cfg.Build = config.DefaultBuildConfig()
+		cfg.Build.Optimize = false
+		cfg.Build.Compress = false
+	}
+	cfg.Build.Workers = 1
+	// Verbose builds include the compiler output, which is forwarded to the browser console
+	cfg.Build.Verbose = true
+
+	return &Server{
+		config:  cfg,
+		builder: builder.New(cfg.Build),
+		clients: make(map[*client]struct{}),
+	}
+}
+
+// ListenAndServe starts the HTTP server and, with hot reload enabled, the module watcher
+func (s *Server) ListenAndServe() error {
+	modules, err := builder.DiscoverModules(s.config.RootDir)
+	if err != nil {
+		return fmt.Errorf("failed to discover modules: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	files := s.moduleFiles(modules)
+
+	if s.config.HotReload {
+		watcher, err := s.watch(modules)
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
+
+		mux.HandleFunc(ClientPath, s.serveClient)
+		mux.HandleFunc(SocketPath, s.serveSocket)
+		mux.Handle("/", injectClient(files))
+
+		fmt.Printf("🔥 Hot reload enabled for %d modules\n", len(modules))
+	} else {
+		mux.Handle("/", files)
+	}
+
+	fmt.Printf("🌐 Serving %d modules from %s on http://%s\n", len(modules), s.config.RootDir, s.config.Addr)
+	return http.ListenAndServe(s.config.Addr, noCache(mux))
+}
+
+// moduleFiles serves the module directories and wasm_exec.js only, so the rest of the
+// repository (.git, build configuration, local files) is never exposed. wasm_exec.js is
+// available at /wasm_exec.js and at its shared/ and runtime/ paths.
+func (s *Server) moduleFiles(modules []string) http.Handler {
+	allowed := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		allowed[module] = true
+	}
+	files := http.FileServer(http.Dir(s.config.RootDir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		switch path {
+		case "":
+			s.serveIndex(w, modules)
+			return
+		case "wasm_exec.js", "runtime/wasm_exec.js", "shared/wasm_exec.js":
+			s.serveWasmExec(w, r, path)
+			return
+		}
+
+		segments := strings.Split(path, "/")
+		if !allowed[segments[0]] {
+			http.NotFound(w, r)
+			return
+		}
+		for _, segment := range segments {
+			if strings.HasPrefix(segment, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// serveWasmExec serves the requested wasm_exec.js, preferring the one installed by
+// "wasm-manager runtime" for /wasm_exec.js since it matches the toolchain building the modules
+func (s *Server) serveWasmExec(w http.ResponseWriter, r *http.Request, path string) {
+	candidates := []string{path}
+	if path == "wasm_exec.js" {
+		candidates = []string{"runtime/wasm_exec.js", "shared/wasm_exec.js"}
+	}
+	for _, candidate := range candidates {
+		file := filepath.Join(s.config.RootDir, filepath.FromSlash(candidate))
+		if _, err := os.Stat(file); err == nil {
+			http.ServeFile(w, r, file)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// serveIndex lists the served modules
+func (s *Server) serveIndex(w http.ResponseWriter, modules []string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	var page strings.Builder
+	page.WriteString("<!DOCTYPE html>\n<html><head><title>wasm-manager</title></head><body>\n<h1>Modules</h1>\n<ul>\n")
+	for _, module := range modules {
+		name := html.EscapeString(module)
+		fmt.Fprintf(&page, "<li><a href=\"/%s/\">%s</a></li>\n", name, name)
+	}
+	page.WriteString("</ul>\n</body></html>\n")
+	io.WriteString(w, page.String())
+}
+
+// watch rebuilds a module whenever one of its Go sources changes
+func (s *Server) watch(modules []string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	for _, module := range modules {
+		if err := watcher.Add(filepath.Join(s.config.RootDir, module)); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch %s: %w", module, err)
+		}
+	}
+
+	go func() {
+		// Editors emit bursts of events per save, so changes are debounced per module
+		pending := make(map[string]*time.Timer)
+		var mu sync.Mutex
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isSourceFile(event.Name) || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				module := filepath.Base(filepath.Dir(event.Name))
+				mu.Lock()
+				if timer, exists := pending[module]; exists {
+					timer.Stop()
+				}
+				pending[module] = time.AfterFunc(200*time.Millisecond, func() {
+					mu.Lock()
+					delete(pending, module)
+					mu.Unlock()
+					s.rebuild(module)
+				})
+				mu.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Printf("⚠️ Watcher error: %v\n", err)
+			}
+		}
+	}()
+
+	return watcher, nil
+}
+
+// rebuild builds a module and notifies connected browsers
+func (s *Server) rebuild(module string) {
+	s.buildMu.Lock()
+	defer s.buildMu.Unlock()
+
+	fmt.Printf("🔨 Rebuilding %s...\n", module)
+
+	results, err := s.builder.BuildModules([]string{module})
+	event := Event{Module: module, Time: time.Now().UnixMilli()}
+
+	switch {
+	case err != nil:
+		event.Type = "error"
+		event.Error = err.Error()
+	case len(results) == 0 || results[0] == nil || !results[0].Success:
+		event.Type = "error"
+		if len(results) > 0 && results[0] != nil {
+			event.Error = results[0].Error
+		}
+	default:
+		event.Type = "reload"
+		event.URL = fmt.Sprintf("/%s/main.wasm?t=%d", module, event.Time)
+		event.Integrity = results[0].Integrity
+	}
+
+	sent := s.broadcast(event)
+	if s.config.Verbose {
+		fmt.Printf("📡 Notified %d clients\n", sent)
+	}
+}
+
+// broadcast sends an event to every connected browser and drops dead connections
+func (s *Server) broadcast(event Event) int {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := 0
+	for c := range s.clients {
+		if err := c.writeFrame(0x1, payload); err != nil {
+			c.conn.Close()
+			delete(s.clients, c)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+func (s *Server) serveClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Write(clientScript)
+}
+
+// serveSocket performs the WebSocket handshake (RFC 6455). The channel is
+// server-to-client only; incoming frames are read just to detect closes.
+func (s *Server) serveSocket(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "expected WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin WebSocket connections are not allowed", http.StatusForbidden)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	c := &client{conn: conn}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.clients, c)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+		for {
+			opcode, payload, err := readFrame(rw.Reader)
+			if err != nil || opcode == 0x8 {
+				return
+			}
+			if opcode == 0x9 {
+				c.writeFrame(0xA, payload)
+			}
+		}
+	}()
+}
+
+// sameOrigin reports whether a WebSocket upgrade comes from a page served by this server.
+// Browsers always send Origin on WebSocket handshakes; without this check any site open in
+// the browser could connect to the dev server.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser (curl, test clients)
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// writeFrame writes a single unmasked, unfragmented frame
+func (c *client) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readFrame reads a single client frame, unmasking its payload
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > 1<<20 {
+		return 0, nil, fmt.Errorf("frame too large: %d bytes", length)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return opcode, payload, nil
+}
+
+// injectClient adds the hot-reload client script to served HTML pages
+func injectClient(next http.Handler) http.Handler {
+	tag := []byte(`<script src="` + ClientPath + `"></script>`)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !strings.HasSuffix(path, "/") && !strings.HasSuffix(path, ".html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		body := rec.body.Bytes()
+		if strings.HasPrefix(rec.header.Get("Content-Type"), "text/html") {
+			if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+				body = append(body[:i:i], append(tag, body[i:]...)...)
+			} else {
+				body = append(body, tag...)
+			}
+			rec.header.Del("Content-Length")
+		}
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	})
+}
+
+// bufferedResponse captures a response so it can be rewritten
+type bufferedResponse struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+
+// noCache disables browser caching so rebuilt artifacts are always fetched
+func noCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isSourceFile(name string) bool {
+	base := filepath.Base(name)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+	if base == "go.mod" || base == "go.sum" {
+		return true
+	}
+	if filepath.Ext(base) != ".go" {
+		return false
+	}
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,96 @@
+// wasm-manager hot reload client
+// Injected by "wasm-manager serve". When a module is rebuilt, only that module
+// is re-instantiated: its exported globals are replaced in place and a
+// "wasm-module-reloaded" event is dispatched on window.
+(function () {
+  'use strict';
+
+  if (window.wasmHotReload) {
+    return;
+  }
+
+  var handlers = {};
+  var retryDelay = 500;
+
+  function log(message) {
+    console.log('%c[wasm-manager]', 'color:#7c3aed;font-weight:bold', message);
+  }
+
+  // Default strategy: run the new binary with wasm_exec.js, which re-registers
+  // the module functions on the global object.
+  function reinstantiate(event) {
+    if (typeof Go === 'undefined') {
+      return Promise.reject(new Error('wasm_exec.js is not loaded'));
+    }
+    var go = new Go();
+    var request = fetch(event.url, { cache: 'no-store' });
+    var instantiate = WebAssembly.instantiateStreaming
+      ? WebAssembly.instantiateStreaming(request, go.importObject)
+      : request
+          .then(function (response) { return response.arrayBuffer(); })
+          .then(function (bytes) { return WebAssembly.instantiate(bytes, go.importObject); });
+
+    return instantiate.then(function (result) {
+      // The previous instance keeps waiting on its channel; this is acceptable in development
+      go.run(result.instance);
+      return result.instance;
+    });
+  }
+
+  function handle(event) {
+    if (event.type === 'error') {
+      console.error('[wasm-manager] ' + event.module + ' build failed:\n' + event.error);
+      window.dispatchEvent(new CustomEvent('wasm-module-error', { detail: event }));
+      return;
+    }
+    if (event.type !== 'reload') {
+      return;
+    }
+
+    var custom = handlers[event.module];
+    var reload = custom ? Promise.resolve(custom(event)) : reinstantiate(event);
+
+    reload
+      .then(function () {
+        log(event.module + ' reloaded');
+        window.dispatchEvent(new CustomEvent('wasm-module-reloaded', { detail: event }));
+      })
+      .catch(function (err) {
+        console.error('[wasm-manager] failed to reload ' + event.module + ':', err);
+      });
+  }
+
+  function connect() {
+    var protocol = location.protocol === 'https:' ? 'wss://' : 'ws://';
+    var socket = new WebSocket(protocol + location.host + '/__wasm-manager/ws');
+
+    socket.onopen = function () {
+      retryDelay = 500;
+      log('hot reload connected');
+    };
+    socket.onmessage = function (message) {
+      try {
+        handle(JSON.parse(message.data));
+      } catch (err) {
+        console.error('[wasm-manager] invalid message:', err);
+      }
+    };
+    socket.onclose = function () {
+      setTimeout(connect, retryDelay);
+      retryDelay = Math.min(retryDelay * 2, 5000);
+    };
+  }
+
+  window.wasmHotReload = {
+    // Register a custom reload handler for a module, e.g. to reload it through GoWM:
+    // wasmHotReload.register('math-wasm', function (event) { return gowm.load(event.url, { name: 'math' }); });
+    register: function (module, handler) {
+      handlers[module] = handler;
+    },
+    unregister: function (module) {
+      delete handlers[module];
+    }
+  };
+
+  connect();
+})();
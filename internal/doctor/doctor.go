@@ -0,0 +1,424 @@
+package doctor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Status is the outcome of a single diagnostic check
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarning
+	StatusError
+)
+
+// Doctor diagnoses the local build environment
+type Doctor struct {
+	config *Config
+}
+
+// Config holds doctor configuration
+type Config struct {
+	RootDir string
+	Strict  bool
+	Verbose bool
+}
+
+// CheckResult represents the result of a single diagnostic check
+type CheckResult struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	Status   Status `json:"status"`
+	Message  string `json:"message"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// Report groups all diagnostic results
+type Report struct {
+	Results  []*CheckResult `json:"results"`
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+}
+
+var goVersionPattern = regexp.MustCompile(`go(\d+(?:\.\d+){0,2})`)
+
+// New creates a new Doctor instance
+func New(cfg *Config) *Doctor {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.RootDir == "" {
+		cfg.RootDir = "."
+	}
+	return &Doctor{config: cfg}
+}
+
+// Run executes every diagnostic check and returns the collected report
+func (d *Doctor) Run() *Report {
+	report := &Report{}
+
+	goVersion := d.checkGoToolchain(report)
+	d.checkEnvironment(report)
+	d.checkWasmExec(report)
+	d.checkTools(report)
+	d.checkRepository(report, goVersion)
+
+	for _, result := range report.Results {
+		switch result.Status {
+		case StatusError:
+			report.Errors++
+		case StatusWarning:
+			report.Warnings++
+		}
+	}
+
+	return report
+}
+
+// Healthy reports whether the environment passed all checks
+func (r *Report) Healthy(strict bool) bool {
+	if strict {
+		return r.Errors == 0 && r.Warnings == 0
+	}
+	return r.Errors == 0
+}
+
+// checkGoToolchain verifies that Go is installed and supports js/wasm
+func (d *Doctor) checkGoToolchain(report *Report) string {
+	output, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		d.add(report, "Go toolchain", "go", StatusError,
+			"Go toolchain not found in PATH",
+			"Install Go from https://go.dev/dl/ and make sure 'go' is in your PATH")
+		return ""
+	}
+
+	goVersion := strings.TrimSpace(string(output))
+	d.add(report, "Go toolchain", "go", StatusOK, goVersion, "")
+
+	targets, err := exec.Command("go", "tool", "dist", "list").Output()
+	if err != nil || !strings.Contains(string(targets), "js/wasm") {
+		d.add(report, "Go toolchain", "js/wasm target", StatusError,
+			"toolchain does not list the js/wasm target",
+			"Use an official Go distribution; custom builds may omit the js/wasm port")
+	} else {
+		d.add(report, "Go toolchain", "js/wasm target", StatusOK, "supported", "")
+	}
+
+	return goVersion
+}
+
+// checkEnvironment looks for environment variables that break module builds
+func (d *Doctor) checkEnvironment(report *Report) {
+	goos, goarch := os.Getenv("GOOS"), os.Getenv("GOARCH")
+
+	switch {
+	case goos == "" && goarch == "":
+		d.add(report, "Environment", "GOOS/GOARCH", StatusOK, "not set (host defaults)", "")
+	case goos == "js" && goarch == "wasm":
+		d.add(report, "Environment", "GOOS/GOARCH", StatusWarning,
+			"exported globally as js/wasm",
+			"Unset GOOS and GOARCH; wasm-manager sets them per module and a global value breaks 'go build' of the manager itself")
+	case (goos == "js") != (goarch == "wasm") && (goos == "js" || goarch == "wasm"):
+		d.add(report, "Environment", "GOOS/GOARCH", StatusError,
+			fmt.Sprintf("inconsistent pair GOOS=%s GOARCH=%s", goos, goarch),
+			"Unset GOOS and GOARCH or set both consistently (js/wasm)")
+	default:
+		d.add(report, "Environment", "GOOS/GOARCH", StatusWarning,
+			fmt.Sprintf("set to %s/%s", goos, goarch),
+			"Unset GOOS and GOARCH unless you are cross-compiling the manager on purpose")
+	}
+
+	if os.Getenv("GO111MODULE") == "off" {
+		d.add(report, "Environment", "GO111MODULE", StatusError,
+			"module mode is disabled",
+			"Unset GO111MODULE or set it to 'on'; every module has its own go.mod")
+	}
+
+	if flags := os.Getenv("GOFLAGS"); strings.Contains(flags, "-mod=vendor") {
+		d.add(report, "Environment", "GOFLAGS", StatusWarning,
+			fmt.Sprintf("GOFLAGS=%s", flags),
+			"Remove -mod=vendor from GOFLAGS; modules are not vendored")
+	}
+}
+
+// checkWasmExec compares the shared wasm_exec.js with the one shipped by the toolchain
+func (d *Doctor) checkWasmExec(report *Report) {
+	sharedPath := filepath.Join(d.config.RootDir, "shared", "wasm_exec.js")
+	shared, err := os.ReadFile(sharedPath)
+	if err != nil {
+		d.add(report, "Runtime", "wasm_exec.js", StatusError,
+			fmt.Sprintf("%s not found", sharedPath),
+			"cp \"$(go env GOROOT)/lib/wasm/wasm_exec.js\" shared/")
+		return
+	}
+
+	output, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		d.add(report, "Runtime", "wasm_exec.js", StatusWarning,
+			"cannot locate GOROOT to compare versions", "")
+		return
+	}
+	goroot := strings.TrimSpace(string(output))
+
+	// Go 1.24 moved the support files from misc/wasm to lib/wasm
+	var toolchainPath string
+	for _, candidate := range []string{
+		filepath.Join(goroot, "lib", "wasm", "wasm_exec.js"),
+		filepath.Join(goroot, "misc", "wasm", "wasm_exec.js"),
+	} {
+		if fileExists(candidate) {
+			toolchainPath = candidate
+			break
+		}
+	}
+
+	if toolchainPath == "" {
+		d.add(report, "Runtime", "wasm_exec.js", StatusWarning,
+			"toolchain copy of wasm_exec.js not found", "")
+		return
+	}
+
+	toolchain, err := os.ReadFile(toolchainPath)
+	if err != nil {
+		d.add(report, "Runtime", "wasm_exec.js", StatusWarning,
+			fmt.Sprintf("failed to read %s: %v", toolchainPath, err), "")
+		return
+	}
+
+	if bytes.Equal(normalizeNewlines(shared), normalizeNewlines(toolchain)) {
+		d.add(report, "Runtime", "wasm_exec.js", StatusOK, "matches the installed toolchain", "")
+		return
+	}
+
+	d.add(report, "Runtime", "wasm_exec.js", StatusError,
+		"shared/wasm_exec.js differs from the installed toolchain",
+		fmt.Sprintf("cp \"%s\" shared/  (modules built with this toolchain fail to start with a mismatched runtime)", toolchainPath))
+}
+
+// checkTools verifies optimization and compression tools
+func (d *Doctor) checkTools(report *Report) {
+	tools := []struct {
+		command string
+		hint    string
+	}{
+		{"wasm-opt", "Run 'wasm-manager install-tools --binaryen' or build with --optimize=false"},
+		{"gzip", "Install gzip or build with --compress=false"},
+		{"brotli", "Run 'wasm-manager install-tools' to enable .br output"},
+		{"wasm2wat", "Run 'wasm-manager install-tools --wabt'"},
+		{"wat2wasm", "Run 'wasm-manager install-tools --wabt'"},
+		{"base64", "Install coreutils"},
+	}
+
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool.command); err != nil {
+			d.add(report, "Tools", tool.command, StatusWarning, "not installed", tool.hint)
+			continue
+		}
+		d.add(report, "Tools", tool.command, StatusOK, toolVersion(tool.command), "")
+	}
+}
+
+// checkRepository looks for common module misconfigurations
+func (d *Doctor) checkRepository(report *Report, goVersion string) {
+	entries, err := os.ReadDir(d.config.RootDir)
+	if err != nil {
+		d.add(report, "Repository", d.config.RootDir, StatusError,
+			fmt.Sprintf("failed to read directory: %v", err), "")
+		return
+	}
+
+	found := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), "-wasm") {
+			continue
+		}
+		found++
+		d.checkModule(report, entry.Name(), goVersion)
+	}
+
+	if found == 0 {
+		d.add(report, "Repository", "modules", StatusError,
+			"no *-wasm module directories found",
+			"Run wasm-manager from the repository root")
+	}
+}
+
+// checkModule checks a single module directory
+func (d *Doctor) checkModule(report *Report, module, goVersion string) {
+	modulePath := filepath.Join(d.config.RootDir, module)
+	var problems []string
+	var hints []string
+	status := StatusOK
+
+	fail := func(s Status, problem, hint string) {
+		if s > status {
+			status = s
+		}
+		problems = append(problems, problem)
+		if hint != "" {
+			hints = append(hints, hint)
+		}
+	}
+
+	if !fileExists(filepath.Join(modulePath, "go.mod")) {
+		fail(StatusError, "missing go.mod", fmt.Sprintf("cd %s && go mod init %s", module, module))
+	} else {
+		goMod, _ := os.ReadFile(filepath.Join(modulePath, "go.mod"))
+		required := goModVersion(string(goMod))
+		if required != "" && goVersion != "" && compareVersions(required, goVersion) > 0 {
+			fail(StatusError, fmt.Sprintf("requires go %s (toolchain is %s)", required, goVersion),
+				"Upgrade Go or lower the 'go' directive in go.mod")
+		}
+		if strings.Contains(string(goMod), "require") && !fileExists(filepath.Join(modulePath, "go.sum")) {
+			fail(StatusError, "go.sum missing", fmt.Sprintf("cd %s && go mod tidy", module))
+		}
+	}
+
+	mainGo, err := os.ReadFile(filepath.Join(modulePath, "main.go"))
+	if err != nil {
+		fail(StatusError, "missing main.go", "")
+	} else if !strings.Contains(string(mainGo), "//go:build js && wasm") {
+		fail(StatusWarning, "main.go lacks '//go:build js && wasm'",
+			"Add the build constraint so host tooling skips the module")
+	}
+
+	moduleJSON, err := os.ReadFile(filepath.Join(modulePath, "module.json"))
+	if err != nil {
+		fail(StatusError, "missing module.json", "Run 'wasm-manager validate --fix'")
+	} else {
+		var meta struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(moduleJSON, &meta); err != nil {
+			fail(StatusError, fmt.Sprintf("module.json is invalid JSON: %v", err), "")
+		} else if meta.Name != "" && meta.Name != module && meta.Name+"-wasm" != module {
+			fail(StatusWarning, fmt.Sprintf("module.json name %q does not match directory", meta.Name),
+				"Rename the module or fix the 'name' field")
+		}
+	}
+
+	if fileExists(filepath.Join(modulePath, "main.wasm")) && !fileExists(filepath.Join(modulePath, "main.wasm.integrity")) {
+		fail(StatusWarning, "main.wasm has no integrity file",
+			fmt.Sprintf("wasm-manager build %s", module))
+	}
+
+	if status == StatusOK {
+		d.add(report, "Repository", module, StatusOK, "ok", "")
+		return
+	}
+	d.add(report, "Repository", module, status, strings.Join(problems, "; "), strings.Join(hints, "; "))
+}
+
+// PrintReport prints the diagnostic report grouped by category
+func PrintReport(report *Report, verbose bool) {
+	fmt.Println("🩺 WASM Manager Doctor")
+	fmt.Println("======================")
+
+	category := ""
+	for _, result := range report.Results {
+		if result.Category != category {
+			category = result.Category
+			fmt.Printf("\n%s\n", category)
+		}
+
+		if result.Status == StatusOK && !verbose && category == "Repository" {
+			continue
+		}
+
+		icon := "✅"
+		switch result.Status {
+		case StatusWarning:
+			icon = "⚠️ "
+		case StatusError:
+			icon = "❌"
+		}
+
+		fmt.Printf("  %s %-16s %s\n", icon, result.Name, result.Message)
+		if result.Hint != "" && result.Status != StatusOK {
+			fmt.Printf("     💡 %s\n", result.Hint)
+		}
+	}
+
+	fmt.Printf("\n📊 %d checks, %d errors, %d warnings\n", len(report.Results), report.Errors, report.Warnings)
+}
+
+func (d *Doctor) add(report *Report, category, name string, status Status, message, hint string) {
+	report.Results = append(report.Results, &CheckResult{
+		Category: category,
+		Name:     name,
+		Status:   status,
+		Message:  message,
+		Hint:     hint,
+	})
+}
+
+// Helper functions
+func goModVersion(goMod string) string {
+	for _, line := range strings.Split(goMod, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "go" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// compareVersions compares two Go versions such as "1.21" and "go1.22.3"
+func compareVersions(a, b string) int {
+	pa, pb := parseVersion(a), parseVersion(b)
+	for i := 0; i < 3; i++ {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(version string) [3]int {
+	var parts [3]int
+	if !strings.HasPrefix(version, "go") {
+		version = "go" + version
+	}
+	match := goVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return parts
+	}
+	for i, part := range strings.Split(match[1], ".") {
+		parts[i], _ = strconv.Atoi(part)
+	}
+	return parts
+}
+
+func toolVersion(command string) string {
+	output, err := exec.Command(command, "--version").Output()
+	if err != nil {
+		return "installed"
+	}
+	line := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	if line == "" {
+		return "installed"
+	}
+	return line
+}
+
+func normalizeNewlines(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,282 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// Dependency represents a single require directive of a module
+type Dependency struct {
+	Path     string `json:"path"`
+	Version  string `json:"version"`
+	Indirect bool   `json:"indirect,omitempty"`
+}
+
+// ModuleDeps holds the dependencies declared in a module's go.mod
+type ModuleDeps struct {
+	Module     string       `json:"module"`
+	ModulePath string       `json:"modulePath"`
+	GoVersion  string       `json:"goVersion"`
+	Requires   []Dependency `json:"requires"`
+}
+
+// Usage records which version of a dependency a module requires
+type Usage struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Indirect bool   `json:"indirect,omitempty"`
+}
+
+// SharedDependency is a dependency required by more than one module
+type SharedDependency struct {
+	Path  string  `json:"path"`
+	Users []Usage `json:"users"`
+}
+
+// Skew describes a dependency required at different versions across modules
+type Skew struct {
+	Path      string              `json:"path"`
+	Versions  map[string][]string `json:"versions"`
+	Suggested string              `json:"suggested"`
+}
+
+// Graph is the dependency graph of all analyzed modules
+type Graph struct {
+	Modules    []*ModuleDeps       `json:"modules"`
+	Shared     []*SharedDependency `json:"shared"`
+	Skews      []*Skew             `json:"skews"`
+	GoVersions map[string][]string `json:"goVersions"`
+	// Suggested is the common version set: the highest required version of every shared dependency
+	Suggested map[string]string `json:"suggested"`
+}
+
+// Options controls which dependencies are analyzed
+type Options struct {
+	DirectOnly bool
+}
+
+// Analyze parses the go.mod of every module and builds the dependency graph
+func Analyze(rootDir string, modules []string, opts Options) (*Graph, error) {
+	g := &Graph{
+		GoVersions: make(map[string][]string),
+		Suggested:  make(map[string]string),
+	}
+
+	usages := make(map[string][]Usage)
+
+	for _, module := range modules {
+		goModPath := filepath.Join(rootDir, module, "go.mod")
+		data, err := os.ReadFile(goModPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", goModPath, err)
+		}
+
+		f, err := modfile.ParseLax(goModPath, data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", goModPath, err)
+		}
+
+		deps := &ModuleDeps{Module: module}
+		if f.Module != nil {
+			deps.ModulePath = f.Module.Mod.Path
+		}
+		if f.Go != nil {
+			deps.GoVersion = f.Go.Version
+			g.GoVersions[f.Go.Version] = append(g.GoVersions[f.Go.Version], module)
+		}
+
+		for _, req := range f.Require {
+			if opts.DirectOnly && req.Indirect {
+				continue
+			}
+			deps.Requires = append(deps.Requires, Dependency{
+				Path:     req.Mod.Path,
+				Version:  req.Mod.Version,
+				Indirect: req.Indirect,
+			})
+			usages[req.Mod.Path] = append(usages[req.Mod.Path], Usage{
+				Module:   module,
+				Version:  req.Mod.Version,
+				Indirect: req.Indirect,
+			})
+		}
+
+		g.Modules = append(g.Modules, deps)
+	}
+
+	paths := make([]string, 0, len(usages))
+	for path := range usages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		users := usages[path]
+		if len(users) < 2 {
+			continue
+		}
+
+		g.Shared = append(g.Shared, &SharedDependency{Path: path, Users: users})
+
+		versions := make(map[string][]string)
+		highest := ""
+		for _, u := range users {
+			versions[u.Version] = append(versions[u.Version], u.Module)
+			if highest == "" || semver.Compare(u.Version, highest) > 0 {
+				highest = u.Version
+			}
+		}
+		g.Suggested[path] = highest
+
+		if len(versions) > 1 {
+			g.Skews = append(g.Skews, &Skew{Path: path, Versions: versions, Suggested: highest})
+		}
+	}
+
+	return g, nil
+}
+
+// Upgrades returns the "go get" commands that align each module on the suggested version set
+func (g *Graph) Upgrades() map[string][]string {
+	upgrades := make(map[string][]string)
+	for _, skew := range g.Skews {
+		for version, modules := range skew.Versions {
+			if version == skew.Suggested {
+				continue
+			}
+			for _, module := range modules {
+				upgrades[module] = append(upgrades[module], fmt.Sprintf("%s@%s", skew.Path, skew.Suggested))
+			}
+		}
+	}
+	for module := range upgrades {
+		sort.Strings(upgrades[module])
+	}
+	return upgrades
+}
+
+// WriteDOT writes the graph in Graphviz DOT format.
+// Shared dependencies are highlighted and skewed ones are drawn in red.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	skewed := make(map[string]bool)
+	for _, skew := range g.Skews {
+		skewed[skew.Path] = true
+	}
+	shared := make(map[string]bool)
+	for _, dep := range g.Shared {
+		shared[dep.Path] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph modules {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n\n")
+
+	for _, m := range g.Modules {
+		fmt.Fprintf(&b, "  %q [style=filled, fillcolor=\"#cfe2ff\"];\n", m.Module)
+	}
+	b.WriteString("\n")
+
+	declared := make(map[string]bool)
+	for _, m := range g.Modules {
+		for _, dep := range m.Requires {
+			if !declared[dep.Path] {
+				declared[dep.Path] = true
+				switch {
+				case skewed[dep.Path]:
+					fmt.Fprintf(&b, "  %q [shape=ellipse, color=red, fontcolor=red];\n", dep.Path)
+				case shared[dep.Path]:
+					fmt.Fprintf(&b, "  %q [shape=ellipse, style=filled, fillcolor=\"#d1e7dd\"];\n", dep.Path)
+				default:
+					fmt.Fprintf(&b, "  %q [shape=ellipse];\n", dep.Path)
+				}
+			}
+
+			attrs := []string{fmt.Sprintf("label=%q", dep.Version)}
+			if dep.Indirect {
+				attrs = append(attrs, "style=dashed")
+			}
+			if skewed[dep.Path] {
+				attrs = append(attrs, "color=red")
+			}
+			fmt.Fprintf(&b, "  %q -> %q [%s];\n", m.Module, dep.Path, strings.Join(attrs, ", "))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// PrintSummary prints a human readable summary of shared dependencies and version skew
+func (g *Graph) PrintSummary(verbose bool) {
+	fmt.Println("🕸️  Module Dependency Graph")
+	fmt.Println("==========================")
+
+	for _, m := range g.Modules {
+		fmt.Printf("📦 %-14s go %-6s %d dependencies\n", m.Module, m.GoVersion, len(m.Requires))
+		if verbose {
+			for _, dep := range m.Requires {
+				suffix := ""
+				if dep.Indirect {
+					suffix = " (indirect)"
+				}
+				fmt.Printf("   • %s %s%s\n", dep.Path, dep.Version, suffix)
+			}
+		}
+	}
+
+	fmt.Printf("\n🔗 Shared dependencies (%d)\n", len(g.Shared))
+	for _, dep := range g.Shared {
+		modules := make([]string, len(dep.Users))
+		for i, u := range dep.Users {
+			modules[i] = u.Module
+		}
+		fmt.Printf("   • %s ← %s\n", dep.Path, strings.Join(modules, ", "))
+	}
+
+	if len(g.GoVersions) > 1 {
+		fmt.Println("\n⚠️  Go directive differs across modules:")
+		for _, version := range sortedKeys(g.GoVersions) {
+			fmt.Printf("   • go %s: %s\n", version, strings.Join(g.GoVersions[version], ", "))
+		}
+	}
+
+	if len(g.Skews) == 0 {
+		fmt.Println("\n✅ No version skew detected")
+		return
+	}
+
+	fmt.Printf("\n⚠️  Version skew (%d)\n", len(g.Skews))
+	for _, skew := range g.Skews {
+		fmt.Printf("   • %s\n", skew.Path)
+		versions := sortedKeys(skew.Versions)
+		semver.Sort(versions)
+		for _, version := range versions {
+			fmt.Printf("       %-40s %s\n", version, strings.Join(skew.Versions[version], ", "))
+		}
+	}
+
+	fmt.Println("\n💡 Suggested alignment:")
+	upgrades := g.Upgrades()
+	for _, module := range sortedKeys(upgrades) {
+		fmt.Printf("   cd %s && go get %s && go mod tidy\n", module, strings.Join(upgrades[module], " "))
+	}
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
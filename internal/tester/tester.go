@@ -3,14 +3,19 @@ package tester
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"wasm-manager/internal/wasmexec"
 )
 
 // Tester handles module testing
 type Tester struct {
-	config *Config
+	config  *Config
+	runtime *wasmexec.Runtime
+	node    string
 }
 
 // Config holds tester configuration
@@ -23,10 +28,11 @@ type Config struct {
 
 // TestResult represents the result of testing a module
 type TestResult struct {
-	Module string          `json:"module"`
-	Passed bool            `json:"passed"`
-	Errors []string        `json:"errors,omitempty"`
-	Tests  map[string]bool `json:"tests"`
+	Module   string          `json:"module"`
+	Passed   bool            `json:"passed"`
+	Errors   []string        `json:"errors,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Tests    map[string]bool `json:"tests"`
 }
 
 // New creates a new Tester instance
@@ -48,6 +54,18 @@ func (t *Tester) TestModules(modules []string) ([]*TestResult, error) {
 		modules = discoveredModules
 	}
 
+	// Integration tests load the built modules with the managed wasm_exec.js
+	if t.config.Integration {
+		rt, err := wasmexec.Load(".")
+		if err != nil {
+			return nil, fmt.Errorf("%w (run 'wasm-manager runtime' to install wasm_exec.js)", err)
+		}
+		t.runtime = rt
+		if node, err := exec.LookPath("node"); err == nil {
+			t.node = node
+		}
+	}
+
 	results := make([]*TestResult, len(modules))
 
 	for i, module := range modules {
@@ -84,6 +102,11 @@ func (t *Tester) testModule(module string) *TestResult {
 	// Test module.json documentation
 	t.testModuleJsonDocumentation(modulePath, result)
 
+	// Test the built binary against runtime/wasm_exec.js
+	if t.config.Integration {
+		t.testRuntime(modulePath, result)
+	}
+
 	// Determine if all tests passed
 	result.Passed = len(result.Errors) == 0
 
@@ -161,7 +184,7 @@ func (t *Tester) testFunctionRegistration(modulePath string, result *TestResult)
 
 	source := string(content)
 
-	functions := []string{"getAvailableFunctions", "setSilentMode"}
+	functions := []string{"getAvailableFunctions", "setSilentMode", "getModuleInfo", "getMemoryStats", "releaseResources", "setLocale"}
 
 	for _, fn := range functions {
 		pattern := fmt.Sprintf(`js\.FuncOf\(%s\)`, fn)
@@ -198,6 +221,63 @@ func (t *Tester) testModuleJsonDocumentation(modulePath string, result *TestResu
 	}
 }
 
+// testRuntime checks that the built module only imports host functions defined by the managed
+// wasm_exec.js, then loads it in Node.js and compares the Go version it was built with
+func (t *Tester) testRuntime(modulePath string, result *TestResult) {
+	scriptPath := filepath.Join(wasmexec.Dir, wasmexec.ScriptFile)
+	wasmPath := filepath.Join(modulePath, "main.wasm")
+	wasm, err := os.ReadFile(wasmPath)
+	if err != nil {
+		result.Errors = append(result.Errors, "main.wasm not found (run 'wasm-manager build' first)")
+		return
+	}
+
+	missing, err := wasmexec.MissingImports(wasm, t.runtime.Script)
+	result.Tests["runtime_imports"] = err == nil && len(missing) == 0
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to read main.wasm imports: %v", err))
+		return
+	}
+	if len(missing) > 0 {
+		result.Errors = append(result.Errors, fmt.Sprintf("main.wasm imports %s, not defined by %s",
+			strings.Join(missing, ", "), scriptPath))
+	}
+
+	if t.runtime.Modified {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s was modified after 'wasm-manager runtime' copied it", scriptPath))
+	}
+
+	if t.node == "" {
+		result.Warnings = append(result.Warnings, "node not found, main.wasm was not loaded")
+		return
+	}
+
+	built := ""
+	loaded, err := wasmexec.LoadModule(t.node, scriptPath, wasmPath)
+	switch {
+	case err != nil:
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to load main.wasm with %s: %v", scriptPath, err))
+	case !loaded.Registered:
+		result.Errors = append(result.Errors, fmt.Sprintf("main.wasm started with %s but did not register getModuleInfo", scriptPath))
+	default:
+		built = loaded.GoVersion
+	}
+	result.Tests["runtime_load"] = err == nil && loaded.Registered
+
+	// A module that failed to start still carries the version of the toolchain that built it
+	if built == "" {
+		built = wasmexec.BuildVersion(wasm)
+	}
+	result.Tests["runtime_go_version"] = built == t.runtime.GoVersion
+	if built != t.runtime.GoVersion {
+		if built == "" {
+			built = "an unknown Go version"
+		}
+		result.Warnings = append(result.Warnings, fmt.Sprintf("built with %s but %s is from %s (rebuild the module or run 'wasm-manager runtime')",
+			built, scriptPath, t.runtime.GoVersion))
+	}
+}
+
 // discoverModules finds all WASM modules
 func (t *Tester) discoverModules(rootDir string) ([]string, error) {
 	var modules []string
@@ -244,6 +324,10 @@ func PrintTestSummary(results []*TestResult) (passed, total int) {
 				fmt.Printf("   • %s\n", err)
 			}
 		}
+
+		for _, warning := range result.Warnings {
+			fmt.Printf("   ⚠️  %s\n", warning)
+		}
 	}
 
 	fmt.Printf("\n📊 Results: %d/%d modules passed\n", passed, total)
@@ -156,6 +156,10 @@ func (v *Validator) checkGoSource(modulePath string, result *ValidationResult) {
 	requiredFunctions := []string{
 		"getAvailableFunctions",
 		"setSilentMode",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setLocale",
 	}
 
 	for _, fn := range requiredFunctions {
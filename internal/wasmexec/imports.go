@@ -0,0 +1,173 @@
+package wasmexec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Import is a function, table, memory or global a WebAssembly binary expects from its host
+type Import struct {
+	Module string
+	Name   string
+}
+
+var errTruncated = errors.New("truncated WebAssembly binary")
+
+// Imports lists the imports declared in the import section of a WebAssembly binary
+func Imports(wasm []byte) ([]Import, error) {
+	if len(wasm) < 8 || !bytes.Equal(wasm[:4], []byte("\x00asm")) {
+		return nil, errors.New("not a WebAssembly binary")
+	}
+
+	r := &wasmReader{data: wasm, pos: 8}
+	for r.pos < len(r.data) {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.uleb()
+		if err != nil {
+			return nil, err
+		}
+		if size > uint64(len(r.data)-r.pos) {
+			return nil, errTruncated
+		}
+		end := r.pos + int(size)
+
+		// The import section (id 2) comes before any section that could depend on it
+		if id == 2 {
+			return (&wasmReader{data: r.data[:end], pos: r.pos}).imports()
+		}
+		r.pos = end
+	}
+	return nil, nil
+}
+
+// MissingImports returns the host functions imported by a Go binary that script does not define.
+// Go binaries import from the "gojs" module, or "go" before Go 1.21, and wasm_exec.js defines
+// every function under its quoted name.
+func MissingImports(wasm, script []byte) ([]string, error) {
+	imports, err := Imports(wasm)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, imp := range imports {
+		if imp.Module != "gojs" && imp.Module != "go" {
+			continue
+		}
+		if !bytes.Contains(script, []byte(strconv.Quote(imp.Name))) {
+			missing = append(missing, imp.Module+"."+imp.Name)
+		}
+	}
+	return missing, nil
+}
+
+type wasmReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *wasmReader) imports() ([]Import, error) {
+	count, err := r.uleb()
+	if err != nil {
+		return nil, err
+	}
+	var imports []Import
+	for i := uint64(0); i < count; i++ {
+		module, err := r.name()
+		if err != nil {
+			return nil, err
+		}
+		name, err := r.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := r.skipDescriptor(); err != nil {
+			return nil, fmt.Errorf("import %s.%s: %w", module, name, err)
+		}
+		imports = append(imports, Import{Module: module, Name: name})
+	}
+	return imports, nil
+}
+
+func (r *wasmReader) skipDescriptor() error {
+	kind, err := r.byte()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case 0x00: // function: type index
+		_, err = r.uleb()
+	case 0x01: // table: reference type and limits
+		if _, err = r.byte(); err == nil {
+			err = r.skipLimits()
+		}
+	case 0x02: // memory: limits
+		err = r.skipLimits()
+	case 0x03: // global: value type and mutability
+		if _, err = r.byte(); err == nil {
+			_, err = r.byte()
+		}
+	case 0x04: // tag: attribute and type index
+		if _, err = r.byte(); err == nil {
+			_, err = r.uleb()
+		}
+	default:
+		err = fmt.Errorf("unknown import kind 0x%02x", kind)
+	}
+	return err
+}
+
+func (r *wasmReader) skipLimits() error {
+	flags, err := r.byte()
+	if err != nil {
+		return err
+	}
+	if _, err := r.uleb(); err != nil {
+		return err
+	}
+	if flags&0x01 != 0 {
+		_, err = r.uleb()
+	}
+	return err
+}
+
+func (r *wasmReader) name() (string, error) {
+	length, err := r.uleb()
+	if err != nil {
+		return "", err
+	}
+	if length > uint64(len(r.data)-r.pos) {
+		return "", errTruncated
+	}
+	name := string(r.data[r.pos : r.pos+int(length)])
+	r.pos += int(length)
+	return name, nil
+}
+
+func (r *wasmReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncated
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *wasmReader) uleb() (uint64, error) {
+	var value uint64
+	for shift := 0; shift < 64; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, errors.New("invalid LEB128 integer")
+}
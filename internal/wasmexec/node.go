@@ -0,0 +1,100 @@
+package wasmexec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// LoadTimeout bounds the time a module may take to instantiate and start
+const LoadTimeout = 30 * time.Second
+
+// resultMarker prefixes the line the loader script prints, so module output is ignored
+const resultMarker = "WASM_MANAGER_LOAD "
+
+// loaderScript instantiates a module with the given wasm_exec.js and reports the Go version
+// returned by its getModuleInfo. go.run returns once main blocks, with the functions registered.
+// A module built by another Go release may start without registering anything.
+const loaderScript = `
+const fs = require("fs");
+const report = (result) => {
+	process.stdout.write("\n" + "` + resultMarker + `" + JSON.stringify(result) + "\n", () => process.exit(0));
+};
+try {
+	require(process.env.WASM_EXEC);
+	const go = new Go();
+	WebAssembly.instantiate(fs.readFileSync(process.env.WASM_FILE), go.importObject)
+		.then(({ instance }) => {
+			go.run(instance);
+			if (typeof globalThis.getModuleInfo !== "function") {
+				report({ registered: false });
+				return;
+			}
+			let info = globalThis.getModuleInfo();
+			if (typeof info === "string") {
+				info = JSON.parse(info);
+			}
+			report({ registered: true, goVersion: (info && info.goVersion) || "" });
+		})
+		.catch((err) => report({ error: String((err && err.message) || err) }));
+} catch (err) {
+	report({ error: String((err && err.message) || err) });
+}
+`
+
+// LoadResult is what a module reported once loaded
+type LoadResult struct {
+	Registered bool   `json:"registered"`
+	GoVersion  string `json:"goVersion"`
+	Error      string `json:"error"`
+}
+
+// LoadModule instantiates wasmPath in Node.js with the wasm_exec.js at scriptPath and returns the
+// Go version the module reports through getModuleInfo. Registered is false when the module
+// started without defining getModuleInfo.
+func LoadModule(node, scriptPath, wasmPath string) (*LoadResult, error) {
+	script, err := filepath.Abs(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	wasm, err := filepath.Abs(wasmPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), LoadTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, node, "-e", loaderScript)
+	cmd.Env = append(os.Environ(), "WASM_EXEC="+script, "WASM_FILE="+wasm)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("module did not start within %s", LoadTimeout)
+	}
+
+	index := bytes.LastIndex(output, []byte(resultMarker))
+	if index < 0 {
+		if err == nil {
+			err = errors.New("no result")
+		}
+		return nil, fmt.Errorf("node failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	result := &LoadResult{}
+	line, _, _ := bytes.Cut(output[index+len(resultMarker):], []byte("\n"))
+	if err := json.Unmarshal(line, result); err != nil {
+		return nil, fmt.Errorf("invalid loader output: %w", err)
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	return result, nil
+}
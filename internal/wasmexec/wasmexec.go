@@ -0,0 +1,158 @@
+package wasmexec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Dir is the repository directory holding the managed copy of wasm_exec.js
+const Dir = "runtime"
+
+// ScriptFile is the name of the JavaScript support file shipped with the Go toolchain
+const ScriptFile = "wasm_exec.js"
+
+// ManifestFile records which toolchain the managed wasm_exec.js was copied from
+const ManifestFile = "runtime.json"
+
+// goVersionPattern matches release versions such as go1.24.3 or go1.25rc1
+var goVersionPattern = regexp.MustCompile(`go1\.\d+(?:\.\d+)?(?:(?:rc|beta)\d+)?`)
+
+// Toolchain describes the active Go toolchain
+type Toolchain struct {
+	GoRoot     string
+	GoVersion  string
+	ScriptPath string
+}
+
+// Manifest is the content of runtime/runtime.json
+type Manifest struct {
+	GoVersion string `json:"goVersion"`
+	SHA256    string `json:"sha256"`
+}
+
+// Runtime is the managed wasm_exec.js of a repository
+type Runtime struct {
+	Manifest
+	ScriptPath string
+	Script     []byte
+	// Modified reports that wasm_exec.js no longer matches the checksum in runtime.json
+	Modified bool
+}
+
+// DetectToolchain asks the go command on PATH for its version and the location of wasm_exec.js
+func DetectToolchain() (*Toolchain, error) {
+	output, err := exec.Command("go", "env", "GOROOT", "GOVERSION").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run go env: %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 || lines[0] == "" || lines[1] == "" {
+		return nil, fmt.Errorf("unexpected go env output: %q", output)
+	}
+
+	tc := &Toolchain{
+		GoRoot:    strings.TrimSpace(lines[0]),
+		GoVersion: strings.TrimSpace(lines[1]),
+	}
+
+	// Go 1.24 moved the support files from misc/wasm to lib/wasm
+	for _, candidate := range []string{
+		filepath.Join(tc.GoRoot, "lib", "wasm", ScriptFile),
+		filepath.Join(tc.GoRoot, "misc", "wasm", ScriptFile),
+	} {
+		if _, err := os.Stat(candidate); err == nil {
+			tc.ScriptPath = candidate
+			return tc, nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found in %s", ScriptFile, tc.GoRoot)
+}
+
+// Load reads the managed wasm_exec.js and its manifest from rootDir
+func Load(rootDir string) (*Runtime, error) {
+	rt := &Runtime{ScriptPath: filepath.Join(rootDir, Dir, ScriptFile)}
+
+	manifestPath := filepath.Join(rootDir, Dir, ManifestFile)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", manifestPath, err)
+	}
+	if err := json.Unmarshal(data, &rt.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestPath, err)
+	}
+
+	rt.Script, err = os.ReadFile(rt.ScriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rt.ScriptPath, err)
+	}
+	rt.Modified = checksum(rt.Script) != rt.SHA256
+
+	return rt, nil
+}
+
+// Install copies the wasm_exec.js of tc into rootDir/runtime and records its version. It returns
+// the runtime previously installed, or nil when there was none or it could not be read.
+func Install(rootDir string, tc *Toolchain) (previous *Runtime, err error) {
+	previous, _ = Load(rootDir)
+
+	script, err := os.ReadFile(tc.ScriptPath)
+	if err != nil {
+		return previous, fmt.Errorf("failed to read %s: %w", tc.ScriptPath, err)
+	}
+
+	data, err := json.MarshalIndent(Manifest{GoVersion: tc.GoVersion, SHA256: checksum(script)}, "", "  ")
+	if err != nil {
+		return previous, fmt.Errorf("failed to encode %s: %w", ManifestFile, err)
+	}
+
+	dir := filepath.Join(rootDir, Dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return previous, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ScriptFile), script, 0644); err != nil {
+		return previous, fmt.Errorf("failed to write %s: %w", ScriptFile, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return previous, fmt.Errorf("failed to write %s: %w", ManifestFile, err)
+	}
+
+	return previous, nil
+}
+
+// Matches reports whether the runtime is an unmodified copy of the wasm_exec.js of tc
+func (rt *Runtime) Matches(tc *Toolchain) bool {
+	if rt.Modified || rt.GoVersion != tc.GoVersion {
+		return false
+	}
+	script, err := os.ReadFile(tc.ScriptPath)
+	return err == nil && bytes.Equal(script, rt.Script)
+}
+
+// BuildVersion returns the Go release a binary was built with, found by scanning it for the
+// version string the linker stores in its data section, or "" if there is none. A module reports
+// the same value through getModuleInfo; use this when it cannot be loaded.
+func BuildVersion(wasm []byte) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, match := range goVersionPattern.FindAll(wasm, -1) {
+		version := string(match)
+		counts[version]++
+		if counts[version] > counts[best] {
+			best = version
+		}
+	}
+	return best
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -4,12 +4,13 @@ go 1.21
 
 require (
 	github.com/antchfx/xmlquery v1.4.4
+	github.com/antchfx/xpath v1.3.3
+	github.com/pelletier/go-toml/v2 v2.1.0
+	golang.org/x/net v0.33.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/antchfx/xpath v1.3.3 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
-	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
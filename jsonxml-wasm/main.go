@@ -4,14 +4,38 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall/js"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 	"gopkg.in/yaml.v3"
 )
 
@@ -38,24 +62,6 @@ type XMLResult struct {
 	Error    string      `json:"error,omitempty"`
 }
 
-// CSVResult represents a CSV operation result
-type CSVResult struct {
-	Data    interface{} `json:"data"`
-	Rows    int         `json:"rows"`
-	Columns int         `json:"columns"`
-	Format  string      `json:"format"`
-	Error   string      `json:"error,omitempty"`
-}
-
-// YAMLResult represents a YAML operation result
-type YAMLResult struct {
-	Data   interface{} `json:"data"`
-	Valid  bool        `json:"valid"`
-	Size   int         `json:"size"`
-	Format string      `json:"format"`
-	Error  string      `json:"error,omitempty"`
-}
-
 // ValidationResult represents validation result
 type ValidationResult struct {
 	Valid    bool     `json:"valid"`
@@ -72,11 +78,377 @@ func setSilentMode(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(silentMode)
 }
 
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires exactly 1 argument (%s)":                     "%s requiert exactement 1 argument (%s)",
+	"Invalid JSON: %v":                                        "JSON invalide: %v",
+	"%s requires at least 1 argument (%s)":                    "%s requiert au moins 1 argument (%s)",
+	"Failed to stringify JSON: %v":                            "Échec de la sérialisation JSON: %v",
+	"Failed to minify JSON: %v":                               "Échec de la minification JSON: %v",
+	"Invalid XML: %v":                                         "XML invalide: %v",
+	"Failed to convert to JSON: %v":                           "Échec de la conversion en JSON: %v",
+	"Invalid CSV: %v":                                         "CSV invalide: %v",
+	"Empty CSV data":                                          "Données CSV vides",
+	"Empty JSON array":                                        "Tableau JSON vide",
+	"Invalid YAML: %v":                                        "YAML invalide: %v",
+	"Failed to convert to YAML: %v":                           "Échec de la conversion en YAML: %v",
+	"Invalid TOML: %v":                                        "TOML invalide: %v",
+	"Failed to convert to TOML: %v":                           "Échec de la conversion en TOML: %v",
+	"Invalid INI: %v":                                         "INI invalide: %v",
+	"Failed to convert to INI: %v":                            "Échec de la conversion en INI: %v",
+	"line %d, column %d: %v":                                  "ligne %d, colonne %d: %v",
+	"the JSON value must be an object":                        "la valeur JSON doit être un objet",
+	"TOML cannot represent null (at %s)":                      "TOML ne peut pas représenter null (à %s)",
+	"line %d: unterminated section header":                    "ligne %d: en-tête de section non terminé",
+	"line %d: unexpected %q after section header":             "ligne %d: %q inattendu après l'en-tête de section",
+	"line %d: empty section name":                             "ligne %d: nom de section vide",
+	"line %d: section %q is also a key":                       "ligne %d: la section %q est aussi une clé",
+	"line %d: expected a section, key = value or comment":     "ligne %d: section, clé = valeur ou commentaire attendu",
+	"key %q cannot be written to INI":                         "la clé %q ne peut pas être écrite en INI",
+	"section %q cannot be written to INI":                     "la section %q ne peut pas être écrite en INI",
+	"value %q cannot be written to INI":                       "la valeur %q ne peut pas être écrite en INI",
+	"INI values cannot contain line breaks (at %s)":           "les valeurs INI ne peuvent pas contenir de saut de ligne (à %s)",
+	"INI arrays can only hold scalar values (at %s)":          "les tableaux INI ne peuvent contenir que des valeurs scalaires (à %s)",
+	"Invalid MessagePack: %v":                                 "MessagePack invalide: %v",
+	"Invalid CBOR: %v":                                        "CBOR invalide: %v",
+	"Invalid BSON: %v":                                        "BSON invalide: %v",
+	"Invalid chunk: %v":                                       "Fragment invalide: %v",
+	"Unknown NDJSON parser %q (it may already be finished)":   "Analyseur NDJSON %q inconnu (il est peut-être déjà terminé)",
+	"maxLineLength must be a positive number of bytes":        "maxLineLength doit être un nombre d'octets positif",
+	"line longer than %d bytes":                               "ligne de plus de %d octets",
+	"line %d: %v":                                             "ligne %d: %v",
+	"Failed to convert to MessagePack: %v":                    "Échec de la conversion en MessagePack: %v",
+	"Failed to convert to CBOR: %v":                           "Échec de la conversion en CBOR: %v",
+	"expected a Uint8Array, ArrayBuffer or base64 string":     "Uint8Array, ArrayBuffer ou chaîne base64 attendu",
+	"empty input":                                             "entrée vide",
+	"unexpected data after the value at offset %d":            "données inattendues après la valeur à la position %d",
+	"unexpected end of data at offset %d":                     "fin des données inattendue à la position %d",
+	"nesting deeper than %d levels at offset %d":              "imbrication de plus de %d niveaux à la position %d",
+	"length %d at offset %d exceeds the remaining data":       "la longueur %d à la position %d dépasse les données restantes",
+	"unsupported map key at offset %d":                        "clé de map non prise en charge à la position %d",
+	"invalid byte 0x%02x at offset %d":                        "octet 0x%02x invalide à la position %d",
+	"invalid timestamp at offset %d":                          "horodatage invalide à la position %d",
+	"invalid content for tag %d at offset %d":                 "contenu invalide pour le tag %d à la position %d",
+	"unexpected break at offset %d":                           "break inattendu à la position %d",
+	"unsupported simple value %d at offset %d":                "valeur simple %d non prise en charge à la position %d",
+	"invalid document size %d at offset %d":                   "taille de document %d invalide à la position %d",
+	"unterminated document at offset %d":                      "document non terminé à la position %d",
+	"unterminated string at offset %d":                        "chaîne non terminée à la position %d",
+	"invalid string length %d at offset %d":                   "longueur de chaîne %d invalide à la position %d",
+	"invalid binary length %d at offset %d":                   "longueur binaire %d invalide à la position %d",
+	"invalid boolean 0x%02x at offset %d":                     "booléen 0x%02x invalide à la position %d",
+	"unknown element type 0x%02x at offset %d":                "type d'élément 0x%02x inconnu à la position %d",
+	"%s requires exactly 2 arguments (%s)":                    "%s requiert exactement 2 arguments (%s)",
+	"Failed to serialize result: %v":                          "Échec de la sérialisation du résultat: %v",
+	"setLocale requires exactly 1 argument (locale)":          "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":                   "Langue %q non prise en charge (disponibles: %s)",
+	"Invalid JSON schema: %v":                                 "Schéma JSON invalide: %v",
+	"count must be between 1 and %d":                          "count doit être compris entre 1 et %d",
+	"Failed to generate mock data: %v":                        "Échec de la génération des données fictives: %v",
+	"unsupported $ref %q, only local references are resolved": "$ref %q non pris en charge, seules les références locales sont résolues",
+	"unresolvable $ref %q":                                    "$ref %q introuvable",
+	"the false schema accepts no value":                       "le schéma false n'accepte aucune valeur",
+	"schema must be an object or a boolean":                   "le schéma doit être un objet ou un booléen",
+	"$ref %q recurses without an exit":                        "$ref %q est récursif sans fin",
+	"unsupported type %q":                                     "type %q non pris en charge",
+	"cannot generate enough unique items":                     "impossible de générer assez d'éléments uniques",
+	"invalid pattern %q: %v":                                  "pattern %q invalide: %v",
+	"minimum is greater than maximum":                         "minimum est supérieur à maximum",
+	"no value satisfies the numeric constraints":              "aucune valeur ne satisfait les contraintes numériques",
+	"%s requires at least 2 arguments (%s)":                   "%s requiert au moins 2 arguments (%s)",
+	"Invalid options: %v":                                     "Options invalides: %v",
+	"Unknown array strategy %q":                               "Stratégie de tableau %q inconnue",
+	"Invalid JSONPath at position %d: %s":                     "JSONPath invalide à la position %d: %s",
+	"unexpected data after the JSON value":                    "données inattendues après la valeur JSON",
+	"unexpected %q":                                           "%q inattendu",
+	"%q expected":                                             "%q attendu",
+	"member name expected":                                    "nom de membre attendu",
+	"selector expected":                                       "sélecteur attendu",
+	"expression expected":                                     "expression attendue",
+	"invalid escape":                                          "séquence d'échappement invalide",
+	"unterminated string":                                     "chaîne non terminée",
+	"invalid number %q":                                       "nombre %q invalide",
+	"comparisons need queries selecting a single value":       "les comparaisons requièrent des requêtes sélectionnant une seule valeur",
+	"%s() takes a query":                                      "%s() prend une requête",
+	"Invalid patch: %v":                                       "Patch invalide: %v",
+	"Patch operation %d (%s) failed: %v":                      "Échec de l'opération de patch %d (%s): %v",
+	"invalid JSON pointer %q":                                 "pointeur JSON %q invalide",
+	"invalid array index %q":                                  "indice de tableau %q invalide",
+	"array index %d out of range":                             "indice de tableau %d hors limites",
+	"member %q not found":                                     "membre %q introuvable",
+	"cannot read %q of a scalar":                              "impossible de lire %q dans un scalaire",
+	"cannot add %q to a scalar":                               "impossible d'ajouter %q à un scalaire",
+	"cannot remove the whole document":                        "impossible de supprimer le document entier",
+	"missing %q":                                              "%q manquant",
+	"cannot move a value into itself":                         "impossible de déplacer une valeur dans elle-même",
+	"unknown operation %q":                                    "opération %q inconnue",
+	"value at %q differs":                                     "la valeur à %q est différente",
+	"tolerance must not be negative":                          "tolerance ne doit pas être négative",
+	"no differences":                                          "aucune différence",
+	"%d added, %d removed, %d changed":                        "%d ajoutés, %d supprimés, %d modifiés",
+	"%s requires a JSON object or array":                      "%s nécessite un objet ou un tableau JSON",
+	"%s requires a JSON object":                               "%s nécessite un objet JSON",
+	"delimiter must not be empty":                             "le délimiteur ne doit pas être vide",
+	"Unknown array mode %q":                                   "Mode de tableau %q inconnu",
+	"key %q is produced twice":                                "la clé %q est produite deux fois",
+	"key %q conflicts with another key":                       "la clé %q est en conflit avec une autre clé",
+	"Invalid XPath: %v":                                       "XPath invalide: %v",
+	"Invalid JSON data: %v":                                   "Données JSON invalides: %v",
+	"no value is allowed here":                                "aucune valeur n'est autorisée ici",
+	"must be %s, got %s":                                      "doit être %s, reçu %s",
+	" or ":                                                    " ou ",
+	"must be one of the enum values":                          "doit être une des valeurs de enum",
+	"must equal the const value":                              "doit être égal à la valeur const",
+	"must match at least one schema in anyOf":                 "doit valider au moins un schéma de anyOf",
+	"must match exactly one schema in oneOf (%d matched)":     "doit valider exactement un schéma de oneOf (%d validés)",
+	"must not match the schema in not":                        "ne doit pas valider le schéma de not",
+	"must be a multiple of %v":                                "doit être un multiple de %v",
+	"must be <= %v":                                           "doit être <= %v",
+	"must be < %v":                                            "doit être < %v",
+	"must be >= %v":                                           "doit être >= %v",
+	"must be > %v":                                            "doit être > %v",
+	"must be at most %v characters":                           "doit faire au plus %v caractères",
+	"must be at least %v characters":                          "doit faire au moins %v caractères",
+	"must match pattern %q":                                   "doit correspondre au pattern %q",
+	"must be a valid %s":                                      "doit être un %s valide",
+	"must contain at least %v matching items":                 "doit contenir au moins %v éléments valides",
+	"must contain at most %v matching items":                  "doit contenir au plus %v éléments valides",
+	"must have at most %v items":                              "doit avoir au plus %v éléments",
+	"must have at least %v items":                             "doit avoir au moins %v éléments",
+	"items %d and %d are equal":                               "les éléments %d et %d sont égaux",
+	"property %q is not allowed":                              "la propriété %q n'est pas autorisée",
+	"property name %q: %s":                                    "nom de propriété %q: %s",
+	"missing required property %q":                            "propriété requise %q manquante",
+	"property %q requires property %q":                        "la propriété %q requiert la propriété %q",
+	"must have at most %v properties":                         "doit avoir au plus %v propriétés",
+	"must have at least %v properties":                        "doit avoir au moins %v propriétés",
+	"Invalid XPath at position %d: %s":                        "XPath invalide à la position %d: %s",
+	"Invalid XSLT stylesheet: %v":                             "Feuille de style XSLT invalide: %v",
+	"XSLT transformation failed: %v":                          "Échec de la transformation XSLT: %v",
+	"undeclared namespace prefix %q":                          "préfixe d'espace de noms %q non déclaré",
+	"the document has more than one root element":             "le document a plus d'un élément racine",
+	"the document has no root element":                        "le document n'a pas d'élément racine",
+	"unexpected end tag </%s>":                                "balise fermante </%s> inattendue",
+	"text outside the root element":                           "texte en dehors de l'élément racine",
+	"unclosed element <%s>":                                   "élément <%s> non fermé",
+	"variable name expected":                                  "nom de variable attendu",
+	"unknown axis %q":                                         "axe %q inconnu",
+	"patterns only use the child and attribute axes":          "les motifs n'utilisent que les axes child et attribute",
+	"expression does not evaluate to a node-set":              "l'expression ne renvoie pas un ensemble de nœuds",
+	"undefined variable $%s":                                  "variable $%s non définie",
+	"circular definition of $%s":                              "définition circulaire de $%s",
+	"unknown function %s()":                                   "fonction %s() inconnue",
+	"wrong number of arguments for %s()":                      "nombre d'arguments incorrect pour %s()",
+	"unknown decimal format %q":                               "format décimal %q inconnu",
+	"unknown key %q":                                          "clé %q inconnue",
+	"document() is not supported":                             "document() n'est pas pris en charge",
+	"the root element is not an xsl:stylesheet":               "l'élément racine n'est pas un xsl:stylesheet",
+	"<%s> requires a %s attribute":                            "<%s> requiert un attribut %s",
+	"invalid priority %q":                                     "priorité %q invalide",
+	"xsl:%s is not supported":                                 "xsl:%s n'est pas pris en charge",
+	"unknown instruction xsl:%s":                              "instruction xsl:%s inconnue",
+	"no template named %q":                                    "aucun modèle nommé %q",
+	"unknown attribute set %q":                                "ensemble d'attributs %q inconnu",
+	"attribute set %q uses itself":                            "l'ensemble d'attributs %q s'utilise lui-même",
+	"invalid name %q":                                         "nom %q invalide",
+	"unterminated attribute value template %q":                "modèle de valeur d'attribut %q non terminé",
+	"templates nest deeper than %d levels":                    "les modèles s'imbriquent sur plus de %d niveaux",
+	"xsl:message terminated the transformation: %s":           "xsl:message a interrompu la transformation: %s",
+	"unsupported output method %q":                            "méthode de sortie %q non prise en charge",
+	"delimiter must be a single character":                    "le délimiteur doit être un seul caractère",
+	"sampleSize must not be negative":                         "sampleSize ne doit pas être négatif",
+	"Invalid expression at position %d: %s":                   "Expression invalide à la position %d: %s",
+	"Transformation failed: %v":                               "Échec de la transformation: %v",
+	"assignment operators are not supported":                  "les opérateurs d'affectation ne sont pas pris en charge",
+	"%s is not supported":                                     "%s n'est pas pris en charge",
+	"unknown format @%s":                                      "format @%s inconnu",
+	"unknown function %s/%d":                                  "fonction %s/%d inconnue",
+	"%s (not a string)":                                       "%s (pas une chaîne)",
+	"recursion deeper than %d levels":                         "récursion sur plus de %d niveaux",
+	"cannot index %s with %s":                                 "impossible d'indexer %s avec %s",
+	"cannot iterate over %s":                                  "impossible d'itérer sur %s",
+	"object keys must be strings, got %s":                     "les clés d'objet doivent être des chaînes, reçu %s",
+	"%s cannot be negated":                                    "%s ne peut pas être rendu négatif",
+	"%s and %s cannot be added":                               "%s et %s ne peuvent pas être additionnés",
+	"%s and %s cannot be subtracted":                          "%s et %s ne peuvent pas être soustraits",
+	"%s and %s cannot be multiplied":                          "%s et %s ne peuvent pas être multipliés",
+	"%s and %s cannot be divided":                             "%s et %s ne peuvent pas être divisés",
+	"%s and %s cannot be divided because the divisor is zero": "%s et %s ne peuvent pas être divisés car le diviseur est nul",
+	"%s and %s cannot have their containment checked":         "impossible de vérifier si %s contient %s",
+	"cannot check whether %s has the key %s":                  "impossible de vérifier si %s a la clé %s",
+	"%s cannot be formatted with @%s":                         "%s ne peut pas être formaté avec @%s",
+	"%s is not valid base64 data":                             "%s n'est pas une donnée base64 valide",
+	"%s() requires %s input, got %s":                          "%s() requiert une entrée %s, reçu %s",
+	"%s() requires a %s argument, got %s":                     "%s() requiert un argument %s, reçu %s",
+	"%s cannot be parsed as a number":                         "%s ne peut pas être converti en nombre",
+	"%s cannot be joined":                                     "%s ne peut pas être joint",
+	"flatten depth must not be negative":                      "la profondeur de flatten ne doit pas être négative",
+	"unsupported regex flag %q":                               "option d'expression régulière %q non prise en charge",
+	"%s does not match the date format %s":                    "%s ne correspond pas au format de date %s",
+	"at least one sample document is required":                "au moins un document d'exemple est requis",
+	"rootName %q has no letters or digits":                    "rootName %q ne contient ni lettre ni chiffre",
+	"indent must be 0 to 16 spaces, or spaces and tabs":       "indent doit être 0 à 16 espaces, ou des espaces et tabulations",
+	"no element has the ID %q":                                "aucun élément n'a l'ID %q",
+	"Unknown conversion mode %q (supported: legacy, xml2js)":  "Mode de conversion %q inconnu (pris en charge: legacy, xml2js)",
+	"Invalid validation rules: %v":                            "Règles de validation invalides: %v",
+	"maxErrors must be at least 1":                            "maxErrors doit être au moins 1",
+	"row %d is not an object":                                 "la ligne %d n'est pas un objet",
+	"format must be json or csv, got %q":                      "format doit être json ou csv, %q reçu",
+	"rules must be an object of field rules":                  "les règles doivent être un objet de règles par champ",
+	"invalid rule for %s: %v":                                 "règle invalide pour %s: %v",
+	"unknown type %q":                                         "type %q inconnu",
+	"unknown comparison operator %q":                          "opérateur de comparaison %q inconnu",
+	"is required":                                             "est requis",
+	"must be one of %s":                                       "doit être l'une des valeurs %s",
+	"duplicates the value of row %d":                          "reprend la valeur de la ligne %d",
+	"must be %s %s (%s)":                                      "doit être %s %s (%s)",
+	"data must be an array of records":                        "les données doivent être un tableau d'enregistrements",
+	"Invalid HTML: %v":                                        "HTML invalide: %v",
+	"headers must be auto, first or none, got %q":             "headers doit être auto, first ou none, %q reçu",
+	"Invalid descriptor: %v":                                  "Descripteur invalide: %v",
+	"Failed to encode protobuf: %v":                           "Échec de l'encodage protobuf: %v",
+	"Invalid protobuf: %v":                                    "Protobuf invalide: %v",
+	"Unknown message type %q, load its descriptor first":      "Type de message %q inconnu, chargez d'abord son descripteur",
+	"varint longer than 10 bytes at offset %d":                "varint de plus de 10 octets à la position %d",
+	"invalid field number %d at offset %d":                    "numéro de champ %d invalide à la position %d",
+	"invalid wire type %d at offset %d":                       "type de codage %d invalide à la position %d",
+	"unexpected end of group %d at offset %d":                 "fin de groupe %d inattendue à la position %d",
+	"group %d has no end tag":                                 "le groupe %d n'a pas de balise de fin",
+	"no file descriptors found":                               "aucun descripteur de fichier trouvé",
+	"message type without a name in %s":                       "type de message sans nom dans %s",
+	"invalid field %q":                                        "champ %q invalide",
+	"messages nested deeper than %d levels":                   "messages imbriqués sur plus de %d niveaux",
+	"field %s has wire type %d, expected %d":                  "le champ %s a le type de codage %d au lieu de %d",
+	"invalid map entry type %s":                               "type d'entrée de map %s invalide",
+	"field %s holds invalid UTF-8":                            "le champ %s contient de l'UTF-8 invalide",
+	"invalid %s value":                                        "valeur %s invalide",
+	"%s has none of its kinds set":                            "%s n'a aucune de ses variantes définie",
+	"%s: expected %s, got %s":                                 "%s: %s attendu, %s reçu",
+	"%s: unknown field %q":                                    "%s: champ %q inconnu",
+	"%s: field %s is set twice":                               "%s: le champ %s est défini deux fois",
+	"%s: fields %s and %s of the same oneof are both set":     "%s: les champs %s et %s du même oneof sont tous deux définis",
+	"%s: invalid map key %q":                                  "%s: clé de map %q invalide",
+	"%s: invalid base64: %v":                                  "%s: base64 invalide: %v",
+	"%s: %v is out of range for %s":                           "%s: %v est hors limites pour %s",
+	"%s: unknown value %q of enum %s":                         "%s: valeur %q inconnue pour l'enum %s",
+	"%s: invalid %s value %q":                                 "%s: valeur %s invalide %q",
+	"%s: %s requires an @type member":                         "%s: %s requiert un membre @type",
+	"Invalid GeoJSON: %v":                                     "GeoJSON invalide: %v",
+	"expected a GeoJSON object, got %s":                       "objet GeoJSON attendu, %s reçu",
+	"bbox must be an array of 4 or 6 numbers":                 "bbox doit être un tableau de 4 ou 6 nombres",
+	"bbox south %v is above north %v":                         "le sud %v de bbox est au-dessus du nord %v",
+	"crs is obsolete, RFC 7946 coordinates are WGS 84":        "crs est obsolète, les coordonnées RFC 7946 sont en WGS 84",
+	"expected a %s":                                           "%s attendu",
+	"a Feature requires a %s member, null when it has none":   "une Feature requiert un membre %s, null s'il est vide",
+	"properties must be an object or null":                    "properties doit être un objet ou null",
+	"id must be a string or a number":                         "id doit être une chaîne ou un nombre",
+	"expected a geometry object, got %s":                      "objet géométrie attendu, %s reçu",
+	"a %s requires a %s array":                                "un %s requiert un tableau %s",
+	"nested GeometryCollections should be avoided":            "les GeometryCollection imbriquées sont à éviter",
+	"unknown GeoJSON type %q":                                 "type GeoJSON %q inconnu",
+	"expected an array, got %s":                               "tableau attendu, %s reçu",
+	"a line needs at least 2 positions, got %d":               "une ligne requiert au moins 2 positions, %d reçues",
+	"a polygon ring needs at least 4 positions, got %d":       "un anneau de polygone requiert au moins 4 positions, %d reçues",
+	"a polygon ring must end with its first position":         "un anneau de polygone doit finir par sa première position",
+	"exterior rings should be counterclockwise":               "les anneaux extérieurs devraient être dans le sens antihoraire",
+	"holes should be clockwise":                               "les trous devraient être dans le sens horaire",
+	"a position needs at least 2 numbers":                     "une position requiert au moins 2 nombres",
+	"expected a number, got %s":                               "nombre attendu, %s reçu",
+	"positions should have no more than 3 elements":           "les positions ne devraient pas avoir plus de 3 éléments",
+	"latitude %v is out of range (positions are [lon, lat])":  "latitude %v hors limites (les positions sont [lon, lat])",
+	"longitude %v is out of range":                            "longitude %v hors limites",
+	"latitude %v is out of range":                             "latitude %v hors limites",
+	"row %d (line %d): %v":                                    "enregistrement %d (ligne %d): %v",
+	"no %s column found, set the %s option":                   "aucune colonne %s trouvée, définissez l'option %s",
+	"column %q not found":                                     "colonne %q introuvable",
+	"invalid coordinate %q":                                   "coordonnée %q invalide",
+	"expected a FeatureCollection or a Feature, got a %s":     "FeatureCollection ou Feature attendue, %s reçu",
+	"geometry latlon only writes Point features, use wkt":     "geometry latlon n'écrit que des Point, utilisez wkt",
+	"geometry must be auto, latlon or wkt, got %q":            "geometry doit être auto, latlon ou wkt, %q reçu",
+	"property %q clashes with a generated column":             "la propriété %q entre en conflit avec une colonne générée",
+	"unexpected end of input":                                 "fin de l'entrée inattendue",
+	"Invalid JSON5: %v":                                       "JSON5 invalide: %v",
+	"invalid escape sequence":                                 "séquence d'échappement invalide",
+	"nesting deeper than %d levels":                           "imbrication de plus de %d niveaux",
+	"unterminated comment":                                    "commentaire non terminé",
+	"octal escapes are not allowed":                           "les échappements octaux ne sont pas autorisés",
+	"expected ':' after member name %q":                       "':' attendu après le nom de membre %q",
+	"expected ',' or '}' after member %q":                     "',' ou '}' attendu après le membre %q",
+	"expected ',' or ']' after element %d":                    "',' ou ']' attendu après l'élément %d",
+	"invalid identifier character %q":                         "caractère d'identifiant invalide %q",
+	"unescaped line break in string":                          "saut de ligne non échappé dans une chaîne",
+}
+
 // parseJSON - Parse JSON string and validate
 func parseJSON(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
 		return js.ValueOf(JSONResult{
-			Error: "parseJSON requires exactly 1 argument (jsonString)",
+			Error: localize("%s requires exactly 1 argument (%s)", "parseJSON", "jsonString"),
 		})
 	}
 
@@ -90,7 +462,7 @@ func parseJSON(this js.Value, args []js.Value) interface{} {
 			Valid:  false,
 			Size:   len(jsonString),
 			Format: "json",
-			Error:  fmt.Sprintf("Invalid JSON: %v", err),
+			Error:  localize("Invalid JSON: %v", err),
 		})
 	}
 
@@ -106,11 +478,75 @@ func parseJSON(this js.Value, args []js.Value) interface{} {
 	})
 }
 
+// parseJSON5 - Parse JSON5 or relaxed JSON, as found in hand-written config files: comments, trailing
+// commas, unquoted keys, single-quoted strings, hexadecimal numbers, NaN and Infinity. data is the JS
+// value, or with {strict: true} the document as strict JSON text.
+func parseJSON5(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "parseJSON5", "json5String"),
+		})
+	}
+	var options json5Options
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	text := args[0].String()
+	p := &json5Parser{src: text}
+	data, err := p.document()
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON5: %v", err),
+			"format": "json",
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Parsed JSON5 (%d bytes)\n", len(text))
+	}
+
+	if options.Strict {
+		return js.ValueOf(jsonDataResult(data))
+	}
+
+	// JSON.parse builds the value, then the numbers JSON cannot hold are set in place
+	compact, err := json.Marshal(data)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to serialize result: %v", err),
+		})
+	}
+	value := js.Global().Get("JSON").Call("parse", string(compact))
+	for _, special := range p.nonFinite {
+		if len(special.path) == 0 {
+			value = js.ValueOf(special.value)
+			continue
+		}
+		parent := value
+		for _, step := range special.path[:len(special.path)-1] {
+			parent = step.get(parent)
+		}
+		special.path[len(special.path)-1].set(parent, special.value)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"data":   value,
+		"valid":  true,
+		"format": "json",
+	})
+}
+
 // stringifyJSON - Convert object to JSON string
 func stringifyJSON(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(JSONResult{
-			Error: "stringifyJSON requires at least 1 argument (data)",
+			Error: localize("%s requires at least 1 argument (%s)", "stringifyJSON", "data"),
 		})
 	}
 
@@ -134,7 +570,7 @@ func stringifyJSON(this js.Value, args []js.Value) interface{} {
 
 	if err != nil {
 		return js.ValueOf(JSONResult{
-			Error: fmt.Sprintf("Failed to stringify JSON: %v", err),
+			Error: localize("Failed to stringify JSON: %v", err),
 		})
 	}
 
@@ -189,7 +625,7 @@ func validateJSON(this js.Value, args []js.Value) interface{} {
 func minifyJSON(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
 		return js.ValueOf(JSONResult{
-			Error: "minifyJSON requires exactly 1 argument (jsonString)",
+			Error: localize("%s requires exactly 1 argument (%s)", "minifyJSON", "jsonString"),
 		})
 	}
 
@@ -201,7 +637,7 @@ func minifyJSON(this js.Value, args []js.Value) interface{} {
 	if err != nil {
 		return js.ValueOf(JSONResult{
 			Valid:  false,
-			Error:  fmt.Sprintf("Invalid JSON: %v", err),
+			Error:  localize("Invalid JSON: %v", err),
 			Format: "json",
 		})
 	}
@@ -209,7 +645,7 @@ func minifyJSON(this js.Value, args []js.Value) interface{} {
 	minifiedBytes, err := json.Marshal(data)
 	if err != nil {
 		return js.ValueOf(JSONResult{
-			Error: fmt.Sprintf("Failed to minify JSON: %v", err),
+			Error: localize("Failed to minify JSON: %v", err),
 		})
 	}
 
@@ -232,11 +668,71 @@ func minifyJSON(this js.Value, args []js.Value) interface{} {
 	})
 }
 
+// profileJSON - Profile a JSON document to debug oversized payloads: value and key counts, depth, type
+// histogram, the largest arrays, objects, strings and subtrees, and an estimate of its memory once parsed
+func profileJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "profileJSON", "jsonString"),
+		})
+	}
+	options := jsonProfileOptions{Top: 10}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	if options.Top < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", localize("top must be at least 1")),
+		})
+	}
+
+	text, err := documentText(args[0])
+	var data interface{}
+	if err == nil {
+		data, err = decodeOrdered([]byte(text), true)
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "json",
+		})
+	}
+
+	p := newJSONProfile(options.Top)
+	minified := p.visit(data)
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Profiled JSON (%d bytes, %d values, depth %d)\n", len(text), p.nodes, p.depth)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"valid":           true,
+		"size":            len(text),
+		"minifiedSize":    minified,
+		"nodes":           p.nodes,
+		"depth":           p.depth,
+		"deepestPath":     p.deepestPath,
+		"types":           p.types,
+		"keys":            p.keyStats(options.Top),
+		"largestArrays":   p.arrays.list(),
+		"largestObjects":  p.objects.list(),
+		"largestStrings":  p.strings.list(),
+		"largestSubtrees": p.subtrees.list(),
+		"estimatedMemory": p.memory,
+		"format":          "json",
+	})
+}
+
 // parseXML - Parse XML string and validate
 func parseXML(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
 		return js.ValueOf(XMLResult{
-			Error: "parseXML requires exactly 1 argument (xmlString)",
+			Error: localize("%s requires exactly 1 argument (%s)", "parseXML", "xmlString"),
 		})
 	}
 
@@ -249,7 +745,7 @@ func parseXML(this js.Value, args []js.Value) interface{} {
 			Valid:  false,
 			Size:   len(xmlString),
 			Format: "xml",
-			Error:  fmt.Sprintf("Invalid XML: %v", err),
+			Error:  localize("Invalid XML: %v", err),
 		})
 	}
 
@@ -275,73 +771,84 @@ func parseXML(this js.Value, args []js.Value) interface{} {
 	})
 }
 
-// xmlToJSON - Convert XML to JSON
+// xmlToJSON - Convert XML to JSON. The default conversion lists child elements under "children";
+// mode: "xml2js" follows the xml2js conventions instead, keeping namespace prefixes, attributes
+// under "$", text under "_" and repeated elements as arrays.
 func xmlToJSON(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(JSONResult{
-			Error: "xmlToJSON requires exactly 1 argument (xmlString)",
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "xmlToJSON", "xmlString"),
 		})
 	}
 
 	xmlString := args[0].String()
 
-	doc, err := xmlquery.Parse(strings.NewReader(xmlString))
-	if err != nil {
-		return js.ValueOf(JSONResult{
-			Valid:  false,
-			Error:  fmt.Sprintf("Invalid XML: %v", err),
-			Format: "json",
-		})
+	options := xml2jsOptions{Mode: "legacy", AttrKey: "$", CharKey: "_", ExplicitArray: true, ExplicitRoot: true}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
 	}
 
-	// Convert XML to map structure
-	data := xmlNodeToMap(doc)
-
-	// Convert to JSON
-	jsonBytes, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return js.ValueOf(JSONResult{
-			Error: fmt.Sprintf("Failed to convert to JSON: %v", err),
+	var data interface{}
+	switch options.Mode {
+	case "legacy":
+		doc, err := xmlquery.Parse(strings.NewReader(xmlString))
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"valid":  false,
+				"error":  localize("Invalid XML: %v", err),
+				"format": "json",
+			})
+		}
+		// Convert XML to map structure
+		data = xmlNodeToMap(doc)
+	case "xml2js":
+		doc, err := parseXNodes(xmlString)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"valid":  false,
+				"error":  localize("Invalid XML: %v", err),
+				"format": "json",
+			})
+		}
+		data = options.document(doc)
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown conversion mode %q (supported: legacy, xml2js)", options.Mode),
 		})
 	}
 
-	jsonString := string(jsonBytes)
-
-	if !silentMode {
-		fmt.Printf("XML WASM: Converted XML to JSON (%d → %d bytes)\n",
-			len(xmlString), len(jsonString))
+	result := jsonDataResult(data)
+	if !silentMode && result["error"] == nil {
+		fmt.Printf("XML WASM: Converted XML to JSON (%d bytes)\n", len(xmlString))
 	}
 
-	return js.ValueOf(JSONResult{
-		Data:   jsonString,
-		Valid:  true,
-		Size:   len(jsonString),
-		Format: "json",
-	})
+	return js.ValueOf(result)
 }
 
 // jsonToXML - Convert JSON to XML
 func jsonToXML(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
-		return js.ValueOf(XMLResult{
-			Error: "jsonToXML requires at least 1 argument (jsonString)",
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "jsonToXML", "jsonString"),
 		})
 	}
 
-	jsonString := args[0].String()
 	rootElement := "root"
 
 	if len(args) > 1 {
 		rootElement = args[1].String()
 	}
 
-	var data interface{}
-	err := json.Unmarshal([]byte(jsonString), &data)
+	data, err := decodeDocument(args[0])
 	if err != nil {
-		return js.ValueOf(XMLResult{
-			Valid:  false,
-			Error:  fmt.Sprintf("Invalid JSON: %v", err),
-			Format: "xml",
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "xml",
 		})
 	}
 
@@ -350,17 +857,16 @@ func jsonToXML(this js.Value, args []js.Value) interface{} {
 	xmlString = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + xmlString
 
 	if !silentMode {
-		fmt.Printf("XML WASM: Converted JSON to XML (%d → %d bytes)\n",
-			len(jsonString), len(xmlString))
+		fmt.Printf("XML WASM: Converted JSON to XML (%d bytes)\n", len(xmlString))
 	}
 
-	return js.ValueOf(XMLResult{
-		Data:     xmlString,
-		Valid:    true,
-		Size:     len(xmlString),
-		Format:   "xml",
-		Root:     rootElement,
-		Encoding: "UTF-8",
+	return js.ValueOf(map[string]interface{}{
+		"data":     xmlString,
+		"valid":    true,
+		"size":     len(xmlString),
+		"format":   "xml",
+		"root":     rootElement,
+		"encoding": "UTF-8",
 	})
 }
 
@@ -396,549 +902,18506 @@ func validateXML(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(result)
 }
 
-// csvToJSON - Convert CSV to JSON
-func csvToJSON(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(JSONResult{
-			Error: "csvToJSON requires exactly 1 argument (csvString)",
-		})
-	}
-
-	csvString := args[0].String()
+// xpathOptions configures queryXML and queryXMLFirst: Namespaces maps the prefixes used in the
+// expression to namespace URIs, for documents whose prefixes differ or that use a default namespace
+type xpathOptions struct {
+	Namespaces map[string]string `json:"namespaces"`
+}
 
-	reader := csv.NewReader(strings.NewReader(csvString))
-	records, err := reader.ReadAll()
+// evaluateXPath parses the XML document and evaluates the XPath expression of a query function's
+// arguments. The result is a node iterator, or a float64, string or bool for scalar expressions.
+func evaluateXPath(name string, args []js.Value) (result interface{}, err error) {
+	if len(args) < 2 {
+		return nil, errors.New(localize("%s requires at least 2 arguments (%s)", name, "xmlString, xpath"))
+	}
+	var options xpathOptions
+	if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return nil, errors.New(localize("Invalid options: %v", err))
+		}
+	}
 
+	doc, err := xmlquery.Parse(strings.NewReader(args[0].String()))
 	if err != nil {
-		return js.ValueOf(JSONResult{
-			Valid:  false,
-			Error:  fmt.Sprintf("Invalid CSV: %v", err),
-			Format: "json",
-		})
+		return nil, errors.New(localize("Invalid XML: %v", err))
 	}
-
-	if len(records) == 0 {
-		return js.ValueOf(JSONResult{
-			Valid:  false,
-			Error:  "Empty CSV data",
-			Format: "json",
-		})
+	expr, err := xpath.CompileWithNS(args[1].String(), options.Namespaces)
+	if err != nil {
+		return nil, errors.New(localize("Invalid XPath: %v", err))
 	}
 
-	// Use first row as headers
-	headers := records[0]
-	var jsonData []map[string]interface{}
+	// The xpath package panics on some runtime errors, such as a function applied to the wrong type
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, errors.New(localize("Invalid XPath: %v", r))
+		}
+	}()
+	return expr.Evaluate(xmlquery.CreateXPathNavigator(doc)), nil
+}
 
-	for i := 1; i < len(records); i++ {
-		row := make(map[string]interface{})
-		for j, value := range records[i] {
-			if j < len(headers) {
-				// Try to convert numbers
-				if num, err := strconv.ParseFloat(value, 64); err == nil {
-					row[headers[j]] = num
-				} else if value == "true" || value == "false" {
-					row[headers[j]] = value == "true"
-				} else {
-					row[headers[j]] = value
+// xpathNodeName returns the qualified name of an element
+func xpathNodeName(node *xmlquery.Node) string {
+	if node.Prefix != "" {
+		return node.Prefix + ":" + node.Data
+	}
+	return node.Data
+}
+
+// xpathNodePath builds an absolute location path such as /rss/channel/item[2] for an element
+func xpathNodePath(node *xmlquery.Node) string {
+	var steps []string
+	for ; node != nil && node.Type == xmlquery.ElementNode; node = node.Parent {
+		position, count := 0, 0
+		for sibling := node.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+			if sibling.Type == xmlquery.ElementNode && xpathNodeName(sibling) == xpathNodeName(node) {
+				count++
+				if sibling == node {
+					position = count
 				}
 			}
 		}
-		jsonData = append(jsonData, row)
+		step := xpathNodeName(node)
+		if count > 1 {
+			step += "[" + strconv.Itoa(position) + "]"
+		}
+		steps = append([]string{step}, steps...)
 	}
+	return "/" + strings.Join(steps, "/")
+}
 
-	jsonBytes, err := json.MarshalIndent(jsonData, "", "  ")
-	if err != nil {
-		return js.ValueOf(JSONResult{
-			Error: fmt.Sprintf("Failed to convert to JSON: %v", err),
-		})
-	}
+// xpathMatch describes the node under the navigator as JSON, with its string value
+func xpathMatch(nav *xmlquery.NodeNavigator) (map[string]interface{}, string) {
+	node := nav.Current()
+	switch nav.NodeType() {
+	case xpath.AttributeNode:
+		name := nav.LocalName()
+		if nav.Prefix() != "" {
+			name = nav.Prefix() + ":" + name
+		}
+		return map[string]interface{}{
+			"type":  "attribute",
+			"name":  name,
+			"value": nav.Value(),
+			"path":  xpathNodePath(node) + "/@" + name,
+		}, nav.Value()
 
-	jsonString := string(jsonBytes)
+	case xpath.ElementNode:
+		attributes := map[string]interface{}{}
+		for _, attr := range node.Attr {
+			name := attr.Name.Local
+			if attr.Name.Space != "" {
+				name = attr.Name.Space + ":" + name
+			}
+			attributes[name] = attr.Value
+		}
+		match := map[string]interface{}{
+			"type":       "element",
+			"name":       xpathNodeName(node),
+			"path":       xpathNodePath(node),
+			"text":       strings.TrimSpace(node.InnerText()),
+			"attributes": attributes,
+			"data":       xmlNodeToMap(node),
+			"xml":        node.OutputXML(true),
+		}
+		if node.NamespaceURI != "" {
+			match["namespace"] = node.NamespaceURI
+		}
+		return match, node.InnerText()
 
-	if !silentMode {
-		fmt.Printf("CSV WASM: Converted CSV to JSON (%d rows → %d bytes)\n",
-			len(records)-1, len(jsonString))
+	case xpath.TextNode, xpath.CommentNode:
+		kind := "text"
+		if nav.NodeType() == xpath.CommentNode {
+			kind = "comment"
+		}
+		return map[string]interface{}{
+			"type": kind,
+			"text": node.Data,
+			"path": xpathNodePath(node.Parent) + "/" + kind + "()",
+		}, node.Data
 	}
 
-	return js.ValueOf(JSONResult{
-		Data:   jsonString,
-		Valid:  true,
-		Size:   len(jsonString),
-		Format: "json",
-	})
+	return map[string]interface{}{
+		"type": "document",
+		"text": strings.TrimSpace(node.InnerText()),
+		"xml":  node.OutputXML(false),
+	}, node.InnerText()
 }
 
-// jsonToCSV - Convert JSON to CSV
-func jsonToCSV(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(CSVResult{
-			Error: "jsonToCSV requires exactly 1 argument (jsonString)",
-		})
+// xpathScalarResult returns the value of an XPath expression that does not select nodes, such as count(//item)
+func xpathScalarResult(value interface{}) map[string]interface{} {
+	result := jsonDataResult(value)
+	if _, failed := result["error"]; failed {
+		return result
 	}
+	result["value"] = value
+	switch value.(type) {
+	case float64:
+		result["resultType"] = "number"
+	case bool:
+		result["resultType"] = "boolean"
+	default:
+		result["resultType"] = "string"
+	}
+	return result
+}
 
-	jsonString := args[0].String()
-
-	var data []map[string]interface{}
-	err := json.Unmarshal([]byte(jsonString), &data)
+// queryXML - Evaluate an XPath expression over an XML document, returning every matched node as JSON
+func queryXML(this js.Value, args []js.Value) interface{} {
+	evaluated, err := evaluateXPath("queryXML", args)
 	if err != nil {
-		return js.ValueOf(CSVResult{
-			Error:  fmt.Sprintf("Invalid JSON: %v", err),
-			Format: "csv",
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
 		})
 	}
+	iterator, ok := evaluated.(*xpath.NodeIterator)
+	if !ok {
+		return js.ValueOf(xpathScalarResult(evaluated))
+	}
 
-	if len(data) == 0 {
-		return js.ValueOf(CSVResult{
-			Error:  "Empty JSON array",
-			Format: "csv",
-		})
+	matches := []interface{}{}
+	values := []interface{}{}
+	for iterator.MoveNext() {
+		match, value := xpathMatch(iterator.Current().(*xmlquery.NodeNavigator))
+		matches = append(matches, match)
+		values = append(values, value)
 	}
 
-	// Extract headers from first object
-	var headers []string
-	for key := range data[0] {
-		headers = append(headers, key)
+	result := jsonDataResult(matches)
+	if _, failed := result["error"]; !failed {
+		result["values"] = values
+		result["count"] = len(matches)
+		result["resultType"] = "nodeset"
 	}
 
-	var buf bytes.Buffer
-	writer := csv.NewWriter(&buf)
+	if !silentMode {
+		fmt.Printf("XML WASM: XPath query '%s' matched %d nodes\n", args[1].String(), len(matches))
+	}
 
-	// Write headers
-	writer.Write(headers)
+	return js.ValueOf(result)
+}
 
-	// Write data rows
-	for _, row := range data {
-		var record []string
-		for _, header := range headers {
-			if value, exists := row[header]; exists {
-				record = append(record, fmt.Sprintf("%v", value))
-			} else {
-				record = append(record, "")
-			}
-		}
-		writer.Write(record)
+// queryXMLFirst - Evaluate an XPath expression over an XML document, returning only the first matched node
+func queryXMLFirst(this js.Value, args []js.Value) interface{} {
+	evaluated, err := evaluateXPath("queryXMLFirst", args)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	iterator, ok := evaluated.(*xpath.NodeIterator)
+	if !ok {
+		return js.ValueOf(xpathScalarResult(evaluated))
 	}
 
-	writer.Flush()
-	csvString := buf.String()
+	var match, value interface{}
+	found := iterator.MoveNext()
+	if found {
+		match, value = xpathMatch(iterator.Current().(*xmlquery.NodeNavigator))
+	}
+
+	result := jsonDataResult(match)
+	if _, failed := result["error"]; !failed {
+		result["value"] = value
+		result["found"] = found
+		result["resultType"] = "nodeset"
+	}
 
 	if !silentMode {
-		fmt.Printf("CSV WASM: Converted JSON to CSV (%d rows, %d columns)\n",
-			len(data), len(headers))
+		fmt.Printf("XML WASM: XPath query '%s' found a node: %v\n", args[1].String(), found)
 	}
 
-	return js.ValueOf(CSVResult{
-		Data:    csvString,
-		Rows:    len(data),
-		Columns: len(headers),
-		Format:  "csv",
-	})
+	return js.ValueOf(result)
 }
 
-// yamlToJSON - Convert YAML to JSON
-func yamlToJSON(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(JSONResult{
-			Error: "yamlToJSON requires exactly 1 argument (yamlString)",
+// transformXML - Apply an XSLT 1.0 stylesheet to an XML document, producing XML, HTML or text
+func transformXML(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "transformXML", "xmlString, xsltString"),
 		})
 	}
-
-	yamlString := args[0].String()
-
-	var data interface{}
-	err := yaml.Unmarshal([]byte(yamlString), &data)
-	if err != nil {
-		return js.ValueOf(JSONResult{
-			Valid:  false,
-			Error:  fmt.Sprintf("Invalid YAML: %v", err),
-			Format: "json",
-		})
+	var options xsltOptions
+	if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
 	}
 
-	jsonBytes, err := json.MarshalIndent(data, "", "  ")
+	output, method, proc, err := runXSLT(args[0].String(), args[1].String(), options)
 	if err != nil {
-		return js.ValueOf(JSONResult{
-			Error: fmt.Sprintf("Failed to convert to JSON: %v", err),
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
 		})
 	}
 
-	jsonString := string(jsonBytes)
+	mediaType := proc.output.mediaType
+	if mediaType == "" {
+		mediaType = map[string]string{"xml": "text/xml", "html": "text/html", "text": "text/plain"}[method]
+	}
+	messages := proc.messages
+	if messages == nil {
+		messages = []interface{}{}
+	}
 
 	if !silentMode {
-		fmt.Printf("YAML WASM: Converted YAML to JSON (%d → %d bytes)\n",
-			len(yamlString), len(jsonString))
+		fmt.Printf("XML WASM: XSLT transformation produced %d characters of %s\n", len(output), method)
 	}
 
-	return js.ValueOf(JSONResult{
-		Data:   jsonString,
-		Valid:  true,
-		Size:   len(jsonString),
-		Format: "json",
+	return js.ValueOf(map[string]interface{}{
+		"data":      output,
+		"valid":     true,
+		"size":      len(output),
+		"format":    method,
+		"mediaType": mediaType,
+		"encoding":  proc.outputEncoding(),
+		"messages":  messages,
 	})
 }
 
-// jsonToYAML - Convert JSON to YAML
-func jsonToYAML(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(YAMLResult{
-			Error: "jsonToYAML requires exactly 1 argument (jsonString)",
+// formatXML - Pretty-print an XML document, indenting element-only content; text, mixed content and
+// xml:space="preserve" elements are written unchanged
+func formatXML(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "formatXML", "xmlString"),
 		})
 	}
 
-	jsonString := args[0].String()
+	indent := "  "
+	if len(args) > 1 {
+		switch args[1].Type() {
+		case js.TypeNumber:
+			if n := args[1].Int(); n >= 0 && n <= 16 {
+				indent = strings.Repeat(" ", n)
+			} else {
+				indent = "\x00"
+			}
+		case js.TypeString:
+			indent = args[1].String()
+		case js.TypeUndefined, js.TypeNull:
+		default:
+			indent = "\x00"
+		}
+		if strings.Trim(indent, " \t") != "" {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("indent must be 0 to 16 spaces, or spaces and tabs"),
+			})
+		}
+	}
 
-	var data interface{}
-	err := json.Unmarshal([]byte(jsonString), &data)
+	output, err := writeXML(args[0].String(), xmlWriter{indent: indent, pretty: true, comments: true})
 	if err != nil {
-		return js.ValueOf(YAMLResult{
-			Valid:  false,
-			Error:  fmt.Sprintf("Invalid JSON: %v", err),
-			Format: "yaml",
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid XML: %v", err),
+			"format": "xml",
 		})
 	}
 
-	yamlBytes, err := yaml.Marshal(data)
-	if err != nil {
-		return js.ValueOf(YAMLResult{
-			Error: fmt.Sprintf("Failed to convert to YAML: %v", err),
+	if !silentMode {
+		fmt.Printf("XML WASM: Formatted XML (%d → %d bytes)\n", len(args[0].String()), len(output))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"data":   output,
+		"valid":  true,
+		"size":   len(output),
+		"format": "xml",
+	})
+}
+
+// minifyXML - Remove the whitespace between elements and, unless comments is set, the comments of
+// an XML document; text and mixed content are kept as they are
+func minifyXML(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "minifyXML", "xmlString"),
 		})
 	}
 
-	yamlString := string(yamlBytes)
+	var options struct {
+		Comments bool `json:"comments"`
+	}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	xmlString := args[0].String()
+	output, err := writeXML(xmlString, xmlWriter{comments: options.Comments})
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid XML: %v", err),
+			"format": "xml",
+		})
+	}
 
 	if !silentMode {
-		fmt.Printf("YAML WASM: Converted JSON to YAML (%d → %d bytes)\n",
-			len(jsonString), len(yamlString))
+		fmt.Printf("XML WASM: Minified XML (%d → %d bytes)\n", len(xmlString), len(output))
 	}
 
-	return js.ValueOf(YAMLResult{
-		Data:   yamlString,
-		Valid:  true,
-		Size:   len(yamlString),
-		Format: "yaml",
+	return js.ValueOf(map[string]interface{}{
+		"data":          output,
+		"valid":         true,
+		"size":          len(output),
+		"originalSize":  len(xmlString),
+		"reductionSize": len(xmlString) - len(output),
+		"format":        "xml",
 	})
 }
 
-// extractJSONPath - Extract value using JSON path
-func extractJSONPath(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
-		return js.ValueOf(JSONResult{
-			Error: "extractJSONPath requires exactly 2 arguments (jsonString, path)",
+// c14nXML - Canonicalize an XML document, or the element with a given ID, with Exclusive XML
+// Canonicalization 1.0 as XML signatures require
+func c14nXML(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "c14nXML", "xmlString"),
 		})
 	}
 
-	jsonString := args[0].String()
-	path := args[1].String()
+	var options c14nOptions
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
 
-	var data interface{}
-	err := json.Unmarshal([]byte(jsonString), &data)
+	doc, err := parseXNodes(args[0].String())
 	if err != nil {
-		return js.ValueOf(JSONResult{
-			Valid:  false,
-			Error:  fmt.Sprintf("Invalid JSON: %v", err),
-			Format: "json",
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid XML: %v", err),
+			"format": "xml",
 		})
 	}
-
-	// Simple path extraction (supports basic dot notation)
-	result := extractByPath(data, path)
-
-	resultBytes, err := json.MarshalIndent(result, "", "  ")
+	output, err := canonicalizeXML(doc, options)
 	if err != nil {
-		return js.ValueOf(JSONResult{
-			Error: fmt.Sprintf("Failed to serialize result: %v", err),
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
 		})
 	}
 
-	resultString := string(resultBytes)
-
 	if !silentMode {
-		fmt.Printf("JSON WASM: Extracted JSON path '%s'\n", path)
+		fmt.Printf("XML WASM: Canonicalized XML (%d bytes)\n", len(output))
 	}
 
-	return js.ValueOf(JSONResult{
-		Data:   resultString,
-		Valid:  true,
-		Size:   len(resultString),
-		Format: "json",
+	algorithm := "http://www.w3.org/2001/10/xml-exc-c14n#"
+	if options.WithComments {
+		algorithm += "WithComments"
+	}
+	return js.ValueOf(map[string]interface{}{
+		"data":      output,
+		"valid":     true,
+		"size":      len(output),
+		"algorithm": algorithm,
+		"format":    "xml",
 	})
 }
 
-// validateJSONSchema - Basic JSON schema validation
-func validateJSONSchema(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
-		return js.ValueOf(ValidationResult{
-			Valid:  false,
-			Errors: []string{"validateJSONSchema requires exactly 2 arguments (jsonString, schemaString)"},
-			Format: "json",
+// htmlTablesToJSON - Extract the tables of an HTML page as arrays of row objects keyed by their header
+// cells, for screen scraping and content migration. Cells spanning several columns or rows fill every
+// slot they cover, and nested tables are extracted on their own.
+func htmlTablesToJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "htmlTablesToJSON", "htmlString"),
 		})
 	}
 
-	jsonString := args[0].String()
-	schemaString := args[1].String()
-
-	var data interface{}
-	var schema interface{}
+	options := htmlTableOptions{Headers: "auto"}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	switch options.Headers {
+	case "auto", "first", "none":
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", localize("headers must be auto, first or none, got %q", options.Headers)),
+		})
+	}
 
-	// Validate JSON data
-	err := json.Unmarshal([]byte(jsonString), &data)
+	htmlString := args[0].String()
+	doc, err := html.Parse(strings.NewReader(htmlString))
 	if err != nil {
-		return js.ValueOf(ValidationResult{
-			Valid:  false,
-			Errors: []string{fmt.Sprintf("Invalid JSON data: %v", err)},
-			Format: "json",
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid HTML: %v", err),
+			"format": "json",
 		})
 	}
 
-	// Validate schema
-	err = json.Unmarshal([]byte(schemaString), &schema)
-	if err != nil {
-		return js.ValueOf(ValidationResult{
-			Valid:  false,
-			Errors: []string{fmt.Sprintf("Invalid JSON schema: %v", err)},
-			Format: "json",
+	tables := []interface{}{}
+	for i, table := range htmlElements(doc, atom.Table) {
+		tables = append(tables, htmlTableJSON(table, i, options))
+	}
+
+	result := jsonDataResult(tables)
+	if _, failed := result["error"]; failed {
+		return js.ValueOf(result)
+	}
+	result["count"] = len(tables)
+
+	if !silentMode {
+		fmt.Printf("HTML WASM: Extracted %d tables (%d bytes)\n", len(tables), len(htmlString))
+	}
+
+	return js.ValueOf(result)
+}
+
+// htmlListsToJSON - Extract the lists of an HTML page: ul and ol items as strings, or as objects with
+// their own items when they hold a nested list, and dl terms with their descriptions
+func htmlListsToJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "htmlListsToJSON", "htmlString"),
 		})
 	}
 
-	// Basic validation (simplified)
-	errors := performBasicSchemaValidation(data, schema)
+	htmlString := args[0].String()
+	doc, err := html.Parse(strings.NewReader(htmlString))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid HTML: %v", err),
+			"format": "json",
+		})
+	}
 
-	result := ValidationResult{
-		Valid:  len(errors) == 0,
-		Format: "json",
+	// Nested lists are returned inside the items of the list holding them
+	lists := []interface{}{}
+	for _, list := range htmlElements(doc, atom.Ul, atom.Ol, atom.Dl) {
+		if htmlInList(list) {
+			continue
+		}
+		entry := &jsonObject{values: map[string]interface{}{}}
+		entry.set("index", len(lists))
+		if id := htmlAttr(list, "id"); id != "" {
+			entry.set("id", id)
+		}
+		entry.set("type", list.Data)
+		entry.set("items", htmlListItems(list))
+		lists = append(lists, entry)
 	}
 
-	if len(errors) > 0 {
-		result.Errors = errors
+	result := jsonDataResult(lists)
+	if _, failed := result["error"]; failed {
+		return js.ValueOf(result)
 	}
+	result["count"] = len(lists)
 
 	if !silentMode {
-		fmt.Printf("JSON WASM: Schema validation result: %v (%d errors)\n",
-			result.Valid, len(errors))
+		fmt.Printf("HTML WASM: Extracted %d lists (%d bytes)\n", len(lists), len(htmlString))
 	}
 
 	return js.ValueOf(result)
 }
 
-// getAvailableFunctions - Return list of available functions
-func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
-	functions := []interface{}{
-		"parseJSON",
-		"stringifyJSON",
-		"validateJSON",
-		"minifyJSON",
-		"parseXML",
-		"xmlToJSON",
-		"jsonToXML",
-		"validateXML",
-		"csvToJSON",
-		"jsonToCSV",
-		"yamlToJSON",
-		"jsonToYAML",
-		"extractJSONPath",
-		"validateJSONSchema",
-		"getAvailableFunctions",
-		"setSilentMode",
+// csvToJSON - Convert CSV to JSON. By default numbers and true/false are converted cell by cell;
+// with inferTypes every column gets the one type all of its values share (see inferCSVSchema).
+func csvToJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "csvToJSON", "csvString"),
+		})
 	}
-	return js.ValueOf(functions)
-}
 
-// Helper functions
+	options, err := csvArgument(args, 1)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  err.Error(),
+			"format": "json",
+		})
+	}
 
-func parseJSValue(value js.Value) interface{} {
-	switch value.Type() {
-	case js.TypeBoolean:
-		return value.Bool()
-	case js.TypeNumber:
-		return value.Float()
-	case js.TypeString:
-		return value.String()
-	case js.TypeObject:
-		if value.Get("constructor").Get("name").String() == "Array" {
-			length := value.Get("length").Int()
-			result := make([]interface{}, length)
-			for i := 0; i < length; i++ {
-				result[i] = parseJSValue(value.Index(i))
+	csvString := args[0].String()
+
+	records, err := readCSV(csvString, options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  err.Error(),
+			"format": "json",
+		})
+	}
+
+	// Use first row as headers
+	headers := records[0]
+	var columns []*csvColumn
+	if options.InferTypes {
+		columns = inferCSVColumns(headers, records[1:], options)
+	}
+
+	jsonData := []map[string]interface{}{}
+	for i := 1; i < len(records); i++ {
+		row := make(map[string]interface{})
+		for j, value := range records[i] {
+			if j >= len(headers) {
+				continue
 			}
-			return result
-		} else {
-			result := make(map[string]interface{})
-			keys := js.Global().Get("Object").Call("keys", value)
-			for i := 0; i < keys.Get("length").Int(); i++ {
-				key := keys.Index(i).String()
-				result[key] = parseJSValue(value.Get(key))
+			if columns != nil {
+				row[headers[j]] = columns[j].convert(value, options)
+				continue
 			}
-			return result
+			row[headers[j]] = csvCellValue(value)
 		}
-	case js.TypeNull:
-		return nil
-	default:
-		return value.String()
+		jsonData = append(jsonData, row)
 	}
-}
 
-func xmlNodeToMap(node *xmlquery.Node) interface{} {
-	if node == nil {
-		return nil
+	result := jsonDataResult(jsonData)
+	if _, failed := result["error"]; failed {
+		return js.ValueOf(result)
 	}
 
-	switch node.Type {
-	case xmlquery.TextNode:
-		return strings.TrimSpace(node.Data)
-	case xmlquery.ElementNode:
-		result := make(map[string]interface{})
+	if !silentMode {
+		fmt.Printf("CSV WASM: Converted CSV to JSON (%d rows)\n", len(records)-1)
+	}
 
-		// Add attributes
-		if len(node.Attr) > 0 {
-			attrs := make(map[string]string)
-			for _, attr := range node.Attr {
-				attrs[attr.Name.Local] = attr.Value
-			}
-			result["@attributes"] = attrs
+	if columns != nil {
+		types := map[string]interface{}{}
+		for _, column := range columns {
+			types[column.name] = column.kind
 		}
+		result["types"] = types
+	}
+	return js.ValueOf(result)
+}
 
-		// Process children
-		var children []interface{}
-		var textContent string
+// csvCellValue converts a cell without type inference: numbers and true or false are converted, other
+// cells stay strings
+func csvCellValue(value string) interface{} {
+	if num, err := strconv.ParseFloat(value, 64); err == nil {
+		return num
+	}
+	if value == "true" || value == "false" {
+		return value == "true"
+	}
+	return value
+}
 
-		for child := node.FirstChild; child != nil; child = child.NextSibling {
-			switch child.Type {
-			case xmlquery.TextNode:
-				text := strings.TrimSpace(child.Data)
-				if text != "" {
-					textContent += text
-				}
-			case xmlquery.ElementNode:
-				children = append(children, map[string]interface{}{
-					child.Data: xmlNodeToMap(child),
-				})
-			}
-		}
+// inferCSVSchema - Describe the columns of a CSV document for data-import wizards: name, inferred type
+// (null, boolean, integer, float, date, datetime or string), date format, null count and sample values
+func inferCSVSchema(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "inferCSVSchema", "csvString"),
+		})
+	}
 
-		if textContent != "" {
-			result["#text"] = textContent
-		}
+	options, err := csvArgument(args, 1)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
 
-		if len(children) > 0 {
-			result["children"] = children
+	records, err := readCSV(args[0].String(), options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	columns := inferCSVColumns(records[0], records[1:], options)
+	described := make([]interface{}, len(columns))
+	for i, column := range columns {
+		samples := make([]interface{}, len(column.samples))
+		for j, sample := range column.samples {
+			samples[j] = sample
+		}
+		described[i] = map[string]interface{}{
+			"name":          column.name,
+			"index":         i,
+			"type":          column.kind,
+			"format":        column.layout.format,
+			"nullable":      column.nulls > 0,
+			"nullCount":     column.nulls,
+			"valueCount":    column.values,
+			"distinctCount": len(column.distinct),
+			"samples":       samples,
 		}
+	}
 
-		return result
+	if !silentMode {
+		fmt.Printf("CSV WASM: Inferred the schema of %d columns from %d rows\n", len(columns), len(records)-1)
 	}
 
-	return nil
+	return js.ValueOf(map[string]interface{}{
+		"columns":     described,
+		"columnCount": len(columns),
+		"rows":        len(records) - 1,
+	})
 }
 
-func mapToXML(data interface{}, tagName string, indent int) string {
-	indentStr := strings.Repeat("  ", indent)
+// csvOptions configures csvToJSON and inferCSVSchema
+type csvOptions struct {
+	InferTypes bool   `json:"inferTypes"`
+	Delimiter  string `json:"delimiter"`
+	// NullValues replaces the default null markers; the empty cell is always null
+	NullValues []string `json:"nullValues"`
+	// MonthFirst reads ambiguous dates such as 03/04/2024 as March 4 instead of April 3
+	MonthFirst bool `json:"monthFirst"`
+	SampleSize *int `json:"sampleSize"`
 
-	switch v := data.(type) {
-	case map[string]interface{}:
-		var result strings.Builder
-		result.WriteString(fmt.Sprintf("%s<%s>\n", indentStr, tagName))
+	comma rune
+	nulls map[string]bool
+}
 
-		for key, value := range v {
-			result.WriteString(mapToXML(value, key, indent+1))
-		}
+// csvDefaultNulls are the cells read as null when nullValues is not given
+var csvDefaultNulls = []string{"null", "NULL", "Null", "NA", "N/A", "n/a"}
 
-		result.WriteString(fmt.Sprintf("%s</%s>\n", indentStr, tagName))
-		return result.String()
+// csvDefaultSamples is the number of distinct sample values inferCSVSchema keeps per column
+const csvDefaultSamples = 5
 
-	case []interface{}:
-		var result strings.Builder
-		for _, item := range v {
-			result.WriteString(mapToXML(item, tagName, indent))
-		}
-		return result.String()
+// csvDateLayout is a date or date-time format recognised by the type inference
+type csvDateLayout struct {
+	layout   string
+	format   string
+	datetime bool
+}
 
-	default:
-		return fmt.Sprintf("%s<%s>%v</%s>\n", indentStr, tagName, v, tagName)
-	}
+// csvDateLayouts are tried in order; day-first dates come before month-first ones unless monthFirst is set
+var csvDateLayouts = []csvDateLayout{
+	{time.RFC3339, "YYYY-MM-DDThh:mm:ssZ", true},
+	{"2006-01-02T15:04:05", "YYYY-MM-DDThh:mm:ss", true},
+	{"2006-01-02 15:04:05", "YYYY-MM-DD hh:mm:ss", true},
+	{"2006-01-02 15:04", "YYYY-MM-DD hh:mm", true},
+	{"2006-01-02", "YYYY-MM-DD", false},
+	{"2006/01/02", "YYYY/MM/DD", false},
+	{"2/1/2006", "DD/MM/YYYY", false},
+	{"1/2/2006", "MM/DD/YYYY", false},
+	{"2.1.2006", "DD.MM.YYYY", false},
+	{"2-1-2006", "DD-MM-YYYY", false},
+	{"1-2-2006", "MM-DD-YYYY", false},
 }
 
-func extractByPath(data interface{}, path string) interface{} {
-	if path == "" || path == "." {
-		return data
-	}
+// csvNumber matches decimal numbers; NaN, Inf, hexadecimal and underscores are left to strings
+var csvNumber = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
 
-	parts := strings.Split(path, ".")
-	current := data
+// csvColumn accumulates what the type inference learns about one column
+type csvColumn struct {
+	name     string
+	kind     string
+	layout   csvDateLayout
+	nulls    int
+	values   int
+	distinct map[string]bool
+	samples  []string
+}
 
-	for _, part := range parts {
-		if part == "" {
-			continue
+// csvArgument reads the optional options argument at position
+func csvArgument(args []js.Value, position int) (csvOptions, error) {
+	options := csvOptions{Delimiter: ","}
+	if len(args) > position {
+		if err := decodeOptions(args[position], &options); err != nil {
+			return options, errors.New(localize("Invalid options: %v", err))
 		}
+	}
 
-		switch v := current.(type) {
-		case map[string]interface{}:
-			current = v[part]
-		case []interface{}:
-			if idx, err := strconv.Atoi(part); err == nil && idx >= 0 && idx < len(v) {
-				current = v[idx]
+	delimiter := []rune(options.Delimiter)
+	if len(delimiter) != 1 || delimiter[0] == '"' || delimiter[0] == '\r' || delimiter[0] == '\n' {
+		return options, errors.New(localize("delimiter must be a single character"))
+	}
+	options.comma = delimiter[0]
+
+	if options.SampleSize != nil && *options.SampleSize < 0 {
+		return options, errors.New(localize("sampleSize must not be negative"))
+	}
+
+	nulls := csvDefaultNulls
+	if options.NullValues != nil {
+		nulls = options.NullValues
+	}
+	options.nulls = map[string]bool{"": true}
+	for _, value := range nulls {
+		options.nulls[value] = true
+	}
+	return options, nil
+}
+
+// readCSV parses a CSV document, which must at least have a header row
+func readCSV(csvString string, options csvOptions) ([][]string, error) {
+	reader := csv.NewReader(strings.NewReader(csvString))
+	reader.Comma = options.comma
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.New(localize("Invalid CSV: %v", err))
+	}
+	if len(records) == 0 {
+		return nil, errors.New(localize("Empty CSV data"))
+	}
+	return records, nil
+}
+
+// inferCSVColumns finds for every column the narrowest type all of its non-null values share.
+// Integers with a leading zero, such as postal codes, stay strings.
+func inferCSVColumns(headers []string, rows [][]string, options csvOptions) []*csvColumn {
+	samples := csvDefaultSamples
+	if options.SampleSize != nil {
+		samples = *options.SampleSize
+	}
+
+	layouts := csvDateLayouts
+	if options.MonthFirst {
+		layouts = make([]csvDateLayout, 0, len(csvDateLayouts))
+		for _, layout := range csvDateLayouts {
+			if strings.HasPrefix(layout.format, "MM") {
+				layouts = append(layouts, layout)
+			}
+		}
+		for _, layout := range csvDateLayouts {
+			if !strings.HasPrefix(layout.format, "MM") {
+				layouts = append(layouts, layout)
+			}
+		}
+	}
+
+	columns := make([]*csvColumn, len(headers))
+	for j, name := range headers {
+		column := &csvColumn{name: name, distinct: map[string]bool{}}
+		boolean, integer, float := true, true, true
+		dates := append([]csvDateLayout(nil), layouts...)
+
+		for _, row := range rows {
+			if j >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[j])
+			if options.nulls[value] || options.nulls[row[j]] {
+				column.nulls++
+				continue
+			}
+			column.values++
+			if !column.distinct[value] {
+				column.distinct[value] = true
+				if len(column.samples) < samples {
+					column.samples = append(column.samples, value)
+				}
+			}
+
+			boolean = boolean && (strings.EqualFold(value, "true") || strings.EqualFold(value, "false"))
+			integer = integer && csvInteger(value)
+			float = float && csvFloat(value)
+			matching := dates[:0]
+			for _, layout := range dates {
+				if _, err := time.Parse(layout.layout, value); err == nil {
+					matching = append(matching, layout)
+				}
+			}
+			dates = matching
+		}
+
+		switch {
+		case column.values == 0:
+			column.kind = "null"
+		case boolean:
+			column.kind = "boolean"
+		case integer:
+			column.kind = "integer"
+		case float:
+			column.kind = "float"
+		case len(dates) > 0:
+			column.layout = dates[0]
+			column.kind = "date"
+			if column.layout.datetime {
+				column.kind = "datetime"
+			}
+		default:
+			column.kind = "string"
+		}
+		columns[j] = column
+	}
+	return columns
+}
+
+// csvInteger reports whether a cell is a decimal integer that fits in 64 bits and has no leading zero
+func csvInteger(value string) bool {
+	digits := strings.TrimLeft(value, "+-")
+	if len(digits) > 1 && digits[0] == '0' {
+		return false
+	}
+	_, err := strconv.ParseInt(value, 10, 64)
+	return err == nil
+}
+
+// csvFloat reports whether a cell is a decimal number, refusing leading zeros before other digits
+func csvFloat(value string) bool {
+	digits := strings.TrimLeft(value, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' && digits[1] != 'e' && digits[1] != 'E' {
+		return false
+	}
+	return csvNumber.MatchString(value)
+}
+
+// convert turns a cell into the JSON value of the column type: null markers become null and dates
+// are written in ISO 8601
+func (c *csvColumn) convert(value string, options csvOptions) interface{} {
+	trimmed := strings.TrimSpace(value)
+	if options.nulls[trimmed] || options.nulls[value] {
+		return nil
+	}
+
+	switch c.kind {
+	case "boolean":
+		return strings.EqualFold(trimmed, "true")
+	case "integer":
+		n, _ := strconv.ParseInt(trimmed, 10, 64)
+		return json.Number(strconv.FormatInt(n, 10))
+	case "float":
+		f, _ := strconv.ParseFloat(trimmed, 64)
+		return f
+	case "date", "datetime":
+		t, err := time.Parse(c.layout.layout, trimmed)
+		if err != nil {
+			return value
+		}
+		switch {
+		case !c.layout.datetime:
+			return t.Format("2006-01-02")
+		case c.layout.layout == time.RFC3339:
+			return t.Format(time.RFC3339Nano)
+		}
+		return t.Format("2006-01-02T15:04:05")
+	}
+	return value
+}
+
+// validateData - Check records against per-field rules (required, type, pattern, range, length, enum,
+// unique, comparisons with other fields) and report every failure with its row and column, for
+// validating imports before upload. Records are a JSON array of objects, or CSV with format: "csv".
+func validateData(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "validateData", "dataJSON, rulesJSON"),
+		})
+	}
+
+	options := dataValidationOptions{Format: "json", MaxErrors: 1000}
+	if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	if options.MaxErrors < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", localize("maxErrors must be at least 1")),
+		})
+	}
+
+	rules, err := parseDataRules(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid validation rules: %v", err),
+		})
+	}
+
+	var records []map[string]interface{}
+	columns := map[string]int{}
+	switch options.Format {
+	case "json":
+		document, err := decodeDocument(args[0])
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid JSON: %v", err),
+			})
+		}
+		rows, ok := document.([]interface{})
+		if !ok {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("data must be an array of records"),
+			})
+		}
+		for i, row := range rows {
+			record, ok := row.(map[string]interface{})
+			if !ok {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("row %d is not an object", i+1),
+				})
+			}
+			records = append(records, record)
+		}
+	case "csv":
+		csvOpts, err := csvArgument(args, 2)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		rows, err := readCSV(args[0].String(), csvOpts)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		for i, name := range rows[0] {
+			columns[name] = i
+		}
+		// Cells are strings, null markers are missing values
+		for _, row := range rows[1:] {
+			record := make(map[string]interface{}, len(row))
+			for i, cell := range row {
+				if i < len(rows[0]) && !csvOpts.nulls[cell] {
+					record[rows[0][i]] = cell
+				}
+			}
+			records = append(records, record)
+		}
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", localize("format must be json or csv, got %q", options.Format)),
+		})
+	}
+
+	report := validateRecords(records, rules, options.MaxErrors)
+	errorsList := make([]interface{}, len(report.errors))
+	for i, e := range report.errors {
+		entry := map[string]interface{}{
+			"row":     e.row,
+			"column":  e.column,
+			"rule":    e.rule,
+			"value":   e.value,
+			"message": e.message,
+		}
+		if index, ok := columns[e.column]; ok {
+			entry["columnIndex"] = index
+			// The header is line 1 of the CSV, so record n is on line n+1 when no cell spans lines
+			entry["line"] = e.row + 1
+		}
+		errorsList[i] = entry
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Validated %d rows against %d rules (%d errors)\n", len(records), len(rules), report.count)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"valid":          report.count == 0,
+		"errors":         errorsList,
+		"errorCount":     report.count,
+		"truncated":      report.count > len(report.errors),
+		"errorsByColumn": report.byColumn,
+		"rows":           len(records),
+		"invalidRows":    report.invalidRows,
+	})
+}
+
+// dataValidationOptions configures validateData: the data format and how many errors are listed.
+// CSV data also takes the delimiter and nullValues options of csvToJSON.
+type dataValidationOptions struct {
+	Format    string `json:"format"`
+	MaxErrors int    `json:"maxErrors"`
+}
+
+// dataRule is what validateData checks on one field. Min and Max are numbers, or dates when Type is
+// date; Compare lists comparisons with other fields of the same record.
+type dataRule struct {
+	field     string
+	pattern   *regexp.Regexp
+	Required  bool            `json:"required"`
+	Type      string          `json:"type"`
+	Pattern   string          `json:"pattern"`
+	Min       interface{}     `json:"min"`
+	Max       interface{}     `json:"max"`
+	MinLength *int            `json:"minLength"`
+	MaxLength *int            `json:"maxLength"`
+	Enum      []interface{}   `json:"enum"`
+	Unique    bool            `json:"unique"`
+	Compare   json.RawMessage `json:"compare"`
+	Message   string          `json:"message"`
+
+	comparisons []dataComparison
+}
+
+// dataComparison requires a field to be Op the value of Field, such as end >= start
+type dataComparison struct {
+	Op    string `json:"op"`
+	Field string `json:"field"`
+}
+
+// dataError is one failed rule
+type dataError struct {
+	row     int
+	column  string
+	rule    string
+	value   interface{}
+	message string
+}
+
+// dataReport collects the errors of validateRecords, keeping the first maxErrors while counting all
+type dataReport struct {
+	errors      []dataError
+	count       int
+	byColumn    map[string]interface{}
+	invalidRows int
+	max         int
+}
+
+func (r *dataReport) add(e dataError) {
+	r.count++
+	count, _ := r.byColumn[e.column].(int)
+	r.byColumn[e.column] = count + 1
+	if len(r.errors) < r.max {
+		r.errors = append(r.errors, e)
+	}
+}
+
+// parseDataRules reads the rules object, keeping the order of its fields so errors come in that order
+func parseDataRules(value js.Value) ([]*dataRule, error) {
+	parsed, err := decodeOrderedDocument(value, false)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := parsed.(*jsonObject)
+	if !ok {
+		return nil, errors.New(localize("rules must be an object of field rules"))
+	}
+
+	rules := make([]*dataRule, 0, len(object.keys))
+	for _, field := range object.keys {
+		encoded, _ := json.Marshal(object.values[field])
+		decoder := json.NewDecoder(bytes.NewReader(encoded))
+		decoder.DisallowUnknownFields()
+		rule := &dataRule{field: field}
+		if err := decoder.Decode(rule); err != nil {
+			return nil, errors.New(localize("invalid rule for %s: %v", field, err))
+		}
+
+		switch rule.Type {
+		case "", "string", "number", "integer", "boolean", "date":
+		default:
+			return nil, errors.New(localize("invalid rule for %s: %v", field, localize("unknown type %q", rule.Type)))
+		}
+		if rule.Pattern != "" {
+			if rule.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+				return nil, errors.New(localize("invalid rule for %s: %v", field, err))
+			}
+		}
+		if len(rule.Compare) > 0 {
+			// One comparison or a list of them
+			if err := json.Unmarshal(rule.Compare, &rule.comparisons); err != nil {
+				var single dataComparison
+				if err := json.Unmarshal(rule.Compare, &single); err != nil {
+					return nil, errors.New(localize("invalid rule for %s: %v", field, err))
+				}
+				rule.comparisons = []dataComparison{single}
+			}
+			for _, comparison := range rule.comparisons {
+				switch comparison.Op {
+				case "==", "!=", "<", "<=", ">", ">=":
+				default:
+					return nil, errors.New(localize("invalid rule for %s: %v", field, localize("unknown comparison operator %q", comparison.Op)))
+				}
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// validateRecords applies the rules to every record. Empty values only fail required: the other
+// rules apply to values that are present.
+func validateRecords(records []map[string]interface{}, rules []*dataRule, maxErrors int) *dataReport {
+	report := &dataReport{byColumn: map[string]interface{}{}, max: maxErrors}
+	firstRows := make([]map[string]int, len(rules))
+	for i := range firstRows {
+		firstRows[i] = map[string]int{}
+	}
+
+	for index, record := range records {
+		row := index + 1
+		before := report.count
+		for i, rule := range rules {
+			value, present := dataField(record, rule.field)
+			fail := func(name, message string) {
+				if rule.Message != "" {
+					message = rule.Message
+				}
+				report.add(dataError{row: row, column: rule.field, rule: name, value: value, message: message})
+			}
+
+			text := dataText(value)
+			if !present || value == nil || strings.TrimSpace(text) == "" {
+				if rule.Required {
+					fail("required", localize("is required"))
+				}
+				continue
+			}
+
+			if rule.Type != "" && !dataHasType(value, rule.Type) {
+				fail("type", localize("must be %s, got %s", rule.Type, dataTypeName(value)))
+				continue
+			}
+			if rule.pattern != nil && !rule.pattern.MatchString(text) {
+				fail("pattern", localize("must match pattern %q", rule.Pattern))
+			}
+			if rule.MinLength != nil && utf8.RuneCountInString(text) < *rule.MinLength {
+				fail("minLength", localize("must be at least %v characters", *rule.MinLength))
+			}
+			if rule.MaxLength != nil && utf8.RuneCountInString(text) > *rule.MaxLength {
+				fail("maxLength", localize("must be at most %v characters", *rule.MaxLength))
+			}
+			if rule.Min != nil {
+				if order, ok := dataCompare(value, rule.Min, rule.Type == "date"); !ok {
+					fail("min", localize("must be a valid %s", dataRangeKind(rule.Type)))
+				} else if order < 0 {
+					fail("min", localize("must be >= %v", rule.Min))
+				}
+			}
+			if rule.Max != nil {
+				if order, ok := dataCompare(value, rule.Max, rule.Type == "date"); !ok {
+					fail("max", localize("must be a valid %s", dataRangeKind(rule.Type)))
+				} else if order > 0 {
+					fail("max", localize("must be <= %v", rule.Max))
+				}
+			}
+			if rule.Enum != nil {
+				allowed := false
+				names := make([]string, len(rule.Enum))
+				for j, candidate := range rule.Enum {
+					names[j] = dataText(candidate)
+					allowed = allowed || names[j] == text
+				}
+				if !allowed {
+					fail("enum", localize("must be one of %s", strings.Join(names, ", ")))
+				}
+			}
+			if rule.Unique {
+				if first, seen := firstRows[i][text]; seen {
+					fail("unique", localize("duplicates the value of row %d", first))
+				} else {
+					firstRows[i][text] = row
+				}
+			}
+			for _, comparison := range rule.comparisons {
+				other, found := dataField(record, comparison.Field)
+				if !found || other == nil || dataText(other) == "" {
+					continue
+				}
+				order, ok := dataCompare(value, other, rule.Type == "date")
+				if !ok || !dataOrderHolds(order, comparison.Op) {
+					fail("compare", localize("must be %s %s (%s)", comparison.Op, comparison.Field, dataText(other)))
+				}
+			}
+		}
+		if report.count > before {
+			report.invalidRows++
+		}
+	}
+	return report
+}
+
+// dataField returns a field of a record by name, or by dot path into nested objects
+func dataField(record map[string]interface{}, field string) (interface{}, bool) {
+	if value, ok := record[field]; ok {
+		return value, true
+	}
+	var current interface{} = record
+	for _, segment := range strings.Split(field, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[segment]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// dataText is the text of a value as a CSV cell would hold it
+func dataText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// dataNumber reads a number, or a string holding one since CSV cells are strings
+func dataNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// dataDate reads a date in one of the layouts the CSV type inference recognises
+func dataDate(value interface{}) (time.Time, bool) {
+	text, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range csvDateLayouts {
+		if t, err := time.Parse(layout.layout, strings.TrimSpace(text)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dataHasType checks a value against a rule type; strings holding numbers and booleans pass, as CSV
+// cells always are strings
+func dataHasType(value interface{}, kind string) bool {
+	switch kind {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := dataNumber(value)
+		return ok
+	case "integer":
+		n, ok := dataNumber(value)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		if _, ok := value.(bool); ok {
+			return true
+		}
+		text := strings.ToLower(strings.TrimSpace(dataText(value)))
+		return text == "true" || text == "false"
+	case "date":
+		_, ok := dataDate(value)
+		return ok
+	}
+	return true
+}
+
+// dataTypeName names the type of a value for error messages
+func dataTypeName(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
+
+// dataRangeKind names what a range rule compares
+func dataRangeKind(kind string) string {
+	if kind == "date" {
+		return "date"
+	}
+	return "number"
+}
+
+// dataCompare orders two values: as dates when asked, as numbers when both are numeric, otherwise as
+// text. ok is false when dates are asked for and either value is not one, or a bound of a number is not.
+func dataCompare(a, b interface{}, dates bool) (order int, ok bool) {
+	if dates {
+		x, okA := dataDate(a)
+		y, okB := dataDate(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		return x.Compare(y), true
+	}
+	x, okA := dataNumber(a)
+	y, okB := dataNumber(b)
+	if okA && okB {
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	if _, bound := b.(float64); bound {
+		return 0, false
+	}
+	return strings.Compare(dataText(a), dataText(b)), true
+}
+
+// dataOrderHolds tells whether an order satisfies a comparison operator
+func dataOrderHolds(order int, op string) bool {
+	switch op {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	}
+	return order >= 0
+}
+
+// jsonToCSV - Convert JSON to CSV
+func jsonToCSV(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "jsonToCSV", "jsonString"),
+		})
+	}
+
+	document, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "csv",
+		})
+	}
+	rows, ok := document.([]interface{})
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error":  localize("Invalid JSON: %v", localize("data must be an array of records")),
+			"format": "csv",
+		})
+	}
+	data := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		record, ok := row.(map[string]interface{})
+		if !ok && row != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error":  localize("Invalid JSON: %v", localize("row %d is not an object", i+1)),
+				"format": "csv",
+			})
+		}
+		data[i] = record
+	}
+
+	if len(data) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error":  localize("Empty JSON array"),
+			"format": "csv",
+		})
+	}
+
+	// Extract headers from first object
+	var headers []string
+	for key := range data[0] {
+		headers = append(headers, key)
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	// Write headers
+	writer.Write(headers)
+
+	// Write data rows
+	for _, row := range data {
+		var record []string
+		for _, header := range headers {
+			if value, exists := row[header]; exists {
+				record = append(record, fmt.Sprintf("%v", value))
+			} else {
+				record = append(record, "")
+			}
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	csvString := buf.String()
+
+	if !silentMode {
+		fmt.Printf("CSV WASM: Converted JSON to CSV (%d rows, %d columns)\n",
+			len(data), len(headers))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"data":    csvString,
+		"rows":    len(data),
+		"columns": len(headers),
+		"format":  "csv",
+	})
+}
+
+// validateGeoJSON - Check a GeoJSON document against RFC 7946 and compute its bounding box. Errors and
+// warnings, such as a polygon ring against the right-hand rule, point into the document with JSON Pointers.
+func validateGeoJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "validateGeoJSON", "geojsonString"),
+		})
+	}
+
+	document, err := decodeOrderedDocument(args[0], true)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid": false,
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	v := &geoValidator{}
+	kind := v.document(document)
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Validated GeoJSON %s (%d errors, %d warnings)\n", kind, len(v.errors), len(v.warnings))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"valid":        len(v.errors) == 0,
+		"errors":       geoIssues(v.errors),
+		"warnings":     geoIssues(v.warnings),
+		"type":         kind,
+		"featureCount": v.features,
+		"bbox":         v.bboxValue(),
+	})
+}
+
+// csvToGeoJSON - Convert CSV rows to a FeatureCollection of Point features, the other columns becoming
+// properties. Latitude and longitude columns are found by their usual names unless given.
+func csvToGeoJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "csvToGeoJSON", "csvString"),
+		})
+	}
+
+	csvOpts, err := csvArgument(args, 1)
+	var options geoCSVOptions
+	if err == nil && len(args) > 1 {
+		err = decodeOptions(args[1], &options)
+		if err != nil {
+			err = errors.New(localize("Invalid options: %v", err))
+		}
+	}
+	var records [][]string
+	if err == nil {
+		records, err = readCSV(args[0].String(), csvOpts)
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  err.Error(),
+			"format": "json",
+		})
+	}
+
+	collection, v, skipped, err := geoFeaturesFromCSV(records, csvOpts, options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  err.Error(),
+			"format": "json",
+		})
+	}
+
+	result := jsonDataResult(collection)
+	if result["error"] == nil {
+		result["featureCount"] = v.features
+		result["skipped"] = skipped
+		result["bbox"] = v.bboxValue()
+		if !silentMode {
+			fmt.Printf("CSV WASM: Converted CSV to GeoJSON (%d features, %d rows skipped)\n", v.features, skipped)
+		}
+	}
+	return js.ValueOf(result)
+}
+
+// geojsonToCSV - Convert a GeoJSON FeatureCollection or Feature to CSV, one row per feature with its
+// id and properties, and its geometry as latitude and longitude columns or as WKT
+func geojsonToCSV(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "geojsonToCSV", "geojsonString"),
+		})
+	}
+
+	csvOpts, err := csvArgument(args, 1)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error":  err.Error(),
+			"format": "csv",
+		})
+	}
+	options := geoCSVOptions{Geometry: "auto", Latitude: "latitude", Longitude: "longitude", Altitude: "altitude", ID: "id"}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error":  localize("Invalid options: %v", err),
+				"format": "csv",
+			})
+		}
+	}
+
+	document, err := decodeOrderedDocument(args[0], true)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "csv",
+		})
+	}
+	v := &geoValidator{}
+	if v.document(document); len(v.errors) > 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error":  localize("Invalid GeoJSON: %v", v.errors[0].String()),
+			"format": "csv",
+		})
+	}
+
+	headers, rows, err := geoFeaturesToCSV(document.(*jsonObject), options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error":  err.Error(),
+			"format": "csv",
+		})
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Comma = csvOpts.comma
+	writer.Write(headers)
+	writer.WriteAll(rows)
+	csvString := buf.String()
+
+	if !silentMode {
+		fmt.Printf("CSV WASM: Converted GeoJSON to CSV (%d rows, %d columns)\n", len(rows), len(headers))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"data":    csvString,
+		"rows":    len(rows),
+		"columns": len(headers),
+		"bbox":    v.bboxValue(),
+		"format":  "csv",
+	})
+}
+
+// yamlToJSON - Convert YAML to JSON
+func yamlToJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(JSONResult{
+			Error: localize("%s requires exactly 1 argument (%s)", "yamlToJSON", "yamlString"),
+		})
+	}
+
+	yamlString := args[0].String()
+
+	var data interface{}
+	err := yaml.Unmarshal([]byte(yamlString), &data)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid YAML: %v", err),
+			"format": "json",
+		})
+	}
+
+	result := jsonDataResult(data)
+	if !silentMode && result["error"] == nil {
+		fmt.Printf("YAML WASM: Converted YAML to JSON (%d bytes)\n", len(yamlString))
+	}
+
+	return js.ValueOf(result)
+}
+
+// jsonToYAML - Convert JSON to YAML
+func jsonToYAML(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "jsonToYAML", "jsonString"),
+		})
+	}
+
+	data, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "yaml",
+		})
+	}
+
+	yamlBytes, err := yaml.Marshal(data)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to convert to YAML: %v", err),
+		})
+	}
+
+	yamlString := string(yamlBytes)
+
+	if !silentMode {
+		fmt.Printf("YAML WASM: Converted JSON to YAML (%d bytes)\n", len(yamlString))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"data":   yamlString,
+		"valid":  true,
+		"size":   len(yamlString),
+		"format": "yaml",
+	})
+}
+
+// tomlToJSON - Convert TOML to JSON
+func tomlToJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "tomlToJSON", "tomlString"),
+		}
+	}
+
+	tomlString := args[0].String()
+
+	var data map[string]interface{}
+	if err := toml.Unmarshal([]byte(tomlString), &data); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			row, column := decodeErr.Position()
+			err = fmt.Errorf(localize("line %d, column %d: %v"), row, column, err)
+		}
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid TOML: %v", err),
+			"format": "json",
+		}
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	result := jsonDataResult(data)
+	if !silentMode && result["error"] == nil {
+		fmt.Printf("TOML WASM: Converted TOML to JSON (%d bytes)\n", len(tomlString))
+	}
+
+	return result
+}
+
+// jsonToTOML - Convert a JSON object to TOML, keeping its key order
+func jsonToTOML(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "jsonToTOML", "jsonString"),
+		}
+	}
+
+	data, err := decodeOrderedDocument(args[0], false)
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "toml",
+		}
+	}
+
+	tomlString, err := encodeTOML(data)
+	if err != nil {
+		return map[string]interface{}{
+			"error": localize("Failed to convert to TOML: %v", err),
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("TOML WASM: Converted JSON to TOML (%d bytes)\n", len(tomlString))
+	}
+
+	return map[string]interface{}{
+		"data":   tomlString,
+		"valid":  true,
+		"size":   len(tomlString),
+		"format": "toml",
+	}
+}
+
+// iniToJSON - Convert INI to JSON, sections becoming objects and key[] entries arrays
+func iniToJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "iniToJSON", "iniString"),
+		}
+	}
+
+	iniString := args[0].String()
+
+	data, err := parseINI(iniString)
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid INI: %v", err),
+			"format": "json",
+		}
+	}
+
+	result := jsonDataResult(data)
+	if !silentMode && result["error"] == nil {
+		fmt.Printf("INI WASM: Converted INI to JSON (%d bytes)\n", len(iniString))
+	}
+
+	return result
+}
+
+// jsonToINI - Convert a JSON object to INI, nested objects becoming [section] and [section.sub]
+func jsonToINI(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "jsonToINI", "jsonString"),
+		}
+	}
+
+	data, err := decodeOrderedDocument(args[0], false)
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "ini",
+		}
+	}
+
+	iniString, err := encodeINI(data)
+	if err != nil {
+		return map[string]interface{}{
+			"error": localize("Failed to convert to INI: %v", err),
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("INI WASM: Converted JSON to INI (%d bytes)\n", len(iniString))
+	}
+
+	return map[string]interface{}{
+		"data":   iniString,
+		"valid":  true,
+		"size":   len(iniString),
+		"format": "ini",
+	}
+}
+
+// encodeMsgPack - Encode JSON to MessagePack, returned as a Uint8Array
+func encodeMsgPack(this js.Value, args []js.Value) interface{} {
+	return encodeBinary("encodeMsgPack", "msgpack", "MessagePack", "Failed to convert to MessagePack: %v", args, writeMsgPack)
+}
+
+// decodeMsgPack - Decode MessagePack from a Uint8Array, ArrayBuffer or base64 string to JSON
+func decodeMsgPack(this js.Value, args []js.Value) interface{} {
+	return decodeBinary("decodeMsgPack", "MessagePack", "Invalid MessagePack: %v", args, readMsgPack)
+}
+
+// encodeCBOR - Encode JSON to CBOR (RFC 8949), returned as a Uint8Array
+func encodeCBOR(this js.Value, args []js.Value) interface{} {
+	return encodeBinary("encodeCBOR", "cbor", "CBOR", "Failed to convert to CBOR: %v", args, writeCBOR)
+}
+
+// decodeCBOR - Decode CBOR from a Uint8Array, ArrayBuffer or base64 string to JSON
+func decodeCBOR(this js.Value, args []js.Value) interface{} {
+	return decodeBinary("decodeCBOR", "CBOR", "Invalid CBOR: %v", args, readCBOR)
+}
+
+// decodeBSON - Decode a BSON document to MongoDB Extended JSON (relaxed mode)
+func decodeBSON(this js.Value, args []js.Value) interface{} {
+	return decodeBinary("decodeBSON", "BSON", "Invalid BSON: %v", args, readBSONDocument)
+}
+
+// loadProtoDescriptor - Register the message and enum types of a serialized FileDescriptorSet, as
+// written by protoc --descriptor_set_out or buf build, for encodeProto and decodeProto
+func loadProtoDescriptor(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "loadProtoDescriptor", "descriptorBytes"),
+		}
+	}
+
+	data, err := bytesFromJS(args[0])
+	if err == nil && len(data) == 0 {
+		err = errors.New(localize("empty input"))
+	}
+	var files []*protoFile
+	if err == nil {
+		files, err = parseProtoDescriptorSet(data)
+	}
+	if err != nil {
+		return map[string]interface{}{
+			"valid": false,
+			"error": localize("Invalid descriptor: %v", err),
+		}
+	}
+
+	// Types are registered once the whole set has been read, so a bad descriptor changes nothing
+	names, messages, enums := []interface{}{}, []interface{}{}, []interface{}{}
+	for _, file := range files {
+		names = append(names, file.name)
+		for _, m := range file.messages {
+			protoMessages[m.name] = m
+			if !m.mapEntry {
+				messages = append(messages, m.name)
+			}
+		}
+		for _, enum := range file.enums {
+			protoEnums[enum.name] = enum
+			enums = append(enums, enum.name)
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Protobuf WASM: Loaded %d message types from %d files\n", len(messages), len(files))
+	}
+
+	return map[string]interface{}{
+		"valid":    true,
+		"files":    names,
+		"messages": messages,
+		"enums":    enums,
+		"count":    len(messages),
+	}
+}
+
+// encodeProto - Encode JSON, in the proto3 JSON mapping, as a protobuf message of a loaded type,
+// returned as a Uint8Array
+func encodeProto(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "encodeProto", "messageType, jsonString"),
+		}
+	}
+	var options protoEncodeOptions
+	if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			}
+		}
+	}
+
+	m, err := protoMessageType(args[0].String())
+	if err != nil {
+		return map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	data, err := decodeOrderedDocument(args[1], true)
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "protobuf",
+		}
+	}
+
+	var b bytes.Buffer
+	if err := options.message(&b, m, data, m.name, 0); err != nil {
+		return map[string]interface{}{
+			"error": localize("Failed to encode protobuf: %v", err),
+		}
+	}
+
+	encoded := js.Global().Get("Uint8Array").New(b.Len())
+	js.CopyBytesToJS(encoded, b.Bytes())
+
+	if !silentMode {
+		fmt.Printf("Protobuf WASM: Encoded %s (%d bytes)\n", m.name, b.Len())
+	}
+
+	return map[string]interface{}{
+		"data":        encoded,
+		"valid":       true,
+		"size":        b.Len(),
+		"format":      "protobuf",
+		"messageType": m.name,
+	}
+}
+
+// decodeProto - Decode a protobuf message of a loaded type from a Uint8Array, ArrayBuffer or base64
+// string to JSON in the proto3 JSON mapping
+func decodeProto(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "decodeProto", "messageType, bytes"),
+		}
+	}
+	var options protoDecodeOptions
+	if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			}
+		}
+	}
+
+	m, err := protoMessageType(args[0].String())
+	if err != nil {
+		return map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	// An empty message is valid protobuf: every field has its default value
+	data, err := bytesFromJS(args[1])
+	var value interface{}
+	if err == nil {
+		value, err = options.message(m, data, 0)
+	}
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid protobuf: %v", err),
+			"format": "json",
+		}
+	}
+
+	result := jsonDataResult(value)
+	if result["error"] == nil {
+		result["messageType"] = m.name
+		if !silentMode {
+			fmt.Printf("Protobuf WASM: Decoded %s (%d bytes)\n", m.name, len(data))
+		}
+	}
+	return result
+}
+
+// createNDJSONParser - Start an incremental NDJSON / JSON Lines parser fed chunk by chunk with feedNDJSON
+func createNDJSONParser(this js.Value, args []js.Value) interface{} {
+	options := ndjsonOptions{MaxLineLength: ndjsonMaxLineLength}
+	if len(args) > 0 {
+		if err := decodeOptions(args[0], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	if options.MaxLineLength <= 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("maxLineLength must be a positive number of bytes"),
+		})
+	}
+
+	nextNDJSONParser++
+	id := fmt.Sprintf("ndjson-%d", nextNDJSONParser)
+	ndjsonParsers[id] = &ndjsonParser{options: options}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Created NDJSON parser %s\n", id)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"parserId":      id,
+		"strict":        options.Strict,
+		"maxLineLength": options.MaxLineLength,
+	})
+}
+
+// feedNDJSON - Parse the complete lines of a chunk; an incomplete last line waits for the next chunk
+func feedNDJSON(this js.Value, args []js.Value) interface{} {
+	p, id, failure := ndjsonArgument(args, "feedNDJSON", 2, "parserId, chunk")
+	if failure != nil {
+		return failure
+	}
+
+	var chunk []byte
+	if args[1].Type() == js.TypeString {
+		chunk = []byte(args[1].String())
+	} else {
+		data, err := bytesFromJS(args[1])
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid chunk: %v", err),
+			})
+		}
+		chunk = data
+	}
+
+	return js.ValueOf(ndjsonBatch(id, p, chunk, false))
+}
+
+// finishNDJSON - Parse the last line left without a line break, report the totals and free the parser
+func finishNDJSON(this js.Value, args []js.Value) interface{} {
+	p, id, failure := ndjsonArgument(args, "finishNDJSON", 1, "parserId")
+	if failure != nil {
+		return failure
+	}
+
+	result := ndjsonBatch(id, p, nil, true)
+	delete(ndjsonParsers, id)
+	result["finished"] = true
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Finished NDJSON parser %s (%d records, %d lines)\n", id, p.records, p.line)
+	}
+
+	return js.ValueOf(result)
+}
+
+// freeNDJSONParser - Drop a parser and the data it buffered without parsing it
+func freeNDJSONParser(this js.Value, args []js.Value) interface{} {
+	_, id, failure := ndjsonArgument(args, "freeNDJSONParser", 1, "parserId")
+	if failure != nil {
+		return failure
+	}
+
+	delete(ndjsonParsers, id)
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Freed NDJSON parser %s\n", id)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"parserId": id,
+		"freed":    true,
+	})
+}
+
+// extractJSONPath - Query JSON with a JSONPath expression ($, wildcards, .., slices, filters)
+func extractJSONPath(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return map[string]interface{}{
+			"error": localize("%s requires exactly 2 arguments (%s)", "extractJSONPath", "jsonString, path"),
+		}
+	}
+
+	path := args[1].String()
+
+	data, err := decodeOrderedDocument(args[0], false)
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "json",
+		}
+	}
+
+	query, err := parseJSONPath(path)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	nodes := query.apply(data, data)
+
+	// $ queries return every match; dot notation paths keep returning the single value, or null
+	paths := make([]interface{}, len(nodes))
+	matches := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		paths[i] = node.path
+		matches[i] = node.value
+	}
+	var value interface{} = matches
+	if !strings.HasPrefix(strings.TrimSpace(path), "$") {
+		value = nil
+		if len(matches) > 0 {
+			value = matches[0]
+		}
+	}
+
+	result := jsonDataResult(value)
+	if _, failed := result["error"]; failed {
+		return result
+	}
+	result["paths"] = paths
+	result["count"] = len(nodes)
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Extracted JSON path '%s' (%d matches)\n", path, len(nodes))
+	}
+
+	return result
+}
+
+// transformJSON - Reshape JSON with a jq expression (pipes, select, map, keys, arithmetic, string interpolation)
+func transformJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "transformJSON", "jsonString, expression"),
+		}
+	}
+	var options jqOptions
+	if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return map[string]interface{}{"error": localize("Invalid options: %v", err)}
+		}
+	}
+
+	expression := args[1].String()
+
+	data, err := decodeOrderedDocument(args[0], false)
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": "json",
+		}
+	}
+
+	program, err := parseJQ(expression)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	var env *jqEnv
+	names := make([]string, 0, len(options.Variables))
+	for name := range options.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := decodeOrderedJSON(options.Variables[name])
+		if err != nil {
+			return map[string]interface{}{"error": localize("Invalid options: %v", err)}
+		}
+		env = env.bind(name, value)
+	}
+
+	outputs, err := jqCollect(program, data, env)
+	if err != nil {
+		return map[string]interface{}{"error": localize("Transformation failed: %v", err)}
+	}
+
+	// A single output is returned as is; none or several are returned as an array
+	var value interface{} = jqFinite(outputs)
+	if len(outputs) == 1 {
+		value = jqFinite(outputs[0])
+	}
+
+	result := jsonDataResult(value)
+	if _, failed := result["error"]; failed {
+		return result
+	}
+	result["count"] = len(outputs)
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Transformed JSON with '%s' (%d results)\n", expression, len(outputs))
+	}
+
+	return result
+}
+
+// validateJSONSchema - Validate a JSON document against a JSON Schema (draft 2020-12, with earlier drafts' keywords)
+func validateJSONSchema(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "validateJSONSchema", "jsonString, schemaString"),
+		})
+	}
+
+	data, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON data: %v", err),
+		})
+	}
+	schema, err := decodeDocument(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON schema: %v", err),
+		})
+	}
+
+	options := struct {
+		Formats bool `json:"formats"`
+	}{Formats: true}
+	if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	validator := newSchemaValidator(schema, options.Formats)
+	result := validator.validate(data, schema, schemaDefaultBase, "", "")
+
+	messages := make([]interface{}, len(result.errors))
+	details := make([]interface{}, len(result.errors))
+	for i, e := range result.errors {
+		messages[i] = e.message
+		if e.instancePath != "" {
+			messages[i] = e.instancePath + ": " + e.message
+		}
+		details[i] = map[string]interface{}{
+			"instancePath":    e.instancePath,
+			"keywordLocation": e.keywordLocation,
+			"keyword":         e.keyword,
+			"message":         e.message,
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Schema validation result: %v (%d errors)\n",
+			len(result.errors) == 0, len(result.errors))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"valid":   len(result.errors) == 0,
+		"errors":  messages,
+		"details": details,
+		"format":  "json",
+	})
+}
+
+// generateMockData - Generate fake records conforming to a JSON schema, reproducible with a seed
+func generateMockData(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "generateMockData", "schemaString"),
+		})
+	}
+
+	schema, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON schema: %v", err),
+		})
+	}
+
+	count := 1
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		count = args[1].Int()
+	}
+	if count < 1 || count > maxMockRecords {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("count must be between 1 and %d", maxMockRecords),
+		})
+	}
+
+	// Without a seed every call differs; the seed used is returned so a run can be replayed
+	seed := time.Now().UnixNano()
+	if len(args) > 2 && args[2].Type() == js.TypeNumber {
+		seed = int64(args[2].Float())
+	}
+
+	generator := &mockGenerator{rng: rand.New(rand.NewSource(seed)), root: schema}
+	records := make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		record, err := generator.value(schema, "", 0)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to generate mock data: %v", err),
+			})
+		}
+		records = append(records, record)
+	}
+
+	result := jsonDataResult(records)
+	if _, failed := result["error"]; failed {
+		return js.ValueOf(result)
+	}
+	result["count"] = count
+	result["seed"] = seed
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Generated %d mock records (seed %d)\n", count, seed)
+	}
+
+	return js.ValueOf(result)
+}
+
+// generateJSONSchema - Infer a JSON Schema (draft 2020-12) from sample documents: types, required
+// properties, string formats and enums of repeated values
+func generateJSONSchema(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "generateJSONSchema", "samplesJSON"),
+		})
+	}
+
+	data, err := decodeOrderedDocument(args[0], false)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	options := schemaInferOptions{Enums: true, MaxEnumValues: 10, Formats: true}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	// An array holds the samples; any other document is a single sample
+	samples, ok := data.([]interface{})
+	if !ok {
+		samples = []interface{}{data}
+	}
+	if len(samples) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("at least one sample document is required"),
+		})
+	}
+
+	inference := newSchemaInference()
+	for _, sample := range samples {
+		inference.observe(sample, options)
+	}
+	schema := &jsonObject{values: map[string]interface{}{}}
+	schema.set("$schema", "https://json-schema.org/draft/2020-12/schema")
+	inferred := inference.schema(options)
+	for _, key := range inferred.keys {
+		schema.set(key, inferred.values[key])
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Inferred JSON schema from %d samples\n", len(samples))
+	}
+
+	result := jsonDataResult(schema)
+	result["samples"] = len(samples)
+	return js.ValueOf(result)
+}
+
+// jsonToTypeScript - Generate TypeScript interfaces from a JSON sample: nested objects become named
+// interfaces, members missing from some array elements become optional
+func jsonToTypeScript(this js.Value, args []js.Value) interface{} {
+	return generateTypes("jsonToTypeScript", "typescript", args)
+}
+
+// jsonToGoStruct - Generate Go struct definitions with json tags from a JSON sample: nested objects
+// become named structs, members missing from some array elements get omitempty
+func jsonToGoStruct(this js.Value, args []js.Value) interface{} {
+	return generateTypes("jsonToGoStruct", "go", args)
+}
+
+// generateTypes infers the shape of a JSON sample and writes it as TypeScript or Go types
+func generateTypes(name, language string, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", name, "jsonString"),
+		})
+	}
+
+	data, err := decodeOrderedDocument(args[0], false)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": language,
+		})
+	}
+
+	options := typeGenOptions{RootName: "Root", Export: true, OmitEmpty: true}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	if typeName(options.RootName) == "" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", localize("rootName %q has no letters or digits", options.RootName)),
+		})
+	}
+
+	inference := newSchemaInference()
+	inference.observe(data, schemaInferOptions{})
+	generator := &typeGenerator{language: language, options: options, used: map[string]bool{}}
+	code := generator.generate(inference, generator.typeName(options.RootName))
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Generated %d %s types from JSON\n", len(generator.declared), language)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"data":   code,
+		"types":  len(generator.declared),
+		"valid":  true,
+		"size":   len(code),
+		"format": language,
+	})
+}
+
+// mergeJSON - Deep merge two JSON documents, objects key by key and arrays by the chosen strategy.
+// The third argument is an options object, or just the array strategy name.
+func mergeJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "mergeJSON", "jsonA, jsonB"),
+		})
+	}
+
+	a, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+	b, err := decodeDocument(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	options := mergeOptions{Arrays: "replace", Key: "id"}
+	if len(args) > 2 && args[2].Type() == js.TypeString && !strings.HasPrefix(strings.TrimSpace(args[2].String()), "{") {
+		options.Arrays = args[2].String()
+	} else if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	switch options.Arrays {
+	case "replace", "concat", "union", "mergeByKey":
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown array strategy %q", options.Arrays),
+		})
+	}
+
+	merged := deepMerge(a, b, options)
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Merged JSON documents (arrays: %s)\n", options.Arrays)
+	}
+
+	return js.ValueOf(jsonDataResult(merged))
+}
+
+// cloneJSON - Deep copy a JSON document, optionally keeping only the pick paths and dropping the omit paths
+func cloneJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "cloneJSON", "jsonString"),
+		})
+	}
+
+	data, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	var options struct {
+		Pick []string `json:"pick"`
+		Omit []string `json:"omit"`
+	}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	// Decoding already produced an independent copy; pick and omit rebuild or trim it
+	if len(options.Pick) > 0 {
+		var picked interface{}
+		for _, path := range options.Pick {
+			picked = pickPath(picked, data, splitPath(path))
+		}
+		data = picked
+	}
+	for _, path := range options.Omit {
+		data = omitPath(data, splitPath(path))
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Cloned JSON (%d picked, %d omitted paths)\n", len(options.Pick), len(options.Omit))
+	}
+
+	return js.ValueOf(jsonDataResult(data))
+}
+
+// pruneJSON - Recursively remove nulls, empty values and named keys from a JSON document
+func pruneJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "pruneJSON", "jsonString"),
+		})
+	}
+
+	data, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	options := pruneOptions{Nulls: true, EmptyArrays: true, EmptyObjects: true}
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	removed := 0
+	pruned, keep := pruneValue(data, options, &removed)
+	if !keep {
+		// A document that prunes away entirely becomes the empty value of its own type
+		switch data.(type) {
+		case map[string]interface{}:
+			pruned = map[string]interface{}{}
+		case []interface{}:
+			pruned = []interface{}{}
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Pruned %d values\n", removed)
+	}
+
+	result := jsonDataResult(pruned)
+	if _, failed := result["error"]; !failed {
+		result["removed"] = removed
+	}
+	return js.ValueOf(result)
+}
+
+// flattenJSON - Flatten a nested JSON document into one object of path keys such as "a.b[0].c"
+func flattenJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "flattenJSON", "jsonString"),
+		})
+	}
+
+	data, err := decodeOrderedDocument(args[0], false)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	options, err := flattenArgument(args, 1)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	switch data.(type) {
+	case *jsonObject, []interface{}:
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires a JSON object or array", "flattenJSON"),
+		})
+	}
+
+	flat := &jsonObject{values: map[string]interface{}{}}
+	if err := flattenValue(flat, data, "", options); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Flattened JSON into %d keys\n", len(flat.keys))
+	}
+
+	result := jsonDataResult(flat)
+	if _, failed := result["error"]; !failed {
+		result["keys"] = len(flat.keys)
+	}
+	return js.ValueOf(result)
+}
+
+// unflattenJSON - Rebuild a nested JSON document from the path keys produced by flattenJSON
+func unflattenJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "unflattenJSON", "jsonString"),
+		})
+	}
+
+	data, err := decodeOrderedDocument(args[0], false)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	options, err := flattenArgument(args, 1)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	flat, ok := data.(*jsonObject)
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires a JSON object", "unflattenJSON"),
+		})
+	}
+
+	root := &flatNode{}
+	for _, key := range flat.keys {
+		if err := root.insert(parseFlatKey(key, options), flat.values[key], key); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+	if root.object == nil && root.array == nil {
+		root.object = &jsonObject{values: map[string]interface{}{}}
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Unflattened %d keys\n", len(flat.keys))
+	}
+
+	return js.ValueOf(jsonDataResult(root.value()))
+}
+
+// applyJSONPatch - Apply an RFC 6902 JSON Patch; the operations apply atomically, all or none
+func applyJSONPatch(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "applyJSONPatch", "jsonString, patch"),
+		})
+	}
+
+	data, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+	var patch []map[string]interface{}
+	if err := decodeOptions(args[1], &patch); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid patch: %v", err),
+		})
+	}
+
+	// data is a fresh decode, so a failed operation leaves nothing half applied for the caller
+	for i, operation := range patch {
+		if data, err = applyPatchOperation(data, operation); err != nil {
+			op, _ := operation["op"].(string)
+			return js.ValueOf(map[string]interface{}{
+				"error":     localize("Patch operation %d (%s) failed: %v", i, op, err),
+				"operation": i,
+			})
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Applied JSON Patch (%d operations)\n", len(patch))
+	}
+
+	result := jsonDataResult(data)
+	if _, failed := result["error"]; !failed {
+		result["operations"] = len(patch)
+	}
+	return js.ValueOf(result)
+}
+
+// applyMergePatch - Apply an RFC 7386 JSON Merge Patch: objects merge recursively, null removes a member
+func applyMergePatch(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "applyMergePatch", "jsonString, patch"),
+		})
+	}
+
+	data, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+	patch, err := decodeDocument(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid patch: %v", err),
+		})
+	}
+
+	merged := mergePatch(data, patch)
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Applied JSON Merge Patch\n")
+	}
+
+	return js.ValueOf(jsonDataResult(merged))
+}
+
+// generateJSONPatch - Compute the RFC 6902 JSON Patch that turns document a into document b
+func generateJSONPatch(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "generateJSONPatch", "jsonA, jsonB"),
+		})
+	}
+
+	a, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+	b, err := decodeDocument(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	patch := diffPatch(a, b, "", []interface{}{})
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Generated JSON Patch (%d operations)\n", len(patch))
+	}
+
+	result := jsonDataResult(patch)
+	if _, failed := result["error"]; !failed {
+		result["operations"] = len(patch)
+	}
+	return js.ValueOf(result)
+}
+
+// diffJSON - Compare two JSON documents and list the added, removed and changed paths
+func diffJSON(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "diffJSON", "jsonA, jsonB"),
+		})
+	}
+
+	var options diffOptions
+	if len(args) > 2 {
+		if err := decodeOptions(args[2], &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	if options.Tolerance < 0 || math.IsNaN(options.Tolerance) {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("tolerance must not be negative"),
+		})
+	}
+
+	a, err := decodeDocument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+	b, err := decodeDocument(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid JSON: %v", err),
+		})
+	}
+
+	d := &structuralDiff{options: options}
+	d.compare(a, b, "$")
+
+	if !silentMode {
+		fmt.Printf("JSON WASM: Compared JSON documents (%d differences)\n", len(d.entries))
+	}
+
+	result := jsonDataResult(d.report())
+	if _, failed := result["error"]; !failed {
+		result["equal"] = len(d.entries) == 0
+		result["count"] = len(d.entries)
+		result["summary"] = d.summary()
+		result["text"] = d.text()
+	}
+	return js.ValueOf(result)
+}
+
+// Build metadata, injected by wasm-manager build through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"json",
+	"json5",
+	"json-profiling",
+	"xml",
+	"csv",
+	"csv-schema",
+	"native-values",
+	"data-validation",
+	"geojson",
+	"yaml",
+	"toml",
+	"ini",
+	"msgpack",
+	"cbor",
+	"bson",
+	"protobuf",
+	"ndjson",
+	"jsonpath",
+	"jq",
+	"json-schema",
+	"schema-inference",
+	"type-generation",
+	"mock-data",
+	"merge",
+	"flatten",
+	"json-patch",
+	"json-diff",
+	"xpath",
+	"xslt",
+	"xml-formatting",
+	"xml-c14n",
+	"html-extraction",
+	"xml2js",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	buffered := 0
+	for _, p := range ndjsonParsers {
+		buffered += len(p.pending)
+	}
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"ndjsonParsers":  len(ndjsonParsers),
+		"ndjsonBuffered": buffered,
+		"protoMessages":  len(protoMessages),
+	}))
+}
+
+// releaseResources - Free every NDJSON parser and loaded protobuf type and return freed memory to
+// the runtime
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{
+		"ndjsonParsers": len(ndjsonParsers),
+		"protoMessages": len(protoMessages),
+	}
+	ndjsonParsers = map[string]*ndjsonParser{}
+	protoMessages = map[string]*protoMessage{}
+	protoEnums = map[string]*protoEnum{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	advertised := getAvailableFunctions(this, nil).(js.Value)
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "jsonxml-wasm",
+		"version":         moduleVersion,
+		"description":     "JSON, XML, CSV and YAML processing module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions - Return list of available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"parseJSON",
+		"parseJSON5",
+		"stringifyJSON",
+		"validateJSON",
+		"minifyJSON",
+		"profileJSON",
+		"parseXML",
+		"xmlToJSON",
+		"jsonToXML",
+		"validateXML",
+		"queryXML",
+		"queryXMLFirst",
+		"transformXML",
+		"formatXML",
+		"minifyXML",
+		"c14nXML",
+		"htmlTablesToJSON",
+		"htmlListsToJSON",
+		"csvToJSON",
+		"inferCSVSchema",
+		"validateData",
+		"jsonToCSV",
+		"validateGeoJSON",
+		"csvToGeoJSON",
+		"geojsonToCSV",
+		"yamlToJSON",
+		"jsonToYAML",
+		"tomlToJSON",
+		"jsonToTOML",
+		"iniToJSON",
+		"jsonToINI",
+		"encodeMsgPack",
+		"decodeMsgPack",
+		"encodeCBOR",
+		"decodeCBOR",
+		"decodeBSON",
+		"loadProtoDescriptor",
+		"encodeProto",
+		"decodeProto",
+		"createNDJSONParser",
+		"feedNDJSON",
+		"finishNDJSON",
+		"freeNDJSONParser",
+		"extractJSONPath",
+		"transformJSON",
+		"validateJSONSchema",
+		"generateJSONSchema",
+		"jsonToTypeScript",
+		"jsonToGoStruct",
+		"generateMockData",
+		"mergeJSON",
+		"cloneJSON",
+		"pruneJSON",
+		"flattenJSON",
+		"unflattenJSON",
+		"applyJSONPatch",
+		"applyMergePatch",
+		"generateJSONPatch",
+		"diffJSON",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+	for _, native := range nativeFunctions {
+		functions = append(functions, native.name+"Native")
+	}
+	return js.ValueOf(functions)
+}
+
+// Helper functions
+
+func parseJSValue(value js.Value) interface{} {
+	switch value.Type() {
+	case js.TypeBoolean:
+		return value.Bool()
+	case js.TypeNumber:
+		return value.Float()
+	case js.TypeString:
+		return value.String()
+	case js.TypeObject:
+		if value.Get("constructor").Get("name").String() == "Array" {
+			length := value.Get("length").Int()
+			result := make([]interface{}, length)
+			for i := 0; i < length; i++ {
+				result[i] = parseJSValue(value.Index(i))
+			}
+			return result
+		} else {
+			result := make(map[string]interface{})
+			keys := js.Global().Get("Object").Call("keys", value)
+			for i := 0; i < keys.Get("length").Int(); i++ {
+				key := keys.Index(i).String()
+				result[key] = parseJSValue(value.Get(key))
+			}
+			return result
+		}
+	case js.TypeNull:
+		return nil
+	default:
+		return value.String()
+	}
+}
+
+// nativeResults is set while a Native variant runs: jsonDataResult then returns the document itself
+// as a JS value rather than JSON text
+var nativeResults bool
+
+// nativeVariant wraps a function returning a JSON document into its Native variant
+func nativeVariant(fn func(js.Value, []js.Value) interface{}) func(js.Value, []js.Value) interface{} {
+	return func(this js.Value, args []js.Value) interface{} {
+		nativeResults = true
+		defer func() { nativeResults = false }()
+		return fn(this, args)
+	}
+}
+
+// nativeFunctions are the functions returning a JSON document that also come as a Native variant,
+// such as transformJSONNative, whose data is a JS object or array
+var nativeFunctions = []struct {
+	name string
+	fn   func(js.Value, []js.Value) interface{}
+}{
+	{"xmlToJSON", xmlToJSON},
+	{"queryXML", queryXML},
+	{"queryXMLFirst", queryXMLFirst},
+	{"htmlTablesToJSON", htmlTablesToJSON},
+	{"htmlListsToJSON", htmlListsToJSON},
+	{"csvToJSON", csvToJSON},
+	{"csvToGeoJSON", csvToGeoJSON},
+	{"yamlToJSON", yamlToJSON},
+	{"tomlToJSON", tomlToJSON},
+	{"iniToJSON", iniToJSON},
+	{"decodeMsgPack", decodeMsgPack},
+	{"decodeCBOR", decodeCBOR},
+	{"decodeBSON", decodeBSON},
+	{"decodeProto", decodeProto},
+	{"extractJSONPath", extractJSONPath},
+	{"transformJSON", transformJSON},
+	{"generateJSONSchema", generateJSONSchema},
+	{"generateMockData", generateMockData},
+	{"mergeJSON", mergeJSON},
+	{"cloneJSON", cloneJSON},
+	{"pruneJSON", pruneJSON},
+	{"flattenJSON", flattenJSON},
+	{"unflattenJSON", unflattenJSON},
+	{"applyJSONPatch", applyJSONPatch},
+	{"applyMergePatch", applyMergePatch},
+	{"generateJSONPatch", generateJSONPatch},
+	{"diffJSON", diffJSON},
+}
+
+// xml2jsOptions configures the xml2js mode of xmlToJSON, with the option names and defaults of xml2js.
+// AttrNamePrefix is added to attribute names, such as "@" with mergeAttrs.
+type xml2jsOptions struct {
+	Mode           string `json:"mode"`
+	AttrKey        string `json:"attrkey"`
+	CharKey        string `json:"charkey"`
+	ExplicitArray  bool   `json:"explicitArray"`
+	ExplicitRoot   bool   `json:"explicitRoot"`
+	MergeAttrs     bool   `json:"mergeAttrs"`
+	AttrNamePrefix string `json:"attrNamePrefix"`
+	Trim           bool   `json:"trim"`
+	XMLNS          bool   `json:"xmlns"`
+}
+
+// document converts the root element, wrapped in an object named after it with explicitRoot
+func (o xml2jsOptions) document(doc *xnode) interface{} {
+	for _, child := range doc.children {
+		if child.kind != xelementNode {
+			continue
+		}
+		if !o.ExplicitRoot {
+			return o.element(child)
+		}
+		root := &jsonObject{values: map[string]interface{}{}}
+		root.set(child.qname(), o.element(child))
+		return root
+	}
+	return nil
+}
+
+// element converts an element: a string when it only holds text, otherwise an object of its
+// attributes, text and child elements by qualified name
+func (o xml2jsOptions) element(n *xnode) interface{} {
+	object := &jsonObject{values: map[string]interface{}{}}
+
+	// Namespace declarations are attributes to xml2js, listed before the others
+	type attribute struct {
+		prefix, local, uri, value string
+	}
+	attributes := []attribute{}
+	prefixes := make([]string, 0, len(n.namespaces))
+	for prefix := range n.namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			attributes = append(attributes, attribute{"", "xmlns", "http://www.w3.org/2000/xmlns/", n.namespaces[prefix]})
+		} else {
+			attributes = append(attributes, attribute{"xmlns", prefix, "http://www.w3.org/2000/xmlns/", n.namespaces[prefix]})
+		}
+	}
+	for _, a := range n.attrs {
+		attributes = append(attributes, attribute{a.prefix, a.name.Local, a.name.Space, a.value})
+	}
+
+	if len(attributes) > 0 {
+		attrs := &jsonObject{values: map[string]interface{}{}}
+		for _, a := range attributes {
+			name := a.local
+			if a.prefix != "" {
+				name = a.prefix + ":" + a.local
+			}
+			var value interface{} = a.value
+			if o.XMLNS {
+				value = map[string]interface{}{"name": name, "value": a.value, "prefix": a.prefix, "local": a.local, "uri": a.uri}
+			}
+			if !o.MergeAttrs {
+				attrs.set(o.AttrNamePrefix+name, value)
+				continue
+			}
+			if o.ExplicitArray {
+				value = []interface{}{value}
+			}
+			object.set(o.AttrNamePrefix+name, value)
+		}
+		if !o.MergeAttrs {
+			object.set(o.AttrKey, attrs)
+		}
+	}
+	if o.XMLNS {
+		object.set("$ns", map[string]interface{}{"local": n.name.Local, "uri": n.name.Space})
+	}
+
+	// Text pieces are joined; whitespace between elements only is not text
+	var text strings.Builder
+	for _, child := range n.children {
+		if child.kind == xtextNode {
+			text.WriteString(child.value)
+		}
+	}
+	content := text.String()
+	if o.Trim {
+		content = strings.TrimSpace(content)
+	}
+	if strings.TrimSpace(content) == "" {
+		content = ""
+	}
+
+	hasChildren := false
+	for _, child := range n.children {
+		if child.kind == xelementNode {
+			hasChildren = true
+		}
+	}
+	if len(object.keys) == 0 && !hasChildren {
+		return content
+	}
+	if content != "" {
+		object.set(o.CharKey, content)
+	}
+
+	for _, child := range n.children {
+		if child.kind != xelementNode {
+			continue
+		}
+		name, value := child.qname(), o.element(child)
+		existing, seen := object.values[name]
+		switch {
+		case o.ExplicitArray && seen:
+			object.values[name] = append(existing.([]interface{}), value)
+		case o.ExplicitArray:
+			object.set(name, []interface{}{value})
+		case seen:
+			// A repeated element turns the member into an array
+			if list, ok := existing.([]interface{}); ok {
+				object.values[name] = append(list, value)
+			} else {
+				object.values[name] = []interface{}{existing, value}
+			}
+		default:
+			object.set(name, value)
+		}
+	}
+	return object
+}
+
+func xmlNodeToMap(node *xmlquery.Node) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Type {
+	case xmlquery.DocumentNode:
+		// The document converts to its root element, keyed by name like the children of an element
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == xmlquery.ElementNode {
+				return map[string]interface{}{child.Data: xmlNodeToMap(child)}
+			}
+		}
+		return nil
+	case xmlquery.TextNode:
+		return strings.TrimSpace(node.Data)
+	case xmlquery.ElementNode:
+		result := make(map[string]interface{})
+
+		// Add attributes
+		if len(node.Attr) > 0 {
+			attrs := make(map[string]string)
+			for _, attr := range node.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			result["@attributes"] = attrs
+		}
+
+		// Process children
+		var children []interface{}
+		var textContent string
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			switch child.Type {
+			case xmlquery.TextNode:
+				text := strings.TrimSpace(child.Data)
+				if text != "" {
+					textContent += text
+				}
+			case xmlquery.ElementNode:
+				children = append(children, map[string]interface{}{
+					child.Data: xmlNodeToMap(child),
+				})
+			}
+		}
+
+		if textContent != "" {
+			result["#text"] = textContent
+		}
+
+		if len(children) > 0 {
+			result["children"] = children
+		}
+
+		return result
+	}
+
+	return nil
+}
+
+func mapToXML(data interface{}, tagName string, indent int) string {
+	indentStr := strings.Repeat("  ", indent)
+
+	switch v := data.(type) {
+	case map[string]interface{}:
+		var result strings.Builder
+		result.WriteString(fmt.Sprintf("%s<%s>\n", indentStr, tagName))
+
+		for key, value := range v {
+			result.WriteString(mapToXML(value, key, indent+1))
+		}
+
+		result.WriteString(fmt.Sprintf("%s</%s>\n", indentStr, tagName))
+		return result.String()
+
+	case []interface{}:
+		var result strings.Builder
+		for _, item := range v {
+			result.WriteString(mapToXML(item, tagName, indent))
+		}
+		return result.String()
+
+	default:
+		return fmt.Sprintf("%s<%s>%v</%s>\n", indentStr, tagName, v, tagName)
+	}
+}
+
+// JSONPath (RFC 9535) queries for extractJSONPath
+
+// jsonObject is a decoded JSON object that keeps its members in document order, so wildcard and
+// descendant queries return matches in the order they appear
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// MarshalJSON writes the members in document order
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// set adds or replaces a member, new members going last
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, seen := o.values[key]; !seen {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// decodeOrderedJSON decodes a JSON document like json.Unmarshal, objects becoming *jsonObject
+func decodeOrderedJSON(data []byte) (interface{}, error) {
+	return decodeOrdered(data, false)
+}
+
+// decodeOrdered decodes like decodeOrderedJSON, with numbers kept as json.Number when useNumber is
+// set, for the encoders that tell integers from floats and must keep integers beyond 2^53 exact
+func decodeOrdered(data []byte, useNumber bool) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if useNumber {
+		dec.UseNumber()
+	}
+	value, err := decodeOrderedValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New(localize("unexpected data after the JSON value"))
+	}
+	return value, nil
+}
+
+// decodeOrderedValue reads the next value from the token stream
+func decodeOrderedValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := &jsonObject{values: map[string]interface{}{}}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			name := key.(string)
+			if _, seen := object.values[name]; !seen {
+				object.keys = append(object.keys, name)
+			}
+			object.values[name] = value
+		}
+		_, err := dec.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err := dec.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// jsonPathNode is a value matched by a query along with its normalized path, such as $['store']['book'][0]
+type jsonPathNode struct {
+	path  string
+	value interface{}
+}
+
+// jsonPathQuery is a parsed query: segments applied in turn from the root ($) or the current node (@)
+type jsonPathQuery struct {
+	relative bool
+	segments []jsonPathSegment
+}
+
+// jsonPathSegment selects children of each input node, or of the node and all its descendants
+type jsonPathSegment struct {
+	descendant bool
+	selectors  []jsonPathSelector
+}
+
+// jsonPathSelector kinds
+const (
+	selectName = iota
+	selectIndex
+	selectWildcard
+	selectSlice
+	selectFilter
+)
+
+// jsonPathSelector is one selector of a segment. lenient name selectors come from dot notation paths
+// without $ and also index arrays, as in items.0.id
+type jsonPathSelector struct {
+	kind    int
+	name    string
+	lenient bool
+	index   int
+	slice   [3]*int
+	filter  *jsonPathExpr
+}
+
+// singular reports whether the query selects at most one node, as comparisons require
+func (q *jsonPathQuery) singular() bool {
+	for _, segment := range q.segments {
+		if segment.descendant || len(segment.selectors) != 1 {
+			return false
+		}
+		if kind := segment.selectors[0].kind; kind != selectName && kind != selectIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// apply runs the query against a root document and the current node of a filter
+func (q *jsonPathQuery) apply(root, current interface{}) []jsonPathNode {
+	nodes := []jsonPathNode{{path: "$", value: root}}
+	if q.relative {
+		nodes = []jsonPathNode{{path: "@", value: current}}
+	}
+	for _, segment := range q.segments {
+		var next []jsonPathNode
+		for _, node := range nodes {
+			if segment.descendant {
+				for _, descendant := range jsonPathDescendants(node, nil) {
+					next = segment.selectChildren(root, descendant, next)
+				}
+			} else {
+				next = segment.selectChildren(root, node, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// jsonPathDescendants appends the node and all its descendants, in document order
+func jsonPathDescendants(node jsonPathNode, nodes []jsonPathNode) []jsonPathNode {
+	nodes = append(nodes, node)
+	for _, child := range jsonPathChildren(node) {
+		nodes = jsonPathDescendants(child, nodes)
+	}
+	return nodes
+}
+
+// jsonPathChildren returns the members of an object or the elements of an array
+func jsonPathChildren(node jsonPathNode) []jsonPathNode {
+	var children []jsonPathNode
+	switch v := node.value.(type) {
+	case *jsonObject:
+		for _, key := range v.keys {
+			children = append(children, jsonPathNode{path: node.path + jsonPathName(key), value: v.values[key]})
+		}
+	case []interface{}:
+		for i, element := range v {
+			children = append(children, jsonPathNode{path: node.path + "[" + strconv.Itoa(i) + "]", value: element})
+		}
+	}
+	return children
+}
+
+// jsonPathName formats a member name for a normalized path
+func jsonPathName(name string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "['" + replacer.Replace(name) + "']"
+}
+
+// selectChildren appends the children of node matched by the segment's selectors, selector by selector
+func (s jsonPathSegment) selectChildren(root interface{}, node jsonPathNode, out []jsonPathNode) []jsonPathNode {
+	for _, selector := range s.selectors {
+		switch selector.kind {
+		case selectName:
+			switch v := node.value.(type) {
+			case *jsonObject:
+				if value, ok := v.values[selector.name]; ok {
+					out = append(out, jsonPathNode{path: node.path + jsonPathName(selector.name), value: value})
+				}
+			case []interface{}:
+				if i, err := strconv.Atoi(selector.name); selector.lenient && err == nil && i >= 0 && i < len(v) {
+					out = append(out, jsonPathNode{path: node.path + "[" + strconv.Itoa(i) + "]", value: v[i]})
+				}
+			}
+
+		case selectIndex:
+			if array, ok := node.value.([]interface{}); ok {
+				i := selector.index
+				if i < 0 {
+					i += len(array)
+				}
+				if i >= 0 && i < len(array) {
+					out = append(out, jsonPathNode{path: node.path + "[" + strconv.Itoa(i) + "]", value: array[i]})
+				}
+			}
+
+		case selectWildcard:
+			out = append(out, jsonPathChildren(node)...)
+
+		case selectSlice:
+			if array, ok := node.value.([]interface{}); ok {
+				for _, i := range jsonPathSliceIndices(selector.slice, len(array)) {
+					out = append(out, jsonPathNode{path: node.path + "[" + strconv.Itoa(i) + "]", value: array[i]})
+				}
+			}
+
+		case selectFilter:
+			for _, child := range jsonPathChildren(node) {
+				if selector.filter.test(root, child.value) {
+					out = append(out, child)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// jsonPathSliceIndices returns the indices selected by start:end:step on an array of length n
+func jsonPathSliceIndices(slice [3]*int, n int) []int {
+	step := 1
+	if slice[2] != nil {
+		step = *slice[2]
+	}
+	if step == 0 {
+		return nil
+	}
+	normalize := func(i int) int {
+		if i < 0 {
+			return i + n
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int) int {
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+
+	var indices []int
+	if step > 0 {
+		start, end := 0, n
+		if slice[0] != nil {
+			start = clamp(normalize(*slice[0]), 0, n)
+		}
+		if slice[1] != nil {
+			end = clamp(normalize(*slice[1]), 0, n)
+		}
+		for i := start; i < end; i += step {
+			indices = append(indices, i)
+		}
+		return indices
+	}
+
+	start, end := n-1, -1
+	if slice[0] != nil {
+		start = clamp(normalize(*slice[0]), -1, n-1)
+	}
+	if slice[1] != nil {
+		end = clamp(normalize(*slice[1]), -1, n-1)
+	}
+	for i := start; i > end; i += step {
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+// jsonPathExpr is a node of a filter expression. Logical operators (||, &&, !) and comparisons are
+// tests; queries, literals and function calls produce values.
+type jsonPathExpr struct {
+	op       string
+	left     *jsonPathExpr
+	right    *jsonPathExpr
+	query    *jsonPathQuery
+	literal  interface{}
+	function string
+	args     []*jsonPathExpr
+}
+
+// test evaluates the expression as a condition: a query alone tests that it matches something
+func (e *jsonPathExpr) test(root, current interface{}) bool {
+	switch e.op {
+	case "||":
+		return e.left.test(root, current) || e.right.test(root, current)
+	case "&&":
+		return e.left.test(root, current) && e.right.test(root, current)
+	case "!":
+		return !e.left.test(root, current)
+	case "==", "!=", "<", "<=", ">", ">=":
+		left, leftOK := e.left.value(root, current)
+		right, rightOK := e.right.value(root, current)
+		return jsonPathCompare(e.op, left, leftOK, right, rightOK)
+	case "query":
+		return len(e.query.apply(root, current)) > 0
+	}
+	value, ok := e.value(root, current)
+	result, isBool := value.(bool)
+	return ok && isBool && result
+}
+
+// value evaluates the expression as a value; ok is false for Nothing, such as a query matching no node
+func (e *jsonPathExpr) value(root, current interface{}) (interface{}, bool) {
+	switch e.op {
+	case "literal":
+		return e.literal, true
+	case "query":
+		nodes := e.query.apply(root, current)
+		if len(nodes) != 1 {
+			return nil, false
+		}
+		return nodes[0].value, true
+	case "call":
+		return e.call(root, current)
+	}
+	return e.test(root, current), true
+}
+
+// call evaluates the RFC 9535 functions length, count, match, search and value
+func (e *jsonPathExpr) call(root, current interface{}) (interface{}, bool) {
+	switch e.function {
+	case "length":
+		value, ok := e.args[0].value(root, current)
+		if !ok {
+			return nil, false
+		}
+		switch v := value.(type) {
+		case string:
+			return float64(len([]rune(v))), true
+		case []interface{}:
+			return float64(len(v)), true
+		case *jsonObject:
+			return float64(len(v.keys)), true
+		}
+		return nil, false
+	case "count":
+		return float64(len(e.args[0].query.apply(root, current))), true
+	case "value":
+		return e.args[0].value(root, current)
+	case "match", "search":
+		value, ok := e.args[0].value(root, current)
+		pattern, patternOK := e.args[1].value(root, current)
+		text, isString := value.(string)
+		expr, isPattern := pattern.(string)
+		if !ok || !patternOK || !isString || !isPattern {
+			return false, true
+		}
+		if e.function == "match" {
+			expr = "^(?:" + expr + ")$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return false, true
+		}
+		return re.MatchString(text), true
+	}
+	return nil, false
+}
+
+// jsonPathCompare compares two values: Nothing only equals Nothing, and ordering applies to two
+// numbers or two strings
+func jsonPathCompare(op string, left interface{}, leftOK bool, right interface{}, rightOK bool) bool {
+	switch op {
+	case "!=":
+		return !jsonPathCompare("==", left, leftOK, right, rightOK)
+	case ">":
+		return jsonPathCompare("<", right, rightOK, left, leftOK)
+	case ">=":
+		return jsonPathCompare("<=", right, rightOK, left, leftOK)
+	case "<=":
+		return jsonPathCompare("<", left, leftOK, right, rightOK) || jsonPathCompare("==", left, leftOK, right, rightOK)
+	case "==":
+		if !leftOK || !rightOK {
+			return leftOK == rightOK
+		}
+		return jsonPathEqual(left, right)
+	}
+
+	if !leftOK || !rightOK {
+		return false
+	}
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		return ok && l < r
+	case string:
+		r, ok := right.(string)
+		return ok && l < r
+	}
+	return false
+}
+
+// jsonPathEqual compares two JSON values deeply; object member order does not matter
+func jsonPathEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case *jsonObject:
+		y, ok := b.(*jsonObject)
+		if !ok || len(x.keys) != len(y.keys) {
+			return false
+		}
+		for _, key := range x.keys {
+			value, found := y.values[key]
+			if !found || !jsonPathEqual(x.values[key], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonPathEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// jsonPathParser reads a JSONPath query
+type jsonPathParser struct {
+	src string
+	pos int
+}
+
+// parseJSONPath parses a query starting with $. Paths without $ use the dot notation of earlier versions,
+// where numeric segments also index arrays.
+func parseJSONPath(path string) (*jsonPathQuery, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		query := &jsonPathQuery{}
+		for _, part := range splitPath(path) {
+			selector := jsonPathSelector{kind: selectName, name: part, lenient: true}
+			query.segments = append(query.segments, jsonPathSegment{selectors: []jsonPathSelector{selector}})
+		}
+		return query, nil
+	}
+
+	p := &jsonPathParser{src: path}
+	query, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf(localize("unexpected %q"), p.src[p.pos:p.pos+1])
+	}
+	return query, nil
+}
+
+// errorf reports a syntax error at the current position
+func (p *jsonPathParser) errorf(format string, args ...interface{}) error {
+	return errors.New(localize("Invalid JSONPath at position %d: %s", p.pos, fmt.Sprintf(format, args...)))
+}
+
+// skipSpace skips blanks, allowed between the tokens of brackets and filters
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume skips blanks and the given token if it comes next
+func (p *jsonPathParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// query parses $ or @ followed by segments
+func (p *jsonPathParser) query() (*jsonPathQuery, error) {
+	query := &jsonPathQuery{relative: p.src[p.pos] == '@'}
+	p.pos++
+	for p.pos < len(p.src) {
+		var segment jsonPathSegment
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], ".."):
+			p.pos += 2
+			segment.descendant = true
+			if p.pos < len(p.src) && p.src[p.pos] == '[' {
+				selectors, err := p.brackets()
+				if err != nil {
+					return nil, err
+				}
+				segment.selectors = selectors
+			} else {
+				selector, err := p.dotSelector()
+				if err != nil {
+					return nil, err
+				}
+				segment.selectors = []jsonPathSelector{selector}
+			}
+		case p.src[p.pos] == '.':
+			p.pos++
+			selector, err := p.dotSelector()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = []jsonPathSelector{selector}
+		case p.src[p.pos] == '[':
+			selectors, err := p.brackets()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = selectors
+		default:
+			return query, nil
+		}
+		query.segments = append(query.segments, segment)
+	}
+	return query, nil
+}
+
+// dotSelector parses the * or member name following a dot
+func (p *jsonPathParser) dotSelector() (jsonPathSelector, error) {
+	if p.pos < len(p.src) && p.src[p.pos] == '*' {
+		p.pos++
+		return jsonPathSelector{kind: selectWildcard}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c == '-' || c >= 0x80 || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || p.pos > start && c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos == start {
+		return jsonPathSelector{}, p.errorf(localize("member name expected"))
+	}
+	return jsonPathSelector{kind: selectName, name: p.src[start:p.pos]}, nil
+}
+
+// brackets parses a comma separated list of selectors between [ and ]
+func (p *jsonPathParser) brackets() ([]jsonPathSelector, error) {
+	p.pos++
+	var selectors []jsonPathSelector
+	for {
+		selector, err := p.bracketSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+		if p.consume(",") {
+			continue
+		}
+		if p.consume("]") {
+			return selectors, nil
+		}
+		return nil, p.errorf(localize("%q expected"), "]")
+	}
+}
+
+// bracketSelector parses a quoted name, *, an index, a slice or a filter
+func (p *jsonPathParser) bracketSelector() (jsonPathSelector, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return jsonPathSelector{}, p.errorf(localize("selector expected"))
+	}
+	switch c := p.src[p.pos]; {
+	case c == '\'' || c == '"':
+		name, err := p.stringLiteral()
+		return jsonPathSelector{kind: selectName, name: name}, err
+	case c == '*':
+		p.pos++
+		return jsonPathSelector{kind: selectWildcard}, nil
+	case c == '?':
+		p.pos++
+		filter, err := p.logicalOr()
+		return jsonPathSelector{kind: selectFilter, filter: filter}, err
+	}
+
+	var bounds [3]*int
+	for part := 0; part < 3; part++ {
+		p.skipSpace()
+		if n, ok := p.integer(); ok {
+			bounds[part] = &n
+		}
+		if part == 2 || !p.consume(":") {
+			if part == 0 {
+				if bounds[0] == nil {
+					return jsonPathSelector{}, p.errorf(localize("selector expected"))
+				}
+				return jsonPathSelector{kind: selectIndex, index: *bounds[0]}, nil
+			}
+			break
+		}
+	}
+	return jsonPathSelector{kind: selectSlice, slice: bounds}, nil
+}
+
+// integer parses an optionally negative integer
+func (p *jsonPathParser) integer() (int, bool) {
+	start := p.pos
+	if p.pos < len(p.src) && p.src[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	return n, true
+}
+
+// stringLiteral parses a single or double quoted string with backslash escapes
+func (p *jsonPathParser) stringLiteral() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.src):
+			escaped := p.src[p.pos]
+			p.pos++
+			switch escaped {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				if p.pos+4 > len(p.src) {
+					return "", p.errorf(localize("invalid escape"))
+				}
+				code, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					return "", p.errorf(localize("invalid escape"))
+				}
+				b.WriteRune(rune(code))
+				p.pos += 4
+			default:
+				b.WriteByte(escaped)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf(localize("unterminated string"))
+}
+
+// logicalOr parses a filter expression: || binds looser than &&, which binds looser than comparisons
+func (p *jsonPathParser) logicalOr() (*jsonPathExpr, error) {
+	left, err := p.logicalAnd()
+	for err == nil && p.consume("||") {
+		var right *jsonPathExpr
+		if right, err = p.logicalAnd(); err == nil {
+			left = &jsonPathExpr{op: "||", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// logicalAnd parses a sequence of comparisons joined by &&
+func (p *jsonPathParser) logicalAnd() (*jsonPathExpr, error) {
+	left, err := p.comparison()
+	for err == nil && p.consume("&&") {
+		var right *jsonPathExpr
+		if right, err = p.comparison(); err == nil {
+			left = &jsonPathExpr{op: "&&", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// comparison parses an operand, optionally compared with a second one
+func (p *jsonPathParser) comparison() (*jsonPathExpr, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			for _, side := range []*jsonPathExpr{left, right} {
+				if side.op == "query" && !side.query.singular() {
+					return nil, p.errorf(localize("comparisons need queries selecting a single value"))
+				}
+			}
+			return &jsonPathExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// operand parses a negation, a parenthesized expression, a query, a function call or a literal
+func (p *jsonPathParser) operand() (*jsonPathExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf(localize("expression expected"))
+	}
+	rest := p.src[p.pos:]
+	switch c := rest[0]; {
+	case c == '!' && !strings.HasPrefix(rest, "!="):
+		p.pos++
+		operand, err := p.operand()
+		return &jsonPathExpr{op: "!", left: operand}, err
+	case c == '(':
+		p.pos++
+		expr, err := p.logicalOr()
+		if err == nil && !p.consume(")") {
+			err = p.errorf(localize("%q expected"), ")")
+		}
+		return expr, err
+	case c == '@' || c == '$':
+		query, err := p.query()
+		return &jsonPathExpr{op: "query", query: query}, err
+	case c == '\'' || c == '"':
+		text, err := p.stringLiteral()
+		return &jsonPathExpr{op: "literal", literal: text}, err
+	case c == '-' || c >= '0' && c <= '9':
+		end := p.pos + 1
+		for end < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[end]) >= 0 {
+			end++
+		}
+		number, err := strconv.ParseFloat(p.src[p.pos:end], 64)
+		if err != nil {
+			return nil, p.errorf(localize("invalid number %q"), p.src[p.pos:end])
+		}
+		p.pos = end
+		return &jsonPathExpr{op: "literal", literal: number}, nil
+	}
+
+	for word, literal := range map[string]interface{}{"true": true, "false": false, "null": nil} {
+		if strings.HasPrefix(rest, word) {
+			p.pos += len(word)
+			return &jsonPathExpr{op: "literal", literal: literal}, nil
+		}
+	}
+	for name, arity := range jsonPathFunctions {
+		if strings.HasPrefix(rest, name+"(") {
+			p.pos += len(name) + 1
+			call := &jsonPathExpr{op: "call", function: name}
+			for i := 0; i < arity; i++ {
+				if i > 0 && !p.consume(",") {
+					return nil, p.errorf(localize("%q expected"), ",")
+				}
+				arg, err := p.logicalOr()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+			}
+			if !p.consume(")") {
+				return nil, p.errorf(localize("%q expected"), ")")
+			}
+			if name == "count" && call.args[0].op != "query" {
+				return nil, p.errorf(localize("%s() takes a query"), name)
+			}
+			return call, nil
+		}
+	}
+	return nil, p.errorf(localize("expression expected"))
+}
+
+// jsonPathFunctions lists the filter functions with their number of arguments
+var jsonPathFunctions = map[string]int{
+	"length": 1,
+	"count":  1,
+	"match":  2,
+	"search": 2,
+	"value":  1,
+}
+
+func getJSONType(data interface{}) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, int:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+// jq expressions for transformJSON
+
+// jqOptions configures transformJSON: Variables binds $name to each value
+type jqOptions struct {
+	Variables map[string]json.RawMessage `json:"variables"`
+}
+
+// jqNode kinds
+const (
+	jqIdentity = iota
+	jqRecurseAll
+	jqLiteral
+	jqString
+	jqFormat
+	jqVariable
+	jqField
+	jqIndex
+	jqSlice
+	jqIterate
+	jqArray
+	jqObject
+	jqPipe
+	jqComma
+	jqAlternative
+	jqAnd
+	jqOr
+	jqBinary
+	jqNegate
+	jqIf
+	jqTry
+	jqBind
+	jqReduce
+	jqCall
+)
+
+// jqMaxDepth bounds recurse(f), whose filter may never stop descending
+const jqMaxDepth = 1000
+
+// jqNode is a node of a parsed jq expression. left and right are the operands; third is the else
+// branch of if, the end of a slice and the update of reduce.
+type jqNode struct {
+	kind    int
+	op      string
+	name    string
+	value   interface{}
+	left    *jqNode
+	right   *jqNode
+	third   *jqNode
+	args    []*jqNode
+	parts   []jqPart
+	entries []jqEntry
+}
+
+// jqPart is a piece of a string literal: text, or an interpolated \(expression)
+type jqPart struct {
+	text string
+	expr *jqNode
+}
+
+// jqEntry is a member of an object construction, {key: value}
+type jqEntry struct {
+	key   *jqNode
+	value *jqNode
+}
+
+// jqEnv binds the variables in scope, innermost first
+type jqEnv struct {
+	name   string
+	value  interface{}
+	parent *jqEnv
+}
+
+func (e *jqEnv) bind(name string, value interface{}) *jqEnv {
+	return &jqEnv{name: name, value: value, parent: e}
+}
+
+func (e *jqEnv) lookup(name string) (interface{}, bool) {
+	for ; e != nil; e = e.parent {
+		if e.name == name {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// jqEmit receives the outputs of an expression one by one. jq expressions are generators: returning
+// an error stops the one producing them.
+type jqEmit func(value interface{}) error
+
+// jqError is raised by error(value); try ... catch receives the value
+type jqError struct {
+	value interface{}
+}
+
+func (e *jqError) Error() string {
+	if message, ok := e.value.(string); ok {
+		return message
+	}
+	return localize("%s (not a string)", jqCompact(e.value))
+}
+
+// jqBreak stops a generator once limit, first or isempty have the outputs they need. Each call
+// allocates its own, so nested calls only stop their own generator.
+type jqBreak struct{}
+
+func (*jqBreak) Error() string {
+	return "break"
+}
+
+// jqPassed carries an error raised downstream of a try body through it, so the try does not catch it
+type jqPassed struct {
+	err   error
+	owner *int
+}
+
+func (e *jqPassed) Error() string {
+	return e.err.Error()
+}
+
+// eval runs the expression on input and emits its outputs
+func (n *jqNode) eval(input interface{}, env *jqEnv, emit jqEmit) error {
+	switch n.kind {
+	case jqIdentity:
+		return emit(input)
+
+	case jqRecurseAll:
+		return jqRecurse(input, emit)
+
+	case jqLiteral:
+		return emit(n.value)
+
+	case jqString:
+		return n.evalString(input, env, 0, "", emit)
+
+	case jqFormat:
+		text, err := jqFormatValue(n.op, input)
+		if err != nil {
+			return err
+		}
+		return emit(text)
+
+	case jqVariable:
+		value, ok := env.lookup(n.name)
+		if !ok {
+			return errors.New(localize("undefined variable $%s", n.name))
+		}
+		return emit(value)
+
+	case jqField:
+		return n.left.eval(input, env, func(value interface{}) error {
+			member, err := jqIndexValue(value, n.name)
+			if err != nil {
+				return err
+			}
+			return emit(member)
+		})
+
+	case jqIndex:
+		return n.right.eval(input, env, func(key interface{}) error {
+			return n.left.eval(input, env, func(value interface{}) error {
+				member, err := jqIndexValue(value, key)
+				if err != nil {
+					return err
+				}
+				return emit(member)
+			})
+		})
+
+	case jqSlice:
+		return jqOptionalEval(n.third, input, env, func(to interface{}) error {
+			return jqOptionalEval(n.right, input, env, func(from interface{}) error {
+				return n.left.eval(input, env, func(value interface{}) error {
+					slice, err := jqSliceValue(value, from, to)
+					if err != nil {
+						return err
+					}
+					return emit(slice)
+				})
+			})
+		})
+
+	case jqIterate:
+		return n.left.eval(input, env, func(value interface{}) error {
+			values, err := jqValues(value)
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				if err := emit(v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	case jqArray:
+		array := []interface{}{}
+		if n.left != nil {
+			err := n.left.eval(input, env, func(value interface{}) error {
+				array = append(array, value)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return emit(array)
+
+	case jqObject:
+		return n.evalObject(input, env, 0, &jsonObject{values: map[string]interface{}{}}, emit)
+
+	case jqPipe:
+		return n.left.eval(input, env, func(value interface{}) error {
+			return n.right.eval(value, env, emit)
+		})
+
+	case jqComma:
+		if err := n.left.eval(input, env, emit); err != nil {
+			return err
+		}
+		return n.right.eval(input, env, emit)
+
+	case jqAlternative:
+		// Errors on the left count as false; the left outputs are collected first so that errors
+		// raised downstream are not swallowed with them
+		var truthy []interface{}
+		err := n.left.eval(input, env, func(value interface{}) error {
+			if jqTruthy(value) {
+				truthy = append(truthy, value)
+			}
+			return nil
+		})
+		if _, isBreak := err.(*jqBreak); isBreak {
+			return err
+		}
+		if len(truthy) == 0 {
+			return n.right.eval(input, env, emit)
+		}
+		for _, value := range truthy {
+			if err := emit(value); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case jqAnd, jqOr:
+		return n.left.eval(input, env, func(left interface{}) error {
+			if jqTruthy(left) == (n.kind == jqOr) {
+				return emit(n.kind == jqOr)
+			}
+			return n.right.eval(input, env, func(right interface{}) error {
+				return emit(jqTruthy(right))
+			})
+		})
+
+	case jqBinary:
+		return n.right.eval(input, env, func(right interface{}) error {
+			return n.left.eval(input, env, func(left interface{}) error {
+				value, err := jqBinaryOp(n.op, left, right)
+				if err != nil {
+					return err
+				}
+				return emit(value)
+			})
+		})
+
+	case jqNegate:
+		return n.left.eval(input, env, func(value interface{}) error {
+			number, ok := value.(float64)
+			if !ok {
+				return errors.New(localize("%s cannot be negated", jqDescribe(value)))
+			}
+			return emit(-number)
+		})
+
+	case jqIf:
+		return n.left.eval(input, env, func(condition interface{}) error {
+			switch {
+			case jqTruthy(condition):
+				return n.right.eval(input, env, emit)
+			case n.third != nil:
+				return n.third.eval(input, env, emit)
+			}
+			return emit(input)
+		})
+
+	case jqTry:
+		owner := new(int)
+		err := n.left.eval(input, env, func(value interface{}) error {
+			if err := emit(value); err != nil {
+				return &jqPassed{err: err, owner: owner}
+			}
+			return nil
+		})
+		switch e := err.(type) {
+		case nil, *jqBreak:
+			return err
+		case *jqPassed:
+			if e.owner == owner {
+				return e.err
+			}
+			return err
+		}
+		if n.right == nil {
+			return nil
+		}
+		var caught interface{} = err.Error()
+		if e, ok := err.(*jqError); ok {
+			caught = e.value
+		}
+		return n.right.eval(caught, env, emit)
+
+	case jqBind:
+		return n.left.eval(input, env, func(value interface{}) error {
+			return n.right.eval(input, env.bind(n.name, value), emit)
+		})
+
+	case jqReduce:
+		return n.right.eval(input, env, func(initial interface{}) error {
+			accumulator := initial
+			err := n.left.eval(input, env, func(value interface{}) error {
+				var last interface{}
+				err := n.third.eval(accumulator, env.bind(n.name, value), func(updated interface{}) error {
+					last = updated
+					return nil
+				})
+				accumulator = last
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return emit(accumulator)
+		})
+
+	case jqCall:
+		return jqBuiltins[n.name+"/"+strconv.Itoa(len(n.args))](n, input, env, emit)
+	}
+	return nil
+}
+
+// jqOptionalEval emits null for an omitted slice bound
+func jqOptionalEval(n *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+	if n == nil {
+		return emit(nil)
+	}
+	return n.eval(input, env, emit)
+}
+
+// evalString emits the string built from parts i onwards, one output per combination of the
+// interpolated values
+func (n *jqNode) evalString(input interface{}, env *jqEnv, i int, prefix string, emit jqEmit) error {
+	if i == len(n.parts) {
+		return emit(prefix)
+	}
+	part := n.parts[i]
+	if part.expr == nil {
+		return n.evalString(input, env, i+1, prefix+part.text, emit)
+	}
+	return part.expr.eval(input, env, func(value interface{}) error {
+		text, err := jqFormatValue(n.op, value)
+		if err != nil {
+			return err
+		}
+		return n.evalString(input, env, i+1, prefix+text, emit)
+	})
+}
+
+// evalObject emits the objects built from entries i onwards, one output per combination of keys and values
+func (n *jqNode) evalObject(input interface{}, env *jqEnv, i int, object *jsonObject, emit jqEmit) error {
+	if i == len(n.entries) {
+		return emit(object)
+	}
+	entry := n.entries[i]
+	return entry.key.eval(input, env, func(key interface{}) error {
+		name, ok := key.(string)
+		if !ok {
+			return errors.New(localize("object keys must be strings, got %s", jqDescribe(key)))
+		}
+		return entry.value.eval(input, env, func(value interface{}) error {
+			next := &jsonObject{keys: append([]string(nil), object.keys...), values: make(map[string]interface{}, len(object.keys)+1)}
+			for k, v := range object.values {
+				next.values[k] = v
+			}
+			next.set(name, value)
+			return n.evalObject(input, env, i+1, next, emit)
+		})
+	})
+}
+
+// jqCollect returns every output of an expression
+func jqCollect(n *jqNode, input interface{}, env *jqEnv) ([]interface{}, error) {
+	outputs := []interface{}{}
+	err := n.eval(input, env, func(value interface{}) error {
+		outputs = append(outputs, value)
+		return nil
+	})
+	return outputs, err
+}
+
+// jqFirst returns the first output of an expression, if any
+func jqFirst(n *jqNode, input interface{}, env *jqEnv) (interface{}, bool, error) {
+	var first interface{}
+	found := false
+	stop := &jqBreak{}
+	err := n.eval(input, env, func(value interface{}) error {
+		first, found = value, true
+		return stop
+	})
+	if err == stop {
+		err = nil
+	}
+	return first, found, err
+}
+
+// jqRecurse emits a value and everything below it, as recurse does
+func jqRecurse(value interface{}, emit jqEmit) error {
+	if err := emit(value); err != nil {
+		return err
+	}
+	switch value.(type) {
+	case []interface{}, *jsonObject:
+		children, _ := jqValues(value)
+		for _, child := range children {
+			if err := jqRecurse(child, emit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jqRecurseWith emits a value, then recurses on each output of f
+func jqRecurseWith(f *jqNode, value interface{}, env *jqEnv, depth int, emit jqEmit) error {
+	if depth > jqMaxDepth {
+		return errors.New(localize("recursion deeper than %d levels", jqMaxDepth))
+	}
+	if err := emit(value); err != nil {
+		return err
+	}
+	return f.eval(value, env, func(child interface{}) error {
+		return jqRecurseWith(f, child, env, depth+1, emit)
+	})
+}
+
+// jqWalk applies f bottom-up to every value, as walk does
+func jqWalk(f *jqNode, value interface{}, env *jqEnv, emit jqEmit) error {
+	switch v := value.(type) {
+	case []interface{}:
+		walked := []interface{}{}
+		for _, element := range v {
+			err := jqWalk(f, element, env, func(result interface{}) error {
+				walked = append(walked, result)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		value = walked
+	case *jsonObject:
+		walked := &jsonObject{values: make(map[string]interface{}, len(v.keys))}
+		for _, key := range v.keys {
+			found := false
+			err := jqWalk(f, v.values[key], env, func(result interface{}) error {
+				if !found {
+					found = true
+					walked.set(key, result)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		value = walked
+	}
+	return f.eval(value, env, emit)
+}
+
+// jqTruthy reports whether a value counts as true: everything but null and false
+func jqTruthy(value interface{}) bool {
+	return value != nil && value != false
+}
+
+// jqTypeName returns the jq type of a value
+func jqTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// jqDescribe names a value in error messages, as in number (42)
+func jqDescribe(value interface{}) string {
+	text := jqCompact(value)
+	if len(text) > 30 {
+		cut := 27
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "..."
+	}
+	return jqTypeName(value) + " (" + text + ")"
+}
+
+// jqCompact encodes a value as compact JSON, the form tostring and tojson produce
+func jqCompact(value interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(jqFinite(value)); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jqFinite replaces NaN by null and infinities by the largest finite numbers, as jq prints them, since
+// JSON cannot represent them
+func jqFinite(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return nil
+		case math.IsInf(v, 1):
+			return math.MaxFloat64
+		case math.IsInf(v, -1):
+			return -math.MaxFloat64
+		}
+	case []interface{}:
+		finite := make([]interface{}, len(v))
+		for i, element := range v {
+			finite[i] = jqFinite(element)
+		}
+		return finite
+	case *jsonObject:
+		finite := &jsonObject{keys: v.keys, values: make(map[string]interface{}, len(v.keys))}
+		for key, element := range v.values {
+			finite.values[key] = jqFinite(element)
+		}
+		return finite
+	}
+	return value
+}
+
+// jqOrder ranks the types in the jq sort order: null, false, true, numbers, strings, arrays, objects
+func jqOrder(value interface{}) int {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+// jqCompare orders two values as jq sorts them. Objects compare by their sorted keys first, then by
+// the values of those keys.
+func jqCompare(a, b interface{}) int {
+	oa, ob := jqOrder(a), jqOrder(b)
+	if oa != ob {
+		if oa < ob {
+			return -1
+		}
+		return 1
+	}
+
+	switch x := a.(type) {
+	case float64:
+		y := b.(float64)
+		switch {
+		case x < y || (math.IsNaN(x) && !math.IsNaN(y)):
+			return -1
+		case x > y || (math.IsNaN(y) && !math.IsNaN(x)):
+			return 1
+		}
+	case string:
+		return strings.Compare(x, b.(string))
+	case []interface{}:
+		y := b.([]interface{})
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := jqCompare(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return jqCompare(float64(len(x)), float64(len(y)))
+	case *jsonObject:
+		y := b.(*jsonObject)
+		kx, ky := jqSortedKeys(x), jqSortedKeys(y)
+		if c := jqCompare(kx, ky); c != 0 {
+			return c
+		}
+		for _, key := range kx {
+			if c := jqCompare(x.values[key.(string)], y.values[key.(string)]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// jqSortedKeys returns the keys of an object sorted, as keys does
+func jqSortedKeys(object *jsonObject) []interface{} {
+	names := append([]string(nil), object.keys...)
+	sort.Strings(names)
+	keys := make([]interface{}, len(names))
+	for i, name := range names {
+		keys[i] = name
+	}
+	return keys
+}
+
+// jqValues returns the elements of an array or the values of an object, as .[] iterates them
+func jqValues(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case *jsonObject:
+		values := make([]interface{}, len(v.keys))
+		for i, key := range v.keys {
+			values[i] = v.values[key]
+		}
+		return values, nil
+	}
+	return nil, errors.New(localize("cannot iterate over %s", jqDescribe(value)))
+}
+
+// jqIndexValue reads .[key]: an object member, or an array element counted from the end when
+// negative. Missing members and elements, and any index of null, are null.
+func jqIndexValue(value, key interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		switch key.(type) {
+		case nil, string, float64:
+			return nil, nil
+		}
+	case *jsonObject:
+		if name, ok := key.(string); ok {
+			return v.values[name], nil
+		}
+	case []interface{}:
+		if number, ok := key.(float64); ok {
+			i := int(math.Floor(number))
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return nil, nil
+			}
+			return v[i], nil
+		}
+	}
+	return nil, errors.New(localize("cannot index %s with %s", jqDescribe(value), jqDescribe(key)))
+}
+
+// jqSliceValue reads .[from:to] of an array or a string, counting characters; null bounds are the ends
+func jqSliceValue(value, from, to interface{}) (interface{}, error) {
+	var length int
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		length = len(v)
+	case string:
+		length = utf8.RuneCountInString(v)
+	default:
+		return nil, errors.New(localize("cannot index %s with %s", jqDescribe(value), jqDescribe(from)))
+	}
+
+	bound := func(b interface{}, fallback int, round func(float64) float64) (int, error) {
+		if b == nil {
+			return fallback, nil
+		}
+		number, ok := b.(float64)
+		if !ok {
+			return 0, errors.New(localize("cannot index %s with %s", jqDescribe(value), jqDescribe(b)))
+		}
+		i := int(round(number))
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			i = 0
+		}
+		if i > length {
+			i = length
+		}
+		return i, nil
+	}
+	start, err := bound(from, 0, math.Floor)
+	if err != nil {
+		return nil, err
+	}
+	end, err := bound(to, length, math.Ceil)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		end = start
+	}
+
+	if array, ok := value.([]interface{}); ok {
+		return append([]interface{}{}, array[start:end]...), nil
+	}
+	runes := []rune(value.(string))
+	return string(runes[start:end]), nil
+}
+
+// jqBinaryOp applies an arithmetic or comparison operator
+func jqBinaryOp(op string, left, right interface{}) (interface{}, error) {
+	switch op {
+	case "==":
+		return jqCompare(left, right) == 0, nil
+	case "!=":
+		return jqCompare(left, right) != 0, nil
+	case "<":
+		return jqCompare(left, right) < 0, nil
+	case "<=":
+		return jqCompare(left, right) <= 0, nil
+	case ">":
+		return jqCompare(left, right) > 0, nil
+	case ">=":
+		return jqCompare(left, right) >= 0, nil
+	case "+":
+		return jqAdd(left, right)
+	}
+
+	x, xNumber := left.(float64)
+	y, yNumber := right.(float64)
+	switch op {
+	case "-":
+		switch {
+		case xNumber && yNumber:
+			return x - y, nil
+		}
+		if a, ok := left.([]interface{}); ok {
+			if b, ok := right.([]interface{}); ok {
+				kept := []interface{}{}
+				for _, element := range a {
+					removed := false
+					for _, other := range b {
+						if jqCompare(element, other) == 0 {
+							removed = true
+							break
+						}
+					}
+					if !removed {
+						kept = append(kept, element)
+					}
+				}
+				return kept, nil
+			}
+		}
+		return nil, errors.New(localize("%s and %s cannot be subtracted", jqDescribe(left), jqDescribe(right)))
+
+	case "*":
+		switch {
+		case xNumber && yNumber:
+			return x * y, nil
+		case xNumber:
+			if text, ok := right.(string); ok {
+				return jqRepeat(text, x), nil
+			}
+		case yNumber:
+			if text, ok := left.(string); ok {
+				return jqRepeat(text, y), nil
+			}
+		}
+		if a, ok := left.(*jsonObject); ok {
+			if b, ok := right.(*jsonObject); ok {
+				return jqDeepMerge(a, b), nil
+			}
+		}
+		return nil, errors.New(localize("%s and %s cannot be multiplied", jqDescribe(left), jqDescribe(right)))
+
+	case "/":
+		if xNumber && yNumber {
+			if y == 0 {
+				return nil, errors.New(localize("%s and %s cannot be divided because the divisor is zero", jqDescribe(left), jqDescribe(right)))
+			}
+			return x / y, nil
+		}
+		if a, ok := left.(string); ok {
+			if b, ok := right.(string); ok {
+				return jqSplit(a, b), nil
+			}
+		}
+		return nil, errors.New(localize("%s and %s cannot be divided", jqDescribe(left), jqDescribe(right)))
+
+	case "%":
+		if xNumber && yNumber {
+			a, b := int64(x), int64(y)
+			if b == 0 {
+				return nil, errors.New(localize("%s and %s cannot be divided because the divisor is zero", jqDescribe(left), jqDescribe(right)))
+			}
+			if b < 0 {
+				b = -b
+			}
+			return float64(a % b), nil
+		}
+		return nil, errors.New(localize("%s and %s cannot be divided", jqDescribe(left), jqDescribe(right)))
+	}
+	return nil, nil
+}
+
+// jqAdd adds numbers, concatenates strings and arrays and merges objects; null is the neutral element
+func jqAdd(left, right interface{}) (interface{}, error) {
+	switch {
+	case left == nil:
+		return right, nil
+	case right == nil:
+		return left, nil
+	}
+	switch a := left.(type) {
+	case float64:
+		if b, ok := right.(float64); ok {
+			return a + b, nil
+		}
+	case string:
+		if b, ok := right.(string); ok {
+			return a + b, nil
+		}
+	case []interface{}:
+		if b, ok := right.([]interface{}); ok {
+			return append(append(make([]interface{}, 0, len(a)+len(b)), a...), b...), nil
+		}
+	case *jsonObject:
+		if b, ok := right.(*jsonObject); ok {
+			merged := &jsonObject{keys: append([]string(nil), a.keys...), values: make(map[string]interface{}, len(a.keys)+len(b.keys))}
+			for k, v := range a.values {
+				merged.values[k] = v
+			}
+			for _, k := range b.keys {
+				merged.set(k, b.values[k])
+			}
+			return merged, nil
+		}
+	}
+	return nil, errors.New(localize("%s and %s cannot be added", jqDescribe(left), jqDescribe(right)))
+}
+
+// jqDeepMerge merges objects recursively, as object multiplication does
+func jqDeepMerge(a, b *jsonObject) *jsonObject {
+	merged := &jsonObject{keys: append([]string(nil), a.keys...), values: make(map[string]interface{}, len(a.keys)+len(b.keys))}
+	for k, v := range a.values {
+		merged.values[k] = v
+	}
+	for _, k := range b.keys {
+		if x, ok := merged.values[k].(*jsonObject); ok {
+			if y, ok := b.values[k].(*jsonObject); ok {
+				merged.set(k, jqDeepMerge(x, y))
+				continue
+			}
+		}
+		merged.set(k, b.values[k])
+	}
+	return merged
+}
+
+// jqRepeat repeats a string; a count below 1 gives null
+func jqRepeat(text string, count float64) interface{} {
+	if count < 1 {
+		return nil
+	}
+	return strings.Repeat(text, int(count))
+}
+
+// jqSplit splits a string on a separator into an array of strings
+func jqSplit(text, separator string) []interface{} {
+	parts := []interface{}{}
+	if text == "" {
+		return parts
+	}
+	for _, part := range strings.Split(text, separator) {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// jqContains reports whether a contains b: substrings for strings, every element of b being
+// contained in some element of a for arrays, and recursively by key for objects
+func jqContains(a, b interface{}) bool {
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Contains(x, y)
+		}
+	case []interface{}:
+		if y, ok := b.([]interface{}); ok {
+			for _, wanted := range y {
+				found := false
+				for _, element := range x {
+					if jqContains(element, wanted) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return false
+				}
+			}
+			return true
+		}
+	case *jsonObject:
+		if y, ok := b.(*jsonObject); ok {
+			for _, key := range y.keys {
+				value, present := x.values[key]
+				if !present || !jqContains(value, y.values[key]) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return jqCompare(a, b) == 0
+}
+
+// jqFormatValue formats a value with @format, or converts it with tostring when format is empty
+func jqFormatValue(format string, value interface{}) (string, error) {
+	text, isString := value.(string)
+	if !isString {
+		text = jqCompact(value)
+	}
+
+	switch format {
+	case "", "text":
+		return text, nil
+	case "json":
+		return jqCompact(value), nil
+	case "html":
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", "'", "&#39;", `"`, "&quot;").Replace(text), nil
+	case "uri":
+		var b strings.Builder
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+			if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		return b.String(), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString([]byte(text)), nil
+	case "base64d":
+		decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(text, "="))
+		if err != nil {
+			return "", errors.New(localize("%s is not valid base64 data", jqDescribe(value)))
+		}
+		return string(decoded), nil
+	}
+
+	// csv, tsv and sh format rows: arrays of scalars
+	row, ok := value.([]interface{})
+	if !ok {
+		if format != "sh" || !jqScalar(value) {
+			return "", errors.New(localize("%s cannot be formatted with @%s", jqDescribe(value), format))
+		}
+		row = []interface{}{value}
+	}
+	separator := map[string]string{"csv": ",", "tsv": "\t", "sh": " "}[format]
+	cells := make([]string, len(row))
+	for i, cell := range row {
+		if !jqScalar(cell) {
+			return "", errors.New(localize("%s cannot be formatted with @%s", jqDescribe(cell), format))
+		}
+		text, isString := cell.(string)
+		switch {
+		case cell == nil && format != "sh":
+			cells[i] = ""
+		case !isString:
+			cells[i] = jqCompact(cell)
+		case format == "csv":
+			cells[i] = `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+		case format == "tsv":
+			cells[i] = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace(text)
+		default:
+			cells[i] = "'" + strings.ReplaceAll(text, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(cells, separator), nil
+}
+
+// jqFormats lists the @formats
+var jqFormats = map[string]bool{
+	"text": true, "json": true, "html": true, "uri": true, "csv": true, "tsv": true, "sh": true, "base64": true, "base64d": true,
+}
+
+// jqScalar reports whether a value is neither an array nor an object
+func jqScalar(value interface{}) bool {
+	switch value.(type) {
+	case []interface{}, *jsonObject:
+		return false
+	}
+	return true
+}
+
+// jqRegexp compiles a regular expression with jq flags: g (global), i (ignore case), s (dot matches
+// newlines) and n (ignored). Named groups may use the (?<name>...) syntax.
+func jqRegexp(function string, pattern, flags interface{}) (*regexp.Regexp, bool, error) {
+	source, ok := pattern.(string)
+	if !ok {
+		return nil, false, errors.New(localize("%s() requires a %s argument, got %s", function, "string", jqDescribe(pattern)))
+	}
+	modifiers, global := "", false
+	if flags != nil {
+		text, ok := flags.(string)
+		if !ok {
+			return nil, false, errors.New(localize("%s() requires a %s argument, got %s", function, "string", jqDescribe(flags)))
+		}
+		for _, flag := range text {
+			switch flag {
+			case 'g':
+				global = true
+			case 'i', 's':
+				modifiers += string(flag)
+			case 'n':
+			default:
+				return nil, false, errors.New(localize("unsupported regex flag %q", string(flag)))
+			}
+		}
+	}
+
+	source = regexp.MustCompile(`\(\?<([A-Za-z_][A-Za-z0-9_]*)>`).ReplaceAllString(source, "(?P<$1>")
+	if modifiers != "" {
+		source = "(?" + modifiers + ")" + source
+	}
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, false, errors.New(localize("invalid pattern %q: %v", pattern, err))
+	}
+	return re, global, nil
+}
+
+// jqCaptures returns the named groups of a match as an object, null for groups that did not take part
+func jqCaptures(re *regexp.Regexp, text string, match []int) *jsonObject {
+	captures := &jsonObject{values: map[string]interface{}{}}
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if match[2*i] < 0 {
+			captures.set(name, nil)
+		} else {
+			captures.set(name, text[match[2*i]:match[2*i+1]])
+		}
+	}
+	return captures
+}
+
+// jqBuiltin implements a jq function; call holds the arguments, unevaluated
+type jqBuiltin func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error
+
+// jqBuiltins maps name/arity to the functions transformJSON supports
+var jqBuiltins map[string]jqBuiltin
+
+// jqFunc wraps a function of its input and of its argument values. Arguments are evaluated against
+// the input, with one call per combination of their outputs.
+func jqFunc(f func(input interface{}, args []interface{}) (interface{}, error)) jqBuiltin {
+	return func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+		return jqEvalArgs(call.args, input, env, make([]interface{}, len(call.args)), 0, func(args []interface{}) error {
+			value, err := f(input, args)
+			if err != nil {
+				return err
+			}
+			return emit(value)
+		})
+	}
+}
+
+// jqEvalArgs calls done for each combination of the outputs of the arguments
+func jqEvalArgs(args []*jqNode, input interface{}, env *jqEnv, values []interface{}, i int, done func([]interface{}) error) error {
+	if i == len(args) {
+		return done(values)
+	}
+	return args[i].eval(input, env, func(value interface{}) error {
+		values[i] = value
+		return jqEvalArgs(args, input, env, values, i+1, done)
+	})
+}
+
+// jqInputError reports a function applied to an input of the wrong type
+func jqInputError(function, expected string, input interface{}) error {
+	return errors.New(localize("%s() requires %s input, got %s", function, expected, jqDescribe(input)))
+}
+
+// jqArrayInput returns the input of a function that works on arrays
+func jqArrayInput(function string, input interface{}) ([]interface{}, error) {
+	array, ok := input.([]interface{})
+	if !ok {
+		return nil, jqInputError(function, "array", input)
+	}
+	return array, nil
+}
+
+// jqStringInput returns the input of a function that works on strings
+func jqStringInput(function string, input interface{}) (string, error) {
+	text, ok := input.(string)
+	if !ok {
+		return "", jqInputError(function, "string", input)
+	}
+	return text, nil
+}
+
+// jqStringArgument returns a string argument
+func jqStringArgument(function string, value interface{}) (string, error) {
+	text, ok := value.(string)
+	if !ok {
+		return "", errors.New(localize("%s() requires a %s argument, got %s", function, "string", jqDescribe(value)))
+	}
+	return text, nil
+}
+
+// jqNumberArgument returns a number argument
+func jqNumberArgument(function string, value interface{}) (float64, error) {
+	number, ok := value.(float64)
+	if !ok {
+		return 0, errors.New(localize("%s() requires a %s argument, got %s", function, "number", jqDescribe(value)))
+	}
+	return number, nil
+}
+
+// jqKeyed pairs each element of an array with the outputs of f for it, the key sort_by and
+// group_by compare, and sorts the pairs by key
+func jqKeyed(function string, f *jqNode, input interface{}, env *jqEnv) ([][2]interface{}, error) {
+	array, err := jqArrayInput(function, input)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([][2]interface{}, len(array))
+	for i, element := range array {
+		key, err := jqCollect(f, element, env)
+		if err != nil {
+			return nil, err
+		}
+		pairs[i] = [2]interface{}{key, element}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return jqCompare(pairs[i][0], pairs[j][0]) < 0
+	})
+	return pairs, nil
+}
+
+// jqMath wraps a function of a number
+func jqMath(name string, f func(float64) float64) jqBuiltin {
+	return jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+		number, ok := input.(float64)
+		if !ok {
+			return nil, jqInputError(name, "number", input)
+		}
+		return f(number), nil
+	})
+}
+
+// jqTypeFilter keeps the inputs of the given types, as arrays, strings and the like do
+func jqTypeFilter(types ...string) jqBuiltin {
+	return func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+		for _, name := range types {
+			if jqTypeName(input) == name {
+				return emit(input)
+			}
+		}
+		return nil
+	}
+}
+
+// jqMinMax returns the smallest or largest of the keyed elements, null for an empty array
+func jqMinMax(pairs [][2]interface{}, largest bool) interface{} {
+	if len(pairs) == 0 {
+		return nil
+	}
+	if largest {
+		return pairs[len(pairs)-1][1]
+	}
+	return pairs[0][1]
+}
+
+// jqSubstitute replaces the first match of a regular expression, or every match with the g flag or
+// for gsub. The replacement is evaluated with the named captures of each match as input.
+func jqSubstitute(global bool) jqBuiltin {
+	return func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+		text, err := jqStringInput(call.name, input)
+		if err != nil {
+			return err
+		}
+		patterns := []*jqNode{call.args[0]}
+		if len(call.args) > 2 {
+			patterns = append(patterns, call.args[2])
+		}
+		return jqEvalArgs(patterns, input, env, make([]interface{}, 2), 0, func(values []interface{}) error {
+			re, globalFlag, err := jqRegexp(call.name, values[0], values[1])
+			if err != nil {
+				return err
+			}
+			count := 1
+			if global || globalFlag {
+				count = -1
+			}
+
+			var b strings.Builder
+			last := 0
+			for _, match := range re.FindAllStringSubmatchIndex(text, count) {
+				replacement, _, err := jqFirst(call.args[1], jqCaptures(re, text, match), env)
+				if err != nil {
+					return err
+				}
+				replaced, err := jqStringArgument(call.name, replacement)
+				if err != nil {
+					return err
+				}
+				b.WriteString(text[last:match[0]])
+				b.WriteString(replaced)
+				last = match[1]
+			}
+			b.WriteString(text[last:])
+			return emit(b.String())
+		})
+	}
+}
+
+// jqDateFormat is the ISO 8601 form of todate and fromdate
+const jqDateFormat = "2006-01-02T15:04:05Z"
+
+func init() {
+	length := jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+		switch v := input.(type) {
+		case nil:
+			return 0.0, nil
+		case float64:
+			return math.Abs(v), nil
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case *jsonObject:
+			return float64(len(v.keys)), nil
+		}
+		return nil, jqInputError("length", "string, array, object, number or null", input)
+	})
+
+	keys := func(name string, sorted bool) jqBuiltin {
+		return jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			switch v := input.(type) {
+			case *jsonObject:
+				if sorted {
+					return jqSortedKeys(v), nil
+				}
+				keys := make([]interface{}, len(v.keys))
+				for i, key := range v.keys {
+					keys[i] = key
+				}
+				return keys, nil
+			case []interface{}:
+				keys := make([]interface{}, len(v))
+				for i := range v {
+					keys[i] = float64(i)
+				}
+				return keys, nil
+			}
+			return nil, jqInputError(name, "object or array", input)
+		})
+	}
+
+	has := func(input, key interface{}) (interface{}, error) {
+		switch v := input.(type) {
+		case *jsonObject:
+			if name, ok := key.(string); ok {
+				_, present := v.values[name]
+				return present, nil
+			}
+		case []interface{}:
+			if index, ok := key.(float64); ok {
+				return index >= 0 && index < float64(len(v)), nil
+			}
+		}
+		return nil, errors.New(localize("cannot check whether %s has the key %s", jqDescribe(input), jqDescribe(key)))
+	}
+
+	contains := func(a, b interface{}) (interface{}, error) {
+		if jqTypeName(a) != jqTypeName(b) {
+			return nil, errors.New(localize("%s and %s cannot have their containment checked", jqDescribe(a), jqDescribe(b)))
+		}
+		return jqContains(a, b), nil
+	}
+
+	toEntries := func(input interface{}) (interface{}, error) {
+		object, ok := input.(*jsonObject)
+		if !ok {
+			return nil, jqInputError("to_entries", "object", input)
+		}
+		entries := make([]interface{}, len(object.keys))
+		for i, key := range object.keys {
+			entry := &jsonObject{values: map[string]interface{}{}}
+			entry.set("key", key)
+			entry.set("value", object.values[key])
+			entries[i] = entry
+		}
+		return entries, nil
+	}
+
+	fromEntries := func(input interface{}) (interface{}, error) {
+		entries, err := jqArrayInput("from_entries", input)
+		if err != nil {
+			return nil, err
+		}
+		object := &jsonObject{values: map[string]interface{}{}}
+		for _, element := range entries {
+			entry, ok := element.(*jsonObject)
+			if !ok {
+				return nil, jqInputError("from_entries", "array of objects", input)
+			}
+			var key, value interface{}
+			for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+				if v, present := entry.values[name]; present && v != nil {
+					key = v
+					break
+				}
+			}
+			for _, name := range []string{"value", "v", "Value", "V"} {
+				if v, present := entry.values[name]; present {
+					value = v
+					break
+				}
+			}
+			name, isString := key.(string)
+			if !isString {
+				if !jqScalar(key) {
+					return nil, errors.New(localize("object keys must be strings, got %s", jqDescribe(key)))
+				}
+				name = jqCompact(key)
+			}
+			object.set(name, value)
+		}
+		return object, nil
+	}
+
+	reverse := jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+		switch v := input.(type) {
+		case nil:
+			return []interface{}{}, nil
+		case string:
+			runes := []rune(v)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return string(runes), nil
+		case []interface{}:
+			reversed := make([]interface{}, len(v))
+			for i, element := range v {
+				reversed[len(v)-1-i] = element
+			}
+			return reversed, nil
+		}
+		return nil, jqInputError("reverse", "array or string", input)
+	})
+
+	add := jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+		values, err := jqValues(input)
+		if err != nil {
+			return nil, jqInputError("add", "array or object", input)
+		}
+		var sum interface{}
+		for _, value := range values {
+			if sum, err = jqAdd(sum, value); err != nil {
+				return nil, err
+			}
+		}
+		return sum, nil
+	})
+
+	flatten := func(input interface{}, depth float64) (interface{}, error) {
+		array, err := jqArrayInput("flatten", input)
+		if err != nil {
+			return nil, err
+		}
+		if depth < 0 {
+			return nil, errors.New(localize("flatten depth must not be negative"))
+		}
+		var flat func(values []interface{}, depth float64) []interface{}
+		flat = func(values []interface{}, depth float64) []interface{} {
+			result := []interface{}{}
+			for _, value := range values {
+				if nested, ok := value.([]interface{}); ok && depth > 0 {
+					result = append(result, flat(nested, depth-1)...)
+				} else {
+					result = append(result, value)
+				}
+			}
+			return result
+		}
+		return flat(array, depth), nil
+	}
+
+	// quantifier implements any and all over the outputs of gen tested with cond
+	quantifier := func(all bool) func(gen, cond *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+		return func(gen, cond *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			stop := &jqBreak{}
+			decided := false
+			err := gen.eval(input, env, func(value interface{}) error {
+				check := func(result interface{}) error {
+					if jqTruthy(result) != all {
+						decided = true
+						return stop
+					}
+					return nil
+				}
+				if cond == nil {
+					return check(value)
+				}
+				return cond.eval(value, env, check)
+			})
+			if err != nil && err != stop {
+				return err
+			}
+			return emit(decided != all)
+		}
+	}
+	iterate := &jqNode{kind: jqIterate, left: &jqNode{kind: jqIdentity}}
+	anyOf, allOf := quantifier(false), quantifier(true)
+
+	limit := func(count, f *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+		return count.eval(input, env, func(value interface{}) error {
+			n, err := jqNumberArgument("limit", value)
+			if err != nil || n <= 0 {
+				return err
+			}
+			stop := &jqBreak{}
+			emitted := 0.0
+			err = f.eval(input, env, func(output interface{}) error {
+				if err := emit(output); err != nil {
+					return err
+				}
+				if emitted++; emitted >= n {
+					return stop
+				}
+				return nil
+			})
+			if err == stop {
+				return nil
+			}
+			return err
+		})
+	}
+
+	rangeFunc := func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+		return jqEvalArgs(call.args, input, env, make([]interface{}, len(call.args)), 0, func(args []interface{}) error {
+			bounds := []float64{0, 0, 1}
+			for i, arg := range args {
+				number, err := jqNumberArgument("range", arg)
+				if err != nil {
+					return err
+				}
+				bounds[i] = number
+			}
+			if len(args) == 1 {
+				bounds[0], bounds[1] = 0, bounds[0]
+			}
+			from, upto, by := bounds[0], bounds[1], bounds[2]
+			for x := from; (by > 0 && x < upto) || (by < 0 && x > upto); x += by {
+				if err := emit(x); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	jqBuiltins = map[string]jqBuiltin{
+		"empty/0": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return nil
+		},
+		"error/0": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return &jqError{value: input}
+		},
+		"error/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return nil, &jqError{value: args[0]}
+		}),
+		"not/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return !jqTruthy(input), nil
+		}),
+		"type/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqTypeName(input), nil
+		}),
+		"length/0": length,
+		"utf8bytelength/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("utf8bytelength", input)
+			return float64(len(text)), err
+		}),
+		"keys/0":          keys("keys", true),
+		"keys_unsorted/0": keys("keys_unsorted", false),
+		"has/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return has(input, args[0])
+		}),
+		"in/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return has(args[0], input)
+		}),
+		"contains/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return contains(input, args[0])
+		}),
+		"inside/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return contains(args[0], input)
+		}),
+		"select/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return call.args[0].eval(input, env, func(value interface{}) error {
+				if jqTruthy(value) {
+					return emit(input)
+				}
+				return nil
+			})
+		},
+		"values/0":    jqTypeFilter("boolean", "number", "string", "array", "object"),
+		"nulls/0":     jqTypeFilter("null"),
+		"booleans/0":  jqTypeFilter("boolean"),
+		"numbers/0":   jqTypeFilter("number"),
+		"strings/0":   jqTypeFilter("string"),
+		"arrays/0":    jqTypeFilter("array"),
+		"objects/0":   jqTypeFilter("object"),
+		"iterables/0": jqTypeFilter("array", "object"),
+		"scalars/0":   jqTypeFilter("null", "boolean", "number", "string"),
+		"map/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			values, err := jqValues(input)
+			if err != nil {
+				return err
+			}
+			mapped := []interface{}{}
+			for _, value := range values {
+				err := call.args[0].eval(value, env, func(result interface{}) error {
+					mapped = append(mapped, result)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return emit(mapped)
+		},
+		"map_values/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			switch v := input.(type) {
+			case *jsonObject:
+				mapped := &jsonObject{values: make(map[string]interface{}, len(v.keys))}
+				for _, key := range v.keys {
+					value, found, err := jqFirst(call.args[0], v.values[key], env)
+					if err != nil {
+						return err
+					}
+					if found {
+						mapped.set(key, value)
+					}
+				}
+				return emit(mapped)
+			case []interface{}:
+				mapped := []interface{}{}
+				for _, element := range v {
+					value, found, err := jqFirst(call.args[0], element, env)
+					if err != nil {
+						return err
+					}
+					if found {
+						mapped = append(mapped, value)
+					}
+				}
+				return emit(mapped)
+			}
+			return errors.New(localize("cannot iterate over %s", jqDescribe(input)))
+		},
+		"recurse/0": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return jqRecurse(input, emit)
+		},
+		"recurse/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return jqRecurseWith(call.args[0], input, env, 0, emit)
+		},
+		"walk/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return jqWalk(call.args[0], input, env, emit)
+		},
+		"to_entries/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return toEntries(input)
+		}),
+		"from_entries/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return fromEntries(input)
+		}),
+		"with_entries/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			entries, err := toEntries(input)
+			if err != nil {
+				return jqInputError("with_entries", "object", input)
+			}
+			mapped := []interface{}{}
+			for _, entry := range entries.([]interface{}) {
+				err := call.args[0].eval(entry, env, func(result interface{}) error {
+					mapped = append(mapped, result)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			object, err := fromEntries(mapped)
+			if err != nil {
+				return err
+			}
+			return emit(object)
+		},
+		"add/0": add,
+		"any/0": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return anyOf(iterate, nil, input, env, emit)
+		},
+		"all/0": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return allOf(iterate, nil, input, env, emit)
+		},
+		"any/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return anyOf(iterate, call.args[0], input, env, emit)
+		},
+		"all/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return allOf(iterate, call.args[0], input, env, emit)
+		},
+		"any/2": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return anyOf(call.args[0], call.args[1], input, env, emit)
+		},
+		"all/2": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return allOf(call.args[0], call.args[1], input, env, emit)
+		},
+		"flatten/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return flatten(input, math.Inf(1))
+		}),
+		"flatten/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			depth, err := jqNumberArgument("flatten", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return flatten(input, depth)
+		}),
+		"range/1": rangeFunc,
+		"range/2": rangeFunc,
+		"range/3": rangeFunc,
+		"sort/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			array, err := jqArrayInput("sort", input)
+			if err != nil {
+				return nil, err
+			}
+			sorted := append([]interface{}{}, array...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return jqCompare(sorted[i], sorted[j]) < 0
+			})
+			return sorted, nil
+		}),
+		"sort_by/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			pairs, err := jqKeyed("sort_by", call.args[0], input, env)
+			if err != nil {
+				return err
+			}
+			sorted := make([]interface{}, len(pairs))
+			for i, pair := range pairs {
+				sorted[i] = pair[1]
+			}
+			return emit(sorted)
+		},
+		"group_by/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			pairs, err := jqKeyed("group_by", call.args[0], input, env)
+			if err != nil {
+				return err
+			}
+			groups := []interface{}{}
+			for i, pair := range pairs {
+				if i == 0 || jqCompare(pairs[i-1][0], pair[0]) != 0 {
+					groups = append(groups, []interface{}{})
+				}
+				groups[len(groups)-1] = append(groups[len(groups)-1].([]interface{}), pair[1])
+			}
+			return emit(groups)
+		},
+		"unique/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			array, err := jqArrayInput("unique", input)
+			if err != nil {
+				return nil, err
+			}
+			sorted := append([]interface{}{}, array...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return jqCompare(sorted[i], sorted[j]) < 0
+			})
+			unique := []interface{}{}
+			for i, value := range sorted {
+				if i == 0 || jqCompare(sorted[i-1], value) != 0 {
+					unique = append(unique, value)
+				}
+			}
+			return unique, nil
+		}),
+		"unique_by/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			pairs, err := jqKeyed("unique_by", call.args[0], input, env)
+			if err != nil {
+				return err
+			}
+			unique := []interface{}{}
+			for i, pair := range pairs {
+				if i == 0 || jqCompare(pairs[i-1][0], pair[0]) != 0 {
+					unique = append(unique, pair[1])
+				}
+			}
+			return emit(unique)
+		},
+		"min/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			pairs, err := jqKeyed("min", &jqNode{kind: jqIdentity}, input, nil)
+			return jqMinMax(pairs, false), err
+		}),
+		"max/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			pairs, err := jqKeyed("max", &jqNode{kind: jqIdentity}, input, nil)
+			return jqMinMax(pairs, true), err
+		}),
+		"min_by/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			pairs, err := jqKeyed("min_by", call.args[0], input, env)
+			if err != nil {
+				return err
+			}
+			return emit(jqMinMax(pairs, false))
+		},
+		"max_by/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			pairs, err := jqKeyed("max_by", call.args[0], input, env)
+			if err != nil {
+				return err
+			}
+			return emit(jqMinMax(pairs, true))
+		},
+		"reverse/0": reverse,
+		"first/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqIndexValue(input, 0.0)
+		}),
+		"last/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqIndexValue(input, -1.0)
+		}),
+		"nth/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqIndexValue(input, args[0])
+		}),
+		"first/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			value, found, err := jqFirst(call.args[0], input, env)
+			if err != nil || !found {
+				return err
+			}
+			return emit(value)
+		},
+		"last/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			var last interface{}
+			found := false
+			err := call.args[0].eval(input, env, func(value interface{}) error {
+				last, found = value, true
+				return nil
+			})
+			if err != nil || !found {
+				return err
+			}
+			return emit(last)
+		},
+		"nth/2": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return call.args[0].eval(input, env, func(value interface{}) error {
+				n, err := jqNumberArgument("nth", value)
+				if err != nil {
+					return err
+				}
+				if n < 0 {
+					return errors.New(localize("array index %d out of range", int(n)))
+				}
+				var nth interface{}
+				found := false
+				seen := 0.0
+				stop := &jqBreak{}
+				err = call.args[1].eval(input, env, func(output interface{}) error {
+					if seen++; seen > n {
+						nth, found = output, true
+						return stop
+					}
+					return nil
+				})
+				if err != nil && err != stop {
+					return err
+				}
+				if !found {
+					return nil
+				}
+				return emit(nth)
+			})
+		},
+		"limit/2": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			return limit(call.args[0], call.args[1], input, env, emit)
+		},
+		"isempty/1": func(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+			_, found, err := jqFirst(call.args[0], input, env)
+			if err != nil {
+				return err
+			}
+			return emit(!found)
+		},
+		"tostring/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqFormatValue("", input)
+		}),
+		"tonumber/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			switch v := input.(type) {
+			case float64:
+				return v, nil
+			case string:
+				if number, err := strconv.ParseFloat(v, 64); err == nil {
+					return number, nil
+				}
+				return nil, errors.New(localize("%s cannot be parsed as a number", jqDescribe(input)))
+			}
+			return nil, jqInputError("tonumber", "number or string", input)
+		}),
+		"tojson/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqCompact(input), nil
+		}),
+		"fromjson/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("fromjson", input)
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedJSON([]byte(text))
+			if err != nil {
+				return nil, errors.New(localize("Invalid JSON: %v", err))
+			}
+			return value, nil
+		}),
+		"toarray/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			if array, ok := input.([]interface{}); ok {
+				return array, nil
+			}
+			return []interface{}{input}, nil
+		}),
+		"ascii_downcase/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("ascii_downcase", input)
+			return strings.Map(func(r rune) rune {
+				if r >= 'A' && r <= 'Z' {
+					return r + 'a' - 'A'
+				}
+				return r
+			}, text), err
+		}),
+		"ascii_upcase/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("ascii_upcase", input)
+			return strings.Map(func(r rune) rune {
+				if r >= 'a' && r <= 'z' {
+					return r - 'a' + 'A'
+				}
+				return r
+			}, text), err
+		}),
+		"trim/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("trim", input)
+			return strings.TrimSpace(text), err
+		}),
+		"ltrim/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("ltrim", input)
+			return strings.TrimLeftFunc(text, unicode.IsSpace), err
+		}),
+		"rtrim/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("rtrim", input)
+			return strings.TrimRightFunc(text, unicode.IsSpace), err
+		}),
+		"ltrimstr/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, ok := input.(string)
+			prefix, isString := args[0].(string)
+			if ok && isString {
+				return strings.TrimPrefix(text, prefix), nil
+			}
+			return input, nil
+		}),
+		"rtrimstr/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, ok := input.(string)
+			suffix, isString := args[0].(string)
+			if ok && isString {
+				return strings.TrimSuffix(text, suffix), nil
+			}
+			return input, nil
+		}),
+		"startswith/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("startswith", input)
+			if err != nil {
+				return nil, err
+			}
+			prefix, err := jqStringArgument("startswith", args[0])
+			return strings.HasPrefix(text, prefix), err
+		}),
+		"endswith/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("endswith", input)
+			if err != nil {
+				return nil, err
+			}
+			suffix, err := jqStringArgument("endswith", args[0])
+			return strings.HasSuffix(text, suffix), err
+		}),
+		"split/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("split", input)
+			if err != nil {
+				return nil, err
+			}
+			separator, err := jqStringArgument("split", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return jqSplit(text, separator), nil
+		}),
+		"join/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			values, err := jqValues(input)
+			if err != nil {
+				return nil, jqInputError("join", "array", input)
+			}
+			separator, err := jqStringArgument("join", args[0])
+			if err != nil {
+				return nil, err
+			}
+			parts := make([]string, len(values))
+			for i, value := range values {
+				switch v := value.(type) {
+				case nil:
+				case string:
+					parts[i] = v
+				case float64, bool:
+					parts[i] = jqCompact(v)
+				default:
+					return nil, errors.New(localize("%s cannot be joined", jqDescribe(value)))
+				}
+			}
+			return strings.Join(parts, separator), nil
+		}),
+		"explode/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			text, err := jqStringInput("explode", input)
+			codepoints := []interface{}{}
+			for _, r := range text {
+				codepoints = append(codepoints, float64(r))
+			}
+			return codepoints, err
+		}),
+		"implode/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			array, err := jqArrayInput("implode", input)
+			if err != nil {
+				return nil, err
+			}
+			var b strings.Builder
+			for _, value := range array {
+				codepoint, ok := value.(float64)
+				if !ok {
+					return nil, jqInputError("implode", "array of codepoints", input)
+				}
+				b.WriteRune(rune(codepoint))
+			}
+			return b.String(), nil
+		}),
+		"test/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqTest(input, args[0], nil)
+		}),
+		"test/2": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqTest(input, args[0], args[1])
+		}),
+		"capture/1": jqCapture,
+		"capture/2": jqCapture,
+		"sub/2":     jqSubstitute(false),
+		"sub/3":     jqSubstitute(false),
+		"gsub/2":    jqSubstitute(true),
+		"gsub/3":    jqSubstitute(true),
+		"todate/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqToDate(input)
+		}),
+		"todateiso8601/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqToDate(input)
+		}),
+		"fromdate/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqFromDate(input)
+		}),
+		"fromdateiso8601/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqFromDate(input)
+		}),
+		"getpath/1": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			path, ok := args[0].([]interface{})
+			if !ok {
+				return nil, errors.New(localize("%s() requires a %s argument, got %s", "getpath", "array", jqDescribe(args[0])))
+			}
+			value := input
+			for _, key := range path {
+				if value == nil {
+					return nil, nil
+				}
+				var err error
+				if value, err = jqIndexValue(value, key); err != nil {
+					return nil, err
+				}
+			}
+			return value, nil
+		}),
+		"floor/0": jqMath("floor", math.Floor),
+		"ceil/0":  jqMath("ceil", math.Ceil),
+		"round/0": jqMath("round", math.Round),
+		"trunc/0": jqMath("trunc", math.Trunc),
+		"fabs/0":  jqMath("fabs", math.Abs),
+		"abs/0":   jqMath("abs", math.Abs),
+		"sqrt/0":  jqMath("sqrt", math.Sqrt),
+		"exp/0":   jqMath("exp", math.Exp),
+		"exp2/0":  jqMath("exp2", math.Exp2),
+		"exp10/0": jqMath("exp10", func(x float64) float64 { return math.Pow(10, x) }),
+		"log/0":   jqMath("log", math.Log),
+		"log2/0":  jqMath("log2", math.Log2),
+		"log10/0": jqMath("log10", math.Log10),
+		"isnan/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqIsNumber("isnan", input, math.IsNaN)
+		}),
+		"infinite/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return math.Inf(1), nil
+		}),
+		"nan/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return math.NaN(), nil
+		}),
+		"isinfinite/0": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			return jqIsNumber("isinfinite", input, func(x float64) bool { return math.IsInf(x, 0) })
+		}),
+		"pow/2": jqFunc(func(input interface{}, args []interface{}) (interface{}, error) {
+			base, err := jqNumberArgument("pow", args[0])
+			if err != nil {
+				return nil, err
+			}
+			exponent, err := jqNumberArgument("pow", args[1])
+			return math.Pow(base, exponent), err
+		}),
+	}
+}
+
+// jqIsNumber applies a test to a number input
+func jqIsNumber(function string, input interface{}, test func(float64) bool) (interface{}, error) {
+	number, ok := input.(float64)
+	if !ok {
+		return nil, jqInputError(function, "number", input)
+	}
+	return test(number), nil
+}
+
+// jqTest reports whether a string matches a regular expression
+func jqTest(input, pattern, flags interface{}) (interface{}, error) {
+	text, err := jqStringInput("test", input)
+	if err != nil {
+		return nil, err
+	}
+	re, _, err := jqRegexp("test", pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(text), nil
+}
+
+// jqCapture emits the named groups of the first match as an object, or of every match with the g
+// flag; nothing is emitted without a match
+func jqCapture(call *jqNode, input interface{}, env *jqEnv, emit jqEmit) error {
+	text, err := jqStringInput("capture", input)
+	if err != nil {
+		return err
+	}
+	return jqEvalArgs(call.args, input, env, make([]interface{}, 2), 0, func(args []interface{}) error {
+		re, global, err := jqRegexp("capture", args[0], args[1])
+		if err != nil {
+			return err
+		}
+		count := 1
+		if global {
+			count = -1
+		}
+		for _, match := range re.FindAllStringSubmatchIndex(text, count) {
+			if err := emit(jqCaptures(re, text, match)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// jqToDate formats seconds since the Unix epoch as an ISO 8601 UTC date
+func jqToDate(input interface{}) (interface{}, error) {
+	seconds, ok := input.(float64)
+	if !ok {
+		return nil, jqInputError("todate", "number", input)
+	}
+	return time.Unix(int64(math.Floor(seconds)), 0).UTC().Format(jqDateFormat), nil
+}
+
+// jqFromDate parses an ISO 8601 UTC date into seconds since the Unix epoch
+func jqFromDate(input interface{}) (interface{}, error) {
+	text, err := jqStringInput("fromdate", input)
+	if err != nil {
+		return nil, err
+	}
+	date, err := time.Parse(jqDateFormat, text)
+	if err != nil {
+		return nil, errors.New(localize("%s does not match the date format %s", jqDescribe(input), "YYYY-MM-DDTHH:MM:SSZ"))
+	}
+	return float64(date.Unix()), nil
+}
+
+// jqParser reads a jq expression
+type jqParser struct {
+	src string
+	pos int
+}
+
+// jqKeywords cannot be used as function names
+var jqKeywords = map[string]bool{
+	"if": true, "then": true, "elif": true, "else": true, "end": true, "as": true, "reduce": true, "foreach": true,
+	"try": true, "catch": true, "label": true, "import": true, "include": true, "def": true, "and": true, "or": true,
+}
+
+// parseJQ parses a jq expression; an empty expression is the identity
+func parseJQ(expression string) (*jqNode, error) {
+	p := &jqParser{src: expression}
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return &jqNode{kind: jqIdentity}, nil
+	}
+	node, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf(localize("unexpected %q"), p.token())
+	}
+	return node, nil
+}
+
+// errorf reports a syntax error at the current position
+func (p *jqParser) errorf(format string, args ...interface{}) error {
+	return errors.New(localize("Invalid expression at position %d: %s", p.pos, fmt.Sprintf(format, args...)))
+}
+
+// skipSpace skips blanks and # comments
+func (p *jqParser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token returns the token at the current position, for error messages
+func (p *jqParser) token() string {
+	end := p.pos
+	for end < len(p.src) && jqIdentifierByte(p.src[end], end > p.pos) {
+		end++
+	}
+	if end == p.pos && end < len(p.src) {
+		_, size := utf8.DecodeRuneInString(p.src[end:])
+		end += size
+	}
+	return p.src[p.pos:end]
+}
+
+// jqLongerOperators are the operators that start with a shorter one
+var jqLongerOperators = []string{"//=", "//", "|=", "+=", "-=", "*=", "/=", "%=", "==", "!=", "<=", ">=", "?//"}
+
+// operator skips blanks and the operator op if it comes next, and is not the start of a longer one
+func (p *jqParser) operator(op string) bool {
+	p.skipSpace()
+	rest := p.src[p.pos:]
+	if !strings.HasPrefix(rest, op) {
+		return false
+	}
+	for _, longer := range jqLongerOperators {
+		if len(longer) > len(op) && strings.HasPrefix(longer, op) && strings.HasPrefix(rest, longer) {
+			return false
+		}
+	}
+	p.pos += len(op)
+	return true
+}
+
+// expect consumes the operator op or reports that it is missing
+func (p *jqParser) expect(op string) error {
+	if !p.operator(op) {
+		return p.errorf(localize("%q expected"), op)
+	}
+	return nil
+}
+
+// keyword consumes the keyword word if it comes next
+func (p *jqParser) keyword(word string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.src[p.pos:], word) {
+		return false
+	}
+	if end := p.pos + len(word); end < len(p.src) && jqIdentifierByte(p.src[end], true) {
+		return false
+	}
+	p.pos += len(word)
+	return true
+}
+
+// jqIdentifierByte reports whether c can appear in a name, digits only after the first byte
+func jqIdentifierByte(c byte, inside bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || inside && c >= '0' && c <= '9'
+}
+
+// identifier reads a name, or returns "" when none comes next
+func (p *jqParser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.src) && jqIdentifierByte(p.src[p.pos], p.pos > start) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// assignment reports an update-assignment operator, which the subset does not support
+func (p *jqParser) assignment() bool {
+	p.skipSpace()
+	rest := p.src[p.pos:]
+	for _, op := range []string{"|=", "+=", "-=", "*=", "/=", "%=", "//="} {
+		if strings.HasPrefix(rest, op) {
+			return true
+		}
+	}
+	return strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "==")
+}
+
+// pipe parses a | b, the loosest binding operator
+func (p *jqParser) pipe() (*jqNode, error) {
+	left, err := p.comma()
+	if err != nil {
+		return nil, err
+	}
+	if p.assignment() {
+		return nil, p.errorf(localize("assignment operators are not supported"))
+	}
+	if !p.operator("|") {
+		return left, nil
+	}
+	right, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &jqNode{kind: jqPipe, left: left, right: right}, nil
+}
+
+// comma parses a, b
+func (p *jqParser) comma() (*jqNode, error) {
+	left, err := p.alternative()
+	for err == nil && p.operator(",") {
+		var right *jqNode
+		if right, err = p.alternative(); err == nil {
+			left = &jqNode{kind: jqComma, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// alternative parses a // b, which is right associative
+func (p *jqParser) alternative() (*jqNode, error) {
+	left, err := p.or()
+	if err != nil || !p.operator("//") {
+		return left, err
+	}
+	right, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	return &jqNode{kind: jqAlternative, left: left, right: right}, nil
+}
+
+func (p *jqParser) or() (*jqNode, error) {
+	left, err := p.and()
+	for err == nil && p.keyword("or") {
+		var right *jqNode
+		if right, err = p.and(); err == nil {
+			left = &jqNode{kind: jqOr, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *jqParser) and() (*jqNode, error) {
+	left, err := p.comparison()
+	for err == nil && p.keyword("and") {
+		var right *jqNode
+		if right, err = p.comparison(); err == nil {
+			left = &jqNode{kind: jqAnd, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// comparison parses ==, !=, <, <=, > and >=, which do not chain
+func (p *jqParser) comparison() (*jqNode, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.operator(op) {
+			right, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			return &jqNode{kind: jqBinary, op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jqParser) additive() (*jqNode, error) {
+	left, err := p.multiplicative()
+	for err == nil {
+		op := ""
+		switch {
+		case p.operator("+"):
+			op = "+"
+		case p.operator("-"):
+			op = "-"
+		default:
+			return left, nil
+		}
+		var right *jqNode
+		if right, err = p.multiplicative(); err == nil {
+			left = &jqNode{kind: jqBinary, op: op, left: left, right: right}
+		}
+	}
+	return nil, err
+}
+
+func (p *jqParser) multiplicative() (*jqNode, error) {
+	left, err := p.unary()
+	for err == nil {
+		op := ""
+		switch {
+		case p.operator("*"):
+			op = "*"
+		case p.operator("/"):
+			op = "/"
+		case p.operator("%"):
+			op = "%"
+		default:
+			return left, nil
+		}
+		var right *jqNode
+		if right, err = p.unary(); err == nil {
+			left = &jqNode{kind: jqBinary, op: op, left: left, right: right}
+		}
+	}
+	return nil, err
+}
+
+func (p *jqParser) unary() (*jqNode, error) {
+	if p.operator("-") {
+		operand, err := p.postfix(true)
+		if err != nil {
+			return nil, err
+		}
+		return &jqNode{kind: jqNegate, left: operand}, nil
+	}
+	return p.postfix(true)
+}
+
+// postfix parses a term followed by .name, [index], [from:to], [] and ? suffixes. With bind, a
+// following "as $name | body" binds the term's outputs to $name in body.
+func (p *jqParser) postfix(bind bool) (*jqNode, error) {
+	term, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		c := p.src[p.pos]
+		if c == '.' && p.pos+1 < len(p.src) && (p.src[p.pos+1] == '"' || p.src[p.pos+1] == '[' || jqIdentifierByte(p.src[p.pos+1], false)) {
+			p.pos++
+			if term, err = p.member(term); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if c == '[' {
+			if term, err = p.brackets(term); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.operator("?") {
+			term = &jqNode{kind: jqTry, left: term}
+			continue
+		}
+		break
+	}
+
+	if !bind || !p.keyword("as") {
+		return term, nil
+	}
+	name, err := p.variable()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("|"); err != nil {
+		return nil, err
+	}
+	body, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &jqNode{kind: jqBind, name: name, left: term, right: body}, nil
+}
+
+// member parses what follows a dot: a name, a string or brackets
+func (p *jqParser) member(target *jqNode) (*jqNode, error) {
+	switch p.src[p.pos] {
+	case '"':
+		key, err := p.stringLiteral("")
+		if err != nil {
+			return nil, err
+		}
+		return &jqNode{kind: jqIndex, left: target, right: key}, nil
+	case '[':
+		return p.brackets(target)
+	}
+	return &jqNode{kind: jqField, left: target, name: p.identifier()}, nil
+}
+
+// brackets parses [], [index] and [from:to] after target
+func (p *jqParser) brackets(target *jqNode) (*jqNode, error) {
+	p.pos++
+	if p.operator("]") {
+		return &jqNode{kind: jqIterate, left: target}, nil
+	}
+
+	var from *jqNode
+	if !p.operator(":") {
+		var err error
+		if from, err = p.pipe(); err != nil {
+			return nil, err
+		}
+		if !p.operator(":") {
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			return &jqNode{kind: jqIndex, left: target, right: from}, nil
+		}
+	}
+
+	var to *jqNode
+	if !p.operator("]") {
+		var err error
+		if to, err = p.pipe(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	return &jqNode{kind: jqSlice, left: target, right: from, third: to}, nil
+}
+
+// variable reads $name
+func (p *jqParser) variable() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '$' {
+		p.pos++
+		if name := p.identifier(); name != "" {
+			return name, nil
+		}
+	}
+	return "", p.errorf(localize("variable name expected"))
+}
+
+// term parses a value: ., .., .name, a literal, a string, a format, a variable, a parenthesized
+// expression, an array or object construction, if, try, reduce or a function call
+func (p *jqParser) term() (*jqNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf(localize("expression expected"))
+	}
+	identity := &jqNode{kind: jqIdentity}
+
+	switch c := p.src[p.pos]; {
+	case strings.HasPrefix(p.src[p.pos:], ".."):
+		p.pos += 2
+		return &jqNode{kind: jqRecurseAll}, nil
+
+	case c == '.':
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '"' || jqIdentifierByte(p.src[p.pos], false)) {
+			return p.member(identity)
+		}
+		return identity, nil
+
+	case c == '$':
+		name, err := p.variable()
+		if err != nil {
+			return nil, err
+		}
+		return &jqNode{kind: jqVariable, name: name}, nil
+
+	case c == '"':
+		return p.stringLiteral("")
+
+	case c == '@':
+		p.pos++
+		format := p.identifier()
+		if !jqFormats[format] {
+			return nil, p.errorf(localize("unknown format @%s"), format)
+		}
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '"' {
+			return p.stringLiteral(format)
+		}
+		return &jqNode{kind: jqFormat, op: format}, nil
+
+	case c >= '0' && c <= '9':
+		return p.number()
+
+	case c == '(':
+		p.pos++
+		node, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+
+	case c == '[':
+		p.pos++
+		if p.operator("]") {
+			return &jqNode{kind: jqArray}, nil
+		}
+		node, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		return &jqNode{kind: jqArray, left: node}, p.expect("]")
+
+	case c == '{':
+		p.pos++
+		return p.object()
+
+	case jqIdentifierByte(c, false):
+		start := p.pos
+		word := p.identifier()
+		switch word {
+		case "null":
+			return &jqNode{kind: jqLiteral}, nil
+		case "true", "false":
+			return &jqNode{kind: jqLiteral, value: word == "true"}, nil
+		case "if":
+			return p.conditional()
+		case "try":
+			body, err := p.postfix(false)
+			if err != nil {
+				return nil, err
+			}
+			node := &jqNode{kind: jqTry, left: body}
+			if p.keyword("catch") {
+				if node.right, err = p.postfix(false); err != nil {
+					return nil, err
+				}
+			}
+			return node, nil
+		case "reduce":
+			return p.reduce()
+		case "def", "foreach", "label", "import", "include":
+			p.pos = start
+			return nil, p.errorf(localize("%s is not supported"), word)
+		}
+		if jqKeywords[word] {
+			p.pos = start
+			return nil, p.errorf(localize("unexpected %q"), word)
+		}
+		return p.call(word, start)
+	}
+	return nil, p.errorf(localize("unexpected %q"), p.token())
+}
+
+// call parses the arguments of a function, separated by semicolons
+func (p *jqParser) call(name string, start int) (*jqNode, error) {
+	node := &jqNode{kind: jqCall, name: name}
+	if p.operator("(") {
+		for {
+			arg, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			node.args = append(node.args, arg)
+			if !p.operator(";") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := jqBuiltins[name+"/"+strconv.Itoa(len(node.args))]; !ok {
+		p.pos = start
+		return nil, p.errorf(localize("unknown function %s/%d"), name, len(node.args))
+	}
+	return node, nil
+}
+
+// conditional parses the rest of if cond then a (elif cond then b)* (else c)? end
+func (p *jqParser) conditional() (*jqNode, error) {
+	condition, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if !p.keyword("then") {
+		return nil, p.errorf(localize("%q expected"), "then")
+	}
+	branch, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	node := &jqNode{kind: jqIf, left: condition, right: branch}
+
+	switch {
+	case p.keyword("elif"):
+		node.third, err = p.conditional()
+		return node, err
+	case p.keyword("else"):
+		if node.third, err = p.pipe(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.keyword("end") {
+		return nil, p.errorf(localize("%q expected"), "end")
+	}
+	return node, nil
+}
+
+// reduce parses the rest of reduce source as $name (initial; update)
+func (p *jqParser) reduce() (*jqNode, error) {
+	source, err := p.postfix(false)
+	if err != nil {
+		return nil, err
+	}
+	if !p.keyword("as") {
+		return nil, p.errorf(localize("%q expected"), "as")
+	}
+	name, err := p.variable()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	initial, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	update, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &jqNode{kind: jqReduce, name: name, left: source, right: initial, third: update}, nil
+}
+
+// object parses the members of {...}: name, "string", $variable and (expression) keys, each with a
+// value after a colon, or the shorthands {name} for {name: .name} and {$x} for {x: $x}
+func (p *jqParser) object() (*jqNode, error) {
+	node := &jqNode{kind: jqObject}
+	if p.operator("}") {
+		return node, nil
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf(localize("%q expected"), "}")
+		}
+		var entry jqEntry
+		switch c := p.src[p.pos]; {
+		case c == '$':
+			name, err := p.variable()
+			if err != nil {
+				return nil, err
+			}
+			entry = jqEntry{key: &jqNode{kind: jqLiteral, value: name}, value: &jqNode{kind: jqVariable, name: name}}
+
+		case c == '"':
+			key, err := p.stringLiteral("")
+			if err != nil {
+				return nil, err
+			}
+			entry = jqEntry{key: key, value: &jqNode{kind: jqIndex, left: &jqNode{kind: jqIdentity}, right: key}}
+
+		case c == '(':
+			p.pos++
+			key, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			entry = jqEntry{key: key}
+
+		case jqIdentifierByte(c, false):
+			name := p.identifier()
+			entry = jqEntry{key: &jqNode{kind: jqLiteral, value: name}, value: &jqNode{kind: jqField, left: &jqNode{kind: jqIdentity}, name: name}}
+
+		default:
+			return nil, p.errorf(localize("unexpected %q"), p.token())
+		}
+
+		if p.operator(":") {
+			value, err := p.objectValue()
+			if err != nil {
+				return nil, err
+			}
+			entry.value = value
+		} else if entry.value == nil {
+			return nil, p.errorf(localize("%q expected"), ":")
+		}
+		node.entries = append(node.entries, entry)
+
+		if !p.operator(",") {
+			break
+		}
+	}
+	return node, p.expect("}")
+}
+
+// objectValue parses a member value, which may pipe but not use commas
+func (p *jqParser) objectValue() (*jqNode, error) {
+	left, err := p.alternative()
+	for err == nil && p.operator("|") {
+		var right *jqNode
+		if right, err = p.alternative(); err == nil {
+			left = &jqNode{kind: jqPipe, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// number parses a number literal
+func (p *jqParser) number() (*jqNode, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	number, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil {
+		return nil, p.errorf(localize("invalid number %q"), p.src[start:p.pos])
+	}
+	return &jqNode{kind: jqLiteral, value: number}, nil
+}
+
+// stringLiteral parses a double quoted string with JSON escapes and \(expression) interpolations.
+// Interpolated values go through tostring, or through the format of @format "string".
+func (p *jqParser) stringLiteral(format string) (*jqNode, error) {
+	p.pos++
+	node := &jqNode{kind: jqString, op: format}
+	var text strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			if text.Len() > 0 || len(node.parts) == 0 {
+				node.parts = append(node.parts, jqPart{text: text.String()})
+			}
+			if len(node.parts) == 1 && node.parts[0].expr == nil {
+				return &jqNode{kind: jqLiteral, value: node.parts[0].text}, nil
+			}
+			return node, nil
+
+		case '\\':
+			if p.pos >= len(p.src) {
+				return nil, p.errorf(localize("unterminated string"))
+			}
+			escaped := p.src[p.pos]
+			p.pos++
+			switch escaped {
+			case '(':
+				if text.Len() > 0 {
+					node.parts = append(node.parts, jqPart{text: text.String()})
+					text.Reset()
+				}
+				expr, err := p.pipe()
+				if err != nil {
+					return nil, err
+				}
+				p.skipSpace()
+				if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+					return nil, p.errorf(localize("%q expected"), ")")
+				}
+				p.pos++
+				node.parts = append(node.parts, jqPart{expr: expr})
+			case '"', '\\', '/':
+				text.WriteByte(escaped)
+			case 'n':
+				text.WriteByte('\n')
+			case 't':
+				text.WriteByte('\t')
+			case 'r':
+				text.WriteByte('\r')
+			case 'b':
+				text.WriteByte('\b')
+			case 'f':
+				text.WriteByte('\f')
+			case 'u':
+				r, ok := p.unicodeEscape()
+				if !ok {
+					return nil, p.errorf(localize("invalid escape"))
+				}
+				text.WriteRune(r)
+			default:
+				return nil, p.errorf(localize("invalid escape"))
+			}
+
+		default:
+			text.WriteByte(c)
+		}
+	}
+	return nil, p.errorf(localize("unterminated string"))
+}
+
+// unicodeEscape reads the hex digits of \uXXXX, joining a surrogate pair written as two escapes
+func (p *jqParser) unicodeEscape() (rune, bool) {
+	read := func() (rune, bool) {
+		if p.pos+4 > len(p.src) {
+			return 0, false
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+		if err != nil {
+			return 0, false
+		}
+		p.pos += 4
+		return rune(code), true
+	}
+	r, ok := read()
+	if !ok || !utf16.IsSurrogate(r) {
+		return r, ok
+	}
+	if !strings.HasPrefix(p.src[p.pos:], `\u`) {
+		return unicode.ReplacementChar, true
+	}
+	p.pos += 2
+	low, ok := read()
+	return utf16.DecodeRune(r, low), ok
+}
+
+// JSON Schema (draft 2020-12) validation for validateJSONSchema
+
+// schemaDefaultBase is the base URI of a root schema without $id, against which references resolve
+const schemaDefaultBase = "https://jsonxml.local/schema.json"
+
+// maxSchemaDepth bounds nested $ref evaluation, so a schema referencing itself without consuming the
+// instance reports an error instead of overflowing the stack
+const maxSchemaDepth = 200
+
+// schemaError is one validation failure: where in the instance, which keyword at which schema location
+type schemaError struct {
+	instancePath    string
+	keywordLocation string
+	keyword         string
+	message         string
+}
+
+// schemaResult is the outcome of validating an instance against a schema: its errors, and the object
+// members and array items the schema evaluated, which unevaluatedProperties and unevaluatedItems need
+type schemaResult struct {
+	errors     []schemaError
+	properties map[string]bool
+	items      map[int]bool
+}
+
+// annotate records the annotations of a subschema that validated the same instance
+func (r *schemaResult) annotate(other schemaResult) {
+	for name := range other.properties {
+		if r.properties == nil {
+			r.properties = map[string]bool{}
+		}
+		r.properties[name] = true
+	}
+	for index := range other.items {
+		if r.items == nil {
+			r.items = map[int]bool{}
+		}
+		r.items[index] = true
+	}
+}
+
+// fail records an error of the keyword at the schema location
+func (r *schemaResult) fail(instancePath, schemaPath, keyword, message string) {
+	r.errors = append(r.errors, schemaError{
+		instancePath:    instancePath,
+		keywordLocation: schemaPath + "/" + keyword,
+		keyword:         keyword,
+		message:         message,
+	})
+}
+
+// schemaValidator validates instances against a root schema and the resources it embeds
+type schemaValidator struct {
+	resources map[string]interface{}
+	formats   bool
+	patterns  map[string]*regexp.Regexp
+	depth     int
+}
+
+// newSchemaValidator indexes the root schema and every embedded $id, $anchor and $dynamicAnchor
+func newSchemaValidator(root interface{}, formats bool) *schemaValidator {
+	v := &schemaValidator{resources: map[string]interface{}{}, formats: formats, patterns: map[string]*regexp.Regexp{}}
+	v.resources[schemaDefaultBase] = root
+	v.index(root, schemaDefaultBase)
+	return v
+}
+
+// index registers the resources under schema, whose base URI is base
+func (v *schemaValidator) index(schema interface{}, base string) {
+	switch s := schema.(type) {
+	case map[string]interface{}:
+		if id, ok := s["$id"].(string); ok {
+			base = resolveURI(base, id)
+			v.resources[strings.TrimSuffix(base, "#")] = s
+		}
+		for _, keyword := range []string{"$anchor", "$dynamicAnchor"} {
+			if anchor, ok := s[keyword].(string); ok {
+				v.resources[strings.SplitN(base, "#", 2)[0]+"#"+anchor] = s
+			}
+		}
+		for keyword, value := range s {
+			switch keyword {
+			case "enum", "const", "default", "examples":
+				continue
+			}
+			v.index(value, base)
+		}
+	case []interface{}:
+		for _, item := range s {
+			v.index(item, base)
+		}
+	}
+}
+
+// resolveURI resolves a reference against a base URI
+func resolveURI(base, ref string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
+
+// resolve finds the schema a $ref points to: a resource, an anchor, or a JSON Pointer inside a resource.
+// It returns the schema and the base URI of its resource.
+func (v *schemaValidator) resolve(base, ref string) (interface{}, string, error) {
+	target := resolveURI(base, ref)
+	document, fragment := target, ""
+	if i := strings.Index(target, "#"); i >= 0 {
+		document, fragment = target[:i], target[i+1:]
+	}
+
+	schema, ok := v.resources[document]
+	if !ok {
+		return nil, "", fmt.Errorf(localize("unsupported $ref %q, only local references are resolved"), ref)
+	}
+	if fragment == "" {
+		return schema, document, nil
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		if anchored, ok := v.resources[document+"#"+fragment]; ok {
+			return anchored, document, nil
+		}
+		return nil, "", fmt.Errorf(localize("unresolvable $ref %q"), ref)
+	}
+
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+	tokens, err := parsePointer(fragment)
+	if err != nil {
+		return nil, "", fmt.Errorf(localize("unresolvable $ref %q"), ref)
+	}
+	if schema, err = getPointer(schema, tokens); err != nil {
+		return nil, "", fmt.Errorf(localize("unresolvable $ref %q"), ref)
+	}
+	return schema, document, nil
+}
+
+// pattern compiles and caches a pattern of the schema
+func (v *schemaValidator) pattern(expr string) (*regexp.Regexp, error) {
+	if re, ok := v.patterns[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err == nil {
+		v.patterns[expr] = re
+	}
+	return re, err
+}
+
+// schemaTypeMatches reports whether instance has the JSON Schema type name
+func schemaTypeMatches(name string, instance interface{}) bool {
+	switch name {
+	case "integer":
+		n, ok := instance.(float64)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	case "number":
+		_, ok := instance.(float64)
+		return ok
+	}
+	return getJSONType(instance) == name
+}
+
+// validate checks instance against schema. base is the URI of the enclosing resource, instancePath and
+// schemaPath the JSON Pointers of the instance and of the schema reached so far.
+func (v *schemaValidator) validate(instance, schema interface{}, base, instancePath, schemaPath string) schemaResult {
+	var result schemaResult
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			result.errors = append(result.errors, schemaError{instancePath: instancePath, keywordLocation: schemaPath, keyword: "false", message: localize("no value is allowed here")})
+		}
+		return result
+	case map[string]interface{}:
+	default:
+		result.errors = append(result.errors, schemaError{instancePath: instancePath, keywordLocation: schemaPath, message: localize("schema must be an object or a boolean")})
+		return result
+	}
+	s := schema.(map[string]interface{})
+	if id, ok := s["$id"].(string); ok {
+		base = strings.TrimSuffix(resolveURI(base, id), "#")
+	}
+
+	// subschema validates instance (or one of its children) against a nested schema
+	subschema := func(child interface{}, childSchema interface{}, childPath, keywordPath string) schemaResult {
+		return v.validate(child, childSchema, base, childPath, schemaPath+keywordPath)
+	}
+	// apply validates in place and keeps the errors and the annotations
+	apply := func(childSchema interface{}, keywordPath string) bool {
+		sub := subschema(instance, childSchema, instancePath, keywordPath)
+		result.errors = append(result.errors, sub.errors...)
+		if len(sub.errors) == 0 {
+			result.annotate(sub)
+		}
+		return len(sub.errors) == 0
+	}
+
+	for _, keyword := range []string{"$ref", "$dynamicRef"} {
+		ref, ok := s[keyword].(string)
+		if !ok {
+			continue
+		}
+		target, targetBase, err := v.resolve(base, ref)
+		if err != nil {
+			result.fail(instancePath, schemaPath, keyword, err.Error())
+			continue
+		}
+		if v.depth >= maxSchemaDepth {
+			result.fail(instancePath, schemaPath, keyword, localize("$ref %q recurses without an exit", ref))
+			continue
+		}
+		v.depth++
+		sub := v.validate(instance, target, targetBase, instancePath, schemaPath+"/"+keyword)
+		v.depth--
+		result.errors = append(result.errors, sub.errors...)
+		if len(sub.errors) == 0 {
+			result.annotate(sub)
+		}
+	}
+
+	// Type, enum and const apply to every instance
+	if t, ok := s["type"]; ok {
+		names := []string{}
+		switch t := t.(type) {
+		case string:
+			names = append(names, t)
+		case []interface{}:
+			for _, name := range t {
+				if name, ok := name.(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+		matched := false
+		for _, name := range names {
+			matched = matched || schemaTypeMatches(name, instance)
+		}
+		if !matched {
+			result.fail(instancePath, schemaPath, "type", localize("must be %s, got %s", strings.Join(names, localize(" or ")), getJSONType(instance)))
+		}
+	}
+	if values, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, value := range values {
+			found = found || jsonEqual(instance, value)
+		}
+		if !found {
+			result.fail(instancePath, schemaPath, "enum", localize("must be one of the enum values"))
+		}
+	}
+	if value, ok := s["const"]; ok && !jsonEqual(instance, value) {
+		result.fail(instancePath, schemaPath, "const", localize("must equal the const value"))
+	}
+
+	// Combinators
+	if schemas, ok := s["allOf"].([]interface{}); ok {
+		for i, sub := range schemas {
+			apply(sub, "/allOf/"+strconv.Itoa(i))
+		}
+	}
+	if schemas, ok := s["anyOf"].([]interface{}); ok {
+		matched := false
+		for i, sub := range schemas {
+			if r := subschema(instance, sub, instancePath, "/anyOf/"+strconv.Itoa(i)); len(r.errors) == 0 {
+				matched = true
+				result.annotate(r)
+			}
+		}
+		if !matched {
+			result.fail(instancePath, schemaPath, "anyOf", localize("must match at least one schema in anyOf"))
+		}
+	}
+	if schemas, ok := s["oneOf"].([]interface{}); ok {
+		var matches []int
+		for i, sub := range schemas {
+			if r := subschema(instance, sub, instancePath, "/oneOf/"+strconv.Itoa(i)); len(r.errors) == 0 {
+				matches = append(matches, i)
+				result.annotate(r)
+			}
+		}
+		if len(matches) != 1 {
+			result.fail(instancePath, schemaPath, "oneOf", localize("must match exactly one schema in oneOf (%d matched)", len(matches)))
+		}
+	}
+	if sub, ok := s["not"]; ok {
+		if r := subschema(instance, sub, instancePath, "/not"); len(r.errors) == 0 {
+			result.fail(instancePath, schemaPath, "not", localize("must not match the schema in not"))
+		}
+	}
+	if condition, ok := s["if"]; ok {
+		r := subschema(instance, condition, instancePath, "/if")
+		if len(r.errors) == 0 {
+			result.annotate(r)
+			if then, ok := s["then"]; ok {
+				apply(then, "/then")
+			}
+		} else if otherwise, ok := s["else"]; ok {
+			apply(otherwise, "/else")
+		}
+	}
+
+	switch value := instance.(type) {
+	case float64:
+		v.validateNumber(value, s, &result, instancePath, schemaPath)
+	case string:
+		v.validateString(value, s, &result, instancePath, schemaPath)
+	case []interface{}:
+		v.validateArray(value, s, &result, subschema, instancePath, schemaPath)
+	case map[string]interface{}:
+		v.validateObject(value, s, &result, subschema, apply, instancePath, schemaPath)
+	}
+	return result
+}
+
+// schemaNumber reads a numeric keyword
+func schemaNumber(s map[string]interface{}, keyword string) (float64, bool) {
+	n, ok := s[keyword].(float64)
+	return n, ok
+}
+
+// validateNumber checks multipleOf and the bounds
+func (v *schemaValidator) validateNumber(n float64, s map[string]interface{}, result *schemaResult, instancePath, schemaPath string) {
+	if m, ok := schemaNumber(s, "multipleOf"); ok && m > 0 {
+		q := n / m
+		if math.IsInf(q, 0) || math.Abs(q-math.Round(q)) > 1e-9*math.Max(1, math.Abs(q)) {
+			result.fail(instancePath, schemaPath, "multipleOf", localize("must be a multiple of %v", m))
+		}
+	}
+	if limit, ok := schemaNumber(s, "maximum"); ok && n > limit {
+		result.fail(instancePath, schemaPath, "maximum", localize("must be <= %v", limit))
+	}
+	if limit, ok := schemaNumber(s, "exclusiveMaximum"); ok && n >= limit {
+		result.fail(instancePath, schemaPath, "exclusiveMaximum", localize("must be < %v", limit))
+	}
+	if limit, ok := schemaNumber(s, "minimum"); ok && n < limit {
+		result.fail(instancePath, schemaPath, "minimum", localize("must be >= %v", limit))
+	}
+	if limit, ok := schemaNumber(s, "exclusiveMinimum"); ok && n <= limit {
+		result.fail(instancePath, schemaPath, "exclusiveMinimum", localize("must be > %v", limit))
+	}
+}
+
+// validateString checks the length, pattern and format of a string
+func (v *schemaValidator) validateString(text string, s map[string]interface{}, result *schemaResult, instancePath, schemaPath string) {
+	length := utf8.RuneCountInString(text)
+	if limit, ok := schemaNumber(s, "maxLength"); ok && float64(length) > limit {
+		result.fail(instancePath, schemaPath, "maxLength", localize("must be at most %v characters", limit))
+	}
+	if limit, ok := schemaNumber(s, "minLength"); ok && float64(length) < limit {
+		result.fail(instancePath, schemaPath, "minLength", localize("must be at least %v characters", limit))
+	}
+	if expr, ok := s["pattern"].(string); ok {
+		re, err := v.pattern(expr)
+		if err != nil {
+			result.fail(instancePath, schemaPath, "pattern", localize("invalid pattern %q: %v", expr, err))
+		} else if !re.MatchString(text) {
+			result.fail(instancePath, schemaPath, "pattern", localize("must match pattern %q", expr))
+		}
+	}
+	if format, ok := s["format"].(string); ok && v.formats && !validFormat(format, text) {
+		result.fail(instancePath, schemaPath, "format", localize("must be a valid %s", format))
+	}
+}
+
+// validateArray checks the item applicators and the array constraints
+func (v *schemaValidator) validateArray(items []interface{}, s map[string]interface{}, result *schemaResult, subschema func(interface{}, interface{}, string, string) schemaResult, instancePath, schemaPath string) {
+	evaluate := func(index int, schema interface{}, keywordPath string) {
+		r := subschema(items[index], schema, instancePath+"/"+strconv.Itoa(index), keywordPath)
+		result.errors = append(result.errors, r.errors...)
+		if result.items == nil {
+			result.items = map[int]bool{}
+		}
+		result.items[index] = true
+	}
+
+	// Draft 2019-09 and earlier spell prefixItems as an items array, followed by additionalItems
+	prefix, _ := s["prefixItems"].([]interface{})
+	rest, hasRest := s["items"]
+	restKeyword := "items"
+	if tuple, ok := rest.([]interface{}); ok {
+		prefix = tuple
+		rest, hasRest = s["additionalItems"]
+		restKeyword = "additionalItems"
+	}
+	prefixKeyword := "prefixItems"
+	if _, ok := s["prefixItems"]; !ok {
+		prefixKeyword = "items"
+	}
+	for i := 0; i < len(prefix) && i < len(items); i++ {
+		evaluate(i, prefix[i], "/"+prefixKeyword+"/"+strconv.Itoa(i))
+	}
+	if hasRest {
+		for i := len(prefix); i < len(items); i++ {
+			evaluate(i, rest, "/"+restKeyword)
+		}
+	}
+
+	if contains, ok := s["contains"]; ok {
+		matched := 0
+		for i, item := range items {
+			if r := subschema(item, contains, instancePath+"/"+strconv.Itoa(i), "/contains"); len(r.errors) == 0 {
+				matched++
+				if result.items == nil {
+					result.items = map[int]bool{}
+				}
+				result.items[i] = true
+			}
+		}
+		minimum := 1.0
+		if limit, ok := schemaNumber(s, "minContains"); ok {
+			minimum = limit
+		}
+		if float64(matched) < minimum {
+			result.fail(instancePath, schemaPath, "contains", localize("must contain at least %v matching items", minimum))
+		}
+		if limit, ok := schemaNumber(s, "maxContains"); ok && float64(matched) > limit {
+			result.fail(instancePath, schemaPath, "maxContains", localize("must contain at most %v matching items", limit))
+		}
+	}
+
+	if limit, ok := schemaNumber(s, "maxItems"); ok && float64(len(items)) > limit {
+		result.fail(instancePath, schemaPath, "maxItems", localize("must have at most %v items", limit))
+	}
+	if limit, ok := schemaNumber(s, "minItems"); ok && float64(len(items)) < limit {
+		result.fail(instancePath, schemaPath, "minItems", localize("must have at least %v items", limit))
+	}
+	if unique, ok := s["uniqueItems"].(bool); ok && unique {
+	duplicates:
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if jsonEqual(items[i], items[j]) {
+					result.fail(instancePath, schemaPath, "uniqueItems", localize("items %d and %d are equal", i, j))
+					break duplicates
+				}
+			}
+		}
+	}
+
+	if unevaluated, ok := s["unevaluatedItems"]; ok {
+		for i := range items {
+			if !result.items[i] {
+				evaluate(i, unevaluated, "/unevaluatedItems")
+			}
+		}
+	}
+}
+
+// validateObject checks the property applicators and the object constraints
+func (v *schemaValidator) validateObject(object map[string]interface{}, s map[string]interface{}, result *schemaResult, subschema func(interface{}, interface{}, string, string) schemaResult, apply func(interface{}, string) bool, instancePath, schemaPath string) {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	evaluate := func(name string, schema interface{}, keywordPath, keyword string) {
+		r := subschema(object[name], schema, formatPointer(instancePath, name), keywordPath)
+		if schema == false && (keyword == "additionalProperties" || keyword == "unevaluatedProperties") {
+			result.fail(instancePath, schemaPath, keyword, localize("property %q is not allowed", name))
+		} else {
+			result.errors = append(result.errors, r.errors...)
+		}
+		if result.properties == nil {
+			result.properties = map[string]bool{}
+		}
+		result.properties[name] = true
+	}
+
+	matchedHere := map[string]bool{}
+	if properties, ok := s["properties"].(map[string]interface{}); ok {
+		for _, name := range names {
+			if schema, ok := properties[name]; ok {
+				evaluate(name, schema, formatPointer("/properties", name), "properties")
+				matchedHere[name] = true
+			}
+		}
+	}
+	if patterns, ok := s["patternProperties"].(map[string]interface{}); ok {
+		exprs := make([]string, 0, len(patterns))
+		for expr := range patterns {
+			exprs = append(exprs, expr)
+		}
+		sort.Strings(exprs)
+		for _, expr := range exprs {
+			re, err := v.pattern(expr)
+			if err != nil {
+				result.fail(instancePath, schemaPath, "patternProperties", localize("invalid pattern %q: %v", expr, err))
+				continue
+			}
+			for _, name := range names {
+				if re.MatchString(name) {
+					evaluate(name, patterns[expr], formatPointer("/patternProperties", expr), "patternProperties")
+					matchedHere[name] = true
+				}
+			}
+		}
+	}
+	if additional, ok := s["additionalProperties"]; ok {
+		for _, name := range names {
+			if !matchedHere[name] {
+				evaluate(name, additional, "/additionalProperties", "additionalProperties")
+			}
+		}
+	}
+	if propertyNames, ok := s["propertyNames"]; ok {
+		for _, name := range names {
+			r := subschema(name, propertyNames, formatPointer(instancePath, name), "/propertyNames")
+			for _, e := range r.errors {
+				result.fail(instancePath, schemaPath, "propertyNames", localize("property name %q: %s", name, e.message))
+			}
+		}
+	}
+
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := object[name]; !present {
+					result.fail(instancePath, schemaPath, "required", localize("missing required property %q", name))
+				}
+			}
+		}
+	}
+
+	// Draft 2019-09 split dependencies into dependentRequired (arrays) and dependentSchemas (schemas)
+	dependentRequired, _ := s["dependentRequired"].(map[string]interface{})
+	dependentSchemas, _ := s["dependentSchemas"].(map[string]interface{})
+	if dependencies, ok := s["dependencies"].(map[string]interface{}); ok {
+		for name, dependency := range dependencies {
+			if _, isArray := dependency.([]interface{}); isArray {
+				if dependentRequired == nil {
+					dependentRequired = map[string]interface{}{}
+				}
+				dependentRequired[name] = dependency
+			} else {
+				if dependentSchemas == nil {
+					dependentSchemas = map[string]interface{}{}
+				}
+				dependentSchemas[name] = dependency
+			}
+		}
+	}
+	for _, name := range names {
+		if needed, ok := dependentRequired[name].([]interface{}); ok {
+			for _, other := range needed {
+				if other, ok := other.(string); ok {
+					if _, present := object[other]; !present {
+						result.fail(instancePath, schemaPath, "dependentRequired", localize("property %q requires property %q", name, other))
+					}
+				}
+			}
+		}
+		if schema, ok := dependentSchemas[name]; ok {
+			apply(schema, formatPointer("/dependentSchemas", name))
+		}
+	}
+
+	if limit, ok := schemaNumber(s, "maxProperties"); ok && float64(len(object)) > limit {
+		result.fail(instancePath, schemaPath, "maxProperties", localize("must have at most %v properties", limit))
+	}
+	if limit, ok := schemaNumber(s, "minProperties"); ok && float64(len(object)) < limit {
+		result.fail(instancePath, schemaPath, "minProperties", localize("must have at least %v properties", limit))
+	}
+
+	if unevaluated, ok := s["unevaluatedProperties"]; ok {
+		for _, name := range names {
+			if !result.properties[name] {
+				evaluate(name, unevaluated, "/unevaluatedProperties", "unevaluatedProperties")
+			}
+		}
+	}
+}
+
+// Patterns of the string formats checked by validFormat
+var (
+	formatEmail    = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	formatHostname = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+	formatUUID     = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	formatDuration = regexp.MustCompile(`^P(\d+Y)?(\d+M)?(\d+W)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?$`)
+	formatTime     = regexp.MustCompile(`^(?i)\d{2}:\d{2}:\d{2}(\.\d+)?(z|[+-]\d{2}:\d{2})$`)
+)
+
+// validFormat checks the formats of the format-assertion vocabulary; unknown formats always pass
+func validFormat(format, text string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(text))
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", text)
+		return err == nil
+	case "time":
+		if !formatTime.MatchString(text) {
+			return false
+		}
+		_, err := time.Parse("2006-01-02T"+text[:8], "2000-01-01T"+text[:8])
+		return err == nil
+	case "duration":
+		return formatDuration.MatchString(text) && text != "P" && !strings.HasSuffix(text, "T")
+	case "email", "idn-email":
+		return formatEmail.MatchString(text)
+	case "hostname", "idn-hostname":
+		return len(text) <= 253 && formatHostname.MatchString(text)
+	case "ipv4":
+		addr, err := netip.ParseAddr(text)
+		return err == nil && addr.Is4()
+	case "ipv6":
+		addr, err := netip.ParseAddr(text)
+		return err == nil && addr.Is6() && addr.Zone() == ""
+	case "uri", "iri":
+		u, err := url.Parse(text)
+		return err == nil && u.Scheme != ""
+	case "uri-reference", "iri-reference", "uri-template":
+		_, err := url.Parse(text)
+		return err == nil
+	case "uuid":
+		return formatUUID.MatchString(text)
+	case "regex":
+		_, err := regexp.Compile(text)
+		return err == nil
+	case "json-pointer":
+		_, err := parsePointer(text)
+		return err == nil && !strings.Contains(strings.NewReplacer("~0", "", "~1", "").Replace(text), "~")
+	}
+	return true
+}
+
+// mergeOptions configures mergeJSON: how arrays combine, the identity key used by mergeByKey, and
+// whether a null in the overlay deletes the member instead of being stored
+type mergeOptions struct {
+	Arrays      string `json:"arrays"`
+	Key         string `json:"key"`
+	NullDeletes bool   `json:"nullDeletes"`
+}
+
+// pruneOptions selects what pruneJSON removes; Keys are dropped at any depth
+type pruneOptions struct {
+	Nulls        bool     `json:"nulls"`
+	EmptyStrings bool     `json:"emptyStrings"`
+	EmptyArrays  bool     `json:"emptyArrays"`
+	EmptyObjects bool     `json:"emptyObjects"`
+	Keys         []string `json:"keys"`
+}
+
+// decodeOptions reads an options argument given as a JS object or a JSON string, leaving defaults for missing keys
+func decodeOptions(value js.Value, target interface{}) error {
+	switch value.Type() {
+	case js.TypeUndefined, js.TypeNull:
+		return nil
+	case js.TypeObject:
+		value = js.Global().Get("JSON").Call("stringify", value)
+	}
+	return json.Unmarshal([]byte(value.String()), target)
+}
+
+// jsonDataResult serializes data the way the JSON functions return documents; in a Native variant
+// data is the document as a JS value, with no size
+func jsonDataResult(data interface{}) map[string]interface{} {
+	if nativeResults {
+		// Compact JSON read by JSON.parse builds large documents faster than setting members one by one
+		resultBytes, err := json.Marshal(data)
+		if err != nil {
+			return map[string]interface{}{
+				"error": localize("Failed to serialize result: %v", err),
+			}
+		}
+		return map[string]interface{}{
+			"data":   js.Global().Get("JSON").Call("parse", string(resultBytes)),
+			"valid":  true,
+			"format": "json",
+		}
+	}
+
+	resultBytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return map[string]interface{}{
+			"error": localize("Failed to serialize result: %v", err),
+		}
+	}
+
+	resultString := string(resultBytes)
+	return map[string]interface{}{
+		"data":   resultString,
+		"valid":  true,
+		"size":   len(resultString),
+		"format": "json",
+	}
+}
+
+// deepMerge merges b into a: objects key by key, arrays per options, anything else is replaced by b.
+// With NullDeletes, nulls of b remove members, including inside objects b adds.
+func deepMerge(a, b interface{}, options mergeOptions) interface{} {
+	switch bv := b.(type) {
+	case map[string]interface{}:
+		av, ok := a.(map[string]interface{})
+		if !ok && !options.NullDeletes {
+			return bv
+		}
+		merged := make(map[string]interface{}, len(av)+len(bv))
+		for key, value := range av {
+			merged[key] = value
+		}
+		for key, value := range bv {
+			if value == nil && options.NullDeletes {
+				delete(merged, key)
+				continue
+			}
+			merged[key] = deepMerge(merged[key], value, options)
+		}
+		return merged
+
+	case []interface{}:
+		av, ok := a.([]interface{})
+		if !ok {
+			return bv
+		}
+		switch options.Arrays {
+		case "concat":
+			return append(append([]interface{}{}, av...), bv...)
+		case "union":
+			return mergeArraysUnion(av, bv)
+		case "mergeByKey":
+			return mergeArraysByKey(av, bv, options)
+		}
+		return bv
+	}
+	return b
+}
+
+// mergeArraysUnion appends the elements of b that are not already in a, comparing them as JSON
+func mergeArraysUnion(a, b []interface{}) []interface{} {
+	merged := append([]interface{}{}, a...)
+	seen := map[string]bool{}
+	for _, item := range a {
+		encoded, _ := json.Marshal(item)
+		seen[string(encoded)] = true
+	}
+	for _, item := range b {
+		encoded, _ := json.Marshal(item)
+		if !seen[string(encoded)] {
+			seen[string(encoded)] = true
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// mergeArraysByKey merges the objects of b into the objects of a sharing the same key value, keeping
+// the order of a; unmatched elements of b, and elements without the key, are appended
+func mergeArraysByKey(a, b []interface{}, options mergeOptions) []interface{} {
+	identity := func(item interface{}) (string, bool) {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		value, found := object[options.Key]
+		if !found || value == nil {
+			return "", false
+		}
+		encoded, _ := json.Marshal(value)
+		return string(encoded), true
+	}
+
+	merged := append([]interface{}{}, a...)
+	positions := map[string]int{}
+	for i, item := range merged {
+		if id, ok := identity(item); ok {
+			if _, seen := positions[id]; !seen {
+				positions[id] = i
+			}
+		}
+	}
+	for _, item := range b {
+		id, ok := identity(item)
+		if !ok {
+			merged = append(merged, item)
+			continue
+		}
+		if i, found := positions[id]; found {
+			merged[i] = deepMerge(merged[i], item, options)
+			continue
+		}
+		positions[id] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// splitPath splits a dot notation path such as "users.*.email" into its segments
+func splitPath(path string) []string {
+	segments := []string{}
+	for _, segment := range strings.Split(path, ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// pickPath copies the value at path from source into target, creating the containers on the way;
+// "*" matches every key or index
+func pickPath(target, source interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return source
+	}
+	segment, rest := path[0], path[1:]
+
+	switch sv := source.(type) {
+	case map[string]interface{}:
+		tv, ok := target.(map[string]interface{})
+		if !ok {
+			tv = map[string]interface{}{}
+		}
+		for key, value := range sv {
+			if segment == "*" || segment == key {
+				tv[key] = pickPath(tv[key], value, rest)
+			}
+		}
+		if len(tv) == 0 && target == nil {
+			return nil
+		}
+		return tv
+
+	case []interface{}:
+		tv, ok := target.([]interface{})
+		if !ok || len(tv) != len(sv) {
+			// Picked array entries keep their index, entries that were not picked stay null
+			tv = make([]interface{}, len(sv))
+		}
+		for i, value := range sv {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				tv[i] = pickPath(tv[i], value, rest)
+			}
+		}
+		return tv
+	}
+	return target
+}
+
+// omitPath removes the value at path; "*" matches every key or index
+func omitPath(data interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return data
+	}
+	segment, rest := path[0], path[1:]
+
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if segment != "*" && segment != key {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(v, key)
+			} else {
+				v[key] = omitPath(value, rest)
+			}
+		}
+		return v
+
+	case []interface{}:
+		kept := make([]interface{}, 0, len(v))
+		for i, value := range v {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				kept = append(kept, value)
+				continue
+			}
+			if len(rest) > 0 {
+				kept = append(kept, omitPath(value, rest))
+			}
+		}
+		return kept
+	}
+	return data
+}
+
+// pruneValue returns the pruned value and whether it should be kept in its parent
+func pruneValue(data interface{}, options pruneOptions, removed *int) (interface{}, bool) {
+	switch v := data.(type) {
+	case nil:
+		return nil, !options.Nulls
+	case string:
+		return v, !(options.EmptyStrings && v == "")
+	case map[string]interface{}:
+		pruned := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			keep := true
+			for _, name := range options.Keys {
+				if key == name {
+					keep = false
+					break
+				}
+			}
+			if keep {
+				value, keep = pruneValue(value, options, removed)
+			}
+			if keep {
+				pruned[key] = value
+			} else {
+				*removed++
+			}
+		}
+		return pruned, !(options.EmptyObjects && len(pruned) == 0)
+	case []interface{}:
+		pruned := make([]interface{}, 0, len(v))
+		for _, value := range v {
+			if value, keep := pruneValue(value, options, removed); keep {
+				pruned = append(pruned, value)
+			} else {
+				*removed++
+			}
+		}
+		return pruned, !(options.EmptyArrays && len(pruned) == 0)
+	}
+	return data, true
+}
+
+// flattenOptions configures flattenJSON and unflattenJSON. Arrays is "brackets" (a.b[0].c),
+// "index" (a.b.0.c) or "keep", which leaves arrays as values.
+type flattenOptions struct {
+	Delimiter string `json:"delimiter"`
+	Arrays    string `json:"arrays"`
+}
+
+// flattenMaxIndex bounds the array indexes unflattenJSON accepts, as the missing elements are filled with null
+const flattenMaxIndex = 1 << 20
+
+// flattenArgument reads the optional options argument at position
+func flattenArgument(args []js.Value, position int) (flattenOptions, error) {
+	options := flattenOptions{Delimiter: ".", Arrays: "brackets"}
+	if len(args) > position {
+		if err := decodeOptions(args[position], &options); err != nil {
+			return options, errors.New(localize("Invalid options: %v", err))
+		}
+	}
+	if options.Delimiter == "" {
+		return options, errors.New(localize("delimiter must not be empty"))
+	}
+	switch options.Arrays {
+	case "brackets", "index", "keep":
+	default:
+		return options, errors.New(localize("Unknown array mode %q", options.Arrays))
+	}
+	return options, nil
+}
+
+// flattenValue adds the leaves of value to flat under key. Empty objects and arrays are leaves, so
+// unflattenJSON restores them.
+func flattenValue(flat *jsonObject, value interface{}, key string, options flattenOptions) error {
+	switch v := value.(type) {
+	case *jsonObject:
+		if len(v.keys) > 0 {
+			for _, name := range v.keys {
+				child := name
+				if key != "" {
+					child = key + options.Delimiter + name
+				}
+				if err := flattenValue(flat, v.values[name], child, options); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case []interface{}:
+		if len(v) > 0 && options.Arrays != "keep" {
+			for i, element := range v {
+				child := "[" + strconv.Itoa(i) + "]"
+				if options.Arrays == "index" {
+					child = strconv.Itoa(i)
+					if key != "" {
+						child = key + options.Delimiter + child
+					}
+				} else {
+					child = key + child
+				}
+				if err := flattenValue(flat, element, child, options); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	// Keys holding the delimiter can collide with nested paths, as {"a.b": 1, "a": {"b": 2}}
+	if _, seen := flat.values[key]; seen {
+		return errors.New(localize("key %q is produced twice", key))
+	}
+	flat.set(key, value)
+	return nil
+}
+
+// flatSegment is one step of a flattened key: an object member or an array index
+type flatSegment struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// parseFlatKey splits a flattened key into its segments. Parts that do not parse as indexes are
+// member names, so "a[x]" is the member "a[x]".
+func parseFlatKey(key string, options flattenOptions) []flatSegment {
+	var segments []flatSegment
+	for _, part := range strings.Split(key, options.Delimiter) {
+		switch options.Arrays {
+		case "index":
+			if index, ok := flatIndex(part); ok {
+				segments = append(segments, flatSegment{index: index, isIndex: true})
+				continue
+			}
+		case "brackets":
+			if indexes, name, ok := flatBrackets(part); ok {
+				if name != "" {
+					segments = append(segments, flatSegment{name: name})
+				}
+				for _, index := range indexes {
+					segments = append(segments, flatSegment{index: index, isIndex: true})
+				}
+				continue
+			}
+		}
+		segments = append(segments, flatSegment{name: part})
+	}
+	return segments
+}
+
+// flatBrackets splits a part such as "b[0][1]" into its name and indexes
+func flatBrackets(part string) (indexes []int, name string, ok bool) {
+	end := len(part)
+	for strings.HasSuffix(part[:end], "]") {
+		open := strings.LastIndex(part[:end], "[")
+		if open < 0 {
+			return nil, "", false
+		}
+		index, valid := flatIndex(part[open+1 : end-1])
+		if !valid {
+			return nil, "", false
+		}
+		indexes = append([]int{index}, indexes...)
+		end = open
+	}
+	if len(indexes) == 0 {
+		return nil, "", false
+	}
+	return indexes, part[:end], true
+}
+
+// flatIndex parses an array index written without sign or leading zeros
+func flatIndex(text string) (int, bool) {
+	if text == "" || (text != "0" && strings.HasPrefix(text, "0")) {
+		return 0, false
+	}
+	index, err := strconv.Atoi(text)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
+
+// flatNode is a value being rebuilt by unflattenJSON: a leaf, an object of *flatNode or an array
+// of *flatNode where nil elements were never set
+type flatNode struct {
+	leaf   bool
+	data   interface{}
+	object *jsonObject
+	array  []*flatNode
+}
+
+// insert stores value at the path of segments below the node
+func (n *flatNode) insert(segments []flatSegment, value interface{}, key string) error {
+	if len(segments) == 0 {
+		if n.leaf || n.object != nil || n.array != nil {
+			return errors.New(localize("key %q conflicts with another key", key))
+		}
+		n.leaf, n.data = true, value
+		return nil
+	}
+	if n.leaf {
+		return errors.New(localize("key %q conflicts with another key", key))
+	}
+
+	segment := segments[0]
+	var child *flatNode
+	if segment.isIndex {
+		if n.object != nil {
+			return errors.New(localize("key %q conflicts with another key", key))
+		}
+		if segment.index >= flattenMaxIndex {
+			return errors.New(localize("array index %d out of range", segment.index))
+		}
+		if n.array == nil {
+			n.array = []*flatNode{}
+		}
+		for len(n.array) <= segment.index {
+			n.array = append(n.array, nil)
+		}
+		if n.array[segment.index] == nil {
+			n.array[segment.index] = &flatNode{}
+		}
+		child = n.array[segment.index]
+	} else {
+		if n.array != nil {
+			return errors.New(localize("key %q conflicts with another key", key))
+		}
+		if n.object == nil {
+			n.object = &jsonObject{values: map[string]interface{}{}}
+		}
+		existing, found := n.object.values[segment.name]
+		if !found {
+			existing = &flatNode{}
+			n.object.set(segment.name, existing)
+		}
+		child = existing.(*flatNode)
+	}
+	return child.insert(segments[1:], value, key)
+}
+
+// value converts the node to a JSON value, elements that were never set becoming null
+func (n *flatNode) value() interface{} {
+	switch {
+	case n.object != nil:
+		object := &jsonObject{values: make(map[string]interface{}, len(n.object.keys))}
+		for _, name := range n.object.keys {
+			object.set(name, n.object.values[name].(*flatNode).value())
+		}
+		return object
+	case n.array != nil:
+		array := make([]interface{}, len(n.array))
+		for i, element := range n.array {
+			if element != nil {
+				array[i] = element.value()
+			}
+		}
+		return array
+	}
+	return n.data
+}
+
+// decodeDocument reads a JSON document given as a JSON string, or as a JS object or array
+func decodeDocument(value js.Value) (interface{}, error) {
+	text, err := documentText(value)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	err = json.Unmarshal([]byte(text), &data)
+	return data, err
+}
+
+// decodeOrderedDocument is decodeDocument keeping the member order of objects, and with numbers
+// kept as json.Number when numbers is set, like decodeOrdered
+func decodeOrderedDocument(value js.Value, numbers bool) (interface{}, error) {
+	text, err := documentText(value)
+	if err != nil {
+		return nil, err
+	}
+	return decodeOrdered([]byte(text), numbers)
+}
+
+// documentText returns the JSON text of a document argument. Objects and arrays are serialized by
+// JSON.stringify, much faster than reading them member by member through syscall/js; the values it
+// rejects, such as BigInt or cyclic objects, are reported as an error.
+func documentText(value js.Value) (text string, err error) {
+	if value.Type() != js.TypeObject {
+		return value.String(), nil
+	}
+	defer func() {
+		if failure := recover(); failure != nil {
+			jsErr, ok := failure.(js.Error)
+			if !ok {
+				panic(failure)
+			}
+			err = errors.New(jsErr.Value.Get("message").String())
+		}
+	}()
+	return js.Global().Get("JSON").Call("stringify", value).String(), nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer such as "/users/0/e~1mail" into its unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.New(localize("invalid JSON pointer %q", pointer))
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// formatPointer escapes a token and appends it to a JSON Pointer
+func formatPointer(pointer, token string) string {
+	return pointer + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// arrayIndex reads the token of an array element; "-" is accepted as the end when allowEnd is set
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, errors.New(localize("invalid array index %q", token))
+	}
+	limit := length - 1
+	if allowEnd {
+		limit = length
+	}
+	if index > limit {
+		return 0, errors.New(localize("array index %d out of range", index))
+	}
+	return index, nil
+}
+
+// getPointer returns the value a JSON Pointer refers to
+func getPointer(data interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			value, ok := v[token]
+			if !ok {
+				return nil, errors.New(localize("member %q not found", token))
+			}
+			data = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			data = v[index]
+		default:
+			return nil, errors.New(localize("cannot read %q of a scalar", token))
+		}
+	}
+	return data, nil
+}
+
+// updatePointer calls update on the container holding the last token and stores the container it returns,
+// so arrays can grow and shrink. It returns the new document.
+func updatePointer(data interface{}, tokens []string, update func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return update(data, tokens[0])
+	}
+	switch v := data.(type) {
+	case map[string]interface{}:
+		child, ok := v[tokens[0]]
+		if !ok {
+			return nil, errors.New(localize("member %q not found", tokens[0]))
+		}
+		updated, err := updatePointer(child, tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		v[tokens[0]] = updated
+		return v, nil
+	case []interface{}:
+		index, err := arrayIndex(tokens[0], len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := updatePointer(v[index], tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		v[index] = updated
+		return v, nil
+	}
+	return nil, errors.New(localize("cannot read %q of a scalar", tokens[0]))
+}
+
+// addPointer inserts value: a member is created or replaced, an array element is inserted before index
+func addPointer(data interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return updatePointer(data, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			v[token] = value
+			return v, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(v), true)
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, nil)
+			copy(v[index+1:], v[index:])
+			v[index] = value
+			return v, nil
+		}
+		return nil, errors.New(localize("cannot add %q to a scalar", token))
+	})
+}
+
+// removePointer deletes the member or array element and returns the new document
+func removePointer(data interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New(localize("cannot remove the whole document"))
+	}
+	return updatePointer(data, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			if _, ok := v[token]; !ok {
+				return nil, errors.New(localize("member %q not found", token))
+			}
+			delete(v, token)
+			return v, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(v[:index], v[index+1:]...), nil
+		}
+		return nil, errors.New(localize("cannot read %q of a scalar", token))
+	})
+}
+
+// applyPatchOperation applies one JSON Patch operation and returns the new document
+func applyPatchOperation(data interface{}, operation map[string]interface{}) (interface{}, error) {
+	op, _ := operation["op"].(string)
+	pointer, ok := operation["path"].(string)
+	if !ok {
+		return nil, errors.New(localize("missing %q", "path"))
+	}
+	path, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	value, hasValue := operation["value"]
+	switch op {
+	case "add", "replace", "test":
+		if !hasValue {
+			return nil, errors.New(localize("missing %q", "value"))
+		}
+	case "move", "copy":
+		pointer, ok := operation["from"].(string)
+		if !ok {
+			return nil, errors.New(localize("missing %q", "from"))
+		}
+		from, err := parsePointer(pointer)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = getPointer(data, from); err != nil {
+			return nil, err
+		}
+		if op == "copy" {
+			value = copyJSONValue(value)
+			break
+		}
+		if strings.HasPrefix(operation["path"].(string), pointer+"/") {
+			return nil, errors.New(localize("cannot move a value into itself"))
+		}
+		if data, err = removePointer(data, from); err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, errors.New(localize("unknown operation %q", op))
+	}
+
+	switch op {
+	case "remove":
+		return removePointer(data, path)
+	case "replace":
+		if _, err := getPointer(data, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		return updatePointer(data, path, func(container interface{}, token string) (interface{}, error) {
+			switch v := container.(type) {
+			case map[string]interface{}:
+				v[token] = value
+			case []interface{}:
+				index, _ := arrayIndex(token, len(v), false)
+				v[index] = value
+			}
+			return container, nil
+		})
+	case "test":
+		current, err := getPointer(data, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(current, value) {
+			return nil, errors.New(localize("value at %q differs", pointer))
+		}
+		return data, nil
+	}
+	return addPointer(data, path, value)
+}
+
+// copyJSONValue deep copies a decoded JSON value
+func copyJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyJSONValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyJSONValue(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// jsonEqual compares two decoded JSON values; member order does not matter
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, found := y[key]
+			if !found || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// mergePatch applies a JSON Merge Patch to target
+func mergePatch(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	for key, value := range members {
+		if value == nil {
+			delete(object, key)
+		} else {
+			object[key] = mergePatch(object[key], value)
+		}
+	}
+	return object
+}
+
+// diffPatch appends to patch the operations turning a into b at pointer. Objects are compared member by
+// member in key order; arrays keep their common head and tail, then the elements in between are diffed
+// pairwise and the surplus added or removed.
+func diffPatch(a, b interface{}, pointer string, patch []interface{}) []interface{} {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(x)+len(y))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		for key := range y {
+			if _, found := x[key]; !found {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			before, inA := x[key]
+			after, inB := y[key]
+			switch {
+			case !inB:
+				patch = append(patch, map[string]interface{}{"op": "remove", "path": formatPointer(pointer, key)})
+			case !inA:
+				patch = append(patch, map[string]interface{}{"op": "add", "path": formatPointer(pointer, key), "value": after})
+			default:
+				patch = diffPatch(before, after, formatPointer(pointer, key), patch)
+			}
+		}
+		return patch
+
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		head := 0
+		for head < len(x) && head < len(y) && jsonEqual(x[head], y[head]) {
+			head++
+		}
+		tail := 0
+		for tail < len(x)-head && tail < len(y)-head && jsonEqual(x[len(x)-1-tail], y[len(y)-1-tail]) {
+			tail++
+		}
+		middleA, middleB := x[head:len(x)-tail], y[head:len(y)-tail]
+		common := min(len(middleA), len(middleB))
+		for i := 0; i < common; i++ {
+			patch = diffPatch(middleA[i], middleB[i], formatPointer(pointer, strconv.Itoa(head+i)), patch)
+		}
+		// Removals go from the last index down so earlier indexes stay valid
+		for i := len(middleA) - 1; i >= common; i-- {
+			patch = append(patch, map[string]interface{}{"op": "remove", "path": formatPointer(pointer, strconv.Itoa(head+i))})
+		}
+		for i := common; i < len(middleB); i++ {
+			patch = append(patch, map[string]interface{}{"op": "add", "path": formatPointer(pointer, strconv.Itoa(head+i)), "value": middleB[i]})
+		}
+		return patch
+	}
+
+	if !jsonEqual(a, b) {
+		patch = append(patch, map[string]interface{}{"op": "replace", "path": pointer, "value": b})
+	}
+	return patch
+}
+
+// diffOptions tunes diffJSON. Key matches the objects of arrays by that member, whatever their position.
+type diffOptions struct {
+	IgnoreArrayOrder bool    `json:"ignoreArrayOrder"`
+	Tolerance        float64 `json:"tolerance"`
+	Key              string  `json:"key"`
+}
+
+// diffMaxValueLength bounds the values quoted in the text output of diffJSON
+const diffMaxValueLength = 80
+
+// structuralDiff collects the differences found by diffJSON, in document order
+type structuralDiff struct {
+	options diffOptions
+	entries []diffEntry
+}
+
+// diffEntry is one difference: kind is "added", "removed" or "changed"
+type diffEntry struct {
+	kind     string
+	path     string
+	from, to interface{}
+}
+
+// compare records the differences between a and b at path
+func (d *structuralDiff) compare(a, b interface{}, path string) {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(x)+len(y))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		for key := range y {
+			if _, found := x[key]; !found {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			before, inA := x[key]
+			after, inB := y[key]
+			switch {
+			case !inB:
+				d.entries = append(d.entries, diffEntry{kind: "removed", path: diffMemberPath(path, key), from: before})
+			case !inA:
+				d.entries = append(d.entries, diffEntry{kind: "added", path: diffMemberPath(path, key), to: after})
+			default:
+				d.compare(before, after, diffMemberPath(path, key))
+			}
+		}
+		return
+
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		switch {
+		case d.keyed(x) && d.keyed(y):
+			d.compareKeyed(x, y, path)
+		case d.options.IgnoreArrayOrder:
+			d.compareUnordered(x, y, path)
+		default:
+			d.compareOrdered(x, y, path)
+		}
+		return
+	}
+
+	if !d.equal(a, b) {
+		d.entries = append(d.entries, diffEntry{kind: "changed", path: path, from: a, to: b})
+	}
+}
+
+// compareOrdered keeps the common head and tail of two arrays and compares the elements in between
+// by position, so an insertion does not show every following element as changed
+func (d *structuralDiff) compareOrdered(x, y []interface{}, path string) {
+	head := 0
+	for head < len(x) && head < len(y) && d.equal(x[head], y[head]) {
+		head++
+	}
+	tail := 0
+	for tail < len(x)-head && tail < len(y)-head && d.equal(x[len(x)-1-tail], y[len(y)-1-tail]) {
+		tail++
+	}
+	middleA, middleB := x[head:len(x)-tail], y[head:len(y)-tail]
+	common := min(len(middleA), len(middleB))
+	for i := 0; i < common; i++ {
+		d.compare(middleA[i], middleB[i], diffIndexPath(path, head+i))
+	}
+	for i := common; i < len(middleA); i++ {
+		d.entries = append(d.entries, diffEntry{kind: "removed", path: diffIndexPath(path, head+i), from: middleA[i]})
+	}
+	for i := common; i < len(middleB); i++ {
+		d.entries = append(d.entries, diffEntry{kind: "added", path: diffIndexPath(path, head+i), to: middleB[i]})
+	}
+}
+
+// compareUnordered pairs the equal elements of two arrays wherever they are; the others are
+// removed, at their index in a, or added, at their index in b
+func (d *structuralDiff) compareUnordered(x, y []interface{}, path string) {
+	matched := d.matchUnordered(x, y)
+	for i, element := range x {
+		if matched[i] < 0 {
+			d.entries = append(d.entries, diffEntry{kind: "removed", path: diffIndexPath(path, i), from: element})
+		}
+	}
+	used := make([]bool, len(y))
+	for _, j := range matched {
+		if j >= 0 {
+			used[j] = true
+		}
+	}
+	for j, element := range y {
+		if !used[j] {
+			d.entries = append(d.entries, diffEntry{kind: "added", path: diffIndexPath(path, j), to: element})
+		}
+	}
+}
+
+// matchUnordered returns for each element of x the index of an equal element of y, or -1
+func (d *structuralDiff) matchUnordered(x, y []interface{}) []int {
+	matched := make([]int, len(x))
+	used := make([]bool, len(y))
+	for i, element := range x {
+		matched[i] = -1
+		for j, candidate := range y {
+			if !used[j] && d.equal(element, candidate) {
+				matched[i], used[j] = j, true
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// compareKeyed pairs the objects of two arrays by their key member, reported with a filter path such
+// as $.items[?(@.id==7)]
+func (d *structuralDiff) compareKeyed(x, y []interface{}, path string) {
+	keysB := make(map[string]interface{}, len(y))
+	for _, element := range y {
+		keysB[diffKeyValue(element, d.options.Key)] = element
+	}
+	seen := make(map[string]bool, len(x))
+	for _, element := range x {
+		key := diffKeyValue(element, d.options.Key)
+		seen[key] = true
+		elementPath := path + "[?(@" + diffMemberPath("", d.options.Key) + "==" + key + ")]"
+		if after, found := keysB[key]; found {
+			d.compare(element, after, elementPath)
+		} else {
+			d.entries = append(d.entries, diffEntry{kind: "removed", path: elementPath, from: element})
+		}
+	}
+	for _, element := range y {
+		key := diffKeyValue(element, d.options.Key)
+		if !seen[key] {
+			seen[key] = true
+			elementPath := path + "[?(@" + diffMemberPath("", d.options.Key) + "==" + key + ")]"
+			d.entries = append(d.entries, diffEntry{kind: "added", path: elementPath, to: element})
+		}
+	}
+}
+
+// keyed reports whether every element is an object with a distinct scalar key member
+func (d *structuralDiff) keyed(array []interface{}) bool {
+	if d.options.Key == "" || len(array) == 0 {
+		return false
+	}
+	seen := make(map[string]bool, len(array))
+	for _, element := range array {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		switch object[d.options.Key].(type) {
+		case string, float64, bool:
+		default:
+			return false
+		}
+		key := diffKeyValue(element, d.options.Key)
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+	}
+	return true
+}
+
+// equal compares two values like jsonEqual, with the numeric tolerance and array matching options
+func (d *structuralDiff) equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		return ok && math.Abs(x-y) <= d.options.Tolerance
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, found := y[key]
+			if !found || !d.equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		if d.options.IgnoreArrayOrder || (d.keyed(x) && d.keyed(y)) {
+			for _, j := range d.matchUnordered(x, y) {
+				if j < 0 {
+					return false
+				}
+			}
+			return true
+		}
+		for i := range x {
+			if !d.equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return jsonEqual(a, b)
+}
+
+// report lists the differences by kind, for the data of diffJSON
+func (d *structuralDiff) report() *jsonObject {
+	lists := map[string][]interface{}{"added": {}, "removed": {}, "changed": {}}
+	for _, entry := range d.entries {
+		member := &jsonObject{values: map[string]interface{}{}}
+		member.set("path", entry.path)
+		switch entry.kind {
+		case "added":
+			member.set("value", entry.to)
+		case "removed":
+			member.set("value", entry.from)
+		default:
+			member.set("from", entry.from)
+			member.set("to", entry.to)
+		}
+		lists[entry.kind] = append(lists[entry.kind], member)
+	}
+
+	report := &jsonObject{values: map[string]interface{}{}}
+	for _, kind := range []string{"added", "removed", "changed"} {
+		report.set(kind, lists[kind])
+	}
+	return report
+}
+
+// summary counts the differences, e.g. "2 added, 1 removed, 3 changed"
+func (d *structuralDiff) summary() string {
+	if len(d.entries) == 0 {
+		return localize("no differences")
+	}
+	counts := map[string]int{}
+	for _, entry := range d.entries {
+		counts[entry.kind]++
+	}
+	return localize("%d added, %d removed, %d changed", counts["added"], counts["removed"], counts["changed"])
+}
+
+// text writes one line per difference in document order: "+ path: value", "- path: value" and
+// "~ path: from → to"
+func (d *structuralDiff) text() string {
+	var b strings.Builder
+	for _, entry := range d.entries {
+		switch entry.kind {
+		case "added":
+			fmt.Fprintf(&b, "+ %s: %s\n", entry.path, diffValueText(entry.to))
+		case "removed":
+			fmt.Fprintf(&b, "- %s: %s\n", entry.path, diffValueText(entry.from))
+		default:
+			fmt.Fprintf(&b, "~ %s: %s → %s\n", entry.path, diffValueText(entry.from), diffValueText(entry.to))
+		}
+	}
+	return b.String()
+}
+
+// diffValueText formats a value as compact JSON, shortened past diffMaxValueLength characters
+func diffValueText(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if text := []rune(string(data)); len(text) > diffMaxValueLength {
+		return string(text[:diffMaxValueLength-1]) + "…"
+	}
+	return string(data)
+}
+
+// diffKeyValue formats the key member of an array element as a JSON literal
+func diffKeyValue(element interface{}, key string) string {
+	object, _ := element.(map[string]interface{})
+	data, _ := json.Marshal(object[key])
+	return string(data)
+}
+
+var diffIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// diffMemberPath appends a member to a JSONPath, in dot notation when the name allows it
+func diffMemberPath(path, name string) string {
+	if diffIdentifier.MatchString(name) {
+		return path + "." + name
+	}
+	return path + jsonPathName(name)
+}
+
+func diffIndexPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+// schemaInferOptions configures generateJSONSchema: whether strings get enum and format keywords,
+// and how many distinct values an enum may list
+type schemaInferOptions struct {
+	Enums         bool `json:"enums"`
+	MaxEnumValues int  `json:"maxEnumValues"`
+	Formats       bool `json:"formats"`
+}
+
+// schemaInferFormats are the formats generateJSONSchema recognises, the most specific first
+var schemaInferFormats = []string{"date-time", "date", "time", "uuid", "email", "ipv4", "ipv6", "uri"}
+
+// schemaInference accumulates what the samples show of one position of the documents
+type schemaInference struct {
+	count      int
+	types      map[string]bool
+	strings    []string
+	distinct   map[string]bool
+	stringSeen int
+	formats    []string
+	objects    int
+	properties *jsonObject
+	items      *schemaInference
+}
+
+func newSchemaInference() *schemaInference {
+	return &schemaInference{types: map[string]bool{}, distinct: map[string]bool{}}
+}
+
+// observe records one value found at this position
+func (s *schemaInference) observe(value interface{}, options schemaInferOptions) {
+	s.count++
+	switch v := value.(type) {
+	case nil:
+		s.types["null"] = true
+	case bool:
+		s.types["boolean"] = true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			s.types["integer"] = true
+		} else {
+			s.types["number"] = true
+		}
+	case string:
+		s.types["string"] = true
+		s.observeString(v, options)
+	case *jsonObject:
+		s.types["object"] = true
+		s.objects++
+		if s.properties == nil {
+			s.properties = &jsonObject{values: map[string]interface{}{}}
+		}
+		for _, key := range v.keys {
+			property, ok := s.properties.values[key].(*schemaInference)
+			if !ok {
+				property = newSchemaInference()
+				s.properties.set(key, property)
+			}
+			property.observe(v.values[key], options)
+		}
+	case []interface{}:
+		s.types["array"] = true
+		if s.items == nil {
+			s.items = newSchemaInference()
+		}
+		for _, item := range v {
+			s.items.observe(item, options)
+		}
+	}
+}
+
+// observeString tracks the distinct values of a string position, up to one more than an enum may
+// list, and the formats every value so far matches
+func (s *schemaInference) observeString(text string, options schemaInferOptions) {
+	s.stringSeen++
+	if !s.distinct[text] && len(s.strings) <= options.MaxEnumValues {
+		s.distinct[text] = true
+		s.strings = append(s.strings, text)
+	}
+
+	if !options.Formats || (s.stringSeen > 1 && len(s.formats) == 0) {
+		return
+	}
+	candidates := s.formats
+	if s.stringSeen == 1 {
+		candidates = schemaInferFormats
+	}
+	matched := []string{}
+	for _, format := range candidates {
+		// Anything with a scheme is a valid uri; only values with an authority look like links
+		if validFormat(format, text) && (format != "uri" || strings.Contains(text, "://")) {
+			matched = append(matched, format)
+		}
+	}
+	s.formats = matched
+}
+
+// schema writes the inferred keywords: type, then format or enum for strings, properties and
+// required for objects, items for arrays
+func (s *schemaInference) schema(options schemaInferOptions) *jsonObject {
+	schema := &jsonObject{values: map[string]interface{}{}}
+
+	types := []interface{}{}
+	for _, name := range []string{"null", "boolean", "integer", "number", "string", "array", "object"} {
+		// Integers are numbers: a position holding both is a number
+		if s.types[name] && !(name == "integer" && s.types["number"]) {
+			types = append(types, name)
+		}
+	}
+	switch len(types) {
+	case 0:
+		return schema
+	case 1:
+		schema.set("type", types[0])
+	default:
+		schema.set("type", types)
+	}
+
+	if s.types["string"] {
+		if len(s.formats) > 0 {
+			schema.set("format", s.formats[0])
+		} else if options.Enums && len(s.strings) <= options.MaxEnumValues && s.stringSeen > len(s.strings) {
+			// Values repeat, so they look like a closed set rather than free text
+			enum := make([]interface{}, 0, len(s.strings)+1)
+			for _, value := range s.strings {
+				enum = append(enum, value)
+			}
+			if s.types["null"] {
+				enum = append(enum, nil)
+			}
+			schema.set("enum", enum)
+		}
+	}
+
+	if s.types["object"] {
+		properties := &jsonObject{values: map[string]interface{}{}}
+		required := []interface{}{}
+		if s.properties != nil {
+			for _, key := range s.properties.keys {
+				property := s.properties.values[key].(*schemaInference)
+				properties.set(key, property.schema(options))
+				if property.count == s.objects {
+					required = append(required, key)
+				}
+			}
+		}
+		schema.set("properties", properties)
+		if len(required) > 0 {
+			schema.set("required", required)
+		}
+	}
+
+	if s.types["array"] && s.items != nil && s.items.count > 0 {
+		schema.set("items", s.items.schema(options))
+	}
+	return schema
+}
+
+// typeGenOptions configures jsonToTypeScript and jsonToGoStruct
+type typeGenOptions struct {
+	RootName  string `json:"rootName"`
+	Export    bool   `json:"export"`
+	Package   string `json:"package"`
+	OmitEmpty bool   `json:"omitempty"`
+}
+
+// goInitialisms are written in capitals in Go field names, as golint asks
+var goInitialisms = map[string]bool{
+	"API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true, "GUID": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "OS": true,
+	"SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true,
+	"URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// tsIdentifier matches the member names TypeScript accepts without quotes
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// identifierWords splits a JSON key into words at separators and lower-to-upper case changes, so
+// "user_id", "user-id" and "userId" all give user, id
+func identifierWords(key string) []string {
+	words := []string{}
+	var word []rune
+	var previous rune
+	for _, r := range key {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+		case unicode.IsUpper(r) && len(word) > 0 && (unicode.IsLower(previous) || unicode.IsDigit(previous)):
+			words = append(words, string(word))
+			word = []rune{r}
+		default:
+			word = append(word, r)
+		}
+		previous = r
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// typeName turns a JSON key into a PascalCase type name
+func typeName(key string) string {
+	var name strings.Builder
+	for _, word := range identifierWords(key) {
+		runes := []rune(word)
+		name.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+	}
+	if text := name.String(); text != "" && !unicode.IsLetter([]rune(text)[0]) {
+		return "T" + text
+	}
+	return name.String()
+}
+
+// goFieldName turns a JSON key into an exported Go field name with initialisms in capitals
+func goFieldName(key string) string {
+	var name strings.Builder
+	for _, word := range identifierWords(key) {
+		if goInitialisms[strings.ToUpper(word)] {
+			name.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		name.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+	}
+	text := name.String()
+	if text == "" || !unicode.IsLetter([]rune(text)[0]) || !unicode.IsUpper([]rune(text)[0]) {
+		return "X" + text
+	}
+	return text
+}
+
+// singularName names the elements of an array from the array's name: Users gives User, Categories
+// Category, and names that are not plurals get an Item suffix
+func singularName(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
+
+// typeDeclaration is a named object type waiting to be written
+type typeDeclaration struct {
+	name string
+	node *schemaInference
+}
+
+// typeGenerator writes the types inferred from a sample, the root first and nested types in the
+// order they are met
+type typeGenerator struct {
+	language string
+	options  typeGenOptions
+	used     map[string]bool
+	pending  []typeDeclaration
+	declared []typeDeclaration
+}
+
+// claim reserves a unique type name, prefixing the parent type then numbering on collisions
+func (g *typeGenerator) claim(name, parent string) string {
+	candidates := []string{name, parent + name}
+	for i := 2; ; i++ {
+		candidates = append(candidates, name+strconv.Itoa(i))
+		for _, candidate := range candidates {
+			if !g.used[candidate] {
+				g.used[candidate] = true
+				return candidate
+			}
+		}
+	}
+}
+
+func (g *typeGenerator) generate(root *schemaInference, rootName string) string {
+	var out strings.Builder
+	if g.language == "go" && g.options.Package != "" {
+		fmt.Fprintf(&out, "package %s\n", g.options.Package)
+	}
+
+	if root.types["object"] && len(root.types) == 1 {
+		g.used[rootName] = true
+		g.pending = append(g.pending, typeDeclaration{rootName, root})
+	} else {
+		// A root that is not a single object is an alias of its type
+		g.used[rootName] = true
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		if g.language == "go" {
+			fmt.Fprintf(&out, "type %s %s\n", rootName, g.goType(root, rootName, rootName))
+		} else {
+			fmt.Fprintf(&out, "%stype %s = %s;\n", g.exportKeyword(), rootName, g.tsType(root, rootName, rootName))
+		}
+	}
+
+	for len(g.pending) > 0 {
+		declaration := g.pending[0]
+		g.pending = g.pending[1:]
+		g.declared = append(g.declared, declaration)
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		if g.language == "go" {
+			g.writeGoStruct(&out, declaration)
+		} else {
+			g.writeTSInterface(&out, declaration)
+		}
+	}
+	return out.String()
+}
+
+// typeName names a type after a JSON key, with Go initialisms in Go
+func (g *typeGenerator) typeName(key string) string {
+	if g.language == "go" {
+		return goFieldName(key)
+	}
+	return typeName(key)
+}
+
+func (g *typeGenerator) exportKeyword() string {
+	if g.options.Export {
+		return "export "
+	}
+	return ""
+}
+
+// objectType names an object position and queues its declaration
+func (g *typeGenerator) objectType(node *schemaInference, name, parent string) string {
+	name = g.claim(name, parent)
+	g.pending = append(g.pending, typeDeclaration{name, node})
+	return name
+}
+
+// tsType writes the TypeScript type of a position: a union of what the samples held there
+func (g *typeGenerator) tsType(node *schemaInference, name, parent string) string {
+	members := []string{}
+	for _, kind := range []string{"boolean", "number", "string", "array", "object", "null"} {
+		switch {
+		case kind == "number" && (node.types["integer"] || node.types["number"]):
+			members = append(members, "number")
+		case kind == "array" && node.types["array"]:
+			item := "unknown"
+			if node.items != nil && node.items.count > 0 {
+				item = g.tsType(node.items, singularName(name), parent)
+			}
+			if strings.Contains(item, " ") {
+				item = "(" + item + ")"
+			}
+			members = append(members, item+"[]")
+		case kind == "object" && node.types["object"]:
+			members = append(members, g.objectType(node, name, parent))
+		case kind != "number" && kind != "array" && kind != "object" && node.types[kind]:
+			members = append(members, kind)
+		}
+	}
+	if len(members) == 0 {
+		return "unknown"
+	}
+	return strings.Join(members, " | ")
+}
+
+func (g *typeGenerator) writeTSInterface(out *strings.Builder, declaration typeDeclaration) {
+	fmt.Fprintf(out, "%sinterface %s {\n", g.exportKeyword(), declaration.name)
+	if properties := declaration.node.properties; properties != nil {
+		for _, key := range properties.keys {
+			property := properties.values[key].(*schemaInference)
+			member := key
+			if !tsIdentifier.MatchString(key) {
+				encoded, _ := json.Marshal(key)
+				member = string(encoded)
+			}
+			if property.count < declaration.node.objects {
+				member += "?"
+			}
+			fmt.Fprintf(out, "  %s: %s;\n", member, g.tsType(property, g.typeName(key), declaration.name))
+		}
+	}
+	out.WriteString("}\n")
+}
+
+// goType writes the Go type of a position. Mixed types become interface{}; a position that is also
+// null becomes a pointer, except slices, which are nil already.
+func (g *typeGenerator) goType(node *schemaInference, name, parent string) string {
+	kinds := []string{}
+	for kind := range node.types {
+		if kind != "null" && !(kind == "integer" && node.types["number"]) {
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) != 1 {
+		return "interface{}"
+	}
+
+	var goType string
+	switch kinds[0] {
+	case "boolean":
+		goType = "bool"
+	case "integer":
+		goType = "int64"
+	case "number":
+		goType = "float64"
+	case "string":
+		goType = "string"
+	case "object":
+		goType = g.objectType(node, name, parent)
+	case "array":
+		item := "interface{}"
+		if node.items != nil && node.items.count > 0 {
+			item = g.goType(node.items, singularName(name), parent)
+		}
+		return "[]" + item
+	}
+	if node.types["null"] {
+		return "*" + goType
+	}
+	return goType
+}
+
+func (g *typeGenerator) writeGoStruct(out *strings.Builder, declaration typeDeclaration) {
+	type field struct{ name, goType, tag string }
+	fields := []field{}
+	names := map[string]bool{}
+	if properties := declaration.node.properties; properties != nil {
+		for _, key := range properties.keys {
+			property := properties.values[key].(*schemaInference)
+			name := goFieldName(key)
+			for i := 2; names[name]; i++ {
+				name = goFieldName(key) + strconv.Itoa(i)
+			}
+			names[name] = true
+
+			tag := key
+			if g.options.OmitEmpty && property.count < declaration.node.objects {
+				tag += ",omitempty"
+			}
+			encoded, _ := json.Marshal(tag)
+			fields = append(fields, field{name, g.goType(property, g.typeName(key), declaration.name), "`json:" + string(encoded) + "`"})
+		}
+	}
+
+	// Align the columns the way gofmt does, so the output can be pasted as is
+	nameWidth, typeWidth := 0, 0
+	for _, f := range fields {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(f.name))
+		typeWidth = max(typeWidth, utf8.RuneCountInString(f.goType))
+	}
+	fmt.Fprintf(out, "type %s struct {\n", declaration.name)
+	for _, f := range fields {
+		fmt.Fprintf(out, "\t%-*s %-*s %s\n", nameWidth, f.name, typeWidth, f.goType, f.tag)
+	}
+	out.WriteString("}\n")
+}
+
+// maxMockRecords bounds generateMockData so a typo cannot exhaust the wasm memory
+const maxMockRecords = 10000
+
+// maxMockDepth stops recursive schemas: past it only required properties and minItems are generated
+const maxMockDepth = 8
+
+var (
+	mockFirstNames = []string{"Alice", "Bruno", "Chloé", "David", "Emma", "Farid", "Grace", "Hugo", "Inès", "Jules", "Kenji", "Léa", "Marco", "Nina", "Omar", "Paula", "Quentin", "Rosa", "Sven", "Tara"}
+	mockLastNames  = []string{"Martin", "Bernard", "Dubois", "Garcia", "Müller", "Rossi", "Smith", "Johnson", "Nguyen", "Kowalski", "Silva", "Tanaka", "Petit", "Moreau", "Fischer", "Lopez"}
+	mockCities     = []string{"Paris", "Lyon", "Berlin", "Madrid", "Lisbon", "Montréal", "Tokyo", "Austin", "Toronto", "Milan", "Amsterdam", "Brussels", "Geneva", "Oslo"}
+	mockCountries  = []string{"France", "Germany", "Spain", "Portugal", "Canada", "Japan", "United States", "Italy", "Netherlands", "Belgium", "Switzerland", "Norway"}
+	mockStreets    = []string{"Main Street", "rue de la Paix", "Baker Street", "avenue Victor Hugo", "Elm Road", "Hauptstraße", "Calle Mayor", "Oak Avenue"}
+	mockCompanies  = []string{"Acme Corp", "Globex", "Initech", "Umbrella Labs", "Stark Industries", "Wayne Enterprises", "Hooli", "Vandelay Imports"}
+	mockDomains    = []string{"example.com", "example.org", "mail.test", "demo.dev"}
+	mockASCII      = strings.NewReplacer("é", "e", "è", "e", "ü", "u", "ö", "o")
+	mockWords      = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua"}
+)
+
+// mockGenerator produces schema-conforming fake values from a seeded source
+type mockGenerator struct {
+	rng  *rand.Rand
+	root interface{}
+}
+
+// pick returns a random element of a word list
+func (g *mockGenerator) pick(words []string) string {
+	return words[g.rng.Intn(len(words))]
+}
+
+// email returns a first.last address on a reserved domain
+func (g *mockGenerator) email() string {
+	local := strings.ToLower(g.pick(mockFirstNames) + "." + g.pick(mockLastNames))
+	return mockASCII.Replace(local) + "@" + g.pick(mockDomains)
+}
+
+// resolve follows a local $ref ("#/definitions/user", "#/$defs/user") within the root schema
+func (g *mockGenerator) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return g.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf(localize("unsupported $ref %q, only local references are resolved"), ref)
+	}
+
+	current := g.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(localize("unresolvable $ref %q"), ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf(localize("unresolvable $ref %q"), ref)
+		}
+	}
+	return current, nil
+}
+
+// mergeSchemas combines allOf members (or a schema and its chosen oneOf/anyOf branch) into one schema
+func mergeSchemas(schemas ...map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+	for _, schema := range schemas {
+		for key, value := range schema {
+			switch key {
+			case "properties":
+				if props, ok := value.(map[string]interface{}); ok {
+					for name, prop := range props {
+						properties[name] = prop
+					}
+				}
+			case "required":
+				if names, ok := value.([]interface{}); ok {
+					required = append(required, names...)
+				}
+			default:
+				merged[key] = value
+			}
+		}
+	}
+	if len(properties) > 0 {
+		merged["properties"] = properties
+	}
+	if len(required) > 0 {
+		merged["required"] = required
+	}
+	return merged
+}
+
+// value generates one value for schema; name is the enclosing property name, used to pick realistic strings
+func (g *mockGenerator) value(schema interface{}, name string, depth int) (interface{}, error) {
+	if enabled, ok := schema.(bool); ok {
+		if !enabled {
+			return nil, errors.New(localize("the false schema accepts no value"))
+		}
+		schema = map[string]interface{}{}
+	}
+	object, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(localize("schema must be an object or a boolean"))
+	}
+
+	if ref, ok := object["$ref"].(string); ok {
+		if depth > maxMockDepth*4 {
+			return nil, fmt.Errorf(localize("$ref %q recurses without an exit"), ref)
+		}
+		target, err := g.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		return g.value(target, name, depth+1)
+	}
+
+	if members, ok := object["allOf"].([]interface{}); ok {
+		schemas := []map[string]interface{}{}
+		for _, member := range members {
+			if ref, ok := member.(map[string]interface{})["$ref"].(string); ok {
+				resolved, err := g.resolve(ref)
+				if err != nil {
+					return nil, err
+				}
+				member = resolved
+			}
+			if memberSchema, ok := member.(map[string]interface{}); ok {
+				schemas = append(schemas, memberSchema)
+			}
+		}
+		rest := map[string]interface{}{}
+		for key, value := range object {
+			if key != "allOf" {
+				rest[key] = value
+			}
+		}
+		return g.value(mergeSchemas(append(schemas, rest)...), name, depth)
+	}
+
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		if branches, ok := object[keyword].([]interface{}); ok && len(branches) > 0 {
+			branch := branches[g.rng.Intn(len(branches))]
+			if ref, ok := branch.(map[string]interface{})["$ref"].(string); ok {
+				resolved, err := g.resolve(ref)
+				if err != nil {
+					return nil, err
+				}
+				branch = resolved
+			}
+			rest := map[string]interface{}{}
+			for key, value := range object {
+				if key != keyword {
+					rest[key] = value
+				}
+			}
+			if branchSchema, ok := branch.(map[string]interface{}); ok {
+				return g.value(mergeSchemas(rest, branchSchema), name, depth)
+			}
+			return g.value(branch, name, depth)
+		}
+	}
+
+	if constant, ok := object["const"]; ok {
+		return constant, nil
+	}
+	if enum, ok := object["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[g.rng.Intn(len(enum))], nil
+	}
+
+	switch schemaType := g.schemaType(object); schemaType {
+	case "object":
+		return g.object(object, depth)
+	case "array":
+		return g.array(object, name, depth)
+	case "string":
+		return g.str(object, name)
+	case "integer", "number":
+		return g.number(object, name, schemaType == "integer")
+	case "boolean":
+		return g.rng.Intn(2) == 1, nil
+	case "null":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf(localize("unsupported type %q"), schemaType)
+	}
+}
+
+// schemaType picks the type to generate, inferring it from the keywords present when "type" is missing
+func (g *mockGenerator) schemaType(object map[string]interface{}) string {
+	switch declared := object["type"].(type) {
+	case string:
+		return declared
+	case []interface{}:
+		types := []string{}
+		for _, t := range declared {
+			if s, ok := t.(string); ok && s != "null" {
+				types = append(types, s)
+			}
+		}
+		if len(types) == 0 {
+			return "null"
+		}
+		return g.pick(types)
+	}
+
+	switch {
+	case object["properties"] != nil || object["required"] != nil || object["additionalProperties"] != nil:
+		return "object"
+	case object["items"] != nil || object["prefixItems"] != nil || object["minItems"] != nil:
+		return "array"
+	case object["minimum"] != nil || object["maximum"] != nil || object["multipleOf"] != nil:
+		return "number"
+	}
+	return "string"
+}
+
+// object generates every required property and most optional ones, in a stable order
+func (g *mockGenerator) object(object map[string]interface{}, depth int) (interface{}, error) {
+	properties, _ := object["properties"].(map[string]interface{})
+	required := map[string]bool{}
+	if names, ok := object["required"].([]interface{}); ok {
+		for _, name := range names {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	for name := range required {
+		if _, ok := properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := map[string]interface{}{}
+	for _, name := range names {
+		if !required[name] && (depth >= maxMockDepth || g.rng.Float64() >= 0.8) {
+			continue
+		}
+		propertySchema, ok := properties[name]
+		if !ok {
+			propertySchema = map[string]interface{}{}
+		}
+		value, err := g.value(propertySchema, name, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// array generates between minItems and maxItems entries, honoring tuple schemas and uniqueItems
+func (g *mockGenerator) array(object map[string]interface{}, name string, depth int) (interface{}, error) {
+	minItems, maxItems := 1, 5
+	if v, ok := object["minItems"].(float64); ok {
+		minItems = int(v)
+		if maxItems < minItems {
+			maxItems = minItems + 4
+		}
+	}
+	if v, ok := object["maxItems"].(float64); ok {
+		maxItems = int(v)
+		if minItems > maxItems {
+			minItems = maxItems
+		}
+	}
+	length := minItems
+	if depth < maxMockDepth && maxItems > minItems {
+		length += g.rng.Intn(maxItems - minItems + 1)
+	}
+
+	// Draft 2020-12 prefixItems, or the older array form of items, describe positional entries
+	tuple, _ := object["prefixItems"].([]interface{})
+	itemSchema := object["items"]
+	if items, ok := itemSchema.([]interface{}); ok {
+		tuple, itemSchema = items, object["additionalItems"]
+	}
+	if itemSchema == nil {
+		itemSchema = map[string]interface{}{}
+	}
+	if len(tuple) > length {
+		length = len(tuple)
+	}
+
+	unique, _ := object["uniqueItems"].(bool)
+	seen := map[string]bool{}
+	result := make([]interface{}, 0, length)
+	for i := 0; i < length; i++ {
+		schema := itemSchema
+		if i < len(tuple) {
+			schema = tuple[i]
+		}
+
+		var item interface{}
+		for attempt := 0; ; attempt++ {
+			value, err := g.value(schema, name, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+			item = value
+			if !unique {
+				break
+			}
+			key, _ := json.Marshal(value)
+			if !seen[string(key)] {
+				seen[string(key)] = true
+				break
+			}
+			if attempt == 20 {
+				if len(result) >= minItems {
+					return result, nil
+				}
+				return nil, errors.New(localize("cannot generate enough unique items"))
+			}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// str generates a string from the format, pattern or property name, then fits it to minLength/maxLength
+func (g *mockGenerator) str(object map[string]interface{}, name string) (interface{}, error) {
+	var value string
+	format, _ := object["format"].(string)
+	switch format {
+	case "email", "idn-email":
+		value = g.email()
+	case "uuid":
+		b := make([]byte, 16)
+		g.rng.Read(b)
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		value = fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	case "date-time", "date", "time":
+		// Dates are drawn from 2020-2025 rather than around time.Now so seeded runs stay reproducible
+		t := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(g.rng.Int63n(6*365*24*3600)) * time.Second)
+		value = t.Format(map[string]string{"date-time": time.RFC3339, "date": "2006-01-02", "time": "15:04:05Z"}[format])
+	case "uri", "url", "iri", "uri-reference":
+		value = "https://www." + g.pick(mockWords) + ".example.com/" + g.pick(mockWords)
+	case "hostname", "idn-hostname":
+		value = g.pick(mockWords) + "." + g.pick(mockDomains)
+	case "ipv4":
+		value = fmt.Sprintf("%d.%d.%d.%d", 1+g.rng.Intn(223), g.rng.Intn(256), g.rng.Intn(256), 1+g.rng.Intn(254))
+	case "ipv6":
+		parts := make([]string, 8)
+		for i := range parts {
+			parts[i] = strconv.FormatInt(int64(g.rng.Intn(65536)), 16)
+		}
+		value = strings.Join(parts, ":")
+	}
+
+	if pattern, ok := object["pattern"].(string); ok && value == "" {
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		if err != nil {
+			return nil, fmt.Errorf(localize("invalid pattern %q: %v"), pattern, err)
+		}
+		var b strings.Builder
+		g.fromRegexp(re.Simplify(), &b)
+		value = b.String()
+	}
+
+	if value == "" {
+		value = g.namedString(strings.ToLower(name))
+	}
+
+	// Length limits count characters, not bytes
+	runes := []rune(value)
+	if v, ok := object["maxLength"].(float64); ok && len(runes) > int(v) {
+		runes = runes[:int(v)]
+	}
+	if v, ok := object["minLength"].(float64); ok {
+		for len(runes) < int(v) {
+			runes = append(runes, rune('a'+g.rng.Intn(26)))
+		}
+	}
+	return string(runes), nil
+}
+
+// namedString returns a realistic value for common property names, falling back to lorem ipsum
+func (g *mockGenerator) namedString(name string) string {
+	switch {
+	case strings.Contains(name, "email"):
+		return g.email()
+	case strings.Contains(name, "firstname") || strings.Contains(name, "first_name") || name == "given":
+		return g.pick(mockFirstNames)
+	case strings.Contains(name, "lastname") || strings.Contains(name, "last_name") || strings.Contains(name, "surname"):
+		return g.pick(mockLastNames)
+	case strings.Contains(name, "username") || name == "login":
+		return mockASCII.Replace(strings.ToLower(g.pick(mockFirstNames))) + strconv.Itoa(g.rng.Intn(1000))
+	case strings.Contains(name, "company") || strings.Contains(name, "organization"):
+		return g.pick(mockCompanies)
+	case strings.Contains(name, "name"):
+		return g.pick(mockFirstNames) + " " + g.pick(mockLastNames)
+	case strings.Contains(name, "city"):
+		return g.pick(mockCities)
+	case strings.Contains(name, "country"):
+		return g.pick(mockCountries)
+	case strings.Contains(name, "street") || strings.Contains(name, "address"):
+		return strconv.Itoa(1+g.rng.Intn(200)) + " " + g.pick(mockStreets)
+	case strings.Contains(name, "zip") || strings.Contains(name, "postal"):
+		return fmt.Sprintf("%05d", g.rng.Intn(100000))
+	case strings.Contains(name, "phone") || strings.Contains(name, "mobile"):
+		return fmt.Sprintf("+33 6 %02d %02d %02d %02d", g.rng.Intn(100), g.rng.Intn(100), g.rng.Intn(100), g.rng.Intn(100))
+	case strings.Contains(name, "url") || strings.Contains(name, "website"):
+		return "https://www." + g.pick(mockWords) + ".example.com"
+	case name == "id" || strings.HasSuffix(name, "id"):
+		return strconv.FormatInt(g.rng.Int63n(1e12), 36)
+	case strings.Contains(name, "description") || strings.Contains(name, "comment") || strings.Contains(name, "bio"):
+		return g.words(8 + g.rng.Intn(8))
+	}
+	return g.words(1 + g.rng.Intn(3))
+}
+
+// words returns n lorem ipsum words, capitalized like a sentence
+func (g *mockGenerator) words(n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = g.pick(mockWords)
+	}
+	sentence := strings.Join(words, " ")
+	return strings.ToUpper(sentence[:1]) + sentence[1:]
+}
+
+// fromRegexp writes a random string matched by re, with unbounded repeats capped to a few occurrences
+func (g *mockGenerator) fromRegexp(re *syntax.Regexp, b *strings.Builder) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return
+		}
+		// Prefer printable ASCII so negated classes such as [^,] stay readable
+		var printable []rune
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1] && r < 0x7f; r++ {
+				if r >= 0x20 {
+					printable = append(printable, r)
+				}
+			}
+		}
+		if len(printable) > 0 {
+			b.WriteRune(printable[g.rng.Intn(len(printable))])
+			return
+		}
+		i := g.rng.Intn(len(re.Rune)/2) * 2
+		b.WriteRune(re.Rune[i] + rune(g.rng.Intn(int(re.Rune[i+1]-re.Rune[i])+1)))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune(rune('a' + g.rng.Intn(26)))
+	case syntax.OpCapture:
+		g.fromRegexp(re.Sub[0], b)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := 0, 3
+		switch re.Op {
+		case syntax.OpPlus:
+			min, max = 1, 4
+		case syntax.OpQuest:
+			max = 1
+		case syntax.OpRepeat:
+			min, max = re.Min, re.Max
+			if max < 0 {
+				max = min + 3
+			}
+		}
+		for n := min + g.rng.Intn(max-min+1); n > 0; n-- {
+			g.fromRegexp(re.Sub[0], b)
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.fromRegexp(sub, b)
+		}
+	case syntax.OpAlternate:
+		g.fromRegexp(re.Sub[g.rng.Intn(len(re.Sub))], b)
+	}
+}
+
+// number generates a value within minimum/maximum (inclusive or exclusive) that respects multipleOf
+func (g *mockGenerator) number(object map[string]interface{}, name string, integer bool) (interface{}, error) {
+	low, high := 0.0, 1000.0
+	switch name = strings.ToLower(name); {
+	case strings.Contains(name, "age"):
+		low, high = 18, 90
+	case strings.Contains(name, "lat"):
+		low, high = -90, 90
+	case strings.Contains(name, "lon") || strings.Contains(name, "lng"):
+		low, high = -180, 180
+	case strings.Contains(name, "year"):
+		low, high = 1970, 2025
+	case strings.Contains(name, "rating") || strings.Contains(name, "score"):
+		low, high = 0, 5
+	}
+
+	minimum, hasMin := object["minimum"].(float64)
+	maximum, hasMax := object["maximum"].(float64)
+	exclusiveMin, exclusiveMax := false, false
+	// Draft 6+ gives exclusive bounds as numbers, draft 4 as booleans qualifying minimum/maximum
+	switch v := object["exclusiveMinimum"].(type) {
+	case float64:
+		minimum, hasMin, exclusiveMin = v, true, true
+	case bool:
+		exclusiveMin = v && hasMin
+	}
+	switch v := object["exclusiveMaximum"].(type) {
+	case float64:
+		maximum, hasMax, exclusiveMax = v, true, true
+	case bool:
+		exclusiveMax = v && hasMax
+	}
+
+	switch {
+	case hasMin && hasMax:
+		low, high = minimum, maximum
+	case hasMin:
+		if low < minimum || high <= minimum {
+			low, high = minimum, minimum+(high-low)
+		}
+	case hasMax:
+		if high > maximum || low >= maximum {
+			low, high = maximum-(high-low), maximum
+		}
+	}
+	if low > high {
+		return nil, errors.New(localize("minimum is greater than maximum"))
+	}
+
+	step := 0.0
+	if v, ok := object["multipleOf"].(float64); ok && v > 0 {
+		step = v
+	} else if integer {
+		step = 1
+	}
+
+	if step > 0 {
+		first, last := math.Ceil(low/step), math.Floor(high/step)
+		if exclusiveMin && first*step <= low {
+			first++
+		}
+		if exclusiveMax && last*step >= high {
+			last--
+		}
+		if integer && step != math.Trunc(step) {
+			// Only multiples that are whole numbers are valid integers
+			for first <= last && first*step != math.Trunc(first*step) {
+				first++
+			}
+		}
+		if first > last {
+			return nil, errors.New(localize("no value satisfies the numeric constraints"))
+		}
+		value := (first + float64(g.rng.Int63n(int64(last-first)+1))) * step
+		// Multiplying by a decimal step such as 0.01 leaves float noise
+		return strconv.ParseFloat(strconv.FormatFloat(value, 'f', 10, 64), 64)
+	}
+
+	value := math.Round((low+g.rng.Float64()*(high-low))*100) / 100
+	if value < low || (exclusiveMin && value <= low) {
+		value = low + (high-low)/2
+	}
+	if value > high || (exclusiveMax && value >= high) {
+		value = low + (high-low)/2
+	}
+	return value, nil
+}
+
+// XML formatting and canonicalization for formatXML, minifyXML and c14nXML, written from the
+// parseXNodes tree
+
+// xmlWriter configures writeXML: pretty indents element-only content by indent, comments keeps them
+type xmlWriter struct {
+	b        strings.Builder
+	indent   string
+	pretty   bool
+	comments bool
+}
+
+// c14nAttrEscaper escapes attribute values as canonical XML does; unlike the xml output method it
+// leaves > as is
+var c14nAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", "\"", "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+// c14nOptions configures c14nXML: withComments keeps comments, inclusiveNamespaces lists the prefixes
+// (#default for the default namespace) handled as by inclusive canonicalization, and id selects the
+// element whose Id, ID or id attribute, in any namespace such as wsu:Id, has that value, as a
+// same-document reference does
+type c14nOptions struct {
+	WithComments        bool     `json:"withComments"`
+	InclusiveNamespaces []string `json:"inclusiveNamespaces"`
+	ID                  string   `json:"id"`
+}
+
+// writeXML parses a document and writes it back with the writer's settings, keeping its XML
+// declaration and DOCTYPE
+func writeXML(source string, w xmlWriter) (string, error) {
+	doc, err := parseXNodes(source)
+	if err != nil {
+		return "", err
+	}
+
+	declaration, doctype := xmlProlog(source)
+	for _, line := range []string{declaration, doctype} {
+		if line != "" {
+			w.b.WriteString(line)
+			if w.pretty {
+				w.b.WriteString("\n")
+			}
+		}
+	}
+	for _, child := range doc.children {
+		if child.kind == xcommentNode && !w.comments {
+			continue
+		}
+		w.node(child, 0)
+		if w.pretty {
+			w.b.WriteString("\n")
+		}
+	}
+	return w.b.String(), nil
+}
+
+// xmlProlog returns the XML declaration and the DOCTYPE of a document as written, which
+// parseXNodes does not keep
+func xmlProlog(source string) (declaration, doctype string) {
+	decoder := xml.NewDecoder(strings.NewReader(source))
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return declaration, doctype
+		}
+		switch t := token.(type) {
+		case xml.ProcInst:
+			if t.Target == "xml" {
+				declaration = "<?xml " + strings.TrimSpace(string(t.Inst)) + "?>"
+			}
+		case xml.Directive:
+			if bytes.HasPrefix(t, []byte("DOCTYPE")) {
+				doctype = "<!" + string(t) + ">"
+			}
+		case xml.StartElement:
+			return declaration, doctype
+		}
+	}
+}
+
+// node writes a node and its descendants
+func (w *xmlWriter) node(n *xnode, depth int) {
+	switch n.kind {
+	case xtextNode:
+		w.b.WriteString(xmlTextEscaper.Replace(n.value))
+	case xcommentNode:
+		w.b.WriteString("<!--" + n.value + "-->")
+	case xpiNode:
+		w.b.WriteString("<?" + n.name.Local)
+		if n.value != "" {
+			w.b.WriteString(" " + n.value)
+		}
+		w.b.WriteString("?>")
+	case xelementNode:
+		w.element(n, depth)
+	}
+}
+
+// element writes an element with its namespace declarations and attributes as written. Whitespace
+// between child elements is dropped, and replaced by indentation when pretty printing, only when
+// the element holds no other text and does not ask for xml:space="preserve".
+func (w *xmlWriter) element(n *xnode, depth int) {
+	w.b.WriteString("<" + n.qname())
+	prefixes := make([]string, 0, len(n.namespaces))
+	for prefix := range n.namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		name := "xmlns"
+		if prefix != "" {
+			name += ":" + prefix
+		}
+		w.b.WriteString(" " + name + `="` + xmlAttrEscaper.Replace(n.namespaces[prefix]) + `"`)
+	}
+	for _, a := range n.attrs {
+		w.b.WriteString(" " + a.qname() + `="` + xmlAttrEscaper.Replace(a.value) + `"`)
+	}
+
+	children := n.children
+	elementOnly := true
+	for _, a := range n.attrs {
+		if a.name.Space == xmlNamespace && a.name.Local == "space" && a.value == "preserve" {
+			elementOnly = false
+		}
+	}
+	for _, child := range children {
+		if child.kind == xtextNode && strings.Trim(child.value, " \t\r\n") != "" {
+			elementOnly = false
+		}
+	}
+	if elementOnly || !w.comments {
+		kept := make([]*xnode, 0, len(children))
+		for _, child := range children {
+			if (child.kind == xtextNode && elementOnly) || (child.kind == xcommentNode && !w.comments) {
+				continue
+			}
+			kept = append(kept, child)
+		}
+		children = kept
+	}
+
+	if len(children) == 0 {
+		w.b.WriteString("/>")
+		return
+	}
+	w.b.WriteString(">")
+	for _, child := range children {
+		if elementOnly && w.pretty {
+			w.b.WriteString("\n" + strings.Repeat(w.indent, depth+1))
+		}
+		w.node(child, depth+1)
+	}
+	if elementOnly && w.pretty {
+		w.b.WriteString("\n" + strings.Repeat(w.indent, depth))
+	}
+	w.b.WriteString("</" + n.qname() + ">")
+}
+
+// canonicalizeXML writes a document, or the element selected by options.ID, in Exclusive XML
+// Canonicalization 1.0 form: no declaration or DOCTYPE, empty elements as start-end tag pairs,
+// namespace declarations only where visibly used and sorted by prefix, attributes sorted by namespace
+// URI then local name, and canonical escaping
+func canonicalizeXML(doc *xnode, options c14nOptions) (string, error) {
+	inclusive := map[string]bool{}
+	for _, prefix := range options.InclusiveNamespaces {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		inclusive[prefix] = true
+	}
+	c := &xmlCanonicalizer{comments: options.WithComments, inclusive: inclusive}
+
+	if options.ID != "" {
+		for _, node := range xdescendants(doc, nil) {
+			if node.kind != xelementNode {
+				continue
+			}
+			for _, a := range node.attrs {
+				if (a.name.Local == "Id" || a.name.Local == "ID" || a.name.Local == "id") && a.value == options.ID {
+					c.element(node, map[string]string{})
+					return c.b.String(), nil
+				}
+			}
+		}
+		return "", errors.New(localize("no element has the ID %q", options.ID))
+	}
+
+	// Outside the root element, comments and processing instructions are separated from it by a line break
+	seenRoot := false
+	for _, child := range doc.children {
+		switch child.kind {
+		case xelementNode:
+			c.element(child, map[string]string{})
+			seenRoot = true
+		case xcommentNode, xpiNode:
+			if child.kind == xcommentNode && !c.comments {
+				continue
+			}
+			if seenRoot {
+				c.b.WriteString("\n")
+			}
+			c.node(child, nil)
+			if !seenRoot {
+				c.b.WriteString("\n")
+			}
+		}
+	}
+	return c.b.String(), nil
+}
+
+// xmlCanonicalizer writes canonical XML
+type xmlCanonicalizer struct {
+	b         strings.Builder
+	comments  bool
+	inclusive map[string]bool
+}
+
+// node writes a node of element content; rendered maps the prefixes declared by output ancestors
+func (c *xmlCanonicalizer) node(n *xnode, rendered map[string]string) {
+	switch n.kind {
+	case xtextNode:
+		c.b.WriteString(xmlTextEscaper.Replace(n.value))
+	case xcommentNode:
+		if c.comments {
+			c.b.WriteString("<!--" + n.value + "-->")
+		}
+	case xpiNode:
+		c.b.WriteString("<?" + n.name.Local)
+		if n.value != "" {
+			c.b.WriteString(" " + n.value)
+		}
+		c.b.WriteString("?>")
+	case xelementNode:
+		c.element(n, rendered)
+	}
+}
+
+// element declares the namespaces the element visibly uses, or lists as inclusive, that the output
+// ancestors have not declared with the same URI, then writes its sorted attributes and content
+func (c *xmlCanonicalizer) element(n *xnode, rendered map[string]string) {
+	scope := n.inScopeNamespaces()
+	used := map[string]string{n.prefix: n.name.Space}
+	for _, a := range n.attrs {
+		if a.prefix != "" && a.prefix != "xml" {
+			used[a.prefix] = a.name.Space
+		}
+	}
+	for prefix := range c.inclusive {
+		if uri, ok := scope[prefix]; ok {
+			used[prefix] = uri
+		}
+	}
+
+	declared := make(map[string]string, len(rendered)+len(used))
+	for prefix, uri := range rendered {
+		declared[prefix] = uri
+	}
+	prefixes := []string{}
+	for prefix, uri := range used {
+		previous, ok := rendered[prefix]
+		// The empty default namespace needs a declaration only to undo one an ancestor made
+		if (ok && previous == uri) || (!ok && prefix == "" && uri == "") {
+			continue
+		}
+		declared[prefix] = uri
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	c.b.WriteString("<" + n.qname())
+	for _, prefix := range prefixes {
+		name := "xmlns"
+		if prefix != "" {
+			name += ":" + prefix
+		}
+		c.b.WriteString(" " + name + `="` + c14nAttrEscaper.Replace(declared[prefix]) + `"`)
+	}
+	attrs := append([]*xnode{}, n.attrs...)
+	sort.SliceStable(attrs, func(i, j int) bool {
+		if attrs[i].name.Space != attrs[j].name.Space {
+			return attrs[i].name.Space < attrs[j].name.Space
+		}
+		return attrs[i].name.Local < attrs[j].name.Local
+	})
+	for _, a := range attrs {
+		c.b.WriteString(" " + a.qname() + `="` + c14nAttrEscaper.Replace(a.value) + `"`)
+	}
+	c.b.WriteString(">")
+	for _, child := range n.children {
+		c.node(child, declared)
+	}
+	c.b.WriteString("</" + n.qname() + ">")
+}
+
+// XSLT 1.0 transformations for transformXML, with the XPath 1.0 evaluator they need: XSLT adds
+// variables, current(), key() and generate-id() to XPath, which the xpath package cannot evaluate
+
+// xslNamespace identifies the XSLT instructions of a stylesheet
+const xslNamespace = "http://www.w3.org/1999/XSL/Transform"
+
+// xmlNamespace is bound to the xml prefix in every document
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// xsltMaxDepth bounds nested template calls, so infinitely recursive stylesheets fail instead of
+// exhausting the stack
+const xsltMaxDepth = 1000
+
+// xsltFailure carries an error out of the evaluator, which panics with it and is recovered by transformXML
+type xsltFailure struct {
+	message string
+}
+
+// xsltFail aborts the transformation with a localized error
+func xsltFail(format string, args ...interface{}) {
+	panic(xsltFailure{localize(format, args...)})
+}
+
+// recoverXSLT turns a failure raised with xsltFail into the error returned by the deferring function.
+// Other panics are runtime errors and propagate.
+func recoverXSLT(err *error) {
+	if r := recover(); r != nil {
+		failure, ok := r.(xsltFailure)
+		if !ok {
+			panic(r)
+		}
+		*err = errors.New(failure.message)
+	}
+}
+
+// xnodeKind is the type of a node in the XPath data model
+type xnodeKind int
+
+const (
+	xrootNode xnodeKind = iota
+	xelementNode
+	xattributeNode
+	xtextNode
+	xcommentNode
+	xpiNode
+)
+
+// xnode is a node of the XPath data model, used for the source document, the stylesheet and the
+// result tree. For elements and attributes name.Space holds the namespace URI; namespaces lists the
+// declarations made on an element.
+type xnode struct {
+	kind       xnodeKind
+	name       xml.Name
+	prefix     string
+	value      string
+	raw        bool // text written without escaping (disable-output-escaping)
+	parent     *xnode
+	children   []*xnode
+	attrs      []*xnode
+	namespaces map[string]string
+	order      int
+}
+
+// qname returns the name of an element or attribute as written, with its prefix
+func (n *xnode) qname() string {
+	if n.prefix != "" {
+		return n.prefix + ":" + n.name.Local
+	}
+	return n.name.Local
+}
+
+// stringValue returns the XPath string-value of the node
+func (n *xnode) stringValue() string {
+	if n.kind != xrootNode && n.kind != xelementNode {
+		return n.value
+	}
+	var b strings.Builder
+	var walk func(*xnode)
+	walk = func(node *xnode) {
+		for _, child := range node.children {
+			if child.kind == xtextNode {
+				b.WriteString(child.value)
+			} else if child.kind == xelementNode {
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// attr returns the value of an attribute without namespace
+func (n *xnode) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.name.Space == "" && a.name.Local == name {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// lookupNamespace resolves a prefix with the declarations in scope at the node
+func (n *xnode) lookupNamespace(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNamespace, true
+	}
+	for node := n; node != nil; node = node.parent {
+		if uri, ok := node.namespaces[prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", prefix == ""
+}
+
+// inScopeNamespaces merges the namespace declarations in scope at the node
+func (n *xnode) inScopeNamespaces() map[string]string {
+	scope := map[string]string{}
+	var chain []*xnode
+	for node := n; node != nil; node = node.parent {
+		chain = append(chain, node)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for prefix, uri := range chain[i].namespaces {
+			scope[prefix] = uri
+		}
+	}
+	return scope
+}
+
+// appendChild adds a child, merging adjacent text nodes written with the same escaping
+func (n *xnode) appendChild(child *xnode) {
+	if child.kind == xtextNode {
+		if child.value == "" {
+			return
+		}
+		if last := len(n.children) - 1; last >= 0 && n.children[last].kind == xtextNode && n.children[last].raw == child.raw {
+			n.children[last].value += child.value
+			return
+		}
+	}
+	child.parent = n
+	n.children = append(n.children, child)
+}
+
+// numberNodes assigns document order to a tree, attributes following their element
+func numberNodes(root *xnode, next int) int {
+	root.order = next
+	next++
+	for _, a := range root.attrs {
+		a.order = next
+		next++
+	}
+	for _, child := range root.children {
+		next = numberNodes(child, next)
+	}
+	return next
+}
+
+// parseXNodes parses an XML document into the XPath data model. Prefixes are resolved here from
+// the raw tokens, so that element and attribute nodes keep the prefix they were written with.
+func parseXNodes(source string) (*xnode, error) {
+	decoder := xml.NewDecoder(strings.NewReader(source))
+	root := &xnode{kind: xrootNode}
+	current := root
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			element := &xnode{kind: xelementNode, prefix: t.Name.Space, namespaces: map[string]string{}}
+			element.parent = current
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" {
+					element.namespaces[a.Name.Local] = a.Value
+				} else if a.Name.Space == "" && a.Name.Local == "xmlns" {
+					element.namespaces[""] = a.Value
+				}
+			}
+			uri, ok := element.lookupNamespace(element.prefix)
+			if !ok {
+				return nil, errors.New(localize("undeclared namespace prefix %q", element.prefix))
+			}
+			element.name = xml.Name{Space: uri, Local: t.Name.Local}
+
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					continue
+				}
+				attribute := &xnode{kind: xattributeNode, prefix: a.Name.Space, value: a.Value, parent: element}
+				attribute.name.Local = a.Name.Local
+				if a.Name.Space != "" {
+					if attribute.name.Space, ok = element.lookupNamespace(a.Name.Space); !ok {
+						return nil, errors.New(localize("undeclared namespace prefix %q", a.Name.Space))
+					}
+				}
+				element.attrs = append(element.attrs, attribute)
+			}
+			if current == root {
+				for _, child := range root.children {
+					if child.kind == xelementNode {
+						return nil, errors.New(localize("the document has more than one root element"))
+					}
+				}
+			}
+			current.appendChild(element)
+			current = element
+
+		case xml.EndElement:
+			name := t.Name.Local
+			if t.Name.Space != "" {
+				name = t.Name.Space + ":" + name
+			}
+			if current.kind != xelementNode || current.qname() != name {
+				return nil, errors.New(localize("unexpected end tag </%s>", name))
+			}
+			current = current.parent
+
+		case xml.CharData:
+			if current == root {
+				if strings.TrimSpace(string(t)) != "" {
+					return nil, errors.New(localize("text outside the root element"))
+				}
+				continue
+			}
+			current.appendChild(&xnode{kind: xtextNode, value: string(t)})
+
+		case xml.Comment:
+			current.appendChild(&xnode{kind: xcommentNode, value: string(t)})
+
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				pi := &xnode{kind: xpiNode, value: strings.TrimSpace(string(t.Inst))}
+				pi.name.Local = t.Target
+				current.appendChild(pi)
+			}
+		}
+	}
+
+	if current != root {
+		return nil, errors.New(localize("unclosed element <%s>", current.qname()))
+	}
+	hasElement := false
+	for _, child := range root.children {
+		hasElement = hasElement || child.kind == xelementNode
+	}
+	if !hasElement {
+		return nil, errors.New(localize("the document has no root element"))
+	}
+	numberNodes(root, 0)
+	return root, nil
+}
+
+// xnodeSet is an XPath node-set, kept in document order without duplicates
+type xnodeSet []*xnode
+
+// sortNodes puts nodes in document order and removes duplicates
+func sortNodes(nodes []*xnode) xnodeSet {
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].order < nodes[j].order })
+	unique := nodes[:0]
+	for i, node := range nodes {
+		if i == 0 || node != nodes[i-1] {
+			unique = append(unique, node)
+		}
+	}
+	return xnodeSet(unique)
+}
+
+// xscope is a variable binding, chained to the bindings visible before it
+type xscope struct {
+	name   string
+	value  interface{}
+	parent *xscope
+}
+
+// lookup finds the innermost binding of a variable
+func (s *xscope) lookup(name string) (interface{}, bool) {
+	for scope := s; scope != nil; scope = scope.parent {
+		if scope.name == name {
+			return scope.value, true
+		}
+	}
+	return nil, false
+}
+
+// xcontext is the dynamic context of an XPath evaluation
+type xcontext struct {
+	node     *xnode
+	position int
+	size     int
+	current  *xnode
+	vars     *xscope
+	proc     *xsltProcessor
+}
+
+// at returns a context for evaluating relative to another node, keeping variables and current()
+func (c *xcontext) at(node *xnode, position, size int) *xcontext {
+	next := *c
+	next.node, next.position, next.size = node, position, size
+	return &next
+}
+
+// xexpr is a compiled XPath expression. Values are xnodeSet, string, float64 or bool.
+type xexpr interface {
+	eval(c *xcontext) interface{}
+}
+
+// XPath value conversions
+
+func xstring(v interface{}) string {
+	switch v := v.(type) {
+	case xnodeSet:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].stringValue()
+	case string:
+		return v
+	case float64:
+		return xnumberString(v)
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	return ""
+}
+
+func xnumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return xparseNumber(xstring(v))
+}
+
+func xboolean(v interface{}) bool {
+	switch v := v.(type) {
+	case xnodeSet:
+		return len(v) > 0
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case bool:
+		return v
+	}
+	return false
+}
+
+// xnodes converts a value that must be a node-set
+func xnodes(v interface{}) xnodeSet {
+	nodes, ok := v.(xnodeSet)
+	if !ok {
+		xsltFail("expression does not evaluate to a node-set")
+	}
+	return nodes
+}
+
+// xnumberPattern is the XPath Number syntax accepted by number()
+var xnumberPattern = regexp.MustCompile(`^-?(\d+(\.\d*)?|\.\d+)$`)
+
+func xparseNumber(s string) float64 {
+	s = strings.Trim(s, " \t\r\n")
+	if !xnumberPattern.MatchString(s) {
+		return math.NaN()
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+// xnumberString formats a number the XPath way: integers without a decimal point, never an exponent
+func xnumberString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// XPath abstract syntax
+
+type xliteral string
+
+func (e xliteral) eval(c *xcontext) interface{} { return string(e) }
+
+type xnumberLiteral float64
+
+func (e xnumberLiteral) eval(c *xcontext) interface{} { return float64(e) }
+
+type xvariable string
+
+func (e xvariable) eval(c *xcontext) interface{} {
+	if value, ok := c.vars.lookup(string(e)); ok {
+		return value
+	}
+	if value, ok := c.proc.global(string(e)); ok {
+		return value
+	}
+	xsltFail("undefined variable $%s", string(e))
+	return nil
+}
+
+type xnegate struct{ operand xexpr }
+
+func (e xnegate) eval(c *xcontext) interface{} { return -xnumber(e.operand.eval(c)) }
+
+type xunion struct{ left, right xexpr }
+
+func (e xunion) eval(c *xcontext) interface{} {
+	left := xnodes(e.left.eval(c))
+	right := xnodes(e.right.eval(c))
+	return sortNodes(append(append([]*xnode{}, left...), right...))
+}
+
+type xbinary struct {
+	op          string
+	left, right xexpr
+}
+
+func (e xbinary) eval(c *xcontext) interface{} {
+	switch e.op {
+	case "or":
+		return xboolean(e.left.eval(c)) || xboolean(e.right.eval(c))
+	case "and":
+		return xboolean(e.left.eval(c)) && xboolean(e.right.eval(c))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return xcompare(e.op, e.left.eval(c), e.right.eval(c))
+	}
+
+	left, right := xnumber(e.left.eval(c)), xnumber(e.right.eval(c))
+	switch e.op {
+	case "+":
+		return left + right
+	case "-":
+		return left - right
+	case "*":
+		return left * right
+	case "div":
+		return left / right
+	}
+	// mod truncates like the remainder of Java and ECMAScript
+	return math.Mod(left, right)
+}
+
+// xcompare implements the XPath comparisons, which hold for a node-set if they hold for any of its nodes
+func xcompare(op string, left, right interface{}) bool {
+	leftNodes, leftIsSet := left.(xnodeSet)
+	rightNodes, rightIsSet := right.(xnodeSet)
+
+	if leftIsSet || rightIsSet {
+		// Compare against a boolean as a whole; otherwise node by node
+		if _, ok := left.(bool); ok {
+			return xcompareAtoms(op, left, xboolean(right))
+		}
+		if _, ok := right.(bool); ok {
+			return xcompareAtoms(op, xboolean(left), right)
+		}
+		if leftIsSet && rightIsSet {
+			for _, l := range leftNodes {
+				for _, r := range rightNodes {
+					if xcompareAtoms(op, l.stringValue(), r.stringValue()) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		if leftIsSet {
+			for _, l := range leftNodes {
+				if xcompareAtoms(op, xatomLike(l.stringValue(), right), right) {
+					return true
+				}
+			}
+			return false
+		}
+		for _, r := range rightNodes {
+			if xcompareAtoms(op, left, xatomLike(r.stringValue(), left)) {
+				return true
+			}
+		}
+		return false
+	}
+	return xcompareAtoms(op, left, right)
+}
+
+// xatomLike converts the string-value of a node to the type of the value it is compared with
+func xatomLike(s string, other interface{}) interface{} {
+	if _, ok := other.(float64); ok {
+		return xparseNumber(s)
+	}
+	return s
+}
+
+// xcompareAtoms compares two values that are not node-sets
+func xcompareAtoms(op string, left, right interface{}) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, leftBool := left.(bool)
+		_, rightBool := right.(bool)
+		_, leftNumber := left.(float64)
+		_, rightNumber := right.(float64)
+		switch {
+		case leftBool || rightBool:
+			equal = xboolean(left) == xboolean(right)
+		case leftNumber || rightNumber:
+			equal = xnumber(left) == xnumber(right)
+		default:
+			equal = xstring(left) == xstring(right)
+		}
+		return equal == (op == "=")
+	}
+
+	l, r := xnumber(left), xnumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	}
+	return l >= r
+}
+
+// xfilter applies predicates to the node-set of a primary expression
+type xfilter struct {
+	primary    xexpr
+	predicates []xexpr
+}
+
+func (e xfilter) eval(c *xcontext) interface{} {
+	nodes := xnodes(e.primary.eval(c))
+	for _, predicate := range e.predicates {
+		nodes = xapplyPredicate(c, nodes, predicate)
+	}
+	return nodes
+}
+
+// xapplyPredicate keeps the nodes for which the predicate holds; numbers select a position
+func xapplyPredicate(c *xcontext, nodes []*xnode, predicate xexpr) []*xnode {
+	var kept []*xnode
+	for i, node := range nodes {
+		value := predicate.eval(c.at(node, i+1, len(nodes)))
+		if n, ok := value.(float64); ok {
+			if n == float64(i+1) {
+				kept = append(kept, node)
+			}
+		} else if xboolean(value) {
+			kept = append(kept, node)
+		}
+	}
+	return kept
+}
+
+// xtest kinds
+const (
+	xtestName = iota
+	xtestAnyName
+	xtestNamespace
+	xtestNode
+	xtestText
+	xtestComment
+	xtestPI
+)
+
+// xtest is the node test of a step
+type xtest struct {
+	kind  int
+	space string
+	local string
+}
+
+// matches reports whether the test accepts the node on an axis whose principal node type is principal
+func (t xtest) matches(n *xnode, principal xnodeKind) bool {
+	switch t.kind {
+	case xtestNode:
+		return true
+	case xtestText:
+		return n.kind == xtextNode
+	case xtestComment:
+		return n.kind == xcommentNode
+	case xtestPI:
+		return n.kind == xpiNode && (t.local == "" || n.name.Local == t.local)
+	case xtestAnyName:
+		return n.kind == principal
+	case xtestNamespace:
+		return n.kind == principal && n.name.Space == t.space
+	}
+	return n.kind == principal && n.name.Local == t.local && n.name.Space == t.space
+}
+
+// priority returns the default priority of a pattern made of this test alone
+func (t xtest) priority() float64 {
+	switch t.kind {
+	case xtestName:
+		return 0
+	case xtestPI:
+		if t.local != "" {
+			return 0
+		}
+	case xtestNamespace:
+		return -0.25
+	}
+	return -0.5
+}
+
+// xstep is a location step
+type xstep struct {
+	axis       string
+	test       xtest
+	predicates []xexpr
+}
+
+// xlocationPath is a location path, starting from the root, the context node or a filter expression
+type xlocationPath struct {
+	start    xexpr
+	absolute bool
+	steps    []*xstep
+}
+
+func (e *xlocationPath) eval(c *xcontext) interface{} {
+	var nodes xnodeSet
+	switch {
+	case e.start != nil:
+		nodes = xnodes(e.start.eval(c))
+	case e.absolute:
+		root := c.node
+		for root.parent != nil {
+			root = root.parent
+		}
+		nodes = xnodeSet{root}
+	default:
+		nodes = xnodeSet{c.node}
+	}
+
+	for _, step := range e.steps {
+		var selected []*xnode
+		for _, node := range nodes {
+			principal := xelementNode
+			if step.axis == "attribute" {
+				principal = xattributeNode
+			}
+			var candidates []*xnode
+			for _, candidate := range xaxis(step.axis, node) {
+				if step.test.matches(candidate, principal) {
+					candidates = append(candidates, candidate)
+				}
+			}
+			for _, predicate := range step.predicates {
+				candidates = xapplyPredicate(c, candidates, predicate)
+			}
+			selected = append(selected, candidates...)
+		}
+		nodes = sortNodes(selected)
+	}
+	return nodes
+}
+
+// xaxis lists the nodes of an axis, reverse axes nearest first as proximity positions count them
+func xaxis(axis string, n *xnode) []*xnode {
+	switch axis {
+	case "child":
+		return n.children
+	case "attribute":
+		return n.attrs
+	case "self":
+		return []*xnode{n}
+	case "parent":
+		if n.parent != nil {
+			return []*xnode{n.parent}
+		}
+		return nil
+	case "descendant", "descendant-or-self":
+		var nodes []*xnode
+		if axis == "descendant-or-self" {
+			nodes = append(nodes, n)
+		}
+		return xdescendants(n, nodes)
+	case "ancestor", "ancestor-or-self":
+		var nodes []*xnode
+		if axis == "ancestor-or-self" {
+			nodes = append(nodes, n)
+		}
+		for node := n.parent; node != nil; node = node.parent {
+			nodes = append(nodes, node)
+		}
+		return nodes
+	case "following-sibling", "preceding-sibling":
+		if n.parent == nil || n.kind == xattributeNode {
+			return nil
+		}
+		siblings := n.parent.children
+		index := 0
+		for i, sibling := range siblings {
+			if sibling == n {
+				index = i
+			}
+		}
+		if axis == "following-sibling" {
+			return siblings[index+1:]
+		}
+		var nodes []*xnode
+		for i := index - 1; i >= 0; i-- {
+			nodes = append(nodes, siblings[i])
+		}
+		return nodes
+	case "following":
+		var nodes []*xnode
+		start := n
+		if n.kind == xattributeNode {
+			nodes = xdescendants(n.parent, nodes)
+			start = n.parent
+		}
+		for node := start; node != nil && node.parent != nil; node = node.parent {
+			for _, sibling := range xaxis("following-sibling", node) {
+				nodes = append(nodes, sibling)
+				nodes = xdescendants(sibling, nodes)
+			}
+		}
+		return nodes
+	case "preceding":
+		var nodes []*xnode
+		start := n
+		if n.kind == xattributeNode {
+			start = n.parent
+		}
+		for node := start; node != nil && node.parent != nil; node = node.parent {
+			for _, sibling := range xaxis("preceding-sibling", node) {
+				nodes = append(nodes, sibling)
+				nodes = xdescendants(sibling, nodes)
+			}
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].order > nodes[j].order })
+		return nodes
+	}
+	// The namespace axis is not modelled
+	return nil
+}
+
+// xdescendants appends the descendants of n in document order
+func xdescendants(n *xnode, nodes []*xnode) []*xnode {
+	for _, child := range n.children {
+		nodes = append(nodes, child)
+		nodes = xdescendants(child, nodes)
+	}
+	return nodes
+}
+
+// xtoken is a lexical token of an XPath expression
+type xtoken struct {
+	kind string // number, literal, variable, name, operator or the punctuation itself
+	text string
+	pos  int
+}
+
+// isNameStart and isNameChar implement the NCName character classes
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// tokenizeXPath splits an expression into tokens. As the XPath grammar requires, * and the names
+// and, or, div and mod are operators only where an operator can follow the previous token.
+func tokenizeXPath(expr string) ([]xtoken, error) {
+	runes := []rune(expr)
+	var tokens []xtoken
+
+	readName := func(i int) int {
+		for i < len(runes) && isNameChar(runes[i]) {
+			i++
+		}
+		return i
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			i++
+			continue
+		}
+
+		operatorAllowed := false
+		if n := len(tokens); n > 0 {
+			switch tokens[n-1].kind {
+			case "@", "::", "(", "[", ",", "operator":
+			default:
+				operatorAllowed = true
+			}
+		}
+
+		start := i
+		switch {
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New(localize("unterminated string"))
+			}
+			tokens = append(tokens, xtoken{"literal", string(runes[i+1 : end]), start})
+			i = end + 1
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, xtoken{"number", string(runes[start:i]), start})
+
+		case r == '.':
+			if i+1 < len(runes) && runes[i+1] == '.' {
+				tokens = append(tokens, xtoken{"..", "..", start})
+				i += 2
+			} else {
+				tokens = append(tokens, xtoken{".", ".", start})
+				i++
+			}
+
+		case r == '$':
+			i = readName(i + 1)
+			if i < len(runes)-1 && runes[i] == ':' && isNameStart(runes[i+1]) {
+				i = readName(i + 1)
+			}
+			if i == start+1 {
+				return nil, errors.New(localize("Invalid XPath at position %d: %s", start, localize("variable name expected")))
+			}
+			tokens = append(tokens, xtoken{"variable", string(runes[start+1 : i]), start})
+
+		case r == '*':
+			if operatorAllowed {
+				tokens = append(tokens, xtoken{"operator", "*", start})
+			} else {
+				tokens = append(tokens, xtoken{"name", "*", start})
+			}
+			i++
+
+		case isNameStart(r):
+			i = readName(i)
+			if i < len(runes)-1 && runes[i] == ':' && runes[i+1] != ':' {
+				if runes[i+1] == '*' {
+					i += 2
+				} else if isNameStart(runes[i+1]) {
+					i = readName(i + 1)
+				}
+			}
+			name := string(runes[start:i])
+			if operatorAllowed && (name == "and" || name == "or" || name == "div" || name == "mod") {
+				tokens = append(tokens, xtoken{"operator", name, start})
+			} else {
+				tokens = append(tokens, xtoken{"name", name, start})
+			}
+
+		default:
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch {
+			case two == "//" || two == "!=" || two == "<=" || two == ">=":
+				tokens = append(tokens, xtoken{"operator", two, start})
+				i += 2
+			case two == "::":
+				tokens = append(tokens, xtoken{"::", "::", start})
+				i += 2
+			case strings.ContainsRune("/|+-=<>", r):
+				tokens = append(tokens, xtoken{"operator", string(r), start})
+				i++
+			case strings.ContainsRune("()[]@,", r):
+				tokens = append(tokens, xtoken{string(r), string(r), start})
+				i++
+			default:
+				return nil, errors.New(localize("Invalid XPath at position %d: %s", start, localize("unexpected %q", string(r))))
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// xpathParser builds expressions and patterns from tokens. Prefixes resolve with the namespace
+// declarations in scope where the expression appears.
+type xpathParser struct {
+	tokens     []xtoken
+	pos        int
+	namespaces map[string]string
+}
+
+// xcall is a function call, its prefixed name resolved to a namespace URI
+type xcall struct {
+	space      string
+	local      string
+	args       []xexpr
+	namespaces map[string]string
+}
+
+// compileXPath parses an expression in the scope of a stylesheet element
+func compileXPath(expr string, namespaces map[string]string) (parsed xexpr, err error) {
+	p, err := newXPathParser(expr, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	defer recoverXSLT(&err)
+	parsed = p.expr()
+	if p.pos < len(p.tokens) {
+		p.fail(localize("unexpected %q", p.tokens[p.pos].text))
+	}
+	return parsed, nil
+}
+
+// newXPathParser tokenizes an expression for parsing
+func newXPathParser(expr string, namespaces map[string]string) (*xpathParser, error) {
+	tokens, err := tokenizeXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New(localize("Invalid XPath at position %d: %s", 0, localize("expression expected")))
+	}
+	return &xpathParser{tokens: tokens, namespaces: namespaces}, nil
+}
+
+// fail reports a syntax error at the current token, recovered as the error of compileXPath
+func (p *xpathParser) fail(message string) {
+	position := 0
+	if p.pos < len(p.tokens) {
+		position = p.tokens[p.pos].pos
+	} else if len(p.tokens) > 0 {
+		last := p.tokens[len(p.tokens)-1]
+		position = last.pos + len([]rune(last.text))
+	}
+	panic(xsltFailure{localize("Invalid XPath at position %d: %s", position, message)})
+}
+
+// peek returns the kind and text of the token at offset from the current one
+func (p *xpathParser) peek(offset int) (string, string) {
+	if p.pos+offset < len(p.tokens) {
+		token := p.tokens[p.pos+offset]
+		return token.kind, token.text
+	}
+	return "", ""
+}
+
+// accept consumes the next token if it is the given punctuation or operator
+func (p *xpathParser) accept(text string) bool {
+	kind, value := p.peek(0)
+	if (kind == "operator" || kind == text) && value == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the given punctuation or fails
+func (p *xpathParser) expect(text string) {
+	if !p.accept(text) {
+		p.fail(localize("%q expected", text))
+	}
+}
+
+// resolveName splits a QName of the expression and resolves its prefix
+func (p *xpathParser) resolveName(qname string) (string, string) {
+	prefix, local, found := strings.Cut(qname, ":")
+	if !found {
+		return "", qname
+	}
+	uri, ok := p.namespaces[prefix]
+	if !ok && prefix == "xml" {
+		uri, ok = xmlNamespace, true
+	}
+	if !ok {
+		p.fail(localize("undeclared namespace prefix %q", prefix))
+	}
+	return uri, local
+}
+
+// binary parses a left-associative chain of operators over operands parsed by next
+func (p *xpathParser) binary(next func() xexpr, operators ...string) xexpr {
+	left := next()
+	for {
+		kind, text := p.peek(0)
+		matched := false
+		for _, op := range operators {
+			if kind == "operator" && text == op {
+				matched = true
+			}
+		}
+		if !matched {
+			return left
+		}
+		p.pos++
+		left = xbinary{op: text, left: left, right: next()}
+	}
+}
+
+func (p *xpathParser) expr() xexpr {
+	return p.binary(p.andExpr, "or")
+}
+
+func (p *xpathParser) andExpr() xexpr {
+	return p.binary(p.equalityExpr, "and")
+}
+
+func (p *xpathParser) equalityExpr() xexpr {
+	return p.binary(p.relationalExpr, "=", "!=")
+}
+
+func (p *xpathParser) relationalExpr() xexpr {
+	return p.binary(p.additiveExpr, "<", "<=", ">", ">=")
+}
+
+func (p *xpathParser) additiveExpr() xexpr {
+	return p.binary(p.multiplicativeExpr, "+", "-")
+}
+
+func (p *xpathParser) multiplicativeExpr() xexpr {
+	return p.binary(p.unaryExpr, "*", "div", "mod")
+}
+
+func (p *xpathParser) unaryExpr() xexpr {
+	if p.accept("-") {
+		return xnegate{p.unaryExpr()}
+	}
+	left := p.pathExpr()
+	for p.accept("|") {
+		left = xunion{left, p.pathExpr()}
+	}
+	return left
+}
+
+// isNodeType reports whether a name followed by ( is a node test rather than a function
+func isNodeType(name string) bool {
+	return name == "node" || name == "text" || name == "comment" || name == "processing-instruction"
+}
+
+// pathExpr parses a location path, or a filter expression optionally followed by a relative path
+func (p *xpathParser) pathExpr() xexpr {
+	kind, text := p.peek(0)
+	next, _ := p.peek(1)
+	isFilter := kind == "variable" || kind == "literal" || kind == "number" || kind == "(" ||
+		(kind == "name" && next == "(" && !isNodeType(text))
+	if !isFilter {
+		return p.locationPath()
+	}
+
+	var primary xexpr = p.primaryExpr()
+	var predicates []xexpr
+	for p.accept("[") {
+		predicates = append(predicates, p.expr())
+		p.expect("]")
+	}
+	if predicates != nil {
+		primary = xfilter{primary, predicates}
+	}
+
+	path := &xlocationPath{start: primary}
+	for {
+		if p.accept("//") {
+			path.steps = append(path.steps, descendantStep())
+		} else if !p.accept("/") {
+			break
+		}
+		path.steps = append(path.steps, p.step())
+	}
+	if path.steps == nil {
+		return primary
+	}
+	return path
+}
+
+// descendantStep is the step /descendant-or-self::node()/ that // abbreviates
+func descendantStep() *xstep {
+	return &xstep{axis: "descendant-or-self", test: xtest{kind: xtestNode}}
+}
+
+// primaryExpr parses a variable reference, a parenthesized expression, a literal, a number or a function call
+func (p *xpathParser) primaryExpr() xexpr {
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token.kind {
+	case "variable":
+		return xvariable(token.text)
+	case "literal":
+		return xliteral(token.text)
+	case "number":
+		f, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			p.pos--
+			p.fail(localize("invalid number %q", token.text))
+		}
+		return xnumberLiteral(f)
+	case "(":
+		inner := p.expr()
+		p.expect(")")
+		return inner
+	}
+
+	call := &xcall{namespaces: p.namespaces}
+	call.space, call.local = p.resolveName(token.text)
+	p.expect("(")
+	if !p.accept(")") {
+		for {
+			call.args = append(call.args, p.expr())
+			if p.accept(")") {
+				break
+			}
+			p.expect(",")
+		}
+	}
+	return call
+}
+
+// canStartStep reports whether the next token begins a location step
+func (p *xpathParser) canStartStep() bool {
+	kind, _ := p.peek(0)
+	return kind == "name" || kind == "@" || kind == "." || kind == ".."
+}
+
+// locationPath parses an absolute or relative location path
+func (p *xpathParser) locationPath() xexpr {
+	path := &xlocationPath{}
+	if p.accept("/") {
+		path.absolute = true
+		if !p.canStartStep() {
+			return path
+		}
+	} else if p.accept("//") {
+		path.absolute = true
+		path.steps = append(path.steps, descendantStep())
+	}
+
+	path.steps = append(path.steps, p.step())
+	for {
+		if p.accept("//") {
+			path.steps = append(path.steps, descendantStep())
+		} else if !p.accept("/") {
+			return path
+		}
+		path.steps = append(path.steps, p.step())
+	}
+}
+
+// xaxes are the axis names of XPath 1.0
+var xaxes = map[string]bool{
+	"ancestor": true, "ancestor-or-self": true, "attribute": true, "child": true, "descendant": true,
+	"descendant-or-self": true, "following": true, "following-sibling": true, "namespace": true,
+	"parent": true, "preceding": true, "preceding-sibling": true, "self": true,
+}
+
+// step parses a location step with its predicates
+func (p *xpathParser) step() *xstep {
+	if p.accept(".") {
+		return &xstep{axis: "self", test: xtest{kind: xtestNode}}
+	}
+	if p.accept("..") {
+		return &xstep{axis: "parent", test: xtest{kind: xtestNode}}
+	}
+
+	s := &xstep{axis: "child"}
+	if p.accept("@") {
+		s.axis = "attribute"
+	} else if kind, text := p.peek(0); kind == "name" {
+		if next, _ := p.peek(1); next == "::" {
+			if !xaxes[text] {
+				p.fail(localize("unknown axis %q", text))
+			}
+			s.axis = text
+			p.pos += 2
+		}
+	}
+	s.test = p.nodeTest()
+	for p.accept("[") {
+		s.predicates = append(s.predicates, p.expr())
+		p.expect("]")
+	}
+	return s
+}
+
+// nodeTest parses a name test or a node type test
+func (p *xpathParser) nodeTest() xtest {
+	kind, text := p.peek(0)
+	if kind == "" {
+		p.fail(localize("expression expected"))
+	}
+	if kind != "name" {
+		p.fail(localize("unexpected %q", text))
+	}
+	p.pos++
+
+	if next, _ := p.peek(0); next == "(" && isNodeType(text) {
+		p.pos++
+		test := xtest{kind: xtestNode}
+		switch text {
+		case "text":
+			test.kind = xtestText
+		case "comment":
+			test.kind = xtestComment
+		case "processing-instruction":
+			test.kind = xtestPI
+			if kind, literal := p.peek(0); kind == "literal" {
+				test.local = literal
+				p.pos++
+			}
+		}
+		p.expect(")")
+		return test
+	}
+
+	if text == "*" {
+		return xtest{kind: xtestAnyName}
+	}
+	if prefix, found := strings.CutSuffix(text, ":*"); found {
+		space, _ := p.resolveName(prefix + ":x")
+		return xtest{kind: xtestNamespace, space: space}
+	}
+	space, local := p.resolveName(text)
+	return xtest{kind: xtestName, space: space, local: local}
+}
+
+// xpattern is one alternative of a match pattern: steps matched from the node upwards, each joined
+// to the previous one by / or //, below an optional root or id()/key() anchor
+type xpattern struct {
+	absolute bool
+	anchor   xexpr
+	steps    []*xstep
+	deep     []bool // deep[i]: steps[i] joins what precedes it with //
+}
+
+// priority returns the default priority of the pattern in template rules
+func (pattern *xpattern) priority() float64 {
+	if pattern.anchor != nil || pattern.absolute || len(pattern.steps) != 1 || pattern.deep[0] || pattern.steps[0].predicates != nil {
+		return 0.5
+	}
+	return pattern.steps[0].test.priority()
+}
+
+// compilePattern parses a match pattern into its alternatives
+func compilePattern(source string, namespaces map[string]string) (patterns []*xpattern, err error) {
+	p, err := newXPathParser(source, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	defer recoverXSLT(&err)
+
+	for {
+		patterns = append(patterns, p.pattern())
+		if !p.accept("|") {
+			break
+		}
+	}
+	if p.pos < len(p.tokens) {
+		p.fail(localize("unexpected %q", p.tokens[p.pos].text))
+	}
+	return patterns, nil
+}
+
+// pattern parses a location path pattern
+func (p *xpathParser) pattern() *xpattern {
+	pattern := &xpattern{}
+	deep := false
+
+	kind, text := p.peek(0)
+	next, _ := p.peek(1)
+	switch {
+	case kind == "name" && next == "(" && (text == "id" || text == "key"):
+		pattern.anchor = p.primaryExpr()
+		if p.accept("//") {
+			deep = true
+		} else if !p.accept("/") {
+			return pattern
+		}
+	case p.accept("/"):
+		pattern.absolute = true
+		if !p.canStartStep() {
+			return pattern
+		}
+	case p.accept("//"):
+		deep = true
+	}
+
+	for {
+		s := p.step()
+		if s.axis != "child" && s.axis != "attribute" {
+			p.fail(localize("patterns only use the child and attribute axes"))
+		}
+		pattern.steps = append(pattern.steps, s)
+		pattern.deep = append(pattern.deep, deep)
+		if p.accept("//") {
+			deep = true
+		} else if p.accept("/") {
+			deep = false
+		} else {
+			return pattern
+		}
+	}
+}
+
+// matches reports whether the pattern matches the node
+func (pattern *xpattern) matches(c *xcontext, n *xnode) bool {
+	if len(pattern.steps) == 0 {
+		if pattern.absolute {
+			return n.kind == xrootNode
+		}
+		return pattern.inAnchor(c, n)
+	}
+	return pattern.matchStep(c, n, len(pattern.steps)-1)
+}
+
+// matchStep matches steps[i] against the node, then what precedes the step against its parent or ancestors
+func (pattern *xpattern) matchStep(c *xcontext, n *xnode, i int) bool {
+	s := pattern.steps[i]
+	principal := xelementNode
+	if s.axis == "attribute" {
+		principal = xattributeNode
+	}
+	if n.parent == nil || (n.kind == xattributeNode) != (s.axis == "attribute") || !s.test.matches(n, principal) {
+		return false
+	}
+
+	if s.predicates != nil {
+		siblings := n.parent.children
+		if s.axis == "attribute" {
+			siblings = n.parent.attrs
+		}
+		var candidates []*xnode
+		for _, sibling := range siblings {
+			if s.test.matches(sibling, principal) {
+				candidates = append(candidates, sibling)
+			}
+		}
+		for _, predicate := range s.predicates {
+			candidates = xapplyPredicate(c, candidates, predicate)
+		}
+		found := false
+		for _, candidate := range candidates {
+			found = found || candidate == n
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for ancestor := n.parent; ancestor != nil; ancestor = ancestor.parent {
+		var matched bool
+		switch {
+		case i > 0:
+			matched = pattern.matchStep(c, ancestor, i-1)
+		case pattern.absolute:
+			matched = ancestor.kind == xrootNode
+		case pattern.anchor != nil:
+			matched = pattern.inAnchor(c, ancestor)
+		default:
+			return true
+		}
+		if matched || !pattern.deep[i] {
+			return matched
+		}
+	}
+	return false
+}
+
+// inAnchor reports whether the node is selected by the id() or key() call anchoring the pattern
+func (pattern *xpattern) inAnchor(c *xcontext, n *xnode) bool {
+	if pattern.anchor == nil {
+		return false
+	}
+	for _, node := range xnodes(pattern.anchor.eval(c.at(n, 1, 1))) {
+		if node == n {
+			return true
+		}
+	}
+	return false
+}
+
+// XPath and XSLT function library
+
+// xsltFunctions lists the functions function-available() reports, by expanded name
+var xsltFunctions = map[string]bool{
+	"boolean": true, "ceiling": true, "concat": true, "contains": true, "count": true, "current": true,
+	"element-available": true, "false": true, "floor": true, "format-number": true, "function-available": true,
+	"generate-id": true, "id": true, "key": true, "lang": true, "last": true, "local-name": true, "name": true,
+	"namespace-uri": true, "normalize-space": true, "not": true, "number": true, "position": true, "round": true,
+	"starts-with": true, "string": true, "string-length": true, "substring": true, "substring-after": true,
+	"substring-before": true, "sum": true, "system-property": true, "translate": true, "true": true,
+	"unparsed-entity-uri": true, "{http://exslt.org/common}object-type": true,
+	"{http://exslt.org/common}node-set":        true,
+	"{urn:schemas-microsoft-com:xslt}node-set": true,
+}
+
+// expandedName formats a namespace URI and local name as {uri}local, or local without namespace
+func expandedName(space, local string) string {
+	if space == "" {
+		return local
+	}
+	return "{" + space + "}" + local
+}
+
+// resolveQName resolves a QName given as a string argument, such as system-property('xsl:version')
+func (e *xcall) resolveQName(qname string) (string, string) {
+	prefix, local, found := strings.Cut(qname, ":")
+	if !found {
+		return "", qname
+	}
+	uri, ok := e.namespaces[prefix]
+	if !ok {
+		xsltFail("undeclared namespace prefix %q", prefix)
+	}
+	return uri, local
+}
+
+// arity checks the number of arguments of a call
+func (e *xcall) arity(min, max int) {
+	if len(e.args) < min || (max >= 0 && len(e.args) > max) {
+		xsltFail("wrong number of arguments for %s()", e.local)
+	}
+}
+
+// nodeArg returns the node a node-set argument designates, the context node when omitted
+func (e *xcall) nodeArg(c *xcontext) *xnode {
+	e.arity(0, 1)
+	if len(e.args) == 0 {
+		return c.node
+	}
+	nodes := xnodes(e.args[0].eval(c))
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+// stringArg evaluates an argument as a string, the string-value of the context node when omitted
+func (e *xcall) stringArg(c *xcontext, i int) string {
+	if i >= len(e.args) {
+		return c.node.stringValue()
+	}
+	return xstring(e.args[i].eval(c))
+}
+
+func (e *xcall) eval(c *xcontext) interface{} {
+	switch e.space {
+	case "http://exslt.org/common", "urn:schemas-microsoft-com:xslt":
+		switch e.local {
+		case "node-set":
+			e.arity(1, 1)
+			value := e.args[0].eval(c)
+			if nodes, ok := value.(xnodeSet); ok {
+				return nodes
+			}
+			root := &xnode{kind: xrootNode}
+			root.appendChild(&xnode{kind: xtextNode, value: xstring(value)})
+			c.proc.nextOrder = numberNodes(root, c.proc.nextOrder)
+			return xnodeSet{root}
+		case "object-type":
+			e.arity(1, 1)
+			switch e.args[0].eval(c).(type) {
+			case xnodeSet:
+				return "node-set"
+			case float64:
+				return "number"
+			case bool:
+				return "boolean"
+			}
+			return "string"
+		}
+	case "":
+		return e.core(c)
+	}
+	xsltFail("unknown function %s()", expandedName(e.space, e.local))
+	return nil
+}
+
+// core evaluates the functions of XPath 1.0 and those XSLT 1.0 adds
+func (e *xcall) core(c *xcontext) interface{} {
+	switch e.local {
+	case "last":
+		e.arity(0, 0)
+		return float64(c.size)
+	case "position":
+		e.arity(0, 0)
+		return float64(c.position)
+	case "count":
+		e.arity(1, 1)
+		return float64(len(xnodes(e.args[0].eval(c))))
+	case "id":
+		e.arity(1, 1)
+		return xidNodes(c.node, e.args[0].eval(c))
+	case "local-name", "name", "namespace-uri":
+		n := e.nodeArg(c)
+		if n == nil {
+			return ""
+		}
+		switch {
+		case e.local == "namespace-uri":
+			return n.name.Space
+		case e.local == "name" && (n.kind == xelementNode || n.kind == xattributeNode):
+			return n.qname()
+		}
+		return n.name.Local
+
+	case "string":
+		e.arity(0, 1)
+		return e.stringArg(c, 0)
+	case "concat":
+		e.arity(2, -1)
+		var b strings.Builder
+		for i := range e.args {
+			b.WriteString(e.stringArg(c, i))
+		}
+		return b.String()
+	case "starts-with", "contains", "substring-before", "substring-after":
+		e.arity(2, 2)
+		s, sub := e.stringArg(c, 0), e.stringArg(c, 1)
+		switch e.local {
+		case "starts-with":
+			return strings.HasPrefix(s, sub)
+		case "contains":
+			return strings.Contains(s, sub)
+		}
+		before, after, found := strings.Cut(s, sub)
+		if !found {
+			return ""
+		}
+		if e.local == "substring-before" {
+			return before
+		}
+		return after
+	case "substring":
+		e.arity(2, 3)
+		runes := []rune(e.stringArg(c, 0))
+		start := xround(xnumber(e.args[1].eval(c)))
+		end := math.Inf(1)
+		if len(e.args) == 3 {
+			end = start + xround(xnumber(e.args[2].eval(c)))
+		}
+		var b strings.Builder
+		for i, r := range runes {
+			if position := float64(i + 1); position >= start && position < end {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	case "string-length":
+		e.arity(0, 1)
+		return float64(utf8.RuneCountInString(e.stringArg(c, 0)))
+	case "normalize-space":
+		e.arity(0, 1)
+		return strings.Join(strings.FieldsFunc(e.stringArg(c, 0), func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+		}), " ")
+	case "translate":
+		e.arity(3, 3)
+		from, to := []rune(e.stringArg(c, 1)), []rune(e.stringArg(c, 2))
+		mapping := map[rune]rune{}
+		for i, r := range from {
+			if _, seen := mapping[r]; seen {
+				continue
+			}
+			mapping[r] = -1
+			if i < len(to) {
+				mapping[r] = to[i]
+			}
+		}
+		var b strings.Builder
+		for _, r := range e.stringArg(c, 0) {
+			if replacement, ok := mapping[r]; !ok {
+				b.WriteRune(r)
+			} else if replacement >= 0 {
+				b.WriteRune(replacement)
+			}
+		}
+		return b.String()
+
+	case "boolean":
+		e.arity(1, 1)
+		return xboolean(e.args[0].eval(c))
+	case "not":
+		e.arity(1, 1)
+		return !xboolean(e.args[0].eval(c))
+	case "true", "false":
+		e.arity(0, 0)
+		return e.local == "true"
+	case "lang":
+		e.arity(1, 1)
+		lang := strings.ToLower(e.stringArg(c, 0))
+		for node := c.node; node != nil; node = node.parent {
+			for _, a := range node.attrs {
+				if a.name.Space == xmlNamespace && a.name.Local == "lang" {
+					value := strings.ToLower(a.value)
+					return value == lang || strings.HasPrefix(value, lang+"-")
+				}
+			}
+		}
+		return false
+
+	case "number":
+		e.arity(0, 1)
+		if len(e.args) == 0 {
+			return xparseNumber(c.node.stringValue())
+		}
+		return xnumber(e.args[0].eval(c))
+	case "sum":
+		e.arity(1, 1)
+		total := 0.0
+		for _, node := range xnodes(e.args[0].eval(c)) {
+			total += xparseNumber(node.stringValue())
+		}
+		return total
+	case "floor", "ceiling", "round":
+		e.arity(1, 1)
+		n := xnumber(e.args[0].eval(c))
+		switch e.local {
+		case "floor":
+			return math.Floor(n)
+		case "ceiling":
+			return math.Ceil(n)
+		}
+		return xround(n)
+
+	case "current":
+		e.arity(0, 0)
+		return xnodeSet{c.current}
+	case "key":
+		e.arity(2, 2)
+		space, local := e.resolveQName(e.stringArg(c, 0))
+		root := c.node
+		for root.parent != nil {
+			root = root.parent
+		}
+		var values []string
+		if nodes, ok := e.args[1].eval(c).(xnodeSet); ok {
+			for _, node := range nodes {
+				values = append(values, node.stringValue())
+			}
+		} else {
+			values = append(values, e.stringArg(c, 1))
+		}
+		return c.proc.keyNodes(expandedName(space, local), values, root)
+	case "generate-id":
+		n := e.nodeArg(c)
+		if n == nil {
+			return ""
+		}
+		return "id" + strconv.Itoa(n.order)
+	case "format-number":
+		e.arity(2, 3)
+		name := ""
+		if len(e.args) == 3 {
+			space, local := e.resolveQName(e.stringArg(c, 2))
+			name = expandedName(space, local)
+		}
+		format, ok := c.proc.decimalFormats[name]
+		if !ok {
+			xsltFail("unknown decimal format %q", name)
+		}
+		return format.format(xnumber(e.args[0].eval(c)), e.stringArg(c, 1))
+	case "system-property":
+		e.arity(1, 1)
+		space, local := e.resolveQName(e.stringArg(c, 0))
+		if space == xslNamespace {
+			switch local {
+			case "version":
+				return 1.0
+			case "vendor":
+				return "jsonxml-wasm"
+			}
+		}
+		return ""
+	case "element-available":
+		e.arity(1, 1)
+		space, local := e.resolveQName(e.stringArg(c, 0))
+		return space == xslNamespace && xsltInstructions[local]
+	case "function-available":
+		e.arity(1, 1)
+		space, local := e.resolveQName(e.stringArg(c, 0))
+		return xsltFunctions[expandedName(space, local)]
+	case "unparsed-entity-uri":
+		e.arity(1, 1)
+		return ""
+	case "document":
+		xsltFail("document() is not supported")
+	}
+	xsltFail("unknown function %s()", e.local)
+	return nil
+}
+
+// xround rounds to the nearest integer, halves towards positive infinity
+func xround(n float64) float64 {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return n
+	}
+	return math.Floor(n + 0.5)
+}
+
+// xidNodes returns the elements whose xml:id is among the whitespace-separated IDs of the value,
+// as no DTD declares other ID attributes
+func xidNodes(context *xnode, value interface{}) xnodeSet {
+	wanted := map[string]bool{}
+	if nodes, ok := value.(xnodeSet); ok {
+		for _, node := range nodes {
+			for _, id := range strings.Fields(node.stringValue()) {
+				wanted[id] = true
+			}
+		}
+	} else {
+		for _, id := range strings.Fields(xstring(value)) {
+			wanted[id] = true
+		}
+	}
+
+	root := context
+	for root.parent != nil {
+		root = root.parent
+	}
+	var found []*xnode
+	for _, node := range xdescendants(root, nil) {
+		for _, a := range node.attrs {
+			if a.name.Space == xmlNamespace && a.name.Local == "id" && wanted[a.value] {
+				found = append(found, node)
+			}
+		}
+	}
+	return xnodeSet(found)
+}
+
+// xdecimalFormat is a decimal format declared with xsl:decimal-format
+type xdecimalFormat struct {
+	decimal, grouping, percent, perMille, zero, digit, separator, minus rune
+	infinity, nan                                                       string
+}
+
+// defaultDecimalFormat is the decimal format format-number() uses unless one is named
+var defaultDecimalFormat = xdecimalFormat{
+	decimal: '.', grouping: ',', percent: '%', perMille: '‰', zero: '0', digit: '#', separator: ';', minus: '-',
+	infinity: "Infinity", nan: "NaN",
+}
+
+// format implements format-number() with the JDK 1.1 DecimalFormat pattern syntax XSLT 1.0 refers to
+func (f xdecimalFormat) format(n float64, pattern string) string {
+	if math.IsNaN(n) {
+		return f.nan
+	}
+	positive, negative, hasNegative := strings.Cut(pattern, string(f.separator))
+
+	isPattern := func(r rune) bool {
+		return r == f.digit || r == f.zero || r == f.grouping || r == f.decimal
+	}
+	split := func(sub string) (string, string, string) {
+		runes := []rune(sub)
+		start := 0
+		for start < len(runes) && !isPattern(runes[start]) {
+			start++
+		}
+		end := start
+		for end < len(runes) && isPattern(runes[end]) {
+			end++
+		}
+		return string(runes[:start]), string(runes[start:end]), string(runes[end:])
+	}
+
+	prefix, body, suffix := split(positive)
+	if strings.ContainsRune(prefix+suffix, f.percent) {
+		n *= 100
+	} else if strings.ContainsRune(prefix+suffix, f.perMille) {
+		n *= 1000
+	}
+	if n < 0 || (n == 0 && math.Signbit(n)) {
+		if hasNegative {
+			prefix, _, suffix = split(negative)
+		} else {
+			prefix = string(f.minus) + prefix
+		}
+	}
+	if math.IsInf(n, 0) {
+		return prefix + f.infinity + suffix
+	}
+
+	integerPattern, fractionPattern, _ := strings.Cut(body, string(f.decimal))
+	minInteger := strings.Count(integerPattern, string(f.zero))
+	minFraction := strings.Count(fractionPattern, string(f.zero))
+	maxFraction := minFraction + strings.Count(fractionPattern, string(f.digit))
+	groupSize := 0
+	if i := strings.LastIndex(integerPattern, string(f.grouping)); i >= 0 {
+		groupSize = utf8.RuneCountInString(integerPattern[i+utf8.RuneLen(f.grouping):])
+	}
+
+	digits := strconv.FormatFloat(math.Abs(n), 'f', maxFraction, 64)
+	integer, fraction, _ := strings.Cut(digits, ".")
+	for len(fraction) > minFraction && strings.HasSuffix(fraction, "0") {
+		fraction = fraction[:len(fraction)-1]
+	}
+	integer = strings.TrimLeft(integer, "0")
+	for len(integer) < minInteger {
+		integer = "0" + integer
+	}
+	if integer == "" && fraction == "" {
+		integer = "0"
+	}
+	if strings.Trim(integer+fraction, "0") == "" && !hasNegative && (n < 0 || math.Signbit(n)) {
+		prefix = strings.TrimPrefix(prefix, string(f.minus))
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i, r := range integer {
+		if groupSize > 0 && i > 0 && (len(integer)-i)%groupSize == 0 {
+			b.WriteRune(f.grouping)
+		}
+		b.WriteRune(f.zero + (r - '0'))
+	}
+	if fraction != "" {
+		b.WriteRune(f.decimal)
+		for _, r := range fraction {
+			b.WriteRune(f.zero + (r - '0'))
+		}
+	}
+	b.WriteString(suffix)
+	return b.String()
+}
+
+// xsltInstructions lists the XSLT 1.0 instructions element-available() reports
+var xsltInstructions = map[string]bool{
+	"apply-templates": true, "attribute": true, "call-template": true, "choose": true, "comment": true,
+	"copy": true, "copy-of": true, "element": true, "fallback": true, "for-each": true, "if": true,
+	"message": true, "number": true, "processing-instruction": true, "text": true, "value-of": true,
+	"variable": true,
+}
+
+// xrule is a template rule for one alternative of a match pattern
+type xrule struct {
+	pattern  *xpattern
+	priority float64
+	mode     string
+	template *xtemplate
+}
+
+// xtemplate is the body of an xsl:template, its leading xsl:param elements in params
+type xtemplate struct {
+	params []*xnode
+	body   []*xnode
+}
+
+// xglobal is a top-level variable or parameter, evaluated on first use
+type xglobal struct {
+	decl  *xnode
+	value interface{}
+	state int // 0 pending, 1 being evaluated, 2 evaluated
+}
+
+// xkey is an xsl:key declaration
+type xkey struct {
+	match []*xpattern
+	use   xexpr
+}
+
+// xspaceTest is a name test of xsl:strip-space or xsl:preserve-space
+type xspaceTest struct {
+	test     xtest
+	priority float64
+	strip    bool
+}
+
+// xoutput gathers the attributes of the xsl:output elements
+type xoutput struct {
+	method, version, encoding, mediaType     string
+	doctypePublic, doctypeSystem, standalone string
+	omitDeclaration, indent                  bool
+	cdata                                    map[xml.Name]bool
+}
+
+// xexprKey identifies a compiled expression: an attribute holding an expression, or the offset of
+// an expression in an attribute value template
+type xexprKey struct {
+	attribute *xnode
+	offset    int
+}
+
+// xsltProcessor holds a compiled stylesheet and the state of one transformation
+type xsltProcessor struct {
+	rules          []*xrule
+	named          map[string]*xtemplate
+	globals        map[string]*xglobal
+	params         map[string]interface{}
+	keys           map[string][]*xkey
+	keyIndex       map[*xnode]map[string]map[string][]*xnode
+	decimalFormats map[string]xdecimalFormat
+	attributeSets  map[string][]*xnode
+	spaceTests     []xspaceTest
+	output         xoutput
+	exprs          map[xexprKey]xexpr
+	source         *xnode
+	nextOrder      int
+	depth          int
+	messages       []interface{}
+}
+
+// newXSLTProcessor compiles a stylesheet: a stylesheet or transform element, or a literal result
+// element carrying xsl:version as simplified stylesheets do
+func newXSLTProcessor(stylesheet *xnode) (proc *xsltProcessor, err error) {
+	defer recoverXSLT(&err)
+	proc = &xsltProcessor{
+		named:          map[string]*xtemplate{},
+		globals:        map[string]*xglobal{},
+		keys:           map[string][]*xkey{},
+		keyIndex:       map[*xnode]map[string]map[string][]*xnode{},
+		decimalFormats: map[string]xdecimalFormat{"": defaultDecimalFormat},
+		attributeSets:  map[string][]*xnode{},
+		exprs:          map[xexprKey]xexpr{},
+		output:         xoutput{cdata: map[xml.Name]bool{}},
+	}
+
+	var root *xnode
+	for _, child := range stylesheet.children {
+		if child.kind == xelementNode {
+			root = child
+		}
+	}
+	stripStylesheetSpace(root)
+
+	if root.name.Space != xslNamespace || (root.name.Local != "stylesheet" && root.name.Local != "transform") {
+		if _, ok := xslAttr(root, "version"); !ok {
+			xsltFail("the root element is not an xsl:stylesheet")
+		}
+		template := &xtemplate{body: []*xnode{root}}
+		patterns, _ := compilePattern("/", nil)
+		proc.rules = append(proc.rules, &xrule{pattern: patterns[0], priority: 0.5, template: template})
+		return proc, nil
+	}
+
+	for _, decl := range root.children {
+		if decl.kind != xelementNode || decl.name.Space != xslNamespace {
+			continue
+		}
+		switch decl.name.Local {
+		case "template":
+			proc.addTemplate(decl)
+		case "variable", "param":
+			name := requiredAttr(decl, "name")
+			proc.globals[name] = &xglobal{decl: decl}
+		case "key":
+			name := proc.qualify(decl, requiredAttr(decl, "name"))
+			match, err := compilePattern(requiredAttr(decl, "match"), decl.inScopeNamespaces())
+			if err != nil {
+				xsltFail("%v", err)
+			}
+			proc.keys[name] = append(proc.keys[name], &xkey{match: match, use: proc.expr(decl, "use")})
+		case "output":
+			proc.addOutput(decl)
+		case "strip-space", "preserve-space":
+			for _, name := range strings.Fields(requiredAttr(decl, "elements")) {
+				p, err := newXPathParser(name, decl.inScopeNamespaces())
+				if err != nil {
+					xsltFail("%v", err)
+				}
+				var test xtest
+				func() {
+					defer recoverXSLT(&err)
+					test = p.nodeTest()
+				}()
+				if err != nil {
+					xsltFail("%v", err)
+				}
+				proc.spaceTests = append(proc.spaceTests, xspaceTest{test, test.priority(), decl.name.Local == "strip-space"})
+			}
+		case "decimal-format":
+			proc.addDecimalFormat(decl)
+		case "attribute-set":
+			name := proc.qualify(decl, requiredAttr(decl, "name"))
+			proc.attributeSets[name] = append(proc.attributeSets[name], decl)
+		case "import", "include", "namespace-alias":
+			xsltFail("xsl:%s is not supported", decl.name.Local)
+		}
+	}
+	return proc, nil
+}
+
+// stripStylesheetSpace removes whitespace-only text from the stylesheet, except in xsl:text and
+// where xml:space="preserve"
+func stripStylesheetSpace(n *xnode) {
+	if n.name.Space == xslNamespace && n.name.Local == "text" {
+		return
+	}
+	for _, a := range n.attrs {
+		if a.name.Space == xmlNamespace && a.name.Local == "space" && a.value == "preserve" {
+			return
+		}
+	}
+	kept := n.children[:0]
+	for _, child := range n.children {
+		if child.kind == xtextNode && strings.Trim(child.value, " \t\r\n") == "" {
+			continue
+		}
+		if child.kind == xelementNode {
+			stripStylesheetSpace(child)
+		}
+		kept = append(kept, child)
+	}
+	n.children = kept
+}
+
+// xslAttr returns an attribute in the XSLT namespace, as literal result elements carry them
+func xslAttr(n *xnode, name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.name.Space == xslNamespace && a.name.Local == name {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// requiredAttr returns an attribute the instruction cannot do without
+func requiredAttr(n *xnode, name string) string {
+	value, ok := n.attr(name)
+	if !ok {
+		xsltFail("<%s> requires a %s attribute", n.qname(), name)
+	}
+	return value
+}
+
+// qualify expands a QName attribute value such as a key or mode name, so that prefixes bound to
+// the same namespace designate the same name
+func (proc *xsltProcessor) qualify(n *xnode, qname string) string {
+	qname = strings.TrimSpace(qname)
+	prefix, local, found := strings.Cut(qname, ":")
+	if !found {
+		return qname
+	}
+	uri, ok := n.lookupNamespace(prefix)
+	if !ok {
+		xsltFail("undeclared namespace prefix %q", prefix)
+	}
+	return expandedName(uri, local)
+}
+
+// addTemplate registers a template by name and as one rule per alternative of its match pattern
+func (proc *xsltProcessor) addTemplate(decl *xnode) {
+	template := &xtemplate{}
+	for _, child := range decl.children {
+		if child.kind == xelementNode && child.name.Space == xslNamespace && child.name.Local == "param" && template.body == nil {
+			template.params = append(template.params, child)
+		} else {
+			template.body = append(template.body, child)
+		}
+	}
+
+	name, named := decl.attr("name")
+	if named {
+		proc.named[proc.qualify(decl, name)] = template
+	}
+	match, ok := decl.attr("match")
+	if !ok {
+		if !named {
+			xsltFail("<%s> requires a %s attribute", decl.qname(), "match")
+		}
+		return
+	}
+
+	patterns, err := compilePattern(match, decl.inScopeNamespaces())
+	if err != nil {
+		xsltFail("%v", err)
+	}
+	mode, _ := decl.attr("mode")
+	mode = proc.qualify(decl, mode)
+	for _, pattern := range patterns {
+		rule := &xrule{pattern: pattern, priority: pattern.priority(), mode: mode, template: template}
+		if priority, ok := decl.attr("priority"); ok {
+			rule.priority = xparseNumber(priority)
+			if math.IsNaN(rule.priority) {
+				xsltFail("invalid priority %q", priority)
+			}
+		}
+		proc.rules = append(proc.rules, rule)
+	}
+}
+
+// addOutput merges an xsl:output element into the output settings
+func (proc *xsltProcessor) addOutput(decl *xnode) {
+	out := &proc.output
+	for _, a := range decl.attrs {
+		switch a.name.Local {
+		case "method":
+			out.method = proc.qualify(decl, a.value)
+		case "version":
+			out.version = a.value
+		case "encoding":
+			out.encoding = a.value
+		case "media-type":
+			out.mediaType = a.value
+		case "doctype-public":
+			out.doctypePublic = a.value
+		case "doctype-system":
+			out.doctypeSystem = a.value
+		case "standalone":
+			out.standalone = a.value
+		case "omit-xml-declaration":
+			out.omitDeclaration = a.value == "yes"
+		case "indent":
+			out.indent = a.value == "yes"
+		case "cdata-section-elements":
+			for _, qname := range strings.Fields(a.value) {
+				prefix, local, found := strings.Cut(qname, ":")
+				if !found {
+					prefix, local = "", qname
+				}
+				uri, _ := decl.lookupNamespace(prefix)
+				out.cdata[xml.Name{Space: uri, Local: local}] = true
+			}
+		}
+	}
+}
+
+// addDecimalFormat registers an xsl:decimal-format element
+func (proc *xsltProcessor) addDecimalFormat(decl *xnode) {
+	format := defaultDecimalFormat
+	name := ""
+	for _, a := range decl.attrs {
+		r, _ := utf8.DecodeRuneInString(a.value)
+		switch a.name.Local {
+		case "name":
+			name = proc.qualify(decl, a.value)
+		case "decimal-separator":
+			format.decimal = r
+		case "grouping-separator":
+			format.grouping = r
+		case "percent":
+			format.percent = r
+		case "per-mille":
+			format.perMille = r
+		case "zero-digit":
+			format.zero = r
+		case "digit":
+			format.digit = r
+		case "pattern-separator":
+			format.separator = r
+		case "minus-sign":
+			format.minus = r
+		case "infinity":
+			format.infinity = a.value
+		case "NaN":
+			format.nan = a.value
+		}
+	}
+	proc.decimalFormats[name] = format
+}
+
+// stripSourceSpace removes the whitespace-only text nodes xsl:strip-space selects from the source
+func (proc *xsltProcessor) stripSourceSpace(n *xnode) {
+	if n.kind == xelementNode {
+		for _, a := range n.attrs {
+			if a.name.Space == xmlNamespace && a.name.Local == "space" {
+				if a.value == "preserve" {
+					return
+				}
+			}
+		}
+	}
+
+	strip := false
+	if n.kind == xelementNode {
+		best := math.Inf(-1)
+		for _, test := range proc.spaceTests {
+			if test.test.matches(n, xelementNode) && test.priority >= best {
+				best, strip = test.priority, test.strip
+			}
+		}
+	}
+
+	kept := n.children[:0]
+	for _, child := range n.children {
+		if strip && child.kind == xtextNode && strings.Trim(child.value, " \t\r\n") == "" {
+			continue
+		}
+		if child.kind == xelementNode {
+			proc.stripSourceSpace(child)
+		}
+		kept = append(kept, child)
+	}
+	n.children = kept
+}
+
+// expr returns the compiled expression of an instruction attribute, compiled once
+func (proc *xsltProcessor) expr(n *xnode, name string) xexpr {
+	var attribute *xnode
+	for _, a := range n.attrs {
+		if a.name.Space == "" && a.name.Local == name {
+			attribute = a
+		}
+	}
+	if attribute == nil {
+		xsltFail("<%s> requires a %s attribute", n.qname(), name)
+	}
+	return proc.compiled(xexprKey{attribute, -1}, attribute.value)
+}
+
+// hasAttr reports whether an instruction has an attribute
+func hasAttr(n *xnode, name string) bool {
+	_, ok := n.attr(name)
+	return ok
+}
+
+// avt evaluates an attribute value template, the expressions between braces; {{ and }} stand for braces
+func (proc *xsltProcessor) avt(c *xcontext, attribute *xnode) string {
+	value := attribute.value
+	if !strings.ContainsAny(value, "{}") {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; {
+		case ch == '}':
+			if i+1 < len(value) && value[i+1] == '}' {
+				i++
+			}
+			b.WriteByte('}')
+		case ch == '{' && i+1 < len(value) && value[i+1] == '{':
+			b.WriteByte('{')
+			i++
+		case ch == '{':
+			end, quote := i+1, byte(0)
+			for ; end < len(value) && (quote != 0 || value[end] != '}'); end++ {
+				if quote == 0 && (value[end] == '"' || value[end] == '\'') {
+					quote = value[end]
+				} else if value[end] == quote {
+					quote = 0
+				}
+			}
+			if end == len(value) {
+				xsltFail("unterminated attribute value template %q", value)
+			}
+			b.WriteString(xstring(proc.compiled(xexprKey{attribute, i}, value[i+1:end]).eval(c)))
+			i = end
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// compiled compiles an expression of a stylesheet attribute once
+func (proc *xsltProcessor) compiled(key xexprKey, source string) xexpr {
+	if compiled, ok := proc.exprs[key]; ok {
+		return compiled
+	}
+	compiled, err := compileXPath(source, key.attribute.parent.inScopeNamespaces())
+	if err != nil {
+		xsltFail("%v", err)
+	}
+	proc.exprs[key] = compiled
+	return compiled
+}
+
+// attrAVT evaluates an optional attribute value template of an instruction
+func (proc *xsltProcessor) attrAVT(c *xcontext, n *xnode, name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.name.Space == "" && a.name.Local == name {
+			return proc.avt(c, a), true
+		}
+	}
+	return "", false
+}
+
+// global returns the value of a top-level variable or parameter, evaluating it on first use
+func (proc *xsltProcessor) global(name string) (interface{}, bool) {
+	g, ok := proc.globals[name]
+	if !ok {
+		return nil, false
+	}
+	switch g.state {
+	case 1:
+		xsltFail("circular definition of $%s", name)
+	case 0:
+		g.state = 1
+		if value, passed := proc.params[name]; passed && g.decl.name.Local == "param" {
+			g.value = value
+		} else {
+			c := &xcontext{node: proc.source, position: 1, size: 1, current: proc.source, proc: proc}
+			g.value = proc.variableValue(c, g.decl)
+		}
+		g.state = 2
+	}
+	return g.value, true
+}
+
+// variableValue evaluates the select attribute or content of a variable, parameter or with-param:
+// content builds a result tree fragment, returned as a node-set holding its root
+func (proc *xsltProcessor) variableValue(c *xcontext, decl *xnode) interface{} {
+	if hasAttr(decl, "select") {
+		return proc.expr(decl, "select").eval(c)
+	}
+	if len(decl.children) == 0 {
+		return ""
+	}
+	return xnodeSet{proc.fragment(c, decl.children)}
+}
+
+// fragment instantiates a sequence of instructions into a new tree and returns its root
+func (proc *xsltProcessor) fragment(c *xcontext, body []*xnode) *xnode {
+	root := &xnode{kind: xrootNode}
+	proc.execute(c, body, root)
+	proc.nextOrder = numberNodes(root, proc.nextOrder)
+	return root
+}
+
+// keyNodes returns the nodes of a document whose key named name has one of the values
+func (proc *xsltProcessor) keyNodes(name string, values []string, root *xnode) xnodeSet {
+	decls, ok := proc.keys[name]
+	if !ok {
+		xsltFail("unknown key %q", name)
+	}
+
+	if proc.keyIndex[root] == nil {
+		proc.keyIndex[root] = map[string]map[string][]*xnode{}
+	}
+	index, built := proc.keyIndex[root][name]
+	if !built {
+		index = map[string][]*xnode{}
+		proc.keyIndex[root][name] = index
+		nodes := xdescendants(root, []*xnode{root})
+		for _, node := range nodes {
+			nodes = append(nodes, node.attrs...)
+		}
+		for _, node := range nodes {
+			c := &xcontext{node: node, position: 1, size: 1, current: node, proc: proc}
+			for _, decl := range decls {
+				matched := false
+				for _, pattern := range decl.match {
+					matched = matched || pattern.matches(c, node)
+				}
+				if !matched {
+					continue
+				}
+				if used, ok := decl.use.eval(c).(xnodeSet); ok {
+					for _, u := range used {
+						index[u.stringValue()] = append(index[u.stringValue()], node)
+					}
+				} else {
+					value := xstring(decl.use.eval(c))
+					index[value] = append(index[value], node)
+				}
+			}
+		}
+	}
+
+	var found []*xnode
+	for _, value := range values {
+		found = append(found, index[value]...)
+	}
+	return sortNodes(found)
+}
+
+// withParams evaluates the xsl:with-param children of apply-templates or call-template
+func (proc *xsltProcessor) withParams(c *xcontext, n *xnode) map[string]interface{} {
+	params := map[string]interface{}{}
+	for _, child := range n.children {
+		if child.kind == xelementNode && child.name.Space == xslNamespace && child.name.Local == "with-param" {
+			params[requiredAttr(child, "name")] = proc.variableValue(c, child)
+		}
+	}
+	return params
+}
+
+// invoke instantiates a template for the context node, binding its parameters
+func (proc *xsltProcessor) invoke(c *xcontext, template *xtemplate, params map[string]interface{}, out *xnode) {
+	proc.depth++
+	if proc.depth > xsltMaxDepth {
+		xsltFail("templates nest deeper than %d levels", xsltMaxDepth)
+	}
+	inner := *c
+	inner.current = c.node
+	inner.vars = nil
+	for _, param := range template.params {
+		name := requiredAttr(param, "name")
+		value, passed := params[name]
+		if !passed {
+			value = proc.variableValue(&inner, param)
+		}
+		inner.vars = &xscope{name: name, value: value, parent: inner.vars}
+	}
+	proc.execute(&inner, template.body, out)
+	proc.depth--
+}
+
+// applyTemplates processes a list of nodes with the best matching rule of the mode
+func (proc *xsltProcessor) applyTemplates(c *xcontext, nodes []*xnode, mode string, params map[string]interface{}, out *xnode) {
+	for i, node := range nodes {
+		inner := c.at(node, i+1, len(nodes))
+		inner.current = node
+
+		var best *xrule
+		for _, rule := range proc.rules {
+			if rule.mode == mode && (best == nil || rule.priority >= best.priority) && rule.pattern.matches(inner, node) {
+				best = rule
+			}
+		}
+		if best != nil {
+			proc.invoke(inner, best.template, params, out)
+			continue
+		}
+
+		// Built-in rules: recurse into roots and elements, copy the text of text and attribute nodes
+		switch node.kind {
+		case xrootNode, xelementNode:
+			proc.depth++
+			if proc.depth > xsltMaxDepth {
+				xsltFail("templates nest deeper than %d levels", xsltMaxDepth)
+			}
+			proc.applyTemplates(inner, node.children, mode, params, out)
+			proc.depth--
+		case xtextNode, xattributeNode:
+			out.appendChild(&xnode{kind: xtextNode, value: node.value})
+		}
+	}
+}
+
+// sortNodes orders nodes by the xsl:sort children of an instruction, if any
+func (proc *xsltProcessor) sortBy(c *xcontext, n *xnode, nodes []*xnode) []*xnode {
+	var sorts []*xnode
+	for _, child := range n.children {
+		if child.kind == xelementNode && child.name.Space == xslNamespace && child.name.Local == "sort" {
+			sorts = append(sorts, child)
+		}
+	}
+	if sorts == nil {
+		return nodes
+	}
+
+	type sortKey struct {
+		text       []string
+		numbers    []float64
+		descending bool
+		numeric    bool
+		upperFirst bool
+	}
+	keys := make([]sortKey, len(sorts))
+	for k, s := range sorts {
+		key := &keys[k]
+		order, _ := proc.attrAVT(c, s, "order")
+		dataType, _ := proc.attrAVT(c, s, "data-type")
+		caseOrder, _ := proc.attrAVT(c, s, "case-order")
+		key.descending, key.numeric, key.upperFirst = order == "descending", dataType == "number", caseOrder == "upper-first"
+
+		var selectExpr xexpr = &xlocationPath{steps: []*xstep{{axis: "self", test: xtest{kind: xtestNode}}}}
+		if hasAttr(s, "select") {
+			selectExpr = proc.expr(s, "select")
+		}
+		for i, node := range nodes {
+			inner := c.at(node, i+1, len(nodes))
+			inner.current = node
+			value := xstring(selectExpr.eval(inner))
+			key.text = append(key.text, value)
+			key.numbers = append(key.numbers, xparseNumber(value))
+		}
+	}
+
+	indices := make([]int, len(nodes))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		i, j := indices[a], indices[b]
+		for _, key := range keys {
+			cmp := 0
+			if key.numeric {
+				x, y := key.numbers[i], key.numbers[j]
+				switch {
+				case math.IsNaN(x) && !math.IsNaN(y):
+					cmp = -1
+				case !math.IsNaN(x) && math.IsNaN(y):
+					cmp = 1
+				case x < y:
+					cmp = -1
+				case x > y:
+					cmp = 1
+				}
+			} else {
+				x, y := key.text[i], key.text[j]
+				cmp = strings.Compare(strings.ToLower(x), strings.ToLower(y))
+				if cmp == 0 && x != y {
+					// Case differences only break ties, lower case first unless upper-first
+					cmp = strings.Compare(y, x)
+					if key.upperFirst {
+						cmp = -cmp
+					}
+				}
+			}
+			if key.descending {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	sorted := make([]*xnode, len(nodes))
+	for k, i := range indices {
+		sorted[k] = nodes[i]
+	}
+	return sorted
+}
+
+// execute instantiates a sequence of instructions and literal result nodes into out. Variables
+// bound by the sequence are visible to the following siblings.
+func (proc *xsltProcessor) execute(c *xcontext, body []*xnode, out *xnode) {
+	scope := *c
+	c = &scope
+	for _, n := range body {
+		switch {
+		case n.kind == xtextNode:
+			out.appendChild(&xnode{kind: xtextNode, value: n.value})
+		case n.kind != xelementNode:
+			// Comments and processing instructions of the stylesheet are not copied
+		case n.name.Space == xslNamespace:
+			if n.name.Local == "variable" || n.name.Local == "param" {
+				c.vars = &xscope{name: requiredAttr(n, "name"), value: proc.variableValue(c, n), parent: c.vars}
+				continue
+			}
+			proc.instruction(c, n, out)
+		default:
+			proc.literalElement(c, n, out)
+		}
+	}
+}
+
+// isExcluded reports whether a namespace of a literal result element stays out of the result:
+// the XSLT namespace and those named by exclude-result-prefixes or extension-element-prefixes
+func isExcluded(n *xnode, uri string) bool {
+	if uri == xslNamespace {
+		return true
+	}
+	for node := n; node != nil; node = node.parent {
+		for _, a := range node.attrs {
+			isStylesheet := node.name.Space == xslNamespace && a.name.Space == ""
+			isLiteral := node.name.Space != xslNamespace && a.name.Space == xslNamespace
+			if (!isStylesheet && !isLiteral) || (a.name.Local != "exclude-result-prefixes" && a.name.Local != "extension-element-prefixes") {
+				continue
+			}
+			for _, prefix := range strings.Fields(a.value) {
+				if prefix == "#default" {
+					prefix = ""
+				}
+				if excluded, ok := node.lookupNamespace(prefix); ok && excluded == uri {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// literalElement copies a literal result element with its namespaces and evaluated attributes
+func (proc *xsltProcessor) literalElement(c *xcontext, n *xnode, out *xnode) {
+	element := &xnode{kind: xelementNode, name: n.name, prefix: n.prefix, namespaces: map[string]string{}}
+	for prefix, uri := range n.inScopeNamespaces() {
+		if !isExcluded(n, uri) || uri == n.name.Space {
+			element.namespaces[prefix] = uri
+		}
+	}
+	out.appendChild(element)
+
+	if sets, ok := xslAttr(n, "use-attribute-sets"); ok {
+		proc.useAttributeSets(c, n, sets, element, nil)
+	}
+	for _, a := range n.attrs {
+		if a.name.Space == xslNamespace {
+			continue
+		}
+		setAttribute(element, &xnode{kind: xattributeNode, name: a.name, prefix: a.prefix, value: proc.avt(c, a)})
+	}
+	proc.execute(c, n.children, element)
+}
+
+// setAttribute adds an attribute to a result element, replacing one of the same name. Attributes
+// added after children are ignored, as XSLT 1.0 allows.
+func setAttribute(element *xnode, attribute *xnode) {
+	if element.kind != xelementNode || len(element.children) > 0 {
+		return
+	}
+	attribute.parent = element
+	for i, a := range element.attrs {
+		if a.name == attribute.name {
+			element.attrs[i] = attribute
+			return
+		}
+	}
+	element.attrs = append(element.attrs, attribute)
+}
+
+// useAttributeSets adds the attributes of the named attribute sets, detecting sets that use themselves
+func (proc *xsltProcessor) useAttributeSets(c *xcontext, n *xnode, names string, element *xnode, using map[string]bool) {
+	if using == nil {
+		using = map[string]bool{}
+	}
+	for _, name := range strings.Fields(names) {
+		name = proc.qualify(n, name)
+		decls, ok := proc.attributeSets[name]
+		if !ok {
+			xsltFail("unknown attribute set %q", name)
+		}
+		if using[name] {
+			xsltFail("attribute set %q uses itself", name)
+		}
+		using[name] = true
+		for _, decl := range decls {
+			if nested, ok := decl.attr("use-attribute-sets"); ok {
+				proc.useAttributeSets(c, decl, nested, element, using)
+			}
+			// Attribute sets see only global variables
+			global := *c
+			global.vars = nil
+			proc.execute(&global, decl.children, element)
+		}
+		delete(using, name)
+	}
+}
+
+// textContent instantiates content into a fragment and returns its text, for attributes, comments
+// and processing instructions
+func (proc *xsltProcessor) textContent(c *xcontext, n *xnode) string {
+	if len(n.children) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, child := range proc.fragment(c, n.children).children {
+		if child.kind == xtextNode {
+			b.WriteString(child.value)
+		}
+	}
+	return b.String()
+}
+
+// resultName resolves the name of xsl:element or xsl:attribute, with its namespace attribute or
+// the namespaces in scope of the instruction
+func (proc *xsltProcessor) resultName(c *xcontext, n *xnode, isAttribute bool) (xml.Name, string) {
+	qname := strings.TrimSpace(proc.avt(c, requiredAttrNode(n, "name")))
+	prefix, local, found := strings.Cut(qname, ":")
+	if !found {
+		prefix, local = "", qname
+	}
+	if !validNCName(local) || (found && !validNCName(prefix)) || (isAttribute && qname == "xmlns") {
+		xsltFail("invalid name %q", qname)
+	}
+
+	if namespace, ok := proc.attrAVT(c, n, "namespace"); ok {
+		if namespace == "" {
+			prefix = ""
+		}
+		return xml.Name{Space: namespace, Local: local}, prefix
+	}
+	if isAttribute && prefix == "" {
+		return xml.Name{Local: local}, ""
+	}
+	uri, ok := n.lookupNamespace(prefix)
+	if !ok {
+		xsltFail("undeclared namespace prefix %q", prefix)
+	}
+	return xml.Name{Space: uri, Local: local}, prefix
+}
+
+// requiredAttrNode returns the attribute node an instruction cannot do without
+func requiredAttrNode(n *xnode, name string) *xnode {
+	for _, a := range n.attrs {
+		if a.name.Space == "" && a.name.Local == name {
+			return a
+		}
+	}
+	xsltFail("<%s> requires a %s attribute", n.qname(), name)
+	return nil
+}
+
+// validNCName reports whether a string is a name without colon
+func validNCName(s string) bool {
+	for i, r := range s {
+		if (i == 0 && !isNameStart(r)) || !isNameChar(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// copyNode deep copies a node into out, as xsl:copy-of does; roots contribute their children
+func copyNode(n *xnode, out *xnode) {
+	switch n.kind {
+	case xrootNode:
+		for _, child := range n.children {
+			copyNode(child, out)
+		}
+	case xattributeNode:
+		setAttribute(out, &xnode{kind: xattributeNode, name: n.name, prefix: n.prefix, value: n.value})
+	case xelementNode:
+		element := &xnode{kind: xelementNode, name: n.name, prefix: n.prefix, namespaces: n.inScopeNamespaces()}
+		out.appendChild(element)
+		for _, a := range n.attrs {
+			element.attrs = append(element.attrs, &xnode{kind: xattributeNode, name: a.name, prefix: a.prefix, value: a.value, parent: element})
+		}
+		for _, child := range n.children {
+			copyNode(child, element)
+		}
+	default:
+		out.appendChild(&xnode{kind: n.kind, name: n.name, value: n.value, raw: n.raw})
+	}
+}
+
+// instruction executes an XSLT instruction
+func (proc *xsltProcessor) instruction(c *xcontext, n *xnode, out *xnode) {
+	switch n.name.Local {
+	case "apply-templates":
+		var nodes []*xnode = c.node.children
+		if hasAttr(n, "select") {
+			nodes = xnodes(proc.expr(n, "select").eval(c))
+		}
+		mode, _ := n.attr("mode")
+		params := proc.withParams(c, n)
+		proc.applyTemplates(c, proc.sortBy(c, n, nodes), proc.qualify(n, mode), params, out)
+
+	case "call-template":
+		name := proc.qualify(n, requiredAttr(n, "name"))
+		template, ok := proc.named[name]
+		if !ok {
+			xsltFail("no template named %q", name)
+		}
+		proc.invoke(c, template, proc.withParams(c, n), out)
+
+	case "for-each":
+		nodes := proc.sortBy(c, n, xnodes(proc.expr(n, "select").eval(c)))
+		var body []*xnode
+		for _, child := range n.children {
+			if child.kind != xelementNode || child.name.Space != xslNamespace || child.name.Local != "sort" {
+				body = append(body, child)
+			}
+		}
+		for i, node := range nodes {
+			inner := c.at(node, i+1, len(nodes))
+			inner.current = node
+			proc.execute(inner, body, out)
+		}
+
+	case "value-of":
+		value, _ := n.attr("disable-output-escaping")
+		out.appendChild(&xnode{kind: xtextNode, value: xstring(proc.expr(n, "select").eval(c)), raw: value == "yes"})
+
+	case "text":
+		value, _ := n.attr("disable-output-escaping")
+		for _, child := range n.children {
+			if child.kind == xtextNode {
+				out.appendChild(&xnode{kind: xtextNode, value: child.value, raw: value == "yes"})
+			}
+		}
+
+	case "copy-of":
+		value := proc.expr(n, "select").eval(c)
+		if nodes, ok := value.(xnodeSet); ok {
+			for _, node := range nodes {
+				copyNode(node, out)
+			}
+		} else {
+			out.appendChild(&xnode{kind: xtextNode, value: xstring(value)})
+		}
+
+	case "copy":
+		switch node := c.node; node.kind {
+		case xrootNode:
+			proc.execute(c, n.children, out)
+		case xelementNode:
+			element := &xnode{kind: xelementNode, name: node.name, prefix: node.prefix, namespaces: node.inScopeNamespaces()}
+			out.appendChild(element)
+			if sets, ok := n.attr("use-attribute-sets"); ok {
+				proc.useAttributeSets(c, n, sets, element, nil)
+			}
+			proc.execute(c, n.children, element)
+		default:
+			copyNode(node, out)
+		}
+
+	case "if":
+		if xboolean(proc.expr(n, "test").eval(c)) {
+			proc.execute(c, n.children, out)
+		}
+
+	case "choose":
+		for _, branch := range n.children {
+			if branch.kind != xelementNode || branch.name.Space != xslNamespace {
+				continue
+			}
+			if branch.name.Local == "otherwise" || (branch.name.Local == "when" && xboolean(proc.expr(branch, "test").eval(c))) {
+				proc.execute(c, branch.children, out)
+				return
+			}
+		}
+
+	case "element":
+		name, prefix := proc.resultName(c, n, false)
+		element := &xnode{kind: xelementNode, name: name, prefix: prefix, namespaces: map[string]string{prefix: name.Space}}
+		out.appendChild(element)
+		if sets, ok := n.attr("use-attribute-sets"); ok {
+			proc.useAttributeSets(c, n, sets, element, nil)
+		}
+		proc.execute(c, n.children, element)
+
+	case "attribute":
+		name, prefix := proc.resultName(c, n, true)
+		setAttribute(out, &xnode{kind: xattributeNode, name: name, prefix: prefix, value: proc.textContent(c, n)})
+
+	case "comment":
+		text := strings.ReplaceAll(proc.textContent(c, n), "--", "- -")
+		if strings.HasSuffix(text, "-") {
+			text += " "
+		}
+		out.appendChild(&xnode{kind: xcommentNode, value: text})
+
+	case "processing-instruction":
+		target := strings.TrimSpace(proc.avt(c, requiredAttrNode(n, "name")))
+		if !validNCName(target) || strings.EqualFold(target, "xml") {
+			xsltFail("invalid name %q", target)
+		}
+		pi := &xnode{kind: xpiNode, value: strings.ReplaceAll(proc.textContent(c, n), "?>", "? >")}
+		pi.name.Local = target
+		out.appendChild(pi)
+
+	case "number":
+		out.appendChild(&xnode{kind: xtextNode, value: proc.number(c, n)})
+
+	case "message":
+		text := proc.textContent(c, n)
+		proc.messages = append(proc.messages, text)
+		if terminate, _ := n.attr("terminate"); terminate == "yes" {
+			xsltFail("xsl:message terminated the transformation: %s", text)
+		}
+
+	case "fallback", "sort", "with-param":
+		// Executed by their parent instruction
+
+	default:
+		// Unknown instructions run their xsl:fallback children, as forwards-compatible processing requires
+		found := false
+		for _, child := range n.children {
+			if child.kind == xelementNode && child.name.Space == xslNamespace && child.name.Local == "fallback" {
+				found = true
+				proc.execute(c, child.children, out)
+			}
+		}
+		if !found {
+			xsltFail("unknown instruction xsl:%s", n.name.Local)
+		}
+	}
+}
+
+// number implements xsl:number: the value attribute, or the position of the context node counted
+// at level single, multiple or any, formatted by the format tokens
+func (proc *xsltProcessor) number(c *xcontext, n *xnode) string {
+	var numbers []int
+	if hasAttr(n, "value") {
+		value := xround(xnumber(proc.expr(n, "value").eval(c)))
+		if math.IsNaN(value) || math.IsInf(value, 0) || value < 1 {
+			return xnumberString(value)
+		}
+		numbers = []int{int(value)}
+	} else {
+		numbers = proc.countNodes(c, n)
+	}
+
+	format := "1"
+	if value, ok := proc.attrAVT(c, n, "format"); ok {
+		format = value
+	}
+	separator, _ := proc.attrAVT(c, n, "grouping-separator")
+	size := 0
+	if value, ok := proc.attrAVT(c, n, "grouping-size"); ok {
+		size, _ = strconv.Atoi(value)
+	}
+	return formatNumberList(numbers, format, separator, size)
+}
+
+// countNodes counts the context node's position for xsl:number
+func (proc *xsltProcessor) countNodes(c *xcontext, n *xnode) []int {
+	var count, from []*xpattern
+	var err error
+	if value, ok := n.attr("count"); ok {
+		if count, err = compilePattern(value, n.inScopeNamespaces()); err != nil {
+			xsltFail("%v", err)
+		}
+	}
+	if value, ok := n.attr("from"); ok {
+		if from, err = compilePattern(value, n.inScopeNamespaces()); err != nil {
+			xsltFail("%v", err)
+		}
+	}
+
+	matchesAny := func(patterns []*xpattern, node *xnode) bool {
+		for _, pattern := range patterns {
+			if pattern.matches(c, node) {
+				return true
+			}
+		}
+		return false
+	}
+	counted := func(node *xnode) bool {
+		if count != nil {
+			return matchesAny(count, node)
+		}
+		// By default, nodes of the context node's type and name are counted
+		return node.kind == c.node.kind && node.name == c.node.name
+	}
+	position := func(node *xnode) int {
+		index := 1
+		for _, sibling := range xaxis("preceding-sibling", node) {
+			if counted(sibling) {
+				index++
+			}
+		}
+		return index
+	}
+
+	level, _ := n.attr("level")
+	switch level {
+	case "any":
+		// Count back in document order through preceding nodes and ancestors, up to a from node
+		nodes := append(xaxis("preceding", c.node), xaxis("ancestor", c.node)...)
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].order > nodes[j].order })
+		index := 0
+		if counted(c.node) {
+			index++
+		}
+		for _, node := range nodes {
+			if from != nil && matchesAny(from, node) {
+				break
+			}
+			if counted(node) {
+				index++
+			}
+		}
+		if index == 0 {
+			return nil
+		}
+		return []int{index}
+
+	case "multiple":
+		var numbers []int
+		for _, node := range xaxis("ancestor-or-self", c.node) {
+			if from != nil && matchesAny(from, node) {
+				break
+			}
+			if counted(node) {
+				numbers = append([]int{position(node)}, numbers...)
+			}
+		}
+		return numbers
+	}
+
+	for _, node := range xaxis("ancestor-or-self", c.node) {
+		if from != nil && matchesAny(from, node) {
+			break
+		}
+		if counted(node) {
+			return []int{position(node)}
+		}
+	}
+	return nil
+}
+
+// formatNumberList formats numbers with an xsl:number format: alphanumeric tokens (1, 01, a, A, i, I)
+// separated by punctuation, the last token and separator reused for extra numbers
+func formatNumberList(numbers []int, format, separator string, size int) string {
+	var tokens, separators []string
+	runes := []rune(format)
+	isAlnum := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	i := 0
+	for i < len(runes) && !isAlnum(runes[i]) {
+		i++
+	}
+	prefix := string(runes[:i])
+	for i < len(runes) {
+		start := i
+		for i < len(runes) && isAlnum(runes[i]) {
+			i++
+		}
+		tokens = append(tokens, string(runes[start:i]))
+		start = i
+		for i < len(runes) && !isAlnum(runes[i]) {
+			i++
+		}
+		separators = append(separators, string(runes[start:i]))
+	}
+	suffix := ""
+	if len(tokens) == 0 {
+		tokens, separators = []string{"1"}, []string{""}
+	} else {
+		suffix = separators[len(separators)-1]
+		separators = separators[:len(separators)-1]
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	for k, number := range numbers {
+		if k > 0 {
+			sep := "."
+			if k-1 < len(separators) {
+				sep = separators[k-1]
+			} else if len(separators) > 0 {
+				sep = separators[len(separators)-1]
+			}
+			b.WriteString(sep)
+		}
+		token := tokens[len(tokens)-1]
+		if k < len(tokens) {
+			token = tokens[k]
+		}
+		b.WriteString(formatNumberToken(number, token, separator, size))
+	}
+	b.WriteString(suffix)
+	return b.String()
+}
+
+// formatNumberToken formats one number with an xsl:number format token
+func formatNumberToken(number int, token, separator string, size int) string {
+	switch token {
+	case "a", "A":
+		var letters []byte
+		for ; number > 0; number = (number - 1) / 26 {
+			letters = append([]byte{token[0] + byte((number-1)%26)}, letters...)
+		}
+		return string(letters)
+	case "i", "I":
+		if number <= 0 || number >= 4000 {
+			return strconv.Itoa(number)
+		}
+		var b strings.Builder
+		values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+		numerals := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+		for k, value := range values {
+			for ; number >= value; number -= value {
+				b.WriteString(numerals[k])
+			}
+		}
+		if token == "I" {
+			return strings.ToUpper(b.String())
+		}
+		return b.String()
+	}
+
+	digits := strconv.Itoa(number)
+	for width := utf8.RuneCountInString(token); len(digits) < width; {
+		digits = "0" + digits
+	}
+	if separator == "" || size <= 0 {
+		return digits
+	}
+	var b strings.Builder
+	for k, r := range digits {
+		if k > 0 && (len(digits)-k)%size == 0 {
+			b.WriteString(separator)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// htmlVoidElements are written without end tag by the html output method
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "basefont": true, "br": true, "col": true, "embed": true, "frame": true,
+	"hr": true, "img": true, "input": true, "isindex": true, "link": true, "meta": true, "param": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// htmlBooleanAttributes are minimized by the html output method when their value is their name
+var htmlBooleanAttributes = map[string]bool{
+	"checked": true, "compact": true, "declare": true, "defer": true, "disabled": true, "ismap": true,
+	"multiple": true, "nohref": true, "noresize": true, "noshade": true, "nowrap": true, "readonly": true,
+	"selected": true,
+}
+
+// xmlTextEscaper and xmlAttrEscaper escape text and attribute values of the xml output method
+var (
+	xmlTextEscaper  = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	xmlAttrEscaper  = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;", "\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+	htmlAttrEscaper = strings.NewReplacer("&", "&amp;", "\"", "&quot;")
+)
+
+// xserializer writes a result tree with the xml or html output method
+type xserializer struct {
+	b      strings.Builder
+	output *xoutput
+	html   bool
+}
+
+// outputMethod returns the output method: the declared one, otherwise html when the first element
+// of the result is an html element without namespace, as XSLT 1.0 specifies
+func (proc *xsltProcessor) outputMethod(result *xnode) string {
+	if proc.output.method != "" {
+		return proc.output.method
+	}
+	for _, child := range result.children {
+		switch child.kind {
+		case xelementNode:
+			if child.name.Space == "" && strings.EqualFold(child.name.Local, "html") {
+				return "html"
+			}
+			return "xml"
+		case xtextNode:
+			if strings.Trim(child.value, " \t\r\n") != "" {
+				return "xml"
+			}
+		}
+	}
+	return "xml"
+}
+
+// serialize writes the result tree with the output method
+func (proc *xsltProcessor) serialize(result *xnode, method string) string {
+	if method == "text" {
+		var b strings.Builder
+		for _, node := range xdescendants(result, nil) {
+			if node.kind == xtextNode {
+				b.WriteString(node.value)
+			}
+		}
+		return b.String()
+	}
+
+	s := &xserializer{output: &proc.output, html: method == "html"}
+	if !s.html && !proc.output.omitDeclaration {
+		version := proc.output.version
+		if version == "" {
+			version = "1.0"
+		}
+		fmt.Fprintf(&s.b, `<?xml version="%s" encoding="%s"`, version, proc.outputEncoding())
+		if proc.output.standalone != "" {
+			fmt.Fprintf(&s.b, ` standalone="%s"`, proc.output.standalone)
+		}
+		s.b.WriteString("?>\n")
+	}
+
+	if proc.output.doctypeSystem != "" || (s.html && proc.output.doctypePublic != "") {
+		rootName := "html"
+		for _, child := range result.children {
+			if child.kind == xelementNode && !s.html {
+				rootName = child.qname()
+			}
+		}
+		s.b.WriteString("<!DOCTYPE " + rootName)
+		if proc.output.doctypePublic != "" {
+			fmt.Fprintf(&s.b, ` PUBLIC "%s"`, proc.output.doctypePublic)
+			if proc.output.doctypeSystem != "" {
+				fmt.Fprintf(&s.b, ` "%s"`, proc.output.doctypeSystem)
+			}
+		} else {
+			fmt.Fprintf(&s.b, ` SYSTEM "%s"`, proc.output.doctypeSystem)
+		}
+		s.b.WriteString(">\n")
+	}
+
+	scope := map[string]string{"": "", "xml": xmlNamespace}
+	for i, child := range result.children {
+		if i > 0 && proc.output.indent && child.kind != xtextNode && result.children[i-1].kind != xtextNode {
+			s.b.WriteString("\n")
+		}
+		s.node(child, scope, 0)
+	}
+	if s.html || proc.output.indent {
+		return strings.TrimRight(s.b.String(), "\n") + "\n"
+	}
+	return s.b.String()
+}
+
+// outputEncoding returns the declared encoding. Results are JavaScript strings, so the text is
+// the same whatever the encoding; only the declaration reflects it.
+func (proc *xsltProcessor) outputEncoding() string {
+	if proc.output.encoding != "" {
+		return proc.output.encoding
+	}
+	return "UTF-8"
+}
+
+// node writes a node and its descendants; scope maps the prefixes declared so far to their URIs
+func (s *xserializer) node(n *xnode, scope map[string]string, depth int) {
+	switch n.kind {
+	case xtextNode:
+		if n.raw {
+			s.b.WriteString(n.value)
+		} else if s.html {
+			s.b.WriteString(strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(n.value))
+		} else {
+			s.b.WriteString(xmlTextEscaper.Replace(n.value))
+		}
+	case xcommentNode:
+		s.b.WriteString("<!--" + n.value + "-->")
+	case xpiNode:
+		s.b.WriteString("<?" + n.name.Local)
+		if n.value != "" {
+			s.b.WriteString(" " + n.value)
+		}
+		if s.html {
+			s.b.WriteString(">")
+		} else {
+			s.b.WriteString("?>")
+		}
+	case xelementNode:
+		s.element(n, scope, depth)
+	}
+}
+
+// element writes an element, declaring the namespaces its name and attributes need that are not in scope
+func (s *xserializer) element(n *xnode, parentScope map[string]string, depth int) {
+	scope := make(map[string]string, len(parentScope))
+	for prefix, uri := range parentScope {
+		scope[prefix] = uri
+	}
+	var declarations []string
+	declare := func(prefix, uri string) {
+		if scope[prefix] == uri {
+			return
+		}
+		if _, bound := scope[prefix]; !bound && uri == "" {
+			return
+		}
+		scope[prefix] = uri
+		if prefix == "" {
+			declarations = append(declarations, ` xmlns="`+xmlAttrEscaper.Replace(uri)+`"`)
+		} else if uri != "" {
+			declarations = append(declarations, ` xmlns:`+prefix+`="`+xmlAttrEscaper.Replace(uri)+`"`)
+		}
+	}
+
+	declare(n.prefix, n.name.Space)
+	prefixes := make([]string, 0, len(n.namespaces))
+	for prefix := range n.namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		// A default namespace other than the element's own cannot be declared on it
+		if prefix != n.prefix && prefix != "" && n.namespaces[prefix] != "" {
+			declare(prefix, n.namespaces[prefix])
+		}
+	}
+
+	var attributes strings.Builder
+	for _, a := range n.attrs {
+		prefix := a.prefix
+		if a.name.Space != "" && a.name.Space != xmlNamespace && (prefix == "" || scope[prefix] != a.name.Space) {
+			// Attributes in a namespace need a prefix bound to it
+			if prefix == "" || scope[prefix] != "" {
+				prefix = ""
+				for p, uri := range scope {
+					if uri == a.name.Space && p != "" {
+						prefix = p
+					}
+				}
+				for i := 0; prefix == ""; i++ {
+					if _, taken := scope["ns"+strconv.Itoa(i)]; !taken {
+						prefix = "ns" + strconv.Itoa(i)
+					}
+				}
+			}
+			declare(prefix, a.name.Space)
+		}
+		if a.name.Space == "" {
+			prefix = ""
+		}
+
+		name := a.name.Local
+		if prefix != "" {
+			name = prefix + ":" + name
+		}
+		if s.html && n.name.Space == "" && a.name.Space == "" && htmlBooleanAttributes[strings.ToLower(name)] && strings.EqualFold(a.value, name) {
+			attributes.WriteString(" " + name)
+		} else if s.html && n.name.Space == "" {
+			attributes.WriteString(" " + name + `="` + htmlAttrEscaper.Replace(a.value) + `"`)
+		} else {
+			attributes.WriteString(" " + name + `="` + xmlAttrEscaper.Replace(a.value) + `"`)
+		}
+	}
+
+	name := n.name.Local
+	if n.prefix != "" {
+		name = n.prefix + ":" + name
+	}
+	s.b.WriteString("<" + name + strings.Join(declarations, "") + attributes.String())
+
+	isHTML := s.html && n.name.Space == ""
+	if isHTML && htmlVoidElements[strings.ToLower(name)] {
+		s.b.WriteString(">")
+		return
+	}
+	if len(n.children) == 0 && !isHTML {
+		s.b.WriteString("/>")
+		return
+	}
+	s.b.WriteString(">")
+
+	// Indent only element-only content, where added whitespace cannot change the text
+	indent := s.output.indent
+	for _, child := range n.children {
+		if child.kind == xtextNode {
+			indent = false
+		}
+	}
+	rawText := isHTML && (strings.EqualFold(name, "script") || strings.EqualFold(name, "style"))
+	for _, child := range n.children {
+		if indent {
+			s.b.WriteString("\n" + strings.Repeat("  ", depth+1))
+		}
+		switch {
+		case child.kind == xtextNode && rawText:
+			s.b.WriteString(child.value)
+		case child.kind == xtextNode && s.output.cdata[n.name] && !child.raw:
+			s.b.WriteString("<![CDATA[" + strings.ReplaceAll(child.value, "]]>", "]]]]><![CDATA[>") + "]]>")
+		default:
+			s.node(child, scope, depth+1)
+		}
+	}
+	if indent && len(n.children) > 0 {
+		s.b.WriteString("\n" + strings.Repeat("  ", depth))
+	}
+	s.b.WriteString("</" + name + ">")
+}
+
+// xsltOptions configures transformXML: Params sets the stylesheet's top-level xsl:param values by name
+type xsltOptions struct {
+	Params map[string]interface{} `json:"params"`
+}
+
+// runXSLT applies a stylesheet to a document, returning the serialized result, its output method
+// and the xsl:message texts
+func runXSLT(source, stylesheet string, options xsltOptions) (output, method string, proc *xsltProcessor, err error) {
+	doc, err := parseXNodes(source)
+	if err != nil {
+		return "", "", nil, errors.New(localize("Invalid XML: %v", err))
+	}
+	sheet, err := parseXNodes(stylesheet)
+	if err != nil {
+		return "", "", nil, errors.New(localize("Invalid XSLT stylesheet: %v", err))
+	}
+	proc, err = newXSLTProcessor(sheet)
+	if err != nil {
+		return "", "", nil, errors.New(localize("Invalid XSLT stylesheet: %v", err))
+	}
+
+	proc.params = map[string]interface{}{}
+	for name, value := range options.Params {
+		switch value := value.(type) {
+		case string, float64, bool:
+			proc.params[name] = value
+		default:
+			encoded, _ := json.Marshal(value)
+			proc.params[name] = string(encoded)
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.New(localize("XSLT transformation failed: %v", err))
+		}
+	}()
+	defer recoverXSLT(&err)
+
+	proc.stripSourceSpace(doc)
+	proc.nextOrder = numberNodes(doc, 0)
+	proc.source = doc
+	c := &xcontext{node: doc, position: 1, size: 1, current: doc, proc: proc}
+
+	// Evaluate every global so that errors in unused ones are reported, as XSLT processors do
+	for name := range proc.globals {
+		proc.global(name)
+	}
+
+	result := &xnode{kind: xrootNode}
+	proc.applyTemplates(c, []*xnode{doc}, "", nil, result)
+	method = proc.outputMethod(result)
+	if method != "xml" && method != "html" && method != "text" {
+		xsltFail("unsupported output method %q", method)
+	}
+	return proc.serialize(result, method), method, proc, nil
+}
+
+// JSON5 parsing for parseJSON5
+
+// json5MaxDepth bounds the nesting of objects and arrays, deep enough for any configuration file
+const json5MaxDepth = 1000
+
+// json5Options configures parseJSON5: Strict returns the document as strict JSON text instead of a JS
+// value, NaN and Infinity becoming null as with JSON.stringify
+type json5Options struct {
+	Strict bool `json:"strict"`
+}
+
+// json5NonFinite is NaN or an infinity, which JSON cannot represent: it is written as null
+type json5NonFinite float64
+
+func (json5NonFinite) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// json5Special is a NaN or infinite number and the path where parseJSON5 puts it back in the JS value
+type json5Special struct {
+	path  []jsonStep
+	value float64
+}
+
+// get returns the member or element of a JS object or array a step leads to
+func (step jsonStep) get(value js.Value) js.Value {
+	if step.index >= 0 {
+		return value.Index(step.index)
+	}
+	return value.Get(step.key)
+}
+
+// set replaces the member or element of a JS object or array a step leads to
+func (step jsonStep) set(value js.Value, x interface{}) {
+	if step.index >= 0 {
+		value.SetIndex(step.index, x)
+	} else {
+		value.Set(step.key, x)
+	}
+}
+
+// json5Parser reads a JSON5 document (https://spec.json5.org) into ordered objects with numbers kept as
+// json.Number, hexadecimal ones converted to decimal
+type json5Parser struct {
+	src       string
+	pos       int
+	depth     int
+	path      []jsonStep
+	nonFinite []json5Special
+}
+
+// errorf reports a syntax error at the line and column of the current position
+func (p *json5Parser) errorf(format string, args ...interface{}) error {
+	before := p.src[:p.pos]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return fmt.Errorf(localize("line %d, column %d: %v"), line, column, fmt.Sprintf(localize(format), args...))
+}
+
+// unexpected reports the character at the current position, or the end of the input
+func (p *json5Parser) unexpected() error {
+	if p.pos >= len(p.src) {
+		return p.errorf("unexpected end of input")
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return p.errorf("unexpected %q", string(r))
+}
+
+// peek returns the byte at the current position, 0 at the end of the input
+func (p *json5Parser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// document parses the single value of a document
+func (p *json5Parser) document() (interface{}, error) {
+	value, err := p.value()
+	if err == nil {
+		err = p.skip()
+	}
+	if err == nil && p.pos < len(p.src) {
+		err = p.unexpected()
+	}
+	return value, err
+}
+
+// skip passes white space, line terminators and comments
+func (p *json5Parser) skip() error {
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		switch {
+		case r == '\t' || r == '\n' || r == '\v' || r == '\f' || r == '\r' || r == ' ' ||
+			r == 0xa0 || r == 0x2028 || r == 0x2029 || r == 0xfeff || unicode.Is(unicode.Zs, r):
+			p.pos += size
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			end := strings.IndexAny(p.src[p.pos:], "\n\r\u2028\u2029")
+			if end < 0 {
+				end = len(p.src) - p.pos
+			}
+			p.pos += end
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// value parses any value after optional white space and comments
+func (p *json5Parser) value() (interface{}, error) {
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	switch c := p.peek(); {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9' || c == 'I' || c == 'N':
+		return p.number()
+	}
+	for _, literal := range []struct {
+		word  string
+		value interface{}
+	}{{"true", true}, {"false", false}, {"null", nil}} {
+		if p.word(literal.word) {
+			return literal.value, nil
+		}
+	}
+	return nil, p.unexpected()
+}
+
+// word consumes a keyword that is not the start of a longer identifier
+func (p *json5Parser) word(word string) bool {
+	if !strings.HasPrefix(p.src[p.pos:], word) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(p.src[p.pos+len(word):])
+	if p.pos+len(word) < len(p.src) && json5IdentifierRune(next, false) {
+		return false
+	}
+	p.pos += len(word)
+	return true
+}
+
+// enter counts a level of nesting, failing beyond json5MaxDepth; leave undoes it
+func (p *json5Parser) enter() error {
+	p.depth++
+	if p.depth > json5MaxDepth {
+		return p.errorf("nesting deeper than %d levels", json5MaxDepth)
+	}
+	return nil
+}
+
+func (p *json5Parser) leave() {
+	p.depth--
+}
+
+// object parses the members of an object, whose names may be identifiers or strings; a member name
+// given twice keeps its first position and its last value, as with JSON.parse
+func (p *json5Parser) object() (interface{}, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	p.pos++
+
+	object := &jsonObject{values: map[string]interface{}{}}
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.peek() == '}' {
+			p.pos++
+			return object, nil
+		}
+
+		var key string
+		var err error
+		if c := p.peek(); c == '"' || c == '\'' {
+			key, err = p.str()
+		} else {
+			key, err = p.identifier()
+		}
+		if err == nil {
+			err = p.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ':' {
+			return nil, p.errorf("expected ':' after member name %q", key)
+		}
+		p.pos++
+
+		p.path = append(p.path, jsonStep{key: key, index: -1})
+		value, err := p.value()
+		p.path = p.path[:len(p.path)-1]
+		if err == nil {
+			err = p.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+		object.set(key, value)
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return object, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' after member %q", key)
+		}
+	}
+}
+
+// array parses the elements of an array
+func (p *json5Parser) array() (interface{}, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	p.pos++
+
+	items := []interface{}{}
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return items, nil
+		}
+
+		p.path = append(p.path, jsonStep{index: len(items)})
+		value, err := p.value()
+		p.path = p.path[:len(p.path)-1]
+		if err == nil {
+			err = p.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return items, nil
+		default:
+			return nil, p.errorf("expected ',' or ']' after element %d", len(items)-1)
+		}
+	}
+}
+
+// json5IdentifierRune tells whether a character may start, or with first unset continue, an
+// ECMAScript identifier name
+func json5IdentifierRune(r rune, first bool) bool {
+	if r == '$' || r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r) {
+		return true
+	}
+	return !first && (unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc) || r == 0x200c || r == 0x200d)
+}
+
+// identifier parses an unquoted member name, which may contain \uXXXX escapes
+func (p *json5Parser) identifier() (string, error) {
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		start := p.pos
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if r == '\\' {
+			if !strings.HasPrefix(p.src[p.pos:], `\u`) {
+				return "", p.unexpected()
+			}
+			p.pos += 2
+			escaped, err := p.hex(4)
+			if err != nil {
+				return "", err
+			}
+			if !json5IdentifierRune(escaped, b.Len() == 0) {
+				p.pos = start
+				return "", p.errorf("invalid identifier character %q", string(escaped))
+			}
+			b.WriteRune(escaped)
+			continue
+		}
+		if !json5IdentifierRune(r, b.Len() == 0) {
+			break
+		}
+		b.WriteRune(r)
+		p.pos += size
+	}
+	if b.Len() == 0 {
+		return "", p.unexpected()
+	}
+	return b.String(), nil
+}
+
+// hex reads a character given as n hexadecimal digits
+func (p *json5Parser) hex(n int) (rune, error) {
+	if p.pos+n > len(p.src) {
+		return 0, p.errorf("invalid escape sequence")
+	}
+	value, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid escape sequence")
+	}
+	p.pos += n
+	return rune(value), nil
+}
+
+// str parses a single or double quoted string, with the escapes of JavaScript strings and lines
+// continued by a backslash
+func (p *json5Parser) str() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\n' || c == '\r':
+			return "", p.errorf("unescaped line break in string")
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+
+		p.pos++
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += size
+		switch r {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '0':
+			if c := p.peek(); c >= '0' && c <= '9' {
+				return "", p.errorf("octal escapes are not allowed")
+			}
+			b.WriteByte(0)
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			p.pos -= size
+			return "", p.errorf("octal escapes are not allowed")
+		case 'x':
+			value, err := p.hex(2)
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(value)
+		case 'u':
+			value, err := p.hex(4)
+			if err != nil {
+				return "", err
+			}
+			// A surrogate pair is written as two escapes
+			if utf16.IsSurrogate(value) && strings.HasPrefix(p.src[p.pos:], `\u`) {
+				p.pos += 2
+				low, err := p.hex(4)
+				if err != nil {
+					return "", err
+				}
+				if pair := utf16.DecodeRune(value, low); pair != unicode.ReplacementChar {
+					value = pair
+				} else {
+					b.WriteRune(unicode.ReplacementChar)
+					value = low
+				}
+			}
+			b.WriteRune(value)
+		case '\r':
+			if p.peek() == '\n' {
+				p.pos++
+			}
+		case '\n', 0x2028, 0x2029:
+			// Line continuation
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// number parses a decimal or hexadecimal number with an optional sign, a leading or trailing decimal
+// point, Infinity or NaN
+func (p *json5Parser) number() (interface{}, error) {
+	start := p.pos
+	sign := ""
+	if c := p.peek(); c == '-' || c == '+' {
+		if c == '-' {
+			sign = "-"
+		}
+		p.pos++
+	}
+
+	var value interface{}
+	switch rest := p.src[p.pos:]; {
+	case p.word("Infinity"):
+		special := math.Inf(1)
+		if sign == "-" {
+			special = math.Inf(-1)
+		}
+		value = json5NonFinite(special)
+	case p.word("NaN"):
+		value = json5NonFinite(math.NaN())
+	case strings.HasPrefix(rest, "0x") || strings.HasPrefix(rest, "0X"):
+		p.pos += 2
+		digits := p.pos
+		for p.pos < len(p.src) && strings.IndexByte("0123456789abcdefABCDEF", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, ok := new(big.Int).SetString(p.src[digits:p.pos], 16)
+		if !ok {
+			return nil, p.errorf("invalid number %q", p.src[start:p.pos])
+		}
+		value = json.Number(sign + n.String())
+	default:
+		integer := p.digits()
+		fraction := ""
+		if p.peek() == '.' {
+			p.pos++
+			fraction = p.digits()
+		}
+		exponent := ""
+		if c := p.peek(); c == 'e' || c == 'E' {
+			mark := p.pos
+			p.pos++
+			if c := p.peek(); c == '+' || c == '-' {
+				p.pos++
+			}
+			if p.digits() == "" {
+				return nil, p.errorf("invalid number %q", p.src[start:p.pos])
+			}
+			exponent = p.src[mark:p.pos]
+		}
+		if integer == "" && fraction == "" || len(integer) > 1 && integer[0] == '0' {
+			return nil, p.errorf("invalid number %q", p.src[start:p.pos])
+		}
+		if integer == "" {
+			integer = "0"
+		}
+		if fraction != "" {
+			fraction = "." + fraction
+		}
+		value = json.Number(sign + integer + fraction + exponent)
+	}
+
+	if r, _ := utf8.DecodeRuneInString(p.src[p.pos:]); p.pos < len(p.src) && json5IdentifierRune(r, false) {
+		return nil, p.unexpected()
+	}
+	if special, ok := value.(json5NonFinite); ok {
+		p.nonFinite = append(p.nonFinite, json5Special{append([]jsonStep(nil), p.path...), float64(special)})
+	}
+	return value, nil
+}
+
+// digits consumes a run of decimal digits
+func (p *json5Parser) digits() string {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// JSON profiling for profileJSON
+
+// Rough sizes of parsed JSON values in a 64-bit JavaScript engine, for the estimatedMemory of
+// profileJSON: small integers, booleans and null live in the slot that holds them
+const (
+	jsonObjectMemory = 16
+	jsonSlotMemory   = 8
+	jsonStringMemory = 16
+	jsonNumberMemory = 16
+)
+
+// jsonProfileOptions configures profileJSON: Top is the length of each ranking
+type jsonProfileOptions struct {
+	Top int `json:"top"`
+}
+
+// jsonProfile gathers the statistics of profileJSON in one walk of the document. stack holds the
+// path to the current value, written out only for the values that make a ranking.
+type jsonProfile struct {
+	nodes       int
+	depth       int
+	deepestPath string
+	types       map[string]interface{}
+	keys        map[string]int
+	members     int
+	memory      int
+	arrays      *jsonRanking
+	objects     *jsonRanking
+	strings     *jsonRanking
+	subtrees    *jsonRanking
+	stack       []jsonStep
+}
+
+// jsonStep is a member name, or an array index when index is not negative
+type jsonStep struct {
+	key   string
+	index int
+}
+
+func newJSONProfile(top int) *jsonProfile {
+	return &jsonProfile{
+		deepestPath: "$",
+		types: map[string]interface{}{
+			"object": 0, "array": 0, "string": 0, "integer": 0, "float": 0, "boolean": 0, "null": 0,
+		},
+		keys:     map[string]int{},
+		arrays:   &jsonRanking{limit: top},
+		objects:  &jsonRanking{limit: top},
+		strings:  &jsonRanking{limit: top},
+		subtrees: &jsonRanking{limit: top},
+	}
+}
+
+// path writes the current position as a normalized JSONPath, as extractJSONPath returns them
+func (p *jsonProfile) path() string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, step := range p.stack {
+		if step.index >= 0 {
+			b.WriteString("[" + strconv.Itoa(step.index) + "]")
+		} else {
+			b.WriteString(jsonPathName(step.key))
+		}
+	}
+	return b.String()
+}
+
+// count adds a value to the type histogram
+func (p *jsonProfile) count(kind string) {
+	p.nodes++
+	p.types[kind] = p.types[kind].(int) + 1
+}
+
+// container records the depth of an object or array at the current path
+func (p *jsonProfile) container() {
+	if depth := len(p.stack) + 1; depth > p.depth {
+		p.depth = depth
+		p.deepestPath = p.path()
+	}
+}
+
+// visit profiles the value at the current path and returns the length of its compact JSON
+func (p *jsonProfile) visit(value interface{}) int {
+	switch v := value.(type) {
+	case *jsonObject:
+		p.count("object")
+		p.container()
+		p.memory += jsonObjectMemory + jsonSlotMemory*len(v.keys)
+		p.members += len(v.keys)
+		size := 2 + max(len(v.keys)-1, 0)
+		for _, key := range v.keys {
+			p.keys[key]++
+			p.stack = append(p.stack, jsonStep{key: key, index: -1})
+			size += jsonQuotedLength(key) + 1 + p.visit(v.values[key])
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+		p.objects.add(len(v.keys), func() map[string]interface{} {
+			return map[string]interface{}{"path": p.path(), "keys": len(v.keys), "size": size}
+		})
+		p.subtree("object", size)
+		return size
+
+	case []interface{}:
+		p.count("array")
+		p.container()
+		p.memory += jsonObjectMemory + jsonSlotMemory*len(v)
+		size := 2 + max(len(v)-1, 0)
+		for i, item := range v {
+			p.stack = append(p.stack, jsonStep{index: i})
+			size += p.visit(item)
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+		p.arrays.add(len(v), func() map[string]interface{} {
+			return map[string]interface{}{"path": p.path(), "length": len(v), "size": size}
+		})
+		p.subtree("array", size)
+		return size
+
+	case string:
+		p.count("string")
+		// JavaScript strings count UTF-16 code units and take one byte per character when all are Latin-1
+		length, wide := 0, false
+		for _, r := range v {
+			length++
+			if r > 0xffff {
+				length++
+			}
+			wide = wide || r > 0xff
+		}
+		p.memory += jsonStringMemory + length
+		if wide {
+			p.memory += length
+		}
+		p.strings.add(length, func() map[string]interface{} {
+			return map[string]interface{}{"path": p.path(), "length": length}
+		})
+		return jsonQuotedLength(v)
+
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			p.count("integer")
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				p.memory += jsonNumberMemory
+			}
+		} else {
+			p.count("float")
+			p.memory += jsonNumberMemory
+		}
+		return len(v)
+
+	case bool:
+		p.count("boolean")
+		if v {
+			return 4
+		}
+		return 5
+	}
+	p.count("null")
+	return 4
+}
+
+// subtree ranks an object or array by the size of its compact JSON, the document itself aside
+func (p *jsonProfile) subtree(kind string, size int) {
+	if len(p.stack) == 0 {
+		return
+	}
+	p.subtrees.add(size, func() map[string]interface{} {
+		return map[string]interface{}{"path": p.path(), "type": kind, "size": size}
+	})
+}
+
+// keyStats counts member names, listing the most frequent ones
+func (p *jsonProfile) keyStats(top int) map[string]interface{} {
+	names := make([]string, 0, len(p.keys))
+	for name := range p.keys {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if p.keys[names[i]] != p.keys[names[j]] {
+			return p.keys[names[i]] > p.keys[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > top {
+		names = names[:top]
+	}
+	frequent := make([]interface{}, len(names))
+	for i, name := range names {
+		frequent[i] = map[string]interface{}{"key": name, "count": p.keys[name]}
+	}
+	return map[string]interface{}{
+		"total":        p.members,
+		"unique":       len(p.keys),
+		"mostFrequent": frequent,
+	}
+}
+
+// jsonRanking keeps the entries with the largest measures, largest first and earliest first on ties
+type jsonRanking struct {
+	limit   int
+	entries []jsonRanked
+}
+
+type jsonRanked struct {
+	measure int
+	entry   map[string]interface{}
+}
+
+// add ranks an entry, built only when its measure makes the ranking
+func (r *jsonRanking) add(measure int, entry func() map[string]interface{}) {
+	if len(r.entries) == r.limit && measure <= r.entries[len(r.entries)-1].measure {
+		return
+	}
+	i := sort.Search(len(r.entries), func(i int) bool { return r.entries[i].measure < measure })
+	r.entries = append(r.entries, jsonRanked{})
+	copy(r.entries[i+1:], r.entries[i:])
+	r.entries[i] = jsonRanked{measure, entry()}
+	if len(r.entries) > r.limit {
+		r.entries = r.entries[:r.limit]
+	}
+}
+
+func (r *jsonRanking) list() []interface{} {
+	list := make([]interface{}, len(r.entries))
+	for i, ranked := range r.entries {
+		list[i] = ranked.entry
+	}
+	return list
+}
+
+// jsonQuotedLength is the length of a string written as JSON.stringify does
+func jsonQuotedLength(s string) int {
+	n := 2
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\' || r == '\b' || r == '\f' || r == '\n' || r == '\r' || r == '\t':
+			n += 2
+		case r < 0x20:
+			n += 6
+		default:
+			n += utf8.RuneLen(r)
+		}
+	}
+	return n
+}
+
+// GeoJSON validation and conversion for validateGeoJSON, csvToGeoJSON and geojsonToCSV
+
+// geoDepths is the array nesting of the coordinates of each geometry type down to its positions
+var geoDepths = map[string]int{
+	"Point":           0,
+	"MultiPoint":      1,
+	"LineString":      1,
+	"MultiLineString": 2,
+	"Polygon":         2,
+	"MultiPolygon":    3,
+}
+
+// geoLatitudeNames and geoLongitudeNames are the column names csvToGeoJSON looks for, case
+// insensitively and in order, when the latitude or longitude option is not given
+var (
+	geoLatitudeNames  = []string{"latitude", "lat", "y"}
+	geoLongitudeNames = []string{"longitude", "lon", "lng", "long", "x"}
+)
+
+// geoCSVOptions configures csvToGeoJSON and geojsonToCSV: the latitude, longitude, altitude and id
+// columns, whether rows without valid coordinates are skipped rather than rejected, whether the
+// collection gets a bbox member, and whether geometries are written as coordinates (latlon), as
+// WKT, or as coordinates when every geometry is a Point (auto). The CSV options of csvToJSON apply too.
+type geoCSVOptions struct {
+	Latitude    string `json:"latitude"`
+	Longitude   string `json:"longitude"`
+	Altitude    string `json:"altitude"`
+	ID          string `json:"id"`
+	SkipInvalid bool   `json:"skipInvalid"`
+	Bbox        bool   `json:"bbox"`
+	Geometry    string `json:"geometry"`
+}
+
+// geoIssue is a validation error or warning at a JSON Pointer into the document
+type geoIssue struct {
+	path    string
+	message string
+}
+
+func (i geoIssue) String() string {
+	if i.path == "" {
+		return i.message
+	}
+	return i.path + ": " + i.message
+}
+
+// geoIssues lists issues as {path, message} objects
+func geoIssues(issues []geoIssue) []interface{} {
+	list := make([]interface{}, len(issues))
+	for i, issue := range issues {
+		list[i] = map[string]interface{}{
+			"path":    issue.path,
+			"message": issue.message,
+		}
+	}
+	return list
+}
+
+// geoValidator checks GeoJSON objects against RFC 7946, counting features and extending the bounding
+// box with every valid position
+type geoValidator struct {
+	errors   []geoIssue
+	warnings []geoIssue
+	features int
+	bbox     []float64
+}
+
+func (v *geoValidator) errorf(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, geoIssue{path, fmt.Sprintf(localize(format), args...)})
+}
+
+func (v *geoValidator) warnf(path, format string, args ...interface{}) {
+	v.warnings = append(v.warnings, geoIssue{path, fmt.Sprintf(localize(format), args...)})
+}
+
+// extend grows the bounding box to a position
+func (v *geoValidator) extend(lon, lat float64) {
+	if v.bbox == nil {
+		v.bbox = []float64{lon, lat, lon, lat}
+		return
+	}
+	v.bbox[0] = math.Min(v.bbox[0], lon)
+	v.bbox[1] = math.Min(v.bbox[1], lat)
+	v.bbox[2] = math.Max(v.bbox[2], lon)
+	v.bbox[3] = math.Max(v.bbox[3], lat)
+}
+
+// bboxValue is the bounding box as [west, south, east, north], or null without any position
+func (v *geoValidator) bboxValue() interface{} {
+	if v.bbox == nil {
+		return nil
+	}
+	return []interface{}{v.bbox[0], v.bbox[1], v.bbox[2], v.bbox[3]}
+}
+
+// document checks a GeoJSON object of any type and returns its type
+func (v *geoValidator) document(value interface{}) string {
+	object, ok := value.(*jsonObject)
+	if !ok {
+		v.errorf("", "expected a GeoJSON object, got %s", protoJSONType(value))
+		return ""
+	}
+	kind, _ := object.values["type"].(string)
+	switch kind {
+	case "FeatureCollection":
+		v.members(object, "")
+		features, ok := object.values["features"].([]interface{})
+		if !ok {
+			v.errorf("/features", "a %s requires a %s array", kind, "features")
+			break
+		}
+		for i, feature := range features {
+			v.feature(feature, "/features/"+strconv.Itoa(i))
+		}
+	case "Feature":
+		v.feature(object, "")
+	default:
+		v.geometry(object, "")
+	}
+	return kind
+}
+
+// members checks the members every GeoJSON object may have
+func (v *geoValidator) members(object *jsonObject, path string) {
+	if value, ok := object.values["bbox"]; ok {
+		items, _ := value.([]interface{})
+		bounds := make([]float64, 0, len(items))
+		for _, item := range items {
+			if n, ok := item.(json.Number); ok {
+				f, _ := n.Float64()
+				bounds = append(bounds, f)
+			}
+		}
+		dimensions := len(bounds) / 2
+		switch {
+		case len(bounds) != len(items) || len(bounds) != 4 && len(bounds) != 6:
+			v.errorf(path+"/bbox", "bbox must be an array of 4 or 6 numbers")
+		case bounds[1] > bounds[1+dimensions]:
+			// West may exceed east for a box crossing the antimeridian, but south never exceeds north
+			v.errorf(path+"/bbox", "bbox south %v is above north %v", bounds[1], bounds[1+dimensions])
+		}
+	}
+	if _, ok := object.values["crs"]; ok {
+		v.warnf(path+"/crs", "crs is obsolete, RFC 7946 coordinates are WGS 84")
+	}
+}
+
+// feature checks a Feature: a geometry that may be null, properties that may be null and an optional
+// string or number id
+func (v *geoValidator) feature(value interface{}, path string) {
+	object, ok := value.(*jsonObject)
+	if !ok || object.values["type"] != "Feature" {
+		v.errorf(path, "expected a %s", "Feature")
+		return
+	}
+	v.features++
+	v.members(object, path)
+
+	if geometry, ok := object.values["geometry"]; !ok {
+		v.errorf(path, "a Feature requires a %s member, null when it has none", "geometry")
+	} else if geometry != nil {
+		v.geometry(geometry, path+"/geometry")
+	}
+	if properties, ok := object.values["properties"]; !ok {
+		v.errorf(path, "a Feature requires a %s member, null when it has none", "properties")
+	} else if _, isObject := properties.(*jsonObject); !isObject && properties != nil {
+		v.errorf(path+"/properties", "properties must be an object or null")
+	}
+	if id, ok := object.values["id"]; ok {
+		switch id.(type) {
+		case string, json.Number:
+		default:
+			v.errorf(path+"/id", "id must be a string or a number")
+		}
+	}
+}
+
+// geometry checks a geometry object and its coordinates, or the geometries of a GeometryCollection
+func (v *geoValidator) geometry(value interface{}, path string) {
+	object, ok := value.(*jsonObject)
+	if !ok {
+		v.errorf(path, "expected a geometry object, got %s", protoJSONType(value))
+		return
+	}
+	kind, _ := object.values["type"].(string)
+	v.members(object, path)
+
+	if kind == "GeometryCollection" {
+		geometries, ok := object.values["geometries"].([]interface{})
+		if !ok {
+			v.errorf(path+"/geometries", "a %s requires a %s array", kind, "geometries")
+			return
+		}
+		for i, geometry := range geometries {
+			itemPath := path + "/geometries/" + strconv.Itoa(i)
+			if nested, ok := geometry.(*jsonObject); ok && nested.values["type"] == "GeometryCollection" {
+				v.warnf(itemPath, "nested GeometryCollections should be avoided")
+			}
+			v.geometry(geometry, itemPath)
+		}
+		return
+	}
+
+	depth, ok := geoDepths[kind]
+	if !ok {
+		v.errorf(path+"/type", "unknown GeoJSON type %q", kind)
+		return
+	}
+	coordinates, ok := object.values["coordinates"]
+	if !ok {
+		v.errorf(path, "a %s requires a %s array", kind, "coordinates")
+		return
+	}
+	v.coordinates(kind, coordinates, depth, path+"/coordinates")
+}
+
+// coordinates checks the arrays of a geometry down to its positions: lines need 2 positions, polygon
+// rings 4 with the last equal to the first, outer rings counterclockwise and holes clockwise
+func (v *geoValidator) coordinates(kind string, value interface{}, depth int, path string) bool {
+	if depth == 0 {
+		return v.position(value, path)
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		v.errorf(path, "expected an array, got %s", protoJSONType(value))
+		return false
+	}
+	valid := true
+	for i, item := range items {
+		valid = v.coordinates(kind, item, depth-1, path+"/"+strconv.Itoa(i)) && valid
+	}
+	if !valid {
+		return false
+	}
+
+	polygon := kind == "Polygon" || kind == "MultiPolygon"
+	switch {
+	case depth == 1 && (kind == "LineString" || kind == "MultiLineString") && len(items) < 2:
+		v.errorf(path, "a line needs at least 2 positions, got %d", len(items))
+		return false
+	case depth == 1 && polygon && len(items) < 4:
+		v.errorf(path, "a polygon ring needs at least 4 positions, got %d", len(items))
+		return false
+	case depth == 1 && polygon && !geoSamePosition(items[0], items[len(items)-1]):
+		v.errorf(path, "a polygon ring must end with its first position")
+		return false
+	case depth == 2 && polygon:
+		for i, ring := range items {
+			if clockwise := geoRingArea(ring.([]interface{})) < 0; clockwise != (i > 0) {
+				if i == 0 {
+					v.warnf(path+"/0", "exterior rings should be counterclockwise")
+				} else {
+					v.warnf(path+"/"+strconv.Itoa(i), "holes should be clockwise")
+				}
+			}
+		}
+	}
+	return true
+}
+
+// position checks a position, [longitude, latitude] with an optional altitude, and extends the
+// bounding box with it
+func (v *geoValidator) position(value interface{}, path string) bool {
+	items, ok := value.([]interface{})
+	if !ok || len(items) < 2 {
+		v.errorf(path, "a position needs at least 2 numbers")
+		return false
+	}
+	coordinates := make([]float64, len(items))
+	for i, item := range items {
+		n, ok := item.(json.Number)
+		if !ok {
+			v.errorf(path+"/"+strconv.Itoa(i), "expected a number, got %s", protoJSONType(item))
+			return false
+		}
+		coordinates[i], _ = n.Float64()
+	}
+	if len(items) > 3 {
+		v.warnf(path, "positions should have no more than 3 elements")
+	}
+
+	lon, lat := coordinates[0], coordinates[1]
+	switch {
+	case lat < -90 || lat > 90:
+		v.errorf(path, "latitude %v is out of range (positions are [lon, lat])", lat)
+		return false
+	case lon < -180 || lon > 180:
+		v.errorf(path, "longitude %v is out of range", lon)
+		return false
+	}
+	v.extend(lon, lat)
+	return true
+}
+
+// geoSamePosition compares two valid positions
+func geoSamePosition(a, b interface{}) bool {
+	p, q := a.([]interface{}), b.([]interface{})
+	if len(p) != len(q) {
+		return false
+	}
+	for i := range p {
+		x, _ := p[i].(json.Number).Float64()
+		y, _ := q[i].(json.Number).Float64()
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+// geoRingArea is the signed area of a ring with the shoelace formula, positive when counterclockwise
+func geoRingArea(ring []interface{}) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		p, q := ring[i].([]interface{}), ring[i+1].([]interface{})
+		x1, _ := p[0].(json.Number).Float64()
+		y1, _ := p[1].(json.Number).Float64()
+		x2, _ := q[0].(json.Number).Float64()
+		y2, _ := q[1].(json.Number).Float64()
+		area += x1*y2 - x2*y1
+	}
+	return area / 2
+}
+
+// geoColumn finds a column by name, case insensitively, or the first of the usual names when the
+// option is empty; it returns -1 when there is none
+func geoColumn(headers []string, name string, usual []string) int {
+	names := usual
+	if name != "" {
+		names = []string{name}
+	}
+	for _, candidate := range names {
+		for i, header := range headers {
+			if strings.EqualFold(strings.TrimSpace(header), candidate) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// geoFeaturesFromCSV builds the FeatureCollection of csvToGeoJSON from CSV records, the first being
+// the header row
+func geoFeaturesFromCSV(records [][]string, csvOpts csvOptions, options geoCSVOptions) (*jsonObject, *geoValidator, int, error) {
+	headers := records[0]
+	latitude := geoColumn(headers, options.Latitude, geoLatitudeNames)
+	longitude := geoColumn(headers, options.Longitude, geoLongitudeNames)
+	altitude, id := -1, -1
+	if options.Altitude != "" {
+		altitude = geoColumn(headers, options.Altitude, nil)
+	}
+	if options.ID != "" {
+		id = geoColumn(headers, options.ID, nil)
+	}
+	switch {
+	case latitude < 0:
+		return nil, nil, 0, geoMissingColumn("latitude", options.Latitude)
+	case longitude < 0:
+		return nil, nil, 0, geoMissingColumn("longitude", options.Longitude)
+	case altitude < 0 && options.Altitude != "":
+		return nil, nil, 0, geoMissingColumn("altitude", options.Altitude)
+	case id < 0 && options.ID != "":
+		return nil, nil, 0, geoMissingColumn("id", options.ID)
+	}
+
+	var columns []*csvColumn
+	if csvOpts.InferTypes {
+		columns = inferCSVColumns(headers, records[1:], csvOpts)
+	}
+
+	v := &geoValidator{}
+	features := []interface{}{}
+	skipped := 0
+	for i, record := range records[1:] {
+		position, err := geoCSVPosition(record, latitude, longitude, altitude)
+		if err != nil {
+			if options.SkipInvalid {
+				skipped++
+				continue
+			}
+			// The header is line 1 of the CSV, so record n is on line n+1 when no cell spans lines
+			return nil, nil, 0, errors.New(localize("row %d (line %d): %v", i+1, i+2, err))
+		}
+		v.extend(position[0], position[1])
+		v.features++
+
+		coordinates := make([]interface{}, len(position))
+		for j, f := range position {
+			coordinates[j] = json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+		}
+		geometry := &jsonObject{values: map[string]interface{}{}}
+		geometry.set("type", "Point")
+		geometry.set("coordinates", coordinates)
+
+		properties := &jsonObject{values: map[string]interface{}{}}
+		for j, cell := range record {
+			if j >= len(headers) || j == latitude || j == longitude || j == altitude || j == id {
+				continue
+			}
+			if columns != nil {
+				properties.set(headers[j], columns[j].convert(cell, csvOpts))
+			} else {
+				properties.set(headers[j], csvCellValue(cell))
+			}
+		}
+
+		feature := &jsonObject{values: map[string]interface{}{}}
+		feature.set("type", "Feature")
+		if id >= 0 && id < len(record) && !csvOpts.nulls[record[id]] {
+			if value := strings.TrimSpace(record[id]); csvInteger(value) {
+				feature.set("id", json.Number(value))
+			} else {
+				feature.set("id", record[id])
+			}
+		}
+		feature.set("geometry", geometry)
+		feature.set("properties", properties)
+		features = append(features, feature)
+	}
+
+	collection := &jsonObject{values: map[string]interface{}{}}
+	collection.set("type", "FeatureCollection")
+	if options.Bbox && v.bbox != nil {
+		collection.set("bbox", v.bboxValue())
+	}
+	collection.set("features", features)
+	return collection, v, skipped, nil
+}
+
+// geoMissingColumn reports a coordinate or id column that is not in the CSV header
+func geoMissingColumn(option, name string) error {
+	if name == "" {
+		return errors.New(localize("no %s column found, set the %s option", option, option))
+	}
+	return errors.New(localize("column %q not found", name))
+}
+
+// geoCSVPosition reads the [longitude, latitude] or [longitude, latitude, altitude] position of a row
+func geoCSVPosition(record []string, latitude, longitude, altitude int) ([]float64, error) {
+	indexes := []int{longitude, latitude}
+	if altitude >= 0 {
+		indexes = append(indexes, altitude)
+	}
+	position := make([]float64, len(indexes))
+	for i, index := range indexes {
+		cell := ""
+		if index < len(record) {
+			cell = strings.TrimSpace(record[index])
+		}
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, errors.New(localize("invalid coordinate %q", cell))
+		}
+		position[i] = f
+	}
+	switch {
+	case position[1] < -90 || position[1] > 90:
+		return nil, errors.New(localize("latitude %v is out of range", position[1]))
+	case position[0] < -180 || position[0] > 180:
+		return nil, errors.New(localize("longitude %v is out of range", position[0]))
+	}
+	return position, nil
+}
+
+// geoFeaturesToCSV lays out the features of a valid FeatureCollection or Feature as CSV: the id column
+// when a feature has an id, the properties in order of first appearance, then the geometry columns
+func geoFeaturesToCSV(document *jsonObject, options geoCSVOptions) ([]string, [][]string, error) {
+	var features []*jsonObject
+	switch kind := document.values["type"].(string); kind {
+	case "FeatureCollection":
+		for _, feature := range document.values["features"].([]interface{}) {
+			features = append(features, feature.(*jsonObject))
+		}
+	case "Feature":
+		features = []*jsonObject{document}
+	default:
+		return nil, nil, errors.New(localize("expected a FeatureCollection or a Feature, got a %s", kind))
+	}
+
+	hasID, points, altitude := false, true, false
+	var names []string
+	seen := map[string]bool{}
+	for _, feature := range features {
+		if _, ok := feature.values["id"]; ok {
+			hasID = true
+		}
+		if properties, ok := feature.values["properties"].(*jsonObject); ok {
+			for _, key := range properties.keys {
+				if !seen[key] {
+					seen[key] = true
+					names = append(names, key)
+				}
+			}
+		}
+		if geometry, ok := feature.values["geometry"].(*jsonObject); ok {
+			if geometry.values["type"] != "Point" {
+				points = false
+			} else if len(geometry.values["coordinates"].([]interface{})) > 2 {
+				altitude = true
+			}
+		}
+	}
+
+	var geometryColumns []string
+	switch options.Geometry {
+	case "auto", "latlon":
+		if !points {
+			if options.Geometry == "latlon" {
+				return nil, nil, errors.New(localize("geometry latlon only writes Point features, use wkt"))
+			}
+			geometryColumns = []string{"wkt"}
+			break
+		}
+		geometryColumns = []string{options.Latitude, options.Longitude}
+		if altitude {
+			geometryColumns = append(geometryColumns, options.Altitude)
+		}
+	case "wkt":
+		geometryColumns = []string{"wkt"}
+	default:
+		return nil, nil, errors.New(localize("Invalid options: %v", localize("geometry must be auto, latlon or wkt, got %q", options.Geometry)))
+	}
+
+	headers := []string{}
+	if hasID {
+		headers = append(headers, options.ID)
+	}
+	headers = append(headers, names...)
+	columns := geometryColumns
+	if hasID {
+		columns = append([]string{options.ID}, columns...)
+	}
+	for _, column := range columns {
+		if seen[column] {
+			return nil, nil, errors.New(localize("property %q clashes with a generated column", column))
+		}
+	}
+	headers = append(headers, geometryColumns...)
+
+	rows := make([][]string, len(features))
+	for i, feature := range features {
+		row := make([]string, 0, len(headers))
+		if hasID {
+			row = append(row, geoCell(feature.values["id"]))
+		}
+		properties, _ := feature.values["properties"].(*jsonObject)
+		for _, name := range names {
+			var value interface{}
+			if properties != nil {
+				value = properties.values[name]
+			}
+			row = append(row, geoCell(value))
+		}
+
+		geometry, _ := feature.values["geometry"].(*jsonObject)
+		switch {
+		case geometry == nil:
+			for range geometryColumns {
+				row = append(row, "")
+			}
+		case geometryColumns[0] == "wkt":
+			var b strings.Builder
+			geoWKT(&b, geometry)
+			row = append(row, b.String())
+		default:
+			position := geometry.values["coordinates"].([]interface{})
+			row = append(row, geoCell(position[1]), geoCell(position[0]))
+			if altitude {
+				if len(position) > 2 {
+					row = append(row, geoCell(position[2]))
+				} else {
+					row = append(row, "")
+				}
+			}
+		}
+		rows[i] = row
+	}
+	return headers, rows, nil
+}
+
+// geoCell writes a property or coordinate as a CSV cell, objects and arrays as compact JSON
+func geoCell(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return string(value)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// geoWKT writes a valid geometry as Well-Known Text, with a Z when its first position has an altitude
+func geoWKT(b *strings.Builder, geometry *jsonObject) {
+	kind := geometry.values["type"].(string)
+	b.WriteString(strings.ToUpper(kind))
+	if kind == "GeometryCollection" {
+		geometries := geometry.values["geometries"].([]interface{})
+		if len(geometries) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		b.WriteString(" (")
+		for i, nested := range geometries {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			geoWKT(b, nested.(*jsonObject))
+		}
+		b.WriteByte(')')
+		return
+	}
+
+	depth := geoDepths[kind]
+	coordinates := geometry.values["coordinates"]
+	first := coordinates
+	for i := 0; i < depth; i++ {
+		items := first.([]interface{})
+		if len(items) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		first = items[0]
+	}
+	if len(first.([]interface{})) > 2 {
+		b.WriteString(" Z")
+	}
+	b.WriteByte(' ')
+	geoWKTCoordinates(b, coordinates, depth, kind == "MultiPoint")
+}
+
+// geoWKTCoordinates writes nested coordinates as parenthesized lists of positions, or EMPTY; the
+// points of a MultiPoint get parentheses of their own
+func geoWKTCoordinates(b *strings.Builder, value interface{}, depth int, multiPoint bool) {
+	if items, ok := value.([]interface{}); depth > 0 && ok && len(items) == 0 {
+		b.WriteString("EMPTY")
+		return
+	}
+	b.WriteByte('(')
+	if depth == 0 {
+		geoWKTPosition(b, value)
+	} else {
+		for i, item := range value.([]interface{}) {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if depth == 1 && !multiPoint {
+				geoWKTPosition(b, item)
+			} else {
+				geoWKTCoordinates(b, item, depth-1, false)
+			}
+		}
+	}
+	b.WriteByte(')')
+}
+
+// geoWKTPosition writes a position as space separated numbers
+func geoWKTPosition(b *strings.Builder, position interface{}) {
+	for i, n := range position.([]interface{}) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(string(n.(json.Number)))
+	}
+}
+
+// HTML table and list extraction for htmlTablesToJSON and htmlListsToJSON
+
+// htmlTableOptions configures htmlTablesToJSON. Headers is auto (the thead rows, or the leading rows
+// made only of th cells), first (the first row) or none (rows returned as arrays of cells);
+// InferTypes converts numbers and true/false as csvToJSON does.
+type htmlTableOptions struct {
+	Headers    string `json:"headers"`
+	InferTypes bool   `json:"inferTypes"`
+}
+
+// htmlCell is one slot of a table grid; a cell spanning several slots fills each of them
+type htmlCell struct {
+	text   string
+	header bool
+}
+
+// htmlRow is a row of a table grid, head telling whether it comes from the thead
+type htmlRow struct {
+	cells []htmlCell
+	head  bool
+}
+
+// htmlTR is a tr element of a table, head telling whether it is in the thead
+type htmlTR struct {
+	cells []*html.Node
+	head  bool
+}
+
+// htmlElements returns the elements of the given kinds in document order
+func htmlElements(root *html.Node, kinds ...atom.Atom) []*html.Node {
+	var found []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, kind := range kinds {
+				if n.DataAtom == kind {
+					found = append(found, n)
+					break
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return found
+}
+
+// htmlAttr returns an attribute of an element, or "" when it has none
+func htmlAttr(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// htmlText returns the text of an element as a reader sees it: runs of whitespace become one space,
+// br and block elements break lines, and script, style and the skipped elements are left out
+func htmlText(n *html.Node, skip ...atom.Atom) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Template:
+				return
+			case atom.Br:
+				b.WriteByte('\n')
+				return
+			}
+			for _, kind := range skip {
+				if n.DataAtom == kind {
+					return
+				}
+			}
+		}
+		block := htmlBlock(n)
+		if block {
+			b.WriteByte('\n')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			b.WriteByte('\n')
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// htmlBlock tells whether an element starts on a new line
+func htmlBlock(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.P, atom.Div, atom.Li, atom.Ul, atom.Ol, atom.Dl, atom.Dt, atom.Dd, atom.Tr, atom.Table,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Blockquote, atom.Pre, atom.Section,
+		atom.Article, atom.Header, atom.Footer, atom.Hr:
+		return true
+	}
+	return false
+}
+
+// htmlSpan returns a colspan or rowspan attribute, 1 when missing or invalid, bounded by max as in
+// the HTML table model
+func htmlSpan(n *html.Node, name string, max int) int {
+	span, err := strconv.Atoi(strings.TrimSpace(htmlAttr(n, name)))
+	if err != nil || span < 1 {
+		return 1
+	}
+	if span > max {
+		return max
+	}
+	return span
+}
+
+// htmlTableTRs returns the rows of a table and of its thead, tbody and tfoot sections with their td
+// and th cells, leaving out the rows of nested tables
+func htmlTableTRs(table *html.Node) []htmlTR {
+	var rows []htmlTR
+	var collect func(parent *html.Node, head bool)
+	collect = func(parent *html.Node, head bool) {
+		for c := parent.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Thead:
+				collect(c, true)
+			case atom.Tbody, atom.Tfoot:
+				collect(c, false)
+			case atom.Tr:
+				tr := htmlTR{head: head}
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						tr.cells = append(tr.cells, cell)
+					}
+				}
+				rows = append(rows, tr)
+			}
+		}
+	}
+	collect(table, false)
+	return rows
+}
+
+// htmlTableGrid lays the rows of a table out on a grid: a cell fills the columns of its colspan in its
+// row and the same columns of the rows its rowspan covers, as browsers render it
+func htmlTableGrid(table *html.Node) []htmlRow {
+	type pending struct {
+		cell htmlCell
+		rows int
+	}
+	var grid []htmlRow
+	var spans []pending
+	for _, tr := range htmlTableTRs(table) {
+		row := htmlRow{head: tr.head}
+		column := 0
+		// Slots still covered by a cell from a row above are filled first
+		fill := func() {
+			for column < len(spans) && spans[column].rows > 0 {
+				row.cells = append(row.cells, spans[column].cell)
+				spans[column].rows--
+				column++
+			}
+		}
+		for _, td := range tr.cells {
+			fill()
+			cell := htmlCell{text: htmlText(td, atom.Table), header: td.DataAtom == atom.Th}
+			rowspan := htmlSpan(td, "rowspan", 65534)
+			for i := htmlSpan(td, "colspan", 1000); i > 0; i-- {
+				if column == len(spans) {
+					spans = append(spans, pending{})
+				}
+				spans[column] = pending{cell: cell, rows: rowspan - 1}
+				row.cells = append(row.cells, cell)
+				column++
+			}
+		}
+		for ; column < len(spans); column++ {
+			if spans[column].rows > 0 {
+				for len(row.cells) < column {
+					row.cells = append(row.cells, htmlCell{})
+				}
+				row.cells = append(row.cells, spans[column].cell)
+				spans[column].rows--
+			}
+		}
+		grid = append(grid, row)
+	}
+	return grid
+}
+
+// htmlTableJSON describes one table: its index, id, caption, headers and rows, as objects keyed by
+// the headers or as arrays when there are none
+func htmlTableJSON(table *html.Node, index int, options htmlTableOptions) *jsonObject {
+	grid := htmlTableGrid(table)
+
+	// Rows without cells, such as spacers, carry no data
+	rows := grid[:0]
+	for _, row := range grid {
+		if len(row.cells) > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	headerRows := 0
+	switch options.Headers {
+	case "first":
+		headerRows = min(1, len(rows))
+	case "auto":
+		for headerRows < len(rows) && rows[headerRows].head {
+			headerRows++
+		}
+		if headerRows == 0 {
+			for headerRows < len(rows) && htmlHeaderRow(rows[headerRows]) {
+				headerRows++
+			}
+		}
+	}
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row.cells))
+	}
+	var headers []string
+	if options.Headers != "none" {
+		headers = htmlHeaders(rows[:headerRows], width)
+	}
+
+	records := []interface{}{}
+	for _, row := range rows[headerRows:] {
+		values := make([]interface{}, width)
+		for i := range values {
+			if i < len(row.cells) {
+				values[i] = htmlCellValue(row.cells[i].text, options.InferTypes)
+			}
+		}
+		if headers == nil {
+			records = append(records, values)
+			continue
+		}
+		record := &jsonObject{values: make(map[string]interface{}, width)}
+		for i, header := range headers {
+			record.set(header, values[i])
+		}
+		records = append(records, record)
+	}
+
+	entry := &jsonObject{values: map[string]interface{}{}}
+	entry.set("index", index)
+	if id := htmlAttr(table, "id"); id != "" {
+		entry.set("id", id)
+	}
+	for c := table.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Caption {
+			entry.set("caption", htmlText(c))
+			break
+		}
+	}
+	if headers != nil {
+		entry.set("headers", headers)
+	}
+	entry.set("rows", records)
+	return entry
+}
+
+// htmlHeaderRow tells whether a row is made only of th cells
+func htmlHeaderRow(row htmlRow) bool {
+	for _, cell := range row.cells {
+		if !cell.header {
+			return false
+		}
+	}
+	return true
+}
+
+// htmlHeaders names the columns after the header rows: the distinct texts of a column are joined, as
+// for a "Price" cell spanning "Net" and "Gross" cells, while blank headers become column1, column2...
+// and repeated names get a _2, _3... suffix
+func htmlHeaders(rows []htmlRow, width int) []string {
+	headers := make([]string, width)
+	used := map[string]int{}
+	for i := range headers {
+		var parts []string
+		for _, row := range rows {
+			if i < len(row.cells) && row.cells[i].text != "" && (len(parts) == 0 || parts[len(parts)-1] != row.cells[i].text) {
+				parts = append(parts, row.cells[i].text)
+			}
+		}
+		name := strings.ReplaceAll(strings.Join(parts, " "), "\n", " ")
+		if name == "" {
+			name = fmt.Sprintf("column%d", i+1)
+		}
+		used[name]++
+		if used[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, used[name])
+		}
+		headers[i] = name
+	}
+	return headers
+}
+
+// htmlCellValue returns the text of a cell, converted to a number or boolean with inferTypes
+func htmlCellValue(text string, inferTypes bool) interface{} {
+	if inferTypes {
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			return number
+		}
+		if text == "true" || text == "false" {
+			return text == "true"
+		}
+	}
+	return text
+}
+
+// htmlInList tells whether a list is nested in another list
+func htmlInList(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && (p.DataAtom == atom.Ul || p.DataAtom == atom.Ol || p.DataAtom == atom.Dl) {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlListItems returns the items of a list. An li holding nested lists becomes an object with its
+// own text and the items of those lists; a dt becomes a term with the text of the dd elements after it.
+func htmlListItems(list *html.Node) []interface{} {
+	items := []interface{}{}
+	if list.DataAtom == atom.Dl {
+		var terms []*jsonObject
+		var descriptions []string
+		flush := func() {
+			for _, term := range terms {
+				term.set("description", strings.Join(descriptions, "\n"))
+			}
+			terms, descriptions = nil, nil
+		}
+		for _, child := range htmlListChildren(list) {
+			switch child.DataAtom {
+			case atom.Dt:
+				if len(descriptions) > 0 {
+					flush()
+				}
+				term := &jsonObject{values: map[string]interface{}{}}
+				term.set("term", htmlText(child))
+				terms = append(terms, term)
+				items = append(items, term)
+			case atom.Dd:
+				descriptions = append(descriptions, htmlText(child))
+			}
+		}
+		flush()
+		return items
+	}
+
+	for _, child := range htmlListChildren(list) {
+		if child.DataAtom != atom.Li {
+			continue
+		}
+		nested := htmlElements(child, atom.Ul, atom.Ol, atom.Dl)
+		text := htmlText(child, atom.Ul, atom.Ol, atom.Dl)
+		if len(nested) == 0 {
+			items = append(items, text)
+			continue
+		}
+		item := &jsonObject{values: map[string]interface{}{}}
+		item.set("text", text)
+		var children []interface{}
+		for _, sublist := range nested {
+			// Only the lists directly in this item, deeper ones belong to their own items
+			if p := htmlListParent(sublist); p == child {
+				children = append(children, htmlListItems(sublist)...)
+			}
+		}
+		item.set("type", nested[0].Data)
+		item.set("items", children)
+		items = append(items, item)
+	}
+	return items
+}
+
+// htmlListChildren returns the element children of a list, looking through the div wrappers HTML
+// allows around dt and dd groups
+func htmlListChildren(list *html.Node) []*html.Node {
+	var children []*html.Node
+	for c := list.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if c.DataAtom == atom.Div && list.DataAtom == atom.Dl {
+			children = append(children, htmlListChildren(c)...)
+			continue
+		}
+		children = append(children, c)
+	}
+	return children
+}
+
+// htmlListParent returns the li or dd holding a nested list
+func htmlListParent(n *html.Node) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && (p.DataAtom == atom.Li || p.DataAtom == atom.Dd) {
+			return p
+		}
+	}
+	return nil
+}
+
+// TOML and INI encoding for jsonToTOML and jsonToINI
+
+// tomlBareKey matches the keys TOML accepts without quotes
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// encodeTOML writes a decoded JSON object as a TOML document, keeping its key order
+func encodeTOML(data interface{}) (string, error) {
+	object, ok := data.(*jsonObject)
+	if !ok {
+		return "", errors.New(localize("the JSON value must be an object"))
+	}
+	var b strings.Builder
+	if err := writeTOMLTable(&b, nil, object, false); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// writeTOMLTable writes the key/value pairs of a table, then its objects as [a.b] sub-tables and
+// its arrays of objects as [[a.b]] arrays of tables. Headers of tables that only hold sub-tables
+// are left implicit.
+func writeTOMLTable(b *strings.Builder, path []string, object *jsonObject, arrayTable bool) error {
+	var entries strings.Builder
+	var tables, arrays []string
+	for _, key := range object.keys {
+		value := object.values[key]
+		switch value := value.(type) {
+		case *jsonObject:
+			tables = append(tables, key)
+			continue
+		case []interface{}:
+			if isTOMLArrayOfTables(value) {
+				arrays = append(arrays, key)
+				continue
+			}
+		}
+		text, err := tomlValue(value, configPath(path, key))
+		if err != nil {
+			return err
+		}
+		entries.WriteString(tomlKey(key) + " = " + text + "\n")
+	}
+
+	if len(path) > 0 && (arrayTable || entries.Len() > 0 || len(tables)+len(arrays) == 0) {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		keys := make([]string, len(path))
+		for i, key := range path {
+			keys[i] = tomlKey(key)
+		}
+		if arrayTable {
+			b.WriteString("[[" + strings.Join(keys, ".") + "]]\n")
+		} else {
+			b.WriteString("[" + strings.Join(keys, ".") + "]\n")
+		}
+	}
+	b.WriteString(entries.String())
+
+	for _, key := range tables {
+		if err := writeTOMLTable(b, configPath(path, key), object.values[key].(*jsonObject), false); err != nil {
+			return err
+		}
+	}
+	for _, key := range arrays {
+		for _, item := range object.values[key].([]interface{}) {
+			if err := writeTOMLTable(b, configPath(path, key), item.(*jsonObject), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTOMLArrayOfTables reports whether an array is written as [[a]] tables: a non-empty array of
+// objects only. Other arrays holding objects are written inline.
+func isTOMLArrayOfTables(items []interface{}) bool {
+	for _, item := range items {
+		if _, ok := item.(*jsonObject); !ok {
+			return false
+		}
+	}
+	return len(items) > 0
+}
+
+// tomlValue writes a value inline, objects as inline tables
+func tomlValue(value interface{}, path []string) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", errors.New(localize("TOML cannot represent null (at %s)", strings.Join(path, ".")))
+	case string:
+		return tomlString(value), nil
+	case float64:
+		return configNumber(value), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			text, err := tomlValue(item, configPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return "", err
+			}
+			items[i] = text
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case *jsonObject:
+		if len(value.keys) == 0 {
+			return "{}", nil
+		}
+		members := make([]string, len(value.keys))
+		for i, key := range value.keys {
+			text, err := tomlValue(value.values[key], configPath(path, key))
+			if err != nil {
+				return "", err
+			}
+			members[i] = tomlKey(key) + " = " + text
+		}
+		return "{ " + strings.Join(members, ", ") + " }", nil
+	}
+	return "", fmt.Errorf("unexpected %T", value)
+}
+
+// tomlKey writes a key bare when TOML allows it, quoted otherwise
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString writes a basic string, escaping quotes, backslashes and control characters
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// configNumber writes integral JSON numbers as integers, others in the shortest float form
+func configNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// configPath returns path extended with key, without sharing path's backing array
+func configPath(path []string, key string) []string {
+	return append(path[:len(path):len(path)], key)
+}
+
+// parseINI reads an INI document: [section] headers, key = value or key: value entries and ; or #
+// comments. Entries before the first section are top-level members, sections stay flat, values
+// stay strings and key[] entries collect into arrays.
+func parseINI(text string) (*jsonObject, error) {
+	root := &jsonObject{values: map[string]interface{}{}}
+	section := root
+
+	for i, line := range strings.Split(strings.TrimPrefix(text, "\ufeff"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf(localize("line %d: unterminated section header"), i+1)
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+				return nil, fmt.Errorf(localize("line %d: unexpected %q after section header"), i+1, rest)
+			}
+			name := strings.TrimSpace(line[1:end])
+			if name == "" {
+				return nil, fmt.Errorf(localize("line %d: empty section name"), i+1)
+			}
+			switch existing := root.values[name].(type) {
+			case nil:
+				section = &jsonObject{values: map[string]interface{}{}}
+				root.set(name, section)
+			case *jsonObject:
+				section = existing
+			default:
+				return nil, fmt.Errorf(localize("line %d: section %q is also a key"), i+1, name)
+			}
+			continue
+		}
+
+		separator := strings.IndexAny(line, "=:")
+		if separator <= 0 {
+			return nil, fmt.Errorf(localize("line %d: expected a section, key = value or comment"), i+1)
+		}
+		key := strings.TrimSpace(line[:separator])
+		value := iniValueText(strings.TrimSpace(line[separator+1:]))
+		if name, isArray := strings.CutSuffix(key, "[]"); isArray {
+			name = strings.TrimSpace(name)
+			items, _ := section.values[name].([]interface{})
+			section.set(name, append(items, value))
+			continue
+		}
+		section.set(key, value)
+	}
+	return root, nil
+}
+
+// iniValueText returns the text of a value: the content of a quoted value, otherwise the value
+// without its inline comment, which starts with ; or # after whitespace
+func iniValueText(value string) string {
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		quote := value[0]
+		if end := strings.IndexByte(value[1:], quote); end >= 0 {
+			if rest := strings.TrimSpace(value[end+2:]); rest == "" || rest[0] == ';' || rest[0] == '#' {
+				return value[1 : end+1]
+			}
+		}
+		if len(value) >= 2 && value[len(value)-1] == quote {
+			return value[1 : len(value)-1]
+		}
+	}
+	for i := 1; i < len(value); i++ {
+		if (value[i] == ';' || value[i] == '#') && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i])
+		}
+	}
+	return value
+}
+
+// encodeINI writes a decoded JSON object as INI, keeping its key order: its scalars and arrays
+// first, then its objects as [section] and their nested objects as [section.sub]
+func encodeINI(data interface{}) (string, error) {
+	object, ok := data.(*jsonObject)
+	if !ok {
+		return "", errors.New(localize("the JSON value must be an object"))
+	}
+	var b strings.Builder
+	if err := writeINISection(&b, "", object); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// writeINISection writes the entries of a section, arrays as repeated key[] entries, then its
+// objects as sections. Headers of sections that only hold sections are left out.
+func writeINISection(b *strings.Builder, name string, object *jsonObject) error {
+	var entries strings.Builder
+	var sections []string
+	for _, key := range object.keys {
+		value := object.values[key]
+		if _, ok := value.(*jsonObject); ok {
+			sections = append(sections, key)
+			continue
+		}
+		if key == "" || key != strings.TrimSpace(key) || strings.ContainsAny(key, "=:\r\n") ||
+			strings.ContainsAny(key[:1], "[;#") || strings.HasSuffix(key, "[]") {
+			return errors.New(localize("key %q cannot be written to INI", key))
+		}
+
+		path := key
+		if name != "" {
+			path = name + "." + key
+		}
+		if items, ok := value.([]interface{}); ok {
+			for i, item := range items {
+				text, err := iniValue(item, fmt.Sprintf("%s[%d]", path, i))
+				if err != nil {
+					return err
+				}
+				entries.WriteString(iniEntry(key+"[]", text))
+			}
+			continue
+		}
+		text, err := iniValue(value, path)
+		if err != nil {
+			return err
+		}
+		entries.WriteString(iniEntry(key, text))
+	}
+
+	if name != "" && (entries.Len() > 0 || len(sections) == 0) {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("[" + name + "]\n")
+	}
+	b.WriteString(entries.String())
+
+	for _, key := range sections {
+		child := key
+		if name != "" {
+			child = name + "." + key
+		}
+		if child != strings.TrimSpace(child) || strings.ContainsAny(child, "]\r\n") {
+			return errors.New(localize("section %q cannot be written to INI", child))
+		}
+		if err := writeINISection(b, child, object.values[key].(*jsonObject)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iniEntry writes a key = value line, null values as a bare key =
+func iniEntry(key, text string) string {
+	if text == "" {
+		return key + " =\n"
+	}
+	return key + " = " + text + "\n"
+}
+
+// iniValue writes a scalar, quoting strings that would not read back as themselves
+func iniValue(value interface{}, path string) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if strings.ContainsAny(value, "\r\n") {
+			return "", errors.New(localize("INI values cannot contain line breaks (at %s)", path))
+		}
+		if value != "" && value == strings.TrimSpace(value) && iniValueText(value) == value {
+			return value, nil
+		}
+		for _, quote := range []string{`"`, `'`} {
+			if quoted := quote + value + quote; iniValueText(quoted) == value {
+				return quoted, nil
+			}
+		}
+		return "", errors.New(localize("value %q cannot be written to INI", value))
+	case float64:
+		return configNumber(value), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+	return "", errors.New(localize("INI arrays can only hold scalar values (at %s)", path))
+}
+
+// MessagePack, CBOR and BSON codecs for the binary functions
+
+// binaryDecodeOptions configures decodeMsgPack, decodeCBOR and decodeBSON: Sequence decodes
+// concatenated values, such as MessagePack streams, CBOR sequences or mongodump files, into an array
+type binaryDecodeOptions struct {
+	Sequence bool `json:"sequence"`
+}
+
+// binaryMaxDepth bounds the nesting of decoded arrays, maps, tags and documents
+const binaryMaxDepth = 512
+
+// encodeBinary implements encodeMsgPack and encodeCBOR: the JSON document, with its key order and
+// exact integers, is written by write and returned as a Uint8Array
+func encodeBinary(name, format, label, failed string, args []js.Value, write func(*bytes.Buffer, interface{}) error) interface{} {
+	if len(args) != 1 {
+		return map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", name, "jsonString"),
+		}
+	}
+
+	data, err := decodeOrderedDocument(args[0], true)
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize("Invalid JSON: %v", err),
+			"format": format,
+		}
+	}
+
+	var b bytes.Buffer
+	if err := write(&b, data); err != nil {
+		return map[string]interface{}{
+			"error": localize(failed, err),
+		}
+	}
+
+	encoded := js.Global().Get("Uint8Array").New(b.Len())
+	js.CopyBytesToJS(encoded, b.Bytes())
+
+	if !silentMode {
+		fmt.Printf("%s WASM: Converted JSON to %s (%d bytes)\n", label, label, b.Len())
+	}
+
+	return map[string]interface{}{
+		"data":   encoded,
+		"valid":  true,
+		"size":   b.Len(),
+		"format": format,
+	}
+}
+
+// decodeBinary implements decodeMsgPack, decodeCBOR and decodeBSON: the bytes are decoded by read,
+// once or with the sequence option until the end of the input, and returned as JSON
+func decodeBinary(name, label, invalid string, args []js.Value, read func(*binaryReader) (interface{}, error)) interface{} {
+	if len(args) < 1 {
+		return map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", name, "bytes"),
+		}
+	}
+	var options binaryDecodeOptions
+	if len(args) > 1 {
+		if err := decodeOptions(args[1], &options); err != nil {
+			return map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			}
+		}
+	}
+
+	data, err := bytesFromJS(args[0])
+	if err == nil && len(data) == 0 {
+		err = errors.New(localize("empty input"))
+	}
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize(invalid, err),
+			"format": "json",
+		}
+	}
+
+	r := &binaryReader{data: data}
+	var value interface{}
+	if options.Sequence {
+		items := []interface{}{}
+		for r.pos < len(r.data) && err == nil {
+			var item interface{}
+			if item, err = read(r); err == nil {
+				items = append(items, item)
+			}
+		}
+		value = items
+	} else {
+		value, err = read(r)
+		if err == nil && r.pos < len(r.data) {
+			err = fmt.Errorf(localize("unexpected data after the value at offset %d"), r.pos)
+		}
+	}
+	if err != nil {
+		return map[string]interface{}{
+			"valid":  false,
+			"error":  localize(invalid, err),
+			"format": "json",
+		}
+	}
+
+	result := jsonDataResult(value)
+	if result["error"] == nil {
+		if options.Sequence {
+			result["count"] = len(value.([]interface{}))
+		}
+		if !silentMode {
+			fmt.Printf("%s WASM: Converted %s to JSON (%d bytes)\n", label, label, len(data))
+		}
+	}
+	return result
+}
+
+// bytesFromJS reads binary data given as a Uint8Array, an ArrayBuffer or a base64 string
+func bytesFromJS(value js.Value) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		return base64.StdEncoding.DecodeString(value.String())
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case !value.InstanceOf(js.Global().Get("Uint8Array")):
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or base64 string"))
+	}
+
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// splitJSONNumber returns a JSON number literal as an integer when it has neither fraction nor
+// exponent, otherwise as a float64
+func splitJSONNumber(n json.Number) (*big.Int, float64, error) {
+	if !strings.ContainsAny(string(n), ".eE") {
+		if integer, ok := new(big.Int).SetString(string(n), 10); ok {
+			return integer, 0, nil
+		}
+	}
+	f, err := n.Float64()
+	return nil, f, err
+}
+
+// writeMsgPack writes a decoded JSON value as MessagePack, in the smallest encoding of each value.
+// Integers beyond 64 bits become float64 values.
+func writeMsgPack(b *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		b.WriteByte(0xc0)
+	case bool:
+		if value {
+			b.WriteByte(0xc3)
+		} else {
+			b.WriteByte(0xc2)
+		}
+	case json.Number:
+		integer, f, err := splitJSONNumber(value)
+		if err != nil {
+			return err
+		}
+		switch {
+		case integer != nil && integer.IsInt64():
+			writeMsgPackInt(b, integer.Int64())
+		case integer != nil && integer.IsUint64():
+			b.WriteByte(0xcf)
+			b.Write(binary.BigEndian.AppendUint64(nil, integer.Uint64()))
+		default:
+			if integer != nil {
+				f, _ = new(big.Float).SetInt(integer).Float64()
+			}
+			if float64(float32(f)) == f {
+				b.WriteByte(0xca)
+				b.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))))
 			} else {
+				b.WriteByte(0xcb)
+				b.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+			}
+		}
+	case string:
+		writeMsgPackHeader(b, len(value), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		b.WriteString(value)
+	case []interface{}:
+		writeMsgPackHeader(b, len(value), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := writeMsgPack(b, item); err != nil {
+				return err
+			}
+		}
+	case *jsonObject:
+		writeMsgPackHeader(b, len(value.keys), 0x80, 15, 0, 0xde, 0xdf)
+		for _, key := range value.keys {
+			writeMsgPack(b, key)
+			if err := writeMsgPack(b, value.values[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeMsgPackHeader writes the type and length of a string, array or map: the fix form up to
+// fixMax, then the 8-bit (when the type has one), 16-bit and 32-bit forms
+func writeMsgPackHeader(b *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		b.WriteByte(fix | byte(n))
+	case n <= 0xff && code8 != 0:
+		b.WriteByte(code8)
+		b.WriteByte(byte(n))
+	case n <= 0xffff:
+		b.WriteByte(code16)
+		b.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		b.WriteByte(code32)
+		b.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+// writeMsgPackInt writes an integer as a fixint or the smallest unsigned or signed type holding it
+func writeMsgPackInt(b *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f, n < 0 && n >= -32:
+		b.WriteByte(byte(n))
+	case n > 0 && n <= math.MaxUint8:
+		b.Write([]byte{0xcc, byte(n)})
+	case n > 0 && n <= math.MaxUint16:
+		b.WriteByte(0xcd)
+		b.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n > 0 && n <= math.MaxUint32:
+		b.WriteByte(0xce)
+		b.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	case n > 0:
+		b.WriteByte(0xcf)
+		b.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+	case n >= math.MinInt8:
+		b.Write([]byte{0xd0, byte(n)})
+	case n >= math.MinInt16:
+		b.WriteByte(0xd1)
+		b.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n >= math.MinInt32:
+		b.WriteByte(0xd2)
+		b.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		b.WriteByte(0xd3)
+		b.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+	}
+}
+
+// writeCBOR writes a decoded JSON value as CBOR with the preferred serialization of RFC 8949:
+// shortest heads and the shortest float (half, single or double) that keeps the value. Integers
+// beyond 64 bits become bignums (tags 2 and 3).
+func writeCBOR(b *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		b.WriteByte(0xf6)
+	case bool:
+		if value {
+			b.WriteByte(0xf5)
+		} else {
+			b.WriteByte(0xf4)
+		}
+	case json.Number:
+		integer, f, err := splitJSONNumber(value)
+		if err != nil {
+			return err
+		}
+		switch {
+		case integer != nil && integer.Sign() >= 0 && integer.IsUint64():
+			writeCBORHead(b, 0, integer.Uint64())
+		case integer != nil && integer.Sign() < 0 && new(big.Int).Not(integer).IsUint64():
+			// Negative integers are encoded as -1 - n, which is ^n in two's complement
+			writeCBORHead(b, 1, new(big.Int).Not(integer).Uint64())
+		case integer != nil && integer.Sign() >= 0:
+			writeCBORHead(b, 6, 2)
+			writeCBORHead(b, 2, uint64(len(integer.Bytes())))
+			b.Write(integer.Bytes())
+		case integer != nil:
+			magnitude := new(big.Int).Not(integer).Bytes()
+			writeCBORHead(b, 6, 3)
+			writeCBORHead(b, 2, uint64(len(magnitude)))
+			b.Write(magnitude)
+		default:
+			writeCBORFloat(b, f)
+		}
+	case string:
+		writeCBORHead(b, 3, uint64(len(value)))
+		b.WriteString(value)
+	case []interface{}:
+		writeCBORHead(b, 4, uint64(len(value)))
+		for _, item := range value {
+			if err := writeCBOR(b, item); err != nil {
+				return err
+			}
+		}
+	case *jsonObject:
+		writeCBORHead(b, 5, uint64(len(value.keys)))
+		for _, key := range value.keys {
+			writeCBOR(b, key)
+			if err := writeCBOR(b, value.values[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCBORHead writes the initial byte of a data item of the major type and its argument n
+func writeCBORHead(b *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		b.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		b.Write([]byte{major | 24, byte(n)})
+	case n <= math.MaxUint16:
+		b.WriteByte(major | 25)
+		b.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		b.WriteByte(major | 26)
+		b.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		b.WriteByte(major | 27)
+		b.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+// writeCBORFloat writes a float in the shortest of half, single and double precision keeping it
+func writeCBORFloat(b *bytes.Buffer, f float64) {
+	if half, ok := float16Bits(f); ok {
+		b.WriteByte(0xf9)
+		b.Write(binary.BigEndian.AppendUint16(nil, half))
+	} else if float64(float32(f)) == f {
+		b.WriteByte(0xfa)
+		b.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))))
+	} else {
+		b.WriteByte(0xfb)
+		b.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+	}
+}
+
+// float16Bits returns the IEEE 754 half precision encoding of a finite float, if it is exact
+func float16Bits(f float64) (uint16, bool) {
+	if float64(float32(f)) != f {
+		return 0, false
+	}
+	bits := math.Float32bits(float32(f))
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23&0xff) - 127
+	significand := bits&0x7fffff | 0x800000
+	switch {
+	case f == 0:
+		return sign, true
+	case exponent >= -14 && exponent <= 15:
+		if significand&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(exponent+15)<<10 | uint16(significand>>13&0x3ff), true
+	case exponent >= -24 && exponent < -14:
+		// Subnormal halves hold significand * 2^(exponent-23) as a multiple of 2^-24
+		shift := uint(-exponent - 1)
+		if significand&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(significand>>shift), true
+	}
+	return 0, false
+}
+
+// float16Value decodes an IEEE 754 half precision float
+func float16Value(half uint16) float64 {
+	exponent := int(half >> 10 & 0x1f)
+	significand := float64(half & 0x3ff)
+	var f float64
+	switch exponent {
+	case 0:
+		f = math.Ldexp(significand, -24)
+	case 0x1f:
+		if significand == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(significand+1024, exponent-25)
+	}
+	if half&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// binaryReader reads the input of the binary decoders, reporting errors with their offset
+type binaryReader struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// next returns the next n bytes
+func (r *binaryReader) next(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, fmt.Errorf(localize("unexpected end of data at offset %d"), len(r.data))
+	}
+	data := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return data, nil
+}
+
+// readByte returns the next byte
+func (r *binaryReader) readByte() (byte, error) {
+	data, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// bigEndian reads an unsigned big-endian integer of size bytes
+func (r *binaryReader) bigEndian(size int) (uint64, error) {
+	data, err := r.next(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range data {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+// littleEndian reads an unsigned little-endian integer of size bytes
+func (r *binaryReader) littleEndian(size int) (uint64, error) {
+	data, err := r.next(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for i := len(data) - 1; i >= 0; i-- {
+		n = n<<8 | uint64(data[i])
+	}
+	return n, nil
+}
+
+// enter counts a level of nesting, failing beyond binaryMaxDepth; leave undoes it
+func (r *binaryReader) enter() error {
+	r.depth++
+	if r.depth > binaryMaxDepth {
+		return fmt.Errorf(localize("nesting deeper than %d levels at offset %d"), binaryMaxDepth, r.pos)
+	}
+	return nil
+}
+
+func (r *binaryReader) leave() {
+	r.depth--
+}
+
+// checkLength fails when n items of at least size bytes each cannot fit in the remaining input,
+// before anything is allocated for them
+func (r *binaryReader) checkLength(n uint64, size uint64, offset int) error {
+	if n > uint64(len(r.data)-r.pos)/size {
+		return fmt.Errorf(localize("length %d at offset %d exceeds the remaining data"), n, offset)
+	}
+	return nil
+}
+
+// readBinaryArray reads n items with item, or with indefinite the items of a CBOR indefinite-length
+// array up to its break byte
+func readBinaryArray(r *binaryReader, n uint64, indefinite bool, offset int, item func(*binaryReader) (interface{}, error)) (interface{}, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	if err := r.checkLength(n, 1, offset); err != nil {
+		return nil, err
+	}
+
+	items := make([]interface{}, 0, n)
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite && r.pos < len(r.data) && r.data[r.pos] == 0xff {
+			r.pos++
+			break
+		}
+		value, err := item(r)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return items, nil
+}
+
+// readBinaryMap reads n key/value pairs with item, or with indefinite the pairs of a CBOR
+// indefinite-length map up to its break byte. Keys become strings, in the order they appear.
+func readBinaryMap(r *binaryReader, n uint64, indefinite bool, offset int, item func(*binaryReader) (interface{}, error)) (interface{}, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	if err := r.checkLength(n, 2, offset); err != nil {
+		return nil, err
+	}
+
+	object := &jsonObject{values: map[string]interface{}{}}
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite && r.pos < len(r.data) && r.data[r.pos] == 0xff {
+			r.pos++
+			break
+		}
+		keyOffset := r.pos
+		key, err := item(r)
+		if err != nil {
+			return nil, err
+		}
+		name, err := binaryMapKey(key, keyOffset)
+		if err != nil {
+			return nil, err
+		}
+		value, err := item(r)
+		if err != nil {
+			return nil, err
+		}
+		object.set(name, value)
+	}
+	return object, nil
+}
+
+// binaryMapKey converts a decoded map key to a JSON member name: strings as is, numbers, booleans
+// and null as their JSON text, byte strings in base64
+func binaryMapKey(key interface{}, offset int) (string, error) {
+	switch key := key.(type) {
+	case string:
+		return key, nil
+	case json.Number:
+		return string(key), nil
+	case bool:
+		return strconv.FormatBool(key), nil
+	case nil:
+		return "null", nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(key), nil
+	}
+	return "", fmt.Errorf(localize("unsupported map key at offset %d"), offset)
+}
+
+// binaryFloat converts a decoded float to a JSON number formatted like encoding/json does, with
+// the precision of its encoding so that a float32 0.1 stays 0.1. JSON has no NaN or infinities,
+// which become null.
+func binaryFloat(f float64, bits int) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	return json.Number(strconv.FormatFloat(f, format, -1, bits))
+}
+
+// readMsgPack reads a MessagePack value. Binary data becomes base64 strings, timestamps (extension
+// type -1) RFC 3339 strings and other extensions {"type": n, "data": base64} objects.
+func readMsgPack(r *binaryReader) (interface{}, error) {
+	offset := r.pos
+	c, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return json.Number(strconv.Itoa(int(c))), nil
+	case c >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(c)))), nil
+	case c <= 0x8f:
+		return readBinaryMap(r, uint64(c&0x0f), false, offset, readMsgPack)
+	case c <= 0x9f:
+		return readBinaryArray(r, uint64(c&0x0f), false, offset, readMsgPack)
+	case c <= 0xbf:
+		data, err := r.next(uint64(c & 0x1f))
+		return string(data), err
+	}
+
+	var n uint64
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, nil
+	case 0xc4, 0xc5, 0xc6:
+		if n, err = r.bigEndian(1 << (c - 0xc4)); err != nil {
+			return nil, err
+		}
+		data, err := r.next(n)
+		return append([]byte(nil), data...), err
+	case 0xc7, 0xc8, 0xc9, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		if c <= 0xc9 {
+			n, err = r.bigEndian(1 << (c - 0xc7))
+		} else {
+			n = 1 << (c - 0xd4)
+		}
+		if err != nil {
+			return nil, err
+		}
+		kind, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return msgPackExtension(int8(kind), data, offset)
+	case 0xca:
+		bits, err := r.bigEndian(4)
+		return binaryFloat(float64(math.Float32frombits(uint32(bits))), 32), err
+	case 0xcb:
+		bits, err := r.bigEndian(8)
+		return binaryFloat(math.Float64frombits(bits), 64), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err = r.bigEndian(1 << (c - 0xcc))
+		return json.Number(strconv.FormatUint(n, 10)), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err = r.bigEndian(size)
+		// Sign-extend the size-byte two's complement value
+		shift := 64 - 8*size
+		return json.Number(strconv.FormatInt(int64(n<<shift)>>shift, 10)), err
+	case 0xd9, 0xda, 0xdb:
+		if n, err = r.bigEndian(1 << (c - 0xd9)); err != nil {
+			return nil, err
+		}
+		data, err := r.next(n)
+		return string(data), err
+	case 0xdc, 0xdd:
+		if n, err = r.bigEndian(2 << (c - 0xdc)); err != nil {
+			return nil, err
+		}
+		return readBinaryArray(r, n, false, offset, readMsgPack)
+	case 0xde, 0xdf:
+		if n, err = r.bigEndian(2 << (c - 0xde)); err != nil {
+			return nil, err
+		}
+		return readBinaryMap(r, n, false, offset, readMsgPack)
+	}
+	return nil, fmt.Errorf(localize("invalid byte 0x%02x at offset %d"), c, offset)
+}
+
+// msgPackExtension decodes an extension value: the timestamp type (-1) in its 32, 64 and 96-bit
+// forms, other types as their type number and base64 data
+func msgPackExtension(kind int8, data []byte, offset int) (interface{}, error) {
+	if kind != -1 {
+		return map[string]interface{}{"type": int(kind), "data": append([]byte(nil), data...)}, nil
+	}
+
+	var seconds, nanoseconds int64
+	switch len(data) {
+	case 4:
+		seconds = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		n := binary.BigEndian.Uint64(data)
+		seconds, nanoseconds = int64(n&(1<<34-1)), int64(n>>34)
+	case 12:
+		nanoseconds = int64(binary.BigEndian.Uint32(data))
+		seconds = int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return nil, fmt.Errorf(localize("invalid timestamp at offset %d"), offset)
+	}
+	return time.Unix(seconds, nanoseconds).UTC().Format(time.RFC3339Nano), nil
+}
+
+// readCBOR reads a CBOR data item, definite or indefinite-length. Byte strings become base64
+// strings, epoch and date tags (0, 1) RFC 3339 strings and bignums (2, 3) exact JSON numbers;
+// the content of other tags is kept as is.
+func readCBOR(r *binaryReader) (interface{}, error) {
+	offset := r.pos
+	initial, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := initial>>5, initial&0x1f
+	if major == 7 {
+		return readCBORSimple(r, info, offset)
+	}
+
+	var n uint64
+	indefinite := false
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		if n, err = r.bigEndian(1 << (info - 24)); err != nil {
+			return nil, err
+		}
+	case info == 31 && major >= 2 && major <= 5:
+		indefinite = true
+	default:
+		return nil, fmt.Errorf(localize("invalid byte 0x%02x at offset %d"), initial, offset)
+	}
+
+	switch major {
+	case 0:
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case 1:
+		// The argument n stands for -1 - n
+		return json.Number(new(big.Int).Not(new(big.Int).SetUint64(n)).String()), nil
+	case 2, 3:
+		var data []byte
+		if !indefinite {
+			chunk, err := r.next(n)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, chunk...)
+		}
+		// Indefinite-length strings are definite-length chunks of the same major type up to a break
+		for indefinite {
+			chunkOffset := r.pos
+			head, err := r.readByte()
+			if err != nil {
+				return nil, err
+			}
+			if head == 0xff {
+				break
+			}
+			if head>>5 != major || head&0x1f > 27 {
+				return nil, fmt.Errorf(localize("invalid byte 0x%02x at offset %d"), head, chunkOffset)
+			}
+			length := uint64(head & 0x1f)
+			if length >= 24 {
+				if length, err = r.bigEndian(1 << (length - 24)); err != nil {
+					return nil, err
+				}
+			}
+			chunk, err := r.next(length)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, chunk...)
+		}
+		if major == 3 {
+			return string(data), nil
+		}
+		if data == nil {
+			data = []byte{}
+		}
+		return data, nil
+	case 4:
+		return readBinaryArray(r, n, indefinite, offset, readCBOR)
+	case 5:
+		return readBinaryMap(r, n, indefinite, offset, readCBOR)
+	}
+
+	// Major type 6: a tag number n followed by its content
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	content, err := readCBOR(r)
+	if err != nil {
+		return nil, err
+	}
+	switch n {
+	case 0:
+		if _, ok := content.(string); ok {
+			return content, nil
+		}
+	case 1:
+		if number, ok := content.(json.Number); ok {
+			seconds, err := number.Float64()
+			if err == nil {
+				whole, fraction := math.Modf(seconds)
+				return time.Unix(int64(whole), int64(math.Round(fraction*1e9))).UTC().Format(time.RFC3339Nano), nil
+			}
+		}
+	case 2, 3:
+		if data, ok := content.([]byte); ok {
+			integer := new(big.Int).SetBytes(data)
+			if n == 3 {
+				integer.Not(integer)
+			}
+			return json.Number(integer.String()), nil
+		}
+	default:
+		return content, nil
+	}
+	return nil, fmt.Errorf(localize("invalid content for tag %d at offset %d"), n, offset)
+}
+
+// readCBORSimple reads the simple values and floats of major type 7
+func readCBORSimple(r *binaryReader, info byte, offset int) (interface{}, error) {
+	switch info {
+	case 20, 21:
+		return info == 21, nil
+	case 22, 23:
+		// null and undefined
+		return nil, nil
+	case 25:
+		bits, err := r.bigEndian(2)
+		return binaryFloat(float16Value(uint16(bits)), 32), err
+	case 26:
+		bits, err := r.bigEndian(4)
+		return binaryFloat(float64(math.Float32frombits(uint32(bits))), 32), err
+	case 27:
+		bits, err := r.bigEndian(8)
+		return binaryFloat(math.Float64frombits(bits), 64), err
+	case 31:
+		return nil, fmt.Errorf(localize("unexpected break at offset %d"), offset)
+	case 24:
+		value, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf(localize("unsupported simple value %d at offset %d"), value, offset)
+	}
+	if info < 20 {
+		return nil, fmt.Errorf(localize("unsupported simple value %d at offset %d"), info, offset)
+	}
+	return nil, fmt.Errorf(localize("invalid byte 0x%02x at offset %d"), 0xe0|info, offset)
+}
+
+// readBSONDocument reads a BSON document as MongoDB Extended JSON v2 in relaxed mode: numbers as
+// JSON numbers, other types as $oid, $date, $binary, $numberDecimal... wrappers
+func readBSONDocument(r *binaryReader) (interface{}, error) {
+	return readBSONElements(r, false)
+}
+
+// readBSONElements reads a document, or the elements of an array document as a JSON array
+func readBSONElements(r *binaryReader, array bool) (interface{}, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	offset := r.pos
+	size32, err := r.littleEndian(4)
+	if err != nil {
+		return nil, err
+	}
+	size := int(int32(size32))
+	if size < 5 || size > len(r.data)-offset {
+		return nil, fmt.Errorf(localize("invalid document size %d at offset %d"), size, offset)
+	}
+	end := offset + size - 1
+	if r.data[end] != 0 {
+		return nil, fmt.Errorf(localize("unterminated document at offset %d"), offset)
+	}
+
+	object := &jsonObject{values: map[string]interface{}{}}
+	items := []interface{}{}
+	for r.pos < end {
+		elementOffset := r.pos
+		kind, _ := r.readByte()
+		name, err := r.cstring()
+		if err != nil {
+			return nil, err
+		}
+		value, err := readBSONValue(r, kind, elementOffset)
+		if err != nil {
+			return nil, err
+		}
+		if array {
+			items = append(items, value)
+		} else {
+			object.set(name, value)
+		}
+	}
+	if r.pos != end {
+		return nil, fmt.Errorf(localize("invalid document size %d at offset %d"), size, offset)
+	}
+	r.pos = end + 1
+
+	if array {
+		return items, nil
+	}
+	return object, nil
+}
+
+// cstring reads a NUL-terminated string
+func (r *binaryReader) cstring() (string, error) {
+	length := bytes.IndexByte(r.data[r.pos:], 0)
+	if length < 0 {
+		return "", fmt.Errorf(localize("unterminated string at offset %d"), r.pos)
+	}
+	s := string(r.data[r.pos : r.pos+length])
+	r.pos += length + 1
+	return s, nil
+}
+
+// bsonString reads a length-prefixed, NUL-terminated string
+func (r *binaryReader) bsonString() (string, error) {
+	offset := r.pos
+	length, err := r.littleEndian(4)
+	if err != nil {
+		return "", err
+	}
+	if int32(length) < 1 {
+		return "", fmt.Errorf(localize("invalid string length %d at offset %d"), int32(length), offset)
+	}
+	data, err := r.next(length)
+	if err != nil {
+		return "", err
+	}
+	if data[len(data)-1] != 0 {
+		return "", fmt.Errorf(localize("unterminated string at offset %d"), offset)
+	}
+	return string(data[:len(data)-1]), nil
+}
+
+// readBSONValue reads the value of an element of the given type
+func readBSONValue(r *binaryReader, kind byte, offset int) (interface{}, error) {
+	switch kind {
+	case 0x01:
+		bits, err := r.littleEndian(8)
+		f := math.Float64frombits(bits)
+		switch {
+		case math.IsNaN(f):
+			return map[string]interface{}{"$numberDouble": "NaN"}, err
+		case math.IsInf(f, 1):
+			return map[string]interface{}{"$numberDouble": "Infinity"}, err
+		case math.IsInf(f, -1):
+			return map[string]interface{}{"$numberDouble": "-Infinity"}, err
+		}
+		return binaryFloat(f, 64), err
+	case 0x02:
+		return r.bsonString()
+	case 0x03:
+		return readBSONElements(r, false)
+	case 0x04:
+		return readBSONElements(r, true)
+	case 0x05:
+		length, err := r.littleEndian(4)
+		if err != nil {
+			return nil, err
+		}
+		if int32(length) < 0 {
+			return nil, fmt.Errorf(localize("invalid binary length %d at offset %d"), int32(length), offset)
+		}
+		subtype, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.next(length)
+		if err != nil {
+			return nil, err
+		}
+		// The deprecated subtype 2 repeats the length inside the data
+		if subtype == 0x02 && len(data) >= 4 {
+			data = data[4:]
+		}
+		return map[string]interface{}{"$binary": map[string]interface{}{
+			"base64":  base64.StdEncoding.EncodeToString(data),
+			"subType": fmt.Sprintf("%02x", subtype),
+		}}, nil
+	case 0x06:
+		return map[string]interface{}{"$undefined": true}, nil
+	case 0x07:
+		id, err := r.next(12)
+		return map[string]interface{}{"$oid": hex.EncodeToString(id)}, err
+	case 0x08:
+		c, err := r.readByte()
+		if err == nil && c > 1 {
+			err = fmt.Errorf(localize("invalid boolean 0x%02x at offset %d"), c, offset)
+		}
+		return c == 1, err
+	case 0x09:
+		milliseconds, err := r.littleEndian(8)
+		return bsonDate(int64(milliseconds)), err
+	case 0x0a:
+		return nil, nil
+	case 0x0b:
+		pattern, err := r.cstring()
+		if err != nil {
+			return nil, err
+		}
+		options, err := r.cstring()
+		return map[string]interface{}{"$regularExpression": map[string]interface{}{"pattern": pattern, "options": options}}, err
+	case 0x0c:
+		namespace, err := r.bsonString()
+		if err != nil {
+			return nil, err
+		}
+		id, err := r.next(12)
+		return map[string]interface{}{"$dbPointer": map[string]interface{}{
+			"$ref": namespace,
+			"$id":  map[string]interface{}{"$oid": hex.EncodeToString(id)},
+		}}, err
+	case 0x0d:
+		code, err := r.bsonString()
+		return map[string]interface{}{"$code": code}, err
+	case 0x0e:
+		symbol, err := r.bsonString()
+		return map[string]interface{}{"$symbol": symbol}, err
+	case 0x0f:
+		// The total length of the code and scope precedes them
+		if _, err := r.littleEndian(4); err != nil {
+			return nil, err
+		}
+		code, err := r.bsonString()
+		if err != nil {
+			return nil, err
+		}
+		scope, err := readBSONElements(r, false)
+		return map[string]interface{}{"$code": code, "$scope": scope}, err
+	case 0x10:
+		n, err := r.littleEndian(4)
+		return json.Number(strconv.FormatInt(int64(int32(n)), 10)), err
+	case 0x11:
+		n, err := r.littleEndian(8)
+		return map[string]interface{}{"$timestamp": map[string]interface{}{"t": uint32(n >> 32), "i": uint32(n)}}, err
+	case 0x12:
+		n, err := r.littleEndian(8)
+		return json.Number(strconv.FormatInt(int64(n), 10)), err
+	case 0x13:
+		low, err := r.littleEndian(8)
+		if err != nil {
+			return nil, err
+		}
+		high, err := r.littleEndian(8)
+		return map[string]interface{}{"$numberDecimal": decimal128String(high, low)}, err
+	case 0xff:
+		return map[string]interface{}{"$minKey": 1}, nil
+	case 0x7f:
+		return map[string]interface{}{"$maxKey": 1}, nil
+	}
+	return nil, fmt.Errorf(localize("unknown element type 0x%02x at offset %d"), kind, offset)
+}
+
+// bsonDate formats a UTC datetime in milliseconds since the epoch: an ISO-8601 string for years
+// 1970 to 9999, as relaxed Extended JSON does, the number of milliseconds otherwise
+func bsonDate(milliseconds int64) interface{} {
+	t := time.UnixMilli(milliseconds).UTC()
+	if t.Year() < 1970 || t.Year() > 9999 {
+		return map[string]interface{}{"$date": map[string]interface{}{"$numberLong": strconv.FormatInt(milliseconds, 10)}}
+	}
+	return map[string]interface{}{"$date": t.Format("2006-01-02T15:04:05.999Z07:00")}
+}
+
+// decimal128String formats an IEEE 754-2008 decimal128 value (BID encoding) as the BSON
+// specification does: plain notation for small exponents, scientific notation otherwise
+func decimal128String(high, low uint64) string {
+	sign := ""
+	if high>>63 != 0 {
+		sign = "-"
+	}
+
+	var exponent int
+	coefficient := new(big.Int)
+	if high>>61&3 == 3 {
+		switch high >> 58 & 0x1f {
+		case 0x1e:
+			return sign + "Infinity"
+		case 0x1f:
+			return "NaN"
+		}
+		// The coefficient of this form would exceed 10^34 - 1, so it is zero
+		exponent = int(high>>47&0x3fff) - 6176
+	} else {
+		exponent = int(high>>49&0x3fff) - 6176
+		coefficient.SetUint64(high & (1<<49 - 1))
+		coefficient.Lsh(coefficient, 64)
+		coefficient.Or(coefficient, new(big.Int).SetUint64(low))
+		if coefficient.Cmp(new(big.Int).Exp(big.NewInt(10), big.NewInt(34), nil)) >= 0 {
+			coefficient.SetInt64(0)
+		}
+	}
+
+	digits := coefficient.String()
+	adjusted := exponent + len(digits) - 1
+	if exponent <= 0 && adjusted >= -6 {
+		if exponent == 0 {
+			return sign + digits
+		}
+		point := len(digits) + exponent
+		if point > 0 {
+			return sign + digits[:point] + "." + digits[point:]
+		}
+		return sign + "0." + strings.Repeat("0", -point) + digits
+	}
+
+	s := sign + digits[:1]
+	if len(digits) > 1 {
+		s += "." + digits[1:]
+	}
+	if adjusted >= 0 {
+		return s + "E+" + strconv.Itoa(adjusted)
+	}
+	return s + "E" + strconv.Itoa(adjusted)
+}
+
+// Protocol Buffers for loadProtoDescriptor, encodeProto and decodeProto: types come from a
+// serialized FileDescriptorSet and messages map to JSON as the proto3 JSON mapping specifies
+
+// protoMessages and protoEnums hold the types registered by loadProtoDescriptor, by full name
+var (
+	protoMessages = map[string]*protoMessage{}
+	protoEnums    = map[string]*protoEnum{}
+)
+
+// Field types of FieldDescriptorProto
+const (
+	protoTypeDouble   = 1
+	protoTypeFloat    = 2
+	protoTypeInt64    = 3
+	protoTypeUint64   = 4
+	protoTypeInt32    = 5
+	protoTypeFixed64  = 6
+	protoTypeFixed32  = 7
+	protoTypeBool     = 8
+	protoTypeString   = 9
+	protoTypeGroup    = 10
+	protoTypeMessage  = 11
+	protoTypeBytes    = 12
+	protoTypeUint32   = 13
+	protoTypeEnum     = 14
+	protoTypeSfixed32 = 15
+	protoTypeSfixed64 = 16
+	protoTypeSint32   = 17
+	protoTypeSint64   = 18
+)
+
+// protoTypeNames names the field types in error messages
+var protoTypeNames = []string{"", "double", "float", "int64", "uint64", "int32", "fixed64", "fixed32", "bool",
+	"string", "group", "message", "bytes", "uint32", "enum", "sfixed32", "sfixed64", "sint32", "sint64"}
+
+// protoFile is a file of a descriptor set with the types it declares, nested ones included
+type protoFile struct {
+	name     string
+	messages []*protoMessage
+	enums    []*protoEnum
+}
+
+// protoMessage is a message type, its fields in declaration order and looked up by number or by
+// proto and JSON name
+type protoMessage struct {
+	name     string
+	fields   []*protoField
+	byNumber map[int32]*protoField
+	byName   map[string]*protoField
+	mapEntry bool
+}
+
+// protoField is a field of a message. Presence tells whether a default value is still written and
+// decoded: always in proto2 and editions, for optional, oneof and message fields in proto3.
+type protoField struct {
+	name     string
+	jsonName string
+	number   int32
+	kind     int
+	typeName string
+	repeated bool
+	packed   bool
+	presence bool
+	oneof    int
+}
+
+// protoEnum is an enum type with the names of its values by number and their numbers by name
+type protoEnum struct {
+	name    string
+	names   map[int32]string
+	numbers map[string]int32
+}
+
+// protoMessageType returns a registered message type, with or without its leading dot
+func protoMessageType(name string) (*protoMessage, error) {
+	m := protoMessages[strings.TrimPrefix(name, ".")]
+	if m == nil {
+		return nil, errors.New(localize("Unknown message type %q, load its descriptor first", name))
+	}
+	return m, nil
+}
+
+// protoMapEntry returns the entry type of a map field, nil for other fields
+func protoMapEntry(field *protoField) *protoMessage {
+	if !field.repeated || field.kind != protoTypeMessage {
+		return nil
+	}
+	if entry := protoMessages[field.typeName]; entry != nil && entry.mapEntry {
+		return entry
+	}
+	return nil
+}
+
+// protoWireType is the wire type of a field type: varint 0, 64-bit 1, length-delimited 2, group 3
+// and 32-bit 5
+func protoWireType(kind int) int {
+	switch kind {
+	case protoTypeDouble, protoTypeFixed64, protoTypeSfixed64:
+		return 1
+	case protoTypeString, protoTypeBytes, protoTypeMessage:
+		return 2
+	case protoTypeGroup:
+		return 3
+	case protoTypeFloat, protoTypeFixed32, protoTypeSfixed32:
+		return 5
+	}
+	return 0
+}
+
+// protoVarint reads a base 128 varint of up to 10 bytes
+func protoVarint(r *binaryReader) (uint64, error) {
+	offset := r.pos
+	var n uint64
+	for shift := uint(0); shift < 70; shift += 7 {
+		c, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		n |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf(localize("varint longer than 10 bytes at offset %d"), offset)
+}
+
+// protoTag reads a field tag: the field number and the wire type
+func protoTag(r *binaryReader) (int32, int, error) {
+	offset := r.pos
+	key, err := protoVarint(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	if number := key >> 3; number == 0 || number > 1<<29-1 {
+		return 0, 0, fmt.Errorf(localize("invalid field number %d at offset %d"), number, offset)
+	}
+	return int32(key >> 3), int(key & 7), nil
+}
+
+// protoWire calls visit with each field of an encoded message: its number, its wire type, and the
+// integer of a varint or fixed-size field or the bytes of a length-delimited field or group
+func protoWire(data []byte, visit func(number int32, wire int, n uint64, b []byte) error) error {
+	r := &binaryReader{data: data}
+	for r.pos < len(r.data) {
+		offset := r.pos
+		number, wire, err := protoTag(r)
+		if err != nil {
+			return err
+		}
+		var n uint64
+		var b []byte
+		switch wire {
+		case 0:
+			n, err = protoVarint(r)
+		case 1:
+			n, err = r.littleEndian(8)
+		case 2:
+			if n, err = protoVarint(r); err == nil {
+				b, err = r.next(n)
+			}
+		case 3:
+			start := r.pos
+			var end int
+			if end, err = protoGroupEnd(r, number); err == nil {
+				b = r.data[start:end]
+			}
+		case 5:
+			n, err = r.littleEndian(4)
+		default:
+			err = fmt.Errorf(localize("invalid wire type %d at offset %d"), wire, offset)
+		}
+		if err == nil {
+			err = visit(number, wire, n, b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protoGroupEnd skips the fields of a group up to its end tag and returns where that tag starts
+func protoGroupEnd(r *binaryReader, number int32) (int, error) {
+	if err := r.enter(); err != nil {
+		return 0, err
+	}
+	defer r.leave()
+	for r.pos < len(r.data) {
+		offset := r.pos
+		field, wire, err := protoTag(r)
+		if err != nil {
+			return 0, err
+		}
+		switch wire {
+		case 0:
+			_, err = protoVarint(r)
+		case 1:
+			_, err = r.next(8)
+		case 2:
+			var n uint64
+			if n, err = protoVarint(r); err == nil {
+				_, err = r.next(n)
+			}
+		case 3:
+			_, err = protoGroupEnd(r, field)
+		case 4:
+			if field == number {
+				return offset, nil
+			}
+			err = fmt.Errorf(localize("unexpected end of group %d at offset %d"), field, offset)
+		case 5:
+			_, err = r.next(4)
+		default:
+			err = fmt.Errorf(localize("invalid wire type %d at offset %d"), wire, offset)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf(localize("group %d has no end tag"), number)
+}
+
+// protoAppendVarint writes a base 128 varint
+func protoAppendVarint(b *bytes.Buffer, n uint64) {
+	for n >= 0x80 {
+		b.WriteByte(byte(n) | 0x80)
+		n >>= 7
+	}
+	b.WriteByte(byte(n))
+}
+
+// protoAppendTag writes the tag of a field
+func protoAppendTag(b *bytes.Buffer, number int32, wire int) {
+	protoAppendVarint(b, uint64(number)<<3|uint64(wire))
+}
+
+// parseProtoDescriptorSet reads a FileDescriptorSet, whose field 1 holds each FileDescriptorProto
+func parseProtoDescriptorSet(data []byte) ([]*protoFile, error) {
+	var files []*protoFile
+	err := protoWire(data, func(number int32, wire int, _ uint64, b []byte) error {
+		if number != 1 || wire != 2 {
+			return nil
+		}
+		file, err := parseProtoFile(b)
+		if err == nil {
+			files = append(files, file)
+		}
+		return err
+	})
+	if err == nil && len(files) == 0 {
+		err = errors.New(localize("no file descriptors found"))
+	}
+	return files, err
+}
+
+// parseProtoFile reads a FileDescriptorProto: name (1), package (2), message types (4), enums (5)
+// and syntax (12), empty for proto2
+func parseProtoFile(data []byte) (*protoFile, error) {
+	file := &protoFile{}
+	var pkg, syntax string
+	var messages, enums [][]byte
+	err := protoWire(data, func(number int32, wire int, _ uint64, b []byte) error {
+		if wire != 2 {
+			return nil
+		}
+		switch number {
+		case 1:
+			file.name = string(b)
+		case 2:
+			pkg = string(b)
+		case 4:
+			messages = append(messages, b)
+		case 5:
+			enums = append(enums, b)
+		case 12:
+			syntax = string(b)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range enums {
+		enum, err := parseProtoEnum(b, pkg)
+		if err != nil {
+			return nil, err
+		}
+		file.enums = append(file.enums, enum)
+	}
+	for _, b := range messages {
+		if err := file.parseMessage(b, pkg, syntax); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// protoQualify prefixes a type name with its package or enclosing message
+func protoQualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// parseMessage reads a DescriptorProto: name (1), fields (2), nested types (3), enums (4) and
+// options (7), where map_entry is field 7
+func (file *protoFile) parseMessage(data []byte, scope, syntax string) error {
+	m := &protoMessage{byNumber: map[int32]*protoField{}, byName: map[string]*protoField{}}
+	var fields, nested, enums [][]byte
+	err := protoWire(data, func(number int32, wire int, _ uint64, b []byte) error {
+		if wire != 2 {
+			return nil
+		}
+		switch number {
+		case 1:
+			m.name = string(b)
+		case 2:
+			fields = append(fields, b)
+		case 3:
+			nested = append(nested, b)
+		case 4:
+			enums = append(enums, b)
+		case 7:
+			return protoWire(b, func(number int32, wire int, n uint64, _ []byte) error {
+				if number == 7 && wire == 0 {
+					m.mapEntry = n != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if m.name == "" {
+		return errors.New(localize("message type without a name in %s", file.name))
+	}
+	m.name = protoQualify(scope, m.name)
+
+	for _, b := range fields {
+		field, err := parseProtoField(b, syntax)
+		if err != nil {
+			return fmt.Errorf("%s: %v", m.name, err)
+		}
+		m.fields = append(m.fields, field)
+		m.byNumber[field.number] = field
+		m.byName[field.name] = field
+		m.byName[field.jsonName] = field
+	}
+	file.messages = append(file.messages, m)
+
+	for _, b := range enums {
+		enum, err := parseProtoEnum(b, m.name)
+		if err != nil {
+			return err
+		}
+		file.enums = append(file.enums, enum)
+	}
+	for _, b := range nested {
+		if err := file.parseMessage(b, m.name, syntax); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseProtoField reads a FieldDescriptorProto: name (1), number (3), label (4), type (5), type name
+// (6), options (8), where packed is field 2, oneof index (9), JSON name (10) and proto3 optional (17)
+func parseProtoField(data []byte, syntax string) (*protoField, error) {
+	field := &protoField{oneof: -1}
+	var label uint64
+	var optional bool
+	packed := -1
+	err := protoWire(data, func(number int32, wire int, n uint64, b []byte) error {
+		switch {
+		case number == 1 && wire == 2:
+			field.name = string(b)
+		case number == 3 && wire == 0:
+			field.number = int32(n)
+		case number == 4 && wire == 0:
+			label = n
+		case number == 5 && wire == 0:
+			field.kind = int(n)
+		case number == 6 && wire == 2:
+			field.typeName = strings.TrimPrefix(string(b), ".")
+		case number == 8 && wire == 2:
+			return protoWire(b, func(number int32, wire int, n uint64, _ []byte) error {
+				if number == 2 && wire == 0 {
+					packed = int(n)
+				}
+				return nil
+			})
+		case number == 9 && wire == 0:
+			field.oneof = int(n)
+		case number == 10 && wire == 2:
+			field.jsonName = string(b)
+		case number == 17 && wire == 0:
+			optional = n != 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if field.name == "" || field.number <= 0 || field.kind < protoTypeDouble || field.kind > protoTypeSint64 {
+		return nil, errors.New(localize("invalid field %q", field.name))
+	}
+	if field.jsonName == "" {
+		field.jsonName = protoJSONName(field.name)
+	}
+
+	proto2 := syntax == "" || syntax == "proto2"
+	field.repeated = label == 3
+	if field.repeated && protoWireType(field.kind) != 2 && field.kind != protoTypeGroup {
+		field.packed = packed == 1 || packed == -1 && !proto2
+	}
+	field.presence = !field.repeated && (syntax != "proto3" || optional || field.oneof >= 0 ||
+		field.kind == protoTypeMessage || field.kind == protoTypeGroup)
+	return field, nil
+}
+
+// parseProtoEnum reads an EnumDescriptorProto: name (1) and values (2), each with a name (1) and a
+// number (2)
+func parseProtoEnum(data []byte, scope string) (*protoEnum, error) {
+	enum := &protoEnum{names: map[int32]string{}, numbers: map[string]int32{}}
+	err := protoWire(data, func(number int32, wire int, _ uint64, b []byte) error {
+		switch {
+		case number == 1 && wire == 2:
+			enum.name = string(b)
+		case number == 2 && wire == 2:
+			var name string
+			var value int32
+			err := protoWire(b, func(number int32, wire int, n uint64, b []byte) error {
+				if number == 1 && wire == 2 {
+					name = string(b)
+				} else if number == 2 && wire == 0 {
+					value = int32(n)
+				}
+				return nil
+			})
+			// Aliases share a number: the first name is the one decoded
+			if _, seen := enum.names[value]; !seen {
+				enum.names[value] = name
+			}
+			enum.numbers[name] = value
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	enum.name = protoQualify(scope, enum.name)
+	return enum, nil
+}
+
+// protoJSONName derives the JSON name of a field as protoc does: underscores are dropped and the
+// letter after each one is capitalized
+func protoJSONName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(c))
+			upper = false
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// protoDecodeOptions configures decodeProto, with the option names of protobuf-es: UseProtoNames
+// keys fields by their .proto name rather than lowerCamelCase, EmitDefaults also writes fields left
+// at their default value and EnumsAsIntegers writes enum numbers rather than names
+type protoDecodeOptions struct {
+	UseProtoNames   bool `json:"useProtoNames"`
+	EmitDefaults    bool `json:"emitDefaults"`
+	EnumsAsIntegers bool `json:"enumsAsIntegers"`
+}
+
+// message decodes a message of type m to its JSON value
+func (o protoDecodeOptions) message(m *protoMessage, data []byte, depth int) (interface{}, error) {
+	if depth > binaryMaxDepth {
+		return nil, errors.New(localize("messages nested deeper than %d levels", binaryMaxDepth))
+	}
+	if protoHasJSONForm(m.name) || m.name == "google.protobuf.Any" {
+		// Well-known types are read field by field under their .proto names, then converted
+		fields, err := protoDecodeOptions{UseProtoNames: true}.fields(m, data, depth)
+		if err != nil {
+			return nil, err
+		}
+		return o.wellKnown(m, fields, depth)
+	}
+	return o.fields(m, data, depth)
+}
+
+// fields decodes the fields of a message into an object in declaration order. Unknown fields are
+// skipped, the last value of a singular field wins and embedded messages repeated on the wire merge.
+func (o protoDecodeOptions) fields(m *protoMessage, data []byte, depth int) (*jsonObject, error) {
+	values := map[*protoField]interface{}{}
+	embedded := map[*protoField][]byte{}
+	err := protoWire(data, func(number int32, wire int, n uint64, b []byte) error {
+		field := m.byNumber[number]
+		if field == nil {
+			return nil
+		}
+
+		if field.repeated && wire == 2 && protoWireType(field.kind) != 2 && field.kind != protoTypeGroup {
+			items, err := o.packed(field, b)
+			if err != nil {
+				return err
+			}
+			list, _ := values[field].([]interface{})
+			values[field] = append(list, items...)
+			return nil
+		}
+		if expected := protoWireType(field.kind); wire != expected {
+			return fmt.Errorf(localize("field %s has wire type %d, expected %d"), field.name, wire, expected)
+		}
+
+		if field.oneof >= 0 {
+			// Setting a member of a oneof clears the others
+			for _, other := range m.fields {
+				if other.oneof == field.oneof && other != field {
+					delete(values, other)
+					delete(embedded, other)
+				}
+			}
+		}
+
+		switch {
+		case field.repeated:
+			if entry := protoMapEntry(field); entry != nil {
+				key, value, err := o.mapEntry(entry, b, depth)
+				if err != nil {
+					return err
+				}
+				object, _ := values[field].(*jsonObject)
+				if object == nil {
+					object = &jsonObject{values: map[string]interface{}{}}
+					values[field] = object
+				}
+				object.set(key, value)
 				return nil
 			}
-		default:
-			return nil
+			value, err := o.value(field, n, b, depth)
+			if err != nil {
+				return err
+			}
+			list, _ := values[field].([]interface{})
+			values[field] = append(list, value)
+		case field.kind == protoTypeMessage || field.kind == protoTypeGroup:
+			embedded[field] = append(embedded[field], b...)
+			values[field] = nil
+		case !field.presence && n == 0 && len(b) == 0:
+			delete(values, field)
+		default:
+			value, err := o.value(field, n, b, depth)
+			if err != nil {
+				return err
+			}
+			values[field] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	object := &jsonObject{values: map[string]interface{}{}}
+	for _, field := range m.fields {
+		value, present := values[field]
+		if b, ok := embedded[field]; ok {
+			nested, err := protoMessageType(field.typeName)
+			if err == nil {
+				value, err = o.message(nested, b, depth+1)
+			}
+			if err != nil {
+				return nil, err
+			}
+		} else if !present {
+			if !o.EmitDefaults || field.oneof >= 0 {
+				continue
+			}
+			if value, err = o.defaultValue(field, depth); err != nil {
+				return nil, err
+			}
+		}
+
+		name := field.jsonName
+		if o.UseProtoNames {
+			name = field.name
+		}
+		object.set(name, value)
+	}
+	return object, nil
+}
+
+// packed decodes the values of a packed repeated field
+func (o protoDecodeOptions) packed(field *protoField, data []byte) ([]interface{}, error) {
+	r := &binaryReader{data: data}
+	items := []interface{}{}
+	for r.pos < len(r.data) {
+		var n uint64
+		var err error
+		switch protoWireType(field.kind) {
+		case 1:
+			n, err = r.littleEndian(8)
+		case 5:
+			n, err = r.littleEndian(4)
+		default:
+			n, err = protoVarint(r)
+		}
+		if err != nil {
+			return nil, err
+		}
+		value, err := o.value(field, n, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return items, nil
+}
+
+// mapEntry decodes a map entry, whose key is field 1 and value field 2, to a JSON member name and value
+func (o protoDecodeOptions) mapEntry(entry *protoMessage, data []byte, depth int) (string, interface{}, error) {
+	keyField, valueField := entry.byNumber[1], entry.byNumber[2]
+	if keyField == nil || valueField == nil {
+		return "", nil, errors.New(localize("invalid map entry type %s", entry.name))
+	}
+	var key, value interface{}
+	err := protoWire(data, func(number int32, wire int, n uint64, b []byte) error {
+		field := entry.byNumber[number]
+		if field == nil {
+			return nil
+		}
+		if expected := protoWireType(field.kind); wire != expected {
+			return fmt.Errorf(localize("field %s has wire type %d, expected %d"), field.name, wire, expected)
+		}
+		decoded, err := o.value(field, n, b, depth)
+		if number == 1 {
+			key = decoded
+		} else {
+			value = decoded
+		}
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if key == nil {
+		key, _ = o.value(keyField, 0, nil, depth)
+	}
+	if value == nil && valueField.typeName != "google.protobuf.Value" {
+		if valueField.kind == protoTypeMessage {
+			value, err = o.value(valueField, 0, nil, depth)
+		} else {
+			value, err = o.defaultValue(valueField, depth)
+		}
+	}
+	return fmt.Sprint(key), value, err
+}
+
+// value decodes one value of a field from its wire integer or bytes
+func (o protoDecodeOptions) value(field *protoField, n uint64, b []byte, depth int) (interface{}, error) {
+	switch field.kind {
+	case protoTypeInt32, protoTypeSfixed32:
+		return int32(n), nil
+	case protoTypeUint32, protoTypeFixed32:
+		return uint32(n), nil
+	case protoTypeSint32:
+		return int32(uint32(n)>>1) ^ -int32(n&1), nil
+	case protoTypeInt64, protoTypeSfixed64:
+		// 64-bit integers are strings in the JSON mapping, JavaScript numbers losing precision
+		return strconv.FormatInt(int64(n), 10), nil
+	case protoTypeUint64, protoTypeFixed64:
+		return strconv.FormatUint(n, 10), nil
+	case protoTypeSint64:
+		return strconv.FormatInt(int64(n>>1)^-int64(n&1), 10), nil
+	case protoTypeBool:
+		return n != 0, nil
+	case protoTypeFloat:
+		return protoFloatJSON(float64(math.Float32frombits(uint32(n))), 32), nil
+	case protoTypeDouble:
+		return protoFloatJSON(math.Float64frombits(n), 64), nil
+	case protoTypeEnum:
+		return o.enumValue(field.typeName, int32(n)), nil
+	case protoTypeString:
+		if !utf8.Valid(b) {
+			return nil, errors.New(localize("field %s holds invalid UTF-8", field.name))
+		}
+		return string(b), nil
+	case protoTypeBytes:
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	m, err := protoMessageType(field.typeName)
+	if err != nil {
+		return nil, err
+	}
+	return o.message(m, b, depth+1)
+}
+
+// defaultValue is the JSON value written by EmitDefaults for a field absent from the message
+func (o protoDecodeOptions) defaultValue(field *protoField, depth int) (interface{}, error) {
+	switch {
+	case protoMapEntry(field) != nil:
+		return &jsonObject{values: map[string]interface{}{}}, nil
+	case field.repeated:
+		return []interface{}{}, nil
+	case field.kind == protoTypeMessage || field.kind == protoTypeGroup:
+		return nil, nil
+	}
+	return o.value(field, 0, nil, depth)
+}
+
+// enumValue is the name of an enum value, or its number when unknown or with EnumsAsIntegers.
+// google.protobuf.NullValue is null.
+func (o protoDecodeOptions) enumValue(typeName string, n int32) interface{} {
+	if typeName == "google.protobuf.NullValue" {
+		return nil
+	}
+	if enum := protoEnums[typeName]; enum != nil && !o.EnumsAsIntegers {
+		if name, ok := enum.names[n]; ok {
+			return name
+		}
+	}
+	return n
+}
+
+// protoFloatJSON writes a float as a JSON number, or as the string NaN, Infinity or -Infinity
+func protoFloatJSON(f float64, bits int) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return binaryFloat(f, bits)
+}
+
+// protoWrappers are the wrapper types, written in JSON as their wrapped value
+var protoWrappers = map[string]bool{
+	"google.protobuf.DoubleValue": true, "google.protobuf.FloatValue": true,
+	"google.protobuf.Int64Value": true, "google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value": true, "google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue": true, "google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue": true,
+}
+
+// protoHasJSONForm tells whether a well-known type is written in JSON as something other than an
+// object of its fields; google.protobuf.Any is an object with an @type member besides
+func protoHasJSONForm(name string) bool {
+	switch name {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.Struct",
+		"google.protobuf.Value", "google.protobuf.ListValue", "google.protobuf.FieldMask":
+		return true
+	}
+	return protoWrappers[name]
+}
+
+// wellKnown converts the fields of a well-known type, keyed by .proto name, to its JSON form
+func (o protoDecodeOptions) wellKnown(m *protoMessage, fields *jsonObject, depth int) (interface{}, error) {
+	switch m.name {
+	case "google.protobuf.Timestamp":
+		seconds, nanos := protoSecondsNanos(fields)
+		if nanos < 0 || nanos > 999999999 || seconds < -62135596800 || seconds > 253402300799 {
+			return nil, errors.New(localize("invalid %s value", m.name))
+		}
+		return time.Unix(seconds, nanos).UTC().Format("2006-01-02T15:04:05") + protoFraction(nanos) + "Z", nil
+
+	case "google.protobuf.Duration":
+		seconds, nanos := protoSecondsNanos(fields)
+		if seconds < -315576000000 || seconds > 315576000000 || nanos <= -1e9 || nanos >= 1e9 ||
+			seconds < 0 && nanos > 0 || seconds > 0 && nanos < 0 {
+			return nil, errors.New(localize("invalid %s value", m.name))
+		}
+		sign := ""
+		if seconds < 0 || nanos < 0 {
+			sign, seconds, nanos = "-", -seconds, -nanos
+		}
+		return sign + strconv.FormatInt(seconds, 10) + protoFraction(nanos) + "s", nil
+
+	case "google.protobuf.Struct":
+		if object, ok := fields.values["fields"]; ok {
+			return object, nil
+		}
+		return &jsonObject{values: map[string]interface{}{}}, nil
+
+	case "google.protobuf.ListValue":
+		if list, ok := fields.values["values"]; ok {
+			return list, nil
+		}
+		return []interface{}{}, nil
+
+	case "google.protobuf.Value":
+		// A Value with its null_value set decodes to null too, so this reads the last member
+		if len(fields.keys) == 0 {
+			return nil, errors.New(localize("%s has none of its kinds set", m.name))
+		}
+		return fields.values[fields.keys[len(fields.keys)-1]], nil
+
+	case "google.protobuf.FieldMask":
+		paths, _ := fields.values["paths"].([]interface{})
+		camel := make([]string, len(paths))
+		for i, path := range paths {
+			segments := strings.Split(path.(string), ".")
+			for j, segment := range segments {
+				segments[j] = protoJSONName(segment)
+			}
+			camel[i] = strings.Join(segments, ".")
+		}
+		return strings.Join(camel, ","), nil
+
+	case "google.protobuf.Any":
+		typeURL, _ := fields.values["type_url"].(string)
+		if typeURL == "" {
+			return &jsonObject{values: map[string]interface{}{}}, nil
+		}
+		inner, err := protoMessageType(typeURL[strings.LastIndex(typeURL, "/")+1:])
+		if err != nil {
+			return nil, err
+		}
+		var data []byte
+		if value, ok := fields.values["value"].(string); ok {
+			data, _ = base64.StdEncoding.DecodeString(value)
 		}
+		value, err := o.message(inner, data, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		object := &jsonObject{values: map[string]interface{}{}}
+		object.set("@type", typeURL)
+		if members, ok := value.(*jsonObject); ok && !protoHasJSONForm(inner.name) && inner.name != m.name {
+			for _, key := range members.keys {
+				object.set(key, members.values[key])
+			}
+		} else {
+			object.set("value", value)
+		}
+		return object, nil
+	}
+
+	// Wrappers
+	if value, ok := fields.values["value"]; ok {
+		return value, nil
+	}
+	return o.value(m.byNumber[1], 0, nil, depth)
+}
+
+// protoSecondsNanos reads the seconds and nanos fields of a Timestamp or Duration
+func protoSecondsNanos(fields *jsonObject) (int64, int64) {
+	var seconds, nanos int64
+	if text, ok := fields.values["seconds"].(string); ok {
+		seconds, _ = strconv.ParseInt(text, 10, 64)
+	}
+	if n, ok := fields.values["nanos"].(int32); ok {
+		nanos = int64(n)
 	}
+	return seconds, nanos
+}
 
-	return current
+// protoFraction writes nanoseconds as a fraction of 0, 3, 6 or 9 digits
+func protoFraction(nanos int64) string {
+	switch {
+	case nanos == 0:
+		return ""
+	case nanos%1e6 == 0:
+		return fmt.Sprintf(".%03d", nanos/1e6)
+	case nanos%1e3 == 0:
+		return fmt.Sprintf(".%06d", nanos/1e3)
+	}
+	return fmt.Sprintf(".%09d", nanos)
 }
 
-func performBasicSchemaValidation(data interface{}, schema interface{}) []string {
-	var errors []string
+// protoEncodeOptions configures encodeProto: IgnoreUnknownFields skips members that are not fields
+// of the message rather than failing
+type protoEncodeOptions struct {
+	IgnoreUnknownFields bool `json:"ignoreUnknownFields"`
+}
 
-	schemaMap, ok := schema.(map[string]interface{})
+// message writes a JSON value as a message of type m, its fields in number order. path names the
+// value in error messages.
+func (e protoEncodeOptions) message(b *bytes.Buffer, m *protoMessage, value interface{}, path string, depth int) error {
+	if depth > binaryMaxDepth {
+		return errors.New(localize("messages nested deeper than %d levels", binaryMaxDepth))
+	}
+	if protoHasJSONForm(m.name) || m.name == "google.protobuf.Any" {
+		converted, err := e.wellKnown(m, value, path, depth)
+		if err != nil {
+			return err
+		}
+		value = converted
+	}
+	object, ok := value.(*jsonObject)
 	if !ok {
-		errors = append(errors, "Schema must be an object")
-		return errors
+		return fmt.Errorf(localize("%s: expected %s, got %s"), path, "object", protoJSONType(value))
 	}
 
-	// Check type
-	if expectedType, exists := schemaMap["type"]; exists {
-		actualType := getJSONType(data)
-		if expectedType.(string) != actualType {
-			errors = append(errors, fmt.Sprintf("Expected type %s, got %s",
-				expectedType, actualType))
+	set := map[*protoField]interface{}{}
+	oneofs := map[int]string{}
+	for _, key := range object.keys {
+		field := m.byName[key]
+		if field == nil {
+			if e.IgnoreUnknownFields {
+				continue
+			}
+			return fmt.Errorf(localize("%s: unknown field %q"), path, key)
+		}
+		if _, seen := set[field]; seen {
+			return fmt.Errorf(localize("%s: field %s is set twice"), path, field.name)
+		}
+		value := object.values[key]
+		if value == nil && field.typeName != "google.protobuf.Value" {
+			// null is the default value of any field
+			continue
 		}
+		if field.oneof >= 0 {
+			if other, seen := oneofs[field.oneof]; seen {
+				return fmt.Errorf(localize("%s: fields %s and %s of the same oneof are both set"), path, other, key)
+			}
+			oneofs[field.oneof] = key
+		}
+		set[field] = value
 	}
 
-	// Check required properties for objects
-	if required, exists := schemaMap["required"]; exists {
-		if dataMap, ok := data.(map[string]interface{}); ok {
-			if reqArray, ok := required.([]interface{}); ok {
-				for _, req := range reqArray {
-					if reqStr, ok := req.(string); ok {
-						if _, exists := dataMap[reqStr]; !exists {
-							errors = append(errors, fmt.Sprintf("Required property '%s' is missing", reqStr))
-						}
-					}
+	fields := make([]*protoField, 0, len(set))
+	for field := range set {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].number < fields[j].number })
+	for _, field := range fields {
+		if err := e.field(b, field, set[field], path+"."+field.jsonName, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field writes the value of a field: a map as one entry message per member, a repeated field
+// packed or element by element, and a singular one unless it is a default value without presence
+func (e protoEncodeOptions) field(b *bytes.Buffer, field *protoField, value interface{}, path string, depth int) error {
+	if entry := protoMapEntry(field); entry != nil {
+		object, ok := value.(*jsonObject)
+		if !ok {
+			return fmt.Errorf(localize("%s: expected %s, got %s"), path, "object", protoJSONType(value))
+		}
+		keyField, valueField := entry.byNumber[1], entry.byNumber[2]
+		if keyField == nil || valueField == nil {
+			return errors.New(localize("invalid map entry type %s", entry.name))
+		}
+		for _, key := range object.keys {
+			var item bytes.Buffer
+			var keyValue interface{} = key
+			if keyField.kind == protoTypeBool {
+				keyValue = key == "true"
+				if key != "true" && key != "false" {
+					return fmt.Errorf(localize("%s: invalid map key %q"), path, key)
 				}
 			}
+			itemPath := path + "[" + strconv.Quote(key) + "]"
+			if err := e.single(&item, keyField, keyValue, itemPath, depth, true); err != nil {
+				return err
+			}
+			if member := object.values[key]; member != nil || valueField.typeName == "google.protobuf.Value" {
+				if err := e.single(&item, valueField, member, itemPath, depth, true); err != nil {
+					return err
+				}
+			}
+			protoAppendTag(b, field.number, 2)
+			protoAppendVarint(b, uint64(item.Len()))
+			b.Write(item.Bytes())
+		}
+		return nil
+	}
+
+	if !field.repeated {
+		return e.single(b, field, value, path, depth, field.presence)
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf(localize("%s: expected %s, got %s"), path, "array", protoJSONType(value))
+	}
+	if field.packed {
+		var packed bytes.Buffer
+		for i, item := range items {
+			if err := e.scalar(&packed, field, item, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		if len(items) > 0 {
+			protoAppendTag(b, field.number, 2)
+			protoAppendVarint(b, uint64(packed.Len()))
+			b.Write(packed.Bytes())
+		}
+		return nil
+	}
+	for i, item := range items {
+		itemPath := path + "[" + strconv.Itoa(i) + "]"
+		if item == nil && field.typeName != "google.protobuf.Value" {
+			return fmt.Errorf(localize("%s: expected %s, got %s"), itemPath, protoTypeNames[field.kind], "null")
+		}
+		if err := e.single(b, field, item, itemPath, depth, true); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// single writes one value of a field with its tag; without presence a default value is left out
+func (e protoEncodeOptions) single(b *bytes.Buffer, field *protoField, value interface{}, path string, depth int, presence bool) error {
+	switch field.kind {
+	case protoTypeMessage, protoTypeGroup:
+		m, err := protoMessageType(field.typeName)
+		if err != nil {
+			return err
+		}
+		if field.kind == protoTypeGroup {
+			protoAppendTag(b, field.number, 3)
+			if err := e.message(b, m, value, path, depth+1); err != nil {
+				return err
+			}
+			protoAppendTag(b, field.number, 4)
+			return nil
+		}
+		var nested bytes.Buffer
+		if err := e.message(&nested, m, value, path, depth+1); err != nil {
+			return err
+		}
+		protoAppendTag(b, field.number, 2)
+		protoAppendVarint(b, uint64(nested.Len()))
+		b.Write(nested.Bytes())
+		return nil
+	}
 
-	return errors
+	var scalar bytes.Buffer
+	if err := e.scalar(&scalar, field, value, path); err != nil {
+		return err
+	}
+	// Zero, false, empty and the first enum value are all encoded as zero bytes, but not -0.0
+	if !presence && bytes.Count(scalar.Bytes(), []byte{0}) == scalar.Len() {
+		return nil
+	}
+	protoAppendTag(b, field.number, protoWireType(field.kind))
+	b.Write(scalar.Bytes())
+	return nil
 }
 
-func getJSONType(data interface{}) string {
-	switch data.(type) {
+// scalar writes a value of a scalar or enum field without its tag; strings and bytes carry their
+// length
+func (e protoEncodeOptions) scalar(b *bytes.Buffer, field *protoField, value interface{}, path string) error {
+	invalid := func() error {
+		switch value.(type) {
+		case string, json.Number:
+			return fmt.Errorf(localize("%s: invalid %s value %q"), path, protoTypeNames[field.kind], value)
+		}
+		return fmt.Errorf(localize("%s: expected %s, got %s"), path, protoTypeNames[field.kind], protoJSONType(value))
+	}
+
+	switch field.kind {
+	case protoTypeBool:
+		v, ok := value.(bool)
+		if !ok {
+			return invalid()
+		}
+		if v {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+
+	case protoTypeString, protoTypeBytes:
+		text, ok := value.(string)
+		if !ok {
+			return invalid()
+		}
+		data := []byte(text)
+		if field.kind == protoTypeBytes {
+			var err error
+			if data, err = protoBase64(text); err != nil {
+				return fmt.Errorf(localize("%s: invalid base64: %v"), path, err)
+			}
+		}
+		protoAppendVarint(b, uint64(len(data)))
+		b.Write(data)
+
+	case protoTypeFloat, protoTypeDouble:
+		f, ok := protoFloatValue(value)
+		if !ok {
+			return invalid()
+		}
+		if field.kind == protoTypeDouble {
+			b.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+			break
+		}
+		if !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			return fmt.Errorf(localize("%s: %v is out of range for %s"), path, value, "float")
+		}
+		b.Write(binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(f))))
+
+	case protoTypeEnum:
+		if name, ok := value.(string); ok {
+			enum := protoEnums[field.typeName]
+			number, known := int32(0), false
+			if enum != nil {
+				number, known = enum.numbers[name]
+			}
+			if !known {
+				return fmt.Errorf(localize("%s: unknown value %q of enum %s"), path, name, field.typeName)
+			}
+			protoAppendVarint(b, uint64(int64(number)))
+			break
+		}
+		if value == nil && field.typeName == "google.protobuf.NullValue" {
+			b.WriteByte(0)
+			break
+		}
+		fallthrough
+
+	default:
+		n, ok := protoIntegerValue(value)
+		if !ok {
+			return invalid()
+		}
+		bits, signed := 32, true
+		switch field.kind {
+		case protoTypeInt64, protoTypeSint64, protoTypeSfixed64:
+			bits = 64
+		case protoTypeUint32, protoTypeFixed32:
+			signed = false
+		case protoTypeUint64, protoTypeFixed64:
+			bits, signed = 64, false
+		}
+		min, max := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		if signed {
+			max.Rsh(max, 1)
+			min.Neg(max)
+		}
+		if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+			return fmt.Errorf(localize("%s: %v is out of range for %s"), path, value, protoTypeNames[field.kind])
+		}
+
+		var u uint64
+		if signed {
+			u = uint64(n.Int64())
+		} else {
+			u = n.Uint64()
+		}
+		switch field.kind {
+		case protoTypeSint32:
+			v := int32(n.Int64())
+			u = uint64(uint32(v<<1 ^ v>>31))
+		case protoTypeSint64:
+			v := n.Int64()
+			u = uint64(v<<1 ^ v>>63)
+		}
+		switch protoWireType(field.kind) {
+		case 1:
+			b.Write(binary.LittleEndian.AppendUint64(nil, u))
+		case 5:
+			b.Write(binary.LittleEndian.AppendUint32(nil, uint32(u)))
+		default:
+			protoAppendVarint(b, u)
+		}
+	}
+	return nil
+}
+
+// protoIntegerValue reads an integer given as a JSON number or a string, as the JSON mapping allows;
+// numbers written with a fraction or exponent must still be whole
+func protoIntegerValue(value interface{}) (*big.Int, bool) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = string(v)
+	case string:
+		text = strings.TrimSpace(v)
+	default:
+		return nil, false
+	}
+	integer, f, err := splitJSONNumber(json.Number(text))
+	if err != nil {
+		return nil, false
+	}
+	if integer == nil {
+		if math.IsInf(f, 0) || f != math.Trunc(f) {
+			return nil, false
+		}
+		integer, _ = new(big.Float).SetFloat64(f).Int(nil)
+	}
+	return integer, true
+}
+
+// protoFloatValue reads a float given as a JSON number or a string, NaN, Infinity and -Infinity included
+func protoFloatValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		switch v {
+		case "NaN":
+			return math.NaN(), true
+		case "Infinity":
+			return math.Inf(1), true
+		case "-Infinity":
+			return math.Inf(-1), true
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+	return 0, false
+}
+
+// protoBase64 decodes bytes in standard or URL-safe base64, padded or not
+func protoBase64(text string) ([]byte, error) {
+	if strings.ContainsAny(text, "-_") {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(text, "="))
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(text, "="))
+}
+
+// protoJSONType names the JSON type of a value in error messages
+func protoJSONType(value interface{}) string {
+	switch value.(type) {
 	case nil:
 		return "null"
 	case bool:
 		return "boolean"
-	case float64, int:
+	case json.Number:
 		return "number"
 	case string:
 		return "string"
 	case []interface{}:
 		return "array"
-	case map[string]interface{}:
-		return "object"
+	}
+	return "object"
+}
+
+// wellKnown converts the JSON form of a well-known type to an object of its fields keyed by .proto
+// name, which message then writes as any other
+func (e protoEncodeOptions) wellKnown(m *protoMessage, value interface{}, path string, depth int) (interface{}, error) {
+	fields := &jsonObject{values: map[string]interface{}{}}
+	text, isString := value.(string)
+	invalid := func(expected string) error {
+		return fmt.Errorf(localize("%s: expected %s, got %s"), path, expected, protoJSONType(value))
+	}
+
+	switch m.name {
+	case "google.protobuf.Timestamp":
+		if !isString {
+			return nil, invalid("RFC 3339 timestamp")
+		}
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil || t.Year() < 1 || t.Year() > 9999 {
+			return nil, fmt.Errorf(localize("%s: invalid %s value %q"), path, m.name, text)
+		}
+		fields.set("seconds", json.Number(strconv.FormatInt(t.Unix(), 10)))
+		fields.set("nanos", json.Number(strconv.Itoa(t.Nanosecond())))
+
+	case "google.protobuf.Duration":
+		if !isString {
+			return nil, invalid("string")
+		}
+		seconds, nanos, ok := protoParseDuration(text)
+		if !ok {
+			return nil, fmt.Errorf(localize("%s: invalid %s value %q"), path, m.name, text)
+		}
+		fields.set("seconds", json.Number(strconv.FormatInt(seconds, 10)))
+		fields.set("nanos", json.Number(strconv.FormatInt(nanos, 10)))
+
+	case "google.protobuf.Struct":
+		if _, ok := value.(*jsonObject); !ok {
+			return nil, invalid("object")
+		}
+		fields.set("fields", value)
+
+	case "google.protobuf.ListValue":
+		if _, ok := value.([]interface{}); !ok {
+			return nil, invalid("array")
+		}
+		fields.set("values", value)
+
+	case "google.protobuf.Value":
+		switch value.(type) {
+		case nil:
+			fields.set("null_value", json.Number("0"))
+		case bool:
+			fields.set("bool_value", value)
+		case json.Number:
+			fields.set("number_value", value)
+		case string:
+			fields.set("string_value", value)
+		case []interface{}:
+			fields.set("list_value", value)
+		default:
+			fields.set("struct_value", value)
+		}
+
+	case "google.protobuf.FieldMask":
+		if !isString {
+			return nil, invalid("string")
+		}
+		paths := []interface{}{}
+		for _, camel := range strings.Split(text, ",") {
+			if camel = strings.TrimSpace(camel); camel == "" {
+				continue
+			}
+			var snake strings.Builder
+			for _, c := range camel {
+				if unicode.IsUpper(c) {
+					snake.WriteByte('_')
+					c = unicode.ToLower(c)
+				}
+				snake.WriteRune(c)
+			}
+			paths = append(paths, snake.String())
+		}
+		fields.set("paths", paths)
+
+	case "google.protobuf.Any":
+		object, ok := value.(*jsonObject)
+		if !ok {
+			return nil, invalid("object")
+		}
+		if len(object.keys) == 0 {
+			return object, nil
+		}
+		typeURL, _ := object.values["@type"].(string)
+		if typeURL == "" {
+			return nil, fmt.Errorf(localize("%s: %s requires an @type member"), path, m.name)
+		}
+		inner, err := protoMessageType(typeURL[strings.LastIndex(typeURL, "/")+1:])
+		if err != nil {
+			return nil, err
+		}
+		var contents interface{} = object.values["value"]
+		if !protoHasJSONForm(inner.name) && inner.name != m.name {
+			members := &jsonObject{values: map[string]interface{}{}}
+			for _, key := range object.keys {
+				if key != "@type" {
+					members.set(key, object.values[key])
+				}
+			}
+			contents = members
+		}
+		var nested bytes.Buffer
+		if err := e.message(&nested, inner, contents, path, depth+1); err != nil {
+			return nil, err
+		}
+		fields.set("type_url", typeURL)
+		fields.set("value", base64.StdEncoding.EncodeToString(nested.Bytes()))
+
 	default:
-		return "unknown"
+		// Wrappers
+		fields.set("value", value)
+	}
+	return fields, nil
+}
+
+// protoParseDuration reads a Duration in its JSON form, seconds with up to 9 fractional digits and
+// an s suffix, as in -1.5s
+func protoParseDuration(text string) (int64, int64, bool) {
+	number, ok := strings.CutSuffix(text, "s")
+	negative := strings.HasPrefix(number, "-")
+	number = strings.TrimPrefix(number, "-")
+	whole, fraction, _ := strings.Cut(number, ".")
+	if !ok || whole == "" || len(fraction) > 9 || strings.Trim(whole+fraction, "0123456789") != "" {
+		return 0, 0, false
+	}
+	seconds, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || seconds > 315576000000 {
+		return 0, 0, false
+	}
+	var nanos int64
+	if fraction != "" {
+		nanos, _ = strconv.ParseInt(fraction+strings.Repeat("0", 9-len(fraction)), 10, 64)
+	}
+	if negative {
+		seconds, nanos = -seconds, -nanos
 	}
+	return seconds, nanos, true
+}
+
+// NDJSON / JSON Lines parsers for the streaming functions
+
+// ndjsonMaxLineLength is the default bound on a single line, which is buffered until its line break
+const ndjsonMaxLineLength = 16 << 20
+
+type ndjsonOptions struct {
+	// Strict stops at the first invalid line instead of reporting it and moving on
+	Strict        bool `json:"strict"`
+	MaxLineLength int  `json:"maxLineLength"`
+}
+
+// ndjsonParser holds the state kept between feedNDJSON calls: only the incomplete last line is
+// buffered, the records of each chunk are handed back to the caller
+type ndjsonParser struct {
+	options ndjsonOptions
+	pending []byte
+	line    int  // lines read so far
+	records int  // records parsed so far
+	invalid int  // lines skipped as invalid or too long
+	discard bool // dropping the rest of a line longer than MaxLineLength
+}
+
+// ndjsonParsers holds the parsers created by createNDJSONParser, keyed by parser ID
+var ndjsonParsers = map[string]*ndjsonParser{}
+
+var nextNDJSONParser int
+
+// ndjsonArgument checks the argument count and resolves the parser ID of the streaming functions
+func ndjsonArgument(args []js.Value, function string, count int, names string) (*ndjsonParser, string, interface{}) {
+	if len(args) < count {
+		message := localize("%s requires at least 2 arguments (%s)", function, names)
+		if count == 1 {
+			message = localize("%s requires at least 1 argument (%s)", function, names)
+		}
+		return nil, "", js.ValueOf(map[string]interface{}{"error": message})
+	}
+	id := args[0].String()
+	p, ok := ndjsonParsers[id]
+	if !ok {
+		return nil, "", js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown NDJSON parser %q (it may already be finished)", id),
+		})
+	}
+	return p, id, nil
+}
+
+// ndjsonBatch feeds a chunk to a parser and builds the result of feedNDJSON and finishNDJSON. In
+// strict mode the first invalid line fails the batch and frees the parser.
+func ndjsonBatch(id string, p *ndjsonParser, chunk []byte, final bool) map[string]interface{} {
+	var records ndjsonRecords
+	problems, err := p.feed(chunk, final, &records)
+	if err != nil {
+		delete(ndjsonParsers, id)
+		return map[string]interface{}{
+			"error":    err.Error(),
+			"parserId": id,
+		}
+	}
+	data := records.array()
+
+	if !silentMode && !final {
+		fmt.Printf("JSON WASM: Parsed %d NDJSON records (%d bytes buffered)\n", records.count, len(p.pending))
+	}
+
+	return map[string]interface{}{
+		"data":     string(data),
+		"valid":    len(problems) == 0,
+		"size":     len(data),
+		"format":   "json",
+		"parserId": id,
+		"count":    records.count,
+		"errors":   problems,
+		"lines":    p.line,
+		"records":  p.records,
+		"invalid":  p.invalid,
+		"pending":  len(p.pending),
+	}
+}
+
+// ndjsonRecords collects the records of a batch as the elements of a JSON array. Valid lines are
+// copied as they are, which keeps key order and exact numbers without decoding them.
+type ndjsonRecords struct {
+	buf   bytes.Buffer
+	count int
+}
+
+func (r *ndjsonRecords) add(record []byte) {
+	if r.count == 0 {
+		r.buf.WriteByte('[')
+	} else {
+		r.buf.WriteByte(',')
+	}
+	r.buf.Write(record)
+	r.count++
+}
+
+func (r *ndjsonRecords) array() []byte {
+	if r.count == 0 {
+		return []byte("[]")
+	}
+	r.buf.WriteByte(']')
+	return r.buf.Bytes()
+}
+
+// feed parses the complete lines of chunk, and with final the line left without a line break.
+// Invalid lines are reported in problems, or returned as err in strict mode.
+func (p *ndjsonParser) feed(chunk []byte, final bool, records *ndjsonRecords) (problems []interface{}, err error) {
+	problems = []interface{}{}
+	reject := func(line int, message string) error {
+		p.invalid++
+		if p.options.Strict {
+			return errors.New(localize("line %d: %v", line, message))
+		}
+		problems = append(problems, map[string]interface{}{"line": line, "error": message})
+		return nil
+	}
+
+	for len(chunk) > 0 {
+		end := bytes.IndexByte(chunk, '\n')
+		if end < 0 {
+			if !p.discard {
+				p.pending = append(p.pending, chunk...)
+				if len(p.pending) > p.options.MaxLineLength {
+					// The error is reported once; the rest of the line is dropped as it arrives
+					p.pending, p.discard = nil, true
+					if err := reject(p.line+1, localize("line longer than %d bytes", p.options.MaxLineLength)); err != nil {
+						return nil, err
+					}
+				}
+			}
+			break
+		}
+
+		line := chunk[:end]
+		chunk = chunk[end+1:]
+		p.line++
+		if p.discard {
+			p.discard = false
+			continue
+		}
+		if len(p.pending) > 0 {
+			p.pending = append(p.pending, line...)
+			line = p.pending
+		}
+		if len(line) > p.options.MaxLineLength {
+			err = reject(p.line, localize("line longer than %d bytes", p.options.MaxLineLength))
+		} else {
+			err = p.parse(line, records, reject)
+		}
+		p.pending = p.pending[:0]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if final {
+		if !p.discard && len(p.pending) > 0 {
+			p.line++
+			if err := p.parse(p.pending, records, reject); err != nil {
+				return nil, err
+			}
+		}
+		p.pending, p.discard = nil, false
+	}
+	return problems, nil
+}
+
+// parse checks one line and adds it to records. Blank lines are skipped.
+func (p *ndjsonParser) parse(line []byte, records *ndjsonRecords, reject func(int, string) error) error {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if p.line == 1 {
+		line = bytes.TrimPrefix(line, []byte("\ufeff"))
+	}
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+
+	if !json.Valid(line) {
+		// Decode only to describe the error
+		var value interface{}
+		err := json.Unmarshal(line, &value)
+		return reject(p.line, localize("Invalid JSON: %v", err))
+	}
+	p.records++
+	records.add(line)
+	return nil
 }
 
 func main() {
@@ -946,29 +19409,92 @@ func main() {
 
 	// Register all functions
 	js.Global().Set("parseJSON", js.FuncOf(parseJSON))
+	js.Global().Set("parseJSON5", js.FuncOf(parseJSON5))
 	js.Global().Set("stringifyJSON", js.FuncOf(stringifyJSON))
 	js.Global().Set("validateJSON", js.FuncOf(validateJSON))
 	js.Global().Set("minifyJSON", js.FuncOf(minifyJSON))
+	js.Global().Set("profileJSON", js.FuncOf(profileJSON))
 	js.Global().Set("parseXML", js.FuncOf(parseXML))
 	js.Global().Set("xmlToJSON", js.FuncOf(xmlToJSON))
 	js.Global().Set("jsonToXML", js.FuncOf(jsonToXML))
 	js.Global().Set("validateXML", js.FuncOf(validateXML))
+	js.Global().Set("queryXML", js.FuncOf(queryXML))
+	js.Global().Set("queryXMLFirst", js.FuncOf(queryXMLFirst))
+	js.Global().Set("transformXML", js.FuncOf(transformXML))
+	js.Global().Set("formatXML", js.FuncOf(formatXML))
+	js.Global().Set("minifyXML", js.FuncOf(minifyXML))
+	js.Global().Set("c14nXML", js.FuncOf(c14nXML))
+	js.Global().Set("htmlTablesToJSON", js.FuncOf(htmlTablesToJSON))
+	js.Global().Set("htmlListsToJSON", js.FuncOf(htmlListsToJSON))
 	js.Global().Set("csvToJSON", js.FuncOf(csvToJSON))
+	js.Global().Set("inferCSVSchema", js.FuncOf(inferCSVSchema))
+	js.Global().Set("validateData", js.FuncOf(validateData))
 	js.Global().Set("jsonToCSV", js.FuncOf(jsonToCSV))
+	js.Global().Set("validateGeoJSON", js.FuncOf(validateGeoJSON))
+	js.Global().Set("csvToGeoJSON", js.FuncOf(csvToGeoJSON))
+	js.Global().Set("geojsonToCSV", js.FuncOf(geojsonToCSV))
 	js.Global().Set("yamlToJSON", js.FuncOf(yamlToJSON))
 	js.Global().Set("jsonToYAML", js.FuncOf(jsonToYAML))
+	js.Global().Set("tomlToJSON", js.FuncOf(tomlToJSON))
+	js.Global().Set("jsonToTOML", js.FuncOf(jsonToTOML))
+	js.Global().Set("iniToJSON", js.FuncOf(iniToJSON))
+	js.Global().Set("jsonToINI", js.FuncOf(jsonToINI))
+	js.Global().Set("encodeMsgPack", js.FuncOf(encodeMsgPack))
+	js.Global().Set("decodeMsgPack", js.FuncOf(decodeMsgPack))
+	js.Global().Set("encodeCBOR", js.FuncOf(encodeCBOR))
+	js.Global().Set("decodeCBOR", js.FuncOf(decodeCBOR))
+	js.Global().Set("decodeBSON", js.FuncOf(decodeBSON))
+	js.Global().Set("loadProtoDescriptor", js.FuncOf(loadProtoDescriptor))
+	js.Global().Set("encodeProto", js.FuncOf(encodeProto))
+	js.Global().Set("decodeProto", js.FuncOf(decodeProto))
+	js.Global().Set("createNDJSONParser", js.FuncOf(createNDJSONParser))
+	js.Global().Set("feedNDJSON", js.FuncOf(feedNDJSON))
+	js.Global().Set("finishNDJSON", js.FuncOf(finishNDJSON))
+	js.Global().Set("freeNDJSONParser", js.FuncOf(freeNDJSONParser))
 	js.Global().Set("extractJSONPath", js.FuncOf(extractJSONPath))
+	js.Global().Set("transformJSON", js.FuncOf(transformJSON))
 	js.Global().Set("validateJSONSchema", js.FuncOf(validateJSONSchema))
+	js.Global().Set("generateJSONSchema", js.FuncOf(generateJSONSchema))
+	js.Global().Set("jsonToTypeScript", js.FuncOf(jsonToTypeScript))
+	js.Global().Set("jsonToGoStruct", js.FuncOf(jsonToGoStruct))
+	js.Global().Set("generateMockData", js.FuncOf(generateMockData))
+	js.Global().Set("mergeJSON", js.FuncOf(mergeJSON))
+	js.Global().Set("cloneJSON", js.FuncOf(cloneJSON))
+	js.Global().Set("pruneJSON", js.FuncOf(pruneJSON))
+	js.Global().Set("flattenJSON", js.FuncOf(flattenJSON))
+	js.Global().Set("unflattenJSON", js.FuncOf(unflattenJSON))
+	js.Global().Set("applyJSONPatch", js.FuncOf(applyJSONPatch))
+	js.Global().Set("applyMergePatch", js.FuncOf(applyMergePatch))
+	js.Global().Set("generateJSONPatch", js.FuncOf(generateJSONPatch))
+	js.Global().Set("diffJSON", js.FuncOf(diffJSON))
 	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
 	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+	natives := make([]string, len(nativeFunctions))
+	for i, native := range nativeFunctions {
+		natives[i] = native.name + "Native"
+		js.Global().Set(natives[i], js.FuncOf(nativeVariant(native.fn)))
+	}
 
 	fmt.Println("JSONXML WASM: Module loaded successfully with comprehensive data processing capabilities")
 	fmt.Println("Available functions:")
-	fmt.Println("- JSON: parseJSON, stringifyJSON, validateJSON, minifyJSON")
-	fmt.Println("- XML: parseXML, xmlToJSON, jsonToXML, validateXML")
-	fmt.Println("- CSV: csvToJSON, jsonToCSV")
+	fmt.Println("- JSON: parseJSON, parseJSON5, stringifyJSON, validateJSON, minifyJSON, profileJSON")
+	fmt.Println("- XML: parseXML, xmlToJSON, jsonToXML, validateXML, queryXML, queryXMLFirst, transformXML, formatXML, minifyXML, c14nXML")
+	fmt.Println("- HTML: htmlTablesToJSON, htmlListsToJSON")
+	fmt.Println("- CSV: csvToJSON, inferCSVSchema, validateData, jsonToCSV")
+	fmt.Println("- GeoJSON: validateGeoJSON, csvToGeoJSON, geojsonToCSV")
 	fmt.Println("- YAML: yamlToJSON, jsonToYAML")
-	fmt.Println("- Advanced: extractJSONPath, validateJSONSchema")
+	fmt.Println("- Config: tomlToJSON, jsonToTOML, iniToJSON, jsonToINI")
+	fmt.Println("- Binary: encodeMsgPack, decodeMsgPack, encodeCBOR, decodeCBOR, decodeBSON")
+	fmt.Println("- Protobuf: loadProtoDescriptor, encodeProto, decodeProto")
+	fmt.Println("- Streaming: createNDJSONParser, feedNDJSON, finishNDJSON, freeNDJSONParser")
+	fmt.Println("- Advanced: extractJSONPath, transformJSON, validateJSONSchema, generateJSONSchema, jsonToTypeScript, jsonToGoStruct, generateMockData")
+	fmt.Println("- Merge: mergeJSON, cloneJSON, pruneJSON, flattenJSON, unflattenJSON")
+	fmt.Println("- Patch: applyJSONPatch, applyMergePatch, generateJSONPatch, diffJSON")
+	fmt.Println("- Native: " + strings.Join(natives, ", "))
 	fmt.Println("- Utility: getAvailableFunctions, setSilentMode")
 
 	<-done
@@ -3,10 +3,18 @@
 package main
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"runtime"
+	"runtime/debug"
 	"sort"
+	"strconv"
+	"strings"
 	"syscall/js"
+	"time"
 )
 
 var silentMode = false
@@ -19,10 +27,139 @@ func setSilentMode(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(silentMode)
 }
 
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"Error: two arguments required for %s":                                              "Erreur: deux arguments requis pour %s",
+	"Error: division by zero":                                                           "Erreur: division par zéro",
+	"Error: invalid result (NaN or Infinity)":                                           "Erreur: résultat invalide (NaN ou Infinity)",
+	"Error: one argument required for %s":                                               "Erreur: un argument requis pour %s",
+	"Error: factorial not defined for negative numbers":                                 "Erreur: factorielle non définie pour les nombres négatifs",
+	"Error: factorial overflow for numbers greater than 170":                            "Erreur: dépassement de capacité de la factorielle au-delà de 170",
+	"Error: square root of negative number":                                             "Erreur: racine carrée d'un nombre négatif",
+	"Error: at least two arguments required for %s":                                     "Erreur: au moins deux arguments requis pour %s",
+	"Error: tangent is undefined for this value":                                        "Erreur: tangente non définie pour cette valeur",
+	"Error: logarithm of non-positive number":                                           "Erreur: logarithme d'un nombre négatif ou nul",
+	"Error: fibonacci not defined for negative numbers":                                 "Erreur: fibonacci non défini pour les nombres négatifs",
+	"Error: fibonacci overflow for numbers greater than 92":                             "Erreur: dépassement de capacité de fibonacci au-delà de 92",
+	"Error: at least one argument required for %s":                                      "Erreur: au moins un argument requis pour %s",
+	"Error: at least two arguments required for standard deviation":                     "Erreur: au moins deux arguments requis pour l'écart type",
+	"Error: one or two arguments required for %s":                                       "Erreur: un ou deux arguments requis pour %s",
+	"unknown rounding mode %q (use halfUp, halfEven, halfDown, ceil or floor)":          "mode d'arrondi %q inconnu (utilisez halfUp, halfEven, halfDown, ceil ou floor)",
+	"Error: precision must be between 0 and 15":                                         "Erreur: la précision doit être comprise entre 0 et 15",
+	"Error: cannot round NaN or Infinity":                                               "Erreur: impossible d'arrondir NaN ou Infinity",
+	"Error: two or three arguments required for %s":                                     "Erreur: deux ou trois arguments requis pour %s",
+	"Error: step must be a positive number":                                             "Erreur: step doit être un nombre positif",
+	"Error: ":                                                                           "Erreur: ",
+	"Error: three arguments required for clamp (value, min, max)":                       "Erreur: trois arguments requis pour clamp (value, min, max)",
+	"Error: min must be less than or equal to max":                                      "Erreur: min doit être inférieur ou égal à max",
+	"Error: two arguments required for percentChange (oldValue, newValue)":              "Erreur: deux arguments requis pour percentChange (oldValue, newValue)",
+	"Error: percent change from zero is undefined":                                      "Erreur: la variation en pourcentage depuis zéro n'est pas définie",
+	"Error: ratio terms must be finite numbers":                                         "Erreur: les termes du ratio doivent être des nombres finis",
+	"Error: ratio 0:0 is undefined":                                                     "Erreur: le ratio 0:0 n'est pas défini",
+	"Error: %s arguments must be finite numbers":                                        "Erreur: les arguments de %s doivent être des nombres finis",
+	"Error: %s result overflows float64":                                                "Erreur: le résultat de %s dépasse la capacité de float64",
+	"coordinates must be a Float64Array or an array of numbers":                         "les coordonnées doivent être un Float64Array ou un tableau de nombres",
+	"coordinates must contain an even number of values (x, y pairs)":                    "les coordonnées doivent contenir un nombre pair de valeurs (paires x, y)",
+	"at least %d points required":                                                       "au moins %d points requis",
+	"Error: four arguments required for distance (x1, y1, x2, y2)":                      "Erreur: quatre arguments requis pour distance (x1, y1, x2, y2)",
+	"Error: lineIntersection expects exactly 4 points [x1, y1, x2, y2, x3, y3, x4, y4]": "Erreur: lineIntersection attend exactement 4 points [x1, y1, x2, y2, x3, y3, x4, y4]",
+	"Error: centroid undefined for a degenerate polygon":                                "Erreur: centroïde non défini pour un polygone dégénéré",
+	"Error: three arguments required for pointInPolygon (x, y, polygon)":                "Erreur: trois arguments requis pour pointInPolygon (x, y, polygon)",
+	"setLocale requires exactly 1 argument (locale)":                                    "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":                                             "Langue %q non prise en charge (disponibles: %s)",
+	"vectors must be a Float64Array or an array of numbers":                             "les vecteurs doivent être un Float64Array ou un tableau de nombres",
+	"vectors must have the same length (%d and %d)":                                     "les vecteurs doivent avoir la même longueur (%d et %d)",
+	"Error: three arguments required for axpy (alpha, x, y)":                            "Erreur: trois arguments requis pour axpy (alpha, x, y)",
+	"Error: at least one value required for %s":                                         "Erreur: au moins une valeur requise pour %s",
+	"Error: five arguments required for matrixMultiply (a, b, rows, inner, cols)":       "Erreur: cinq arguments requis pour matrixMultiply (a, b, rows, inner, cols)",
+	"matrix dimensions must be positive integers":                                       "les dimensions de la matrice doivent être des entiers positifs",
+	"matrix %s must hold %d values, got %d":                                             "la matrice %s doit contenir %d valeurs, %d reçues",
+	"sparse matrix must be an object {rows, cols, values, columns, rowOffsets}":         "la matrice creuse doit être un objet {rows, cols, values, columns, rowOffsets}",
+	"%s[%d] must be a non-negative integer":                                             "%s[%d] doit être un entier positif ou nul",
+	"columns must hold one index per value (%d values, %d columns)":                     "columns doit contenir un indice par valeur (%d valeurs, %d colonnes)",
+	"rowOffsets must hold %d increasing offsets from 0 to %d":                           "rowOffsets doit contenir %d positions croissantes de 0 à %d",
+	"columns[%d] = %d is outside the %d columns":                                        "columns[%d] = %d est hors des %d colonnes",
+	"vector must hold one value per column (%d columns, %d values)":                     "le vecteur doit contenir une valeur par colonne (%d colonnes, %d valeurs)",
+	"objective must be an object {maximize: [...]} or {minimize: [...]}":                "l'objectif doit être un objet {maximize: [...]} ou {minimize: [...]}",
+	"the objective must have between 1 and %d variables":                                "l'objectif doit avoir entre 1 et %d variables",
+	"constraints must be an array of {coefficients, op, rhs}":                           "les contraintes doivent être un tableau de {coefficients, op, rhs}",
+	"at most %d constraints are supported":                                              "au plus %d contraintes sont prises en charge",
+	"coefficients must be finite numbers":                                               "les coefficients doivent être des nombres finis",
+	"constraint %d must be an object {coefficients, op, rhs}":                           "la contrainte %d doit être un objet {coefficients, op, rhs}",
+	"constraint %d must have %d coefficients, got %d":                                   "la contrainte %d doit avoir %d coefficients, %d reçus",
+	"constraint %d has unknown operator %q (use <=, >= or =)":                           "la contrainte %d a un opérateur %q inconnu (utilisez <=, >= ou =)",
+	"constraint %d needs a finite rhs":                                                  "la contrainte %d requiert un rhs fini",
+}
+
 // Basic arithmetic operations
 func add(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for add")
+		return js.ValueOf(localize("Error: two arguments required for %s", "add"))
 	}
 
 	a := args[0].Float()
@@ -37,7 +174,7 @@ func add(this js.Value, args []js.Value) interface{} {
 
 func subtract(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for subtract")
+		return js.ValueOf(localize("Error: two arguments required for %s", "subtract"))
 	}
 
 	a := args[0].Float()
@@ -52,7 +189,7 @@ func subtract(this js.Value, args []js.Value) interface{} {
 
 func multiply(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for multiply")
+		return js.ValueOf(localize("Error: two arguments required for %s", "multiply"))
 	}
 
 	a := args[0].Float()
@@ -67,14 +204,14 @@ func multiply(this js.Value, args []js.Value) interface{} {
 
 func divide(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for divide")
+		return js.ValueOf(localize("Error: two arguments required for %s", "divide"))
 	}
 
 	a := args[0].Float()
 	b := args[1].Float()
 
 	if b == 0 {
-		return js.ValueOf("Error: division by zero")
+		return js.ValueOf(localize("Error: division by zero"))
 	}
 
 	result := a / b
@@ -86,7 +223,7 @@ func divide(this js.Value, args []js.Value) interface{} {
 
 func power(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for power")
+		return js.ValueOf(localize("Error: two arguments required for %s", "power"))
 	}
 
 	base := args[0].Float()
@@ -95,7 +232,7 @@ func power(this js.Value, args []js.Value) interface{} {
 	result := math.Pow(base, exp)
 
 	if math.IsNaN(result) || math.IsInf(result, 0) {
-		return js.ValueOf("Error: invalid result (NaN or Infinity)")
+		return js.ValueOf(localize("Error: invalid result (NaN or Infinity)"))
 	}
 
 	if !silentMode {
@@ -106,17 +243,17 @@ func power(this js.Value, args []js.Value) interface{} {
 
 func factorial(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for factorial")
+		return js.ValueOf(localize("Error: one argument required for %s", "factorial"))
 	}
 
 	n := int(args[0].Float())
 
 	if n < 0 {
-		return js.ValueOf("Error: factorial not defined for negative numbers")
+		return js.ValueOf(localize("Error: factorial not defined for negative numbers"))
 	}
 
 	if n > 170 {
-		return js.ValueOf("Error: factorial overflow for numbers greater than 170")
+		return js.ValueOf(localize("Error: factorial overflow for numbers greater than 170"))
 	}
 
 	if n == 0 || n == 1 {
@@ -137,13 +274,13 @@ func factorial(this js.Value, args []js.Value) interface{} {
 // Advanced mathematical functions
 func sqrt(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for sqrt")
+		return js.ValueOf(localize("Error: one argument required for %s", "sqrt"))
 	}
 
 	x := args[0].Float()
 
 	if x < 0 {
-		return js.ValueOf("Error: square root of negative number")
+		return js.ValueOf(localize("Error: square root of negative number"))
 	}
 
 	result := math.Sqrt(x)
@@ -156,7 +293,7 @@ func sqrt(this js.Value, args []js.Value) interface{} {
 
 func abs(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for abs")
+		return js.ValueOf(localize("Error: one argument required for %s", "abs"))
 	}
 
 	x := args[0].Float()
@@ -170,7 +307,7 @@ func abs(this js.Value, args []js.Value) interface{} {
 
 func min(this js.Value, args []js.Value) interface{} {
 	if len(args) < 2 {
-		return js.ValueOf("Error: at least two arguments required for min")
+		return js.ValueOf(localize("Error: at least two arguments required for %s", "min"))
 	}
 
 	result := args[0].Float()
@@ -189,7 +326,7 @@ func min(this js.Value, args []js.Value) interface{} {
 
 func max(this js.Value, args []js.Value) interface{} {
 	if len(args) < 2 {
-		return js.ValueOf("Error: at least two arguments required for max")
+		return js.ValueOf(localize("Error: at least two arguments required for %s", "max"))
 	}
 
 	result := args[0].Float()
@@ -209,7 +346,7 @@ func max(this js.Value, args []js.Value) interface{} {
 // Trigonometric functions
 func sin(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for sin")
+		return js.ValueOf(localize("Error: one argument required for %s", "sin"))
 	}
 
 	x := args[0].Float()
@@ -223,7 +360,7 @@ func sin(this js.Value, args []js.Value) interface{} {
 
 func cos(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for cos")
+		return js.ValueOf(localize("Error: one argument required for %s", "cos"))
 	}
 
 	x := args[0].Float()
@@ -237,14 +374,14 @@ func cos(this js.Value, args []js.Value) interface{} {
 
 func tan(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for tan")
+		return js.ValueOf(localize("Error: one argument required for %s", "tan"))
 	}
 
 	x := args[0].Float()
 	result := math.Tan(x)
 
 	if math.IsNaN(result) || math.IsInf(result, 0) {
-		return js.ValueOf("Error: tangent is undefined for this value")
+		return js.ValueOf(localize("Error: tangent is undefined for this value"))
 	}
 
 	if !silentMode {
@@ -256,13 +393,13 @@ func tan(this js.Value, args []js.Value) interface{} {
 // Logarithmic functions
 func log(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for log")
+		return js.ValueOf(localize("Error: one argument required for %s", "log"))
 	}
 
 	x := args[0].Float()
 
 	if x <= 0 {
-		return js.ValueOf("Error: logarithm of non-positive number")
+		return js.ValueOf(localize("Error: logarithm of non-positive number"))
 	}
 
 	result := math.Log(x)
@@ -275,13 +412,13 @@ func log(this js.Value, args []js.Value) interface{} {
 
 func log10(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for log10")
+		return js.ValueOf(localize("Error: one argument required for %s", "log10"))
 	}
 
 	x := args[0].Float()
 
 	if x <= 0 {
-		return js.ValueOf("Error: logarithm of non-positive number")
+		return js.ValueOf(localize("Error: logarithm of non-positive number"))
 	}
 
 	result := math.Log10(x)
@@ -295,7 +432,7 @@ func log10(this js.Value, args []js.Value) interface{} {
 // Number theory functions
 func gcd(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for gcd")
+		return js.ValueOf(localize("Error: two arguments required for %s", "gcd"))
 	}
 
 	a := int(math.Abs(args[0].Float()))
@@ -313,7 +450,7 @@ func gcd(this js.Value, args []js.Value) interface{} {
 
 func lcm(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for lcm")
+		return js.ValueOf(localize("Error: two arguments required for %s", "lcm"))
 	}
 
 	a := int(math.Abs(args[0].Float()))
@@ -340,7 +477,7 @@ func lcm(this js.Value, args []js.Value) interface{} {
 
 func isPrime(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for isPrime")
+		return js.ValueOf(localize("Error: one argument required for %s", "isPrime"))
 	}
 
 	n := int(args[0].Float())
@@ -371,17 +508,17 @@ func isPrime(this js.Value, args []js.Value) interface{} {
 
 func fibonacci(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for fibonacci")
+		return js.ValueOf(localize("Error: one argument required for %s", "fibonacci"))
 	}
 
 	n := int(args[0].Float())
 
 	if n < 0 {
-		return js.ValueOf("Error: fibonacci not defined for negative numbers")
+		return js.ValueOf(localize("Error: fibonacci not defined for negative numbers"))
 	}
 
 	if n > 92 {
-		return js.ValueOf("Error: fibonacci overflow for numbers greater than 92")
+		return js.ValueOf(localize("Error: fibonacci overflow for numbers greater than 92"))
 	}
 
 	if n <= 1 {
@@ -402,7 +539,7 @@ func fibonacci(this js.Value, args []js.Value) interface{} {
 // Statistical functions
 func mean(this js.Value, args []js.Value) interface{} {
 	if len(args) == 0 {
-		return js.ValueOf("Error: at least one argument required for mean")
+		return js.ValueOf(localize("Error: at least one argument required for %s", "mean"))
 	}
 
 	sum := 0.0
@@ -420,7 +557,7 @@ func mean(this js.Value, args []js.Value) interface{} {
 
 func median(this js.Value, args []js.Value) interface{} {
 	if len(args) == 0 {
-		return js.ValueOf("Error: at least one argument required for median")
+		return js.ValueOf(localize("Error: at least one argument required for %s", "median"))
 	}
 
 	numbers := make([]float64, len(args))
@@ -447,7 +584,7 @@ func median(this js.Value, args []js.Value) interface{} {
 
 func standardDeviation(this js.Value, args []js.Value) interface{} {
 	if len(args) < 2 {
-		return js.ValueOf("Error: at least two arguments required for standard deviation")
+		return js.ValueOf(localize("Error: at least two arguments required for standard deviation"))
 	}
 
 	// Calculate mean
@@ -476,7 +613,7 @@ func standardDeviation(this js.Value, args []js.Value) interface{} {
 // Utility functions
 func round(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 || len(args) > 2 {
-		return js.ValueOf("Error: one or two arguments required for round")
+		return js.ValueOf(localize("Error: one or two arguments required for %s", "round"))
 	}
 
 	x := args[0].Float()
@@ -497,7 +634,7 @@ func round(this js.Value, args []js.Value) interface{} {
 
 func ceil(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for ceil")
+		return js.ValueOf(localize("Error: one argument required for %s", "ceil"))
 	}
 
 	x := args[0].Float()
@@ -511,7 +648,7 @@ func ceil(this js.Value, args []js.Value) interface{} {
 
 func floor(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for floor")
+		return js.ValueOf(localize("Error: one argument required for %s", "floor"))
 	}
 
 	x := args[0].Float()
@@ -523,6 +660,1612 @@ func floor(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(result)
 }
 
+// exactDecimal converts a float to the exact decimal value it prints as,
+// so that 2.675 is treated as 2.675 and not as 2.67499999...
+func exactDecimal(x float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(x, 'f', -1, 64))
+	return r
+}
+
+// isFinite reports whether x is neither NaN nor an infinity
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+// roundRat rounds a rational number to an integer using the given mode
+func roundRat(r *big.Rat, mode string) (*big.Int, error) {
+	switch mode {
+	case "halfUp", "halfEven", "halfDown", "ceil", "floor":
+	default:
+		return nil, fmt.Errorf(localize("unknown rounding mode %q (use halfUp, halfEven, halfDown, ceil or floor)"), mode)
+	}
+
+	quotient, remainder := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient, nil
+	}
+
+	// QuoRem truncates toward zero; step is the adjustment away from zero
+	step := big.NewInt(int64(r.Sign()))
+	twiceRemainder := new(big.Int).Abs(remainder)
+	twiceRemainder.Lsh(twiceRemainder, 1)
+	half := twiceRemainder.Cmp(r.Denom())
+
+	switch mode {
+	case "halfUp":
+		if half >= 0 {
+			quotient.Add(quotient, step)
+		}
+	case "halfEven":
+		if half > 0 || (half == 0 && quotient.Bit(0) == 1) {
+			quotient.Add(quotient, step)
+		}
+	case "halfDown":
+		if half > 0 {
+			quotient.Add(quotient, step)
+		}
+	case "ceil":
+		if r.Sign() > 0 {
+			quotient.Add(quotient, step)
+		}
+	case "floor":
+		if r.Sign() < 0 {
+			quotient.Add(quotient, step)
+		}
+	}
+	return quotient, nil
+}
+
+func roundHalfEven(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || len(args) > 2 {
+		return js.ValueOf(localize("Error: one or two arguments required for %s", "roundHalfEven"))
+	}
+
+	x := args[0].Float()
+	precision := 0
+	if len(args) == 2 {
+		precision = int(args[1].Float())
+	}
+	if precision < 0 || precision > 15 {
+		return js.ValueOf(localize("Error: precision must be between 0 and 15"))
+	}
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return js.ValueOf(localize("Error: cannot round NaN or Infinity"))
+	}
+
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil))
+	scaled := new(big.Rat).Mul(exactDecimal(x), scale)
+	rounded, _ := roundRat(scaled, "halfEven")
+	result, _ := new(big.Rat).Quo(new(big.Rat).SetInt(rounded), scale).Float64()
+
+	if !silentMode {
+		fmt.Printf("Go WASM: roundHalfEven(%f, %d) = %f\n", x, precision, result)
+	}
+	return js.ValueOf(result)
+}
+
+func roundToIncrement(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 || len(args) > 3 {
+		return js.ValueOf(localize("Error: two or three arguments required for %s", "roundToIncrement"))
+	}
+
+	value := args[0].Float()
+	step := args[1].Float()
+	mode := "halfUp"
+	if len(args) == 3 {
+		mode = args[2].String()
+	}
+
+	if step <= 0 || math.IsNaN(step) || math.IsInf(step, 0) {
+		return js.ValueOf(localize("Error: step must be a positive number"))
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return js.ValueOf(localize("Error: cannot round NaN or Infinity"))
+	}
+
+	exactStep := exactDecimal(step)
+	multiples, err := roundRat(new(big.Rat).Quo(exactDecimal(value), exactStep), mode)
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+	result, _ := new(big.Rat).Mul(new(big.Rat).SetInt(multiples), exactStep).Float64()
+
+	if !silentMode {
+		fmt.Printf("Go WASM: roundToIncrement(%f, %f, %s) = %f\n", value, step, mode, result)
+	}
+	return js.ValueOf(result)
+}
+
+func clamp(this js.Value, args []js.Value) interface{} {
+	if len(args) != 3 {
+		return js.ValueOf(localize("Error: three arguments required for clamp (value, min, max)"))
+	}
+
+	value := args[0].Float()
+	lower := args[1].Float()
+	upper := args[2].Float()
+
+	if !isFinite(value) || !isFinite(lower) || !isFinite(upper) {
+		return js.ValueOf(localize("Error: %s arguments must be finite numbers", "clamp"))
+	}
+	if lower > upper {
+		return js.ValueOf(localize("Error: min must be less than or equal to max"))
+	}
+
+	result := math.Max(lower, math.Min(upper, value))
+
+	if !silentMode {
+		fmt.Printf("Go WASM: clamp(%f, %f, %f) = %f\n", value, lower, upper, result)
+	}
+	return js.ValueOf(result)
+}
+
+func percentChange(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.ValueOf(localize("Error: two arguments required for percentChange (oldValue, newValue)"))
+	}
+
+	oldValue := args[0].Float()
+	newValue := args[1].Float()
+
+	if !isFinite(oldValue) || !isFinite(newValue) {
+		return js.ValueOf(localize("Error: %s arguments must be finite numbers", "percentChange"))
+	}
+	if oldValue == 0 {
+		return js.ValueOf(localize("Error: percent change from zero is undefined"))
+	}
+
+	result := (newValue - oldValue) / math.Abs(oldValue) * 100
+	if !isFinite(result) {
+		return js.ValueOf(localize("Error: %s result overflows float64", "percentChange"))
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: percentChange(%f, %f) = %f%%\n", oldValue, newValue, result)
+	}
+	return js.ValueOf(result)
+}
+
+func simplifyRatio(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.ValueOf(localize("Error: two arguments required for %s", "simplifyRatio"))
+	}
+
+	a := args[0].Float()
+	b := args[1].Float()
+
+	if math.IsNaN(a) || math.IsNaN(b) || math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return js.ValueOf(localize("Error: ratio terms must be finite numbers"))
+	}
+	if a == 0 && b == 0 {
+		return js.ValueOf(localize("Error: ratio 0:0 is undefined"))
+	}
+
+	// Work on exact decimals so 1.5:0.25 simplifies to 6:1
+	ratA, ratB := exactDecimal(a), exactDecimal(b)
+	commonDenom := new(big.Int).Mul(ratA.Denom(), ratB.Denom())
+	termA := new(big.Int).Div(new(big.Int).Mul(ratA.Num(), commonDenom), ratA.Denom())
+	termB := new(big.Int).Div(new(big.Int).Mul(ratB.Num(), commonDenom), ratB.Denom())
+
+	divisor := new(big.Int).GCD(nil, nil, new(big.Int).Abs(termA), new(big.Int).Abs(termB))
+	termA.Div(termA, divisor)
+	termB.Div(termB, divisor)
+
+	left, _ := new(big.Float).SetInt(termA).Float64()
+	right, _ := new(big.Float).SetInt(termB).Float64()
+	if !isFinite(left) || !isFinite(right) {
+		return js.ValueOf(localize("Error: %s result overflows float64", "simplifyRatio"))
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: simplifyRatio(%f, %f) = %s:%s\n", a, b, termA, termB)
+	}
+	return js.ValueOf(map[string]interface{}{
+		"left":  left,
+		"right": right,
+		"ratio": termA.String() + ":" + termB.String(),
+	})
+}
+
+// Geometry functions
+// Coordinate buffers are flat Float64Array (or Array) values: [x0, y0, x1, y1, ...]
+
+// readCoordinates converts a Float64Array or Array of numbers into a Go slice
+func readCoordinates(value js.Value) ([]float64, error) {
+	if value.Type() != js.TypeObject {
+		return nil, errors.New(localize("coordinates must be a Float64Array or an array of numbers"))
+	}
+
+	if value.InstanceOf(js.Global().Get("Float64Array")) {
+		raw := make([]byte, value.Get("byteLength").Int())
+		view := js.Global().Get("Uint8Array").New(value.Get("buffer"), value.Get("byteOffset"), value.Get("byteLength"))
+		js.CopyBytesToGo(raw, view)
+
+		coords := make([]float64, len(raw)/8)
+		for i := range coords {
+			coords[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw[i*8:]))
+		}
+		return coords, nil
+	}
+
+	length := value.Get("length").Int()
+	coords := make([]float64, length)
+	for i := 0; i < length; i++ {
+		coords[i] = value.Index(i).Float()
+	}
+	return coords, nil
+}
+
+// readPoints reads a coordinate buffer and checks it holds at least minPoints (x, y) pairs
+func readPoints(value js.Value, minPoints int) ([]float64, error) {
+	coords, err := readCoordinates(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(coords)%2 != 0 {
+		return nil, errors.New(localize("coordinates must contain an even number of values (x, y pairs)"))
+	}
+	if len(coords)/2 < minPoints {
+		return nil, fmt.Errorf(localize("at least %d points required"), minPoints)
+	}
+	return coords, nil
+}
+
+// newFloat64Array copies a Go slice into a new JavaScript Float64Array
+func newFloat64Array(values []float64) js.Value {
+	raw := make([]byte, len(values)*8)
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(raw[i*8:], math.Float64bits(v))
+	}
+	bytes := js.Global().Get("Uint8Array").New(len(raw))
+	js.CopyBytesToJS(bytes, raw)
+	return js.Global().Get("Float64Array").New(bytes.Get("buffer"))
+}
+
+// signedArea returns the shoelace area, positive for counter-clockwise polygons
+func signedArea(coords []float64) float64 {
+	n := len(coords) / 2
+	area := 0.0
+	for i := 0; i < n; i++ {
+		j := (i + 1) % n
+		area += coords[2*i]*coords[2*j+1] - coords[2*j]*coords[2*i+1]
+	}
+	return area / 2
+}
+
+func distance(this js.Value, args []js.Value) interface{} {
+	if len(args) != 4 {
+		return js.ValueOf(localize("Error: four arguments required for distance (x1, y1, x2, y2)"))
+	}
+
+	x1, y1 := args[0].Float(), args[1].Float()
+	x2, y2 := args[2].Float(), args[3].Float()
+	result := math.Hypot(x2-x1, y2-y1)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: distance((%f, %f), (%f, %f)) = %f\n", x1, y1, x2, y2, result)
+	}
+	return js.ValueOf(result)
+}
+
+func lineIntersection(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || len(args) > 2 {
+		return js.ValueOf(localize("Error: one or two arguments required for %s", "lineIntersection"))
+	}
+
+	coords, err := readPoints(args[0], 4)
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+	if len(coords) != 8 {
+		return js.ValueOf(localize("Error: lineIntersection expects exactly 4 points [x1, y1, x2, y2, x3, y3, x4, y4]"))
+	}
+
+	segmentsOnly := len(args) == 2 && args[1].Bool()
+
+	x1, y1, x2, y2 := coords[0], coords[1], coords[2], coords[3]
+	x3, y3, x4, y4 := coords[4], coords[5], coords[6], coords[7]
+
+	denominator := (x1-x2)*(y3-y4) - (y1-y2)*(x3-x4)
+	if math.Abs(denominator) < 1e-12 {
+		return js.ValueOf(map[string]interface{}{
+			"intersects": false,
+			"parallel":   true,
+		})
+	}
+
+	t := ((x1-x3)*(y3-y4) - (y1-y3)*(x3-x4)) / denominator
+	u := ((x1-x3)*(y1-y2) - (y1-y3)*(x1-x2)) / denominator
+
+	if segmentsOnly && (t < 0 || t > 1 || u < 0 || u > 1) {
+		return js.ValueOf(map[string]interface{}{
+			"intersects": false,
+			"parallel":   false,
+		})
+	}
+
+	x := x1 + t*(x2-x1)
+	y := y1 + t*(y2-y1)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: lineIntersection = (%f, %f)\n", x, y)
+	}
+	return js.ValueOf(map[string]interface{}{
+		"intersects": true,
+		"parallel":   false,
+		"x":          x,
+		"y":          y,
+	})
+}
+
+func polygonArea(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(localize("Error: one argument required for %s", "polygonArea"))
+	}
+
+	coords, err := readPoints(args[0], 3)
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+
+	result := math.Abs(signedArea(coords))
+
+	if !silentMode {
+		fmt.Printf("Go WASM: polygonArea of %d points = %f\n", len(coords)/2, result)
+	}
+	return js.ValueOf(result)
+}
+
+func polygonCentroid(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(localize("Error: one argument required for %s", "polygonCentroid"))
+	}
+
+	coords, err := readPoints(args[0], 3)
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+
+	area := signedArea(coords)
+	if math.Abs(area) < 1e-12 {
+		return js.ValueOf(localize("Error: centroid undefined for a degenerate polygon"))
+	}
+
+	n := len(coords) / 2
+	cx, cy := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		j := (i + 1) % n
+		cross := coords[2*i]*coords[2*j+1] - coords[2*j]*coords[2*i+1]
+		cx += (coords[2*i] + coords[2*j]) * cross
+		cy += (coords[2*i+1] + coords[2*j+1]) * cross
+	}
+	cx /= 6 * area
+	cy /= 6 * area
+
+	if !silentMode {
+		fmt.Printf("Go WASM: polygonCentroid of %d points = (%f, %f)\n", n, cx, cy)
+	}
+	return js.ValueOf(map[string]interface{}{
+		"x": cx,
+		"y": cy,
+	})
+}
+
+func convexHull(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(localize("Error: one argument required for %s", "convexHull"))
+	}
+
+	coords, err := readPoints(args[0], 1)
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+
+	points := make([][2]float64, len(coords)/2)
+	for i := range points {
+		points[i] = [2]float64{coords[2*i], coords[2*i+1]}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i][0] != points[j][0] {
+			return points[i][0] < points[j][0]
+		}
+		return points[i][1] < points[j][1]
+	})
+
+	cross := func(o, a, b [2]float64) float64 {
+		return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+	}
+
+	// Andrew's monotone chain, counter-clockwise without collinear points
+	hull := make([][2]float64, 0, 2*len(points))
+	for _, p := range points {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(points) - 2; i >= 0; i-- {
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], points[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, points[i])
+	}
+	if len(hull) > 1 {
+		hull = hull[:len(hull)-1]
+	}
+
+	result := make([]float64, 0, len(hull)*2)
+	for _, p := range hull {
+		result = append(result, p[0], p[1])
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: convexHull of %d points has %d vertices\n", len(points), len(hull))
+	}
+	return newFloat64Array(result)
+}
+
+func pointInPolygon(this js.Value, args []js.Value) interface{} {
+	if len(args) != 3 {
+		return js.ValueOf(localize("Error: three arguments required for pointInPolygon (x, y, polygon)"))
+	}
+
+	x, y := args[0].Float(), args[1].Float()
+	coords, err := readPoints(args[2], 3)
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+
+	// Ray casting (even-odd rule)
+	inside := false
+	n := len(coords) / 2
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		xi, yi := coords[2*i], coords[2*i+1]
+		xj, yj := coords[2*j], coords[2*j+1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: pointInPolygon(%f, %f) = %t\n", x, y, inside)
+	}
+	return js.ValueOf(inside)
+}
+
+func boundingBox(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(localize("Error: one argument required for %s", "boundingBox"))
+	}
+
+	coords, err := readPoints(args[0], 1)
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i := 0; i < len(coords); i += 2 {
+		minX = math.Min(minX, coords[i])
+		maxX = math.Max(maxX, coords[i])
+		minY = math.Min(minY, coords[i+1])
+		maxY = math.Max(maxY, coords[i+1])
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: boundingBox of %d points = [%f, %f, %f, %f]\n", len(coords)/2, minX, minY, maxX, maxY)
+	}
+	return js.ValueOf(map[string]interface{}{
+		"minX":   minX,
+		"minY":   minY,
+		"maxX":   maxX,
+		"maxY":   maxY,
+		"width":  maxX - minX,
+		"height": maxY - minY,
+	})
+}
+
+// Vector and matrix functions
+// Vectors are Float64Array (or Array) values and matrices are flat row-major buffers. The kernels work
+// through chunks of vectorChunk elements with four independent accumulators: Go does not emit wasm SIMD
+// instructions, so unrolled lanes are what lets the engine overlap the floating point work.
+
+// vectorChunk is the number of elements a kernel handles per pass, small enough to stay in cache
+const vectorChunk = 4096
+
+// matrixBlock is the number of inner rows of b accumulated per pass of matrixMultiply
+const matrixBlock = 64
+
+// readVector converts a Float64Array or Array of numbers into a Go slice
+func readVector(value js.Value) ([]float64, error) {
+	if value.Type() != js.TypeObject {
+		return nil, errors.New(localize("vectors must be a Float64Array or an array of numbers"))
+	}
+	return readCoordinates(value)
+}
+
+// readVectorPair reads two vectors of the same length
+func readVectorPair(a, b js.Value) ([]float64, []float64, error) {
+	x, err := readVector(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	y, err := readVector(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(x) != len(y) {
+		return nil, nil, fmt.Errorf(localize("vectors must have the same length (%d and %d)"), len(x), len(y))
+	}
+	return x, y, nil
+}
+
+// chunkEnd returns the end of the chunk starting at start
+func chunkEnd(start, n int) int {
+	if start+vectorChunk < n {
+		return start + vectorChunk
+	}
+	return n
+}
+
+// dotKernel returns the dot product of two slices of the same length, four lanes at a time
+func dotKernel(a, b []float64) float64 {
+	var s0, s1, s2, s3 float64
+	b = b[:len(a)]
+	n := len(a) &^ 3
+	for i := 0; i < n; i += 4 {
+		x, y := a[i:i+4:i+4], b[i:i+4:i+4]
+		s0 += x[0] * y[0]
+		s1 += x[1] * y[1]
+		s2 += x[2] * y[2]
+		s3 += x[3] * y[3]
+	}
+	for i, v := range a[n:] {
+		s0 += v * b[n+i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// dotChunked sums the dot products of each chunk, which also keeps rounding errors from growing with n
+func dotChunked(a, b []float64) float64 {
+	total := 0.0
+	for start := 0; start < len(a); start += vectorChunk {
+		end := chunkEnd(start, len(a))
+		total += dotKernel(a[start:end], b[start:end])
+	}
+	return total
+}
+
+// axpyKernel adds alpha*x to y in place, four lanes at a time
+func axpyKernel(alpha float64, x, y []float64) {
+	y = y[:len(x)]
+	n := len(x) &^ 3
+	for i := 0; i < n; i += 4 {
+		xs, ys := x[i:i+4:i+4], y[i:i+4:i+4]
+		ys[0] += alpha * xs[0]
+		ys[1] += alpha * xs[1]
+		ys[2] += alpha * xs[2]
+		ys[3] += alpha * xs[3]
+	}
+	for i, v := range x[n:] {
+		y[n+i] += alpha * v
+	}
+}
+
+// sumKernel returns the sum, minimum and maximum of a non-empty slice
+func sumKernel(values []float64) (float64, float64, float64) {
+	var s0, s1, s2, s3 float64
+	lo, hi := values[0], values[0]
+	n := len(values) &^ 3
+	for i := 0; i < n; i += 4 {
+		v := values[i : i+4 : i+4]
+		s0 += v[0]
+		s1 += v[1]
+		s2 += v[2]
+		s3 += v[3]
+		lo, hi = minMax(lo, hi, v[0], v[1])
+		lo, hi = minMax(lo, hi, v[2], v[3])
+	}
+	for _, v := range values[n:] {
+		s0 += v
+		lo, hi = minMax(lo, hi, v, v)
+	}
+	return (s0 + s1) + (s2 + s3), lo, hi
+}
+
+// minMax widens [lo, hi] to include a and b, comparing them with each other first
+func minMax(lo, hi, a, b float64) (float64, float64) {
+	if a > b {
+		a, b = b, a
+	}
+	if a < lo {
+		lo = a
+	}
+	if b > hi {
+		hi = b
+	}
+	return lo, hi
+}
+
+// squaredDeviationKernel returns the sum of squared deviations from mean
+func squaredDeviationKernel(values []float64, mean float64) float64 {
+	var s0, s1, s2, s3 float64
+	n := len(values) &^ 3
+	for i := 0; i < n; i += 4 {
+		v := values[i : i+4 : i+4]
+		d0, d1, d2, d3 := v[0]-mean, v[1]-mean, v[2]-mean, v[3]-mean
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
+	}
+	for _, v := range values[n:] {
+		d := v - mean
+		s0 += d * d
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// vectorSummary computes the sum, minimum, maximum and population variance of a non-empty vector chunk
+// by chunk, in two passes
+func vectorSummary(values []float64) (float64, float64, float64, float64) {
+	sum, lo, hi := 0.0, math.Inf(1), math.Inf(-1)
+	for start := 0; start < len(values); start += vectorChunk {
+		s, l, h := sumKernel(values[start:chunkEnd(start, len(values))])
+		sum += s
+		lo, hi = math.Min(lo, l), math.Max(hi, h)
+	}
+	mean := sum / float64(len(values))
+
+	deviation := 0.0
+	for start := 0; start < len(values); start += vectorChunk {
+		deviation += squaredDeviationKernel(values[start:chunkEnd(start, len(values))], mean)
+	}
+	return sum, lo, hi, deviation / float64(len(values))
+}
+
+// matrixMultiplyKernel multiplies a (rows x inner) by b (inner x cols). Each output row accumulates
+// scaled rows of b, a block of them at a time, so both matrices are read sequentially.
+func matrixMultiplyKernel(a, b []float64, rows, inner, cols int) []float64 {
+	out := make([]float64, rows*cols)
+	for kStart := 0; kStart < inner; kStart += matrixBlock {
+		kEnd := kStart + matrixBlock
+		if kEnd > inner {
+			kEnd = inner
+		}
+		for i := 0; i < rows; i++ {
+			row := out[i*cols : (i+1)*cols]
+			for k := kStart; k < kEnd; k++ {
+				axpyKernel(a[i*inner+k], b[k*cols:(k+1)*cols], row)
+			}
+		}
+	}
+	return out
+}
+
+// matrixDimension reads a positive integer dimension
+func matrixDimension(value js.Value) (int, bool) {
+	if value.Type() != js.TypeNumber {
+		return 0, false
+	}
+	n := value.Float()
+	if n < 1 || n != math.Trunc(n) || n > math.MaxInt32 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+func dot(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.ValueOf(localize("Error: two arguments required for %s", "dot"))
+	}
+
+	a, b, err := readVectorPair(args[0], args[1])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+
+	result := dotChunked(a, b)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: dot product of %d values = %f\n", len(a), result)
+	}
+	return js.ValueOf(result)
+}
+
+func axpy(this js.Value, args []js.Value) interface{} {
+	if len(args) != 3 {
+		return js.ValueOf(localize("Error: three arguments required for axpy (alpha, x, y)"))
+	}
+
+	x, y, err := readVectorPair(args[1], args[2])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+
+	// y was copied out of JavaScript, the caller's array is left untouched
+	alpha := args[0].Float()
+	for start := 0; start < len(x); start += vectorChunk {
+		end := chunkEnd(start, len(x))
+		axpyKernel(alpha, x[start:end], y[start:end])
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: axpy of %d values\n", len(x))
+	}
+	return newFloat64Array(y)
+}
+
+func vectorNorm(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(localize("Error: one argument required for %s", "vectorNorm"))
+	}
+
+	values, err := readVector(args[0])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+
+	result := math.Sqrt(dotChunked(values, values))
+	if math.IsInf(result, 0) || (result == 0 && len(values) > 0) {
+		// The squares overflowed or underflowed: scale by the largest magnitude first
+		scale := 0.0
+		for _, v := range values {
+			scale = math.Max(scale, math.Abs(v))
+		}
+		if scale > 0 && !math.IsInf(scale, 0) {
+			scaled := make([]float64, len(values))
+			for i, v := range values {
+				scaled[i] = v / scale
+			}
+			result = scale * math.Sqrt(dotChunked(scaled, scaled))
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: norm of %d values = %f\n", len(values), result)
+	}
+	return js.ValueOf(result)
+}
+
+func vectorStats(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(localize("Error: one argument required for %s", "vectorStats"))
+	}
+
+	values, err := readVector(args[0])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+	if len(values) == 0 {
+		return js.ValueOf(localize("Error: at least one value required for %s", "vectorStats"))
+	}
+
+	sum, lo, hi, variance := vectorSummary(values)
+	mean := sum / float64(len(values))
+
+	if !silentMode {
+		fmt.Printf("Go WASM: vectorStats of %d values, mean = %f\n", len(values), mean)
+	}
+	return js.ValueOf(map[string]interface{}{
+		"count":             len(values),
+		"sum":               sum,
+		"mean":              mean,
+		"min":               lo,
+		"max":               hi,
+		"variance":          variance,
+		"standardDeviation": math.Sqrt(variance),
+	})
+}
+
+func matrixMultiply(this js.Value, args []js.Value) interface{} {
+	if len(args) != 5 {
+		return js.ValueOf(localize("Error: five arguments required for matrixMultiply (a, b, rows, inner, cols)"))
+	}
+
+	a, err := readVector(args[0])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+	b, err := readVector(args[1])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+	rows, ok1 := matrixDimension(args[2])
+	inner, ok2 := matrixDimension(args[3])
+	cols, ok3 := matrixDimension(args[4])
+	if !ok1 || !ok2 || !ok3 {
+		return js.ValueOf(localize("Error: ") + localize("matrix dimensions must be positive integers"))
+	}
+	if len(a) != rows*inner {
+		return js.ValueOf(localize("Error: ") + localize("matrix %s must hold %d values, got %d", "a", rows*inner, len(a)))
+	}
+	if len(b) != inner*cols {
+		return js.ValueOf(localize("Error: ") + localize("matrix %s must hold %d values, got %d", "b", inner*cols, len(b)))
+	}
+
+	result := matrixMultiplyKernel(a, b, rows, inner, cols)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: matrixMultiply %dx%d by %dx%d\n", rows, inner, inner, cols)
+	}
+	return newFloat64Array(result)
+}
+
+// sparseMatrix is a matrix in compressed sparse row form: the non-zero values of row i are
+// values[rowOffsets[i]:rowOffsets[i+1]], in the columns listed at the same positions of columns
+type sparseMatrix struct {
+	rows, cols int
+	values     []float64
+	columns    []int
+	rowOffsets []int
+}
+
+// readSparseMatrix reads and validates a {rows, cols, values, columns, rowOffsets} object
+func readSparseMatrix(value js.Value) (*sparseMatrix, error) {
+	if value.Type() != js.TypeObject {
+		return nil, errors.New(localize("sparse matrix must be an object {rows, cols, values, columns, rowOffsets}"))
+	}
+	rows, ok1 := matrixDimension(value.Get("rows"))
+	cols, ok2 := matrixDimension(value.Get("cols"))
+	if !ok1 || !ok2 {
+		return nil, errors.New(localize("matrix dimensions must be positive integers"))
+	}
+
+	m := &sparseMatrix{rows: rows, cols: cols}
+	var err error
+	if m.values, err = readVector(value.Get("values")); err != nil {
+		return nil, err
+	}
+	readIndices := func(name string) ([]int, error) {
+		raw, err := readVector(value.Get(name))
+		if err != nil {
+			return nil, err
+		}
+		indices := make([]int, len(raw))
+		for i, v := range raw {
+			if v < 0 || v != math.Trunc(v) {
+				return nil, fmt.Errorf(localize("%s[%d] must be a non-negative integer"), name, i)
+			}
+			indices[i] = int(v)
+		}
+		return indices, nil
+	}
+	if m.columns, err = readIndices("columns"); err != nil {
+		return nil, err
+	}
+	if m.rowOffsets, err = readIndices("rowOffsets"); err != nil {
+		return nil, err
+	}
+
+	if len(m.columns) != len(m.values) {
+		return nil, fmt.Errorf(localize("columns must hold one index per value (%d values, %d columns)"), len(m.values), len(m.columns))
+	}
+	if len(m.rowOffsets) != rows+1 || m.rowOffsets[0] != 0 || m.rowOffsets[rows] != len(m.values) {
+		return nil, fmt.Errorf(localize("rowOffsets must hold %d increasing offsets from 0 to %d"), rows+1, len(m.values))
+	}
+	for i := 1; i <= rows; i++ {
+		if m.rowOffsets[i] < m.rowOffsets[i-1] {
+			return nil, fmt.Errorf(localize("rowOffsets must hold %d increasing offsets from 0 to %d"), rows+1, len(m.values))
+		}
+	}
+	for i, c := range m.columns {
+		if c >= cols {
+			return nil, fmt.Errorf(localize("columns[%d] = %d is outside the %d columns"), i, c, cols)
+		}
+	}
+	return m, nil
+}
+
+// multiplyVector returns m x, visiting only the stored values
+func (m *sparseMatrix) multiplyVector(x []float64) []float64 {
+	out := make([]float64, m.rows)
+	for i := 0; i < m.rows; i++ {
+		sum := 0.0
+		for j := m.rowOffsets[i]; j < m.rowOffsets[i+1]; j++ {
+			sum += m.values[j] * x[m.columns[j]]
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+func sparseMatrixVector(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.ValueOf(localize("Error: two arguments required for %s", "sparseMatrixVector"))
+	}
+
+	m, err := readSparseMatrix(args[0])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+	x, err := readVector(args[1])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+	if len(x) != m.cols {
+		return js.ValueOf(localize("Error: ") + localize("vector must hold one value per column (%d columns, %d values)", m.cols, len(x)))
+	}
+
+	result := m.multiplyVector(x)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: sparseMatrixVector %dx%d with %d stored values\n", m.rows, m.cols, len(m.values))
+	}
+	return newFloat64Array(result)
+}
+
+// Linear programming
+// solveLP maximizes or minimizes c·x subject to linear constraints and x >= 0, with the two-phase simplex
+// method on a dense tableau. That is the right tool for the small problems of budgeting and allocation
+// pages; larger ones call for a sparse, revised simplex.
+
+// lpMaxSize bounds the number of variables and of constraints, keeping the tableau under 10 MB
+const lpMaxSize = 500
+
+// lpEpsilon is the tolerance under which tableau values count as zero
+const lpEpsilon = 1e-9
+
+// lpConstraint is one constraint: coefficients·x op rhs, with op "<=", ">=" or "="
+type lpConstraint struct {
+	coefficients []float64
+	op           string
+	rhs          float64
+}
+
+// lpTableau is a simplex tableau: one row per constraint then the objective row, one column per
+// variable then the right-hand side. The objective row holds the reduced costs, the value in its last column.
+type lpTableau struct {
+	rows, cols int
+	cells      [][]float64
+	basis      []int
+}
+
+// pivot makes column col basic in row row
+func (t *lpTableau) pivot(row, col int) {
+	pivotRow := t.cells[row]
+	scale := 1 / pivotRow[col]
+	for j := range pivotRow {
+		pivotRow[j] *= scale
+	}
+	pivotRow[col] = 1
+	for i, r := range t.cells {
+		if i == row || r[col] == 0 {
+			continue
+		}
+		factor := r[col]
+		for j := range r {
+			r[j] -= factor * pivotRow[j]
+		}
+		r[col] = 0
+	}
+	t.basis[row] = col
+}
+
+// setObjective fills the objective row for maximizing c·x with the current basis
+func (t *lpTableau) setObjective(c []float64) {
+	objective := t.cells[t.rows]
+	for j := range objective {
+		objective[j] = 0
+	}
+	for j, cj := range c {
+		objective[j] = -cj
+	}
+	for i, b := range t.basis {
+		if b < len(c) && c[b] != 0 {
+			for j, v := range t.cells[i] {
+				objective[j] += c[b] * v
+			}
+		}
+	}
+}
+
+// maximize pivots until no column among the first allowed ones improves the objective. It picks the most
+// negative reduced cost, falling back to Bland's rule (lowest index) after degenerate pivots so it cannot
+// cycle. It returns "optimal", "unbounded" or "iterationLimit", and the number of pivots.
+func (t *lpTableau) maximize(allowed, maxIterations int) (string, int) {
+	degenerate := 0
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		objective := t.cells[t.rows]
+		col := -1
+		for j := 0; j < allowed; j++ {
+			if objective[j] >= -lpEpsilon {
+				continue
+			}
+			if col < 0 || degenerate < 50 && objective[j] < objective[col] {
+				col = j
+			}
+		}
+		if col < 0 {
+			return "optimal", iteration
+		}
+
+		row := -1
+		best := math.Inf(1)
+		for i := 0; i < t.rows; i++ {
+			a := t.cells[i][col]
+			if a <= lpEpsilon {
+				continue
+			}
+			ratio := t.cells[i][t.cols] / a
+			if row < 0 || ratio < best-lpEpsilon || ratio <= best+lpEpsilon && t.basis[i] < t.basis[row] {
+				row, best = i, ratio
+			}
+		}
+		if row < 0 {
+			return "unbounded", iteration
+		}
+
+		if best <= lpEpsilon {
+			degenerate++
+		} else {
+			degenerate = 0
+		}
+		t.pivot(row, col)
+	}
+	return "iterationLimit", maxIterations
+}
+
+// lpSolution is the outcome of solveLinearProgram
+type lpSolution struct {
+	status       string
+	value        float64
+	x            []float64
+	slack        []float64
+	shadowPrices []float64
+	iterations   int
+}
+
+// solveLinearProgram optimizes c·x under the constraints, with x >= 0
+func solveLinearProgram(c []float64, maximize bool, constraints []lpConstraint, maxIterations int) lpSolution {
+	n, m := len(c), len(constraints)
+
+	// Columns: the variables, one slack or surplus per inequality, then one artificial variable per row
+	// without a slack to start the basis from. Rows are flipped so every right-hand side is non-negative.
+	sign := make([]float64, m)
+	ops := make([]string, m)
+	slackCol := make([]int, m)
+	identity := make([]int, m)
+	cols := n
+	for i, constraint := range constraints {
+		sign[i], ops[i] = 1, constraint.op
+		if constraint.rhs < 0 {
+			sign[i] = -1
+			switch constraint.op {
+			case "<=":
+				ops[i] = ">="
+			case ">=":
+				ops[i] = "<="
+			}
+		}
+		slackCol[i] = -1
+		if ops[i] != "=" {
+			slackCol[i] = cols
+			cols++
+		}
+	}
+	artificialStart := cols
+	for i := range constraints {
+		if ops[i] == "<=" {
+			identity[i] = slackCol[i]
+		} else {
+			identity[i] = cols
+			cols++
+		}
+	}
+
+	t := &lpTableau{rows: m, cols: cols, cells: make([][]float64, m+1), basis: make([]int, m)}
+	for i := range t.cells {
+		t.cells[i] = make([]float64, cols+1)
+	}
+	for i, constraint := range constraints {
+		row := t.cells[i]
+		for j, a := range constraint.coefficients {
+			row[j] = sign[i] * a
+		}
+		switch ops[i] {
+		case "<=":
+			row[slackCol[i]] = 1
+		case ">=":
+			row[slackCol[i]] = -1
+		}
+		row[identity[i]] = 1
+		row[cols] = sign[i] * constraint.rhs
+		t.basis[i] = identity[i]
+	}
+
+	// Phase 1: drive the artificial variables to zero
+	iterations := 0
+	if artificialStart < cols {
+		phase1 := make([]float64, cols)
+		for j := artificialStart; j < cols; j++ {
+			phase1[j] = -1
+		}
+		t.setObjective(phase1)
+		status, pivots := t.maximize(cols, maxIterations)
+		iterations += pivots
+		if status == "iterationLimit" {
+			return lpSolution{status: status, iterations: iterations}
+		}
+		scale := 1.0
+		for _, constraint := range constraints {
+			scale = math.Max(scale, math.Abs(constraint.rhs))
+		}
+		if t.cells[m][cols] < -lpEpsilon*scale*float64(m) {
+			return lpSolution{status: "infeasible", iterations: iterations}
+		}
+
+		// Artificial variables left in the basis at zero are swapped for real ones; a row where that is
+		// impossible is a redundant equality and keeps its artificial variable, which can never grow again
+		for i, b := range t.basis {
+			if b < artificialStart {
+				continue
+			}
+			for j := 0; j < artificialStart; j++ {
+				if math.Abs(t.cells[i][j]) > lpEpsilon {
+					t.pivot(i, j)
+					iterations++
+					break
+				}
+			}
+		}
+	}
+
+	// Phase 2: optimize the objective over the original and slack columns
+	objective := make([]float64, n)
+	for j, cj := range c {
+		objective[j] = cj
+		if !maximize {
+			objective[j] = -cj
+		}
+	}
+	t.setObjective(objective)
+	status, pivots := t.maximize(artificialStart, maxIterations-iterations)
+	iterations += pivots
+	if status != "optimal" {
+		return lpSolution{status: status, iterations: iterations}
+	}
+
+	solution := lpSolution{
+		status:       "optimal",
+		x:            make([]float64, n),
+		slack:        make([]float64, m),
+		shadowPrices: make([]float64, m),
+		iterations:   iterations,
+	}
+	for i, b := range t.basis {
+		if b < n {
+			solution.x[b] = lpClean(t.cells[i][cols])
+		}
+	}
+	for j, cj := range c {
+		solution.value += cj * solution.x[j]
+	}
+	for i, constraint := range constraints {
+		lhs := 0.0
+		for j, a := range constraint.coefficients {
+			lhs += a * solution.x[j]
+		}
+		switch constraint.op {
+		case "<=":
+			solution.slack[i] = lpClean(constraint.rhs - lhs)
+		case ">=":
+			solution.slack[i] = lpClean(lhs - constraint.rhs)
+		}
+
+		// The reduced cost of the column that started as the row's identity is the row's dual value
+		dual := sign[i] * t.cells[m][identity[i]]
+		if !maximize {
+			dual = -dual
+		}
+		solution.shadowPrices[i] = lpClean(dual)
+	}
+	return solution
+}
+
+// lpClean rounds values within the tolerance of zero to zero
+func lpClean(v float64) float64 {
+	if math.Abs(v) < lpEpsilon {
+		return 0
+	}
+	return v
+}
+
+// readLinearProgram reads the {maximize: [...]} or {minimize: [...]} objective and the
+// [{coefficients, op, rhs}] constraints of solveLP
+func readLinearProgram(objective, constraints js.Value) ([]float64, bool, []lpConstraint, error) {
+	if objective.Type() != js.TypeObject {
+		return nil, false, nil, errors.New(localize("objective must be an object {maximize: [...]} or {minimize: [...]}"))
+	}
+	maximize := true
+	coefficients := objective.Get("maximize")
+	if coefficients.IsUndefined() {
+		maximize = false
+		coefficients = objective.Get("minimize")
+	}
+	if coefficients.IsUndefined() {
+		return nil, false, nil, errors.New(localize("objective must be an object {maximize: [...]} or {minimize: [...]}"))
+	}
+	c, err := readVector(coefficients)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	if len(c) == 0 || len(c) > lpMaxSize {
+		return nil, false, nil, fmt.Errorf(localize("the objective must have between 1 and %d variables"), lpMaxSize)
+	}
+
+	if constraints.Type() != js.TypeObject || constraints.Get("length").Type() != js.TypeNumber {
+		return nil, false, nil, errors.New(localize("constraints must be an array of {coefficients, op, rhs}"))
+	}
+	count := constraints.Length()
+	if count > lpMaxSize {
+		return nil, false, nil, fmt.Errorf(localize("at most %d constraints are supported"), lpMaxSize)
+	}
+
+	finite := func(v float64) bool { return !math.IsNaN(v) && !math.IsInf(v, 0) }
+	for _, v := range c {
+		if !finite(v) {
+			return nil, false, nil, errors.New(localize("coefficients must be finite numbers"))
+		}
+	}
+	rows := make([]lpConstraint, count)
+	for i := range rows {
+		item := constraints.Index(i)
+		if item.Type() != js.TypeObject {
+			return nil, false, nil, fmt.Errorf(localize("constraint %d must be an object {coefficients, op, rhs}"), i)
+		}
+		a, err := readVector(item.Get("coefficients"))
+		if err != nil {
+			return nil, false, nil, err
+		}
+		if len(a) != len(c) {
+			return nil, false, nil, fmt.Errorf(localize("constraint %d must have %d coefficients, got %d"), i, len(c), len(a))
+		}
+		op := "<="
+		if v := item.Get("op"); v.Type() == js.TypeString {
+			op = v.String()
+		}
+		if op == "==" {
+			op = "="
+		}
+		if op != "<=" && op != ">=" && op != "=" {
+			return nil, false, nil, fmt.Errorf(localize("constraint %d has unknown operator %q (use <=, >= or =)"), i, op)
+		}
+		rhs := item.Get("rhs")
+		if rhs.Type() != js.TypeNumber || !finite(rhs.Float()) {
+			return nil, false, nil, fmt.Errorf(localize("constraint %d needs a finite rhs"), i)
+		}
+		for _, v := range a {
+			if !finite(v) {
+				return nil, false, nil, errors.New(localize("coefficients must be finite numbers"))
+			}
+		}
+		rows[i] = lpConstraint{coefficients: a, op: op, rhs: rhs.Float()}
+	}
+	return c, maximize, rows, nil
+}
+
+func solveLP(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 && len(args) != 3 {
+		return js.ValueOf(localize("Error: two or three arguments required for %s", "solveLP"))
+	}
+
+	c, maximize, constraints, err := readLinearProgram(args[0], args[1])
+	if err != nil {
+		return js.ValueOf(localize("Error: ") + err.Error())
+	}
+	maxIterations := 50 * (len(c) + len(constraints))
+	if len(args) == 3 && args[2].Type() == js.TypeObject {
+		if v := args[2].Get("maxIterations"); v.Type() == js.TypeNumber && v.Int() > 0 {
+			maxIterations = v.Int()
+		}
+	}
+
+	solution := solveLinearProgram(c, maximize, constraints, maxIterations)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: solveLP with %d variables and %d constraints: %s after %d pivots\n", len(c), len(constraints), solution.status, solution.iterations)
+	}
+	result := map[string]interface{}{
+		"status":     solution.status,
+		"iterations": solution.iterations,
+	}
+	if solution.status == "optimal" {
+		result["value"] = solution.value
+		result["variables"] = newFloat64Array(solution.x)
+		result["slack"] = newFloat64Array(solution.slack)
+		result["shadowPrices"] = newFloat64Array(solution.shadowPrices)
+	}
+	return js.ValueOf(result)
+}
+
+// Benchmarks
+
+// benchmarkCase times one operation in three forms: the chunked kernel used by the module, a plain scalar
+// loop compiled by the same toolchain, and the same loop written in JavaScript when the page allows
+// compiling it.
+type benchmarkCase struct {
+	name    string
+	size    int
+	chunked func() float64
+	scalar  func() float64
+	js      func() float64
+}
+
+// timeRun calls run until the duration has elapsed, at least three times, and returns the mean time of
+// a call in milliseconds along with the number of calls and the last result. It first runs untimed for
+// the same duration, so that engines which compile hot code again have done so before it is measured.
+func timeRun(run func() float64, duration time.Duration) (float64, int, float64) {
+	var result float64
+	for start := time.Now(); time.Since(start) < duration; {
+		result = run()
+	}
+	iterations := 0
+	start := time.Now()
+	for iterations < 3 || time.Since(start) < duration {
+		result = run()
+		iterations++
+	}
+	return float64(time.Since(start).Microseconds()) / 1000 / float64(iterations), iterations, result
+}
+
+// jsBenchmark compiles a JavaScript loop; ok is false when the page forbids compiling code (CSP)
+func jsBenchmark(params, body string) (fn js.Value, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return js.Global().Get("Function").New(params, body), true
+}
+
+// sameResult reports whether two results agree to a relative tolerance
+func sameResult(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func selfBenchmark(this js.Value, args []js.Value) interface{} {
+	size, matrixSize, duration := 1<<16, 128, 50*time.Millisecond
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		if v := args[0].Get("size"); v.Type() == js.TypeNumber && v.Int() > 0 {
+			size = v.Int()
+		}
+		if v := args[0].Get("matrixSize"); v.Type() == js.TypeNumber && v.Int() > 0 {
+			matrixSize = v.Int()
+		}
+		if v := args[0].Get("duration"); v.Type() == js.TypeNumber && v.Float() > 0 {
+			duration = time.Duration(v.Float() * float64(time.Millisecond))
+		}
+	}
+
+	// Deterministic inputs, so results can be compared between runs and browsers
+	a, b := make([]float64, size), make([]float64, size)
+	for i := range a {
+		a[i] = math.Sin(float64(i)) * 10
+		b[i] = math.Cos(float64(i)*0.5) * 10
+	}
+	n := matrixSize
+	ma, mb := make([]float64, n*n), make([]float64, n*n)
+	for i := range ma {
+		ma[i] = float64(i%17) - 8
+		mb[i] = float64(i%13) - 6
+	}
+	jsA, jsB := newFloat64Array(a), newFloat64Array(b)
+	jsMA, jsMB := newFloat64Array(ma), newFloat64Array(mb)
+	y := make([]float64, size)
+
+	cases := []benchmarkCase{
+		{
+			name:    "dot",
+			size:    size,
+			chunked: func() float64 { return dotChunked(a, b) },
+			scalar: func() float64 {
+				sum := 0.0
+				for i := range a {
+					sum += a[i] * b[i]
+				}
+				return sum
+			},
+		},
+		{
+			name: "axpy",
+			size: size,
+			chunked: func() float64 {
+				copy(y, b)
+				for start := 0; start < size; start += vectorChunk {
+					end := chunkEnd(start, size)
+					axpyKernel(1.5, a[start:end], y[start:end])
+				}
+				return y[size-1]
+			},
+			scalar: func() float64 {
+				copy(y, b)
+				for i := range a {
+					y[i] += 1.5 * a[i]
+				}
+				return y[size-1]
+			},
+		},
+		{
+			name: "vectorStats",
+			size: size,
+			chunked: func() float64 {
+				_, _, _, variance := vectorSummary(a)
+				return variance
+			},
+			scalar: func() float64 {
+				sum, lo, hi := 0.0, a[0], a[0]
+				for _, v := range a {
+					sum += v
+					lo, hi = math.Min(lo, v), math.Max(hi, v)
+				}
+				mean := sum / float64(size)
+				deviation := 0.0
+				for _, v := range a {
+					deviation += (v - mean) * (v - mean)
+				}
+				return deviation / float64(size)
+			},
+		},
+		{
+			name:    "matrixMultiply",
+			size:    n,
+			chunked: func() float64 { return matrixMultiplyKernel(ma, mb, n, n, n)[n*n-1] },
+			scalar: func() float64 {
+				out := make([]float64, n*n)
+				for i := 0; i < n; i++ {
+					for j := 0; j < n; j++ {
+						sum := 0.0
+						for k := 0; k < n; k++ {
+							sum += ma[i*n+k] * mb[k*n+j]
+						}
+						out[i*n+j] = sum
+					}
+				}
+				return out[n*n-1]
+			},
+		},
+	}
+
+	if fn, ok := jsBenchmark("a, b", "let s = 0; for (let i = 0; i < a.length; i++) s += a[i] * b[i]; return s;"); ok {
+		cases[0].js = func() float64 { return fn.Invoke(jsA, jsB).Float() }
+	}
+	if fn, ok := jsBenchmark("a, b, n", "const o = new Float64Array(n * n); for (let i = 0; i < n; i++) for (let j = 0; j < n; j++) { let s = 0; for (let k = 0; k < n; k++) s += a[i * n + k] * b[k * n + j]; o[i * n + j] = s; } return o[n * n - 1];"); ok {
+		cases[3].js = func() float64 { return fn.Invoke(jsMA, jsMB, n).Float() }
+	}
+
+	results := make([]interface{}, 0, len(cases))
+	for _, c := range cases {
+		chunkedMs, iterations, chunkedResult := timeRun(c.chunked, duration)
+		scalarMs, _, scalarResult := timeRun(c.scalar, duration)
+		entry := map[string]interface{}{
+			"name":       c.name,
+			"size":       c.size,
+			"iterations": iterations,
+			"chunkedMs":  chunkedMs,
+			"scalarMs":   scalarMs,
+			"speedup":    scalarMs / math.Max(chunkedMs, 1e-6),
+			"verified":   sameResult(chunkedResult, scalarResult),
+		}
+		if c.js != nil {
+			jsMs, _, jsResult := timeRun(c.js, duration)
+			entry["jsMs"] = jsMs
+			entry["jsSpeedup"] = jsMs / math.Max(chunkedMs, 1e-6)
+			entry["verified"] = entry["verified"].(bool) && sameResult(chunkedResult, jsResult)
+		}
+		results = append(results, entry)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: selfBenchmark ran %d cases\n", len(results))
+	}
+	return js.ValueOf(map[string]interface{}{
+		"simd":       false,
+		"kernel":     "chunked, 4 lanes",
+		"chunkSize":  vectorChunk,
+		"goVersion":  runtime.Version(),
+		"durationMs": float64(duration.Microseconds()) / 1000,
+		"results":    results,
+	})
+}
+
+// Build metadata, injected by wasm-manager build through -ldflags -X
+var (
+	moduleVersion = "0.2.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"arithmetic",
+	"trigonometry",
+	"logarithms",
+	"number-theory",
+	"statistics",
+	"rounding",
+	"geometry",
+	"linear-algebra",
+	"sparse-matrices",
+	"benchmark",
+	"linear-programming",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{}))
+}
+
+// releaseResources - Return freed memory to the runtime; math-wasm keeps no cached state between calls
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	advertised := getAvailableFunctions(this, nil).(js.Value)
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "math-wasm",
+		"version":         moduleVersion,
+		"description":     "High-performance mathematical calculation module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
 func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 	functions := []interface{}{
 		// Basic arithmetic
@@ -538,9 +2281,18 @@ func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 		// Statistical
 		"mean", "median", "standardDeviation",
 		// Utility
-		"round", "ceil", "floor",
+		"round", "ceil", "floor", "roundHalfEven", "roundToIncrement", "clamp",
+		"percentChange", "simplifyRatio",
+		// Geometry
+		"distance", "lineIntersection", "polygonArea", "polygonCentroid",
+		"convexHull", "pointInPolygon", "boundingBox",
+		// Vectors and matrices
+		"dot", "axpy", "vectorNorm", "vectorStats", "matrixMultiply", "sparseMatrixVector",
+		// Optimization
+		"solveLP",
 		// System
-		"getAvailableFunctions", "setSilentMode",
+		"getAvailableFunctions", "setSilentMode", "setLocale", "getModuleInfo",
+		"getMemoryStats", "releaseResources", "selfBenchmark",
 	}
 	return js.ValueOf(functions)
 }
@@ -586,16 +2338,46 @@ func main() {
 	js.Global().Set("round", js.FuncOf(round))
 	js.Global().Set("ceil", js.FuncOf(ceil))
 	js.Global().Set("floor", js.FuncOf(floor))
+	js.Global().Set("roundHalfEven", js.FuncOf(roundHalfEven))
+	js.Global().Set("roundToIncrement", js.FuncOf(roundToIncrement))
+	js.Global().Set("clamp", js.FuncOf(clamp))
+	js.Global().Set("percentChange", js.FuncOf(percentChange))
+	js.Global().Set("simplifyRatio", js.FuncOf(simplifyRatio))
+
+	// Register geometry functions
+	js.Global().Set("distance", js.FuncOf(distance))
+	js.Global().Set("lineIntersection", js.FuncOf(lineIntersection))
+	js.Global().Set("polygonArea", js.FuncOf(polygonArea))
+	js.Global().Set("polygonCentroid", js.FuncOf(polygonCentroid))
+	js.Global().Set("convexHull", js.FuncOf(convexHull))
+	js.Global().Set("pointInPolygon", js.FuncOf(pointInPolygon))
+	js.Global().Set("boundingBox", js.FuncOf(boundingBox))
+
+	// Register vector and matrix functions
+	js.Global().Set("dot", js.FuncOf(dot))
+	js.Global().Set("axpy", js.FuncOf(axpy))
+	js.Global().Set("vectorNorm", js.FuncOf(vectorNorm))
+	js.Global().Set("vectorStats", js.FuncOf(vectorStats))
+	js.Global().Set("matrixMultiply", js.FuncOf(matrixMultiply))
+	js.Global().Set("sparseMatrixVector", js.FuncOf(sparseMatrixVector))
+	js.Global().Set("selfBenchmark", js.FuncOf(selfBenchmark))
+
+	// Register optimization functions
+	js.Global().Set("solveLP", js.FuncOf(solveLP))
 
 	// Register system functions
 	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
 	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
 
 	// Signal readiness for GoWM
 	js.Global().Set("__gowm_ready", js.ValueOf(true))
 
 	fmt.Println("Go WASM Enhanced Math Module ready!")
-	fmt.Println("Available functions: Basic arithmetic, Advanced math, Trigonometry, Logarithms, Number theory, Statistics, Utilities")
+	fmt.Println("Available functions: Basic arithmetic, Advanced math, Trigonometry, Logarithms, Number theory, Statistics, Utilities, Geometry, Vectors and matrices, Linear programming")
 
 	// Keep the program alive
 	select {}
@@ -0,0 +1,3 @@
+module ml-wasm
+
+go 1.21
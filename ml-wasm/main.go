@@ -0,0 +1,3590 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+var silentMode = false
+
+// ONNX tensor element types (TensorProto.DataType)
+const (
+	typeFloat   = 1
+	typeUint8   = 2
+	typeInt8    = 3
+	typeUint16  = 4
+	typeInt16   = 5
+	typeInt32   = 6
+	typeInt64   = 7
+	typeString  = 8
+	typeBool    = 9
+	typeFloat16 = 10
+	typeDouble  = 11
+	typeUint32  = 12
+	typeUint64  = 13
+)
+
+// typeNames are the element type names reported for inputs and outputs
+var typeNames = map[int32]string{
+	typeFloat:   "float",
+	typeUint8:   "uint8",
+	typeInt8:    "int8",
+	typeUint16:  "uint16",
+	typeInt16:   "int16",
+	typeInt32:   "int32",
+	typeInt64:   "int64",
+	typeString:  "string",
+	typeBool:    "bool",
+	typeFloat16: "float16",
+	typeDouble:  "double",
+	typeUint32:  "uint32",
+	typeUint64:  "uint64",
+}
+
+// tensor is a value flowing through the graph. Numbers of every element type are held as float64, which
+// is exact for the integer labels and indexes small models use; string tensors use text. A ZipMap output
+// keeps its probabilities in data and the class of each column in labels.
+type tensor struct {
+	dtype  int32
+	shape  []int
+	data   []float64
+	text   []string
+	labels []string
+}
+
+// onnxModel is a parsed model kept in Go memory between predictions
+type onnxModel struct {
+	irVersion       int64
+	producer        string
+	producerVersion string
+	domain          string
+	modelVersion    int64
+	docString       string
+	opsets          map[string]int64
+	metadata        map[string]string
+	graph           onnxGraph
+}
+
+// onnxGraph holds the nodes in the topological order ONNX requires, and the weights by name
+type onnxGraph struct {
+	name         string
+	nodes        []*onnxNode
+	initializers map[string]*tensor
+	inputs       []valueInfo
+	outputs      []valueInfo
+}
+
+// onnxNode is an operator call. compiled caches what an operator derives from its attributes, such as
+// the trees of an ensemble, so it is built on the first prediction only.
+type onnxNode struct {
+	name     string
+	op       string
+	domain   string
+	inputs   []string
+	outputs  []string
+	attrs    map[string]*attribute
+	compiled interface{}
+}
+
+// attribute is a node attribute; only the fields of its type are set
+type attribute struct {
+	f       float64
+	i       int64
+	s       string
+	t       *tensor
+	floats  []float64
+	ints    []int64
+	strings []string
+}
+
+// valueInfo describes a graph input or output. Unknown dimensions are -1, named ones keep their name.
+type valueInfo struct {
+	name     string
+	kind     string
+	elemType int32
+	shape    []int64
+	params   []string
+	hasShape bool
+}
+
+// predictOptions select the outputs returned by predict
+type predictOptions struct {
+	Outputs []string `json:"outputs"`
+}
+
+// models holds the loaded models by the ID returned by loadModel
+var models = map[string]*onnxModel{}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%d coefficients do not match %d features":                            "%d coefficients ne correspondent pas à %d variables",
+	"%s requires at least %d arguments (%s)":                              "%s nécessite au moins %d arguments (%s)",
+	"%s requires at least 1 argument (%s)":                                "%s nécessite au moins 1 argument (%s)",
+	"ArrayFeatureExtractor expects at least 1 dimension":                  "ArrayFeatureExtractor attend au moins 1 dimension",
+	"BatchNormalization expects %d channels, got %d":                      "BatchNormalization attend %d canaux, reçu %d",
+	"BatchNormalization expects at least 2 dimensions, got %v":            "BatchNormalization attend au moins 2 dimensions, reçu %v",
+	"Constant has no value":                                               "Constant n'a pas de valeur",
+	"Failed to load model: %v":                                            "Échec du chargement du modèle : %v",
+	"Gemm expects 2D inputs, got %v and %v":                               "Gemm attend des entrées 2D, reçu %v et %v",
+	"Inference failed: %v":                                                "Échec de l'inférence : %v",
+	"Invalid features: %v":                                                "Variables invalides : %v",
+	"Invalid model data: %v":                                              "Données de modèle invalides : %v",
+	"Invalid options: %v":                                                 "Options invalides : %v",
+	"MatMul shapes %v and %v do not match":                                "Les formes %v et %v de MatMul ne correspondent pas",
+	"Unknown model %q (it may already be unloaded)":                       "Modèle inconnu %q (il a peut-être déjà été déchargé)",
+	"Unsupported locale %q (available: %s)":                               "Langue non prise en charge %q (disponibles : %s)",
+	"ZipMap has %d labels for %d columns":                                 "ZipMap a %d étiquettes pour %d colonnes",
+	"axis %d is out of range for rank %d":                                 "l'axe %d est hors limites pour le rang %d",
+	"cannot concatenate shapes %v and %v":                                 "impossible de concaténer les formes %v et %v",
+	"cannot infer the shape %s from %d values, pass the features as rows": "impossible de déduire la forme %s à partir de %d valeurs, passez les variables par lignes",
+	"cannot reshape %v to %v":                                             "impossible de redimensionner %v en %v",
+	"cannot squeeze dimension %d of size %d":                              "impossible de supprimer la dimension %d de taille %d",
+	"expected %d inputs, got %d":                                          "%d entrées attendues, reçu %d",
+	"expected %s values, got %d":                                          "valeurs %s attendues, reçu %d",
+	"expected a Uint8Array, ArrayBuffer or base64 string":                 "un Uint8Array, un ArrayBuffer ou une chaîne base64 est attendu",
+	"feature %d is not a number":                                          "la variable %d n'est pas un nombre",
+	"features must be a Float64Array, another typed array, an array of numbers or an array of rows": "les variables doivent être un Float64Array, un autre tableau typé, un tableau de nombres ou un tableau de lignes",
+	"index %d is out of range for dimension %d":                                                     "l'index %d est hors limites pour la dimension %d",
+	"input %d is missing":                                                   "l'entrée %d est manquante",
+	"missing input %q":                                                      "entrée %q manquante",
+	"model data is truncated or not an ONNX file":                           "les données du modèle sont tronquées ou ne sont pas un fichier ONNX",
+	"not an ONNX model":                                                     "ce n'est pas un modèle ONNX",
+	"output %q was not computed":                                            "la sortie %q n'a pas été calculée",
+	"perm %v does not match rank %d":                                        "perm %v ne correspond pas au rang %d",
+	"row %d has %d values, expected %d":                                     "la ligne %d a %d valeurs, %d attendues",
+	"setLocale requires exactly 1 argument (locale)":                        "setLocale nécessite exactement 1 argument (locale)",
+	"shapes %v and %v cannot be broadcast":                                  "les formes %v et %v ne peuvent pas être diffusées",
+	"tensor %q has %d values for the shape %v":                              "le tenseur %q a %d valeurs pour la forme %v",
+	"tensor %q stores its data in an external file, which is not supported": "le tenseur %q stocke ses données dans un fichier externe, ce qui n'est pas pris en charge",
+	"the model has %d inputs, pass an object keyed by %s":                   "le modèle a %d entrées, passez un objet avec les clés %s",
+	"the model has no inputs":                                               "le modèle n'a pas d'entrées",
+	"the model has no outputs":                                              "le modèle n'a pas de sorties",
+	"the tree node attributes have different lengths":                       "les attributs des nœuds d'arbre ont des longueurs différentes",
+	"the trees contain a cycle":                                             "les arbres contiennent un cycle",
+	"the trees use feature %d of %d":                                        "les arbres utilisent la variable %d sur %d",
+	"tree %d node %d points to a missing node":                              "l'arbre %d nœud %d pointe vers un nœud manquant",
+	"tree weights refer to target %d of %d":                                 "les poids des arbres désignent la cible %d sur %d",
+	"unknown output %q":                                                     "sortie inconnue %q",
+	"unsupported norm %q":                                                   "norme non prise en charge %q",
+	"unsupported operators: %s":                                             "opérateurs non pris en charge : %s",
+	"unsupported post_transform %q":                                         "post_transform non pris en charge %q",
+	"unsupported tensor element type %d":                                    "type d'élément de tenseur non pris en charge %d",
+	"unsupported tree node mode %q":                                         "mode de nœud d'arbre non pris en charge %q",
+	"value %q is not defined":                                               "la valeur %q n'est pas définie",
+}
+
+// loadModel - Parse an ONNX model once and keep it for predictions. Returns the modelId the other
+// functions take, with the inputs and outputs the model declares.
+func loadModel(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "loadModel", "model"),
+		})
+	}
+
+	data, err := bytesFromJS(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid model data: %v", err),
+		})
+	}
+
+	model, err := parseModel(data)
+	if err == errMalformed {
+		err = errors.New(localize(errMalformed.Error()))
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to load model: %v", err),
+		})
+	}
+
+	id := newID()
+	models[id] = model
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Loaded model %s (%d nodes, %d parameters)\n", id, len(model.graph.nodes), model.parameters())
+	}
+
+	info := model.info()
+	info["modelId"] = id
+	return js.ValueOf(info)
+}
+
+// predict - Run a model on features. A Float64Array (or any typed array or array of numbers) feeds the
+// only input, an array of rows feeds a batch, and an object feeds inputs by name. Each output is returned
+// by name as {data, shape, type}; ZipMap outputs are arrays of {label: probability} objects.
+func predict(this js.Value, args []js.Value) interface{} {
+	model, _, failure := modelArgument(args, "predict", 2, "modelId, features")
+	if failure != nil {
+		return failure
+	}
+
+	var options predictOptions
+	if err := decodeOptions(optionalValue(args, 2), &options); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	inputs, rows, err := model.readInputs(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid features: %v", err),
+		})
+	}
+
+	wanted, err := model.outputNames(options.Outputs)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	started := time.Now()
+	values, err := model.run(inputs)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Inference failed: %v", err),
+		})
+	}
+
+	outputs := map[string]interface{}{}
+	for _, name := range wanted {
+		value, ok := values[name]
+		if !ok {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Inference failed: %v", localize("output %q was not computed", name)),
+			})
+		}
+		outputs[name] = value.jsValue()
+	}
+	elapsed := float64(time.Since(started)) / float64(time.Millisecond)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Predicted %d rows in %.2fms\n", rows, elapsed)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"outputs": outputs,
+		"rows":    rows,
+		"elapsed": math.Round(elapsed*100) / 100,
+	})
+}
+
+// getModelInfo - Describe a loaded model: producer, opsets, inputs, outputs, operators and parameter count
+func getModelInfo(this js.Value, args []js.Value) interface{} {
+	model, id, failure := modelArgument(args, "getModelInfo", 1, "modelId")
+	if failure != nil {
+		return failure
+	}
+
+	info := model.info()
+	info["modelId"] = id
+	return js.ValueOf(info)
+}
+
+// unloadModel - Free a loaded model
+func unloadModel(this js.Value, args []js.Value) interface{} {
+	_, id, failure := modelArgument(args, "unloadModel", 1, "modelId")
+	if failure != nil {
+		return failure
+	}
+
+	delete(models, id)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Unloaded model %s\n", id)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"modelId":  id,
+		"unloaded": true,
+	})
+}
+
+// modelArgument checks the argument count and looks up the model passed first
+func modelArgument(args []js.Value, function string, count int, names string) (*onnxModel, string, interface{}) {
+	if len(args) < count {
+		message := localize("%s requires at least %d arguments (%s)", function, count, names)
+		if count == 1 {
+			message = localize("%s requires at least 1 argument (%s)", function, names)
+		}
+		return nil, "", js.ValueOf(map[string]interface{}{"error": message})
+	}
+	id := args[0].String()
+	model, ok := models[id]
+	if !ok {
+		return nil, "", js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown model %q (it may already be unloaded)", id),
+		})
+	}
+	return model, id, nil
+}
+
+// info describes the model for loadModel and getModelInfo
+func (m *onnxModel) info() map[string]interface{} {
+	opsets := map[string]interface{}{}
+	for domain, version := range m.opsets {
+		if domain == "" {
+			domain = "ai.onnx"
+		}
+		opsets[domain] = version
+	}
+
+	metadata := map[string]interface{}{}
+	for key, value := range m.metadata {
+		metadata[key] = value
+	}
+
+	seen := map[string]bool{}
+	operators := []string{}
+	for _, node := range m.graph.nodes {
+		if !seen[node.op] {
+			seen[node.op] = true
+			operators = append(operators, node.op)
+		}
+	}
+	sort.Strings(operators)
+
+	return map[string]interface{}{
+		"irVersion":       m.irVersion,
+		"producer":        m.producer,
+		"producerVersion": m.producerVersion,
+		"domain":          m.domain,
+		"modelVersion":    m.modelVersion,
+		"description":     m.docString,
+		"graph":           m.graph.name,
+		"opsets":          opsets,
+		"inputs":          valueInfos(m.graph.inputs),
+		"outputs":         valueInfos(m.graph.outputs),
+		"nodes":           len(m.graph.nodes),
+		"operators":       stringValues(operators),
+		"parameters":      m.parameters(),
+		"metadata":        metadata,
+	}
+}
+
+// parameters counts the weights of the initializers and of the attributes of ML operators
+func (m *onnxModel) parameters() int {
+	count := 0
+	for _, t := range m.graph.initializers {
+		count += len(t.data) + len(t.text)
+	}
+	for _, node := range m.graph.nodes {
+		for name, attr := range node.attrs {
+			if name == "coefficients" || name == "intercepts" || strings.HasSuffix(name, "_weights") || name == "nodes_values" {
+				count += len(attr.floats)
+			}
+		}
+	}
+	return count
+}
+
+// valueInfos describes graph inputs or outputs, with null for unknown dimensions
+func valueInfos(infos []valueInfo) []interface{} {
+	described := make([]interface{}, len(infos))
+	for i, info := range infos {
+		entry := map[string]interface{}{
+			"name": info.name,
+			"type": info.typeName(),
+		}
+		if info.hasShape {
+			shape := make([]interface{}, len(info.shape))
+			for j, dim := range info.shape {
+				switch {
+				case dim >= 0:
+					shape[j] = dim
+				case info.params[j] != "":
+					shape[j] = info.params[j]
+				}
+			}
+			entry["shape"] = shape
+		}
+		described[i] = entry
+	}
+	return described
+}
+
+// typeName names the type of a value, e.g. float or sequence<map<int64,float>>
+func (v valueInfo) typeName() string {
+	name := typeNames[v.elemType]
+	if name == "" {
+		name = "unknown"
+	}
+	if v.kind == "tensor" || v.kind == "" {
+		return name
+	}
+	return v.kind
+}
+
+// readInputs converts the features argument to the model inputs and returns the batch size
+func (m *onnxModel) readInputs(value js.Value) (map[string]*tensor, int, error) {
+	if len(m.graph.inputs) == 0 {
+		return nil, 0, errors.New(localize("the model has no inputs"))
+	}
+
+	inputs := map[string]*tensor{}
+	if value.Type() == js.TypeObject && !isArrayLike(value) {
+		for _, info := range m.graph.inputs {
+			feature := value.Get(info.name)
+			if feature.Type() == js.TypeUndefined {
+				return nil, 0, errors.New(localize("missing input %q", info.name))
+			}
+			t, err := readInput(info, feature)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%s: %v", info.name, err)
+			}
+			inputs[info.name] = t
+		}
+	} else {
+		if len(m.graph.inputs) > 1 {
+			names := make([]string, len(m.graph.inputs))
+			for i, info := range m.graph.inputs {
+				names[i] = info.name
+			}
+			return nil, 0, errors.New(localize("the model has %d inputs, pass an object keyed by %s", len(names), strings.Join(names, ", ")))
+		}
+		t, err := readInput(m.graph.inputs[0], value)
+		if err != nil {
+			return nil, 0, err
+		}
+		inputs[m.graph.inputs[0].name] = t
+	}
+
+	rows := 1
+	for _, t := range inputs {
+		if len(t.shape) > 1 {
+			rows = t.shape[0]
+			break
+		}
+	}
+	return inputs, rows, nil
+}
+
+// readInput reads one input and gives it the shape the model declares. An array of rows sets the batch
+// dimension; otherwise the single unknown dimension is derived from the number of values.
+func readInput(info valueInfo, value js.Value) (*tensor, error) {
+	data, rows, err := readFeatures(value)
+	if err != nil {
+		return nil, err
+	}
+
+	dtype := info.elemType
+	if dtype == 0 || dtype == typeString {
+		dtype = typeFloat
+	}
+
+	if !info.hasShape || len(info.shape) == 0 {
+		if rows > 0 {
+			return &tensor{dtype: dtype, shape: []int{rows, len(data) / rows}, data: data}, nil
+		}
+		return &tensor{dtype: dtype, shape: []int{1, len(data)}, data: data}, nil
+	}
+
+	shape := make([]int, len(info.shape))
+	for i, dim := range info.shape {
+		shape[i] = int(dim)
+	}
+	if rows > 0 && shape[0] < 0 {
+		shape[0] = rows
+	}
+
+	known, unknown := 1, -1
+	for i, dim := range shape {
+		if dim >= 0 {
+			known *= dim
+			continue
+		}
+		if unknown >= 0 {
+			return nil, errors.New(localize("cannot infer the shape %s from %d values, pass the features as rows", describeShape(info), len(data)))
+		}
+		unknown = i
+	}
+	if unknown >= 0 && known > 0 && len(data)%known == 0 {
+		shape[unknown] = len(data) / known
+		known = len(data)
+	}
+	if known != len(data) {
+		return nil, errors.New(localize("expected %s values, got %d", describeShape(info), len(data)))
+	}
+	return &tensor{dtype: dtype, shape: shape, data: data}, nil
+}
+
+// describeShape writes a declared shape such as [N, 4]
+func describeShape(info valueInfo) string {
+	dims := make([]string, len(info.shape))
+	for i, dim := range info.shape {
+		switch {
+		case dim >= 0:
+			dims[i] = strconv.FormatInt(dim, 10)
+		case info.params[i] != "":
+			dims[i] = info.params[i]
+		default:
+			dims[i] = "?"
+		}
+	}
+	return "[" + strings.Join(dims, ", ") + "]"
+}
+
+// readFeatures reads a typed array or an array of numbers, or an array of rows of the same length.
+// rows is 0 for flat values.
+func readFeatures(value js.Value) ([]float64, int, error) {
+	if !isArrayLike(value) {
+		return nil, 0, errors.New(localize("features must be a Float64Array, another typed array, an array of numbers or an array of rows"))
+	}
+	if isTypedArray(value) {
+		data, err := readColumn(value)
+		return data, 0, err
+	}
+
+	length := value.Length()
+	if length > 0 && isArrayLike(value.Index(0)) {
+		var data []float64
+		width := -1
+		for i := 0; i < length; i++ {
+			row, err := readColumn(value.Index(i))
+			if err != nil {
+				return nil, 0, err
+			}
+			if width >= 0 && len(row) != width {
+				return nil, 0, errors.New(localize("row %d has %d values, expected %d", i, len(row), width))
+			}
+			width = len(row)
+			data = append(data, row...)
+		}
+		return data, length, nil
+	}
+
+	data, err := readColumn(value)
+	return data, 0, err
+}
+
+// readColumn converts a typed array or an array of numbers into a Go slice; null is read as NaN, the
+// missing value of tree ensembles and imputers
+func readColumn(value js.Value) ([]float64, error) {
+	if !isArrayLike(value) {
+		return nil, errors.New(localize("features must be a Float64Array, another typed array, an array of numbers or an array of rows"))
+	}
+
+	if isTypedArray(value) {
+		if !value.InstanceOf(js.Global().Get("Float64Array")) {
+			value = js.Global().Get("Float64Array").Call("from", value)
+		}
+		raw := make([]byte, value.Get("byteLength").Int())
+		view := js.Global().Get("Uint8Array").New(value.Get("buffer"), value.Get("byteOffset"), value.Get("byteLength"))
+		js.CopyBytesToGo(raw, view)
+
+		column := make([]float64, len(raw)/8)
+		for i := range column {
+			column[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw[i*8:]))
+		}
+		return column, nil
+	}
+
+	length := value.Length()
+	column := make([]float64, length)
+	for i := 0; i < length; i++ {
+		item := value.Index(i)
+		switch item.Type() {
+		case js.TypeNumber:
+			column[i] = item.Float()
+		case js.TypeBoolean:
+			if item.Bool() {
+				column[i] = 1
+			}
+		case js.TypeNull, js.TypeUndefined:
+			column[i] = math.NaN()
+		default:
+			return nil, errors.New(localize("feature %d is not a number", i))
+		}
+	}
+	return column, nil
+}
+
+// outputNames resolves the outputs option, every graph output by default
+func (m *onnxModel) outputNames(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		names := make([]string, len(m.graph.outputs))
+		for i, info := range m.graph.outputs {
+			names[i] = info.name
+		}
+		return names, nil
+	}
+
+	for _, name := range requested {
+		found := false
+		for _, info := range m.graph.outputs {
+			if info.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New(localize("unknown output %q", name))
+		}
+	}
+	return requested, nil
+}
+
+// run evaluates the nodes in order. Initializers and inputs are never written to: every operator
+// allocates its outputs.
+func (m *onnxModel) run(inputs map[string]*tensor) (map[string]*tensor, error) {
+	values := make(map[string]*tensor, len(m.graph.initializers)+len(inputs)+len(m.graph.nodes))
+	for name, t := range m.graph.initializers {
+		values[name] = t
+	}
+	for name, t := range inputs {
+		values[name] = t
+	}
+
+	opset := m.opsets[""]
+	if opset == 0 {
+		opset = m.opsets["ai.onnx"]
+	}
+
+	for _, node := range m.graph.nodes {
+		in := make([]*tensor, len(node.inputs))
+		for i, name := range node.inputs {
+			if name == "" {
+				continue
+			}
+			t, ok := values[name]
+			if !ok {
+				return nil, node.errorf(localize("value %q is not defined", name))
+			}
+			in[i] = t
+		}
+
+		out, err := operators[node.op](node, in, opset)
+		if err != nil {
+			return nil, node.errorf(err.Error())
+		}
+		for i, name := range node.outputs {
+			if name != "" && i < len(out) && out[i] != nil {
+				values[name] = out[i]
+			}
+		}
+	}
+	return values, nil
+}
+
+// errorf prefixes an error with the node it happened in
+func (n *onnxNode) errorf(message string) error {
+	name := n.name
+	if name == "" && len(n.outputs) > 0 {
+		name = n.outputs[0]
+	}
+	return fmt.Errorf("%s %q: %s", n.op, name, message)
+}
+
+// jsValue converts an output for JavaScript
+func (t *tensor) jsValue() interface{} {
+	if t.labels != nil {
+		rows := []interface{}{}
+		width := len(t.labels)
+		for r := 0; width > 0 && r < len(t.data)/width; r++ {
+			row := map[string]interface{}{}
+			for c, label := range t.labels {
+				row[label] = t.data[r*width+c]
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	shape := make([]interface{}, len(t.shape))
+	for i, dim := range t.shape {
+		shape[i] = dim
+	}
+	if t.dtype == typeString {
+		return map[string]interface{}{
+			"data":  stringValues(t.text),
+			"shape": shape,
+			"type":  "string",
+		}
+	}
+	return map[string]interface{}{
+		"data":  newFloat64Array(t.data),
+		"shape": shape,
+		"type":  typeNames[t.dtype],
+	}
+}
+
+// Protocol buffers
+// ONNX files are protobuf messages. The reader below walks fields by number, which is all the
+// few ONNX messages need, without generated code.
+
+// errMalformed reports data that is not a valid protobuf message, localized by loadModel
+var errMalformed = errors.New("model data is truncated or not an ONNX file")
+
+// protoReader reads the fields of one message
+type protoReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *protoReader) done() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if r.pos >= len(r.data) {
+			return 0, errMalformed
+		}
+		b := r.data[r.pos]
+		r.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errMalformed
+}
+
+// field reads the key of the next field: its number and wire type
+func (r *protoReader) field() (int, int, error) {
+	key, err := r.varint()
+	return int(key >> 3), int(key & 7), err
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errMalformed
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *protoReader) fixed32() (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, errMalformed
+	}
+	v := binary.LittleEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+func (r *protoReader) fixed64() (uint64, error) {
+	if len(r.data)-r.pos < 8 {
+		return 0, errMalformed
+	}
+	v := binary.LittleEndian.Uint64(r.data[r.pos:])
+	r.pos += 8
+	return v, nil
+}
+
+func (r *protoReader) text() (string, error) {
+	b, err := r.bytes()
+	return string(b), err
+}
+
+// skip passes over a field the reader does not use
+func (r *protoReader) skip(wire int) error {
+	var err error
+	switch wire {
+	case 0:
+		_, err = r.varint()
+	case 1:
+		_, err = r.fixed64()
+	case 2:
+		_, err = r.bytes()
+	case 5:
+		_, err = r.fixed32()
+	default:
+		err = errMalformed
+	}
+	return err
+}
+
+// floats32 reads a repeated float field, packed or not
+func (r *protoReader) floats32(wire int, dst []float64) ([]float64, error) {
+	if wire != 2 {
+		v, err := r.fixed32()
+		return append(dst, float64(math.Float32frombits(v))), err
+	}
+	b, err := r.bytes()
+	for ; len(b) >= 4; b = b[4:] {
+		dst = append(dst, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+	}
+	return dst, err
+}
+
+// floats64 reads a repeated double field, packed or not
+func (r *protoReader) floats64(wire int, dst []float64) ([]float64, error) {
+	if wire != 2 {
+		v, err := r.fixed64()
+		return append(dst, math.Float64frombits(v)), err
+	}
+	b, err := r.bytes()
+	for ; len(b) >= 8; b = b[8:] {
+		dst = append(dst, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	}
+	return dst, err
+}
+
+// int64s reads a repeated varint field, packed or not. Negative int32 values are sign extended on the wire.
+func (r *protoReader) int64s(wire int, dst []int64) ([]int64, error) {
+	if wire != 2 {
+		v, err := r.varint()
+		return append(dst, int64(v)), err
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return dst, err
+	}
+	packed := protoReader{data: b}
+	for !packed.done() {
+		v, err := packed.varint()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, int64(v))
+	}
+	return dst, nil
+}
+
+// parseModel reads a ModelProto and checks that every operator is supported
+func parseModel(data []byte) (*onnxModel, error) {
+	model := &onnxModel{opsets: map[string]int64{}, metadata: map[string]string{}}
+	hasGraph := false
+
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case number == 1 && wire == 0:
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			model.irVersion = int64(v)
+		case number == 2 && wire == 2:
+			model.producer, err = r.text()
+		case number == 3 && wire == 2:
+			model.producerVersion, err = r.text()
+		case number == 4 && wire == 2:
+			model.domain, err = r.text()
+		case number == 5 && wire == 0:
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			model.modelVersion = int64(v)
+		case number == 6 && wire == 2:
+			model.docString, err = r.text()
+		case number == 7 && wire == 2:
+			b, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			if err := parseGraph(b, &model.graph); err != nil {
+				return nil, err
+			}
+			hasGraph = true
+		case number == 8 && wire == 2:
+			b, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			domain, version, err := parseOpset(b)
+			if err != nil {
+				return nil, err
+			}
+			model.opsets[domain] = version
+		case number == 14 && wire == 2:
+			b, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			key, value, err := parseEntry(b)
+			if err != nil {
+				return nil, err
+			}
+			model.metadata[key] = value
+		default:
+			err = r.skip(wire)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !hasGraph || model.irVersion == 0 {
+		return nil, errors.New(localize("not an ONNX model"))
+	}
+	if len(model.graph.outputs) == 0 {
+		return nil, errors.New(localize("the model has no outputs"))
+	}
+
+	unsupported := []string{}
+	for _, node := range model.graph.nodes {
+		_, known := operators[node.op]
+		if known && (node.domain == "" || node.domain == "ai.onnx" || node.domain == "ai.onnx.ml") {
+			continue
+		}
+		name := node.op
+		if node.domain != "" {
+			name = node.domain + "." + node.op
+		}
+		unsupported = append(unsupported, name)
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return nil, errors.New(localize("unsupported operators: %s", strings.Join(uniqueStrings(unsupported), ", ")))
+	}
+
+	// Before IR version 4 the weights were listed as graph inputs too
+	inputs := model.graph.inputs[:0]
+	for _, info := range model.graph.inputs {
+		if _, weight := model.graph.initializers[info.name]; !weight {
+			inputs = append(inputs, info)
+		}
+	}
+	model.graph.inputs = inputs
+
+	return model, nil
+}
+
+// parseGraph reads a GraphProto
+func parseGraph(data []byte, graph *onnxGraph) error {
+	graph.initializers = map[string]*tensor{}
+
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return err
+		}
+		if wire != 2 {
+			if err := r.skip(wire); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+		switch number {
+		case 1:
+			node, err := parseNode(b)
+			if err != nil {
+				return err
+			}
+			graph.nodes = append(graph.nodes, node)
+		case 2:
+			graph.name = string(b)
+		case 5:
+			name, t, err := parseTensor(b)
+			if err != nil {
+				return err
+			}
+			graph.initializers[name] = t
+		case 11, 12:
+			info, err := parseValueInfo(b)
+			if err != nil {
+				return err
+			}
+			if number == 11 {
+				graph.inputs = append(graph.inputs, info)
+			} else {
+				graph.outputs = append(graph.outputs, info)
+			}
+		}
+	}
+	return nil
+}
+
+// parseNode reads a NodeProto
+func parseNode(data []byte) (*onnxNode, error) {
+	node := &onnxNode{attrs: map[string]*attribute{}}
+
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return nil, err
+		}
+		if wire != 2 {
+			if err := r.skip(wire); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		switch number {
+		case 1:
+			node.inputs = append(node.inputs, string(b))
+		case 2:
+			node.outputs = append(node.outputs, string(b))
+		case 3:
+			node.name = string(b)
+		case 4:
+			node.op = string(b)
+		case 5:
+			name, attr, err := parseAttribute(b)
+			if err != nil {
+				return nil, err
+			}
+			node.attrs[name] = attr
+		case 7:
+			node.domain = string(b)
+		}
+	}
+	return node, nil
+}
+
+// parseAttribute reads an AttributeProto
+func parseAttribute(data []byte) (string, *attribute, error) {
+	name := ""
+	attr := &attribute{}
+
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return "", nil, err
+		}
+		switch {
+		case number == 1 && wire == 2:
+			name, err = r.text()
+		case number == 2 && wire == 5:
+			v, e := r.fixed32()
+			attr.f, err = float64(math.Float32frombits(v)), e
+		case number == 3 && wire == 0:
+			v, e := r.varint()
+			attr.i, err = int64(v), e
+		case number == 4 && wire == 2:
+			attr.s, err = r.text()
+		case number == 5 && wire == 2:
+			b, e := r.bytes()
+			if e != nil {
+				return "", nil, e
+			}
+			_, attr.t, err = parseTensor(b)
+		case number == 7:
+			attr.floats, err = r.floats32(wire, attr.floats)
+		case number == 8:
+			attr.ints, err = r.int64s(wire, attr.ints)
+		case number == 9 && wire == 2:
+			var s string
+			s, err = r.text()
+			attr.strings = append(attr.strings, s)
+		default:
+			err = r.skip(wire)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return name, attr, nil
+}
+
+// parseTensor reads a TensorProto, from its typed fields or its little-endian raw_data
+func parseTensor(data []byte) (string, *tensor, error) {
+	name := ""
+	t := &tensor{dtype: typeFloat}
+	var dims, ints []int64
+	var raw []byte
+
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return "", nil, err
+		}
+		switch number {
+		case 1:
+			dims, err = r.int64s(wire, dims)
+		case 2:
+			v, e := r.varint()
+			t.dtype, err = int32(v), e
+		case 4:
+			t.data, err = r.floats32(wire, t.data)
+		case 5, 7, 11:
+			ints, err = r.int64s(wire, ints)
+		case 6:
+			var s string
+			s, err = r.text()
+			t.text = append(t.text, s)
+		case 8:
+			name, err = r.text()
+		case 9:
+			raw, err = r.bytes()
+		case 10:
+			t.data, err = r.floats64(wire, t.data)
+		case 14:
+			v, e := r.varint()
+			if e == nil && v == 1 {
+				return "", nil, errors.New(localize("tensor %q stores its data in an external file, which is not supported", name))
+			}
+			err = e
+		default:
+			err = r.skip(wire)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	t.shape = make([]int, len(dims))
+	for i, dim := range dims {
+		t.shape[i] = int(dim)
+	}
+
+	switch {
+	case t.dtype == typeString:
+	case raw != nil:
+		values, err := decodeRaw(raw, t.dtype)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %v", name, err)
+		}
+		t.data = values
+	case len(ints) > 0:
+		t.data = make([]float64, len(ints))
+		for i, v := range ints {
+			switch t.dtype {
+			case typeFloat16:
+				t.data[i] = halfFloat(uint16(v))
+			case typeUint64:
+				t.data[i] = float64(uint64(v))
+			default:
+				t.data[i] = float64(v)
+			}
+		}
+	}
+
+	size := shapeSize(t.shape)
+	if count := len(t.data) + len(t.text); count != size {
+		return "", nil, errors.New(localize("tensor %q has %d values for the shape %v", name, count, t.shape))
+	}
+	return name, t, nil
+}
+
+// decodeRaw converts the raw_data of a tensor
+func decodeRaw(raw []byte, dtype int32) ([]float64, error) {
+	widths := map[int32]int{
+		typeFloat: 4, typeUint8: 1, typeInt8: 1, typeUint16: 2, typeInt16: 2, typeInt32: 4, typeInt64: 8,
+		typeBool: 1, typeFloat16: 2, typeDouble: 8, typeUint32: 4, typeUint64: 8,
+	}
+	width, ok := widths[dtype]
+	if !ok {
+		return nil, errors.New(localize("unsupported tensor element type %d", dtype))
+	}
+
+	values := make([]float64, len(raw)/width)
+	for i := range values {
+		b := raw[i*width:]
+		switch dtype {
+		case typeFloat:
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case typeDouble:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case typeUint8, typeBool:
+			values[i] = float64(b[0])
+		case typeInt8:
+			values[i] = float64(int8(b[0]))
+		case typeUint16:
+			values[i] = float64(binary.LittleEndian.Uint16(b))
+		case typeInt16:
+			values[i] = float64(int16(binary.LittleEndian.Uint16(b)))
+		case typeFloat16:
+			values[i] = halfFloat(binary.LittleEndian.Uint16(b))
+		case typeInt32:
+			values[i] = float64(int32(binary.LittleEndian.Uint32(b)))
+		case typeUint32:
+			values[i] = float64(binary.LittleEndian.Uint32(b))
+		case typeInt64:
+			values[i] = float64(int64(binary.LittleEndian.Uint64(b)))
+		case typeUint64:
+			values[i] = float64(binary.LittleEndian.Uint64(b))
+		}
+	}
+	return values, nil
+}
+
+// halfFloat converts IEEE 754 half precision bits
+func halfFloat(bits uint16) float64 {
+	sign := 1.0
+	if bits&0x8000 != 0 {
+		sign = -1
+	}
+	exponent := int(bits>>10) & 0x1f
+	fraction := float64(bits & 0x3ff)
+	switch exponent {
+	case 0:
+		return sign * math.Ldexp(fraction, -24)
+	case 0x1f:
+		if fraction != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(1024+fraction, exponent-25)
+}
+
+// parseValueInfo reads a ValueInfoProto and its TypeProto
+func parseValueInfo(data []byte) (valueInfo, error) {
+	info := valueInfo{}
+
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return info, err
+		}
+		if wire != 2 {
+			if err := r.skip(wire); err != nil {
+				return info, err
+			}
+			continue
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return info, err
+		}
+		switch number {
+		case 1:
+			info.name = string(b)
+		case 2:
+			if err := parseType(b, &info); err != nil {
+				return info, err
+			}
+		}
+	}
+	return info, nil
+}
+
+// parseType reads a TypeProto: tensors keep their element type and shape, sequences and maps are named
+func parseType(data []byte, info *valueInfo) error {
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return err
+		}
+		if wire != 2 {
+			if err := r.skip(wire); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+		switch number {
+		case 1:
+			info.kind = "tensor"
+			if err := parseTensorType(b, info); err != nil {
+				return err
+			}
+		case 4:
+			var element valueInfo
+			if err := parseSequenceType(b, &element); err != nil {
+				return err
+			}
+			info.kind = "sequence<" + element.typeName() + ">"
+		case 5:
+			info.kind, err = parseMapType(b)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseTensorType reads TypeProto.Tensor: elem_type and shape
+func parseTensorType(data []byte, info *valueInfo) error {
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return err
+		}
+		switch {
+		case number == 1 && wire == 0:
+			v, err := r.varint()
+			if err != nil {
+				return err
+			}
+			info.elemType = int32(v)
+		case number == 2 && wire == 2:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			info.hasShape = true
+			if err := parseShape(b, info); err != nil {
+				return err
+			}
+		default:
+			if err := r.skip(wire); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseShape reads a TensorShapeProto. Each Dimension has a dim_value, a dim_param or neither.
+func parseShape(data []byte, info *valueInfo) error {
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return err
+		}
+		if number != 1 || wire != 2 {
+			if err := r.skip(wire); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+
+		value, param := int64(-1), ""
+		dim := protoReader{data: b}
+		for !dim.done() {
+			number, wire, err := dim.field()
+			if err != nil {
+				return err
+			}
+			switch {
+			case number == 1 && wire == 0:
+				v, err := dim.varint()
+				if err != nil {
+					return err
+				}
+				value = int64(v)
+			case number == 2 && wire == 2:
+				if param, err = dim.text(); err != nil {
+					return err
+				}
+			default:
+				if err := dim.skip(wire); err != nil {
+					return err
+				}
+			}
+		}
+		info.shape = append(info.shape, value)
+		info.params = append(info.params, param)
+	}
+	return nil
+}
+
+// parseSequenceType reads TypeProto.Sequence, whose elem_type is itself a TypeProto
+func parseSequenceType(data []byte, element *valueInfo) error {
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return err
+		}
+		if number == 1 && wire == 2 {
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			if err := parseType(b, element); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := r.skip(wire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseMapType names a TypeProto.Map, e.g. map<int64,float>
+func parseMapType(data []byte) (string, error) {
+	key, value := "unknown", valueInfo{}
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case number == 1 && wire == 0:
+			v, err := r.varint()
+			if err != nil {
+				return "", err
+			}
+			if name, ok := typeNames[int32(v)]; ok {
+				key = name
+			}
+		case number == 2 && wire == 2:
+			b, err := r.bytes()
+			if err != nil {
+				return "", err
+			}
+			if err := parseType(b, &value); err != nil {
+				return "", err
+			}
+		default:
+			if err := r.skip(wire); err != nil {
+				return "", err
+			}
+		}
+	}
+	return "map<" + key + "," + value.typeName() + ">", nil
+}
+
+// parseOpset reads an OperatorSetIdProto
+func parseOpset(data []byte) (string, int64, error) {
+	domain, version := "", int64(0)
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return "", 0, err
+		}
+		switch {
+		case number == 1 && wire == 2:
+			domain, err = r.text()
+		case number == 2 && wire == 0:
+			v, e := r.varint()
+			version, err = int64(v), e
+		default:
+			err = r.skip(wire)
+		}
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	return domain, version, nil
+}
+
+// parseEntry reads a StringStringEntryProto of the model metadata
+func parseEntry(data []byte) (string, string, error) {
+	key, value := "", ""
+	r := protoReader{data: data}
+	for !r.done() {
+		number, wire, err := r.field()
+		if err != nil {
+			return "", "", err
+		}
+		switch {
+		case number == 1 && wire == 2:
+			key, err = r.text()
+		case number == 2 && wire == 2:
+			value, err = r.text()
+		default:
+			err = r.skip(wire)
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return key, value, nil
+}
+
+// Operators
+// Each operator takes the node, its inputs (nil for omitted optional inputs) and the opset of the
+// default domain, and returns new tensors for its outputs.
+
+// operatorFunc implements one ONNX operator
+type operatorFunc func(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error)
+
+// operators maps op types to their implementation. The ai.onnx.ml operators share the map, their
+// names do not collide with the default domain.
+var operators map[string]operatorFunc
+
+func init() {
+	operators = map[string]operatorFunc{
+		// Element-wise arithmetic, with numpy broadcasting
+		"Add":  binaryOperator(func(x, y float64) float64 { return x + y }),
+		"Sub":  binaryOperator(func(x, y float64) float64 { return x - y }),
+		"Mul":  binaryOperator(func(x, y float64) float64 { return x * y }),
+		"Div":  divOperator,
+		"Pow":  binaryOperator(math.Pow),
+		"Max":  variadicOperator(math.Max),
+		"Min":  variadicOperator(math.Min),
+		"Sum":  variadicOperator(func(x, y float64) float64 { return x + y }),
+		"Mean": variadicOperator(func(x, y float64) float64 { return x + y }),
+
+		// Element-wise functions and activations
+		"Abs":         unaryOperator(math.Abs),
+		"Neg":         unaryOperator(func(x float64) float64 { return -x }),
+		"Exp":         unaryOperator(math.Exp),
+		"Log":         unaryOperator(math.Log),
+		"Sqrt":        unaryOperator(math.Sqrt),
+		"Reciprocal":  unaryOperator(func(x float64) float64 { return 1 / x }),
+		"Floor":       unaryOperator(math.Floor),
+		"Ceil":        unaryOperator(math.Ceil),
+		"Round":       unaryOperator(math.RoundToEven),
+		"Sign":        unaryOperator(sign),
+		"Erf":         unaryOperator(math.Erf),
+		"Relu":        unaryOperator(func(x float64) float64 { return math.Max(x, 0) }),
+		"Sigmoid":     unaryOperator(sigmoid),
+		"Tanh":        unaryOperator(math.Tanh),
+		"Softplus":    unaryOperator(func(x float64) float64 { return math.Log1p(math.Exp(x)) }),
+		"LeakyRelu":   leakyReluOperator,
+		"Elu":         eluOperator,
+		"Selu":        seluOperator,
+		"HardSigmoid": hardSigmoidOperator,
+		"Clip":        clipOperator,
+		"Softmax":     softmaxOperator(false),
+		"LogSoftmax":  softmaxOperator(true),
+
+		// Linear algebra and normalization
+		"MatMul":             matMulOperator,
+		"Gemm":               gemmOperator,
+		"BatchNormalization": batchNormOperator,
+
+		// Shapes and indexing
+		"Identity":  identityOperator,
+		"Dropout":   identityOperator,
+		"Cast":      castOperator,
+		"Constant":  constantOperator,
+		"Shape":     shapeOperator,
+		"Reshape":   reshapeOperator,
+		"Flatten":   flattenOperator,
+		"Squeeze":   squeezeOperator,
+		"Unsqueeze": unsqueezeOperator,
+		"Transpose": transposeOperator,
+		"Concat":    concatOperator,
+		"Gather":    gatherOperator,
+
+		// Reductions
+		"ArgMax":     argOperator(false),
+		"ArgMin":     argOperator(true),
+		"ReduceSum":  reduceOperator("sum"),
+		"ReduceMean": reduceOperator("mean"),
+		"ReduceMax":  reduceOperator("max"),
+		"ReduceMin":  reduceOperator("min"),
+
+		// ai.onnx.ml: the operators scikit-learn converters emit
+		"LinearRegressor":        linearRegressorOperator,
+		"LinearClassifier":       linearClassifierOperator,
+		"TreeEnsembleRegressor":  treeEnsembleOperator(false),
+		"TreeEnsembleClassifier": treeEnsembleOperator(true),
+		"Normalizer":             normalizerOperator,
+		"Scaler":                 scalerOperator,
+		"Imputer":                imputerOperator,
+		"Binarizer":              binarizerOperator,
+		"ArrayFeatureExtractor":  arrayFeatureExtractorOperator,
+		"ZipMap":                 zipMapOperator,
+	}
+}
+
+// Attribute accessors, returning the default when the attribute is absent
+
+func (n *onnxNode) attrFloat(name string, fallback float64) float64 {
+	if a, ok := n.attrs[name]; ok {
+		return a.f
+	}
+	return fallback
+}
+
+func (n *onnxNode) attrInt(name string, fallback int64) int64 {
+	if a, ok := n.attrs[name]; ok {
+		return a.i
+	}
+	return fallback
+}
+
+func (n *onnxNode) attrString(name, fallback string) string {
+	if a, ok := n.attrs[name]; ok {
+		return a.s
+	}
+	return fallback
+}
+
+func (n *onnxNode) attrFloats(name string) []float64 {
+	if a, ok := n.attrs[name]; ok {
+		if a.t != nil {
+			return a.t.data
+		}
+		return a.floats
+	}
+	return nil
+}
+
+func (n *onnxNode) attrInts(name string) []int64 {
+	if a, ok := n.attrs[name]; ok {
+		return a.ints
+	}
+	return nil
+}
+
+func (n *onnxNode) attrStrings(name string) []string {
+	if a, ok := n.attrs[name]; ok {
+		return a.strings
+	}
+	return nil
+}
+
+// requireInputs checks that the first count inputs are present
+func requireInputs(in []*tensor, count int) error {
+	if len(in) < count {
+		return errors.New(localize("expected %d inputs, got %d", count, len(in)))
+	}
+	for i := 0; i < count; i++ {
+		if in[i] == nil {
+			return errors.New(localize("input %d is missing", i))
+		}
+	}
+	return nil
+}
+
+// optionalInput returns input i, or nil when it was omitted
+func optionalInput(in []*tensor, i int) *tensor {
+	if i < len(in) {
+		return in[i]
+	}
+	return nil
+}
+
+func shapeSize(shape []int) int {
+	size := 1
+	for _, dim := range shape {
+		size *= dim
+	}
+	return size
+}
+
+func newTensor(dtype int32, shape []int) *tensor {
+	return &tensor{dtype: dtype, shape: shape, data: make([]float64, shapeSize(shape))}
+}
+
+// reshaped shares the values of t under another shape. Values are never modified in place, so sharing is safe.
+func (t *tensor) reshaped(shape []int) *tensor {
+	return &tensor{dtype: t.dtype, shape: shape, data: t.data, text: t.text}
+}
+
+// normalizeAxis resolves a negative axis against a rank
+func normalizeAxis(axis int64, rank int) (int, error) {
+	if axis < 0 {
+		axis += int64(rank)
+	}
+	if axis < 0 || axis >= int64(rank) {
+		return 0, errors.New(localize("axis %d is out of range for rank %d", axis, rank))
+	}
+	return int(axis), nil
+}
+
+// axisSplit returns the number of elements before, along and after an axis
+func axisSplit(shape []int, axis int) (int, int, int) {
+	return shapeSize(shape[:axis]), shape[axis], shapeSize(shape[axis+1:])
+}
+
+func isInteger(dtype int32) bool {
+	switch dtype {
+	case typeFloat, typeDouble, typeFloat16, typeString:
+		return false
+	}
+	return true
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return x
+}
+
+// broadcastShape returns the numpy broadcast of two shapes
+func broadcastShape(a, b []int) ([]int, error) {
+	rank := len(a)
+	if len(b) > rank {
+		rank = len(b)
+	}
+	shape := make([]int, rank)
+	for i := 0; i < rank; i++ {
+		da, db := 1, 1
+		if j := len(a) - rank + i; j >= 0 {
+			da = a[j]
+		}
+		if j := len(b) - rank + i; j >= 0 {
+			db = b[j]
+		}
+		switch {
+		case da == db || db == 1:
+			shape[i] = da
+		case da == 1:
+			shape[i] = db
+		default:
+			return nil, errors.New(localize("shapes %v and %v cannot be broadcast", a, b))
+		}
+	}
+	return shape, nil
+}
+
+// broadcastStrides gives the stride of each dimension of out in a tensor of shape in, 0 where in is broadcast
+func broadcastStrides(in, out []int) []int {
+	strides := make([]int, len(out))
+	stride := 1
+	for i := len(out) - 1; i >= 0; i-- {
+		j := len(in) - len(out) + i
+		if j < 0 {
+			continue
+		}
+		if in[j] != 1 {
+			strides[i] = stride
+		}
+		stride *= in[j]
+	}
+	return strides
+}
+
+// broadcastBinary applies op to a and b broadcast against each other
+func broadcastBinary(a, b *tensor, dtype int32, op func(x, y float64) float64) (*tensor, error) {
+	if len(b.data) == 1 && len(a.shape) >= len(b.shape) {
+		out := newTensor(dtype, append([]int(nil), a.shape...))
+		y := b.data[0]
+		for i, x := range a.data {
+			out.data[i] = op(x, y)
+		}
+		return out, nil
+	}
+
+	shape, err := broadcastShape(a.shape, b.shape)
+	if err != nil {
+		return nil, err
+	}
+	out := newTensor(dtype, shape)
+	if len(a.data) == len(out.data) && len(b.data) == len(out.data) {
+		for i := range out.data {
+			out.data[i] = op(a.data[i], b.data[i])
+		}
+		return out, nil
+	}
+
+	sa, sb := broadcastStrides(a.shape, shape), broadcastStrides(b.shape, shape)
+	index := make([]int, len(shape))
+	ia, ib := 0, 0
+	for k := range out.data {
+		out.data[k] = op(a.data[ia], b.data[ib])
+		for d := len(shape) - 1; d >= 0; d-- {
+			index[d]++
+			ia += sa[d]
+			ib += sb[d]
+			if index[d] < shape[d] {
+				break
+			}
+			ia -= sa[d] * shape[d]
+			ib -= sb[d] * shape[d]
+			index[d] = 0
+		}
+	}
+	return out, nil
+}
+
+func binaryOperator(op func(x, y float64) float64) operatorFunc {
+	return func(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(in, 2); err != nil {
+			return nil, err
+		}
+		out, err := broadcastBinary(in[0], in[1], in[0].dtype, op)
+		return []*tensor{out}, err
+	}
+}
+
+// divOperator divides, truncating toward zero for integer tensors
+func divOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 2); err != nil {
+		return nil, err
+	}
+	op := func(x, y float64) float64 { return x / y }
+	if isInteger(in[0].dtype) {
+		op = func(x, y float64) float64 { return math.Trunc(x / y) }
+	}
+	out, err := broadcastBinary(in[0], in[1], in[0].dtype, op)
+	return []*tensor{out}, err
+}
+
+// variadicOperator folds any number of inputs with op
+func variadicOperator(op func(x, y float64) float64) operatorFunc {
+	return func(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(in, 1); err != nil {
+			return nil, err
+		}
+		out := in[0]
+		for _, next := range in[1:] {
+			if next == nil {
+				continue
+			}
+			var err error
+			if out, err = broadcastBinary(out, next, in[0].dtype, op); err != nil {
+				return nil, err
+			}
+		}
+		if node.op == "Mean" {
+			count := float64(len(in))
+			out = out.apply(func(x float64) float64 { return x / count })
+		}
+		return []*tensor{out}, nil
+	}
+}
+
+// apply maps every value of t into a new tensor
+func (t *tensor) apply(f func(x float64) float64) *tensor {
+	out := newTensor(t.dtype, append([]int(nil), t.shape...))
+	for i, x := range t.data {
+		out.data[i] = f(x)
+	}
+	return out
+}
+
+func unaryOperator(f func(x float64) float64) operatorFunc {
+	return func(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(in, 1); err != nil {
+			return nil, err
+		}
+		return []*tensor{in[0].apply(f)}, nil
+	}
+}
+
+func leakyReluOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	alpha := node.attrFloat("alpha", 0.01)
+	return unaryOperator(func(x float64) float64 {
+		if x < 0 {
+			return alpha * x
+		}
+		return x
+	})(node, in, opset)
+}
+
+func eluOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	alpha := node.attrFloat("alpha", 1)
+	return unaryOperator(func(x float64) float64 {
+		if x < 0 {
+			return alpha * (math.Exp(x) - 1)
+		}
+		return x
+	})(node, in, opset)
+}
+
+func seluOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	alpha := node.attrFloat("alpha", 1.67326319217681884765625)
+	gamma := node.attrFloat("gamma", 1.05070102214813232421875)
+	return unaryOperator(func(x float64) float64 {
+		if x <= 0 {
+			return gamma * alpha * (math.Exp(x) - 1)
+		}
+		return gamma * x
+	})(node, in, opset)
+}
+
+func hardSigmoidOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	alpha := node.attrFloat("alpha", 0.2)
+	beta := node.attrFloat("beta", 0.5)
+	return unaryOperator(func(x float64) float64 {
+		return math.Max(0, math.Min(1, alpha*x+beta))
+	})(node, in, opset)
+}
+
+// clipOperator bounds values; the bounds are attributes before opset 11 and optional inputs after
+func clipOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	low := node.attrFloat("min", math.Inf(-1))
+	high := node.attrFloat("max", math.Inf(1))
+	if t := optionalInput(in, 1); t != nil && len(t.data) > 0 {
+		low = t.data[0]
+	}
+	if t := optionalInput(in, 2); t != nil && len(t.data) > 0 {
+		high = t.data[0]
+	}
+	return unaryOperator(func(x float64) float64 {
+		return math.Min(math.Max(x, low), high)
+	})(node, in, opset)
+}
+
+// softmaxOperator normalizes along an axis. Before opset 13 the input is flattened to 2D at the axis
+// (default 1); from opset 13 only the axis itself (default -1) is normalized.
+func softmaxOperator(logarithm bool) operatorFunc {
+	return func(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(in, 1); err != nil {
+			return nil, err
+		}
+		x := in[0]
+		fallback := int64(-1)
+		if opset < 13 {
+			fallback = 1
+		}
+		axis, err := normalizeAxis(node.attrInt("axis", fallback), len(x.shape))
+		if err != nil {
+			return nil, err
+		}
+
+		outer, dim, inner := axisSplit(x.shape, axis)
+		if opset < 13 {
+			dim, inner = dim*inner, 1
+		}
+
+		out := newTensor(x.dtype, append([]int(nil), x.shape...))
+		for o := 0; o < outer; o++ {
+			for i := 0; i < inner; i++ {
+				base := o*dim*inner + i
+				peak := math.Inf(-1)
+				for d := 0; d < dim; d++ {
+					peak = math.Max(peak, x.data[base+d*inner])
+				}
+				sum := 0.0
+				for d := 0; d < dim; d++ {
+					sum += math.Exp(x.data[base+d*inner] - peak)
+				}
+				for d := 0; d < dim; d++ {
+					v := x.data[base+d*inner] - peak
+					if logarithm {
+						out.data[base+d*inner] = v - math.Log(sum)
+					} else {
+						out.data[base+d*inner] = math.Exp(v) / sum
+					}
+				}
+			}
+		}
+		return []*tensor{out}, nil
+	}
+}
+
+// matMulOperator multiplies matrices with numpy semantics: 1D operands are promoted to matrices and
+// leading dimensions are broadcast as batches
+func matMulOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 2); err != nil {
+		return nil, err
+	}
+	a, b := in[0], in[1]
+	as, bs := a.shape, b.shape
+	vectorA, vectorB := len(as) == 1, len(bs) == 1
+	if vectorA {
+		as = []int{1, as[0]}
+	}
+	if vectorB {
+		bs = []int{bs[0], 1}
+	}
+	if len(as) < 2 || len(bs) < 2 {
+		return nil, errors.New(localize("MatMul shapes %v and %v do not match", a.shape, b.shape))
+	}
+
+	m, k := as[len(as)-2], as[len(as)-1]
+	k2, n := bs[len(bs)-2], bs[len(bs)-1]
+	if k != k2 {
+		return nil, errors.New(localize("MatMul shapes %v and %v do not match", a.shape, b.shape))
+	}
+
+	batchA, batchB := as[:len(as)-2], bs[:len(bs)-2]
+	batch, err := broadcastShape(batchA, batchB)
+	if err != nil {
+		return nil, err
+	}
+	shape := append(append([]int(nil), batch...), m, n)
+	out := newTensor(a.dtype, shape)
+
+	sa, sb := broadcastStrides(batchA, batch), broadcastStrides(batchB, batch)
+	index := make([]int, len(batch))
+	ia, ib := 0, 0
+	for c := 0; c < shapeSize(batch); c++ {
+		matMul(out.data[c*m*n:(c+1)*m*n], a.data[ia*m*k:], b.data[ib*k*n:], m, k, n)
+		for d := len(batch) - 1; d >= 0; d-- {
+			index[d]++
+			ia += sa[d]
+			ib += sb[d]
+			if index[d] < batch[d] {
+				break
+			}
+			ia -= sa[d] * batch[d]
+			ib -= sb[d] * batch[d]
+			index[d] = 0
+		}
+	}
+
+	switch {
+	case vectorA && vectorB:
+		out.shape = append(out.shape[:len(out.shape)-2], []int{}...)
+	case vectorA:
+		out.shape = append(out.shape[:len(out.shape)-2], n)
+	case vectorB:
+		out.shape = out.shape[:len(out.shape)-1]
+	}
+	return []*tensor{out}, nil
+}
+
+// matMul writes the m×n product of a (m×k) and b (k×n), all row-major
+func matMul(out, a, b []float64, m, k, n int) {
+	for i := 0; i < m; i++ {
+		row := out[i*n : (i+1)*n]
+		for p := 0; p < k; p++ {
+			x := a[i*k+p]
+			if x == 0 {
+				continue
+			}
+			for j, y := range b[p*n : (p+1)*n] {
+				row[j] += x * y
+			}
+		}
+	}
+}
+
+// gemmOperator computes alpha·A'·B' + beta·C, A' and B' optionally transposed
+func gemmOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 2); err != nil {
+		return nil, err
+	}
+	a, b := in[0], in[1]
+	if len(a.shape) != 2 || len(b.shape) != 2 {
+		return nil, errors.New(localize("Gemm expects 2D inputs, got %v and %v", a.shape, b.shape))
+	}
+	if node.attrInt("transA", 0) != 0 {
+		a = transpose2D(a)
+	}
+	if node.attrInt("transB", 0) != 0 {
+		b = transpose2D(b)
+	}
+	m, k, n := a.shape[0], a.shape[1], b.shape[1]
+	if b.shape[0] != k {
+		return nil, errors.New(localize("MatMul shapes %v and %v do not match", a.shape, b.shape))
+	}
+
+	out := newTensor(a.dtype, []int{m, n})
+	matMul(out.data, a.data, b.data, m, k, n)
+	if alpha := node.attrFloat("alpha", 1); alpha != 1 {
+		for i := range out.data {
+			out.data[i] *= alpha
+		}
+	}
+
+	if c := optionalInput(in, 2); c != nil {
+		beta := node.attrFloat("beta", 1)
+		sum, err := broadcastBinary(out, c, out.dtype, func(x, y float64) float64 { return x + beta*y })
+		if err != nil {
+			return nil, err
+		}
+		if len(sum.data) != len(out.data) {
+			return nil, errors.New(localize("shapes %v and %v cannot be broadcast", out.shape, c.shape))
+		}
+		out = sum
+	}
+	return []*tensor{out}, nil
+}
+
+// transpose2D returns the transpose of a matrix
+func transpose2D(t *tensor) *tensor {
+	rows, columns := t.shape[0], t.shape[1]
+	out := newTensor(t.dtype, []int{columns, rows})
+	for r := 0; r < rows; r++ {
+		for c := 0; c < columns; c++ {
+			out.data[c*rows+r] = t.data[r*columns+c]
+		}
+	}
+	return out
+}
+
+// batchNormOperator applies inference batch normalization over the channel axis 1
+func batchNormOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 5); err != nil {
+		return nil, err
+	}
+	x, scale, bias, mean, variance := in[0], in[1], in[2], in[3], in[4]
+	if len(x.shape) < 2 {
+		return nil, errors.New(localize("BatchNormalization expects at least 2 dimensions, got %v", x.shape))
+	}
+	epsilon := node.attrFloat("epsilon", 1e-5)
+
+	outer, channels, inner := axisSplit(x.shape, 1)
+	for _, t := range []*tensor{scale, bias, mean, variance} {
+		if len(t.data) != channels {
+			return nil, errors.New(localize("BatchNormalization expects %d channels, got %d", channels, len(t.data)))
+		}
+	}
+
+	out := newTensor(x.dtype, append([]int(nil), x.shape...))
+	for o := 0; o < outer; o++ {
+		for c := 0; c < channels; c++ {
+			factor := scale.data[c] / math.Sqrt(variance.data[c]+epsilon)
+			base := (o*channels + c) * inner
+			for i := 0; i < inner; i++ {
+				out.data[base+i] = (x.data[base+i]-mean.data[c])*factor + bias.data[c]
+			}
+		}
+	}
+	return []*tensor{out}, nil
+}
+
+// identityOperator passes its input through; Dropout is an identity at inference
+func identityOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	out := []*tensor{in[0]}
+	if node.op == "Dropout" && len(node.outputs) > 1 {
+		mask := newTensor(typeBool, append([]int(nil), in[0].shape...))
+		for i := range mask.data {
+			mask.data[i] = 1
+		}
+		out = append(out, mask)
+	}
+	return out, nil
+}
+
+// castOperator converts to the element type of the "to" attribute. Floats cast to integers are
+// truncated toward zero, as in C.
+func castOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	to := int32(node.attrInt("to", typeFloat))
+	shape := append([]int(nil), x.shape...)
+
+	if to == typeString {
+		out := &tensor{dtype: typeString, shape: shape, text: make([]string, len(x.data))}
+		if x.dtype == typeString {
+			copy(out.text, x.text)
+		}
+		for i, v := range x.data {
+			out.text[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return []*tensor{out}, nil
+	}
+
+	out := newTensor(to, shape)
+	if x.dtype == typeString {
+		for i, s := range x.text {
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				v = math.NaN()
+			}
+			out.data[i] = v
+		}
+	} else {
+		copy(out.data, x.data)
+	}
+
+	for i, v := range out.data {
+		switch {
+		case to == typeBool:
+			if v != 0 {
+				out.data[i] = 1
+			}
+		case to == typeFloat:
+			out.data[i] = float64(float32(v))
+		case isInteger(to):
+			out.data[i] = math.Trunc(v)
+		}
+	}
+	return []*tensor{out}, nil
+}
+
+// constantOperator returns its value attribute, or one of the value_* shorthands
+func constantOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if a, ok := node.attrs["value"]; ok && a.t != nil {
+		return []*tensor{a.t}, nil
+	}
+	if a, ok := node.attrs["value_float"]; ok {
+		return []*tensor{{dtype: typeFloat, shape: []int{}, data: []float64{a.f}}}, nil
+	}
+	if a, ok := node.attrs["value_floats"]; ok {
+		return []*tensor{{dtype: typeFloat, shape: []int{len(a.floats)}, data: a.floats}}, nil
+	}
+	if a, ok := node.attrs["value_int"]; ok {
+		return []*tensor{{dtype: typeInt64, shape: []int{}, data: []float64{float64(a.i)}}}, nil
+	}
+	if a, ok := node.attrs["value_ints"]; ok {
+		return []*tensor{{dtype: typeInt64, shape: []int{len(a.ints)}, data: int64sToFloats(a.ints)}}, nil
+	}
+	if a, ok := node.attrs["value_string"]; ok {
+		return []*tensor{{dtype: typeString, shape: []int{}, text: []string{a.s}}}, nil
+	}
+	if a, ok := node.attrs["value_strings"]; ok {
+		return []*tensor{{dtype: typeString, shape: []int{len(a.strings)}, text: a.strings}}, nil
+	}
+	return nil, errors.New(localize("Constant has no value"))
+}
+
+// shapeOperator returns the shape of its input as int64 values
+func shapeOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	out := newTensor(typeInt64, []int{len(in[0].shape)})
+	for i, dim := range in[0].shape {
+		out.data[i] = float64(dim)
+	}
+	return []*tensor{out}, nil
+}
+
+// reshapeOperator reshapes to its second input, where 0 copies a dimension and -1 is inferred
+func reshapeOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	var target []float64
+	if t := optionalInput(in, 1); t != nil {
+		target = t.data
+	} else {
+		target = int64sToFloats(node.attrInts("shape"))
+	}
+
+	shape := make([]int, len(target))
+	inferred, known := -1, 1
+	for i, v := range target {
+		dim := int(v)
+		switch {
+		case dim == 0 && i < len(x.shape) && node.attrInt("allowzero", 0) == 0:
+			dim = x.shape[i]
+		case dim == -1:
+			if inferred >= 0 {
+				return nil, errors.New(localize("cannot reshape %v to %v", x.shape, target))
+			}
+			inferred = i
+			continue
+		}
+		shape[i] = dim
+		known *= dim
+	}
+	size := len(x.data) + len(x.text)
+	if inferred >= 0 {
+		if known == 0 || size%known != 0 {
+			return nil, errors.New(localize("cannot reshape %v to %v", x.shape, target))
+		}
+		shape[inferred] = size / known
+	} else if known != size {
+		return nil, errors.New(localize("cannot reshape %v to %v", x.shape, target))
+	}
+	return []*tensor{x.reshaped(shape)}, nil
+}
+
+// flattenOperator reshapes to 2D, joining the dimensions before and after the axis
+func flattenOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	axis := node.attrInt("axis", 1)
+	if axis < 0 {
+		axis += int64(len(x.shape))
+	}
+	if axis < 0 || axis > int64(len(x.shape)) {
+		return nil, errors.New(localize("axis %d is out of range for rank %d", node.attrInt("axis", 1), len(x.shape)))
+	}
+	return []*tensor{x.reshaped([]int{shapeSize(x.shape[:axis]), shapeSize(x.shape[axis:])})}, nil
+}
+
+// axesArgument reads axes from the attribute (older opsets) or the second input (newer ones)
+func axesArgument(node *onnxNode, in []*tensor) []int64 {
+	if axes := node.attrInts("axes"); axes != nil {
+		return axes
+	}
+	if t := optionalInput(in, 1); t != nil {
+		axes := make([]int64, len(t.data))
+		for i, v := range t.data {
+			axes[i] = int64(v)
+		}
+		return axes
+	}
+	return nil
+}
+
+// squeezeOperator removes the given dimensions of size 1, or all of them
+func squeezeOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	removed := map[int]bool{}
+	for _, axis := range axesArgument(node, in) {
+		a, err := normalizeAxis(axis, len(x.shape))
+		if err != nil {
+			return nil, err
+		}
+		if x.shape[a] != 1 {
+			return nil, errors.New(localize("cannot squeeze dimension %d of size %d", a, x.shape[a]))
+		}
+		removed[a] = true
+	}
+
+	shape := []int{}
+	for i, dim := range x.shape {
+		if removed[i] || (len(removed) == 0 && dim == 1) {
+			continue
+		}
+		shape = append(shape, dim)
+	}
+	return []*tensor{x.reshaped(shape)}, nil
+}
+
+// unsqueezeOperator inserts dimensions of size 1 at the given axes of the output
+func unsqueezeOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	axes := axesArgument(node, in)
+	rank := len(x.shape) + len(axes)
+	inserted := map[int]bool{}
+	for _, axis := range axes {
+		a, err := normalizeAxis(axis, rank)
+		if err != nil {
+			return nil, err
+		}
+		inserted[a] = true
+	}
+
+	shape := make([]int, 0, rank)
+	next := 0
+	for i := 0; i < rank; i++ {
+		if inserted[i] {
+			shape = append(shape, 1)
+			continue
+		}
+		if next >= len(x.shape) {
+			return nil, errors.New(localize("axis %d is out of range for rank %d", i, rank))
+		}
+		shape = append(shape, x.shape[next])
+		next++
+	}
+	return []*tensor{x.reshaped(shape)}, nil
+}
+
+// transposeOperator permutes dimensions, reversing them when perm is absent
+func transposeOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	rank := len(x.shape)
+	perm := make([]int, rank)
+	if attr := node.attrInts("perm"); attr != nil {
+		if len(attr) != rank {
+			return nil, errors.New(localize("perm %v does not match rank %d", attr, rank))
+		}
+		for i, p := range attr {
+			perm[i] = int(p)
+		}
+	} else {
+		for i := range perm {
+			perm[i] = rank - 1 - i
+		}
+	}
+
+	strides := make([]int, rank)
+	stride := 1
+	for i := rank - 1; i >= 0; i-- {
+		strides[i] = stride
+		stride *= x.shape[i]
+	}
+	shape := make([]int, rank)
+	permuted := make([]int, rank)
+	for i, p := range perm {
+		if p < 0 || p >= rank {
+			return nil, errors.New(localize("perm %v does not match rank %d", perm, rank))
+		}
+		shape[i] = x.shape[p]
+		permuted[i] = strides[p]
+	}
+
+	out := newTensor(x.dtype, shape)
+	index := make([]int, rank)
+	source := 0
+	for k := range out.data {
+		out.data[k] = x.data[source]
+		for d := rank - 1; d >= 0; d-- {
+			index[d]++
+			source += permuted[d]
+			if index[d] < shape[d] {
+				break
+			}
+			source -= permuted[d] * shape[d]
+			index[d] = 0
+		}
+	}
+	return []*tensor{out}, nil
+}
+
+// concatOperator joins tensors along an axis
+func concatOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	first := in[0]
+	axis, err := normalizeAxis(node.attrInt("axis", 0), len(first.shape))
+	if err != nil {
+		return nil, err
+	}
+
+	shape := append([]int(nil), first.shape...)
+	shape[axis] = 0
+	for _, t := range in {
+		if t == nil {
+			continue
+		}
+		if len(t.shape) != len(first.shape) {
+			return nil, errors.New(localize("cannot concatenate shapes %v and %v", first.shape, t.shape))
+		}
+		for d := range t.shape {
+			if d != axis && t.shape[d] != first.shape[d] {
+				return nil, errors.New(localize("cannot concatenate shapes %v and %v", first.shape, t.shape))
+			}
+		}
+		shape[axis] += t.shape[axis]
+	}
+
+	outer := shapeSize(shape[:axis])
+	out := &tensor{dtype: first.dtype, shape: shape}
+	for o := 0; o < outer; o++ {
+		for _, t := range in {
+			if t == nil {
+				continue
+			}
+			block := t.shape[axis] * shapeSize(t.shape[axis+1:])
+			if t.dtype == typeString {
+				out.text = append(out.text, t.text[o*block:(o+1)*block]...)
+			} else {
+				out.data = append(out.data, t.data[o*block:(o+1)*block]...)
+			}
+		}
+	}
+	return []*tensor{out}, nil
+}
+
+// gatherOperator picks slices along an axis by index; negative indexes count from the end
+func gatherOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 2); err != nil {
+		return nil, err
+	}
+	x, indices := in[0], in[1]
+	axis, err := normalizeAxis(node.attrInt("axis", 0), len(x.shape))
+	if err != nil {
+		return nil, err
+	}
+	outer, dim, inner := axisSplit(x.shape, axis)
+
+	shape := append(append(append([]int(nil), x.shape[:axis]...), indices.shape...), x.shape[axis+1:]...)
+	out := &tensor{dtype: x.dtype, shape: shape}
+	for o := 0; o < outer; o++ {
+		for _, v := range indices.data {
+			i := int(v)
+			if i < 0 {
+				i += dim
+			}
+			if i < 0 || i >= dim {
+				return nil, errors.New(localize("index %d is out of range for dimension %d", int(v), dim))
+			}
+			start := (o*dim + i) * inner
+			if x.dtype == typeString {
+				out.text = append(out.text, x.text[start:start+inner]...)
+			} else {
+				out.data = append(out.data, x.data[start:start+inner]...)
+			}
+		}
+	}
+	return []*tensor{out}, nil
+}
+
+// argOperator returns the index of the largest (or smallest) value along an axis
+func argOperator(smallest bool) operatorFunc {
+	return func(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(in, 1); err != nil {
+			return nil, err
+		}
+		x := in[0]
+		axis, err := normalizeAxis(node.attrInt("axis", 0), len(x.shape))
+		if err != nil {
+			return nil, err
+		}
+		last := node.attrInt("select_last_index", 0) != 0
+		outer, dim, inner := axisSplit(x.shape, axis)
+
+		shape := append([]int(nil), x.shape...)
+		if node.attrInt("keepdims", 1) != 0 {
+			shape[axis] = 1
+		} else {
+			shape = append(shape[:axis], shape[axis+1:]...)
+		}
+		out := newTensor(typeInt64, shape)
+		for o := 0; o < outer; o++ {
+			for i := 0; i < inner; i++ {
+				best := 0
+				for d := 1; d < dim; d++ {
+					v, b := x.data[(o*dim+d)*inner+i], x.data[(o*dim+best)*inner+i]
+					if smallest {
+						v, b = -v, -b
+					}
+					if v > b || (last && v == b) {
+						best = d
+					}
+				}
+				out.data[o*inner+i] = float64(best)
+			}
+		}
+		return []*tensor{out}, nil
+	}
+}
+
+// reduceOperator reduces over axes (all of them by default), keeping reduced dimensions unless keepdims is 0
+func reduceOperator(kind string) operatorFunc {
+	return func(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(in, 1); err != nil {
+			return nil, err
+		}
+		x := in[0]
+		rank := len(x.shape)
+		reduced := make([]bool, rank)
+		axes := axesArgument(node, in)
+		if len(axes) == 0 {
+			if node.attrInt("noop_with_empty_axes", 0) != 0 {
+				return []*tensor{x}, nil
+			}
+			for i := range reduced {
+				reduced[i] = true
+			}
+		}
+		for _, axis := range axes {
+			a, err := normalizeAxis(axis, rank)
+			if err != nil {
+				return nil, err
+			}
+			reduced[a] = true
+		}
+
+		keep := node.attrInt("keepdims", 1) != 0
+		kept := make([]int, rank)
+		shape := []int{}
+		for i, dim := range x.shape {
+			kept[i] = dim
+			if reduced[i] {
+				kept[i] = 1
+				if !keep {
+					continue
+				}
+				shape = append(shape, 1)
+				continue
+			}
+			shape = append(shape, dim)
+		}
+
+		out := newTensor(x.dtype, shape)
+		switch kind {
+		case "max":
+			for i := range out.data {
+				out.data[i] = math.Inf(-1)
+			}
+		case "min":
+			for i := range out.data {
+				out.data[i] = math.Inf(1)
+			}
+		}
+
+		strides := broadcastStrides(kept, x.shape)
+		index := make([]int, rank)
+		target := 0
+		for _, v := range x.data {
+			switch kind {
+			case "sum", "mean":
+				out.data[target] += v
+			case "max":
+				out.data[target] = math.Max(out.data[target], v)
+			case "min":
+				out.data[target] = math.Min(out.data[target], v)
+			}
+			for d := rank - 1; d >= 0; d-- {
+				index[d]++
+				target += strides[d]
+				if index[d] < x.shape[d] {
+					break
+				}
+				target -= strides[d] * x.shape[d]
+				index[d] = 0
+			}
+		}
+
+		if kind == "mean" && len(out.data) > 0 {
+			count := float64(len(x.data) / len(out.data))
+			for i := range out.data {
+				out.data[i] /= count
+			}
+		}
+		return []*tensor{out}, nil
+	}
+}
+
+// rowsOf views an ML operator input as rows of features: [N, F], or a single row for 1D input
+func rowsOf(x *tensor) (int, int) {
+	switch len(x.shape) {
+	case 0:
+		return 1, 1
+	case 1:
+		return 1, x.shape[0]
+	}
+	return x.shape[0], len(x.data) / maxInt(x.shape[0], 1)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// postTransform applies the post_transform of the ai.onnx.ml operators to one row of scores
+func postTransform(scores []float64, transform string) error {
+	switch transform {
+	case "", "NONE":
+	case "LOGISTIC":
+		for i, v := range scores {
+			scores[i] = sigmoid(v)
+		}
+	case "SOFTMAX", "SOFTMAX_ZERO":
+		peak := math.Inf(-1)
+		for _, v := range scores {
+			peak = math.Max(peak, v)
+		}
+		sum := 0.0
+		for i, v := range scores {
+			if transform == "SOFTMAX_ZERO" && v == 0 {
+				continue
+			}
+			scores[i] = math.Exp(v - peak)
+			sum += scores[i]
+		}
+		for i, v := range scores {
+			if transform == "SOFTMAX_ZERO" && v == 0 {
+				continue
+			}
+			scores[i] = v / sum
+		}
+	case "PROBIT":
+		for i, v := range scores {
+			scores[i] = math.Sqrt2 * math.Erfinv(2*v-1)
+		}
+	default:
+		return errors.New(localize("unsupported post_transform %q", transform))
+	}
+	return nil
+}
+
+// binaryScores expands the single score of a two-class model to both classes
+func binaryScores(score float64, transform string) ([]float64, error) {
+	if transform == "LOGISTIC" {
+		p := sigmoid(score)
+		return []float64{1 - p, p}, nil
+	}
+	scores := []float64{-score, score}
+	return scores, postTransform(scores, transform)
+}
+
+// classLabels returns the class labels of a classifier, as numbers or strings
+func classLabels(node *onnxNode, numbers, texts string) ([]int64, []string) {
+	return node.attrInts(numbers), node.attrStrings(texts)
+}
+
+// labelTensor builds the label output from the class index of each row
+func labelTensor(indexes []int, ints []int64, texts []string) *tensor {
+	if texts != nil {
+		out := &tensor{dtype: typeString, shape: []int{len(indexes)}, text: make([]string, len(indexes))}
+		for r, i := range indexes {
+			if i < len(texts) {
+				out.text[r] = texts[i]
+			}
+		}
+		return out
+	}
+	out := newTensor(typeInt64, []int{len(indexes)})
+	for r, i := range indexes {
+		out.data[r] = float64(i)
+		if i < len(ints) {
+			out.data[r] = float64(ints[i])
+		}
+	}
+	return out
+}
+
+// argmax returns the index of the first largest value
+func argmax(values []float64) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// linearScores computes X·Cᵀ + intercepts for each row, with coefficients given target by target
+func linearScores(node *onnxNode, x *tensor, targets int) ([][]float64, error) {
+	rows, features := rowsOf(x)
+	coefficients := node.attrFloats("coefficients")
+	intercepts := node.attrFloats("intercepts")
+	if targets <= 0 || len(coefficients) != targets*features {
+		return nil, errors.New(localize("%d coefficients do not match %d features", len(coefficients), features))
+	}
+
+	scores := make([][]float64, rows)
+	for r := range scores {
+		row := x.data[r*features : (r+1)*features]
+		scores[r] = make([]float64, targets)
+		for t := 0; t < targets; t++ {
+			sum := 0.0
+			if t < len(intercepts) {
+				sum = intercepts[t]
+			}
+			for f, v := range row {
+				sum += coefficients[t*features+f] * v
+			}
+			scores[r][t] = sum
+		}
+	}
+	return scores, nil
+}
+
+// linearRegressorOperator computes the targets of a linear model
+func linearRegressorOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	targets := int(node.attrInt("targets", 1))
+	scores, err := linearScores(node, in[0], targets)
+	if err != nil {
+		return nil, err
+	}
+
+	out := newTensor(typeFloat, []int{len(scores), targets})
+	transform := node.attrString("post_transform", "NONE")
+	for r, row := range scores {
+		if err := postTransform(row, transform); err != nil {
+			return nil, err
+		}
+		copy(out.data[r*targets:], row)
+	}
+	return []*tensor{out}, nil
+}
+
+// linearClassifierOperator scores each class with a linear model and returns the label and the scores.
+// A single row of coefficients with two labels is a binary model: the score is that of the second class.
+func linearClassifierOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	ints, texts := classLabels(node, "classlabels_ints", "classlabels_strings")
+	classes := len(ints) + len(texts)
+	_, features := rowsOf(in[0])
+	targets := 0
+	if features > 0 {
+		targets = len(node.attrFloats("coefficients")) / features
+	}
+
+	scores, err := linearScores(node, in[0], targets)
+	if err != nil {
+		return nil, err
+	}
+	return classifierOutputs(scores, targets == 1 && classes == 2, node.attrString("post_transform", "NONE"), ints, texts)
+}
+
+// classifierOutputs picks the label of each row and applies the post transform to its scores
+func classifierOutputs(scores [][]float64, binary bool, transform string, ints []int64, texts []string) ([]*tensor, error) {
+	width := 2
+	if !binary && len(scores) > 0 {
+		width = len(scores[0])
+	}
+	indexes := make([]int, len(scores))
+	out := newTensor(typeFloat, []int{len(scores), width})
+
+	for r, row := range scores {
+		if binary {
+			if row[0] > 0 {
+				indexes[r] = 1
+			}
+			expanded, err := binaryScores(row[0], transform)
+			if err != nil {
+				return nil, err
+			}
+			row = expanded
+		} else {
+			indexes[r] = argmax(row)
+			if err := postTransform(row, transform); err != nil {
+				return nil, err
+			}
+		}
+		copy(out.data[r*width:], row)
+	}
+	return []*tensor{labelTensor(indexes, ints, texts), out}, nil
+}
+
+// Tree ensembles
+
+// Node modes of tree ensembles
+const (
+	branchLEQ = iota
+	branchLT
+	branchGTE
+	branchGT
+	branchEQ
+	branchNEQ
+	branchLeaf
+)
+
+var branchModes = map[string]int{
+	"BRANCH_LEQ": branchLEQ,
+	"BRANCH_LT":  branchLT,
+	"BRANCH_GTE": branchGTE,
+	"BRANCH_GT":  branchGT,
+	"BRANCH_EQ":  branchEQ,
+	"BRANCH_NEQ": branchNEQ,
+	"LEAF":       branchLeaf,
+}
+
+// treeNode is a compiled node: a comparison that leads to another node, or a leaf with its weights
+type treeNode struct {
+	mode        int
+	feature     int
+	value       float64
+	trueNext    int
+	falseNext   int
+	missingTrue bool
+	weights     []treeWeight
+}
+
+// treeWeight adds weight to a target (regressor) or class (classifier) when a tree ends on a leaf
+type treeWeight struct {
+	target int
+	weight float64
+}
+
+// treeEnsemble is the compiled form of a TreeEnsembleRegressor or TreeEnsembleClassifier node
+type treeEnsemble struct {
+	nodes     []treeNode
+	roots     []int
+	width     int
+	aggregate string
+	base      []float64
+	transform string
+	binary    bool
+	ints      []int64
+	texts     []string
+}
+
+// treeEnsembleOperator evaluates decision trees, random forests and gradient boosting ensembles
+func treeEnsembleOperator(classifier bool) operatorFunc {
+	return func(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(in, 1); err != nil {
+			return nil, err
+		}
+		if node.compiled == nil {
+			ensemble, err := compileTrees(node, classifier)
+			if err != nil {
+				return nil, err
+			}
+			node.compiled = ensemble
+		}
+		ensemble := node.compiled.(*treeEnsemble)
+
+		x := in[0]
+		rows, features := rowsOf(x)
+		scores := make([][]float64, rows)
+		for r := range scores {
+			row, err := ensemble.score(x.data[r*features : (r+1)*features])
+			if err != nil {
+				return nil, err
+			}
+			scores[r] = row
+		}
+
+		if classifier {
+			return classifierOutputs(scores, ensemble.binary, ensemble.transform, ensemble.ints, ensemble.texts)
+		}
+		out := newTensor(typeFloat, []int{rows, ensemble.width})
+		for r, row := range scores {
+			if err := postTransform(row, ensemble.transform); err != nil {
+				return nil, err
+			}
+			copy(out.data[r*ensemble.width:], row)
+		}
+		return []*tensor{out}, nil
+	}
+}
+
+// compileTrees links the flat node attributes of a tree ensemble into trees
+func compileTrees(node *onnxNode, classifier bool) (*treeEnsemble, error) {
+	treeIDs := node.attrInts("nodes_treeids")
+	nodeIDs := node.attrInts("nodes_nodeids")
+	featureIDs := node.attrInts("nodes_featureids")
+	values := node.attrFloats("nodes_values")
+	if values == nil {
+		values = node.attrFloats("nodes_values_as_tensor")
+	}
+	modes := node.attrStrings("nodes_modes")
+	trueIDs := node.attrInts("nodes_truenodeids")
+	falseIDs := node.attrInts("nodes_falsenodeids")
+	missing := node.attrInts("nodes_missing_value_tracks_true")
+
+	count := len(nodeIDs)
+	for _, n := range []int{len(treeIDs), len(featureIDs), len(values), len(modes), len(trueIDs), len(falseIDs)} {
+		if n != count {
+			return nil, errors.New(localize("the tree node attributes have different lengths"))
+		}
+	}
+
+	type key struct{ tree, node int64 }
+	index := make(map[key]int, count)
+	for i := range nodeIDs {
+		index[key{treeIDs[i], nodeIDs[i]}] = i
+	}
+
+	ensemble := &treeEnsemble{
+		nodes:     make([]treeNode, count),
+		aggregate: node.attrString("aggregate_function", "SUM"),
+		base:      node.attrFloats("base_values"),
+		transform: node.attrString("post_transform", "NONE"),
+	}
+	child := make([]bool, count)
+	for i := range ensemble.nodes {
+		mode, ok := branchModes[modes[i]]
+		if !ok {
+			return nil, errors.New(localize("unsupported tree node mode %q", modes[i]))
+		}
+		n := treeNode{mode: mode, feature: int(featureIDs[i]), value: values[i]}
+		if i < len(missing) {
+			n.missingTrue = missing[i] != 0
+		}
+		if mode != branchLeaf {
+			next, ok := index[key{treeIDs[i], trueIDs[i]}]
+			other, ok2 := index[key{treeIDs[i], falseIDs[i]}]
+			if !ok || !ok2 {
+				return nil, errors.New(localize("tree %d node %d points to a missing node", treeIDs[i], nodeIDs[i]))
+			}
+			n.trueNext, n.falseNext = next, other
+			child[next], child[other] = true, true
+		}
+		ensemble.nodes[i] = n
+	}
+
+	seen := map[int64]bool{}
+	for i := range nodeIDs {
+		if !child[i] && !seen[treeIDs[i]] {
+			seen[treeIDs[i]] = true
+			ensemble.roots = append(ensemble.roots, i)
+		}
+	}
+
+	prefix := "target"
+	if classifier {
+		prefix = "class"
+	}
+	weightTrees := node.attrInts(prefix + "_treeids")
+	weightNodes := node.attrInts(prefix + "_nodeids")
+	weightTargets := node.attrInts(prefix + "_ids")
+	weights := node.attrFloats(prefix + "_weights")
+	if weights == nil {
+		weights = node.attrFloats(prefix + "_weights_as_tensor")
+	}
+	if len(weightNodes) != len(weightTrees) || len(weightTargets) != len(weightTrees) || len(weights) != len(weightTrees) {
+		return nil, errors.New(localize("the tree node attributes have different lengths"))
+	}
+
+	widest := 0
+	for i := range weightTrees {
+		leaf, ok := index[key{weightTrees[i], weightNodes[i]}]
+		if !ok {
+			return nil, errors.New(localize("tree %d node %d points to a missing node", weightTrees[i], weightNodes[i]))
+		}
+		target := int(weightTargets[i])
+		ensemble.nodes[leaf].weights = append(ensemble.nodes[leaf].weights, treeWeight{target: target, weight: weights[i]})
+		widest = maxInt(widest, target+1)
+	}
+
+	if classifier {
+		ensemble.ints, ensemble.texts = classLabels(node, "classlabels_int64s", "classlabels_strings")
+		classes := len(ensemble.ints) + len(ensemble.texts)
+		ensemble.binary = classes == 2 && widest <= 1
+		ensemble.width = classes
+		if ensemble.binary {
+			ensemble.width = 1
+		}
+	} else {
+		ensemble.width = int(node.attrInt("n_targets", 1))
+	}
+	if widest > ensemble.width {
+		return nil, errors.New(localize("tree weights refer to target %d of %d", widest-1, ensemble.width))
+	}
+	return ensemble, nil
+}
+
+// score walks every tree for one row and aggregates the leaf weights
+func (e *treeEnsemble) score(row []float64) ([]float64, error) {
+	scores := make([]float64, e.width)
+	switch e.aggregate {
+	case "MIN":
+		for i := range scores {
+			scores[i] = math.Inf(1)
+		}
+	case "MAX":
+		for i := range scores {
+			scores[i] = math.Inf(-1)
+		}
+	}
+
+	for _, root := range e.roots {
+		i := root
+		for steps := 0; e.nodes[i].mode != branchLeaf; steps++ {
+			n := &e.nodes[i]
+			if steps > len(e.nodes) {
+				return nil, errors.New(localize("the trees contain a cycle"))
+			}
+			if n.feature < 0 || n.feature >= len(row) {
+				return nil, errors.New(localize("the trees use feature %d of %d", n.feature, len(row)))
+			}
+			if n.decide(row[n.feature]) {
+				i = n.trueNext
+			} else {
+				i = n.falseNext
+			}
+		}
+
+		for _, w := range e.nodes[i].weights {
+			switch e.aggregate {
+			case "MIN":
+				scores[w.target] = math.Min(scores[w.target], w.weight)
+			case "MAX":
+				scores[w.target] = math.Max(scores[w.target], w.weight)
+			default:
+				scores[w.target] += w.weight
+			}
+		}
+	}
+
+	if e.aggregate == "AVERAGE" && len(e.roots) > 0 {
+		for i := range scores {
+			scores[i] /= float64(len(e.roots))
+		}
+	}
+	for i := range scores {
+		if i < len(e.base) {
+			scores[i] += e.base[i]
+		}
+	}
+	return scores, nil
+}
+
+// decide tells whether a value takes the true branch. Missing values (NaN) follow missing_value_tracks_true.
+func (n *treeNode) decide(v float64) bool {
+	if math.IsNaN(v) {
+		return n.missingTrue
+	}
+	switch n.mode {
+	case branchLEQ:
+		return v <= n.value
+	case branchLT:
+		return v < n.value
+	case branchGTE:
+		return v >= n.value
+	case branchGT:
+		return v > n.value
+	case branchEQ:
+		return v == n.value
+	}
+	return v != n.value
+}
+
+// normalizerOperator scales each row to unit MAX, L1 or L2 norm
+func normalizerOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	rows, features := rowsOf(x)
+	norm := node.attrString("norm", "MAX")
+
+	out := newTensor(typeFloat, []int{rows, features})
+	for r := 0; r < rows; r++ {
+		row := x.data[r*features : (r+1)*features]
+		scale := 0.0
+		switch norm {
+		case "MAX":
+			scale = math.Inf(-1)
+			for _, v := range row {
+				scale = math.Max(scale, v)
+			}
+		case "L1":
+			for _, v := range row {
+				scale += math.Abs(v)
+			}
+		case "L2":
+			for _, v := range row {
+				scale += v * v
+			}
+			scale = math.Sqrt(scale)
+		default:
+			return nil, errors.New(localize("unsupported norm %q", norm))
+		}
+		for f, v := range row {
+			if scale != 0 {
+				v /= scale
+			}
+			out.data[r*features+f] = v
+		}
+	}
+	return []*tensor{out}, nil
+}
+
+// featureValue returns the value for a feature from a per-feature list, or its only value
+func featureValue(values []float64, feature int, fallback float64) float64 {
+	switch {
+	case feature < len(values):
+		return values[feature]
+	case len(values) == 1:
+		return values[0]
+	}
+	return fallback
+}
+
+// scalerOperator computes (x - offset) * scale per feature
+func scalerOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	rows, features := rowsOf(x)
+	offsets, scales := node.attrFloats("offset"), node.attrFloats("scale")
+
+	out := newTensor(typeFloat, []int{rows, features})
+	for i, v := range x.data {
+		f := i % features
+		out.data[i] = (v - featureValue(offsets, f, 0)) * featureValue(scales, f, 1)
+	}
+	return []*tensor{out}, nil
+}
+
+// imputerOperator replaces the values equal to replaced_value_float (NaN matching NaN) by the imputed value of the feature
+func imputerOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	_, features := rowsOf(x)
+	imputed := node.attrFloats("imputed_value_floats")
+	replaced := node.attrFloat("replaced_value_float", 0)
+
+	out := newTensor(x.dtype, append([]int(nil), x.shape...))
+	for i, v := range x.data {
+		if v == replaced || (math.IsNaN(v) && math.IsNaN(replaced)) {
+			v = featureValue(imputed, i%features, v)
+		}
+		out.data[i] = v
+	}
+	return []*tensor{out}, nil
+}
+
+// binarizerOperator maps values above the threshold to 1, the others to 0
+func binarizerOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	threshold := node.attrFloat("threshold", 0)
+	return unaryOperator(func(x float64) float64 {
+		if x > threshold {
+			return 1
+		}
+		return 0
+	})(node, in, opset)
+}
+
+// arrayFeatureExtractorOperator selects columns of the last dimension by index
+func arrayFeatureExtractorOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 2); err != nil {
+		return nil, err
+	}
+	x, indices := in[0], in[1]
+	if len(x.shape) == 0 {
+		return nil, errors.New(localize("ArrayFeatureExtractor expects at least 1 dimension"))
+	}
+	width := x.shape[len(x.shape)-1]
+	outer := shapeSize(x.shape[:len(x.shape)-1])
+
+	shape := append(append([]int(nil), x.shape[:len(x.shape)-1]...), len(indices.data))
+	if len(x.shape) == 1 {
+		shape = []int{1, len(indices.data)}
+	}
+	out := &tensor{dtype: x.dtype, shape: shape}
+	for o := 0; o < outer; o++ {
+		for _, v := range indices.data {
+			i := int(v)
+			if i < 0 || i >= width {
+				return nil, errors.New(localize("index %d is out of range for dimension %d", i, width))
+			}
+			if x.dtype == typeString {
+				out.text = append(out.text, x.text[o*width+i])
+			} else {
+				out.data = append(out.data, x.data[o*width+i])
+			}
+		}
+	}
+	return []*tensor{out}, nil
+}
+
+// zipMapOperator pairs each column of class scores with its label
+func zipMapOperator(node *onnxNode, in []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(in, 1); err != nil {
+		return nil, err
+	}
+	x := in[0]
+	labels := node.attrStrings("classlabels_strings")
+	if labels == nil {
+		for _, label := range node.attrInts("classlabels_int64s") {
+			labels = append(labels, strconv.FormatInt(label, 10))
+		}
+	}
+	_, width := rowsOf(x)
+	if len(labels) != width {
+		return nil, errors.New(localize("ZipMap has %d labels for %d columns", len(labels), width))
+	}
+	return []*tensor{{dtype: x.dtype, shape: x.shape, data: x.data, labels: labels}}, nil
+}
+
+func int64sToFloats(values []int64) []float64 {
+	floats := make([]float64, len(values))
+	for i, v := range values {
+		floats[i] = float64(v)
+	}
+	return floats
+}
+
+// uniqueStrings removes repeated values from a sorted slice
+func uniqueStrings(values []string) []string {
+	unique := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// decodeOptions reads an options object, or its JSON text, into target
+func decodeOptions(value js.Value, target interface{}) error {
+	switch value.Type() {
+	case js.TypeUndefined, js.TypeNull:
+		return nil
+	case js.TypeObject:
+		value = js.Global().Get("JSON").Call("stringify", value)
+	}
+	return json.Unmarshal([]byte(value.String()), target)
+}
+
+// optionalValue returns the argument at index, or undefined when it was not passed
+func optionalValue(args []js.Value, index int) js.Value {
+	if len(args) > index {
+		return args[index]
+	}
+	return js.Undefined()
+}
+
+// bytesFromJS - Read binary data passed as a Uint8Array, an ArrayBuffer or a base64 string
+func bytesFromJS(value js.Value) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		return base64.StdEncoding.DecodeString(value.String())
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case !value.InstanceOf(js.Global().Get("Uint8Array")):
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or base64 string"))
+	}
+
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// isTypedArray reports whether value is a typed array (not a DataView)
+func isTypedArray(value js.Value) bool {
+	return value.Type() == js.TypeObject &&
+		js.Global().Get("ArrayBuffer").Call("isView", value).Bool() &&
+		!value.InstanceOf(js.Global().Get("DataView"))
+}
+
+// isArrayLike reports whether value is an Array or a typed array
+func isArrayLike(value js.Value) bool {
+	return value.Type() == js.TypeObject &&
+		(js.Global().Get("Array").Call("isArray", value).Bool() || isTypedArray(value))
+}
+
+// newFloat64Array copies a Go slice into a new JavaScript Float64Array
+func newFloat64Array(values []float64) js.Value {
+	raw := make([]byte, len(values)*8)
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(raw[i*8:], math.Float64bits(v))
+	}
+	bytes := js.Global().Get("Uint8Array").New(len(raw))
+	js.CopyBytesToJS(bytes, raw)
+	return js.Global().Get("Float64Array").New(bytes.Get("buffer"))
+}
+
+// stringValues converts strings for js.ValueOf
+func stringValues(values []string) []interface{} {
+	converted := make([]interface{}, len(values))
+	for i, value := range values {
+		converted[i] = value
+	}
+	return converted
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"onnx",
+	"linear-models",
+	"mlp",
+	"tree-ensembles",
+	"zipmap",
+	"batch-inference",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and loaded models so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	parameters := 0
+	for _, model := range models {
+		parameters += model.parameters()
+	}
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"models":     len(models),
+		"parameters": parameters,
+	}))
+}
+
+// releaseResources - Unload every model and return freed memory to the runtime
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{
+		"models": len(models),
+	}
+	models = map[string]*onnxModel{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "ml-wasm",
+		"version":         moduleVersion,
+		"description":     "Lightweight ONNX inference: linear and logistic regression, small MLPs and tree ensembles",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"loadModel",
+		"predict",
+		"getModelInfo",
+		"unloadModel",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register model functions
+	js.Global().Set("loadModel", js.FuncOf(loadModel))
+	js.Global().Set("predict", js.FuncOf(predict))
+	js.Global().Set("getModelInfo", js.FuncOf(getModelInfo))
+	js.Global().Set("unloadModel", js.FuncOf(unloadModel))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM ML module ready!")
+	fmt.Println("Available functions: loadModel, predict, getModelInfo, unloadModel")
+
+	// Keep the program alive
+	select {}
+}
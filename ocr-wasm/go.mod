@@ -0,0 +1,7 @@
+module ocr-wasm
+
+go 1.21
+
+require golang.org/x/image v0.19.0
+
+require golang.org/x/text v0.17.0 // indirect
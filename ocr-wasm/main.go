@@ -0,0 +1,1841 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"syscall/js"
+	"time"
+	"unicode"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+var silentMode = false
+
+const (
+	// featureSize is the side of the normalized glyph bitmap compared against templates
+	featureSize = 16
+	// templateSize is the em size in pixels glyph templates are rendered at
+	templateSize = 64
+	// maxImagePixels bounds the decoded image size (about a 300 dpi A3 scan)
+	maxImagePixels = 25000000
+)
+
+// LanguageData is a downloadable recognition pack: the characters to recognize, the fonts
+// their templates are rendered from, and a word list used to correct low-confidence words
+type LanguageData struct {
+	Language string   `json:"language"`
+	Name     string   `json:"name"`
+	Charset  string   `json:"charset"`
+	Fonts    []string `json:"fonts"`
+	Words    []string `json:"words"`
+}
+
+// OCROptions tunes recognizeText
+type OCROptions struct {
+	Threshold     int     `json:"threshold"`
+	Invert        *bool   `json:"invert"`
+	MinConfidence float64 `json:"minConfidence"`
+	HOCR          bool    `json:"hocr"`
+}
+
+// asciiCharset is recognized by every built-in language
+const asciiCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789" +
+	".,;:!?'\"()[]{}<>-_/\\@#$%&*+=~|"
+
+// builtinLanguages are rendered from the Go fonts on first use, without any download
+var builtinLanguages = map[string]LanguageData{
+	"eng": {Language: "eng", Name: "English", Charset: asciiCharset},
+	"fra": {Language: "fra", Name: "French", Charset: asciiCharset + "àâçéèêëîïôùûüÿœÀÂÇÉÈÊËÎÏÔÙÛÜŒ«»€"},
+}
+
+// languageAliases maps ISO 639-1 codes to the ISO 639-2 codes used by language packs
+var languageAliases = map[string]string{
+	"en": "eng",
+	"fr": "fra",
+}
+
+// loadedLanguages caches the templates of the languages used so far, released by releaseResources
+var loadedLanguages = map[string]*language{}
+
+// language is a language pack ready for recognition
+type language struct {
+	data      LanguageData
+	templates []glyphTemplate
+	words     map[string]bool
+	byLength  map[int][]string
+	builtin   bool
+}
+
+// glyphTemplate is the rendering of one character in one font
+type glyphTemplate struct {
+	char     rune
+	features glyphFeatures
+}
+
+// glyphFeatures describes a glyph independently of its size. Positions are measured from
+// the baseline in units of the line's reference height (ascender height).
+type glyphFeatures struct {
+	bitmap [featureSize * featureSize]float64
+	aspect float64
+	size   float64
+	parts  int
+	holes  int
+	top    float64
+	bottom float64
+}
+
+// candidate is a possible reading of a glyph
+type candidate struct {
+	char     rune
+	distance float64
+	aspect   float64
+}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires at least 1 argument (%s)":                "%s requiert au moins 1 argument (%s)",
+	"Invalid image data: %v":                              "Données d'image invalides: %v",
+	"Invalid options format: %v":                          "Format des options invalide: %v",
+	"Invalid language: %v":                                "Langue invalide: %v",
+	"Invalid language data: %v":                           "Données de langue invalides: %v",
+	"expected a Uint8Array, ArrayBuffer or base64 string": "Uint8Array, ArrayBuffer ou chaîne base64 attendu",
+	"image is %dx%d pixels, the limit is %d pixels":       "l'image fait %dx%d pixels, la limite est de %d pixels",
+	"unknown language %q (load it with loadLanguageData)": "langue %q inconnue (chargez-la avec loadLanguageData)",
+	"language code is required":                           "le code de langue est requis",
+	"charset is required":                                 "le jeu de caractères (charset) est requis",
+	"font %d: %v":                                         "police %d: %v",
+	"no glyph of the charset could be rendered":           "aucun glyphe du jeu de caractères n'a pu être rendu",
+	"threshold must be between 0 and 255":                 "le seuil (threshold) doit être compris entre 0 et 255",
+	"setLocale requires exactly 1 argument (locale)":      "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":               "Langue %q non prise en charge (disponibles: %s)",
+	"built-in language %q cannot be replaced":             "la langue intégrée %q ne peut pas être remplacée",
+}
+
+// recognizeText - Recognize the text of an image, with word-level bounding boxes and confidence
+func recognizeText(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "recognizeText", "imageData"),
+		})
+	}
+
+	start := time.Now()
+
+	data, err := bytesFromJS(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid image data: %v", err),
+		})
+	}
+
+	code := "eng"
+	if len(args) > 1 && args[1].Type() == js.TypeString && args[1].String() != "" {
+		code = args[1].String()
+	}
+	lang, err := languageFor(code)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid language: %v", err),
+		})
+	}
+
+	var options OCROptions
+	if len(args) > 2 && args[2].Type() != js.TypeUndefined && args[2].Type() != js.TypeNull {
+		optionsJSON := args[2].String()
+		if args[2].Type() == js.TypeObject {
+			optionsJSON = js.Global().Get("JSON").Call("stringify", args[2]).String()
+		}
+		if err := json.Unmarshal([]byte(optionsJSON), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options format: %v", err),
+			})
+		}
+	}
+	if options.Threshold < 0 || options.Threshold > 255 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options format: %v", localize("threshold must be between 0 and 255")),
+		})
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid image data: %v", err),
+		})
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid image data: %v", localize("image is %dx%d pixels, the limit is %d pixels", config.Width, config.Height, maxImagePixels)),
+		})
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid image data: %v", err),
+		})
+	}
+
+	page := recognize(binarize(img, options), lang)
+
+	bounds := img.Bounds()
+	lines := []interface{}{}
+	words := []interface{}{}
+	var text strings.Builder
+	var confidenceSum float64
+	var charCount int
+	previousBottom := -1
+	for _, line := range page {
+		lineWords := []interface{}{}
+		var lineText []string
+		var lineConfidence float64
+		for _, word := range line.words {
+			if word.confidence < options.MinConfidence {
+				continue
+			}
+			entry := map[string]interface{}{
+				"text":       word.text,
+				"confidence": round1(word.confidence),
+				"bbox":       bboxValue(word.box),
+			}
+			lineWords = append(lineWords, entry)
+			lineText = append(lineText, word.text)
+			lineConfidence += word.confidence
+
+			flat := map[string]interface{}{"line": len(lines)}
+			for key, value := range entry {
+				flat[key] = value
+			}
+			words = append(words, flat)
+
+			n := len([]rune(word.text))
+			confidenceSum += word.confidence * float64(n)
+			charCount += n
+		}
+		if len(lineWords) == 0 {
+			continue
+		}
+
+		// A gap taller than a line of text starts a new paragraph
+		if previousBottom >= 0 {
+			text.WriteString("\n")
+			if float64(line.box.Min.Y-previousBottom) > line.ref*1.5 {
+				text.WriteString("\n")
+			}
+		}
+		previousBottom = line.box.Max.Y
+		text.WriteString(strings.Join(lineText, " "))
+
+		lines = append(lines, map[string]interface{}{
+			"text":       strings.Join(lineText, " "),
+			"confidence": round1(lineConfidence / float64(len(lineWords))),
+			"bbox":       bboxValue(line.box),
+			"baseline":   line.baseline,
+			"words":      lineWords,
+		})
+	}
+
+	confidence := 0.0
+	if charCount > 0 {
+		confidence = confidenceSum / float64(charCount)
+	}
+
+	result := map[string]interface{}{
+		"text":       text.String(),
+		"confidence": round1(confidence),
+		"language":   lang.data.Language,
+		"width":      bounds.Dx(),
+		"height":     bounds.Dy(),
+		"format":     format,
+		"lines":      lines,
+		"words":      words,
+		"duration":   time.Since(start).Milliseconds(),
+	}
+	if options.HOCR {
+		result["hocr"] = hocrDocument(bounds, lines)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Recognized %d words on %d lines (%s, %.1f%% confidence) in %dms\n",
+			len(words), len(lines), lang.data.Language, confidence, time.Since(start).Milliseconds())
+	}
+
+	return js.ValueOf(result)
+}
+
+// loadLanguageData - Register a downloaded language pack {language, name, charset, fonts?, words?}
+func loadLanguageData(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "loadLanguageData", "data"),
+		})
+	}
+
+	dataJSON := args[0].String()
+	if args[0].Type() == js.TypeObject {
+		dataJSON = js.Global().Get("JSON").Call("stringify", args[0]).String()
+	}
+	var data LanguageData
+	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid language data: %v", err),
+		})
+	}
+
+	data.Language = strings.ToLower(strings.TrimSpace(data.Language))
+	if _, ok := builtinLanguages[data.Language]; ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid language data: %v", localize("built-in language %q cannot be replaced", data.Language)),
+		})
+	}
+	lang, err := buildLanguage(data)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid language data: %v", err),
+		})
+	}
+	loadedLanguages[data.Language] = lang
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Loaded language %s (%d templates, %d words)\n", data.Language, len(lang.templates), len(lang.words))
+	}
+
+	return js.ValueOf(languageInfo(lang))
+}
+
+// getLanguages - List the built-in and loaded languages
+func getLanguages(this js.Value, args []js.Value) interface{} {
+	names := map[string]bool{}
+	for name := range builtinLanguages {
+		names[name] = true
+	}
+	for name := range loadedLanguages {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	languages := []interface{}{}
+	for _, name := range sorted {
+		if lang, ok := loadedLanguages[name]; ok {
+			languages = append(languages, languageInfo(lang))
+			continue
+		}
+		data := builtinLanguages[name]
+		languages = append(languages, map[string]interface{}{
+			"language":   data.Language,
+			"name":       data.Name,
+			"characters": len([]rune(data.Charset)),
+			"builtin":    true,
+			"loaded":     false,
+		})
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"languages": languages,
+	})
+}
+
+// languageInfo describes a language pack ready for recognition
+func languageInfo(lang *language) map[string]interface{} {
+	return map[string]interface{}{
+		"language":   lang.data.Language,
+		"name":       lang.data.Name,
+		"characters": len([]rune(lang.data.Charset)),
+		"templates":  len(lang.templates),
+		"words":      len(lang.words),
+		"builtin":    lang.builtin,
+		"loaded":     true,
+	}
+}
+
+// languageFor returns the language pack for a code. Codes joined with "+" (eng+fra)
+// combine several packs, as with Tesseract.
+func languageFor(code string) (*language, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	parts := strings.Split(code, "+")
+	for i, part := range parts {
+		if alias, ok := languageAliases[part]; ok {
+			parts[i] = alias
+		}
+	}
+	code = strings.Join(parts, "+")
+	if lang, ok := loadedLanguages[code]; ok {
+		return lang, nil
+	}
+
+	var packs []*language
+	for _, part := range parts {
+		if lang, ok := loadedLanguages[part]; ok {
+			packs = append(packs, lang)
+			continue
+		}
+		data, ok := builtinLanguages[part]
+		if !ok {
+			return nil, errors.New(localize("unknown language %q (load it with loadLanguageData)", part))
+		}
+		lang, err := buildLanguage(data)
+		if err != nil {
+			return nil, err
+		}
+		lang.builtin = true
+		loadedLanguages[part] = lang
+		packs = append(packs, lang)
+	}
+	if len(packs) == 1 {
+		return packs[0], nil
+	}
+
+	combined := &language{
+		data:     LanguageData{Language: code},
+		words:    map[string]bool{},
+		byLength: map[int][]string{},
+	}
+	seen := map[rune]bool{}
+	var names []string
+	for _, pack := range packs {
+		names = append(names, pack.data.Name)
+		for _, r := range pack.data.Charset {
+			if !seen[r] {
+				seen[r] = true
+				combined.data.Charset += string(r)
+			}
+		}
+		combined.templates = append(combined.templates, pack.templates...)
+		for word := range pack.words {
+			combined.addWord(word)
+		}
+	}
+	combined.data.Name = strings.Join(names, " + ")
+	loadedLanguages[code] = combined
+	return combined, nil
+}
+
+// buildLanguage renders the templates of a language pack
+func buildLanguage(data LanguageData) (*language, error) {
+	if data.Language == "" {
+		return nil, errors.New(localize("language code is required"))
+	}
+	if strings.TrimSpace(data.Charset) == "" {
+		return nil, errors.New(localize("charset is required"))
+	}
+	if data.Name == "" {
+		data.Name = data.Language
+	}
+
+	fonts := [][]byte{goregular.TTF, gobold.TTF, gomono.TTF}
+	if len(data.Fonts) > 0 {
+		fonts = nil
+		for i, encoded := range data.Fonts {
+			raw, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, errors.New(localize("font %d: %v", i, err))
+			}
+			fonts = append(fonts, raw)
+		}
+	}
+
+	lang := &language{
+		data:     data,
+		words:    map[string]bool{},
+		byLength: map[int][]string{},
+	}
+	for i, raw := range fonts {
+		parsed, err := opentype.Parse(raw)
+		if err != nil {
+			return nil, errors.New(localize("font %d: %v", i, err))
+		}
+		face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: templateSize, DPI: 72, Hinting: font.HintingNone})
+		if err != nil {
+			return nil, errors.New(localize("font %d: %v", i, err))
+		}
+		lang.templates = append(lang.templates, renderTemplates(face, parsed, data.Charset)...)
+		face.Close()
+	}
+	if len(lang.templates) == 0 {
+		return nil, errors.New(localize("no glyph of the charset could be rendered"))
+	}
+
+	for _, word := range data.Words {
+		lang.addWord(word)
+	}
+	return lang, nil
+}
+
+// addWord adds a dictionary word, compared case-insensitively
+func (l *language) addWord(word string) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" || l.words[word] {
+		return
+	}
+	l.words[word] = true
+	n := len([]rune(word))
+	l.byLength[n] = append(l.byLength[n], word)
+}
+
+// renderTemplates draws every character of the charset the face supports
+func renderTemplates(face font.Face, parsed *sfnt.Font, charset string) []glyphTemplate {
+	// The reference height is the ascender height, halfway between H and d as in running text
+	ref := 0.0
+	for _, r := range "Hd" {
+		bounds, _, ok := face.GlyphBounds(r)
+		if ok {
+			ref += float64(-bounds.Min.Y) / 64 / 2
+		}
+	}
+	if ref <= 0 {
+		ref = templateSize * 0.7
+	}
+
+	var templates []glyphTemplate
+	var buf sfnt.Buffer
+	seen := map[rune]bool{}
+	for _, r := range charset {
+		if seen[r] || unicode.IsSpace(r) {
+			continue
+		}
+		seen[r] = true
+
+		// Characters missing from the font would render as .notdef
+		if index, err := parsed.GlyphIndex(&buf, r); err != nil || index == 0 {
+			continue
+		}
+		bin, box, baseline, ok := renderGlyph(face, r)
+		if !ok {
+			continue
+		}
+		features := extractFeatures(box, float64(baseline), ref, func(x, y int) bool {
+			return bin.ink[y*bin.width+x]
+		})
+		templates = append(templates, glyphTemplate{char: r, features: features})
+	}
+
+	// Most sans-serif fonts draw l as a plain stem, unlike the Go fonts: add that form with
+	// the height of the font's l and the stroke width of its |,
+	if seen['l'] {
+		_, stem, _, okStem := renderGlyph(face, '|')
+		_, l, baseline, okL := renderGlyph(face, 'l')
+		if okStem && okL {
+			box := image.Rect(0, l.Min.Y, stem.Dx(), baseline)
+			features := extractFeatures(box, float64(baseline), ref, func(x, y int) bool { return true })
+			templates = append(templates, glyphTemplate{char: 'l', features: features})
+		}
+	}
+
+	// and a round period where the Go fonts have a square one
+	if seen['.'] {
+		if _, dot, baseline, ok := renderGlyph(face, '.'); ok {
+			radius := float64(dot.Dx()) / 2
+			cx, cy := float64(dot.Min.X)+radius, float64(dot.Min.Y)+radius
+			features := extractFeatures(dot, float64(baseline), ref, func(x, y int) bool {
+				dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+				return dx*dx+dy*dy <= radius*radius
+			})
+			templates = append(templates, glyphTemplate{char: '.', features: features})
+		}
+	}
+
+	// Likewise their 1 has no foot serif: keep only the stem in the rows of the foot
+	if seen['1'] {
+		if bin, box, baseline, ok := renderGlyph(face, '1'); ok {
+			row := func(y int) (int, int) {
+				left, right := -1, -1
+				for x := box.Min.X; x < box.Max.X; x++ {
+					if bin.ink[y*bin.width+x] {
+						if left < 0 {
+							left = x
+						}
+						right = x + 1
+					}
+				}
+				return left, right
+			}
+			stemLeft, stemRight := row((box.Min.Y + box.Max.Y) / 2)
+			foot := box.Max.Y
+			for y := box.Max.Y - 1; y > box.Min.Y; y-- {
+				left, right := row(y)
+				if right-left <= (stemRight-stemLeft)*3/2 {
+					break
+				}
+				foot = y
+			}
+			if foot < box.Max.Y {
+				ink := func(x, y int) bool {
+					return bin.ink[y*bin.width+x] && (y < foot || (x >= stemLeft && x < stemRight))
+				}
+				trimmed := image.Rect(box.Min.X, box.Min.Y, box.Max.X, box.Max.Y)
+				trimmed.Min.X, trimmed.Max.X = box.Max.X, box.Min.X
+				for y := box.Min.Y; y < box.Max.Y; y++ {
+					for x := box.Min.X; x < box.Max.X; x++ {
+						if ink(x, y) {
+							trimmed.Min.X, trimmed.Max.X = min(trimmed.Min.X, x), max(trimmed.Max.X, x+1)
+						}
+					}
+				}
+				features := extractFeatures(trimmed, float64(baseline), ref, ink)
+				templates = append(templates, glyphTemplate{char: '1', features: features})
+			}
+		}
+	}
+	return templates
+}
+
+// renderGlyph draws a character on its own and returns the ink, its bounds and the baseline
+func renderGlyph(face font.Face, r rune) (*bitmap, image.Rectangle, int, bool) {
+	bounds, _, ok := face.GlyphBounds(r)
+	if !ok || bounds.Max.X <= bounds.Min.X || bounds.Max.Y <= bounds.Min.Y {
+		return nil, image.Rectangle{}, 0, false
+	}
+
+	const pad = 4
+	width := bounds.Max.X.Ceil() - bounds.Min.X.Floor() + 2*pad
+	height := bounds.Max.Y.Ceil() - bounds.Min.Y.Floor() + 2*pad
+	dst := image.NewAlpha(image.Rect(0, 0, width, height))
+	baseline := pad - bounds.Min.Y.Floor()
+	drawer := font.Drawer{
+		Dst:  dst,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.P(pad-bounds.Min.X.Floor(), baseline),
+	}
+	drawer.DrawString(string(r))
+
+	bin := &bitmap{width: width, height: height, ink: make([]bool, width*height)}
+	for i, alpha := range dst.Pix {
+		bin.ink[i] = alpha >= 128
+	}
+	box := bin.inkBounds()
+	return bin, box, baseline, !box.Empty()
+}
+
+// bitmap is a binarized image where true marks ink
+type bitmap struct {
+	width  int
+	height int
+	ink    []bool
+}
+
+// inkBounds returns the bounding box of the ink
+func (b *bitmap) inkBounds() image.Rectangle {
+	box := image.Rectangle{}
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			if b.ink[y*b.width+x] {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+// binarize converts an image to ink and paper, with Otsu's threshold unless one is given.
+// Light text on a dark background is inverted when most of the image is dark.
+func binarize(img image.Image, options OCROptions) *bitmap {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	gray := make([]uint8, width*height)
+	var histogram [256]int
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			// Transparent pixels are paper
+			lum := (299*r + 587*g + 114*b) / 1000
+			lum = lum*a/0xFFFF + (0xFFFF - a)
+			value := uint8(lum >> 8)
+			gray[y*width+x] = value
+			histogram[value]++
+		}
+	}
+
+	threshold := options.Threshold
+	if threshold == 0 {
+		threshold = otsuThreshold(histogram, width*height)
+	}
+
+	dark := 0
+	for value := 0; value < threshold; value++ {
+		dark += histogram[value]
+	}
+	invert := dark*2 > width*height
+	if options.Invert != nil {
+		invert = *options.Invert
+	}
+
+	bin := &bitmap{width: width, height: height, ink: make([]bool, width*height)}
+	for i, value := range gray {
+		bin.ink[i] = (int(value) < threshold) != invert
+	}
+	return bin
+}
+
+// otsuThreshold picks the gray level that best separates ink from paper
+func otsuThreshold(histogram [256]int, total int) int {
+	var sum float64
+	for value, count := range histogram {
+		sum += float64(value * count)
+	}
+
+	var sumBackground, best float64
+	var weightBackground int
+	threshold := 128
+	for value, count := range histogram {
+		weightBackground += count
+		if weightBackground == 0 {
+			continue
+		}
+		weightForeground := total - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+		sumBackground += float64(value * count)
+		meanBackground := sumBackground / float64(weightBackground)
+		meanForeground := (sum - sumBackground) / float64(weightForeground)
+		between := float64(weightBackground) * float64(weightForeground) * (meanBackground - meanForeground) * (meanBackground - meanForeground)
+		if between > best {
+			best = between
+			threshold = value + 1
+		}
+	}
+	return threshold
+}
+
+// component is a connected group of ink pixels
+type component struct {
+	box    image.Rectangle
+	pixels int
+}
+
+// glyph is one or more components read as a single character
+type glyph struct {
+	box        image.Rectangle
+	components []int
+	// clip limits the glyph to part of its components when touching characters are split
+	clip       image.Rectangle
+	candidates []candidate
+}
+
+// ocrLine is a recognized line of text
+type ocrLine struct {
+	box      image.Rectangle
+	baseline int
+	ref      float64
+	words    []ocrWord
+}
+
+// ocrWord is a recognized word
+type ocrWord struct {
+	text       string
+	confidence float64
+	box        image.Rectangle
+}
+
+// page holds the connected components of a binarized image
+type page struct {
+	bin        *bitmap
+	labels     []int32
+	components []component
+}
+
+// recognize segments the image into lines, glyphs and words and reads them
+func recognize(bin *bitmap, lang *language) []ocrLine {
+	p := labelComponents(bin)
+
+	// Ignore speckles, smaller than the dots of small text, and rules, borders or pictures
+	// much taller than the text
+	var heights []int
+	for _, c := range p.components {
+		if c.pixels >= 2 {
+			heights = append(heights, c.box.Dy())
+		}
+	}
+	if len(heights) == 0 {
+		return nil
+	}
+	textHeight := float64(median(heights))
+	speckle := int(math.Max(2, textHeight*textHeight*0.01))
+	var kept []int
+	for i, c := range p.components {
+		if c.pixels < speckle || float64(c.box.Dy()) > textHeight*6 || float64(c.box.Dx()) > textHeight*12 {
+			continue
+		}
+		kept = append(kept, i)
+	}
+
+	var layouts []*lineLayout
+	for _, band := range lineBands(p, kept, textHeight) {
+		for _, segment := range splitColumns(p, band, textHeight) {
+			layouts = append(layouts, p.layoutLine(segment))
+		}
+	}
+	if len(layouts) == 0 {
+		return nil
+	}
+
+	// Lines without ascenders only reach the x-height; measuring them with the page's
+	// reference height keeps pairs such as o/O and c/C apart
+	var refs []float64
+	for _, layout := range layouts {
+		refs = append(refs, layout.line.ref)
+	}
+	sort.Float64s(refs)
+	pageRef := refs[len(refs)/2]
+
+	var lines []ocrLine
+	for _, layout := range layouts {
+		if layout.line.ref < pageRef*0.8 && layout.line.ref > pageRef*0.4 {
+			layout.line.ref = pageRef
+		}
+		lines = append(lines, p.readLine(layout, lang))
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		a, b := lines[i].box, lines[j].box
+		if a.Max.Y <= b.Min.Y || b.Max.Y <= a.Min.Y {
+			return a.Min.Y < b.Min.Y
+		}
+		return a.Min.X < b.Min.X
+	})
+
+	var result []ocrLine
+	for _, line := range lines {
+		if len(line.words) > 0 {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// labelComponents finds the 8-connected components of the ink
+func labelComponents(bin *bitmap) *page {
+	p := &page{bin: bin, labels: make([]int32, len(bin.ink))}
+	for i := range p.labels {
+		p.labels[i] = -1
+	}
+
+	var stack []int
+	for start, ink := range bin.ink {
+		if !ink || p.labels[start] >= 0 {
+			continue
+		}
+		id := int32(len(p.components))
+		c := component{box: image.Rect(start%bin.width, start/bin.width, start%bin.width+1, start/bin.width+1)}
+		p.labels[start] = id
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%bin.width, i/bin.width
+			c.pixels++
+			c.box = c.box.Union(image.Rect(x, y, x+1, y+1))
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= bin.width || ny >= bin.height {
+						continue
+					}
+					n := ny*bin.width + nx
+					if bin.ink[n] && p.labels[n] < 0 {
+						p.labels[n] = id
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		p.components = append(p.components, c)
+	}
+	return p
+}
+
+// lineBands groups components into text lines. Bands come from the vertical extent of
+// letter-sized components; dots, accents and punctuation join the nearest band.
+func lineBands(p *page, kept []int, textHeight float64) [][]int {
+	type band struct{ top, bottom int }
+	var bands []band
+	coverage := make([]int, p.bin.height+1)
+	for _, i := range kept {
+		box := p.components[i].box
+		if float64(box.Dy()) < textHeight*0.5 {
+			continue
+		}
+		coverage[box.Min.Y]++
+		coverage[box.Max.Y]--
+	}
+	depth := 0
+	for y := 0; y <= p.bin.height; y++ {
+		wasInside := depth > 0
+		depth += coverage[y]
+		switch {
+		case depth > 0 && !wasInside:
+			bands = append(bands, band{top: y})
+		case depth == 0 && wasInside:
+			bands[len(bands)-1].bottom = y
+		}
+	}
+	if len(bands) == 0 {
+		return nil
+	}
+
+	groups := make([][]int, len(bands))
+	for _, i := range kept {
+		box := p.components[i].box
+		center := (box.Min.Y + box.Max.Y) / 2
+		best, bestDistance := 0, math.MaxInt
+		for b, band := range bands {
+			distance := 0
+			switch {
+			case center < band.top:
+				distance = band.top - center
+			case center >= band.bottom:
+				distance = center - band.bottom + 1
+			}
+			// Marks between two lines belong to the line below (accents) unless they touch the one above
+			if distance < bestDistance || (distance == bestDistance && center < band.top) {
+				best, bestDistance = b, distance
+			}
+		}
+		groups[best] = append(groups[best], i)
+	}
+	return groups
+}
+
+// splitColumns cuts a line band where the horizontal gap is wide enough to separate columns
+func splitColumns(p *page, band []int, textHeight float64) [][]int {
+	if len(band) == 0 {
+		return nil
+	}
+	sort.Slice(band, func(i, j int) bool {
+		return p.components[band[i]].box.Min.X < p.components[band[j]].box.Min.X
+	})
+
+	var segments [][]int
+	current := []int{band[0]}
+	right := p.components[band[0]].box.Max.X
+	for _, i := range band[1:] {
+		box := p.components[i].box
+		if float64(box.Min.X-right) > textHeight*2.5 {
+			segments = append(segments, current)
+			current = nil
+		}
+		current = append(current, i)
+		if box.Max.X > right {
+			right = box.Max.X
+		}
+	}
+	return append(segments, current)
+}
+
+// lineLayout is a line cut into glyphs, before recognition
+type lineLayout struct {
+	line   ocrLine
+	glyphs []*glyph
+}
+
+// layoutLine cuts a line into glyphs and measures its baseline and reference height
+func (p *page) layoutLine(members []int) *lineLayout {
+	// Components stacked vertically (i and its dot, accents, colons) or nested horizontally
+	// (the circles of %) form one glyph; kerned neighbors only overlap partially side by side
+	var glyphs []*glyph
+	for _, i := range members {
+		box := p.components[i].box
+		if n := len(glyphs); n > 0 {
+			last := glyphs[n-1]
+			overlap := min(last.box.Max.X, box.Max.X) - max(last.box.Min.X, box.Min.X)
+			narrower := min(last.box.Dx(), box.Dx())
+			vertical := min(last.box.Max.Y, box.Max.Y) - max(last.box.Min.Y, box.Min.Y)
+			stacked := float64(vertical) <= float64(min(last.box.Dy(), box.Dy()))*0.2
+			if float64(overlap) >= float64(narrower)*0.5 && (stacked || overlap >= narrower) {
+				last.box = last.box.Union(box)
+				last.components = append(last.components, i)
+				continue
+			}
+		}
+		glyphs = append(glyphs, &glyph{box: box, components: []int{i}})
+	}
+
+	line := ocrLine{}
+	for _, g := range glyphs {
+		line.box = line.box.Union(g.box)
+	}
+
+	// The baseline is the usual bottom of letter-sized glyphs, descenders aside
+	var heights, bottoms []int
+	for _, g := range glyphs {
+		heights = append(heights, g.box.Dy())
+	}
+	letterHeight := float64(median(heights))
+	for _, g := range glyphs {
+		if float64(g.box.Dy()) >= letterHeight*0.6 {
+			bottoms = append(bottoms, g.box.Max.Y)
+		}
+	}
+	line.baseline = median(bottoms)
+
+	// The reference height is the ascender height, taken from the tall glyphs of the line
+	var tops []int
+	for _, g := range glyphs {
+		tops = append(tops, line.baseline-g.box.Min.Y)
+	}
+	sort.Ints(tops)
+	line.ref = math.Max(float64(tops[len(tops)*9/10]), 1)
+
+	return &lineLayout{line: line, glyphs: glyphs}
+}
+
+// readLine recognizes the glyphs of one line and groups them into words
+func (p *page) readLine(layout *lineLayout, lang *language) ocrLine {
+	line, glyphs := layout.line, layout.glyphs
+	for i := 0; i < len(glyphs); i++ {
+		g := glyphs[i]
+		g.clip = g.box
+		g.candidates = p.classify(g, line, lang)
+		if parts := p.splitTouching(g, line, lang, 2); len(parts) > 1 {
+			glyphs = append(glyphs[:i], append(parts, glyphs[i+1:]...)...)
+			i += len(parts) - 1
+		}
+	}
+
+	line.words = p.groupWords(glyphs, line, lang)
+	return line
+}
+
+// classify returns the closest templates of a glyph, best first, one per character
+func (p *page) classify(g *glyph, line ocrLine, lang *language) []candidate {
+	inGlyph := p.inkOf(g)
+
+	box := image.Rectangle{}
+	for y := g.clip.Min.Y; y < g.clip.Max.Y; y++ {
+		for x := g.clip.Min.X; x < g.clip.Max.X; x++ {
+			if inGlyph(x, y) {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if box.Empty() {
+		return nil
+	}
+	g.box = box
+	features := extractFeatures(box, float64(line.baseline), line.ref, inGlyph)
+
+	// Only the five best characters are kept, so templates that cannot beat the fifth are
+	// abandoned as soon as their distance exceeds it
+	const kept = 5
+	candidates := make([]candidate, 0, kept+1)
+	for _, t := range lang.templates {
+		limit := math.Inf(1)
+		if len(candidates) == kept {
+			limit = candidates[kept-1].distance
+		}
+		d := featureDistance(features, t.features, limit)
+		if d >= limit {
+			continue
+		}
+
+		found := false
+		for i := range candidates {
+			if candidates[i].char == t.char {
+				if d < candidates[i].distance {
+					candidates[i] = candidate{char: t.char, distance: d, aspect: t.features.aspect}
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			candidates = append(candidates, candidate{char: t.char, distance: d, aspect: t.features.aspect})
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].distance == candidates[j].distance {
+				return candidates[i].char < candidates[j].char
+			}
+			return candidates[i].distance < candidates[j].distance
+		})
+		if len(candidates) > kept {
+			candidates = candidates[:kept]
+		}
+	}
+	return candidates
+}
+
+// inkOf returns whether a pixel belongs to a glyph, within its clip rectangle
+func (p *page) inkOf(g *glyph) func(x, y int) bool {
+	return func(x, y int) bool {
+		if !image.Pt(x, y).In(g.clip) {
+			return false
+		}
+		label := int(p.labels[y*p.bin.width+x])
+		for _, i := range g.components {
+			if i == label {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// gap returns the horizontal distance between two neighboring glyphs. When their boxes
+// overlap (the tail of j under the previous letter), the narrowest distance between their
+// ink on a common row is used instead.
+func (p *page) gap(a, b *glyph) int {
+	gap := b.box.Min.X - a.box.Max.X
+	if gap >= 0 {
+		return gap
+	}
+	inA, inB := p.inkOf(a), p.inkOf(b)
+	found := false
+	for y := max(a.box.Min.Y, b.box.Min.Y); y < min(a.box.Max.Y, b.box.Max.Y); y++ {
+		right := -1
+		for x := a.box.Max.X - 1; x >= a.box.Min.X; x-- {
+			if inA(x, y) {
+				right = x
+				break
+			}
+		}
+		left := -1
+		for x := b.box.Min.X; x < b.box.Max.X; x++ {
+			if inB(x, y) {
+				left = x
+				break
+			}
+		}
+		if right < 0 || left < 0 {
+			continue
+		}
+		if d := left - right - 1; !found || d < gap {
+			gap, found = d, true
+		}
+	}
+	return gap
+}
+
+// splitTouching cuts a poorly recognized wide glyph at its thinnest columns when the
+// pieces read better, which separates characters touching after binarization
+func (p *page) splitTouching(g *glyph, line ocrLine, lang *language, depth int) []*glyph {
+	if depth == 0 || len(g.candidates) == 0 || confidence(g.candidates[0].distance) >= 75 ||
+		float64(g.box.Dx()) < line.ref*0.6 ||
+		float64(g.box.Dx())/float64(g.box.Dy()) < g.candidates[0].aspect*1.25 {
+		return nil
+	}
+
+	inGlyph := p.inkOf(g)
+	profile := make([]int, g.box.Dx())
+	for y := g.box.Min.Y; y < g.box.Max.Y; y++ {
+		for x := g.box.Min.X; x < g.box.Max.X; x++ {
+			if inGlyph(x, y) {
+				profile[x-g.box.Min.X]++
+			}
+		}
+	}
+
+	margin := int(line.ref * 0.2)
+	var cuts []int
+	for x := margin; x < len(profile)-margin; x++ {
+		cuts = append(cuts, x)
+	}
+	sort.SliceStable(cuts, func(i, j int) bool { return profile[cuts[i]] < profile[cuts[j]] })
+	if len(cuts) > 3 {
+		cuts = cuts[:3]
+	}
+
+	var best []*glyph
+	bestScore := g.candidates[0].distance
+	for _, cut := range cuts {
+		x := g.box.Min.X + cut
+		left := &glyph{components: g.components, clip: image.Rect(g.box.Min.X, g.box.Min.Y, x, g.box.Max.Y)}
+		right := &glyph{components: g.components, clip: image.Rect(x, g.box.Min.Y, g.box.Max.X, g.box.Max.Y)}
+		left.candidates = p.classify(left, line, lang)
+		right.candidates = p.classify(right, line, lang)
+		if len(left.candidates) == 0 || len(right.candidates) == 0 {
+			continue
+		}
+		score := math.Max(left.candidates[0].distance, right.candidates[0].distance)
+		if score < bestScore*0.7 {
+			parts := []*glyph{}
+			for _, piece := range []*glyph{left, right} {
+				if sub := p.splitTouching(piece, line, lang, depth-1); len(sub) > 1 {
+					parts = append(parts, sub...)
+				} else {
+					parts = append(parts, piece)
+				}
+			}
+			best, bestScore = parts, score
+		}
+	}
+	return best
+}
+
+// groupWords splits a line at the gaps wider than the spacing between letters
+func (p *page) groupWords(glyphs []*glyph, line ocrLine, lang *language) []ocrWord {
+	var kept []*glyph
+	for _, g := range glyphs {
+		if len(g.candidates) > 0 {
+			kept = append(kept, g)
+		}
+	}
+	glyphs = kept
+
+	gaps := make([]int, len(glyphs))
+	for i := 1; i < len(glyphs); i++ {
+		gaps[i] = p.gap(glyphs[i-1], glyphs[i])
+	}
+	spacing := wordSpacing(gaps[min(1, len(gaps)):], line.ref)
+
+	var words []ocrWord
+	var current []*glyph
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, readWord(current, line, lang))
+		}
+		current = nil
+	}
+	for i, g := range glyphs {
+		// Digits have the same advance, so narrow ones such as 1 leave wider gaps
+		limit := spacing
+		if i > 0 && unicode.IsDigit(g.candidates[0].char) && unicode.IsDigit(glyphs[i-1].candidates[0].char) {
+			limit *= 1.5
+		}
+		if i > 0 && float64(gaps[i]) > limit {
+			flush()
+		}
+		current = append(current, g)
+	}
+	flush()
+	return words
+}
+
+// wordSpacing returns the gap above which glyphs belong to different words. The gaps of a
+// line fall in two groups, letter spacing and word spacing, split where they differ most.
+func wordSpacing(gaps []int, ref float64) float64 {
+	sorted := append([]int(nil), gaps...)
+	sort.Ints(sorted)
+
+	best, threshold := 0.0, 0.0
+	for k := 1; k < len(sorted); k++ {
+		if sorted[k] == sorted[k-1] {
+			continue
+		}
+		low, high := mean(sorted[:k]), mean(sorted[k:])
+		between := float64(k) * float64(len(sorted)-k) * (high - low) * (high - low)
+		// Word gaps are clearly wider than letter gaps and than a sliver of the text height
+		if between > best && high >= low*2 && float64(sorted[k]) >= ref*0.2 {
+			best, threshold = between, float64(sorted[k-1]+sorted[k])/2
+		}
+	}
+	if best > 0 {
+		return threshold
+	}
+
+	// A single word, or too few gaps to tell: a space is about a third of the text height
+	spacing := ref * 0.35
+	if len(sorted) > 0 {
+		spacing = math.Max(spacing, float64(sorted[len(sorted)/2])*2)
+	}
+	return spacing
+}
+
+// mean returns the average of a list of integers
+func mean(values []int) float64 {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return float64(sum) / float64(len(values))
+}
+
+// readWord picks the characters of a word, using the context of digits and the
+// language's dictionary to settle close readings
+func readWord(glyphs []*glyph, line ocrLine, lang *language) ocrWord {
+	chars := make([]candidate, len(glyphs))
+	digits, letters := 0, 0
+	for i, g := range glyphs {
+		chars[i] = g.candidates[0]
+		switch {
+		case unicode.IsDigit(chars[i].char):
+			digits++
+		case unicode.IsLetter(chars[i].char):
+			letters++
+		}
+	}
+
+	// In numbers, l, I, O and o are usually 1 and 0
+	if digits > 0 && digits >= letters {
+		for i, g := range glyphs {
+			if !unicode.IsLetter(chars[i].char) {
+				continue
+			}
+			for _, c := range g.candidates[1:] {
+				if unicode.IsDigit(c.char) && c.distance <= chars[i].distance*1.5 {
+					chars[i] = c
+					break
+				}
+			}
+		}
+	}
+
+	// Two neighboring apostrophes are a double quote
+	var merged []candidate
+	var boxes []image.Rectangle
+	for i, c := range chars {
+		n := len(merged)
+		if n > 0 && c.char == '\'' && merged[n-1].char == '\'' &&
+			float64(glyphs[i].box.Min.X-boxes[n-1].Max.X) < line.ref*0.2 {
+			merged[n-1] = candidate{char: '"', distance: math.Max(c.distance, merged[n-1].distance)}
+			boxes[n-1] = boxes[n-1].Union(glyphs[i].box)
+			continue
+		}
+		merged = append(merged, c)
+		boxes = append(boxes, glyphs[i].box)
+	}
+	if len(merged) == len(chars) {
+		correctWord(chars, glyphs, lang)
+		merged = chars
+	}
+
+	word := ocrWord{}
+	var text strings.Builder
+	var total float64
+	for i, c := range merged {
+		text.WriteRune(c.char)
+		total += confidence(c.distance)
+		word.box = word.box.Union(boxes[i])
+	}
+	word.text = text.String()
+	word.confidence = total / float64(len(merged))
+	return word
+}
+
+// correctWord replaces a word missing from the dictionary by the closest dictionary word
+// reachable through the alternative readings of a few of its glyphs
+func correctWord(chars []candidate, glyphs []*glyph, lang *language) {
+	if len(lang.words) == 0 {
+		return
+	}
+
+	// Leading and trailing punctuation is kept as read
+	first, last := 0, len(chars)-1
+	for first <= last && !unicode.IsLetter(chars[first].char) && !unicode.IsDigit(chars[first].char) {
+		first++
+	}
+	for last >= first && !unicode.IsLetter(chars[last].char) && !unicode.IsDigit(chars[last].char) {
+		last--
+	}
+	if last-first < 1 {
+		return
+	}
+
+	var read strings.Builder
+	for _, c := range chars[first : last+1] {
+		read.WriteRune(unicode.ToLower(c.char))
+	}
+	if lang.words[read.String()] {
+		return
+	}
+
+	length := last - first + 1
+	maxChanges := max(1, length/3)
+	var best []candidate
+	bestCost := math.Inf(1)
+	for _, word := range lang.byLength[length] {
+		changes := 0
+		cost := 0.0
+		replacement := make([]candidate, length)
+		for i, r := range []rune(word) {
+			current := chars[first+i]
+			if unicode.ToLower(current.char) == r {
+				replacement[i] = current
+				continue
+			}
+			changes++
+			found := false
+			for _, c := range glyphs[first+i].candidates {
+				if unicode.ToLower(c.char) == r {
+					replacement[i] = c
+					cost += c.distance - current.distance
+					found = true
+					break
+				}
+			}
+			if !found || changes > maxChanges {
+				cost = math.Inf(1)
+				break
+			}
+		}
+		if cost < bestCost {
+			best, bestCost = replacement, cost
+		}
+	}
+	if best != nil {
+		copy(chars[first:], best)
+	}
+}
+
+// extractFeatures normalizes the glyph inside box into a size-independent description
+func extractFeatures(box image.Rectangle, baseline, ref float64, ink func(x, y int) bool) glyphFeatures {
+	var f glyphFeatures
+	mask := make([]bool, box.Dx()*box.Dy())
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			mask[(y-box.Min.Y)*box.Dx()+x-box.Min.X] = ink(x, y)
+		}
+	}
+	width, height := float64(box.Dx()), float64(box.Dy())
+	side := math.Max(width, height)
+	offsetX, offsetY := (side-width)/2, (side-height)/2
+
+	// Each cell of the square grid is sampled 4x4 times; the glyph keeps its aspect ratio
+	const samples = 4
+	var grid [featureSize * featureSize]float64
+	for cy := 0; cy < featureSize; cy++ {
+		for cx := 0; cx < featureSize; cx++ {
+			hits := 0
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					x := (float64(cx)+(float64(sx)+0.5)/samples)*side/featureSize - offsetX
+					y := (float64(cy)+(float64(sy)+0.5)/samples)*side/featureSize - offsetY
+					if x < 0 || y < 0 || x >= width || y >= height {
+						continue
+					}
+					if mask[int(y)*box.Dx()+int(x)] {
+						hits++
+					}
+				}
+			}
+			grid[cy*featureSize+cx] = float64(hits) / (samples * samples)
+		}
+	}
+
+	// A light blur tolerates differences of stroke weight between fonts
+	for cy := 0; cy < featureSize; cy++ {
+		for cx := 0; cx < featureSize; cx++ {
+			sum, weight := 0.0, 0.0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					x, y := cx+dx, cy+dy
+					if x < 0 || y < 0 || x >= featureSize || y >= featureSize {
+						continue
+					}
+					w := 1.0
+					if dx == 0 && dy == 0 {
+						w = 4
+					}
+					sum += grid[y*featureSize+x] * w
+					weight += w
+				}
+			}
+			f.bitmap[cy*featureSize+cx] = sum / weight
+		}
+	}
+
+	f.aspect = width / height
+	f.size = side
+	inMask := func(x, y int) bool {
+		return image.Pt(x, y).In(box) && mask[(y-box.Min.Y)*box.Dx()+x-box.Min.X]
+	}
+	f.parts = countRegions(box, inMask, true)
+	f.holes = countRegions(box.Inset(-1), func(x, y int) bool { return !inMask(x, y) }, false) - 1
+	f.top = (baseline - float64(box.Min.Y)) / ref
+	f.bottom = (baseline - float64(box.Max.Y)) / ref
+	return f
+}
+
+// featureDistance compares an observed glyph a with a template b: shape first, then proportions and
+// position on the line, which tell apart glyphs of the same shape such as , and '.
+// The comparison stops early once the distance exceeds limit.
+func featureDistance(a, b glyphFeatures, limit float64) float64 {
+	aspect := math.Log(a.aspect / b.aspect)
+	top := a.top - b.top
+	bottom := a.bottom - b.bottom
+	parts := math.Min(math.Abs(float64(a.parts-b.parts)), 2)
+	holes := math.Min(math.Abs(float64(a.holes-b.holes)), 2)
+	distance := 0.02*aspect*aspect + 0.15*top*top + 0.4*bottom*bottom + 0.01*parts + 0.02*holes
+
+	// A glyph a few pixels wide has no reliable shape: punctuation is told apart by position
+	weight := math.Min(1, math.Max(0, (a.size-2)/6)) / (featureSize * featureSize)
+	if weight == 0 {
+		return distance
+	}
+	for row := 0; row < featureSize; row++ {
+		if distance >= limit {
+			return distance
+		}
+		var shape float64
+		for i := row * featureSize; i < (row+1)*featureSize; i++ {
+			d := a.bitmap[i] - b.bitmap[i]
+			shape += d * d
+		}
+		distance += shape * weight
+	}
+	return distance
+}
+
+// countRegions counts the connected regions of the pixels of box matching in. Ink is
+// 8-connected while the paper around it is 4-connected, so that diagonal strokes close holes.
+func countRegions(box image.Rectangle, in func(x, y int) bool, diagonal bool) int {
+	width := box.Dx()
+	seen := make([]bool, width*box.Dy())
+	regions := 0
+	var stack []image.Point
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			i := (y-box.Min.Y)*width + x - box.Min.X
+			if seen[i] || !in(x, y) {
+				continue
+			}
+			regions++
+			seen[i] = true
+			stack = append(stack[:0], image.Pt(x, y))
+			for len(stack) > 0 {
+				pt := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						if (dx == 0 && dy == 0) || (!diagonal && dx != 0 && dy != 0) {
+							continue
+						}
+						n := image.Pt(pt.X+dx, pt.Y+dy)
+						if !n.In(box) {
+							continue
+						}
+						j := (n.Y-box.Min.Y)*width + n.X - box.Min.X
+						if !seen[j] && in(n.X, n.Y) {
+							seen[j] = true
+							stack = append(stack, n)
+						}
+					}
+				}
+			}
+		}
+	}
+	return regions
+}
+
+// confidence maps a template distance to a 0-100 score
+func confidence(distance float64) float64 {
+	return 100 * math.Exp(-distance/0.05)
+}
+
+// median returns the middle value of a list of integers
+func median(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// round1 rounds to one decimal
+func round1(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+// bboxValue returns a bounding box in image pixels, in the x0/y0/x1/y1 form of hOCR
+func bboxValue(box image.Rectangle) map[string]interface{} {
+	return map[string]interface{}{
+		"x0": box.Min.X,
+		"y0": box.Min.Y,
+		"x1": box.Max.X,
+		"y1": box.Max.Y,
+	}
+}
+
+// hocrDocument renders the recognized lines as hOCR, the format searchable PDF tools consume
+func hocrDocument(bounds image.Rectangle, lines []interface{}) string {
+	bbox := func(value interface{}) string {
+		box := value.(map[string]interface{})
+		return fmt.Sprintf("bbox %d %d %d %d", box["x0"], box["y0"], box["x1"], box["y1"])
+	}
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<meta name=\"ocr-system\" content=\"ocr-wasm\">\n")
+	b.WriteString("<meta name=\"ocr-capabilities\" content=\"ocr_page ocr_line ocrx_word\">\n</head>\n<body>\n")
+	fmt.Fprintf(&b, "<div class=\"ocr_page\" id=\"page_1\" title=\"bbox 0 0 %d %d\">\n", bounds.Dx(), bounds.Dy())
+	wordID := 0
+	for i, value := range lines {
+		line := value.(map[string]interface{})
+		fmt.Fprintf(&b, "<span class=\"ocr_line\" id=\"line_1_%d\" title=\"%s\">", i+1, bbox(line["bbox"]))
+		for j, wordValue := range line["words"].([]interface{}) {
+			word := wordValue.(map[string]interface{})
+			wordID++
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "<span class=\"ocrx_word\" id=\"word_1_%d\" title=\"%s; x_wconf %d\">%s</span>",
+				wordID, bbox(word["bbox"]), int(word["confidence"].(float64)), html.EscapeString(word["text"].(string)))
+		}
+		b.WriteString("</span>\n")
+	}
+	b.WriteString("</div>\n</body>\n</html>\n")
+	return b.String()
+}
+
+// bytesFromJS reads binary data given as base64 (optionally a data: URL), a Uint8Array or an ArrayBuffer
+func bytesFromJS(value js.Value) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		encoded := value.String()
+		if comma := strings.Index(encoded, ","); strings.HasPrefix(encoded, "data:") && comma >= 0 {
+			encoded = encoded[comma+1:]
+		}
+		return base64.StdEncoding.DecodeString(encoded)
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case !value.InstanceOf(js.Global().Get("Uint8Array")):
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or base64 string"))
+	}
+
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"ocr",
+	"word-bounding-boxes",
+	"confidence",
+	"hocr",
+	"language-packs",
+	"dictionary-correction",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"languages": len(loadedLanguages),
+	}))
+}
+
+// releaseResources - Drop the rendered templates of built-in languages (rebuilt on next use) and return freed memory
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	// Downloaded packs stay loaded since their data is not kept to rebuild them
+	dropped := 0
+	for name, lang := range loadedLanguages {
+		if lang.builtin || strings.Contains(name, "+") {
+			delete(loadedLanguages, name)
+			dropped++
+		}
+	}
+	released := map[string]interface{}{
+		"languages": dropped,
+	}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "ocr-wasm",
+		"version":         moduleVersion,
+		"description":     "Optical character recognition module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"recognizeText",
+		"loadLanguageData",
+		"getLanguages",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register OCR functions
+	js.Global().Set("recognizeText", js.FuncOf(recognizeText))
+	js.Global().Set("loadLanguageData", js.FuncOf(loadLanguageData))
+	js.Global().Set("getLanguages", js.FuncOf(getLanguages))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM OCR module ready!")
+	fmt.Println("Available functions: recognizeText, loadLanguageData, getLanguages")
+
+	// Keep the program alive
+	select {}
+}
@@ -0,0 +1,5 @@
+module password-manager-wasm
+
+go 1.21
+
+require golang.org/x/crypto v0.22.0
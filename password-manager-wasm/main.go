@@ -0,0 +1,1652 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var silentMode = false
+
+// vaultFormat and vaultVersion identify the files written by exportVault
+const (
+	vaultFormat  = "wasm-vault"
+	vaultVersion = 1
+	vaultCipher  = "aes-256-gcm"
+)
+
+// Argon2id parameters. The defaults follow the OWASP recommendation for interactive logins with
+// more memory; the bounds keep a crafted vault file from requesting unbounded work.
+const (
+	defaultKDFMemory      = 64 * 1024 // KiB
+	defaultKDFIterations  = 3
+	defaultKDFParallelism = 1
+	minKDFMemory          = 19 * 1024
+	maxKDFMemory          = 1024 * 1024
+	maxKDFIterations      = 10
+	maxKDFParallelism     = 16
+	saltSize              = 16
+	keySize               = 32
+)
+
+// Password generation bounds, matching text-wasm's generatePassword
+const (
+	defaultPasswordLength = 12
+	minPasswordLength     = 4
+	maxPasswordLength     = 128
+)
+
+// maxImportEntries bounds the entries importEntries reads in one call
+const maxImportEntries = 10000
+
+// Character classes of generated passwords
+const (
+	lowercaseChars = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars     = "0123456789"
+	symbolChars    = "!@#$%^&*()_+-=[]{}|;:,.<>?"
+	ambiguousChars = "Il1O0o|`'\""
+)
+
+// KDFParams are the Argon2id parameters stored in the vault header
+type KDFParams struct {
+	Algorithm   string `json:"algorithm"`
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+	Salt        string `json:"salt"`
+}
+
+// VaultFile is the encrypted vault as exported. Everything but Nonce and Data is
+// authenticated as additional data, so the KDF parameters cannot be swapped.
+type VaultFile struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	KDF     KDFParams `json:"kdf"`
+	Cipher  string    `json:"cipher"`
+	Nonce   string    `json:"nonce"`
+	Data    string    `json:"data"`
+}
+
+// vaultContent is the plaintext sealed in VaultFile.Data
+type vaultContent struct {
+	Name     string   `json:"name"`
+	Created  string   `json:"created"`
+	Modified string   `json:"modified"`
+	Entries  []*Entry `json:"entries"`
+}
+
+// Entry is a login stored in a vault
+type Entry struct {
+	ID              string            `json:"id"`
+	Title           string            `json:"title"`
+	Username        string            `json:"username"`
+	Password        string            `json:"password"`
+	URL             string            `json:"url"`
+	Notes           string            `json:"notes"`
+	Tags            []string          `json:"tags"`
+	Fields          map[string]string `json:"fields"`
+	Favorite        bool              `json:"favorite"`
+	Created         string            `json:"created"`
+	Modified        string            `json:"modified"`
+	PasswordChanged string            `json:"passwordChanged"`
+}
+
+// EntryInput represents the fields given to addEntry and updateEntry; nil fields are left unchanged
+type EntryInput struct {
+	Title    *string            `json:"title"`
+	Username *string            `json:"username"`
+	Password *string            `json:"password"`
+	URL      *string            `json:"url"`
+	Notes    *string            `json:"notes"`
+	Tags     *[]string          `json:"tags"`
+	Fields   *map[string]string `json:"fields"`
+	Favorite *bool              `json:"favorite"`
+	Generate *PasswordPolicy    `json:"generate"`
+}
+
+// PasswordPolicy represents the options of generatePassword. Every character class is enabled
+// by default; includeSymbols is accepted as an alias of symbols, as in text-wasm.
+type PasswordPolicy struct {
+	Length           int    `json:"length"`
+	Lowercase        *bool  `json:"lowercase"`
+	Uppercase        *bool  `json:"uppercase"`
+	Digits           *bool  `json:"digits"`
+	Symbols          *bool  `json:"symbols"`
+	IncludeSymbols   *bool  `json:"includeSymbols"`
+	SymbolSet        string `json:"symbolSet"`
+	ExcludeAmbiguous bool   `json:"excludeAmbiguous"`
+	Exclude          string `json:"exclude"`
+	RequireEach      *bool  `json:"requireEach"`
+}
+
+// vault is an unlocked vault: its content and the key derived from the master password
+type vault struct {
+	key     []byte
+	kdf     KDFParams
+	content vaultContent
+	dirty   bool
+}
+
+// openVaults holds the unlocked vaults by handle until lockVault
+var openVaults = map[string]*vault{}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires at least %d arguments (%s)":                               "%s requiert au moins %d arguments (%s)",
+	"%s requires at least 1 argument (%s)":                                 "%s requiert au moins 1 argument (%s)",
+	"Failed to derive key: %v":                                             "Échec de la dérivation de la clé : %v",
+	"Failed to encrypt vault: %v":                                          "Échec du chiffrement du coffre : %v",
+	"Failed to export entries: %v":                                         "Échec de l'export des entrées : %v",
+	"Failed to import entries: %v":                                         "Échec de l'import des entrées : %v",
+	"Failed to unlock vault: %v":                                           "Échec du déverrouillage du coffre : %v",
+	"Invalid entry format: %v":                                             "Format d'entrée invalide : %v",
+	"Invalid entry: %v":                                                    "Entrée invalide : %v",
+	"Invalid import data: %v":                                              "Données d'import invalides : %v",
+	"Invalid master password: %v":                                          "Mot de passe maître invalide : %v",
+	"Invalid options: %v":                                                  "Options invalides : %v",
+	"Invalid password policy: %v":                                          "Politique de mot de passe invalide : %v",
+	"Invalid password: %v":                                                 "Mot de passe invalide : %v",
+	"Invalid vault data: %v":                                               "Données de coffre invalides : %v",
+	"Unknown entry %q":                                                     "Entrée inconnue %q",
+	"Unknown vault %q (it may already be locked)":                          "Coffre inconnu %q (il est peut-être déjà verrouillé)",
+	"Unsupported locale %q (available: %s)":                                "Langue %q non prise en charge (disponibles : %s)",
+	"corrupted vault content: %v":                                          "contenu du coffre corrompu : %v",
+	"custom field names must not be empty":                                 "les noms de champs personnalisés ne doivent pas être vides",
+	"entry %d: %v":                                                         "entrée %d : %v",
+	"expected a Uint8Array, ArrayBuffer or string":                         "Uint8Array, ArrayBuffer ou chaîne attendu",
+	"expected a string or an {vaultId, entryId} reference":                 "chaîne ou référence {vaultId, entryId} attendue",
+	"invalid KDF salt":                                                     "sel KDF invalide",
+	"invalid base64 in nonce or data":                                      "base64 invalide dans nonce ou data",
+	"invalid nonce size":                                                   "taille de nonce invalide",
+	"kdf iterations must be between 1 and %d":                              "les itérations kdf doivent être comprises entre 1 et %d",
+	"kdf memory must be between %d and %d KiB":                             "la mémoire kdf doit être comprise entre %d et %d Kio",
+	"kdf parallelism must be between 1 and %d":                             "le parallélisme kdf doit être compris entre 1 et %d",
+	"length %d is too short to include every character class":              "la longueur %d est trop courte pour inclure chaque classe de caractères",
+	"length must be between %d and %d":                                     "la longueur doit être comprise entre %d et %d",
+	"not a vault file (format %q)":                                         "ce n'est pas un fichier de coffre (format %q)",
+	"password and generate cannot be combined":                             "password et generate ne peuvent pas être combinés",
+	"setLocale requires exactly 1 argument (locale)":                       "setLocale requiert exactement 1 argument (locale)",
+	"the CSV file is empty":                                                "le fichier CSV est vide",
+	"the CSV header must name a password column and a title or url column": "l'en-tête CSV doit nommer une colonne password et une colonne title ou url",
+	"the current master password is wrong":                                 "le mot de passe maître actuel est incorrect",
+	"the master password must not be empty":                                "le mot de passe maître ne doit pas être vide",
+	"the policy leaves no characters to choose from":                       "la politique ne laisse aucun caractère disponible",
+	"title or url is required":                                             "title ou url est obligatoire",
+	"too many entries (at most %d)":                                        "trop d'entrées (au plus %d)",
+	"unsupported KDF %q (only argon2id is supported)":                      "KDF %q non pris en charge (seul argon2id l'est)",
+	"unsupported cipher %q":                                                "chiffrement %q non pris en charge",
+	"unsupported format %q (expected json or csv)":                         "format %q non pris en charge (json ou csv attendu)",
+	"unsupported vault version %d":                                         "version de coffre %d non prise en charge",
+	"wrong master password or corrupted vault":                             "mot de passe maître incorrect ou coffre corrompu",
+}
+
+// createVault - Create an empty vault protected by a master password and open it
+func createVault(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "createVault", "masterPassword"),
+		})
+	}
+
+	var options struct {
+		Name string    `json:"name"`
+		KDF  KDFParams `json:"kdf"`
+	}
+	if len(args) > 1 && args[1].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	password := args[0].String()
+	if password == "" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid master password: %v", localize("the master password must not be empty")),
+		})
+	}
+
+	kdf, err := newKDFParams(options.KDF)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	key, err := deriveKey(password, kdf)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to derive key: %v", err),
+		})
+	}
+
+	now := timestamp()
+	v := &vault{
+		key:     key,
+		kdf:     kdf,
+		content: vaultContent{Name: options.Name, Created: now, Modified: now, Entries: []*Entry{}},
+		dirty:   true,
+	}
+	id := newID()
+	openVaults[id] = v
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Created vault %s\n", id)
+	}
+
+	return js.ValueOf(v.info(id))
+}
+
+// unlockVault - Decrypt a vault exported by exportVault with its master password and open it
+func unlockVault(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 || args[1].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least %d arguments (%s)", "unlockVault", 2, "vault, masterPassword"),
+		})
+	}
+
+	raw, err := rawFromJS(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid vault data: %v", err),
+		})
+	}
+
+	var file VaultFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid vault data: %v", err),
+		})
+	}
+
+	v, err := openVaultFile(file, args[1].String())
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to unlock vault: %v", err),
+		})
+	}
+	id := newID()
+	openVaults[id] = v
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Unlocked vault %s (%d entries)\n", id, len(v.content.Entries))
+	}
+
+	return js.ValueOf(v.info(id))
+}
+
+// lockVault - Close an open vault, wiping its key and entries from memory
+func lockVault(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "lockVault", "vaultId"),
+		})
+	}
+
+	id := args[0].String()
+	v, ok := openVaults[id]
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown vault %q (it may already be locked)", id),
+		})
+	}
+	unsaved := v.dirty
+	v.wipe()
+	delete(openVaults, id)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Locked vault %s\n", id)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"locked":         true,
+		"vaultId":        id,
+		"unsavedChanges": unsaved,
+	})
+}
+
+// exportVault - Encrypt an open vault into the JSON file format read by unlockVault
+func exportVault(this js.Value, args []js.Value) interface{} {
+	v, id, failure := vaultArgument(args, "exportVault", 1, "vaultId")
+	if failure != nil {
+		return failure
+	}
+
+	file, err := v.seal()
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to encrypt vault: %v", err),
+		})
+	}
+	data, _ := json.MarshalIndent(file, "", "  ")
+	v.dirty = false
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Exported vault %s (%d bytes)\n", id, len(data))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"vault":    string(data),
+		"size":     len(data),
+		"entries":  len(v.content.Entries),
+		"filename": vaultFilename(v.content.Name),
+		"format":   "application/json",
+	})
+}
+
+// changeMasterPassword - Re-derive the key of an open vault from a new master password and salt
+func changeMasterPassword(this js.Value, args []js.Value) interface{} {
+	v, id, failure := vaultArgument(args, "changeMasterPassword", 3, "vaultId, currentPassword, newPassword")
+	if failure != nil {
+		return failure
+	}
+
+	current, err := deriveKey(args[1].String(), v.kdf)
+	if err != nil || !bytes.Equal(current, v.key) {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid master password: %v", localize("the current master password is wrong")),
+		})
+	}
+	if args[2].String() == "" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid master password: %v", localize("the master password must not be empty")),
+		})
+	}
+
+	var params KDFParams
+	if len(args) > 3 && args[3].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[3])), &params); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	} else {
+		params = KDFParams{Memory: v.kdf.Memory, Iterations: v.kdf.Iterations, Parallelism: v.kdf.Parallelism}
+	}
+	kdf, err := newKDFParams(params)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+	key, err := deriveKey(args[2].String(), kdf)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to derive key: %v", err),
+		})
+	}
+
+	wipeBytes(v.key)
+	v.key, v.kdf = key, kdf
+	v.touch()
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Changed master password of vault %s\n", id)
+	}
+
+	return js.ValueOf(v.info(id))
+}
+
+// addEntry - Add a login to an open vault, optionally generating its password
+func addEntry(this js.Value, args []js.Value) interface{} {
+	v, _, failure := vaultArgument(args, "addEntry", 2, "vaultId, entry")
+	if failure != nil {
+		return failure
+	}
+
+	var in EntryInput
+	if err := json.Unmarshal([]byte(jsonArgument(args[1])), &in); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid entry format: %v", err),
+		})
+	}
+
+	now := timestamp()
+	entry := &Entry{ID: newID(), Tags: []string{}, Fields: map[string]string{}, Created: now}
+	if err := entry.apply(in, now); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid entry: %v", err),
+		})
+	}
+	v.content.Entries = append(v.content.Entries, entry)
+	v.touch()
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Added entry %s\n", entry.ID)
+	}
+
+	return js.ValueOf(entry.summary())
+}
+
+// updateEntry - Change the given fields of an entry; a new password is timestamped in passwordChanged
+func updateEntry(this js.Value, args []js.Value) interface{} {
+	v, _, failure := vaultArgument(args, "updateEntry", 3, "vaultId, entryId, changes")
+	if failure != nil {
+		return failure
+	}
+
+	entry, failure := v.entryArgument(args[1])
+	if failure != nil {
+		return failure
+	}
+
+	var in EntryInput
+	if err := json.Unmarshal([]byte(jsonArgument(args[2])), &in); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid entry format: %v", err),
+		})
+	}
+
+	// Changes are applied to a copy so a rejected update leaves the entry untouched
+	updated := entry.clone()
+	if err := updated.apply(in, timestamp()); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid entry: %v", err),
+		})
+	}
+	*entry = *updated
+	v.touch()
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Updated entry %s\n", entry.ID)
+	}
+
+	return js.ValueOf(entry.summary())
+}
+
+// deleteEntry - Remove an entry from an open vault
+func deleteEntry(this js.Value, args []js.Value) interface{} {
+	v, _, failure := vaultArgument(args, "deleteEntry", 2, "vaultId, entryId")
+	if failure != nil {
+		return failure
+	}
+
+	entry, failure := v.entryArgument(args[1])
+	if failure != nil {
+		return failure
+	}
+	for i, e := range v.content.Entries {
+		if e == entry {
+			v.content.Entries = append(v.content.Entries[:i], v.content.Entries[i+1:]...)
+			break
+		}
+	}
+	entry.wipe()
+	v.touch()
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Deleted entry %s\n", entry.ID)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"deleted": true,
+		"id":      entry.ID,
+		"entries": len(v.content.Entries),
+	})
+}
+
+// getEntry - Get an entry with its password, notes and custom fields
+func getEntry(this js.Value, args []js.Value) interface{} {
+	v, _, failure := vaultArgument(args, "getEntry", 2, "vaultId, entryId")
+	if failure != nil {
+		return failure
+	}
+
+	entry, failure := v.entryArgument(args[1])
+	if failure != nil {
+		return failure
+	}
+
+	result := entry.summary()
+	result["password"] = entry.Password
+	result["notes"] = entry.Notes
+	fields := map[string]interface{}{}
+	for name, value := range entry.Fields {
+		fields[name] = value
+	}
+	result["fields"] = fields
+
+	return js.ValueOf(result)
+}
+
+// listEntries - List the entries of an open vault without their secrets, optionally filtered
+func listEntries(this js.Value, args []js.Value) interface{} {
+	v, _, failure := vaultArgument(args, "listEntries", 1, "vaultId")
+	if failure != nil {
+		return failure
+	}
+
+	var filter struct {
+		Query    string `json:"query"`
+		Tag      string `json:"tag"`
+		Favorite bool   `json:"favorite"`
+	}
+	if len(args) > 1 && args[1].Type() == js.TypeString {
+		filter.Query = args[1].String()
+	} else if len(args) > 1 && args[1].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &filter); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+	entries := []interface{}{}
+	for _, e := range v.sortedEntries() {
+		if filter.Favorite && !e.Favorite || filter.Tag != "" && !containsFold(e.Tags, filter.Tag) {
+			continue
+		}
+		if query != "" && !e.matches(query) {
+			continue
+		}
+		entries = append(entries, e.summary())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+		"total":   len(v.content.Entries),
+	})
+}
+
+// importEntries - Add entries from a JSON export or a CSV file (Bitwarden, Chrome, Firefox, 1Password or KeePass columns)
+func importEntries(this js.Value, args []js.Value) interface{} {
+	v, _, failure := vaultArgument(args, "importEntries", 2, "vaultId, data")
+	if failure != nil {
+		return failure
+	}
+
+	raw, err := rawFromJS(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid import data: %v", err),
+		})
+	}
+
+	format := ""
+	if len(args) > 2 && args[2].Type() == js.TypeString {
+		format = strings.ToLower(args[2].String())
+	}
+	if format == "" {
+		format = "csv"
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+			format = "json"
+		}
+	}
+
+	var inputs []EntryInput
+	switch format {
+	case "json":
+		inputs, err = jsonImport(raw)
+	case "csv":
+		inputs, err = csvImport(raw)
+	default:
+		err = errors.New(localize("unsupported format %q (expected json or csv)", format))
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to import entries: %v", err),
+		})
+	}
+
+	// The import is all or nothing: entries are checked before any is added
+	now := timestamp()
+	added := make([]*Entry, 0, len(inputs))
+	ids := []interface{}{}
+	for i, in := range inputs {
+		entry := &Entry{ID: newID(), Tags: []string{}, Fields: map[string]string{}, Created: now}
+		if err := entry.apply(in, now); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to import entries: %v", localize("entry %d: %v", i+1, err)),
+			})
+		}
+		added = append(added, entry)
+		ids = append(ids, entry.ID)
+	}
+	v.content.Entries = append(v.content.Entries, added...)
+	if len(added) > 0 {
+		v.touch()
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Imported %d entries (%s)\n", len(added), format)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"imported": len(added),
+		"ids":      ids,
+		"entries":  len(v.content.Entries),
+		"format":   format,
+	})
+}
+
+// exportEntries - Export the entries of an open vault in plain text (json or csv) for migration
+func exportEntries(this js.Value, args []js.Value) interface{} {
+	v, _, failure := vaultArgument(args, "exportEntries", 1, "vaultId")
+	if failure != nil {
+		return failure
+	}
+
+	format := "json"
+	if len(args) > 1 && args[1].Type() == js.TypeString {
+		format = strings.ToLower(args[1].String())
+	}
+
+	var data []byte
+	switch format {
+	case "json":
+		data, _ = json.MarshalIndent(v.sortedEntries(), "", "  ")
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"title", "url", "username", "password", "notes", "tags", "favorite"})
+		for _, e := range v.sortedEntries() {
+			w.Write([]string{e.Title, e.URL, e.Username, e.Password, e.Notes, strings.Join(e.Tags, ","), strconv.FormatBool(e.Favorite)})
+		}
+		w.Flush()
+		data = buf.Bytes()
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to export entries: %v", localize("unsupported format %q (expected json or csv)", format)),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Exported %d entries in plain text (%s)\n", len(v.content.Entries), format)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"data":     string(data),
+		"size":     len(data),
+		"entries":  len(v.content.Entries),
+		"format":   format,
+		"filename": strings.TrimSuffix(vaultFilename(v.content.Name), ".vault.json") + "-export." + format,
+	})
+}
+
+// generatePassword - Generate a random password from a policy, or (length, includeSymbols) as in text-wasm
+func generatePassword(this js.Value, args []js.Value) interface{} {
+	policy := PasswordPolicy{}
+	if len(args) > 0 {
+		switch args[0].Type() {
+		case js.TypeNumber:
+			policy.Length = args[0].Int()
+			if len(args) > 1 {
+				include := args[1].Bool()
+				policy.IncludeSymbols = &include
+			}
+		case js.TypeObject, js.TypeString:
+			if err := json.Unmarshal([]byte(jsonArgument(args[0])), &policy); err != nil {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("Invalid password policy: %v", err),
+				})
+			}
+		}
+	}
+
+	password, charset, err := policy.generate()
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid password policy: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated password of length %d\n", len(password))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"password":       password,
+		"length":         utf8.RuneCountInString(password),
+		"charsetSize":    len(charset),
+		"entropyBits":    math.Round(float64(utf8.RuneCountInString(password))*math.Log2(float64(len(charset)))*10) / 10,
+		"includeSymbols": strings.ContainsAny(charset, policy.symbols()),
+	})
+}
+
+// breachCheckPrefix - Hash a password for a k-anonymity breach lookup (Have I Been Pwned range API).
+// Only the 5-character prefix is meant to leave the page; the password can be an entry reference.
+func breachCheckPrefix(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "breachCheckPrefix", "password"),
+		})
+	}
+
+	password, err := passwordArgument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid password: %v", err),
+		})
+	}
+
+	hash := breachHash(password)
+	return js.ValueOf(map[string]interface{}{
+		"prefix": hash[:5],
+		"suffix": hash[5:],
+		"url":    "https://api.pwnedpasswords.com/range/" + hash[:5],
+	})
+}
+
+// matchBreachRange - Look a password up in the response of a range query for its prefix
+func matchBreachRange(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 || args[1].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least %d arguments (%s)", "matchBreachRange", 2, "password, rangeResponse"),
+		})
+	}
+
+	password, err := passwordArgument(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid password: %v", err),
+		})
+	}
+
+	hash := breachHash(password)
+	count := 0
+	for _, line := range strings.Split(args[1].String(), "\n") {
+		suffix, occurrences, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && strings.EqualFold(suffix, hash[5:]) {
+			count, _ = strconv.Atoi(strings.TrimSpace(occurrences))
+			break
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Breach range lookup for prefix %s: %d occurrences\n", hash[:5], count)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"breached": count > 0,
+		"count":    count,
+		"prefix":   hash[:5],
+	})
+}
+
+// newKDFParams fills the defaults of the Argon2id parameters, checks their bounds and draws a new salt
+func newKDFParams(params KDFParams) (KDFParams, error) {
+	if params.Algorithm != "" && !strings.EqualFold(params.Algorithm, "argon2id") {
+		return params, errors.New(localize("unsupported KDF %q (only argon2id is supported)", params.Algorithm))
+	}
+	params.Algorithm = "argon2id"
+	if params.Memory == 0 {
+		params.Memory = defaultKDFMemory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = defaultKDFIterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = defaultKDFParallelism
+	}
+	if err := params.check(); err != nil {
+		return params, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return params, err
+	}
+	params.Salt = base64.StdEncoding.EncodeToString(salt)
+	return params, nil
+}
+
+// check validates the Argon2id parameters, from options or from a vault file
+func (p KDFParams) check() error {
+	if p.Algorithm != "argon2id" {
+		return errors.New(localize("unsupported KDF %q (only argon2id is supported)", p.Algorithm))
+	}
+	if p.Memory < minKDFMemory || p.Memory > maxKDFMemory {
+		return errors.New(localize("kdf memory must be between %d and %d KiB", minKDFMemory, maxKDFMemory))
+	}
+	if p.Iterations < 1 || p.Iterations > maxKDFIterations {
+		return errors.New(localize("kdf iterations must be between 1 and %d", maxKDFIterations))
+	}
+	if p.Parallelism < 1 || p.Parallelism > maxKDFParallelism {
+		return errors.New(localize("kdf parallelism must be between 1 and %d", maxKDFParallelism))
+	}
+	return nil
+}
+
+// deriveKey derives the vault key from the master password with Argon2id
+func deriveKey(password string, kdf KDFParams) ([]byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(kdf.Salt)
+	if err != nil || len(salt) < 8 {
+		return nil, errors.New(localize("invalid KDF salt"))
+	}
+	return argon2.IDKey([]byte(password), salt, kdf.Iterations, kdf.Memory, kdf.Parallelism, keySize), nil
+}
+
+// header returns the authenticated part of a vault file
+func (f VaultFile) header() []byte {
+	header, _ := json.Marshal(struct {
+		Format  string    `json:"format"`
+		Version int       `json:"version"`
+		KDF     KDFParams `json:"kdf"`
+		Cipher  string    `json:"cipher"`
+	}{f.Format, f.Version, f.KDF, f.Cipher})
+	return header
+}
+
+// seal encrypts the vault content with a fresh nonce
+func (v *vault) seal() (VaultFile, error) {
+	file := VaultFile{Format: vaultFormat, Version: vaultVersion, KDF: v.kdf, Cipher: vaultCipher}
+	plaintext, err := json.Marshal(v.content)
+	if err != nil {
+		return file, err
+	}
+	defer wipeBytes(plaintext)
+
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return file, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return file, err
+	}
+	file.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	file.Data = base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, file.header()))
+	return file, nil
+}
+
+// openVaultFile checks a vault file, derives its key and decrypts its content
+func openVaultFile(file VaultFile, password string) (*vault, error) {
+	if file.Format != vaultFormat {
+		return nil, errors.New(localize("not a vault file (format %q)", file.Format))
+	}
+	if file.Version != vaultVersion {
+		return nil, errors.New(localize("unsupported vault version %d", file.Version))
+	}
+	if file.Cipher != vaultCipher {
+		return nil, errors.New(localize("unsupported cipher %q", file.Cipher))
+	}
+	if err := file.KDF.check(); err != nil {
+		return nil, err
+	}
+	nonce, err1 := base64.StdEncoding.DecodeString(file.Nonce)
+	sealed, err2 := base64.StdEncoding.DecodeString(file.Data)
+	if err1 != nil || err2 != nil {
+		return nil, errors.New(localize("invalid base64 in nonce or data"))
+	}
+
+	key, err := deriveKey(password, file.KDF)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New(localize("invalid nonce size"))
+	}
+
+	// A wrong password and a tampered file are indistinguishable by design
+	plaintext, err := gcm.Open(nil, nonce, sealed, file.header())
+	if err != nil {
+		wipeBytes(key)
+		return nil, errors.New(localize("wrong master password or corrupted vault"))
+	}
+	defer wipeBytes(plaintext)
+
+	v := &vault{key: key, kdf: file.KDF}
+	if err := json.Unmarshal(plaintext, &v.content); err != nil {
+		wipeBytes(key)
+		return nil, errors.New(localize("corrupted vault content: %v", err))
+	}
+	if v.content.Entries == nil {
+		v.content.Entries = []*Entry{}
+	}
+	for _, e := range v.content.Entries {
+		if e.Tags == nil {
+			e.Tags = []string{}
+		}
+		if e.Fields == nil {
+			e.Fields = map[string]string{}
+		}
+	}
+	return v, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// info describes an open vault without its entries
+func (v *vault) info(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"vaultId":        id,
+		"name":           v.content.Name,
+		"entries":        len(v.content.Entries),
+		"created":        v.content.Created,
+		"modified":       v.content.Modified,
+		"unsavedChanges": v.dirty,
+		"kdf": map[string]interface{}{
+			"algorithm":   v.kdf.Algorithm,
+			"memory":      v.kdf.Memory,
+			"iterations":  v.kdf.Iterations,
+			"parallelism": v.kdf.Parallelism,
+		},
+	}
+}
+
+// touch records a change that exportVault has not saved yet
+func (v *vault) touch() {
+	v.content.Modified = timestamp()
+	v.dirty = true
+}
+
+// wipe overwrites the key and the secrets of the entries
+func (v *vault) wipe() {
+	wipeBytes(v.key)
+	v.key = nil
+	for _, e := range v.content.Entries {
+		e.wipe()
+	}
+	v.content.Entries = nil
+}
+
+// sortedEntries returns the entries ordered by title, then creation
+func (v *vault) sortedEntries() []*Entry {
+	entries := append([]*Entry(nil), v.content.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Title) < strings.ToLower(entries[j].Title)
+	})
+	return entries
+}
+
+// vaultArgument resolves the vault handle of args[0] after checking the argument count
+func vaultArgument(args []js.Value, function string, count int, names string) (*vault, string, interface{}) {
+	if len(args) < count {
+		message := localize("%s requires at least %d arguments (%s)", function, count, names)
+		if count == 1 {
+			message = localize("%s requires at least 1 argument (%s)", function, names)
+		}
+		return nil, "", js.ValueOf(map[string]interface{}{"error": message})
+	}
+	id := args[0].String()
+	v, ok := openVaults[id]
+	if !ok {
+		return nil, "", js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown vault %q (it may already be locked)", id),
+		})
+	}
+	return v, id, nil
+}
+
+// entryArgument finds the entry whose id is given
+func (v *vault) entryArgument(value js.Value) (*Entry, interface{}) {
+	id := value.String()
+	for _, e := range v.content.Entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, js.ValueOf(map[string]interface{}{
+		"error": localize("Unknown entry %q", id),
+	})
+}
+
+// passwordArgument reads a password given as a string or as an {vaultId, entryId} reference
+func passwordArgument(value js.Value) (string, error) {
+	if value.Type() == js.TypeString {
+		return value.String(), nil
+	}
+	if value.Type() != js.TypeObject {
+		return "", errors.New(localize("expected a string or an {vaultId, entryId} reference"))
+	}
+	v, ok := openVaults[value.Get("vaultId").String()]
+	if !ok {
+		return "", errors.New(localize("Unknown vault %q (it may already be locked)", value.Get("vaultId").String()))
+	}
+	id := value.Get("entryId").String()
+	for _, e := range v.content.Entries {
+		if e.ID == id {
+			return e.Password, nil
+		}
+	}
+	return "", errors.New(localize("Unknown entry %q", id))
+}
+
+// apply sets the given fields of an entry and checks it
+func (e *Entry) apply(in EntryInput, now string) error {
+	set := func(target *string, value *string) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	set(&e.Title, in.Title)
+	set(&e.Username, in.Username)
+	set(&e.URL, in.URL)
+	set(&e.Notes, in.Notes)
+	if in.Tags != nil {
+		e.Tags = []string{}
+		for _, tag := range *in.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" && !containsFold(e.Tags, tag) {
+				e.Tags = append(e.Tags, tag)
+			}
+		}
+	}
+	if in.Fields != nil {
+		e.Fields = map[string]string{}
+		for name, value := range *in.Fields {
+			e.Fields[name] = value
+		}
+	}
+	if in.Favorite != nil {
+		e.Favorite = *in.Favorite
+	}
+
+	if in.Password != nil && in.Generate != nil {
+		return errors.New(localize("password and generate cannot be combined"))
+	}
+	password := in.Password
+	if in.Generate != nil {
+		generated, _, err := in.Generate.generate()
+		if err != nil {
+			return err
+		}
+		password = &generated
+	}
+	if password != nil && *password != e.Password {
+		e.Password = *password
+		e.PasswordChanged = now
+	}
+
+	if strings.TrimSpace(e.Title) == "" {
+		e.Title = e.URL
+		if e.Title == "" {
+			return errors.New(localize("title or url is required"))
+		}
+	}
+	for name := range e.Fields {
+		if strings.TrimSpace(name) == "" {
+			return errors.New(localize("custom field names must not be empty"))
+		}
+	}
+	e.Modified = now
+	return nil
+}
+
+// clone copies an entry with its own tags and fields
+func (e *Entry) clone() *Entry {
+	c := *e
+	c.Tags = append([]string{}, e.Tags...)
+	c.Fields = map[string]string{}
+	for name, value := range e.Fields {
+		c.Fields[name] = value
+	}
+	return &c
+}
+
+// summary describes an entry without its password, notes and custom field values
+func (e *Entry) summary() map[string]interface{} {
+	tags := make([]interface{}, len(e.Tags))
+	for i, tag := range e.Tags {
+		tags[i] = tag
+	}
+	fieldNames := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+	names := make([]interface{}, len(fieldNames))
+	for i, name := range fieldNames {
+		names[i] = name
+	}
+
+	return map[string]interface{}{
+		"id":              e.ID,
+		"title":           e.Title,
+		"username":        e.Username,
+		"url":             e.URL,
+		"tags":            tags,
+		"fieldNames":      names,
+		"favorite":        e.Favorite,
+		"hasPassword":     e.Password != "",
+		"created":         e.Created,
+		"modified":        e.Modified,
+		"passwordChanged": e.PasswordChanged,
+	}
+}
+
+// matches reports whether a lower-case query appears in the title, username, url or tags
+func (e *Entry) matches(query string) bool {
+	for _, text := range append([]string{e.Title, e.Username, e.URL}, e.Tags...) {
+		if strings.Contains(strings.ToLower(text), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// wipe clears the secrets of an entry. Go strings are immutable, so this drops the references
+// for the garbage collector rather than overwriting the bytes.
+func (e *Entry) wipe() {
+	e.Password, e.Notes = "", ""
+	e.Fields = nil
+}
+
+// jsonImport reads an array of entries, or an object with an entries array
+func jsonImport(raw []byte) ([]EntryInput, error) {
+	var inputs []EntryInput
+	if err := json.Unmarshal(raw, &inputs); err != nil {
+		var wrapped struct {
+			Entries []EntryInput `json:"entries"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			return nil, err
+		}
+		inputs = wrapped.Entries
+	}
+	if len(inputs) > maxImportEntries {
+		return nil, errors.New(localize("too many entries (at most %d)", maxImportEntries))
+	}
+	for i := range inputs {
+		inputs[i].Generate = nil
+	}
+	return inputs, nil
+}
+
+// csvColumns maps the header names of common password manager exports to entry fields
+var csvColumns = map[string]string{
+	"title": "title", "name": "title",
+	"url": "url", "login_uri": "url", "website": "url", "web site": "url",
+	"username": "username", "login_username": "username", "login name": "username", "user name": "username",
+	"password": "password", "login_password": "password",
+	"notes": "notes", "note": "notes", "extra": "notes", "comments": "notes",
+	"tags": "tags", "folder": "tags", "grouping": "tags", "group": "tags",
+	"favorite": "favorite", "fav": "favorite",
+}
+
+// csvImport reads a CSV export whose first row names the columns
+func csvImport(raw []byte) ([]EntryInput, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(raw, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New(localize("the CSV file is empty"))
+	}
+	if len(records)-1 > maxImportEntries {
+		return nil, errors.New(localize("too many entries (at most %d)", maxImportEntries))
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		if field, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	_, hasTitle := columns["title"]
+	_, hasURL := columns["url"]
+	if _, ok := columns["password"]; !ok || !hasTitle && !hasURL {
+		return nil, errors.New(localize("the CSV header must name a password column and a title or url column"))
+	}
+
+	var inputs []EntryInput
+	for _, record := range records[1:] {
+		value := func(field string) *string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return nil
+			}
+			text := record[i]
+			return &text
+		}
+		in := EntryInput{Title: value("title"), Username: value("username"), Password: value("password"), URL: value("url"), Notes: value("notes")}
+		if tags := value("tags"); tags != nil && *tags != "" {
+			list := strings.Split(*tags, ",")
+			in.Tags = &list
+		}
+		if favorite := value("favorite"); favorite != nil {
+			flag := *favorite == "1" || strings.EqualFold(*favorite, "true")
+			in.Favorite = &flag
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+// symbols returns the symbol set of the policy
+func (p PasswordPolicy) symbols() string {
+	if p.SymbolSet != "" {
+		return p.SymbolSet
+	}
+	return symbolChars
+}
+
+// generate draws a password from the policy's character classes. With requireEach (the
+// default), every enabled class appears at least once.
+func (p PasswordPolicy) generate() (string, string, error) {
+	length := p.Length
+	if length == 0 {
+		length = defaultPasswordLength
+	}
+	if length < minPasswordLength || length > maxPasswordLength {
+		return "", "", errors.New(localize("length must be between %d and %d", minPasswordLength, maxPasswordLength))
+	}
+
+	enabled := func(flag *bool, fallback bool) bool {
+		if flag == nil {
+			return fallback
+		}
+		return *flag
+	}
+	includeSymbols := enabled(p.IncludeSymbols, true)
+	var classes []string
+	for _, class := range []struct {
+		chars string
+		on    bool
+	}{
+		{lowercaseChars, enabled(p.Lowercase, true)},
+		{uppercaseChars, enabled(p.Uppercase, true)},
+		{digitChars, enabled(p.Digits, true)},
+		{p.symbols(), enabled(p.Symbols, includeSymbols)},
+	} {
+		if !class.on {
+			continue
+		}
+		kept := strings.Map(func(r rune) rune {
+			if p.ExcludeAmbiguous && strings.ContainsRune(ambiguousChars, r) || strings.ContainsRune(p.Exclude, r) || r > unicode.MaxASCII {
+				return -1
+			}
+			return r
+		}, class.chars)
+		if kept != "" {
+			classes = append(classes, kept)
+		}
+	}
+	if len(classes) == 0 {
+		return "", "", errors.New(localize("the policy leaves no characters to choose from"))
+	}
+	requireEach := enabled(p.RequireEach, true)
+	if requireEach && len(classes) > length {
+		return "", "", errors.New(localize("length %d is too short to include every character class", length))
+	}
+
+	charset := strings.Join(classes, "")
+	password := make([]byte, length)
+	for i := range password {
+		password[i] = charset[randomIndex(len(charset))]
+	}
+	if requireEach {
+		// One position per class, drawn without repetition, is overwritten from that class
+		positions := make([]int, length)
+		for i := range positions {
+			positions[i] = i
+		}
+		for i, class := range classes {
+			j := i + randomIndex(length-i)
+			positions[i], positions[j] = positions[j], positions[i]
+			password[positions[i]] = class[randomIndex(len(class))]
+		}
+	}
+	return string(password), charset, nil
+}
+
+// randomIndex draws a uniform index below n from crypto/rand
+func randomIndex(n int) int {
+	num, _ := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	return int(num.Int64())
+}
+
+// breachHash returns the upper-case hex SHA-1 of a password, as used by the range API
+func breachHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// newID generates a random vault handle or entry id
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// vaultFilename derives a download name from the vault name
+func vaultFilename(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			dash = true
+			continue
+		}
+		if dash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		dash = false
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "vault.vault.json"
+	}
+	return b.String() + ".vault.json"
+}
+
+// jsonArgument returns a JSON argument given either as a string or as a plain object
+func jsonArgument(value js.Value) string {
+	if value.Type() == js.TypeObject {
+		return js.Global().Get("JSON").Call("stringify", value).String()
+	}
+	return value.String()
+}
+
+// rawFromJS reads data given as text, a Uint8Array or an ArrayBuffer
+func rawFromJS(value js.Value) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		return []byte(value.String()), nil
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case !value.InstanceOf(js.Global().Get("Uint8Array")):
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or string"))
+	}
+
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"vault-argon2id",
+	"vault-aes-gcm",
+	"entry-crud",
+	"csv-import",
+	"password-policies",
+	"breach-k-anonymity",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	entries := 0
+	for _, v := range openVaults {
+		entries += len(v.content.Entries)
+	}
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"vaults":  len(openVaults),
+		"entries": entries,
+	}))
+}
+
+// releaseResources - Return freed memory to the runtime. Open vaults stay unlocked until lockVault.
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "password-manager-wasm",
+		"version":         moduleVersion,
+		"description":     "Encrypted password vault primitives module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"createVault",
+		"unlockVault",
+		"lockVault",
+		"exportVault",
+		"changeMasterPassword",
+		"addEntry",
+		"updateEntry",
+		"deleteEntry",
+		"getEntry",
+		"listEntries",
+		"importEntries",
+		"exportEntries",
+		"generatePassword",
+		"breachCheckPrefix",
+		"matchBreachRange",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register vault functions
+	js.Global().Set("createVault", js.FuncOf(createVault))
+	js.Global().Set("unlockVault", js.FuncOf(unlockVault))
+	js.Global().Set("lockVault", js.FuncOf(lockVault))
+	js.Global().Set("exportVault", js.FuncOf(exportVault))
+	js.Global().Set("changeMasterPassword", js.FuncOf(changeMasterPassword))
+
+	// Register entry functions
+	js.Global().Set("addEntry", js.FuncOf(addEntry))
+	js.Global().Set("updateEntry", js.FuncOf(updateEntry))
+	js.Global().Set("deleteEntry", js.FuncOf(deleteEntry))
+	js.Global().Set("getEntry", js.FuncOf(getEntry))
+	js.Global().Set("listEntries", js.FuncOf(listEntries))
+	js.Global().Set("importEntries", js.FuncOf(importEntries))
+	js.Global().Set("exportEntries", js.FuncOf(exportEntries))
+
+	// Register password functions
+	js.Global().Set("generatePassword", js.FuncOf(generatePassword))
+	js.Global().Set("breachCheckPrefix", js.FuncOf(breachCheckPrefix))
+	js.Global().Set("matchBreachRange", js.FuncOf(matchBreachRange))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM Password Manager module ready!")
+	fmt.Println("Available functions: createVault, unlockVault, addEntry, listEntries, generatePassword, breachCheckPrefix")
+
+	// Keep the program alive
+	select {}
+}
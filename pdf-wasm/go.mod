@@ -2,4 +2,19 @@ module pdf-wasm
 
 go 1.21
 
-require github.com/jung-kurt/gofpdf v1.16.2
+require (
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/pdfcpu/pdfcpu v0.8.1
+	golang.org/x/image v0.19.0
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
+)
+
+require (
+	github.com/hhrutter/lzw v1.0.0 // indirect
+	github.com/hhrutter/tiff v1.0.1 // indirect
+	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
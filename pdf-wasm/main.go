@@ -4,20 +4,58 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
 	"math"
+	"mime"
+	"path"
 	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall/js"
 	"time"
+	"unicode"
+	"unicode/utf16"
 
 	"github.com/jung-kurt/gofpdf"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/form"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/vector"
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding/charmap"
 )
 
 var silentMode = false
 
+// binaryMode returns documents and images as Uint8Array instead of base64, changed with setBinaryMode
+var binaryMode = false
+
 // PDFError represents an error in PDF operations
 type PDFError struct {
 	Operation string `json:"operation"`
@@ -34,10 +72,24 @@ type PDFDocument struct {
 
 // PDFPage represents a page configuration
 type PDFPage struct {
-	Width   float64 `json:"width"`
-	Height  float64 `json:"height"`
-	Margin  float64 `json:"margin"`
-	Content string  `json:"content"`
+	Width     float64    `json:"width"`
+	Height    float64    `json:"height"`
+	Margin    float64    `json:"margin"`
+	Content   string     `json:"content"`
+	Font      string     `json:"font,omitempty"`
+	FontStyle string     `json:"fontStyle,omitempty"`
+	FontSize  float64    `json:"fontSize,omitempty"`
+	Images    []PDFImage `json:"images,omitempty"`
+}
+
+// PDFImage represents a raster image placed on a page (positions and sizes in mm)
+type PDFImage struct {
+	Data   json.RawMessage `json:"data"`
+	Type   string          `json:"type,omitempty"`
+	X      float64         `json:"x"`
+	Y      float64         `json:"y"`
+	Width  float64         `json:"width"`
+	Height float64         `json:"height"`
 }
 
 // PDFTemplate represents a template configuration
@@ -49,36 +101,94 @@ type PDFTemplate struct {
 
 // PDFWatermark represents watermark configuration
 type PDFWatermark struct {
-	Text     string  `json:"text"`
-	Opacity  float64 `json:"opacity"`
-	Rotation float64 `json:"rotation"`
-	Size     float64 `json:"size"`
-	Color    string  `json:"color"`
+	Text     string          `json:"text"`
+	Image    json.RawMessage `json:"image,omitempty"`
+	Opacity  float64         `json:"opacity"`
+	Rotation *float64        `json:"rotation"`
+	Size     float64         `json:"size"`
+	Scale    float64         `json:"scale"`
+	Color    string          `json:"color"`
+	Position string          `json:"position"`
+	Pages    string          `json:"pages"`
+	Behind   bool            `json:"behind"`
+}
+
+// PDFHeaderFooter represents a running header, footer or page number configuration
+
+type PDFHeaderFooter struct {
+	Text          string   `json:"text"`
+	Format        string   `json:"format"`
+	Position      string   `json:"position"`
+	Align         string   `json:"align"`
+	Font          string   `json:"font"`
+	FontStyle     string   `json:"fontStyle"`
+	FontSize      float64  `json:"fontSize"`
+	Color         string   `json:"color"`
+	Margin        *float64 `json:"margin"`
+	MarginX       *float64 `json:"marginX"`
+	Opacity       float64  `json:"opacity"`
+	Pages         string   `json:"pages"`
+	SkipFirstPage bool     `json:"skipFirstPage"`
+}
+
+// PDFBookmark represents a document outline entry linked to a page (1-based).
+// Bold and Italic are honoured by addBookmarks; gofpdf outlines are plain.
+type PDFBookmark struct {
+	Title    string        `json:"title"`
+	Page     int           `json:"page"`
+	Bold     bool          `json:"bold,omitempty"`
+	Italic   bool          `json:"italic,omitempty"`
+	Children []PDFBookmark `json:"children,omitempty"`
 }
 
 // InvoiceData represents invoice data structure
 type InvoiceData struct {
-	Number      string                   `json:"number"`
-	Date        string                   `json:"date"`
-	DueDate     string                   `json:"dueDate"`
-	Company     CompanyInfo              `json:"company"`
-	Client      CompanyInfo              `json:"client"`
-	Items       []InvoiceItem            `json:"items"`
-	Tax         float64                  `json:"tax"`
-	Discount    float64                  `json:"discount"`
-	Currency    string                   `json:"currency"`
-	Notes       string                   `json:"notes"`
-	PaymentInfo map[string]interface{}   `json:"paymentInfo"`
+	Number      string                 `json:"number"`
+	Date        string                 `json:"date"`
+	DueDate     string                 `json:"dueDate"`
+	Company     CompanyInfo            `json:"company"`
+	Client      CompanyInfo            `json:"client"`
+	Items       []InvoiceItem          `json:"items"`
+	Tax         float64                `json:"tax"`
+	Discount    float64                `json:"discount"`
+	Currency    string                 `json:"currency"`
+	Notes       string                 `json:"notes"`
+	PaymentInfo map[string]interface{} `json:"paymentInfo"`
+	Font        string                 `json:"font"`
+	FacturX     json.RawMessage        `json:"facturX,omitempty"`
 }
 
 // CompanyInfo represents company information
 type CompanyInfo struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
-	Phone   string `json:"phone"`
-	Email   string `json:"email"`
-	Website string `json:"website"`
-	VAT     string `json:"vat"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	PostalCode string `json:"postalCode,omitempty"`
+	City       string `json:"city,omitempty"`
+	Country    string `json:"country,omitempty"`
+	Phone      string `json:"phone"`
+	Email      string `json:"email"`
+	Website    string `json:"website"`
+	VAT        string `json:"vat"`
+	LegalID    string `json:"legalId,omitempty"`
+}
+
+// locality - Postal code, city and country on one line, empty when none are set
+func (c CompanyInfo) locality() string {
+	parts := []string{}
+	for _, part := range []string{strings.TrimSpace(c.PostalCode + " " + c.City), strings.ToUpper(c.Country)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FacturXOptions configures the EN 16931 XML embedded by generateInvoice,
+// given as "facturX": true or as an object with these fields
+type FacturXOptions struct {
+	Enabled         bool   `json:"-"`
+	Relationship    string `json:"relationship"`
+	ExemptionReason string `json:"exemptionReason"`
 }
 
 // InvoiceItem represents an invoice line item
@@ -91,9 +201,17 @@ type InvoiceItem struct {
 
 // TableData represents table structure
 type TableData struct {
-	Headers []string                 `json:"headers"`
-	Rows    [][]string               `json:"rows"`
-	Style   map[string]interface{}   `json:"style"`
+	Headers []string               `json:"headers"`
+	Rows    [][]interface{}        `json:"rows"`
+	Style   map[string]interface{} `json:"style"`
+	ContentPlacement
+}
+
+// ContentPlacement chooses where addTable and addChart draw on an existing document: Page is the page
+// to draw on (0 appends a blank page the size of the last one) and Y the distance from its top in mm
+type ContentPlacement struct {
+	Page int      `json:"page"`
+	Y    *float64 `json:"y"`
 }
 
 // ChartData represents chart configuration
@@ -101,8 +219,19 @@ type ChartData struct {
 	Type   string                 `json:"type"`
 	Title  string                 `json:"title"`
 	Data   []ChartPoint           `json:"data"`
+	Labels []string               `json:"labels"`
+	Series []ChartSeries          `json:"series"`
+	XLabel string                 `json:"xLabel"`
+	YLabel string                 `json:"yLabel"`
 	Colors []string               `json:"colors"`
 	Style  map[string]interface{} `json:"style"`
+	ContentPlacement
+}
+
+// ChartSeries is a named set of values, one per label, for multi-series charts
+type ChartSeries struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
 }
 
 // ChartPoint represents a data point in a chart
@@ -120,6 +249,7 @@ type CertificateData struct {
 	Issuer      string `json:"issuer"`
 	Signature   string `json:"signature"`
 	Template    string `json:"template"`
+	Font        string `json:"font"`
 }
 
 // ContractData represents contract information
@@ -133,32 +263,107 @@ type ContractData struct {
 	Currency   string                 `json:"currency"`
 	Signatures []SignatureField       `json:"signatures"`
 	Clauses    map[string]interface{} `json:"clauses"`
+	Font       string                 `json:"font"`
 }
 
 // SignatureField represents a signature area
 type SignatureField struct {
-	Name     string  `json:"name"`
-	Title    string  `json:"title"`
-	Date     string  `json:"date"`
-	X        float64 `json:"x"`
-	Y        float64 `json:"y"`
-	Width    float64 `json:"width"`
-	Height   float64 `json:"height"`
-}
-
-// AnalysisResult represents PDF analysis results
-type AnalysisResult struct {
-	FileSize        int                    `json:"fileSize"`
-	Pages           int                    `json:"pages"`
-	Images          int                    `json:"images"`
-	Fonts           []string               `json:"fonts"`
-	Hyperlinks      []string               `json:"hyperlinks"`
-	FormFields      int                    `json:"formFields"`
-	Encrypted       bool                   `json:"encrypted"`
-	Version         string                 `json:"version"`
-	Metadata        map[string]interface{} `json:"metadata"`
-	TextContent     string                 `json:"textContent"`
-	OptimizationTips []string              `json:"optimizationTips"`
+	Name   string  `json:"name"`
+	Title  string  `json:"title"`
+	Date   string  `json:"date"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Page   int     `json:"page,omitempty"`
+}
+
+// JSONDocument represents a declarative document model rendered by jsonToPDF
+type JSONDocument struct {
+	Title       string        `json:"title"`
+	Author      string        `json:"author"`
+	Subject     string        `json:"subject"`
+	Orientation string        `json:"orientation"`
+	Margin      float64       `json:"margin"`
+	Font        string        `json:"font"`
+	FontSize    float64       `json:"fontSize"`
+	PageNumbers bool          `json:"pageNumbers"`
+	Layout      JSONLayout    `json:"layout"`
+	TOC         TOCOptions    `json:"toc"`
+	Content     []JSONBlock   `json:"content"`
+	Sections    []JSONSection `json:"sections"`
+}
+
+// JSONLayout flows the whole document body, below the title, across columns
+type JSONLayout struct {
+	Columns int     `json:"columns"`
+	Gap     float64 `json:"gap"`
+	Balance *bool   `json:"balance"`
+}
+
+// TOCOptions inserts a table of contents listing the document headings. true enables it with the
+// defaults; an object enables it with its own title and the deepest heading level listed (3 by default).
+type TOCOptions struct {
+	Enabled bool   `json:"-"`
+	Title   string `json:"title"`
+	Depth   int    `json:"depth"`
+}
+
+// UnmarshalJSON accepts a boolean or an options object
+func (o *TOCOptions) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*o = TOCOptions{Enabled: enabled}
+		return nil
+	}
+	type options TOCOptions
+	var parsed options
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	*o = TOCOptions(parsed)
+	o.Enabled = true
+	return nil
+}
+
+// JSONSection represents a titled group of blocks, optionally flowed across columns
+type JSONSection struct {
+	Title   string      `json:"title"`
+	NewPage bool        `json:"newPage"`
+	Columns int         `json:"columns"`
+	Gap     float64     `json:"gap"`
+	Balance *bool       `json:"balance"`
+	Content []JSONBlock `json:"content"`
+}
+
+// JSONBlock represents a single content block: heading, paragraph, list, table, image, spacer, pageBreak,
+// or a layout block (columns, columnBreak, row, group)
+
+type JSONBlock struct {
+	Type      string                 `json:"type"`
+	Text      string                 `json:"text"`
+	Level     int                    `json:"level"`
+	Align     string                 `json:"align"`
+	FontStyle string                 `json:"fontStyle"`
+	FontSize  float64                `json:"fontSize"`
+	Items     []string               `json:"items"`
+	Ordered   bool                   `json:"ordered"`
+	Headers   []string               `json:"headers"`
+	Rows      [][]interface{}        `json:"rows"`
+	Widths    []float64              `json:"widths"`
+	Style     map[string]interface{} `json:"style"`
+	Data      json.RawMessage        `json:"data"`
+	ImageType string                 `json:"imageType"`
+	Width     float64                `json:"width"`
+	Height    float64                `json:"height"`
+
+	// Layout blocks
+	Columns      int           `json:"columns"`
+	Gap          float64       `json:"gap"`
+	Balance      *bool         `json:"balance"`
+	Content      []JSONBlock   `json:"content"`
+	Cells        [][]JSONBlock `json:"cells"`
+	KeepTogether bool          `json:"keepTogether"`
 }
 
 // setSilentMode - Set silent mode for operations
@@ -169,42 +374,321 @@ func setSilentMode(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(silentMode)
 }
 
+// setBinaryMode - Return documents and images as Uint8Array instead of base64 strings
+func setBinaryMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		binaryMode = args[0].Bool()
+	}
+	return js.ValueOf(binaryMode)
+}
+
+// currentLocale is the language of error messages and generated document labels, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages and document labels ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+
+var messagesFR = map[string]string{
+	"%s requires at least 1 argument (%s)":                "%s requiert au moins 1 argument (%s)",
+	"Invalid pages format: %v":                            "Format de pages invalide: %v",
+	"Invalid image %d on page %d: %v":                     "Image %d invalide sur la page %d: %v",
+	"Failed to generate PDF: %v":                          "Échec de la génération du PDF: %v",
+	"only JPEG and PNG images are supported":              "seules les images JPEG et PNG sont prises en charge",
+	"missing image data":                                  "données d'image manquantes",
+	"image data must be base64 or a byte array":           "les données d'image doivent être en base64 ou un tableau d'octets",
+	"%s requires at least 2 arguments (%s)":               "%s requiert au moins 2 arguments (%s)",
+	"Font name must not be empty":                         "Le nom de la police ne doit pas être vide",
+	"Font style must be one of '', 'B', 'I' or 'BI'":      "Le style de police doit être '', 'B', 'I' ou 'BI'",
+	"Invalid font data: %v":                               "Données de police invalides: %v",
+	"Invalid TrueType font: %v":                           "Police TrueType invalide: %v",
+	"expected a Uint8Array, ArrayBuffer or base64 string": "un Uint8Array, un ArrayBuffer ou une chaîne base64 est attendu",
+	"Invalid page content format: %v":                     "Format du contenu de page invalide: %v",
+	"Invalid PDF data: %v":                                "Données PDF invalides: %v",
+	"Failed to add page: %v":                              "Échec de l'ajout de la page: %v",
+	"Invalid PDF array format: %v":                        "Format du tableau de PDF invalide: %v",
+	"At least 2 PDFs are required for merging":            "Au moins 2 PDF sont requis pour la fusion",
+	"Invalid PDF data at index %d: %v":                    "Données PDF invalides à l'index %d: %v",
+	"Failed to merge PDFs: %v":                            "Échec de la fusion des PDF: %v",
+	"Invalid ranges format: %v":                           "Format des plages invalide: %v",
+	"Failed to create split PDF %d: %v":                   "Échec de la création du PDF découpé %d: %v",
+	"Invalid watermark format: %v":                        "Format de filigrane invalide: %v",
+	"Watermark requires a text or an image":               "Le filigrane requiert un texte ou une image",
+	"Invalid watermark image: %v":                         "Image de filigrane invalide: %v",
+	"Invalid watermark settings: %v":                      "Paramètres de filigrane invalides: %v",
+	"Failed to add watermark: %v":                         "Échec de l'ajout du filigrane: %v",
+	"Failed to read watermarked PDF: %v":                  "Impossible de lire le PDF filigrané: %v",
+	"%s requires arguments (%s)":                          "%s requiert des arguments (%s)",
+	"Invalid options format: %v":                          "Format des options invalide: %v",
+	"%s requires a text":                                  "%s requiert un texte",
+	"Invalid position '%s' (expected top or bottom)":      "Position '%s' invalide (top ou bottom attendu)",
+	"Invalid align '%s' (expected left, center or right)": "Alignement '%s' invalide (left, center ou right attendu)",
+	"Invalid %s settings: %v":                             "Paramètres %s invalides: %v",
+	"Failed to stamp %s: %v":                              "Échec de l'apposition de %s: %v",
+	"Failed to read stamped PDF: %v":                      "Impossible de lire le PDF tamponné: %v",
+	"%s requires exactly 2 arguments (%s)":                "%s requiert exactement 2 arguments (%s)",
+	"Invalid data format: %v":                             "Format de données invalide: %v",
+	"Invalid template format: %v":                         "Format de modèle invalide: %v",
+	"Generated Report":                                    "Rapport généré",
+	"Amount: $%.2f":                                       "Montant: %.2f $",
+	"Report generated from template":                      "Rapport généré à partir du modèle",
+	"Generated on %s":                                     "Généré le %s",
+	"Failed to generate report: %v":                       "Échec de la génération du rapport: %v",
+	"Failed to compress PDF: %v":                          "Échec de la compression du PDF: %v",
+	"%s requires exactly 1 argument (%s)":                 "%s requiert exactement 1 argument (%s)",
+	"Invalid invoice data format: %v":                     "Format des données de facture invalide: %v",
+	"INVOICE":                                             "FACTURE",
+	"Number: %s":                                          "Numéro: %s",
+	"Due date: %s":                                        "Échéance: %s",
+	"From:":                                               "Émetteur:",
+	"Phone: %s | Email: %s":                               "Tél: %s | Email: %s",
+	"Bill to:":                                            "Facturé à:",
+	"Qty":                                                 "Qté",
+	"Unit price":                                          "Prix unit.",
+	"Subtotal:":                                           "Sous-total:",
+	"Discount (%.1f%%):":                                  "Remise (%.1f%%):",
+	"VAT (%.1f%%):":                                       "TVA (%.1f%%):",
+	"Invalid document options: %v":                        "Options du document invalides: %v",
+	"%s: the document was already saved":                  "%s: le document a déjà été enregistré",
+	"font size must be positive":                          "la taille de police doit être positive",
+	"Failed to generate invoice: %v":                      "Échec de la génération de la facture: %v",
+	"Invalid facturX options: %v":                         "Options facturX invalides: %v",
+	"Invalid Factur-X invoice: %v":                        "Facture Factur-X invalide: %v",
+	"Failed to embed Factur-X data: %v":                   "Échec de l'intégration des données Factur-X: %v",
+	"Invoice %s":                                          "Facture %s",
+	"Invoices":                                            "Factures",
+	"Invalid invoice batch format: %v":                    "Format du lot de factures invalide: %v",
+	"Invoice batch is empty":                              "Le lot de factures est vide",
+	"Invalid template options: %v":                        "Options de modèle invalides: %v",
+	"Invalid output %q (use separate or merged)":          "Sortie %q invalide (utilisez separate ou merged)",
+	"No invoice could be generated: %s":                   "Aucune facture n'a pu être générée: %s",
+	"Factur-X invoices need the separate output":          "Les factures Factur-X nécessitent la sortie separate",
+	"relationship must be Alternative, Data or Source":    "relationship doit valoir Alternative, Data ou Source",
+	"unrecognized date %q, expected YYYY-MM-DD":           "date %q non reconnue, format attendu AAAA-MM-JJ",
+	"currency %q is not an ISO 4217 code":                 "la devise %q n'est pas un code ISO 4217",
+	"invoice number is required":                          "le numéro de facture est requis",
+	"%s name is required":                                 "le nom de %s est requis",
+	"%s country must be an ISO 3166 alpha-2 code":         "le pays de %s doit être un code ISO 3166 alpha-2",
+	"company VAT number is required":                      "le numéro de TVA de l'émetteur est requis",
+	"at least one item is required":                       "au moins une ligne est requise",
+	"dueDate or paymentInfo.terms is required":            "dueDate ou paymentInfo.terms est requis",
+	"item %d: description is required":                    "ligne %d: la description est requise",
+	"document dates changed while writing":                "les dates du document ont changé pendant l'écriture",
+	"Invalid certificate data format: %v":                 "Format des données du certificat invalide: %v",
+	"This is to certify that":                             "Ce certificat atteste que",
+	"Issued by: %s":                                       "Émis par: %s",
+	"Failed to generate certificate: %v":                  "Échec de la génération du certificat: %v",
+	"Invalid contract data format: %v":                    "Format des données du contrat invalide: %v",
+	"Contract requires at least 2 parties":                "Le contrat requiert au moins 2 parties",
+	"CONTRACT":                                            "CONTRAT",
+	"Between the undersigned:":                            "Entre les soussignés:",
+	"VAT number: %s":                                      "N° TVA: %s",
+	"hereinafter referred to as “Party %d”":               "ci-après dénommé « Partie %d »",
+	"and":                                                   "et",
+	"Effective date:":                                       "Date d'effet:",
+	"Duration:":                                             "Durée:",
+	"Amount:":                                               "Montant:",
+	"Terms and conditions":                                  "Conditions",
+	"Failed to generate contract: %v":                       "Échec de la génération du contrat: %v",
+	"Invalid table data format: %v":                         "Format des données du tableau invalide: %v",
+	"Failed to add table: %v":                               "Échec de l'ajout du tableau: %v",
+	"Invalid table style: %v":                               "Style de tableau invalide: %v",
+	"style %s must be a positive number":                    "le style %s doit être un nombre positif",
+	"style %s must be a #RRGGBB color":                      "le style %s doit être une couleur #RRGGBB",
+	"style %s must be true or false":                        "le style %s doit valoir true ou false",
+	"style %s must be an array of numbers":                  "le style %s doit être un tableau de nombres",
+	"style %s must be a string or an array of strings":      "le style %s doit être une chaîne ou un tableau de chaînes",
+	"Invalid chart data format: %v":                         "Format des données du graphique invalide: %v",
+	"Failed to add chart: %v":                               "Échec de l'ajout du graphique: %v",
+	"Chart has no data":                                     "Le graphique ne contient aucune donnée",
+	"Invalid chart style: %v":                               "Style de graphique invalide: %v",
+	"unsupported chart type %q":                             "type de graphique %q non pris en charge",
+	"pie chart values must not be negative":                 "les valeurs d'un graphique circulaire ne doivent pas être négatives",
+	"pie chart values add up to zero":                       "la somme des valeurs du graphique circulaire est nulle",
+	"Failed to convert HTML to PDF: %v":                     "Échec de la conversion HTML en PDF: %v",
+	"image %q skipped: %v":                                  "image %q ignorée: %v",
+	"data URLs must be base64 encoded":                      "les URL data doivent être encodées en base64",
+	"no data provided in options.images":                    "aucune donnée fournie dans options.images",
+	"Failed to convert Markdown to PDF: %v":                 "Échec de la conversion Markdown en PDF: %v",
+	"Invalid document format: %v":                           "Format de document invalide: %v",
+	"Document requires content or sections":                 "Le document requiert du contenu ou des sections",
+	"Invalid block %d: %v":                                  "Bloc %d invalide: %v",
+	"Invalid block %d in section %d: %v":                    "Bloc %d invalide dans la section %d: %v",
+	"Invalid section %d: %v":                                "Section %d invalide: %v",
+	"Invalid layout: %v":                                    "Mise en page invalide: %v",
+	"Contents":                                              "Table des matières",
+	"block %d: %v":                                          "bloc %d: %v",
+	"cell %d: %v":                                           "cellule %d: %v",
+	"cell %d, block %d: %v":                                 "cellule %d, bloc %d: %v",
+	"columns cannot be nested":                              "les colonnes ne peuvent pas être imbriquées",
+	"columns must be between 1 and %d":                      "le nombre de colonnes doit être compris entre 1 et %d",
+	"row requires cells":                                    "la ligne requiert des cellules",
+	"Failed to convert JSON to PDF: %v":                     "Échec de la conversion JSON en PDF: %v",
+	"table requires headers or rows":                        "le tableau requiert des en-têtes ou des lignes",
+	"unknown block type %q":                                 "type de bloc %q inconnu",
+	"Failed to optimize PDF: %v":                            "Échec de l'optimisation du PDF: %v",
+	"Failed to analyze PDF: %v":                             "Échec de l'analyse du PDF: %v",
+	"Failed to extract images: %v":                          "Échec de l'extraction des images: %v",
+	"image object not found":                                "objet image introuvable",
+	"unsupported image encoding":                            "encodage d'image non pris en charge",
+	"DPI must be between %g and %g":                         "Le DPI doit être compris entre %g et %g",
+	"Failed to render page: %v":                             "Échec du rendu de la page: %v",
+	"Page %d does not exist (document has %d pages)":        "La page %d n'existe pas (le document contient %d pages)",
+	"page %d not found":                                     "page %d introuvable",
+	"rendered page would be %dx%d pixels, lower the DPI":    "la page rendue ferait %dx%d pixels, réduisez le DPI",
+	"Failed to decrypt PDF: %v":                             "Échec du déchiffrement du PDF: %v",
+	"Invalid form values JSON: %v":                          "JSON des valeurs du formulaire invalide: %v",
+	"Failed to read form fields: %v":                        "Impossible de lire les champs du formulaire: %v",
+	"PDF does not contain any form fields":                  "Le PDF ne contient aucun champ de formulaire",
+	"None of the given values match a form field name":      "Aucune des valeurs fournies ne correspond à un champ du formulaire",
+	"Failed to encode form data: %v":                        "Échec de l'encodage des données du formulaire: %v",
+	"Failed to fill form: %v":                               "Échec du remplissage du formulaire: %v",
+	"incorrect password for encrypted PDF":                  "mot de passe incorrect pour le PDF chiffré",
+	"expected a JSON string or an array of documents":       "une chaîne JSON ou un tableau de documents est attendu",
+	"%s failed: %v":                                         "Échec de %s: %v",
+	"setLocale requires exactly 1 argument (locale)":        "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":                 "Langue %q non prise en charge (disponibles: %s)",
+	"Invalid outline format: %v":                            "Format de sommaire invalide: %v",
+	"Invalid outline: %v":                                   "Sommaire invalide: %v",
+	"Outline must contain at least one bookmark":            "Le sommaire doit contenir au moins un signet",
+	"Failed to read PDF: %v":                                "Impossible de lire le PDF: %v",
+	"Failed to add bookmarks: %v":                           "Impossible d'ajouter les signets: %v",
+	"bookmark title is required":                            "le titre du signet est requis",
+	"bookmark %q points to page %d (document has %d pages)": "le signet %q pointe vers la page %d (le document a %d pages)",
+	"Rotation must be a multiple of 90 degrees, got %d":     "La rotation doit être un multiple de 90 degrés, reçu %d",
+	"Failed to rotate pages: %v":                            "Échec de la rotation des pages: %v",
+	"Failed to reorder pages: %v":                           "Échec de la réorganisation des pages: %v",
+	"Failed to delete pages: %v":                            "Échec de la suppression des pages: %v",
+	"Failed to insert blank pages: %v":                      "Échec de l'insertion des pages blanches: %v",
+	"No pages selected":                                     "Aucune page sélectionnée",
+	"Cannot delete all %d pages":                            "Impossible de supprimer les %d pages",
+	"unknown relationship %q":                               "relation %q inconnue",
+	"Attachment filename is required":                       "Le nom du fichier joint est requis",
+	"Invalid attachment data: %v":                           "Données de pièce jointe invalides: %v",
+	"Failed to attach file: %v":                             "Échec de l'ajout de la pièce jointe: %v",
+	"attachment %q was not added":                           "la pièce jointe %q n'a pas été ajoutée",
+	"Failed to list attachments: %v":                        "Impossible de lister les pièces jointes: %v",
+	"Unknown page size %q":                                  "Format de page %q inconnu",
+	"Failed to read modified PDF: %v":                       "Impossible de lire le PDF modifié: %v",
+	"Failed to extract text: %v":                            "Échec de l'extraction du texte: %v",
+	"Unknown level %q (expected %s)":                        "Niveau %q inconnu (attendu: %s)",
+}
+
 // createPDF - Generate PDF from scratch
 func createPDF(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "createPDF requires at least 1 argument (pages)",
+			"error": localize("%s requires at least 1 argument (%s)", "createPDF", "pages"),
 		})
 	}
 
 	pagesJSON := args[0].String()
+	if args[0].Type() == js.TypeObject {
+		pagesJSON = js.Global().Get("JSON").Call("stringify", args[0]).String()
+	}
 	var pages []PDFPage
 	if err := json.Unmarshal([]byte(pagesJSON), &pages); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid pages format: %v", err),
+			"error": localize("Invalid pages format: %v", err),
 		})
 	}
 
 	metadata := make(map[string]interface{})
+	var options struct {
+		Outline []PDFBookmark `json:"outline"`
+	}
 	if len(args) > 1 {
 		metadataJSON := args[1].String()
 		json.Unmarshal([]byte(metadataJSON), &metadata)
+		if _, ok := metadata["outline"]; ok {
+			if err := json.Unmarshal([]byte(metadataJSON), &options); err != nil {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("Invalid outline format: %v", err),
+				})
+			}
+		}
+	}
+	if err := validateOutline(options.Outline, len(pages)); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid outline: %v", err),
+		})
 	}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf := newDocument("P")
+	defaultFont, _ := metadata["font"].(string)
 
 	// Set metadata if provided
 	if title, ok := metadata["title"].(string); ok {
-		pdf.SetTitle(title, false)
+		pdf.SetTitle(title, true)
 	}
 	if author, ok := metadata["author"].(string); ok {
-		pdf.SetAuthor(author, false)
+		pdf.SetAuthor(author, true)
 	}
 	if subject, ok := metadata["subject"].(string); ok {
-		pdf.SetSubject(subject, false)
+		pdf.SetSubject(subject, true)
 	}
 
-	for _, page := range pages {
+	for i, page := range pages {
 		if page.Width > 0 && page.Height > 0 {
 			pdf.AddPageFormat("P", gofpdf.SizeType{Wd: page.Width, Ht: page.Height})
 		} else {
@@ -217,1271 +701,12735 @@ func createPDF(this js.Value, args []js.Value) interface{} {
 		}
 		pdf.SetMargins(margin, margin, margin)
 
-		pdf.SetFont("Arial", "", 12)
-		pdf.MultiCell(0, 10, page.Content, "", "", false)
+		font := page.Font
+		if font == "" {
+			font = defaultFont
+		}
+		fontSize := page.FontSize
+		if fontSize == 0 {
+			fontSize = 12
+		}
+		tr := useFont(pdf, font, page.FontStyle, fontSize)
+		pdf.MultiCell(0, fontSize*10/12, tr(page.Content), "", "", false)
+
+		for j, img := range page.Images {
+			if err := placeImage(pdf, img, false); err != nil {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("Invalid image %d on page %d: %v", j+1, i+1, err),
+				})
+			}
+		}
+	}
+
+	if len(options.Outline) > 0 {
+		addOutline(pdf, defaultFont, options.Outline, 0)
+		pdf.SetPage(pdf.PageCount())
 	}
 
 	var buf bytes.Buffer
 	if err := pdf.Output(&buf); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate PDF: %v", err),
+			"error": localize("Failed to generate PDF: %v", err),
 		})
 	}
 
-	pdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
+	pdfData := binaryOutput(buf.Bytes())
 
 	if !silentMode {
 		fmt.Printf("Go WASM: Generated PDF with %d pages, size: %d bytes\n", len(pages), buf.Len())
 	}
 
 	return js.ValueOf(map[string]interface{}{
-		"pdfData":  pdfData,
-		"size":     buf.Len(),
-		"pages":    len(pages),
-		"format":   "application/pdf",
-		"metadata": metadata,
+		"pdfData":   pdfData,
+		"size":      buf.Len(),
+		"pages":     len(pages),
+		"bookmarks": countBookmarks(options.Outline),
+		"format":    "application/pdf",
+		"metadata":  metadata,
 	})
 }
 
-// addPage - Add page to existing PDF
-func addPage(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "addPage requires exactly 2 arguments (pdfData, pageContent)",
-		})
+// placeImage - Draw a JPEG or PNG image on the current page.
+// With flow, the image is placed at the current position, which then moves below it.
+func placeImage(pdf *gofpdf.Fpdf, img PDFImage, flow bool) error {
+	data, err := decodeImageData(img.Data)
+	if err != nil {
+		return err
 	}
+	return placeImageData(pdf, data, img.Type, img.X, img.Y, img.Width, img.Height, flow)
+}
 
-	pdfDataStr := args[0].String()
-	pageContentJSON := args[1].String()
+// placeImageData - Draw JPEG or PNG bytes, detecting the type from the data when it is not given
+func placeImageData(pdf *gofpdf.Fpdf, data []byte, imageType string, x, y, width, height float64, flow bool) error {
+	imageType = strings.ToUpper(imageType)
+	switch {
+	case imageType == "JPEG":
+		imageType = "JPG"
+	case imageType == "" && bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		imageType = "JPG"
+	case imageType == "" && bytes.HasPrefix(data, []byte("\x89PNG")):
+		imageType = "PNG"
+	}
+	if imageType != "JPG" && imageType != "PNG" {
+		return errors.New(localize("only JPEG and PNG images are supported"))
+	}
 
-	var pageContent PDFPage
-	if err := json.Unmarshal([]byte(pageContentJSON), &pageContent); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid page content format: %v", err),
-		})
+	// Name images by content so a logo repeated on every page is embedded once
+	name := fmt.Sprintf("img-%x", sha1.Sum(data))
+	options := gofpdf.ImageOptions{ImageType: imageType}
+	if info := pdf.GetImageInfo(name); info == nil {
+		pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(data))
+	}
+	if err := pdf.Error(); err != nil {
+		return err
 	}
 
-	_, err := base64.StdEncoding.DecodeString(pdfDataStr)
-	if err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
-		})
+	pdf.ImageOptions(name, x, y, width, height, flow, options, 0, "")
+	return pdf.Error()
+}
+
+// decodeImageData - Accept base64 strings, data URLs, byte arrays or a JSON-serialized Uint8Array
+func decodeImageData(raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 {
+		return nil, errors.New(localize("missing image data"))
 	}
 
-	// For simplicity, we'll create a new PDF and add the page
-	// In a real implementation, you'd modify the existing PDF
-	pdf := gofpdf.New("P", "mm", "A4", "")
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		if comma := strings.Index(encoded, ","); strings.HasPrefix(encoded, "data:") && comma >= 0 {
+			encoded = encoded[comma+1:]
+		}
+		return base64.StdEncoding.DecodeString(encoded)
+	}
 
-	if pageContent.Width > 0 && pageContent.Height > 0 {
-		pdf.AddPageFormat("P", gofpdf.SizeType{Wd: pageContent.Width, Ht: pageContent.Height})
-	} else {
-		pdf.AddPage()
+	var list []byte
+	var numbers []int
+	if err := json.Unmarshal(raw, &numbers); err == nil {
+		list = make([]byte, len(numbers))
+		for i, n := range numbers {
+			list[i] = byte(n)
+		}
+		return list, nil
 	}
 
-	margin := pageContent.Margin
-	if margin == 0 {
-		margin = 10
+	// JSON.stringify turns a Uint8Array into {"0": 137, "1": 80, ...}
+	var indexed map[string]int
+	if err := json.Unmarshal(raw, &indexed); err != nil {
+		return nil, errors.New(localize("image data must be base64 or a byte array"))
 	}
-	pdf.SetMargins(margin, margin, margin)
+	list = make([]byte, len(indexed))
+	for key, n := range indexed {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(list) {
+			return nil, errors.New(localize("image data must be base64 or a byte array"))
+		}
+		list[i] = byte(n)
+	}
+	return list, nil
+}
 
-	pdf.SetFont("Arial", "", 12)
-	pdf.MultiCell(0, 10, pageContent.Content, "", "", false)
+// registeredFonts - TrueType fonts added with registerFont, by family then style
+var registeredFonts = map[string]map[string][]byte{}
 
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
+// registerFont - Register a TrueType font for UTF-8 text in generated documents
+func registerFont(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to add page: %v", err),
+			"error": localize("%s requires at least 2 arguments (%s)", "registerFont", "name, ttfData"),
 		})
 	}
 
-	newPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	if !silentMode {
-		fmt.Printf("Go WASM: Added page to PDF, new size: %d bytes\n", buf.Len())
+	name := strings.TrimSpace(args[0].String())
+	if name == "" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Font name must not be empty"),
+		})
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"pdfData": newPdfData,
-		"size":    buf.Len(),
-		"format":  "application/pdf",
-	})
-}
-
-// extractText - Extract text from PDF
-func extractText(this js.Value, args []js.Value) interface{} {
-	if len(args) < 1 {
+	style := ""
+	if len(args) > 2 && args[2].Type() == js.TypeString {
+		style = strings.ToUpper(args[2].String())
+	}
+	if style != "" && style != "B" && style != "I" && style != "BI" {
 		return js.ValueOf(map[string]interface{}{
-			"error": "extractText requires at least 1 argument (pdfData)",
+			"error": localize("Font style must be one of '', 'B', 'I' or 'BI'"),
 		})
 	}
 
-	pdfDataStr := args[0].String()
-	pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
+	ttfBytes, err := bytesFromJS(args[1])
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
+			"error": localize("Invalid font data: %v", err),
 		})
 	}
 
-	// Page range handling
-	pageRange := ""
-	if len(args) > 1 {
-		pageRange = args[1].String()
+	if err := checkTTF(name, style, ttfBytes); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid TrueType font: %v", err),
+		})
 	}
 
-	// Extract text using pdfcpu
-	var selectedPages []string
-	if pageRange != "" {
-		selectedPages = strings.Split(pageRange, ",")
+	if registeredFonts[name] == nil {
+		registeredFonts[name] = map[string][]byte{}
+	}
+	registeredFonts[name][style] = ttfBytes
+
+	styles := make([]string, 0, len(registeredFonts[name]))
+	for registered := range registeredFonts[name] {
+		styles = append(styles, registered)
 	}
+	sort.Strings(styles)
 
-	// Simplified text extraction
-	extractedText := fmt.Sprintf("Text extracted from PDF (%d bytes)", len(pdfBytes))
-	if pageRange != "" {
-		extractedText += fmt.Sprintf(" for pages: %s", pageRange)
+	styleList := make([]interface{}, len(styles))
+	for i, registered := range styles {
+		styleList[i] = registered
 	}
 
 	if !silentMode {
-		fmt.Printf("Go WASM: Extracted text from PDF (%d bytes)\n", len(pdfBytes))
+		fmt.Printf("Go WASM: Registered font %s (style %q, %d bytes)\n", name, style, len(ttfBytes))
 	}
 
 	return js.ValueOf(map[string]interface{}{
-		"text":      extractedText,
-		"pages":     len(selectedPages),
-		"pageRange": pageRange,
-		"size":      len(extractedText),
+		"name":   name,
+		"style":  style,
+		"styles": styleList,
+		"size":   len(ttfBytes),
 	})
 }
 
-// extractImages - Extract images from PDF
-func extractImages(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "extractImages requires exactly 1 argument (pdfData)",
-		})
+// checkTTF - Make sure gofpdf can parse the font before it is used in a document
+func checkTTF(name, style string, ttfBytes []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(name, style, ttfBytes)
+	return pdf.Error()
+}
+
+// newDocument - Create an A4 document with every registered font available
+func newDocument(orientation string) *gofpdf.Fpdf {
+	pdf := gofpdf.New(orientation, "mm", "A4", "")
+	for name, styles := range registeredFonts {
+		for style, ttfBytes := range styles {
+			pdf.AddUTF8FontFromBytes(name, style, ttfBytes)
+		}
 	}
+	return pdf
+}
 
-	pdfDataStr := args[0].String()
-	_, err := base64.StdEncoding.DecodeString(pdfDataStr)
-	if err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
-		})
+// useFont - Select a registered font, falling back to core Arial, and return the matching text encoder.
+// Core fonts only cover cp1252, so their text is translated instead of being written as raw UTF-8.
+func useFont(pdf *gofpdf.Fpdf, family, style string, size float64) func(string) string {
+	if styles, ok := registeredFonts[family]; ok {
+		if _, found := styles[style]; !found {
+			style = ""
+			if _, found := styles[style]; !found {
+				for registered := range styles {
+					style = registered
+					break
+				}
+			}
+		}
+		pdf.SetFont(family, style, size)
+		return func(text string) string { return text }
 	}
 
-	// Simplified image extraction simulation
-	images := []map[string]interface{}{
-		{
-			"page":   1,
-			"format": "jpeg",
-			"width":  800,
-			"height": 600,
-			"data":   "data:image/jpeg;base64,/9j/4AAQSkZJRgABAQEASABIAAD//2Q==", // Placeholder
-		},
+	pdf.SetFont("Arial", style, size)
+	if cp1252Translator == nil && pdf.Ok() {
+		cp1252Translator = pdf.UnicodeTranslatorFromDescriptor("")
 	}
+	if cp1252Translator == nil {
+		return pdf.UnicodeTranslatorFromDescriptor("")
+	}
+	return cp1252Translator
+}
 
-	if !silentMode {
-		fmt.Printf("Go WASM: Extracted %d images from PDF\n", len(images))
+// cp1252Translator is shared by all documents: building it parses the code page table, which dominated
+// the time of documents switching fonts often, such as invoice batches
+var cp1252Translator func(string) string
+
+// bytesFromJS - Read binary data passed as a Uint8Array, an ArrayBuffer or a base64 string
+func bytesFromJS(value js.Value) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		return base64.StdEncoding.DecodeString(value.String())
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case !value.InstanceOf(js.Global().Get("Uint8Array")):
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or base64 string"))
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"images": images,
-		"count":  len(images),
-	})
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
 }
 
-// mergePDFs - Combine multiple PDFs
-func mergePDFs(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "mergePDFs requires exactly 1 argument (pdfArray)",
-		})
+// binaryOutput - Return generated bytes as base64, or as a Uint8Array in binary mode
+func binaryOutput(data []byte) interface{} {
+	if !binaryMode {
+		return base64.StdEncoding.EncodeToString(data)
 	}
 
-	pdfArrayJSON := args[0].String()
-	var pdfArray []string
-	if err := json.Unmarshal([]byte(pdfArrayJSON), &pdfArray); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF array format: %v", err),
-		})
-	}
+	dst := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(dst, data)
+	return dst
+}
 
-	if len(pdfArray) < 2 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "At least 2 PDFs are required for merging",
-		})
+// dataURLOutput - Return an image as a data URL, or as a Uint8Array in binary mode
+func dataURLOutput(mimeType string, data []byte) interface{} {
+	if binaryMode {
+		return binaryOutput(data)
 	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
 
-	// Simplified merge - create a new PDF with placeholder content
-	pdf := gofpdf.New("P", "mm", "A4", "")
-
-	totalPages := 0
-	for i, pdfDataStr := range pdfArray {
-		pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
-		if err != nil {
-			return js.ValueOf(map[string]interface{}{
-				"error": fmt.Sprintf("Invalid PDF data at index %d: %v", i, err),
-			})
+// pdfArrayFromJS - Read the documents to merge from a JSON array of base64 strings or from a JS array
+// of Uint8Array, ArrayBuffer or base64 values
+func pdfArrayFromJS(value js.Value) ([]js.Value, error) {
+	if value.Type() == js.TypeString {
+		var encoded []string
+		if err := json.Unmarshal([]byte(value.String()), &encoded); err != nil {
+			return nil, err
 		}
 
-		pdf.AddPage()
-		pdf.SetFont("Arial", "", 12)
-		pdf.Cell(0, 10, fmt.Sprintf("Content from PDF #%d (%d bytes)", i+1, len(pdfBytes)))
-		totalPages++
+		pdfArray := make([]js.Value, len(encoded))
+		for i, pdfData := range encoded {
+			pdfArray[i] = js.ValueOf(pdfData)
+		}
+		return pdfArray, nil
 	}
 
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to merge PDFs: %v", err),
-		})
+	if value.Type() != js.TypeObject || !js.Global().Get("Array").Call("isArray", value).Bool() {
+		return nil, errors.New(localize("expected a JSON string or an array of documents"))
 	}
 
-	mergedPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	if !silentMode {
-		fmt.Printf("Go WASM: Merged %d PDFs into %d pages\n", len(pdfArray), totalPages)
+	pdfArray := make([]js.Value, value.Length())
+	for i := range pdfArray {
+		pdfArray[i] = value.Index(i)
 	}
-
-	return js.ValueOf(map[string]interface{}{
-		"pdfData":     mergedPdfData,
-		"size":        buf.Len(),
-		"pages":       totalPages,
-		"sourceCount": len(pdfArray),
-		"format":      "application/pdf",
-	})
+	return pdfArray, nil
 }
 
-// splitPDF - Split PDF into parts
-func splitPDF(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
+// addPage - Add page to existing PDF
+func addPage(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "splitPDF requires exactly 2 arguments (pdfData, ranges)",
+			"error": localize("%s requires at least 2 arguments (%s)", "addPage", "pdfData, pageContent"),
 		})
 	}
 
-	pdfDataStr := args[0].String()
-	rangesJSON := args[1].String()
+	pdfData := args[0]
+	pageContentJSON := args[1].String()
 
-	pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
-	if err != nil {
+	var pageContent PDFPage
+	if err := json.Unmarshal([]byte(pageContentJSON), &pageContent); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
+			"error": localize("Invalid page content format: %v", err),
 		})
 	}
 
-	var ranges []string
-	if err := json.Unmarshal([]byte(rangesJSON), &ranges); err != nil {
+	_, err := decodePDFData(pdfData, optionalPassword(args, 2))
+	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid ranges format: %v", err),
+			"error": localize("Invalid PDF data: %v", err),
 		})
 	}
 
-	// Simplified split - create separate PDFs for each range
-	var splitPDFs []map[string]interface{}
+	// For simplicity, we'll create a new PDF and add the page
+	// In a real implementation, you'd modify the existing PDF
+	pdf := gofpdf.New("P", "mm", "A4", "")
 
-	for i, pageRange := range ranges {
-		pdf := gofpdf.New("P", "mm", "A4", "")
+	if pageContent.Width > 0 && pageContent.Height > 0 {
+		pdf.AddPageFormat("P", gofpdf.SizeType{Wd: pageContent.Width, Ht: pageContent.Height})
+	} else {
 		pdf.AddPage()
-		pdf.SetFont("Arial", "", 12)
-		pdf.Cell(0, 10, fmt.Sprintf("Split PDF part %d - Pages: %s", i+1, pageRange))
+	}
 
-		var buf bytes.Buffer
-		if err := pdf.Output(&buf); err != nil {
-			return js.ValueOf(map[string]interface{}{
-				"error": fmt.Sprintf("Failed to create split PDF %d: %v", i+1, err),
-			})
-		}
+	margin := pageContent.Margin
+	if margin == 0 {
+		margin = 10
+	}
+	pdf.SetMargins(margin, margin, margin)
 
-		splitPDFData := base64.StdEncoding.EncodeToString(buf.Bytes())
+	pdf.SetFont("Arial", "", 12)
+	pdf.MultiCell(0, 10, pageContent.Content, "", "", false)
 
-		splitPDFs = append(splitPDFs, map[string]interface{}{
-			"pdfData":   splitPDFData,
-			"pageRange": pageRange,
-			"size":      buf.Len(),
-			"partIndex": i + 1,
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to add page: %v", err),
 		})
 	}
 
+	newPdfData := binaryOutput(buf.Bytes())
+
 	if !silentMode {
-		fmt.Printf("Go WASM: Split PDF into %d parts\n", len(splitPDFs))
+		fmt.Printf("Go WASM: Added page to PDF, new size: %d bytes\n", buf.Len())
 	}
 
 	return js.ValueOf(map[string]interface{}{
-		"splitPDFs": splitPDFs,
-		"parts":     len(splitPDFs),
-		"original":  len(pdfBytes),
+		"pdfData": newPdfData,
+		"size":    buf.Len(),
+		"format":  "application/pdf",
 	})
 }
 
-// addWatermark - Add watermark to PDF
-func addWatermark(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "addWatermark requires exactly 2 arguments (pdfData, watermarkData)",
-		})
-	}
+// openDocuments counts the createDocument handles not released yet, reported by getMemoryStats
+var openDocuments = 0
 
-	pdfDataStr := args[0].String()
-	watermarkJSON := args[1].String()
+// documentBuilder - A document kept in Go memory while a createDocument handle adds content to it
+type documentBuilder struct {
+	pdf       *gofpdf.Fpdf
+	font      string
+	fontStyle string
+	fontSize  float64
+	err       error
+}
 
-	pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
-	if err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
-		})
+// documentMethods are the chainable methods of a createDocument handle; save and release are added separately
+var documentMethods = map[string]func(*documentBuilder, []js.Value) error{
+	"addPage":  (*documentBuilder).addPage,
+	"setFont":  (*documentBuilder).setFont,
+	"addText":  (*documentBuilder).addText,
+	"addTable": (*documentBuilder).addTable,
+	"addImage": (*documentBuilder).addImage,
+}
+
+// jsonArgument returns a JSON string argument as is and serializes objects passed directly
+func jsonArgument(value js.Value) string {
+	if value.Type() == js.TypeObject {
+		return js.Global().Get("JSON").Call("stringify", value).String()
 	}
+	return value.String()
+}
 
-	var watermark PDFWatermark
-	if err := json.Unmarshal([]byte(watermarkJSON), &watermark); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid watermark format: %v", err),
-		})
+// createDocument - Start a document built incrementally through the returned handle. Content methods
+// return the handle so calls can be chained; the first failure is kept in handle.error, later calls are
+// skipped and save() reports it. Call release() once done to free the Go side of the handle.
+func createDocument(this js.Value, args []js.Value) interface{} {
+	var options struct {
+		Orientation string  `json:"orientation"`
+		Margin      float64 `json:"margin"`
+		Title       string  `json:"title"`
+		Author      string  `json:"author"`
+		Subject     string  `json:"subject"`
+		Font        string  `json:"font"`
+		FontSize    float64 `json:"fontSize"`
+	}
+	if len(args) > 0 && !args[0].IsUndefined() && !args[0].IsNull() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[0])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid document options: %v", err),
+			})
+		}
 	}
 
-	// Create new PDF with watermark
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
+	orientation := strings.ToUpper(options.Orientation)
+	if orientation != "L" {
+		orientation = "P"
+	}
+	margin := options.Margin
+	if margin == 0 {
+		margin = 20
+	}
+	fontSize := options.FontSize
+	if fontSize == 0 {
+		fontSize = 12
+	}
 
-	// Set transparency (simplified)
-	opacity := watermark.Opacity
-	if opacity == 0 {
-		opacity = 0.3
+	builder := &documentBuilder{pdf: newDocument(orientation), font: options.Font, fontSize: fontSize}
+	builder.pdf.SetMargins(margin, margin, margin)
+	builder.pdf.SetAutoPageBreak(true, margin)
+	if options.Title != "" {
+		builder.pdf.SetTitle(options.Title, true)
+	}
+	if options.Author != "" {
+		builder.pdf.SetAuthor(options.Author, true)
+	}
+	if options.Subject != "" {
+		builder.pdf.SetSubject(options.Subject, true)
+	}
+
+	handle := js.Global().Get("Object").New()
+	methods := map[string]js.Func{}
+	for name, method := range documentMethods {
+		name, method := name, method
+		methods[name] = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if builder.err != nil {
+				return handle
+			}
+			switch {
+			case builder.pdf == nil:
+				builder.err = errors.New(localize("%s: the document was already saved", name))
+			default:
+				if err := method(builder, args); err != nil {
+					builder.err = fmt.Errorf("%s: %v", name, err)
+				} else if err := builder.pdf.Error(); err != nil {
+					builder.err = fmt.Errorf("%s: %v", name, err)
+				}
+			}
+			if builder.err != nil {
+				handle.Set("error", builder.err.Error())
+			}
+			return handle
+		})
 	}
 
-	// Add watermark text
-	pdf.SetFont("Arial", "", 48)
-	pdf.SetTextColor(128, 128, 128) // Gray color
+	// save() writes the document and ends the builder, gofpdf cannot append once the output is written
+	methods["save"] = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if builder.err != nil {
+			return js.ValueOf(map[string]interface{}{"error": builder.err.Error()})
+		}
+		if builder.pdf == nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("%s: the document was already saved", "save"),
+			})
+		}
+		if builder.pdf.PageNo() == 0 {
+			builder.pdf.AddPage()
+		}
 
-	// Add watermark text (rotation simplified for compatibility)
-	pdf.Text(50, 150, watermark.Text)
+		pages := builder.pdf.PageCount()
+		var buf bytes.Buffer
+		err := builder.pdf.Output(&buf)
+		builder.pdf = nil
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to generate PDF: %v", err),
+			})
+		}
 
-	// Add original content placeholder
-	pdf.SetFont("Arial", "", 12)
-	pdf.SetTextColor(0, 0, 0) // Black color
-	pdf.Text(20, 50, fmt.Sprintf("Original PDF content (%d bytes)", len(pdfBytes)))
+		if !silentMode {
+			fmt.Printf("Go WASM: Saved built document with %d pages, size: %d bytes\n", pages, buf.Len())
+		}
 
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to add watermark: %v", err),
+			"pdfData": binaryOutput(buf.Bytes()),
+			"size":    buf.Len(),
+			"pages":   pages,
+			"format":  "application/pdf",
 		})
+	})
+	for name, method := range methods {
+		handle.Set(name, method)
 	}
 
-	watermarkedPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
+	// release() frees the Go functions and the document, the handle becomes unusable
+	var release js.Func
+	release = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		for name, method := range methods {
+			handle.Delete(name)
+			method.Release()
+		}
+		handle.Delete("release")
+		release.Release()
+		builder.pdf = nil
+		openDocuments--
+		return nil
+	})
+	handle.Set("release", release)
+	openDocuments++
 
-	if !silentMode {
-		fmt.Printf("Go WASM: Added watermark '%s' to PDF\n", watermark.Text)
-	}
+	return handle
+}
 
-	return js.ValueOf(map[string]interface{}{
-		"pdfData":   watermarkedPdfData,
-		"size":      buf.Len(),
-		"watermark": watermark.Text,
-		"opacity":   opacity,
-		"format":    "application/pdf",
-	})
+// ensurePage adds the first page when content is added to a document that has none yet
+func (b *documentBuilder) ensurePage() {
+	if b.pdf.PageNo() == 0 {
+		b.pdf.AddPage()
+	}
 }
 
-// generateReport - Template-based PDF generation
-func generateReport(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "generateReport requires exactly 2 arguments (data, template)",
-		})
+// addPage - handle.addPage(orientation) or handle.addPage({orientation, width, height}) with sizes in mm
+func (b *documentBuilder) addPage(args []js.Value) error {
+	var options struct {
+		Orientation string  `json:"orientation"`
+		Width       float64 `json:"width"`
+		Height      float64 `json:"height"`
+	}
+	if len(args) > 0 {
+		switch args[0].Type() {
+		case js.TypeString:
+			options.Orientation = args[0].String()
+		case js.TypeObject:
+			if err := json.Unmarshal([]byte(jsonArgument(args[0])), &options); err != nil {
+				return err
+			}
+		}
 	}
 
-	dataJSON := args[0].String()
-	templateJSON := args[1].String()
+	orientation := strings.ToUpper(options.Orientation)
+	switch {
+	case options.Width > 0 && options.Height > 0:
+		b.pdf.AddPageFormat(orientation, gofpdf.SizeType{Wd: options.Width, Ht: options.Height})
+	case orientation == "P" || orientation == "L":
+		b.pdf.AddPageFormat(orientation, gofpdf.SizeType{Wd: 210, Ht: 297})
+	default:
+		b.pdf.AddPage()
+	}
+	return nil
+}
 
-	var reportData map[string]interface{}
-	if err := json.Unmarshal([]byte(dataJSON), &reportData); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid data format: %v", err),
-		})
+// setFont - handle.setFont(family, style, size) selects a registerFont family (Arial otherwise) for later text and tables
+func (b *documentBuilder) setFont(args []js.Value) error {
+	if len(args) > 0 && args[0].Type() == js.TypeString {
+		b.font = args[0].String()
+	}
+	if len(args) > 1 && args[1].Type() == js.TypeString {
+		b.fontStyle = strings.ToUpper(args[1].String())
 	}
+	if len(args) > 2 && args[2].Type() == js.TypeNumber {
+		if args[2].Float() <= 0 {
+			return errors.New(localize("font size must be positive"))
+		}
+		b.fontSize = args[2].Float()
+	}
+	useFont(b.pdf, b.font, b.fontStyle, b.fontSize)
+	return nil
+}
 
-	var template PDFTemplate
-	if err := json.Unmarshal([]byte(templateJSON), &template); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid template format: %v", err),
-		})
+// addText - handle.addText(text, {align, fontSize, fontStyle}) writes a wrapped paragraph at the current position
+func (b *documentBuilder) addText(args []js.Value) error {
+	if len(args) < 1 {
+		return errors.New(localize("%s requires at least 1 argument (%s)", "addText", "text"))
+	}
+	var options struct {
+		Align     string  `json:"align"`
+		FontSize  float64 `json:"fontSize"`
+		FontStyle *string `json:"fontStyle"`
+	}
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return err
+		}
 	}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
+	size, style := b.fontSize, b.fontStyle
+	if options.FontSize > 0 {
+		size = options.FontSize
+	}
+	if options.FontStyle != nil {
+		style = strings.ToUpper(*options.FontStyle)
+	}
 
-	// Header
-	pdf.SetFont("Arial", "B", 16)
-	if title, ok := reportData["title"].(string); ok {
-		pdf.Cell(0, 20, title)
-	} else {
-		pdf.Cell(0, 20, "Generated Report")
+	b.ensurePage()
+	tr := useFont(b.pdf, b.font, style, size)
+	b.pdf.MultiCell(0, size*0.5, tr(args[0].String()), "", blockAlign(options.Align, "L"), false)
+	b.pdf.Ln(size * 0.25)
+	useFont(b.pdf, b.font, b.fontStyle, b.fontSize)
+	return nil
+}
+
+// addTable - handle.addTable(tableData) takes the {headers, rows, style} of addTable, in the current font by default
+func (b *documentBuilder) addTable(args []js.Value) error {
+	if len(args) < 1 {
+		return errors.New(localize("%s requires at least 1 argument (%s)", "addTable", "tableData"))
+	}
+	var table TableData
+	if err := json.Unmarshal([]byte(jsonArgument(args[0])), &table); err != nil {
+		return errors.New(localize("Invalid table data format: %v", err))
 	}
 
-	pdf.Ln(10)
+	style, err := parseTableStyle(table.Style, defaultTableStyle(b.font, b.fontSize*10/12))
+	if err != nil {
+		return errors.New(localize("Invalid table style: %v", err))
+	}
+	if _, ok := table.Style["headerFontSize"]; !ok {
+		style.HeaderFontSize = style.FontSize + 1
+	}
 
-	// Content based on template type
-	pdf.SetFont("Arial", "", 12)
+	b.ensurePage()
+	drawTable(b.pdf, table.Headers, table.Rows, style)
+	b.pdf.Ln(b.fontSize * 0.5)
+	useFont(b.pdf, b.font, b.fontStyle, b.fontSize)
+	return nil
+}
 
-	switch template.Type {
-	case "table":
-		// Generate table report
-		if rows, ok := reportData["rows"].([]interface{}); ok {
-			for i, row := range rows {
-				if rowMap, ok := row.(map[string]interface{}); ok {
-					for key, value := range rowMap {
-						pdf.Cell(90, 10, fmt.Sprintf("%s:", key))
-						pdf.Cell(90, 10, fmt.Sprintf("%v", value))
-					}
-					if i < len(rows)-1 {
-						pdf.Ln(5)
-					}
-				}
-			}
-		}
-	case "invoice":
-		// Generate invoice
-		if date, ok := reportData["date"].(string); ok {
-			pdf.Cell(0, 10, fmt.Sprintf("Date: %s", date))
-		}
-		if amount, ok := reportData["amount"].(float64); ok {
-			pdf.Cell(0, 10, fmt.Sprintf("Amount: $%.2f", amount))
+// addImage - handle.addImage(imageData, {x, y, width, height, align, type}) places a JPEG or PNG given as
+// Uint8Array, ArrayBuffer, base64 or data URL. Without x and y it flows at the current position.
+func (b *documentBuilder) addImage(args []js.Value) error {
+	if len(args) < 1 {
+		return errors.New(localize("%s requires at least 1 argument (%s)", "addImage", "imageData"))
+	}
+	imageData := args[0]
+	if imageData.Type() == js.TypeString {
+		if encoded := imageData.String(); strings.HasPrefix(encoded, "data:") && strings.Contains(encoded, ",") {
+			imageData = js.ValueOf(encoded[strings.Index(encoded, ",")+1:])
 		}
-	default:
-		// Simple text report
-		if content, ok := reportData["content"].(string); ok {
-			pdf.MultiCell(0, 10, content, "", "", false)
-		} else {
-			pdf.MultiCell(0, 10, "Report generated from template", "", "", false)
+	}
+	data, err := bytesFromJS(imageData)
+	if err != nil {
+		return err
+	}
+
+	var options struct {
+		X      *float64 `json:"x"`
+		Y      *float64 `json:"y"`
+		Width  float64  `json:"width"`
+		Height float64  `json:"height"`
+		Align  string   `json:"align"`
+		Type   string   `json:"type"`
+	}
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return err
 		}
 	}
 
-	// Footer
-	pdf.Ln(20)
-	pdf.SetFont("Arial", "I", 10)
-	pdf.Cell(0, 10, fmt.Sprintf("Generated on %s", time.Now().Format("2006-01-02 15:04:05")))
+	b.ensurePage()
+	if options.X != nil && options.Y != nil {
+		return placeImageData(b.pdf, data, options.Type, *options.X, *options.Y, options.Width, options.Height, false)
+	}
 
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
+	left, _, right, _ := b.pdf.GetMargins()
+	pageWidth, _ := b.pdf.GetPageSize()
+	x := left
+	if options.Height > 0 {
+		ensureSpace(b.pdf, options.Height)
+	}
+	if options.Width > 0 {
+		switch blockAlign(options.Align, "L") {
+		case "C":
+			x = (pageWidth - options.Width) / 2
+		case "R":
+			x = pageWidth - right - options.Width
+		}
+	}
+	if err := placeImageData(b.pdf, data, options.Type, x, -1, options.Width, options.Height, true); err != nil {
+		return err
+	}
+	b.pdf.Ln(b.fontSize * 0.25)
+	return nil
+}
+
+// extractText - Extract text from PDF
+func extractText(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate report: %v", err),
+			"error": localize("%s requires at least 1 argument (%s)", "extractText", "pdfData"),
 		})
 	}
 
-	reportPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	if !silentMode {
-		fmt.Printf("Go WASM: Generated %s report (%d bytes)\n", template.Type, buf.Len())
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"pdfData":      reportPdfData,
-		"size":         buf.Len(),
-		"templateType": template.Type,
-		"pages":        1,
-		"format":       "application/pdf",
-		"generatedAt":  time.Now().Format("2006-01-02T15:04:05Z"),
-	})
-}
+	// All pages unless a selection such as "1,3-5" is given
+	var selectedPages []string
+	if len(args) > 1 {
+		selectedPages = pageSelectionArgument(args[1])
+	}
 
-// getPDFInfo - Get PDF metadata and information
-func getPDFInfo(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
+	ctx, err := api.ReadAndValidate(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": "getPDFInfo requires exactly 1 argument (pdfData)",
+			"error": localize("Failed to extract text: %v", err),
 		})
 	}
 
-	pdfDataStr := args[0].String()
-	pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
+	pages, err := api.PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
+			"error": localize("Invalid pages format: %v", err),
 		})
 	}
+	pageNrs := []int{}
+	for pageNr, selected := range pages {
+		if selected {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+	sort.Ints(pageNrs)
 
-	// Simplified PDF info extraction
-	info := map[string]interface{}{
-		"size":       len(pdfBytes),
-		"pages":      1, // Placeholder
-		"version":    "1.4",
-		"encrypted":  false,
-		"title":      "PDF Document",
-		"author":     "PDF-WASM",
-		"subject":    "",
-		"keywords":   "",
-		"creator":    "Go PDF-WASM Module",
-		"producer":   "GoFPDF",
-		"createdAt":  time.Now().Format("2006-01-02T15:04:05Z"),
-		"modifiedAt": time.Now().Format("2006-01-02T15:04:05Z"),
+	pageTexts := []interface{}{}
+	texts := make([]string, 0, len(pageNrs))
+	for i, pageNr := range pageNrs {
+		reportProgress(i, len(pageNrs))
+		text, err := extractPageText(ctx, pageNr)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to extract text: %v", err),
+			})
+		}
+		texts = append(texts, text)
+		pageTexts = append(pageTexts, map[string]interface{}{
+			"page": pageNr,
+			"text": text,
+		})
 	}
 
+	// Pages are separated by a form feed, as pdftotext does
+	extractedText := strings.Join(texts, "\f")
+
 	if !silentMode {
-		fmt.Printf("Go WASM: Retrieved info for PDF (%d bytes)\n", len(pdfBytes))
+		fmt.Printf("Go WASM: Extracted %d characters from %d pages\n", len([]rune(extractedText)), len(pageNrs))
 	}
 
-	return js.ValueOf(info)
+	return js.ValueOf(map[string]interface{}{
+		"text":      extractedText,
+		"pageTexts": pageTexts,
+		"pages":     len(pageNrs),
+		"pageRange": strings.Join(selectedPages, ","),
+		"size":      len(extractedText),
+	})
 }
 
-// compressPDF - Compress PDF file
-func compressPDF(this js.Value, args []js.Value) interface{} {
+// extractImages - Extract images from PDF
+func extractImages(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "compressPDF requires at least 1 argument (pdfData)",
+			"error": localize("%s requires at least 1 argument (%s)", "extractImages", "pdfData"),
 		})
 	}
 
-	pdfDataStr := args[0].String()
-	compressionLevel := "medium"
-	if len(args) > 1 {
-		compressionLevel = args[1].String()
-	}
-
-	pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
+	pdfData := args[0]
+	pdfBytes, err := decodePDFData(pdfData, optionalPassword(args, 1))
 	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
+			"error": localize("Invalid PDF data: %v", err),
 		})
 	}
 
-	// Simplified compression simulation
-	originalSize := len(pdfBytes)
-	compressionRatio := 0.7 // 30% reduction
-
-	switch compressionLevel {
-	case "low":
-		compressionRatio = 0.9
-	case "medium":
-		compressionRatio = 0.7
-	case "high":
-		compressionRatio = 0.5
+	// Optional page selection such as "1-3,5" (all pages by default)
+	var selectedPages []string
+	if len(args) > 2 {
+		selectedPages = pageSelectionArgument(args[2])
 	}
 
-	compressedSize := int(float64(originalSize) * compressionRatio)
-
-	// Create a mock compressed PDF
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(0, 10, fmt.Sprintf("Compressed PDF (Compression: %s)", compressionLevel))
+	conf := newPDFConfiguration("")
+	conf.Cmd = model.EXTRACTIMAGES
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfBytes), conf)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to extract images: %v", err),
+		})
+	}
 
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
+	pages, err := api.PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
+	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to compress PDF: %v", err),
+			"error": localize("Invalid pages format: %v", err),
 		})
 	}
+	pageNrs := []int{}
+	for pageNr, selected := range pages {
+		if selected {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+	sort.Ints(pageNrs)
+
+	// Images shared by several pages (logos, backgrounds) are decoded once
+	decoded := map[int]map[string]interface{}{}
+	failed := map[int]error{}
+
+	images := []interface{}{}
+	skipped := []interface{}{}
+	for i, pageNr := range pageNrs {
+		reportProgress(i, len(pageNrs))
+		for _, objNr := range pdfcpu.ImageObjNrs(ctx, pageNr) {
+			if _, seen := decoded[objNr]; !seen && failed[objNr] == nil {
+				image, err := extractImageObject(ctx, objNr)
+				if err != nil {
+					failed[objNr] = err
+				} else {
+					decoded[objNr] = image
+				}
+			}
+
+			if err := failed[objNr]; err != nil {
+				skipped = append(skipped, map[string]interface{}{
+					"page":         pageNr,
+					"objectNumber": objNr,
+					"error":        err.Error(),
+				})
+				continue
+			}
 
-	compressedPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
+			image := map[string]interface{}{"page": pageNr}
+			for key, value := range decoded[objNr] {
+				image[key] = value
+			}
+			images = append(images, image)
+		}
+	}
 
 	if !silentMode {
-		fmt.Printf("Go WASM: Compressed PDF from %d to %d bytes (%s)\n", originalSize, compressedSize, compressionLevel)
+		fmt.Printf("Go WASM: Extracted %d images from PDF (%d skipped)\n", len(images), len(skipped))
 	}
 
 	return js.ValueOf(map[string]interface{}{
-		"pdfData":          compressedPdfData,
-		"originalSize":     originalSize,
-		"compressedSize":   buf.Len(),
-		"compressionRatio": math.Round((1.0-float64(buf.Len())/float64(originalSize))*100*100) / 100,
-		"compressionLevel": compressionLevel,
-		"format":           "application/pdf",
+		"images":  images,
+		"count":   len(images),
+		"skipped": skipped,
 	})
 }
 
-// generateInvoice - Generate professional invoice PDF
-func generateInvoice(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "generateInvoice requires exactly 1 argument (invoiceData)",
-		})
-	}
+// imageMimeTypes maps the file types rendered by pdfcpu to the format and MIME type reported by extractImages
+var imageMimeTypes = map[string][2]string{
+	"jpg": {"jpeg", "image/jpeg"},
+	"png": {"png", "image/png"},
+	"tif": {"tiff", "image/tiff"},
+	"jpx": {"jpeg2000", "image/jp2"},
+}
 
-	invoiceJSON := args[0].String()
-	var invoice InvoiceData
-	if err := json.Unmarshal([]byte(invoiceJSON), &invoice); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid invoice data format: %v", err),
-		})
+// extractImageObject decodes one image XObject: DCT stays JPEG, JPX stays JPEG 2000, Flate, CCITT
+// and RunLength images are rendered to PNG (or TIFF for CMYK)
+func extractImageObject(ctx *model.Context, objNr int) (map[string]interface{}, error) {
+	imageObject := ctx.Optimize.ImageObjects[objNr]
+	if imageObject == nil || imageObject.ImageDict == nil {
+		return nil, errors.New(localize("image object not found"))
+	}
+	name := ""
+	if len(imageObject.ResourceNames) > 0 {
+		name = imageObject.ResourceNames[0]
 	}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetMargins(20, 20, 20)
+	// The stub only reads the dictionary, so it must run before decoding replaces the stream content
+	stub, err := pdfcpu.ExtractImage(ctx, imageObject.ImageDict, false, name, objNr, true)
+	if err != nil {
+		return nil, err
+	}
+	image, err := pdfcpu.ExtractImage(ctx, imageObject.ImageDict, false, name, objNr, false)
+	if err != nil {
+		return nil, err
+	}
+	if stub == nil || image == nil || image.Reader == nil {
+		return nil, errors.New(localize("unsupported image encoding"))
+	}
 
-	// Header
-	pdf.SetFont("Arial", "B", 20)
-	pdf.Cell(0, 15, "FACTURE")
-	pdf.Ln(20)
+	data, err := io.ReadAll(image.Reader)
+	if err != nil {
+		return nil, err
+	}
+	format, ok := imageMimeTypes[image.FileType]
+	if !ok {
+		format = [2]string{image.FileType, "application/octet-stream"}
+	}
 
-	// Invoice info
-	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(90, 8, fmt.Sprintf("Numéro: %s", invoice.Number))
-	pdf.Cell(90, 8, fmt.Sprintf("Date: %s", invoice.Date))
-	pdf.Ln(6)
-	pdf.Cell(90, 8, fmt.Sprintf("Échéance: %s", invoice.DueDate))
-	pdf.Ln(15)
+	return map[string]interface{}{
+		"name":             name,
+		"objectNumber":     objNr,
+		"format":           format[0],
+		"mimeType":         format[1],
+		"width":            stub.Width,
+		"height":           stub.Height,
+		"bitsPerComponent": stub.Bpc,
+		"colorSpace":       stub.Cs,
+		"filter":           stub.Filter,
+		"imageMask":        stub.IsImgMask,
+		"softMask":         stub.HasSMask,
+		"size":             len(data),
+		"data":             dataURLOutput(format[1], data),
+	}, nil
+}
 
-	// Company info
-	pdf.SetFont("Arial", "B", 12)
-	pdf.Cell(0, 8, "Émetteur:")
-	pdf.Ln(8)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 6, invoice.Company.Name)
-	pdf.Ln(5)
-	pdf.Cell(0, 6, invoice.Company.Address)
-	pdf.Ln(5)
-	pdf.Cell(0, 6, fmt.Sprintf("Tél: %s | Email: %s", invoice.Company.Phone, invoice.Company.Email))
-	pdf.Ln(15)
+// mergePDFs - Combine multiple PDFs
+func mergePDFs(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "mergePDFs", "pdfArray"),
+		})
+	}
 
-	// Client info
-	pdf.SetFont("Arial", "B", 12)
-	pdf.Cell(0, 8, "Facturé à:")
-	pdf.Ln(8)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 6, invoice.Client.Name)
-	pdf.Ln(5)
-	pdf.Cell(0, 6, invoice.Client.Address)
-	pdf.Ln(15)
+	password := optionalPassword(args, 1)
 
-	// Items table
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(80, 8, "Description")
-	pdf.Cell(25, 8, "Qté")
-	pdf.Cell(30, 8, "Prix unit.")
-	pdf.Cell(35, 8, "Total")
-	pdf.Ln(8)
+	pdfArray, err := pdfArrayFromJS(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF array format: %v", err),
+		})
+	}
 
-	pdf.SetFont("Arial", "", 10)
-	subtotal := 0.0
-	for _, item := range invoice.Items {
-		pdf.Cell(80, 8, item.Description)
-		pdf.Cell(25, 8, fmt.Sprintf("%.0f", item.Quantity))
-		pdf.Cell(30, 8, fmt.Sprintf("%.2f %s", item.Price, invoice.Currency))
-		pdf.Cell(35, 8, fmt.Sprintf("%.2f %s", item.Total, invoice.Currency))
-		pdf.Ln(8)
-		subtotal += item.Total
+	if len(pdfArray) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("At least 2 PDFs are required for merging"),
+		})
 	}
 
-	// Totals
-	pdf.Ln(5)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(135, 8, "Sous-total:")
-	pdf.Cell(35, 8, fmt.Sprintf("%.2f %s", subtotal, invoice.Currency))
-	pdf.Ln(8)
+	readers := make([]io.ReadSeeker, len(pdfArray))
+	for i, pdfData := range pdfArray {
+		pdfBytes, err := decodePDFData(pdfData, password)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid PDF data at index %d: %v", i, err),
+			})
+		}
+		readers[i] = bytes.NewReader(pdfBytes)
+		reportProgress(i+1, len(pdfArray))
+	}
 
-	if invoice.Discount > 0 {
-		discount := subtotal * invoice.Discount / 100
-		pdf.Cell(135, 8, fmt.Sprintf("Remise (%.1f%%):", invoice.Discount))
-		pdf.Cell(35, 8, fmt.Sprintf("-%.2f %s", discount, invoice.Currency))
-		pdf.Ln(8)
-		subtotal -= discount
+	var buf bytes.Buffer
+	if err := api.MergeRaw(readers, &buf, false, newPDFConfiguration("")); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to merge PDFs: %v", err),
+		})
 	}
 
-	if invoice.Tax > 0 {
-		tax := subtotal * invoice.Tax / 100
-		pdf.Cell(135, 8, fmt.Sprintf("TVA (%.1f%%):", invoice.Tax))
-		pdf.Cell(35, 8, fmt.Sprintf("%.2f %s", tax, invoice.Currency))
-		pdf.Ln(8)
-		subtotal += tax
+	totalPages, err := api.PageCount(bytes.NewReader(buf.Bytes()), newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read modified PDF: %v", err),
+		})
 	}
 
-	pdf.Cell(135, 8, "TOTAL:")
-	pdf.Cell(35, 8, fmt.Sprintf("%.2f %s", subtotal, invoice.Currency))
+	mergedPdfData := binaryOutput(buf.Bytes())
 
-	// Notes
-	if invoice.Notes != "" {
-		pdf.Ln(20)
-		pdf.SetFont("Arial", "", 10)
-		pdf.MultiCell(0, 6, "Notes: "+invoice.Notes, "", "", false)
+	if !silentMode {
+		fmt.Printf("Go WASM: Merged %d PDFs into %d pages\n", len(pdfArray), totalPages)
 	}
 
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":     mergedPdfData,
+		"size":        buf.Len(),
+		"pages":       totalPages,
+		"sourceCount": len(pdfArray),
+		"format":      "application/pdf",
+	})
+}
+
+// splitPDF - Split PDF into parts
+func splitPDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "splitPDF", "pdfData, ranges"),
+		})
+	}
+
+	pdfData := args[0]
+	rangesJSON := args[1].String()
+
+	pdfBytes, err := decodePDFData(pdfData, optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	var ranges []string
+	if err := json.Unmarshal([]byte(rangesJSON), &ranges); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid ranges format: %v", err),
+		})
+	}
+
+	if len(ranges) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("No pages selected"),
+		})
+	}
+
+	conf := newPDFConfiguration("")
+	conf.Cmd = model.TRIM
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfBytes), conf)
+	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate invoice: %v", err),
+			"error": localize("Invalid PDF data: %v", err),
 		})
 	}
 
-	invoicePdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
+	// Each range is a page selection such as "1-3" or "4,6" extracted from the source document
+	var splitPDFs []interface{}
+	for i, pageRange := range ranges {
+		pages, err := api.PagesForPageSelection(ctx.PageCount, strings.Split(pageRange, ","), false, true)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to create split PDF %d: %v", i+1, err),
+			})
+		}
+		pageNrs := []int{}
+		for pageNr, selected := range pages {
+			if selected {
+				pageNrs = append(pageNrs, pageNr)
+			}
+		}
+		if len(pageNrs) == 0 {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to create split PDF %d: %v", i+1, localize("No pages selected")),
+			})
+		}
+		sort.Ints(pageNrs)
+
+		part, err := pdfcpu.ExtractPages(ctx, pageNrs, false)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to create split PDF %d: %v", i+1, err),
+			})
+		}
+		var buf bytes.Buffer
+		if err := api.WriteContext(part, &buf); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to create split PDF %d: %v", i+1, err),
+			})
+		}
+
+		splitPDFs = append(splitPDFs, map[string]interface{}{
+			"pdfData":   binaryOutput(buf.Bytes()),
+			"pageRange": pageRange,
+			"pages":     len(pageNrs),
+			"size":      buf.Len(),
+			"partIndex": i + 1,
+		})
+		reportProgress(i+1, len(ranges))
+	}
 
 	if !silentMode {
-		fmt.Printf("Go WASM: Generated invoice %s (%d bytes)\n", invoice.Number, buf.Len())
+		fmt.Printf("Go WASM: Split PDF into %d parts\n", len(splitPDFs))
 	}
 
 	return js.ValueOf(map[string]interface{}{
-		"pdfData":     invoicePdfData,
-		"size":        buf.Len(),
-		"invoiceNumber": invoice.Number,
-		"total":       subtotal,
-		"currency":    invoice.Currency,
-		"format":      "application/pdf",
+		"splitPDFs": splitPDFs,
+		"parts":     len(splitPDFs),
+		"original":  len(pdfBytes),
 	})
 }
 
-// generateCertificate - Generate certificate PDF
-func generateCertificate(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
+// rotatePages - Rotate pages of an existing PDF clockwise by a multiple of 90 degrees
+func rotatePages(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
 		return js.ValueOf(map[string]interface{}{
-			"error": "generateCertificate requires exactly 1 argument (certificateData)",
+			"error": localize("%s requires at least 2 arguments (%s)", "rotatePages", "pdfData, rotation"),
 		})
 	}
 
-	certJSON := args[0].String()
-	var cert CertificateData
-	if err := json.Unmarshal([]byte(certJSON), &cert); err != nil {
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 3))
+	if err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid certificate data format: %v", err),
+			"error": localize("Invalid PDF data: %v", err),
 		})
 	}
 
-	pdf := gofpdf.New("L", "mm", "A4", "")
-	pdf.AddPage()
+	rotation := args[1].Int()
+	if rotation%90 != 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Rotation must be a multiple of 90 degrees, got %d", rotation),
+		})
+	}
 
-	// Border
-	pdf.Rect(10, 10, 277, 190, "D")
-	pdf.Rect(15, 15, 267, 180, "D")
+	// All pages unless a selection such as "1-3,even" is given
+	var selectedPages []string
+	if len(args) > 2 {
+		selectedPages = pageSelectionArgument(args[2])
+	}
 
-	// Title
-	pdf.SetFont("Arial", "B", 24)
-	pdf.SetY(40)
-	pdf.CellFormat(0, 20, cert.Title, "", 0, "C", false, 0, "")
-	pdf.Ln(30)
+	var buf bytes.Buffer
+	if err := api.Rotate(bytes.NewReader(pdfBytes), &buf, rotation, selectedPages, newPDFConfiguration("")); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to rotate pages: %v", err),
+		})
+	}
 
-	// Main text
-	pdf.SetFont("Arial", "", 16)
-	pdf.CellFormat(0, 10, "Ce certificat atteste que", "", 0, "C", false, 0, "")
-	pdf.Ln(20)
+	return pageOperationResult(buf.Bytes(), "Rotated pages by %d degrees", rotation)
+}
 
-	// Recipient name
-	pdf.SetFont("Arial", "B", 20)
-	pdf.CellFormat(0, 15, cert.Recipient, "", 0, "C", false, 0, "")
-	pdf.Ln(25)
+// reorderPages - Rebuild a PDF with its pages in a new order. The order is a page selection such
+// as "3,1,2,4-" or an array of page numbers; pages left out are dropped and repeated pages duplicated.
+func reorderPages(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "reorderPages", "pdfData, order"),
+		})
+	}
 
-	// Achievement
-	pdf.SetFont("Arial", "", 14)
-	pdf.CellFormat(0, 10, cert.Achievement, "", 0, "C", false, 0, "")
-	pdf.Ln(30)
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
 
-	// Date and issuer
-	pdf.SetFont("Arial", "", 12)
-	pdf.SetY(150)
-	pdf.Cell(80, 10, fmt.Sprintf("Date: %s", cert.Date))
-	pdf.Cell(117, 10, fmt.Sprintf("Émis par: %s", cert.Issuer))
+	order := pageSelectionArgument(args[1])
+	if len(order) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("No pages selected"),
+		})
+	}
 
 	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
+	if err := api.Collect(bytes.NewReader(pdfBytes), &buf, order, newPDFConfiguration("")); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to generate certificate: %v", err),
+			"error": localize("Failed to reorder pages: %v", err),
 		})
 	}
 
-	certificatePdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
+	return pageOperationResult(buf.Bytes(), "Reordered pages (%s)", strings.Join(order, ","))
+}
 
-	if !silentMode {
-		fmt.Printf("Go WASM: Generated certificate for %s (%d bytes)\n", cert.Recipient, buf.Len())
+// deletePages - Remove the selected pages from an existing PDF; at least one page must remain
+func deletePages(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "deletePages", "pdfData, pages"),
+		})
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"pdfData":    certificatePdfData,
-		"size":       buf.Len(),
-		"recipient":  cert.Recipient,
-		"format":     "application/pdf",
-	})
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	selectedPages := pageSelectionArgument(args[1])
+	pageCount, err := api.PageCount(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read PDF: %v", err),
+		})
+	}
+	pages, err := api.PagesForPageSelection(pageCount, selectedPages, true, true)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid pages format: %v", err),
+		})
+	}
+	// pdfcpu writes nothing for an empty selection and an invalid document without pages
+	removed := 0
+	for _, selected := range pages {
+		if selected {
+			removed++
+		}
+	}
+	if len(selectedPages) == 0 || removed == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("No pages selected"),
+		})
+	}
+	if removed >= pageCount {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Cannot delete all %d pages", pageCount),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := api.RemovePages(bytes.NewReader(pdfBytes), &buf, selectedPages, newPDFConfiguration("")); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to delete pages: %v", err),
+		})
+	}
+
+	return pageOperationResult(buf.Bytes(), "Deleted pages %s", strings.Join(selectedPages, ","))
 }
 
-// addTable - Add formatted table to PDF
-func addTable(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
+// BlankPageOptions configures insertBlankPage. The blank page takes the size of its neighbour
+// unless a paper size such as "A4" or "Letter" is given.
+type BlankPageOptions struct {
+	Before      bool   `json:"before"`
+	Size        string `json:"size"`
+	Orientation string `json:"orientation"`
+}
+
+// insertBlankPage - Insert a blank page after (or before) each selected page, "l" selects the last page
+func insertBlankPage(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "insertBlankPage", "pdfData, pages"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 3))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	selectedPages := pageSelectionArgument(args[1])
+	if len(selectedPages) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("No pages selected"),
+		})
+	}
+
+	var options BlankPageOptions
+	if len(args) > 2 && args[2].Type() != js.TypeUndefined && args[2].Type() != js.TypeNull {
+		if err := json.Unmarshal([]byte(jsonArgument(args[2])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options format: %v", err),
+			})
+		}
+	}
+
+	var pageConf *pdfcpu.PageConfiguration
+	if options.Size != "" {
+		dim, ok := types.PaperSize[options.Size]
+		if !ok {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Unknown page size %q", options.Size),
+			})
+		}
+		size := *dim
+		if landscape := strings.HasPrefix(strings.ToLower(options.Orientation), "l"); landscape != (size.Width > size.Height) {
+			size.Width, size.Height = size.Height, size.Width
+		}
+		pageConf = &pdfcpu.PageConfiguration{PageDim: &size}
+	}
+
+	var buf bytes.Buffer
+	if err := api.InsertPages(bytes.NewReader(pdfBytes), &buf, selectedPages, options.Before, pageConf, newPDFConfiguration("")); err != nil {
 		return js.ValueOf(map[string]interface{}{
-			"error": "addTable requires exactly 2 arguments (pdfData, tableData)",
+			"error": localize("Failed to insert blank pages: %v", err),
 		})
 	}
-
-	pdfDataStr := args[0].String()
-	tableJSON := args[1].String()
-
-	_, err := base64.StdEncoding.DecodeString(pdfDataStr)
-	if err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
-		})
+
+	return pageOperationResult(buf.Bytes(), "Inserted blank pages at %s", strings.Join(selectedPages, ","))
+}
+
+// pageSelectionArgument reads a page selection given as a string such as "1-3,5,even" or as an array
+// of page numbers and selectors
+func pageSelectionArgument(value js.Value) []string {
+	var selection []string
+	switch {
+	case value.Type() == js.TypeNumber:
+		selection = []string{strconv.Itoa(value.Int())}
+	case value.Type() == js.TypeString:
+		selection = strings.Split(value.String(), ",")
+	case value.Type() == js.TypeObject && js.Global().Get("Array").Call("isArray", value).Bool():
+		for i := 0; i < value.Length(); i++ {
+			if item := value.Index(i); item.Type() == js.TypeNumber {
+				selection = append(selection, strconv.Itoa(item.Int()))
+			} else {
+				selection = append(selection, item.String())
+			}
+		}
+	}
+
+	pages := []string{}
+	for _, page := range selection {
+		if page = strings.TrimSpace(page); page != "" {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// pageOperationResult reports a rewritten document with its page count
+func pageOperationResult(pdfBytes []byte, message string, args ...interface{}) interface{} {
+	pageCount, err := api.PageCount(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read modified PDF: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: "+message+" (%d pages)\n", append(args, pageCount)...)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData": binaryOutput(pdfBytes),
+		"size":    len(pdfBytes),
+		"pages":   pageCount,
+		"format":  "application/pdf",
+	})
+}
+
+// AttachmentOptions configures attachFile. Relationship (Data, Source, Alternative, Supplement or
+// Unspecified) also lists the file as an associated file of the document, as PDF/A-3 requires.
+type AttachmentOptions struct {
+	MimeType     string `json:"mimeType"`
+	Relationship string `json:"relationship"`
+	Modified     string `json:"modified"`
+}
+
+// attachFile - Embed a file in an existing PDF; a file with the same name is replaced
+func attachFile(this js.Value, args []js.Value) interface{} {
+	if len(args) < 3 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 3 arguments (%s)", "attachFile", "pdfData, filename, fileData"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 5))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	filename := strings.TrimSpace(args[1].String())
+	if filename == "" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Attachment filename is required"),
+		})
+	}
+	fileData, err := bytesFromJS(args[2])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid attachment data: %v", err),
+		})
+	}
+	description := ""
+	if len(args) > 3 && args[3].Type() == js.TypeString {
+		description = args[3].String()
+	}
+
+	var options AttachmentOptions
+	if len(args) > 4 && args[4].Type() != js.TypeUndefined && args[4].Type() != js.TypeNull {
+		if err := json.Unmarshal([]byte(jsonArgument(args[4])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options format: %v", err),
+			})
+		}
+	}
+	switch options.Relationship {
+	case "", "Data", "Source", "Alternative", "Supplement", "Unspecified":
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("unknown relationship %q", options.Relationship),
+		})
+	}
+	modified := time.Now()
+	if options.Modified != "" {
+		if modified, err = time.Parse(time.RFC3339, options.Modified); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options format: %v", err),
+			})
+		}
+	}
+	mimeType := options.MimeType
+	if mimeType == "" {
+		mimeType, _, _ = strings.Cut(mime.TypeByExtension(path.Ext(filename)), ";")
+	}
+
+	buf, err := embedAttachment(pdfBytes, model.Attachment{
+		Reader:  bytes.NewReader(fileData),
+		ID:      filename,
+		Desc:    description,
+		ModTime: &modified,
+	}, mimeType, options.Relationship)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to attach file: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Attached %s (%d bytes) to PDF\n", filename, len(fileData))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":  binaryOutput(buf),
+		"size":     len(buf),
+		"filename": filename,
+		"fileSize": len(fileData),
+		"mimeType": mimeType,
+		"format":   "application/pdf",
+	})
+}
+
+// embedAttachment adds an attachment to the EmbeddedFiles name tree, then sets the MIME type of the
+// embedded stream and the associated file relationship that pdfcpu does not write
+func embedAttachment(pdfBytes []byte, attachment model.Attachment, mimeType, relationship string) ([]byte, error) {
+	// Validation builds the name trees, a plain read would lose the files already embedded
+	conf := newPDFConfiguration("")
+	conf.Cmd = model.ADDATTACHMENTS
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfBytes), conf)
+	if err != nil {
+		return nil, err
+	}
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	if tree := ctx.Names["EmbeddedFiles"]; tree != nil {
+		if previous, found := tree.Value(attachment.ID); found {
+			// The replaced file is no longer an associated file of the document
+			if associated, _ := ctx.DereferenceArray(rootDict["AF"]); associated != nil {
+				kept := types.Array{}
+				for _, ref := range associated {
+					if ref != previous {
+						kept = append(kept, ref)
+					}
+				}
+				if len(kept) == 0 {
+					rootDict.Delete("AF")
+				} else {
+					rootDict.Update("AF", kept)
+				}
+			}
+			if _, err := ctx.RemoveAttachments([]string{attachment.ID}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := ctx.AddAttachment(attachment, false); err != nil {
+		return nil, err
+	}
+
+	specRef, ok := ctx.Names["EmbeddedFiles"].Value(attachment.ID)
+	if !ok {
+		return nil, errors.New(localize("attachment %q was not added", attachment.ID))
+	}
+	fileSpec, err := ctx.DereferenceDict(specRef)
+	if err != nil {
+		return nil, err
+	}
+	// Drop the empty collection item pdfcpu adds for portfolios
+	fileSpec.Delete("CI")
+	if mimeType != "" {
+		if ef := fileSpec.DictEntry("EF"); ef != nil {
+			if stream, _, err := ctx.DereferenceStreamDict(ef["F"]); err == nil && stream != nil {
+				stream.InsertName("Subtype", mimeType)
+			}
+		}
+	}
+	if relationship != "" {
+		fileSpec.InsertName("AFRelationship", relationship)
+		associated, _ := ctx.DereferenceArray(rootDict["AF"])
+		rootDict.Update("AF", append(associated, specRef))
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// listAttachments - List the files embedded in a PDF, with their content when includeData is true
+func listAttachments(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "listAttachments", "pdfData"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+	includeData := len(args) > 1 && args[1].Type() == js.TypeBoolean && args[1].Bool()
+
+	conf := newPDFConfiguration("")
+	conf.Cmd = model.LISTATTACHMENTS
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfBytes), conf)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to list attachments: %v", err),
+		})
+	}
+
+	attachments := []interface{}{}
+	if tree := ctx.Names["EmbeddedFiles"]; tree != nil {
+		err = tree.Process(ctx.XRefTable, func(xRefTable *model.XRefTable, id string, o *types.Object) error {
+			attachment, err := attachmentInfo(xRefTable, id, *o, includeData)
+			if err != nil {
+				return err
+			}
+			attachments = append(attachments, attachment)
+			return nil
+		})
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to list attachments: %v", err),
+			})
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Found %d attachments in PDF\n", len(attachments))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"attachments": attachments,
+		"count":       len(attachments),
+	})
+}
+
+// attachmentInfo describes one file specification of the EmbeddedFiles name tree
+func attachmentInfo(xRefTable *model.XRefTable, id string, o types.Object, includeData bool) (map[string]interface{}, error) {
+	fileSpec, err := xRefTable.DereferenceDict(o)
+	if err != nil {
+		return nil, err
+	}
+	text := func(d types.Dict, key string) string {
+		if value, found := d.Find(key); found {
+			s, _ := xRefTable.DereferenceStringOrHexLiteral(value, model.V10, nil)
+			return s
+		}
+		return ""
+	}
+
+	filename := text(fileSpec, "UF")
+	if filename == "" {
+		filename = text(fileSpec, "F")
+	}
+	if filename == "" {
+		filename = id
+	}
+	info := map[string]interface{}{
+		"name":         filename,
+		"description":  text(fileSpec, "Desc"),
+		"relationship": "",
+		"mimeType":     "",
+		"size":         0,
+		"modified":     "",
+	}
+	if relationship := fileSpec.NameEntry("AFRelationship"); relationship != nil {
+		info["relationship"] = *relationship
+	}
+
+	ef := fileSpec.DictEntry("EF")
+	if ef == nil {
+		return info, nil
+	}
+	stream, _, err := xRefTable.DereferenceStreamDict(ef["F"])
+	if err != nil || stream == nil {
+		return info, err
+	}
+	if subtype := stream.NameEntry("Subtype"); subtype != nil {
+		info["mimeType"] = *subtype
+	}
+	if params := stream.DictEntry("Params"); params != nil {
+		if size := params.IntEntry("Size"); size != nil {
+			info["size"] = *size
+		}
+		if date := text(params, "ModDate"); date != "" {
+			if t, ok := types.DateTime(date, true); ok {
+				info["modified"] = t.UTC().Format(time.RFC3339)
+			}
+		}
+	}
+	if err := stream.Decode(); err != nil {
+		return nil, err
+	}
+	info["size"] = len(stream.Content)
+	if includeData {
+		info["data"] = binaryOutput(stream.Content)
+	}
+	return info, nil
+}
+
+// addWatermark - Add watermark to PDF
+func addWatermark(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "addWatermark", "pdfData, watermarkData"),
+		})
+	}
+
+	pdfData := args[0]
+	watermarkJSON := args[1].String()
+
+	pdfBytes, err := decodePDFData(pdfData, optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	var watermark PDFWatermark
+	if err := json.Unmarshal([]byte(watermarkJSON), &watermark); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid watermark format: %v", err),
+		})
+	}
+
+	if watermark.Text == "" && len(watermark.Image) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Watermark requires a text or an image"),
+		})
+	}
+
+	opacity := watermark.Opacity
+	if opacity == 0 {
+		opacity = 0.3
+	}
+	rotation := 45.0
+	if watermark.Rotation != nil {
+		rotation = *watermark.Rotation
+	}
+
+	// pdfcpu watermark description, see "pdfcpu stamp" for the available keys
+	desc := []string{
+		fmt.Sprintf("opacity:%g", opacity),
+		fmt.Sprintf("rotation:%g", rotation),
+	}
+	if watermark.Position != "" {
+		desc = append(desc, "position:"+watermark.Position)
+	}
+	switch {
+	case watermark.Size > 0:
+		desc = append(desc, fmt.Sprintf("points:%d", int(watermark.Size)), "scalefactor:1 abs")
+	case watermark.Scale > 0:
+		desc = append(desc, fmt.Sprintf("scalefactor:%g rel", watermark.Scale))
+	}
+	onTop := !watermark.Behind
+	var wm *model.Watermark
+	if len(watermark.Image) > 0 {
+		imageBytes, imgErr := decodeImageData(watermark.Image)
+		if imgErr != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid watermark image: %v", imgErr),
+			})
+		}
+		wm, err = api.ImageWatermarkForReader(bytes.NewReader(imageBytes), strings.Join(desc, ", "), onTop, false, types.POINTS)
+	} else {
+		color := watermark.Color
+		if color == "" {
+			color = "#808080"
+		}
+		desc = append(desc, "fillcolor:"+color)
+		wm, err = api.TextWatermark(watermark.Text, strings.Join(desc, ", "), onTop, false, types.POINTS)
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid watermark settings: %v", err),
+		})
+	}
+
+	var selectedPages []string
+	if watermark.Pages != "" {
+		selectedPages = strings.Split(watermark.Pages, ",")
+	}
+
+	var buf bytes.Buffer
+	if err := api.AddWatermarks(bytes.NewReader(pdfBytes), &buf, selectedPages, wm, newPDFConfiguration("")); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to add watermark: %v", err),
+		})
+	}
+
+	pageCount, err := api.PageCount(bytes.NewReader(buf.Bytes()), newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read watermarked PDF: %v", err),
+		})
+	}
+
+	watermarkedPdfData := binaryOutput(buf.Bytes())
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Added watermark '%s' to %d pages\n", watermark.Text, pageCount)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":   watermarkedPdfData,
+		"size":      buf.Len(),
+		"watermark": watermark.Text,
+		"opacity":   opacity,
+		"rotation":  rotation,
+		"pages":     pageCount,
+		"format":    "application/pdf",
+	})
+}
+
+// addHeader - Stamp a running header onto the pages of an existing PDF
+func addHeader(this js.Value, args []js.Value) interface{} {
+	return stampRunningText("addHeader", "top", "", args)
+}
+
+// addFooter - Stamp a running footer onto the pages of an existing PDF
+func addFooter(this js.Value, args []js.Value) interface{} {
+	return stampRunningText("addFooter", "bottom", "", args)
+}
+
+// addPageNumbers - Stamp "Page X of Y" style page numbers onto an existing PDF
+func addPageNumbers(this js.Value, args []js.Value) interface{} {
+	return stampRunningText("addPageNumbers", "bottom", "Page {page} of {total}", args)
+}
+
+// stampRunningText implements addHeader, addFooter and addPageNumbers.
+// The text may contain {page} and {total} placeholders which are resolved per page.
+func stampRunningText(operation, defaultPosition, defaultFormat string, args []js.Value) interface{} {
+	if len(args) < 1 || (defaultFormat == "" && len(args) < 2) {
+		usage := "pdfData, options"
+		if defaultFormat != "" {
+			usage = "pdfData, options?"
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires arguments (%s)", operation, usage),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	// Options may be a JSON object or simply the text to print
+	var opts PDFHeaderFooter
+	if len(args) > 1 && args[1].Type() == js.TypeString && args[1].String() != "" {
+		optionsStr := args[1].String()
+		if strings.HasPrefix(strings.TrimSpace(optionsStr), "{") {
+			if err := json.Unmarshal([]byte(optionsStr), &opts); err != nil {
+				return js.ValueOf(map[string]interface{}{
+					"error": localize("Invalid options format: %v", err),
+				})
+			}
+		} else {
+			opts.Text = optionsStr
+		}
+	}
+
+	text := opts.Text
+	if text == "" {
+		text = opts.Format
+	}
+	if text == "" {
+		text = defaultFormat
+	}
+	if text == "" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires a text", operation),
+		})
+	}
+
+	position := opts.Position
+	if position == "" {
+		position = defaultPosition
+	}
+	if position != "top" && position != "bottom" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid position '%s' (expected top or bottom)", position),
+		})
+	}
+
+	align := opts.Align
+	if align == "" {
+		align = "center"
+	}
+	anchor := position[:1]
+	switch align {
+	case "left":
+		anchor += "l"
+	case "center":
+		anchor += "c"
+	case "right":
+		anchor += "r"
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid align '%s' (expected left, center or right)", align),
+		})
+	}
+
+	fontSize := opts.FontSize
+	if fontSize == 0 {
+		fontSize = 9
+	}
+	color := opts.Color
+	if color == "" {
+		color = "#000000"
+	}
+	opacity := opts.Opacity
+	if opacity == 0 {
+		opacity = 1
+	}
+	// Margins are in mm like the rest of the generation API
+	marginY, marginX := 10.0, 15.0
+	if opts.Margin != nil {
+		marginY = *opts.Margin
+	}
+	if opts.MarginX != nil {
+		marginX = *opts.MarginX
+	}
+
+	dx := 0.0
+	if anchor[1] == 'l' {
+		dx = marginX
+	} else if anchor[1] == 'r' {
+		dx = -marginX
+	}
+	dy := marginY
+	if anchor[0] == 't' {
+		dy = -marginY
+	}
+
+	desc := []string{
+		"fontname:" + stampFontName(opts.Font, opts.FontStyle),
+		fmt.Sprintf("points:%d", int(math.Round(fontSize))),
+		"scalefactor:1 abs",
+		"rotation:0",
+		fmt.Sprintf("opacity:%g", opacity),
+		"fillcolor:" + color,
+		"position:" + anchor,
+		fmt.Sprintf("offset:%g %g", dx, dy),
+		"aligntext:" + align,
+	}
+
+	wm, err := api.TextWatermark(pdfcpuPlaceholders(text), strings.Join(desc, ", "), true, false, types.MILLIMETRES)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid %s settings: %v", operation, err),
+		})
+	}
+
+	var selectedPages []string
+	if opts.Pages != "" {
+		selectedPages = strings.Split(opts.Pages, ",")
+	}
+	if opts.SkipFirstPage {
+		if selectedPages == nil {
+			selectedPages = []string{"1-"}
+		}
+		selectedPages = append(selectedPages, "!1")
+	}
+
+	var buf bytes.Buffer
+	if err := api.AddWatermarks(bytes.NewReader(pdfBytes), &buf, selectedPages, wm, newPDFConfiguration("")); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to stamp %s: %v", position, err),
+		})
+	}
+
+	pageCount, err := api.PageCount(bytes.NewReader(buf.Bytes()), newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read stamped PDF: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: %s stamped '%s' on %d pages\n", operation, text, pageCount)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":       binaryOutput(buf.Bytes()),
+		"size":          buf.Len(),
+		"text":          text,
+		"position":      position,
+		"align":         align,
+		"skipFirstPage": opts.SkipFirstPage,
+		"pages":         pageCount,
+		"format":        "application/pdf",
+	})
+}
+
+// pdfcpuPlaceholders converts {page}/{total} templates to pdfcpu's %p/%P syntax
+func pdfcpuPlaceholders(text string) string {
+	text = strings.ReplaceAll(text, "%", "%%")
+	text = strings.ReplaceAll(text, "{page}", "%p")
+	text = strings.ReplaceAll(text, "{total}", "%P")
+	return text
+}
+
+// stampFontName maps gofpdf style font names to the pdfcpu core font names
+func stampFontName(family, style string) string {
+	bold := strings.Contains(strings.ToUpper(style), "B")
+	italic := strings.Contains(strings.ToUpper(style), "I")
+
+	switch strings.ToLower(family) {
+	case "times", "times-roman", "times new roman":
+		switch {
+		case bold && italic:
+			return "Times-BoldItalic"
+		case bold:
+			return "Times-Bold"
+		case italic:
+			return "Times-Italic"
+		}
+		return "Times-Roman"
+	case "courier", "courier new":
+		family = "Courier"
+	default:
+		family = "Helvetica"
+	}
+
+	switch {
+	case bold && italic:
+		return family + "-BoldOblique"
+	case bold:
+		return family + "-Bold"
+	case italic:
+		return family + "-Oblique"
+	}
+	return family
+}
+
+// addBookmarks - Add a nested outline (sidebar table of contents) to an existing PDF
+func addBookmarks(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "addBookmarks", "pdfData, outline"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	outlineJSON := args[1].String()
+	if args[1].Type() == js.TypeObject {
+		outlineJSON = js.Global().Get("JSON").Call("stringify", args[1]).String()
+	}
+	var outline []PDFBookmark
+	if err := json.Unmarshal([]byte(outlineJSON), &outline); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid outline format: %v", err),
+		})
+	}
+	if len(outline) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Outline must contain at least one bookmark"),
+		})
+	}
+
+	pageCount, err := api.PageCount(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read PDF: %v", err),
+		})
+	}
+	if err := validateOutline(outline, pageCount); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid outline: %v", err),
+		})
+	}
+
+	// Any existing outline is replaced
+	var buf bytes.Buffer
+	if err := api.AddBookmarks(bytes.NewReader(pdfBytes), &buf, pdfcpuBookmarks(outline), true, newPDFConfiguration("")); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to add bookmarks: %v", err),
+		})
+	}
+
+	count := countBookmarks(outline)
+	if !silentMode {
+		fmt.Printf("Go WASM: Added %d bookmarks to PDF (%d pages)\n", count, pageCount)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":   binaryOutput(buf.Bytes()),
+		"size":      buf.Len(),
+		"bookmarks": count,
+		"pages":     pageCount,
+		"format":    "application/pdf",
+	})
+}
+
+// validateOutline checks that every bookmark has a title and points to an existing page
+func validateOutline(outline []PDFBookmark, pageCount int) error {
+	for _, bm := range outline {
+		if strings.TrimSpace(bm.Title) == "" {
+			return errors.New(localize("bookmark title is required"))
+		}
+		if bm.Page < 1 || bm.Page > pageCount {
+			return errors.New(localize("bookmark %q points to page %d (document has %d pages)", bm.Title, bm.Page, pageCount))
+		}
+		if err := validateOutline(bm.Children, pageCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addOutline registers gofpdf bookmarks depth-first, which is the order gofpdf nests them in
+func addOutline(pdf *gofpdf.Fpdf, font string, outline []PDFBookmark, level int) {
+	for _, bm := range outline {
+		pdf.SetPage(bm.Page)
+		tr := useFont(pdf, font, "", 12)
+		pdf.Bookmark(tr(bm.Title), level, 0)
+		addOutline(pdf, font, bm.Children, level+1)
+	}
+}
+
+// pdfcpuBookmarks converts outline entries to pdfcpu bookmarks
+func pdfcpuBookmarks(outline []PDFBookmark) []pdfcpu.Bookmark {
+	bms := make([]pdfcpu.Bookmark, 0, len(outline))
+	for _, bm := range outline {
+		bms = append(bms, pdfcpu.Bookmark{
+			Title:    bm.Title,
+			PageFrom: bm.Page,
+			Bold:     bm.Bold,
+			Italic:   bm.Italic,
+			Kids:     pdfcpuBookmarks(bm.Children),
+		})
+	}
+	return bms
+}
+
+func countBookmarks(outline []PDFBookmark) int {
+	count := len(outline)
+	for _, bm := range outline {
+		count += countBookmarks(bm.Children)
+	}
+	return count
+}
+
+// generateReport - Template-based PDF generation
+func generateReport(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 2 arguments (%s)", "generateReport", "data, template"),
+		})
+	}
+
+	dataJSON := args[0].String()
+	templateJSON := args[1].String()
+
+	var reportData map[string]interface{}
+	if err := json.Unmarshal([]byte(dataJSON), &reportData); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid data format: %v", err),
+		})
+	}
+
+	var template PDFTemplate
+	if err := json.Unmarshal([]byte(templateJSON), &template); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid template format: %v", err),
+		})
+	}
+
+	font, _ := reportData["font"].(string)
+
+	pdf := newDocument("P")
+	pdf.AddPage()
+
+	// Header
+	tr := useFont(pdf, font, "B", 16)
+	if title, ok := reportData["title"].(string); ok {
+		pdf.Cell(0, 20, tr(title))
+	} else {
+		pdf.Cell(0, 20, tr(localize("Generated Report")))
+	}
+
+	pdf.Ln(10)
+
+	// Content based on template type
+	tr = useFont(pdf, font, "", 12)
+
+	switch template.Type {
+	case "table":
+		// Generate table report
+		if rows, ok := reportData["rows"].([]interface{}); ok {
+			for i, row := range rows {
+				if rowMap, ok := row.(map[string]interface{}); ok {
+					for key, value := range rowMap {
+						pdf.Cell(90, 10, tr(fmt.Sprintf("%s:", key)))
+						pdf.Cell(90, 10, tr(fmt.Sprintf("%v", value)))
+					}
+					if i < len(rows)-1 {
+						pdf.Ln(5)
+					}
+				}
+				reportProgress(i+1, len(rows))
+			}
+		}
+	case "invoice":
+		// Generate invoice
+		if date, ok := reportData["date"].(string); ok {
+			pdf.Cell(0, 10, tr(localize("Date: %s", date)))
+		}
+		if amount, ok := reportData["amount"].(float64); ok {
+			pdf.Cell(0, 10, tr(localize("Amount: $%.2f", amount)))
+		}
+	default:
+		// Simple text report
+		if content, ok := reportData["content"].(string); ok {
+			pdf.MultiCell(0, 10, tr(content), "", "", false)
+		} else {
+			pdf.MultiCell(0, 10, tr(localize("Report generated from template")), "", "", false)
+		}
+	}
+
+	// Footer
+	pdf.Ln(20)
+	tr = useFont(pdf, font, "I", 10)
+	pdf.Cell(0, 10, tr(localize("Generated on %s", time.Now().Format("2006-01-02 15:04:05"))))
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to generate report: %v", err),
+		})
+	}
+
+	reportPdfData := binaryOutput(buf.Bytes())
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated %s report (%d bytes)\n", template.Type, buf.Len())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":      reportPdfData,
+		"size":         buf.Len(),
+		"templateType": template.Type,
+		"pages":        1,
+		"format":       "application/pdf",
+		"generatedAt":  time.Now().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// getPDFInfo - Get PDF metadata and information
+func getPDFInfo(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "getPDFInfo", "pdfData"),
+		})
+	}
+
+	pdfData := args[0]
+	pdfBytes, err := decodePDFData(pdfData, optionalPassword(args, 1))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	// Simplified PDF info extraction
+	info := map[string]interface{}{
+		"size":       len(pdfBytes),
+		"pages":      1, // Placeholder
+		"version":    "1.4",
+		"encrypted":  false,
+		"title":      "PDF Document",
+		"author":     "PDF-WASM",
+		"subject":    "",
+		"keywords":   "",
+		"creator":    "Go PDF-WASM Module",
+		"producer":   "GoFPDF",
+		"createdAt":  time.Now().Format("2006-01-02T15:04:05Z"),
+		"modifiedAt": time.Now().Format("2006-01-02T15:04:05Z"),
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Retrieved info for PDF (%d bytes)\n", len(pdfBytes))
+	}
+
+	return js.ValueOf(info)
+}
+
+// compressPDF - Compress PDF file
+func compressPDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "compressPDF", "pdfData"),
+		})
+	}
+
+	pdfData := args[0]
+	compressionLevel := "medium"
+	if len(args) > 1 && args[1].Type() == js.TypeString && args[1].String() != "" {
+		compressionLevel = args[1].String()
+	}
+	level, ok := compressionLevels[compressionLevel]
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown level %q (expected %s)", compressionLevel, "low, medium, high"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(pdfData, optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	originalSize := len(pdfBytes)
+	compressed, _, err := rewritePDF(pdfBytes, level)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to compress PDF: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Compressed PDF from %d to %d bytes (%s)\n", originalSize, len(compressed), compressionLevel)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":          binaryOutput(compressed),
+		"originalSize":     originalSize,
+		"compressedSize":   len(compressed),
+		"compressionRatio": math.Round((1.0-float64(len(compressed))/float64(originalSize))*100*100) / 100,
+		"compressionLevel": compressionLevel,
+		"format":           "application/pdf",
+	})
+}
+
+// pdfRewriteLevel selects the steps rewritePDF applies on top of pdfcpu's optimization.
+type pdfRewriteLevel struct {
+	objectStreams bool // pack objects into object streams indexed by an xref stream
+	flateStreams  bool // Flate-encode streams stored without any filter
+}
+
+// compressionLevels and optimizationLevels map the compressPDF and optimizePDF levels onto rewritePDF.
+var (
+	compressionLevels = map[string]pdfRewriteLevel{
+		"low":    {},
+		"medium": {objectStreams: true},
+		"high":   {objectStreams: true, flateStreams: true},
+	}
+	optimizationLevels = map[string]pdfRewriteLevel{
+		"conservative": {},
+		"balanced":     {objectStreams: true},
+		"aggressive":   {objectStreams: true, flateStreams: true},
+	}
+)
+
+// rewritePDF optimizes a decoded PDF with pdfcpu (duplicate fonts and images are merged and
+// unreachable objects dropped), then applies level. It returns the new file and the steps that changed
+// something, or the input unchanged when the rewrite is not smaller.
+func rewritePDF(pdfBytes []byte, level pdfRewriteLevel) ([]byte, []interface{}, error) {
+	conf := newPDFConfiguration("")
+	conf.Cmd = model.OPTIMIZE
+	conf.WriteObjectStream = level.objectStreams
+	conf.WriteXRefStream = level.objectStreams
+
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfBytes), conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	optimizations := []interface{}{}
+	if n := len(ctx.Optimize.DuplicateFonts); n > 0 {
+		optimizations = append(optimizations, fmt.Sprintf("Merged %d duplicate fonts", n))
+	}
+	if n := len(ctx.Optimize.DuplicateImages); n > 0 {
+		optimizations = append(optimizations, fmt.Sprintf("Merged %d duplicate images", n))
+	}
+
+	if level.flateStreams {
+		encoded := 0
+		for _, entry := range ctx.XRefTable.Table {
+			if entry == nil || entry.Free {
+				continue
+			}
+			sd, ok := entry.Object.(types.StreamDict)
+			if !ok || sd.FilterPipeline != nil || len(sd.Raw) == 0 {
+				continue
+			}
+			raw := sd.Raw
+			sd.Content = raw
+			sd.FilterPipeline = []types.PDFFilter{{Name: filter.Flate}}
+			if err := sd.Encode(); err != nil {
+				return nil, nil, err
+			}
+			if len(sd.Raw) >= len(raw) {
+				// Keep streams that do not shrink as they were
+				continue
+			}
+			sd.InsertName("Filter", filter.Flate)
+			entry.Object = sd
+			encoded++
+		}
+		if encoded > 0 {
+			optimizations = append(optimizations, fmt.Sprintf("Flate-encoded %d uncompressed streams", encoded))
+		}
+	}
+
+	if level.objectStreams {
+		optimizations = append(optimizations, "Packed objects into object streams")
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, nil, err
+	}
+	if buf.Len() >= len(pdfBytes) {
+		// Rewriting added more (metadata, xref) than it saved
+		return pdfBytes, []interface{}{}, nil
+	}
+	return buf.Bytes(), optimizations, nil
+}
+
+// generateInvoice - Generate professional invoice PDF
+func generateInvoice(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "generateInvoice", "invoiceData"),
+		})
+	}
+
+	invoiceJSON := args[0].String()
+	var invoice InvoiceData
+	if err := json.Unmarshal([]byte(invoiceJSON), &invoice); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid invoice data format: %v", err),
+		})
+	}
+
+	built, err := buildInvoice(&invoice)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated invoice %s (%d bytes)\n", invoice.Number, len(built.pdf))
+	}
+	return js.ValueOf(built.result(&invoice))
+}
+
+// builtInvoice is a standalone invoice PDF with the values reported to JavaScript
+type builtInvoice struct {
+	pdf     []byte
+	total   float64
+	facturX bool
+	xml     []byte
+}
+
+// result describes the invoice as returned by generateInvoice
+func (b builtInvoice) result(invoice *InvoiceData) map[string]interface{} {
+	result := map[string]interface{}{
+		"pdfData":       binaryOutput(b.pdf),
+		"size":          len(b.pdf),
+		"invoiceNumber": invoice.Number,
+		"total":         b.total,
+		"currency":      invoice.Currency,
+		"format":        "application/pdf",
+		"facturX":       b.facturX,
+	}
+	if b.facturX {
+		result["xml"] = string(b.xml)
+		result["profile"] = "EN 16931"
+	}
+	return result
+}
+
+// buildInvoice renders an invoice as a standalone PDF, with its Factur-X XML embedded when requested.
+// Errors are localized and ready to be returned.
+func buildInvoice(invoice *InvoiceData) (builtInvoice, error) {
+	facturX, err := facturXOptions(invoice.FacturX)
+	if err != nil {
+		return builtInvoice{}, errors.New(localize("Invalid facturX options: %v", err))
+	}
+
+	var xmlData []byte
+	if facturX.Enabled {
+		if xmlData, err = buildFacturXML(invoice, facturX); err != nil {
+			return builtInvoice{}, errors.New(localize("Invalid Factur-X invoice: %v", err))
+		}
+	}
+
+	pdf := newDocument("P")
+	invoiceFont := func(style string, size float64) func(string) string {
+		return useFont(pdf, invoice.Font, style, size)
+	}
+	if facturX.Enabled {
+		pdf.SetTitle(localize("Invoice %s", invoice.Number), true)
+		pdf.SetAuthor(invoice.Company.Name, true)
+
+		// PDF/A-3 only allows embedded fonts, so the core Arial fallback is replaced by the bundled Go fonts
+		if _, ok := registeredFonts[invoice.Font]; !ok {
+			pdf.AddUTF8FontFromBytes("GoInvoice", "", goregular.TTF)
+			pdf.AddUTF8FontFromBytes("GoInvoice", "B", gobold.TTF)
+			invoiceFont = func(style string, size float64) func(string) string {
+				pdf.SetFont("GoInvoice", style, size)
+				return func(text string) string { return text }
+			}
+		}
+	}
+	total := drawInvoice(pdf, invoice, invoiceFont, facturX.Enabled)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return builtInvoice{}, errors.New(localize("Failed to generate invoice: %v", err))
+	}
+
+	pdfBytes := buf.Bytes()
+	if facturX.Enabled {
+		if pdfBytes, err = embedFacturX(pdfBytes, xmlData, facturX); err != nil {
+			return builtInvoice{}, errors.New(localize("Failed to embed Factur-X data: %v", err))
+		}
+	}
+	return builtInvoice{pdf: pdfBytes, total: total, facturX: facturX.Enabled, xml: xmlData}, nil
+}
+
+// drawInvoice draws an invoice on a new page and returns its total. Amounts are rounded to the cent
+// at each step when rounded is set, as Factur-X requires.
+func drawInvoice(pdf *gofpdf.Fpdf, invoice *InvoiceData, invoiceFont func(style string, size float64) func(string) string, rounded bool) float64 {
+	pdf.AddPage()
+	pdf.SetMargins(20, 20, 20)
+
+	// Header
+	tr := invoiceFont("B", 20)
+	pdf.Cell(0, 15, tr(localize("INVOICE")))
+	pdf.Ln(20)
+
+	// Invoice info
+	tr = invoiceFont("", 12)
+	pdf.Cell(90, 8, tr(localize("Number: %s", invoice.Number)))
+	pdf.Cell(90, 8, tr(localize("Date: %s", invoice.Date)))
+	pdf.Ln(6)
+	pdf.Cell(90, 8, tr(localize("Due date: %s", invoice.DueDate)))
+	pdf.Ln(15)
+
+	// Company info
+	tr = invoiceFont("B", 12)
+	pdf.Cell(0, 8, tr(localize("From:")))
+	pdf.Ln(8)
+	tr = invoiceFont("", 10)
+	pdf.Cell(0, 6, tr(invoice.Company.Name))
+	pdf.Ln(5)
+	pdf.Cell(0, 6, tr(invoice.Company.Address))
+	pdf.Ln(5)
+	if locality := invoice.Company.locality(); locality != "" {
+		pdf.Cell(0, 6, tr(locality))
+		pdf.Ln(5)
+	}
+	pdf.Cell(0, 6, tr(localize("Phone: %s | Email: %s", invoice.Company.Phone, invoice.Company.Email)))
+	if invoice.Company.VAT != "" {
+		pdf.Ln(5)
+		pdf.Cell(0, 6, tr(localize("VAT number: %s", invoice.Company.VAT)))
+	}
+	pdf.Ln(15)
+
+	// Client info
+	tr = invoiceFont("B", 12)
+	pdf.Cell(0, 8, tr(localize("Bill to:")))
+	pdf.Ln(8)
+	tr = invoiceFont("", 10)
+	pdf.Cell(0, 6, tr(invoice.Client.Name))
+	pdf.Ln(5)
+	pdf.Cell(0, 6, tr(invoice.Client.Address))
+	if locality := invoice.Client.locality(); locality != "" {
+		pdf.Ln(5)
+		pdf.Cell(0, 6, tr(locality))
+	}
+	if invoice.Client.VAT != "" {
+		pdf.Ln(5)
+		pdf.Cell(0, 6, tr(localize("VAT number: %s", invoice.Client.VAT)))
+	}
+	pdf.Ln(15)
+
+	// Items table
+	tr = invoiceFont("B", 10)
+	pdf.Cell(80, 8, tr(localize("Description")))
+	pdf.Cell(25, 8, tr(localize("Qty")))
+	pdf.Cell(30, 8, tr(localize("Unit price")))
+	pdf.Cell(35, 8, tr(localize("Total")))
+	pdf.Ln(8)
+
+	tr = invoiceFont("", 10)
+	subtotal := 0.0
+	for _, item := range invoice.Items {
+		pdf.Cell(80, 8, tr(item.Description))
+		pdf.Cell(25, 8, tr(strconv.FormatFloat(item.Quantity, 'f', -1, 64)))
+		pdf.Cell(30, 8, tr(fmt.Sprintf("%.2f %s", item.Price, invoice.Currency)))
+		pdf.Cell(35, 8, tr(fmt.Sprintf("%.2f %s", item.Total, invoice.Currency)))
+		pdf.Ln(8)
+		subtotal += item.Total
+	}
+	if rounded {
+		subtotal = roundCents(subtotal)
+	}
+
+	// Totals
+	pdf.Ln(5)
+	tr = invoiceFont("B", 10)
+	pdf.Cell(135, 8, tr(localize("Subtotal:")))
+	pdf.Cell(35, 8, tr(fmt.Sprintf("%.2f %s", subtotal, invoice.Currency)))
+	pdf.Ln(8)
+
+	if invoice.Discount > 0 {
+		discount := subtotal * invoice.Discount / 100
+		if rounded {
+			discount = roundCents(discount)
+		}
+		pdf.Cell(135, 8, tr(localize("Discount (%.1f%%):", invoice.Discount)))
+		pdf.Cell(35, 8, tr(fmt.Sprintf("-%.2f %s", discount, invoice.Currency)))
+		pdf.Ln(8)
+		subtotal -= discount
+	}
+
+	if invoice.Tax > 0 {
+		tax := subtotal * invoice.Tax / 100
+		if rounded {
+			tax = roundCents(tax)
+		}
+		pdf.Cell(135, 8, tr(localize("VAT (%.1f%%):", invoice.Tax)))
+		pdf.Cell(35, 8, tr(fmt.Sprintf("%.2f %s", tax, invoice.Currency)))
+		pdf.Ln(8)
+		subtotal += tax
+	}
+
+	if rounded {
+		subtotal = roundCents(subtotal)
+	}
+	pdf.Cell(135, 8, tr(localize("TOTAL:")))
+	pdf.Cell(35, 8, tr(fmt.Sprintf("%.2f %s", subtotal, invoice.Currency)))
+
+	// Notes
+	if invoice.Notes != "" {
+		pdf.Ln(20)
+		tr = invoiceFont("", 10)
+		pdf.MultiCell(0, 6, tr(localize("Notes: %s", invoice.Notes)), "", "", false)
+	}
+
+	return subtotal
+}
+
+// InvoiceBatchOptions holds the generateInvoiceBatch settings of the template, which otherwise
+// carries the invoice fields shared by the whole batch
+type InvoiceBatchOptions struct {
+	Output string `json:"output"` // "separate" (default) or "merged"
+}
+
+// generateInvoiceBatch - Generate many invoices from a shared template in one call, as one PDF per invoice or a single merged document
+func generateInvoiceBatch(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "generateInvoiceBatch", "invoices"),
+		})
+	}
+
+	var invoices []json.RawMessage
+	if err := json.Unmarshal([]byte(jsonArgument(args[0])), &invoices); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid invoice batch format: %v", err),
+		})
+	}
+	if len(invoices) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invoice batch is empty"),
+		})
+	}
+
+	var template InvoiceData
+	var options InvoiceBatchOptions
+	if len(args) > 1 && args[1].Truthy() {
+		templateJSON := []byte(jsonArgument(args[1]))
+		err := json.Unmarshal(templateJSON, &template)
+		if err == nil {
+			err = json.Unmarshal(templateJSON, &options)
+		}
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid template options: %v", err),
+			})
+		}
+	}
+
+	var merged *gofpdf.Fpdf
+	switch options.Output {
+	case "", "separate":
+	case "merged":
+		merged = newDocument("P")
+		merged.SetTitle(localize("Invoices"), true)
+		merged.SetAuthor(template.Company.Name, true)
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid output %q (use separate or merged)", options.Output),
+		})
+	}
+
+	results := []interface{}{}
+	failed := []interface{}{}
+	// A failed invoice is reported and skipped, it still counts as processed
+	fail := func(index int, number string, message string) {
+		failed = append(failed, map[string]interface{}{
+			"index":         index,
+			"invoiceNumber": number,
+			"error":         message,
+		})
+		reportProgress(index+1, len(invoices))
+	}
+	for i, raw := range invoices {
+		invoice := template.clone()
+		if err := json.Unmarshal(raw, &invoice); err != nil {
+			fail(i, "", localize("Invalid invoice data format: %v", err))
+			continue
+		}
+
+		if merged == nil {
+			built, err := buildInvoice(&invoice)
+			if err != nil {
+				fail(i, invoice.Number, err.Error())
+				continue
+			}
+			result := built.result(&invoice)
+			result["index"] = i
+			results = append(results, result)
+		} else {
+			// Factur-X data is attached to a whole document, so it cannot describe one invoice of a merge
+			if facturX, err := facturXOptions(invoice.FacturX); err != nil || facturX.Enabled {
+				fail(i, invoice.Number, localize("Factur-X invoices need the separate output"))
+				continue
+			}
+			first := merged.PageCount() + 1
+			total := drawInvoice(merged, &invoice, func(style string, size float64) func(string) string {
+				return useFont(merged, invoice.Font, style, size)
+			}, false)
+
+			// The invoice may have run over several pages, its bookmark points to the first one
+			last := merged.PageNo()
+			merged.SetPage(first)
+			tr := useFont(merged, invoice.Font, "", 10)
+			merged.Bookmark(tr(localize("Invoice %s", invoice.Number)), 0, 0)
+			merged.SetPage(last)
+
+			results = append(results, map[string]interface{}{
+				"index":         i,
+				"invoiceNumber": invoice.Number,
+				"total":         total,
+				"currency":      invoice.Currency,
+				"firstPage":     first,
+				"pages":         last - first + 1,
+			})
+		}
+		reportProgress(i+1, len(invoices))
+	}
+
+	if len(results) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error":  localize("No invoice could be generated: %s", failed[0].(map[string]interface{})["error"]),
+			"failed": failed,
+		})
+	}
+
+	if merged == nil {
+		if !silentMode {
+			fmt.Printf("Go WASM: Generated %d invoices (%d failed)\n", len(results), len(failed))
+		}
+		return js.ValueOf(map[string]interface{}{
+			"output":   "separate",
+			"invoices": results,
+			"count":    len(results),
+			"failed":   failed,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := merged.Output(&buf); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to generate invoice: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated %d invoices in one document (%d pages, %d bytes, %d failed)\n", len(results), merged.PageCount(), buf.Len(), len(failed))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"output":   "merged",
+		"pdfData":  binaryOutput(buf.Bytes()),
+		"size":     buf.Len(),
+		"pages":    merged.PageCount(),
+		"invoices": results,
+		"count":    len(results),
+		"failed":   failed,
+		"format":   "application/pdf",
+	})
+}
+
+// clone copies an invoice template so that decoding an invoice over it leaves the template intact:
+// encoding/json reuses the backing arrays of slices and fills existing maps
+func (invoice InvoiceData) clone() InvoiceData {
+	invoice.Items = append([]InvoiceItem(nil), invoice.Items...)
+	invoice.FacturX = append(json.RawMessage(nil), invoice.FacturX...)
+	if invoice.PaymentInfo != nil {
+		info := make(map[string]interface{}, len(invoice.PaymentInfo))
+		for key, value := range invoice.PaymentInfo {
+			info[key] = value
+		}
+		invoice.PaymentInfo = info
+	}
+	return invoice
+}
+
+// facturXOptions - Read the facturX invoice field, either a boolean or an options object
+func facturXOptions(raw json.RawMessage) (FacturXOptions, error) {
+	opts := FacturXOptions{Relationship: "Alternative"}
+	trimmed := strings.TrimSpace(string(raw))
+	switch trimmed {
+	case "", "null", "false":
+		return opts, nil
+	case "true":
+		opts.Enabled = true
+		return opts, nil
+	}
+
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return opts, err
+	}
+	opts.Enabled = true
+	switch opts.Relationship {
+	case "":
+		opts.Relationship = "Alternative"
+	case "Alternative", "Data", "Source":
+	default:
+		return opts, errors.New(localize("relationship must be Alternative, Data or Source"))
+	}
+	return opts, nil
+}
+
+// facturXDate - Convert an invoice date to the CII 102 format (YYYYMMDD)
+func facturXDate(value string) (string, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "20060102", "02/01/2006", "02.01.2006"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return t.Format("20060102"), nil
+		}
+	}
+	return "", errors.New(localize("unrecognized date %q, expected YYYY-MM-DD", value))
+}
+
+// facturXCurrency - Map the invoice currency to its ISO 4217 code
+func facturXCurrency(currency string) (string, error) {
+	switch strings.TrimSpace(currency) {
+	case "€":
+		return "EUR", nil
+	case "$":
+		return "USD", nil
+	case "£":
+		return "GBP", nil
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(currency))
+	if !regexp.MustCompile(`^[A-Z]{3}$`).MatchString(code) {
+		return "", errors.New(localize("currency %q is not an ISO 4217 code", currency))
+	}
+	return code, nil
+}
+
+// xmlText - Escape a value for use as XML character data or attribute
+func xmlText(value string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
+
+// roundCents - Round an amount to the cent, as EN 16931 totals are
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// facturXAmount - Format an amount with the two decimals required by EN 16931
+func facturXAmount(amount float64) string {
+	return strconv.FormatFloat(roundCents(amount), 'f', 2, 64)
+}
+
+// writeFacturXParty - Write a seller or buyer trade party
+func writeFacturXParty(b *strings.Builder, element string, party CompanyInfo) {
+	fmt.Fprintf(b, "      <ram:%s>\n", element)
+	fmt.Fprintf(b, "        <ram:Name>%s</ram:Name>\n", xmlText(party.Name))
+	if party.LegalID != "" {
+		fmt.Fprintf(b, "        <ram:SpecifiedLegalOrganization><ram:ID>%s</ram:ID></ram:SpecifiedLegalOrganization>\n", xmlText(party.LegalID))
+	}
+	b.WriteString("        <ram:PostalTradeAddress>\n")
+	if party.PostalCode != "" {
+		fmt.Fprintf(b, "          <ram:PostcodeCode>%s</ram:PostcodeCode>\n", xmlText(party.PostalCode))
+	}
+	if party.Address != "" {
+		fmt.Fprintf(b, "          <ram:LineOne>%s</ram:LineOne>\n", xmlText(party.Address))
+	}
+	if party.City != "" {
+		fmt.Fprintf(b, "          <ram:CityName>%s</ram:CityName>\n", xmlText(party.City))
+	}
+	fmt.Fprintf(b, "          <ram:CountryID>%s</ram:CountryID>\n", strings.ToUpper(party.Country))
+	b.WriteString("        </ram:PostalTradeAddress>\n")
+	if party.Email != "" {
+		fmt.Fprintf(b, "        <ram:URIUniversalCommunication><ram:URIID schemeID=\"EM\">%s</ram:URIID></ram:URIUniversalCommunication>\n", xmlText(party.Email))
+	}
+	if party.VAT != "" {
+		fmt.Fprintf(b, "        <ram:SpecifiedTaxRegistration><ram:ID schemeID=\"VA\">%s</ram:ID></ram:SpecifiedTaxRegistration>\n", xmlText(party.VAT))
+	}
+	fmt.Fprintf(b, "      </ram:%s>\n", element)
+}
+
+// buildFacturXML - Build the EN 16931 Cross Industry Invoice for the invoice. Missing line
+// totals are filled in from quantity and price so the PDF shows the same amounts as the XML.
+func buildFacturXML(invoice *InvoiceData, opts FacturXOptions) ([]byte, error) {
+	if strings.TrimSpace(invoice.Number) == "" {
+		return nil, errors.New(localize("invoice number is required"))
+	}
+	issueDate, err := facturXDate(invoice.Date)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := facturXCurrency(invoice.Currency)
+	if err != nil {
+		return nil, err
+	}
+	for _, party := range []struct {
+		role string
+		info CompanyInfo
+	}{{"company", invoice.Company}, {"client", invoice.Client}} {
+		if strings.TrimSpace(party.info.Name) == "" {
+			return nil, errors.New(localize("%s name is required", party.role))
+		}
+		if !regexp.MustCompile(`^[A-Za-z]{2}$`).MatchString(party.info.Country) {
+			return nil, errors.New(localize("%s country must be an ISO 3166 alpha-2 code", party.role))
+		}
+	}
+	if invoice.Company.VAT == "" {
+		return nil, errors.New(localize("company VAT number is required"))
+	}
+	if len(invoice.Items) == 0 {
+		return nil, errors.New(localize("at least one item is required"))
+	}
+
+	dueDate := ""
+	if invoice.DueDate != "" {
+		if dueDate, err = facturXDate(invoice.DueDate); err != nil {
+			return nil, err
+		}
+	}
+	terms, _ := invoice.PaymentInfo["terms"].(string)
+	if dueDate == "" && terms == "" {
+		return nil, errors.New(localize("dueDate or paymentInfo.terms is required"))
+	}
+
+	category, exemption := "S", ""
+	if invoice.Tax == 0 {
+		category, exemption = "E", opts.ExemptionReason
+		if exemption == "" {
+			exemption = "Exempt from VAT"
+		}
+	}
+	rate := strconv.FormatFloat(invoice.Tax, 'f', -1, 64)
+	tradeTax := fmt.Sprintf("<ram:TypeCode>VAT</ram:TypeCode><ram:CategoryCode>%s</ram:CategoryCode><ram:RateApplicablePercent>%s</ram:RateApplicablePercent>", category, rate)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<rsm:CrossIndustryInvoice xmlns:rsm="urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100" xmlns:ram="urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100" xmlns:qdt="urn:un:unece:uncefact:data:standard:QualifiedDataType:100" xmlns:udt="urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100">
+  <rsm:ExchangedDocumentContext>
+    <ram:GuidelineSpecifiedDocumentContextParameter><ram:ID>urn:cen.eu:en16931:2017</ram:ID></ram:GuidelineSpecifiedDocumentContextParameter>
+  </rsm:ExchangedDocumentContext>
+  <rsm:ExchangedDocument>
+`)
+	fmt.Fprintf(&b, "    <ram:ID>%s</ram:ID>\n", xmlText(invoice.Number))
+	b.WriteString("    <ram:TypeCode>380</ram:TypeCode>\n")
+	fmt.Fprintf(&b, "    <ram:IssueDateTime><udt:DateTimeString format=\"102\">%s</udt:DateTimeString></ram:IssueDateTime>\n", issueDate)
+	if invoice.Notes != "" {
+		fmt.Fprintf(&b, "    <ram:IncludedNote><ram:Content>%s</ram:Content></ram:IncludedNote>\n", xmlText(invoice.Notes))
+	}
+	b.WriteString("  </rsm:ExchangedDocument>\n  <rsm:SupplyChainTradeTransaction>\n")
+
+	lineTotal := 0.0
+	for i := range invoice.Items {
+		item := &invoice.Items[i]
+		if strings.TrimSpace(item.Description) == "" {
+			return nil, fmt.Errorf(localize("item %d: description is required"), i+1)
+		}
+		if item.Total == 0 {
+			item.Total = item.Quantity * item.Price
+		}
+		item.Total = roundCents(item.Total)
+		lineTotal += item.Total
+
+		b.WriteString("    <ram:IncludedSupplyChainTradeLineItem>\n")
+		fmt.Fprintf(&b, "      <ram:AssociatedDocumentLineDocument><ram:LineID>%d</ram:LineID></ram:AssociatedDocumentLineDocument>\n", i+1)
+		fmt.Fprintf(&b, "      <ram:SpecifiedTradeProduct><ram:Name>%s</ram:Name></ram:SpecifiedTradeProduct>\n", xmlText(item.Description))
+		fmt.Fprintf(&b, "      <ram:SpecifiedLineTradeAgreement><ram:NetPriceProductTradePrice><ram:ChargeAmount>%s</ram:ChargeAmount></ram:NetPriceProductTradePrice></ram:SpecifiedLineTradeAgreement>\n",
+			strconv.FormatFloat(math.Round(item.Price*10000)/10000, 'f', -1, 64))
+		fmt.Fprintf(&b, "      <ram:SpecifiedLineTradeDelivery><ram:BilledQuantity unitCode=\"C62\">%s</ram:BilledQuantity></ram:SpecifiedLineTradeDelivery>\n",
+			strconv.FormatFloat(item.Quantity, 'f', -1, 64))
+		b.WriteString("      <ram:SpecifiedLineTradeSettlement>\n")
+		fmt.Fprintf(&b, "        <ram:ApplicableTradeTax>%s</ram:ApplicableTradeTax>\n", tradeTax)
+		fmt.Fprintf(&b, "        <ram:SpecifiedTradeSettlementLineMonetarySummation><ram:LineTotalAmount>%s</ram:LineTotalAmount></ram:SpecifiedTradeSettlementLineMonetarySummation>\n", facturXAmount(item.Total))
+		b.WriteString("      </ram:SpecifiedLineTradeSettlement>\n    </ram:IncludedSupplyChainTradeLineItem>\n")
+	}
+
+	// Same arithmetic as the totals printed by generateInvoice
+	lineTotal = roundCents(lineTotal)
+	allowance := roundCents(lineTotal * invoice.Discount / 100)
+	basis := lineTotal - allowance
+	taxTotal := roundCents(basis * invoice.Tax / 100)
+	grandTotal := roundCents(basis + taxTotal)
+
+	b.WriteString("    <ram:ApplicableHeaderTradeAgreement>\n")
+	writeFacturXParty(&b, "SellerTradeParty", invoice.Company)
+	writeFacturXParty(&b, "BuyerTradeParty", invoice.Client)
+	b.WriteString("    </ram:ApplicableHeaderTradeAgreement>\n    <ram:ApplicableHeaderTradeDelivery/>\n    <ram:ApplicableHeaderTradeSettlement>\n")
+	if reference, ok := invoice.PaymentInfo["reference"].(string); ok && reference != "" {
+		fmt.Fprintf(&b, "      <ram:PaymentReference>%s</ram:PaymentReference>\n", xmlText(reference))
+	}
+	fmt.Fprintf(&b, "      <ram:InvoiceCurrencyCode>%s</ram:InvoiceCurrencyCode>\n", currency)
+	if iban, ok := invoice.PaymentInfo["iban"].(string); ok && iban != "" {
+		b.WriteString("      <ram:SpecifiedTradeSettlementPaymentMeans>\n        <ram:TypeCode>58</ram:TypeCode>\n")
+		fmt.Fprintf(&b, "        <ram:PayeePartyCreditorFinancialAccount><ram:IBANID>%s</ram:IBANID></ram:PayeePartyCreditorFinancialAccount>\n", xmlText(strings.ReplaceAll(iban, " ", "")))
+		if bic, ok := invoice.PaymentInfo["bic"].(string); ok && bic != "" {
+			fmt.Fprintf(&b, "        <ram:PayeeSpecifiedCreditorFinancialInstitution><ram:BICID>%s</ram:BICID></ram:PayeeSpecifiedCreditorFinancialInstitution>\n", xmlText(bic))
+		}
+		b.WriteString("      </ram:SpecifiedTradeSettlementPaymentMeans>\n")
+	}
+
+	b.WriteString("      <ram:ApplicableTradeTax>\n")
+	fmt.Fprintf(&b, "        <ram:CalculatedAmount>%s</ram:CalculatedAmount>\n        <ram:TypeCode>VAT</ram:TypeCode>\n", facturXAmount(taxTotal))
+	if exemption != "" {
+		fmt.Fprintf(&b, "        <ram:ExemptionReason>%s</ram:ExemptionReason>\n", xmlText(exemption))
+	}
+	fmt.Fprintf(&b, "        <ram:BasisAmount>%s</ram:BasisAmount>\n", facturXAmount(basis))
+	fmt.Fprintf(&b, "        <ram:CategoryCode>%s</ram:CategoryCode>\n        <ram:RateApplicablePercent>%s</ram:RateApplicablePercent>\n", category, rate)
+	b.WriteString("      </ram:ApplicableTradeTax>\n")
+
+	if allowance > 0 {
+		b.WriteString("      <ram:SpecifiedTradeAllowanceCharge>\n        <ram:ChargeIndicator><udt:Indicator>false</udt:Indicator></ram:ChargeIndicator>\n")
+		fmt.Fprintf(&b, "        <ram:CalculationPercent>%s</ram:CalculationPercent>\n", strconv.FormatFloat(invoice.Discount, 'f', -1, 64))
+		fmt.Fprintf(&b, "        <ram:BasisAmount>%s</ram:BasisAmount>\n", facturXAmount(lineTotal))
+		fmt.Fprintf(&b, "        <ram:ActualAmount>%s</ram:ActualAmount>\n", facturXAmount(allowance))
+		b.WriteString("        <ram:Reason>Discount</ram:Reason>\n")
+		fmt.Fprintf(&b, "        <ram:CategoryTradeTax>%s</ram:CategoryTradeTax>\n", tradeTax)
+		b.WriteString("      </ram:SpecifiedTradeAllowanceCharge>\n")
+	}
+
+	b.WriteString("      <ram:SpecifiedTradePaymentTerms>\n")
+	if terms != "" {
+		fmt.Fprintf(&b, "        <ram:Description>%s</ram:Description>\n", xmlText(terms))
+	}
+	if dueDate != "" {
+		fmt.Fprintf(&b, "        <ram:DueDateDateTime><udt:DateTimeString format=\"102\">%s</udt:DateTimeString></ram:DueDateDateTime>\n", dueDate)
+	}
+	b.WriteString("      </ram:SpecifiedTradePaymentTerms>\n")
+
+	b.WriteString("      <ram:SpecifiedTradeSettlementHeaderMonetarySummation>\n")
+	fmt.Fprintf(&b, "        <ram:LineTotalAmount>%s</ram:LineTotalAmount>\n", facturXAmount(lineTotal))
+	if allowance > 0 {
+		fmt.Fprintf(&b, "        <ram:AllowanceTotalAmount>%s</ram:AllowanceTotalAmount>\n", facturXAmount(allowance))
+	}
+	fmt.Fprintf(&b, "        <ram:TaxBasisTotalAmount>%s</ram:TaxBasisTotalAmount>\n", facturXAmount(basis))
+	fmt.Fprintf(&b, "        <ram:TaxTotalAmount currencyID=\"%s\">%s</ram:TaxTotalAmount>\n", currency, facturXAmount(taxTotal))
+	fmt.Fprintf(&b, "        <ram:GrandTotalAmount>%s</ram:GrandTotalAmount>\n", facturXAmount(grandTotal))
+	fmt.Fprintf(&b, "        <ram:DuePayableAmount>%s</ram:DuePayableAmount>\n", facturXAmount(grandTotal))
+	b.WriteString("      </ram:SpecifiedTradeSettlementHeaderMonetarySummation>\n    </ram:ApplicableHeaderTradeSettlement>\n  </rsm:SupplyChainTradeTransaction>\n</rsm:CrossIndustryInvoice>\n")
+
+	return []byte(b.String()), nil
+}
+
+// srgbICCProfile - Build a compact ICC v2 sRGB display profile for the PDF/A output intent
+func srgbICCProfile() []byte {
+	be32 := func(v uint32) []byte { return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)} }
+	s15 := func(v float64) []byte { return be32(uint32(int32(math.Round(v * 65536)))) }
+	xyz := func(x, y, z float64) []byte {
+		return append(append(append([]byte("XYZ \x00\x00\x00\x00"), s15(x)...), s15(y)...), s15(z)...)
+	}
+	text := func(kind, value string) []byte {
+		if kind == "desc" {
+			data := append([]byte("desc\x00\x00\x00\x00"), be32(uint32(len(value)+1))...)
+			data = append(append(data, value...), 0)
+			return append(data, make([]byte, 8+2+1+67)...)
+		}
+		return append(append([]byte("text\x00\x00\x00\x00"), value...), 0)
+	}
+	// Gamma 2.2 as a u8Fixed8Number
+	trc := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x02\x33\x00\x00")
+
+	tags := []struct {
+		signature string
+		data      []byte
+	}{
+		{"desc", text("desc", "sRGB IEC61966-2.1")},
+		{"cprt", text("text", "No copyright, use freely")},
+		{"wtpt", xyz(0.9642, 1, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+
+	table := be32(uint32(len(tags)))
+	var data []byte
+	offset := 128 + 4 + 12*len(tags)
+	for _, tag := range tags {
+		table = append(table, tag.signature...)
+		table = append(table, be32(uint32(offset+len(data)))...)
+		table = append(table, be32(uint32(len(tag.data)))...)
+		data = append(data, tag.data...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+
+	header := make([]byte, 128)
+	copy(header[0:], be32(uint32(128+len(table)+len(data))))
+	copy(header[8:], be32(0x02100000))
+	copy(header[12:], "mntrRGB XYZ ")
+	copy(header[24:], []byte{0x07, 0xce, 0, 2, 0, 9, 0, 6, 0, 0x31, 0, 0})
+	copy(header[36:], "acsp")
+	copy(header[68:], s15(0.9642))
+	copy(header[72:], s15(1))
+	copy(header[76:], s15(0.8249))
+
+	return append(append(header, table...), data...)
+}
+
+// facturXMetadata - XMP packet declaring PDF/A-3B conformance and the Factur-X extension schema
+func facturXMetadata(title, author, producer string, date time.Time, opts FacturXOptions) []byte {
+	stamp := date.Format(time.RFC3339)
+	return []byte(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+  <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">
+      <pdfaid:part>3</pdfaid:part>
+      <pdfaid:conformance>B</pdfaid:conformance>
+    </rdf:Description>
+    <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+      <dc:title><rdf:Alt><rdf:li xml:lang="x-default">` + xmlText(title) + `</rdf:li></rdf:Alt></dc:title>
+      <dc:creator><rdf:Seq><rdf:li>` + xmlText(author) + `</rdf:li></rdf:Seq></dc:creator>
+    </rdf:Description>
+    <rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/">
+      <pdf:Producer>` + xmlText(producer) + `</pdf:Producer>
+    </rdf:Description>
+    <rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+      <xmp:CreateDate>` + stamp + `</xmp:CreateDate>
+      <xmp:ModifyDate>` + stamp + `</xmp:ModifyDate>
+    </rdf:Description>
+    <rdf:Description rdf:about="" xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/" xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#" xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+      <pdfaExtension:schemas>
+        <rdf:Bag>
+          <rdf:li rdf:parseType="Resource">
+            <pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+            <pdfaSchema:namespaceURI>urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#</pdfaSchema:namespaceURI>
+            <pdfaSchema:prefix>fx</pdfaSchema:prefix>
+            <pdfaSchema:property>
+              <rdf:Seq>
+                <rdf:li rdf:parseType="Resource"><pdfaProperty:name>DocumentFileName</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>name of the embedded XML invoice file</pdfaProperty:description></rdf:li>
+                <rdf:li rdf:parseType="Resource"><pdfaProperty:name>DocumentType</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>INVOICE</pdfaProperty:description></rdf:li>
+                <rdf:li rdf:parseType="Resource"><pdfaProperty:name>Version</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>The actual version of the Factur-X XML schema</pdfaProperty:description></rdf:li>
+                <rdf:li rdf:parseType="Resource"><pdfaProperty:name>ConformanceLevel</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>The conformance level of the embedded Factur-X data</pdfaProperty:description></rdf:li>
+              </rdf:Seq>
+            </pdfaSchema:property>
+          </rdf:li>
+        </rdf:Bag>
+      </pdfaExtension:schemas>
+    </rdf:Description>
+    <rdf:Description rdf:about="" xmlns:fx="urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#">
+      <fx:DocumentType>INVOICE</fx:DocumentType>
+      <fx:DocumentFileName>factur-x.xml</fx:DocumentFileName>
+      <fx:Version>1.0</fx:Version>
+      <fx:ConformanceLevel>EN 16931</fx:ConformanceLevel>
+    </rdf:Description>
+  </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+}
+
+// embedFacturX - Turn the generated invoice into a PDF/A-3B document carrying the XML as
+// factur-x.xml. pdfcpu stamps CreationDate and ModDate while writing and PDF/A requires the
+// XMP dates to match them, so the document is written again if the clock ticks over a second.
+func embedFacturX(pdfBytes, xmlData []byte, opts FacturXOptions) ([]byte, error) {
+	producer := "pdfcpu " + model.VersionStr
+	for attempt := 0; attempt < 3; attempt++ {
+		ctx, err := api.ReadContext(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+		if err != nil {
+			return nil, err
+		}
+		rootDict, err := ctx.Catalog()
+		if err != nil {
+			return nil, err
+		}
+
+		title, author := "", ""
+		if ctx.Info != nil {
+			if info, err := ctx.DereferenceDict(*ctx.Info); err == nil && info != nil {
+				for key, target := range map[string]*string{"Title": &title, "Author": &author} {
+					if obj, found := info.Find(key); found {
+						*target, _ = ctx.DereferenceStringOrHexLiteral(obj, model.V10, nil)
+					}
+				}
+			}
+		}
+
+		now := time.Now()
+		stamp := types.DateString(now)
+
+		fileSD, err := ctx.NewStreamDictForBuf(xmlData)
+		if err != nil {
+			return nil, err
+		}
+		fileSD.InsertName("Type", "EmbeddedFile")
+		fileSD.InsertName("Subtype", "text/xml")
+		params := types.NewDict()
+		params.InsertInt("Size", len(xmlData))
+		params.InsertString("ModDate", stamp)
+		fileSD.Insert("Params", params)
+		if err := fileSD.Encode(); err != nil {
+			return nil, err
+		}
+		fileRef, err := ctx.IndRefForNewObject(*fileSD)
+		if err != nil {
+			return nil, err
+		}
+
+		fileSpec := types.NewDict()
+		fileSpec.InsertName("Type", "Filespec")
+		fileSpec.InsertString("F", "factur-x.xml")
+		fileSpec.InsertString("UF", "factur-x.xml")
+		fileSpec.InsertString("Desc", "Factur-X invoice")
+		fileSpec.InsertName("AFRelationship", opts.Relationship)
+		fileSpec.Insert("EF", types.Dict{"F": *fileRef, "UF": *fileRef})
+		specRef, err := ctx.IndRefForNewObject(fileSpec)
+		if err != nil {
+			return nil, err
+		}
+
+		iccSD, err := ctx.NewStreamDictForBuf(srgbICCProfile())
+		if err != nil {
+			return nil, err
+		}
+		iccSD.InsertInt("N", 3)
+		if err := iccSD.Encode(); err != nil {
+			return nil, err
+		}
+		iccRef, err := ctx.IndRefForNewObject(*iccSD)
+		if err != nil {
+			return nil, err
+		}
+
+		outputIntent := types.NewDict()
+		outputIntent.InsertName("Type", "OutputIntent")
+		outputIntent.InsertName("S", "GTS_PDFA1")
+		outputIntent.InsertString("OutputConditionIdentifier", "sRGB IEC61966-2.1")
+		outputIntent.InsertString("Info", "sRGB IEC61966-2.1")
+		outputIntent.Insert("DestOutputProfile", *iccRef)
+
+		// The XMP packet stays uncompressed so archivers can find it without decoding streams
+		metadata := facturXMetadata(title, author, producer, now, opts)
+		metaSD := types.StreamDict{Dict: types.NewDict(), Content: metadata, Raw: metadata}
+		metaSD.InsertName("Type", "Metadata")
+		metaSD.InsertName("Subtype", "XML")
+		streamLength := int64(len(metadata))
+		metaSD.StreamLength = &streamLength
+		metaSD.InsertInt("Length", len(metadata))
+		metaRef, err := ctx.IndRefForNewObject(metaSD)
+		if err != nil {
+			return nil, err
+		}
+
+		rootDict.Update("Metadata", *metaRef)
+		rootDict.Update("OutputIntents", types.Array{outputIntent})
+		rootDict.Update("AF", types.Array{*specRef})
+		rootDict.Update("Names", types.Dict{
+			"EmbeddedFiles": types.Dict{"Names": types.Array{types.StringLiteral("factur-x.xml"), *specRef}},
+		})
+		version := model.V17
+		ctx.HeaderVersion = &version
+		ctx.RootVersion = nil
+		rootDict.Delete("Version")
+
+		var buf bytes.Buffer
+		if err := api.WriteContext(ctx, &buf); err != nil {
+			return nil, err
+		}
+
+		if info, err := ctx.DereferenceDict(*ctx.Info); err == nil && info != nil {
+			if created, found := info.Find("CreationDate"); found && created.String() == types.StringLiteral(stamp).String() {
+				return buf.Bytes(), nil
+			}
+		}
+	}
+	return nil, errors.New(localize("document dates changed while writing"))
+}
+
+// generateCertificate - Generate certificate PDF
+func generateCertificate(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "generateCertificate", "certificateData"),
+		})
+	}
+
+	certJSON := args[0].String()
+	var cert CertificateData
+	if err := json.Unmarshal([]byte(certJSON), &cert); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid certificate data format: %v", err),
+		})
+	}
+
+	pdf := newDocument("L")
+	pdf.AddPage()
+
+	// Border
+	pdf.Rect(10, 10, 277, 190, "D")
+	pdf.Rect(15, 15, 267, 180, "D")
+
+	// Title
+	tr := useFont(pdf, cert.Font, "B", 24)
+	pdf.SetY(40)
+	pdf.CellFormat(0, 20, tr(cert.Title), "", 0, "C", false, 0, "")
+	pdf.Ln(30)
+
+	// Main text
+	tr = useFont(pdf, cert.Font, "", 16)
+	pdf.CellFormat(0, 10, tr(localize("This is to certify that")), "", 0, "C", false, 0, "")
+	pdf.Ln(20)
+
+	// Recipient name
+	tr = useFont(pdf, cert.Font, "B", 20)
+	pdf.CellFormat(0, 15, tr(cert.Recipient), "", 0, "C", false, 0, "")
+	pdf.Ln(25)
+
+	// Achievement
+	tr = useFont(pdf, cert.Font, "", 14)
+	pdf.CellFormat(0, 10, tr(cert.Achievement), "", 0, "C", false, 0, "")
+	pdf.Ln(30)
+
+	// Date and issuer
+	tr = useFont(pdf, cert.Font, "", 12)
+	pdf.SetY(150)
+	pdf.Cell(80, 10, tr(localize("Date: %s", cert.Date)))
+	pdf.Cell(117, 10, tr(localize("Issued by: %s", cert.Issuer)))
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to generate certificate: %v", err),
+		})
+	}
+
+	certificatePdfData := binaryOutput(buf.Bytes())
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated certificate for %s (%d bytes)\n", cert.Recipient, buf.Len())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":   certificatePdfData,
+		"size":      buf.Len(),
+		"recipient": cert.Recipient,
+		"format":    "application/pdf",
+	})
+}
+
+// generateContract - Generate a multi-page contract with parties, clauses and signature blocks
+func generateContract(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 1 argument (%s)", "generateContract", "contractData"),
+		})
+	}
+
+	contractJSON := args[0].String()
+	var contract ContractData
+	if err := json.Unmarshal([]byte(contractJSON), &contract); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid contract data format: %v", err),
+		})
+	}
+
+	if len(contract.Parties) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Contract requires at least 2 parties"),
+		})
+	}
+	if contract.Title == "" {
+		contract.Title = localize("CONTRACT")
+	}
+
+	pdf := newDocument("P")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		tr := useFont(pdf, contract.Font, "I", 8)
+		pdf.SetY(-15)
+		pdf.CellFormat(0, 10, tr(localize("%s - Page %d / {nb}", contract.Title, pdf.PageNo())), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Title
+	tr := useFont(pdf, contract.Font, "B", 18)
+	pdf.MultiCell(0, 10, tr(contract.Title), "", "C", false)
+	pdf.Ln(8)
+
+	// Parties
+	tr = useFont(pdf, contract.Font, "B", 12)
+	pdf.Cell(0, 8, tr(localize("Between the undersigned:")))
+	pdf.Ln(10)
+	for i, party := range contract.Parties {
+		tr = useFont(pdf, contract.Font, "B", 11)
+		pdf.MultiCell(0, 6, tr(party.Name), "", "", false)
+		tr = useFont(pdf, contract.Font, "", 10)
+		if party.Address != "" {
+			pdf.MultiCell(0, 5, tr(party.Address), "", "", false)
+		}
+		if party.VAT != "" {
+			pdf.MultiCell(0, 5, tr(localize("VAT number: %s", party.VAT)), "", "", false)
+		}
+		if party.Email != "" || party.Phone != "" {
+			pdf.MultiCell(0, 5, tr(strings.Trim(fmt.Sprintf("%s | %s", party.Phone, party.Email), " |")), "", "", false)
+		}
+		tr = useFont(pdf, contract.Font, "I", 10)
+		pdf.MultiCell(0, 5, tr(localize("hereinafter referred to as “Party %d”", i+1)), "", "", false)
+		pdf.Ln(4)
+		if i < len(contract.Parties)-1 {
+			pdf.Cell(0, 6, tr(localize("and")))
+			pdf.Ln(8)
+		}
+	}
+
+	// Key terms
+	if contract.Date != "" || contract.Duration != "" || contract.Value > 0 {
+		pdf.Ln(4)
+		tr = useFont(pdf, contract.Font, "", 10)
+		if contract.Date != "" {
+			pdf.Cell(50, 6, tr(localize("Effective date:")))
+			pdf.Cell(0, 6, tr(contract.Date))
+			pdf.Ln(6)
+		}
+		if contract.Duration != "" {
+			pdf.Cell(50, 6, tr(localize("Duration:")))
+			pdf.Cell(0, 6, tr(contract.Duration))
+			pdf.Ln(6)
+		}
+		if contract.Value > 0 {
+			pdf.Cell(50, 6, tr(localize("Amount:")))
+			pdf.Cell(0, 6, tr(fmt.Sprintf("%.2f %s", contract.Value, contract.Currency)))
+			pdf.Ln(6)
+		}
+	}
+
+	// Numbered clauses, kept in the order they appear in the JSON object
+	clauseCount := 0
+	for _, key := range contractClauseOrder(contractJSON, contract.Clauses) {
+		clauseCount++
+		pdf.Ln(6)
+		tr = useFont(pdf, contract.Font, "B", 11)
+		pdf.MultiCell(0, 6, tr(localize("Article %d - %s", clauseCount, key)), "", "", false)
+		pdf.Ln(1)
+		tr = useFont(pdf, contract.Font, "", 10)
+
+		switch body := contract.Clauses[key].(type) {
+		case []interface{}:
+			for j, paragraph := range body {
+				pdf.MultiCell(0, 5, tr(fmt.Sprintf("%d.%d  %v", clauseCount, j+1, paragraph)), "", "J", false)
+				pdf.Ln(1)
+			}
+		case nil:
+		default:
+			pdf.MultiCell(0, 5, tr(fmt.Sprintf("%v", body)), "", "J", false)
+		}
+	}
+
+	// Terms and conditions
+	if len(contract.Terms) > 0 {
+		pdf.Ln(6)
+		tr = useFont(pdf, contract.Font, "B", 11)
+		pdf.Cell(0, 6, tr(localize("Terms and conditions")))
+		pdf.Ln(7)
+		tr = useFont(pdf, contract.Font, "", 10)
+		for i, term := range contract.Terms {
+			pdf.MultiCell(0, 5, tr(fmt.Sprintf("%d. %s", i+1, term)), "", "J", false)
+			pdf.Ln(1)
+		}
+	}
+
+	// Signature blocks: fields with coordinates are placed where configured,
+	// the others are laid out two per row after the contract body
+	if len(contract.Signatures) > 0 {
+		_, pageHeight := pdf.GetPageSize()
+		_, _, _, bottomMargin := pdf.GetMargins()
+		pdf.Ln(10)
+
+		autoX, autoY := 20.0, pdf.GetY()
+		lastPage := pdf.PageNo()
+		for i, sig := range contract.Signatures {
+			if sig.Width == 0 {
+				sig.Width = 80
+			}
+			if sig.Height == 0 {
+				sig.Height = 30
+			}
+
+			if sig.X == 0 && sig.Y == 0 {
+				pdf.SetPage(lastPage)
+				if autoY+sig.Height+12 > pageHeight-bottomMargin {
+					pdf.AddPage()
+					lastPage = pdf.PageNo()
+					autoX, autoY = 20, pdf.GetY()
+				}
+				sig.X, sig.Y = autoX, autoY
+				if autoX == 20 {
+					autoX = 110
+				} else {
+					autoX, autoY = 20, autoY+sig.Height+20
+				}
+			} else {
+				page := sig.Page
+				if page < 1 || page > lastPage {
+					page = lastPage
+				}
+				pdf.SetPage(page)
+			}
+
+			drawSignatureBlock(pdf, contract.Font, sig, i+1)
+		}
+		pdf.SetPage(lastPage)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to generate contract: %v", err),
+		})
+	}
+
+	contractPdfData := binaryOutput(buf.Bytes())
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated contract '%s' (%d pages, %d bytes)\n", contract.Title, pdf.PageCount(), buf.Len())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":    contractPdfData,
+		"size":       buf.Len(),
+		"title":      contract.Title,
+		"pages":      pdf.PageCount(),
+		"parties":    len(contract.Parties),
+		"clauses":    clauseCount,
+		"signatures": len(contract.Signatures),
+		"format":     "application/pdf",
+	})
+}
+
+// drawSignatureBlock draws a signature box with the signer's name, title and date below it
+func drawSignatureBlock(pdf *gofpdf.Fpdf, font string, sig SignatureField, index int) {
+	tr := useFont(pdf, font, "", 8)
+	pdf.SetXY(sig.X, sig.Y)
+	pdf.CellFormat(sig.Width, 5, tr(localize("Signature %d", index)), "", 0, "L", false, 0, "")
+	pdf.Rect(sig.X, sig.Y+5, sig.Width, sig.Height, "D")
+
+	y := sig.Y + sig.Height + 6
+	if sig.Name != "" {
+		tr = useFont(pdf, font, "B", 10)
+		pdf.SetXY(sig.X, y)
+		pdf.CellFormat(sig.Width, 5, tr(sig.Name), "", 0, "L", false, 0, "")
+		y += 5
+	}
+	tr = useFont(pdf, font, "", 9)
+	if sig.Title != "" {
+		pdf.SetXY(sig.X, y)
+		pdf.CellFormat(sig.Width, 5, tr(sig.Title), "", 0, "L", false, 0, "")
+		y += 5
+	}
+	date := sig.Date
+	if date == "" {
+		date = "____ / ____ / ________"
+	}
+	pdf.SetXY(sig.X, y)
+	pdf.CellFormat(sig.Width, 5, tr(localize("Date: %s", date)), "", 0, "L", false, 0, "")
+}
+
+// contractClauseOrder returns the clause titles in the order they appear in the
+// source JSON, since decoding into a map loses the author's ordering
+func contractClauseOrder(contractJSON string, clauses map[string]interface{}) []string {
+	var raw struct {
+		Clauses json.RawMessage `json:"clauses"`
+	}
+	var order []string
+	if err := json.Unmarshal([]byte(contractJSON), &raw); err == nil && len(raw.Clauses) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(raw.Clauses))
+		if tok, err := dec.Token(); err == nil && tok == json.Delim('{') {
+			for dec.More() {
+				tok, err := dec.Token()
+				if err != nil {
+					break
+				}
+				key, _ := tok.(string)
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					break
+				}
+				if _, ok := clauses[key]; ok {
+					order = append(order, key)
+				}
+			}
+		}
+	}
+
+	if len(order) != len(clauses) {
+		order = order[:0]
+		for key := range clauses {
+			order = append(order, key)
+		}
+		sort.Strings(order)
+	}
+	return order
+}
+
+// addTable - Draw a formatted table onto a page of an existing PDF
+func addTable(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "addTable", "pdfData, tableData"),
+		})
+	}
+
+	pdfData := args[0]
+	tableJSON := args[1].String()
+
+	pdfBytes, err := decodePDFData(pdfData, optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	var table TableData
+	if err := json.Unmarshal([]byte(tableJSON), &table); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid table data format: %v", err),
+		})
+	}
+
+	style, err := parseTableStyle(table.Style, defaultTableStyle("Arial", 10))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid table style: %v", err),
+		})
+	}
+	if _, ok := table.Style["headerFontSize"]; !ok {
+		style.HeaderFontSize = style.FontSize + 1
+	}
+
+	result, err := stampGeneratedContent(pdfBytes, table.ContentPlacement, func(pdf *gofpdf.Fpdf) error {
+		drawTable(pdf, table.Headers, table.Rows, style)
+		return nil
+	})
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to add table: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Added table with %d columns and %d rows on page %d\n", len(table.Headers), len(table.Rows), result.page)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":    binaryOutput(result.pdfBytes),
+		"size":       len(result.pdfBytes),
+		"columns":    len(table.Headers),
+		"rows":       len(table.Rows),
+		"page":       result.page,
+		"pagesAdded": result.pagesAdded,
+		"pages":      result.pageCount,
+		"format":     "application/pdf",
+	})
+}
+
+// addChart - Draw a bar, stacked-bar, line, pie or donut chart onto a page of an existing PDF
+func addChart(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "addChart", "pdfData, chartData"),
+		})
+	}
+
+	pdfData := args[0]
+	chartJSON := args[1].String()
+
+	pdfBytes, err := decodePDFData(pdfData, optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	var chart ChartData
+	if err := json.Unmarshal([]byte(chartJSON), &chart); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid chart data format: %v", err),
+		})
+	}
+
+	chartType, err := normalizeChartType(chart.Type)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	labels, series := chartSeries(chart)
+	if len(labels) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Chart has no data"),
+		})
+	}
+	style, err := parseChartStyle(chart.Style, chartType, len(series))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid chart style: %v", err),
+		})
+	}
+	colors, err := chartColors(chart.Colors)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid chart style: %v", err),
+		})
+	}
+
+	result, err := stampGeneratedContent(pdfBytes, chart.ContentPlacement, func(pdf *gofpdf.Fpdf) error {
+		if chart.Title != "" {
+			tr := useFont(pdf, style.Font, "B", 14)
+			pdf.CellFormat(0, 10, tr(chart.Title), "", 1, "C", false, 0, "")
+			pdf.Ln(5)
+		}
+
+		switch chartType {
+		case "pie", "donut":
+			return drawPieChart(pdf, labels, series[0].Values, colors, style, chartType == "donut")
+		default:
+			drawAxisChart(pdf, chartType, labels, series, colors, chart.XLabel, chart.YLabel, style)
+			return nil
+		}
+	})
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to add chart: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Added %s chart with %d data points on page %d\n", chartType, len(labels), result.page)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":    binaryOutput(result.pdfBytes),
+		"size":       len(result.pdfBytes),
+		"chartType":  chartType,
+		"dataPoints": len(labels),
+		"series":     len(series),
+		"page":       result.page,
+		"pagesAdded": result.pagesAdded,
+		"pages":      result.pageCount,
+		"format":     "application/pdf",
+	})
+}
+
+// stampedContent is a document with generated content stamped onto it
+type stampedContent struct {
+	pdfBytes   []byte
+	page       int
+	pagesAdded int
+	pageCount  int
+}
+
+// stampGeneratedContent - Lay out content with gofpdf on transparent overlay pages the size of the target
+// page and stamp them onto the document with pdfcpu, the same way addWatermark does. Content overflowing
+// the target page continues on blank pages inserted after it.
+func stampGeneratedContent(pdfBytes []byte, placement ContentPlacement, layout func(pdf *gofpdf.Fpdf) error) (*stampedContent, error) {
+	ctx, err := api.ReadAndValidate(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
+		return nil, err
+	}
+	if placement.Page < 0 || placement.Page > ctx.PageCount {
+		return nil, errors.New(localize("Page %d does not exist (document has %d pages)", placement.Page, ctx.PageCount))
+	}
+
+	target := placement.Page
+	if target == 0 {
+		target = ctx.PageCount
+	}
+	_, _, inherited, err := ctx.PageDict(target, false)
+	if err != nil {
+		return nil, err
+	}
+	if inherited == nil {
+		return nil, errors.New(localize("page %d not found", target))
+	}
+	box := inherited.CropBox
+	if box == nil {
+		box = inherited.MediaBox
+	}
+	if box == nil {
+		box = types.NewRectangle(0, 0, 595.28, 841.89)
+	}
+	pageDim := &types.Dim{Width: box.Width(), Height: box.Height()}
+
+	// Overlay pages use the page size in mm so the stamp lines up with the page when centered
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: pageDim.Width * 25.4 / 72, Ht: pageDim.Height * 25.4 / 72},
+	})
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+	if placement.Y != nil {
+		pdf.SetY(*placement.Y)
+	}
+	if err := layout(pdf); err != nil {
+		return nil, err
+	}
+	var overlay bytes.Buffer
+	if err := pdf.Output(&overlay); err != nil {
+		return nil, err
+	}
+
+	// A new page is appended for page 0, and one more after the target page per overflow page
+	result := &stampedContent{pdfBytes: pdfBytes, page: target}
+	inserts := pdf.PageCount() - 1
+	if placement.Page == 0 {
+		inserts++
+	}
+	for i := 0; i < inserts; i++ {
+		var buf bytes.Buffer
+		if err := api.InsertPages(bytes.NewReader(result.pdfBytes), &buf, []string{strconv.Itoa(target)}, false, &pdfcpu.PageConfiguration{PageDim: pageDim}, newPDFConfiguration("")); err != nil {
+			return nil, err
+		}
+		result.pdfBytes = buf.Bytes()
+	}
+	if placement.Page == 0 {
+		result.page = target + 1
+	}
+	result.pagesAdded = inserts
+
+	stamps := map[int]*model.Watermark{}
+	for i := 1; i <= pdf.PageCount(); i++ {
+		wm, err := api.PDFWatermarkForReadSeeker(bytes.NewReader(overlay.Bytes()), i, "scalefactor:1 abs, rotation:0, position:c, opacity:1", true, false, types.POINTS)
+		if err != nil {
+			return nil, err
+		}
+		stamps[result.page+i-1] = wm
+	}
+	var buf bytes.Buffer
+	if err := api.AddWatermarksMap(bytes.NewReader(result.pdfBytes), &buf, stamps, newPDFConfiguration("")); err != nil {
+		return nil, err
+	}
+	result.pdfBytes = buf.Bytes()
+
+	result.pageCount = ctx.PageCount + inserts
+	return result, nil
+}
+
+// chartStyle controls chart rendering. Sizes are in mm.
+type chartStyle struct {
+	Font       string
+	FontSize   float64
+	Width      float64
+	Height     float64
+	Legend     bool
+	ShowValues bool
+	Grid       bool
+	HoleRatio  float64
+}
+
+// chartPalette colors the series when the chart has no Colors array
+var chartPalette = [][3]int{
+	{66, 133, 244}, {219, 68, 55}, {244, 180, 0}, {15, 157, 88},
+	{171, 71, 188}, {0, 172, 193}, {255, 112, 67}, {158, 157, 36},
+}
+
+// normalizeChartType accepts bar, stackedBar (stacked-bar, stacked), line, pie and donut (doughnut)
+func normalizeChartType(chartType string) (string, error) {
+	switch strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(chartType)) {
+	case "", "bar":
+		return "bar", nil
+	case "stackedbar", "stacked":
+		return "stackedBar", nil
+	case "line":
+		return "line", nil
+	case "pie":
+		return "pie", nil
+	case "donut", "doughnut":
+		return "donut", nil
+	}
+	return "", fmt.Errorf(localize("unsupported chart type %q"), chartType)
+}
+
+// chartSeries returns the category labels and the series of a chart. Data points form a
+// single series; Series use Labels, padded to the longest series.
+func chartSeries(chart ChartData) ([]string, []ChartSeries) {
+	if len(chart.Series) == 0 {
+		if len(chart.Data) == 0 {
+			return nil, nil
+		}
+		labels := make([]string, len(chart.Data))
+		values := make([]float64, len(chart.Data))
+		for i, point := range chart.Data {
+			labels[i], values[i] = point.Label, point.Value
+		}
+		return labels, []ChartSeries{{Name: chart.Title, Values: values}}
+	}
+
+	count := len(chart.Labels)
+	for _, s := range chart.Series {
+		if len(s.Values) > count {
+			count = len(s.Values)
+		}
+	}
+	labels := make([]string, count)
+	copy(labels, chart.Labels)
+	series := make([]ChartSeries, len(chart.Series))
+	for i, s := range chart.Series {
+		series[i] = ChartSeries{Name: s.Name, Values: make([]float64, count)}
+		copy(series[i].Values, s.Values)
+	}
+	return labels, series
+}
+
+// parseChartStyle reads the keys of a chart Style map: font, fontSize, width, height,
+// legend, showValues, grid and holeRatio (donut hole, 0 to 0.9 of the radius)
+func parseChartStyle(style map[string]interface{}, chartType string, seriesCount int) (chartStyle, error) {
+	s := chartStyle{
+		Font:       "Arial",
+		FontSize:   8,
+		Width:      170,
+		Height:     100,
+		Legend:     seriesCount > 1 || chartType == "pie" || chartType == "donut",
+		ShowValues: chartType != "line",
+		Grid:       true,
+		HoleRatio:  0.5,
+	}
+
+	if font, ok := style["font"].(string); ok {
+		s.Font = font
+	}
+	for key, target := range map[string]*float64{"fontSize": &s.FontSize, "width": &s.Width, "height": &s.Height, "holeRatio": &s.HoleRatio} {
+		if value, ok := style[key]; ok {
+			n, ok := value.(float64)
+			if !ok || n <= 0 {
+				return s, errors.New(localize("style %s must be a positive number", key))
+			}
+			*target = n
+		}
+	}
+	for key, target := range map[string]*bool{"legend": &s.Legend, "showValues": &s.ShowValues, "grid": &s.Grid} {
+		if value, ok := style[key]; ok {
+			b, ok := value.(bool)
+			if !ok {
+				return s, errors.New(localize("style %s must be true or false", key))
+			}
+			*target = b
+		}
+	}
+
+	s.Width = math.Min(s.Width, 170)
+	s.Height = math.Min(s.Height, 200)
+	s.HoleRatio = math.Min(s.HoleRatio, 0.9)
+	return s, nil
+}
+
+// chartColors parses the Colors array, falling back to the default palette
+func chartColors(colors []string) ([][3]int, error) {
+	if len(colors) == 0 {
+		return chartPalette, nil
+	}
+	parsed := make([][3]int, len(colors))
+	for i, color := range colors {
+		rgb, ok := hexColor(color)
+		if !ok {
+			return nil, errors.New(localize("style %s must be a #RRGGBB color", fmt.Sprintf("colors[%d]", i)))
+		}
+		parsed[i] = rgb
+	}
+	return parsed, nil
+}
+
+// niceScale widens [low, high] to round tick values and returns the tick step
+func niceScale(low, high float64, ticks int) (float64, float64, float64) {
+	if high <= low {
+		high = low + 1
+	}
+	raw := (high - low) / float64(ticks)
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := 10 * magnitude
+	for _, m := range []float64{1, 2, 2.5, 5} {
+		if raw <= m*magnitude {
+			step = m * magnitude
+			break
+		}
+	}
+	return math.Floor(low/step) * step, math.Ceil(high/step) * step, step
+}
+
+// chartNumber formats a value without trailing zeros
+func chartNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1e6)/1e6, 'f', -1, 64)
+}
+
+// drawAxisChart draws bar, stacked-bar and line charts: a value axis with grid lines,
+// one slot per label and the legend below the axis labels
+func drawAxisChart(pdf *gofpdf.Fpdf, chartType string, labels []string, series []ChartSeries, colors [][3]int, xLabel, yLabel string, style chartStyle) {
+	// Value range; stacked bars add positive and negative values separately
+	low, high := 0.0, 0.0
+	for i := range labels {
+		positive, negative := 0.0, 0.0
+		for _, s := range series {
+			value := s.Values[i]
+			if chartType == "stackedBar" {
+				if value >= 0 {
+					positive += value
+				} else {
+					negative += value
+				}
+				continue
+			}
+			high, low = math.Max(high, value), math.Min(low, value)
+		}
+		high, low = math.Max(high, positive), math.Min(low, negative)
+	}
+	low, high, step := niceScale(low, high, 5)
+
+	tr := useFont(pdf, style.Font, "", style.FontSize)
+	left, _, _, _ := pdf.GetMargins()
+	axisWidth := 0.0
+	for tick := low; tick <= high+step/2; tick += step {
+		axisWidth = math.Max(axisWidth, pdf.GetStringWidth(chartNumber(tick)))
+	}
+	x := left + axisWidth + 2
+	if yLabel != "" {
+		x += style.FontSize * 0.5
+	}
+	y := pdf.GetY()
+	width := left + style.Width - x
+	height := style.Height
+	valueY := func(value float64) float64 {
+		return y + height - (value-low)/(high-low)*height
+	}
+
+	// Grid lines and tick labels
+	pdf.SetLineWidth(0.1)
+	pdf.SetDrawColor(200, 200, 200)
+	for tick := low; tick <= high+step/2; tick += step {
+		ty := valueY(tick)
+		if style.Grid {
+			pdf.Line(x, ty, x+width, ty)
+		}
+		pdf.SetXY(x-axisWidth-2, ty-2)
+		pdf.CellFormat(axisWidth+1, 4, chartNumber(tick), "", 0, "R", false, 0, "")
+	}
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.3)
+	pdf.Line(x, y, x, y+height)
+	pdf.Line(x, valueY(0), x+width, valueY(0))
+
+	slot := width / float64(len(labels))
+	center := func(i int) float64 { return x + (float64(i)+0.5)*slot }
+	color := func(i int) [3]int { return colors[i%len(colors)] }
+	valueLabel := func(cx, value float64) {
+		vy := valueY(value)
+		if value >= 0 {
+			vy -= 4
+		}
+		pdf.SetXY(cx-slot/2, vy)
+		pdf.CellFormat(slot, 4, chartNumber(value), "", 0, "C", false, 0, "")
+	}
+
+	switch chartType {
+	case "bar":
+		barWidth := slot * 0.7 / float64(len(series))
+		for i := range labels {
+			for j, s := range series {
+				c := color(j)
+				if len(series) == 1 {
+					c = color(i)
+				}
+				pdf.SetFillColor(c[0], c[1], c[2])
+				bx := center(i) - slot*0.35 + float64(j)*barWidth
+				top, bottom := valueY(math.Max(s.Values[i], 0)), valueY(math.Min(s.Values[i], 0))
+				pdf.Rect(bx, top, barWidth, bottom-top, "F")
+				if style.ShowValues {
+					valueLabel(bx+barWidth/2, s.Values[i])
+				}
+			}
+		}
+
+	case "stackedBar":
+		for i := range labels {
+			positive, negative := 0.0, 0.0
+			for j, s := range series {
+				value := s.Values[i]
+				base := &positive
+				if value < 0 {
+					base = &negative
+				}
+				top, bottom := valueY(math.Max(*base, *base+value)), valueY(math.Min(*base, *base+value))
+				*base += value
+				c := color(j)
+				pdf.SetFillColor(c[0], c[1], c[2])
+				pdf.Rect(center(i)-slot*0.35, top, slot*0.7, bottom-top, "F")
+			}
+			if style.ShowValues {
+				valueLabel(center(i), positive+negative)
+			}
+		}
+
+	case "line":
+		pdf.SetLineWidth(0.6)
+		for j, s := range series {
+			c := color(j)
+			pdf.SetDrawColor(c[0], c[1], c[2])
+			pdf.SetFillColor(c[0], c[1], c[2])
+			for i, value := range s.Values {
+				if i > 0 {
+					pdf.Line(center(i-1), valueY(s.Values[i-1]), center(i), valueY(value))
+				}
+			}
+			for i, value := range s.Values {
+				pdf.Circle(center(i), valueY(value), 0.9, "F")
+				if style.ShowValues {
+					valueLabel(center(i), value)
+				}
+			}
+		}
+		pdf.SetDrawColor(0, 0, 0)
+	}
+
+	// Category labels, axis titles and legend
+	for i, label := range labels {
+		pdf.SetXY(center(i)-slot/2, y+height+1)
+		pdf.CellFormat(slot, 4, tr(label), "", 0, "C", false, 0, "")
+	}
+	pdf.SetXY(x, y+height+6)
+	if xLabel != "" {
+		useFont(pdf, style.Font, "B", style.FontSize)
+		pdf.CellFormat(width, 5, tr(xLabel), "", 2, "C", false, 0, "")
+	}
+	if yLabel != "" {
+		useFont(pdf, style.Font, "B", style.FontSize)
+		lx, ly := left, y+height/2+pdf.GetStringWidth(tr(yLabel))/2
+		pdf.TransformBegin()
+		pdf.TransformRotate(90, lx, ly)
+		pdf.Text(lx, ly+style.FontSize*0.35, tr(yLabel))
+		pdf.TransformEnd()
+	}
+
+	if style.Legend {
+		names := make([]string, len(series))
+		for j, s := range series {
+			names[j] = s.Name
+			if names[j] == "" {
+				names[j] = fmt.Sprintf("%d", j+1)
+			}
+		}
+		if len(series) == 1 && chartType == "bar" {
+			names = labels
+		}
+		drawChartLegend(pdf, tr, names, colors, style)
+	}
+}
+
+// drawPieChart draws pie and donut charts; slices start at the top and run clockwise
+func drawPieChart(pdf *gofpdf.Fpdf, labels []string, values []float64, colors [][3]int, style chartStyle, donut bool) error {
+	total := 0.0
+	for _, value := range values {
+		if value < 0 {
+			return errors.New(localize("pie chart values must not be negative"))
+		}
+		total += value
+	}
+	if total == 0 {
+		return errors.New(localize("pie chart values add up to zero"))
+	}
+
+	left, _, _, _ := pdf.GetMargins()
+	radius := math.Min(style.Width, style.Height) / 2
+	cx, cy := left+style.Width/2, pdf.GetY()+radius
+	inner := 0.0
+	if donut {
+		inner = radius * style.HoleRatio
+	}
+	arc := func(r, from, to float64, points []gofpdf.PointType) []gofpdf.PointType {
+		steps := int(math.Ceil((to-from)/(math.Pi/90))) + 1
+		for k := 0; k <= steps; k++ {
+			angle := from + (to-from)*float64(k)/float64(steps)
+			points = append(points, gofpdf.PointType{X: cx + r*math.Cos(angle), Y: cy + r*math.Sin(angle)})
+		}
+		return points
+	}
+
+	pdf.SetDrawColor(255, 255, 255)
+	pdf.SetLineWidth(0.4)
+	start := -math.Pi / 2
+	names := make([]string, len(labels))
+	for i, value := range values {
+		share := value / total
+		names[i] = fmt.Sprintf("%s (%.1f%%)", labels[i], share*100)
+		if value == 0 {
+			continue
+		}
+		end := start + share*2*math.Pi
+		points := arc(radius, start, end, nil)
+		if inner > 0 {
+			reversed := arc(inner, start, end, nil)
+			for k := len(reversed) - 1; k >= 0; k-- {
+				points = append(points, reversed[k])
+			}
+		} else {
+			points = append(points, gofpdf.PointType{X: cx, Y: cy})
+		}
+		c := colors[i%len(colors)]
+		pdf.SetFillColor(c[0], c[1], c[2])
+		pdf.Polygon(points, "FD")
+
+		if style.ShowValues && share >= 0.04 {
+			middle := (start + end) / 2
+			r := (radius + inner) / 2
+			if inner == 0 {
+				r = radius * 0.65
+			}
+			useFont(pdf, style.Font, "B", style.FontSize)
+			pdf.SetTextColor(255, 255, 255)
+			pdf.SetXY(cx+r*math.Cos(middle)-10, cy+r*math.Sin(middle)-2)
+			pdf.CellFormat(20, 4, fmt.Sprintf("%.0f%%", share*100), "", 0, "C", false, 0, "")
+			pdf.SetTextColor(0, 0, 0)
+		}
+		start = end
+	}
+	pdf.SetDrawColor(0, 0, 0)
+
+	tr := useFont(pdf, style.Font, "", style.FontSize)
+	if donut && inner > 8 {
+		pdf.SetXY(cx-inner, cy-2)
+		pdf.CellFormat(2*inner, 4, chartNumber(total), "", 0, "C", false, 0, "")
+	}
+	pdf.SetXY(left, cy+radius+4)
+	if style.Legend {
+		drawChartLegend(pdf, tr, names, colors, style)
+	}
+	return nil
+}
+
+// drawChartLegend draws a color swatch and a name per entry, wrapping within the chart width
+func drawChartLegend(pdf *gofpdf.Fpdf, tr func(string) string, names []string, colors [][3]int, style chartStyle) {
+	useFont(pdf, style.Font, "", style.FontSize)
+	left, _, _, _ := pdf.GetMargins()
+	x, y := left, pdf.GetY()+2
+	for i, name := range names {
+		width := 6 + pdf.GetStringWidth(tr(name)) + 4
+		if x > left && x+width > left+style.Width {
+			x, y = left, y+5
+		}
+		c := colors[i%len(colors)]
+		pdf.SetFillColor(c[0], c[1], c[2])
+		pdf.Rect(x, y, 4, 3, "F")
+		pdf.SetXY(x+5, y-0.5)
+		pdf.CellFormat(width-5, 4, tr(name), "", 0, "L", false, 0, "")
+		x += width
+	}
+	pdf.SetXY(left, y+6)
+}
+
+// htmlToPDF - Lay out HTML with a CSS subset (headings, paragraphs, inline styles, lists, tables, images,
+// links and page breaks) and render it as a PDF. Stylesheets come from <style> elements and style attributes.
+func htmlToPDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "htmlToPDF", "htmlContent"),
+		})
+	}
+
+	htmlContent := args[0].String()
+	var options HTMLOptions
+	if len(args) > 1 && args[1].Type() != js.TypeUndefined && args[1].Type() != js.TypeNull {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options format: %v", err),
+			})
+		}
+	}
+
+	root, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to convert HTML to PDF: %v", err),
+		})
+	}
+
+	r := renderHTMLDocument(root, options)
+
+	var buf bytes.Buffer
+	if err := r.pdf.Output(&buf); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to convert HTML to PDF: %v", err),
+		})
+	}
+
+	htmlPdfData := binaryOutput(buf.Bytes())
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Converted HTML to PDF (%d pages, %d bytes)\n", r.pdf.PageCount(), buf.Len())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":        htmlPdfData,
+		"size":           buf.Len(),
+		"pages":          r.pdf.PageCount(),
+		"images":         r.images,
+		"links":          r.links,
+		"warnings":       r.warnings,
+		"originalLength": len(htmlContent),
+		"format":         "application/pdf",
+	})
+}
+
+// HTMLOptions configures htmlToPDF. Margin and orientation can also come from an @page rule;
+// Images maps <img src> values to their data since the module cannot fetch URLs.
+type HTMLOptions struct {
+	Orientation string                     `json:"orientation"`
+	Margin      float64                    `json:"margin"`
+	Font        string                     `json:"font"`
+	FontSize    float64                    `json:"fontSize"`
+	Title       string                     `json:"title"`
+	PageNumbers bool                       `json:"pageNumbers"`
+	TOC         TOCOptions                 `json:"toc"`
+	Images      map[string]json.RawMessage `json:"images"`
+}
+
+// htmlStyle is the computed style of an element; margins, paddings and borders are in mm, top/right/bottom/left
+type htmlStyle struct {
+	display       string
+	family        string
+	size          float64 // font size in points
+	bold          bool
+	italic        bool
+	underline     bool
+	strike        bool
+	color         [3]int
+	hasBackground bool
+	background    [3]int
+	align         string // L, C, R or J
+	lineHeight    float64
+	pre           bool
+	transform     string
+	listStyle     string
+	verticalAlign string
+	margin        [4]float64
+	autoMargin    [2]bool // left and right margins set to auto
+	padding       [4]float64
+	border        [4]float64
+	borderStyled  [4]bool
+	borderColor   [4][3]int
+	width         float64
+	widthPercent  float64
+	maxWidth      float64
+	height        float64
+	breakBefore   bool
+	breakAfter    bool
+	avoidBreak    bool
+}
+
+// inherit starts the style of a child element: inherited properties are copied, the box is reset
+func (s *htmlStyle) inherit() *htmlStyle {
+	child := &htmlStyle{
+		display:    "inline",
+		family:     s.family,
+		size:       s.size,
+		bold:       s.bold,
+		italic:     s.italic,
+		underline:  s.underline,
+		strike:     s.strike,
+		color:      s.color,
+		align:      s.align,
+		lineHeight: s.lineHeight,
+		pre:        s.pre,
+		transform:  s.transform,
+		listStyle:  s.listStyle,
+	}
+	if s.display == "inline" && s.hasBackground {
+		// Nested inline elements are painted over the background of their parent
+		child.hasBackground, child.background = true, s.background
+	}
+	for side := range child.border {
+		child.border[side] = 3 * pxToMM
+		child.borderColor[side] = s.color
+	}
+	return child
+}
+
+// borderWidth is the drawn width of a border side: a width without a visible style draws nothing
+func (s *htmlStyle) borderWidth(side int) float64 {
+	if !s.borderStyled[side] {
+		return 0
+	}
+	return s.border[side]
+}
+
+// inset returns the padding plus border of each side
+func (s *htmlStyle) inset() [4]float64 {
+	var inset [4]float64
+	for side := range inset {
+		inset[side] = s.padding[side] + s.borderWidth(side)
+	}
+	return inset
+}
+
+// fontStyle returns the gofpdf style string for the bold and italic flags
+func (s *htmlStyle) fontStyle() string {
+	style := ""
+	if s.bold {
+		style += "B"
+	}
+	if s.italic {
+		style += "I"
+	}
+	return style
+}
+
+// pxToMM converts CSS pixels (1/96 in) to millimeters
+const pxToMM = 25.4 / 96
+
+// ptToMM converts points (1/72 in) to millimeters
+const ptToMM = 25.4 / 72
+
+// cssDeclaration is a single property: value pair
+type cssDeclaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+// cssCompound is one compound selector such as td.amount:last-child; child is set when
+// it follows a > combinator
+type cssCompound struct {
+	tag     string
+	id      string
+	classes []string
+	pseudo  []string
+	child   bool
+}
+
+// cssRule is a selector with its declarations, ordered by specificity then source order
+type cssRule struct {
+	selector     []cssCompound
+	specificity  int
+	order        int
+	declarations []cssDeclaration
+}
+
+// htmlFragment is a piece of inline content: a word, a space, a forced line break, an image
+// or a zero-width anchor target
+type htmlFragment struct {
+	text      string
+	style     *htmlStyle
+	link      string
+	anchor    string
+	space     bool
+	preserved bool
+	lineBreak bool
+	image     []byte
+	imageType string
+	width     float64
+	height    float64
+}
+
+// htmlLine is a laid out line; last lines are not justified
+type htmlLine struct {
+	fragments []htmlFragment
+	width     float64
+	ascent    float64
+	descent   float64
+	last      bool
+}
+
+// htmlCell is a table cell with the first column it occupies
+type htmlCell struct {
+	node   *html.Node
+	style  *htmlStyle
+	column int
+	span   int
+}
+
+// htmlRow is a table row; background is the nearest row, row group or table background
+type htmlRow struct {
+	style         *htmlStyle
+	cells         []htmlCell
+	header        bool
+	hasBackground bool
+	background    [3]int
+}
+
+// htmlRenderer lays out an HTML tree on a gofpdf document. y is the cursor and pending the collapsed
+// vertical margin not placed yet; in dry mode nothing is drawn and pages never break, to measure heights.
+type htmlRenderer struct {
+	pdf           *gofpdf.Fpdf
+	rules         []cssRule
+	pageRules     []cssDeclaration
+	styles        map[*html.Node]*htmlStyle
+	widths        map[string]float64
+	sources       map[string]json.RawMessage
+	cp1252        func(string) string
+	defaultFamily string
+	rootSize      float64
+	pageWidth     float64
+	top           float64
+	bottom        float64
+	y             float64
+	pending       float64
+	dry           bool
+	noBreak       bool
+	breakNext     bool
+	marker        *htmlFragment
+	anchors       map[string]int
+	targets       []string
+	toc           *tableOfContents
+	headings      []tocEntry
+	images        int
+	links         int
+	warnings      []interface{}
+}
+
+// renderHTMLDocument lays out a parsed HTML document on a new A4 document. With a table of contents the
+// layout runs twice: the draft finds the page of each heading, the final layout lists them up front.
+func renderHTMLDocument(root *html.Node, options HTMLOptions) *htmlRenderer {
+	if !options.TOC.Enabled {
+		return layoutHTMLDocument(root, options, nil)
+	}
+	draft := layoutHTMLDocument(root, options, newTableOfContents(options.TOC))
+	return layoutHTMLDocument(root, options, draft.toc.final())
+}
+
+// layoutHTMLDocument runs one layout of a parsed HTML document
+func layoutHTMLDocument(root *html.Node, options HTMLOptions, toc *tableOfContents) *htmlRenderer {
+	r := &htmlRenderer{
+		toc:      toc,
+		styles:   map[*html.Node]*htmlStyle{},
+		widths:   map[string]float64{},
+		sources:  options.Images,
+		anchors:  map[string]int{},
+		warnings: []interface{}{},
+	}
+
+	// Stylesheets and internal link targets are collected before layout
+	title := options.Title
+	var internal []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "style":
+				if n.FirstChild != nil {
+					r.parseStylesheet(n.FirstChild.Data)
+				}
+			case "title":
+				if title == "" && n.FirstChild != nil {
+					title = strings.TrimSpace(n.FirstChild.Data)
+				}
+			case "a":
+				if href := htmlAttr(n, "href"); strings.HasPrefix(href, "#") && len(href) > 1 {
+					internal = append(internal, href[1:])
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	orientation := "P"
+	margins := [4]float64{20, 20, 20, 20}
+	for _, decl := range r.pageRules {
+		switch decl.property {
+		case "size":
+			if strings.Contains(strings.ToLower(decl.value), "landscape") {
+				orientation = "L"
+			}
+		case "margin":
+			values := strings.Fields(decl.value)
+			for side, value := range cssSides(values) {
+				if length, ok := r.length(value, 12, 0); ok {
+					margins[side] = length
+				}
+			}
+		}
+	}
+	if strings.HasPrefix(strings.ToUpper(options.Orientation), "L") {
+		orientation = "L"
+	} else if strings.HasPrefix(strings.ToUpper(options.Orientation), "P") {
+		orientation = "P"
+	}
+	if options.Margin > 0 {
+		margins = [4]float64{options.Margin, options.Margin, options.Margin, options.Margin}
+	}
+
+	pdf := newDocument(orientation)
+	r.pdf = pdf
+	r.cp1252 = pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(margins[3], margins[0], margins[1])
+	pdf.SetAutoPageBreak(false, margins[2])
+	if title != "" {
+		pdf.SetTitle(title, true)
+	}
+	for _, id := range internal {
+		if _, ok := r.anchors[id]; !ok {
+			r.anchors[id] = pdf.AddLink()
+		}
+	}
+	if options.PageNumbers {
+		pdf.AliasNbPages("")
+		pdf.SetFooterFunc(func() {
+			tr := useFont(pdf, options.Font, "I", 8)
+			pageWidth, _ := pdf.GetPageSize()
+			pdf.SetTextColor(0, 0, 0)
+			pdf.SetXY(margins[3], -15)
+			pdf.CellFormat(pageWidth-margins[1]-margins[3], 10, tr(localize("Page %d / {nb}", pdf.PageNo())), "", 0, "C", false, 0, "")
+		})
+	}
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	r.pageWidth = pageWidth - margins[1] - margins[3]
+	r.top, r.bottom = margins[0], pageHeight-margins[2]
+
+	r.defaultFamily = "Arial"
+	if _, ok := registeredFonts[options.Font]; ok {
+		r.defaultFamily = options.Font
+	}
+	base := &htmlStyle{display: "block", family: r.defaultFamily, size: options.FontSize, align: "L", lineHeight: 1.2, listStyle: "disc"}
+	if base.size <= 0 {
+		base.size = 11
+	}
+	r.rootSize = base.size
+
+	pdf.AddPage()
+	r.y = r.top
+	if toc != nil {
+		toc.draw(pdf, options.Font)
+		r.newPage()
+	}
+	for n := root.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode && n.Data == "html" {
+			s := r.style(n, base)
+			r.rootSize = s.size
+			r.renderBlock(n, s, margins[3], r.pageWidth)
+		}
+	}
+	return r
+}
+
+// htmlAttr returns an attribute value, or "" when it is missing
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// htmlHeadingLevel returns the level of an h1-h6 element, or 0
+func htmlHeadingLevel(n *html.Node) int {
+	if len(n.Data) == 2 && n.Data[0] == 'h' && n.Data[1] >= '1' && n.Data[1] <= '6' {
+		return int(n.Data[1] - '0')
+	}
+	return 0
+}
+
+// htmlNodeText returns the text content of an element with whitespace collapsed
+func htmlNodeText(n *html.Node) string {
+	var text strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+			text.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(text.String()), " ")
+}
+
+// htmlBlockDisplays are the display values laid out as blocks
+var htmlBlockDisplays = map[string]bool{
+	"block": true, "list-item": true, "table": true, "table-row-group": true,
+	"table-row": true, "table-cell": true, "table-caption": true,
+}
+
+// renderChildren lays out the children of n: runs of inline content become anonymous paragraphs
+func (r *htmlRenderer) renderChildren(n *html.Node, s *htmlStyle, x, width float64) {
+	var inline []*html.Node
+	flush := func() {
+		if len(inline) > 0 {
+			r.renderInline(inline, s, x, width)
+			inline = nil
+		}
+	}
+
+	total, done := 0, 0
+	if n.Data == "body" && !r.dry && !r.toc.drafting() {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			total++
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			inline = append(inline, c)
+		case html.ElementNode:
+			cs := r.style(c, s)
+			switch {
+			case cs.display == "none":
+			case htmlBlockDisplays[cs.display]:
+				flush()
+				r.renderBlock(c, cs, x, width)
+			default:
+				inline = append(inline, c)
+			}
+		}
+		if total > 0 {
+			done++
+			reportProgress(done, total)
+		}
+	}
+	flush()
+}
+
+// renderBlock lays out a block-level element within the horizontal band [x, x+width]
+func (r *htmlRenderer) renderBlock(n *html.Node, s *htmlStyle, x, width float64) {
+	if s.breakBefore {
+		r.breakNext = true
+	}
+	r.pending = math.Max(r.pending, s.margin[0])
+	if id := htmlAttr(n, "id"); id != "" {
+		r.targets = append(r.targets, id)
+	}
+	if level := htmlHeadingLevel(n); level > 0 && r.toc != nil && !r.dry {
+		r.headings = append(r.headings, tocEntry{Level: level, Title: htmlNodeText(n)})
+	}
+
+	x += s.margin[3]
+	width -= s.margin[1] + s.margin[3]
+	if s.display != "table" {
+		x, width = s.boxWidth(x, width)
+	}
+	inset := s.inset()
+	cx, cw := x+inset[3], width-inset[1]-inset[3]
+
+	if s.display == "list-item" {
+		r.marker = r.listMarker(n, s)
+	}
+	content := func() {
+		if s.display == "table" {
+			r.renderTable(n, s, cx, cw)
+		} else {
+			r.renderChildren(n, s, cx, cw)
+		}
+	}
+
+	decorated := s.hasBackground || inset[0] > 0 || inset[2] > 0 || s.height > 0 ||
+		s.borderWidth(1) > 0 || s.borderWidth(3) > 0
+	switch {
+	case decorated:
+		h := math.Max(r.measure(content)+inset[0]+inset[2], s.height)
+		fits := h <= r.bottom-r.top
+		if fits {
+			r.keep(h)
+		}
+		r.place(0)
+		top := r.y
+		if fits && !r.dry {
+			r.drawBox(s, x, top, width, h)
+		}
+		r.y += inset[0]
+		content()
+		r.y += r.pending + inset[2]
+		r.pending = 0
+		if fits {
+			r.y = math.Max(r.y, top+h)
+		}
+
+	case s.avoidBreak:
+		if h := r.measure(content); h <= r.bottom-r.top {
+			r.keep(h)
+		}
+		content()
+
+	default:
+		content()
+	}
+
+	if s.display == "list-item" {
+		r.marker = nil
+	}
+	r.pending = math.Max(r.pending, s.margin[2])
+	if s.breakAfter {
+		r.breakNext = true
+	}
+}
+
+// boxWidth applies an explicit width to a block, centering or right-aligning it with auto margins
+func (s *htmlStyle) boxWidth(x, width float64) (float64, float64) {
+	content := s.width
+	if s.widthPercent > 0 {
+		content = width * s.widthPercent / 100
+	}
+	if s.maxWidth > 0 && (content == 0 || content > s.maxWidth) {
+		content = s.maxWidth
+	}
+	if content <= 0 {
+		return x, width
+	}
+
+	inset := s.inset()
+	box := math.Min(content+inset[1]+inset[3], width)
+	switch {
+	case s.autoMargin[0] && s.autoMargin[1]:
+		x += (width - box) / 2
+	case s.autoMargin[0]:
+		x += width - box
+	}
+	return x, box
+}
+
+// drawBox paints the background and borders of a block or a table cell
+func (r *htmlRenderer) drawBox(s *htmlStyle, x, y, width, height float64) {
+	if s.hasBackground {
+		r.pdf.SetFillColor(s.background[0], s.background[1], s.background[2])
+		r.pdf.Rect(x, y, width, height, "F")
+	}
+	r.drawBorders(s, x, y, width, height)
+}
+
+// drawBorders strokes each visible border side, centered on the box edge
+func (r *htmlRenderer) drawBorders(s *htmlStyle, x, y, width, height float64) {
+	edges := [4][4]float64{
+		{x, y, x + width, y},
+		{x + width, y, x + width, y + height},
+		{x, y + height, x + width, y + height},
+		{x, y, x, y + height},
+	}
+	for side, edge := range edges {
+		if w := s.borderWidth(side); w > 0 {
+			c := s.borderColor[side]
+			r.pdf.SetDrawColor(c[0], c[1], c[2])
+			r.pdf.SetLineWidth(w)
+			r.pdf.Line(edge[0], edge[1], edge[2], edge[3])
+		}
+	}
+}
+
+// measure runs a layout in dry mode and returns the height it used
+func (r *htmlRenderer) measure(layout func()) float64 {
+	y, pending, dry, marker, breakNext, targets := r.y, r.pending, r.dry, r.marker, r.breakNext, r.targets
+	r.y, r.pending, r.dry, r.targets = 0, 0, true, nil
+	layout()
+	height := r.y + r.pending
+	r.y, r.pending, r.dry, r.marker, r.breakNext, r.targets = y, pending, dry, marker, breakNext, targets
+	return height
+}
+
+// keep starts a new page when content of the given height would not fit on the current one
+func (r *htmlRenderer) keep(height float64) {
+	if !r.dry && !r.noBreak && r.y > r.top && r.y+r.pending+height > r.bottom {
+		r.newPage()
+	}
+}
+
+// place reserves height for content at the cursor: a pending page break is taken, the collapsed margin
+// is added, and the page breaks when the content would not fit. Anchors waiting for content point here.
+func (r *htmlRenderer) place(height float64) {
+	if r.dry {
+		r.y += r.pending
+		r.pending = 0
+		return
+	}
+
+	if r.breakNext && r.y > r.top && !r.noBreak {
+		r.newPage()
+	}
+	r.breakNext = false
+	if !r.noBreak && r.y > r.top && r.y+r.pending+height > r.bottom {
+		r.newPage()
+	}
+	r.y += r.pending
+	r.pending = 0
+
+	for _, id := range r.targets {
+		if link, ok := r.anchors[id]; ok {
+			r.pdf.SetLink(link, r.y, r.pdf.PageNo())
+		}
+	}
+	r.targets = nil
+	for _, heading := range r.headings {
+		r.toc.mark(r.pdf, heading.Level, heading.Title, r.y)
+	}
+	r.headings = nil
+}
+
+// newPage continues the layout at the top of a new page; margins do not carry over a page break
+func (r *htmlRenderer) newPage() {
+	r.pdf.AddPage()
+	r.y = r.top
+	r.pending = 0
+	r.breakNext = false
+}
+
+// renderInline lays out inline content as lines aligned by the enclosing block
+func (r *htmlRenderer) renderInline(nodes []*html.Node, s *htmlStyle, x, width float64) {
+	var fragments []htmlFragment
+	for _, n := range nodes {
+		r.collectInline(n, s, "", width, &fragments)
+	}
+	for _, line := range r.layoutLines(fragments, width) {
+		r.drawLine(line, x, width, s.align)
+	}
+}
+
+// collectInline flattens inline content into fragments; nested blocks become line breaks
+func (r *htmlRenderer) collectInline(n *html.Node, s *htmlStyle, link string, width float64, fragments *[]htmlFragment) {
+	if n.Type == html.TextNode {
+		r.appendText(n.Data, s, link, fragments)
+		return
+	}
+	if n.Type != html.ElementNode {
+		return
+	}
+
+	cs := r.style(n, s)
+	if cs.display == "none" {
+		return
+	}
+	if id := htmlAttr(n, "id"); id != "" {
+		*fragments = append(*fragments, htmlFragment{anchor: id, style: cs})
+	}
+	switch n.Data {
+	case "br":
+		*fragments = append(*fragments, htmlFragment{lineBreak: true, style: cs})
+		return
+	case "img":
+		r.appendImage(n, cs, link, width, fragments)
+		return
+	case "a":
+		if href := htmlAttr(n, "href"); href != "" {
+			link = href
+		}
+	}
+
+	block := htmlBlockDisplays[cs.display]
+	breakLine := func() {
+		if count := len(*fragments); count > 0 && !(*fragments)[count-1].lineBreak {
+			*fragments = append(*fragments, htmlFragment{lineBreak: true, style: cs})
+		}
+	}
+	if block {
+		breakLine()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.collectInline(c, cs, link, width, fragments)
+	}
+	if block {
+		breakLine()
+	}
+}
+
+// appendText splits text into words and spaces; white space collapses unless the style preserves it
+func (r *htmlRenderer) appendText(text string, s *htmlStyle, link string, fragments *[]htmlFragment) {
+	switch s.transform {
+	case "uppercase":
+		text = strings.ToUpper(text)
+	case "lowercase":
+		text = strings.ToLower(text)
+	case "capitalize":
+		text = strings.Title(text)
+	}
+
+	if s.pre {
+		text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\t", "    ")
+		for i, line := range strings.Split(text, "\n") {
+			if i > 0 {
+				*fragments = append(*fragments, htmlFragment{lineBreak: true, style: s})
+			}
+			for j, word := range strings.Split(line, " ") {
+				if j > 0 {
+					*fragments = append(*fragments, htmlFragment{text: " ", space: true, preserved: true, style: s, link: link})
+				}
+				if word != "" {
+					*fragments = append(*fragments, htmlFragment{text: word, style: s, link: link})
+				}
+			}
+		}
+		return
+	}
+
+	// Only ASCII white space collapses, a no-break space stays part of its word
+	isSpace := func(c rune) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+	words := strings.FieldsFunc(text, isSpace)
+	space := htmlFragment{text: " ", space: true, style: s, link: link}
+	if text != "" && isSpace(rune(text[0])) {
+		*fragments = append(*fragments, space)
+	}
+	for i, word := range words {
+		if i > 0 {
+			*fragments = append(*fragments, space)
+		}
+		*fragments = append(*fragments, htmlFragment{text: word, style: s, link: link})
+	}
+	if len(words) > 0 && isSpace(rune(text[len(text)-1])) {
+		*fragments = append(*fragments, space)
+	}
+}
+
+// appendImage adds an inline image sized from its attributes or CSS, keeping the aspect ratio;
+// an image that cannot be loaded is replaced by its alt text
+func (r *htmlRenderer) appendImage(n *html.Node, s *htmlStyle, link string, width float64, fragments *[]htmlFragment) {
+	src := htmlAttr(n, "src")
+	data, err := r.imageSource(src)
+	var config image.Config
+	format := ""
+	if err == nil {
+		config, format, err = image.DecodeConfig(bytes.NewReader(data))
+		if err == nil && format != "jpeg" && format != "png" {
+			err = errors.New(localize("only JPEG and PNG images are supported"))
+		}
+	}
+	if err != nil {
+		r.warn(localize("image %q skipped: %v", truncateSource(src), err))
+		r.appendText(htmlAttr(n, "alt"), s, link, fragments)
+		return
+	}
+
+	w, h := s.width, s.height
+	if s.widthPercent > 0 {
+		w = width * s.widthPercent / 100
+	}
+	naturalW, naturalH := float64(config.Width)*pxToMM, float64(config.Height)*pxToMM
+	switch {
+	case w == 0 && h == 0:
+		w, h = naturalW, naturalH
+	case w == 0 && naturalH > 0:
+		w = h * naturalW / naturalH
+	case h == 0 && naturalW > 0:
+		h = w * naturalH / naturalW
+	}
+	if s.maxWidth > 0 && w > s.maxWidth {
+		h, w = h*s.maxWidth/w, s.maxWidth
+	}
+	if w > width && width > 0 {
+		h, w = h*width/w, width
+	}
+	*fragments = append(*fragments, htmlFragment{image: data, imageType: format, width: w, height: h, style: s, link: link})
+}
+
+// imageSource resolves an <img src>: data URLs are decoded, other sources are looked up in options.images
+func (r *htmlRenderer) imageSource(src string) ([]byte, error) {
+	if strings.HasPrefix(src, "data:") {
+		comma := strings.Index(src, ",")
+		if comma < 0 || !strings.Contains(src[:comma], ";base64") {
+			return nil, errors.New(localize("data URLs must be base64 encoded"))
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(src[comma+1:]))
+	}
+	if raw, ok := r.sources[src]; ok {
+		return decodeImageData(raw)
+	}
+	return nil, errors.New(localize("no data provided in options.images"))
+}
+
+// warn records a warning once, layouts measured several times report it only once
+func (r *htmlRenderer) warn(message string) {
+	for _, warning := range r.warnings {
+		if warning == message {
+			return
+		}
+	}
+	r.warnings = append(r.warnings, message)
+}
+
+// truncateSource shortens long image sources such as data URLs in warnings
+func truncateSource(src string) string {
+	if len(src) > 48 {
+		return src[:45] + "..."
+	}
+	return src
+}
+
+// textWidth measures a text fragment in its font, caching the result
+func (r *htmlRenderer) textWidth(text string, s *htmlStyle) float64 {
+	key := fmt.Sprintf("%s|%s|%g|%s", s.family, s.fontStyle(), s.size, text)
+	if width, ok := r.widths[key]; ok {
+		return width
+	}
+	tr := r.font(s)
+	width := r.pdf.GetStringWidth(tr(text))
+	r.widths[key] = width
+	return width
+}
+
+// font selects the font of a style and returns the encoder for its text
+func (r *htmlRenderer) font(s *htmlStyle) func(string) string {
+	if _, ok := registeredFonts[s.family]; ok {
+		return useFont(r.pdf, s.family, s.fontStyle(), s.size)
+	}
+	r.pdf.SetFont(s.family, s.fontStyle(), s.size)
+	return r.cp1252
+}
+
+// layoutLines breaks fragments into lines of at most width, breaking at spaces and forced breaks;
+// a word wider than the line is split between characters
+func (r *htmlRenderer) layoutLines(fragments []htmlFragment, width float64) []htmlLine {
+	for i := range fragments {
+		f := &fragments[i]
+		if f.image == nil && f.text != "" {
+			f.width = r.textWidth(f.text, f.style)
+		}
+	}
+
+	var lines []htmlLine
+	var current htmlLine
+	hasContent := false
+	finish := func(last bool, breakStyle *htmlStyle) {
+		// Collapsible spaces at the end of a line are dropped
+		for len(current.fragments) > 0 {
+			end := current.fragments[len(current.fragments)-1]
+			if !end.space || end.preserved {
+				break
+			}
+			current.fragments = current.fragments[:len(current.fragments)-1]
+			current.width -= end.width
+		}
+		if !hasContent && breakStyle == nil {
+			current = htmlLine{}
+			return
+		}
+		if !hasContent {
+			current.fragments = append(current.fragments, htmlFragment{style: breakStyle})
+		}
+		current.last = last
+		for _, f := range current.fragments {
+			ascent, descent := f.metrics()
+			current.ascent = math.Max(current.ascent, ascent)
+			current.descent = math.Max(current.descent, descent)
+		}
+		lines = append(lines, current)
+		current = htmlLine{}
+		hasContent = false
+	}
+	add := func(f htmlFragment) {
+		current.fragments = append(current.fragments, f)
+		current.width += f.width
+		if !f.space && f.anchor == "" {
+			hasContent = true
+		}
+	}
+
+	for i := 0; i < len(fragments); {
+		f := fragments[i]
+		switch {
+		case f.lineBreak:
+			finish(true, f.style)
+			i++
+
+		case f.space:
+			count := len(current.fragments)
+			if f.preserved || (count > 0 && !current.fragments[count-1].space) {
+				add(f)
+			}
+			i++
+
+		default:
+			// A unit is a run of fragments without break opportunity, like "<b>Total</b>:"
+			j, unit := i, 0.0
+			for j < len(fragments) && !fragments[j].space && !fragments[j].lineBreak {
+				unit += fragments[j].width
+				j++
+			}
+			if hasContent && current.width+unit > width+0.01 {
+				finish(false, nil)
+			}
+			if unit > width+0.01 {
+				for _, piece := range fragments[i:j] {
+					r.splitFragment(piece, width, &current, &hasContent, func() { finish(false, nil) })
+				}
+			} else {
+				for _, piece := range fragments[i:j] {
+					add(piece)
+				}
+			}
+			i = j
+		}
+	}
+	finish(true, nil)
+	return lines
+}
+
+// splitFragment adds a fragment too wide for the line, starting new lines between characters
+func (r *htmlRenderer) splitFragment(f htmlFragment, width float64, current *htmlLine, hasContent *bool, finish func()) {
+	if f.image != nil || f.text == "" {
+		if *hasContent && current.width+f.width > width {
+			finish()
+		}
+		current.fragments = append(current.fragments, f)
+		current.width += f.width
+		*hasContent = *hasContent || f.image != nil
+		return
+	}
+
+	piece := ""
+	for _, c := range f.text {
+		w := r.textWidth(piece+string(c), f.style)
+		if piece != "" && current.width+w > width {
+			part := f
+			part.text, part.width = piece, r.textWidth(piece, f.style)
+			current.fragments = append(current.fragments, part)
+			current.width += part.width
+			*hasContent = true
+			finish()
+			piece = ""
+		} else if piece == "" && *hasContent && current.width+w > width {
+			finish()
+		}
+		piece += string(c)
+	}
+	if piece != "" {
+		part := f
+		part.text, part.width = piece, r.textWidth(piece, f.style)
+		current.fragments = append(current.fragments, part)
+		current.width += part.width
+		*hasContent = true
+	}
+}
+
+// metrics returns the height a fragment needs above and below the baseline, including half the leading
+func (f htmlFragment) metrics() (float64, float64) {
+	if f.image != nil {
+		return f.height, 0
+	}
+	size := f.style.size * ptToMM
+	leading := (f.style.lineHeight*size - size) / 2
+	return 0.8*size + leading, 0.2*size + leading
+}
+
+// drawLine places a line at the cursor, aligning or justifying it within the band
+func (r *htmlRenderer) drawLine(line htmlLine, x, width float64, align string) {
+	height := line.ascent + line.descent
+	r.place(height)
+	top := r.y
+	r.y += height
+	if r.dry {
+		return
+	}
+
+	offset, stretch := 0.0, 0.0
+	extra := width - line.width
+	switch align {
+	case "C":
+		offset = extra / 2
+	case "R":
+		offset = extra
+	case "J":
+		spaces := 0
+		for _, f := range line.fragments {
+			if f.space {
+				spaces++
+			}
+		}
+		if !line.last && spaces > 0 && extra > 0 {
+			stretch = extra / float64(spaces)
+		}
+	}
+
+	baseline := top + line.ascent
+	if r.marker != nil {
+		marker := *r.marker
+		r.marker = nil
+		tr := r.font(marker.style)
+		c := marker.style.color
+		r.pdf.SetTextColor(c[0], c[1], c[2])
+		r.pdf.Text(x-r.textWidth(marker.text, marker.style)-1.5, baseline, tr(marker.text))
+	}
+
+	// Consecutive fragments of the same link share one annotation
+	linkStart, linkTarget := 0.0, ""
+	flushLink := func(end float64) {
+		if linkTarget != "" && end > linkStart {
+			if strings.HasPrefix(linkTarget, "#") {
+				if link, ok := r.anchors[linkTarget[1:]]; ok {
+					r.pdf.Link(linkStart, top, end-linkStart, height, link)
+				}
+			} else {
+				r.pdf.LinkString(linkStart, top, end-linkStart, height, linkTarget)
+			}
+			r.links++
+		}
+	}
+
+	cx := x + math.Max(offset, 0)
+	for _, f := range line.fragments {
+		w := f.width
+		if f.space {
+			w += stretch
+		}
+		if f.anchor != "" {
+			if link, ok := r.anchors[f.anchor]; ok {
+				r.pdf.SetLink(link, top, r.pdf.PageNo())
+			}
+		}
+		if f.style.hasBackground && w > 0 && f.image == nil {
+			b := f.style.background
+			r.pdf.SetFillColor(b[0], b[1], b[2])
+			r.pdf.Rect(cx, top, w, height, "F")
+		}
+
+		switch {
+		case f.image != nil:
+			if err := placeImageData(r.pdf, f.image, f.imageType, cx, baseline-f.height, f.width, f.height, false); err == nil {
+				r.images++
+			}
+		case f.text != "" && (!f.space || f.style.underline || f.style.strike):
+			tr := r.font(f.style)
+			c := f.style.color
+			r.pdf.SetTextColor(c[0], c[1], c[2])
+			if !f.space {
+				r.pdf.Text(cx, baseline, tr(f.text))
+			}
+			size := f.style.size * ptToMM
+			r.pdf.SetDrawColor(c[0], c[1], c[2])
+			r.pdf.SetLineWidth(size * 0.06)
+			if f.style.underline {
+				r.pdf.Line(cx, baseline+size*0.12, cx+w, baseline+size*0.12)
+			}
+			if f.style.strike {
+				r.pdf.Line(cx, baseline-size*0.27, cx+w, baseline-size*0.27)
+			}
+		}
+
+		if f.link != linkTarget {
+			flushLink(cx)
+			linkStart, linkTarget = cx, f.link
+		}
+		cx += w
+	}
+	flushLink(cx)
+}
+
+// listMarker returns the bullet or number of a list item, numbered among its siblings
+func (r *htmlRenderer) listMarker(n *html.Node, s *htmlStyle) *htmlFragment {
+	number := 1
+	if n.Parent != nil && n.Parent.Data == "ol" {
+		if start, err := strconv.Atoi(htmlAttr(n.Parent, "start")); err == nil {
+			number = start
+		}
+	}
+	if n.Parent != nil {
+		for c := n.Parent.FirstChild; c != nil && c != n; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "li" {
+				number++
+				if value, err := strconv.Atoi(htmlAttr(c, "value")); err == nil {
+					number = value + 1
+				}
+			}
+		}
+	}
+	if value, err := strconv.Atoi(htmlAttr(n, "value")); err == nil {
+		number = value
+	}
+
+	text := ""
+	switch s.listStyle {
+	case "none":
+		return nil
+	case "disc":
+		text = "•"
+	case "circle":
+		text = "o"
+	case "square":
+		text = "-"
+	case "lower-alpha", "lower-latin":
+		text = alphaNumber(number) + "."
+	case "upper-alpha", "upper-latin":
+		text = strings.ToUpper(alphaNumber(number)) + "."
+	case "lower-roman":
+		text = strings.ToLower(romanNumber(number)) + "."
+	case "upper-roman":
+		text = romanNumber(number) + "."
+	default:
+		text = strconv.Itoa(number) + "."
+	}
+	return &htmlFragment{text: text, style: s}
+}
+
+// alphaNumber formats 1, 2, ..., 26, 27 as a, b, ..., z, aa
+func alphaNumber(n int) string {
+	if n < 1 {
+		return strconv.Itoa(n)
+	}
+	text := ""
+	for n > 0 {
+		n--
+		text = string(rune('a'+n%26)) + text
+		n /= 26
+	}
+	return text
+}
+
+// romanNumber formats n in upper case roman numerals
+func romanNumber(n int) string {
+	if n < 1 || n > 3999 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	text := ""
+	for i, value := range values {
+		for n >= value {
+			text += symbols[i]
+			n -= value
+		}
+	}
+	return text
+}
+
+// renderTable lays out a table: column widths come from explicit widths and the content, rows never
+// split across pages and header rows are repeated at the top of each new page
+func (r *htmlRenderer) renderTable(n *html.Node, s *htmlStyle, x, width float64) {
+	var rows []htmlRow
+	var caption *html.Node
+	addRow := func(tr *html.Node, parent *htmlStyle, header bool, group *htmlStyle) {
+		rs := r.style(tr, parent)
+		if rs.display == "none" {
+			return
+		}
+		row := htmlRow{style: rs, header: header}
+		for _, candidate := range []*htmlStyle{rs, group, s} {
+			if candidate != nil && candidate.hasBackground {
+				row.hasBackground, row.background = true, candidate.background
+				break
+			}
+		}
+		column := 0
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.Data != "td" && c.Data != "th") {
+				continue
+			}
+			cs := r.style(c, rs)
+			if cs.display == "none" {
+				continue
+			}
+			span, err := strconv.Atoi(htmlAttr(c, "colspan"))
+			if err != nil || span < 1 {
+				span = 1
+			}
+			row.cells = append(row.cells, htmlCell{node: c, style: cs, column: column, span: span})
+			column += span
+		}
+		rows = append(rows, row)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.Data {
+		case "caption":
+			caption = c
+		case "tr":
+			addRow(c, s, false, nil)
+		case "thead", "tbody", "tfoot":
+			gs := r.style(c, s)
+			if gs.display == "none" {
+				continue
+			}
+			for tr := c.FirstChild; tr != nil; tr = tr.NextSibling {
+				if tr.Type == html.ElementNode && tr.Data == "tr" {
+					addRow(tr, gs, c.Data == "thead", gs)
+				}
+			}
+		}
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row.cells) > 0 {
+			last := row.cells[len(row.cells)-1]
+			columns = max(columns, last.column+last.span)
+		}
+	}
+
+	tableWidth := s.width
+	if s.widthPercent > 0 {
+		tableWidth = width * s.widthPercent / 100
+	}
+	explicit := tableWidth > 0
+	if !explicit || tableWidth > width {
+		tableWidth = width
+	}
+
+	// Column widths: explicit cell widths are fixed, the others get their content size, squeezed
+	// between minimum (longest word) and natural width when the table is too narrow
+	minimum := make([]float64, columns)
+	natural := make([]float64, columns)
+	fixed := make([]float64, columns)
+	var spanning []htmlCell
+	for _, row := range rows {
+		for _, cell := range row.cells {
+			if cell.span != 1 {
+				spanning = append(spanning, cell)
+				continue
+			}
+			inset := cell.style.inset()
+			pad := inset[1] + inset[3]
+			if w := cell.style.width; w > 0 || cell.style.widthPercent > 0 {
+				if cell.style.widthPercent > 0 {
+					w = tableWidth * cell.style.widthPercent / 100
+				} else {
+					w += pad
+				}
+				fixed[cell.column] = math.Max(fixed[cell.column], w)
+			}
+			low, high := r.contentWidths(cell.node, cell.style, tableWidth)
+			minimum[cell.column] = math.Max(minimum[cell.column], low+pad)
+			natural[cell.column] = math.Max(natural[cell.column], high+pad)
+		}
+	}
+	for _, cell := range spanning {
+		// Spanned columns grow evenly when their content does not fit across them
+		inset := cell.style.inset()
+		low, high := r.contentWidths(cell.node, cell.style, tableWidth)
+		low, high = low+inset[1]+inset[3], high+inset[1]+inset[3]
+		spanMin, spanNatural := 0.0, 0.0
+		last := min(cell.column+cell.span, columns)
+		for i := cell.column; i < last; i++ {
+			spanMin += minimum[i]
+			spanNatural += natural[i]
+		}
+		for i := cell.column; i < last; i++ {
+			minimum[i] += math.Max(low-spanMin, 0) / float64(last-cell.column)
+			natural[i] += math.Max(high-spanNatural, 0) / float64(last-cell.column)
+		}
+	}
+	widths := make([]float64, columns)
+	remaining, sumMin, sumNatural := tableWidth, 0.0, 0.0
+	for i := range widths {
+		if fixed[i] > 0 {
+			widths[i] = math.Max(fixed[i], minimum[i])
+			remaining -= widths[i]
+			continue
+		}
+		sumMin += minimum[i]
+		sumNatural += natural[i]
+	}
+	for i := range widths {
+		if fixed[i] > 0 {
+			continue
+		}
+		switch {
+		case sumNatural <= remaining:
+			widths[i] = natural[i]
+			if explicit && sumNatural > 0 {
+				widths[i] = natural[i] * remaining / sumNatural
+			} else if explicit {
+				widths[i] = remaining / float64(max(columns, 1))
+			}
+		case sumMin < remaining && sumNatural > sumMin:
+			widths[i] = minimum[i] + (natural[i]-minimum[i])*(remaining-sumMin)/(sumNatural-sumMin)
+		case sumMin > 0:
+			widths[i] = minimum[i] * math.Max(remaining, 0) / sumMin
+		}
+	}
+	total := 0.0
+	for _, w := range widths {
+		total += w
+	}
+	if total > width && total > 0 {
+		for i := range widths {
+			widths[i] *= width / total
+		}
+		total = width
+	}
+	switch {
+	case s.autoMargin[0] && s.autoMargin[1]:
+		x += (width - total) / 2
+	case s.autoMargin[0]:
+		x += width - total
+	}
+
+	if caption != nil {
+		cs := r.style(caption, s)
+		r.renderBlock(caption, cs, x, total)
+	}
+
+	// Rows keep their cell margins inside and do not collapse with the margins around the table
+	r.place(0)
+	var header []htmlRow
+	for _, row := range rows {
+		if row.header {
+			header = append(header, row)
+		}
+	}
+	for _, row := range rows {
+		height := r.rowHeight(row, widths)
+		if !r.dry && !r.noBreak && r.y > r.top && r.y+height > r.bottom {
+			r.newPage()
+			if !row.header {
+				for _, repeated := range header {
+					r.drawRow(repeated, widths, x, r.rowHeight(repeated, widths))
+				}
+			}
+		}
+		r.drawRow(row, widths, x, height)
+	}
+}
+
+// cellWidth returns the width of a cell across its spanned columns
+func cellWidth(cell htmlCell, widths []float64) float64 {
+	width := 0.0
+	for i := cell.column; i < cell.column+cell.span && i < len(widths); i++ {
+		width += widths[i]
+	}
+	return width
+}
+
+// rowHeight measures the tallest cell of a row
+func (r *htmlRenderer) rowHeight(row htmlRow, widths []float64) float64 {
+	height := row.style.height
+	for _, cell := range row.cells {
+		inset := cell.style.inset()
+		inner := cellWidth(cell, widths) - inset[1] - inset[3]
+		content := r.measure(func() { r.renderChildren(cell.node, cell.style, 0, inner) })
+		height = math.Max(height, math.Max(content+inset[0]+inset[2], cell.style.height))
+	}
+	return height
+}
+
+// drawRow draws the cells of a row at the cursor, vertically aligned within the row height
+func (r *htmlRenderer) drawRow(row htmlRow, widths []float64, x, height float64) {
+	r.place(height)
+	top := r.y
+	for _, cell := range row.cells {
+		cx := x
+		for i := 0; i < cell.column && i < len(widths); i++ {
+			cx += widths[i]
+		}
+		w := cellWidth(cell, widths)
+		inset := cell.style.inset()
+		inner := w - inset[1] - inset[3]
+
+		offset := 0.0
+		if cell.style.verticalAlign != "top" && cell.style.verticalAlign != "baseline" {
+			content := r.measure(func() { r.renderChildren(cell.node, cell.style, 0, inner) })
+			offset = height - inset[0] - inset[2] - content
+			if cell.style.verticalAlign != "bottom" {
+				offset /= 2
+			}
+		}
+
+		if !r.dry {
+			if !cell.style.hasBackground && row.hasBackground {
+				r.pdf.SetFillColor(row.background[0], row.background[1], row.background[2])
+				r.pdf.Rect(cx, top, w, height, "F")
+			}
+			r.drawBox(cell.style, cx, top, w, height)
+		}
+
+		if id := htmlAttr(cell.node, "id"); id != "" {
+			r.targets = append(r.targets, id)
+		}
+		noBreak := r.noBreak
+		r.noBreak = true
+		r.y, r.pending = top+inset[0]+math.Max(offset, 0), 0
+		r.renderChildren(cell.node, cell.style, cx+inset[3], inner)
+		r.noBreak = noBreak
+	}
+	r.y, r.pending = top+height, 0
+}
+
+// contentWidths returns the widest unbreakable run and the widest line of a cell laid out without wrapping
+func (r *htmlRenderer) contentWidths(n *html.Node, s *htmlStyle, width float64) (float64, float64) {
+	var fragments []htmlFragment
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.collectInline(c, s, "", width, &fragments)
+	}
+
+	low, high, unit, line := 0.0, 0.0, 0.0, 0.0
+	for _, f := range fragments {
+		w := f.width
+		if f.image == nil && f.text != "" {
+			w = r.textWidth(f.text, f.style)
+		}
+		switch {
+		case f.lineBreak:
+			unit, line = 0, 0
+		case f.space:
+			unit = 0
+			line += w
+		default:
+			unit += w
+			line += w
+		}
+		low, high = math.Max(low, unit), math.Max(high, line)
+	}
+	return low, high
+}
+
+// style computes and caches the style of an element: user agent defaults, then the document CSS
+// by specificity, then the style attribute
+func (r *htmlRenderer) style(n *html.Node, parent *htmlStyle) *htmlStyle {
+	if s, ok := r.styles[n]; ok {
+		return s
+	}
+
+	s := parent.inherit()
+	if n.Namespace != "" {
+		// SVG and MathML content is not rendered
+		s.display = "none"
+		r.styles[n] = s
+		return s
+	}
+
+	var declarations []cssDeclaration
+	var matched []cssRule
+	for _, rule := range r.rules {
+		if matchSelector(n, rule.selector) {
+			matched = append(matched, rule)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].specificity != matched[j].specificity {
+			return matched[i].specificity < matched[j].specificity
+		}
+		return matched[i].order < matched[j].order
+	})
+	for _, rule := range matched {
+		declarations = append(declarations, rule.declarations...)
+	}
+	declarations = append(declarations, parseDeclarations(htmlAttr(n, "style"))...)
+	sort.SliceStable(declarations, func(i, j int) bool {
+		return !declarations[i].important && declarations[j].important
+	})
+
+	// The font size comes first, so that em lengths in the defaults and declarations use it
+	r.defaultFont(n, s, parent)
+	for _, decl := range declarations {
+		if decl.property == "font-size" || decl.property == "font" {
+			r.applyDeclaration(s, parent, decl)
+		}
+	}
+	r.defaultBox(n, s)
+	r.presentationalHints(n, s)
+	for _, decl := range declarations {
+		r.applyDeclaration(s, parent, decl)
+	}
+
+	r.styles[n] = s
+	return s
+}
+
+// headingScales are the user agent font sizes of h1 to h6 relative to the parent
+var headingScales = map[string]float64{"h1": 2, "h2": 1.5, "h3": 1.17, "h4": 1, "h5": 0.83, "h6": 0.67}
+
+// defaultFont applies the user agent font defaults of an element
+func (r *htmlRenderer) defaultFont(n *html.Node, s, parent *htmlStyle) {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		s.bold = true
+		s.size = parent.size * headingScales[n.Data]
+	case "b", "strong", "th":
+		s.bold = true
+	case "i", "em", "cite", "var", "dfn", "address":
+		s.italic = true
+	case "u", "ins":
+		s.underline = true
+	case "s", "strike", "del":
+		s.strike = true
+	case "code", "kbd", "samp", "tt", "pre":
+		s.family = "Courier"
+	case "small":
+		s.size = parent.size / 1.2
+	case "big":
+		s.size = parent.size * 1.2
+	case "sub", "sup":
+		s.size = parent.size * 0.83
+	case "a":
+		if htmlAttr(n, "href") != "" {
+			s.color = [3]int{0, 0, 238}
+			s.underline = true
+		}
+	}
+}
+
+// defaultBox applies the user agent display, margins and paddings of an element
+func (r *htmlRenderer) defaultBox(n *html.Node, s *htmlStyle) {
+	em := s.size * ptToMM
+	vertical := func(top, bottom float64) {
+		s.margin[0], s.margin[2] = top*em, bottom*em
+	}
+
+	switch n.Data {
+	case "head", "script", "style", "title", "meta", "link", "template", "noscript", "iframe", "object", "input", "select", "button", "textarea":
+		s.display = "none"
+	case "html", "body", "div", "section", "article", "aside", "header", "footer", "main", "nav", "address",
+		"fieldset", "form", "figcaption", "center", "dt", "details", "summary":
+		s.display = "block"
+	case "p", "dl":
+		s.display = "block"
+		vertical(1, 1)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		s.display = "block"
+		margin := map[string]float64{"h1": 0.67, "h2": 0.83, "h3": 1, "h4": 1.33, "h5": 1.67, "h6": 2.33}[n.Data]
+		vertical(margin, margin)
+	case "blockquote", "figure":
+		s.display = "block"
+		vertical(1, 1)
+		s.margin[1], s.margin[3] = 40*pxToMM, 40*pxToMM
+	case "pre":
+		s.display = "block"
+		s.pre = true
+		vertical(1, 1)
+	case "ul", "ol":
+		s.display = "block"
+		nested := 0
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p.Type == html.ElementNode && (p.Data == "ul" || p.Data == "ol") {
+				nested++
+			}
+		}
+		if nested == 0 {
+			vertical(1, 1)
+		}
+		s.padding[3] = 40 * pxToMM
+		s.listStyle = "decimal"
+		if n.Data == "ul" {
+			s.listStyle = []string{"disc", "circle", "square"}[min(nested, 2)]
+		}
+	case "li":
+		s.display = "list-item"
+	case "dd":
+		s.display = "block"
+		s.margin[3] = 40 * pxToMM
+	case "hr":
+		s.display = "block"
+		vertical(0.5, 0.5)
+		s.borderStyled[0] = true
+		s.border[0] = 1 * pxToMM
+		s.borderColor[0] = [3]int{153, 153, 153}
+	case "table":
+		s.display = "table"
+	case "thead", "tbody", "tfoot":
+		s.display = "table-row-group"
+	case "tr":
+		s.display = "table-row"
+	case "td", "th":
+		s.display = "table-cell"
+		s.padding = [4]float64{pxToMM, pxToMM, pxToMM, pxToMM}
+		if n.Data == "th" {
+			s.align = "C"
+		}
+	case "caption":
+		s.display = "table-caption"
+		s.align = "C"
+	case "mark":
+		s.hasBackground, s.background = true, [3]int{255, 255, 0}
+	}
+	if n.Data == "center" {
+		s.align = "C"
+	}
+}
+
+// presentationalHints maps legacy attributes such as align, bgcolor, width or table border to styles
+func (r *htmlRenderer) presentationalHints(n *html.Node, s *htmlStyle) {
+	if align := strings.ToLower(htmlAttr(n, "align")); align != "" {
+		if n.Data == "table" || n.Data == "img" {
+			s.autoMargin = [2]bool{align == "center" || align == "right", align == "center"}
+		} else {
+			s.align = map[string]string{"left": "L", "center": "C", "middle": "C", "right": "R", "justify": "J"}[align]
+			if s.align == "" {
+				s.align = "L"
+			}
+		}
+	}
+	if n.Data == "ol" || n.Data == "ul" || n.Data == "li" {
+		types := map[string]string{"1": "decimal", "a": "lower-alpha", "A": "upper-alpha", "i": "lower-roman",
+			"I": "upper-roman", "disc": "disc", "circle": "circle", "square": "square", "none": "none"}
+		if listStyle, ok := types[htmlAttr(n, "type")]; ok {
+			s.listStyle = listStyle
+		}
+	}
+	if valign := strings.ToLower(htmlAttr(n, "valign")); valign != "" {
+		s.verticalAlign = valign
+	}
+	if c, ok := cssColor(htmlAttr(n, "bgcolor")); ok {
+		s.hasBackground, s.background = true, c
+	}
+	switch n.Data {
+	case "table", "td", "th", "img", "col":
+		if width := htmlAttr(n, "width"); strings.HasSuffix(width, "%") {
+			if pct, err := strconv.ParseFloat(strings.TrimSuffix(width, "%"), 64); err == nil {
+				s.widthPercent = pct
+			}
+		} else if length, ok := r.length(width, s.size, 0); ok {
+			s.width = length
+		}
+		if length, ok := r.length(htmlAttr(n, "height"), s.size, 0); ok {
+			s.height = length
+		}
+	case "font":
+		if c, ok := cssColor(htmlAttr(n, "color")); ok {
+			s.color = c
+		}
+		if face := htmlAttr(n, "face"); face != "" {
+			s.family = r.fontFamily(face, s.family)
+		}
+	}
+
+	if n.Data == "td" || n.Data == "th" {
+		table := n.Parent
+		for table != nil && table.Data != "table" {
+			table = table.Parent
+		}
+		if table == nil {
+			return
+		}
+		if border, err := strconv.ParseFloat(htmlAttr(table, "border"), 64); err == nil && border > 0 {
+			for side := range s.border {
+				s.border[side], s.borderStyled[side] = border*pxToMM, true
+				s.borderColor[side] = [3]int{128, 128, 128}
+			}
+		}
+		if padding, err := strconv.ParseFloat(htmlAttr(table, "cellpadding"), 64); err == nil && padding >= 0 {
+			s.padding = [4]float64{padding * pxToMM, padding * pxToMM, padding * pxToMM, padding * pxToMM}
+		}
+	}
+}
+
+// applyDeclaration applies one supported CSS property; unsupported properties are ignored
+func (r *htmlRenderer) applyDeclaration(s, parent *htmlStyle, decl cssDeclaration) {
+	value := strings.TrimSpace(decl.value)
+	lower := strings.ToLower(value)
+	length := func(value string) (float64, bool) {
+		return r.length(value, s.size, r.pageWidth)
+	}
+	sides := map[string]int{"top": 0, "right": 1, "bottom": 2, "left": 3}
+
+	switch property := decl.property; property {
+	case "color":
+		if c, ok := cssColor(lower); ok {
+			s.color = c
+		}
+	case "background", "background-color":
+		if lower == "none" || lower == "transparent" {
+			s.hasBackground = false
+		}
+		for _, token := range cssTokens(lower) {
+			if c, ok := cssColor(token); ok {
+				s.hasBackground, s.background = true, c
+			}
+		}
+	case "font-size":
+		if size, ok := r.fontSize(lower, parent.size); ok {
+			s.size = size
+		}
+	case "font-weight":
+		weight, err := strconv.Atoi(lower)
+		s.bold = lower == "bold" || lower == "bolder" || (err == nil && weight >= 600)
+	case "font-style":
+		s.italic = lower == "italic" || lower == "oblique"
+	case "font-family":
+		s.family = r.fontFamily(value, s.family)
+	case "font":
+		// font: [style] [weight] size[/line-height] family
+		tokens := strings.Fields(value)
+		for i, token := range tokens {
+			token = strings.ToLower(token)
+			switch {
+			case token == "italic" || token == "oblique":
+				s.italic = true
+			case token == "bold" || token == "bolder" || token == "600" || token == "700" || token == "800" || token == "900":
+				s.bold = true
+			default:
+				size, height, _ := strings.Cut(token, "/")
+				if points, ok := r.fontSize(size, parent.size); ok {
+					s.size = points
+					if height != "" {
+						r.applyDeclaration(s, parent, cssDeclaration{property: "line-height", value: height})
+					}
+					s.family = r.fontFamily(strings.Join(tokens[i+1:], " "), s.family)
+					return
+				}
+			}
+		}
+	case "text-align":
+		switch lower {
+		case "left", "start":
+			s.align = "L"
+		case "center":
+			s.align = "C"
+		case "right", "end":
+			s.align = "R"
+		case "justify":
+			s.align = "J"
+		}
+	case "text-decoration", "text-decoration-line":
+		s.underline = strings.Contains(lower, "underline")
+		s.strike = strings.Contains(lower, "line-through")
+	case "text-transform":
+		s.transform = lower
+	case "line-height":
+		switch number, err := strconv.ParseFloat(lower, 64); {
+		case lower == "normal":
+			s.lineHeight = 1.2
+		case err == nil:
+			s.lineHeight = number
+		case strings.HasSuffix(lower, "%"):
+			if pct, err := strconv.ParseFloat(strings.TrimSuffix(lower, "%"), 64); err == nil {
+				s.lineHeight = pct / 100
+			}
+		default:
+			if mm, ok := r.length(lower, s.size, 0); ok && s.size > 0 {
+				s.lineHeight = mm / (s.size * ptToMM)
+			}
+		}
+	case "white-space":
+		s.pre = strings.HasPrefix(lower, "pre") && lower != "pre-line"
+	case "display":
+		switch lower {
+		case "inline-block", "inline-flex", "inline-table":
+			s.display = "inline"
+		case "flex", "grid", "flow-root":
+			s.display = "block"
+		case "table-header-group", "table-footer-group":
+			s.display = "table-row-group"
+		default:
+			s.display = lower
+		}
+	case "margin", "padding":
+		for side, token := range cssSides(strings.Fields(lower)) {
+			if property == "margin" && side%2 == 1 {
+				s.autoMargin[(3-side)/2] = token == "auto"
+			}
+			if token == "auto" {
+				token = "0"
+			}
+			if mm, ok := length(token); ok {
+				if property == "margin" {
+					s.margin[side] = mm
+				} else {
+					s.padding[side] = mm
+				}
+			}
+		}
+	case "margin-top", "margin-right", "margin-bottom", "margin-left",
+		"padding-top", "padding-right", "padding-bottom", "padding-left":
+		kind, sideName, _ := strings.Cut(property, "-")
+		side := sides[sideName]
+		if kind == "margin" && side%2 == 1 {
+			s.autoMargin[(3-side)/2] = lower == "auto"
+		}
+		if lower == "auto" {
+			lower = "0"
+		}
+		if mm, ok := length(lower); ok {
+			if kind == "margin" {
+				s.margin[side] = mm
+			} else {
+				s.padding[side] = mm
+			}
+		}
+	case "border", "border-top", "border-right", "border-bottom", "border-left":
+		targets := []int{0, 1, 2, 3}
+		if _, sideName, found := strings.Cut(property, "-"); found {
+			targets = []int{sides[sideName]}
+		}
+		for _, side := range targets {
+			s.border[side], s.borderStyled[side], s.borderColor[side] = 3*pxToMM, false, s.color
+			for _, token := range cssTokens(lower) {
+				r.applyBorderToken(s, side, token)
+			}
+		}
+	case "border-width", "border-style", "border-color":
+		for side, token := range cssSides(cssTokens(lower)) {
+			r.applyBorderToken(s, side, token)
+		}
+	case "border-top-width", "border-right-width", "border-bottom-width", "border-left-width",
+		"border-top-style", "border-right-style", "border-bottom-style", "border-left-style",
+		"border-top-color", "border-right-color", "border-bottom-color", "border-left-color":
+		r.applyBorderToken(s, sides[strings.Split(property, "-")[1]], lower)
+	case "width", "height", "min-height", "max-width":
+		if strings.HasSuffix(lower, "%") && property == "width" {
+			if pct, err := strconv.ParseFloat(strings.TrimSuffix(lower, "%"), 64); err == nil {
+				s.width, s.widthPercent = 0, pct
+			}
+			return
+		}
+		mm, ok := r.length(lower, s.size, 0)
+		if !ok {
+			if lower == "auto" && property == "width" {
+				s.width, s.widthPercent = 0, 0
+			}
+			return
+		}
+		switch property {
+		case "width":
+			s.width, s.widthPercent = mm, 0
+		case "max-width":
+			s.maxWidth = mm
+		default:
+			s.height = mm
+		}
+	case "page-break-before", "break-before":
+		s.breakBefore = lower == "always" || lower == "page" || lower == "left" || lower == "right"
+	case "page-break-after", "break-after":
+		s.breakAfter = lower == "always" || lower == "page" || lower == "left" || lower == "right"
+	case "page-break-inside", "break-inside":
+		s.avoidBreak = strings.HasPrefix(lower, "avoid")
+	case "list-style", "list-style-type":
+		for _, token := range strings.Fields(lower) {
+			switch token {
+			case "none", "disc", "circle", "square", "decimal", "lower-alpha", "upper-alpha",
+				"lower-latin", "upper-latin", "lower-roman", "upper-roman":
+				s.listStyle = token
+			}
+		}
+	case "vertical-align":
+		s.verticalAlign = lower
+	}
+}
+
+// applyBorderToken applies a border width, style or color token to one side
+func (r *htmlRenderer) applyBorderToken(s *htmlStyle, side int, token string) {
+	widths := map[string]float64{"thin": 1, "medium": 3, "thick": 5}
+	switch {
+	case token == "none" || token == "hidden":
+		s.borderStyled[side] = false
+	case token == "solid" || token == "dashed" || token == "dotted" || token == "double" ||
+		token == "groove" || token == "ridge" || token == "inset" || token == "outset":
+		s.borderStyled[side] = true
+	case widths[token] > 0:
+		s.border[side] = widths[token] * pxToMM
+	default:
+		if c, ok := cssColor(token); ok {
+			s.borderColor[side] = c
+		} else if mm, ok := r.length(token, s.size, 0); ok {
+			s.border[side] = mm
+		}
+	}
+}
+
+// cssSides expands the 1 to 4 values of a box shorthand to top, right, bottom and left
+func cssSides(values []string) [4]string {
+	switch len(values) {
+	case 0:
+		return [4]string{}
+	case 1:
+		return [4]string{values[0], values[0], values[0], values[0]}
+	case 2:
+		return [4]string{values[0], values[1], values[0], values[1]}
+	case 3:
+		return [4]string{values[0], values[1], values[2], values[1]}
+	}
+	return [4]string{values[0], values[1], values[2], values[3]}
+}
+
+// cssTokens splits a value on spaces outside parentheses, keeping rgb(1, 2, 3) whole
+func cssTokens(value string) []string {
+	var tokens []string
+	depth, start := 0, -1
+	for i, c := range value {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ' ' && depth == 0:
+			if start >= 0 {
+				tokens = append(tokens, value[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, value[start:])
+	}
+	return tokens
+}
+
+// length converts a CSS length to mm; percentages are relative to reference and unitless numbers are pixels
+func (r *htmlRenderer) length(value string, fontSize, reference float64) (float64, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	units := []struct {
+		suffix string
+		factor float64
+	}{
+		{"px", pxToMM}, {"pt", ptToMM}, {"mm", 1}, {"cm", 10}, {"in", 25.4}, {"pc", 12 * ptToMM},
+		{"rem", r.rootSize * ptToMM}, {"em", fontSize * ptToMM}, {"%", reference / 100}, {"", pxToMM},
+	}
+	for _, unit := range units {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		number, err := strconv.ParseFloat(strings.TrimSuffix(value, unit.suffix), 64)
+		if err != nil {
+			return 0, false
+		}
+		return number * unit.factor, true
+	}
+	return 0, false
+}
+
+// fontSize converts a CSS font size to points
+func (r *htmlRenderer) fontSize(value string, parent float64) (float64, bool) {
+	keywords := map[string]float64{"xx-small": 0.6, "x-small": 0.75, "small": 0.89, "medium": 1, "large": 1.2, "x-large": 1.5, "xx-large": 2}
+	switch {
+	case keywords[value] > 0:
+		return r.rootSize * keywords[value], true
+	case value == "smaller":
+		return parent / 1.2, true
+	case value == "larger":
+		return parent * 1.2, true
+	case strings.HasSuffix(value, "%"):
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		return parent * pct / 100, err == nil
+	}
+	mm, ok := r.length(value, parent, 0)
+	return mm / ptToMM, ok && mm > 0
+}
+
+// fontFamily picks the first usable family of a CSS font-family list: a registered font, or the core
+// Times, Courier or Arial for serif, monospace and sans-serif names
+func (r *htmlRenderer) fontFamily(value, fallback string) string {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.Trim(strings.TrimSpace(name), `"'`)
+		for registered := range registeredFonts {
+			if strings.EqualFold(registered, name) {
+				return registered
+			}
+		}
+		switch strings.ToLower(name) {
+		case "monospace", "courier", "courier new", "consolas", "menlo", "monaco", "lucida console":
+			return "Courier"
+		case "serif", "times", "times new roman", "georgia", "garamond", "cambria":
+			return "Times"
+		case "sans-serif", "arial", "helvetica", "helvetica neue", "verdana", "tahoma", "segoe ui",
+			"roboto", "open sans", "calibri", "system-ui", "-apple-system":
+			return r.defaultFamily
+		}
+	}
+	return fallback
+}
+
+// cssNamedColors are the named colors recognized besides hex and rgb() notations
+var cssNamedColors = map[string][3]int{
+	"black": {0, 0, 0}, "white": {255, 255, 255}, "red": {255, 0, 0}, "green": {0, 128, 0},
+	"blue": {0, 0, 255}, "yellow": {255, 255, 0}, "orange": {255, 165, 0}, "purple": {128, 0, 128},
+	"gray": {128, 128, 128}, "grey": {128, 128, 128}, "silver": {192, 192, 192}, "maroon": {128, 0, 0},
+	"navy": {0, 0, 128}, "teal": {0, 128, 128}, "olive": {128, 128, 0}, "lime": {0, 255, 0},
+	"aqua": {0, 255, 255}, "fuchsia": {255, 0, 255}, "lightgray": {211, 211, 211}, "lightgrey": {211, 211, 211},
+	"darkgray": {169, 169, 169}, "darkgrey": {169, 169, 169}, "whitesmoke": {245, 245, 245},
+	"gainsboro": {220, 220, 220}, "darkblue": {0, 0, 139}, "darkgreen": {0, 100, 0}, "darkred": {139, 0, 0},
+	"steelblue": {70, 130, 180}, "royalblue": {65, 105, 225}, "crimson": {220, 20, 60},
+}
+
+// cssColor parses #RGB, #RRGGBB, rgb()/rgba() and named colors
+func cssColor(value string) ([3]int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case strings.HasPrefix(value, "#"):
+		return hexColor(value)
+	case strings.HasPrefix(value, "rgb(") || strings.HasPrefix(value, "rgba("):
+		inner := value[strings.Index(value, "(")+1 : len(value)-1]
+		parts := strings.FieldsFunc(inner, func(c rune) bool { return c == ',' || c == ' ' || c == '/' })
+		if len(parts) < 3 || (len(parts) > 3 && strings.TrimSpace(parts[3]) == "0") {
+			// A fully transparent color paints nothing
+			return [3]int{}, false
+		}
+		var c [3]int
+		for i := range c {
+			part := parts[i]
+			scale := 1.0
+			if strings.HasSuffix(part, "%") {
+				part, scale = strings.TrimSuffix(part, "%"), 2.55
+			}
+			number, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return [3]int{}, false
+			}
+			c[i] = int(math.Max(0, math.Min(255, math.Round(number*scale))))
+		}
+		return c, true
+	}
+	c, ok := cssNamedColors[value]
+	return c, ok
+}
+
+// parseDeclarations parses the body of a CSS rule or a style attribute
+func parseDeclarations(text string) []cssDeclaration {
+	var declarations []cssDeclaration
+	for _, part := range strings.Split(text, ";") {
+		property, value, found := strings.Cut(part, ":")
+		if !found {
+			continue
+		}
+		decl := cssDeclaration{property: strings.ToLower(strings.TrimSpace(property)), value: strings.TrimSpace(value)}
+		if i := strings.Index(strings.ToLower(decl.value), "!important"); i >= 0 {
+			decl.value, decl.important = strings.TrimSpace(decl.value[:i]), true
+		}
+		if decl.property != "" && decl.value != "" {
+			declarations = append(declarations, decl)
+		}
+	}
+	return declarations
+}
+
+// cssComments matches CSS comments
+var cssComments = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// cssArguments matches the parenthesized argument of a pseudo-class such as :nth-child(2n+1)
+var cssArguments = regexp.MustCompile(`\([^)]*\)`)
+
+// parseStylesheet adds the rules of a stylesheet; @media blocks apply unless they only target screens,
+// @page declarations configure the page and other at-rules are skipped
+func (r *htmlRenderer) parseStylesheet(css string) {
+	css = cssComments.ReplaceAllString(css, "")
+	for {
+		open := strings.Index(css, "{")
+		if open < 0 {
+			return
+		}
+		prelude := css[:open]
+		if semicolon := strings.LastIndex(prelude, ";"); semicolon >= 0 {
+			// Statements such as @import end with a semicolon and have no block
+			prelude = prelude[semicolon+1:]
+		}
+		prelude = strings.TrimSpace(prelude)
+
+		depth, end := 0, len(css)
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		body := css[open+1 : end]
+		css = css[min(end+1, len(css)):]
+
+		lower := strings.ToLower(prelude)
+		switch {
+		case strings.HasPrefix(lower, "@page"):
+			r.pageRules = append(r.pageRules, parseDeclarations(body)...)
+		case strings.HasPrefix(lower, "@media"):
+			if !strings.Contains(lower, "screen") || strings.Contains(lower, "print") {
+				r.parseStylesheet(body)
+			}
+		case strings.HasPrefix(lower, "@"):
+		default:
+			declarations := parseDeclarations(body)
+			for _, text := range strings.Split(prelude, ",") {
+				if selector, specificity, ok := parseSelector(text); ok {
+					r.rules = append(r.rules, cssRule{selector: selector, specificity: specificity, order: len(r.rules), declarations: declarations})
+				}
+			}
+		}
+	}
+}
+
+// parseSelector parses a selector made of type, #id, .class and :first-child, :last-child or
+// :nth-child() compounds joined by descendant or > combinators; other selectors are not supported
+func parseSelector(text string) ([]cssCompound, int, bool) {
+	text = strings.ReplaceAll(text, ">", " > ")
+	var selector []cssCompound
+	specificity, child := 0, false
+	for _, token := range strings.Fields(text) {
+		if token == ">" {
+			child = true
+			continue
+		}
+		if strings.ContainsAny(cssArguments.ReplaceAllString(token, "()"), "[+~") || strings.Contains(token, "::") {
+			return nil, 0, false
+		}
+
+		compound := cssCompound{child: child}
+		child = false
+		name, rest := token, ""
+		if i := strings.IndexAny(token, ".#:"); i >= 0 {
+			name, rest = token[:i], token[i:]
+		}
+		if name != "*" && name != "" {
+			compound.tag = strings.ToLower(name)
+			specificity++
+		}
+		for rest != "" {
+			kind := rest[0]
+			end := strings.IndexAny(rest[1:], ".#:")
+			part := rest[1:]
+			if end >= 0 {
+				part, rest = rest[1:end+1], rest[end+1:]
+			} else {
+				rest = ""
+			}
+			if kind == ':' && strings.HasPrefix(part, "nth-child(") && !strings.HasSuffix(part, ")") {
+				// The argument of :nth-child() may hold no separators, keep it whole
+				return nil, 0, false
+			}
+			switch kind {
+			case '#':
+				compound.id = part
+				specificity += 10000
+			case '.':
+				compound.classes = append(compound.classes, part)
+				specificity += 100
+			case ':':
+				switch {
+				case part == "first-child" || part == "last-child" || strings.HasPrefix(part, "nth-child("):
+					compound.pseudo = append(compound.pseudo, part)
+					specificity += 100
+				default:
+					return nil, 0, false
+				}
+			}
+		}
+		selector = append(selector, compound)
+	}
+	return selector, specificity, len(selector) > 0 && !child
+}
+
+// matchSelector reports whether an element matches a selector, checking ancestors for the combinators
+func matchSelector(n *html.Node, selector []cssCompound) bool {
+	last := len(selector) - 1
+	if !selector[last].matches(n) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+	if selector[last].child {
+		return n.Parent != nil && n.Parent.Type == html.ElementNode && matchSelector(n.Parent, selector[:last])
+	}
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if matchSelector(p, selector[:last]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks a compound selector against a single element
+func (c cssCompound) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode || (c.tag != "" && c.tag != n.Data) {
+		return false
+	}
+	if c.id != "" && htmlAttr(n, "id") != c.id {
+		return false
+	}
+	classes := strings.Fields(htmlAttr(n, "class"))
+	for _, class := range c.classes {
+		found := false
+		for _, candidate := range classes {
+			if candidate == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, pseudo := range c.pseudo {
+		index, count := 0, 0
+		if n.Parent != nil {
+			for sibling := n.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+				if sibling.Type == html.ElementNode {
+					count++
+					if sibling == n {
+						index = count
+					}
+				}
+			}
+		}
+		switch {
+		case pseudo == "first-child":
+			if index != 1 {
+				return false
+			}
+		case pseudo == "last-child":
+			if index != count {
+				return false
+			}
+		default:
+			if !nthChild(strings.TrimSuffix(strings.TrimPrefix(pseudo, "nth-child("), ")"), index) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nthChild evaluates an :nth-child() argument (odd, even, b or an+b) for a 1-based index
+func nthChild(expression string, index int) bool {
+	expression = strings.ReplaceAll(strings.ToLower(expression), " ", "")
+	switch expression {
+	case "odd":
+		expression = "2n+1"
+	case "even":
+		expression = "2n"
+	}
+	aText, bText, hasN := strings.Cut(expression, "n")
+	if !hasN {
+		b, err := strconv.Atoi(expression)
+		return err == nil && index == b
+	}
+	a := 1
+	switch aText {
+	case "", "+":
+	case "-":
+		a = -1
+	default:
+		value, err := strconv.Atoi(aText)
+		if err != nil {
+			return false
+		}
+		a = value
+	}
+	b := 0
+	if bText != "" {
+		value, err := strconv.Atoi(bText)
+		if err != nil {
+			return false
+		}
+		b = value
+	}
+	if a == 0 {
+		return index == b
+	}
+	return (index-b)%a == 0 && (index-b)/a >= 0
+}
+
+// markdownToPDF - Convert Markdown (headings, paragraphs, emphasis, links, images, lists, block quotes,
+// fenced code blocks and GFM tables) to PDF. The Markdown is converted to HTML and laid out by the
+// htmlToPDF engine, so options are the htmlToPDF options plus a css string appended to the default styles.
+func markdownToPDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "markdownToPDF", "markdownContent"),
+		})
+	}
+
+	markdownContent := args[0].String()
+	var options MarkdownOptions
+	if len(args) > 1 && args[1].Type() != js.TypeUndefined && args[1].Type() != js.TypeNull {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options format: %v", err),
+			})
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(markdownContent, "\r\n", "\n"), "\n")
+	converter := &markdownConverter{ids: map[string]int{}}
+	converter.out.WriteString("<html><head><style>" + markdownStylesheet + options.CSS + "</style></head><body>")
+	converter.blocks(lines, false)
+	converter.out.WriteString("</body></html>")
+
+	root, err := html.Parse(strings.NewReader(converter.out.String()))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to convert Markdown to PDF: %v", err),
+		})
+	}
+
+	r := renderHTMLDocument(root, options.HTMLOptions)
+
+	var buf bytes.Buffer
+	if err := r.pdf.Output(&buf); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to convert Markdown to PDF: %v", err),
+		})
+	}
+
+	markdownPdfData := binaryOutput(buf.Bytes())
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Converted Markdown to PDF (%d pages, %d bytes)\n", r.pdf.PageCount(), buf.Len())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":        markdownPdfData,
+		"size":           buf.Len(),
+		"pages":          r.pdf.PageCount(),
+		"images":         r.images,
+		"links":          r.links,
+		"warnings":       r.warnings,
+		"originalLength": len(markdownContent),
+		"lines":          len(lines),
+		"format":         "application/pdf",
+	})
+}
+
+// MarkdownOptions configures markdownToPDF: the htmlToPDF options and extra CSS for the generated HTML
+type MarkdownOptions struct {
+	HTMLOptions
+	CSS string `json:"css"`
+}
+
+// markdownStylesheet styles the HTML generated from Markdown, options.css rules come after it
+const markdownStylesheet = `
+pre { background-color: #f6f8fa; border: 1px solid #e1e4e8; padding: 6px 8px; font-size: 0.9em }
+code { background-color: #f0f0f0 }
+pre code { background-color: transparent }
+blockquote { margin-left: 0; margin-right: 0; padding-left: 10px; border-left: 3px solid #d0d7de; color: #57606a }
+table { margin-top: 6px; margin-bottom: 10px }
+th, td { border: 1px solid #d0d7de; padding: 3px 6px }
+th { background-color: #f6f8fa }
+h1, h2 { border-bottom: 1px solid #d8dee4 }
+`
+
+var (
+	markdownHeading        = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	markdownRule           = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	markdownFence          = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	markdownListItem       = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:([ \t]+)(.*))?$`)
+	markdownSetext         = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	markdownTableDelimiter = regexp.MustCompile(`^[ \t]*\|?(?:[ \t]*:?-+:?[ \t]*\|)*[ \t]*:?-+:?[ \t]*\|?[ \t]*$`)
+	markdownLinkTarget     = regexp.MustCompile(`^\([ \t\n]*(?:<([^>\n]*)>|((?:[^\s()\\]|\\.|\([^\s()]*\))*))(?:[ \t\n]+(?:"([^"]*)"|'([^']*)'|\(([^)]*)\)))?[ \t\n]*\)`)
+	markdownAutolink       = regexp.MustCompile(`^<((?:[a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*)|(?:[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9.-]+))>`)
+	markdownBareURL        = regexp.MustCompile(`^(?:https?://|www\.)[^\s<]+`)
+)
+
+// markdownPunctuation are the characters a backslash escapes
+const markdownPunctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// markdownConverter writes the HTML of a Markdown document; ids deduplicates heading anchors
+type markdownConverter struct {
+	out strings.Builder
+	ids map[string]int
+}
+
+// blocks converts Markdown lines to HTML blocks. In tight lists paragraphs are written without <p>
+// so items keep the list spacing.
+func (m *markdownConverter) blocks(lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			i++
+			continue
+		}
+
+		if fence := markdownFence.FindStringSubmatch(line); fence != nil {
+			indent, marker := len(fence[1]), fence[2]
+			var code []string
+			for i++; i < len(lines); i++ {
+				closing := strings.TrimSpace(lines[i])
+				if strings.HasPrefix(closing, marker) && strings.Trim(closing, marker[:1]) == "" {
+					i++
+					break
+				}
+				code = append(code, strings.TrimPrefix(lines[i], strings.Repeat(" ", indent)))
+			}
+			m.out.WriteString("<pre><code")
+			if fence[3] != "" {
+				m.out.WriteString(` class="language-` + html.EscapeString(fence[3]) + `"`)
+			}
+			m.out.WriteString(">" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+
+		if heading := markdownHeading.FindStringSubmatch(line); heading != nil {
+			m.heading(len(heading[1]), heading[2])
+			i++
+			continue
+		}
+
+		if markdownRule.MatchString(line) {
+			m.out.WriteString("<hr>\n")
+			i++
+			continue
+		}
+
+		if quote := strings.TrimLeft(line, " "); strings.HasPrefix(quote, ">") {
+			var inner []string
+			for ; i < len(lines); i++ {
+				quote = strings.TrimLeft(lines[i], " ")
+				if !strings.HasPrefix(quote, ">") {
+					// Lazy continuation lines extend the quoted paragraph
+					if strings.TrimSpace(lines[i]) == "" || len(inner) == 0 || strings.TrimSpace(inner[len(inner)-1]) == "" || markdownStartsBlock(lines[i]) {
+						break
+					}
+					inner = append(inner, lines[i])
+					continue
+				}
+				quote = strings.TrimPrefix(quote, ">")
+				inner = append(inner, strings.TrimPrefix(quote, " "))
+			}
+			m.out.WriteString("<blockquote>\n")
+			m.blocks(inner, false)
+			m.out.WriteString("</blockquote>\n")
+			continue
+		}
+
+		if markdownListItem.MatchString(line) {
+			i = m.list(lines, i)
+			continue
+		}
+
+		if i+1 < len(lines) && strings.Contains(line, "|") && strings.Contains(lines[i+1], "|") &&
+			markdownTableDelimiter.MatchString(lines[i+1]) {
+			if next, ok := m.table(lines, i); ok {
+				i = next
+				continue
+			}
+		}
+
+		// Paragraph: runs until a blank line or another block, a setext underline turns it into a heading
+		paragraph := []string{strings.TrimLeft(line, " \t")}
+		level := 0
+		for i++; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "" {
+				break
+			}
+			if setext := markdownSetext.FindStringSubmatch(lines[i]); setext != nil {
+				level = 1
+				if setext[1][0] == '-' {
+					level = 2
+				}
+				i++
+				break
+			}
+			if markdownStartsBlock(lines[i]) {
+				break
+			}
+			paragraph = append(paragraph, strings.TrimLeft(lines[i], " \t"))
+		}
+		if level > 0 {
+			m.heading(level, strings.Join(paragraph, " "))
+			continue
+		}
+		for j := range paragraph {
+			// Two trailing spaces end the line with a hard break, like a trailing backslash
+			hardBreak := j < len(paragraph)-1 && strings.HasSuffix(paragraph[j], "  ")
+			paragraph[j] = strings.TrimRight(paragraph[j], " \t")
+			if hardBreak {
+				paragraph[j] += "\\"
+			}
+		}
+		content := markdownInline(strings.Join(paragraph, "\n"))
+		if tight {
+			m.out.WriteString(content + "\n")
+		} else {
+			m.out.WriteString("<p>" + content + "</p>\n")
+		}
+	}
+}
+
+// heading writes an h1-h6 with a GitHub style id so [text](#anchor) links can target it
+func (m *markdownConverter) heading(level int, text string) {
+	var slug strings.Builder
+	for _, c := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '-' || c == '_':
+			slug.WriteRune(c)
+		case c == ' ':
+			slug.WriteRune('-')
+		}
+	}
+	id := slug.String()
+	if count := m.ids[id]; count > 0 {
+		m.ids[id] = count + 1
+		id = fmt.Sprintf("%s-%d", id, count)
+	} else {
+		m.ids[id] = 1
+	}
+	fmt.Fprintf(&m.out, "<h%d id=\"%s\">%s</h%d>\n", level, html.EscapeString(id), markdownInline(text), level)
+}
+
+// list writes the list starting at lines[start] and returns the index after it. Item content is
+// indented past the marker; a blank line between items or blocks makes the list loose.
+func (m *markdownConverter) list(lines []string, start int) int {
+	first := markdownListItem.FindStringSubmatch(lines[start])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+	delimiter := first[2][len(first[2])-1]
+
+	// Items continue the list while they use the same kind of marker
+	sameList := func(line string) []string {
+		item := markdownListItem.FindStringSubmatch(line)
+		if item == nil || markdownRule.MatchString(line) ||
+			(item[2][0] >= '0' && item[2][0] <= '9') != ordered || item[2][len(item[2])-1] != delimiter {
+			return nil
+		}
+		return item
+	}
+
+	var items [][]string
+	loose := false
+	i := start
+	for i < len(lines) {
+		item := sameList(lines[i])
+		if item == nil {
+			break
+		}
+		padding := len(item[3])
+		if padding == 0 || padding > 4 {
+			padding = 1
+		}
+		indent := len(item[1]) + len(item[2]) + padding
+		content := []string{strings.Repeat(" ", max(len(item[3])-padding, 0)) + item[4]}
+
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				next := i + 1
+				for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+					next++
+				}
+				if next < len(lines) && len(lines[next])-len(strings.TrimLeft(lines[next], " ")) >= indent {
+					content = append(content, "")
+					loose = true
+					continue
+				}
+				break
+			}
+			if len(line)-len(strings.TrimLeft(line, " ")) >= indent {
+				content = append(content, line[indent:])
+				continue
+			}
+			if markdownStartsBlock(line) || strings.TrimSpace(content[len(content)-1]) == "" {
+				break
+			}
+			// Lazy continuation of the item paragraph
+			content = append(content, strings.TrimSpace(line))
+		}
+		items = append(items, content)
+
+		next := i
+		for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+			next++
+		}
+		if next >= len(lines) || sameList(lines[next]) == nil {
+			break
+		}
+		if next > i {
+			loose = true
+		}
+		i = next
+	}
+
+	if ordered {
+		number, _ := strconv.Atoi(first[2][:len(first[2])-1])
+		if number != 1 {
+			fmt.Fprintf(&m.out, "<ol start=\"%d\">\n", number)
+		} else {
+			m.out.WriteString("<ol>\n")
+		}
+	} else {
+		m.out.WriteString("<ul>\n")
+	}
+	for _, content := range items {
+		m.out.WriteString("<li>")
+		m.blocks(content, !loose)
+		m.out.WriteString("</li>\n")
+	}
+	if ordered {
+		m.out.WriteString("</ol>\n")
+	} else {
+		m.out.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// table writes a GFM table whose header is lines[start]; the delimiter row sets column alignment
+// and must have as many cells as the header
+func (m *markdownConverter) table(lines []string, start int) (int, bool) {
+	header := markdownTableCells(lines[start])
+	delimiters := markdownTableCells(lines[start+1])
+	if len(header) != len(delimiters) {
+		return start, false
+	}
+
+	aligns := make([]string, len(delimiters))
+	for c, delimiter := range delimiters {
+		left, right := strings.HasPrefix(delimiter, ":"), strings.HasSuffix(delimiter, ":")
+		switch {
+		case left && right:
+			aligns[c] = ` style="text-align: center"`
+		case right:
+			aligns[c] = ` style="text-align: right"`
+		case left:
+			aligns[c] = ` style="text-align: left"`
+		}
+	}
+
+	row := func(tag string, cells []string) {
+		m.out.WriteString("<tr>")
+		for c := range header {
+			cell := ""
+			if c < len(cells) {
+				cell = cells[c]
+			}
+			fmt.Fprintf(&m.out, "<%s%s>%s</%s>", tag, aligns[c], markdownInline(cell), tag)
+		}
+		m.out.WriteString("</tr>\n")
+	}
+
+	m.out.WriteString("<table>\n<thead>")
+	row("th", header)
+	m.out.WriteString("</thead>\n<tbody>")
+	i := start + 2
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" || markdownStartsBlock(lines[i]) {
+			break
+		}
+		row("td", markdownTableCells(lines[i]))
+	}
+	m.out.WriteString("</tbody>\n</table>\n")
+	return i, true
+}
+
+// markdownTableCells splits a table row on unescaped pipes, ignoring the leading and trailing pipe
+func markdownTableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// markdownStartsBlock reports whether a line interrupts a paragraph
+func markdownStartsBlock(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	return markdownFence.MatchString(line) || markdownHeading.MatchString(line) || markdownRule.MatchString(line) ||
+		strings.HasPrefix(trimmed, ">") || markdownListItem.MatchString(line)
+}
+
+// markdownInline converts inline Markdown to HTML: code spans, backslash escapes, emphasis,
+// strikethrough, links, images, autolinks and hard line breaks
+func markdownInline(s string) string {
+	var out strings.Builder
+	text := 0 // start of the plain text not written yet
+	flush := func(end int) {
+		out.WriteString(html.EscapeString(s[text:end]))
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			flush(i)
+			out.WriteString("<br>\n")
+			i += 2
+			text = i
+			continue
+
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(markdownPunctuation, s[i+1]) >= 0:
+			flush(i)
+			out.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			text = i
+			continue
+
+		case c == '`':
+			run := markdownRun(s, i)
+			end := -1
+			for j := i + run; j < len(s); {
+				k := strings.IndexByte(s[j:], '`')
+				if k < 0 {
+					break
+				}
+				k += j
+				if markdownRun(s, k) == run {
+					end = k
+					break
+				}
+				j = k + markdownRun(s, k)
+			}
+			if end < 0 {
+				i += run
+				continue
+			}
+			code := strings.ReplaceAll(s[i+run:end], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+			flush(i)
+			out.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			i = end + run
+			text = i
+			continue
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if label, destination, title, end, ok := markdownLink(s, i+1); ok {
+				flush(i)
+				fmt.Fprintf(&out, `<img src="%s" alt="%s"`, html.EscapeString(destination), html.EscapeString(markdownPlainText(label)))
+				if title != "" {
+					fmt.Fprintf(&out, ` title="%s"`, html.EscapeString(title))
+				}
+				out.WriteString(">")
+				i = end
+				text = i
+				continue
+			}
+
+		case c == '[':
+			if label, destination, title, end, ok := markdownLink(s, i); ok {
+				flush(i)
+				fmt.Fprintf(&out, `<a href="%s"`, html.EscapeString(destination))
+				if title != "" {
+					fmt.Fprintf(&out, ` title="%s"`, html.EscapeString(title))
+				}
+				out.WriteString(">" + markdownInline(label) + "</a>")
+				i = end
+				text = i
+				continue
+			}
+
+		case c == '<':
+			if link := markdownAutolink.FindStringSubmatch(s[i:]); link != nil {
+				href := link[1]
+				if !strings.Contains(href, ":") {
+					href = "mailto:" + href
+				}
+				flush(i)
+				fmt.Fprintf(&out, `<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(link[1]))
+				i += len(link[0])
+				text = i
+				continue
+			}
+
+		case (c == 'h' || c == 'w') && (i == 0 || !markdownWordByte(s[i-1])):
+			if url := markdownBareURL.FindString(s[i:]); url != "" {
+				url = strings.TrimRight(url, ".,:;!?'\")*_~")
+				href := url
+				if strings.HasPrefix(href, "www.") {
+					href = "http://" + href
+				}
+				flush(i)
+				fmt.Fprintf(&out, `<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(url))
+				i += len(url)
+				text = i
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			run := markdownRun(s, i)
+			if end, size, ok := markdownEmphasis(s, i, run); ok {
+				flush(i)
+				open, close := "<em>", "</em>"
+				switch {
+				case c == '~':
+					open, close = "<del>", "</del>"
+				case size == 2:
+					open, close = "<strong>", "</strong>"
+				case size == 3:
+					open, close = "<strong><em>", "</em></strong>"
+				}
+				out.WriteString(open + markdownInline(s[i+size:end]) + close)
+				i = end + size
+				text = i
+				continue
+			}
+			i += run
+			continue
+		}
+		i++
+	}
+	flush(len(s))
+	return out.String()
+}
+
+// markdownEmphasis finds the delimiter run closing the one at s[start]. Runs open when followed by
+// non-space and close when preceded by non-space; underscores do not open or close inside words.
+func markdownEmphasis(s string, start, run int) (int, int, bool) {
+	c := s[start]
+	size := min(run, 3)
+	if c == '~' {
+		if run != 2 {
+			return 0, 0, false
+		}
+		size = 2
+	}
+	after := start + run
+	if after >= len(s) || s[after] == ' ' || s[after] == '\n' {
+		return 0, 0, false
+	}
+	if c == '_' && start > 0 && markdownWordByte(s[start-1]) {
+		return 0, 0, false
+	}
+
+	for j := start + run; j < len(s); {
+		k := strings.IndexByte(s[j:], c)
+		if k < 0 {
+			break
+		}
+		k += j
+		length := markdownRun(s, k)
+		closes := s[k-1] != ' ' && s[k-1] != '\n' && s[k-1] != '\\'
+		if c == '_' && k+length < len(s) && markdownWordByte(s[k+length]) {
+			closes = false
+		}
+		if closes && (length == size || (size == 3 && length > 3)) {
+			return k, size, true
+		}
+		j = k + length
+	}
+	return 0, 0, false
+}
+
+// markdownLink parses [label](destination "title") with the opening bracket at s[open] and returns
+// the index after the closing parenthesis
+func markdownLink(s string, open int) (label, destination, title string, end int, ok bool) {
+	depth, closing := 0, -1
+	for j := open; j < len(s) && closing < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			// Brackets inside code spans do not count
+			if k := strings.IndexByte(s[j+1:], '`'); k >= 0 {
+				j += k + 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = j
+			}
+		}
+	}
+	if closing < 0 {
+		return "", "", "", 0, false
+	}
+	target := markdownLinkTarget.FindStringSubmatch(s[closing+1:])
+	if target == nil {
+		return "", "", "", 0, false
+	}
+	destination = target[1] + target[2]
+	title = target[3] + target[4] + target[5]
+	return s[open+1 : closing], markdownUnescape(destination), markdownUnescape(title), closing + 1 + len(target[0]), true
+}
+
+// markdownUnescape removes the backslash of escaped punctuation
+func markdownUnescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(markdownPunctuation, s[i+1]) >= 0 {
+			i++
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+// markdownPlainText is the text of inline Markdown without its markup, used for image alt text
+func markdownPlainText(s string) string {
+	root, err := html.Parse(strings.NewReader(markdownInline(s)))
+	if err != nil {
+		return s
+	}
+	var text strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			text.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.Data == "img":
+			text.WriteString(htmlAttr(n, "alt"))
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return text.String()
+}
+
+// markdownRun returns the length of the run of s[start] repeated from start
+func markdownRun(s string, start int) int {
+	end := start
+	for end < len(s) && s[end] == s[start] {
+		end++
+	}
+	return end - start
+}
+
+// markdownWordByte reports whether b is part of a word for underscore emphasis and bare URLs
+func markdownWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b >= 0x80
+}
+
+// jsonToPDF - Render a declarative JSON document (sections, headings, paragraphs, lists, tables, images, page breaks)
+func jsonToPDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "jsonToPDF", "document"),
+		})
+	}
+
+	documentJSON := args[0].String()
+	if args[0].Type() == js.TypeObject {
+		documentJSON = js.Global().Get("JSON").Call("stringify", args[0]).String()
+	}
+	var doc JSONDocument
+	if err := json.Unmarshal([]byte(documentJSON), &doc); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid document format: %v", err),
+		})
+	}
+
+	if len(doc.Content) == 0 && len(doc.Sections) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Document requires content or sections"),
+		})
+	}
+	if doc.Margin == 0 {
+		doc.Margin = 20
+	}
+	if doc.FontSize == 0 {
+		doc.FontSize = 11
+	}
+	var toc *tableOfContents
+	if doc.TOC.Enabled {
+		draft := newTableOfContents(doc.TOC)
+		if _, _, err := renderJSONDocument(doc, draft); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		toc = draft.final()
+	}
+	pdf, blocks, err := renderJSONDocument(doc, toc)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	sections := len(doc.Sections)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to convert JSON to PDF: %v", err),
+		})
+	}
+
+	jsonPdfData := binaryOutput(buf.Bytes())
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Converted JSON document to PDF (%d blocks, %d pages, %d bytes)\n", blocks, pdf.PageCount(), buf.Len())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":  jsonPdfData,
+		"size":     buf.Len(),
+		"pages":    pdf.PageCount(),
+		"sections": sections,
+		"blocks":   blocks,
+		"format":   "application/pdf",
+	})
+}
+
+// renderJSONDocument lays out a jsonToPDF document. A table of contents is drawn below the title, its
+// draft reserves the first page and records the page of each heading.
+func renderJSONDocument(doc JSONDocument, toc *tableOfContents) (*gofpdf.Fpdf, int, error) {
+	orientation := "P"
+	if strings.HasPrefix(strings.ToUpper(doc.Orientation), "L") {
+		orientation = "L"
+	}
+
+	pdf := newDocument(orientation)
+	pdf.SetMargins(doc.Margin, doc.Margin, doc.Margin)
+	pdf.SetAutoPageBreak(true, doc.Margin)
+	if doc.Title != "" {
+		pdf.SetTitle(doc.Title, true)
+	}
+	if doc.Author != "" {
+		pdf.SetAuthor(doc.Author, true)
+	}
+	if doc.Subject != "" {
+		pdf.SetSubject(doc.Subject, true)
+	}
+	if doc.PageNumbers {
+		pdf.AliasNbPages("")
+		pdf.SetFooterFunc(func() {
+			tr := useFont(pdf, doc.Font, "I", 8)
+			pageWidth, _ := pdf.GetPageSize()
+			pdf.SetXY(doc.Margin, -15)
+			pdf.CellFormat(pageWidth-2*doc.Margin, 10, tr(localize("Page %d / {nb}", pdf.PageNo())), "", 0, "C", false, 0, "")
+		})
+	}
+	pdf.AddPage()
+
+	if doc.Title != "" {
+		tr := useFont(pdf, doc.Font, "B", 20)
+		pdf.MultiCell(0, 10, tr(doc.Title), "", "C", false)
+		pdf.Ln(6)
+	}
+	if toc != nil {
+		tablesOfContents[pdf] = toc
+		defer delete(tablesOfContents, pdf)
+		toc.draw(pdf, doc.Font)
+		pdf.AddPage()
+	}
+	progress := func(processed, total int) {
+		if !toc.drafting() {
+			reportProgress(processed, total)
+		}
+	}
+
+	totalBlocks := len(doc.Content)
+	for _, section := range doc.Sections {
+		totalBlocks += len(section.Content)
+	}
+
+	blocks := 0
+	if doc.Layout.Columns > 0 {
+		body, err := jsonLayoutBlocks(doc)
+		if err == nil {
+			err = renderJSONColumns(pdf, doc, doc.Layout.Columns, doc.Layout.Gap, doc.Layout.Balance, body)
+		}
+		if err != nil {
+			return pdf, blocks, errors.New(localize("Invalid layout: %v", err))
+		}
+		blocks = totalBlocks
+		progress(blocks, totalBlocks)
+		doc.Content, doc.Sections = nil, nil
+	}
+	for i, block := range doc.Content {
+		if err := renderJSONBlock(pdf, doc, block); err != nil {
+			return pdf, blocks, errors.New(localize("Invalid block %d: %v", i+1, err))
+		}
+		blocks++
+		progress(blocks, totalBlocks)
+	}
+
+	for i, section := range doc.Sections {
+		_, top, _, _ := pdf.GetMargins()
+		if section.NewPage && pdf.GetY() > top {
+			pdf.AddPage()
+		}
+		if section.Title != "" {
+			renderJSONBlock(pdf, doc, JSONBlock{Type: "heading", Text: section.Title, Level: 1})
+		}
+		if section.Columns > 0 {
+			if err := renderJSONColumns(pdf, doc, section.Columns, section.Gap, section.Balance, section.Content); err != nil {
+				return pdf, blocks, errors.New(localize("Invalid section %d: %v", i+1, err))
+			}
+			blocks += len(section.Content)
+			progress(blocks, totalBlocks)
+			continue
+		}
+		for j, block := range section.Content {
+			if err := renderJSONBlock(pdf, doc, block); err != nil {
+				return pdf, blocks, errors.New(localize("Invalid block %d in section %d: %v", j+1, i+1, err))
+			}
+			blocks++
+			progress(blocks, totalBlocks)
+		}
+	}
+
+	return pdf, blocks, nil
+}
+
+// renderJSONBlock draws a single jsonToPDF block at the current position
+func renderJSONBlock(pdf *gofpdf.Fpdf, doc JSONDocument, block JSONBlock) error {
+	if block.KeepTogether {
+		if err := keepTogether(pdf, doc, block); err != nil {
+			return err
+		}
+	}
+
+	fontSize := block.FontSize
+	if fontSize == 0 {
+		fontSize = doc.FontSize
+	}
+	lineHeight := fontSize * 0.5
+
+	switch block.Type {
+	case "heading":
+		sizes := map[int]float64{1: 16, 2: 14, 3: 12}
+		size, ok := sizes[block.Level]
+		if !ok {
+			size = sizes[1]
+		}
+		if block.FontSize > 0 {
+			size = block.FontSize
+		}
+		// Keep a heading with at least a couple of lines of the content that follows it
+		ensureSpace(pdf, size*0.5+4+2*lineHeight)
+		pdf.Ln(3)
+		tr := useFont(pdf, doc.Font, "B", size)
+		tablesOfContents[pdf].mark(pdf, block.Level, block.Text, pdf.GetY())
+		pdf.MultiCell(0, size*0.5, tr(block.Text), "", blockAlign(block.Align, "L"), false)
+		pdf.Ln(2)
+
+	case "paragraph":
+		tr := useFont(pdf, doc.Font, strings.ToUpper(block.FontStyle), fontSize)
+		pdf.MultiCell(0, lineHeight, tr(block.Text), "", blockAlign(block.Align, "J"), false)
+		pdf.Ln(lineHeight / 2)
+
+	case "list":
+		tr := useFont(pdf, doc.Font, strings.ToUpper(block.FontStyle), fontSize)
+		pageWidth, _ := pdf.GetPageSize()
+		for i, item := range block.Items {
+			bullet := "•"
+			if block.Ordered {
+				bullet = fmt.Sprintf("%d.", i+1)
+			}
+			// Keep the bullet with its text, the item may continue in the next column
+			ensureSpace(pdf, lineHeight)
+			left, _, right, _ := pdf.GetMargins()
+			pdf.SetX(left)
+			pdf.CellFormat(8, lineHeight, tr(bullet), "", 0, "R", false, 0, "")
+			pdf.SetX(left + 10)
+			pdf.MultiCell(pageWidth-left-right-10, lineHeight, tr(item), "", "L", false)
+		}
+		pdf.Ln(lineHeight / 2)
+
+	case "table":
+		if len(block.Headers) == 0 && len(block.Rows) == 0 {
+			return errors.New(localize("table requires headers or rows"))
+		}
+		style := defaultTableStyle(doc.Font, fontSize-1)
+		style.Widths = block.Widths
+		style, err := parseTableStyle(block.Style, style)
+		if err != nil {
+			return err
+		}
+		drawTable(pdf, block.Headers, block.Rows, style)
+		pdf.Ln(lineHeight)
+
+	case "image":
+		left, _, right, _ := pdf.GetMargins()
+		pageWidth, _ := pdf.GetPageSize()
+		img := PDFImage{Data: block.Data, Type: block.ImageType, X: left, Y: -1, Width: block.Width, Height: block.Height}
+		if img.Width == 0 && img.Height == 0 && columnFlows[pdf] != nil {
+			img.Width = pageWidth - left - right
+		}
+		if img.Height > 0 {
+			ensureSpace(pdf, img.Height)
+			left, _, right, _ = pdf.GetMargins()
+			img.X = left
+		}
+		if img.Width > 0 {
+			switch blockAlign(block.Align, "L") {
+			case "C":
+				img.X = (pageWidth - img.Width) / 2
+			case "R":
+				img.X = pageWidth - right - img.Width
+			}
+		}
+		if err := placeImage(pdf, img, true); err != nil {
+			return err
+		}
+		pdf.Ln(lineHeight)
+
+	case "spacer":
+		height := block.Height
+		if height == 0 {
+			height = lineHeight
+		}
+		pdf.Ln(height)
+
+	case "pageBreak":
+		if flow := columnFlows[pdf]; flow != nil {
+			flow.nextPage()
+		}
+		pdf.AddPage()
+
+	case "columnBreak":
+		breakColumn(pdf)
+
+	case "columns":
+		return renderJSONColumns(pdf, doc, block.Columns, block.Gap, block.Balance, block.Content)
+
+	case "row":
+		return renderJSONRow(pdf, doc, block)
+
+	case "group":
+		for i, child := range block.Content {
+			if err := renderJSONBlock(pdf, doc, child); err != nil {
+				return fmt.Errorf(localize("block %d: %v"), i+1, err)
+			}
+		}
+
+	default:
+		return fmt.Errorf(localize("unknown block type %q"), block.Type)
+	}
+
+	return pdf.Error()
+}
+
+// defaultColumnGap is the space between columns and row cells, in millimeters
+const defaultColumnGap = 6.0
+
+// maxColumns bounds the column count of a column flow
+const maxColumns = 6
+
+// columnFlows holds the active column flow of documents being generated, keyed by document
+var columnFlows = map[*gofpdf.Fpdf]*columnFlow{}
+
+// columnFlow lays content down equal-width columns, continuing in the next column when one is
+// full and on a new page after the last one
+type columnFlow struct {
+	pdf     *gofpdf.Fpdf
+	columns int
+	width   float64
+	gap     float64
+	left    float64
+	right   float64
+	top     float64
+	bottom  float64
+	current int
+	// filled sums the height of the finished columns of the page, breaks counts the pages added
+	filled  float64
+	breaks  int
+	margin  float64
+	balance columnBalance
+}
+
+// columnBalance caps the height of the columns on the last page of a flow so that they end level
+type columnBalance struct {
+	breaks int
+	height float64
+}
+
+// setColumn moves the margins and the cursor to the given column
+func (c *columnFlow) setColumn(column int) {
+	pageWidth, _ := c.pdf.GetPageSize()
+	x := c.left + float64(column)*(c.width+c.gap)
+	c.current = column
+	c.pdf.SetLeftMargin(x)
+	c.pdf.SetRightMargin(pageWidth - x - c.width)
+	c.pdf.SetX(x)
+}
+
+// advance continues at the top of the next column. After the last column it returns to the first
+// one and reports false, the caller then starts a new page.
+func (c *columnFlow) advance() bool {
+	c.bottom = math.Max(c.bottom, c.pdf.GetY())
+	if c.current < c.columns-1 {
+		c.filled += c.pdf.GetY() - c.top
+		c.setColumn(c.current + 1)
+		c.pdf.SetY(c.top)
+		return true
+	}
+
+	c.nextPage()
+	return false
+}
+
+// nextPage returns to the first column before the caller adds a page, capping the columns when
+// the new page is the balanced one
+func (c *columnFlow) nextPage() {
+	c.setColumn(0)
+	_, c.top, _, _ = c.pdf.GetMargins()
+	c.bottom = c.top
+	c.filled = 0
+	c.breaks++
+	c.applyBalance()
+}
+
+// applyBalance moves the page break trigger up to the balanced column height on the last page
+func (c *columnFlow) applyBalance() {
+	auto, _ := c.pdf.GetAutoPageBreak()
+	if c.balance.height > 0 && c.breaks == c.balance.breaks {
+		_, pageHeight := c.pdf.GetPageSize()
+		c.pdf.SetAutoPageBreak(auto, pageHeight-c.top-c.balance.height)
+		return
+	}
+	c.pdf.SetAutoPageBreak(auto, c.margin)
+}
+
+// breakColumn continues in the next column of an active column flow, or on a new page
+func breakColumn(pdf *gofpdf.Fpdf) {
+	if flow := columnFlows[pdf]; flow != nil && flow.advance() {
+		return
+	}
+	pdf.AddPage()
+}
+
+// renderJSONColumns flows blocks down equal-width columns, then leaves the cursor below the longest column.
+// Balanced columns (the default) share the content of the last page evenly.
+func renderJSONColumns(pdf *gofpdf.Fpdf, doc JSONDocument, columns int, gap float64, balance *bool, blocks []JSONBlock) error {
+	if columnFlows[pdf] != nil {
+		return errors.New(localize("columns cannot be nested"))
+	}
+	if columns < 1 || columns > maxColumns {
+		return fmt.Errorf(localize("columns must be between 1 and %d"), maxColumns)
+	}
+	if gap <= 0 {
+		gap = defaultColumnGap
+	}
+
+	var target columnBalance
+	if columns > 1 && (balance == nil || *balance) {
+		var err error
+		if target, err = balanceJSONColumns(pdf, doc, columns, gap, blocks); err != nil {
+			return err
+		}
+	}
+	_, err := flowJSONColumns(pdf, doc, columns, gap, blocks, target)
+	return err
+}
+
+// flowJSONColumns renders blocks in a column flow and returns the flow as it ended
+func flowJSONColumns(pdf *gofpdf.Fpdf, doc JSONDocument, columns int, gap float64, blocks []JSONBlock, balance columnBalance) (*columnFlow, error) {
+	left, _, right, bottom := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	flow := &columnFlow{
+		pdf:     pdf,
+		columns: columns,
+		width:   (pageWidth - left - right - gap*float64(columns-1)) / float64(columns),
+		gap:     gap,
+		left:    left,
+		right:   right,
+		top:     pdf.GetY(),
+		bottom:  pdf.GetY(),
+		margin:  bottom,
+		balance: balance,
+	}
+	columnFlows[pdf] = flow
+	pdf.SetAcceptPageBreakFunc(func() bool {
+		if auto, _ := pdf.GetAutoPageBreak(); !auto {
+			return false
+		}
+		return !flow.advance()
+	})
+	defer func() {
+		delete(columnFlows, pdf)
+		pdf.SetAcceptPageBreakFunc(func() bool {
+			auto, _ := pdf.GetAutoPageBreak()
+			return auto
+		})
+		auto, _ := pdf.GetAutoPageBreak()
+		pdf.SetAutoPageBreak(auto, bottom)
+		pdf.SetLeftMargin(left)
+		pdf.SetRightMargin(right)
+	}()
+
+	flow.applyBalance()
+	flow.setColumn(0)
+	for i, block := range blocks {
+		if err := renderJSONBlock(pdf, doc, block); err != nil {
+			return nil, fmt.Errorf(localize("block %d: %v"), i+1, err)
+		}
+	}
+
+	flow.filled += pdf.GetY() - flow.top
+	pdf.SetLeftMargin(left)
+	pdf.SetXY(left, math.Max(flow.bottom, pdf.GetY()))
+	return flow, nil
+}
+
+// balanceJSONColumns finds the lowest column height that keeps the content of the last page on
+// that page, laying the flow out on scratch documents with the same page, margins and position
+func balanceJSONColumns(pdf *gofpdf.Fpdf, doc JSONDocument, columns int, gap float64, blocks []JSONBlock) (columnBalance, error) {
+	pageWidth, pageHeight := pdf.GetPageSize()
+	orientation := "P"
+	if pageWidth > pageHeight {
+		orientation = "L"
+	}
+	left, top, right, bottom := pdf.GetMargins()
+	start := pdf.GetY()
+
+	measure := func(balance columnBalance) (*columnFlow, error) {
+		scratch := newDocument(orientation)
+		scratch.SetMargins(left, top, right)
+		scratch.SetAutoPageBreak(true, bottom)
+		scratch.AddPage()
+		scratch.SetY(start)
+		return flowJSONColumns(scratch, doc, columns, gap, blocks, balance)
+	}
+
+	natural, err := measure(columnBalance{})
+	if err != nil {
+		return columnBalance{}, err
+	}
+	lastTop := start
+	if natural.breaks > 0 {
+		lastTop = top
+	}
+
+	// Search between an even split of the last page and a full column, to a tenth of a millimeter
+	low := natural.filled / float64(columns)
+	high := pageHeight - bottom - lastTop
+	if low >= high {
+		return columnBalance{}, nil
+	}
+	for high-low > 0.1 {
+		height := (low + high) / 2
+		flow, err := measure(columnBalance{breaks: natural.breaks, height: height})
+		if err != nil {
+			return columnBalance{}, err
+		}
+		if flow.breaks > natural.breaks {
+			low = height
+		} else {
+			high = height
+		}
+	}
+
+	return columnBalance{breaks: natural.breaks, height: high}, nil
+}
+
+// jsonLayoutBlocks gathers the document content and sections into the blocks of a document-wide column flow
+func jsonLayoutBlocks(doc JSONDocument) ([]JSONBlock, error) {
+	blocks := append([]JSONBlock{}, doc.Content...)
+	for i, section := range doc.Sections {
+		if section.Columns > 0 {
+			return nil, fmt.Errorf(localize("section %d: %v"), i+1, localize("columns cannot be nested"))
+		}
+		if section.NewPage && len(blocks) > 0 {
+			blocks = append(blocks, JSONBlock{Type: "pageBreak"})
+		}
+		if section.Title != "" {
+			blocks = append(blocks, JSONBlock{Type: "heading", Text: section.Title, Level: 1})
+		}
+		blocks = append(blocks, section.Content...)
+	}
+	return blocks, nil
+}
+
+// renderJSONRow places cells side by side. The row is kept together and the cursor moves below its tallest cell.
+func renderJSONRow(pdf *gofpdf.Fpdf, doc JSONDocument, block JSONBlock) error {
+	if len(block.Cells) == 0 {
+		return errors.New(localize("row requires cells"))
+	}
+	gap := block.Gap
+	if gap <= 0 {
+		gap = defaultColumnGap
+	}
+
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	widths := columnWidths(block.Widths, len(block.Cells), pageWidth-left-right-gap*float64(len(block.Cells)-1))
+
+	height := 0.0
+	for i, cell := range block.Cells {
+		cellHeight, err := measureJSONBlocks(pdf, doc, widths[i], cell)
+		if err != nil {
+			return fmt.Errorf(localize("cell %d: %v"), i+1, err)
+		}
+		height = math.Max(height, cellHeight)
+	}
+	if fitsOnPage(pdf, height) {
+		ensureSpace(pdf, height)
+	}
+
+	// Cells never break, a row taller than the page runs past the bottom margin
+	left, _, right, _ = pdf.GetMargins()
+	auto, breakMargin := pdf.GetAutoPageBreak()
+	pdf.SetAutoPageBreak(false, breakMargin)
+	defer func() {
+		pdf.SetAutoPageBreak(auto, breakMargin)
+		pdf.SetLeftMargin(left)
+		pdf.SetRightMargin(right)
+	}()
+
+	x, y := left, pdf.GetY()
+	for i, cell := range block.Cells {
+		pdf.SetLeftMargin(x)
+		pdf.SetRightMargin(pageWidth - x - widths[i])
+		pdf.SetXY(x, y)
+		for j, child := range cell {
+			if err := renderJSONBlock(pdf, doc, child); err != nil {
+				return fmt.Errorf(localize("cell %d, block %d: %v"), i+1, j+1, err)
+			}
+		}
+		x += widths[i] + gap
+	}
+
+	pdf.SetLeftMargin(left)
+	pdf.SetXY(left, y+height)
+	return nil
+}
+
+// keepTogether moves to the next column or page when the block does not fit in the remaining space
+func keepTogether(pdf *gofpdf.Fpdf, doc JSONDocument, block JSONBlock) error {
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	block.KeepTogether = false
+	height, err := measureJSONBlocks(pdf, doc, pageWidth-left-right, []JSONBlock{block})
+	if err != nil {
+		return err
+	}
+	if fitsOnPage(pdf, height) {
+		ensureSpace(pdf, height)
+	}
+	return nil
+}
+
+// fitsOnPage reports whether the height fits between the top and bottom margins of an empty page
+func fitsOnPage(pdf *gofpdf.Fpdf, height float64) bool {
+	_, pageHeight := pdf.GetPageSize()
+	_, top, _, bottom := pdf.GetMargins()
+	return height <= pageHeight-top-bottom
+}
+
+// tocEntry is a heading listed in a table of contents
+type tocEntry struct {
+	Level int
+	Title string
+	Page  int
+	link  int
+}
+
+// tableOfContents collects the headings of a document. The draft layout records the page of each heading
+// with a single page reserved for the table; the final layout draws the table with the pages shifted by
+// its real length and links every entry to its heading as the headings are placed again, in the same order.
+type tableOfContents struct {
+	options TOCOptions
+	entries []tocEntry
+	drawn   bool
+	next    int
+}
+
+// tablesOfContents holds the table of contents of jsonToPDF documents being generated, keyed by document
+var tablesOfContents = map[*gofpdf.Fpdf]*tableOfContents{}
+
+// tocLineHeight is the height of a table of contents line, in mm
+const tocLineHeight = 7.0
+
+// newTableOfContents starts the draft of a table of contents
+func newTableOfContents(options TOCOptions) *tableOfContents {
+	if options.Depth <= 0 {
+		options.Depth = 3
+	}
+	return &tableOfContents{options: options}
+}
+
+// final returns the table of contents for the final layout, listing the headings of the draft
+func (t *tableOfContents) final() *tableOfContents {
+	return &tableOfContents{options: t.options, entries: t.entries, drawn: true}
+}
+
+// drafting reports whether t is a draft; drafts do not report progress
+func (t *tableOfContents) drafting() bool {
+	return t != nil && !t.drawn
+}
+
+// mark records a heading placed at y on the current page, or points its entry there in the final layout
+func (t *tableOfContents) mark(pdf *gofpdf.Fpdf, level int, title string, y float64) {
+	if t == nil || title == "" {
+		return
+	}
+	if level < 1 {
+		level = 1
+	}
+	if level > t.options.Depth {
+		return
+	}
+	if t.drafting() {
+		t.entries = append(t.entries, tocEntry{Level: level, Title: title, Page: pdf.PageNo()})
+		return
+	}
+	if t.next < len(t.entries) {
+		pdf.SetLink(t.entries[t.next].link, y, pdf.PageNo())
+	}
+	t.next++
+}
+
+// draw lists the entries from the cursor; a draft draws nothing. Pages are broken here rather than by
+// gofpdf since the HTML layout disables automatic page breaks.
+func (t *tableOfContents) draw(pdf *gofpdf.Fpdf, font string) {
+	if t.drafting() {
+		return
+	}
+	offset := t.layout(pdf, font, true) - 1
+	for i := range t.entries {
+		t.entries[i].Page += offset
+		t.entries[i].link = pdf.AddLink()
+	}
+	t.layout(pdf, font, false)
+}
+
+// layout places the title and one line per entry, drawing them unless dry, and returns the pages used
+func (t *tableOfContents) layout(pdf *gofpdf.Fpdf, font string, dry bool) int {
+	left, top, right, bottom := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	width, limit := pageWidth-left-right, pageHeight-bottom
+	y, pages := pdf.GetY(), 1
+
+	if !dry {
+		title := t.options.Title
+		if title == "" {
+			title = localize("Contents")
+		}
+		tr := useFont(pdf, font, "B", 16)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetXY(left, y)
+		pdf.CellFormat(width, 10, tr(title), "", 0, "L", false, 0, "")
+	}
+	y += 14
+
+	for _, entry := range t.entries {
+		if y+tocLineHeight > limit {
+			pages++
+			y = top
+			if !dry {
+				pdf.AddPage()
+			}
+		}
+		if !dry {
+			drawTOCEntry(pdf, font, entry, left, y, width)
+		}
+		y += tocLineHeight
+	}
+	if !dry {
+		pdf.SetXY(left, y)
+	}
+	return pages
+}
+
+// drawTOCEntry draws an entry indented by level: the title, shortened to one line, a dotted leader and
+// the right-aligned page number, with the whole line linking to the heading
+func drawTOCEntry(pdf *gofpdf.Fpdf, font string, entry tocEntry, x, y, width float64) {
+	style, size := "", 10.0
+	if entry.Level == 1 {
+		style, size = "B", 11
+	}
+	tr := useFont(pdf, font, style, size)
+	indent := float64(entry.Level-1) * 6
+	number := strconv.Itoa(entry.Page)
+	numberWidth := pdf.GetStringWidth(number)
+
+	available := width - indent - numberWidth - 6
+	title := tr(entry.Title)
+	if pdf.GetStringWidth(title) > available {
+		runes := []rune(entry.Title)
+		for len(runes) > 0 && pdf.GetStringWidth(title) > available {
+			runes = runes[:len(runes)-1]
+			title = tr(strings.TrimSpace(string(runes)) + "…")
+		}
+	}
+	titleWidth := pdf.GetStringWidth(title)
+
+	pdf.SetXY(x+indent, y)
+	pdf.CellFormat(titleWidth, tocLineHeight, title, "", 0, "L", false, 0, "")
+	useFont(pdf, font, "", size)
+	dotWidth := pdf.GetStringWidth(".")
+	leader := x + width - numberWidth - 2 - (x + indent + titleWidth + 2)
+	if dots := int(leader / dotWidth); dots > 0 {
+		pdf.SetXY(x+width-numberWidth-2-float64(dots)*dotWidth, y)
+		pdf.CellFormat(float64(dots)*dotWidth, tocLineHeight, strings.Repeat(".", dots), "", 0, "L", false, 0, "")
+	}
+	useFont(pdf, font, style, size)
+	pdf.SetXY(x+width-numberWidth, y)
+	pdf.CellFormat(numberWidth, tocLineHeight, number, "", 0, "R", false, 0, "")
+	pdf.Link(x+indent, y, width-indent, tocLineHeight, entry.link)
+}
+
+// measureJSONBlocks returns the height the blocks take once laid out in the given width, using a scratch
+// document with the same page size and fonts
+func measureJSONBlocks(pdf *gofpdf.Fpdf, doc JSONDocument, width float64, blocks []JSONBlock) (float64, error) {
+	pageWidth, pageHeight := pdf.GetPageSize()
+	orientation := "P"
+	if pageWidth > pageHeight {
+		orientation = "L"
+	}
+	_, top, _, bottom := pdf.GetMargins()
+
+	scratch := newDocument(orientation)
+	scratch.SetMargins(doc.Margin, top, pageWidth-doc.Margin-width)
+	scratch.SetAutoPageBreak(true, bottom)
+	scratch.AddPage()
+	for _, block := range blocks {
+		if err := renderJSONBlock(scratch, doc, block); err != nil {
+			return 0, err
+		}
+	}
+
+	return scratch.GetY() - top + float64(scratch.PageCount()-1)*(pageHeight-top-bottom), nil
+}
+
+// tableStyle controls the table layout engine. Colors are RGB triplets.
+type tableStyle struct {
+	Font           string
+	FontSize       float64
+	HeaderFontSize float64
+	Widths         []float64
+	Align          []string
+	HeaderAlign    string
+	Padding        float64
+	Border         bool
+	BorderColor    [3]int
+	BorderWidth    float64
+	HeaderFill     [3]int
+	HeaderColor    [3]int
+	TextColor      [3]int
+	Zebra          bool
+	ZebraFill      [3]int
+	RepeatHeader   bool
+}
+
+// defaultTableStyle returns bordered rows under a light gray header repeated on every page
+func defaultTableStyle(font string, fontSize float64) tableStyle {
+	return tableStyle{
+		Font:           font,
+		FontSize:       fontSize,
+		HeaderFontSize: fontSize,
+		HeaderAlign:    "L",
+		Padding:        1,
+		Border:         true,
+		BorderWidth:    0.2,
+		HeaderFill:     [3]int{230, 230, 230},
+		ZebraFill:      [3]int{245, 245, 245},
+		RepeatHeader:   true,
+	}
+}
+
+// parseTableStyle applies the keys of a table Style map on top of base:
+// font, fontSize, headerFontSize, columnWidths (mm), align (one value or one per column),
+// headerAlign, padding, border, borderColor, borderWidth, headerBackground, headerColor,
+// textColor, zebra (true or a color), zebraColor and repeatHeader
+func parseTableStyle(style map[string]interface{}, base tableStyle) (tableStyle, error) {
+	s := base
+	number := func(key string, target *float64) error {
+		if value, ok := style[key]; ok {
+			n, ok := value.(float64)
+			if !ok || n < 0 {
+				return errors.New(localize("style %s must be a positive number", key))
+			}
+			*target = n
+		}
+		return nil
+	}
+	color := func(key string, target *[3]int) error {
+		if value, ok := style[key]; ok {
+			text, _ := value.(string)
+			rgb, ok := hexColor(text)
+			if !ok {
+				return errors.New(localize("style %s must be a #RRGGBB color", key))
+			}
+			*target = rgb
+		}
+		return nil
+	}
+	flag := func(key string, target *bool) error {
+		if value, ok := style[key]; ok {
+			b, ok := value.(bool)
+			if !ok {
+				return errors.New(localize("style %s must be true or false", key))
+			}
+			*target = b
+		}
+		return nil
+	}
+
+	if font, ok := style["font"].(string); ok {
+		s.Font = font
+	}
+	if _, ok := style["fontSize"]; ok {
+		if err := number("fontSize", &s.FontSize); err != nil {
+			return s, err
+		}
+		s.HeaderFontSize = s.FontSize
+	}
+	for key, target := range map[string]*float64{"headerFontSize": &s.HeaderFontSize, "padding": &s.Padding, "borderWidth": &s.BorderWidth} {
+		if err := number(key, target); err != nil {
+			return s, err
+		}
+	}
+	for key, target := range map[string]*[3]int{"borderColor": &s.BorderColor, "headerBackground": &s.HeaderFill, "headerColor": &s.HeaderColor, "textColor": &s.TextColor, "zebraColor": &s.ZebraFill} {
+		if err := color(key, target); err != nil {
+			return s, err
+		}
+	}
+	for key, target := range map[string]*bool{"border": &s.Border, "repeatHeader": &s.RepeatHeader} {
+		if err := flag(key, target); err != nil {
+			return s, err
+		}
+	}
+
+	switch zebra := style["zebra"].(type) {
+	case nil:
+	case bool:
+		s.Zebra = zebra
+	case string:
+		rgb, ok := hexColor(zebra)
+		if !ok {
+			return s, errors.New(localize("style %s must be a #RRGGBB color", "zebra"))
+		}
+		s.Zebra, s.ZebraFill = true, rgb
+	default:
+		return s, errors.New(localize("style %s must be true or false", "zebra"))
+	}
+
+	if widths, ok := style["columnWidths"]; ok {
+		list, _ := widths.([]interface{})
+		if list == nil {
+			return s, errors.New(localize("style %s must be an array of numbers", "columnWidths"))
+		}
+		s.Widths = make([]float64, len(list))
+		for i, value := range list {
+			n, ok := value.(float64)
+			if !ok || n < 0 {
+				return s, errors.New(localize("style %s must be an array of numbers", "columnWidths"))
+			}
+			s.Widths[i] = n
+		}
+	}
+
+	switch align := style["align"].(type) {
+	case nil:
+	case string:
+		s.Align = []string{blockAlign(align, "L")}
+	case []interface{}:
+		s.Align = make([]string, len(align))
+		for i, value := range align {
+			text, _ := value.(string)
+			s.Align[i] = blockAlign(text, "L")
+		}
+	default:
+		return s, errors.New(localize("style %s must be a string or an array of strings", "align"))
+	}
+	if align, ok := style["headerAlign"].(string); ok {
+		s.HeaderAlign = blockAlign(align, "L")
+	}
+
+	return s, nil
+}
+
+// hexColor parses a #RRGGBB (or #RGB) color
+func hexColor(value string) ([3]int, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	n, err := strconv.ParseUint(value, 16, 32)
+	if len(value) != 6 || err != nil {
+		return [3]int{}, false
+	}
+	return [3]int{int(n >> 16 & 0xFF), int(n >> 8 & 0xFF), int(n & 0xFF)}, true
+}
+
+// columnWidths resolves the column widths: given widths are kept (missing ones share the
+// remaining space) and the whole table is scaled down when it would overflow the margins
+func columnWidths(given []float64, columns int, available float64) []float64 {
+	widths := make([]float64, columns)
+	used, missing := 0.0, 0
+	for i := range widths {
+		if i < len(given) && given[i] > 0 {
+			widths[i] = given[i]
+			used += given[i]
+		} else {
+			missing++
+		}
+	}
+	if missing > 0 {
+		share := (available - used) / float64(missing)
+		if share < available/float64(columns*4) {
+			share = available / float64(columns*4)
+		}
+		for i := range widths {
+			if widths[i] == 0 {
+				widths[i] = share
+				used += share
+			}
+		}
+	}
+	if used > available {
+		for i := range widths {
+			widths[i] *= available / used
+		}
+	}
+	return widths
+}
+
+// drawTable lays out a table: wrapped cells set the row height, rows that do not fit
+// move to the next page and the header row is repeated there
+func drawTable(pdf *gofpdf.Fpdf, headers []string, rows [][]interface{}, style tableStyle) {
+	columns := len(headers)
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	if columns == 0 {
+		return
+	}
+
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	widths := columnWidths(style.Widths, columns, pageWidth-left-right)
+
+	// Restore the document colors once the table is drawn
+	dr, dg, db := pdf.GetDrawColor()
+	fr, fg, fb := pdf.GetFillColor()
+	tr0, tg0, tb0 := pdf.GetTextColor()
+	lineWidth := pdf.GetLineWidth()
+	defer func() {
+		pdf.SetDrawColor(dr, dg, db)
+		pdf.SetFillColor(fr, fg, fb)
+		pdf.SetTextColor(tr0, tg0, tb0)
+		pdf.SetLineWidth(lineWidth)
+	}()
+	pdf.SetDrawColor(style.BorderColor[0], style.BorderColor[1], style.BorderColor[2])
+	pdf.SetLineWidth(style.BorderWidth)
+
+	header := func() {
+		tr := useFont(pdf, style.Font, "B", style.HeaderFontSize)
+		cells := make([]string, columns)
+		copy(cells, headers)
+		pdf.SetFillColor(style.HeaderFill[0], style.HeaderFill[1], style.HeaderFill[2])
+		pdf.SetTextColor(style.HeaderColor[0], style.HeaderColor[1], style.HeaderColor[2])
+		drawTableRow(pdf, tr, cells, widths, style.HeaderFontSize*0.5, style.Padding, style.Border, true,
+			func(int) string { return style.HeaderAlign })
+	}
+	align := func(column int) string {
+		switch {
+		case column < len(style.Align):
+			return style.Align[column]
+		case len(style.Align) == 1:
+			return style.Align[0]
+		}
+		return "L"
+	}
+
+	lineHeight := style.FontSize * 0.5
+	if len(headers) > 0 {
+		ensureSpace(pdf, tableRowHeight(pdf, useFont(pdf, style.Font, "B", style.HeaderFontSize), headers, widths, style.HeaderFontSize*0.5, style.Padding)+lineHeight+2*style.Padding)
+		header()
+	}
+	for r, row := range rows {
+		cells := make([]string, columns)
+		for i, value := range row {
+			if value != nil {
+				cells[i] = fmt.Sprintf("%v", value)
+			}
+		}
+
+		tr := useFont(pdf, style.Font, "", style.FontSize)
+		if ensureSpace(pdf, tableRowHeight(pdf, tr, cells, widths, lineHeight, style.Padding)) && len(headers) > 0 && style.RepeatHeader {
+			header()
+			tr = useFont(pdf, style.Font, "", style.FontSize)
+		}
+
+		zebra := style.Zebra && r%2 == 1
+		if zebra {
+			pdf.SetFillColor(style.ZebraFill[0], style.ZebraFill[1], style.ZebraFill[2])
+		}
+		pdf.SetTextColor(style.TextColor[0], style.TextColor[1], style.TextColor[2])
+		drawTableRow(pdf, tr, cells, widths, lineHeight, style.Padding, style.Border, zebra, align)
+	}
+}
+
+// tableRowHeight returns the height of a row whose tallest cell sets the height
+func tableRowHeight(pdf *gofpdf.Fpdf, tr func(string) string, cells []string, widths []float64, lineHeight, padding float64) float64 {
+	lines := 1
+	for i, cell := range cells {
+		if i < len(widths) {
+			if n := len(pdf.SplitText(tr(cell), widths[i]-2*padding)); n > lines {
+				lines = n
+			}
+		}
+	}
+	return float64(lines)*lineHeight + 2*padding
+}
+
+// drawTableRow draws one row at the current position and moves below it
+func drawTableRow(pdf *gofpdf.Fpdf, tr func(string) string, cells []string, widths []float64, lineHeight, padding float64, border, fill bool, align func(int) string) {
+	height := tableRowHeight(pdf, tr, cells, widths, lineHeight, padding)
+	style := ""
+	if fill {
+		style += "F"
+	}
+	if border {
+		style += "D"
+	}
+
+	left, _, _, _ := pdf.GetMargins()
+	x, y := left, pdf.GetY()
+	for i, width := range widths {
+		if style != "" {
+			pdf.Rect(x, y, width, height, style)
+		}
+		if i < len(cells) && cells[i] != "" {
+			pdf.SetXY(x+padding, y+padding)
+			pdf.MultiCell(width-2*padding, lineHeight, tr(cells[i]), "", align(i), false)
+		}
+		x += width
+	}
+	pdf.SetXY(left, y+height)
+}
+
+// ensureSpace moves to the next column or page when the given height does not fit above the bottom margin.
+// It reports whether a page was added.
+func ensureSpace(pdf *gofpdf.Fpdf, height float64) bool {
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if auto, _ := pdf.GetAutoPageBreak(); !auto || pdf.GetY()+height <= pageHeight-bottom {
+		return false
+	}
+	breakColumn(pdf)
+	return true
+}
+
+// blockAlign converts left/center/right/justify (or L/C/R/J) to a gofpdf alignment
+func blockAlign(align, fallback string) string {
+	switch strings.ToLower(align) {
+	case "left", "l":
+		return "L"
+	case "center", "c":
+		return "C"
+	case "right", "r":
+		return "R"
+	case "justify", "j":
+		return "J"
+	}
+	return fallback
+}
+
+// analyzePDF - Comprehensive PDF analysis
+func analyzePDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "analyzePDF", "pdfData"),
+		})
+	}
+
+	// Decrypt while reading rather than up front so the encryption status is preserved
+	pdfBytes, err := decodePDFData(args[0], "")
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	ctx, err := readAnalysisContext(pdfBytes, optionalPassword(args, 1))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to analyze PDF: %v", err),
+		})
+	}
+
+	fonts, embeddedFonts := analyzeFonts(ctx)
+	images, uncompressedImages := analyzeImages(ctx)
+	hyperlinks := collectHyperlinks(ctx)
+
+	formFields := 0
+	if ctx.Form != nil {
+		fields, _, err := form.FormFields(ctx)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to read form fields: %v", err),
+			})
+		}
+		formFields = len(fields)
+	}
+
+	version := ctx.HeaderVersion
+	if ctx.RootVersion != nil {
+		version = ctx.RootVersion
+	}
+
+	properties := map[string]interface{}{}
+	for key, value := range ctx.Properties {
+		properties[key] = value
+	}
+
+	metadata := map[string]interface{}{
+		"title":      ctx.Title,
+		"author":     ctx.Author,
+		"subject":    ctx.Subject,
+		"keywords":   ctx.Keywords,
+		"creator":    ctx.Creator,
+		"producer":   ctx.Producer,
+		"createdAt":  pdfDateToISO(ctx.CreationDate),
+		"modifiedAt": pdfDateToISO(ctx.ModDate),
+		"properties": properties,
+	}
+
+	// Tips derived from what the document actually contains
+	tips := []interface{}{}
+	if len(pdfBytes) > 1024*1024 {
+		tips = append(tips, "File is larger than 1MB - consider optimization")
+	}
+	if n := len(ctx.Optimize.DuplicateFonts) + len(ctx.Optimize.DuplicateImages); n > 0 {
+		tips = append(tips, fmt.Sprintf("%d duplicate font or image objects can be removed with optimizePDF", n))
+	}
+	if uncompressedImages > 0 {
+		tips = append(tips, fmt.Sprintf("Images stored without compression: %d", uncompressedImages))
+	}
+	if notEmbedded := fontsNotEmbedded(fonts, embeddedFonts); len(notEmbedded) > 0 {
+		tips = append(tips, fmt.Sprintf("Fonts not embedded, rendering may differ on other systems: %s", strings.Join(notEmbedded, ", ")))
+	}
+	if !ctx.Read.UsingObjectStreams && ctx.XRefTable.Size != nil && *ctx.XRefTable.Size > 100 {
+		tips = append(tips, "Use object streams to reduce the size of the cross-reference data")
+	}
+	if ctx.Title == "" {
+		tips = append(tips, "Set a document title to improve accessibility and search")
+	}
+
+	analysis := map[string]interface{}{
+		"fileSize":         len(pdfBytes),
+		"pages":            ctx.PageCount,
+		"images":           images,
+		"fonts":            fonts,
+		"embeddedFonts":    embeddedFonts,
+		"hyperlinks":       hyperlinks,
+		"formFields":       formFields,
+		"encrypted":        ctx.Encrypt != nil,
+		"version":          version.String(),
+		"tagged":           ctx.Tagged,
+		"signatures":       ctx.SignatureExist,
+		"metadata":         metadata,
+		"optimizationTips": tips,
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Analyzed PDF (%d bytes, %d pages)\n", len(pdfBytes), ctx.PageCount)
+	}
+
+	return js.ValueOf(analysis)
+}
+
+// readAnalysisContext - Read, validate and optimize a PDF so its resources can be inspected
+func readAnalysisContext(pdfBytes []byte, password string) (*model.Context, error) {
+	conf := newPDFConfiguration(password)
+	conf.Cmd = model.LISTINFO
+
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfBytes), conf)
+	if err != nil {
+		if errors.Is(err, pdfcpu.ErrWrongPassword) {
+			return nil, errors.New(localize("incorrect password for encrypted PDF"))
+		}
+		return nil, err
+	}
+	if ctx.Optimize == nil {
+		if err := api.OptimizeContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return ctx, nil
+}
+
+// analyzeFonts - List the distinct font names used by the document and those embedded in it
+func analyzeFonts(ctx *model.Context) ([]interface{}, []interface{}) {
+	seen := map[string]bool{}
+	embeddedSeen := map[string]bool{}
+	fonts := []interface{}{}
+	embedded := []interface{}{}
+
+	// Fonts referenced by pages and by the AcroForm default resources
+	fontObjects := map[int]*model.FontObject{}
+	for objNr, fontObject := range ctx.Optimize.FontObjects {
+		fontObjects[objNr] = fontObject
+	}
+	for objNr, fontObject := range ctx.Optimize.FormFontObjects {
+		fontObjects[objNr] = fontObject
+	}
+
+	objNrs := make([]int, 0, len(fontObjects))
+	for objNr := range fontObjects {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+		fontObject := fontObjects[objNr]
+		name := fontObject.FontName
+		if fontObject.Prefix != "" {
+			name = strings.TrimPrefix(name, fontObject.Prefix+"+")
+		}
+		if !seen[name] {
+			seen[name] = true
+			fonts = append(fonts, name)
+		}
+		if !embeddedSeen[name] && fontIsEmbedded(ctx.XRefTable, fontObject.FontDict) {
+			embeddedSeen[name] = true
+			embedded = append(embedded, name)
+		}
+	}
+
+	return fonts, embedded
+}
+
+// fontsNotEmbedded - List the font names that have no embedded font program
+func fontsNotEmbedded(fonts, embedded []interface{}) []string {
+	isEmbedded := map[interface{}]bool{}
+	for _, name := range embedded {
+		isEmbedded[name] = true
+	}
+
+	missing := []string{}
+	for _, name := range fonts {
+		if !isEmbedded[name] {
+			missing = append(missing, name.(string))
+		}
+	}
+	return missing
+}
+
+// fontIsEmbedded - Report whether a font dict (or its CID descendant) carries a font program
+func fontIsEmbedded(xRefTable *model.XRefTable, fontDict types.Dict) bool {
+	if descendants, err := xRefTable.DereferenceArray(fontDict["DescendantFonts"]); err == nil && len(descendants) > 0 {
+		if descendant, err := xRefTable.DereferenceDict(descendants[0]); err == nil && descendant != nil {
+			fontDict = descendant
+		}
+	}
+
+	descriptor, err := xRefTable.DereferenceDict(fontDict["FontDescriptor"])
+	if err != nil || descriptor == nil {
+		return false
+	}
+
+	for _, key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+		if _, found := descriptor.Find(key); found {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeImages - Count distinct image XObjects and those stored without a compression filter
+func analyzeImages(ctx *model.Context) (int, int) {
+	uncompressed := 0
+	for _, imageObject := range ctx.Optimize.ImageObjects {
+		if imageObject.ImageDict == nil {
+			continue
+		}
+		if _, found := imageObject.ImageDict.Find("Filter"); !found {
+			uncompressed++
+		}
+	}
+	return len(ctx.Optimize.ImageObjects), uncompressed
+}
+
+// collectHyperlinks - Collect the URIs of all link annotations in page order
+func collectHyperlinks(ctx *model.Context) []interface{} {
+	links := []interface{}{}
+	seen := map[string]bool{}
+
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		pageDict, _, _, err := ctx.PageDict(pageNr, false)
+		if err != nil || pageDict == nil {
+			continue
+		}
+
+		annots, err := ctx.DereferenceArray(pageDict["Annots"])
+		if err != nil {
+			continue
+		}
+
+		for _, annot := range annots {
+			annotDict, err := ctx.DereferenceDict(annot)
+			if err != nil || annotDict == nil || annotDict.Subtype() == nil || *annotDict.Subtype() != "Link" {
+				continue
+			}
+
+			action, err := ctx.DereferenceDict(annotDict["A"])
+			if err != nil || action == nil {
+				continue
+			}
+
+			uri, err := ctx.DereferenceStringOrHexLiteral(action["URI"], model.V10, nil)
+			if err != nil || uri == "" || seen[uri] {
+				continue
+			}
+			seen[uri] = true
+			links = append(links, uri)
+		}
+	}
+
+	return links
+}
+
+// pdfDateToISO - Convert a PDF date string to RFC 3339, keeping the raw value when it cannot be parsed
+func pdfDateToISO(value string) string {
+	if value == "" {
+		return ""
+	}
+	if t, ok := types.DateTime(value, true); ok {
+		return t.Format(time.RFC3339)
+	}
+	return value
+}
+
+// optimizePDF - Intelligent PDF optimization
+func optimizePDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "optimizePDF", "pdfData"),
+		})
+	}
+
+	pdfData := args[0]
+	optimizationLevel := "balanced"
+	if len(args) > 1 && args[1].Type() == js.TypeString && args[1].String() != "" {
+		optimizationLevel = args[1].String()
+	}
+	level, ok := optimizationLevels[optimizationLevel]
+	if !ok {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown level %q (expected %s)", optimizationLevel, "conservative, balanced, aggressive"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(pdfData, optionalPassword(args, 2))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	originalSize := len(pdfBytes)
+	optimized, optimizations, err := rewritePDF(pdfBytes, level)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to optimize PDF: %v", err),
+		})
+	}
+
+	optimizedSize := len(optimized)
+	savingsPercent := math.Round((1.0-float64(optimizedSize)/float64(originalSize))*100*100) / 100
+
+	optimizedPdfData := binaryOutput(optimized)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Optimized PDF from %d to %d bytes (%.1f%% savings)\n",
+			originalSize, optimizedSize, savingsPercent)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":           optimizedPdfData,
+		"originalSize":      originalSize,
+		"optimizedSize":     optimizedSize,
+		"savingsPercent":    savingsPercent,
+		"optimizationLevel": optimizationLevel,
+		"optimizations":     optimizations,
+		"format":            "application/pdf",
+	})
+}
+
+// decryptPDF - Remove password protection from an encrypted PDF
+func decryptPDF(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires exactly 2 arguments (%s)", "decryptPDF", "pdfData, password"),
+		})
+	}
+
+	pdfBytes, err := bytesFromJS(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	decrypted, wasEncrypted, err := decryptPDFBytes(pdfBytes, args[1].String())
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to decrypt PDF: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Decrypted PDF (%d bytes, encrypted: %t)\n", len(decrypted), wasEncrypted)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":      binaryOutput(decrypted),
+		"size":         len(decrypted),
+		"wasEncrypted": wasEncrypted,
+		"format":       "application/pdf",
+	})
+}
+
+// fillForm - Fill the AcroForm fields of an existing PDF from JSON values
+func fillForm(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 2 arguments (%s)", "fillForm", "pdfData, valuesJSON"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 3))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(args[1].String()), &values); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid form values JSON: %v", err),
+		})
+	}
+
+	flatten := len(args) > 2 && args[2].Type() == js.TypeBoolean && args[2].Bool()
+
+	formGroup, err := api.ExportForm(bytes.NewReader(pdfBytes), "pdf-wasm", newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read form fields: %v", err),
+		})
+	}
+	if len(formGroup.Forms) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("PDF does not contain any form fields"),
+		})
+	}
+
+	fields := &formGroup.Forms[0]
+	matched := applyFormValues(fields, values, flatten)
+
+	unmatched := []interface{}{}
+	for key := range values {
+		if !matched[key] {
+			unmatched = append(unmatched, key)
+		}
+	}
+
+	if len(matched) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("None of the given values match a form field name"),
+		})
+	}
+
+	formJSON, err := json.Marshal(formGroup)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to encode form data: %v", err),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := api.FillForm(bytes.NewReader(pdfBytes), bytes.NewReader(formJSON), &buf, newPDFConfiguration("")); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to fill form: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Filled %d form fields (flattened: %t)\n", len(matched), flatten)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":         binaryOutput(buf.Bytes()),
+		"size":            buf.Len(),
+		"fieldsCompleted": len(matched),
+		"unmatchedFields": unmatched,
+		"flattened":       flatten,
+		"format":          "application/pdf",
+	})
+}
+
+// applyFormValues - Copy JSON values onto the exported form fields matched by name or id.
+// Flattening locks every field so the filled values can no longer be edited.
+func applyFormValues(f *form.Form, values map[string]interface{}, flatten bool) map[string]bool {
+	matched := make(map[string]bool)
+
+	lookup := func(name, id string) (interface{}, bool) {
+		if v, ok := values[name]; ok && name != "" {
+			matched[name] = true
+			return v, true
+		}
+		if v, ok := values[id]; ok {
+			matched[id] = true
+			return v, true
+		}
+		return nil, false
+	}
+
+	for _, tf := range f.TextFields {
+		if v, ok := lookup(tf.Name, tf.ID); ok {
+			tf.Value = formValueString(v)
+		}
+		tf.Locked = tf.Locked || flatten
+	}
+	for _, df := range f.DateFields {
+		if v, ok := lookup(df.Name, df.ID); ok {
+			df.Value = formValueString(v)
+		}
+		df.Locked = df.Locked || flatten
+	}
+	for _, cb := range f.CheckBoxes {
+		if v, ok := lookup(cb.Name, cb.ID); ok {
+			cb.Value = formValueBool(v)
+		}
+		cb.Locked = cb.Locked || flatten
+	}
+	for _, rb := range f.RadioButtonGroups {
+		if v, ok := lookup(rb.Name, rb.ID); ok {
+			rb.Value = formValueString(v)
+		}
+		rb.Locked = rb.Locked || flatten
+	}
+	for _, cb := range f.ComboBoxes {
+		if v, ok := lookup(cb.Name, cb.ID); ok {
+			cb.Value = formValueString(v)
+		}
+		cb.Locked = cb.Locked || flatten
+	}
+	for _, lb := range f.ListBoxes {
+		if v, ok := lookup(lb.Name, lb.ID); ok {
+			if items, isList := v.([]interface{}); isList {
+				lb.Values = make([]string, len(items))
+				for i, item := range items {
+					lb.Values[i] = formValueString(item)
+				}
+			} else {
+				lb.Values = []string{formValueString(v)}
+			}
+		}
+		lb.Locked = lb.Locked || flatten
+	}
+
+	return matched
+}
+
+// formValueString - Convert a JSON value to the text stored in a form field
+func formValueString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// formValueBool - Interpret a JSON value as a checkbox state
+func formValueBool(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "true", "yes", "on", "1", "x", "checked":
+			return true
+		}
+	}
+	return false
+}
+
+// Resolution bounds for renderPage, the upper one keeps a full page below the pixel budget
+const (
+	defaultRenderDPI = 72.0
+	minRenderDPI     = 10.0
+	maxRenderDPI     = 600.0
+	maxRenderPixels  = 40000000
+)
+
+// renderPage - Rasterize a page to PNG for thumbnails and previews
+func renderPage(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "renderPage", "pdfData"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 3))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	pageNr := 1
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		pageNr = args[1].Int()
+	}
+	dpi := defaultRenderDPI
+	if len(args) > 2 && args[2].Type() == js.TypeNumber {
+		dpi = args[2].Float()
+	}
+	if math.IsNaN(dpi) || dpi < minRenderDPI || dpi > maxRenderDPI {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("DPI must be between %g and %g", minRenderDPI, maxRenderDPI),
+		})
+	}
+
+	ctx, err := api.ReadAndValidate(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render page: %v", err),
+		})
+	}
+	if pageNr < 1 || pageNr > ctx.PageCount {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Page %d does not exist (document has %d pages)", pageNr, ctx.PageCount),
+		})
+	}
+
+	canvas, err := rasterizePage(ctx, pageNr, dpi)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render page: %v", err),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render page: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Rendered page %d at %g dpi (%dx%d)\n", pageNr, dpi, canvas.Rect.Dx(), canvas.Rect.Dy())
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"page":     pageNr,
+		"pages":    ctx.PageCount,
+		"dpi":      dpi,
+		"width":    canvas.Rect.Dx(),
+		"height":   canvas.Rect.Dy(),
+		"format":   "png",
+		"mimeType": "image/png",
+		"size":     buf.Len(),
+		"data":     dataURLOutput("image/png", buf.Bytes()),
+	})
+}
+
+// rasterizePage - Paint the content and annotation appearances of a page onto a white canvas
+func rasterizePage(ctx *model.Context, pageNr int, dpi float64) (*image.RGBA, error) {
+	pageDict, _, inherited, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return nil, err
+	}
+	if pageDict == nil || inherited == nil {
+		return nil, errors.New(localize("page %d not found", pageNr))
+	}
+
+	box := inherited.CropBox
+	if box == nil {
+		box = inherited.MediaBox
+	}
+	if box == nil {
+		box = types.NewRectangle(0, 0, 612, 792)
+	}
+	llx, lly := math.Min(box.LL.X, box.UR.X), math.Min(box.LL.Y, box.UR.Y)
+	urx, ury := math.Max(box.LL.X, box.UR.X), math.Max(box.LL.Y, box.UR.Y)
+
+	// The device matrix maps user space to pixels with the y axis pointing down
+	scale := dpi / 72
+	rotate := ((inherited.Rotate % 360) + 360) % 360
+	width, height := (urx-llx)*scale, (ury-lly)*scale
+	var device pdfMatrix
+	switch rotate {
+	case 90:
+		width, height = height, width
+		device = pdfMatrix{0, scale, scale, 0, -lly * scale, -llx * scale}
+	case 180:
+		device = pdfMatrix{-scale, 0, 0, scale, urx * scale, -lly * scale}
+	case 270:
+		width, height = height, width
+		device = pdfMatrix{0, -scale, -scale, 0, ury * scale, urx * scale}
+	default:
+		device = pdfMatrix{scale, 0, 0, -scale, -llx * scale, ury * scale}
+	}
+
+	w, h := int(math.Ceil(width-0.01)), int(math.Ceil(height-0.01))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	if w*h > maxRenderPixels {
+		return nil, errors.New(localize("rendered page would be %dx%d pixels, lower the DPI", w, h))
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(canvas, canvas.Rect, image.White, image.Point{}, draw.Src)
+
+	r := newPageRenderer(ctx, canvas, device)
+	content, err := ctx.PageContent(pageDict)
+	if err != nil && !errors.Is(err, model.ErrNoContent) {
+		return nil, err
+	}
+	r.run(content, inherited.Resources)
+	r.drawAnnotations(pageDict)
+
+	return canvas, nil
+}
+
+// extractPageText - Run a page content stream through the renderer, collecting its text in content order
+func extractPageText(ctx *model.Context, pageNr int) (string, error) {
+	pageDict, _, inherited, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return "", err
+	}
+	if pageDict == nil || inherited == nil {
+		return "", errors.New(localize("page %d not found", pageNr))
+	}
+
+	content, err := ctx.PageContent(pageDict)
+	if err != nil {
+		if errors.Is(err, model.ErrNoContent) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	// Nothing is painted, so a single pixel canvas in user space is enough
+	r := newPageRenderer(ctx, image.NewRGBA(image.Rect(0, 0, 1, 1)), identityMatrix)
+	r.text = &textCollector{}
+	r.run(content, inherited.Resources)
+
+	return r.text.String(), nil
+}
+
+// textCollector assembles extracted characters into lines, starting a new line when the baseline
+// moves and inserting a space when the gap to the previous character is wider than a fifth of the font size
+type textCollector struct {
+	builder strings.Builder
+	started bool
+	last    renderPoint
+	space   bool
+}
+
+func (t *textCollector) add(runes []rune, origin, end renderPoint, size float64) {
+	if len(runes) == 0 {
+		return
+	}
+	if t.started {
+		switch {
+		case math.Abs(origin.y-t.last.y) > size/2:
+			t.builder.WriteByte('\n')
+			t.space = true
+		case math.Abs(origin.x-t.last.x) > size/5 && !t.space && !unicode.IsSpace(runes[0]):
+			t.builder.WriteByte(' ')
+		}
+	}
+	for _, c := range runes {
+		t.builder.WriteRune(c)
+	}
+	t.started = true
+	t.last = end
+	t.space = unicode.IsSpace(runes[len(runes)-1])
+}
+
+// String returns the collected text without trailing spaces on each line
+func (t *textCollector) String() string {
+	lines := strings.Split(t.builder.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// pdfMatrix is an affine transform stored in PDF order [a b c d e f]
+type pdfMatrix [6]float64
+
+var identityMatrix = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+// multiply returns the transform applying m first and then n
+func (m pdfMatrix) multiply(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m pdfMatrix) apply(x, y float64) renderPoint {
+	return renderPoint{m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]}
+}
+
+// scale returns the mean scaling factor, used for line widths and curve flattening
+func (m pdfMatrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+func (m pdfMatrix) invert() (pdfMatrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if math.Abs(det) < 1e-12 {
+		return identityMatrix, false
+	}
+	return pdfMatrix{
+		m[3] / det,
+		-m[1] / det,
+		-m[2] / det,
+		m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det,
+		(m[1]*m[4] - m[0]*m[5]) / det,
+	}, true
+}
+
+type renderPoint struct {
+	x, y float64
+}
+
+type renderSubpath struct {
+	points []renderPoint
+	closed bool
+}
+
+// renderState is the part of the PDF graphics state the renderer honours
+type renderState struct {
+	ctm           pdfMatrix
+	fillSpace     *renderColorSpace
+	strokeSpace   *renderColorSpace
+	fillColor     []float64
+	strokeColor   []float64
+	fillShading   *renderShading
+	strokeShading *renderShading
+	fillAlpha     float64
+	strokeAlpha   float64
+	lineWidth     float64
+	lineCap       int
+	dash          []float64
+	dashPhase     float64
+	clip          *image.Alpha
+
+	font        *renderFont
+	fontSize    float64
+	charSpacing float64
+	wordSpacing float64
+	hScale      float64
+	leading     float64
+	rise        float64
+	textMode    int
+}
+
+type pageRenderer struct {
+	ctx      *model.Context
+	canvas   *image.RGBA
+	bounds   image.Rectangle
+	state    renderState
+	stack    []renderState
+	baseCTM  pdfMatrix
+	path     []renderSubpath
+	current  renderPoint
+	start    renderPoint
+	clipMode int
+	depth    int
+
+	textMatrix pdfMatrix
+	lineMatrix pdfMatrix
+	textClip   [][]renderPoint
+
+	fonts    map[string]*renderFont
+	images   map[int]image.Image
+	glyphBuf sfnt.Buffer
+
+	// text receives the shown characters instead of painting anything when extracting text
+	text *textCollector
+}
+
+func newPageRenderer(ctx *model.Context, canvas *image.RGBA, device pdfMatrix) *pageRenderer {
+	r := &pageRenderer{
+		ctx:     ctx,
+		canvas:  canvas,
+		bounds:  canvas.Rect,
+		baseCTM: device,
+		fonts:   map[string]*renderFont{},
+		images:  map[int]image.Image{},
+	}
+	r.state = renderState{
+		ctm:         device,
+		fillSpace:   deviceGraySpace,
+		strokeSpace: deviceGraySpace,
+		fillColor:   []float64{0},
+		strokeColor: []float64{0},
+		fillAlpha:   1,
+		strokeAlpha: 1,
+		lineWidth:   1,
+		hScale:      1,
+	}
+	return r
+}
+
+// maxRenderDepth bounds nested form XObjects and Type 3 glyphs
+const maxRenderDepth = 16
+
+// run - Execute a content stream against the current graphics state
+func (r *pageRenderer) run(content []byte, resources types.Dict) {
+	lexer := &contentLexer{data: content}
+	operands := []interface{}{}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return
+		}
+		op, ok := token.(contentOperator)
+		if !ok {
+			operands = append(operands, token)
+			continue
+		}
+		if op == "BI" {
+			r.inlineImage(lexer, resources)
+		} else {
+			r.execute(string(op), operands, resources)
+		}
+		operands = operands[:0]
+	}
+}
+
+func (r *pageRenderer) execute(op string, operands []interface{}, resources types.Dict) {
+	st := &r.state
+	switch op {
+	case "q":
+		r.stack = append(r.stack, r.state)
+	case "Q":
+		if len(r.stack) > 0 {
+			r.state = r.stack[len(r.stack)-1]
+			r.stack = r.stack[:len(r.stack)-1]
+		}
+	case "cm":
+		if m, ok := operandMatrix(operands); ok {
+			st.ctm = m.multiply(st.ctm)
+		}
+	case "w":
+		st.lineWidth = operandNumber(operands, 0)
+	case "J":
+		st.lineCap = int(operandNumber(operands, 0))
+	case "d":
+		if len(operands) == 2 {
+			st.dash = numberArray(operands[0])
+			st.dashPhase = operandNumber(operands, 1)
+		}
+	case "gs":
+		r.applyExtGState(operandName(operands, 0), resources)
+
+	case "m":
+		if len(operands) >= 2 {
+			r.current = renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)}
+			r.start = r.current
+			r.path = append(r.path, renderSubpath{points: []renderPoint{st.ctm.apply(r.current.x, r.current.y)}})
+		}
+	case "l":
+		if len(operands) >= 2 {
+			r.lineTo(renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)})
+		}
+	case "c":
+		if len(operands) >= 6 {
+			r.curveTo(renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)},
+				renderPoint{operandNumber(operands, 2), operandNumber(operands, 3)},
+				renderPoint{operandNumber(operands, 4), operandNumber(operands, 5)})
+		}
+	case "v":
+		if len(operands) >= 4 {
+			r.curveTo(r.current,
+				renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)},
+				renderPoint{operandNumber(operands, 2), operandNumber(operands, 3)})
+		}
+	case "y":
+		if len(operands) >= 4 {
+			end := renderPoint{operandNumber(operands, 2), operandNumber(operands, 3)}
+			r.curveTo(renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)}, end, end)
+		}
+	case "h":
+		if n := len(r.path); n > 0 {
+			r.path[n-1].closed = true
+			r.current = r.start
+		}
+	case "re":
+		if len(operands) >= 4 {
+			x, y := operandNumber(operands, 0), operandNumber(operands, 1)
+			w, h := operandNumber(operands, 2), operandNumber(operands, 3)
+			r.path = append(r.path, renderSubpath{points: []renderPoint{
+				st.ctm.apply(x, y), st.ctm.apply(x+w, y), st.ctm.apply(x+w, y+h), st.ctm.apply(x, y+h),
+			}, closed: true})
+			r.current, r.start = renderPoint{x, y}, renderPoint{x, y}
+		}
+
+	case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n":
+		if op == "s" || op == "b" || op == "b*" {
+			if n := len(r.path); n > 0 {
+				r.path[n-1].closed = true
+			}
+		}
+		evenOdd := strings.HasSuffix(op, "*")
+		if op != "S" && op != "s" && op != "n" {
+			r.fill(subpathPolygons(r.path), evenOdd, r.fillPaint())
+		}
+		if op == "S" || op == "s" || op == "B" || op == "B*" || op == "b" || op == "b*" {
+			r.stroke(r.path)
+		}
+		if r.clipMode != 0 {
+			r.intersectClip(subpathPolygons(r.path), r.clipMode == 2)
+			r.clipMode = 0
+		}
+		r.path = nil
+	case "W":
+		r.clipMode = 1
+	case "W*":
+		r.clipMode = 2
+
+	case "CS", "cs":
+		space := r.colorSpace(operandObject(operands, 0), resources)
+		if op == "CS" {
+			st.strokeSpace, st.strokeColor, st.strokeShading = space, space.initialColor(), nil
+		} else {
+			st.fillSpace, st.fillColor, st.fillShading = space, space.initialColor(), nil
+		}
+	case "SC", "SCN", "sc", "scn":
+		comps := []float64{}
+		for _, operand := range operands {
+			if v, ok := operand.(float64); ok {
+				comps = append(comps, v)
+			}
+		}
+		var shading *renderShading
+		if name := operandName(operands, len(operands)-1); name != "" {
+			shading = r.patternShading(name, resources)
+		}
+		if op == "SC" || op == "SCN" {
+			st.strokeColor, st.strokeShading = comps, shading
+		} else {
+			st.fillColor, st.fillShading = comps, shading
+		}
+	case "G", "g":
+		r.setDeviceColor(op == "G", deviceGraySpace, operands)
+	case "RG", "rg":
+		r.setDeviceColor(op == "RG", deviceRGBSpace, operands)
+	case "K", "k":
+		r.setDeviceColor(op == "K", deviceCMYKSpace, operands)
+
+	case "sh":
+		r.paintShading(operandName(operands, 0), resources)
+	case "Do":
+		r.drawXObject(operandName(operands, 0), resources)
+
+	case "BT":
+		r.textMatrix, r.lineMatrix = identityMatrix, identityMatrix
+		r.textClip = nil
+	case "ET":
+		if st.textMode >= 4 && len(r.textClip) > 0 {
+			r.intersectClip(r.textClip, false)
+		}
+		r.textClip = nil
+	case "Tc":
+		st.charSpacing = operandNumber(operands, 0)
+	case "Tw":
+		st.wordSpacing = operandNumber(operands, 0)
+	case "Tz":
+		st.hScale = operandNumber(operands, 0) / 100
+	case "TL":
+		st.leading = operandNumber(operands, 0)
+	case "Ts":
+		st.rise = operandNumber(operands, 0)
+	case "Tr":
+		st.textMode = int(operandNumber(operands, 0))
+	case "Tf":
+		if len(operands) >= 2 {
+			st.font = r.loadFont(operandName(operands, 0), resources)
+			st.fontSize = operandNumber(operands, 1)
+		}
+	case "Td", "TD":
+		if len(operands) >= 2 {
+			tx, ty := operandNumber(operands, 0), operandNumber(operands, 1)
+			if op == "TD" {
+				st.leading = -ty
+			}
+			r.lineMatrix = pdfMatrix{1, 0, 0, 1, tx, ty}.multiply(r.lineMatrix)
+			r.textMatrix = r.lineMatrix
+		}
+	case "Tm":
+		if m, ok := operandMatrix(operands); ok {
+			r.textMatrix, r.lineMatrix = m, m
+		}
+	case "T*":
+		r.nextLine()
+	case "Tj":
+		if s, ok := operandObject(operands, 0).([]byte); ok {
+			r.showText([]interface{}{s}, resources)
+		}
+	case "'":
+		r.nextLine()
+		if s, ok := operandObject(operands, 0).([]byte); ok {
+			r.showText([]interface{}{s}, resources)
+		}
+	case "\"":
+		if len(operands) >= 3 {
+			st.wordSpacing = operandNumber(operands, 0)
+			st.charSpacing = operandNumber(operands, 1)
+			r.nextLine()
+			if s, ok := operands[2].([]byte); ok {
+				r.showText([]interface{}{s}, resources)
+			}
+		}
+	case "TJ":
+		if items, ok := operandObject(operands, 0).([]interface{}); ok {
+			r.showText(items, resources)
+		}
+	}
+}
+
+func (r *pageRenderer) lineTo(p renderPoint) {
+	if len(r.path) == 0 {
+		r.path = append(r.path, renderSubpath{points: []renderPoint{r.state.ctm.apply(r.current.x, r.current.y)}})
+	}
+	sub := &r.path[len(r.path)-1]
+	sub.points = append(sub.points, r.state.ctm.apply(p.x, p.y))
+	r.current = p
+}
+
+func (r *pageRenderer) curveTo(c1, c2, end renderPoint) {
+	if len(r.path) == 0 {
+		r.path = append(r.path, renderSubpath{points: []renderPoint{r.state.ctm.apply(r.current.x, r.current.y)}})
+	}
+	m := r.state.ctm
+	sub := &r.path[len(r.path)-1]
+	sub.points = flattenCubic(sub.points, m.apply(r.current.x, r.current.y), m.apply(c1.x, c1.y), m.apply(c2.x, c2.y), m.apply(end.x, end.y))
+	r.current = end
+}
+
+func (r *pageRenderer) nextLine() {
+	r.lineMatrix = pdfMatrix{1, 0, 0, 1, 0, -r.state.leading}.multiply(r.lineMatrix)
+	r.textMatrix = r.lineMatrix
+}
+
+// flattenCubic appends line segments approximating a Bézier curve in device space
+func flattenCubic(points []renderPoint, p0, p1, p2, p3 renderPoint) []renderPoint {
+	length := math.Hypot(p1.x-p0.x, p1.y-p0.y) + math.Hypot(p2.x-p1.x, p2.y-p1.y) + math.Hypot(p3.x-p2.x, p3.y-p2.y)
+	steps := int(math.Min(math.Max(math.Ceil(math.Sqrt(length)*1.5), 1), 100))
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		points = append(points, renderPoint{
+			a*p0.x + b*p1.x + c*p2.x + d*p3.x,
+			a*p0.y + b*p1.y + c*p2.y + d*p3.y,
+		})
+	}
+	return points
+}
+
+// flattenQuad appends line segments approximating a quadratic curve in device space
+func flattenQuad(points []renderPoint, p0, p1, p2 renderPoint) []renderPoint {
+	length := math.Hypot(p1.x-p0.x, p1.y-p0.y) + math.Hypot(p2.x-p1.x, p2.y-p1.y)
+	steps := int(math.Min(math.Max(math.Ceil(math.Sqrt(length)*1.5), 1), 100))
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		u := 1 - t
+		a, b, c := u*u, 2*u*t, t*t
+		points = append(points, renderPoint{a*p0.x + b*p1.x + c*p2.x, a*p0.y + b*p1.y + c*p2.y})
+	}
+	return points
+}
+
+func subpathPolygons(path []renderSubpath) [][]renderPoint {
+	polygons := make([][]renderPoint, 0, len(path))
+	for _, sub := range path {
+		if len(sub.points) > 2 {
+			polygons = append(polygons, sub.points)
+		}
+	}
+	return polygons
+}
+
+// renderPaint is either a solid colour or a shading evaluated per pixel
+type renderPaint struct {
+	color   color.NRGBA
+	shading *renderShading
+}
+
+func (r *pageRenderer) fillPaint() renderPaint {
+	st := &r.state
+	if st.fillShading != nil {
+		return renderPaint{shading: st.fillShading, color: color.NRGBA{A: alphaByte(st.fillAlpha)}}
+	}
+	return renderPaint{color: st.fillSpace.toNRGBA(st.fillColor, st.fillAlpha)}
+}
+
+func (r *pageRenderer) strokePaint() renderPaint {
+	st := &r.state
+	if st.strokeShading != nil {
+		return renderPaint{shading: st.strokeShading, color: color.NRGBA{A: alphaByte(st.strokeAlpha)}}
+	}
+	return renderPaint{color: st.strokeSpace.toNRGBA(st.strokeColor, st.strokeAlpha)}
+}
+
+func alphaByte(alpha float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, alpha)) * 255))
+}
+
+// rasterize - Compute the coverage of polygons, clipped to the page and the current clip path
+func (r *pageRenderer) rasterize(polygons [][]renderPoint, evenOdd bool, clip *image.Alpha) *image.Alpha {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, p := range polygon {
+			minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+			maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+		}
+	}
+	if math.IsInf(minX, 0) || math.IsNaN(minX+minY+maxX+maxY) {
+		return nil
+	}
+	rect := image.Rect(int(math.Floor(math.Max(minX, -1))), int(math.Floor(math.Max(minY, -1))),
+		int(math.Ceil(math.Min(maxX, float64(r.bounds.Max.X+1)))), int(math.Ceil(math.Min(maxY, float64(r.bounds.Max.Y+1)))))
+	rect = rect.Intersect(r.bounds)
+	if clip != nil {
+		rect = rect.Intersect(clip.Rect)
+	}
+	if rect.Empty() {
+		return nil
+	}
+
+	var mask *image.Alpha
+	if evenOdd && len(polygons) > 1 {
+		// The rasterizer only knows the non-zero rule, so even-odd coverage is combined per subpath
+		mask = image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+		for _, polygon := range polygons {
+			part := rasterizePolygons([][]renderPoint{polygon}, rect)
+			for i, v := range part.Pix {
+				a, b := int(mask.Pix[i]), int(v)
+				mask.Pix[i] = uint8(a + b - 2*a*b/255)
+			}
+		}
+	} else {
+		mask = rasterizePolygons(polygons, rect)
+	}
+	mask.Rect = rect
+
+	if clip != nil {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			row := mask.Pix[(y-rect.Min.Y)*mask.Stride:]
+			clipRow := clip.Pix[clip.PixOffset(rect.Min.X, y):]
+			for x := 0; x < rect.Dx(); x++ {
+				row[x] = uint8(int(row[x]) * int(clipRow[x]) / 255)
+			}
+		}
+	}
+	return mask
+}
+
+func rasterizePolygons(polygons [][]renderPoint, rect image.Rectangle) *image.Alpha {
+	z := vector.NewRasterizer(rect.Dx(), rect.Dy())
+	z.DrawOp = draw.Src
+	ox, oy := float64(rect.Min.X), float64(rect.Min.Y)
+	for _, polygon := range polygons {
+		if len(polygon) < 2 {
+			continue
+		}
+		z.MoveTo(float32(polygon[0].x-ox), float32(polygon[0].y-oy))
+		for _, p := range polygon[1:] {
+			z.LineTo(float32(p.x-ox), float32(p.y-oy))
+		}
+		z.ClosePath()
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	z.Draw(mask, mask.Rect, image.Opaque, image.Point{})
+	return mask
+}
+
+// fill - Paint polygons with a colour or shading
+func (r *pageRenderer) fill(polygons [][]renderPoint, evenOdd bool, paint renderPaint) {
+	if r.text != nil || len(polygons) == 0 || paint.color.A == 0 && paint.shading == nil {
+		return
+	}
+	mask := r.rasterize(polygons, evenOdd, r.state.clip)
+	if mask == nil {
+		return
+	}
+	r.composite(mask, paint)
+}
+
+func (r *pageRenderer) composite(mask *image.Alpha, paint renderPaint) {
+	if paint.shading == nil {
+		draw.DrawMask(r.canvas, mask.Rect, image.NewUniform(paint.color), image.Point{}, mask, mask.Rect.Min, draw.Over)
+		return
+	}
+	shaded := image.NewNRGBA(mask.Rect)
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			if mask.Pix[mask.PixOffset(x, y)] == 0 {
+				continue
+			}
+			if c, ok := paint.shading.colorAt(float64(x)+0.5, float64(y)+0.5); ok {
+				c.A = uint8(int(c.A) * int(paint.color.A) / 255)
+				shaded.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	draw.DrawMask(r.canvas, mask.Rect, shaded, mask.Rect.Min, mask, mask.Rect.Min, draw.Over)
+}
+
+// stroke - Paint the outline of the current path
+func (r *pageRenderer) stroke(path []renderSubpath) {
+	if r.text != nil {
+		return
+	}
+	st := &r.state
+	scale := st.ctm.scale()
+	width := math.Max(st.lineWidth*scale, 1)
+	var dash []float64
+	for _, d := range st.dash {
+		dash = append(dash, d*scale)
+	}
+	r.fill(strokePolygons(path, width, st.lineCap, dash, st.dashPhase*scale), false, r.strokePaint())
+}
+
+// strokePolygons outlines each segment with a quad, adding round joins and the requested caps
+func strokePolygons(path []renderSubpath, width float64, lineCap int, dash []float64, phase float64) [][]renderPoint {
+	half := width / 2
+	polygons := [][]renderPoint{}
+	for _, sub := range path {
+		points := sub.points
+		if sub.closed && len(points) > 1 {
+			points = append(append([]renderPoint{}, points...), points[0])
+		}
+		runs := [][]renderPoint{points}
+		if len(dash) > 0 {
+			runs = dashPolyline(points, dash, phase)
+		}
+		for _, run := range runs {
+			if len(run) == 1 || len(run) == 2 && run[0] == run[1] {
+				if lineCap == 1 {
+					polygons = append(polygons, circlePolygon(run[0], half))
+				}
+				continue
+			}
+			open := !sub.closed || len(dash) > 0
+			for i := 0; i+1 < len(run); i++ {
+				p, q := run[i], run[i+1]
+				dx, dy := q.x-p.x, q.y-p.y
+				length := math.Hypot(dx, dy)
+				if length == 0 {
+					continue
+				}
+				ux, uy := dx/length, dy/length
+				if open && lineCap == 2 {
+					if i == 0 {
+						p = renderPoint{p.x - ux*half, p.y - uy*half}
+					}
+					if i+2 == len(run) {
+						q = renderPoint{q.x + ux*half, q.y + uy*half}
+					}
+				}
+				nx, ny := -uy*half, ux*half
+				polygons = append(polygons, orientPolygon([]renderPoint{
+					{p.x + nx, p.y + ny}, {q.x + nx, q.y + ny}, {q.x - nx, q.y - ny}, {p.x - nx, p.y - ny},
+				}))
+				joint := i+1 < len(run)-1 || !open
+				if width > 1.5 && joint {
+					polygons = append(polygons, circlePolygon(q, half))
+				}
+			}
+			if open && lineCap == 1 {
+				polygons = append(polygons, circlePolygon(run[0], half), circlePolygon(run[len(run)-1], half))
+			}
+		}
+	}
+	return polygons
+}
+
+// dashPolyline splits a polyline into the visible runs of a dash pattern
+func dashPolyline(points []renderPoint, dash []float64, phase float64) [][]renderPoint {
+	total := 0.0
+	for _, d := range dash {
+		total += d
+	}
+	if total <= 0 {
+		return [][]renderPoint{points}
+	}
+	index, remaining, on := 0, dash[0], true
+	for phase = math.Mod(phase, total); phase > 0; {
+		if phase < remaining {
+			remaining -= phase
+			break
+		}
+		phase -= remaining
+		index = (index + 1) % len(dash)
+		remaining, on = dash[index], !on
+	}
+
+	runs := [][]renderPoint{}
+	var run []renderPoint
+	if on {
+		run = []renderPoint{points[0]}
+	}
+	for i := 0; i+1 < len(points); i++ {
+		p, q := points[i], points[i+1]
+		length := math.Hypot(q.x-p.x, q.y-p.y)
+		for pos := 0.0; length-pos > 1e-9; {
+			step := math.Min(remaining, length-pos)
+			pos += step
+			remaining -= step
+			t := pos / length
+			point := renderPoint{p.x + (q.x-p.x)*t, p.y + (q.y-p.y)*t}
+			if on {
+				run = append(run, point)
+			}
+			if remaining <= 1e-9 {
+				if on && len(run) > 1 {
+					runs = append(runs, run)
+				}
+				run = nil
+				index = (index + 1) % len(dash)
+				remaining, on = dash[index], !on
+				if on {
+					run = []renderPoint{point}
+				}
+			}
+		}
+	}
+	if on && len(run) > 1 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// orientPolygon reverses a polygon when needed so overlapping stroke pieces accumulate instead of cancelling
+func orientPolygon(polygon []renderPoint) []renderPoint {
+	area := 0.0
+	for i := range polygon {
+		p, q := polygon[i], polygon[(i+1)%len(polygon)]
+		area += p.x*q.y - q.x*p.y
+	}
+	if area < 0 {
+		for i, j := 0, len(polygon)-1; i < j; i, j = i+1, j-1 {
+			polygon[i], polygon[j] = polygon[j], polygon[i]
+		}
+	}
+	return polygon
+}
+
+func circlePolygon(center renderPoint, radius float64) []renderPoint {
+	steps := int(math.Min(math.Max(radius*2, 8), 48))
+	polygon := make([]renderPoint, steps)
+	for i := range polygon {
+		angle := 2 * math.Pi * float64(i) / float64(steps)
+		polygon[i] = renderPoint{center.x + radius*math.Cos(angle), center.y + radius*math.Sin(angle)}
+	}
+	return polygon
+}
+
+// intersectClip - Narrow the clip path, which always covers the whole page once set
+func (r *pageRenderer) intersectClip(polygons [][]renderPoint, evenOdd bool) {
+	clip := image.NewAlpha(r.bounds)
+	if mask := r.rasterize(polygons, evenOdd, r.state.clip); mask != nil {
+		for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+			copy(clip.Pix[clip.PixOffset(mask.Rect.Min.X, y):], mask.Pix[(y-mask.Rect.Min.Y)*mask.Stride:][:mask.Rect.Dx()])
+		}
+	}
+	r.state.clip = clip
+}
+
+func (r *pageRenderer) setDeviceColor(stroke bool, space *renderColorSpace, operands []interface{}) {
+	comps := make([]float64, space.components)
+	for i := range comps {
+		comps[i] = operandNumber(operands, i)
+	}
+	if stroke {
+		r.state.strokeSpace, r.state.strokeColor, r.state.strokeShading = space, comps, nil
+	} else {
+		r.state.fillSpace, r.state.fillColor, r.state.fillShading = space, comps, nil
+	}
+}
+
+// applyExtGState - Apply the graphics state parameters the renderer supports
+func (r *pageRenderer) applyExtGState(name string, resources types.Dict) {
+	d := r.resourceDict(resources, "ExtGState", name)
+	if d == nil {
+		return
+	}
+	st := &r.state
+	if v, ok := r.number(d["LW"]); ok {
+		st.lineWidth = v
+	}
+	if v, ok := r.number(d["LC"]); ok {
+		st.lineCap = int(v)
+	}
+	if v, ok := r.number(d["ca"]); ok {
+		st.fillAlpha = v
+	}
+	if v, ok := r.number(d["CA"]); ok {
+		st.strokeAlpha = v
+	}
+	if a, err := r.ctx.DereferenceArray(d["D"]); err == nil && len(a) == 2 {
+		dash, _ := r.ctx.DereferenceArray(a[0])
+		st.dash = st.dash[:0:0]
+		for _, o := range dash {
+			if v, ok := r.number(o); ok {
+				st.dash = append(st.dash, v)
+			}
+		}
+		st.dashPhase, _ = r.number(a[1])
+	}
+	if a, err := r.ctx.DereferenceArray(d["Font"]); err == nil && len(a) == 2 {
+		if fd, err := r.ctx.DereferenceDict(a[0]); err == nil && fd != nil {
+			st.font = r.fontFromDict(fd, a[0])
+			st.fontSize, _ = r.number(a[1])
+		}
+	}
+}
+
+func (r *pageRenderer) number(o types.Object) (float64, bool) {
+	if o == nil {
+		return 0, false
+	}
+	v, err := r.ctx.DereferenceNumber(o)
+	return v, err == nil
+}
+
+// resourceDict - Look up a named resource of the given category as a dictionary
+func (r *pageRenderer) resourceDict(resources types.Dict, category, name string) types.Dict {
+	o := r.resource(resources, category, name)
+	if o == nil {
+		return nil
+	}
+	switch o := o.(type) {
+	case types.Dict:
+		return o
+	case types.StreamDict:
+		return o.Dict
+	}
+	return nil
+}
+
+func (r *pageRenderer) resource(resources types.Dict, category, name string) types.Object {
+	if resources == nil {
+		return nil
+	}
+	entries, err := r.ctx.DereferenceDict(resources[category])
+	if err != nil || entries == nil {
+		return nil
+	}
+	o, err := r.ctx.Dereference(entries[name])
+	if err != nil {
+		return nil
+	}
+	return o
+}
+
+// drawXObject - Paint an image or form XObject
+func (r *pageRenderer) drawXObject(name string, resources types.Dict) {
+	entries, err := r.ctx.DereferenceDict(resources["XObject"])
+	if err != nil || entries == nil {
+		return
+	}
+	ref := entries[name]
+	sd, _, err := r.ctx.DereferenceStreamDict(ref)
+	if err != nil || sd == nil {
+		return
+	}
+
+	switch subtype := sd.NameEntry("Subtype"); {
+	case subtype != nil && *subtype == "Image":
+		if r.text != nil {
+			return
+		}
+		objNr := -1
+		if indRef, ok := ref.(types.IndirectRef); ok {
+			objNr = indRef.ObjectNumber.Value()
+		}
+		img, cached := r.images[objNr]
+		if !cached || objNr < 0 {
+			img = r.decodeImage(sd)
+			if objNr >= 0 {
+				r.images[objNr] = img
+			}
+		}
+		r.drawImage(img, sd)
+	case subtype != nil && *subtype == "Form":
+		r.drawForm(sd, resources)
+	}
+}
+
+// drawForm - Execute a form XObject inside its bounding box
+func (r *pageRenderer) drawForm(sd *types.StreamDict, resources types.Dict) {
+	if r.depth >= maxRenderDepth {
+		return
+	}
+	if err := sd.Decode(); err != nil {
+		return
+	}
+	if res, err := r.ctx.DereferenceDict(sd.Dict["Resources"]); err == nil && res != nil {
+		resources = res
+	}
+
+	saved, savedStack, savedBase, savedPath := r.state, r.stack, r.baseCTM, r.path
+	r.stack, r.path = nil, nil
+	if a, err := r.ctx.DereferenceArray(sd.Dict["Matrix"]); err == nil && len(a) == 6 {
+		if m, ok := r.matrixFromArray(a); ok {
+			r.state.ctm = m.multiply(r.state.ctm)
+		}
+	}
+	if a, err := r.ctx.DereferenceArray(sd.Dict["BBox"]); err == nil && len(a) == 4 {
+		var v [4]float64
+		for i := range v {
+			v[i], _ = r.number(a[i])
+		}
+		m := r.state.ctm
+		r.intersectClip([][]renderPoint{{m.apply(v[0], v[1]), m.apply(v[2], v[1]), m.apply(v[2], v[3]), m.apply(v[0], v[3])}}, false)
+	}
+	r.baseCTM = r.state.ctm
+
+	r.depth++
+	r.run(sd.Content, resources)
+	r.depth--
+
+	r.state, r.stack, r.baseCTM, r.path = saved, savedStack, savedBase, savedPath
+}
+
+func (r *pageRenderer) matrixFromArray(a types.Array) (pdfMatrix, bool) {
+	var m pdfMatrix
+	for i := range m {
+		v, ok := r.number(a[i])
+		if !ok {
+			return identityMatrix, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+// drawAnnotations - Paint the normal appearance of visible annotations such as filled form fields
+func (r *pageRenderer) drawAnnotations(pageDict types.Dict) {
+	annots, err := r.ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return
+	}
+	for _, o := range annots {
+		d, err := r.ctx.DereferenceDict(o)
+		if err != nil || d == nil {
+			continue
+		}
+		if subtype := d.NameEntry("Subtype"); subtype != nil && *subtype == "Popup" {
+			continue
+		}
+		// Hidden (bit 2) and NoView (bit 6) annotations are not displayed
+		if flags, ok := r.number(d["F"]); ok && int(flags)&(2|32) != 0 {
+			continue
+		}
+		ap, err := r.ctx.DereferenceDict(d["AP"])
+		if err != nil || ap == nil {
+			continue
+		}
+		normal, err := r.ctx.Dereference(ap["N"])
+		if err != nil || normal == nil {
+			continue
+		}
+		var sd *types.StreamDict
+		switch n := normal.(type) {
+		case types.StreamDict:
+			sd = &n
+		case types.Dict:
+			if state := d.NameEntry("AS"); state != nil {
+				sd, _, _ = r.ctx.DereferenceStreamDict(n[*state])
+			}
+		}
+		rect, err := r.ctx.DereferenceArray(d["Rect"])
+		if sd == nil || err != nil || len(rect) != 4 {
+			continue
+		}
+		r.drawAppearance(sd, rect)
+	}
+}
+
+// drawAppearance maps the transformed form bounding box onto the annotation rectangle
+func (r *pageRenderer) drawAppearance(sd *types.StreamDict, rect types.Array) {
+	var v [4]float64
+	for i := range v {
+		v[i], _ = r.number(rect[i])
+	}
+	bbox, err := r.ctx.DereferenceArray(sd.Dict["BBox"])
+	if err != nil || len(bbox) != 4 {
+		return
+	}
+	var b [4]float64
+	for i := range b {
+		b[i], _ = r.number(bbox[i])
+	}
+	m := identityMatrix
+	if a, err := r.ctx.DereferenceArray(sd.Dict["Matrix"]); err == nil && len(a) == 6 {
+		m, _ = r.matrixFromArray(a)
+	}
+	corners := []renderPoint{m.apply(b[0], b[1]), m.apply(b[2], b[1]), m.apply(b[2], b[3]), m.apply(b[0], b[3])}
+	minX, minY, maxX, maxY := corners[0].x, corners[0].y, corners[0].x, corners[0].y
+	for _, p := range corners[1:] {
+		minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+		maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+	}
+	if maxX-minX <= 0 || maxY-minY <= 0 {
+		return
+	}
+	llx, lly := math.Min(v[0], v[2]), math.Min(v[1], v[3])
+	sx, sy := (math.Abs(v[2]-v[0]))/(maxX-minX), (math.Abs(v[3]-v[1]))/(maxY-minY)
+	fit := pdfMatrix{sx, 0, 0, sy, llx - minX*sx, lly - minY*sy}
+
+	saved := r.state
+	r.state.ctm = fit.multiply(r.baseCTM)
+	r.drawForm(sd, nil)
+	r.state = saved
+}
+
+// decodeImage - Decode an image XObject, returning an *image.Alpha for stencil masks
+func (r *pageRenderer) decodeImage(sd *types.StreamDict) (img image.Image) {
+	// pdfcpu panics on some malformed image dictionaries, treat them as undecodable
+	defer func() {
+		if rec := recover(); rec != nil {
+			img = nil
+		}
+	}()
+	if sd.IntEntry("Width") == nil || sd.IntEntry("Height") == nil {
+		return nil
+	}
+
+	// Work on a copy, extraction replaces the stream content and may add entries
+	cp := *sd
+	cp.Dict = sd.Dict.Clone().(types.Dict)
+	cp.Content = nil
+
+	stencil := false
+	if im := cp.BooleanEntry("ImageMask"); im != nil && *im {
+		stencil = true
+		cp.Dict["ColorSpace"] = types.Name("DeviceGray")
+		cp.Dict["BitsPerComponent"] = types.Integer(1)
+	}
+
+	extracted, err := pdfcpu.ExtractImage(r.ctx, &cp, false, "", 0, false)
+	if err != nil || extracted == nil || extracted.Reader == nil {
+		return nil
+	}
+	switch extracted.FileType {
+	case "png":
+		img, err = png.Decode(extracted.Reader)
+	case "jpg":
+		img, err = jpeg.Decode(extracted.Reader)
+	case "tif":
+		img, err = tiff.Decode(extracted.Reader)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	if !stencil {
+		// pdfcpu ignores an inverted decode array on soft masks
+		if nrgba, ok := img.(*image.NRGBA); ok && r.invertedSoftMask(sd) {
+			for i := 3; i < len(nrgba.Pix); i += 4 {
+				nrgba.Pix[i] = 255 - nrgba.Pix[i]
+			}
+		}
+		return img
+	}
+
+	// Sample value 0 paints unless the decode array is inverted
+	inverted := false
+	if decode, err := r.ctx.DereferenceArray(sd.Dict["Decode"]); err == nil && len(decode) == 2 {
+		first, _ := r.number(decode[0])
+		inverted = first == 1
+	}
+	bounds := img.Bounds()
+	mask := image.NewAlpha(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gray := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+			if (gray < 128) != inverted {
+				mask.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+		}
+	}
+	return mask
+}
+
+// invertedSoftMask - Report whether the soft mask of an image uses the decode array [1 0]
+func (r *pageRenderer) invertedSoftMask(sd *types.StreamDict) bool {
+	if _, ok := sd.Dict["SMask"]; !ok {
+		return false
+	}
+	smask, _, err := r.ctx.DereferenceStreamDict(sd.Dict["SMask"])
+	if err != nil || smask == nil {
+		return false
+	}
+	decode, err := r.ctx.DereferenceArray(smask.Dict["Decode"])
+	if err != nil || len(decode) != 2 {
+		return false
+	}
+	first, _ := r.number(decode[0])
+	return first == 1
+}
+
+// drawImage - Map an image onto the unit square of the current transformation matrix
+func (r *pageRenderer) drawImage(img image.Image, sd *types.StreamDict) {
+	m := r.state.ctm
+	if img == nil {
+		// Undecodable images (JPEG 2000, exotic colour spaces) are shown as a neutral placeholder
+		placeholder := [][]renderPoint{{m.apply(0, 0), m.apply(1, 0), m.apply(1, 1), m.apply(0, 1)}}
+		r.fill(placeholder, false, renderPaint{color: color.NRGBA{R: 221, G: 221, B: 221, A: 255}})
+		return
+	}
+	if mask, ok := img.(*image.Alpha); ok {
+		fill := r.fillPaint().color
+		colored := image.NewNRGBA(mask.Rect)
+		for i, a := range mask.Pix {
+			colored.Pix[i*4], colored.Pix[i*4+1], colored.Pix[i*4+2] = fill.R, fill.G, fill.B
+			colored.Pix[i*4+3] = uint8(int(a) * int(fill.A) / 255)
+		}
+		img = colored
+	}
+
+	bounds := img.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	if w == 0 || h == 0 {
+		return
+	}
+	// Source pixel (sx, sy) lands on unit square point (sx/w, 1-sy/h)
+	s2d := [6]float64{
+		m[0] / w, -m[2] / h, m[2] + m[4] - m[0]/w*float64(bounds.Min.X) + m[2]/h*float64(bounds.Min.Y),
+		m[1] / w, -m[3] / h, m[3] + m[5] - m[1]/w*float64(bounds.Min.X) + m[3]/h*float64(bounds.Min.Y),
+	}
+	options := &draw.Options{}
+	if r.state.clip != nil {
+		options.DstMask = r.state.clip
+	}
+	var interpolator draw.Transformer = draw.ApproxBiLinear
+	if math.Hypot(m[0], m[1]) < w/2 || math.Hypot(m[2], m[3]) < h/2 {
+		interpolator = draw.BiLinear
+	}
+	interpolator.Transform(r.canvas, s2d, img, bounds, draw.Over, options)
+}
+
+// inlineImageKeys expands the abbreviations allowed in inline image dictionaries
+var inlineImageKeys = map[string]string{
+	"BPC": "BitsPerComponent", "CS": "ColorSpace", "D": "Decode", "DP": "DecodeParms",
+	"F": "Filter", "H": "Height", "IM": "ImageMask", "I": "Interpolate", "W": "Width",
+}
+
+var inlineImageNames = map[string]string{
+	"G": "DeviceGray", "RGB": "DeviceRGB", "CMYK": "DeviceCMYK", "I": "Indexed",
+	"AHx": "ASCIIHexDecode", "A85": "ASCII85Decode", "LZW": "LZWDecode", "Fl": "FlateDecode",
+	"RL": "RunLengthDecode", "CCF": "CCITTFaxDecode", "DCT": "DCTDecode",
+}
+
+// inlineImage - Read a BI ... ID ... EI sequence and paint it
+func (r *pageRenderer) inlineImage(lexer *contentLexer, resources types.Dict) {
+	d := types.Dict{}
+	var key string
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return
+		}
+		if op, ok := token.(contentOperator); ok {
+			if op != "ID" {
+				return
+			}
+			break
+		}
+		if key == "" {
+			if name, ok := token.(contentName); ok {
+				key = string(name)
+				if full, ok := inlineImageKeys[key]; ok {
+					key = full
+				}
+			}
+			continue
+		}
+		d[key] = inlineImageObject(token)
+		key = ""
+	}
+	data := lexer.inlineImageData()
+
+	// Colour spaces may name a resource
+	if name, ok := d["ColorSpace"].(types.Name); ok {
+		if cs := r.resource(resources, "ColorSpace", string(name)); cs != nil {
+			d["ColorSpace"] = cs
+		}
+	}
+
+	var pipeline []types.PDFFilter
+	var parms []types.Dict
+	switch p := d["DecodeParms"].(type) {
+	case types.Dict:
+		parms = []types.Dict{p}
+	case types.Array:
+		for _, o := range p {
+			pd, _ := o.(types.Dict)
+			parms = append(parms, pd)
+		}
+	}
+	filters := []types.Object{}
+	switch f := d["Filter"].(type) {
+	case types.Name:
+		filters = append(filters, f)
+	case types.Array:
+		filters = f
+	}
+	for i, f := range filters {
+		name, _ := f.(types.Name)
+		filter := types.PDFFilter{Name: string(name)}
+		if i < len(parms) {
+			filter.DecodeParms = parms[i]
+		}
+		pipeline = append(pipeline, filter)
+	}
+	d["Filter"] = nil
+	delete(d, "Filter")
+	delete(d, "DecodeParms")
+	if len(pipeline) > 0 {
+		names := types.Array{}
+		for _, f := range pipeline {
+			names = append(names, types.Name(f.Name))
+		}
+		d["Filter"] = names
+	}
+
+	length := int64(len(data))
+	sd := types.NewStreamDict(d, 0, &length, nil, pipeline)
+	sd.Raw = data
+	r.drawImage(r.decodeImage(&sd), &sd)
+}
+
+func inlineImageObject(token interface{}) types.Object {
+	switch v := token.(type) {
+	case contentName:
+		if full, ok := inlineImageNames[string(v)]; ok {
+			return types.Name(full)
+		}
+		return types.Name(v)
+	case float64:
+		if v == math.Trunc(v) {
+			return types.Integer(int(v))
+		}
+		return types.Float(v)
+	case bool:
+		return types.Boolean(v)
+	case []byte:
+		return types.StringLiteral(string(v))
+	case []interface{}:
+		a := types.Array{}
+		for _, item := range v {
+			a = append(a, inlineImageObject(item))
+		}
+		return a
+	case map[string]interface{}:
+		d := types.Dict{}
+		for k, item := range v {
+			d[k] = inlineImageObject(item)
+		}
+		return d
+	}
+	return nil
+}
+
+func operandNumber(operands []interface{}, i int) float64 {
+	if i < len(operands) {
+		if v, ok := operands[i].(float64); ok {
+			return v
+		}
+	}
+	return 0
+}
+
+func operandName(operands []interface{}, i int) string {
+	if i < len(operands) {
+		if v, ok := operands[i].(contentName); ok {
+			return string(v)
+		}
+	}
+	return ""
+}
+
+func operandObject(operands []interface{}, i int) interface{} {
+	if i < len(operands) {
+		return operands[i]
+	}
+	return nil
+}
+
+func operandMatrix(operands []interface{}) (pdfMatrix, bool) {
+	if len(operands) < 6 {
+		return identityMatrix, false
+	}
+	var m pdfMatrix
+	for i := range m {
+		v, ok := operands[len(operands)-6+i].(float64)
+		if !ok {
+			return identityMatrix, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+func numberArray(o interface{}) []float64 {
+	items, _ := o.([]interface{})
+	values := []float64{}
+	for _, item := range items {
+		if v, ok := item.(float64); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// contentOperator and contentName distinguish bare keywords from /Names in content streams
+type contentOperator string
+
+type contentName string
+
+// contentLexer tokenizes content streams; strings come back as []byte, arrays and dictionaries as Go values
+type contentLexer struct {
+	data []byte
+	pos  int
+}
+
+var errContentEnd = errors.New("end of content")
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *contentLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFWhitespace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *contentLexer) regularToken() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// next returns the next operand or operator
+func (l *contentLexer) next() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errContentEnd
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		name := l.regularToken()
+		if strings.Contains(name, "#") {
+			name = decodeNameEscapes(name)
+		}
+		return contentName(name), nil
+	case c == '(':
+		l.pos++
+		return l.literalString(), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		d := map[string]interface{}{}
+		for {
+			l.skipSpace()
+			if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+				l.pos += 2
+				return d, nil
+			}
+			key, err := l.next()
+			if err != nil {
+				return d, nil
+			}
+			value, err := l.next()
+			if err != nil {
+				return d, nil
+			}
+			if name, ok := key.(contentName); ok {
+				d[string(name)] = value
+			}
+		}
+	case c == '<':
+		l.pos++
+		return l.hexString(), nil
+	case c == '[':
+		l.pos++
+		items := []interface{}{}
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return items, nil
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return items, nil
+			}
+			item, err := l.next()
+			if err != nil {
+				return items, nil
+			}
+			items = append(items, item)
+		}
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return contentOperator(string(c)), nil
+	}
+
+	token := l.regularToken()
+	if token == "" {
+		l.pos++
+		return l.next()
+	}
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if first := token[0]; first == '+' || first == '-' || first == '.' || first >= '0' && first <= '9' {
+		if v, err := strconv.ParseFloat(token, 64); err == nil {
+			return v, nil
+		}
+		// Tolerate malformed numbers such as "--1" or "1.2.3" written by some producers
+		cleaned := strings.TrimLeft(token, "+-")
+		if i := strings.Index(cleaned, "."); i >= 0 {
+			if j := strings.Index(cleaned[i+1:], "."); j >= 0 {
+				cleaned = cleaned[:i+1+j]
+			}
+		}
+		v, _ := strconv.ParseFloat(cleaned, 64)
+		if strings.HasPrefix(token, "-") {
+			v = -v
+		}
+		return v, nil
+	}
+	return contentOperator(token), nil
+}
+
+func decodeNameEscapes(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if v, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+func (l *contentLexer) literalString() []byte {
+	out := []byte{}
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for n := 0; n < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; n++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (l *contentLexer) hexString() []byte {
+	out := []byte{}
+	high, half := byte(0), false
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		var v byte
+		switch {
+		case c == '>':
+			if half {
+				out = append(out, high<<4)
+			}
+			return out
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			continue
+		}
+		if half {
+			out = append(out, high<<4|v)
+		} else {
+			high = v
+		}
+		half = !half
+	}
+	return out
+}
+
+// inlineImageData returns the bytes between ID and the EI that ends the image
+func (l *contentLexer) inlineImageData() []byte {
+	if l.pos < len(l.data) && isPDFWhitespace(l.data[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	for i := start; i+1 < len(l.data); i++ {
+		if l.data[i] != 'E' || l.data[i+1] != 'I' || i > start && !isPDFWhitespace(l.data[i-1]) {
+			continue
+		}
+		if i+2 < len(l.data) && !isPDFWhitespace(l.data[i+2]) {
+			continue
+		}
+		end := i
+		if end > start && isPDFWhitespace(l.data[end-1]) {
+			end--
+		}
+		l.pos = i + 2
+		return l.data[start:end]
+	}
+	l.pos = len(l.data)
+	return l.data[start:]
+}
+
+// renderColorSpace converts colour components to RGB
+type renderColorSpace struct {
+	family     string
+	components int
+	base       *renderColorSpace
+	lookup     []byte
+	hival      int
+	tint       *renderFunction
+	whitePoint [3]float64
+}
+
+var (
+	deviceGraySpace = &renderColorSpace{family: "DeviceGray", components: 1}
+	deviceRGBSpace  = &renderColorSpace{family: "DeviceRGB", components: 3}
+	deviceCMYKSpace = &renderColorSpace{family: "DeviceCMYK", components: 4}
+	patternSpace    = &renderColorSpace{family: "Pattern", components: 0}
+)
+
+func (cs *renderColorSpace) initialColor() []float64 {
+	switch cs.family {
+	case "DeviceCMYK":
+		return []float64{0, 0, 0, 1}
+	case "Separation", "DeviceN":
+		comps := make([]float64, cs.components)
+		for i := range comps {
+			comps[i] = 1
+		}
+		return comps
+	}
+	return make([]float64, cs.components)
+}
+
+// rgb returns the colour as red, green and blue in the 0-1 range
+func (cs *renderColorSpace) rgb(comps []float64) [3]float64 {
+	at := func(i int) float64 {
+		if i < len(comps) {
+			return math.Max(0, math.Min(1, comps[i]))
+		}
+		return 0
+	}
+	switch cs.family {
+	case "DeviceGray":
+		return [3]float64{at(0), at(0), at(0)}
+	case "DeviceRGB":
+		return [3]float64{at(0), at(1), at(2)}
+	case "DeviceCMYK":
+		k := at(3)
+		return [3]float64{(1 - at(0)) * (1 - k), (1 - at(1)) * (1 - k), (1 - at(2)) * (1 - k)}
+	case "Lab":
+		return labToRGB(comps, cs.whitePoint)
+	case "Indexed":
+		index := 0
+		if len(comps) > 0 {
+			index = int(math.Max(0, math.Min(float64(cs.hival), math.Round(comps[0]))))
+		}
+		n := cs.base.components
+		values := make([]float64, n)
+		for i := range values {
+			if j := index*n + i; j < len(cs.lookup) {
+				values[i] = float64(cs.lookup[j]) / 255
+			}
+		}
+		return cs.base.rgb(values)
+	case "Separation", "DeviceN":
+		if cs.tint != nil && cs.base != nil {
+			if out := cs.tint.eval(comps); len(out) >= cs.base.components {
+				return cs.base.rgb(out)
+			}
+		}
+		// Without a usable tint transform, treat the tint as a darkness level
+		darkness := 0.0
+		for i := range comps {
+			darkness = math.Max(darkness, at(i))
+		}
+		return [3]float64{1 - darkness, 1 - darkness, 1 - darkness}
+	}
+	return [3]float64{0, 0, 0}
+}
+
+func (cs *renderColorSpace) toNRGBA(comps []float64, alpha float64) color.NRGBA {
+	c := cs.rgb(comps)
+	return color.NRGBA{
+		R: uint8(math.Round(c[0] * 255)),
+		G: uint8(math.Round(c[1] * 255)),
+		B: uint8(math.Round(c[2] * 255)),
+		A: alphaByte(alpha),
+	}
+}
+
+// labToRGB converts CIE L*a*b* to sRGB relative to the colour space white point
+func labToRGB(comps []float64, white [3]float64) [3]float64 {
+	if white[1] == 0 {
+		white = [3]float64{0.9505, 1, 1.089}
+	}
+	var l, a, b float64
+	if len(comps) >= 3 {
+		l, a, b = comps[0], comps[1], comps[2]
+	}
+	fy := (l + 16) / 116
+	fx, fz := fy+a/500, fy-b/200
+	inverse := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+	x, y, z := white[0]*inverse(fx), white[1]*inverse(fy), white[2]*inverse(fz)
+	linear := [3]float64{
+		3.2406*x - 1.5372*y - 0.4986*z,
+		-0.9689*x + 1.8758*y + 0.0415*z,
+		0.0557*x - 0.2040*y + 1.0570*z,
+	}
+	var out [3]float64
+	for i, v := range linear {
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		out[i] = math.Max(0, math.Min(1, v))
+	}
+	return out
+}
+
+// colorSpace - Resolve a colour space operand, either a family name or a resource name
+func (r *pageRenderer) colorSpace(operand interface{}, resources types.Dict) *renderColorSpace {
+	name, _ := operand.(contentName)
+	switch string(name) {
+	case "DeviceGray", "G", "CalGray":
+		return deviceGraySpace
+	case "DeviceRGB", "RGB", "CalRGB":
+		return deviceRGBSpace
+	case "DeviceCMYK", "CMYK":
+		return deviceCMYKSpace
+	case "Pattern":
+		return patternSpace
+	}
+	if o := r.resource(resources, "ColorSpace", string(name)); o != nil {
+		return r.parseColorSpace(o, 0)
+	}
+	return deviceGraySpace
+}
+
+func (r *pageRenderer) parseColorSpace(o types.Object, depth int) *renderColorSpace {
+	o, err := r.ctx.Dereference(o)
+	if err != nil || o == nil || depth > 4 {
+		return deviceGraySpace
+	}
+	if name, ok := o.(types.Name); ok {
+		return r.colorSpace(contentName(name), nil)
+	}
+	a, ok := o.(types.Array)
+	if !ok || len(a) == 0 {
+		return deviceGraySpace
+	}
+	family, _ := r.ctx.Dereference(a[0])
+	familyName, _ := family.(types.Name)
+	switch familyName {
+	case "CalGray":
+		return deviceGraySpace
+	case "CalRGB":
+		return deviceRGBSpace
+	case "Pattern":
+		return patternSpace
+	case "Lab":
+		cs := &renderColorSpace{family: "Lab", components: 3}
+		if len(a) > 1 {
+			if d, err := r.ctx.DereferenceDict(a[1]); err == nil && d != nil {
+				if wp, err := r.ctx.DereferenceArray(d["WhitePoint"]); err == nil && len(wp) == 3 {
+					for i := range cs.whitePoint {
+						cs.whitePoint[i], _ = r.number(wp[i])
+					}
+				}
+			}
+		}
+		return cs
+	case "ICCBased":
+		if len(a) < 2 {
+			return deviceRGBSpace
+		}
+		sd, _, err := r.ctx.DereferenceStreamDict(a[1])
+		if err != nil || sd == nil {
+			return deviceRGBSpace
+		}
+		if alternate, ok := sd.Dict["Alternate"]; ok {
+			return r.parseColorSpace(alternate, depth+1)
+		}
+		switch n, _ := r.number(sd.Dict["N"]); n {
+		case 1:
+			return deviceGraySpace
+		case 4:
+			return deviceCMYKSpace
+		}
+		return deviceRGBSpace
+	case "Indexed", "I":
+		if len(a) < 4 {
+			return deviceGraySpace
+		}
+		cs := &renderColorSpace{family: "Indexed", components: 1, base: r.parseColorSpace(a[1], depth+1)}
+		hival, _ := r.number(a[2])
+		cs.hival = int(hival)
+		lookup, err := r.ctx.Dereference(a[3])
+		if err != nil {
+			return deviceGraySpace
+		}
+		switch l := lookup.(type) {
+		case types.StringLiteral:
+			cs.lookup, _ = types.Unescape(l.Value())
+		case types.HexLiteral:
+			cs.lookup, _ = l.Bytes()
+		case types.StreamDict:
+			if err := l.Decode(); err == nil {
+				cs.lookup = l.Content
+			}
+		}
+		return cs
+	case "Separation", "DeviceN":
+		if len(a) < 4 {
+			return deviceGraySpace
+		}
+		cs := &renderColorSpace{family: string(familyName), components: 1}
+		if familyName == "DeviceN" {
+			if names, err := r.ctx.DereferenceArray(a[1]); err == nil {
+				cs.components = len(names)
+			}
+		}
+		cs.base = r.parseColorSpace(a[2], depth+1)
+		cs.tint = r.parseFunction(a[3], 0)
+		return cs
+	}
+	return deviceGraySpace
+}
+
+// renderFunction evaluates PDF function types 0, 2, 3 and 4
+type renderFunction struct {
+	kind    int
+	domain  []float64
+	rng     []float64
+	c0, c1  []float64
+	n       float64
+	subs    []*renderFunction
+	bounds  []float64
+	encode  []float64
+	decode  []float64
+	size    []int
+	samples []float64
+	outputs int
+	program []psOperation
+}
+
+func (r *pageRenderer) numbers(o types.Object) []float64 {
+	a, err := r.ctx.DereferenceArray(o)
+	if err != nil {
+		return nil
+	}
+	values := make([]float64, 0, len(a))
+	for _, item := range a {
+		v, _ := r.number(item)
+		values = append(values, v)
+	}
+	return values
+}
+
+// parseFunction - Build an evaluator for a function dictionary, stream or array of functions
+func (r *pageRenderer) parseFunction(o types.Object, depth int) *renderFunction {
+	o, err := r.ctx.Dereference(o)
+	if err != nil || o == nil || depth > 4 {
+		return nil
+	}
+	if a, ok := o.(types.Array); ok {
+		// An array holds one single-output function per component
+		f := &renderFunction{kind: -1}
+		for _, item := range a {
+			sub := r.parseFunction(item, depth+1)
+			if sub == nil {
+				return nil
+			}
+			f.subs = append(f.subs, sub)
+		}
+		return f
+	}
+
+	var d types.Dict
+	var sd *types.StreamDict
+	switch v := o.(type) {
+	case types.Dict:
+		d = v
+	case types.StreamDict:
+		sd, d = &v, v.Dict
+	default:
+		return nil
+	}
+	kind, _ := r.number(d["FunctionType"])
+	f := &renderFunction{kind: int(kind), domain: r.numbers(d["Domain"]), rng: r.numbers(d["Range"])}
+	switch f.kind {
+	case 0:
+		if sd == nil || sd.Decode() != nil {
+			return nil
+		}
+		for _, v := range r.numbers(d["Size"]) {
+			f.size = append(f.size, int(v))
+		}
+		bps, _ := r.number(d["BitsPerSample"])
+		f.encode, f.decode = r.numbers(d["Encode"]), r.numbers(d["Decode"])
+		if len(f.decode) == 0 {
+			f.decode = f.rng
+		}
+		f.outputs = len(f.rng) / 2
+		total := f.outputs
+		for _, s := range f.size {
+			total *= s
+		}
+		if total <= 0 || len(f.size) == 0 || bps <= 0 || bps > 32 {
+			return nil
+		}
+		f.samples = unpackSamples(sd.Content, int(bps), total)
+	case 2:
+		f.c0, f.c1 = r.numbers(d["C0"]), r.numbers(d["C1"])
+		if len(f.c0) == 0 {
+			f.c0 = []float64{0}
+		}
+		if len(f.c1) == 0 {
+			f.c1 = []float64{1}
+		}
+		f.n, _ = r.number(d["N"])
+	case 3:
+		functions, err := r.ctx.DereferenceArray(d["Functions"])
+		if err != nil {
+			return nil
+		}
+		for _, item := range functions {
+			sub := r.parseFunction(item, depth+1)
+			if sub == nil {
+				return nil
+			}
+			f.subs = append(f.subs, sub)
+		}
+		f.bounds, f.encode = r.numbers(d["Bounds"]), r.numbers(d["Encode"])
+	case 4:
+		if sd == nil || sd.Decode() != nil {
+			return nil
+		}
+		lexer := &contentLexer{data: sd.Content}
+		if token, err := lexer.next(); err != nil || token != contentOperator("{") {
+			return nil
+		}
+		f.program = parsePostScript(lexer)
+	default:
+		return nil
+	}
+	return f
+}
+
+func unpackSamples(data []byte, bps, count int) []float64 {
+	samples := make([]float64, count)
+	max := math.Pow(2, float64(bps)) - 1
+	bit := 0
+	for i := range samples {
+		v := uint64(0)
+		for b := 0; b < bps; b++ {
+			byteIndex := bit / 8
+			if byteIndex >= len(data) {
+				return samples
+			}
+			v = v<<1 | uint64(data[byteIndex]>>(7-uint(bit%8))&1)
+			bit++
+		}
+		samples[i] = float64(v) / max
+	}
+	return samples
+}
+
+func interpolate(x, xmin, xmax, ymin, ymax float64) float64 {
+	if xmax == xmin {
+		return ymin
+	}
+	return ymin + (x-xmin)*(ymax-ymin)/(xmax-xmin)
+}
+
+func (f *renderFunction) eval(in []float64) []float64 {
+	if f.kind == -1 {
+		out := []float64{}
+		for _, sub := range f.subs {
+			out = append(out, sub.eval(in)...)
+		}
+		return out
+	}
+	clamped := make([]float64, len(in))
+	for i, v := range in {
+		if 2*i+1 < len(f.domain) {
+			v = math.Max(f.domain[2*i], math.Min(f.domain[2*i+1], v))
+		}
+		clamped[i] = v
+	}
+	var out []float64
+	switch f.kind {
+	case 0:
+		out = f.evalSampled(clamped)
+	case 2:
+		x := 0.0
+		if len(clamped) > 0 {
+			x = clamped[0]
+		}
+		out = make([]float64, len(f.c0))
+		for i := range out {
+			c1 := 0.0
+			if i < len(f.c1) {
+				c1 = f.c1[i]
+			}
+			out[i] = f.c0[i] + math.Pow(x, f.n)*(c1-f.c0[i])
+		}
+	case 3:
+		if len(f.subs) == 0 || len(clamped) == 0 {
+			return nil
+		}
+		x := clamped[0]
+		k := 0
+		for k < len(f.bounds) && x >= f.bounds[k] {
+			k++
+		}
+		if k >= len(f.subs) {
+			k = len(f.subs) - 1
+		}
+		low, high := 0.0, 1.0
+		if len(f.domain) >= 2 {
+			low, high = f.domain[0], f.domain[1]
+		}
+		if k > 0 {
+			low = f.bounds[k-1]
+		}
+		if k < len(f.bounds) {
+			high = f.bounds[k]
+		}
+		if 2*k+1 < len(f.encode) {
+			x = interpolate(x, low, high, f.encode[2*k], f.encode[2*k+1])
+		}
+		out = f.subs[k].eval([]float64{x})
+	case 4:
+		out = runPostScript(f.program, clamped)
+	}
+	for i := range out {
+		if 2*i+1 < len(f.rng) {
+			out[i] = math.Max(f.rng[2*i], math.Min(f.rng[2*i+1], out[i]))
+		}
+	}
+	return out
+}
+
+// evalSampled interpolates linearly for one input and picks the nearest sample otherwise
+func (f *renderFunction) evalSampled(in []float64) []float64 {
+	out := make([]float64, f.outputs)
+	encoded := make([]float64, len(f.size))
+	for i := range f.size {
+		x := 0.0
+		if i < len(in) {
+			x = in[i]
+		}
+		e0, e1 := 0.0, float64(f.size[i]-1)
+		if 2*i+1 < len(f.encode) {
+			e0, e1 = f.encode[2*i], f.encode[2*i+1]
+		}
+		if 2*i+1 < len(f.domain) {
+			x = interpolate(x, f.domain[2*i], f.domain[2*i+1], e0, e1)
+		}
+		encoded[i] = math.Max(0, math.Min(float64(f.size[i]-1), x))
+	}
+	sample := func(index []int, j int) float64 {
+		offset, stride := 0, 1
+		for i, v := range index {
+			offset += v * stride
+			stride *= f.size[i]
+		}
+		if k := offset*f.outputs + j; k < len(f.samples) {
+			return f.samples[k]
+		}
+		return 0
+	}
+	index := make([]int, len(f.size))
+	for i, v := range encoded {
+		index[i] = int(math.Round(v))
+	}
+	for j := range out {
+		v := sample(index, j)
+		if len(f.size) == 1 {
+			low := int(math.Floor(encoded[0]))
+			high := low + 1
+			if high < f.size[0] {
+				v = interpolate(encoded[0], float64(low), float64(high), sample([]int{low}, j), sample([]int{high}, j))
+			} else {
+				v = sample([]int{low}, j)
+			}
+		}
+		if 2*j+1 < len(f.decode) {
+			v = interpolate(v, 0, 1, f.decode[2*j], f.decode[2*j+1])
+		}
+		out[j] = v
+	}
+	return out
+}
+
+// psOperation is one step of a type 4 calculator function
+type psOperation struct {
+	operator  string
+	value     float64
+	then      []psOperation
+	otherwise []psOperation
+}
+
+func parsePostScript(lexer *contentLexer) []psOperation {
+	program := []psOperation{}
+	blocks := [][]psOperation{}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return program
+		}
+		switch v := token.(type) {
+		case float64:
+			program = append(program, psOperation{value: v})
+		case bool:
+			if v {
+				program = append(program, psOperation{value: 1})
+			} else {
+				program = append(program, psOperation{value: 0})
+			}
+		case contentOperator:
+			switch v {
+			case "}":
+				return program
+			case "{":
+				blocks = append(blocks, parsePostScript(lexer))
+			case "if":
+				if len(blocks) >= 1 {
+					program = append(program, psOperation{operator: "if", then: blocks[len(blocks)-1]})
+				}
+				blocks = nil
+			case "ifelse":
+				if len(blocks) >= 2 {
+					program = append(program, psOperation{operator: "ifelse", then: blocks[len(blocks)-2], otherwise: blocks[len(blocks)-1]})
+				}
+				blocks = nil
+			default:
+				program = append(program, psOperation{operator: string(v)})
+			}
+		}
+	}
+}
+
+func runPostScript(program []psOperation, in []float64) []float64 {
+	stack := append([]float64{}, in...)
+	var exec func(ops []psOperation)
+	pop := func() float64 {
+		if len(stack) == 0 {
+			return 0
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	push := func(v float64) { stack = append(stack, v) }
+	boolean := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	exec = func(ops []psOperation) {
+		for _, op := range ops {
+			if len(stack) > 1000 {
+				return
+			}
+			switch op.operator {
+			case "":
+				push(op.value)
+			case "add":
+				b, a := pop(), pop()
+				push(a + b)
+			case "sub":
+				b, a := pop(), pop()
+				push(a - b)
+			case "mul":
+				b, a := pop(), pop()
+				push(a * b)
+			case "div":
+				b, a := pop(), pop()
+				if b != 0 {
+					push(a / b)
+				} else {
+					push(0)
+				}
+			case "idiv":
+				b, a := int(pop()), int(pop())
+				if b != 0 {
+					push(float64(a / b))
+				} else {
+					push(0)
+				}
+			case "mod":
+				b, a := int(pop()), int(pop())
+				if b != 0 {
+					push(float64(a % b))
+				} else {
+					push(0)
+				}
+			case "neg":
+				push(-pop())
+			case "abs":
+				push(math.Abs(pop()))
+			case "ceiling":
+				push(math.Ceil(pop()))
+			case "floor":
+				push(math.Floor(pop()))
+			case "round":
+				push(math.Floor(pop() + 0.5))
+			case "truncate", "cvi":
+				push(math.Trunc(pop()))
+			case "cvr":
+			case "sqrt":
+				push(math.Sqrt(math.Max(0, pop())))
+			case "sin":
+				push(math.Sin(pop() * math.Pi / 180))
+			case "cos":
+				push(math.Cos(pop() * math.Pi / 180))
+			case "atan":
+				den, num := pop(), pop()
+				angle := math.Atan2(num, den) * 180 / math.Pi
+				if angle < 0 {
+					angle += 360
+				}
+				push(angle)
+			case "exp":
+				e, base := pop(), pop()
+				push(math.Pow(base, e))
+			case "ln":
+				push(math.Log(pop()))
+			case "log":
+				push(math.Log10(pop()))
+			case "eq":
+				b, a := pop(), pop()
+				push(boolean(a == b))
+			case "ne":
+				b, a := pop(), pop()
+				push(boolean(a != b))
+			case "gt":
+				b, a := pop(), pop()
+				push(boolean(a > b))
+			case "ge":
+				b, a := pop(), pop()
+				push(boolean(a >= b))
+			case "lt":
+				b, a := pop(), pop()
+				push(boolean(a < b))
+			case "le":
+				b, a := pop(), pop()
+				push(boolean(a <= b))
+			case "and":
+				b, a := int(pop()), int(pop())
+				push(float64(a & b))
+			case "or":
+				b, a := int(pop()), int(pop())
+				push(float64(a | b))
+			case "xor":
+				b, a := int(pop()), int(pop())
+				push(float64(a ^ b))
+			case "not":
+				v := pop()
+				if v == 0 || v == 1 {
+					push(1 - v)
+				} else {
+					push(float64(^int(v)))
+				}
+			case "bitshift":
+				shift, v := int(pop()), int(pop())
+				if shift >= 0 {
+					push(float64(v << uint(shift)))
+				} else {
+					push(float64(v >> uint(-shift)))
+				}
+			case "true":
+				push(1)
+			case "false":
+				push(0)
+			case "pop":
+				pop()
+			case "exch":
+				b, a := pop(), pop()
+				push(b)
+				push(a)
+			case "dup":
+				v := pop()
+				push(v)
+				push(v)
+			case "copy":
+				n := int(pop())
+				if n > 0 && n <= len(stack) {
+					stack = append(stack, stack[len(stack)-n:]...)
+				}
+			case "index":
+				n := int(pop())
+				if n >= 0 && n < len(stack) {
+					push(stack[len(stack)-1-n])
+				}
+			case "roll":
+				j, n := int(pop()), int(pop())
+				if n > 0 && n <= len(stack) {
+					top := stack[len(stack)-n:]
+					j = ((j % n) + n) % n
+					rolled := append(append([]float64{}, top[n-j:]...), top[:n-j]...)
+					copy(top, rolled)
+				}
+			case "if":
+				if pop() != 0 {
+					exec(op.then)
+				}
+			case "ifelse":
+				if pop() != 0 {
+					exec(op.then)
+				} else {
+					exec(op.otherwise)
+				}
+			}
+		}
+	}
+	exec(program)
+	return stack
+}
+
+// renderShading paints axial and radial shadings through a precomputed colour ramp
+type renderShading struct {
+	kind    int
+	coords  []float64
+	domain  [2]float64
+	extend  [2]bool
+	inverse pdfMatrix
+	ramp    []color.NRGBA
+}
+
+const shadingRampSize = 256
+
+// parseShading - Build a shading whose space maps to device space through matrix
+func (r *pageRenderer) parseShading(o types.Object, matrix pdfMatrix) *renderShading {
+	o, err := r.ctx.Dereference(o)
+	if err != nil || o == nil {
+		return nil
+	}
+	var d types.Dict
+	switch v := o.(type) {
+	case types.Dict:
+		d = v
+	case types.StreamDict:
+		d = v.Dict
+	default:
+		return nil
+	}
+	kind, _ := r.number(d["ShadingType"])
+	s := &renderShading{kind: int(kind), coords: r.numbers(d["Coords"]), domain: [2]float64{0, 1}}
+	if s.kind != 2 && s.kind != 3 || s.kind == 2 && len(s.coords) != 4 || s.kind == 3 && len(s.coords) != 6 {
+		return nil
+	}
+	if domain := r.numbers(d["Domain"]); len(domain) == 2 {
+		s.domain = [2]float64{domain[0], domain[1]}
+	}
+	if a, err := r.ctx.DereferenceArray(d["Extend"]); err == nil && len(a) == 2 {
+		for i := range s.extend {
+			if b, ok := a[i].(types.Boolean); ok {
+				s.extend[i] = b.Value()
+			}
+		}
+	}
+	inverse, ok := matrix.invert()
+	if !ok {
+		return nil
+	}
+	s.inverse = inverse
+
+	space := r.parseColorSpace(d["ColorSpace"], 0)
+	function := r.parseFunction(d["Function"], 0)
+	if function == nil {
+		return nil
+	}
+	s.ramp = make([]color.NRGBA, shadingRampSize)
+	for i := range s.ramp {
+		t := interpolate(float64(i), 0, shadingRampSize-1, s.domain[0], s.domain[1])
+		s.ramp[i] = space.toNRGBA(function.eval([]float64{t}), 1)
+	}
+	return s
+}
+
+// colorAt returns the colour at a device pixel, or false outside the shading
+func (s *renderShading) colorAt(x, y float64) (color.NRGBA, bool) {
+	p := s.inverse.apply(x, y)
+	var t float64
+	if s.kind == 2 {
+		dx, dy := s.coords[2]-s.coords[0], s.coords[3]-s.coords[1]
+		denominator := dx*dx + dy*dy
+		if denominator == 0 {
+			return color.NRGBA{}, false
+		}
+		t = ((p.x-s.coords[0])*dx + (p.y-s.coords[1])*dy) / denominator
+	} else {
+		var ok bool
+		if t, ok = s.radialParameter(p); !ok {
+			return color.NRGBA{}, false
+		}
+	}
+	if t < 0 {
+		if !s.extend[0] {
+			return color.NRGBA{}, false
+		}
+		t = 0
+	}
+	if t > 1 {
+		if !s.extend[1] {
+			return color.NRGBA{}, false
+		}
+		t = 1
+	}
+	return s.ramp[int(math.Round(t*(shadingRampSize-1)))], true
+}
+
+// radialParameter solves for the largest circle of the blend passing through p
+func (s *renderShading) radialParameter(p renderPoint) (float64, bool) {
+	x0, y0, r0, x1, y1, r1 := s.coords[0], s.coords[1], s.coords[2], s.coords[3], s.coords[4], s.coords[5]
+	cdx, cdy, dr := x1-x0, y1-y0, r1-r0
+	pdx, pdy := p.x-x0, p.y-y0
+	a := cdx*cdx + cdy*cdy - dr*dr
+	b := pdx*cdx + pdy*cdy + r0*dr
+	c := pdx*pdx + pdy*pdy - r0*r0
+	valid := func(t float64) bool {
+		if r0+t*dr < 0 {
+			return false
+		}
+		return t >= 0 && t <= 1 || t < 0 && s.extend[0] || t > 1 && s.extend[1]
+	}
+	if math.Abs(a) < 1e-9 {
+		if b == 0 {
+			return 0, false
+		}
+		t := c / (2 * b)
+		return t, valid(t)
+	}
+	discriminant := b*b - a*c
+	if discriminant < 0 {
+		return 0, false
+	}
+	root := math.Sqrt(discriminant)
+	t1, t2 := (b+root)/a, (b-root)/a
+	if t1 < t2 {
+		t1, t2 = t2, t1
+	}
+	if valid(t1) {
+		return t1, true
+	}
+	return t2, valid(t2)
+}
+
+// patternShading - Resolve a shading pattern used as a fill or stroke colour
+func (r *pageRenderer) patternShading(name string, resources types.Dict) *renderShading {
+	o := r.resource(resources, "Pattern", name)
+	var d types.Dict
+	switch v := o.(type) {
+	case types.Dict:
+		d = v
+	case types.StreamDict:
+		d = v.Dict
+	default:
+		return nil
+	}
+	if kind, _ := r.number(d["PatternType"]); kind != 2 {
+		return nil
+	}
+	matrix := identityMatrix
+	if a, err := r.ctx.DereferenceArray(d["Matrix"]); err == nil && len(a) == 6 {
+		matrix, _ = r.matrixFromArray(a)
+	}
+	return r.parseShading(d["Shading"], matrix.multiply(r.baseCTM))
+}
+
+// paintShading - Fill the clip region with a shading (sh operator)
+func (r *pageRenderer) paintShading(name string, resources types.Dict) {
+	o := r.resource(resources, "Shading", name)
+	if o == nil {
+		return
+	}
+	shading := r.parseShading(o, r.state.ctm)
+	if shading == nil {
+		return
+	}
+	b := r.bounds
+	page := [][]renderPoint{{{float64(b.Min.X), float64(b.Min.Y)}, {float64(b.Max.X), float64(b.Min.Y)}, {float64(b.Max.X), float64(b.Max.Y)}, {float64(b.Min.X), float64(b.Max.Y)}}}
+	r.fill(page, false, renderPaint{shading: shading, color: color.NRGBA{A: alphaByte(r.state.fillAlpha)}})
+}
+
+// renderFont holds what the renderer needs to draw the glyphs of a font resource
+type renderFont struct {
+	composite    bool
+	twoByte      bool
+	baseFont     string
+	widths       map[int]float64
+	defaultWidth float64
+	hasWidths    bool
+	widthScale   float64
+	unicode      map[int][]rune
+	encoding     map[int]rune
+	names        map[int]string
+	face         *sfnt.Font
+	symbolic     bool
+	cidToGID     []int
+	namedGlyphs  map[string]sfnt.GlyphIndex
+	fallback     *sfnt.Font
+	glyphs       map[int]*renderGlyph
+	type3        *type3Font
+}
+
+type type3Font struct {
+	procs     types.Dict
+	matrix    pdfMatrix
+	resources types.Dict
+}
+
+// glyphSegment is an outline command in text space units, y pointing up
+type glyphSegment struct {
+	op     sfnt.SegmentOp
+	points [3]renderPoint
+}
+
+type renderGlyph struct {
+	segments []glyphSegment
+	advance  float64
+}
+
+// loadFont - Resolve a font resource by name
+func (r *pageRenderer) loadFont(name string, resources types.Dict) *renderFont {
+	entries, err := r.ctx.DereferenceDict(resources["Font"])
+	if err != nil || entries == nil {
+		return nil
+	}
+	d, err := r.ctx.DereferenceDict(entries[name])
+	if err != nil || d == nil {
+		return nil
+	}
+	return r.fontFromDict(d, entries[name])
+}
+
+func (r *pageRenderer) fontFromDict(d types.Dict, ref types.Object) *renderFont {
+	key := fmt.Sprintf("%p", d)
+	if indRef, ok := ref.(types.IndirectRef); ok {
+		key = indRef.String()
+	}
+	if f, ok := r.fonts[key]; ok {
+		return f
+	}
+	f := r.buildFont(d)
+	r.fonts[key] = f
+	return f
+}
+
+func (r *pageRenderer) buildFont(d types.Dict) *renderFont {
+	f := &renderFont{
+		widths:     map[int]float64{},
+		widthScale: 0.001,
+		encoding:   map[int]rune{},
+		names:      map[int]string{},
+		glyphs:     map[int]*renderGlyph{},
+	}
+	subtype := ""
+	if s := d.NameEntry("Subtype"); s != nil {
+		subtype = *s
+	}
+	if s := d.NameEntry("BaseFont"); s != nil {
+		f.baseFont = *s
+		// Drop the subset tag such as ABCDEF+
+		if i := strings.IndexByte(f.baseFont, '+'); i == 6 {
+			f.baseFont = f.baseFont[i+1:]
+		}
+	}
+
+	descriptorDict := d
+	if subtype == "Type0" {
+		f.composite, f.twoByte, f.defaultWidth = true, true, 1
+		if sd, _, err := r.ctx.DereferenceStreamDict(d["Encoding"]); err == nil && sd != nil && sd.Decode() == nil {
+			if width := codespaceWidth(sd.Content); width == 1 {
+				f.twoByte = false
+			}
+		}
+		descendants, err := r.ctx.DereferenceArray(d["DescendantFonts"])
+		if err == nil && len(descendants) > 0 {
+			if cid, err := r.ctx.DereferenceDict(descendants[0]); err == nil && cid != nil {
+				descriptorDict = cid
+				if dw, ok := r.number(cid["DW"]); ok {
+					f.defaultWidth = dw / 1000
+				}
+				r.parseCIDWidths(f, cid["W"])
+				if sd, _, err := r.ctx.DereferenceStreamDict(cid["CIDToGIDMap"]); err == nil && sd != nil && sd.Decode() == nil {
+					for i := 0; i+1 < len(sd.Content); i += 2 {
+						f.cidToGID = append(f.cidToGID, int(sd.Content[i])<<8|int(sd.Content[i+1]))
+					}
+				}
+			}
+		}
+	} else {
+		first, _ := r.number(d["FirstChar"])
+		if widths, err := r.ctx.DereferenceArray(d["Widths"]); err == nil && len(widths) > 0 {
+			f.hasWidths = true
+			for i, o := range widths {
+				if w, ok := r.number(o); ok {
+					f.widths[int(first)+i] = w
+				}
+			}
+		}
+	}
+
+	var flags int
+	descriptor, _ := r.ctx.DereferenceDict(descriptorDict["FontDescriptor"])
+	if descriptor != nil {
+		if v, ok := r.number(descriptor["Flags"]); ok {
+			flags = int(v)
+		}
+		if v, ok := r.number(descriptor["MissingWidth"]); ok && !f.composite {
+			f.defaultWidth = v / 1000
+		}
+		f.symbolic = flags&4 != 0
+		f.face = r.embeddedFace(descriptor)
+	}
+	f.fallback = fallbackFace(f.baseFont, flags)
+
+	if subtype == "Type3" {
+		f.type3 = &type3Font{matrix: pdfMatrix{0.001, 0, 0, 0.001, 0, 0}}
+		if a, err := r.ctx.DereferenceArray(d["FontMatrix"]); err == nil && len(a) == 6 {
+			f.type3.matrix, _ = r.matrixFromArray(a)
+		}
+		f.widthScale = f.type3.matrix[0]
+		f.type3.procs, _ = r.ctx.DereferenceDict(d["CharProcs"])
+		f.type3.resources, _ = r.ctx.DereferenceDict(d["Resources"])
+	}
+	if !f.composite {
+		r.parseEncoding(f, d, subtype)
+	}
+	if sd, _, err := r.ctx.DereferenceStreamDict(d["ToUnicode"]); err == nil && sd != nil && sd.Decode() == nil {
+		f.unicode = parseToUnicode(sd.Content)
+	}
+	return f
+}
+
+// parseCIDWidths reads the W array of a CID font: c [w1 w2 ...] or cfirst clast w
+func (r *pageRenderer) parseCIDWidths(f *renderFont, o types.Object) {
+	a, err := r.ctx.DereferenceArray(o)
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(a); {
+		first, ok := r.number(a[i])
+		if !ok || i+1 >= len(a) {
+			return
+		}
+		if list, err := r.ctx.DereferenceArray(a[i+1]); err == nil && list != nil {
+			for j, item := range list {
+				if w, ok := r.number(item); ok {
+					f.widths[int(first)+j] = w
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(a) {
+			return
+		}
+		last, _ := r.number(a[i+1])
+		w, _ := r.number(a[i+2])
+		for c := int(first); c <= int(last) && c-int(first) < 65536; c++ {
+			f.widths[c] = w
+		}
+		i += 3
+	}
+	f.hasWidths = true
+}
+
+// parseEncoding builds the code to Unicode table of a simple font from its base encoding and differences
+func (r *pageRenderer) parseEncoding(f *renderFont, d types.Dict, subtype string) {
+	base := "WinAnsiEncoding"
+	if subtype == "Type1" && f.face == nil {
+		base = "StandardEncoding"
+	}
+	if f.symbolic && f.face != nil || subtype == "Type3" {
+		base = ""
+	}
+	var differences types.Array
+	encoding, _ := r.ctx.Dereference(d["Encoding"])
+	switch e := encoding.(type) {
+	case types.Name:
+		base = string(e)
+	case types.Dict:
+		if b := e.NameEntry("BaseEncoding"); b != nil {
+			base = *b
+		}
+		differences, _ = r.ctx.DereferenceArray(e["Differences"])
+	}
+	for code := 0; code < 256; code++ {
+		if c := baseEncodingRune(base, code); c != 0 {
+			f.encoding[code] = c
+		}
+	}
+	code := 0
+	for _, o := range differences {
+		o, _ = r.ctx.Dereference(o)
+		switch v := o.(type) {
+		case types.Integer:
+			code = v.Value()
+		case types.Float:
+			code = int(v.Value())
+		case types.Name:
+			f.names[code] = string(v)
+			if runes := glyphNameRunes(string(v)); len(runes) == 1 {
+				f.encoding[code] = runes[0]
+			} else {
+				delete(f.encoding, code)
+			}
+			code++
+		}
+	}
+}
+
+// standardEncoding lists where Adobe StandardEncoding differs from ASCII and Latin-1
+var standardEncoding = map[int]rune{
+	0x27: '’', 0x60: '‘', 0xA4: '⁄', 0xA6: 'ƒ', 0xA8: '¤', 0xA9: '\'', 0xAA: '“', 0xAC: '‹', 0xAD: '›',
+	0xAE: 'ﬁ', 0xAF: 'ﬂ', 0xB1: '–', 0xB2: '†', 0xB3: '‡', 0xB4: '·', 0xB7: '•', 0xB8: '‚', 0xB9: '„',
+	0xBA: '”', 0xBC: '…', 0xBD: '‰', 0xC1: '`', 0xC2: '´', 0xC3: 'ˆ', 0xC4: '˜', 0xC5: '¯', 0xC6: '˘',
+	0xC7: '˙', 0xC8: '¨', 0xCA: '˚', 0xCB: '¸', 0xCD: '˝', 0xCE: '˛', 0xCF: 'ˇ', 0xD0: '—', 0xE1: 'Æ',
+	0xE3: 'ª', 0xE8: 'Ł', 0xE9: 'Ø', 0xEA: 'Œ', 0xEB: 'º', 0xF1: 'æ', 0xF5: 'ı', 0xF8: 'ł', 0xF9: 'ø',
+	0xFA: 'œ', 0xFB: 'ß',
+}
+
+func baseEncodingRune(base string, code int) rune {
+	switch base {
+	case "WinAnsiEncoding":
+		if code >= 0x20 {
+			return charmap.Windows1252.DecodeByte(byte(code))
+		}
+	case "MacRomanEncoding":
+		if code >= 0x20 {
+			return charmap.Macintosh.DecodeByte(byte(code))
+		}
+	case "StandardEncoding":
+		if c, ok := standardEncoding[code]; ok {
+			return c
+		}
+		if code >= 0x20 && code < 0x7F || code >= 0xA1 && code <= 0xBF {
+			return rune(code)
+		}
+	}
+	return 0
+}
+
+// glyphNames maps the Adobe glyph names found in Differences arrays to Unicode
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "parenleft": "(", "parenright": ")",
+	"asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "colon": ":", "semicolon": ";", "less": "<", "equal": "=",
+	"greater": ">", "question": "?", "at": "@", "bracketleft": "[", "backslash": "\\", "bracketright": "]",
+	"asciicircum": "^", "underscore": "_", "grave": "`", "quoteleft": "‘", "braceleft": "{", "bar": "|",
+	"braceright": "}", "asciitilde": "~", "exclamdown": "¡", "cent": "¢", "sterling": "£", "currency": "¤",
+	"yen": "¥", "brokenbar": "¦", "section": "§", "dieresis": "¨", "copyright": "©", "ordfeminine": "ª",
+	"guillemotleft": "«", "logicalnot": "¬", "registered": "®", "macron": "¯", "degree": "°",
+	"plusminus": "±", "twosuperior": "²", "threesuperior": "³", "acute": "´", "mu": "µ", "paragraph": "¶",
+	"periodcentered": "·", "cedilla": "¸", "onesuperior": "¹", "ordmasculine": "º", "guillemotright": "»",
+	"onequarter": "¼", "onehalf": "½", "threequarters": "¾", "questiondown": "¿", "multiply": "×",
+	"divide": "÷", "AE": "Æ", "ae": "æ", "Eth": "Ð", "eth": "ð", "Oslash": "Ø", "oslash": "ø",
+	"Thorn": "Þ", "thorn": "þ", "germandbls": "ß", "OE": "Œ", "oe": "œ", "Lslash": "Ł", "lslash": "ł",
+	"dotlessi": "ı", "dotlessj": "ȷ", "florin": "ƒ", "circumflex": "ˆ", "caron": "ˇ", "breve": "˘",
+	"dotaccent": "˙", "ring": "˚", "ogonek": "˛", "tilde": "˜", "hungarumlaut": "˝", "endash": "–",
+	"emdash": "—", "quotesinglbase": "‚", "quotedblleft": "“", "quotedblright": "”", "quotedblbase": "„",
+	"dagger": "†", "daggerdbl": "‡", "bullet": "•", "ellipsis": "…", "perthousand": "‰",
+	"guilsinglleft": "‹", "guilsinglright": "›", "fraction": "⁄", "Euro": "€", "trademark": "™",
+	"minus": "−", "fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl", "nbspace": " ",
+	"sfthyphen": "-", "visiblespace": "␣", "arrowright": "→", "arrowleft": "←", "arrowup": "↑",
+	"arrowdown": "↓", "infinity": "∞", "lessequal": "≤", "greaterequal": "≥", "notequal": "≠",
+	"approxequal": "≈", "summation": "∑", "product": "∏", "radical": "√", "integral": "∫",
+	"partialdiff": "∂", "Delta": "∆", "Omega": "Ω", "pi": "π", "lozenge": "◊", "checkmark": "✓",
+}
+
+// glyphNameRunes - Resolve a glyph name to its Unicode text, following the Adobe naming conventions
+func glyphNameRunes(name string) []rune {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if s, ok := glyphNames[name]; ok {
+		return []rune(s)
+	}
+	if len(name) == 1 {
+		return []rune(name)
+	}
+	if strings.Contains(name, "_") {
+		runes := []rune{}
+		for _, part := range strings.Split(name, "_") {
+			runes = append(runes, glyphNameRunes(part)...)
+		}
+		return runes
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		runes := []rune{}
+		for i := 3; i+4 <= len(name); i += 4 {
+			v, err := strconv.ParseUint(name[i:i+4], 16, 32)
+			if err != nil {
+				return nil
+			}
+			runes = append(runes, rune(v))
+		}
+		return runes
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return []rune{rune(v)}
+		}
+	}
+	if c, ok := latinGlyphs[name]; ok {
+		return []rune{c}
+	}
+	return nil
+}
+
+// latinGlyphs maps the glyph names of accented Latin-1 and Latin Extended-A letters
+var latinGlyphs = func() map[string]rune {
+	names := map[string]rune{}
+	latin1 := strings.Fields("Agrave Aacute Acircumflex Atilde Adieresis Aring AE Ccedilla Egrave Eacute Ecircumflex Edieresis Igrave Iacute Icircumflex Idieresis Eth Ntilde Ograve Oacute Ocircumflex Otilde Odieresis multiply Oslash Ugrave Uacute Ucircumflex Udieresis Yacute Thorn germandbls")
+	for i, name := range latin1 {
+		names[name] = rune(0xC0 + i)
+		if name != "multiply" && name != "germandbls" {
+			names[strings.ToLower(name[:1])+name[1:]] = rune(0xE0 + i)
+		}
+	}
+	names["ydieresis"] = 0xFF
+	extendedA := strings.Fields("Amacron amacron Abreve abreve Aogonek aogonek Cacute cacute Ccircumflex ccircumflex Cdotaccent cdotaccent Ccaron ccaron Dcaron dcaron Dcroat dcroat Emacron emacron Ebreve ebreve Edotaccent edotaccent Eogonek eogonek Ecaron ecaron Gcircumflex gcircumflex Gbreve gbreve Gdotaccent gdotaccent Gcommaaccent gcommaaccent Hcircumflex hcircumflex Hbar hbar Itilde itilde Imacron imacron Ibreve ibreve Iogonek iogonek Idotaccent dotlessi IJ ij Jcircumflex jcircumflex Kcommaaccent kcommaaccent kgreenlandic Lacute lacute Lcommaaccent lcommaaccent Lcaron lcaron Ldot ldot Lslash lslash Nacute nacute Ncommaaccent ncommaaccent Ncaron ncaron napostrophe Eng eng Omacron omacron Obreve obreve Ohungarumlaut ohungarumlaut OE oe Racute racute Rcommaaccent rcommaaccent Rcaron rcaron Sacute sacute Scircumflex scircumflex Scedilla scedilla Scaron scaron Tcedilla tcedilla Tcaron tcaron Tbar tbar Utilde utilde Umacron umacron Ubreve ubreve Uring uring Uhungarumlaut uhungarumlaut Uogonek uogonek Wcircumflex wcircumflex Ycircumflex ycircumflex Ydieresis Zacute zacute Zdotaccent zdotaccent Zcaron zcaron")
+	for i, name := range extendedA {
+		names[name] = rune(0x100 + i)
+	}
+	return names
+}()
+
+// codespaceWidth returns the byte length of the first codespace range of a CMap
+func codespaceWidth(data []byte) int {
+	lexer := &contentLexer{data: data}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return 0
+		}
+		if token == contentOperator("begincodespacerange") {
+			if low, err := lexer.next(); err == nil {
+				if b, ok := low.([]byte); ok {
+					return len(b)
+				}
+			}
+			return 0
+		}
+	}
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap
+func parseToUnicode(data []byte) map[int][]rune {
+	mapping := map[int][]rune{}
+	lexer := &contentLexer{data: data}
+	code := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return mapping
+		}
+		switch token {
+		case contentOperator("beginbfchar"):
+			for {
+				src, err := lexer.next()
+				if err != nil || src == contentOperator("endbfchar") {
+					break
+				}
+				dst, err := lexer.next()
+				if err != nil {
+					break
+				}
+				s, ok1 := src.([]byte)
+				d, ok2 := dst.([]byte)
+				if ok1 && ok2 {
+					mapping[code(s)] = utf16Runes(d)
+				}
+			}
+		case contentOperator("beginbfrange"):
+			for {
+				low, err := lexer.next()
+				if err != nil || low == contentOperator("endbfrange") {
+					break
+				}
+				high, err1 := lexer.next()
+				dst, err2 := lexer.next()
+				if err1 != nil || err2 != nil {
+					break
+				}
+				l, ok1 := low.([]byte)
+				h, ok2 := high.([]byte)
+				if !ok1 || !ok2 {
+					continue
+				}
+				first, last := code(l), code(h)
+				if last-first > 65535 {
+					continue
+				}
+				switch d := dst.(type) {
+				case []byte:
+					base := utf16Runes(d)
+					if len(base) == 0 {
+						continue
+					}
+					for c := first; c <= last; c++ {
+						runes := append([]rune{}, base...)
+						runes[len(runes)-1] += rune(c - first)
+						mapping[c] = runes
+					}
+				case []interface{}:
+					for i, item := range d {
+						if b, ok := item.([]byte); ok && first+i <= last {
+							mapping[first+i] = utf16Runes(b)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func utf16Runes(b []byte) []rune {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	if len(b) == 1 {
+		return []rune{rune(b[0])}
+	}
+	return utf16.Decode(units)
+}
+
+// embeddedFace - Parse an embedded TrueType or OpenType font program
+func (r *pageRenderer) embeddedFace(descriptor types.Dict) *sfnt.Font {
+	sd, _, err := r.ctx.DereferenceStreamDict(descriptor["FontFile2"])
+	if err != nil || sd == nil {
+		sd, _, err = r.ctx.DereferenceStreamDict(descriptor["FontFile3"])
+		if err != nil || sd == nil {
+			return nil
+		}
+		// Bare CFF programs (Type1C, CIDFontType0C) are left to the fallback fonts
+		if subtype := sd.NameEntry("Subtype"); subtype == nil || *subtype != "OpenType" {
+			return nil
+		}
+	}
+	if err := sd.Decode(); err != nil {
+		return nil
+	}
+	if face, err := sfnt.Parse(sd.Content); err == nil {
+		return face
+	}
+	// Subset fonts often drop the cmap and post tables, which only the parser needs
+	for _, replace := range []bool{false, true} {
+		if patched := patchFontTables(sd.Content, replace); patched != nil {
+			if face, err := sfnt.Parse(patched); err == nil {
+				return face
+			}
+		}
+	}
+	return nil
+}
+
+// patchFontTables rebuilds a TrueType table directory with placeholder cmap and post tables,
+// added when missing or also replacing the existing ones
+func patchFontTables(data []byte, replace bool) []byte {
+	if len(data) < 12 {
+		return nil
+	}
+	count := int(data[4])<<8 | int(data[5])
+	if len(data) < 12+16*count {
+		return nil
+	}
+	tables := map[string][]byte{}
+	for i := 0; i < count; i++ {
+		record := data[12+16*i:]
+		tag := string(record[:4])
+		offset := int(record[8])<<24 | int(record[9])<<16 | int(record[10])<<8 | int(record[11])
+		length := int(record[12])<<24 | int(record[13])<<16 | int(record[14])<<8 | int(record[15])
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			continue
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	if _, ok := tables["cmap"]; !ok || replace {
+		// Format 4 subtable holding only the mandatory 0xFFFF segment
+		tables["cmap"] = []byte{
+			0, 0, 0, 1, 0, 3, 0, 1, 0, 0, 0, 12,
+			0, 4, 0, 24, 0, 0, 0, 2, 0, 2, 0, 0, 0, 0,
+			0xFF, 0xFF, 0, 0, 0xFF, 0xFF, 0, 1, 0, 0,
+		}
+	}
+	if post, ok := tables["post"]; !ok || replace || len(post) < 32 {
+		tables["post"] = append([]byte{0, 3, 0, 0}, make([]byte, 28)...)
+	}
+
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	header := 12 + 16*len(tags)
+	out := make([]byte, header, header+len(data)+64)
+	copy(out, data[:4])
+	out[4], out[5] = byte(len(tags)>>8), byte(len(tags))
+	for i, tag := range tags {
+		table := tables[tag]
+		offset := len(out)
+		record := out[12+16*i:]
+		copy(record, tag)
+		for j, v := range []int{offset, len(table)} {
+			record[8+4*j] = byte(v >> 24)
+			record[9+4*j] = byte(v >> 16)
+			record[10+4*j] = byte(v >> 8)
+			record[11+4*j] = byte(v)
+		}
+		out = append(out, table...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+// fallbackFonts are the bundled Go fonts used for fonts that are not embedded or cannot be parsed
+var fallbackFonts = map[string][]byte{
+	"regular":    goregular.TTF,
+	"bold":       gobold.TTF,
+	"italic":     goitalic.TTF,
+	"bolditalic": gobolditalic.TTF,
+	"mono":       gomono.TTF,
+}
+
+var fallbackFaces = map[string]*sfnt.Font{}
+
+// fallbackFace picks a bundled font matching the style suggested by the font name and flags
+func fallbackFace(baseFont string, flags int) *sfnt.Font {
+	name := strings.ToLower(baseFont)
+	style := "regular"
+	bold := flags&(1<<18) != 0 || strings.Contains(name, "bold") || strings.Contains(name, "black") || strings.Contains(name, "heavy")
+	italic := flags&64 != 0 || strings.Contains(name, "italic") || strings.Contains(name, "oblique")
+	switch {
+	case flags&1 != 0 || strings.Contains(name, "courier") || strings.Contains(name, "mono"):
+		style = "mono"
+	case bold && italic:
+		style = "bolditalic"
+	case bold:
+		style = "bold"
+	case italic:
+		style = "italic"
+	}
+	if face, ok := fallbackFaces[style]; ok {
+		return face
+	}
+	face, err := sfnt.Parse(fallbackFonts[style])
+	if err != nil {
+		return nil
+	}
+	fallbackFaces[style] = face
+	return face
+}
+
+// runes returns the Unicode text of a character code, preferring the ToUnicode map
+func (f *renderFont) runes(code int) []rune {
+	if runes, ok := f.unicode[code]; ok {
+		return runes
+	}
+	if f.composite {
+		return nil
+	}
+	if c, ok := f.encoding[code]; ok {
+		return []rune{c}
+	}
+	if name, ok := f.names[code]; ok {
+		return glyphNameRunes(name)
+	}
+	return nil
+}
+
+// pdfWidth returns the advance declared by the font dictionary, in text space units
+func (f *renderFont) pdfWidth(code int) (float64, bool) {
+	if w, ok := f.widths[code]; ok {
+		return w * f.widthScale, true
+	}
+	if f.composite || f.hasWidths {
+		return f.defaultWidth, true
+	}
+	if font.IsCoreFont(f.baseFont) {
+		return float64(font.CharWidth(f.baseFont, rune(code))) / 1000, true
+	}
+	return 0, false
+}
+
+// glyphIndex finds the glyph of a code in the embedded font program
+func (r *pageRenderer) glyphIndex(f *renderFont, code int) sfnt.GlyphIndex {
+	if f.composite {
+		if f.cidToGID != nil {
+			if code < len(f.cidToGID) {
+				return sfnt.GlyphIndex(f.cidToGID[code])
+			}
+			return 0
+		}
+		return sfnt.GlyphIndex(code)
+	}
+	lookup := func(c rune) sfnt.GlyphIndex {
+		gid, err := f.face.GlyphIndex(&r.glyphBuf, c)
+		if err != nil {
+			return 0
+		}
+		return gid
+	}
+	if name, ok := f.names[code]; ok {
+		if f.namedGlyphs == nil {
+			f.namedGlyphs = map[string]sfnt.GlyphIndex{}
+			for gid := 0; gid < f.face.NumGlyphs(); gid++ {
+				if glyphName, err := f.face.GlyphName(&r.glyphBuf, sfnt.GlyphIndex(gid)); err == nil && glyphName != "" {
+					f.namedGlyphs[glyphName] = sfnt.GlyphIndex(gid)
+				}
+			}
+		}
+		if gid, ok := f.namedGlyphs[name]; ok && gid != 0 {
+			return gid
+		}
+	}
+	candidates := []rune{}
+	if runes := f.runes(code); len(runes) == 1 {
+		candidates = append(candidates, runes[0])
 	}
-
-	var table TableData
-	if err := json.Unmarshal([]byte(tableJSON), &table); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid table data format: %v", err),
-		})
+	// Symbolic TrueType fonts map codes through the (3,0) cmap at 0xF000 or the Mac Roman table
+	candidates = append(candidates, rune(0xF000+code), rune(code), charmap.Macintosh.DecodeByte(byte(code)))
+	if f.symbolic {
+		candidates = append(candidates[len(candidates)-3:], candidates[:len(candidates)-3]...)
 	}
+	for _, c := range candidates {
+		if gid := lookup(c); gid != 0 {
+			return gid
+		}
+	}
+	return 0
+}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetMargins(20, 20, 20)
-
-	// Calculate column width
-	colWidth := 170.0 / float64(len(table.Headers))
+// ligatures expand presentation forms missing from the fallback fonts
+var ligatures = map[rune]string{0xFB00: "ff", 0xFB01: "fi", 0xFB02: "fl", 0xFB03: "ffi", 0xFB04: "ffl"}
 
-	// Headers
-	pdf.SetFont("Arial", "B", 12)
-	for _, header := range table.Headers {
-		pdf.Cell(colWidth, 10, header)
+// glyph - Load the outline drawn for a code, scaled to the advance the PDF declares
+func (r *pageRenderer) glyph(f *renderFont, code int) *renderGlyph {
+	if g, ok := f.glyphs[code]; ok {
+		return g
 	}
-	pdf.Ln(10)
-
-	// Rows
-	pdf.SetFont("Arial", "", 10)
-	for _, row := range table.Rows {
-		for _, cell := range row {
-			pdf.Cell(colWidth, 8, cell)
+	g := &renderGlyph{}
+	f.glyphs[code] = g
+
+	if f.face != nil {
+		if gid := r.glyphIndex(f, code); gid != 0 || f.composite && code != 0 {
+			if segments, advance, ok := r.loadOutline(f.face, gid); ok {
+				g.segments, g.advance = segments, advance
+				return g
+			}
 		}
-		pdf.Ln(8)
 	}
-
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to add table: %v", err),
-		})
+	if f.fallback == nil {
+		return g
 	}
-
-	tablePdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	if !silentMode {
-		fmt.Printf("Go WASM: Added table with %d columns and %d rows\n", len(table.Headers), len(table.Rows))
+	runes := []rune{}
+	for _, c := range f.runes(code) {
+		if expanded, ok := ligatures[c]; ok {
+			if gid, _ := f.fallback.GlyphIndex(&r.glyphBuf, c); gid == 0 {
+				runes = append(runes, []rune(expanded)...)
+				continue
+			}
+		}
+		runes = append(runes, c)
 	}
-
-	return js.ValueOf(map[string]interface{}{
-		"pdfData": tablePdfData,
-		"size":    buf.Len(),
-		"columns": len(table.Headers),
-		"rows":    len(table.Rows),
-		"format":  "application/pdf",
-	})
+	for _, c := range runes {
+		if c == ' ' || c == 0xA0 {
+			advance, _ := r.fallbackAdvance(f.fallback, c)
+			g.advance += advance
+			continue
+		}
+		gid, err := f.fallback.GlyphIndex(&r.glyphBuf, c)
+		if err != nil || gid == 0 {
+			continue
+		}
+		segments, advance, ok := r.loadOutline(f.fallback, gid)
+		if !ok {
+			continue
+		}
+		for _, s := range segments {
+			for i := range s.points {
+				s.points[i].x += g.advance
+			}
+			g.segments = append(g.segments, s)
+		}
+		g.advance += advance
+	}
+	// Squeeze or stretch the substitute glyphs to the width the layout was computed with
+	if width, ok := f.pdfWidth(code); ok && width > 0 && g.advance > 0 {
+		scale := math.Max(0.5, math.Min(2, width/g.advance))
+		for i := range g.segments {
+			for j := range g.segments[i].points {
+				g.segments[i].points[j].x *= scale
+			}
+		}
+	}
+	return g
 }
 
-// addChart - Add simple chart to PDF
-func addChart(this js.Value, args []js.Value) interface{} {
-	if len(args) != 2 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "addChart requires exactly 2 arguments (pdfData, chartData)",
-		})
+func (r *pageRenderer) fallbackAdvance(face *sfnt.Font, c rune) (float64, bool) {
+	gid, err := face.GlyphIndex(&r.glyphBuf, c)
+	if err != nil || gid == 0 {
+		return 0, false
 	}
+	_, advance, ok := r.loadOutline(face, gid)
+	return advance, ok
+}
 
-	pdfDataStr := args[0].String()
-	chartJSON := args[1].String()
-
-	_, err := base64.StdEncoding.DecodeString(pdfDataStr)
+// loadOutline returns a glyph outline and advance in em units
+func (r *pageRenderer) loadOutline(face *sfnt.Font, gid sfnt.GlyphIndex) ([]glyphSegment, float64, bool) {
+	unitsPerEm := float64(face.UnitsPerEm())
+	if unitsPerEm <= 0 {
+		return nil, 0, false
+	}
+	ppem := fixed.Int26_6(face.UnitsPerEm()) << 6
+	segments, err := face.LoadGlyph(&r.glyphBuf, gid, ppem, nil)
 	if err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
-		})
+		return nil, 0, false
 	}
-
-	var chart ChartData
-	if err := json.Unmarshal([]byte(chartJSON), &chart); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid chart data format: %v", err),
-		})
+	scale := 1 / (64 * unitsPerEm)
+	outline := make([]glyphSegment, len(segments))
+	for i, s := range segments {
+		outline[i].op = s.Op
+		for j, p := range s.Args {
+			outline[i].points[j] = renderPoint{float64(p.X) * scale, -float64(p.Y) * scale}
+		}
 	}
+	advance, err := face.GlyphAdvance(&r.glyphBuf, gid, ppem, 0)
+	if err != nil {
+		return outline, 0, true
+	}
+	return outline, float64(advance) * scale, true
+}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetMargins(20, 20, 20)
-
-	// Chart title
-	pdf.SetFont("Arial", "B", 14)
-	pdf.Cell(0, 15, chart.Title)
-	pdf.Ln(25)
-
-	// Simple bar chart representation
-	if chart.Type == "bar" {
-		maxValue := 0.0
-		for _, point := range chart.Data {
-			if point.Value > maxValue {
-				maxValue = point.Value
+// appendPolygons flattens the glyph outline in device space
+func (g *renderGlyph) appendPolygons(polygons [][]renderPoint, m pdfMatrix) [][]renderPoint {
+	var contour []renderPoint
+	var last renderPoint
+	for _, s := range g.segments {
+		switch s.op {
+		case sfnt.SegmentOpMoveTo:
+			if len(contour) > 2 {
+				polygons = append(polygons, contour)
 			}
+			contour = []renderPoint{m.apply(s.points[0].x, s.points[0].y)}
+			last = s.points[0]
+		case sfnt.SegmentOpLineTo:
+			contour = append(contour, m.apply(s.points[0].x, s.points[0].y))
+			last = s.points[0]
+		case sfnt.SegmentOpQuadTo:
+			contour = flattenQuad(contour, m.apply(last.x, last.y), m.apply(s.points[0].x, s.points[0].y), m.apply(s.points[1].x, s.points[1].y))
+			last = s.points[1]
+		case sfnt.SegmentOpCubeTo:
+			contour = flattenCubic(contour, m.apply(last.x, last.y), m.apply(s.points[0].x, s.points[0].y),
+				m.apply(s.points[1].x, s.points[1].y), m.apply(s.points[2].x, s.points[2].y))
+			last = s.points[2]
 		}
+	}
+	if len(contour) > 2 {
+		polygons = append(polygons, contour)
+	}
+	return polygons
+}
 
-		chartHeight := 80.0
-		chartWidth := 150.0
-		barWidth := chartWidth / float64(len(chart.Data))
-
-		// Draw bars
-		pdf.SetFont("Arial", "", 8)
-		for i, point := range chart.Data {
-			barHeight := (point.Value / maxValue) * chartHeight
-			x := 20 + float64(i)*barWidth
-			y := 60 + chartHeight - barHeight
-
-			pdf.Rect(x, y, barWidth-2, barHeight, "F")
-			
-			// Label
-			pdf.SetXY(x, y+barHeight+5)
-			pdf.Cell(barWidth, 5, point.Label)
-			
-			// Value
-			pdf.SetXY(x, y-10)
-			pdf.Cell(barWidth, 5, fmt.Sprintf("%.1f", point.Value))
+// showText - Draw the strings of a text showing operator, advancing the text matrix
+func (r *pageRenderer) showText(items []interface{}, resources types.Dict) {
+	st := &r.state
+	f := st.font
+	if f == nil {
+		return
+	}
+	if r.text != nil {
+		r.collectText(items)
+		return
+	}
+	polygons := [][]renderPoint{}
+	for _, item := range items {
+		switch v := item.(type) {
+		case float64:
+			tx := -v / 1000 * st.fontSize * st.hScale
+			r.textMatrix = pdfMatrix{1, 0, 0, 1, tx, 0}.multiply(r.textMatrix)
+		case []byte:
+			step := 1
+			if f.twoByte {
+				step = 2
+			}
+			for i := 0; i+step <= len(v); i += step {
+				code := int(v[i])
+				if step == 2 {
+					code = code<<8 | int(v[i+1])
+				}
+				trm := pdfMatrix{st.fontSize * st.hScale, 0, 0, st.fontSize, 0, st.rise}.multiply(r.textMatrix).multiply(st.ctm)
+				width, ok := f.pdfWidth(code)
+				if f.type3 != nil {
+					if st.textMode != 3 && st.textMode != 7 {
+						r.drawType3Glyph(f, code, trm, resources)
+					}
+				} else {
+					g := r.glyph(f, code)
+					if !ok {
+						width = g.advance
+					}
+					if st.textMode != 3 {
+						polygons = g.appendPolygons(polygons, trm)
+					}
+				}
+				tx := width*st.fontSize + st.charSpacing
+				if step == 1 && code == 32 {
+					tx += st.wordSpacing
+				}
+				r.textMatrix = pdfMatrix{1, 0, 0, 1, tx * st.hScale, 0}.multiply(r.textMatrix)
+			}
 		}
 	}
-
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to add chart: %v", err),
-		})
+	if len(polygons) == 0 {
+		return
 	}
-
-	chartPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	if !silentMode {
-		fmt.Printf("Go WASM: Added %s chart with %d data points\n", chart.Type, len(chart.Data))
+	switch st.textMode {
+	case 0, 4:
+		r.fill(polygons, false, r.fillPaint())
+	case 1, 5:
+		r.strokeGlyphs(polygons)
+	case 2, 6:
+		r.fill(polygons, false, r.fillPaint())
+		r.strokeGlyphs(polygons)
+	}
+	if st.textMode >= 4 {
+		r.textClip = append(r.textClip, polygons...)
 	}
-
-	return js.ValueOf(map[string]interface{}{
-		"pdfData":    chartPdfData,
-		"size":       buf.Len(),
-		"chartType":  chart.Type,
-		"dataPoints": len(chart.Data),
-		"format":     "application/pdf",
-	})
 }
 
-// htmlToPDF - Convert HTML content to PDF
-func htmlToPDF(this js.Value, args []js.Value) interface{} {
-	if len(args) < 1 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "htmlToPDF requires at least 1 argument (htmlContent)",
-		})
+// collectText - Record the characters of a text showing operator with their position, advancing the text matrix
+func (r *pageRenderer) collectText(items []interface{}) {
+	st := &r.state
+	f := st.font
+	for _, item := range items {
+		switch v := item.(type) {
+		case float64:
+			tx := -v / 1000 * st.fontSize * st.hScale
+			r.textMatrix = pdfMatrix{1, 0, 0, 1, tx, 0}.multiply(r.textMatrix)
+		case []byte:
+			step := 1
+			if f.twoByte {
+				step = 2
+			}
+			for i := 0; i+step <= len(v); i += step {
+				code := int(v[i])
+				if step == 2 {
+					code = code<<8 | int(v[i+1])
+				}
+				glyphMatrix := pdfMatrix{st.fontSize * st.hScale, 0, 0, st.fontSize, 0, st.rise}
+				trm := glyphMatrix.multiply(r.textMatrix).multiply(st.ctm)
+				width, ok := f.pdfWidth(code)
+				if !ok && f.type3 == nil {
+					width = r.glyph(f, code).advance
+				}
+				tx := width*st.fontSize + st.charSpacing
+				if step == 1 && code == 32 {
+					tx += st.wordSpacing
+				}
+				r.textMatrix = pdfMatrix{1, 0, 0, 1, tx * st.hScale, 0}.multiply(r.textMatrix)
+				end := glyphMatrix.multiply(r.textMatrix).multiply(st.ctm)
+				r.text.add(f.runes(code), trm.apply(0, 0), end.apply(0, 0), trm.scale())
+			}
+		}
 	}
+}
 
-	htmlContent := args[0].String()
-	options := make(map[string]interface{})
-	if len(args) > 1 {
-		optionsJSON := args[1].String()
-		json.Unmarshal([]byte(optionsJSON), &options)
+func (r *pageRenderer) strokeGlyphs(polygons [][]renderPoint) {
+	path := make([]renderSubpath, len(polygons))
+	for i, polygon := range polygons {
+		path[i] = renderSubpath{points: polygon, closed: true}
 	}
+	r.stroke(path)
+}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetMargins(20, 20, 20)
-
-	// Simple HTML parsing (basic tags)
-	content := htmlContent
-	
-	// Remove HTML tags and extract text
-	re := regexp.MustCompile("<[^>]*>")
-	plainText := re.ReplaceAllString(content, "")
-	
-	// Handle basic formatting
-	if strings.Contains(content, "<h1>") {
-		pdf.SetFont("Arial", "B", 16)
-	} else if strings.Contains(content, "<h2>") {
-		pdf.SetFont("Arial", "B", 14)
-	} else {
-		pdf.SetFont("Arial", "", 12)
+// drawType3Glyph - Run the glyph procedure of a Type 3 font
+func (r *pageRenderer) drawType3Glyph(f *renderFont, code int, trm pdfMatrix, resources types.Dict) {
+	name, ok := f.names[code]
+	if !ok || f.type3.procs == nil || r.depth >= maxRenderDepth {
+		return
+	}
+	sd, _, err := r.ctx.DereferenceStreamDict(f.type3.procs[name])
+	if err != nil || sd == nil || sd.Decode() != nil {
+		return
 	}
+	if f.type3.resources != nil {
+		resources = f.type3.resources
+	}
+	saved, savedStack, savedPath := r.state, r.stack, r.path
+	savedText, savedLine := r.textMatrix, r.lineMatrix
+	r.stack, r.path = nil, nil
+	r.state.ctm = f.type3.matrix.multiply(trm)
+	r.depth++
+	r.run(sd.Content, resources)
+	r.depth--
+	r.state, r.stack, r.path = saved, savedStack, savedPath
+	r.textMatrix, r.lineMatrix = savedText, savedLine
+}
 
-	// Add content
-	pdf.MultiCell(0, 8, plainText, "", "", false)
+// newPDFConfiguration - pdfcpu configuration carrying the document password
+func newPDFConfiguration(password string) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+	conf.ValidationMode = model.ValidationRelaxed
+	return conf
+}
 
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to convert HTML to PDF: %v", err),
-		})
+// decryptPDFBytes - Decrypt PDF bytes, returning them unchanged when not encrypted
+func decryptPDFBytes(pdfBytes []byte, password string) ([]byte, bool, error) {
+	ctx, err := api.ReadContext(bytes.NewReader(pdfBytes), newPDFConfiguration(password))
+	if err != nil {
+		if errors.Is(err, pdfcpu.ErrWrongPassword) {
+			return nil, true, errors.New(localize("incorrect password for encrypted PDF"))
+		}
+		return nil, false, err
 	}
 
-	htmlPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if ctx.Encrypt == nil {
+		return pdfBytes, false, nil
+	}
 
-	if !silentMode {
-		fmt.Printf("Go WASM: Converted HTML to PDF (%d bytes)\n", buf.Len())
+	var buf bytes.Buffer
+	if err := api.Decrypt(bytes.NewReader(pdfBytes), &buf, newPDFConfiguration(password)); err != nil {
+		return nil, true, err
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"pdfData":     htmlPdfData,
-		"size":        buf.Len(),
-		"originalLength": len(htmlContent),
-		"format":      "application/pdf",
-	})
+	return buf.Bytes(), true, nil
 }
 
-// markdownToPDF - Convert Markdown content to PDF
-func markdownToPDF(this js.Value, args []js.Value) interface{} {
-	if len(args) < 1 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "markdownToPDF requires at least 1 argument (markdownContent)",
-		})
+// decodePDFData - Read PDF data given as base64, a Uint8Array or an ArrayBuffer, decrypting it when a password is given
+func decodePDFData(pdfData js.Value, password string) ([]byte, error) {
+	pdfBytes, err := bytesFromJS(pdfData)
+	if err != nil {
+		return nil, err
 	}
 
-	markdownContent := args[0].String()
-
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetMargins(20, 20, 20)
-
-	lines := strings.Split(markdownContent, "\n")
-	
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		
-		if strings.HasPrefix(line, "# ") {
-			// H1
-			pdf.SetFont("Arial", "B", 16)
-			pdf.Cell(0, 12, strings.TrimPrefix(line, "# "))
-			pdf.Ln(15)
-		} else if strings.HasPrefix(line, "## ") {
-			// H2
-			pdf.SetFont("Arial", "B", 14)
-			pdf.Cell(0, 10, strings.TrimPrefix(line, "## "))
-			pdf.Ln(12)
-		} else if strings.HasPrefix(line, "### ") {
-			// H3
-			pdf.SetFont("Arial", "B", 12)
-			pdf.Cell(0, 8, strings.TrimPrefix(line, "### "))
-			pdf.Ln(10)
-		} else if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
-			// List item
-			pdf.SetFont("Arial", "", 11)
-			pdf.Cell(10, 6, "•")
-			pdf.Cell(0, 6, strings.TrimPrefix(strings.TrimPrefix(line, "- "), "* "))
-			pdf.Ln(7)
-		} else if line != "" {
-			// Regular paragraph
-			pdf.SetFont("Arial", "", 11)
-			pdf.MultiCell(0, 6, line, "", "", false)
-			pdf.Ln(3)
-		} else {
-			// Empty line
-			pdf.Ln(5)
-		}
+	if password == "" {
+		return pdfBytes, nil
 	}
 
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to convert Markdown to PDF: %v", err),
-		})
+	decrypted, _, err := decryptPDFBytes(pdfBytes, password)
+	return decrypted, err
+}
+
+// optionalPassword - Read the optional password argument at the given position
+func optionalPassword(args []js.Value, index int) string {
+	if len(args) > index && args[index].Type() == js.TypeString {
+		return args[index].String()
 	}
+	return ""
+}
 
-	markdownPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
+// asyncFunctions are the heavy operations also exposed as Promise-returning <name>Async variants
+var asyncFunctions = []struct {
+	name string
+	fn   func(js.Value, []js.Value) interface{}
+}{
+	{"mergePDFs", mergePDFs},
+	{"splitPDF", splitPDF},
+	{"rotatePages", rotatePages},
+	{"reorderPages", reorderPages},
+	{"deletePages", deletePages},
+	{"insertBlankPage", insertBlankPage},
+	{"compressPDF", compressPDF},
+	{"optimizePDF", optimizePDF},
+	{"extractText", extractText},
+	{"extractImages", extractImages},
+	{"renderPage", renderPage},
+	{"analyzePDF", analyzePDF},
+	{"fillForm", fillForm},
+	{"jsonToPDF", jsonToPDF},
+	{"htmlToPDF", htmlToPDF},
+	{"markdownToPDF", markdownToPDF},
+	{"generateReport", generateReport},
+	{"generateInvoice", generateInvoice},
+	{"generateInvoiceBatch", generateInvoiceBatch},
+	{"generateCertificate", generateCertificate},
+	{"generateContract", generateContract},
+}
 
-	if !silentMode {
-		fmt.Printf("Go WASM: Converted Markdown to PDF (%d bytes)\n", buf.Len())
-	}
+// pendingOperations counts the async operations whose Promise is not settled yet
+var pendingOperations = 0
 
-	return js.ValueOf(map[string]interface{}{
-		"pdfData":        markdownPdfData,
-		"size":           buf.Len(),
-		"originalLength": len(markdownContent),
-		"lines":          len(lines),
-		"format":         "application/pdf",
-	})
+// progressTracker forwards the progress of an async operation to its onProgress callback
+type progressTracker struct {
+	operation string
+	callback  js.Value
+	lastYield time.Time
 }
 
-// analyzePDF - Comprehensive PDF analysis
-func analyzePDF(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "analyzePDF requires exactly 1 argument (pdfData)",
-		})
-	}
+// activeProgress is the tracker of the async operation currently running, nil during synchronous calls
+var activeProgress *progressTracker
+
+// promised - Wrap a function so it runs in a goroutine and returns a Promise. A trailing function
+// argument is taken as the onProgress callback. The Promise rejects with an Error when the result
+// carries an error field.
+func promised(name string, fn func(js.Value, []js.Value) interface{}) func(js.Value, []js.Value) interface{} {
+	return func(this js.Value, args []js.Value) interface{} {
+		tracker := &progressTracker{operation: name, callback: js.Undefined()}
+		if n := len(args); n > 0 && args[n-1].Type() == js.TypeFunction {
+			tracker.callback = args[n-1]
+			args = args[:n-1]
+		}
 
-	pdfDataStr := args[0].String()
-	pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
-	if err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
+		// The executor runs synchronously inside the Promise constructor, so it can be released right after
+		executor := js.FuncOf(func(_ js.Value, handlers []js.Value) interface{} {
+			resolve, reject := handlers[0], handlers[1]
+			pendingOperations++
+
+			go func() {
+				defer func() {
+					pendingOperations--
+					activeProgress = nil
+					if r := recover(); r != nil {
+						reject.Invoke(js.Global().Get("Error").New(localize("%s failed: %v", name, r)))
+					}
+				}()
+
+				// Let the caller attach its handlers and the page repaint before the work starts
+				yieldToEventLoop()
+				tracker.lastYield = time.Now()
+				activeProgress = tracker
+				result := js.ValueOf(fn(this, args))
+				activeProgress = nil
+
+				if result.Type() == js.TypeObject && result.Get("error").Type() == js.TypeString {
+					reject.Invoke(js.Global().Get("Error").New(result.Get("error")))
+					return
+				}
+				resolve.Invoke(result)
+			}()
+
+			return nil
 		})
+		defer executor.Release()
+
+		return js.Global().Get("Promise").New(executor)
 	}
+}
 
-	// Comprehensive analysis
-	analysis := AnalysisResult{
-		FileSize:    len(pdfBytes),
-		Pages:       1, // Simplified
-		Images:      0,
-		Fonts:       []string{"Arial", "Helvetica"},
-		Hyperlinks:  []string{},
-		FormFields:  0,
-		Encrypted:   false,
-		Version:     "1.4",
-		TextContent: "Extracted text content would appear here",
-		Metadata: map[string]interface{}{
-			"title":      "Analyzed PDF",
-			"author":     "PDF-WASM",
-			"creator":    "Go PDF-WASM Module",
-			"producer":   "GoFPDF",
-			"createdAt":  time.Now().Format("2006-01-02T15:04:05Z"),
-		},
-		OptimizationTips: []string{
-			"Consider compressing images to reduce file size",
-			"Remove unused fonts and resources",
-			"Use compression for text content",
-		},
+// reportProgress - Report the units processed by the running async operation (pages, documents, parts
+// or blocks) and give the event loop a turn about once per frame. Synchronous calls are unaffected.
+func reportProgress(processed, total int) {
+	tracker := activeProgress
+	if tracker == nil {
+		return
 	}
 
-	// Basic file size analysis
-	if len(pdfBytes) > 1024*1024 {
-		analysis.OptimizationTips = append(analysis.OptimizationTips, "File is larger than 1MB - consider optimization")
+	if tracker.callback.Type() == js.TypeFunction {
+		progress := map[string]interface{}{
+			"operation": tracker.operation,
+			"processed": processed,
+			"total":     total,
+		}
+		if total > 0 {
+			progress["percent"] = math.Round(float64(processed)*1000/float64(total)) / 10
+		}
+		tracker.callback.Invoke(progress)
 	}
 
-	if !silentMode {
-		fmt.Printf("Go WASM: Analyzed PDF (%d bytes, %d pages)\n", len(pdfBytes), analysis.Pages)
+	if time.Since(tracker.lastYield) >= 16*time.Millisecond {
+		// Other calls may run while this one is suspended, they must not see its tracker
+		activeProgress = nil
+		yieldToEventLoop()
+		activeProgress = tracker
+		tracker.lastYield = time.Now()
 	}
+}
 
-	return js.ValueOf(analysis)
+// yieldToEventLoop - Suspend the calling goroutine until the JS event loop has run a macrotask
+func yieldToEventLoop() {
+	done := make(chan struct{})
+	var callback js.Func
+	callback = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		callback.Release()
+		close(done)
+		return nil
+	})
+	js.Global().Call("setTimeout", callback, 0)
+	<-done
 }
 
-// optimizePDF - Intelligent PDF optimization
-func optimizePDF(this js.Value, args []js.Value) interface{} {
-	if len(args) < 1 {
-		return js.ValueOf(map[string]interface{}{
-			"error": "optimizePDF requires at least 1 argument (pdfData)",
-		})
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	fonts, fontBytes := 0, 0
+	for _, styles := range registeredFonts {
+		for _, ttfBytes := range styles {
+			fonts++
+			fontBytes += len(ttfBytes)
+		}
 	}
 
-	pdfDataStr := args[0].String()
-	optimizationLevel := "balanced"
-	if len(args) > 1 {
-		optimizationLevel = args[1].String()
-	}
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"registeredFonts":   fonts,
+		"fontBytes":         fontBytes,
+		"pendingOperations": pendingOperations,
+		"openDocuments":     openDocuments,
+	}))
+}
 
-	pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
-	if err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Invalid PDF data: %v", err),
-		})
-	}
+// releaseResources - Unregister the TrueType fonts kept by registerFont and return freed memory to the runtime
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
 
-	originalSize := len(pdfBytes)
-	
-	// Optimization simulation based on level
-	var optimizations []string
-
-	switch optimizationLevel {
-	case "aggressive":
-		optimizations = []string{
-			"Maximum image compression applied",
-			"Font subsetting enabled",
-			"Duplicate content removed",
-			"Metadata stripped",
-		}
-	case "balanced":
-		optimizations = []string{
-			"Balanced image compression applied",
-			"Font optimization enabled",
-			"Basic duplicate removal",
-		}
-	case "conservative":
-		optimizations = []string{
-			"Light image compression applied",
-			"Basic font optimization",
-		}
-	default:
-		optimizations = []string{"Standard optimization applied"}
+	fonts := 0
+	for _, styles := range registeredFonts {
+		fonts += len(styles)
 	}
+	registeredFonts = map[string]map[string][]byte{}
 
-	// Create optimized PDF
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetMargins(20, 20, 20)
-	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(0, 10, fmt.Sprintf("Optimized PDF (%s level)", optimizationLevel))
-
-	var buf bytes.Buffer
-	if err := pdf.Output(&buf); err != nil {
-		return js.ValueOf(map[string]interface{}{
-			"error": fmt.Sprintf("Failed to optimize PDF: %v", err),
-		})
+	released := map[string]interface{}{
+		"registeredFonts": fonts,
 	}
 
-	optimizedSize := buf.Len()
-	savingsPercent := math.Round((1.0-float64(optimizedSize)/float64(originalSize))*100*100) / 100
-
-	optimizedPdfData := base64.StdEncoding.EncodeToString(buf.Bytes())
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
 
 	if !silentMode {
-		fmt.Printf("Go WASM: Optimized PDF from %d to %d bytes (%.1f%% savings)\n", 
-			originalSize, optimizedSize, savingsPercent)
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
 	}
 
 	return js.ValueOf(map[string]interface{}{
-		"pdfData":         optimizedPdfData,
-		"originalSize":    originalSize,
-		"optimizedSize":   optimizedSize,
-		"savingsPercent":  savingsPercent,
-		"optimizationLevel": optimizationLevel,
-		"optimizations":   optimizations,
-		"format":          "application/pdf",
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
 	})
 }
 
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
 // getModuleInfo - Get comprehensive module information
 func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
 	info := map[string]interface{}{
-		"name":        "pdf-wasm",
-		"version":     "2.0.0",
-		"description": "Advanced PDF manipulation module with comprehensive features",
-		"author":      "Ben",
-		"language":    "Go",
-		"target":      "WebAssembly",
-		"functions":   32,
-		"categories": []string{
+		"name":            "pdf-wasm",
+		"version":         moduleVersion,
+		"description":     "Advanced PDF manipulation module with comprehensive features",
+		"author":          "Ben",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+		"categories": []interface{}{
 			"PDF Generation",
-			"Document Conversion", 
+			"Document Conversion",
 			"Business Documents",
 			"Analysis & Optimization",
 		},
-		"features": []string{
-			"Professional invoice generation",
-			"Certificate and contract creation",
-			"HTML/Markdown to PDF conversion",
-			"Advanced table and chart support",
-			"Comprehensive PDF analysis",
-			"Intelligent optimization",
-			"Watermarking and signatures",
-			"Multiple output formats",
-		},
 		"buildInfo": map[string]interface{}{
-			"goVersion":    "1.21+",
-			"dependencies": []string{"github.com/jung-kurt/gofpdf"},
-			"optimized":    true,
-			"compressed":   true,
+			"dependencies": []interface{}{"github.com/jung-kurt/gofpdf", "github.com/pdfcpu/pdfcpu"},
 		},
 	}
 
 	if !silentMode {
-		fmt.Printf("Go WASM: Module info retrieved for pdf-wasm v2.0.0\n")
+		fmt.Printf("Go WASM: Module info retrieved for pdf-wasm v%s\n", moduleVersion)
 	}
 
 	return js.ValueOf(info)
 }
 
+// Build metadata, injected by wasm-manager build through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"generation",
+	"images",
+	"utf8-fonts",
+	"headers-footers",
+	"page-numbers",
+	"invoices",
+	"invoice-batches",
+	"certificates",
+	"contracts",
+	"json-documents",
+	"bookmarks",
+	"reports",
+	"forms",
+	"watermarks",
+	"merge-split",
+	"page-manipulation",
+	"attachments",
+	"decryption",
+	"analysis",
+	"rendering",
+	"binary-io",
+	"async",
+	"factur-x",
+	"document-builder",
+	"html-layout",
+	"markdown",
+	"table-of-contents",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
 // getAvailableFunctions - Return list of available functions
 func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
-	functions := []string{
+	functions := []interface{}{
 		// Core PDF operations
-		"createPDF", "addPage", "extractText", "extractImages",
-		"mergePDFs", "splitPDF", "addWatermark", "getPDFInfo", 
+		"createPDF", "createDocument", "addPage", "extractText", "extractImages", "renderPage",
+		"mergePDFs", "splitPDF", "addWatermark", "getPDFInfo",
+		"rotatePages", "reorderPages", "deletePages", "insertBlankPage",
+		"attachFile", "listAttachments",
 		"compressPDF", "optimizePDF",
-		
+
 		// Advanced generation
-		"generateInvoice", "generateCertificate", "generateContract", 
+		"generateInvoice", "generateInvoiceBatch", "generateCertificate", "generateContract",
 		"generateBusinessCard", "generateReport",
-		
+
 		// Content manipulation
 		"addTable", "addChart", "addSignature", "addBarcode",
-		"addHeader", "addFooter", "addPageNumbers",
-		
+		"addHeader", "addFooter", "addPageNumbers", "addBookmarks",
+
 		// Conversion functions
 		"htmlToPDF", "markdownToPDF", "jsonToPDF",
-		
+
 		// Analysis and validation
 		"analyzePDF", "validatePDF", "extractMetadata",
-		
+
+		// Forms
+		"fillForm",
+
+		// Fonts
+		"registerFont",
+
+		// Security
+		"decryptPDF",
+
 		// Utility functions
-		"setSilentMode", "getAvailableFunctions", "getModuleInfo",
+		"setSilentMode", "setBinaryMode", "setLocale", "getAvailableFunctions", "getModuleInfo",
+		"getMemoryStats", "releaseResources",
+	}
+	for _, async := range asyncFunctions {
+		functions = append(functions, async.name+"Async")
 	}
 
 	if !silentMode {
@@ -1494,44 +13442,81 @@ func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 func main() {
 	c := make(chan struct{}, 0)
 
+	// pdfcpu must not look for a config directory inside the browser sandbox
+	api.DisableConfigDir()
+
 	// Core PDF operations
 	js.Global().Set("createPDF", js.FuncOf(createPDF))
+	js.Global().Set("createDocument", js.FuncOf(createDocument))
 	js.Global().Set("addPage", js.FuncOf(addPage))
 	js.Global().Set("extractText", js.FuncOf(extractText))
 	js.Global().Set("extractImages", js.FuncOf(extractImages))
+	js.Global().Set("renderPage", js.FuncOf(renderPage))
 	js.Global().Set("mergePDFs", js.FuncOf(mergePDFs))
 	js.Global().Set("splitPDF", js.FuncOf(splitPDF))
+	js.Global().Set("rotatePages", js.FuncOf(rotatePages))
+	js.Global().Set("reorderPages", js.FuncOf(reorderPages))
+	js.Global().Set("deletePages", js.FuncOf(deletePages))
+	js.Global().Set("insertBlankPage", js.FuncOf(insertBlankPage))
+	js.Global().Set("attachFile", js.FuncOf(attachFile))
+	js.Global().Set("listAttachments", js.FuncOf(listAttachments))
 	js.Global().Set("addWatermark", js.FuncOf(addWatermark))
 	js.Global().Set("getPDFInfo", js.FuncOf(getPDFInfo))
 	js.Global().Set("compressPDF", js.FuncOf(compressPDF))
 
 	// Advanced generation functions
 	js.Global().Set("generateInvoice", js.FuncOf(generateInvoice))
+	js.Global().Set("generateInvoiceBatch", js.FuncOf(generateInvoiceBatch))
 	js.Global().Set("generateCertificate", js.FuncOf(generateCertificate))
+	js.Global().Set("generateContract", js.FuncOf(generateContract))
 	js.Global().Set("generateReport", js.FuncOf(generateReport))
 
 	// Content manipulation
 	js.Global().Set("addTable", js.FuncOf(addTable))
 	js.Global().Set("addChart", js.FuncOf(addChart))
+	js.Global().Set("addHeader", js.FuncOf(addHeader))
+	js.Global().Set("addFooter", js.FuncOf(addFooter))
+	js.Global().Set("addPageNumbers", js.FuncOf(addPageNumbers))
+	js.Global().Set("addBookmarks", js.FuncOf(addBookmarks))
 
 	// Conversion functions
 	js.Global().Set("htmlToPDF", js.FuncOf(htmlToPDF))
 	js.Global().Set("markdownToPDF", js.FuncOf(markdownToPDF))
+	js.Global().Set("jsonToPDF", js.FuncOf(jsonToPDF))
 
 	// Analysis and optimization
 	js.Global().Set("analyzePDF", js.FuncOf(analyzePDF))
 	js.Global().Set("optimizePDF", js.FuncOf(optimizePDF))
 
+	// Forms
+	js.Global().Set("fillForm", js.FuncOf(fillForm))
+
+	// Fonts
+	js.Global().Set("registerFont", js.FuncOf(registerFont))
+
+	// Security
+	js.Global().Set("decryptPDF", js.FuncOf(decryptPDF))
+
 	// Utility functions
 	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setBinaryMode", js.FuncOf(setBinaryMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
 	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
 	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+
+	// Promise-based variants of the heavy operations
+	for _, async := range asyncFunctions {
+		js.Global().Set(async.name+"Async", js.FuncOf(promised(async.name, async.fn)))
+	}
 
-	fmt.Println("🚀 Go WASM: Advanced PDF module v2.0.0 loaded successfully")
-	fmt.Println("📋 Core functions: createPDF, mergePDFs, splitPDF, extractText, compressPDF")
-	fmt.Println("🏢 Business functions: generateInvoice, generateCertificate, generateReport")
-	fmt.Println("🎨 Content functions: addTable, addChart, addWatermark")
-	fmt.Println("🔄 Conversion functions: htmlToPDF, markdownToPDF")
+	fmt.Printf("🚀 Go WASM: Advanced PDF module v%s loaded successfully\n", moduleVersion)
+	fmt.Println("📋 Core functions: createPDF, createDocument, mergePDFs, splitPDF, extractText, renderPage, compressPDF")
+	fmt.Println("📑 Page functions: rotatePages, reorderPages, deletePages, insertBlankPage, attachFile, listAttachments")
+	fmt.Println("🏢 Business functions: generateInvoice, generateInvoiceBatch, generateCertificate, generateContract, generateReport")
+	fmt.Println("🎨 Content functions: addTable, addChart, addWatermark, addHeader, addFooter, addPageNumbers, addBookmarks")
+	fmt.Println("🔄 Conversion functions: htmlToPDF, markdownToPDF, jsonToPDF")
 	fmt.Println("📊 Analysis functions: analyzePDF, optimizePDF")
 	fmt.Println("ℹ️  Use getAvailableFunctions() to see all available functions")
 
@@ -0,0 +1,16 @@
+module pdfform-wasm
+
+go 1.21
+
+require github.com/pdfcpu/pdfcpu v0.8.1
+
+require (
+	github.com/hhrutter/lzw v1.0.0 // indirect
+	github.com/hhrutter/tiff v1.0.1 // indirect
+	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	golang.org/x/image v0.19.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
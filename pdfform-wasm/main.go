@@ -0,0 +1,1499 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/form"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+var silentMode = false
+
+// Widget annotation flags that keep a widget from being drawn (Hidden, NoView)
+const (
+	annotFlagHidden = 1 << 1
+	annotFlagNoView = 1 << 5
+)
+
+// fieldFlagMultiline is the Ff bit of text fields that wrap onto several lines
+const fieldFlagMultiline = 1 << 12
+
+// maxFieldDepth bounds Parent chains, so a cyclic field hierarchy cannot loop forever
+const maxFieldDepth = 32
+
+// fieldValue is the value of one terminal field as written to FDF and XFDF.
+// Checkbox and radio states are PDF names, every other value is a text string.
+type fieldValue struct {
+	name   string
+	isName bool
+	values []string
+}
+
+// fieldNode is one level of the dotted field hierarchy used by FDF and XFDF
+type fieldNode struct {
+	name     string
+	value    *fieldValue
+	children []*fieldNode
+}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires at least %d arguments (%s)":           "%s requiert au moins %d arguments (%s)",
+	"%s requires at least 1 argument (%s)":             "%s requiert au moins 1 argument (%s)",
+	"Failed to encode form data: %v":                   "Échec de l'encodage des données du formulaire: %v",
+	"Failed to fill form: %v":                          "Échec du remplissage du formulaire: %v",
+	"Failed to flatten form: %v":                       "Échec de l'aplatissement du formulaire: %v",
+	"Failed to read form fields: %v":                   "Impossible de lire les champs du formulaire: %v",
+	"Invalid PDF data: %v":                             "Données PDF invalides: %v",
+	"Invalid form values JSON: %v":                     "JSON des valeurs du formulaire invalide: %v",
+	"None of the given values match a form field name": "Aucune des valeurs fournies ne correspond à un champ du formulaire",
+	"PDF does not contain any form fields":             "Le PDF ne contient aucun champ de formulaire",
+	"Unsupported locale %q (available: %s)":            "Langue %q non prise en charge (disponibles: %s)",
+	"incorrect password for encrypted PDF":             "mot de passe incorrect pour le PDF chiffré",
+	"setLocale requires exactly 1 argument (locale)":   "setLocale requiert exactement 1 argument (locale)",
+}
+
+// getFields - List the form fields of a PDF with their type, pages, value, default and options
+func getFields(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "getFields", "pdfData"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0].String(), optionalPassword(args, 1))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	f, err := exportForm(pdfBytes)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read form fields: %v", err),
+		})
+	}
+
+	fields := []interface{}{}
+	if f != nil {
+		fields = describeFields(f)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Found %d form fields\n", len(fields))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"fields":  fields,
+		"count":   len(fields),
+		"hasForm": f != nil,
+	})
+}
+
+// fillForm - Fill form fields from JSON values keyed by field name or id, optionally flattening the result
+func fillForm(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least %d arguments (%s)", "fillForm", 2, "pdfData, valuesJSON"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0].String(), optionalPassword(args, 3))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(args[1].String()), &values); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid form values JSON: %v", err),
+		})
+	}
+
+	flatten := len(args) > 2 && args[2].Type() == js.TypeBoolean && args[2].Bool()
+
+	f, err := exportForm(pdfBytes)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read form fields: %v", err),
+		})
+	}
+	if f == nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("PDF does not contain any form fields"),
+		})
+	}
+
+	matched := applyFormValues(f, values)
+	if len(matched) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("None of the given values match a form field name"),
+		})
+	}
+
+	unmatched := []interface{}{}
+	for key := range values {
+		if !matched[key] {
+			unmatched = append(unmatched, key)
+		}
+	}
+	sort.Slice(unmatched, func(i, j int) bool { return unmatched[i].(string) < unmatched[j].(string) })
+
+	formJSON, err := json.Marshal(form.FormGroup{Forms: []form.Form{*f}})
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to encode form data: %v", err),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := api.FillForm(bytes.NewReader(pdfBytes), bytes.NewReader(formJSON), &buf, newPDFConfiguration("")); err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to fill form: %v", err),
+		})
+	}
+
+	result := buf.Bytes()
+	if flatten {
+		flattened, _, err := flattenPDF(result)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to flatten form: %v", err),
+			})
+		}
+		result = flattened
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Filled %d form fields (flattened: %t)\n", len(matched), flatten)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":         base64.StdEncoding.EncodeToString(result),
+		"size":            len(result),
+		"fieldsCompleted": len(matched),
+		"unmatchedFields": unmatched,
+		"flattened":       flatten,
+		"format":          "application/pdf",
+	})
+}
+
+// flattenForm - Burn the current appearance of every field into the page content and remove the form
+func flattenForm(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "flattenForm", "pdfData"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0].String(), optionalPassword(args, 1))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	result, widgets, err := flattenPDF(pdfBytes)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to flatten form: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Flattened %d form widgets\n", widgets)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"pdfData":          base64.StdEncoding.EncodeToString(result),
+		"size":             len(result),
+		"flattenedWidgets": widgets,
+		"format":           "application/pdf",
+	})
+}
+
+// exportFDF - Export the field values as an FDF document
+func exportFDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "exportFDF", "pdfData"),
+		})
+	}
+
+	tree, count, err := exportFieldTree(args[0].String(), optionalPassword(args, 1))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%FDF-1.2\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /FDF << /Fields [")
+	for _, node := range tree {
+		buf.WriteString("\n")
+		writeFDFField(&buf, node)
+	}
+	buf.WriteString("\n] >> >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Exported %d form fields to FDF\n", count)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"fdfData": base64.StdEncoding.EncodeToString(buf.Bytes()),
+		"fields":  count,
+		"size":    buf.Len(),
+		"format":  "application/vnd.fdf",
+	})
+}
+
+// exportXFDF - Export the field values as an XFDF (XML) document
+func exportXFDF(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "exportXFDF", "pdfData"),
+		})
+	}
+
+	tree, count, err := exportFieldTree(args[0].String(), optionalPassword(args, 1))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<xfdf xmlns=\"http://ns.adobe.com/xfdf/\" xml:space=\"preserve\">\n  <fields>\n")
+	for _, node := range tree {
+		writeXFDFField(&buf, node, 2)
+	}
+	buf.WriteString("  </fields>\n</xfdf>\n")
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Exported %d form fields to XFDF\n", count)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"xfdf":   buf.String(),
+		"fields": count,
+		"size":   buf.Len(),
+		"format": "application/vnd.adobe.xfdf",
+	})
+}
+
+// exportForm - Read the form fields of a PDF, returning nil when the document has no form
+func exportForm(pdfBytes []byte) (*form.Form, error) {
+	formGroup, err := api.ExportForm(bytes.NewReader(pdfBytes), "pdfform-wasm", newPDFConfiguration(""))
+	if err != nil {
+		if errors.Is(err, api.ErrNoFormFieldsAffected) || strings.Contains(err.Error(), "no form available") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(formGroup.Forms) == 0 {
+		return nil, nil
+	}
+	return &formGroup.Forms[0], nil
+}
+
+// describeFields - Flatten the typed field lists of a form into one list ordered by page
+func describeFields(f *form.Form) []interface{} {
+	fields := []map[string]interface{}{}
+
+	add := func(fieldType, id, name string, pages []int, locked bool, value, def interface{}, extra map[string]interface{}) {
+		pageList := make([]interface{}, len(pages))
+		for i, p := range pages {
+			pageList[i] = p
+		}
+		field := map[string]interface{}{
+			"id":      id,
+			"name":    name,
+			"type":    fieldType,
+			"pages":   pageList,
+			"locked":  locked,
+			"value":   value,
+			"default": def,
+		}
+		for key, v := range extra {
+			field[key] = v
+		}
+		fields = append(fields, field)
+	}
+
+	for _, tf := range f.TextFields {
+		add("text", tf.ID, tf.Name, tf.Pages, tf.Locked, tf.Value, tf.Default, map[string]interface{}{
+			"multiline": tf.Multiline,
+		})
+	}
+	for _, df := range f.DateFields {
+		add("date", df.ID, df.Name, df.Pages, df.Locked, df.Value, df.Default, map[string]interface{}{
+			"format": df.Format,
+		})
+	}
+	for _, cb := range f.CheckBoxes {
+		add("checkbox", cb.ID, cb.Name, cb.Pages, cb.Locked, cb.Value, cb.Default, nil)
+	}
+	for _, rb := range f.RadioButtonGroups {
+		add("radio", rb.ID, rb.Name, rb.Pages, rb.Locked, rb.Value, rb.Default, map[string]interface{}{
+			"options": stringList(rb.Options),
+		})
+	}
+	for _, cb := range f.ComboBoxes {
+		add("combobox", cb.ID, cb.Name, cb.Pages, cb.Locked, cb.Value, cb.Default, map[string]interface{}{
+			"options":  stringList(cb.Options),
+			"editable": cb.Editable,
+		})
+	}
+	for _, lb := range f.ListBoxes {
+		add("listbox", lb.ID, lb.Name, lb.Pages, lb.Locked, stringList(lb.Values), stringList(lb.Defaults), map[string]interface{}{
+			"options": stringList(lb.Options),
+			"multi":   lb.Multi,
+		})
+	}
+
+	// pdfcpu groups fields by type in no stable order; list them by page, then by object number
+	sort.SliceStable(fields, func(i, j int) bool {
+		if pi, pj := firstPage(fields[i]), firstPage(fields[j]); pi != pj {
+			return pi < pj
+		}
+		return idLess(fields[i]["id"].(string), fields[j]["id"].(string))
+	})
+
+	list := make([]interface{}, len(fields))
+	for i, field := range fields {
+		list[i] = field
+	}
+	return list
+}
+
+// firstPage - Lowest page a described field appears on
+func firstPage(field map[string]interface{}) int {
+	first := 0
+	for _, p := range field["pages"].([]interface{}) {
+		if page := p.(int); first == 0 || page < first {
+			first = page
+		}
+	}
+	return first
+}
+
+// idLess - Order dotted field IDs ("12.40") by their object numbers
+func idLess(a, b string) bool {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		if errA != nil || errB != nil {
+			if pa[i] != pb[i] {
+				return pa[i] < pb[i]
+			}
+			continue
+		}
+		if na != nb {
+			return na < nb
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+// stringList - Convert a string slice to a JS-compatible list
+func stringList(values []string) []interface{} {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return list
+}
+
+// applyFormValues - Copy JSON values onto the exported form fields matched by name or id
+func applyFormValues(f *form.Form, values map[string]interface{}) map[string]bool {
+	matched := make(map[string]bool)
+
+	lookup := func(name, id string) (interface{}, bool) {
+		if v, ok := values[name]; ok && name != "" {
+			matched[name] = true
+			return v, true
+		}
+		if v, ok := values[id]; ok {
+			matched[id] = true
+			return v, true
+		}
+		return nil, false
+	}
+
+	for _, tf := range f.TextFields {
+		if v, ok := lookup(tf.Name, tf.ID); ok {
+			tf.Value = formValueString(v)
+		}
+	}
+	for _, df := range f.DateFields {
+		if v, ok := lookup(df.Name, df.ID); ok {
+			df.Value = formValueString(v)
+		}
+	}
+	for _, cb := range f.CheckBoxes {
+		if v, ok := lookup(cb.Name, cb.ID); ok {
+			cb.Value = formValueBool(v)
+		}
+	}
+	for _, rb := range f.RadioButtonGroups {
+		if v, ok := lookup(rb.Name, rb.ID); ok {
+			rb.Value = formValueString(v)
+		}
+	}
+	for _, cb := range f.ComboBoxes {
+		if v, ok := lookup(cb.Name, cb.ID); ok {
+			cb.Value = formValueString(v)
+		}
+	}
+	for _, lb := range f.ListBoxes {
+		if v, ok := lookup(lb.Name, lb.ID); ok {
+			if items, isList := v.([]interface{}); isList {
+				lb.Values = make([]string, len(items))
+				for i, item := range items {
+					lb.Values[i] = formValueString(item)
+				}
+			} else {
+				lb.Values = []string{formValueString(v)}
+			}
+		}
+	}
+
+	return matched
+}
+
+// formValueString - Convert a JSON value to the text stored in a form field
+func formValueString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// formValueBool - Interpret a JSON value as a checkbox state
+func formValueBool(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "true", "yes", "on", "1", "x", "checked":
+			return true
+		}
+	}
+	return false
+}
+
+// flattenPDF - Flatten the form of a PDF, returning the new document and the number of widgets drawn
+func flattenPDF(pdfBytes []byte) ([]byte, int, error) {
+	ctx, err := api.ReadAndValidate(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	widgets := 0
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		n, err := flattenPage(ctx, pageNr)
+		if err != nil {
+			return nil, 0, fmt.Errorf("page %d: %w", pageNr, err)
+		}
+		widgets += n
+	}
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, 0, err
+	}
+	rootDict.Delete("AcroForm")
+	ctx.Form = nil
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), widgets, nil
+}
+
+// flattenPage - Draw the appearance stream of each visible widget on a page as a form XObject
+// placed over the widget rectangle, then remove the widget annotations
+func flattenPage(ctx *model.Context, pageNr int) (int, error) {
+	pageDict, _, inhPAttrs, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return 0, err
+	}
+
+	o, found := pageDict.Find("Annots")
+	if !found {
+		return 0, nil
+	}
+	annots, err := ctx.DereferenceArray(o)
+	if err != nil {
+		return 0, err
+	}
+
+	resources := inhPAttrs.Resources
+	if resources == nil {
+		resources = types.NewDict()
+	}
+	xobjects := types.NewDict()
+	if o, found := resources.Find("XObject"); found {
+		if xobjects, err = ctx.DereferenceDict(o); err != nil {
+			return 0, err
+		}
+	}
+
+	var content bytes.Buffer
+	kept := types.Array{}
+	widgets := 0
+	for _, entry := range annots {
+		d, err := ctx.DereferenceDict(entry)
+		if err != nil {
+			return 0, err
+		}
+		if subtype := d.NameEntry("Subtype"); subtype == nil || *subtype != "Widget" {
+			kept = append(kept, entry)
+			continue
+		}
+
+		appearance, matrix, err := widgetAppearance(ctx, d)
+		if err != nil {
+			return 0, err
+		}
+		if appearance == nil {
+			continue
+		}
+
+		name := fmt.Sprintf("Fx%d", widgets)
+		for i := widgets; ; i++ {
+			if _, taken := xobjects.Find(name); !taken {
+				break
+			}
+			name = fmt.Sprintf("Fx%d", i+1)
+		}
+		xobjects.Update(name, *appearance)
+		fmt.Fprintf(&content, "q %s cm /%s Do Q\n", matrix, name)
+		widgets++
+	}
+
+	if len(kept) > 0 {
+		pageDict.Update("Annots", kept)
+	} else {
+		pageDict.Delete("Annots")
+	}
+
+	if widgets == 0 {
+		return 0, nil
+	}
+
+	resources.Update("XObject", xobjects)
+	pageDict.Update("Resources", resources)
+
+	return widgets, wrapPageContent(ctx, pageDict, content.Bytes())
+}
+
+// widgetAppearance - Resolve the normal appearance of a widget for its current state and the matrix
+// mapping the appearance bounding box onto the widget rectangle. Hidden widgets yield nil.
+func widgetAppearance(ctx *model.Context, d types.Dict) (*types.IndirectRef, string, error) {
+	if flags := d.IntEntry("F"); flags != nil && *flags&(annotFlagHidden|annotFlagNoView) != 0 {
+		return nil, "", nil
+	}
+
+	ap, err := ctx.DereferenceDict(d["AP"])
+	if err != nil {
+		return nil, "", err
+	}
+	normal, found := ap.Find("N")
+	if !found {
+		// Viewers draw fields without appearance from their value (NeedAppearances)
+		return generateAppearance(ctx, d)
+	}
+	obj, err := ctx.Dereference(normal)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Checkboxes and radio buttons keep one appearance per state, selected by AS
+	if states, ok := obj.(types.Dict); ok {
+		state := d.NameEntry("AS")
+		if state == nil {
+			return nil, "", nil
+		}
+		if normal, found = states.Find(*state); !found {
+			return nil, "", nil
+		}
+	}
+
+	ref, ok := normal.(types.IndirectRef)
+	if !ok {
+		return nil, "", nil
+	}
+	sd, _, err := ctx.DereferenceStreamDict(ref)
+	if err != nil || sd == nil {
+		return nil, "", err
+	}
+
+	rect, err := rectEntry(ctx, d, "Rect")
+	if err != nil || rect == nil {
+		return nil, "", err
+	}
+	bbox, err := rectEntry(ctx, sd.Dict, "BBox")
+	if err != nil || bbox == nil {
+		return nil, "", err
+	}
+
+	// Transform the bounding box by the form matrix, as the viewer would before fitting it to Rect
+	m := [6]float64{1, 0, 0, 1, 0, 0}
+	if o, found := sd.Dict.Find("Matrix"); found {
+		arr, err := ctx.DereferenceArray(o)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(arr) == 6 {
+			for i, v := range arr {
+				if m[i], err = ctx.DereferenceNumber(v); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range [][2]float64{{bbox.LL.X, bbox.LL.Y}, {bbox.UR.X, bbox.LL.Y}, {bbox.LL.X, bbox.UR.Y}, {bbox.UR.X, bbox.UR.Y}} {
+		x := m[0]*p[0] + m[2]*p[1] + m[4]
+		y := m[1]*p[0] + m[3]*p[1] + m[5]
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	if maxX-minX <= 0 || maxY-minY <= 0 {
+		return nil, "", nil
+	}
+
+	sd.Dict.Insert("Type", types.Name("XObject"))
+	sd.Dict.Insert("Subtype", types.Name("Form"))
+
+	sx := (rect.UR.X - rect.LL.X) / (maxX - minX)
+	sy := (rect.UR.Y - rect.LL.Y) / (maxY - minY)
+	matrix := fmt.Sprintf("%s 0 0 %s %s %s",
+		formatNumber(sx), formatNumber(sy),
+		formatNumber(rect.LL.X-minX*sx), formatNumber(rect.LL.Y-minY*sy))
+
+	return &ref, matrix, nil
+}
+
+// generateAppearance - Build a plain appearance for a text or choice widget that has none,
+// showing its value in Helvetica over the background and border of its MK entry
+func generateAppearance(ctx *model.Context, d types.Dict) (*types.IndirectRef, string, error) {
+	fieldType, err := fieldEntry(ctx, d, "FT")
+	if err != nil {
+		return nil, "", err
+	}
+	ft, ok := fieldType.(types.Name)
+	if !ok || (ft != "Tx" && ft != "Ch") {
+		return nil, "", nil
+	}
+
+	rect, err := rectEntry(ctx, d, "Rect")
+	if err != nil || rect == nil {
+		return nil, "", err
+	}
+	w, h := rect.Width(), rect.Height()
+	if w <= 0 || h <= 0 {
+		return nil, "", nil
+	}
+
+	value, err := fieldEntry(ctx, d, "V")
+	if err != nil {
+		return nil, "", err
+	}
+	text := fieldText(value)
+
+	var content bytes.Buffer
+	content.WriteString("/Tx BMC\nq\n")
+
+	borderWidth := 1.0
+	if bs, err := ctx.DereferenceDict(d["BS"]); err == nil && bs != nil {
+		if width, found := bs.Find("W"); found {
+			if borderWidth, err = ctx.DereferenceNumber(width); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if mk, err := ctx.DereferenceDict(d["MK"]); err == nil && mk != nil {
+		if op := colorOperator(ctx, mk["BG"], false); op != "" {
+			fmt.Fprintf(&content, "%s 0 0 %s %s re f\n", op, formatNumber(w), formatNumber(h))
+		}
+		if op := colorOperator(ctx, mk["BC"], true); op != "" && borderWidth > 0 {
+			fmt.Fprintf(&content, "%s %s w %s %s %s %s re S\n", op, formatNumber(borderWidth),
+				formatNumber(borderWidth/2), formatNumber(borderWidth/2),
+				formatNumber(w-borderWidth), formatNumber(h-borderWidth))
+		}
+	}
+
+	if text != "" {
+		fontSize, color := defaultAppearance(ctx, d)
+
+		multiline := false
+		if flags, err := fieldEntry(ctx, d, "Ff"); err == nil {
+			if ff, ok := flags.(types.Integer); ok && ft == "Tx" {
+				multiline = ff.Value()&fieldFlagMultiline != 0
+			}
+		}
+		lines := []string{strings.ReplaceAll(text, "\n", " ")}
+		if multiline {
+			lines = strings.Split(text, "\n")
+		}
+
+		if fontSize <= 0 {
+			// Auto size: fit one line to the field height
+			fontSize = math.Min(12, math.Max(4, (h-2*borderWidth)*0.7))
+		}
+
+		alignment := 0
+		if q, err := fieldEntry(ctx, d, "Q"); err == nil {
+			if i, ok := q.(types.Integer); ok {
+				alignment = i.Value()
+			}
+		}
+
+		padding := borderWidth + 2
+		leading := fontSize * 1.15
+		y := (h-fontSize)/2 + fontSize*0.22
+		if multiline {
+			y = h - padding - fontSize
+		}
+
+		content.WriteString("BT\n")
+		fmt.Fprintf(&content, "/Helv %s Tf %s\n", formatNumber(fontSize), color)
+		for i, line := range lines {
+			width := font.TextWidth(line, "Helvetica", int(math.Round(fontSize)))
+			x := padding
+			switch alignment {
+			case 1:
+				x = (w - width) / 2
+			case 2:
+				x = w - padding - width
+			}
+			fmt.Fprintf(&content, "1 0 0 1 %s %s Tm %s Tj\n", formatNumber(x), formatNumber(y-float64(i)*leading), winAnsiString(line))
+		}
+		content.WriteString("ET\n")
+	}
+	content.WriteString("Q\nEMC\n")
+
+	sd, err := ctx.NewStreamDictForBuf(content.Bytes())
+	if err != nil {
+		return nil, "", err
+	}
+	sd.InsertName("Type", "XObject")
+	sd.InsertName("Subtype", "Form")
+	sd.Insert("BBox", types.NewNumberArray(0, 0, w, h))
+	sd.Insert("Resources", types.Dict(map[string]types.Object{
+		"Font": types.Dict(map[string]types.Object{
+			"Helv": types.Dict(map[string]types.Object{
+				"Type":     types.Name("Font"),
+				"Subtype":  types.Name("Type1"),
+				"BaseFont": types.Name("Helvetica"),
+				"Encoding": types.Name("WinAnsiEncoding"),
+			}),
+		}),
+	}))
+	if err := sd.Encode(); err != nil {
+		return nil, "", err
+	}
+
+	ref, err := ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		return nil, "", err
+	}
+	return ref, fmt.Sprintf("1 0 0 1 %s %s", formatNumber(rect.LL.X), formatNumber(rect.LL.Y)), nil
+}
+
+// fieldEntry - Look up a field attribute on a widget, falling back to its parent fields
+// where inheritable attributes (FT, V, DA, Q, Ff) usually live
+func fieldEntry(ctx *model.Context, d types.Dict, key string) (types.Object, error) {
+	for depth := 0; d != nil && depth < maxFieldDepth; depth++ {
+		if o, found := d.Find(key); found {
+			return ctx.Dereference(o)
+		}
+		parent, err := ctx.DereferenceDict(d["Parent"])
+		if err != nil {
+			return nil, err
+		}
+		d = parent
+	}
+	return nil, nil
+}
+
+// fieldText - Text shown for a field value; multiple selections are separated by commas
+func fieldText(value types.Object) string {
+	switch v := value.(type) {
+	case types.StringLiteral, types.HexLiteral:
+		if s, err := types.StringOrHexLiteral(v); err == nil && s != nil {
+			return *s
+		}
+	case types.Name:
+		return string(v)
+	case types.Array:
+		parts := []string{}
+		for _, item := range v {
+			if s := fieldText(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// defaultAppearance - Font size and color operator of the field's DA string, falling back to the AcroForm DA.
+// A size of 0 means auto size.
+func defaultAppearance(ctx *model.Context, d types.Dict) (float64, string) {
+	da, _ := fieldEntry(ctx, d, "DA")
+	if da == nil && ctx.Form != nil {
+		da, _ = ctx.Dereference(ctx.Form["DA"])
+	}
+
+	s := fieldText(da)
+	tokens := strings.Fields(s)
+	size := 0.0
+	color := "0 g"
+	for i, token := range tokens {
+		switch token {
+		case "Tf":
+			if i >= 1 {
+				size, _ = strconv.ParseFloat(tokens[i-1], 64)
+			}
+		case "g", "rg", "k":
+			operands := map[string]int{"g": 1, "rg": 3, "k": 4}[token]
+			if i >= operands {
+				color = strings.Join(tokens[i-operands:i+1], " ")
+			}
+		}
+	}
+	return size, color
+}
+
+// colorOperator - Fill or stroke color operator for an MK color array (gray, RGB or CMYK).
+// Empty arrays mean transparent and yield "".
+func colorOperator(ctx *model.Context, o types.Object, stroke bool) string {
+	arr, err := ctx.DereferenceArray(o)
+	if err != nil {
+		return ""
+	}
+
+	operator := map[int]string{1: "g", 3: "rg", 4: "k"}[len(arr)]
+	if operator == "" {
+		return ""
+	}
+	if stroke {
+		operator = strings.ToUpper(operator)
+	}
+
+	parts := []string{}
+	for _, c := range arr {
+		v, err := ctx.DereferenceNumber(c)
+		if err != nil {
+			return ""
+		}
+		parts = append(parts, formatNumber(v))
+	}
+	return strings.Join(parts, " ") + " " + operator
+}
+
+// winAnsiString - Encode text as a PDF literal string for a WinAnsi font.
+// Latin-1 characters map to themselves; anything else is replaced by "?".
+func winAnsiString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// rectEntry - Read a rectangle entry, normalizing corner order. Missing or malformed entries yield nil.
+func rectEntry(ctx *model.Context, d types.Dict, key string) (*types.Rectangle, error) {
+	o, found := d.Find(key)
+	if !found {
+		return nil, nil
+	}
+	arr, err := ctx.DereferenceArray(o)
+	if err != nil || len(arr) != 4 {
+		return nil, err
+	}
+
+	var c [4]float64
+	for i, v := range arr {
+		if c[i], err = ctx.DereferenceNumber(v); err != nil {
+			return nil, err
+		}
+	}
+	return types.NewRectangle(math.Min(c[0], c[2]), math.Min(c[1], c[3]), math.Max(c[0], c[2]), math.Max(c[1], c[3])), nil
+}
+
+// formatNumber - Write a content stream number rounded to 4 decimals, without trailing zeros
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10000)/10000, 'f', -1, 64)
+}
+
+// wrapPageContent - Isolate the existing page content in q/Q and append bb after it,
+// so a graphics state left behind by the page cannot distort the flattened fields
+func wrapPageContent(ctx *model.Context, pageDict types.Dict, bb []byte) error {
+	streams := types.Array{}
+	if o, found := pageDict.Find("Contents"); found {
+		obj, err := ctx.Dereference(o)
+		if err != nil {
+			return err
+		}
+		switch contents := obj.(type) {
+		case types.Array:
+			streams = append(streams, contents...)
+		case types.StreamDict:
+			streams = append(streams, o)
+		}
+	}
+
+	head, err := ctx.StreamDictIndRef([]byte("q\n"))
+	if err != nil {
+		return err
+	}
+	tail, err := ctx.StreamDictIndRef(append([]byte("Q\n"), bb...))
+	if err != nil {
+		return err
+	}
+
+	contents := append(types.Array{*head}, streams...)
+	pageDict.Update("Contents", append(contents, *tail))
+	return nil
+}
+
+// exportFieldTree - Read the form of a PDF and arrange its values in the dotted name hierarchy
+func exportFieldTree(pdfDataStr, password string) ([]*fieldNode, int, error) {
+	pdfBytes, err := decodePDFData(pdfDataStr, password)
+	if err != nil {
+		return nil, 0, errors.New(localize("Invalid PDF data: %v", err))
+	}
+
+	ctx, err := api.ReadAndValidate(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
+		return nil, 0, errors.New(localize("Failed to read form fields: %v", err))
+	}
+	if ctx.Form == nil {
+		return nil, 0, errors.New(localize("PDF does not contain any form fields"))
+	}
+
+	formGroup, ok, err := form.ExportForm(ctx.XRefTable, "pdfform-wasm")
+	if err != nil {
+		return nil, 0, errors.New(localize("Failed to read form fields: %v", err))
+	}
+	if !ok || len(formGroup.Forms) == 0 {
+		return nil, 0, errors.New(localize("PDF does not contain any form fields"))
+	}
+
+	values := fieldValues(ctx, &formGroup.Forms[0])
+	return buildFieldTree(values), len(values), nil
+}
+
+// fieldValues - Collect the value of every named terminal field in FDF terms
+func fieldValues(ctx *model.Context, f *form.Form) []fieldValue {
+	values := []fieldValue{}
+	add := func(name string, isName bool, v ...string) {
+		if name != "" {
+			values = append(values, fieldValue{name: name, isName: isName, values: v})
+		}
+	}
+
+	for _, tf := range f.TextFields {
+		add(tf.Name, false, tf.Value)
+	}
+	for _, df := range f.DateFields {
+		add(df.Name, false, df.Value)
+	}
+	for _, cb := range f.CheckBoxes {
+		state := "Off"
+		if cb.Value {
+			state = checkBoxOnState(ctx, cb.ID)
+		}
+		add(cb.Name, true, state)
+	}
+	for _, rb := range f.RadioButtonGroups {
+		state := rb.Value
+		if state == "" {
+			state = "Off"
+		}
+		add(rb.Name, true, state)
+	}
+	for _, cb := range f.ComboBoxes {
+		add(cb.Name, false, cb.Value)
+	}
+	for _, lb := range f.ListBoxes {
+		add(lb.Name, false, lb.Values...)
+	}
+
+	sort.SliceStable(values, func(i, j int) bool { return values[i].name < values[j].name })
+	return values
+}
+
+// checkBoxOnState - Name of the "checked" appearance state of a checkbox, "Yes" when none is declared
+func checkBoxOnState(ctx *model.Context, id string) string {
+	// Field IDs are the dotted object numbers of the field hierarchy, the last one is the field itself
+	objNr, err := strconv.Atoi(id[strings.LastIndex(id, ".")+1:])
+	if err != nil {
+		return "Yes"
+	}
+
+	d, err := ctx.DereferenceDict(*types.NewIndirectRef(objNr, 0))
+	if err != nil || d == nil {
+		return "Yes"
+	}
+
+	// Field and widget are either merged in one dictionary or the widgets are kids of the field
+	candidates := []types.Dict{d}
+	if kids, err := ctx.DereferenceArray(d["Kids"]); err == nil {
+		for _, kid := range kids {
+			if kd, err := ctx.DereferenceDict(kid); err == nil && kd != nil {
+				candidates = append(candidates, kd)
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		ap, err := ctx.DereferenceDict(candidate["AP"])
+		if err != nil || ap == nil {
+			continue
+		}
+		states, err := ctx.DereferenceDict(ap["N"])
+		if err != nil || states == nil {
+			continue
+		}
+		for state := range states {
+			if state != "Off" {
+				return state
+			}
+		}
+	}
+	return "Yes"
+}
+
+// buildFieldTree - Nest fully qualified field names ("address.city") into a hierarchy
+func buildFieldTree(values []fieldValue) []*fieldNode {
+	root := &fieldNode{}
+	for i := range values {
+		node := root
+		for _, part := range strings.Split(values[i].name, ".") {
+			var child *fieldNode
+			for _, c := range node.children {
+				if c.name == part {
+					child = c
+					break
+				}
+			}
+			if child == nil {
+				child = &fieldNode{name: part}
+				node.children = append(node.children, child)
+			}
+			node = child
+		}
+		node.value = &values[i]
+	}
+	return root.children
+}
+
+// writeFDFField - Write a field dictionary and its kids in FDF syntax
+func writeFDFField(buf *bytes.Buffer, node *fieldNode) {
+	buf.WriteString("<< /T ")
+	buf.WriteString(pdfString(node.name))
+
+	if v := node.value; v != nil {
+		buf.WriteString(" /V ")
+		switch {
+		case v.isName:
+			buf.WriteString(pdfName(v.values[0]))
+		case len(v.values) == 1:
+			buf.WriteString(pdfString(v.values[0]))
+		default:
+			parts := make([]string, len(v.values))
+			for i, s := range v.values {
+				parts[i] = pdfString(s)
+			}
+			buf.WriteString("[" + strings.Join(parts, " ") + "]")
+		}
+	}
+
+	if len(node.children) > 0 {
+		buf.WriteString(" /Kids [")
+		for _, child := range node.children {
+			buf.WriteString("\n")
+			writeFDFField(buf, child)
+		}
+		buf.WriteString("]")
+	}
+	buf.WriteString(" >>")
+}
+
+// writeXFDFField - Write a field element and its nested fields in XFDF syntax
+func writeXFDFField(buf *bytes.Buffer, node *fieldNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	buf.WriteString(indent + "<field name=\"" + xmlEscape(node.name) + "\">\n")
+	if v := node.value; v != nil {
+		for _, s := range v.values {
+			buf.WriteString(indent + "  <value>" + xmlEscape(s) + "</value>\n")
+		}
+	}
+	for _, child := range node.children {
+		writeXFDFField(buf, child, depth+1)
+	}
+	buf.WriteString(indent + "</field>\n")
+}
+
+// pdfString - Encode text as a PDF string: a literal for printable ASCII, UTF-16BE hex otherwise
+func pdfString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+
+	if ascii {
+		escaper := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+		return "(" + escaper.Replace(s) + ")"
+	}
+
+	var hex strings.Builder
+	hex.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&hex, "%04X", unit)
+	}
+	hex.WriteString(">")
+	return hex.String()
+}
+
+// pdfName - Encode a PDF name, escaping delimiters and bytes outside printable ASCII as #xx
+func pdfName(s string) string {
+	var name strings.Builder
+	name.WriteString("/")
+	for _, b := range []byte(s) {
+		if b < 0x21 || b > 0x7e || strings.IndexByte("#()<>[]{}/%", b) >= 0 {
+			fmt.Fprintf(&name, "#%02X", b)
+			continue
+		}
+		name.WriteByte(b)
+	}
+	return name.String()
+}
+
+// xmlEscape - Escape text for an XML attribute or element
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// newPDFConfiguration - pdfcpu configuration carrying the document password
+func newPDFConfiguration(password string) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+	conf.ValidationMode = model.ValidationRelaxed
+	return conf
+}
+
+// decodePDFData - Decode base64 PDF data, decrypting it when a password is given
+func decodePDFData(pdfDataStr, password string) ([]byte, error) {
+	pdfBytes, err := base64.StdEncoding.DecodeString(pdfDataStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if password == "" {
+		return pdfBytes, nil
+	}
+
+	ctx, err := api.ReadContext(bytes.NewReader(pdfBytes), newPDFConfiguration(password))
+	if err != nil {
+		if errors.Is(err, pdfcpu.ErrWrongPassword) {
+			return nil, errors.New(localize("incorrect password for encrypted PDF"))
+		}
+		return nil, err
+	}
+	if ctx.Encrypt == nil {
+		return pdfBytes, nil
+	}
+
+	// Forms are filled and flattened on the decrypted document
+	var buf bytes.Buffer
+	if err := api.Decrypt(bytes.NewReader(pdfBytes), &buf, newPDFConfiguration(password)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// optionalPassword - Read the optional password argument at the given position
+func optionalPassword(args []js.Value, index int) string {
+	if len(args) > index && args[index].Type() == js.TypeString {
+		return args[index].String()
+	}
+	return ""
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"field-discovery",
+	"form-filling",
+	"form-flattening",
+	"fdf-export",
+	"xfdf-export",
+	"encrypted-pdf",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage so pages can monitor memory growth.
+// The module is stateless: documents are released as soon as each call returns.
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{}))
+}
+
+// releaseResources - Return freed memory to the runtime
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        map[string]interface{}{},
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "pdfform-wasm",
+		"version":         moduleVersion,
+		"description":     "PDF form discovery, filling, flattening and FDF/XFDF export module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"getFields",
+		"fillForm",
+		"flattenForm",
+		"exportFDF",
+		"exportXFDF",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// pdfcpu must not look for a config directory inside the browser sandbox
+	api.DisableConfigDir()
+
+	// Register form functions
+	js.Global().Set("getFields", js.FuncOf(getFields))
+	js.Global().Set("fillForm", js.FuncOf(fillForm))
+	js.Global().Set("flattenForm", js.FuncOf(flattenForm))
+	js.Global().Set("exportFDF", js.FuncOf(exportFDF))
+	js.Global().Set("exportXFDF", js.FuncOf(exportXFDF))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM PDF Form module ready!")
+	fmt.Println("Available functions: getFields, fillForm, flattenForm, exportFDF, exportXFDF")
+
+	// Keep the program alive
+	select {}
+}
@@ -0,0 +1,4816 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/vector"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var silentMode = false
+
+// Zoom and tile bounds. Pages are rendered whole below the pixel budget, larger zooms go through renderTile.
+const (
+	minScale           = 0.05
+	maxScale           = 32.0
+	maxRenderPixels    = 40000000
+	defaultTileSize    = 256
+	minTileSize        = 16
+	maxTileSize        = 4096
+	defaultJPEGQuality = 90
+	defaultMaxResults  = 1000
+	searchContextRunes = 30
+)
+
+// zoomLevels are the zoom steps getPageInfo describes for viewers
+var zoomLevels = []float64{0.25, 0.5, 0.75, 1, 1.25, 1.5, 2, 3, 4, 6, 8}
+
+// defaultHighlightColor is a half transparent yellow, multiplied with the page so text stays readable
+var defaultHighlightColor = color.NRGBA{R: 255, G: 212, B: 0, A: 128}
+
+// viewerDocument is a parsed document kept in Go memory between calls. Fonts, decoded images and page
+// text are cached, so zooming or rendering the next tile does not decode them again.
+type viewerDocument struct {
+	ctx    *model.Context
+	size   int
+	fonts  map[string]*renderFont
+	images map[int]image.Image
+	text   map[int]*pageText
+}
+
+// documents holds the open documents by the ID returned by openDocument
+var documents = map[string]*viewerDocument{}
+
+// viewerPage is the visible area of a page: the crop box, turned by the page rotation
+type viewerPage struct {
+	dict      types.Dict
+	resources types.Dict
+	llx, lly  float64
+	urx, ury  float64
+	rotate    int
+}
+
+// viewOptions are the options shared by the rendering, text layer and search functions
+type viewOptions struct {
+	Scale          float64    `json:"scale"`
+	DPI            float64    `json:"dpi"`
+	Format         string     `json:"format"`
+	Quality        int        `json:"quality"`
+	Background     string     `json:"background"`
+	Annotations    *bool      `json:"annotations"`
+	TileSize       int        `json:"tileSize"`
+	Highlight      string     `json:"highlight"`
+	Highlights     []viewRect `json:"highlights"`
+	HighlightColor string     `json:"highlightColor"`
+	CaseSensitive  bool       `json:"caseSensitive"`
+	WholeWord      bool       `json:"wholeWord"`
+	Pages          []int      `json:"pages"`
+	MaxResults     int        `json:"maxResults"`
+
+	background     color.NRGBA
+	highlightColor color.NRGBA
+}
+
+// viewRect is a rectangle in page pixels, from the top left corner of the rendered page
+type viewRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// textGlyph is one shown character and the quadrilateral it covers on the page at scale 1
+type textGlyph struct {
+	text   []rune
+	quad   [4]renderPoint
+	origin renderPoint
+	end    renderPoint
+	size   float64
+	angle  float64
+	font   string
+}
+
+// textItem is a run of glyphs on one baseline, the unit of the text layer. owner maps each rune to its
+// glyph, -1 for the spaces inserted where glyphs are set apart.
+type textItem struct {
+	runes   []rune
+	owner   []int
+	glyphs  []textGlyph
+	newLine bool
+}
+
+// pageText is the text of a page in content order, measured at scale 1
+type pageText struct {
+	items []textItem
+}
+
+// streamRune locates a rune of the flattened page text used for search
+type streamRune struct {
+	item, glyph int
+}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires at least %d arguments (%s)":                                 "%s requiert au moins %d arguments (%s)",
+	"%s requires at least 1 argument (%s)":                                   "%s requiert au moins 1 argument (%s)",
+	"Failed to extract text: %v":                                             "Échec de l'extraction du texte: %v",
+	"Failed to open document: %v":                                            "Échec de l'ouverture du document: %v",
+	"Failed to read page: %v":                                                "Impossible de lire la page: %v",
+	"Failed to render page: %v":                                              "Échec du rendu de la page: %v",
+	"Failed to render tile: %v":                                              "Échec du rendu de la tuile: %v",
+	"Failed to search text: %v":                                              "Échec de la recherche du texte: %v",
+	"Invalid PDF data: %v":                                                   "Données PDF invalides: %v",
+	"Invalid options: %v":                                                    "Options invalides: %v",
+	"Page %d does not exist (document has %d pages)":                         "La page %d n'existe pas (le document contient %d pages)",
+	"Unknown document %q (it may already be closed)":                         "Document inconnu %q (il a peut-être déjà été fermé)",
+	"Unsupported locale %q (available: %s)":                                  "Langue %q non prise en charge (disponibles: %s)",
+	"a transparent background requires the png or rgba format":               "un fond transparent requiert le format png ou rgba",
+	"column and row must be numbers":                                         "column et row doivent être des nombres",
+	"expected a Uint8Array, ArrayBuffer or base64 string":                    "un Uint8Array, un ArrayBuffer ou une chaîne base64 est attendu",
+	"incorrect password for encrypted PDF":                                   "mot de passe incorrect pour le PDF chiffré",
+	"invalid colour %q (expected #rrggbb or #rrggbbaa)":                      "couleur %q invalide (#rrggbb ou #rrggbbaa attendu)",
+	"maxResults must not be negative":                                        "maxResults ne doit pas être négatif",
+	"page %d not found":                                                      "page %d introuvable",
+	"page must be a number":                                                  "la page doit être un nombre",
+	"quality must be between 1 and 100":                                      "quality doit être comprise entre 1 et 100",
+	"query must be a string":                                                 "la recherche doit être une chaîne",
+	"query must not be empty":                                                "la recherche ne doit pas être vide",
+	"rendered page would be %dx%d pixels, lower the scale or use renderTile": "la page rendue ferait %dx%d pixels, réduisez l'échelle ou utilisez renderTile",
+	"scale must be between %g and %g":                                        "scale doit être comprise entre %g et %g",
+	"setLocale requires exactly 1 argument (locale)":                         "setLocale requiert exactement 1 argument (locale)",
+	"tile (%d, %d) is outside the %dx%d tile grid":                           "la tuile (%d, %d) est hors de la grille de %dx%d tuiles",
+	"tileSize must be between %d and %d":                                     "tileSize doit être comprise entre %d et %d",
+	"unsupported format %q (expected png, jpeg or rgba)":                     "format %q non pris en charge (png, jpeg ou rgba attendu)",
+}
+
+// openDocument - Parse a PDF once and keep it open for rendering, text extraction and search.
+// Returns the documentId the other functions take, and the size of every page in points.
+func openDocument(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "openDocument", "pdfData"),
+		})
+	}
+
+	pdfBytes, err := decodePDFData(args[0], optionalPassword(args, 1))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid PDF data: %v", err),
+		})
+	}
+
+	ctx, err := api.ReadAndValidate(bytes.NewReader(pdfBytes), newPDFConfiguration(""))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to open document: %v", err),
+		})
+	}
+
+	doc := &viewerDocument{
+		ctx:    ctx,
+		size:   len(pdfBytes),
+		fonts:  map[string]*renderFont{},
+		images: map[int]image.Image{},
+		text:   map[int]*pageText{},
+	}
+	pages := make([]interface{}, 0, ctx.PageCount)
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		page, err := doc.page(pageNr)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to open document: %v", err),
+			})
+		}
+		width, height := page.size()
+		pages = append(pages, map[string]interface{}{
+			"page":     pageNr,
+			"width":    roundCoordinate(width),
+			"height":   roundCoordinate(height),
+			"rotation": page.rotate,
+		})
+	}
+
+	id := newID()
+	documents[id] = doc
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Opened document %s (%d pages, %d bytes)\n", id, ctx.PageCount, len(pdfBytes))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"documentId": id,
+		"pageCount":  ctx.PageCount,
+		"pages":      pages,
+		"size":       len(pdfBytes),
+		"title":      ctx.Title,
+		"author":     ctx.Author,
+	})
+}
+
+// closeDocument - Free an open document and its caches
+func closeDocument(this js.Value, args []js.Value) interface{} {
+	_, id, failure := documentArgument(args, "closeDocument", 1, "documentId")
+	if failure != nil {
+		return failure
+	}
+
+	delete(documents, id)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Closed document %s\n", id)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"documentId": id,
+		"closed":     true,
+	})
+}
+
+// getPageInfo - Describe a page at a zoom: its size in points and pixels, and the tile grid of each zoom level
+func getPageInfo(this js.Value, args []js.Value) interface{} {
+	doc, _, failure := documentArgument(args, "getPageInfo", 2, "documentId, page")
+	if failure != nil {
+		return failure
+	}
+	pageNr, failure := pageArgument(doc, args[1])
+	if failure != nil {
+		return failure
+	}
+	options, err := readViewOptions(args, 2)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	page, err := doc.page(pageNr)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to read page: %v", err),
+		})
+	}
+
+	width, height := page.size()
+	pixelWidth, pixelHeight := page.pixels(options.Scale)
+	columns, rows := tileGrid(pixelWidth, pixelHeight, options.TileSize)
+	levels := make([]interface{}, 0, len(zoomLevels))
+	for _, zoom := range zoomLevels {
+		w, h := page.pixels(zoom)
+		c, r := tileGrid(w, h, options.TileSize)
+		levels = append(levels, map[string]interface{}{
+			"zoom":    zoom,
+			"width":   w,
+			"height":  h,
+			"columns": c,
+			"rows":    r,
+		})
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"page":        pageNr,
+		"width":       roundCoordinate(width),
+		"height":      roundCoordinate(height),
+		"rotation":    page.rotate,
+		"scale":       options.Scale,
+		"pixelWidth":  pixelWidth,
+		"pixelHeight": pixelHeight,
+		"tileSize":    options.TileSize,
+		"columns":     columns,
+		"rows":        rows,
+		"zoomLevels":  levels,
+	})
+}
+
+// renderPage - Rasterize a whole page at a zoom to PNG, JPEG or raw RGBA pixels, with optional search highlights
+func renderPage(this js.Value, args []js.Value) interface{} {
+	doc, _, failure := documentArgument(args, "renderPage", 2, "documentId, page")
+	if failure != nil {
+		return failure
+	}
+	pageNr, failure := pageArgument(doc, args[1])
+	if failure != nil {
+		return failure
+	}
+	options, err := readViewOptions(args, 2)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	page, err := doc.page(pageNr)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render page: %v", err),
+		})
+	}
+	width, height := page.pixels(options.Scale)
+	if width*height > maxRenderPixels {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("rendered page would be %dx%d pixels, lower the scale or use renderTile", width, height),
+		})
+	}
+
+	canvas, err := doc.render(pageNr, page, options, image.Rect(0, 0, width, height))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render page: %v", err),
+		})
+	}
+	result, err := imageResult(canvas, pageNr, options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render page: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Rendered page %d at scale %g (%dx%d)\n", pageNr, options.Scale, width, height)
+	}
+
+	return js.ValueOf(result)
+}
+
+// renderTile - Rasterize one tile of a page at a zoom. Tiles are tileSize pixels square, numbered from the top
+// left corner; those on the right and bottom edges are cut to the page.
+func renderTile(this js.Value, args []js.Value) interface{} {
+	doc, _, failure := documentArgument(args, "renderTile", 4, "documentId, page, column, row")
+	if failure != nil {
+		return failure
+	}
+	pageNr, failure := pageArgument(doc, args[1])
+	if failure != nil {
+		return failure
+	}
+	if args[2].Type() != js.TypeNumber || args[3].Type() != js.TypeNumber {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("column and row must be numbers"),
+		})
+	}
+	column, row := args[2].Int(), args[3].Int()
+	options, err := readViewOptions(args, 4)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	page, err := doc.page(pageNr)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render tile: %v", err),
+		})
+	}
+	pageWidth, pageHeight := page.pixels(options.Scale)
+	columns, rows := tileGrid(pageWidth, pageHeight, options.TileSize)
+	if column < 0 || row < 0 || column >= columns || row >= rows {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("tile (%d, %d) is outside the %dx%d tile grid", column, row, columns, rows),
+		})
+	}
+
+	area := image.Rect(column*options.TileSize, row*options.TileSize, (column+1)*options.TileSize, (row+1)*options.TileSize)
+	area = area.Intersect(image.Rect(0, 0, pageWidth, pageHeight))
+	canvas, err := doc.render(pageNr, page, options, area)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render tile: %v", err),
+		})
+	}
+	result, err := imageResult(canvas, pageNr, options)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to render tile: %v", err),
+		})
+	}
+	result["column"] = column
+	result["row"] = row
+	result["x"] = area.Min.X
+	result["y"] = area.Min.Y
+	result["columns"] = columns
+	result["rows"] = rows
+	result["tileSize"] = options.TileSize
+	result["pageWidth"] = pageWidth
+	result["pageHeight"] = pageHeight
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Rendered tile (%d, %d) of page %d at scale %g\n", column, row, pageNr, options.Scale)
+	}
+
+	return js.ValueOf(result)
+}
+
+// getTextLayer - Extract the text of a page as positioned items, in pixels of the page rendered at the same
+// scale, so a transparent layer laid over the image makes the text selectable
+func getTextLayer(this js.Value, args []js.Value) interface{} {
+	doc, _, failure := documentArgument(args, "getTextLayer", 2, "documentId, page")
+	if failure != nil {
+		return failure
+	}
+	pageNr, failure := pageArgument(doc, args[1])
+	if failure != nil {
+		return failure
+	}
+	options, err := readViewOptions(args, 2)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	page, err := doc.page(pageNr)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to extract text: %v", err),
+		})
+	}
+	text, err := doc.pageText(pageNr, page)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to extract text: %v", err),
+		})
+	}
+
+	scale := options.Scale
+	items := make([]interface{}, 0, len(text.items))
+	var content strings.Builder
+	for i, item := range text.items {
+		if i > 0 {
+			if item.newLine {
+				content.WriteByte('\n')
+			} else {
+				content.WriteByte(' ')
+			}
+		}
+		content.WriteString(string(item.runes))
+
+		bounds := glyphBounds(item.glyphs)
+		first := item.glyphs[0]
+		items = append(items, map[string]interface{}{
+			"text":     string(item.runes),
+			"x":        roundCoordinate(bounds.X * scale),
+			"y":        roundCoordinate(bounds.Y * scale),
+			"width":    roundCoordinate(bounds.Width * scale),
+			"height":   roundCoordinate(bounds.Height * scale),
+			"fontSize": roundCoordinate(first.size * scale),
+			"angle":    roundCoordinate(first.angle),
+			"fontName": first.font,
+			"newLine":  item.newLine,
+		})
+	}
+
+	width, height := page.pixels(scale)
+	if !silentMode {
+		fmt.Printf("Go WASM: Extracted %d text items from page %d\n", len(items), pageNr)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"page":   pageNr,
+		"scale":  scale,
+		"width":  width,
+		"height": height,
+		"text":   content.String(),
+		"items":  items,
+	})
+}
+
+// searchText - Find a phrase in the document. Whitespace in the query matches any run of spaces or line breaks;
+// every hit has the rectangles covering it in pixels of the page rendered at options.scale.
+func searchText(this js.Value, args []js.Value) interface{} {
+	doc, _, failure := documentArgument(args, "searchText", 2, "documentId, query")
+	if failure != nil {
+		return failure
+	}
+	if args[1].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("query must be a string"),
+		})
+	}
+	query := args[1].String()
+	options, err := readViewOptions(args, 2)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+	needle, _ := foldText([]rune(query), options.CaseSensitive)
+	if len(needle) == 0 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("query must not be empty"),
+		})
+	}
+
+	pageNrs := options.Pages
+	if len(pageNrs) == 0 {
+		for pageNr := 1; pageNr <= doc.ctx.PageCount; pageNr++ {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+	for _, pageNr := range pageNrs {
+		if pageNr < 1 || pageNr > doc.ctx.PageCount {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Page %d does not exist (document has %d pages)", pageNr, doc.ctx.PageCount),
+			})
+		}
+	}
+
+	hits := []interface{}{}
+	found := []interface{}{}
+	truncated := false
+	for _, pageNr := range pageNrs {
+		if truncated {
+			break
+		}
+		page, err := doc.page(pageNr)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to search text: %v", err),
+			})
+		}
+		text, err := doc.pageText(pageNr, page)
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Failed to search text: %v", err),
+			})
+		}
+
+		matches := text.search(needle, options)
+		if len(matches) > 0 {
+			found = append(found, pageNr)
+		}
+		for _, match := range matches {
+			if len(hits) == options.MaxResults {
+				truncated = true
+				break
+			}
+			match["page"] = pageNr
+			hits = append(hits, match)
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Found %d matches of %q on %d pages\n", len(hits), query, len(found))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"query":     query,
+		"total":     len(hits),
+		"truncated": truncated,
+		"pages":     found,
+		"hits":      hits,
+	})
+}
+
+// documentArgument checks the argument count and resolves the documentId given first
+func documentArgument(args []js.Value, function string, count int, names string) (*viewerDocument, string, interface{}) {
+	if len(args) < count {
+		message := localize("%s requires at least %d arguments (%s)", function, count, names)
+		if count == 1 {
+			message = localize("%s requires at least 1 argument (%s)", function, names)
+		}
+		return nil, "", js.ValueOf(map[string]interface{}{"error": message})
+	}
+	id := args[0].String()
+	doc, ok := documents[id]
+	if !ok {
+		return nil, "", js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown document %q (it may already be closed)", id),
+		})
+	}
+	return doc, id, nil
+}
+
+// pageArgument reads a page number, counted from 1
+func pageArgument(doc *viewerDocument, value js.Value) (int, interface{}) {
+	if value.Type() != js.TypeNumber {
+		return 0, js.ValueOf(map[string]interface{}{
+			"error": localize("page must be a number"),
+		})
+	}
+	pageNr := value.Int()
+	if pageNr < 1 || pageNr > doc.ctx.PageCount {
+		return 0, js.ValueOf(map[string]interface{}{
+			"error": localize("Page %d does not exist (document has %d pages)", pageNr, doc.ctx.PageCount),
+		})
+	}
+	return pageNr, nil
+}
+
+// readViewOptions reads the options argument at index and fills in the defaults
+func readViewOptions(args []js.Value, index int) (viewOptions, error) {
+	options := viewOptions{}
+	if len(args) > index {
+		if err := decodeOptions(args[index], &options); err != nil {
+			return options, err
+		}
+	}
+
+	if options.DPI > 0 {
+		options.Scale = options.DPI / 72
+	}
+	if options.Scale == 0 {
+		options.Scale = 1
+	}
+	if options.Scale < minScale || options.Scale > maxScale {
+		return options, errors.New(localize("scale must be between %g and %g", minScale, maxScale))
+	}
+
+	switch options.Format = strings.ToLower(options.Format); options.Format {
+	case "":
+		options.Format = "png"
+	case "jpg":
+		options.Format = "jpeg"
+	case "png", "jpeg", "rgba":
+	default:
+		return options, errors.New(localize("unsupported format %q (expected png, jpeg or rgba)", options.Format))
+	}
+	if options.Quality == 0 {
+		options.Quality = defaultJPEGQuality
+	}
+	if options.Quality < 1 || options.Quality > 100 {
+		return options, errors.New(localize("quality must be between 1 and 100"))
+	}
+
+	if options.TileSize == 0 {
+		options.TileSize = defaultTileSize
+	}
+	if options.TileSize < minTileSize || options.TileSize > maxTileSize {
+		return options, errors.New(localize("tileSize must be between %d and %d", minTileSize, maxTileSize))
+	}
+
+	if options.MaxResults == 0 {
+		options.MaxResults = defaultMaxResults
+	}
+	if options.MaxResults < 0 {
+		return options, errors.New(localize("maxResults must not be negative"))
+	}
+
+	options.background = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	switch options.Background {
+	case "":
+	case "transparent":
+		if options.Format == "jpeg" {
+			return options, errors.New(localize("a transparent background requires the png or rgba format"))
+		}
+		options.background = color.NRGBA{}
+	default:
+		c, ok := hexColor(options.Background)
+		if !ok {
+			return options, errors.New(localize("invalid colour %q (expected #rrggbb or #rrggbbaa)", options.Background))
+		}
+		options.background = c
+	}
+
+	options.highlightColor = defaultHighlightColor
+	if options.HighlightColor != "" {
+		c, ok := hexColor(options.HighlightColor)
+		if !ok {
+			return options, errors.New(localize("invalid colour %q (expected #rrggbb or #rrggbbaa)", options.HighlightColor))
+		}
+		options.highlightColor = c
+	}
+	return options, nil
+}
+
+// decodeOptions reads an options argument given as a JS object or a JSON string, leaving defaults for missing keys
+func decodeOptions(value js.Value, target interface{}) error {
+	switch value.Type() {
+	case js.TypeUndefined, js.TypeNull:
+		return nil
+	case js.TypeObject:
+		value = js.Global().Get("JSON").Call("stringify", value)
+	}
+	return json.Unmarshal([]byte(value.String()), target)
+}
+
+// hexColor parses a #RRGGBB, #RRGGBBAA or #RGB colour
+func hexColor(value string) (color.NRGBA, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if len(value) == 6 {
+		value += "ff"
+	}
+	n, err := strconv.ParseUint(value, 16, 32)
+	if len(value) != 8 || err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, true
+}
+
+// page - Resolve the page dictionary, its resources and its visible box
+func (d *viewerDocument) page(pageNr int) (viewerPage, error) {
+	pageDict, _, inherited, err := d.ctx.PageDict(pageNr, false)
+	if err != nil {
+		return viewerPage{}, err
+	}
+	if pageDict == nil || inherited == nil {
+		return viewerPage{}, errors.New(localize("page %d not found", pageNr))
+	}
+
+	box := inherited.CropBox
+	if box == nil {
+		box = inherited.MediaBox
+	}
+	if box == nil {
+		box = types.NewRectangle(0, 0, 612, 792)
+	}
+	return viewerPage{
+		dict:      pageDict,
+		resources: inherited.Resources,
+		llx:       math.Min(box.LL.X, box.UR.X),
+		lly:       math.Min(box.LL.Y, box.UR.Y),
+		urx:       math.Max(box.LL.X, box.UR.X),
+		ury:       math.Max(box.LL.Y, box.UR.Y),
+		rotate:    ((inherited.Rotate % 360) + 360) % 360,
+	}, nil
+}
+
+// size returns the page width and height in points, as displayed
+func (p viewerPage) size() (float64, float64) {
+	width, height := p.urx-p.llx, p.ury-p.lly
+	if p.rotate == 90 || p.rotate == 270 {
+		return height, width
+	}
+	return width, height
+}
+
+// pixels returns the size of the page rendered at scale
+func (p viewerPage) pixels(scale float64) (int, int) {
+	width, height := p.size()
+	w, h := int(math.Ceil(width*scale-0.01)), int(math.Ceil(height*scale-0.01))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// device returns the matrix from user space to the pixels of the page rendered at scale, y pointing down,
+// shifted so that page pixel (ox, oy) lands on the origin of the canvas
+func (p viewerPage) device(scale, ox, oy float64) pdfMatrix {
+	var device pdfMatrix
+	switch p.rotate {
+	case 90:
+		device = pdfMatrix{0, scale, scale, 0, -p.lly * scale, -p.llx * scale}
+	case 180:
+		device = pdfMatrix{-scale, 0, 0, scale, p.urx * scale, -p.lly * scale}
+	case 270:
+		device = pdfMatrix{0, -scale, -scale, 0, p.ury * scale, p.urx * scale}
+	default:
+		device = pdfMatrix{scale, 0, 0, -scale, -p.llx * scale, p.ury * scale}
+	}
+	device[4] -= ox
+	device[5] -= oy
+	return device
+}
+
+// tileGrid returns the number of tile columns and rows covering a page of width x height pixels
+func tileGrid(width, height, tileSize int) (int, int) {
+	return (width + tileSize - 1) / tileSize, (height + tileSize - 1) / tileSize
+}
+
+// render - Paint the area of the page rendered at options.Scale, then the highlights over it
+func (d *viewerDocument) render(pageNr int, page viewerPage, options viewOptions, area image.Rectangle) (*image.RGBA, error) {
+	canvas := image.NewRGBA(image.Rect(0, 0, area.Dx(), area.Dy()))
+	if options.background.A > 0 {
+		draw.Draw(canvas, canvas.Rect, image.NewUniform(options.background), image.Point{}, draw.Src)
+	}
+
+	content, err := d.ctx.PageContent(page.dict)
+	if err != nil && !errors.Is(err, model.ErrNoContent) {
+		return nil, err
+	}
+	r := newPageRenderer(d.ctx, canvas, page.device(options.Scale, float64(area.Min.X), float64(area.Min.Y)))
+	r.fonts, r.images = d.fonts, d.images
+	r.run(content, page.resources)
+	if options.Annotations == nil || *options.Annotations {
+		r.drawAnnotations(page.dict)
+	}
+
+	highlights := options.Highlights
+	if options.Highlight != "" {
+		text, err := d.pageText(pageNr, page)
+		if err != nil {
+			return nil, err
+		}
+		needle, _ := foldText([]rune(options.Highlight), options.CaseSensitive)
+		for _, match := range text.matches(needle, options.CaseSensitive, options.WholeWord) {
+			highlights = append(highlights, text.matchRects(match)...)
+		}
+	}
+	drawHighlights(canvas, highlights, options.Scale, area.Min, options.highlightColor)
+	return canvas, nil
+}
+
+// drawHighlights multiplies the canvas with the highlight colour inside rectangles given at scale 1,
+// which darkens the background like a marker while text stays readable
+func drawHighlights(canvas *image.RGBA, rects []viewRect, scale float64, origin image.Point, c color.NRGBA) {
+	alpha := int(c.A)
+	tint := [3]int{int(c.R), int(c.G), int(c.B)}
+	for _, rect := range rects {
+		if rect.Width <= 0 || rect.Height <= 0 {
+			continue
+		}
+		area := image.Rect(
+			int(math.Floor(rect.X*scale))-origin.X, int(math.Floor(rect.Y*scale))-origin.Y,
+			int(math.Ceil((rect.X+rect.Width)*scale))-origin.X, int(math.Ceil((rect.Y+rect.Height)*scale))-origin.Y,
+		).Intersect(canvas.Rect)
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				i := canvas.PixOffset(x, y)
+				for k, v := range tint {
+					p := int(canvas.Pix[i+k])
+					canvas.Pix[i+k] = uint8((p*(255-alpha) + p*v/255*alpha) / 255)
+				}
+			}
+		}
+	}
+}
+
+// imageResult - Encode a canvas in the requested format, returned as a Uint8Array
+func imageResult(canvas *image.RGBA, pageNr int, options viewOptions) (map[string]interface{}, error) {
+	var data []byte
+	mimeType := "image/png"
+	switch options.Format {
+	case "jpeg":
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: options.Quality}); err != nil {
+			return nil, err
+		}
+		data, mimeType = buf.Bytes(), "image/jpeg"
+	case "rgba":
+		// ImageData expects straight alpha, the canvas holds premultiplied colours
+		data = make([]byte, len(canvas.Pix))
+		copy(data, canvas.Pix)
+		for i := 0; i < len(data); i += 4 {
+			if a := int(data[i+3]); a > 0 && a < 255 {
+				for k := 0; k < 3; k++ {
+					data[i+k] = uint8(int(data[i+k]) * 255 / a)
+				}
+			}
+		}
+		mimeType = "application/octet-stream"
+	default:
+		// Tiles are encoded often while panning, speed matters more than size
+		var buf bytes.Buffer
+		encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+		if err := encoder.Encode(&buf, canvas); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+
+	return map[string]interface{}{
+		"page":     pageNr,
+		"scale":    options.Scale,
+		"width":    canvas.Rect.Dx(),
+		"height":   canvas.Rect.Dy(),
+		"format":   options.Format,
+		"mimeType": mimeType,
+		"size":     len(data),
+		"data":     uint8Array(data),
+	}, nil
+}
+
+// uint8Array copies bytes into a new Uint8Array, which the worker loader moves to the page without a copy
+func uint8Array(data []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+// pageText - Run the content of a page without painting to collect its text, cached per page
+func (d *viewerDocument) pageText(pageNr int, page viewerPage) (*pageText, error) {
+	if text, ok := d.text[pageNr]; ok {
+		return text, nil
+	}
+
+	content, err := d.ctx.PageContent(page.dict)
+	if err != nil && !errors.Is(err, model.ErrNoContent) {
+		return nil, err
+	}
+	r := newPageRenderer(d.ctx, nil, page.device(1, 0, 0))
+	r.fonts, r.images = d.fonts, d.images
+	r.collectText = true
+	r.run(content, page.resources)
+
+	text := buildPageText(r.textGlyphs)
+	d.text[pageNr] = text
+	return text, nil
+}
+
+// buildPageText groups glyphs into items: a glyph continues the item of the previous one when it sits on the
+// same baseline, at most 1.5 em after it. Wider gaps start a new item on the same line, as in table cells.
+func buildPageText(glyphs []textGlyph) *pageText {
+	text := &pageText{}
+	var item *textItem
+	for _, g := range glyphs {
+		if item != nil {
+			last := item.glyphs[len(item.glyphs)-1]
+			along, across := baselineOffset(last, g)
+			sameLine := math.Abs(g.angle-last.angle) < 1 && math.Abs(across) < 0.35*last.size
+			if sameLine && along > -0.5*last.size && along < 1.5*last.size &&
+				math.Abs(g.size-last.size) < 0.2*last.size {
+				// Word spacing is often positioning rather than a space character
+				if along > 0.2*last.size && !unicode.IsSpace(item.runes[len(item.runes)-1]) && !unicode.IsSpace(g.text[0]) {
+					item.runes = append(item.runes, ' ')
+					item.owner = append(item.owner, -1)
+				}
+				item.appendGlyph(g)
+				continue
+			}
+			text.items = append(text.items, *item)
+			item = &textItem{newLine: !sameLine || along < 0}
+		} else {
+			item = &textItem{}
+		}
+		item.appendGlyph(g)
+	}
+	if item != nil {
+		text.items = append(text.items, *item)
+	}
+	return text
+}
+
+func (item *textItem) appendGlyph(g textGlyph) {
+	for _, c := range g.text {
+		item.runes = append(item.runes, c)
+		item.owner = append(item.owner, len(item.glyphs))
+	}
+	item.glyphs = append(item.glyphs, g)
+}
+
+// baselineOffset returns where g starts relative to the end of last, along and across the baseline of last
+func baselineOffset(last, g textGlyph) (float64, float64) {
+	dx, dy := last.end.x-last.origin.x, last.end.y-last.origin.y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		radians := last.angle * math.Pi / 180
+		dx, dy, length = math.Cos(radians), math.Sin(radians), 1
+	}
+	ux, uy := dx/length, dy/length
+	ox, oy := g.origin.x-last.end.x, g.origin.y-last.end.y
+	return ox*ux + oy*uy, oy*ux - ox*uy
+}
+
+// glyphBounds returns the axis-aligned rectangle covering glyphs
+func glyphBounds(glyphs []textGlyph) viewRect {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, g := range glyphs {
+		for _, p := range g.quad {
+			minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+			maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+		}
+	}
+	if math.IsInf(minX, 0) {
+		return viewRect{}
+	}
+	return viewRect{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+// foldText prepares text for matching: whitespace runs become one space, trimmed at both ends, and letters are
+// lowercased unless the search is case sensitive. keep tells which input runes were kept.
+func foldText(runes []rune, caseSensitive bool) ([]rune, []bool) {
+	out := make([]rune, 0, len(runes))
+	keep := make([]bool, len(runes))
+	for i, c := range runes {
+		if unicode.IsSpace(c) {
+			if len(out) == 0 || out[len(out)-1] == ' ' {
+				continue
+			}
+			c = ' '
+		} else if !caseSensitive {
+			c = unicode.ToLower(c)
+		}
+		out = append(out, c)
+		keep[i] = true
+	}
+	if len(out) > 0 && out[len(out)-1] == ' ' {
+		out = out[:len(out)-1]
+		for i := len(keep) - 1; i >= 0; i-- {
+			if keep[i] {
+				keep[i] = false
+				break
+			}
+		}
+	}
+	return out, keep
+}
+
+// stream flattens the page text for search, items separated by a space, with the origin of every rune
+func (t *pageText) stream(caseSensitive bool) ([]rune, []rune, []streamRune) {
+	raw := []rune{}
+	origins := []streamRune{}
+	for i, item := range t.items {
+		if i > 0 {
+			raw = append(raw, ' ')
+			origins = append(origins, streamRune{item: i, glyph: -1})
+		}
+		for j, c := range item.runes {
+			raw = append(raw, c)
+			origins = append(origins, streamRune{item: i, glyph: item.owner[j]})
+		}
+	}
+
+	folded, keep := foldText(raw, caseSensitive)
+	display := make([]rune, 0, len(folded))
+	kept := make([]streamRune, 0, len(folded))
+	for i, c := range raw {
+		if keep[i] {
+			if unicode.IsSpace(c) {
+				c = ' '
+			}
+			display = append(display, c)
+			kept = append(kept, origins[i])
+		}
+	}
+	return folded, display, kept
+}
+
+// textMatch is a hit of a search: the runes it covers in the flattened page text
+type textMatch struct {
+	start, end int
+	origins    []streamRune
+}
+
+// matches - Find the non-overlapping occurrences of a folded needle in the page text
+func (t *pageText) matches(needle []rune, caseSensitive, wholeWord bool) []textMatch {
+	haystack, _, origins := t.stream(caseSensitive)
+	return findMatches(haystack, origins, needle, wholeWord)
+}
+
+func findMatches(haystack []rune, origins []streamRune, needle []rune, wholeWord bool) []textMatch {
+	found := []textMatch{}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if !runesEqual(haystack[i:i+len(needle)], needle) {
+			continue
+		}
+		end := i + len(needle)
+		if wholeWord && (i > 0 && isWordRune(haystack[i-1]) || end < len(haystack) && isWordRune(haystack[end])) {
+			continue
+		}
+		found = append(found, textMatch{start: i, end: end, origins: origins[i:end]})
+		i = end - 1
+	}
+	return found
+}
+
+// search - Describe the hits of a folded needle on the page: the text found, its context and the rectangles
+// covering it at options.Scale
+func (t *pageText) search(needle []rune, options viewOptions) []map[string]interface{} {
+	haystack, display, origins := t.stream(options.CaseSensitive)
+	hits := []map[string]interface{}{}
+	for _, match := range findMatches(haystack, origins, needle, options.WholeWord) {
+		rects := []interface{}{}
+		for _, rect := range t.matchRects(match) {
+			rects = append(rects, map[string]interface{}{
+				"x":      roundCoordinate(rect.X * options.Scale),
+				"y":      roundCoordinate(rect.Y * options.Scale),
+				"width":  roundCoordinate(rect.Width * options.Scale),
+				"height": roundCoordinate(rect.Height * options.Scale),
+			})
+		}
+		before, after := match.start-searchContextRunes, match.end+searchContextRunes
+		if before < 0 {
+			before = 0
+		}
+		if after > len(display) {
+			after = len(display)
+		}
+		hits = append(hits, map[string]interface{}{
+			"text":    string(display[match.start:match.end]),
+			"context": strings.TrimSpace(string(display[before:after])),
+			"rects":   rects,
+		})
+	}
+	return hits
+}
+
+// matchRects returns one rectangle at scale 1 per text item a match spans
+func (t *pageText) matchRects(match textMatch) []viewRect {
+	rects := []viewRect{}
+	var glyphs []textGlyph
+	item, last := -1, -1
+	for _, origin := range match.origins {
+		if origin.glyph < 0 {
+			continue
+		}
+		if origin.item != item {
+			if len(glyphs) > 0 {
+				rects = append(rects, glyphBounds(glyphs))
+			}
+			glyphs, item, last = nil, origin.item, -1
+		}
+		// Ligatures give several runes to one glyph
+		if origin.glyph != last {
+			glyphs = append(glyphs, t.items[item].glyphs[origin.glyph])
+			last = origin.glyph
+		}
+	}
+	if len(glyphs) > 0 {
+		rects = append(rects, glyphBounds(glyphs))
+	}
+	return rects
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
+
+// roundCoordinate keeps two decimals, enough for sub-pixel positioning in CSS
+func roundCoordinate(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// pdfMatrix is an affine transform stored in PDF order [a b c d e f]
+type pdfMatrix [6]float64
+
+var identityMatrix = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+// multiply returns the transform applying m first and then n
+func (m pdfMatrix) multiply(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m pdfMatrix) apply(x, y float64) renderPoint {
+	return renderPoint{m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]}
+}
+
+// scale returns the mean scaling factor, used for line widths and curve flattening
+func (m pdfMatrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+func (m pdfMatrix) invert() (pdfMatrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if math.Abs(det) < 1e-12 {
+		return identityMatrix, false
+	}
+	return pdfMatrix{
+		m[3] / det,
+		-m[1] / det,
+		-m[2] / det,
+		m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det,
+		(m[1]*m[4] - m[0]*m[5]) / det,
+	}, true
+}
+
+type renderPoint struct {
+	x, y float64
+}
+
+type renderSubpath struct {
+	points []renderPoint
+	closed bool
+}
+
+// renderState is the part of the PDF graphics state the renderer honours
+type renderState struct {
+	ctm           pdfMatrix
+	fillSpace     *renderColorSpace
+	strokeSpace   *renderColorSpace
+	fillColor     []float64
+	strokeColor   []float64
+	fillShading   *renderShading
+	strokeShading *renderShading
+	fillAlpha     float64
+	strokeAlpha   float64
+	lineWidth     float64
+	lineCap       int
+	dash          []float64
+	dashPhase     float64
+	clip          *image.Alpha
+
+	font        *renderFont
+	fontSize    float64
+	charSpacing float64
+	wordSpacing float64
+	hScale      float64
+	leading     float64
+	rise        float64
+	textMode    int
+}
+
+type pageRenderer struct {
+	ctx      *model.Context
+	canvas   *image.RGBA
+	bounds   image.Rectangle
+	state    renderState
+	stack    []renderState
+	baseCTM  pdfMatrix
+	path     []renderSubpath
+	current  renderPoint
+	start    renderPoint
+	clipMode int
+	depth    int
+
+	textMatrix pdfMatrix
+	lineMatrix pdfMatrix
+	textClip   [][]renderPoint
+
+	fonts    map[string]*renderFont
+	images   map[int]image.Image
+	glyphBuf sfnt.Buffer
+
+	// collectText records the shown glyphs in textGlyphs for the text layer
+	collectText bool
+	textGlyphs  []textGlyph
+}
+
+// newPageRenderer - Start a renderer painting onto canvas. Without a canvas nothing is painted and
+// images are not decoded, the content is only followed for its text.
+func newPageRenderer(ctx *model.Context, canvas *image.RGBA, device pdfMatrix) *pageRenderer {
+	r := &pageRenderer{
+		ctx:     ctx,
+		canvas:  canvas,
+		baseCTM: device,
+		fonts:   map[string]*renderFont{},
+		images:  map[int]image.Image{},
+	}
+	if canvas != nil {
+		r.bounds = canvas.Rect
+	}
+	r.state = renderState{
+		ctm:         device,
+		fillSpace:   deviceGraySpace,
+		strokeSpace: deviceGraySpace,
+		fillColor:   []float64{0},
+		strokeColor: []float64{0},
+		fillAlpha:   1,
+		strokeAlpha: 1,
+		lineWidth:   1,
+		hScale:      1,
+	}
+	return r
+}
+
+// maxRenderDepth bounds nested form XObjects and Type 3 glyphs
+const maxRenderDepth = 16
+
+// run - Execute a content stream against the current graphics state
+func (r *pageRenderer) run(content []byte, resources types.Dict) {
+	lexer := &contentLexer{data: content}
+	operands := []interface{}{}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return
+		}
+		op, ok := token.(contentOperator)
+		if !ok {
+			operands = append(operands, token)
+			continue
+		}
+		if op == "BI" {
+			r.inlineImage(lexer, resources)
+		} else {
+			r.execute(string(op), operands, resources)
+		}
+		operands = operands[:0]
+	}
+}
+
+func (r *pageRenderer) execute(op string, operands []interface{}, resources types.Dict) {
+	st := &r.state
+	switch op {
+	case "q":
+		r.stack = append(r.stack, r.state)
+	case "Q":
+		if len(r.stack) > 0 {
+			r.state = r.stack[len(r.stack)-1]
+			r.stack = r.stack[:len(r.stack)-1]
+		}
+	case "cm":
+		if m, ok := operandMatrix(operands); ok {
+			st.ctm = m.multiply(st.ctm)
+		}
+	case "w":
+		st.lineWidth = operandNumber(operands, 0)
+	case "J":
+		st.lineCap = int(operandNumber(operands, 0))
+	case "d":
+		if len(operands) == 2 {
+			st.dash = numberArray(operands[0])
+			st.dashPhase = operandNumber(operands, 1)
+		}
+	case "gs":
+		r.applyExtGState(operandName(operands, 0), resources)
+
+	case "m":
+		if len(operands) >= 2 {
+			r.current = renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)}
+			r.start = r.current
+			r.path = append(r.path, renderSubpath{points: []renderPoint{st.ctm.apply(r.current.x, r.current.y)}})
+		}
+	case "l":
+		if len(operands) >= 2 {
+			r.lineTo(renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)})
+		}
+	case "c":
+		if len(operands) >= 6 {
+			r.curveTo(renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)},
+				renderPoint{operandNumber(operands, 2), operandNumber(operands, 3)},
+				renderPoint{operandNumber(operands, 4), operandNumber(operands, 5)})
+		}
+	case "v":
+		if len(operands) >= 4 {
+			r.curveTo(r.current,
+				renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)},
+				renderPoint{operandNumber(operands, 2), operandNumber(operands, 3)})
+		}
+	case "y":
+		if len(operands) >= 4 {
+			end := renderPoint{operandNumber(operands, 2), operandNumber(operands, 3)}
+			r.curveTo(renderPoint{operandNumber(operands, 0), operandNumber(operands, 1)}, end, end)
+		}
+	case "h":
+		if n := len(r.path); n > 0 {
+			r.path[n-1].closed = true
+			r.current = r.start
+		}
+	case "re":
+		if len(operands) >= 4 {
+			x, y := operandNumber(operands, 0), operandNumber(operands, 1)
+			w, h := operandNumber(operands, 2), operandNumber(operands, 3)
+			r.path = append(r.path, renderSubpath{points: []renderPoint{
+				st.ctm.apply(x, y), st.ctm.apply(x+w, y), st.ctm.apply(x+w, y+h), st.ctm.apply(x, y+h),
+			}, closed: true})
+			r.current, r.start = renderPoint{x, y}, renderPoint{x, y}
+		}
+
+	case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n":
+		if op == "s" || op == "b" || op == "b*" {
+			if n := len(r.path); n > 0 {
+				r.path[n-1].closed = true
+			}
+		}
+		evenOdd := strings.HasSuffix(op, "*")
+		if op != "S" && op != "s" && op != "n" {
+			r.fill(subpathPolygons(r.path), evenOdd, r.fillPaint())
+		}
+		if op == "S" || op == "s" || op == "B" || op == "B*" || op == "b" || op == "b*" {
+			r.stroke(r.path)
+		}
+		if r.clipMode != 0 {
+			r.intersectClip(subpathPolygons(r.path), r.clipMode == 2)
+			r.clipMode = 0
+		}
+		r.path = nil
+	case "W":
+		r.clipMode = 1
+	case "W*":
+		r.clipMode = 2
+
+	case "CS", "cs":
+		space := r.colorSpace(operandObject(operands, 0), resources)
+		if op == "CS" {
+			st.strokeSpace, st.strokeColor, st.strokeShading = space, space.initialColor(), nil
+		} else {
+			st.fillSpace, st.fillColor, st.fillShading = space, space.initialColor(), nil
+		}
+	case "SC", "SCN", "sc", "scn":
+		comps := []float64{}
+		for _, operand := range operands {
+			if v, ok := operand.(float64); ok {
+				comps = append(comps, v)
+			}
+		}
+		var shading *renderShading
+		if name := operandName(operands, len(operands)-1); name != "" {
+			shading = r.patternShading(name, resources)
+		}
+		if op == "SC" || op == "SCN" {
+			st.strokeColor, st.strokeShading = comps, shading
+		} else {
+			st.fillColor, st.fillShading = comps, shading
+		}
+	case "G", "g":
+		r.setDeviceColor(op == "G", deviceGraySpace, operands)
+	case "RG", "rg":
+		r.setDeviceColor(op == "RG", deviceRGBSpace, operands)
+	case "K", "k":
+		r.setDeviceColor(op == "K", deviceCMYKSpace, operands)
+
+	case "sh":
+		r.paintShading(operandName(operands, 0), resources)
+	case "Do":
+		r.drawXObject(operandName(operands, 0), resources)
+
+	case "BT":
+		r.textMatrix, r.lineMatrix = identityMatrix, identityMatrix
+		r.textClip = nil
+	case "ET":
+		if st.textMode >= 4 && len(r.textClip) > 0 {
+			r.intersectClip(r.textClip, false)
+		}
+		r.textClip = nil
+	case "Tc":
+		st.charSpacing = operandNumber(operands, 0)
+	case "Tw":
+		st.wordSpacing = operandNumber(operands, 0)
+	case "Tz":
+		st.hScale = operandNumber(operands, 0) / 100
+	case "TL":
+		st.leading = operandNumber(operands, 0)
+	case "Ts":
+		st.rise = operandNumber(operands, 0)
+	case "Tr":
+		st.textMode = int(operandNumber(operands, 0))
+	case "Tf":
+		if len(operands) >= 2 {
+			st.font = r.loadFont(operandName(operands, 0), resources)
+			st.fontSize = operandNumber(operands, 1)
+		}
+	case "Td", "TD":
+		if len(operands) >= 2 {
+			tx, ty := operandNumber(operands, 0), operandNumber(operands, 1)
+			if op == "TD" {
+				st.leading = -ty
+			}
+			r.lineMatrix = pdfMatrix{1, 0, 0, 1, tx, ty}.multiply(r.lineMatrix)
+			r.textMatrix = r.lineMatrix
+		}
+	case "Tm":
+		if m, ok := operandMatrix(operands); ok {
+			r.textMatrix, r.lineMatrix = m, m
+		}
+	case "T*":
+		r.nextLine()
+	case "Tj":
+		if s, ok := operandObject(operands, 0).([]byte); ok {
+			r.showText([]interface{}{s}, resources)
+		}
+	case "'":
+		r.nextLine()
+		if s, ok := operandObject(operands, 0).([]byte); ok {
+			r.showText([]interface{}{s}, resources)
+		}
+	case "\"":
+		if len(operands) >= 3 {
+			st.wordSpacing = operandNumber(operands, 0)
+			st.charSpacing = operandNumber(operands, 1)
+			r.nextLine()
+			if s, ok := operands[2].([]byte); ok {
+				r.showText([]interface{}{s}, resources)
+			}
+		}
+	case "TJ":
+		if items, ok := operandObject(operands, 0).([]interface{}); ok {
+			r.showText(items, resources)
+		}
+	}
+}
+
+func (r *pageRenderer) lineTo(p renderPoint) {
+	if len(r.path) == 0 {
+		r.path = append(r.path, renderSubpath{points: []renderPoint{r.state.ctm.apply(r.current.x, r.current.y)}})
+	}
+	sub := &r.path[len(r.path)-1]
+	sub.points = append(sub.points, r.state.ctm.apply(p.x, p.y))
+	r.current = p
+}
+
+func (r *pageRenderer) curveTo(c1, c2, end renderPoint) {
+	if len(r.path) == 0 {
+		r.path = append(r.path, renderSubpath{points: []renderPoint{r.state.ctm.apply(r.current.x, r.current.y)}})
+	}
+	m := r.state.ctm
+	sub := &r.path[len(r.path)-1]
+	sub.points = flattenCubic(sub.points, m.apply(r.current.x, r.current.y), m.apply(c1.x, c1.y), m.apply(c2.x, c2.y), m.apply(end.x, end.y))
+	r.current = end
+}
+
+func (r *pageRenderer) nextLine() {
+	r.lineMatrix = pdfMatrix{1, 0, 0, 1, 0, -r.state.leading}.multiply(r.lineMatrix)
+	r.textMatrix = r.lineMatrix
+}
+
+// flattenCubic appends line segments approximating a Bézier curve in device space
+func flattenCubic(points []renderPoint, p0, p1, p2, p3 renderPoint) []renderPoint {
+	length := math.Hypot(p1.x-p0.x, p1.y-p0.y) + math.Hypot(p2.x-p1.x, p2.y-p1.y) + math.Hypot(p3.x-p2.x, p3.y-p2.y)
+	steps := int(math.Min(math.Max(math.Ceil(math.Sqrt(length)*1.5), 1), 100))
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		points = append(points, renderPoint{
+			a*p0.x + b*p1.x + c*p2.x + d*p3.x,
+			a*p0.y + b*p1.y + c*p2.y + d*p3.y,
+		})
+	}
+	return points
+}
+
+// flattenQuad appends line segments approximating a quadratic curve in device space
+func flattenQuad(points []renderPoint, p0, p1, p2 renderPoint) []renderPoint {
+	length := math.Hypot(p1.x-p0.x, p1.y-p0.y) + math.Hypot(p2.x-p1.x, p2.y-p1.y)
+	steps := int(math.Min(math.Max(math.Ceil(math.Sqrt(length)*1.5), 1), 100))
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		u := 1 - t
+		a, b, c := u*u, 2*u*t, t*t
+		points = append(points, renderPoint{a*p0.x + b*p1.x + c*p2.x, a*p0.y + b*p1.y + c*p2.y})
+	}
+	return points
+}
+
+func subpathPolygons(path []renderSubpath) [][]renderPoint {
+	polygons := make([][]renderPoint, 0, len(path))
+	for _, sub := range path {
+		if len(sub.points) > 2 {
+			polygons = append(polygons, sub.points)
+		}
+	}
+	return polygons
+}
+
+// renderPaint is either a solid colour or a shading evaluated per pixel
+type renderPaint struct {
+	color   color.NRGBA
+	shading *renderShading
+}
+
+func (r *pageRenderer) fillPaint() renderPaint {
+	st := &r.state
+	if st.fillShading != nil {
+		return renderPaint{shading: st.fillShading, color: color.NRGBA{A: alphaByte(st.fillAlpha)}}
+	}
+	return renderPaint{color: st.fillSpace.toNRGBA(st.fillColor, st.fillAlpha)}
+}
+
+func (r *pageRenderer) strokePaint() renderPaint {
+	st := &r.state
+	if st.strokeShading != nil {
+		return renderPaint{shading: st.strokeShading, color: color.NRGBA{A: alphaByte(st.strokeAlpha)}}
+	}
+	return renderPaint{color: st.strokeSpace.toNRGBA(st.strokeColor, st.strokeAlpha)}
+}
+
+func alphaByte(alpha float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, alpha)) * 255))
+}
+
+// rasterize - Compute the coverage of polygons, clipped to the page and the current clip path
+func (r *pageRenderer) rasterize(polygons [][]renderPoint, evenOdd bool, clip *image.Alpha) *image.Alpha {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, p := range polygon {
+			minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+			maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+		}
+	}
+	if math.IsInf(minX, 0) || math.IsNaN(minX+minY+maxX+maxY) {
+		return nil
+	}
+	rect := image.Rect(int(math.Floor(math.Max(minX, -1))), int(math.Floor(math.Max(minY, -1))),
+		int(math.Ceil(math.Min(maxX, float64(r.bounds.Max.X+1)))), int(math.Ceil(math.Min(maxY, float64(r.bounds.Max.Y+1)))))
+	rect = rect.Intersect(r.bounds)
+	if clip != nil {
+		rect = rect.Intersect(clip.Rect)
+	}
+	if rect.Empty() {
+		return nil
+	}
+
+	var mask *image.Alpha
+	if evenOdd && len(polygons) > 1 {
+		// The rasterizer only knows the non-zero rule, so even-odd coverage is combined per subpath
+		mask = image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+		for _, polygon := range polygons {
+			part := rasterizePolygons([][]renderPoint{polygon}, rect)
+			for i, v := range part.Pix {
+				a, b := int(mask.Pix[i]), int(v)
+				mask.Pix[i] = uint8(a + b - 2*a*b/255)
+			}
+		}
+	} else {
+		mask = rasterizePolygons(polygons, rect)
+	}
+	mask.Rect = rect
+
+	if clip != nil {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			row := mask.Pix[(y-rect.Min.Y)*mask.Stride:]
+			clipRow := clip.Pix[clip.PixOffset(rect.Min.X, y):]
+			for x := 0; x < rect.Dx(); x++ {
+				row[x] = uint8(int(row[x]) * int(clipRow[x]) / 255)
+			}
+		}
+	}
+	return mask
+}
+
+func rasterizePolygons(polygons [][]renderPoint, rect image.Rectangle) *image.Alpha {
+	z := vector.NewRasterizer(rect.Dx(), rect.Dy())
+	z.DrawOp = draw.Src
+	ox, oy := float64(rect.Min.X), float64(rect.Min.Y)
+	for _, polygon := range polygons {
+		if len(polygon) < 2 {
+			continue
+		}
+		z.MoveTo(float32(polygon[0].x-ox), float32(polygon[0].y-oy))
+		for _, p := range polygon[1:] {
+			z.LineTo(float32(p.x-ox), float32(p.y-oy))
+		}
+		z.ClosePath()
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	z.Draw(mask, mask.Rect, image.Opaque, image.Point{})
+	return mask
+}
+
+// fill - Paint polygons with a colour or shading
+func (r *pageRenderer) fill(polygons [][]renderPoint, evenOdd bool, paint renderPaint) {
+	if len(polygons) == 0 || paint.color.A == 0 && paint.shading == nil {
+		return
+	}
+	mask := r.rasterize(polygons, evenOdd, r.state.clip)
+	if mask == nil {
+		return
+	}
+	r.composite(mask, paint)
+}
+
+func (r *pageRenderer) composite(mask *image.Alpha, paint renderPaint) {
+	if paint.shading == nil {
+		draw.DrawMask(r.canvas, mask.Rect, image.NewUniform(paint.color), image.Point{}, mask, mask.Rect.Min, draw.Over)
+		return
+	}
+	shaded := image.NewNRGBA(mask.Rect)
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			if mask.Pix[mask.PixOffset(x, y)] == 0 {
+				continue
+			}
+			if c, ok := paint.shading.colorAt(float64(x)+0.5, float64(y)+0.5); ok {
+				c.A = uint8(int(c.A) * int(paint.color.A) / 255)
+				shaded.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	draw.DrawMask(r.canvas, mask.Rect, shaded, mask.Rect.Min, mask, mask.Rect.Min, draw.Over)
+}
+
+// stroke - Paint the outline of the current path
+func (r *pageRenderer) stroke(path []renderSubpath) {
+	st := &r.state
+	scale := st.ctm.scale()
+	width := math.Max(st.lineWidth*scale, 1)
+	var dash []float64
+	for _, d := range st.dash {
+		dash = append(dash, d*scale)
+	}
+	r.fill(strokePolygons(path, width, st.lineCap, dash, st.dashPhase*scale), false, r.strokePaint())
+}
+
+// strokePolygons outlines each segment with a quad, adding round joins and the requested caps
+func strokePolygons(path []renderSubpath, width float64, lineCap int, dash []float64, phase float64) [][]renderPoint {
+	half := width / 2
+	polygons := [][]renderPoint{}
+	for _, sub := range path {
+		points := sub.points
+		if sub.closed && len(points) > 1 {
+			points = append(append([]renderPoint{}, points...), points[0])
+		}
+		runs := [][]renderPoint{points}
+		if len(dash) > 0 {
+			runs = dashPolyline(points, dash, phase)
+		}
+		for _, run := range runs {
+			if len(run) == 1 || len(run) == 2 && run[0] == run[1] {
+				if lineCap == 1 {
+					polygons = append(polygons, circlePolygon(run[0], half))
+				}
+				continue
+			}
+			open := !sub.closed || len(dash) > 0
+			for i := 0; i+1 < len(run); i++ {
+				p, q := run[i], run[i+1]
+				dx, dy := q.x-p.x, q.y-p.y
+				length := math.Hypot(dx, dy)
+				if length == 0 {
+					continue
+				}
+				ux, uy := dx/length, dy/length
+				if open && lineCap == 2 {
+					if i == 0 {
+						p = renderPoint{p.x - ux*half, p.y - uy*half}
+					}
+					if i+2 == len(run) {
+						q = renderPoint{q.x + ux*half, q.y + uy*half}
+					}
+				}
+				nx, ny := -uy*half, ux*half
+				polygons = append(polygons, orientPolygon([]renderPoint{
+					{p.x + nx, p.y + ny}, {q.x + nx, q.y + ny}, {q.x - nx, q.y - ny}, {p.x - nx, p.y - ny},
+				}))
+				joint := i+1 < len(run)-1 || !open
+				if width > 1.5 && joint {
+					polygons = append(polygons, circlePolygon(q, half))
+				}
+			}
+			if open && lineCap == 1 {
+				polygons = append(polygons, circlePolygon(run[0], half), circlePolygon(run[len(run)-1], half))
+			}
+		}
+	}
+	return polygons
+}
+
+// dashPolyline splits a polyline into the visible runs of a dash pattern
+func dashPolyline(points []renderPoint, dash []float64, phase float64) [][]renderPoint {
+	total := 0.0
+	for _, d := range dash {
+		total += d
+	}
+	if total <= 0 {
+		return [][]renderPoint{points}
+	}
+	index, remaining, on := 0, dash[0], true
+	for phase = math.Mod(phase, total); phase > 0; {
+		if phase < remaining {
+			remaining -= phase
+			break
+		}
+		phase -= remaining
+		index = (index + 1) % len(dash)
+		remaining, on = dash[index], !on
+	}
+
+	runs := [][]renderPoint{}
+	var run []renderPoint
+	if on {
+		run = []renderPoint{points[0]}
+	}
+	for i := 0; i+1 < len(points); i++ {
+		p, q := points[i], points[i+1]
+		length := math.Hypot(q.x-p.x, q.y-p.y)
+		for pos := 0.0; length-pos > 1e-9; {
+			step := math.Min(remaining, length-pos)
+			pos += step
+			remaining -= step
+			t := pos / length
+			point := renderPoint{p.x + (q.x-p.x)*t, p.y + (q.y-p.y)*t}
+			if on {
+				run = append(run, point)
+			}
+			if remaining <= 1e-9 {
+				if on && len(run) > 1 {
+					runs = append(runs, run)
+				}
+				run = nil
+				index = (index + 1) % len(dash)
+				remaining, on = dash[index], !on
+				if on {
+					run = []renderPoint{point}
+				}
+			}
+		}
+	}
+	if on && len(run) > 1 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// orientPolygon reverses a polygon when needed so overlapping stroke pieces accumulate instead of cancelling
+func orientPolygon(polygon []renderPoint) []renderPoint {
+	area := 0.0
+	for i := range polygon {
+		p, q := polygon[i], polygon[(i+1)%len(polygon)]
+		area += p.x*q.y - q.x*p.y
+	}
+	if area < 0 {
+		for i, j := 0, len(polygon)-1; i < j; i, j = i+1, j-1 {
+			polygon[i], polygon[j] = polygon[j], polygon[i]
+		}
+	}
+	return polygon
+}
+
+func circlePolygon(center renderPoint, radius float64) []renderPoint {
+	steps := int(math.Min(math.Max(radius*2, 8), 48))
+	polygon := make([]renderPoint, steps)
+	for i := range polygon {
+		angle := 2 * math.Pi * float64(i) / float64(steps)
+		polygon[i] = renderPoint{center.x + radius*math.Cos(angle), center.y + radius*math.Sin(angle)}
+	}
+	return polygon
+}
+
+// intersectClip - Narrow the clip path, which always covers the whole page once set
+func (r *pageRenderer) intersectClip(polygons [][]renderPoint, evenOdd bool) {
+	clip := image.NewAlpha(r.bounds)
+	if mask := r.rasterize(polygons, evenOdd, r.state.clip); mask != nil {
+		for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+			copy(clip.Pix[clip.PixOffset(mask.Rect.Min.X, y):], mask.Pix[(y-mask.Rect.Min.Y)*mask.Stride:][:mask.Rect.Dx()])
+		}
+	}
+	r.state.clip = clip
+}
+
+func (r *pageRenderer) setDeviceColor(stroke bool, space *renderColorSpace, operands []interface{}) {
+	comps := make([]float64, space.components)
+	for i := range comps {
+		comps[i] = operandNumber(operands, i)
+	}
+	if stroke {
+		r.state.strokeSpace, r.state.strokeColor, r.state.strokeShading = space, comps, nil
+	} else {
+		r.state.fillSpace, r.state.fillColor, r.state.fillShading = space, comps, nil
+	}
+}
+
+// applyExtGState - Apply the graphics state parameters the renderer supports
+func (r *pageRenderer) applyExtGState(name string, resources types.Dict) {
+	d := r.resourceDict(resources, "ExtGState", name)
+	if d == nil {
+		return
+	}
+	st := &r.state
+	if v, ok := r.number(d["LW"]); ok {
+		st.lineWidth = v
+	}
+	if v, ok := r.number(d["LC"]); ok {
+		st.lineCap = int(v)
+	}
+	if v, ok := r.number(d["ca"]); ok {
+		st.fillAlpha = v
+	}
+	if v, ok := r.number(d["CA"]); ok {
+		st.strokeAlpha = v
+	}
+	if a, err := r.ctx.DereferenceArray(d["D"]); err == nil && len(a) == 2 {
+		dash, _ := r.ctx.DereferenceArray(a[0])
+		st.dash = st.dash[:0:0]
+		for _, o := range dash {
+			if v, ok := r.number(o); ok {
+				st.dash = append(st.dash, v)
+			}
+		}
+		st.dashPhase, _ = r.number(a[1])
+	}
+	if a, err := r.ctx.DereferenceArray(d["Font"]); err == nil && len(a) == 2 {
+		if fd, err := r.ctx.DereferenceDict(a[0]); err == nil && fd != nil {
+			st.font = r.fontFromDict(fd, a[0])
+			st.fontSize, _ = r.number(a[1])
+		}
+	}
+}
+
+func (r *pageRenderer) number(o types.Object) (float64, bool) {
+	if o == nil {
+		return 0, false
+	}
+	v, err := r.ctx.DereferenceNumber(o)
+	return v, err == nil
+}
+
+// resourceDict - Look up a named resource of the given category as a dictionary
+func (r *pageRenderer) resourceDict(resources types.Dict, category, name string) types.Dict {
+	o := r.resource(resources, category, name)
+	if o == nil {
+		return nil
+	}
+	switch o := o.(type) {
+	case types.Dict:
+		return o
+	case types.StreamDict:
+		return o.Dict
+	}
+	return nil
+}
+
+func (r *pageRenderer) resource(resources types.Dict, category, name string) types.Object {
+	if resources == nil {
+		return nil
+	}
+	entries, err := r.ctx.DereferenceDict(resources[category])
+	if err != nil || entries == nil {
+		return nil
+	}
+	o, err := r.ctx.Dereference(entries[name])
+	if err != nil {
+		return nil
+	}
+	return o
+}
+
+// drawXObject - Paint an image or form XObject
+func (r *pageRenderer) drawXObject(name string, resources types.Dict) {
+	entries, err := r.ctx.DereferenceDict(resources["XObject"])
+	if err != nil || entries == nil {
+		return
+	}
+	ref := entries[name]
+	sd, _, err := r.ctx.DereferenceStreamDict(ref)
+	if err != nil || sd == nil {
+		return
+	}
+
+	switch subtype := sd.NameEntry("Subtype"); {
+	case subtype != nil && *subtype == "Image":
+		if r.canvas == nil {
+			return
+		}
+		objNr := -1
+		if indRef, ok := ref.(types.IndirectRef); ok {
+			objNr = indRef.ObjectNumber.Value()
+		}
+		img, cached := r.images[objNr]
+		if !cached || objNr < 0 {
+			img = r.decodeImage(sd)
+			if objNr >= 0 {
+				r.images[objNr] = img
+			}
+		}
+		r.drawImage(img, sd)
+	case subtype != nil && *subtype == "Form":
+		r.drawForm(sd, resources)
+	}
+}
+
+// drawForm - Execute a form XObject inside its bounding box
+func (r *pageRenderer) drawForm(sd *types.StreamDict, resources types.Dict) {
+	if r.depth >= maxRenderDepth {
+		return
+	}
+	if err := sd.Decode(); err != nil {
+		return
+	}
+	if res, err := r.ctx.DereferenceDict(sd.Dict["Resources"]); err == nil && res != nil {
+		resources = res
+	}
+
+	saved, savedStack, savedBase, savedPath := r.state, r.stack, r.baseCTM, r.path
+	r.stack, r.path = nil, nil
+	if a, err := r.ctx.DereferenceArray(sd.Dict["Matrix"]); err == nil && len(a) == 6 {
+		if m, ok := r.matrixFromArray(a); ok {
+			r.state.ctm = m.multiply(r.state.ctm)
+		}
+	}
+	if a, err := r.ctx.DereferenceArray(sd.Dict["BBox"]); err == nil && len(a) == 4 {
+		var v [4]float64
+		for i := range v {
+			v[i], _ = r.number(a[i])
+		}
+		m := r.state.ctm
+		r.intersectClip([][]renderPoint{{m.apply(v[0], v[1]), m.apply(v[2], v[1]), m.apply(v[2], v[3]), m.apply(v[0], v[3])}}, false)
+	}
+	r.baseCTM = r.state.ctm
+
+	r.depth++
+	r.run(sd.Content, resources)
+	r.depth--
+
+	r.state, r.stack, r.baseCTM, r.path = saved, savedStack, savedBase, savedPath
+}
+
+func (r *pageRenderer) matrixFromArray(a types.Array) (pdfMatrix, bool) {
+	var m pdfMatrix
+	for i := range m {
+		v, ok := r.number(a[i])
+		if !ok {
+			return identityMatrix, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+// drawAnnotations - Paint the normal appearance of visible annotations such as filled form fields
+func (r *pageRenderer) drawAnnotations(pageDict types.Dict) {
+	annots, err := r.ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return
+	}
+	for _, o := range annots {
+		d, err := r.ctx.DereferenceDict(o)
+		if err != nil || d == nil {
+			continue
+		}
+		if subtype := d.NameEntry("Subtype"); subtype != nil && *subtype == "Popup" {
+			continue
+		}
+		// Hidden (bit 2) and NoView (bit 6) annotations are not displayed
+		if flags, ok := r.number(d["F"]); ok && int(flags)&(2|32) != 0 {
+			continue
+		}
+		ap, err := r.ctx.DereferenceDict(d["AP"])
+		if err != nil || ap == nil {
+			continue
+		}
+		normal, err := r.ctx.Dereference(ap["N"])
+		if err != nil || normal == nil {
+			continue
+		}
+		var sd *types.StreamDict
+		switch n := normal.(type) {
+		case types.StreamDict:
+			sd = &n
+		case types.Dict:
+			if state := d.NameEntry("AS"); state != nil {
+				sd, _, _ = r.ctx.DereferenceStreamDict(n[*state])
+			}
+		}
+		rect, err := r.ctx.DereferenceArray(d["Rect"])
+		if sd == nil || err != nil || len(rect) != 4 {
+			continue
+		}
+		r.drawAppearance(sd, rect)
+	}
+}
+
+// drawAppearance maps the transformed form bounding box onto the annotation rectangle
+func (r *pageRenderer) drawAppearance(sd *types.StreamDict, rect types.Array) {
+	var v [4]float64
+	for i := range v {
+		v[i], _ = r.number(rect[i])
+	}
+	bbox, err := r.ctx.DereferenceArray(sd.Dict["BBox"])
+	if err != nil || len(bbox) != 4 {
+		return
+	}
+	var b [4]float64
+	for i := range b {
+		b[i], _ = r.number(bbox[i])
+	}
+	m := identityMatrix
+	if a, err := r.ctx.DereferenceArray(sd.Dict["Matrix"]); err == nil && len(a) == 6 {
+		m, _ = r.matrixFromArray(a)
+	}
+	corners := []renderPoint{m.apply(b[0], b[1]), m.apply(b[2], b[1]), m.apply(b[2], b[3]), m.apply(b[0], b[3])}
+	minX, minY, maxX, maxY := corners[0].x, corners[0].y, corners[0].x, corners[0].y
+	for _, p := range corners[1:] {
+		minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+		maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+	}
+	if maxX-minX <= 0 || maxY-minY <= 0 {
+		return
+	}
+	llx, lly := math.Min(v[0], v[2]), math.Min(v[1], v[3])
+	sx, sy := (math.Abs(v[2]-v[0]))/(maxX-minX), (math.Abs(v[3]-v[1]))/(maxY-minY)
+	fit := pdfMatrix{sx, 0, 0, sy, llx - minX*sx, lly - minY*sy}
+
+	saved := r.state
+	r.state.ctm = fit.multiply(r.baseCTM)
+	r.drawForm(sd, nil)
+	r.state = saved
+}
+
+// decodeImage - Decode an image XObject, returning an *image.Alpha for stencil masks
+func (r *pageRenderer) decodeImage(sd *types.StreamDict) (img image.Image) {
+	// pdfcpu panics on some malformed image dictionaries, treat them as undecodable
+	defer func() {
+		if rec := recover(); rec != nil {
+			img = nil
+		}
+	}()
+	if sd.IntEntry("Width") == nil || sd.IntEntry("Height") == nil {
+		return nil
+	}
+
+	// Work on a copy, extraction replaces the stream content and may add entries
+	cp := *sd
+	cp.Dict = sd.Dict.Clone().(types.Dict)
+	cp.Content = nil
+
+	stencil := false
+	if im := cp.BooleanEntry("ImageMask"); im != nil && *im {
+		stencil = true
+		cp.Dict["ColorSpace"] = types.Name("DeviceGray")
+		cp.Dict["BitsPerComponent"] = types.Integer(1)
+	}
+
+	extracted, err := pdfcpu.ExtractImage(r.ctx, &cp, false, "", 0, false)
+	if err != nil || extracted == nil || extracted.Reader == nil {
+		return nil
+	}
+	switch extracted.FileType {
+	case "png":
+		img, err = png.Decode(extracted.Reader)
+	case "jpg":
+		img, err = jpeg.Decode(extracted.Reader)
+	case "tif":
+		img, err = tiff.Decode(extracted.Reader)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	if !stencil {
+		// pdfcpu ignores an inverted decode array on soft masks
+		if nrgba, ok := img.(*image.NRGBA); ok && r.invertedSoftMask(sd) {
+			for i := 3; i < len(nrgba.Pix); i += 4 {
+				nrgba.Pix[i] = 255 - nrgba.Pix[i]
+			}
+		}
+		return img
+	}
+
+	// Sample value 0 paints unless the decode array is inverted
+	inverted := false
+	if decode, err := r.ctx.DereferenceArray(sd.Dict["Decode"]); err == nil && len(decode) == 2 {
+		first, _ := r.number(decode[0])
+		inverted = first == 1
+	}
+	bounds := img.Bounds()
+	mask := image.NewAlpha(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gray := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+			if (gray < 128) != inverted {
+				mask.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+		}
+	}
+	return mask
+}
+
+// invertedSoftMask - Report whether the soft mask of an image uses the decode array [1 0]
+func (r *pageRenderer) invertedSoftMask(sd *types.StreamDict) bool {
+	if _, ok := sd.Dict["SMask"]; !ok {
+		return false
+	}
+	smask, _, err := r.ctx.DereferenceStreamDict(sd.Dict["SMask"])
+	if err != nil || smask == nil {
+		return false
+	}
+	decode, err := r.ctx.DereferenceArray(smask.Dict["Decode"])
+	if err != nil || len(decode) != 2 {
+		return false
+	}
+	first, _ := r.number(decode[0])
+	return first == 1
+}
+
+// drawImage - Map an image onto the unit square of the current transformation matrix
+func (r *pageRenderer) drawImage(img image.Image, sd *types.StreamDict) {
+	m := r.state.ctm
+	if img == nil {
+		// Undecodable images (JPEG 2000, exotic colour spaces) are shown as a neutral placeholder
+		placeholder := [][]renderPoint{{m.apply(0, 0), m.apply(1, 0), m.apply(1, 1), m.apply(0, 1)}}
+		r.fill(placeholder, false, renderPaint{color: color.NRGBA{R: 221, G: 221, B: 221, A: 255}})
+		return
+	}
+	if mask, ok := img.(*image.Alpha); ok {
+		fill := r.fillPaint().color
+		colored := image.NewNRGBA(mask.Rect)
+		for i, a := range mask.Pix {
+			colored.Pix[i*4], colored.Pix[i*4+1], colored.Pix[i*4+2] = fill.R, fill.G, fill.B
+			colored.Pix[i*4+3] = uint8(int(a) * int(fill.A) / 255)
+		}
+		img = colored
+	}
+
+	bounds := img.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	if w == 0 || h == 0 {
+		return
+	}
+	// Source pixel (sx, sy) lands on unit square point (sx/w, 1-sy/h)
+	s2d := [6]float64{
+		m[0] / w, -m[2] / h, m[2] + m[4] - m[0]/w*float64(bounds.Min.X) + m[2]/h*float64(bounds.Min.Y),
+		m[1] / w, -m[3] / h, m[3] + m[5] - m[1]/w*float64(bounds.Min.X) + m[3]/h*float64(bounds.Min.Y),
+	}
+	options := &draw.Options{}
+	if r.state.clip != nil {
+		options.DstMask = r.state.clip
+	}
+	var interpolator draw.Transformer = draw.ApproxBiLinear
+	if math.Hypot(m[0], m[1]) < w/2 || math.Hypot(m[2], m[3]) < h/2 {
+		interpolator = draw.BiLinear
+	}
+	interpolator.Transform(r.canvas, s2d, img, bounds, draw.Over, options)
+}
+
+// inlineImageKeys expands the abbreviations allowed in inline image dictionaries
+var inlineImageKeys = map[string]string{
+	"BPC": "BitsPerComponent", "CS": "ColorSpace", "D": "Decode", "DP": "DecodeParms",
+	"F": "Filter", "H": "Height", "IM": "ImageMask", "I": "Interpolate", "W": "Width",
+}
+
+var inlineImageNames = map[string]string{
+	"G": "DeviceGray", "RGB": "DeviceRGB", "CMYK": "DeviceCMYK", "I": "Indexed",
+	"AHx": "ASCIIHexDecode", "A85": "ASCII85Decode", "LZW": "LZWDecode", "Fl": "FlateDecode",
+	"RL": "RunLengthDecode", "CCF": "CCITTFaxDecode", "DCT": "DCTDecode",
+}
+
+// inlineImage - Read a BI ... ID ... EI sequence and paint it
+func (r *pageRenderer) inlineImage(lexer *contentLexer, resources types.Dict) {
+	d := types.Dict{}
+	var key string
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return
+		}
+		if op, ok := token.(contentOperator); ok {
+			if op != "ID" {
+				return
+			}
+			break
+		}
+		if key == "" {
+			if name, ok := token.(contentName); ok {
+				key = string(name)
+				if full, ok := inlineImageKeys[key]; ok {
+					key = full
+				}
+			}
+			continue
+		}
+		d[key] = inlineImageObject(token)
+		key = ""
+	}
+	data := lexer.inlineImageData()
+	if r.canvas == nil {
+		return
+	}
+
+	// Colour spaces may name a resource
+	if name, ok := d["ColorSpace"].(types.Name); ok {
+		if cs := r.resource(resources, "ColorSpace", string(name)); cs != nil {
+			d["ColorSpace"] = cs
+		}
+	}
+
+	var pipeline []types.PDFFilter
+	var parms []types.Dict
+	switch p := d["DecodeParms"].(type) {
+	case types.Dict:
+		parms = []types.Dict{p}
+	case types.Array:
+		for _, o := range p {
+			pd, _ := o.(types.Dict)
+			parms = append(parms, pd)
+		}
+	}
+	filters := []types.Object{}
+	switch f := d["Filter"].(type) {
+	case types.Name:
+		filters = append(filters, f)
+	case types.Array:
+		filters = f
+	}
+	for i, f := range filters {
+		name, _ := f.(types.Name)
+		filter := types.PDFFilter{Name: string(name)}
+		if i < len(parms) {
+			filter.DecodeParms = parms[i]
+		}
+		pipeline = append(pipeline, filter)
+	}
+	d["Filter"] = nil
+	delete(d, "Filter")
+	delete(d, "DecodeParms")
+	if len(pipeline) > 0 {
+		names := types.Array{}
+		for _, f := range pipeline {
+			names = append(names, types.Name(f.Name))
+		}
+		d["Filter"] = names
+	}
+
+	length := int64(len(data))
+	sd := types.NewStreamDict(d, 0, &length, nil, pipeline)
+	sd.Raw = data
+	r.drawImage(r.decodeImage(&sd), &sd)
+}
+
+func inlineImageObject(token interface{}) types.Object {
+	switch v := token.(type) {
+	case contentName:
+		if full, ok := inlineImageNames[string(v)]; ok {
+			return types.Name(full)
+		}
+		return types.Name(v)
+	case float64:
+		if v == math.Trunc(v) {
+			return types.Integer(int(v))
+		}
+		return types.Float(v)
+	case bool:
+		return types.Boolean(v)
+	case []byte:
+		return types.StringLiteral(string(v))
+	case []interface{}:
+		a := types.Array{}
+		for _, item := range v {
+			a = append(a, inlineImageObject(item))
+		}
+		return a
+	case map[string]interface{}:
+		d := types.Dict{}
+		for k, item := range v {
+			d[k] = inlineImageObject(item)
+		}
+		return d
+	}
+	return nil
+}
+
+func operandNumber(operands []interface{}, i int) float64 {
+	if i < len(operands) {
+		if v, ok := operands[i].(float64); ok {
+			return v
+		}
+	}
+	return 0
+}
+
+func operandName(operands []interface{}, i int) string {
+	if i < len(operands) {
+		if v, ok := operands[i].(contentName); ok {
+			return string(v)
+		}
+	}
+	return ""
+}
+
+func operandObject(operands []interface{}, i int) interface{} {
+	if i < len(operands) {
+		return operands[i]
+	}
+	return nil
+}
+
+func operandMatrix(operands []interface{}) (pdfMatrix, bool) {
+	if len(operands) < 6 {
+		return identityMatrix, false
+	}
+	var m pdfMatrix
+	for i := range m {
+		v, ok := operands[len(operands)-6+i].(float64)
+		if !ok {
+			return identityMatrix, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+func numberArray(o interface{}) []float64 {
+	items, _ := o.([]interface{})
+	values := []float64{}
+	for _, item := range items {
+		if v, ok := item.(float64); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// contentOperator and contentName distinguish bare keywords from /Names in content streams
+type contentOperator string
+
+type contentName string
+
+// contentLexer tokenizes content streams; strings come back as []byte, arrays and dictionaries as Go values
+type contentLexer struct {
+	data []byte
+	pos  int
+}
+
+var errContentEnd = errors.New("end of content")
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *contentLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFWhitespace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *contentLexer) regularToken() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// next returns the next operand or operator
+func (l *contentLexer) next() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errContentEnd
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		name := l.regularToken()
+		if strings.Contains(name, "#") {
+			name = decodeNameEscapes(name)
+		}
+		return contentName(name), nil
+	case c == '(':
+		l.pos++
+		return l.literalString(), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		d := map[string]interface{}{}
+		for {
+			l.skipSpace()
+			if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+				l.pos += 2
+				return d, nil
+			}
+			key, err := l.next()
+			if err != nil {
+				return d, nil
+			}
+			value, err := l.next()
+			if err != nil {
+				return d, nil
+			}
+			if name, ok := key.(contentName); ok {
+				d[string(name)] = value
+			}
+		}
+	case c == '<':
+		l.pos++
+		return l.hexString(), nil
+	case c == '[':
+		l.pos++
+		items := []interface{}{}
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return items, nil
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return items, nil
+			}
+			item, err := l.next()
+			if err != nil {
+				return items, nil
+			}
+			items = append(items, item)
+		}
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return contentOperator(string(c)), nil
+	}
+
+	token := l.regularToken()
+	if token == "" {
+		l.pos++
+		return l.next()
+	}
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if first := token[0]; first == '+' || first == '-' || first == '.' || first >= '0' && first <= '9' {
+		if v, err := strconv.ParseFloat(token, 64); err == nil {
+			return v, nil
+		}
+		// Tolerate malformed numbers such as "--1" or "1.2.3" written by some producers
+		cleaned := strings.TrimLeft(token, "+-")
+		if i := strings.Index(cleaned, "."); i >= 0 {
+			if j := strings.Index(cleaned[i+1:], "."); j >= 0 {
+				cleaned = cleaned[:i+1+j]
+			}
+		}
+		v, _ := strconv.ParseFloat(cleaned, 64)
+		if strings.HasPrefix(token, "-") {
+			v = -v
+		}
+		return v, nil
+	}
+	return contentOperator(token), nil
+}
+
+func decodeNameEscapes(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if v, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+func (l *contentLexer) literalString() []byte {
+	out := []byte{}
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for n := 0; n < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; n++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (l *contentLexer) hexString() []byte {
+	out := []byte{}
+	high, half := byte(0), false
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		var v byte
+		switch {
+		case c == '>':
+			if half {
+				out = append(out, high<<4)
+			}
+			return out
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			continue
+		}
+		if half {
+			out = append(out, high<<4|v)
+		} else {
+			high = v
+		}
+		half = !half
+	}
+	return out
+}
+
+// inlineImageData returns the bytes between ID and the EI that ends the image
+func (l *contentLexer) inlineImageData() []byte {
+	if l.pos < len(l.data) && isPDFWhitespace(l.data[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	for i := start; i+1 < len(l.data); i++ {
+		if l.data[i] != 'E' || l.data[i+1] != 'I' || i > start && !isPDFWhitespace(l.data[i-1]) {
+			continue
+		}
+		if i+2 < len(l.data) && !isPDFWhitespace(l.data[i+2]) {
+			continue
+		}
+		end := i
+		if end > start && isPDFWhitespace(l.data[end-1]) {
+			end--
+		}
+		l.pos = i + 2
+		return l.data[start:end]
+	}
+	l.pos = len(l.data)
+	return l.data[start:]
+}
+
+// renderColorSpace converts colour components to RGB
+type renderColorSpace struct {
+	family     string
+	components int
+	base       *renderColorSpace
+	lookup     []byte
+	hival      int
+	tint       *renderFunction
+	whitePoint [3]float64
+}
+
+var (
+	deviceGraySpace = &renderColorSpace{family: "DeviceGray", components: 1}
+	deviceRGBSpace  = &renderColorSpace{family: "DeviceRGB", components: 3}
+	deviceCMYKSpace = &renderColorSpace{family: "DeviceCMYK", components: 4}
+	patternSpace    = &renderColorSpace{family: "Pattern", components: 0}
+)
+
+func (cs *renderColorSpace) initialColor() []float64 {
+	switch cs.family {
+	case "DeviceCMYK":
+		return []float64{0, 0, 0, 1}
+	case "Separation", "DeviceN":
+		comps := make([]float64, cs.components)
+		for i := range comps {
+			comps[i] = 1
+		}
+		return comps
+	}
+	return make([]float64, cs.components)
+}
+
+// rgb returns the colour as red, green and blue in the 0-1 range
+func (cs *renderColorSpace) rgb(comps []float64) [3]float64 {
+	at := func(i int) float64 {
+		if i < len(comps) {
+			return math.Max(0, math.Min(1, comps[i]))
+		}
+		return 0
+	}
+	switch cs.family {
+	case "DeviceGray":
+		return [3]float64{at(0), at(0), at(0)}
+	case "DeviceRGB":
+		return [3]float64{at(0), at(1), at(2)}
+	case "DeviceCMYK":
+		k := at(3)
+		return [3]float64{(1 - at(0)) * (1 - k), (1 - at(1)) * (1 - k), (1 - at(2)) * (1 - k)}
+	case "Lab":
+		return labToRGB(comps, cs.whitePoint)
+	case "Indexed":
+		index := 0
+		if len(comps) > 0 {
+			index = int(math.Max(0, math.Min(float64(cs.hival), math.Round(comps[0]))))
+		}
+		n := cs.base.components
+		values := make([]float64, n)
+		for i := range values {
+			if j := index*n + i; j < len(cs.lookup) {
+				values[i] = float64(cs.lookup[j]) / 255
+			}
+		}
+		return cs.base.rgb(values)
+	case "Separation", "DeviceN":
+		if cs.tint != nil && cs.base != nil {
+			if out := cs.tint.eval(comps); len(out) >= cs.base.components {
+				return cs.base.rgb(out)
+			}
+		}
+		// Without a usable tint transform, treat the tint as a darkness level
+		darkness := 0.0
+		for i := range comps {
+			darkness = math.Max(darkness, at(i))
+		}
+		return [3]float64{1 - darkness, 1 - darkness, 1 - darkness}
+	}
+	return [3]float64{0, 0, 0}
+}
+
+func (cs *renderColorSpace) toNRGBA(comps []float64, alpha float64) color.NRGBA {
+	c := cs.rgb(comps)
+	return color.NRGBA{
+		R: uint8(math.Round(c[0] * 255)),
+		G: uint8(math.Round(c[1] * 255)),
+		B: uint8(math.Round(c[2] * 255)),
+		A: alphaByte(alpha),
+	}
+}
+
+// labToRGB converts CIE L*a*b* to sRGB relative to the colour space white point
+func labToRGB(comps []float64, white [3]float64) [3]float64 {
+	if white[1] == 0 {
+		white = [3]float64{0.9505, 1, 1.089}
+	}
+	var l, a, b float64
+	if len(comps) >= 3 {
+		l, a, b = comps[0], comps[1], comps[2]
+	}
+	fy := (l + 16) / 116
+	fx, fz := fy+a/500, fy-b/200
+	inverse := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+	x, y, z := white[0]*inverse(fx), white[1]*inverse(fy), white[2]*inverse(fz)
+	linear := [3]float64{
+		3.2406*x - 1.5372*y - 0.4986*z,
+		-0.9689*x + 1.8758*y + 0.0415*z,
+		0.0557*x - 0.2040*y + 1.0570*z,
+	}
+	var out [3]float64
+	for i, v := range linear {
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		out[i] = math.Max(0, math.Min(1, v))
+	}
+	return out
+}
+
+// colorSpace - Resolve a colour space operand, either a family name or a resource name
+func (r *pageRenderer) colorSpace(operand interface{}, resources types.Dict) *renderColorSpace {
+	name, _ := operand.(contentName)
+	switch string(name) {
+	case "DeviceGray", "G", "CalGray":
+		return deviceGraySpace
+	case "DeviceRGB", "RGB", "CalRGB":
+		return deviceRGBSpace
+	case "DeviceCMYK", "CMYK":
+		return deviceCMYKSpace
+	case "Pattern":
+		return patternSpace
+	}
+	if o := r.resource(resources, "ColorSpace", string(name)); o != nil {
+		return r.parseColorSpace(o, 0)
+	}
+	return deviceGraySpace
+}
+
+func (r *pageRenderer) parseColorSpace(o types.Object, depth int) *renderColorSpace {
+	o, err := r.ctx.Dereference(o)
+	if err != nil || o == nil || depth > 4 {
+		return deviceGraySpace
+	}
+	if name, ok := o.(types.Name); ok {
+		return r.colorSpace(contentName(name), nil)
+	}
+	a, ok := o.(types.Array)
+	if !ok || len(a) == 0 {
+		return deviceGraySpace
+	}
+	family, _ := r.ctx.Dereference(a[0])
+	familyName, _ := family.(types.Name)
+	switch familyName {
+	case "CalGray":
+		return deviceGraySpace
+	case "CalRGB":
+		return deviceRGBSpace
+	case "Pattern":
+		return patternSpace
+	case "Lab":
+		cs := &renderColorSpace{family: "Lab", components: 3}
+		if len(a) > 1 {
+			if d, err := r.ctx.DereferenceDict(a[1]); err == nil && d != nil {
+				if wp, err := r.ctx.DereferenceArray(d["WhitePoint"]); err == nil && len(wp) == 3 {
+					for i := range cs.whitePoint {
+						cs.whitePoint[i], _ = r.number(wp[i])
+					}
+				}
+			}
+		}
+		return cs
+	case "ICCBased":
+		if len(a) < 2 {
+			return deviceRGBSpace
+		}
+		sd, _, err := r.ctx.DereferenceStreamDict(a[1])
+		if err != nil || sd == nil {
+			return deviceRGBSpace
+		}
+		if alternate, ok := sd.Dict["Alternate"]; ok {
+			return r.parseColorSpace(alternate, depth+1)
+		}
+		switch n, _ := r.number(sd.Dict["N"]); n {
+		case 1:
+			return deviceGraySpace
+		case 4:
+			return deviceCMYKSpace
+		}
+		return deviceRGBSpace
+	case "Indexed", "I":
+		if len(a) < 4 {
+			return deviceGraySpace
+		}
+		cs := &renderColorSpace{family: "Indexed", components: 1, base: r.parseColorSpace(a[1], depth+1)}
+		hival, _ := r.number(a[2])
+		cs.hival = int(hival)
+		lookup, err := r.ctx.Dereference(a[3])
+		if err != nil {
+			return deviceGraySpace
+		}
+		switch l := lookup.(type) {
+		case types.StringLiteral:
+			cs.lookup, _ = types.Unescape(l.Value())
+		case types.HexLiteral:
+			cs.lookup, _ = l.Bytes()
+		case types.StreamDict:
+			if err := l.Decode(); err == nil {
+				cs.lookup = l.Content
+			}
+		}
+		return cs
+	case "Separation", "DeviceN":
+		if len(a) < 4 {
+			return deviceGraySpace
+		}
+		cs := &renderColorSpace{family: string(familyName), components: 1}
+		if familyName == "DeviceN" {
+			if names, err := r.ctx.DereferenceArray(a[1]); err == nil {
+				cs.components = len(names)
+			}
+		}
+		cs.base = r.parseColorSpace(a[2], depth+1)
+		cs.tint = r.parseFunction(a[3], 0)
+		return cs
+	}
+	return deviceGraySpace
+}
+
+// renderFunction evaluates PDF function types 0, 2, 3 and 4
+type renderFunction struct {
+	kind    int
+	domain  []float64
+	rng     []float64
+	c0, c1  []float64
+	n       float64
+	subs    []*renderFunction
+	bounds  []float64
+	encode  []float64
+	decode  []float64
+	size    []int
+	samples []float64
+	outputs int
+	program []psOperation
+}
+
+func (r *pageRenderer) numbers(o types.Object) []float64 {
+	a, err := r.ctx.DereferenceArray(o)
+	if err != nil {
+		return nil
+	}
+	values := make([]float64, 0, len(a))
+	for _, item := range a {
+		v, _ := r.number(item)
+		values = append(values, v)
+	}
+	return values
+}
+
+// parseFunction - Build an evaluator for a function dictionary, stream or array of functions
+func (r *pageRenderer) parseFunction(o types.Object, depth int) *renderFunction {
+	o, err := r.ctx.Dereference(o)
+	if err != nil || o == nil || depth > 4 {
+		return nil
+	}
+	if a, ok := o.(types.Array); ok {
+		// An array holds one single-output function per component
+		f := &renderFunction{kind: -1}
+		for _, item := range a {
+			sub := r.parseFunction(item, depth+1)
+			if sub == nil {
+				return nil
+			}
+			f.subs = append(f.subs, sub)
+		}
+		return f
+	}
+
+	var d types.Dict
+	var sd *types.StreamDict
+	switch v := o.(type) {
+	case types.Dict:
+		d = v
+	case types.StreamDict:
+		sd, d = &v, v.Dict
+	default:
+		return nil
+	}
+	kind, _ := r.number(d["FunctionType"])
+	f := &renderFunction{kind: int(kind), domain: r.numbers(d["Domain"]), rng: r.numbers(d["Range"])}
+	switch f.kind {
+	case 0:
+		if sd == nil || sd.Decode() != nil {
+			return nil
+		}
+		for _, v := range r.numbers(d["Size"]) {
+			f.size = append(f.size, int(v))
+		}
+		bps, _ := r.number(d["BitsPerSample"])
+		f.encode, f.decode = r.numbers(d["Encode"]), r.numbers(d["Decode"])
+		if len(f.decode) == 0 {
+			f.decode = f.rng
+		}
+		f.outputs = len(f.rng) / 2
+		total := f.outputs
+		for _, s := range f.size {
+			total *= s
+		}
+		if total <= 0 || len(f.size) == 0 || bps <= 0 || bps > 32 {
+			return nil
+		}
+		f.samples = unpackSamples(sd.Content, int(bps), total)
+	case 2:
+		f.c0, f.c1 = r.numbers(d["C0"]), r.numbers(d["C1"])
+		if len(f.c0) == 0 {
+			f.c0 = []float64{0}
+		}
+		if len(f.c1) == 0 {
+			f.c1 = []float64{1}
+		}
+		f.n, _ = r.number(d["N"])
+	case 3:
+		functions, err := r.ctx.DereferenceArray(d["Functions"])
+		if err != nil {
+			return nil
+		}
+		for _, item := range functions {
+			sub := r.parseFunction(item, depth+1)
+			if sub == nil {
+				return nil
+			}
+			f.subs = append(f.subs, sub)
+		}
+		f.bounds, f.encode = r.numbers(d["Bounds"]), r.numbers(d["Encode"])
+	case 4:
+		if sd == nil || sd.Decode() != nil {
+			return nil
+		}
+		lexer := &contentLexer{data: sd.Content}
+		if token, err := lexer.next(); err != nil || token != contentOperator("{") {
+			return nil
+		}
+		f.program = parsePostScript(lexer)
+	default:
+		return nil
+	}
+	return f
+}
+
+func unpackSamples(data []byte, bps, count int) []float64 {
+	samples := make([]float64, count)
+	max := math.Pow(2, float64(bps)) - 1
+	bit := 0
+	for i := range samples {
+		v := uint64(0)
+		for b := 0; b < bps; b++ {
+			byteIndex := bit / 8
+			if byteIndex >= len(data) {
+				return samples
+			}
+			v = v<<1 | uint64(data[byteIndex]>>(7-uint(bit%8))&1)
+			bit++
+		}
+		samples[i] = float64(v) / max
+	}
+	return samples
+}
+
+func interpolate(x, xmin, xmax, ymin, ymax float64) float64 {
+	if xmax == xmin {
+		return ymin
+	}
+	return ymin + (x-xmin)*(ymax-ymin)/(xmax-xmin)
+}
+
+func (f *renderFunction) eval(in []float64) []float64 {
+	if f.kind == -1 {
+		out := []float64{}
+		for _, sub := range f.subs {
+			out = append(out, sub.eval(in)...)
+		}
+		return out
+	}
+	clamped := make([]float64, len(in))
+	for i, v := range in {
+		if 2*i+1 < len(f.domain) {
+			v = math.Max(f.domain[2*i], math.Min(f.domain[2*i+1], v))
+		}
+		clamped[i] = v
+	}
+	var out []float64
+	switch f.kind {
+	case 0:
+		out = f.evalSampled(clamped)
+	case 2:
+		x := 0.0
+		if len(clamped) > 0 {
+			x = clamped[0]
+		}
+		out = make([]float64, len(f.c0))
+		for i := range out {
+			c1 := 0.0
+			if i < len(f.c1) {
+				c1 = f.c1[i]
+			}
+			out[i] = f.c0[i] + math.Pow(x, f.n)*(c1-f.c0[i])
+		}
+	case 3:
+		if len(f.subs) == 0 || len(clamped) == 0 {
+			return nil
+		}
+		x := clamped[0]
+		k := 0
+		for k < len(f.bounds) && x >= f.bounds[k] {
+			k++
+		}
+		if k >= len(f.subs) {
+			k = len(f.subs) - 1
+		}
+		low, high := 0.0, 1.0
+		if len(f.domain) >= 2 {
+			low, high = f.domain[0], f.domain[1]
+		}
+		if k > 0 {
+			low = f.bounds[k-1]
+		}
+		if k < len(f.bounds) {
+			high = f.bounds[k]
+		}
+		if 2*k+1 < len(f.encode) {
+			x = interpolate(x, low, high, f.encode[2*k], f.encode[2*k+1])
+		}
+		out = f.subs[k].eval([]float64{x})
+	case 4:
+		out = runPostScript(f.program, clamped)
+	}
+	for i := range out {
+		if 2*i+1 < len(f.rng) {
+			out[i] = math.Max(f.rng[2*i], math.Min(f.rng[2*i+1], out[i]))
+		}
+	}
+	return out
+}
+
+// evalSampled interpolates linearly for one input and picks the nearest sample otherwise
+func (f *renderFunction) evalSampled(in []float64) []float64 {
+	out := make([]float64, f.outputs)
+	encoded := make([]float64, len(f.size))
+	for i := range f.size {
+		x := 0.0
+		if i < len(in) {
+			x = in[i]
+		}
+		e0, e1 := 0.0, float64(f.size[i]-1)
+		if 2*i+1 < len(f.encode) {
+			e0, e1 = f.encode[2*i], f.encode[2*i+1]
+		}
+		if 2*i+1 < len(f.domain) {
+			x = interpolate(x, f.domain[2*i], f.domain[2*i+1], e0, e1)
+		}
+		encoded[i] = math.Max(0, math.Min(float64(f.size[i]-1), x))
+	}
+	sample := func(index []int, j int) float64 {
+		offset, stride := 0, 1
+		for i, v := range index {
+			offset += v * stride
+			stride *= f.size[i]
+		}
+		if k := offset*f.outputs + j; k < len(f.samples) {
+			return f.samples[k]
+		}
+		return 0
+	}
+	index := make([]int, len(f.size))
+	for i, v := range encoded {
+		index[i] = int(math.Round(v))
+	}
+	for j := range out {
+		v := sample(index, j)
+		if len(f.size) == 1 {
+			low := int(math.Floor(encoded[0]))
+			high := low + 1
+			if high < f.size[0] {
+				v = interpolate(encoded[0], float64(low), float64(high), sample([]int{low}, j), sample([]int{high}, j))
+			} else {
+				v = sample([]int{low}, j)
+			}
+		}
+		if 2*j+1 < len(f.decode) {
+			v = interpolate(v, 0, 1, f.decode[2*j], f.decode[2*j+1])
+		}
+		out[j] = v
+	}
+	return out
+}
+
+// psOperation is one step of a type 4 calculator function
+type psOperation struct {
+	operator  string
+	value     float64
+	then      []psOperation
+	otherwise []psOperation
+}
+
+func parsePostScript(lexer *contentLexer) []psOperation {
+	program := []psOperation{}
+	blocks := [][]psOperation{}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return program
+		}
+		switch v := token.(type) {
+		case float64:
+			program = append(program, psOperation{value: v})
+		case bool:
+			if v {
+				program = append(program, psOperation{value: 1})
+			} else {
+				program = append(program, psOperation{value: 0})
+			}
+		case contentOperator:
+			switch v {
+			case "}":
+				return program
+			case "{":
+				blocks = append(blocks, parsePostScript(lexer))
+			case "if":
+				if len(blocks) >= 1 {
+					program = append(program, psOperation{operator: "if", then: blocks[len(blocks)-1]})
+				}
+				blocks = nil
+			case "ifelse":
+				if len(blocks) >= 2 {
+					program = append(program, psOperation{operator: "ifelse", then: blocks[len(blocks)-2], otherwise: blocks[len(blocks)-1]})
+				}
+				blocks = nil
+			default:
+				program = append(program, psOperation{operator: string(v)})
+			}
+		}
+	}
+}
+
+func runPostScript(program []psOperation, in []float64) []float64 {
+	stack := append([]float64{}, in...)
+	var exec func(ops []psOperation)
+	pop := func() float64 {
+		if len(stack) == 0 {
+			return 0
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	push := func(v float64) { stack = append(stack, v) }
+	boolean := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	exec = func(ops []psOperation) {
+		for _, op := range ops {
+			if len(stack) > 1000 {
+				return
+			}
+			switch op.operator {
+			case "":
+				push(op.value)
+			case "add":
+				b, a := pop(), pop()
+				push(a + b)
+			case "sub":
+				b, a := pop(), pop()
+				push(a - b)
+			case "mul":
+				b, a := pop(), pop()
+				push(a * b)
+			case "div":
+				b, a := pop(), pop()
+				if b != 0 {
+					push(a / b)
+				} else {
+					push(0)
+				}
+			case "idiv":
+				b, a := int(pop()), int(pop())
+				if b != 0 {
+					push(float64(a / b))
+				} else {
+					push(0)
+				}
+			case "mod":
+				b, a := int(pop()), int(pop())
+				if b != 0 {
+					push(float64(a % b))
+				} else {
+					push(0)
+				}
+			case "neg":
+				push(-pop())
+			case "abs":
+				push(math.Abs(pop()))
+			case "ceiling":
+				push(math.Ceil(pop()))
+			case "floor":
+				push(math.Floor(pop()))
+			case "round":
+				push(math.Floor(pop() + 0.5))
+			case "truncate", "cvi":
+				push(math.Trunc(pop()))
+			case "cvr":
+			case "sqrt":
+				push(math.Sqrt(math.Max(0, pop())))
+			case "sin":
+				push(math.Sin(pop() * math.Pi / 180))
+			case "cos":
+				push(math.Cos(pop() * math.Pi / 180))
+			case "atan":
+				den, num := pop(), pop()
+				angle := math.Atan2(num, den) * 180 / math.Pi
+				if angle < 0 {
+					angle += 360
+				}
+				push(angle)
+			case "exp":
+				e, base := pop(), pop()
+				push(math.Pow(base, e))
+			case "ln":
+				push(math.Log(pop()))
+			case "log":
+				push(math.Log10(pop()))
+			case "eq":
+				b, a := pop(), pop()
+				push(boolean(a == b))
+			case "ne":
+				b, a := pop(), pop()
+				push(boolean(a != b))
+			case "gt":
+				b, a := pop(), pop()
+				push(boolean(a > b))
+			case "ge":
+				b, a := pop(), pop()
+				push(boolean(a >= b))
+			case "lt":
+				b, a := pop(), pop()
+				push(boolean(a < b))
+			case "le":
+				b, a := pop(), pop()
+				push(boolean(a <= b))
+			case "and":
+				b, a := int(pop()), int(pop())
+				push(float64(a & b))
+			case "or":
+				b, a := int(pop()), int(pop())
+				push(float64(a | b))
+			case "xor":
+				b, a := int(pop()), int(pop())
+				push(float64(a ^ b))
+			case "not":
+				v := pop()
+				if v == 0 || v == 1 {
+					push(1 - v)
+				} else {
+					push(float64(^int(v)))
+				}
+			case "bitshift":
+				shift, v := int(pop()), int(pop())
+				if shift >= 0 {
+					push(float64(v << uint(shift)))
+				} else {
+					push(float64(v >> uint(-shift)))
+				}
+			case "true":
+				push(1)
+			case "false":
+				push(0)
+			case "pop":
+				pop()
+			case "exch":
+				b, a := pop(), pop()
+				push(b)
+				push(a)
+			case "dup":
+				v := pop()
+				push(v)
+				push(v)
+			case "copy":
+				n := int(pop())
+				if n > 0 && n <= len(stack) {
+					stack = append(stack, stack[len(stack)-n:]...)
+				}
+			case "index":
+				n := int(pop())
+				if n >= 0 && n < len(stack) {
+					push(stack[len(stack)-1-n])
+				}
+			case "roll":
+				j, n := int(pop()), int(pop())
+				if n > 0 && n <= len(stack) {
+					top := stack[len(stack)-n:]
+					j = ((j % n) + n) % n
+					rolled := append(append([]float64{}, top[n-j:]...), top[:n-j]...)
+					copy(top, rolled)
+				}
+			case "if":
+				if pop() != 0 {
+					exec(op.then)
+				}
+			case "ifelse":
+				if pop() != 0 {
+					exec(op.then)
+				} else {
+					exec(op.otherwise)
+				}
+			}
+		}
+	}
+	exec(program)
+	return stack
+}
+
+// renderShading paints axial and radial shadings through a precomputed colour ramp
+type renderShading struct {
+	kind    int
+	coords  []float64
+	domain  [2]float64
+	extend  [2]bool
+	inverse pdfMatrix
+	ramp    []color.NRGBA
+}
+
+const shadingRampSize = 256
+
+// parseShading - Build a shading whose space maps to device space through matrix
+func (r *pageRenderer) parseShading(o types.Object, matrix pdfMatrix) *renderShading {
+	o, err := r.ctx.Dereference(o)
+	if err != nil || o == nil {
+		return nil
+	}
+	var d types.Dict
+	switch v := o.(type) {
+	case types.Dict:
+		d = v
+	case types.StreamDict:
+		d = v.Dict
+	default:
+		return nil
+	}
+	kind, _ := r.number(d["ShadingType"])
+	s := &renderShading{kind: int(kind), coords: r.numbers(d["Coords"]), domain: [2]float64{0, 1}}
+	if s.kind != 2 && s.kind != 3 || s.kind == 2 && len(s.coords) != 4 || s.kind == 3 && len(s.coords) != 6 {
+		return nil
+	}
+	if domain := r.numbers(d["Domain"]); len(domain) == 2 {
+		s.domain = [2]float64{domain[0], domain[1]}
+	}
+	if a, err := r.ctx.DereferenceArray(d["Extend"]); err == nil && len(a) == 2 {
+		for i := range s.extend {
+			if b, ok := a[i].(types.Boolean); ok {
+				s.extend[i] = b.Value()
+			}
+		}
+	}
+	inverse, ok := matrix.invert()
+	if !ok {
+		return nil
+	}
+	s.inverse = inverse
+
+	space := r.parseColorSpace(d["ColorSpace"], 0)
+	function := r.parseFunction(d["Function"], 0)
+	if function == nil {
+		return nil
+	}
+	s.ramp = make([]color.NRGBA, shadingRampSize)
+	for i := range s.ramp {
+		t := interpolate(float64(i), 0, shadingRampSize-1, s.domain[0], s.domain[1])
+		s.ramp[i] = space.toNRGBA(function.eval([]float64{t}), 1)
+	}
+	return s
+}
+
+// colorAt returns the colour at a device pixel, or false outside the shading
+func (s *renderShading) colorAt(x, y float64) (color.NRGBA, bool) {
+	p := s.inverse.apply(x, y)
+	var t float64
+	if s.kind == 2 {
+		dx, dy := s.coords[2]-s.coords[0], s.coords[3]-s.coords[1]
+		denominator := dx*dx + dy*dy
+		if denominator == 0 {
+			return color.NRGBA{}, false
+		}
+		t = ((p.x-s.coords[0])*dx + (p.y-s.coords[1])*dy) / denominator
+	} else {
+		var ok bool
+		if t, ok = s.radialParameter(p); !ok {
+			return color.NRGBA{}, false
+		}
+	}
+	if t < 0 {
+		if !s.extend[0] {
+			return color.NRGBA{}, false
+		}
+		t = 0
+	}
+	if t > 1 {
+		if !s.extend[1] {
+			return color.NRGBA{}, false
+		}
+		t = 1
+	}
+	return s.ramp[int(math.Round(t*(shadingRampSize-1)))], true
+}
+
+// radialParameter solves for the largest circle of the blend passing through p
+func (s *renderShading) radialParameter(p renderPoint) (float64, bool) {
+	x0, y0, r0, x1, y1, r1 := s.coords[0], s.coords[1], s.coords[2], s.coords[3], s.coords[4], s.coords[5]
+	cdx, cdy, dr := x1-x0, y1-y0, r1-r0
+	pdx, pdy := p.x-x0, p.y-y0
+	a := cdx*cdx + cdy*cdy - dr*dr
+	b := pdx*cdx + pdy*cdy + r0*dr
+	c := pdx*pdx + pdy*pdy - r0*r0
+	valid := func(t float64) bool {
+		if r0+t*dr < 0 {
+			return false
+		}
+		return t >= 0 && t <= 1 || t < 0 && s.extend[0] || t > 1 && s.extend[1]
+	}
+	if math.Abs(a) < 1e-9 {
+		if b == 0 {
+			return 0, false
+		}
+		t := c / (2 * b)
+		return t, valid(t)
+	}
+	discriminant := b*b - a*c
+	if discriminant < 0 {
+		return 0, false
+	}
+	root := math.Sqrt(discriminant)
+	t1, t2 := (b+root)/a, (b-root)/a
+	if t1 < t2 {
+		t1, t2 = t2, t1
+	}
+	if valid(t1) {
+		return t1, true
+	}
+	return t2, valid(t2)
+}
+
+// patternShading - Resolve a shading pattern used as a fill or stroke colour
+func (r *pageRenderer) patternShading(name string, resources types.Dict) *renderShading {
+	o := r.resource(resources, "Pattern", name)
+	var d types.Dict
+	switch v := o.(type) {
+	case types.Dict:
+		d = v
+	case types.StreamDict:
+		d = v.Dict
+	default:
+		return nil
+	}
+	if kind, _ := r.number(d["PatternType"]); kind != 2 {
+		return nil
+	}
+	matrix := identityMatrix
+	if a, err := r.ctx.DereferenceArray(d["Matrix"]); err == nil && len(a) == 6 {
+		matrix, _ = r.matrixFromArray(a)
+	}
+	return r.parseShading(d["Shading"], matrix.multiply(r.baseCTM))
+}
+
+// paintShading - Fill the clip region with a shading (sh operator)
+func (r *pageRenderer) paintShading(name string, resources types.Dict) {
+	o := r.resource(resources, "Shading", name)
+	if o == nil {
+		return
+	}
+	shading := r.parseShading(o, r.state.ctm)
+	if shading == nil {
+		return
+	}
+	b := r.bounds
+	page := [][]renderPoint{{{float64(b.Min.X), float64(b.Min.Y)}, {float64(b.Max.X), float64(b.Min.Y)}, {float64(b.Max.X), float64(b.Max.Y)}, {float64(b.Min.X), float64(b.Max.Y)}}}
+	r.fill(page, false, renderPaint{shading: shading, color: color.NRGBA{A: alphaByte(r.state.fillAlpha)}})
+}
+
+// renderFont holds what the renderer needs to draw the glyphs of a font resource
+type renderFont struct {
+	composite    bool
+	twoByte      bool
+	baseFont     string
+	widths       map[int]float64
+	defaultWidth float64
+	hasWidths    bool
+	widthScale   float64
+	unicode      map[int][]rune
+	encoding     map[int]rune
+	names        map[int]string
+	face         *sfnt.Font
+	symbolic     bool
+	cidToGID     []int
+	namedGlyphs  map[string]sfnt.GlyphIndex
+	fallback     *sfnt.Font
+	glyphs       map[int]*renderGlyph
+	type3        *type3Font
+	ascent       float64
+	descent      float64
+}
+
+type type3Font struct {
+	procs     types.Dict
+	matrix    pdfMatrix
+	resources types.Dict
+}
+
+// glyphSegment is an outline command in text space units, y pointing up
+type glyphSegment struct {
+	op     sfnt.SegmentOp
+	points [3]renderPoint
+}
+
+type renderGlyph struct {
+	segments []glyphSegment
+	advance  float64
+}
+
+// loadFont - Resolve a font resource by name
+func (r *pageRenderer) loadFont(name string, resources types.Dict) *renderFont {
+	entries, err := r.ctx.DereferenceDict(resources["Font"])
+	if err != nil || entries == nil {
+		return nil
+	}
+	d, err := r.ctx.DereferenceDict(entries[name])
+	if err != nil || d == nil {
+		return nil
+	}
+	return r.fontFromDict(d, entries[name])
+}
+
+func (r *pageRenderer) fontFromDict(d types.Dict, ref types.Object) *renderFont {
+	key := fmt.Sprintf("%p", d)
+	if indRef, ok := ref.(types.IndirectRef); ok {
+		key = indRef.String()
+	}
+	if f, ok := r.fonts[key]; ok {
+		return f
+	}
+	f := r.buildFont(d)
+	r.fonts[key] = f
+	return f
+}
+
+func (r *pageRenderer) buildFont(d types.Dict) *renderFont {
+	f := &renderFont{
+		widths:     map[int]float64{},
+		widthScale: 0.001,
+		encoding:   map[int]rune{},
+		names:      map[int]string{},
+		glyphs:     map[int]*renderGlyph{},
+		ascent:     0.8,
+		descent:    -0.2,
+	}
+	subtype := ""
+	if s := d.NameEntry("Subtype"); s != nil {
+		subtype = *s
+	}
+	if s := d.NameEntry("BaseFont"); s != nil {
+		f.baseFont = *s
+		// Drop the subset tag such as ABCDEF+
+		if i := strings.IndexByte(f.baseFont, '+'); i == 6 {
+			f.baseFont = f.baseFont[i+1:]
+		}
+	}
+
+	descriptorDict := d
+	if subtype == "Type0" {
+		f.composite, f.twoByte, f.defaultWidth = true, true, 1
+		if sd, _, err := r.ctx.DereferenceStreamDict(d["Encoding"]); err == nil && sd != nil && sd.Decode() == nil {
+			if width := codespaceWidth(sd.Content); width == 1 {
+				f.twoByte = false
+			}
+		}
+		descendants, err := r.ctx.DereferenceArray(d["DescendantFonts"])
+		if err == nil && len(descendants) > 0 {
+			if cid, err := r.ctx.DereferenceDict(descendants[0]); err == nil && cid != nil {
+				descriptorDict = cid
+				if dw, ok := r.number(cid["DW"]); ok {
+					f.defaultWidth = dw / 1000
+				}
+				r.parseCIDWidths(f, cid["W"])
+				if sd, _, err := r.ctx.DereferenceStreamDict(cid["CIDToGIDMap"]); err == nil && sd != nil && sd.Decode() == nil {
+					for i := 0; i+1 < len(sd.Content); i += 2 {
+						f.cidToGID = append(f.cidToGID, int(sd.Content[i])<<8|int(sd.Content[i+1]))
+					}
+				}
+			}
+		}
+	} else {
+		first, _ := r.number(d["FirstChar"])
+		if widths, err := r.ctx.DereferenceArray(d["Widths"]); err == nil && len(widths) > 0 {
+			f.hasWidths = true
+			for i, o := range widths {
+				if w, ok := r.number(o); ok {
+					f.widths[int(first)+i] = w
+				}
+			}
+		}
+	}
+
+	var flags int
+	descriptor, _ := r.ctx.DereferenceDict(descriptorDict["FontDescriptor"])
+	if descriptor != nil {
+		if v, ok := r.number(descriptor["Flags"]); ok {
+			flags = int(v)
+		}
+		if v, ok := r.number(descriptor["MissingWidth"]); ok && !f.composite {
+			f.defaultWidth = v / 1000
+		}
+		f.symbolic = flags&4 != 0
+		// Text layer boxes span the font's ascent and descent, when the descriptor gives sensible ones
+		ascent, okAscent := r.number(descriptor["Ascent"])
+		descent, okDescent := r.number(descriptor["Descent"])
+		if okAscent && okDescent && ascent > 0 && ascent <= 2000 && descent <= 0 && descent > -1000 {
+			f.ascent, f.descent = ascent/1000, descent/1000
+		}
+		f.face = r.embeddedFace(descriptor)
+	}
+	f.fallback = fallbackFace(f.baseFont, flags)
+
+	if subtype == "Type3" {
+		f.type3 = &type3Font{matrix: pdfMatrix{0.001, 0, 0, 0.001, 0, 0}}
+		if a, err := r.ctx.DereferenceArray(d["FontMatrix"]); err == nil && len(a) == 6 {
+			f.type3.matrix, _ = r.matrixFromArray(a)
+		}
+		f.widthScale = f.type3.matrix[0]
+		f.type3.procs, _ = r.ctx.DereferenceDict(d["CharProcs"])
+		f.type3.resources, _ = r.ctx.DereferenceDict(d["Resources"])
+	}
+	if !f.composite {
+		r.parseEncoding(f, d, subtype)
+	}
+	if sd, _, err := r.ctx.DereferenceStreamDict(d["ToUnicode"]); err == nil && sd != nil && sd.Decode() == nil {
+		f.unicode = parseToUnicode(sd.Content)
+	}
+	return f
+}
+
+// parseCIDWidths reads the W array of a CID font: c [w1 w2 ...] or cfirst clast w
+func (r *pageRenderer) parseCIDWidths(f *renderFont, o types.Object) {
+	a, err := r.ctx.DereferenceArray(o)
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(a); {
+		first, ok := r.number(a[i])
+		if !ok || i+1 >= len(a) {
+			return
+		}
+		if list, err := r.ctx.DereferenceArray(a[i+1]); err == nil && list != nil {
+			for j, item := range list {
+				if w, ok := r.number(item); ok {
+					f.widths[int(first)+j] = w
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(a) {
+			return
+		}
+		last, _ := r.number(a[i+1])
+		w, _ := r.number(a[i+2])
+		for c := int(first); c <= int(last) && c-int(first) < 65536; c++ {
+			f.widths[c] = w
+		}
+		i += 3
+	}
+	f.hasWidths = true
+}
+
+// parseEncoding builds the code to Unicode table of a simple font from its base encoding and differences
+func (r *pageRenderer) parseEncoding(f *renderFont, d types.Dict, subtype string) {
+	base := "WinAnsiEncoding"
+	if subtype == "Type1" && f.face == nil {
+		base = "StandardEncoding"
+	}
+	if f.symbolic && f.face != nil || subtype == "Type3" {
+		base = ""
+	}
+	var differences types.Array
+	encoding, _ := r.ctx.Dereference(d["Encoding"])
+	switch e := encoding.(type) {
+	case types.Name:
+		base = string(e)
+	case types.Dict:
+		if b := e.NameEntry("BaseEncoding"); b != nil {
+			base = *b
+		}
+		differences, _ = r.ctx.DereferenceArray(e["Differences"])
+	}
+	for code := 0; code < 256; code++ {
+		if c := baseEncodingRune(base, code); c != 0 {
+			f.encoding[code] = c
+		}
+	}
+	code := 0
+	for _, o := range differences {
+		o, _ = r.ctx.Dereference(o)
+		switch v := o.(type) {
+		case types.Integer:
+			code = v.Value()
+		case types.Float:
+			code = int(v.Value())
+		case types.Name:
+			f.names[code] = string(v)
+			if runes := glyphNameRunes(string(v)); len(runes) == 1 {
+				f.encoding[code] = runes[0]
+			} else {
+				delete(f.encoding, code)
+			}
+			code++
+		}
+	}
+}
+
+// standardEncoding lists where Adobe StandardEncoding differs from ASCII and Latin-1
+var standardEncoding = map[int]rune{
+	0x27: '’', 0x60: '‘', 0xA4: '⁄', 0xA6: 'ƒ', 0xA8: '¤', 0xA9: '\'', 0xAA: '“', 0xAC: '‹', 0xAD: '›',
+	0xAE: 'ﬁ', 0xAF: 'ﬂ', 0xB1: '–', 0xB2: '†', 0xB3: '‡', 0xB4: '·', 0xB7: '•', 0xB8: '‚', 0xB9: '„',
+	0xBA: '”', 0xBC: '…', 0xBD: '‰', 0xC1: '`', 0xC2: '´', 0xC3: 'ˆ', 0xC4: '˜', 0xC5: '¯', 0xC6: '˘',
+	0xC7: '˙', 0xC8: '¨', 0xCA: '˚', 0xCB: '¸', 0xCD: '˝', 0xCE: '˛', 0xCF: 'ˇ', 0xD0: '—', 0xE1: 'Æ',
+	0xE3: 'ª', 0xE8: 'Ł', 0xE9: 'Ø', 0xEA: 'Œ', 0xEB: 'º', 0xF1: 'æ', 0xF5: 'ı', 0xF8: 'ł', 0xF9: 'ø',
+	0xFA: 'œ', 0xFB: 'ß',
+}
+
+func baseEncodingRune(base string, code int) rune {
+	switch base {
+	case "WinAnsiEncoding":
+		if code >= 0x20 {
+			return charmap.Windows1252.DecodeByte(byte(code))
+		}
+	case "MacRomanEncoding":
+		if code >= 0x20 {
+			return charmap.Macintosh.DecodeByte(byte(code))
+		}
+	case "StandardEncoding":
+		if c, ok := standardEncoding[code]; ok {
+			return c
+		}
+		if code >= 0x20 && code < 0x7F || code >= 0xA1 && code <= 0xBF {
+			return rune(code)
+		}
+	}
+	return 0
+}
+
+// glyphNames maps the Adobe glyph names found in Differences arrays to Unicode
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "parenleft": "(", "parenright": ")",
+	"asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "colon": ":", "semicolon": ";", "less": "<", "equal": "=",
+	"greater": ">", "question": "?", "at": "@", "bracketleft": "[", "backslash": "\\", "bracketright": "]",
+	"asciicircum": "^", "underscore": "_", "grave": "`", "quoteleft": "‘", "braceleft": "{", "bar": "|",
+	"braceright": "}", "asciitilde": "~", "exclamdown": "¡", "cent": "¢", "sterling": "£", "currency": "¤",
+	"yen": "¥", "brokenbar": "¦", "section": "§", "dieresis": "¨", "copyright": "©", "ordfeminine": "ª",
+	"guillemotleft": "«", "logicalnot": "¬", "registered": "®", "macron": "¯", "degree": "°",
+	"plusminus": "±", "twosuperior": "²", "threesuperior": "³", "acute": "´", "mu": "µ", "paragraph": "¶",
+	"periodcentered": "·", "cedilla": "¸", "onesuperior": "¹", "ordmasculine": "º", "guillemotright": "»",
+	"onequarter": "¼", "onehalf": "½", "threequarters": "¾", "questiondown": "¿", "multiply": "×",
+	"divide": "÷", "AE": "Æ", "ae": "æ", "Eth": "Ð", "eth": "ð", "Oslash": "Ø", "oslash": "ø",
+	"Thorn": "Þ", "thorn": "þ", "germandbls": "ß", "OE": "Œ", "oe": "œ", "Lslash": "Ł", "lslash": "ł",
+	"dotlessi": "ı", "dotlessj": "ȷ", "florin": "ƒ", "circumflex": "ˆ", "caron": "ˇ", "breve": "˘",
+	"dotaccent": "˙", "ring": "˚", "ogonek": "˛", "tilde": "˜", "hungarumlaut": "˝", "endash": "–",
+	"emdash": "—", "quotesinglbase": "‚", "quotedblleft": "“", "quotedblright": "”", "quotedblbase": "„",
+	"dagger": "†", "daggerdbl": "‡", "bullet": "•", "ellipsis": "…", "perthousand": "‰",
+	"guilsinglleft": "‹", "guilsinglright": "›", "fraction": "⁄", "Euro": "€", "trademark": "™",
+	"minus": "−", "fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl", "nbspace": " ",
+	"sfthyphen": "-", "visiblespace": "␣", "arrowright": "→", "arrowleft": "←", "arrowup": "↑",
+	"arrowdown": "↓", "infinity": "∞", "lessequal": "≤", "greaterequal": "≥", "notequal": "≠",
+	"approxequal": "≈", "summation": "∑", "product": "∏", "radical": "√", "integral": "∫",
+	"partialdiff": "∂", "Delta": "∆", "Omega": "Ω", "pi": "π", "lozenge": "◊", "checkmark": "✓",
+}
+
+// glyphNameRunes - Resolve a glyph name to its Unicode text, following the Adobe naming conventions
+func glyphNameRunes(name string) []rune {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if s, ok := glyphNames[name]; ok {
+		return []rune(s)
+	}
+	if len(name) == 1 {
+		return []rune(name)
+	}
+	if strings.Contains(name, "_") {
+		runes := []rune{}
+		for _, part := range strings.Split(name, "_") {
+			runes = append(runes, glyphNameRunes(part)...)
+		}
+		return runes
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		runes := []rune{}
+		for i := 3; i+4 <= len(name); i += 4 {
+			v, err := strconv.ParseUint(name[i:i+4], 16, 32)
+			if err != nil {
+				return nil
+			}
+			runes = append(runes, rune(v))
+		}
+		return runes
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return []rune{rune(v)}
+		}
+	}
+	if c, ok := latinGlyphs[name]; ok {
+		return []rune{c}
+	}
+	return nil
+}
+
+// latinGlyphs maps the glyph names of accented Latin-1 and Latin Extended-A letters
+var latinGlyphs = func() map[string]rune {
+	names := map[string]rune{}
+	latin1 := strings.Fields("Agrave Aacute Acircumflex Atilde Adieresis Aring AE Ccedilla Egrave Eacute Ecircumflex Edieresis Igrave Iacute Icircumflex Idieresis Eth Ntilde Ograve Oacute Ocircumflex Otilde Odieresis multiply Oslash Ugrave Uacute Ucircumflex Udieresis Yacute Thorn germandbls")
+	for i, name := range latin1 {
+		names[name] = rune(0xC0 + i)
+		if name != "multiply" && name != "germandbls" {
+			names[strings.ToLower(name[:1])+name[1:]] = rune(0xE0 + i)
+		}
+	}
+	names["ydieresis"] = 0xFF
+	extendedA := strings.Fields("Amacron amacron Abreve abreve Aogonek aogonek Cacute cacute Ccircumflex ccircumflex Cdotaccent cdotaccent Ccaron ccaron Dcaron dcaron Dcroat dcroat Emacron emacron Ebreve ebreve Edotaccent edotaccent Eogonek eogonek Ecaron ecaron Gcircumflex gcircumflex Gbreve gbreve Gdotaccent gdotaccent Gcommaaccent gcommaaccent Hcircumflex hcircumflex Hbar hbar Itilde itilde Imacron imacron Ibreve ibreve Iogonek iogonek Idotaccent dotlessi IJ ij Jcircumflex jcircumflex Kcommaaccent kcommaaccent kgreenlandic Lacute lacute Lcommaaccent lcommaaccent Lcaron lcaron Ldot ldot Lslash lslash Nacute nacute Ncommaaccent ncommaaccent Ncaron ncaron napostrophe Eng eng Omacron omacron Obreve obreve Ohungarumlaut ohungarumlaut OE oe Racute racute Rcommaaccent rcommaaccent Rcaron rcaron Sacute sacute Scircumflex scircumflex Scedilla scedilla Scaron scaron Tcedilla tcedilla Tcaron tcaron Tbar tbar Utilde utilde Umacron umacron Ubreve ubreve Uring uring Uhungarumlaut uhungarumlaut Uogonek uogonek Wcircumflex wcircumflex Ycircumflex ycircumflex Ydieresis Zacute zacute Zdotaccent zdotaccent Zcaron zcaron")
+	for i, name := range extendedA {
+		names[name] = rune(0x100 + i)
+	}
+	return names
+}()
+
+// codespaceWidth returns the byte length of the first codespace range of a CMap
+func codespaceWidth(data []byte) int {
+	lexer := &contentLexer{data: data}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return 0
+		}
+		if token == contentOperator("begincodespacerange") {
+			if low, err := lexer.next(); err == nil {
+				if b, ok := low.([]byte); ok {
+					return len(b)
+				}
+			}
+			return 0
+		}
+	}
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap
+func parseToUnicode(data []byte) map[int][]rune {
+	mapping := map[int][]rune{}
+	lexer := &contentLexer{data: data}
+	code := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return mapping
+		}
+		switch token {
+		case contentOperator("beginbfchar"):
+			for {
+				src, err := lexer.next()
+				if err != nil || src == contentOperator("endbfchar") {
+					break
+				}
+				dst, err := lexer.next()
+				if err != nil {
+					break
+				}
+				s, ok1 := src.([]byte)
+				d, ok2 := dst.([]byte)
+				if ok1 && ok2 {
+					mapping[code(s)] = utf16Runes(d)
+				}
+			}
+		case contentOperator("beginbfrange"):
+			for {
+				low, err := lexer.next()
+				if err != nil || low == contentOperator("endbfrange") {
+					break
+				}
+				high, err1 := lexer.next()
+				dst, err2 := lexer.next()
+				if err1 != nil || err2 != nil {
+					break
+				}
+				l, ok1 := low.([]byte)
+				h, ok2 := high.([]byte)
+				if !ok1 || !ok2 {
+					continue
+				}
+				first, last := code(l), code(h)
+				if last-first > 65535 {
+					continue
+				}
+				switch d := dst.(type) {
+				case []byte:
+					base := utf16Runes(d)
+					if len(base) == 0 {
+						continue
+					}
+					for c := first; c <= last; c++ {
+						runes := append([]rune{}, base...)
+						runes[len(runes)-1] += rune(c - first)
+						mapping[c] = runes
+					}
+				case []interface{}:
+					for i, item := range d {
+						if b, ok := item.([]byte); ok && first+i <= last {
+							mapping[first+i] = utf16Runes(b)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func utf16Runes(b []byte) []rune {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	if len(b) == 1 {
+		return []rune{rune(b[0])}
+	}
+	return utf16.Decode(units)
+}
+
+// embeddedFace - Parse an embedded TrueType or OpenType font program
+func (r *pageRenderer) embeddedFace(descriptor types.Dict) *sfnt.Font {
+	sd, _, err := r.ctx.DereferenceStreamDict(descriptor["FontFile2"])
+	if err != nil || sd == nil {
+		sd, _, err = r.ctx.DereferenceStreamDict(descriptor["FontFile3"])
+		if err != nil || sd == nil {
+			return nil
+		}
+		// Bare CFF programs (Type1C, CIDFontType0C) are left to the fallback fonts
+		if subtype := sd.NameEntry("Subtype"); subtype == nil || *subtype != "OpenType" {
+			return nil
+		}
+	}
+	if err := sd.Decode(); err != nil {
+		return nil
+	}
+	if face, err := sfnt.Parse(sd.Content); err == nil {
+		return face
+	}
+	// Subset fonts often drop the cmap and post tables, which only the parser needs
+	for _, replace := range []bool{false, true} {
+		if patched := patchFontTables(sd.Content, replace); patched != nil {
+			if face, err := sfnt.Parse(patched); err == nil {
+				return face
+			}
+		}
+	}
+	return nil
+}
+
+// patchFontTables rebuilds a TrueType table directory with placeholder cmap and post tables,
+// added when missing or also replacing the existing ones
+func patchFontTables(data []byte, replace bool) []byte {
+	if len(data) < 12 {
+		return nil
+	}
+	count := int(data[4])<<8 | int(data[5])
+	if len(data) < 12+16*count {
+		return nil
+	}
+	tables := map[string][]byte{}
+	for i := 0; i < count; i++ {
+		record := data[12+16*i:]
+		tag := string(record[:4])
+		offset := int(record[8])<<24 | int(record[9])<<16 | int(record[10])<<8 | int(record[11])
+		length := int(record[12])<<24 | int(record[13])<<16 | int(record[14])<<8 | int(record[15])
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			continue
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	if _, ok := tables["cmap"]; !ok || replace {
+		// Format 4 subtable holding only the mandatory 0xFFFF segment
+		tables["cmap"] = []byte{
+			0, 0, 0, 1, 0, 3, 0, 1, 0, 0, 0, 12,
+			0, 4, 0, 24, 0, 0, 0, 2, 0, 2, 0, 0, 0, 0,
+			0xFF, 0xFF, 0, 0, 0xFF, 0xFF, 0, 1, 0, 0,
+		}
+	}
+	if post, ok := tables["post"]; !ok || replace || len(post) < 32 {
+		tables["post"] = append([]byte{0, 3, 0, 0}, make([]byte, 28)...)
+	}
+
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	header := 12 + 16*len(tags)
+	out := make([]byte, header, header+len(data)+64)
+	copy(out, data[:4])
+	out[4], out[5] = byte(len(tags)>>8), byte(len(tags))
+	for i, tag := range tags {
+		table := tables[tag]
+		offset := len(out)
+		record := out[12+16*i:]
+		copy(record, tag)
+		for j, v := range []int{offset, len(table)} {
+			record[8+4*j] = byte(v >> 24)
+			record[9+4*j] = byte(v >> 16)
+			record[10+4*j] = byte(v >> 8)
+			record[11+4*j] = byte(v)
+		}
+		out = append(out, table...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+// fallbackFonts are the bundled Go fonts used for fonts that are not embedded or cannot be parsed
+var fallbackFonts = map[string][]byte{
+	"regular":    goregular.TTF,
+	"bold":       gobold.TTF,
+	"italic":     goitalic.TTF,
+	"bolditalic": gobolditalic.TTF,
+	"mono":       gomono.TTF,
+}
+
+var fallbackFaces = map[string]*sfnt.Font{}
+
+// fallbackFace picks a bundled font matching the style suggested by the font name and flags
+func fallbackFace(baseFont string, flags int) *sfnt.Font {
+	name := strings.ToLower(baseFont)
+	style := "regular"
+	bold := flags&(1<<18) != 0 || strings.Contains(name, "bold") || strings.Contains(name, "black") || strings.Contains(name, "heavy")
+	italic := flags&64 != 0 || strings.Contains(name, "italic") || strings.Contains(name, "oblique")
+	switch {
+	case flags&1 != 0 || strings.Contains(name, "courier") || strings.Contains(name, "mono"):
+		style = "mono"
+	case bold && italic:
+		style = "bolditalic"
+	case bold:
+		style = "bold"
+	case italic:
+		style = "italic"
+	}
+	if face, ok := fallbackFaces[style]; ok {
+		return face
+	}
+	face, err := sfnt.Parse(fallbackFonts[style])
+	if err != nil {
+		return nil
+	}
+	fallbackFaces[style] = face
+	return face
+}
+
+// runes returns the Unicode text of a character code, preferring the ToUnicode map
+func (f *renderFont) runes(code int) []rune {
+	if runes, ok := f.unicode[code]; ok {
+		return runes
+	}
+	if f.composite {
+		return nil
+	}
+	if c, ok := f.encoding[code]; ok {
+		return []rune{c}
+	}
+	if name, ok := f.names[code]; ok {
+		return glyphNameRunes(name)
+	}
+	return nil
+}
+
+// pdfWidth returns the advance declared by the font dictionary, in text space units
+func (f *renderFont) pdfWidth(code int) (float64, bool) {
+	if w, ok := f.widths[code]; ok {
+		return w * f.widthScale, true
+	}
+	if f.composite || f.hasWidths {
+		return f.defaultWidth, true
+	}
+	if font.IsCoreFont(f.baseFont) {
+		return float64(font.CharWidth(f.baseFont, rune(code))) / 1000, true
+	}
+	return 0, false
+}
+
+// glyphIndex finds the glyph of a code in the embedded font program
+func (r *pageRenderer) glyphIndex(f *renderFont, code int) sfnt.GlyphIndex {
+	if f.composite {
+		if f.cidToGID != nil {
+			if code < len(f.cidToGID) {
+				return sfnt.GlyphIndex(f.cidToGID[code])
+			}
+			return 0
+		}
+		return sfnt.GlyphIndex(code)
+	}
+	lookup := func(c rune) sfnt.GlyphIndex {
+		gid, err := f.face.GlyphIndex(&r.glyphBuf, c)
+		if err != nil {
+			return 0
+		}
+		return gid
+	}
+	if name, ok := f.names[code]; ok {
+		if f.namedGlyphs == nil {
+			f.namedGlyphs = map[string]sfnt.GlyphIndex{}
+			for gid := 0; gid < f.face.NumGlyphs(); gid++ {
+				if glyphName, err := f.face.GlyphName(&r.glyphBuf, sfnt.GlyphIndex(gid)); err == nil && glyphName != "" {
+					f.namedGlyphs[glyphName] = sfnt.GlyphIndex(gid)
+				}
+			}
+		}
+		if gid, ok := f.namedGlyphs[name]; ok && gid != 0 {
+			return gid
+		}
+	}
+	candidates := []rune{}
+	if runes := f.runes(code); len(runes) == 1 {
+		candidates = append(candidates, runes[0])
+	}
+	// Symbolic TrueType fonts map codes through the (3,0) cmap at 0xF000 or the Mac Roman table
+	candidates = append(candidates, rune(0xF000+code), rune(code), charmap.Macintosh.DecodeByte(byte(code)))
+	if f.symbolic {
+		candidates = append(candidates[len(candidates)-3:], candidates[:len(candidates)-3]...)
+	}
+	for _, c := range candidates {
+		if gid := lookup(c); gid != 0 {
+			return gid
+		}
+	}
+	return 0
+}
+
+// ligatures expand presentation forms missing from the fallback fonts
+var ligatures = map[rune]string{0xFB00: "ff", 0xFB01: "fi", 0xFB02: "fl", 0xFB03: "ffi", 0xFB04: "ffl"}
+
+// glyph - Load the outline drawn for a code, scaled to the advance the PDF declares
+func (r *pageRenderer) glyph(f *renderFont, code int) *renderGlyph {
+	if g, ok := f.glyphs[code]; ok {
+		return g
+	}
+	g := &renderGlyph{}
+	f.glyphs[code] = g
+
+	if f.face != nil {
+		if gid := r.glyphIndex(f, code); gid != 0 || f.composite && code != 0 {
+			if segments, advance, ok := r.loadOutline(f.face, gid); ok {
+				g.segments, g.advance = segments, advance
+				return g
+			}
+		}
+	}
+	if f.fallback == nil {
+		return g
+	}
+	runes := []rune{}
+	for _, c := range f.runes(code) {
+		if expanded, ok := ligatures[c]; ok {
+			if gid, _ := f.fallback.GlyphIndex(&r.glyphBuf, c); gid == 0 {
+				runes = append(runes, []rune(expanded)...)
+				continue
+			}
+		}
+		runes = append(runes, c)
+	}
+	for _, c := range runes {
+		if c == ' ' || c == 0xA0 {
+			advance, _ := r.fallbackAdvance(f.fallback, c)
+			g.advance += advance
+			continue
+		}
+		gid, err := f.fallback.GlyphIndex(&r.glyphBuf, c)
+		if err != nil || gid == 0 {
+			continue
+		}
+		segments, advance, ok := r.loadOutline(f.fallback, gid)
+		if !ok {
+			continue
+		}
+		for _, s := range segments {
+			for i := range s.points {
+				s.points[i].x += g.advance
+			}
+			g.segments = append(g.segments, s)
+		}
+		g.advance += advance
+	}
+	// Squeeze or stretch the substitute glyphs to the width the layout was computed with
+	if width, ok := f.pdfWidth(code); ok && width > 0 && g.advance > 0 {
+		scale := math.Max(0.5, math.Min(2, width/g.advance))
+		for i := range g.segments {
+			for j := range g.segments[i].points {
+				g.segments[i].points[j].x *= scale
+			}
+		}
+	}
+	return g
+}
+
+func (r *pageRenderer) fallbackAdvance(face *sfnt.Font, c rune) (float64, bool) {
+	gid, err := face.GlyphIndex(&r.glyphBuf, c)
+	if err != nil || gid == 0 {
+		return 0, false
+	}
+	_, advance, ok := r.loadOutline(face, gid)
+	return advance, ok
+}
+
+// loadOutline returns a glyph outline and advance in em units
+func (r *pageRenderer) loadOutline(face *sfnt.Font, gid sfnt.GlyphIndex) ([]glyphSegment, float64, bool) {
+	unitsPerEm := float64(face.UnitsPerEm())
+	if unitsPerEm <= 0 {
+		return nil, 0, false
+	}
+	ppem := fixed.Int26_6(face.UnitsPerEm()) << 6
+	segments, err := face.LoadGlyph(&r.glyphBuf, gid, ppem, nil)
+	if err != nil {
+		return nil, 0, false
+	}
+	scale := 1 / (64 * unitsPerEm)
+	outline := make([]glyphSegment, len(segments))
+	for i, s := range segments {
+		outline[i].op = s.Op
+		for j, p := range s.Args {
+			outline[i].points[j] = renderPoint{float64(p.X) * scale, -float64(p.Y) * scale}
+		}
+	}
+	advance, err := face.GlyphAdvance(&r.glyphBuf, gid, ppem, 0)
+	if err != nil {
+		return outline, 0, true
+	}
+	return outline, float64(advance) * scale, true
+}
+
+// appendPolygons flattens the glyph outline in device space
+func (g *renderGlyph) appendPolygons(polygons [][]renderPoint, m pdfMatrix) [][]renderPoint {
+	var contour []renderPoint
+	var last renderPoint
+	for _, s := range g.segments {
+		switch s.op {
+		case sfnt.SegmentOpMoveTo:
+			if len(contour) > 2 {
+				polygons = append(polygons, contour)
+			}
+			contour = []renderPoint{m.apply(s.points[0].x, s.points[0].y)}
+			last = s.points[0]
+		case sfnt.SegmentOpLineTo:
+			contour = append(contour, m.apply(s.points[0].x, s.points[0].y))
+			last = s.points[0]
+		case sfnt.SegmentOpQuadTo:
+			contour = flattenQuad(contour, m.apply(last.x, last.y), m.apply(s.points[0].x, s.points[0].y), m.apply(s.points[1].x, s.points[1].y))
+			last = s.points[1]
+		case sfnt.SegmentOpCubeTo:
+			contour = flattenCubic(contour, m.apply(last.x, last.y), m.apply(s.points[0].x, s.points[0].y),
+				m.apply(s.points[1].x, s.points[1].y), m.apply(s.points[2].x, s.points[2].y))
+			last = s.points[2]
+		}
+	}
+	if len(contour) > 2 {
+		polygons = append(polygons, contour)
+	}
+	return polygons
+}
+
+// showText - Draw the strings of a text showing operator, advancing the text matrix
+func (r *pageRenderer) showText(items []interface{}, resources types.Dict) {
+	st := &r.state
+	f := st.font
+	if f == nil {
+		return
+	}
+	polygons := [][]renderPoint{}
+	for _, item := range items {
+		switch v := item.(type) {
+		case float64:
+			tx := -v / 1000 * st.fontSize * st.hScale
+			r.textMatrix = pdfMatrix{1, 0, 0, 1, tx, 0}.multiply(r.textMatrix)
+		case []byte:
+			step := 1
+			if f.twoByte {
+				step = 2
+			}
+			for i := 0; i+step <= len(v); i += step {
+				code := int(v[i])
+				if step == 2 {
+					code = code<<8 | int(v[i+1])
+				}
+				trm := pdfMatrix{st.fontSize * st.hScale, 0, 0, st.fontSize, 0, st.rise}.multiply(r.textMatrix).multiply(st.ctm)
+				width, ok := f.pdfWidth(code)
+				if f.type3 != nil {
+					if st.textMode != 3 && st.textMode != 7 && r.canvas != nil {
+						r.drawType3Glyph(f, code, trm, resources)
+					}
+				} else {
+					g := r.glyph(f, code)
+					if !ok {
+						width = g.advance
+					}
+					if st.textMode != 3 && r.canvas != nil {
+						polygons = g.appendPolygons(polygons, trm)
+					}
+				}
+				// Invisible text is recorded too: it is the text layer of scanned pages
+				if r.collectText {
+					r.recordGlyph(f, code, trm, width)
+				}
+				tx := width*st.fontSize + st.charSpacing
+				if step == 1 && code == 32 {
+					tx += st.wordSpacing
+				}
+				r.textMatrix = pdfMatrix{1, 0, 0, 1, tx * st.hScale, 0}.multiply(r.textMatrix)
+			}
+		}
+	}
+	if len(polygons) == 0 {
+		return
+	}
+	switch st.textMode {
+	case 0, 4:
+		r.fill(polygons, false, r.fillPaint())
+	case 1, 5:
+		r.strokeGlyphs(polygons)
+	case 2, 6:
+		r.fill(polygons, false, r.fillPaint())
+		r.strokeGlyphs(polygons)
+	}
+	if st.textMode >= 4 {
+		r.textClip = append(r.textClip, polygons...)
+	}
+}
+
+// recordGlyph adds a shown code to the text layer, boxed from the font descent to its ascent
+func (r *pageRenderer) recordGlyph(f *renderFont, code int, trm pdfMatrix, width float64) {
+	text := []rune{}
+	for _, c := range f.runes(code) {
+		if expanded, ok := ligatures[c]; ok {
+			text = append(text, []rune(expanded)...)
+		} else if c != 0 {
+			text = append(text, c)
+		}
+	}
+	if len(text) == 0 {
+		return
+	}
+	r.textGlyphs = append(r.textGlyphs, textGlyph{
+		text:   text,
+		quad:   [4]renderPoint{trm.apply(0, f.descent), trm.apply(width, f.descent), trm.apply(width, f.ascent), trm.apply(0, f.ascent)},
+		origin: trm.apply(0, 0),
+		end:    trm.apply(width, 0),
+		size:   math.Hypot(trm[2], trm[3]),
+		angle:  math.Atan2(trm[1], trm[0]) * 180 / math.Pi,
+		font:   f.baseFont,
+	})
+}
+
+func (r *pageRenderer) strokeGlyphs(polygons [][]renderPoint) {
+	path := make([]renderSubpath, len(polygons))
+	for i, polygon := range polygons {
+		path[i] = renderSubpath{points: polygon, closed: true}
+	}
+	r.stroke(path)
+}
+
+// drawType3Glyph - Run the glyph procedure of a Type 3 font
+func (r *pageRenderer) drawType3Glyph(f *renderFont, code int, trm pdfMatrix, resources types.Dict) {
+	name, ok := f.names[code]
+	if !ok || f.type3.procs == nil || r.depth >= maxRenderDepth {
+		return
+	}
+	sd, _, err := r.ctx.DereferenceStreamDict(f.type3.procs[name])
+	if err != nil || sd == nil || sd.Decode() != nil {
+		return
+	}
+	if f.type3.resources != nil {
+		resources = f.type3.resources
+	}
+	saved, savedStack, savedPath := r.state, r.stack, r.path
+	savedText, savedLine := r.textMatrix, r.lineMatrix
+	r.stack, r.path = nil, nil
+	r.state.ctm = f.type3.matrix.multiply(trm)
+	r.depth++
+	r.run(sd.Content, resources)
+	r.depth--
+	r.state, r.stack, r.path = saved, savedStack, savedPath
+	r.textMatrix, r.lineMatrix = savedText, savedLine
+}
+
+// newPDFConfiguration - pdfcpu configuration carrying the document password
+func newPDFConfiguration(password string) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+	conf.ValidationMode = model.ValidationRelaxed
+	return conf
+}
+
+// decodePDFData - Read PDF data given as base64, a Uint8Array or an ArrayBuffer, decrypting it when a password is given
+func decodePDFData(pdfData js.Value, password string) ([]byte, error) {
+	pdfBytes, err := bytesFromJS(pdfData)
+	if err != nil {
+		return nil, err
+	}
+
+	if password == "" {
+		return pdfBytes, nil
+	}
+
+	ctx, err := api.ReadContext(bytes.NewReader(pdfBytes), newPDFConfiguration(password))
+	if err != nil {
+		if errors.Is(err, pdfcpu.ErrWrongPassword) {
+			return nil, errors.New(localize("incorrect password for encrypted PDF"))
+		}
+		return nil, err
+	}
+	if ctx.Encrypt == nil {
+		return pdfBytes, nil
+	}
+
+	// Pages are rendered from the decrypted document
+	var buf bytes.Buffer
+	if err := api.Decrypt(bytes.NewReader(pdfBytes), &buf, newPDFConfiguration(password)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// optionalPassword - Read the optional password argument at the given position
+func optionalPassword(args []js.Value, index int) string {
+	if len(args) > index && args[index].Type() == js.TypeString {
+		return args[index].String()
+	}
+	return ""
+}
+
+// bytesFromJS - Read binary data passed as a Uint8Array, an ArrayBuffer or a base64 string
+func bytesFromJS(value js.Value) ([]byte, error) {
+	switch {
+	case value.Type() == js.TypeString:
+		return base64.StdEncoding.DecodeString(value.String())
+	case value.InstanceOf(js.Global().Get("ArrayBuffer")):
+		value = js.Global().Get("Uint8Array").New(value)
+	case !value.InstanceOf(js.Global().Get("Uint8Array")):
+		return nil, errors.New(localize("expected a Uint8Array, ArrayBuffer or base64 string"))
+	}
+
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"page-rendering",
+	"tiles",
+	"zoom-levels",
+	"text-layer",
+	"text-search",
+	"search-highlighting",
+	"encrypted-pdf",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and open documents so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	fonts, images, textPages := 0, 0, 0
+	for _, doc := range documents {
+		fonts += len(doc.fonts)
+		images += len(doc.images)
+		textPages += len(doc.text)
+	}
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"documents":    len(documents),
+		"cachedFonts":  fonts,
+		"cachedImages": images,
+		"textPages":    textPages,
+	}))
+}
+
+// releaseResources - Close every open document and return freed memory to the runtime
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{
+		"documents": len(documents),
+	}
+	documents = map[string]*viewerDocument{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "pdfviewer-wasm",
+		"version":         moduleVersion,
+		"description":     "PDF page rendering module for viewers: tiles, zoom levels, text layer and search highlighting",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"openDocument",
+		"closeDocument",
+		"getPageInfo",
+		"renderPage",
+		"renderTile",
+		"getTextLayer",
+		"searchText",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// pdfcpu must not look for a config directory inside the browser sandbox
+	api.DisableConfigDir()
+
+	// Register document functions
+	js.Global().Set("openDocument", js.FuncOf(openDocument))
+	js.Global().Set("closeDocument", js.FuncOf(closeDocument))
+	js.Global().Set("getPageInfo", js.FuncOf(getPageInfo))
+
+	// Register rendering and text functions
+	js.Global().Set("renderPage", js.FuncOf(renderPage))
+	js.Global().Set("renderTile", js.FuncOf(renderTile))
+	js.Global().Set("getTextLayer", js.FuncOf(getTextLayer))
+	js.Global().Set("searchText", js.FuncOf(searchText))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM PDF Viewer module ready!")
+	fmt.Println("Available functions: openDocument, closeDocument, getPageInfo, renderPage, renderTile, getTextLayer, searchText")
+
+	// Keep the program alive
+	select {}
+}
@@ -4,9 +4,20 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
 	"image/png"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall/js"
@@ -15,6 +26,10 @@ import (
 	"github.com/boombuler/barcode/code128"
 	"github.com/boombuler/barcode/code39"
 	"github.com/boombuler/barcode/ean"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/common"
+	zxingqr "github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode/detector"
 	"github.com/skip2/go-qrcode"
 )
 
@@ -107,6 +122,190 @@ func setSilentMode(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(silentMode)
 }
 
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"Error: at least one argument required (data)": "Erreur: au moins un argument requis (data)",
+	"Failed to generate QR code: %v":               "Erreur lors de la génération du QR code: %v",
+	"Unsupported barcode type: %s":                 "Type de code-barres non supporté: %s",
+	"Failed to generate barcode: %v":               "Erreur lors de la génération du code-barres: %v",
+	"Failed to resize barcode: %v":                 "Erreur lors du redimensionnement: %v",
+	"Failed to encode PNG: %v":                     "Erreur lors de l'encodage PNG: %v",
+	"Error: vCard object required":                 "Erreur: objet vCard requis",
+	"Failed to generate vCard QR code: %v":         "Erreur lors de la génération du QR vCard: %v",
+	"Error: WiFi object required":                  "Erreur: objet WiFi requis",
+	"Error: SSID required for WiFi QR code":        "Erreur: SSID requis pour le WiFi QR",
+	"Failed to generate WiFi QR code: %v":          "Erreur lors de la génération du QR WiFi: %v",
+	"Error: base64 image data required":            "Erreur: données d'image base64 requises",
+	"Error: image data too short":                  "Erreur: données d'image trop courtes",
+	"Error: invalid base64 image format":           "Erreur: format d'image base64 invalide",
+	"Error: invalid image: %v":                     "Erreur: image invalide: %v",
+	"Error: no QR code detected in the image":      "Erreur: aucun QR code détecté dans l'image",
+	"Error: unsupported output format %q":          "Erreur: format de sortie %q non supporté",
+	"QR decoding: feature in development - use a client-side JavaScript library for decoding":      "Décodage QR: Fonctionnalité en développement - utilisez une bibliothèque JavaScript côté client pour le décodage",
+	"Barcode decoding: feature in development - use a client-side JavaScript library for decoding": "Décodage code-barres: Fonctionnalité en développement - utilisez une bibliothèque JavaScript côté client pour le décodage",
+	"setLocale requires exactly 1 argument (locale)":                                               "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":                                                        "Langue %q non prise en charge (disponibles: %s)",
+}
+
+// Build metadata, injected by wasm-manager build through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"qr-generate",
+	"qr-decode",
+	"barcode-generate",
+	"barcode-decode",
+	"vcard",
+	"wifi",
+	"qr-assessment",
+	"deterministic-output",
+	"svg-output",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{}))
+}
+
+// releaseResources - Return freed memory to the runtime; qr-wasm keeps no cached state between calls
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("QR WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	advertised := getAvailableFunctions(this, nil).(js.Value)
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "qr-wasm",
+		"version":         moduleVersion,
+		"description":     "QR code and barcode generation and decoding module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
 // getAvailableFunctions - Return list of available functions
 func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 	functions := []interface{}{
@@ -116,8 +315,13 @@ func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 		"decodeBarcode",
 		"generateVCard",
 		"generateWiFiQR",
+		"assessQRCode",
 		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
 		"setSilentMode",
+		"setLocale",
 	}
 	return js.ValueOf(functions)
 }
@@ -126,7 +330,7 @@ func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 func generateQRCode(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(QRResult{
-			Error: "Erreur: au moins un argument requis (data)",
+			Error: localize("Error: at least one argument required (data)"),
 		})
 	}
 
@@ -154,49 +358,41 @@ func generateQRCode(this js.Value, args []js.Value) interface{} {
 		}
 	}
 
+	options, err := readOutputOptions(args, 3)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{"error": err.Error()})
+	}
+
 	if !silentMode {
 		fmt.Printf("QR WASM: Generating QR code for data: %s (size: %d)\n", data, size)
 	}
 
 	// Generate QR code
-	qrBytes, err := qrcode.Encode(data, errorLevel, size)
+	code, err := encodeQRCode(data, errorLevel, size, options)
 	if err != nil {
 		return js.ValueOf(QRResult{
-			Error: fmt.Sprintf("Erreur lors de la génération du QR code: %v", err),
+			Error: localize("Failed to generate QR code: %v", err),
 		})
 	}
 
-	// Convert to base64
-	base64Image := base64.StdEncoding.EncodeToString(qrBytes)
-
-	result := QRResult{
-		Data:         data,
-		Size:         size,
-		Base64Image:  base64Image,
-		ErrorLevel:   getErrorLevelString(errorLevel),
-		ContentType:  "image/png",
-		OriginalData: data,
-	}
-
 	if !silentMode {
-		fmt.Printf("QR WASM: QR code generated successfully (size: %d bytes)\n", len(qrBytes))
+		fmt.Printf("QR WASM: QR code generated successfully (size: %d bytes)\n", len(code.data))
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"data":         result.Data,
-		"size":         result.Size,
-		"base64Image":  result.Base64Image,
-		"errorLevel":   result.ErrorLevel,
-		"contentType":  result.ContentType,
-		"originalData": result.OriginalData,
-	})
+	return js.ValueOf(code.result(map[string]interface{}{
+		"data":         data,
+		"size":         size,
+		"imageSize":    code.width,
+		"errorLevel":   getErrorLevelString(errorLevel),
+		"originalData": data,
+	}, options))
 }
 
 // generateBarcode - Generate barcode from data
 func generateBarcode(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(BarcodeResult{
-			Error: "Erreur: au moins un argument requis (data)",
+			Error: localize("Error: at least one argument required (data)"),
 		})
 	}
 
@@ -221,12 +417,16 @@ func generateBarcode(this js.Value, args []js.Value) interface{} {
 		}
 	}
 
+	options, err := readOutputOptions(args, 4)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{"error": err.Error()})
+	}
+
 	if !silentMode {
 		fmt.Printf("QR WASM: Generating %s barcode for data: %s\n", barcodeType, data)
 	}
 
 	var barcodeObj barcode.Barcode
-	var err error
 
 	switch barcodeType {
 	case "code128":
@@ -239,66 +439,40 @@ func generateBarcode(this js.Value, args []js.Value) interface{} {
 		barcodeObj, err = ean.Encode(data)
 	default:
 		return js.ValueOf(BarcodeResult{
-			Error: fmt.Sprintf("Type de code-barres non supporté: %s", barcodeType),
+			Error: localize("Unsupported barcode type: %s", barcodeType),
 		})
 	}
 
 	if err != nil {
 		return js.ValueOf(BarcodeResult{
-			Error: fmt.Sprintf("Erreur lors de la génération du code-barres: %v", err),
+			Error: localize("Failed to generate barcode: %v", err),
 		})
 	}
 
-	// Scale barcode
-	scaledBarcode, err := barcode.Scale(barcodeObj, width, height)
+	// Scale and encode the barcode
+	code, err := encodeBarcode(barcodeObj, width, height, options)
 	if err != nil {
-		return js.ValueOf(BarcodeResult{
-			Error: fmt.Sprintf("Erreur lors du redimensionnement: %v", err),
-		})
-	}
-
-	// Convert to PNG
-	var buf bytes.Buffer
-	err = png.Encode(&buf, scaledBarcode)
-	if err != nil {
-		return js.ValueOf(BarcodeResult{
-			Error: fmt.Sprintf("Erreur lors de l'encodage PNG: %v", err),
-		})
-	}
-
-	// Convert to base64
-	base64Image := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	result := BarcodeResult{
-		Data:         data,
-		Type:         barcodeType,
-		Base64Image:  base64Image,
-		Width:        width,
-		Height:       height,
-		ContentType:  "image/png",
-		OriginalData: data,
+		return js.ValueOf(map[string]interface{}{"error": err.Error()})
 	}
 
 	if !silentMode {
 		fmt.Printf("QR WASM: Barcode generated successfully (%dx%d)\n", width, height)
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"data":         result.Data,
-		"type":         result.Type,
-		"base64Image":  result.Base64Image,
-		"width":        result.Width,
-		"height":       result.Height,
-		"contentType":  result.ContentType,
-		"originalData": result.OriginalData,
-	})
+	return js.ValueOf(code.result(map[string]interface{}{
+		"data":         data,
+		"type":         barcodeType,
+		"width":        width,
+		"height":       height,
+		"originalData": data,
+	}, options))
 }
 
 // generateVCard - Generate QR code with vCard contact information
 func generateVCard(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(QRResult{
-			Error: "Erreur: objet vCard requis",
+			Error: localize("Error: vCard object required"),
 		})
 	}
 
@@ -364,45 +538,37 @@ func generateVCard(this js.Value, args []js.Value) interface{} {
 		}
 	}
 
+	options, err := readOutputOptions(args, 2)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{"error": err.Error()})
+	}
+
 	// Generate QR code
-	qrBytes, err := qrcode.Encode(vCardString, qrcode.Medium, size)
+	code, err := encodeQRCode(vCardString, qrcode.Medium, size, options)
 	if err != nil {
 		return js.ValueOf(QRResult{
-			Error: fmt.Sprintf("Erreur lors de la génération du QR vCard: %v", err),
+			Error: localize("Failed to generate vCard QR code: %v", err),
 		})
 	}
 
-	// Convert to base64
-	base64Image := base64.StdEncoding.EncodeToString(qrBytes)
-
-	result := QRResult{
-		Data:         "vCard Contact",
-		Size:         size,
-		Base64Image:  base64Image,
-		ErrorLevel:   "Medium",
-		ContentType:  "image/png",
-		OriginalData: vCardString,
-	}
-
 	if !silentMode {
 		fmt.Printf("QR WASM: vCard QR code generated successfully\n")
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"data":         result.Data,
-		"size":         result.Size,
-		"base64Image":  result.Base64Image,
-		"errorLevel":   result.ErrorLevel,
-		"contentType":  result.ContentType,
-		"originalData": result.OriginalData,
-	})
+	return js.ValueOf(code.result(map[string]interface{}{
+		"data":         "vCard Contact",
+		"size":         size,
+		"imageSize":    code.width,
+		"errorLevel":   "Medium",
+		"originalData": vCardString,
+	}, options))
 }
 
 // generateWiFiQR - Generate QR code for WiFi network connection
 func generateWiFiQR(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(QRResult{
-			Error: "Erreur: objet WiFi requis",
+			Error: localize("Error: WiFi object required"),
 		})
 	}
 
@@ -425,7 +591,7 @@ func generateWiFiQR(this js.Value, args []js.Value) interface{} {
 
 	if wifi.SSID == "" {
 		return js.ValueOf(QRResult{
-			Error: "Erreur: SSID requis pour le WiFi QR",
+			Error: localize("Error: SSID required for WiFi QR code"),
 		})
 	}
 
@@ -464,38 +630,30 @@ func generateWiFiQR(this js.Value, args []js.Value) interface{} {
 		}
 	}
 
+	options, err := readOutputOptions(args, 2)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{"error": err.Error()})
+	}
+
 	// Generate QR code
-	qrBytes, err := qrcode.Encode(wifiString, qrcode.Medium, size)
+	code, err := encodeQRCode(wifiString, qrcode.Medium, size, options)
 	if err != nil {
 		return js.ValueOf(QRResult{
-			Error: fmt.Sprintf("Erreur lors de la génération du QR WiFi: %v", err),
+			Error: localize("Failed to generate WiFi QR code: %v", err),
 		})
 	}
 
-	// Convert to base64
-	base64Image := base64.StdEncoding.EncodeToString(qrBytes)
-
-	result := QRResult{
-		Data:         fmt.Sprintf("WiFi Network: %s", wifi.SSID),
-		Size:         size,
-		Base64Image:  base64Image,
-		ErrorLevel:   "Medium",
-		ContentType:  "image/png",
-		OriginalData: wifiString,
-	}
-
 	if !silentMode {
 		fmt.Printf("QR WASM: WiFi QR code generated successfully\n")
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"data":         result.Data,
-		"size":         result.Size,
-		"base64Image":  result.Base64Image,
-		"errorLevel":   result.ErrorLevel,
-		"contentType":  result.ContentType,
-		"originalData": result.OriginalData,
-	})
+	return js.ValueOf(code.result(map[string]interface{}{
+		"data":         fmt.Sprintf("WiFi Network: %s", wifi.SSID),
+		"size":         size,
+		"imageSize":    code.width,
+		"errorLevel":   "Medium",
+		"originalData": wifiString,
+	}, options))
 }
 
 // decodeQRCode - Decode QR code from base64 image data
@@ -503,7 +661,7 @@ func decodeQRCode(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(DecodeResult{
 			Success: false,
-			Error:   "Erreur: données d'image base64 requises",
+			Error:   localize("Error: base64 image data required"),
 		})
 	}
 
@@ -513,7 +671,7 @@ func decodeQRCode(this js.Value, args []js.Value) interface{} {
 	if len(base64Data) < 100 {
 		return js.ValueOf(DecodeResult{
 			Success: false,
-			Error:   "Erreur: données d'image trop courtes",
+			Error:   localize("Error: image data too short"),
 			Type:    "qrcode",
 		})
 	}
@@ -522,7 +680,7 @@ func decodeQRCode(this js.Value, args []js.Value) interface{} {
 	if !strings.HasPrefix(base64Data, "data:image/") && !isValidBase64(base64Data) {
 		return js.ValueOf(DecodeResult{
 			Success: false,
-			Error:   "Erreur: format d'image base64 invalide",
+			Error:   localize("Error: invalid base64 image format"),
 			Type:    "qrcode",
 		})
 	}
@@ -540,7 +698,7 @@ func decodeQRCode(this js.Value, args []js.Value) interface{} {
 		Success:    false,
 		Type:       "qrcode",
 		Confidence: 0,
-		Error:      "Décodage QR: Fonctionnalité en développement - utilisez une bibliothèque JavaScript côté client pour le décodage",
+		Error:      localize("QR decoding: feature in development - use a client-side JavaScript library for decoding"),
 	}
 
 	// For development purposes, provide some mock responses for testing
@@ -569,7 +727,7 @@ func decodeBarcode(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return js.ValueOf(DecodeResult{
 			Success: false,
-			Error:   "Erreur: données d'image base64 requises",
+			Error:   localize("Error: base64 image data required"),
 		})
 	}
 
@@ -579,7 +737,7 @@ func decodeBarcode(this js.Value, args []js.Value) interface{} {
 	if len(base64Data) < 100 {
 		return js.ValueOf(DecodeResult{
 			Success: false,
-			Error:   "Erreur: données d'image trop courtes",
+			Error:   localize("Error: image data too short"),
 			Type:    "barcode",
 		})
 	}
@@ -588,7 +746,7 @@ func decodeBarcode(this js.Value, args []js.Value) interface{} {
 	if !strings.HasPrefix(base64Data, "data:image/") && !isValidBase64(base64Data) {
 		return js.ValueOf(DecodeResult{
 			Success: false,
-			Error:   "Erreur: format d'image base64 invalide",
+			Error:   localize("Error: invalid base64 image format"),
 			Type:    "barcode",
 		})
 	}
@@ -606,7 +764,7 @@ func decodeBarcode(this js.Value, args []js.Value) interface{} {
 		Success:    false,
 		Type:       "barcode",
 		Confidence: 0,
-		Error:      "Décodage code-barres: Fonctionnalité en développement - utilisez une bibliothèque JavaScript côté client pour le décodage",
+		Error:      localize("Barcode decoding: feature in development - use a client-side JavaScript library for decoding"),
 	}
 
 	// For development purposes, provide some mock responses for testing
@@ -631,7 +789,572 @@ func decodeBarcode(this js.Value, args []js.Value) interface{} {
 	})
 }
 
+// assessQRCode - Decode a QR code image and rate how reliably it will scan
+func assessQRCode(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"success": false,
+			"error":   localize("Error: base64 image data required"),
+		})
+	}
+
+	img, err := decodeBase64Image(args[0].String())
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"success": false,
+			"error":   localize("Error: invalid image: %v", err),
+		})
+	}
+
+	// Optional {dpi} to express module size in millimetres for print
+	dpi := 0.0
+	if len(args) >= 2 && args[1].Type() == js.TypeObject {
+		if v := args[1].Get("dpi"); v.Type() == js.TypeNumber {
+			dpi = v.Float()
+		}
+	}
+
+	if !silentMode {
+		fmt.Printf("QR WASM: Assessing QR code (%dx%d pixels)\n", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+
+	// Stretch the luminance range first so faint codes are still located and can be rated
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	source := gozxing.NewLuminanceSourceFromImage(stretchLuminance(img))
+
+	// Light-on-dark codes are only found on the inverted image
+	inverted := false
+	bitmap, detected, err := detectQRCode(source, hints)
+	if err != nil {
+		bitmap, detected, err = detectQRCode(source.Invert(), hints)
+		inverted = err == nil
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"success": false,
+			"score":   0,
+			"rating":  "unreadable",
+			"error":   localize("Error: no QR code detected in the image"),
+			"suggestions": []interface{}{
+				"Make sure all three finder patterns (corner squares) are fully visible",
+				"Use dark modules on a light background with strong contrast",
+				"Leave a blank margin of at least 4 modules around the code",
+			},
+		})
+	}
+
+	points := detected.GetPoints()
+	bottomLeft, topLeft, topRight := points[0], points[1], points[2]
+	dimension := detected.GetBits().GetWidth()
+	between := float64(dimension - 7)
+
+	topEdge := math.Hypot(topRight.GetX()-topLeft.GetX(), topRight.GetY()-topLeft.GetY())
+	leftEdge := math.Hypot(bottomLeft.GetX()-topLeft.GetX(), bottomLeft.GetY()-topLeft.GetY())
+	moduleSize := (topEdge + leftEdge) / 2 / between
+	aspectRatio := topEdge / leftEdge
+
+	rotation := math.Atan2(topRight.GetY()-topLeft.GetY(), topRight.GetX()-topLeft.GetX()) * 180 / math.Pi
+	cornerAngle := math.Acos(((topRight.GetX()-topLeft.GetX())*(bottomLeft.GetX()-topLeft.GetX())+
+		(topRight.GetY()-topLeft.GetY())*(bottomLeft.GetY()-topLeft.GetY()))/(topEdge*leftEdge)) * 180 / math.Pi
+	skew := math.Abs(90 - cornerAngle)
+
+	// Finder centres sit 3.5 modules inside the symbol corners
+	ux, uy := (topRight.GetX()-topLeft.GetX())/between, (topRight.GetY()-topLeft.GetY())/between
+	vx, vy := (bottomLeft.GetX()-topLeft.GetX())/between, (bottomLeft.GetY()-topLeft.GetY())/between
+	corners := [][2]float64{
+		{topLeft.GetX() - 3.5*ux - 3.5*vx, topLeft.GetY() - 3.5*uy - 3.5*vy},
+		{topRight.GetX() + 3.5*ux - 3.5*vx, topRight.GetY() + 3.5*uy - 3.5*vy},
+		{bottomLeft.GetX() - 3.5*ux + 3.5*vx, bottomLeft.GetY() - 3.5*uy + 3.5*vy},
+		{topRight.GetX() + bottomLeft.GetX() - topLeft.GetX() + 3.5*ux + 3.5*vx, topRight.GetY() + bottomLeft.GetY() - topLeft.GetY() + 3.5*uy + 3.5*vy},
+	}
+	symbol := image.Rectangle{
+		Min: image.Pt(math.MaxInt32, math.MaxInt32),
+		Max: image.Pt(math.MinInt32, math.MinInt32),
+	}
+	for _, c := range corners {
+		symbol.Min.X = min(symbol.Min.X, int(math.Floor(c[0])))
+		symbol.Min.Y = min(symbol.Min.Y, int(math.Floor(c[1])))
+		symbol.Max.X = max(symbol.Max.X, int(math.Ceil(c[0])))
+		symbol.Max.Y = max(symbol.Max.Y, int(math.Ceil(c[1])))
+	}
+	symbol = symbol.Intersect(img.Bounds())
+
+	darkLum, lightLum, threshold := qrLuminanceLevels(img, symbol)
+	contrastRatio := (lightLum + 0.05) / (darkLum + 0.05)
+	quietZone := qrQuietZone(img, symbol, threshold, inverted, int(math.Ceil(moduleSize/2))) / moduleSize
+	quietZone = math.Round(quietZone*2) / 2 // the symbol edge is only known to about half a module
+
+	// Decoding proves the data is actually recoverable, not just locatable
+	text := ""
+	errorLevel := ""
+	reader := zxingqr.NewQRCodeReader()
+	if result, err := reader.Decode(bitmap, hints); err == nil {
+		text = result.GetText()
+		if level, ok := result.GetResultMetadata()[gozxing.ResultMetadataType_ERROR_CORRECTION_LEVEL]; ok {
+			errorLevel = fmt.Sprintf("%v", level)
+		}
+	}
+
+	score := 100.0
+	issues := []interface{}{}
+	suggestions := []interface{}{}
+	penalize := func(points float64, issue, suggestion string) {
+		score -= points
+		issues = append(issues, issue)
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if text == "" {
+		penalize(40, "Finder patterns were found but the data could not be decoded",
+			"Regenerate the code with a higher error correction level and check for damage, glare or overprinting")
+	}
+
+	switch {
+	case contrastRatio < 3:
+		penalize(35, fmt.Sprintf("Very low contrast ratio (%.1f:1)", contrastRatio),
+			"Print dark modules (ideally pure black) on a white or very light background to reach at least 7:1")
+	case contrastRatio < 4.5:
+		penalize(15, fmt.Sprintf("Low contrast ratio (%.1f:1)", contrastRatio),
+			"Darken the modules or lighten the background; aim for a contrast ratio of 7:1 or more")
+	case contrastRatio < 7:
+		penalize(5, fmt.Sprintf("Moderate contrast ratio (%.1f:1)", contrastRatio),
+			"Increase contrast for reliable scanning in poor lighting")
+	}
+
+	switch {
+	case quietZone < 2:
+		penalize(30, fmt.Sprintf("Quiet zone is only %.1f modules wide", quietZone),
+			"Leave a blank margin of at least 4 modules on every side and keep text and artwork out of it")
+	case quietZone < 4:
+		penalize(15, fmt.Sprintf("Quiet zone is %.1f modules wide (4 recommended)", quietZone),
+			"Widen the blank margin around the code to at least 4 modules")
+	}
+
+	switch {
+	case moduleSize < 2:
+		penalize(30, fmt.Sprintf("Modules are only %.1f pixels wide", moduleSize),
+			"Render the code at a larger size so each module spans at least 4 pixels")
+	case moduleSize < 3:
+		penalize(15, fmt.Sprintf("Modules are %.1f pixels wide", moduleSize),
+			"Increase the rendered size so each module spans at least 4 pixels")
+	}
+
+	moduleSizeMm := 0.0
+	if dpi > 0 {
+		moduleSizeMm = moduleSize / dpi * 25.4
+		minWidthMm := float64(dimension+8) * 0.4 / 10
+		switch {
+		case moduleSizeMm < 0.25:
+			penalize(25, fmt.Sprintf("Printed modules are %.2f mm, below the 0.25 mm minimum", moduleSizeMm),
+				fmt.Sprintf("Print the code at least %.1f cm wide including the quiet zone, or encode less data", minWidthMm))
+		case moduleSizeMm < 0.33:
+			penalize(10, fmt.Sprintf("Printed modules are %.2f mm, small for consumer phones", moduleSizeMm),
+				fmt.Sprintf("Print the code at least %.1f cm wide including the quiet zone", minWidthMm))
+		}
+	}
+
+	switch {
+	case skew > 5:
+		penalize(15, fmt.Sprintf("Symbol is skewed by %.1f degrees", skew),
+			"Scan or photograph the code straight on, or remove perspective distortion before printing")
+	case skew > 2:
+		penalize(5, fmt.Sprintf("Symbol is slightly skewed (%.1f degrees)", skew),
+			"Check that the artwork is not distorted by scaling or perspective")
+	}
+
+	if math.Abs(aspectRatio-1) > 0.05 {
+		penalize(10, fmt.Sprintf("Symbol is stretched (aspect ratio %.2f)", aspectRatio),
+			"Scale the code proportionally; QR codes must stay square")
+	}
+
+	offAxis := math.Abs(math.Mod(rotation+360, 90))
+	offAxis = math.Min(offAxis, 90-offAxis)
+	if offAxis > 2 {
+		penalize(5, fmt.Sprintf("Symbol is rotated by %.1f degrees", rotation),
+			"Align the code with the page edges to simplify print inspection")
+	}
+
+	if inverted {
+		penalize(10, "Light modules on a dark background (inverted colors)",
+			"Use dark modules on a light background; many scanners cannot read inverted codes")
+	}
+
+	if score < 75 && errorLevel != "" && errorLevel != "H" {
+		suggestions = append(suggestions, "Use a higher error correction level (Q or H) to tolerate print defects")
+	}
+
+	score = math.Max(0, score)
+	rating := "poor"
+	switch {
+	case score >= 90:
+		rating = "excellent"
+	case score >= 75:
+		rating = "good"
+	case score >= 50:
+		rating = "fair"
+	}
+
+	if !silentMode {
+		fmt.Printf("QR WASM: Assessment score %.0f (%s), %d issues\n", score, rating, len(issues))
+	}
+
+	result := map[string]interface{}{
+		"success":          text != "",
+		"data":             text,
+		"version":          (dimension - 17) / 4,
+		"dimension":        dimension,
+		"errorLevel":       errorLevel,
+		"inverted":         inverted,
+		"moduleSizePx":     math.Round(moduleSize*100) / 100,
+		"quietZoneModules": math.Round(quietZone*10) / 10,
+		"contrastRatio":    math.Round(contrastRatio*100) / 100,
+		"rotation":         math.Round(rotation*10) / 10,
+		"skew":             math.Round(skew*10) / 10,
+		"aspectRatio":      math.Round(aspectRatio*1000) / 1000,
+		"score":            math.Round(score),
+		"rating":           rating,
+		"issues":           issues,
+		"suggestions":      suggestions,
+	}
+	if dpi > 0 {
+		result["moduleSizeMm"] = math.Round(moduleSizeMm*1000) / 1000
+	}
+
+	return js.ValueOf(result)
+}
+
+// decodeBase64Image - Decode a PNG or JPEG from base64 or a data URL
+func decodeBase64Image(data string) (image.Image, error) {
+	if comma := strings.Index(data, ","); strings.HasPrefix(data, "data:") && comma >= 0 {
+		data = data[comma+1:]
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	return img, err
+}
+
+// detectQRCode - Locate the finder patterns and sample the module grid
+func detectQRCode(source gozxing.LuminanceSource, hints map[gozxing.DecodeHintType]interface{}) (*gozxing.BinaryBitmap, *common.DetectorResult, error) {
+	bitmap, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(source))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matrix, err := bitmap.GetBlackMatrix()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	detected, err := detector.NewDetector(matrix).Detect(hints)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bitmap, detected, nil
+}
+
+// stretchLuminance - Grayscale copy of the image with its luminance range stretched to 0-255
+func stretchLuminance(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+
+	low, high := uint8(255), uint8(0)
+	for _, v := range gray.Pix {
+		low, high = min(low, v), max(high, v)
+	}
+	if high-low < 2 {
+		return gray
+	}
+
+	for i, v := range gray.Pix {
+		gray.Pix[i] = uint8(int(v-low) * 255 / int(high-low))
+	}
+	return gray
+}
+
+// pixelLuminance - WCAG relative luminance of a pixel
+func pixelLuminance(img image.Image, x, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	linear := func(c uint32) float64 {
+		v := float64(c) / 65535
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+// qrLuminanceLevels - Average dark and light luminance inside the symbol and the threshold between them
+func qrLuminanceLevels(img image.Image, area image.Rectangle) (dark, light, threshold float64) {
+	var values []float64
+	sum := 0.0
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			lum := pixelLuminance(img, x, y)
+			values = append(values, lum)
+			sum += lum
+		}
+	}
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+
+	threshold = sum / float64(len(values))
+	var darkSum, lightSum float64
+	var darkCount, lightCount int
+	for _, lum := range values {
+		if lum < threshold {
+			darkSum += lum
+			darkCount++
+		} else {
+			lightSum += lum
+			lightCount++
+		}
+	}
+	if darkCount > 0 {
+		dark = darkSum / float64(darkCount)
+	}
+	if lightCount > 0 {
+		light = lightSum / float64(lightCount)
+	}
+	if inverted := dark > light; inverted {
+		dark, light = light, dark
+	}
+
+	return dark, light, (dark + light) / 2
+}
+
+// qrQuietZone - Narrowest blank margin in pixels between the symbol and the nearest mark or image edge.
+// The estimated symbol edge can be off by a fraction of a module, so up to slack pixels of symbol are skipped first.
+func qrQuietZone(img image.Image, symbol image.Rectangle, threshold float64, inverted bool, slack int) float64 {
+	bounds := img.Bounds()
+	isMark := func(x, y int) bool {
+		if inverted {
+			return pixelLuminance(img, x, y) >= threshold
+		}
+		return pixelLuminance(img, x, y) < threshold
+	}
+
+	columnClear := func(x int) bool {
+		for y := symbol.Min.Y; y < symbol.Max.Y; y++ {
+			if isMark(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	rowClear := func(y int) bool {
+		for x := symbol.Min.X; x < symbol.Max.X; x++ {
+			if isMark(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// margin - Count clear lines from start, moving by step, once the symbol's own lines are skipped
+	margin := func(start, step, limit int, clear func(int) bool) int {
+		pos := start
+		for skipped := 0; skipped < slack && pos != limit && !clear(pos); skipped++ {
+			pos += step
+		}
+		count := 0
+		for ; pos != limit && clear(pos); pos += step {
+			count++
+		}
+		return count
+	}
+
+	left := margin(symbol.Min.X-1, -1, bounds.Min.X-1, columnClear)
+	right := margin(symbol.Max.X, 1, bounds.Max.X, columnClear)
+	top := margin(symbol.Min.Y-1, -1, bounds.Min.Y-1, rowClear)
+	bottom := margin(symbol.Max.Y, 1, bounds.Max.Y, rowClear)
+
+	return float64(min(left, right, top, bottom))
+}
+
 // Helper function to convert error level to string
+// outputOptions selects how generated codes are rendered: Format is "png" (default) or "svg", and
+// Deterministic renders whole-pixel modules with fixed encoder settings, so identical inputs give
+// byte-identical images that can be cached and compared in snapshot tests
+type outputOptions struct {
+	Format        string
+	Deterministic bool
+}
+
+// readOutputOptions reads the optional {format, deterministic} argument of the generators
+func readOutputOptions(args []js.Value, index int) (outputOptions, error) {
+	options := outputOptions{Format: "png"}
+	if len(args) <= index || args[index].Type() != js.TypeObject {
+		return options, nil
+	}
+	if v := args[index].Get("format"); v.Type() == js.TypeString {
+		options.Format = strings.ToLower(v.String())
+	}
+	if v := args[index].Get("deterministic"); v.Type() == js.TypeBoolean {
+		options.Deterministic = v.Bool()
+	}
+	if options.Format != "png" && options.Format != "svg" {
+		return options, errors.New(localize("Error: unsupported output format %q", options.Format))
+	}
+	return options, nil
+}
+
+// renderedCode is an encoded QR code or barcode image
+type renderedCode struct {
+	data        []byte
+	contentType string
+	width       int
+	height      int
+}
+
+// result adds the image and its rendering details to a generator result
+func (r renderedCode) result(fields map[string]interface{}, options outputOptions) map[string]interface{} {
+	fields["base64Image"] = base64.StdEncoding.EncodeToString(r.data)
+	fields["contentType"] = r.contentType
+	fields["format"] = options.Format
+	fields["deterministic"] = options.Deterministic || options.Format == "svg"
+	if options.Format == "svg" {
+		fields["svg"] = string(r.data)
+	}
+	if options.Deterministic {
+		digest := sha256.Sum256(r.data)
+		fields["sha256"] = hex.EncodeToString(digest[:])
+	}
+	return fields
+}
+
+// encodeQRCode renders content as a QR code of about size pixels. The default PNG keeps go-qrcode's
+// rendering, which stretches modules to fill size exactly; deterministic and SVG output draw every
+// module with the same whole number of pixels instead, so the image may be slightly smaller than size.
+func encodeQRCode(content string, level qrcode.RecoveryLevel, size int, options outputOptions) (renderedCode, error) {
+	if options.Format == "png" && !options.Deterministic {
+		data, err := qrcode.Encode(content, level, size)
+		return renderedCode{data: data, contentType: "image/png", width: size, height: size}, err
+	}
+
+	q, err := qrcode.New(content, level)
+	if err != nil {
+		return renderedCode{}, err
+	}
+	// The bitmap includes the 4-module quiet zone
+	bitmap := q.Bitmap()
+	modules := len(bitmap)
+	scale := size / modules
+	if scale < 1 {
+		scale = 1
+	}
+	dark := func(x, y int) bool { return bitmap[y][x] }
+
+	if options.Format == "svg" {
+		return renderedCode{
+			data:        moduleSVG(modules, modules, modules*scale, modules*scale, dark),
+			contentType: "image/svg+xml",
+			width:       modules * scale,
+			height:      modules * scale,
+		}, nil
+	}
+	data, err := modulePNG(modules, modules, scale, dark)
+	return renderedCode{data: data, contentType: "image/png", width: modules * scale, height: modules * scale}, err
+}
+
+// encodeBarcode renders a barcode at width x height pixels
+func encodeBarcode(code barcode.Barcode, width, height int, options outputOptions) (renderedCode, error) {
+	if options.Format == "svg" {
+		// Bars are drawn from the unscaled code and stretched by the viewBox
+		bounds := code.Bounds()
+		dark := func(x, y int) bool {
+			return isDark(code.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+		return renderedCode{
+			data:        moduleSVG(bounds.Dx(), bounds.Dy(), width, height, dark),
+			contentType: "image/svg+xml",
+			width:       width,
+			height:      height,
+		}, nil
+	}
+
+	scaled, err := barcode.Scale(code, width, height)
+	if err != nil {
+		return renderedCode{}, fmt.Errorf(localize("Failed to resize barcode: %v"), err)
+	}
+
+	var data []byte
+	if options.Deterministic {
+		bounds := scaled.Bounds()
+		data, err = modulePNG(bounds.Dx(), bounds.Dy(), 1, func(x, y int) bool {
+			return isDark(scaled.At(bounds.Min.X+x, bounds.Min.Y+y))
+		})
+	} else {
+		var buf bytes.Buffer
+		err = png.Encode(&buf, scaled)
+		data = buf.Bytes()
+	}
+	if err != nil {
+		return renderedCode{}, fmt.Errorf(localize("Failed to encode PNG: %v"), err)
+	}
+	return renderedCode{data: data, contentType: "image/png", width: width, height: height}, nil
+}
+
+// isDark reports whether a pixel of a rendered code is a dark module
+func isDark(c color.Color) bool {
+	return color.GrayModel.Convert(c).(color.Gray).Y < 128
+}
+
+// modulePNG encodes a grid of modules, each drawn as scale x scale pixels, as a 1-bit PNG. The
+// encoder settings are fixed and Go's encoder writes no timestamp or text chunks, so the bytes
+// depend only on the modules.
+func modulePNG(columns, rows, scale int, dark func(x, y int) bool) ([]byte, error) {
+	palette := color.Palette{color.White, color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, columns*scale, rows*scale), palette)
+	for y := 0; y < rows*scale; y++ {
+		for x := 0; x < columns*scale; x++ {
+			if dark(x/scale, y/scale) {
+				img.Pix[y*img.Stride+x] = 1
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// moduleSVG draws a grid of modules as an SVG of width x height pixels, one path with a subpath per
+// horizontal run of dark modules. The markup has no ids, metadata or dates, so it depends only on the modules.
+func moduleSVG(columns, rows, width, height int, dark func(x, y int) bool) []byte {
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" preserveAspectRatio="none" shape-rendering="crispEdges">`,
+		width, height, columns, rows)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#ffffff"/><path fill="#000000" d="`, columns, rows)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < columns; {
+			if !dark(x, y) {
+				x++
+				continue
+			}
+			run := 1
+			for x+run < columns && dark(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&svg, "M%d %dh%dv1h-%dz", x, y, run, run)
+			x += run
+		}
+	}
+	svg.WriteString(`"/></svg>`)
+	return svg.Bytes()
+}
+
 func getErrorLevelString(level qrcode.RecoveryLevel) string {
 	switch level {
 	case qrcode.Low:
@@ -657,14 +1380,19 @@ func main() {
 	js.Global().Set("decodeBarcode", js.FuncOf(decodeBarcode))
 	js.Global().Set("generateVCard", js.FuncOf(generateVCard))
 	js.Global().Set("generateWiFiQR", js.FuncOf(generateWiFiQR))
+	js.Global().Set("assessQRCode", js.FuncOf(assessQRCode))
 	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
 	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
 
 	// Signal ready for GoWM
 	js.Global().Set("__gowm_ready", js.ValueOf(true))
 
 	fmt.Println("QR WASM Module ready!")
-	fmt.Println("Available functions:", "generateQRCode, decodeQRCode, generateBarcode, decodeBarcode, generateVCard, generateWiFiQR")
+	fmt.Println("Available functions:", "generateQRCode, decodeQRCode, generateBarcode, decodeBarcode, generateVCard, generateWiFiQR, assessQRCode")
 
 	// Keep the program running
 	select {}
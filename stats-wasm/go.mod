@@ -0,0 +1,3 @@
+module stats-wasm
+
+go 1.21
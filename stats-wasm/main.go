@@ -0,0 +1,1873 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+)
+
+var silentMode = false
+
+// t-digest defaults. A compression of 100 keeps about 50-100 centroids, which is accurate
+// to a fraction of a percent at the median and much better in the tails.
+const (
+	defaultCompression = 100
+	minCompression     = 10
+	maxCompression     = 1000
+)
+
+// Histogram bounds, so a tiny bin width cannot allocate millions of bins
+const (
+	defaultHistogramBins = 10
+	maxHistogramBins     = 10000
+)
+
+// defaultAggregations are used by groupBy when no aggregations are given
+var defaultAggregations = []string{"count", "sum", "mean", "min", "max"}
+
+// centroid is a cluster of t-digest samples summarized by their mean and total weight
+type centroid struct {
+	Mean   float64
+	Weight float64
+}
+
+// tdigest is a merging t-digest: samples are buffered, then merged into centroids whose
+// size is bounded by the k1 scale function so the tails keep small clusters
+type tdigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min         float64
+	max         float64
+}
+
+// DigestFile is the portable form of a t-digest written by exportDigest
+type DigestFile struct {
+	Compression float64     `json:"compression"`
+	Count       float64     `json:"count"`
+	Min         float64     `json:"min"`
+	Max         float64     `json:"max"`
+	Centroids   [][]float64 `json:"centroids"`
+}
+
+// digests holds the t-digests created by createDigest, keyed by digest ID
+var digests = map[string]*tdigest{}
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%s requires at least %d arguments (%s)":                      "%s requiert au moins %d arguments (%s)",
+	"%s requires at least 1 argument (%s)":                        "%s requiert au moins 1 argument (%s)",
+	"Failed to export digest: %v":                                 "Échec de l'export du digest : %v",
+	"Invalid column: %v":                                          "Colonne invalide : %v",
+	"Invalid columns: %v":                                         "Colonnes invalides : %v",
+	"Invalid digest: %v":                                          "Digest invalide : %v",
+	"Invalid keys: %v":                                            "Clés invalides : %v",
+	"Invalid options: %v":                                         "Options invalides : %v",
+	"Invalid quantiles: %v":                                       "Quantiles invalides : %v",
+	"Unknown digest %q (it may already be freed)":                 "Digest inconnu %q (il a peut-être déjà été libéré)",
+	"Unsupported locale %q (available: %s)":                       "Langue %q non prise en charge (disponibles: %s)",
+	"at least 1 column is required":                               "au moins 1 colonne est requise",
+	"at least 2 columns are required":                             "au moins 2 colonnes sont requises",
+	"bins must be a number, a strategy name or an array of edges": "bins doit être un nombre, un nom de stratégie ou un tableau de bornes",
+	"bins must be an integer between 1 and %d":                    "bins doit être un entier compris entre 1 et %d",
+	"centroid %d must be [mean, weight] with a positive weight within [min, max]": "le centroïde %d doit être [mean, weight] avec un poids positif dans [min, max]",
+	"column %q has %d values but %q has %d":                                       "la colonne %q a %d valeurs mais %q en a %d",
+	"column %q has %d values but there are %d keys":                               "la colonne %q a %d valeurs mais il y a %d clés",
+	"column %q: %v":                                                                           "colonne %q : %v",
+	"compression must be between %d and %d":                                                   "la compression doit être comprise entre %d et %d",
+	"edges must be finite and strictly increasing":                                            "les bornes doivent être finies et strictement croissantes",
+	"edges must define between 1 and %d bins":                                                 "les bornes doivent définir entre 1 et %d classes",
+	"expected a Float64Array, another typed array or an array of numbers":                     "Float64Array, autre tableau typé ou tableau de nombres attendu",
+	"expected a typed array or an array of strings or numbers":                                "tableau typé ou tableau de chaînes ou de nombres attendu",
+	"expected an object or an array of columns":                                               "objet ou tableau de colonnes attendu",
+	"key %d must be a string, a number or a boolean":                                          "la clé %d doit être une chaîne, un nombre ou un booléen",
+	"minPeriods must be between 1 and the window size (%d)":                                   "minPeriods doit être compris entre 1 et la taille de la fenêtre (%d)",
+	"percentiles must be between 0 and 1":                                                     "les percentiles doivent être compris entre 0 et 1",
+	"quantiles must be between 0 and 1":                                                       "les quantiles doivent être compris entre 0 et 1",
+	"range must be [min, max] with min < max":                                                 "range doit être [min, max] avec min < max",
+	"setLocale requires exactly 1 argument (locale)":                                          "setLocale requiert exactement 1 argument (locale)",
+	"the column has no values to bin":                                                         "la colonne n'a aucune valeur à répartir",
+	"unknown aggregation %q":                                                                  "agrégation %q inconnue",
+	"unknown binning strategy %q (use sturges, sqrt, rice, scott, freedman-diaconis or auto)": "stratégie de classes %q inconnue (utilisez sturges, sqrt, rice, scott, freedman-diaconis ou auto)",
+	"unknown method %q (use pearson or spearman)":                                             "méthode %q inconnue (utilisez pearson ou spearman)",
+	"unknown sort %q (use key, count or none)":                                                "tri %q inconnu (utilisez key, count ou none)",
+	"unknown stat %q (use mean, sum, count, min, max, std, variance or median)":               "statistique %q inconnue (utilisez mean, sum, count, min, max, std, variance ou median)",
+	"value %d is not a number":                                                                "la valeur %d n'est pas un nombre",
+	"value %d is not a number: %q":                                                            "la valeur %d n'est pas un nombre : %q",
+	"window must be a positive integer":                                                       "window doit être un entier positif",
+}
+
+// describe - Summarize a column: count, mean, spread, quartiles and shape. NaN values count as missing.
+func describe(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "describe", "column"),
+		})
+	}
+
+	column, err := readColumn(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid column: %v", err),
+		})
+	}
+
+	var options struct {
+		Percentiles []float64 `json:"percentiles"`
+	}
+	if len(args) > 1 && args[1].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	for _, p := range options.Percentiles {
+		if p < 0 || p > 1 {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", localize("percentiles must be between 0 and 1")),
+			})
+		}
+	}
+
+	values := presentValues(column)
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	n := float64(len(values))
+	mean, variance := meanVariance(values)
+	result := map[string]interface{}{
+		"count":    len(values),
+		"missing":  len(column) - len(values),
+		"sum":      sum(values),
+		"mean":     mean,
+		"variance": variance,
+		"std":      math.Sqrt(variance),
+		"min":      quantileSorted(sorted, 0),
+		"q1":       quantileSorted(sorted, 0.25),
+		"median":   quantileSorted(sorted, 0.5),
+		"q3":       quantileSorted(sorted, 0.75),
+		"max":      quantileSorted(sorted, 1),
+		"skewness": math.NaN(),
+		"kurtosis": math.NaN(),
+	}
+	result["range"] = result["max"].(float64) - result["min"].(float64)
+	result["iqr"] = result["q3"].(float64) - result["q1"].(float64)
+
+	// Adjusted Fisher-Pearson skewness and excess kurtosis, as reported by spreadsheets
+	if n > 3 && variance > 0 {
+		var m3, m4 float64
+		for _, v := range values {
+			d := (v - mean) / math.Sqrt(variance)
+			m3 += d * d * d
+			m4 += d * d * d * d
+		}
+		result["skewness"] = n / ((n - 1) * (n - 2)) * m3
+		result["kurtosis"] = n*(n+1)/((n-1)*(n-2)*(n-3))*m4 - 3*(n-1)*(n-1)/((n-2)*(n-3))
+	}
+
+	if len(options.Percentiles) > 0 {
+		percentiles := map[string]interface{}{}
+		for _, p := range options.Percentiles {
+			percentiles[percentileKey(p)] = quantileSorted(sorted, p)
+		}
+		result["percentiles"] = percentiles
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Described column of %d values (%d missing)\n", len(column), len(column)-len(values))
+	}
+
+	return js.ValueOf(result)
+}
+
+// groupBy - Aggregate value columns by a key column, returning one Float64Array per aggregation
+func groupBy(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least %d arguments (%s)", "groupBy", 2, "keys, columns"),
+		})
+	}
+
+	keys, err := readKeys(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid keys: %v", err),
+		})
+	}
+
+	names, columns, err := readColumns(args[1])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid columns: %v", err),
+		})
+	}
+	for i, column := range columns {
+		if len(column) != len(keys) {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid columns: %v", fmt.Errorf(localize("column %q has %d values but there are %d keys"), names[i], len(column), len(keys))),
+			})
+		}
+	}
+
+	var options struct {
+		Aggregations []string `json:"aggregations"`
+		Sort         string   `json:"sort"`
+	}
+	if len(args) > 2 && args[2].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[2])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	if len(options.Aggregations) == 0 {
+		options.Aggregations = defaultAggregations
+	}
+	for _, name := range options.Aggregations {
+		if _, err := aggregate(name, nil); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	// Groups are kept in order of first appearance unless a sort is requested
+	index := map[interface{}]int{}
+	groupKeys := []interface{}{}
+	rows := [][]int{}
+	for row, key := range keys {
+		g, ok := index[key]
+		if !ok {
+			g = len(groupKeys)
+			index[key] = g
+			groupKeys = append(groupKeys, key)
+			rows = append(rows, nil)
+		}
+		rows[g] = append(rows[g], row)
+	}
+
+	order := make([]int, len(groupKeys))
+	for i := range order {
+		order[i] = i
+	}
+	switch options.Sort {
+	case "", "none":
+	case "key":
+		sort.SliceStable(order, func(a, b int) bool { return keyLess(groupKeys[order[a]], groupKeys[order[b]]) })
+	case "count":
+		sort.SliceStable(order, func(a, b int) bool { return len(rows[order[a]]) > len(rows[order[b]]) })
+	default:
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", fmt.Errorf(localize("unknown sort %q (use key, count or none)"), options.Sort)),
+		})
+	}
+
+	sortedKeys := make([]interface{}, len(order))
+	counts := make([]float64, len(order))
+	for i, g := range order {
+		sortedKeys[i] = groupKeys[g]
+		counts[i] = float64(len(rows[g]))
+	}
+
+	aggregates := map[string]interface{}{}
+	groupValues := make([]float64, 0, len(keys))
+	for c, name := range names {
+		results := map[string][]float64{}
+		for _, aggregation := range options.Aggregations {
+			results[aggregation] = make([]float64, len(order))
+		}
+
+		for i, g := range order {
+			groupValues = groupValues[:0]
+			for _, row := range rows[g] {
+				if !math.IsNaN(columns[c][row]) {
+					groupValues = append(groupValues, columns[c][row])
+				}
+			}
+			for _, aggregation := range options.Aggregations {
+				results[aggregation][i], _ = aggregate(aggregation, groupValues)
+			}
+		}
+
+		columnResult := map[string]interface{}{}
+		for aggregation, values := range results {
+			columnResult[aggregation] = newFloat64Array(values)
+		}
+		aggregates[name] = columnResult
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Grouped %d rows into %d groups\n", len(keys), len(order))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"groups":  len(order),
+		"keys":    sortedKeys,
+		"counts":  newFloat64Array(counts),
+		"columns": aggregates,
+	})
+}
+
+// rolling - Compute a moving-window statistic over a column
+func rolling(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least %d arguments (%s)", "rolling", 2, "column, window"),
+		})
+	}
+
+	column, err := readColumn(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid column: %v", err),
+		})
+	}
+
+	if args[1].Type() != js.TypeNumber || args[1].Int() < 1 || float64(args[1].Int()) != args[1].Float() {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("window must be a positive integer"),
+		})
+	}
+	window := args[1].Int()
+
+	options := struct {
+		Stat       string `json:"stat"`
+		MinPeriods *int   `json:"minPeriods"`
+		Center     bool   `json:"center"`
+	}{Stat: "mean"}
+	if len(args) > 2 && args[2].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[2])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+
+	minPeriods := window
+	if options.MinPeriods != nil {
+		minPeriods = *options.MinPeriods
+	}
+	if minPeriods < 1 || minPeriods > window {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", fmt.Errorf(localize("minPeriods must be between 1 and the window size (%d)"), window)),
+		})
+	}
+
+	values, err := rollingWindow(column, window, minPeriods, options.Stat)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	// A centered window is the trailing window shifted back by half its size
+	if options.Center {
+		shift := window / 2
+		centered := make([]float64, len(values))
+		for i := range centered {
+			if i+shift < len(values) {
+				centered[i] = values[i+shift]
+			} else {
+				centered[i] = math.NaN()
+			}
+		}
+		values = centered
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Rolling %s over %d values (window %d)\n", options.Stat, len(column), window)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"values": newFloat64Array(values),
+		"window": window,
+		"stat":   options.Stat,
+	})
+}
+
+// correlationMatrix - Pairwise Pearson or Spearman correlations between columns
+func correlationMatrix(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "correlationMatrix", "columns"),
+		})
+	}
+
+	names, columns, err := readColumns(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid columns: %v", err),
+		})
+	}
+	if len(columns) < 2 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid columns: %v", localize("at least 2 columns are required")),
+		})
+	}
+	for i, column := range columns {
+		if len(column) != len(columns[0]) {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid columns: %v", fmt.Errorf(localize("column %q has %d values but %q has %d"), names[i], len(column), names[0], len(columns[0]))),
+			})
+		}
+	}
+
+	options := struct {
+		Method string `json:"method"`
+	}{Method: "pearson"}
+	if len(args) > 1 && args[1].Truthy() {
+		if err := json.Unmarshal([]byte(jsonArgument(args[1])), &options); err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", err),
+			})
+		}
+	}
+	if options.Method != "pearson" && options.Method != "spearman" {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", fmt.Errorf(localize("unknown method %q (use pearson or spearman)"), options.Method)),
+		})
+	}
+
+	// Each pair uses the rows where both columns have a value
+	k := len(columns)
+	matrix := make([]float64, k*k)
+	observations := make([]float64, k*k)
+	for i := 0; i < k; i++ {
+		for j := i; j < k; j++ {
+			x, y := completePairs(columns[i], columns[j])
+			if options.Method == "spearman" {
+				x, y = ranks(x), ranks(y)
+			}
+			r := pearson(x, y)
+			if i == j && len(x) > 1 && !math.IsNaN(r) {
+				r = 1
+			}
+			matrix[i*k+j], matrix[j*k+i] = r, r
+			observations[i*k+j], observations[j*k+i] = float64(len(x)), float64(len(x))
+		}
+	}
+
+	jsNames := make([]interface{}, k)
+	for i, name := range names {
+		jsNames[i] = name
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Computed %dx%d %s correlation matrix\n", k, k, options.Method)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"names":        jsNames,
+		"size":         k,
+		"method":       options.Method,
+		"matrix":       newFloat64Array(matrix),
+		"observations": newFloat64Array(observations),
+	})
+}
+
+// histogram - Bin a column with a fixed bin count, explicit edges or a binning strategy
+func histogram(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "histogram", "column"),
+		})
+	}
+
+	column, err := readColumn(args[0])
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid column: %v", err),
+		})
+	}
+
+	// Options are read field by field because bins is a number, a strategy name or an edges array
+	bins := js.Undefined()
+	rangeValue := js.Undefined()
+	density := false
+	if len(args) > 1 && args[1].Type() == js.TypeObject && !isArrayLike(args[1]) {
+		bins = args[1].Get("bins")
+		rangeValue = args[1].Get("range")
+		density = args[1].Get("density").Truthy()
+	} else if len(args) > 1 {
+		bins = args[1]
+	}
+
+	values := presentValues(column)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if rangeValue.Truthy() {
+		bounds, err := readColumn(rangeValue)
+		if err != nil || len(bounds) != 2 || !(bounds[0] < bounds[1]) {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid options: %v", localize("range must be [min, max] with min < max")),
+			})
+		}
+		lo, hi = bounds[0], bounds[1]
+	}
+
+	var edges []float64
+	strategy := "fixed"
+	switch {
+	case isArrayLike(bins):
+		edges, err = readColumn(bins)
+		if err == nil {
+			err = checkEdges(edges)
+		}
+		strategy = "edges"
+	default:
+		if len(values) == 0 && !rangeValue.Truthy() {
+			err = errors.New(localize("the column has no values to bin"))
+			break
+		}
+		count := defaultHistogramBins
+		switch bins.Type() {
+		case js.TypeUndefined, js.TypeNull:
+		case js.TypeNumber:
+			count = bins.Int()
+			if count < 1 || count > maxHistogramBins || float64(count) != bins.Float() {
+				err = fmt.Errorf(localize("bins must be an integer between 1 and %d"), maxHistogramBins)
+			}
+		case js.TypeString:
+			strategy = bins.String()
+			count, err = binCount(strategy, values, lo, hi)
+		default:
+			err = errors.New(localize("bins must be a number, a strategy name or an array of edges"))
+		}
+		if err == nil {
+			edges = uniformEdges(lo, hi, count)
+		}
+	}
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid options: %v", err),
+		})
+	}
+
+	// Bins are half-open [a, b) except the last one, which includes its right edge
+	nbins := len(edges) - 1
+	counts := make([]float64, nbins)
+	underflow, overflow := 0, 0
+	for _, v := range values {
+		switch {
+		case v < edges[0]:
+			underflow++
+		case v > edges[nbins]:
+			overflow++
+		case v == edges[nbins]:
+			counts[nbins-1]++
+		default:
+			counts[sort.Search(nbins, func(i int) bool { return edges[i+1] > v })]++
+		}
+	}
+
+	result := map[string]interface{}{
+		"edges":     newFloat64Array(edges),
+		"counts":    newFloat64Array(counts),
+		"bins":      nbins,
+		"strategy":  strategy,
+		"total":     len(values),
+		"missing":   len(column) - len(values),
+		"underflow": underflow,
+		"overflow":  overflow,
+	}
+	if density {
+		inRange := float64(len(values) - underflow - overflow)
+		densities := make([]float64, nbins)
+		for i, c := range counts {
+			if inRange > 0 {
+				densities[i] = c / inRange / (edges[i+1] - edges[i])
+			}
+		}
+		result["density"] = newFloat64Array(densities)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Histogram of %d values in %d bins (%s)\n", len(values), nbins, strategy)
+	}
+
+	return js.ValueOf(result)
+}
+
+// createDigest - Create an empty t-digest for streaming quantile estimation
+func createDigest(this js.Value, args []js.Value) interface{} {
+	compression := float64(defaultCompression)
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		if value := args[0].Get("compression"); value.Type() == js.TypeNumber {
+			compression = value.Float()
+		}
+	} else if len(args) > 0 && args[0].Type() == js.TypeNumber {
+		compression = args[0].Float()
+	}
+	if compression < minCompression || compression > maxCompression {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("compression must be between %d and %d", minCompression, maxCompression),
+		})
+	}
+
+	id := newID()
+	digests[id] = newTDigest(compression)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Created t-digest %s (compression %g)\n", id, compression)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"digestId":    id,
+		"compression": compression,
+	})
+}
+
+// digestAdd - Feed a value or a column of values into a t-digest. NaN values are skipped.
+func digestAdd(this js.Value, args []js.Value) interface{} {
+	d, id, failure := digestArgument(args, "digestAdd", 2, "digestId, values")
+	if failure != nil {
+		return failure
+	}
+
+	var values []float64
+	if args[1].Type() == js.TypeNumber {
+		values = []float64{args[1].Float()}
+	} else {
+		column, err := readColumn(args[1])
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid column: %v", err),
+			})
+		}
+		values = column
+	}
+
+	added := 0
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		d.add(v, 1)
+		added++
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Added %d values to t-digest %s\n", added, id)
+	}
+
+	return js.ValueOf(d.info(id, added))
+}
+
+// digestQuantiles - Estimate one or more quantiles (0..1) from a t-digest
+func digestQuantiles(this js.Value, args []js.Value) interface{} {
+	d, id, failure := digestArgument(args, "digestQuantiles", 2, "digestId, quantiles")
+	if failure != nil {
+		return failure
+	}
+
+	single := args[1].Type() == js.TypeNumber
+	var qs []float64
+	if single {
+		qs = []float64{args[1].Float()}
+	} else {
+		column, err := readColumn(args[1])
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid quantiles: %v", err),
+			})
+		}
+		qs = column
+	}
+	for _, q := range qs {
+		if !(q >= 0 && q <= 1) {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid quantiles: %v", localize("quantiles must be between 0 and 1")),
+			})
+		}
+	}
+
+	estimates := make([]float64, len(qs))
+	for i, q := range qs {
+		estimates[i] = d.quantile(q)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Estimated %d quantiles from t-digest %s\n", len(qs), id)
+	}
+
+	result := d.info(id, 0)
+	if single {
+		result["value"] = estimates[0]
+	} else {
+		result["values"] = newFloat64Array(estimates)
+	}
+	return js.ValueOf(result)
+}
+
+// digestCDF - Estimate the fraction of values at or below x from a t-digest
+func digestCDF(this js.Value, args []js.Value) interface{} {
+	d, id, failure := digestArgument(args, "digestCDF", 2, "digestId, x")
+	if failure != nil {
+		return failure
+	}
+
+	single := args[1].Type() == js.TypeNumber
+	var xs []float64
+	if single {
+		xs = []float64{args[1].Float()}
+	} else {
+		column, err := readColumn(args[1])
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid column: %v", err),
+			})
+		}
+		xs = column
+	}
+
+	fractions := make([]float64, len(xs))
+	for i, x := range xs {
+		fractions[i] = d.cdf(x)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Estimated CDF at %d points from t-digest %s\n", len(xs), id)
+	}
+
+	result := d.info(id, 0)
+	if single {
+		result["value"] = fractions[0]
+	} else {
+		result["values"] = newFloat64Array(fractions)
+	}
+	return js.ValueOf(result)
+}
+
+// mergeDigests - Merge another digest (by ID or exported JSON) into a t-digest, e.g. partials from workers
+func mergeDigests(this js.Value, args []js.Value) interface{} {
+	d, id, failure := digestArgument(args, "mergeDigests", 2, "digestId, source")
+	if failure != nil {
+		return failure
+	}
+
+	var source *tdigest
+	if args[1].Type() == js.TypeString {
+		source = digests[args[1].String()]
+	}
+	if source == nil {
+		imported, err := parseDigest(jsonArgument(args[1]))
+		if err != nil {
+			return js.ValueOf(map[string]interface{}{
+				"error": localize("Invalid digest: %v", err),
+			})
+		}
+		source = imported
+	}
+
+	d.merge(source)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Merged %g values into t-digest %s\n", source.count, id)
+	}
+
+	return js.ValueOf(d.info(id, 0))
+}
+
+// exportDigest - Serialize a t-digest to JSON so it can be stored or merged elsewhere
+func exportDigest(this js.Value, args []js.Value) interface{} {
+	d, id, failure := digestArgument(args, "exportDigest", 1, "digestId")
+	if failure != nil {
+		return failure
+	}
+
+	d.compress()
+	file := DigestFile{
+		Compression: d.compression,
+		Count:       d.count,
+		Min:         d.min,
+		Max:         d.max,
+		Centroids:   make([][]float64, len(d.centroids)),
+	}
+	if d.count == 0 {
+		file.Min, file.Max = 0, 0
+	}
+	for i, c := range d.centroids {
+		file.Centroids[i] = []float64{c.Mean, c.Weight}
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Failed to export digest: %v", err),
+		})
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Exported t-digest %s (%d centroids)\n", id, len(d.centroids))
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"digest":    string(data),
+		"centroids": len(d.centroids),
+		"count":     d.count,
+	})
+}
+
+// importDigest - Restore a t-digest written by exportDigest under a new digest ID
+func importDigest(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "importDigest", "digest"),
+		})
+	}
+
+	d, err := parseDigest(jsonArgument(args[0]))
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Invalid digest: %v", err),
+		})
+	}
+
+	id := newID()
+	digests[id] = d
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Imported t-digest %s (%g values)\n", id, d.count)
+	}
+
+	return js.ValueOf(d.info(id, 0))
+}
+
+// freeDigest - Drop a t-digest and release its memory
+func freeDigest(this js.Value, args []js.Value) interface{} {
+	_, id, failure := digestArgument(args, "freeDigest", 1, "digestId")
+	if failure != nil {
+		return failure
+	}
+
+	delete(digests, id)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Freed t-digest %s\n", id)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"digestId": id,
+		"freed":    true,
+	})
+}
+
+// readColumn converts a typed array or an Array of numbers into a Go slice. null and undefined become NaN.
+func readColumn(value js.Value) ([]float64, error) {
+	if !isArrayLike(value) {
+		return nil, errors.New(localize("expected a Float64Array, another typed array or an array of numbers"))
+	}
+
+	if isTypedArray(value) {
+		if !value.InstanceOf(js.Global().Get("Float64Array")) {
+			value = js.Global().Get("Float64Array").Call("from", value)
+		}
+		raw := make([]byte, value.Get("byteLength").Int())
+		view := js.Global().Get("Uint8Array").New(value.Get("buffer"), value.Get("byteOffset"), value.Get("byteLength"))
+		js.CopyBytesToGo(raw, view)
+
+		column := make([]float64, len(raw)/8)
+		for i := range column {
+			column[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw[i*8:]))
+		}
+		return column, nil
+	}
+
+	length := value.Length()
+	column := make([]float64, length)
+	for i := 0; i < length; i++ {
+		item := value.Index(i)
+		switch item.Type() {
+		case js.TypeNumber:
+			column[i] = item.Float()
+		case js.TypeNull, js.TypeUndefined:
+			column[i] = math.NaN()
+		case js.TypeString:
+			v, err := strconv.ParseFloat(strings.TrimSpace(item.String()), 64)
+			if err != nil {
+				return nil, fmt.Errorf(localize("value %d is not a number: %q"), i, item.String())
+			}
+			column[i] = v
+		default:
+			return nil, fmt.Errorf(localize("value %d is not a number"), i)
+		}
+	}
+	return column, nil
+}
+
+// readKeys reads a group-by key column: strings, numbers or booleans. Missing keys (null, NaN) form their own group.
+func readKeys(value js.Value) ([]interface{}, error) {
+	if !isArrayLike(value) {
+		return nil, errors.New(localize("expected a typed array or an array of strings or numbers"))
+	}
+
+	if isTypedArray(value) {
+		column, err := readColumn(value)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]interface{}, len(column))
+		for i, v := range column {
+			if !math.IsNaN(v) {
+				keys[i] = v
+			}
+		}
+		return keys, nil
+	}
+
+	length := value.Length()
+	keys := make([]interface{}, length)
+	for i := 0; i < length; i++ {
+		item := value.Index(i)
+		switch item.Type() {
+		case js.TypeString:
+			keys[i] = item.String()
+		case js.TypeNumber:
+			if v := item.Float(); !math.IsNaN(v) {
+				keys[i] = v
+			}
+		case js.TypeBoolean:
+			keys[i] = item.Bool()
+		case js.TypeNull, js.TypeUndefined:
+		default:
+			return nil, fmt.Errorf(localize("key %d must be a string, a number or a boolean"), i)
+		}
+	}
+	return keys, nil
+}
+
+// readColumns reads named columns from an object of columns, or an array of columns named by index
+func readColumns(value js.Value) ([]string, [][]float64, error) {
+	if value.Type() != js.TypeObject {
+		return nil, nil, errors.New(localize("expected an object or an array of columns"))
+	}
+
+	if isTypedArray(value) {
+		column, err := readColumn(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []string{"value"}, [][]float64{column}, nil
+	}
+
+	var names []string
+	var items []js.Value
+	if isArrayLike(value) {
+		for i := 0; i < value.Length(); i++ {
+			names = append(names, strconv.Itoa(i))
+			items = append(items, value.Index(i))
+		}
+	} else {
+		keys := js.Global().Get("Object").Call("keys", value)
+		for i := 0; i < keys.Length(); i++ {
+			name := keys.Index(i).String()
+			names = append(names, name)
+			items = append(items, value.Get(name))
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil, errors.New(localize("at least 1 column is required"))
+	}
+
+	columns := make([][]float64, len(items))
+	for i, item := range items {
+		column, err := readColumn(item)
+		if err != nil {
+			return nil, nil, fmt.Errorf(localize("column %q: %v"), names[i], err)
+		}
+		columns[i] = column
+	}
+	return names, columns, nil
+}
+
+// isTypedArray reports whether value is a typed array view (but not a DataView)
+func isTypedArray(value js.Value) bool {
+	return value.Type() == js.TypeObject &&
+		js.Global().Get("ArrayBuffer").Call("isView", value).Bool() &&
+		!value.InstanceOf(js.Global().Get("DataView"))
+}
+
+// isArrayLike reports whether value is an Array or a typed array
+func isArrayLike(value js.Value) bool {
+	return value.Type() == js.TypeObject &&
+		(js.Global().Get("Array").Call("isArray", value).Bool() || isTypedArray(value))
+}
+
+// jsonArgument returns a JSON argument given either as a string or as a plain object
+func jsonArgument(value js.Value) string {
+	if value.Type() == js.TypeObject {
+		return js.Global().Get("JSON").Call("stringify", value).String()
+	}
+	return value.String()
+}
+
+// newFloat64Array copies a Go slice into a new JavaScript Float64Array
+func newFloat64Array(values []float64) js.Value {
+	raw := make([]byte, len(values)*8)
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(raw[i*8:], math.Float64bits(v))
+	}
+	bytes := js.Global().Get("Uint8Array").New(len(raw))
+	js.CopyBytesToJS(bytes, raw)
+	return js.Global().Get("Float64Array").New(bytes.Get("buffer"))
+}
+
+// presentValues returns the values of a column that are not NaN
+func presentValues(column []float64) []float64 {
+	values := make([]float64, 0, len(column))
+	for _, v := range column {
+		if !math.IsNaN(v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// meanVariance returns the mean and the sample variance (n - 1), computed with Welford's method
+func meanVariance(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	mean, m2 := 0.0, 0.0
+	for i, v := range values {
+		d := v - mean
+		mean += d / float64(i+1)
+		m2 += d * (v - mean)
+	}
+	if len(values) < 2 {
+		return mean, math.NaN()
+	}
+	return mean, m2 / float64(len(values)-1)
+}
+
+// quantileSorted interpolates linearly between the closest ranks (the default of R, NumPy and spreadsheets)
+func quantileSorted(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(pos-float64(lower))
+}
+
+// percentileKey names a percentile the way aggregations are written: 0.9 -> "p90", 0.999 -> "p99.9"
+func percentileKey(q float64) string {
+	return "p" + strconv.FormatFloat(math.Round(q*1e6)/1e4, 'f', -1, 64)
+}
+
+// aggregate computes a named aggregation over the present values of a group. With nil values
+// it only checks that the name is known.
+func aggregate(name string, values []float64) (float64, error) {
+	switch name {
+	case "count", "sum", "mean", "min", "max", "median", "std", "variance", "first", "last":
+	default:
+		if !strings.HasPrefix(name, "p") {
+			return 0, fmt.Errorf(localize("unknown aggregation %q"), name)
+		}
+		p, err := strconv.ParseFloat(name[1:], 64)
+		if err != nil || p < 0 || p > 100 {
+			return 0, fmt.Errorf(localize("unknown aggregation %q"), name)
+		}
+		if values == nil {
+			return 0, nil
+		}
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		return quantileSorted(sorted, p/100), nil
+	}
+	if values == nil {
+		return 0, nil
+	}
+
+	switch name {
+	case "count":
+		return float64(len(values)), nil
+	case "sum":
+		return sum(values), nil
+	}
+	if len(values) == 0 {
+		return math.NaN(), nil
+	}
+
+	switch name {
+	case "mean", "variance", "std":
+		mean, variance := meanVariance(values)
+		if name == "mean" {
+			return mean, nil
+		}
+		if name == "std" {
+			return math.Sqrt(variance), nil
+		}
+		return variance, nil
+	case "min", "max":
+		extreme := values[0]
+		for _, v := range values[1:] {
+			if (name == "min" && v < extreme) || (name == "max" && v > extreme) {
+				extreme = v
+			}
+		}
+		return extreme, nil
+	case "first":
+		return values[0], nil
+	case "last":
+		return values[len(values)-1], nil
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return quantileSorted(sorted, 0.5), nil
+}
+
+// keyLess orders group keys: missing first, then booleans, numbers and strings
+func keyLess(a, b interface{}) bool {
+	rank := func(key interface{}) int {
+		switch key.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		}
+		return 3
+	}
+	if rank(a) != rank(b) {
+		return rank(a) < rank(b)
+	}
+	switch a := a.(type) {
+	case bool:
+		return !a && b.(bool)
+	case float64:
+		return a < b.(float64)
+	case string:
+		return a < b.(string)
+	}
+	return false
+}
+
+// rollingWindow computes stat over each trailing window; positions with fewer than minPeriods present values are NaN
+func rollingWindow(column []float64, window, minPeriods int, stat string) ([]float64, error) {
+	switch stat {
+	case "mean", "sum", "count", "min", "max", "std", "variance", "median":
+	default:
+		return nil, fmt.Errorf(localize("unknown stat %q (use mean, sum, count, min, max, std, variance or median)"), stat)
+	}
+
+	result := make([]float64, len(column))
+	present := 0
+	total, mean, m2 := 0.0, 0.0, 0.0
+	var deque []int      // indices of candidate extremes for min/max
+	var sorted []float64 // window values in order, for median
+
+	for i, x := range column {
+		// Add the entering value
+		if !math.IsNaN(x) {
+			present++
+			total += x
+			d := x - mean
+			mean += d / float64(present)
+			m2 += d * (x - mean)
+
+			switch stat {
+			case "min", "max":
+				for len(deque) > 0 {
+					last := column[deque[len(deque)-1]]
+					if (stat == "min" && last < x) || (stat == "max" && last > x) {
+						break
+					}
+					deque = deque[:len(deque)-1]
+				}
+				deque = append(deque, i)
+			case "median":
+				at := sort.SearchFloat64s(sorted, x)
+				sorted = append(sorted, 0)
+				copy(sorted[at+1:], sorted[at:])
+				sorted[at] = x
+			}
+		}
+
+		// Remove the value leaving the window
+		if j := i - window; j >= 0 && !math.IsNaN(column[j]) {
+			y := column[j]
+			present--
+			total -= y
+			if present == 0 {
+				mean, m2 = 0, 0
+			} else {
+				d := y - mean
+				mean -= d / float64(present)
+				m2 -= d * (y - mean)
+			}
+			if len(deque) > 0 && deque[0] == j {
+				deque = deque[1:]
+			}
+			if stat == "median" {
+				at := sort.SearchFloat64s(sorted, y)
+				sorted = append(sorted[:at], sorted[at+1:]...)
+			}
+		}
+
+		if present < minPeriods {
+			result[i] = math.NaN()
+			continue
+		}
+
+		switch stat {
+		case "mean":
+			result[i] = mean
+		case "sum":
+			result[i] = total
+		case "count":
+			result[i] = float64(present)
+		case "min", "max":
+			result[i] = column[deque[0]]
+		case "variance", "std":
+			variance := math.NaN()
+			if present > 1 {
+				variance = math.Max(m2, 0) / float64(present-1)
+			}
+			if stat == "std" {
+				variance = math.Sqrt(variance)
+			}
+			result[i] = variance
+		case "median":
+			result[i] = quantileSorted(sorted, 0.5)
+		}
+	}
+
+	return result, nil
+}
+
+// completePairs keeps the rows where both columns have a value
+func completePairs(x, y []float64) ([]float64, []float64) {
+	px := make([]float64, 0, len(x))
+	py := make([]float64, 0, len(y))
+	for i := range x {
+		if !math.IsNaN(x[i]) && !math.IsNaN(y[i]) {
+			px = append(px, x[i])
+			py = append(py, y[i])
+		}
+	}
+	return px, py
+}
+
+// ranks replaces values by their rank (1-based), giving ties their average rank
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+
+	result := make([]float64, len(values))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && values[order[end]] == values[order[start]] {
+			end++
+		}
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			result[i] = rank
+		}
+		start = end
+	}
+	return result
+}
+
+// pearson returns the Pearson correlation coefficient, NaN when a column is constant or too short
+func pearson(x, y []float64) float64 {
+	if len(x) < 2 {
+		return math.NaN()
+	}
+	mx, _ := meanVariance(x)
+	my, _ := meanVariance(y)
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return math.NaN()
+	}
+	return math.Max(-1, math.Min(1, sxy/math.Sqrt(sxx*syy)))
+}
+
+// checkEdges validates explicit histogram edges
+func checkEdges(edges []float64) error {
+	if len(edges) < 2 || len(edges)-1 > maxHistogramBins {
+		return fmt.Errorf(localize("edges must define between 1 and %d bins"), maxHistogramBins)
+	}
+	for i, edge := range edges {
+		if math.IsNaN(edge) || math.IsInf(edge, 0) || (i > 0 && edge <= edges[i-1]) {
+			return errors.New(localize("edges must be finite and strictly increasing"))
+		}
+	}
+	return nil
+}
+
+// binCount picks the number of bins for a strategy, following the NumPy definitions
+func binCount(strategy string, values []float64, lo, hi float64) (int, error) {
+	n := float64(len(values))
+	sturges := func() int { return int(math.Ceil(math.Log2(n))) + 1 }
+
+	// width converts a bin width into a bin count over the range; 0 means the width is unusable
+	width := func(h float64) int {
+		if !(h > 0) {
+			return 0
+		}
+		return int(math.Ceil((hi - lo) / h))
+	}
+	scott := func() int {
+		mean, _ := meanVariance(values)
+		variance := 0.0
+		for _, v := range values {
+			variance += (v - mean) * (v - mean)
+		}
+		return width(math.Cbrt(24*math.Sqrt(math.Pi)/n) * math.Sqrt(variance/n))
+	}
+	fd := func() int {
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		return width(2 * (quantileSorted(sorted, 0.75) - quantileSorted(sorted, 0.25)) / math.Cbrt(n))
+	}
+
+	switch strategy {
+	case "sturges", "sqrt", "rice", "scott", "freedman-diaconis", "fd", "auto":
+	default:
+		return 0, fmt.Errorf(localize("unknown binning strategy %q (use sturges, sqrt, rice, scott, freedman-diaconis or auto)"), strategy)
+	}
+
+	var count int
+	switch {
+	case n == 0 || hi <= lo:
+		count = 1
+	case strategy == "sturges":
+		count = sturges()
+	case strategy == "sqrt":
+		count = int(math.Ceil(math.Sqrt(n)))
+	case strategy == "rice":
+		count = int(math.Ceil(2 * math.Cbrt(n)))
+	case strategy == "scott":
+		count = scott()
+	case strategy == "freedman-diaconis", strategy == "fd":
+		count = fd()
+	case strategy == "auto":
+		// The narrower of Sturges and Freedman-Diaconis, falling back to Sturges when the IQR is 0
+		count = sturges()
+		if bins := fd(); bins > count {
+			count = bins
+		}
+	}
+
+	if count < 1 {
+		count = 1
+	}
+	if count > maxHistogramBins {
+		count = maxHistogramBins
+	}
+	return count, nil
+}
+
+// uniformEdges splits [lo, hi] into count equal bins; an empty range is widened by 0.5 on each side
+func uniformEdges(lo, hi float64, count int) []float64 {
+	if lo == hi {
+		lo, hi = lo-0.5, hi+0.5
+	}
+	edges := make([]float64, count+1)
+	for i := range edges {
+		edges[i] = lo + (hi-lo)*float64(i)/float64(count)
+	}
+	edges[count] = hi
+	return edges
+}
+
+// digestArgument resolves the digest handle of args[0] after checking the argument count
+func digestArgument(args []js.Value, function string, count int, names string) (*tdigest, string, interface{}) {
+	if len(args) < count {
+		message := localize("%s requires at least %d arguments (%s)", function, count, names)
+		if count == 1 {
+			message = localize("%s requires at least 1 argument (%s)", function, names)
+		}
+		return nil, "", js.ValueOf(map[string]interface{}{"error": message})
+	}
+	id := args[0].String()
+	d, ok := digests[id]
+	if !ok {
+		return nil, "", js.ValueOf(map[string]interface{}{
+			"error": localize("Unknown digest %q (it may already be freed)", id),
+		})
+	}
+	return d, id, nil
+}
+
+func newTDigest(compression float64) *tdigest {
+	return &tdigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// add buffers a sample; the buffer is merged into the centroids once it holds a few times the compression
+func (d *tdigest) add(x, weight float64) {
+	d.buffer = append(d.buffer, centroid{Mean: x, Weight: weight})
+	d.count += weight
+	d.min = math.Min(d.min, x)
+	d.max = math.Max(d.max, x)
+	if len(d.buffer) >= int(5*d.compression) {
+		d.compress()
+	}
+}
+
+// compress merges the buffered samples into the centroids in a single sorted pass
+func (d *tdigest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+
+	all := append(append(make([]centroid, 0, len(d.centroids)+len(d.buffer)), d.centroids...), d.buffer...)
+	sort.Slice(all, func(a, b int) bool { return all[a].Mean < all[b].Mean })
+	d.buffer = d.buffer[:0]
+
+	merged := []centroid{all[0]}
+	done := 0.0
+	limit := d.count * d.quantileLimit(0)
+	for _, c := range all[1:] {
+		last := &merged[len(merged)-1]
+		if done+last.Weight+c.Weight <= limit {
+			last.Weight += c.Weight
+			last.Mean += (c.Mean - last.Mean) * c.Weight / last.Weight
+			continue
+		}
+		done += last.Weight
+		limit = d.count * d.quantileLimit(done/d.count)
+		merged = append(merged, c)
+	}
+	d.centroids = merged
+}
+
+// quantileLimit is the highest quantile a centroid starting at q may reach: one unit of the
+// k1 scale function k(q) = compression / 2π · asin(2q - 1)
+func (d *tdigest) quantileLimit(q float64) float64 {
+	k := d.compression/(2*math.Pi)*math.Asin(2*q-1) + 1
+	angle := 2 * math.Pi * k / d.compression
+	if angle >= math.Pi/2 {
+		return 1
+	}
+	return (math.Sin(angle) + 1) / 2
+}
+
+// midpoints returns the cumulative weight at the center of each centroid
+func (d *tdigest) midpoints() []float64 {
+	mids := make([]float64, len(d.centroids))
+	cumulative := 0.0
+	for i, c := range d.centroids {
+		mids[i] = cumulative + c.Weight/2
+		cumulative += c.Weight
+	}
+	return mids
+}
+
+// quantile interpolates between centroid centers, and towards the exact min and max at the ends
+func (d *tdigest) quantile(q float64) float64 {
+	d.compress()
+	if d.count == 0 {
+		return math.NaN()
+	}
+	if len(d.centroids) == 1 || q <= 0 {
+		if q <= 0 {
+			return d.min
+		}
+		return d.min + (d.max-d.min)*q
+	}
+	if q >= 1 {
+		return d.max
+	}
+
+	index := q * d.count
+	mids := d.midpoints()
+	first, last := d.centroids[0], d.centroids[len(d.centroids)-1]
+	if index <= mids[0] {
+		return d.min + (first.Mean-d.min)*index/mids[0]
+	}
+	if end := mids[len(mids)-1]; index >= end {
+		return last.Mean + (d.max-last.Mean)*(index-end)/(d.count-end)
+	}
+
+	i := sort.SearchFloat64s(mids, index) - 1
+	if i < 0 {
+		i = 0
+	}
+	lo, hi := d.centroids[i], d.centroids[i+1]
+	return lo.Mean + (hi.Mean-lo.Mean)*(index-mids[i])/(mids[i+1]-mids[i])
+}
+
+// cdf is the inverse of quantile: the estimated fraction of samples at or below x
+func (d *tdigest) cdf(x float64) float64 {
+	d.compress()
+	switch {
+	case d.count == 0 || math.IsNaN(x):
+		return math.NaN()
+	case x < d.min:
+		return 0
+	case x >= d.max:
+		return 1
+	case len(d.centroids) == 1:
+		return (x - d.min) / (d.max - d.min)
+	}
+
+	mids := d.midpoints()
+	first, last := d.centroids[0], d.centroids[len(d.centroids)-1]
+	if x < first.Mean {
+		return (x - d.min) / (first.Mean - d.min) * mids[0] / d.count
+	}
+	if x >= last.Mean {
+		end := mids[len(mids)-1]
+		return (end + (x-last.Mean)/(d.max-last.Mean)*(d.count-end)) / d.count
+	}
+
+	i := sort.Search(len(d.centroids), func(i int) bool { return d.centroids[i].Mean > x }) - 1
+	lo, hi := d.centroids[i], d.centroids[i+1]
+	if hi.Mean == lo.Mean {
+		return mids[i+1] / d.count
+	}
+	return (mids[i] + (x-lo.Mean)/(hi.Mean-lo.Mean)*(mids[i+1]-mids[i])) / d.count
+}
+
+// merge adds the centroids of another digest, as if its samples had been added one by one
+func (d *tdigest) merge(other *tdigest) {
+	other.compress()
+	if other.count == 0 {
+		return
+	}
+	centroids := append([]centroid(nil), other.centroids...)
+	for _, c := range centroids {
+		d.buffer = append(d.buffer, c)
+		d.count += c.Weight
+	}
+	d.min = math.Min(d.min, other.min)
+	d.max = math.Max(d.max, other.max)
+	d.compress()
+}
+
+// info describes a digest for JavaScript; min and max are null while it is empty
+func (d *tdigest) info(id string, added int) map[string]interface{} {
+	d.compress()
+	info := map[string]interface{}{
+		"digestId":    id,
+		"count":       d.count,
+		"centroids":   len(d.centroids),
+		"compression": d.compression,
+		"added":       added,
+		"min":         nil,
+		"max":         nil,
+	}
+	if d.count > 0 {
+		info["min"], info["max"] = d.min, d.max
+	}
+	return info
+}
+
+// parseDigest reads and checks a digest written by exportDigest
+func parseDigest(data string) (*tdigest, error) {
+	var file DigestFile
+	if err := json.Unmarshal([]byte(data), &file); err != nil {
+		return nil, err
+	}
+	if file.Compression < minCompression || file.Compression > maxCompression {
+		return nil, fmt.Errorf(localize("compression must be between %d and %d"), minCompression, maxCompression)
+	}
+
+	d := newTDigest(file.Compression)
+	for i, pair := range file.Centroids {
+		if len(pair) != 2 || math.IsNaN(pair[0]) || !(pair[1] > 0) || pair[0] < file.Min || pair[0] > file.Max {
+			return nil, fmt.Errorf(localize("centroid %d must be [mean, weight] with a positive weight within [min, max]"), i)
+		}
+		d.centroids = append(d.centroids, centroid{Mean: pair[0], Weight: pair[1]})
+		d.count += pair[1]
+	}
+	sort.Slice(d.centroids, func(a, b int) bool { return d.centroids[a].Mean < d.centroids[b].Mean })
+	if d.count > 0 {
+		d.min, d.max = file.Min, file.Max
+	}
+	return d, nil
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"typed-array-columns",
+	"group-by",
+	"rolling-windows",
+	"correlation-matrix",
+	"histogram-binning",
+	"t-digest",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	centroids := 0
+	for _, d := range digests {
+		centroids += len(d.centroids) + len(d.buffer)
+	}
+	return js.ValueOf(memoryStats(map[string]interface{}{
+		"digests":   len(digests),
+		"centroids": centroids,
+	}))
+}
+
+// releaseResources - Free every t-digest and return freed memory to the runtime
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{
+		"digests": len(digests),
+	}
+	digests = map[string]*tdigest{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "stats-wasm",
+		"version":         moduleVersion,
+		"description":     "Descriptive analytics over typed-array columns module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"describe",
+		"groupBy",
+		"rolling",
+		"correlationMatrix",
+		"histogram",
+		"createDigest",
+		"digestAdd",
+		"digestQuantiles",
+		"digestCDF",
+		"mergeDigests",
+		"exportDigest",
+		"importDigest",
+		"freeDigest",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register column analytics functions
+	js.Global().Set("describe", js.FuncOf(describe))
+	js.Global().Set("groupBy", js.FuncOf(groupBy))
+	js.Global().Set("rolling", js.FuncOf(rolling))
+	js.Global().Set("correlationMatrix", js.FuncOf(correlationMatrix))
+	js.Global().Set("histogram", js.FuncOf(histogram))
+
+	// Register t-digest functions
+	js.Global().Set("createDigest", js.FuncOf(createDigest))
+	js.Global().Set("digestAdd", js.FuncOf(digestAdd))
+	js.Global().Set("digestQuantiles", js.FuncOf(digestQuantiles))
+	js.Global().Set("digestCDF", js.FuncOf(digestCDF))
+	js.Global().Set("mergeDigests", js.FuncOf(mergeDigests))
+	js.Global().Set("exportDigest", js.FuncOf(exportDigest))
+	js.Global().Set("importDigest", js.FuncOf(importDigest))
+	js.Global().Set("freeDigest", js.FuncOf(freeDigest))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM Stats module ready!")
+	fmt.Println("Available functions: describe, groupBy, rolling, correlationMatrix, histogram, createDigest, digestQuantiles")
+
+	// Keep the program alive
+	select {}
+}
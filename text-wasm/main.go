@@ -9,8 +9,12 @@ import (
 	"math/big"
 	"net/mail"
 	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"syscall/js"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -50,6 +54,143 @@ var diacriticsMap = map[rune]rune{
 	'Ç': 'C', 'ç': 'c',
 }
 
+// Latin letters that expand to several ASCII letters instead of losing their accent
+var latinLigatures = map[rune]string{
+	'œ': "oe", 'Œ': "OE",
+	'æ': "ae", 'Æ': "AE",
+	'ß': "ss",
+	'ł': "l", 'Ł': "L",
+	'đ': "dj", 'Đ': "Dj",
+	'ð': "d", 'Ð': "D",
+	'þ': "th", 'Þ': "Th",
+}
+
+// transliterationLanguages are the accepted language selectors, "auto" picks the table from the script
+var transliterationLanguages = []string{"auto", "ru", "uk", "bg", "sr", "el", "zh"}
+
+// Cyrillic romanization, Russian by default (BGN/PCGN style digraphs) plus the letters of the other Cyrillic alphabets
+var cyrillicLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u", 'ђ': "dj", 'ј': "j",
+	'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz", 'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+}
+
+// cyrillicLanguages overrides the default Cyrillic table with each national system
+var cyrillicLanguages = map[string]map[rune]string{
+	// Ukrainian national system (2010)
+	"uk": {
+		'г': "h", 'и': "y", 'й': "i", 'х': "kh", 'ї': "i", 'є': "ie", 'ю': "iu", 'я': "ia", 'ь': "",
+	},
+	// Bulgarian streamlined system (2009)
+	"bg": {
+		'х': "h", 'щ': "sht", 'ъ': "a", 'ь': "y",
+	},
+	// Serbian Latin alphabet (Gajica) without its diacritics
+	"sr": {
+		'ж': "z", 'х': "h", 'ц': "c", 'ч': "c", 'ш': "s", 'ћ': "c", 'ђ': "dj",
+	},
+}
+
+// ukrainianInitials are the Ukrainian letters romanized differently at the start of a word
+var ukrainianInitials = map[rune]string{
+	'є': "ye", 'ї': "yi", 'й': "y", 'ю': "yu", 'я': "ya",
+}
+
+// Greek romanization (ELOT 743), accents and diaeresis are folded by greekBase first
+var greekLatin = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+}
+
+// greekBase maps accented Greek vowels to their plain letter
+var greekBase = map[rune]rune{
+	'ά': 'α', 'έ': 'ε', 'ή': 'η', 'ί': 'ι', 'ό': 'ο', 'ύ': 'υ', 'ώ': 'ω',
+	'ϊ': 'ι', 'ΐ': 'ι', 'ϋ': 'υ', 'ΰ': 'υ',
+}
+
+// pinyinSyllables lists common Chinese characters by toneless pinyin syllable, rarer characters are kept as is
+var pinyinSyllables = []string{
+	"a 阿啊", "ai 爱哀挨矮艾碍癌唉埃", "an 安按案暗岸俺鞍庵", "ang 昂肮", "ao 奥傲澳熬袄凹", "ba 八把爸吧巴拔罢霸坝芭扒疤",
+	"bai 白百摆败拜佰", "ban 办半班般板版搬伴扮拌斑颁", "bang 帮邦棒榜绑膀傍磅", "bao 包报保宝抱饱暴爆堡胞豹鲍", "bei 北被背备杯悲贝辈倍碑卑",
+	"ben 本奔笨", "beng 崩蹦泵", "bi 比必笔闭币避鼻彼毕碧壁逼臂弊", "bian 边变便遍编辩鞭扁贬", "biao 表标彪", "bie 别憋",
+	"bin 宾滨彬斌", "bing 并病兵冰饼丙柄", "bo 波博播伯拨薄玻泊勃脖搏驳", "bu 不部步布补捕埠", "ca 擦", "cai 才采菜财材彩猜裁蔡",
+	"can 参餐残惨灿蚕", "cang 仓藏苍舱", "cao 草操曹槽", "ce 策测侧册厕", "ceng 层曾蹭", "cha 查察茶差插叉", "chai 拆柴",
+	"chan 产缠铲颤蝉", "chang 长场常厂唱肠尝畅昌", "chao 超朝潮炒吵抄", "che 车彻撤扯", "chen 陈沉晨尘衬臣",
+	"cheng 成城程称承乘诚呈橙撑", "chi 吃持池迟尺齿赤翅驰", "chong 充冲虫崇", "chou 抽仇愁丑臭", "chu 出处初除楚础储触厨",
+	"chuan 传船穿川串", "chuang 窗床创闯", "chui 吹垂锤", "chun 春纯唇", "ci 此次词刺辞慈磁", "cong 从聪丛葱", "cou 凑",
+	"cu 粗促醋", "cui 催脆翠崔", "cun 村存寸", "cuo 错措", "da 大打达答搭", "dai 代带待戴袋贷", "dan 单但担淡蛋弹丹胆",
+	"dang 当党档", "dao 到道导倒刀岛盗稻", "de 的得德", "deng 等灯登邓", "di 地第低底弟帝敌递滴", "dian 点电店典殿垫",
+	"diao 调掉钓雕", "die 跌叠蝶", "ding 定顶订丁钉", "diu 丢", "dong 东动懂冬洞董", "dou 斗豆抖", "du 度都读独毒督渡杜肚",
+	"duan 段短断端", "dui 对队堆", "dun 顿吨蹲", "duo 多夺朵", "e 饿额俄恶鹅", "en 恩", "er 而二儿耳尔", "fa 发法罚乏",
+	"fan 反饭范翻犯凡烦返", "fang 方放房防访仿芳", "fei 非飞费肥废", "fen 分份粉奋纷愤", "feng 风封丰峰疯锋冯", "fo 佛",
+	"fou 否", "fu 父服府复福夫负富副付符扶浮妇附", "gai 该改概盖", "gan 感干敢赶甘肝杆", "gang 刚钢港岗纲", "gao 高告搞稿",
+	"ge 个各歌哥格革割隔", "gei 给", "gen 根跟", "geng 更耕", "gong 工公共功供攻宫", "gou 够狗构购沟", "gu 古故顾鼓骨谷股",
+	"gua 挂瓜刮", "guai 怪拐", "guan 关观管官馆惯冠", "guang 光广", "gui 贵规鬼归柜", "gun 滚", "guo 国过果锅郭",
+	"ha 哈", "hai 还海孩害", "han 汉寒喊含韩汗", "hang 航", "hao 好号毫豪", "he 和合河何喝盒贺", "hei 黑", "hen 很恨狠",
+	"heng 横衡恒", "hong 红洪宏", "hou 后候厚猴", "hu 湖户呼护胡虎互忽", "hua 话花化画华划", "huai 坏怀", "huan 换欢环缓",
+	"huang 黄皇慌", "hui 会回灰挥汇", "hun 婚混魂", "huo 或活火获货", "ji 机几记己及级即极技基击集急计济继际鸡积纪",
+	"jia 家加价假架甲佳", "jian 见间件建简坚健检减剑", "jiang 将江讲降奖", "jiao 交教叫脚角较郊焦", "jie 接结界解姐节街介借",
+	"jin 进今金近紧仅尽劲", "jing 经京精静境竟警井", "jiu 就九久酒旧救究", "ju 局举具据居巨聚剧", "juan 卷", "jue 觉决绝",
+	"jun 军君均", "ka 卡", "kai 开", "kan 看", "kang 康抗", "kao 考靠", "ke 可科课客克刻", "ken 肯",
+	"kong 空控孔恐", "kou 口", "ku 苦哭库", "kua 夸跨", "kuai 快块", "kuan 宽款", "kuang 况狂矿", "kun 困",
+	"kuo 扩阔", "la 拉啦", "lai 来", "lan 兰蓝篮烂", "lang 浪狼", "lao 老劳", "le 了乐", "lei 类泪累雷",
+	"leng 冷", "li 里理力利李立离例礼历丽", "lian 连联脸练", "liang 两量亮良凉", "liao 料聊", "lie 列烈", "lin 林临",
+	"ling 领另零灵", "liu 六流留刘", "long 龙", "lou 楼", "lu 路陆录鲁露", "lü 绿旅律率", "luan 乱", "lun 论",
+	"luo 落罗", "ma 妈马吗麻", "mai 买卖", "man 满慢", "mang 忙", "mao 毛猫", "me 么", "mei 没每美妹", "men 们门",
+	"meng 梦", "mi 米密", "mian 面", "miao 秒", "min 民", "ming 名明命", "mo 末模", "mou 某", "mu 目母木",
+	"na 那拿", "nai 奶", "nan 南男难", "nao 脑", "ne 呢", "nei 内", "neng 能", "ni 你泥", "nian 年念",
+	"niang 娘", "niao 鸟", "nin 您", "ning 宁", "niu 牛", "nong 农", "nu 努", "nü 女", "nuan 暖",
+	"pa 怕爬", "pai 派排", "pan 盘判", "pang 旁", "pao 跑", "pei 配", "peng 朋", "pi 皮批", "pian 片篇",
+	"piao 票", "pin 品", "ping 平评", "po 破", "pu 普", "qi 其起期气七汽奇", "qian 前钱千", "qiang 强",
+	"qiao 桥", "qie 且", "qin 亲", "qing 情请清青", "qiong 穷", "qiu 求球秋", "qu 去区取", "quan 全权",
+	"que 却确", "qun 群", "ran 然", "rang 让", "re 热", "ren 人认任", "ri 日", "rong 容", "rou 肉",
+	"ru 如入", "ruan 软", "ruo 若弱", "san 三", "se 色", "sen 森", "sha 杀沙", "shan 山", "shang 上商",
+	"shao 少", "she 社设", "shen 身深神什", "sheng 生声省胜", "shi 是时事十市式实使世师识始史", "shou 手收受首",
+	"shu 书数树术属", "shuang 双", "shui 水谁", "shuo 说", "si 四思死司", "song 送", "su 苏速", "suan 算",
+	"sui 岁虽", "sun 孙", "suo 所", "ta 他她它", "tai 太台", "tan 谈", "tang 堂", "tao 讨", "te 特",
+	"ti 体题提", "tian 天田", "tiao 条", "tie 铁", "ting 听停", "tong 同通", "tou 头", "tu 图土", "tuan 团",
+	"tui 推", "wai 外", "wan 完万晚玩", "wang 王往网望", "wei 为位未委维", "wen 文问", "wo 我", "wu 无五物务",
+	"xi 西系喜习息", "xia 下夏", "xian 先现县线", "xiang 想向相香", "xiao 小笑校", "xie 些写谢", "xin 心新信",
+	"xing 行性星姓", "xiong 兄", "xiu 修", "xu 需许", "xuan 选", "xue 学雪", "ya 呀压", "yan 言眼研",
+	"yang 样阳", "yao 要", "ye 也业夜", "yi 一以已意", "yin 因音", "ying 应英迎影营", "yong 用", "you 有又由",
+	"yu 与于语", "yuan 员元", "yue 月越", "yun 运云", "za 杂", "zai 在再", "zan 咱", "zao 早", "ze 则",
+	"zen 怎", "zhan 站", "zhao 找", "zhe 这者", "zhen 真", "zheng 正政", "zhi 之知只", "zhong 中重种",
+	"zhou 周", "zhu 主住", "zhuan 专", "zhuang 装", "zhun 准", "zi 子自字", "zong 总", "zou 走", "zu 组足",
+	"zui 最", "zuo 做作",
+}
+
+// pinyinTable indexes pinyinSyllables by character, built on first use
+var pinyinTable map[rune]string
+
+// sentenceLanguages are the accepted abbreviation sets for splitSentences
+var sentenceLanguages = []string{"en", "fr", "de", "es"}
+
+// sentenceAbbreviations lists, by language, the lowercase abbreviations whose period never ends a sentence
+var sentenceAbbreviations = map[string][]string{
+	"en": {"mr", "mrs", "ms", "dr", "prof", "sr", "st", "mt", "vs", "e.g", "i.e", "cf", "u.s", "u.k", "u.n", "a.m", "p.m",
+		"no", "nos", "fig", "vol", "pp", "approx", "dept", "gen", "gov", "sen", "rep", "rev", "capt", "lt", "col", "sgt",
+		"jan", "feb", "mar", "apr", "jun", "jul", "aug", "sep", "sept", "oct", "nov", "dec"},
+	"fr": {"m", "mm", "mme", "mmes", "mlle", "mlles", "dr", "pr", "me", "st", "ste", "cf", "p.ex", "ex", "env", "av", "bd",
+		"n", "no", "vol", "chap", "fig", "janv", "févr", "avr", "juil", "sept", "oct", "nov", "déc"},
+	"de": {"hr", "hrn", "fr", "dr", "prof", "st", "z.b", "d.h", "u.a", "v.a", "o.ä", "s.o", "s.u", "vgl", "bzw", "ca", "evtl",
+		"ggf", "nr", "str", "abs", "bd", "jan", "feb", "febr", "okt", "dez"},
+	"es": {"sr", "sra", "srta", "sres", "dr", "dra", "prof", "ud", "uds", "d", "dña", "p.ej", "vs", "núm", "pág", "cap",
+		"av", "avda", "ene", "feb", "abr", "ago", "sept", "oct", "dic"},
+}
+
+// sentenceFinalAbbreviations may close a sentence, so they only split before a capitalized word
+var sentenceFinalAbbreviations = map[string][]string{
+	"en": {"etc", "inc", "ltd", "co", "corp", "jr"},
+	"fr": {"etc", "cie"},
+	"de": {"usw", "etc", "bzw", "gmbh"},
+	"es": {"etc", "cía"},
+}
+
 // setSilentMode enables/disables silent mode for console logs
 func setSilentMode(this js.Value, args []js.Value) interface{} {
 	if len(args) == 1 {
@@ -58,10 +199,86 @@ func setSilentMode(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(silentMode)
 }
 
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"Error: two arguments required for %s":           "Erreur: deux arguments requis pour %s",
+	"Error: one argument required for %s":            "Erreur: un argument requis pour %s",
+	"Error: one or two arguments required for %s":    "Erreur: un ou deux arguments requis pour %s",
+	"Error: unsupported language %q (available: %s)": "Erreur: langue %q non prise en charge (disponibles: %s)",
+	"Invalid email format":                           "Format d'e-mail invalide",
+	"setLocale requires exactly 1 argument (locale)": "setLocale requiert exactement 1 argument (locale)",
+	"Unsupported locale %q (available: %s)":          "Langue %q non prise en charge (disponibles: %s)",
+}
+
 // textSimilarity calculates similarity between two texts using Jaro-Winkler distance
 func textSimilarity(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for textSimilarity")
+		return js.ValueOf(localize("Error: two arguments required for %s", "textSimilarity"))
 	}
 
 	s1 := args[0].String()
@@ -92,7 +309,7 @@ func textSimilarity(this js.Value, args []js.Value) interface{} {
 // levenshteinDistance calculates the Levenshtein distance between two strings
 func levenshteinDistance(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
-		return js.ValueOf("Error: two arguments required for levenshteinDistance")
+		return js.ValueOf(localize("Error: two arguments required for %s", "levenshteinDistance"))
 	}
 
 	s1 := args[0].String()
@@ -149,7 +366,7 @@ func levenshteinDistance(this js.Value, args []js.Value) interface{} {
 // soundex generates Soundex code for phonetic matching
 func soundex(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for soundex")
+		return js.ValueOf(localize("Error: one argument required for %s", "soundex"))
 	}
 
 	str := strings.ToUpper(args[0].String())
@@ -184,16 +401,21 @@ func soundex(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(result)
 }
 
-// slugify converts a string to a URL-friendly slug
+// slugify converts a string to a URL-friendly slug, romanizing non-Latin scripts first
 func slugify(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for slugify")
+	if len(args) < 1 || len(args) > 2 {
+		return js.ValueOf(localize("Error: one or two arguments required for %s", "slugify"))
+	}
+
+	language, err := transliterationLanguage(args, 1)
+	if err != "" {
+		return js.ValueOf(err)
 	}
 
 	str := args[0].String()
 
-	// Remove diacritics
-	str = removeDiacriticsFromString(str)
+	// Romanize and remove diacritics
+	str = transliterateString(str, language)
 
 	// Convert to lowercase
 	str = strings.ToLower(str)
@@ -215,7 +437,7 @@ func slugify(this js.Value, args []js.Value) interface{} {
 // camelCase converts string to camelCase
 func camelCase(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for camelCase")
+		return js.ValueOf(localize("Error: one argument required for %s", "camelCase"))
 	}
 
 	str := args[0].String()
@@ -245,7 +467,7 @@ func camelCase(this js.Value, args []js.Value) interface{} {
 // kebabCase converts string to kebab-case
 func kebabCase(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for kebabCase")
+		return js.ValueOf(localize("Error: one argument required for %s", "kebabCase"))
 	}
 
 	str := args[0].String()
@@ -271,7 +493,7 @@ func kebabCase(this js.Value, args []js.Value) interface{} {
 // snakeCase converts string to snake_case
 func snakeCase(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for snakeCase")
+		return js.ValueOf(localize("Error: one argument required for %s", "snakeCase"))
 	}
 
 	str := args[0].String()
@@ -297,7 +519,7 @@ func snakeCase(this js.Value, args []js.Value) interface{} {
 // extractEmails finds all email addresses in the text
 func extractEmails(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for extractEmails")
+		return js.ValueOf(localize("Error: one argument required for %s", "extractEmails"))
 	}
 
 	text := args[0].String()
@@ -321,7 +543,7 @@ func extractEmails(this js.Value, args []js.Value) interface{} {
 // extractURLs finds all URLs in the text
 func extractURLs(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for extractURLs")
+		return js.ValueOf(localize("Error: one argument required for %s", "extractURLs"))
 	}
 
 	text := args[0].String()
@@ -342,7 +564,7 @@ func extractURLs(this js.Value, args []js.Value) interface{} {
 // extractPhoneNumbers finds all phone numbers in the text
 func extractPhoneNumbers(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for extractPhoneNumbers")
+		return js.ValueOf(localize("Error: one argument required for %s", "extractPhoneNumbers"))
 	}
 
 	text := args[0].String()
@@ -363,7 +585,7 @@ func extractPhoneNumbers(this js.Value, args []js.Value) interface{} {
 // wordCount counts words in the text
 func wordCount(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for wordCount")
+		return js.ValueOf(localize("Error: one argument required for %s", "wordCount"))
 	}
 
 	text := args[0].String()
@@ -380,7 +602,7 @@ func wordCount(this js.Value, args []js.Value) interface{} {
 // characterCount counts characters in the text
 func characterCount(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for characterCount")
+		return js.ValueOf(localize("Error: one argument required for %s", "characterCount"))
 	}
 
 	text := args[0].String()
@@ -406,7 +628,7 @@ func characterCount(this js.Value, args []js.Value) interface{} {
 // readingTime estimates reading time in minutes
 func readingTime(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 || len(args) > 2 {
-		return js.ValueOf("Error: one or two arguments required for readingTime")
+		return js.ValueOf(localize("Error: one or two arguments required for %s", "readingTime"))
 	}
 
 	text := args[0].String()
@@ -438,10 +660,41 @@ func readingTime(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(result)
 }
 
+// splitSentences splits text into sentences, keeping abbreviations (Dr., e.g., U.S.),
+// decimal numbers and quoted speech inside the sentence they belong to
+func splitSentences(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || len(args) > 2 {
+		return js.ValueOf(localize("Error: one or two arguments required for %s", "splitSentences"))
+	}
+
+	language := "en"
+	if len(args) == 2 && !args[1].IsUndefined() && !args[1].IsNull() {
+		language = strings.ToLower(args[1].String())
+		if i := strings.IndexAny(language, "-_"); i >= 0 {
+			language = language[:i]
+		}
+		if _, ok := sentenceAbbreviations[language]; !ok {
+			return js.ValueOf(localize("Error: unsupported language %q (available: %s)", args[1].String(), strings.Join(sentenceLanguages, ", ")))
+		}
+	}
+
+	text := args[0].String()
+	sentences := []interface{}{}
+	for _, sentence := range splitSentenceText(text, language) {
+		sentences = append(sentences, sentence)
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Split text into %d sentences\n", len(sentences))
+	}
+
+	return js.ValueOf(sentences)
+}
+
 // removeDiacritics removes accents and diacritics from text
 func removeDiacritics(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for removeDiacritics")
+		return js.ValueOf(localize("Error: one argument required for %s", "removeDiacritics"))
 	}
 
 	text := args[0].String()
@@ -454,28 +707,20 @@ func removeDiacritics(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(result)
 }
 
-// transliterate converts text to ASCII equivalent
+// transliterate converts text to ASCII, romanizing Cyrillic, Greek and Chinese
+// with the tables of the optional language ("auto" by default)
 func transliterate(this js.Value, args []js.Value) interface{} {
-	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for transliterate")
+	if len(args) < 1 || len(args) > 2 {
+		return js.ValueOf(localize("Error: one or two arguments required for %s", "transliterate"))
 	}
 
-	text := args[0].String()
-
-	// First remove diacritics
-	result := removeDiacriticsFromString(text)
-
-	// Additional transliterations
-	transliterations := map[string]string{
-		"œ": "oe", "Œ": "OE",
-		"æ": "ae", "Æ": "AE",
-		"ß": "ss",
-		"ł": "l", "Ł": "L",
+	language, err := transliterationLanguage(args, 1)
+	if err != "" {
+		return js.ValueOf(err)
 	}
 
-	for from, to := range transliterations {
-		result = strings.ReplaceAll(result, from, to)
-	}
+	text := args[0].String()
+	result := transliterateString(text, language)
 
 	if !silentMode {
 		fmt.Printf("Go WASM: Transliterated '%s' -> '%s'\n", text, result)
@@ -530,7 +775,7 @@ func generatePassword(this js.Value, args []js.Value) interface{} {
 // validateEmail validates email format
 func validateEmail(this js.Value, args []js.Value) interface{} {
 	if len(args) != 1 {
-		return js.ValueOf("Error: one argument required for validateEmail")
+		return js.ValueOf(localize("Error: one argument required for %s", "validateEmail"))
 	}
 
 	email := args[0].String()
@@ -539,7 +784,7 @@ func validateEmail(this js.Value, args []js.Value) interface{} {
 	if !emailRegex.MatchString(email) {
 		return js.ValueOf(map[string]interface{}{
 			"valid": false,
-			"error": "Invalid email format",
+			"error": localize("Invalid email format"),
 		})
 	}
 
@@ -567,6 +812,318 @@ func validateEmail(this js.Value, args []js.Value) interface{} {
 
 // Helper functions
 
+// splitSentenceText cuts text after terminal punctuation followed by whitespace, or at blank lines
+func splitSentenceText(text, language string) []string {
+	runes := []rune(text)
+	sentences := []string{}
+	start := 0
+
+	flush := func(end int) {
+		if sentence := strings.TrimSpace(string(runes[start:end])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = end
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		// A blank line closes the paragraph even without punctuation
+		if r == '\n' {
+			j := i + 1
+			for j < len(runes) && runes[j] != '\n' && unicode.IsSpace(runes[j]) {
+				j++
+			}
+			if j < len(runes) && runes[j] == '\n' {
+				flush(i)
+			}
+			continue
+		}
+		if !strings.ContainsRune(".!?…。！？", r) {
+			continue
+		}
+
+		// Swallow "?!", ellipses and the closing quotes or brackets of quoted speech
+		end := i + 1
+		for end < len(runes) && strings.ContainsRune(".!?…。！？\"'”’»)]」』", runes[end]) {
+			end++
+		}
+
+		// CJK full stops need no space after them
+		if strings.ContainsRune("。！？", r) {
+			flush(end)
+			i = end - 1
+			continue
+		}
+
+		// 3.14, example.com or the inner dots of U.S.
+		if end < len(runes) && !unicode.IsSpace(runes[end]) {
+			i = end - 1
+			continue
+		}
+
+		if sentenceBoundary(runes, i, end, language) {
+			flush(end)
+		}
+		i = end - 1
+	}
+	flush(len(runes))
+
+	return sentences
+}
+
+// sentenceBoundary decides whether the terminator at runes[i:end] closes the sentence
+func sentenceBoundary(runes []rune, i, end int, language string) bool {
+	// The next word, past whitespace and opening quotes
+	next := rune(0)
+	for j := end; j < len(runes); j++ {
+		if !unicode.IsSpace(runes[j]) && !strings.ContainsRune("\"'“‘«„([¿¡", runes[j]) {
+			next = runes[j]
+			break
+		}
+	}
+
+	// Quoted speech and ellipses carry on when the next word is lowercase: "Stop!" she said.
+	if unicode.IsLower(next) {
+		return false
+	}
+	if runes[i] != '.' || (i+1 < end && runes[i+1] == '.') {
+		return true
+	}
+
+	k := i
+	for k > 0 && (unicode.IsLetter(runes[k-1]) || unicode.IsDigit(runes[k-1]) || runes[k-1] == '.') {
+		k--
+	}
+	token := strings.ToLower(strings.TrimLeft(string(runes[k:i]), "."))
+
+	switch {
+	case token == "":
+		return true
+	case containsString(sentenceFinalAbbreviations[language], token):
+		return true
+	case containsString(sentenceAbbreviations[language], token):
+		return false
+	case utf8.RuneCountInString(token) == 1 && unicode.IsLetter([]rune(token)[0]):
+		// An initial, as in J. R. R. Tolkien
+		return false
+	case strings.Contains(token, "."):
+		// Dotted acronyms such as U.S.A. or Ph.D.
+		return false
+	case language == "de" && strings.Trim(token, "0123456789") == "":
+		// German ordinals: am 3. Oktober
+		return false
+	}
+	return true
+}
+
+// containsString reports whether list holds value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// transliterationLanguage reads the optional language selector at index
+func transliterationLanguage(args []js.Value, index int) (string, string) {
+	if len(args) <= index || args[index].IsUndefined() || args[index].IsNull() {
+		return "auto", ""
+	}
+	language := strings.ToLower(args[index].String())
+	for _, name := range transliterationLanguages {
+		if language == name {
+			return language, ""
+		}
+	}
+	return "", localize("Error: unsupported language %q (available: %s)", args[index].String(), strings.Join(transliterationLanguages, ", "))
+}
+
+// transliterateString romanizes Cyrillic, Greek and Han characters, then folds Latin letters to ASCII
+func transliterateString(text, language string) string {
+	runes := []rune(text)
+	var result strings.Builder
+	afterHan := false
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		var latin string
+		n := 1
+
+		switch {
+		case unicode.Is(unicode.Han, r):
+			syllable, ok := pinyinSyllable(r)
+			if !ok {
+				latin = string(r)
+				break
+			}
+			// Syllables are written as separate words, like ICU Han-Latin
+			if last, _ := utf8.DecodeLastRuneInString(result.String()); unicode.IsLetter(last) || unicode.IsDigit(last) {
+				result.WriteByte(' ')
+			}
+			result.WriteString(removeDiacriticsFromString(syllable))
+			afterHan = true
+			i++
+			continue
+		case unicode.Is(unicode.Cyrillic, r):
+			latin, n = cyrillicToLatin(runes, i, language)
+			latin = matchCase(latin, runes, i, n)
+		case unicode.Is(unicode.Greek, r):
+			latin, n = greekToLatin(runes, i)
+			latin = matchCase(latin, runes, i, n)
+		case language == "uk" && (r == '\'' || r == '’' || r == 'ʼ') &&
+			i > 0 && i+1 < len(runes) && unicode.Is(unicode.Cyrillic, runes[i-1]) && unicode.Is(unicode.Cyrillic, runes[i+1]):
+			// The Ukrainian apostrophe is not romanized
+			i++
+			continue
+		default:
+			latin = string(r)
+			if ligature, ok := latinLigatures[r]; ok {
+				latin = ligature
+			} else if replacement, ok := diacriticsMap[r]; ok {
+				latin = string(replacement)
+			}
+		}
+		if afterHan && latin != "" {
+			if first, _ := utf8.DecodeRuneInString(latin); unicode.IsLetter(first) || unicode.IsDigit(first) {
+				result.WriteByte(' ')
+			}
+		}
+		afterHan = false
+		result.WriteString(latin)
+		i += n
+	}
+
+	return result.String()
+}
+
+// cyrillicToLatin romanizes the Cyrillic letter at i in lower case, returning the runes consumed
+func cyrillicToLatin(runes []rune, i int, language string) (string, int) {
+	lower := unicode.ToLower(runes[i])
+
+	if language == "uk" {
+		if lower == 'з' && i+1 < len(runes) && unicode.ToLower(runes[i+1]) == 'г' {
+			return "zgh", 2
+		}
+		if initial, ok := ukrainianInitials[lower]; ok && wordStart(runes, i) {
+			return initial, 1
+		}
+	}
+	if latin, ok := cyrillicLanguages[language][lower]; ok {
+		return latin, 1
+	}
+	if latin, ok := cyrillicLatin[lower]; ok {
+		return latin, 1
+	}
+	return string(runes[i]), 1
+}
+
+// greekToLatin romanizes the Greek letter at i in lower case, handling the ELOT 743 digraphs
+func greekToLatin(runes []rune, i int) (string, int) {
+	letter := func(j int) (rune, bool) {
+		if j >= len(runes) || !unicode.Is(unicode.Greek, runes[j]) {
+			return 0, false
+		}
+		r := unicode.ToLower(runes[j])
+		diaeresis := r == 'ϊ' || r == 'ΐ' || r == 'ϋ' || r == 'ΰ'
+		if base, ok := greekBase[r]; ok {
+			r = base
+		}
+		return r, diaeresis
+	}
+
+	c, _ := letter(i)
+	next, nextDiaeresis := letter(i + 1)
+	initial := wordStart(runes, i)
+
+	switch {
+	case (c == 'α' || c == 'ε' || c == 'η') && next == 'υ' && !nextDiaeresis:
+		// αυ, ευ, ηυ sound f before a voiceless consonant or at the end of the word
+		vowel := greekLatin[c]
+		if after, _ := letter(i + 2); after == 0 || strings.ContainsRune("θκξπσςτφχψ", after) {
+			return vowel + "f", 2
+		}
+		return vowel + "v", 2
+	case c == 'ο' && next == 'υ' && !nextDiaeresis:
+		return "ou", 2
+	case c == 'μ' && next == 'π':
+		if initial {
+			return "b", 2
+		}
+		return "mp", 2
+	case c == 'ν' && next == 'τ':
+		if initial {
+			return "d", 2
+		}
+		return "nt", 2
+	case c == 'γ' && next == 'γ':
+		return "ng", 2
+	case c == 'γ' && next == 'ξ':
+		return "nx", 2
+	case c == 'γ' && next == 'χ':
+		return "nch", 2
+	case c == 'γ' && next == 'κ':
+		if initial {
+			return "g", 2
+		}
+		return "gk", 2
+	}
+
+	if latin, ok := greekLatin[c]; ok {
+		return latin, 1
+	}
+	return string(runes[i]), 1
+}
+
+// pinyinSyllable returns the toneless pinyin of a Chinese character
+func pinyinSyllable(r rune) (string, bool) {
+	if pinyinTable == nil {
+		pinyinTable = map[rune]string{}
+		for _, entry := range pinyinSyllables {
+			syllable, characters, _ := strings.Cut(entry, " ")
+			for _, c := range characters {
+				pinyinTable[c] = syllable
+			}
+		}
+	}
+	syllable, ok := pinyinTable[r]
+	return syllable, ok
+}
+
+// wordStart reports whether the letter at i begins a word
+func wordStart(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := runes[i-1]
+	return !unicode.IsLetter(prev) && prev != '\'' && prev != '’' && prev != 'ʼ'
+}
+
+// matchCase applies the case of the source letters to their romanization:
+// "Щука" gives "Shchuka" but "ЩУКА" gives "SHCHUKA"
+func matchCase(latin string, runes []rune, i, n int) string {
+	if latin == "" || !unicode.IsUpper(runes[i]) {
+		return latin
+	}
+
+	upper := n > 1 && unicode.IsUpper(runes[i+1])
+	if !upper {
+		if i+n < len(runes) && unicode.IsLetter(runes[i+n]) {
+			upper = unicode.IsUpper(runes[i+n])
+		} else if i > 0 && unicode.IsLetter(runes[i-1]) {
+			upper = unicode.IsUpper(runes[i-1])
+		}
+	}
+	if upper {
+		return strings.ToUpper(latin)
+	}
+
+	first, size := utf8.DecodeRuneInString(latin)
+	return string(unicode.ToUpper(first)) + latin[size:]
+}
+
 func removeDiacriticsFromString(text string) string {
 	var result strings.Builder
 	for _, r := range text {
@@ -671,10 +1228,108 @@ func max(a, b int) int {
 	return b
 }
 
+// Build metadata, injected by wasm-manager build through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"similarity",
+	"phonetics",
+	"case-conversion",
+	"extraction",
+	"statistics",
+	"sentence-segmentation",
+	"transliteration",
+	"password-generation",
+	"email-validation",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and live handles so pages can monitor memory growth
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	return js.ValueOf(memoryStats(map[string]interface{}{}))
+}
+
+// releaseResources - Return freed memory to the runtime; text-wasm keeps no cached state between calls
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "text-wasm",
+		"version":         moduleVersion,
+		"description":     "Text processing and string manipulation module",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
 // getAvailableFunctions returns all available functions
 func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
-	functions := []string{
+	functions := []interface{}{
 		"setSilentMode",
+		"setLocale",
 		"textSimilarity",
 		"levenshteinDistance",
 		"soundex",
@@ -688,11 +1343,15 @@ func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
 		"wordCount",
 		"characterCount",
 		"readingTime",
+		"splitSentences",
 		"removeDiacritics",
 		"transliterate",
 		"generatePassword",
 		"validateEmail",
 		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
 	}
 
 	if !silentMode {
@@ -707,6 +1366,7 @@ func main() {
 
 	// Register functions
 	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
 	js.Global().Set("textSimilarity", js.FuncOf(textSimilarity))
 	js.Global().Set("levenshteinDistance", js.FuncOf(levenshteinDistance))
 	js.Global().Set("soundex", js.FuncOf(soundex))
@@ -720,11 +1380,15 @@ func main() {
 	js.Global().Set("wordCount", js.FuncOf(wordCount))
 	js.Global().Set("characterCount", js.FuncOf(characterCount))
 	js.Global().Set("readingTime", js.FuncOf(readingTime))
+	js.Global().Set("splitSentences", js.FuncOf(splitSentences))
 	js.Global().Set("removeDiacritics", js.FuncOf(removeDiacritics))
 	js.Global().Set("transliterate", js.FuncOf(transliterate))
 	js.Global().Set("generatePassword", js.FuncOf(generatePassword))
 	js.Global().Set("validateEmail", js.FuncOf(validateEmail))
 	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
 
 	fmt.Println("Go Text Processing WASM Module Loaded")
 	<-c
@@ -0,0 +1,3 @@
+module uuidid-wasm
+
+go 1.21
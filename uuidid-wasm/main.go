@@ -0,0 +1,1250 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+	"unicode/utf8"
+)
+
+var silentMode = false
+
+// Identifier bounds and epochs
+const (
+	maxBatch           = 10000
+	defaultShortLength = 21
+	maxShortLength     = 1024
+
+	// gregorianOffset is the number of 100ns intervals between 1582-10-15 and the Unix epoch
+	gregorianOffset = 0x01B21DD213814000
+
+	// ksuidEpoch is the KSUID epoch in Unix seconds (2014-05-13T16:53:20Z)
+	ksuidEpoch = 1400000000
+)
+
+// Encoding alphabets
+const (
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// uuidNamespaces are the predefined name-based UUID namespaces of RFC 9562
+var uuidNamespaces = map[string]string{
+	"dns":  "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"url":  "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+	"oid":  "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+	"x500": "6ba7b814-9dad-11d1-80b4-00c04fd430c8",
+}
+
+// shortAlphabets are the named alphabets of generateShortID
+var shortAlphabets = map[string]string{
+	"urlsafe":      "useandom-26T198340PX75pxJACKVERYMINDBUSHWOLF_GQZbfghjklqvwyzrict",
+	"alphanumeric": "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	"lowercase":    "0123456789abcdefghijklmnopqrstuvwxyz",
+	"numbers":      "0123456789",
+	"hex":          "0123456789abcdef",
+	"nolookalikes": "346789ABCDEFGHJKLMNPQRTUVWXYabcdefghijkmnpqrtwxyz",
+}
+
+// snowflakeField is a field below the timestamp of a Snowflake ID, from the most significant
+type snowflakeField struct {
+	name string
+	bits uint
+}
+
+// snowflakeLayout describes a Snowflake variant: its epoch in Unix milliseconds and the fields
+// after the timestamp
+type snowflakeLayout struct {
+	epoch  int64
+	fields []snowflakeField
+}
+
+// snowflakePresets are the layouts decodeSnowflake knows by name
+var snowflakePresets = map[string]snowflakeLayout{
+	"twitter":   {1288834974657, []snowflakeField{{"datacenterId", 5}, {"workerId", 5}, {"sequence", 12}}},
+	"discord":   {1420070400000, []snowflakeField{{"workerId", 5}, {"processId", 5}, {"sequence", 12}}},
+	"instagram": {1314220021721, []snowflakeField{{"shardId", 13}, {"sequence", 10}}},
+	"mastodon":  {0, []snowflakeField{{"sequence", 16}}},
+}
+
+// uuidOptions configure generateUUID
+type uuidOptions struct {
+	Count     int      `json:"count"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Node      string   `json:"node"`
+	Timestamp *float64 `json:"timestamp"`
+	Format    string   `json:"format"`
+	Uppercase bool     `json:"uppercase"`
+}
+
+// timedOptions configure generateULID and generateKSUID
+type timedOptions struct {
+	Count     int      `json:"count"`
+	Timestamp *float64 `json:"timestamp"`
+}
+
+// shortOptions configure generateShortID
+type shortOptions struct {
+	Count    int    `json:"count"`
+	Length   int    `json:"length"`
+	Alphabet string `json:"alphabet"`
+}
+
+// snowflakeOptions select the layout decodeSnowflake uses: a preset, or a custom epoch and field widths
+type snowflakeOptions struct {
+	Preset       string   `json:"preset"`
+	Epoch        *float64 `json:"epoch"`
+	WorkerBits   *uint    `json:"workerBits"`
+	SequenceBits *uint    `json:"sequenceBits"`
+}
+
+// Generator state, so IDs generated in the same millisecond still sort in generation order
+var (
+	lastV1Time   int64
+	clockSeq     uint16
+	v1Node       []byte
+	lastV7Millis int64
+	v7Counter    uint16
+	lastULIDTime int64
+	lastULIDRand [10]byte
+	generated    = map[string]int{}
+)
+
+// setSilentMode enables/disables silent mode for console logs
+func setSilentMode(this js.Value, args []js.Value) interface{} {
+	if len(args) == 1 {
+		silentMode = args[0].Bool()
+	}
+	return js.ValueOf(silentMode)
+}
+
+// currentLocale is the language of error messages, changed with setLocale
+var currentLocale = "en"
+
+// translations holds the message packs by locale. English messages are both the keys and the fallback.
+var translations = map[string]map[string]string{
+	"fr": messagesFR,
+}
+
+// setLocale - Select the language of error messages ("en" by default, or "fr")
+func setLocale(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("setLocale requires exactly 1 argument (locale)"),
+		})
+	}
+
+	// Region subtags are ignored: fr-FR and fr_CA both select fr
+	locale := strings.ToLower(args[0].String())
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	available := []interface{}{"en"}
+	for _, name := range sortedLocales() {
+		available = append(available, name)
+	}
+
+	if _, ok := translations[locale]; !ok && locale != "en" {
+		names := make([]string, len(available))
+		for i, name := range available {
+			names[i] = name.(string)
+		}
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("Unsupported locale %q (available: %s)", args[0].String(), strings.Join(names, ", ")),
+		})
+	}
+	currentLocale = locale
+
+	return js.ValueOf(map[string]interface{}{
+		"locale":    currentLocale,
+		"available": available,
+	})
+}
+
+// sortedLocales returns the locales that have a translation pack
+func sortedLocales() []string {
+	locales := make([]string, 0, len(translations))
+	for name := range translations {
+		locales = append(locales, name)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// localize translates a message to the current locale, then formats it with args
+func localize(message string, args ...interface{}) string {
+	if translated, ok := translations[currentLocale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// messagesFR is the French translation pack
+var messagesFR = map[string]string{
+	"%q is not an unsigned 64-bit integer": "%q n'est pas un entier non signé de 64 bits",
+	"%q is not hexadecimal":                "%q n'est pas hexadécimal",
+	"%s requires at least 1 argument (%s)": "%s nécessite au moins 1 argument (%s)",
+	"Failed to generate ID: %v":            "Échec de la génération de l'identifiant : %v",
+	"Failed to generate KSUID: %v":         "Échec de la génération du KSUID : %v",
+	"Failed to generate ULID: %v":          "Échec de la génération de l'ULID : %v",
+	"Failed to generate UUID: %v":          "Échec de la génération de l'UUID : %v",
+	"Invalid KSUID: %v":                    "KSUID invalide : %v",
+	"Invalid Snowflake ID: %v":             "Identifiant Snowflake invalide : %v",
+	"Invalid ULID: %v":                     "ULID invalide : %v",
+	"Invalid UUID: %v":                     "UUID invalide : %v",
+	"Invalid options: %v":                  "Options invalides : %v",
+	"UUID v5 requires a namespace (dns, url, oid, x500 or a UUID) and a name": "l'UUID v5 nécessite un espace de noms (dns, url, oid, x500 ou un UUID) et un nom",
+	"Unsupported locale %q (available: %s)":                                   "Langue %q non prise en charge (disponibles : %s)",
+	"alphabet must have between 2 and 256 characters, got %d":                 "l'alphabet doit comporter entre 2 et 256 caractères, %d reçus",
+	"alphabet repeats the character %q":                                       "l'alphabet répète le caractère %q",
+	"count must be between 1 and %d":                                          "count doit être compris entre 1 et %d",
+	"epoch must be a non-negative integer number of milliseconds":             "epoch doit être un nombre entier positif de millisecondes",
+	"expected 26 characters, got %d":                                          "26 caractères attendus, %d reçus",
+	"expected 27 characters, got %d":                                          "27 caractères attendus, %d reçus",
+	"expected 32 hexadecimal digits, got %d characters":                       "32 chiffres hexadécimaux attendus, %d caractères reçus",
+	"hyphens expected at positions 8, 13, 18 and 23":                          "tirets attendus aux positions 8, 13, 18 et 23",
+	"invalid character %q at position %d":                                     "caractère %q invalide à la position %d",
+	"invalid namespace %q: %v":                                                "espace de noms %q invalide : %v",
+	"length must be between 1 and %d":                                         "length doit être compris entre 1 et %d",
+	"node must be 6 bytes in hexadecimal, such as 01:23:45:67:89:ab":          "node doit être 6 octets en hexadécimal, comme 01:23:45:67:89:ab",
+	"setLocale requires exactly 1 argument (locale)":                          "setLocale nécessite exactement 1 argument (locale)",
+	"the first character must be 0 to 7":                                      "le premier caractère doit être compris entre 0 et 7",
+	"timestamp is before the KSUID epoch (2014-05-13T16:53:20Z)":              "timestamp est antérieur à l'époque KSUID (2014-05-13T16:53:20Z)",
+	"timestamp must be an integer number of milliseconds between 0 and %d":    "timestamp doit être un nombre entier de millisecondes compris entre 0 et %d",
+	"too many ULIDs in millisecond %d":                                        "trop d'ULID dans la milliseconde %d",
+	"unknown preset %q (available: %s)":                                       "préréglage %q inconnu (disponibles : %s)",
+	"unsupported UUID version %d (supported: 1, 4, 5, 7)":                     "version d'UUID %d non prise en charge (prises en charge : 1, 4, 5, 7)",
+	"unsupported UUID version %q (supported: 1, 4, 5, 7)":                     "version d'UUID %q non prise en charge (prises en charge : 1, 4, 5, 7)",
+	"unsupported format %q (supported: canonical, compact, braces, urn)":      "format %q non pris en charge (pris en charge : canonical, compact, braces, urn)",
+	"value exceeds 160 bits":                                                  "la valeur dépasse 160 bits",
+	"workerBits and sequenceBits must add up to at most 63":                   "workerBits et sequenceBits doivent totaliser au plus 63",
+}
+
+// generateUUID - Generate UUIDs: v4 (random, the default), v7 (time-ordered), v1 (time and node) or
+// v5 (SHA-1 of a namespace and a name). count returns a batch as uuids.
+func generateUUID(this js.Value, args []js.Value) interface{} {
+	version := 4
+	if len(args) > 0 && args[0].Type() != js.TypeUndefined && args[0].Type() != js.TypeNull {
+		var err error
+		if version, err = versionArgument(args[0]); err != nil {
+			return errorResult(err.Error())
+		}
+	}
+
+	var options uuidOptions
+	if err := decodeOptions(optionalValue(args, 1), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	count, err := batchCount(options.Count)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	format, err := uuidFormatter(options.Format, options.Uppercase)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+
+	var generate func() ([16]byte, error)
+	switch version {
+	case 4:
+		generate = newUUIDv4
+	case 7:
+		generate = func() ([16]byte, error) { return newUUIDv7(options.Timestamp) }
+	case 1:
+		node, err := nodeOption(options.Node)
+		if err != nil {
+			return errorResult(localize("Invalid options: %v", err))
+		}
+		generate = func() ([16]byte, error) { return newUUIDv1(options.Timestamp, node) }
+	case 5:
+		namespace, err := namespaceOption(options.Namespace)
+		if err != nil {
+			return errorResult(localize("Invalid options: %v", err))
+		}
+		generate = func() ([16]byte, error) { return newUUIDv5(namespace, options.Name), nil }
+	default:
+		return errorResult(localize("unsupported UUID version %d (supported: 1, 4, 5, 7)", version))
+	}
+
+	uuids := make([]interface{}, count)
+	for i := range uuids {
+		id, err := generate()
+		if err != nil {
+			return errorResult(localize("Failed to generate UUID: %v", err))
+		}
+		uuids[i] = format(id)
+	}
+	generated["uuid"] += count
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated %d UUID v%d\n", count, version)
+	}
+
+	result := map[string]interface{}{
+		"uuid":    uuids[0],
+		"version": version,
+	}
+	if options.Count > 0 {
+		result["uuids"] = uuids
+		result["count"] = count
+	}
+	return js.ValueOf(result)
+}
+
+// parseUUID - Parse a UUID in canonical, compact (32 hex digits), braced or urn:uuid: form. Returns the
+// canonical form, version and variant, and the timestamp, clock sequence and node of v1, v6 and v7.
+func parseUUID(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "parseUUID", "uuid"),
+		})
+	}
+
+	id, err := decodeUUID(args[0].String())
+	if err != nil {
+		return errorResult(localize("Invalid UUID: %v", err))
+	}
+
+	version := int(id[6] >> 4)
+	result := map[string]interface{}{
+		"uuid":    formatUUID(id),
+		"version": version,
+		"variant": uuidVariant(id),
+		"nil":     id == [16]byte{},
+		"max":     id == [16]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"hex":     hex.EncodeToString(id[:]),
+	}
+	if uuidVariant(id) != "RFC 9562" {
+		result["version"] = nil
+		return js.ValueOf(result)
+	}
+
+	switch version {
+	case 1, 6:
+		var intervals int64
+		if version == 1 {
+			intervals = int64(binary.BigEndian.Uint16(id[6:8])&0x0fff)<<48 |
+				int64(binary.BigEndian.Uint16(id[4:6]))<<32 |
+				int64(binary.BigEndian.Uint32(id[0:4]))
+		} else {
+			intervals = int64(binary.BigEndian.Uint32(id[0:4]))<<28 |
+				int64(binary.BigEndian.Uint16(id[4:6]))<<12 |
+				int64(binary.BigEndian.Uint16(id[6:8])&0x0fff)
+		}
+		addTimestamp(result, (intervals-gregorianOffset)/10000)
+		result["clockSequence"] = int(binary.BigEndian.Uint16(id[8:10]) & 0x3fff)
+		result["node"] = formatNode(id[10:16])
+	case 7:
+		addTimestamp(result, int64(id[0])<<40|int64(id[1])<<32|int64(binary.BigEndian.Uint32(id[2:6])))
+	}
+
+	return js.ValueOf(result)
+}
+
+// generateULID - Generate ULIDs: 48-bit millisecond timestamp and 80 random bits in 26 Crockford base32
+// characters. IDs of the same millisecond increment the random part, so they sort in generation order.
+func generateULID(this js.Value, args []js.Value) interface{} {
+	var options timedOptions
+	if err := decodeOptions(optionalValue(args, 0), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	count, err := batchCount(options.Count)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	millis, err := timestampOption(options.Timestamp, 1<<48-1)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+
+	ulids := make([]interface{}, count)
+	for i := range ulids {
+		id, err := newULID(millis)
+		if err != nil {
+			return errorResult(localize("Failed to generate ULID: %v", err))
+		}
+		ulids[i] = id
+	}
+	generated["ulid"] += count
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated %d ULID\n", count)
+	}
+
+	result := map[string]interface{}{"ulid": ulids[0]}
+	addTimestamp(result, millis)
+	if options.Count > 0 {
+		result["ulids"] = ulids
+		result["count"] = count
+	}
+	return js.ValueOf(result)
+}
+
+// parseULID - Decode a ULID (case insensitive, with the Crockford I, L and O aliases) into its
+// timestamp and random part, and the UUID with the same 128 bits
+func parseULID(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "parseULID", "ulid"),
+		})
+	}
+
+	id, err := decodeULID(args[0].String())
+	if err != nil {
+		return errorResult(localize("Invalid ULID: %v", err))
+	}
+
+	result := map[string]interface{}{
+		"ulid":       encodeULID(id),
+		"randomness": hex.EncodeToString(id[6:]),
+		"uuid":       formatUUID(id),
+	}
+	addTimestamp(result, int64(id[0])<<40|int64(id[1])<<32|int64(binary.BigEndian.Uint32(id[2:6])))
+	return js.ValueOf(result)
+}
+
+// generateKSUID - Generate KSUIDs: 32-bit second timestamp from the KSUID epoch and 128 random bits in
+// 27 base62 characters
+func generateKSUID(this js.Value, args []js.Value) interface{} {
+	var options timedOptions
+	if err := decodeOptions(optionalValue(args, 0), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	count, err := batchCount(options.Count)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	millis, err := timestampOption(options.Timestamp, (ksuidEpoch+math.MaxUint32)*1000)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	if millis < ksuidEpoch*1000 {
+		return errorResult(localize("Invalid options: %v", localize("timestamp is before the KSUID epoch (2014-05-13T16:53:20Z)")))
+	}
+
+	ksuids := make([]interface{}, count)
+	var id [20]byte
+	binary.BigEndian.PutUint32(id[:4], uint32(millis/1000-ksuidEpoch))
+	for i := range ksuids {
+		if _, err := rand.Read(id[4:]); err != nil {
+			return errorResult(localize("Failed to generate KSUID: %v", err))
+		}
+		ksuids[i] = encodeBase62(id)
+	}
+	generated["ksuid"] += count
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated %d KSUID\n", count)
+	}
+
+	result := map[string]interface{}{"ksuid": ksuids[0]}
+	addTimestamp(result, millis/1000*1000)
+	if options.Count > 0 {
+		result["ksuids"] = ksuids
+		result["count"] = count
+	}
+	return js.ValueOf(result)
+}
+
+// parseKSUID - Decode a KSUID into its timestamp (second precision) and payload
+func parseKSUID(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "parseKSUID", "ksuid"),
+		})
+	}
+
+	id, err := decodeBase62(args[0].String())
+	if err != nil {
+		return errorResult(localize("Invalid KSUID: %v", err))
+	}
+
+	result := map[string]interface{}{
+		"ksuid":   encodeBase62(id),
+		"payload": hex.EncodeToString(id[4:]),
+	}
+	addTimestamp(result, (int64(binary.BigEndian.Uint32(id[:4]))+ksuidEpoch)*1000)
+	return js.ValueOf(result)
+}
+
+// decodeSnowflake - Extract the timestamp and fields of a Snowflake ID. Pass IDs as strings or BigInts:
+// JavaScript numbers lose precision above 2^53. Presets: twitter (default), discord, instagram and
+// mastodon; a custom layout takes epoch, workerBits and sequenceBits.
+func decodeSnowflake(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.ValueOf(map[string]interface{}{
+			"error": localize("%s requires at least 1 argument (%s)", "decodeSnowflake", "id"),
+		})
+	}
+
+	var options snowflakeOptions
+	if err := decodeOptions(optionalValue(args, 1), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	layout, preset, err := snowflakeLayoutOption(options)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+
+	// String() converts numbers and BigInts, which syscall/js cannot read directly
+	text := js.Global().Get("String").Invoke(args[0]).String()
+	id, err := strconv.ParseUint(strings.TrimSpace(text), 10, 64)
+	if err != nil {
+		return errorResult(localize("Invalid Snowflake ID: %v", localize("%q is not an unsigned 64-bit integer", text)))
+	}
+
+	result := map[string]interface{}{
+		"id":     strconv.FormatUint(id, 10),
+		"preset": preset,
+		"epoch":  float64(layout.epoch),
+	}
+	shift := uint(0)
+	for i := len(layout.fields) - 1; i >= 0; i-- {
+		field := layout.fields[i]
+		result[field.name] = float64(id >> shift & (1<<field.bits - 1))
+		shift += field.bits
+	}
+	addTimestamp(result, int64(id>>shift)+layout.epoch)
+
+	return js.ValueOf(result)
+}
+
+// generateShortID - Generate random IDs of a given length (21 by default) over an alphabet: a named one
+// (urlsafe by default, alphanumeric, lowercase, numbers, hex, nolookalikes) or custom characters.
+// Characters are drawn without modulo bias. The entropy and the number of IDs after which a collision
+// has a 1% chance are returned to help choose the length.
+func generateShortID(this js.Value, args []js.Value) interface{} {
+	var options shortOptions
+	if err := decodeOptions(optionalValue(args, 0), &options); err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	count, err := batchCount(options.Count)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	alphabet, err := alphabetOption(options.Alphabet)
+	if err != nil {
+		return errorResult(localize("Invalid options: %v", err))
+	}
+	length := options.Length
+	if length == 0 {
+		length = defaultShortLength
+	}
+	if length < 1 || length > maxShortLength {
+		return errorResult(localize("Invalid options: %v", localize("length must be between 1 and %d", maxShortLength)))
+	}
+
+	ids := make([]interface{}, count)
+	for i := range ids {
+		id, err := newShortID(alphabet, length)
+		if err != nil {
+			return errorResult(localize("Failed to generate ID: %v", err))
+		}
+		ids[i] = id
+	}
+	generated["shortId"] += count
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Generated %d short IDs of %d characters\n", count, length)
+	}
+
+	bits := float64(length) * math.Log2(float64(len(alphabet)))
+	result := map[string]interface{}{
+		"id":                 ids[0],
+		"length":             length,
+		"alphabetSize":       len(alphabet),
+		"entropyBits":        math.Round(bits*100) / 100,
+		"idsFor1PercentRisk": collisionThreshold(bits, 0.01),
+	}
+	if options.Count > 0 {
+		result["ids"] = ids
+		result["count"] = count
+	}
+	return js.ValueOf(result)
+}
+
+func errorResult(message string) js.Value {
+	return js.ValueOf(map[string]interface{}{"error": message})
+}
+
+// versionArgument reads a UUID version given as a number or as a string such as "v7"
+func versionArgument(value js.Value) (int, error) {
+	if value.Type() == js.TypeNumber {
+		return value.Int(), nil
+	}
+	text := strings.TrimPrefix(strings.ToLower(value.String()), "v")
+	version, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, errors.New(localize("unsupported UUID version %q (supported: 1, 4, 5, 7)", value.String()))
+	}
+	return version, nil
+}
+
+// batchCount returns how many IDs to generate: 1 unless count is set
+func batchCount(count int) (int, error) {
+	if count == 0 {
+		return 1, nil
+	}
+	if count < 1 || count > maxBatch {
+		return 0, errors.New(localize("count must be between 1 and %d", maxBatch))
+	}
+	return count, nil
+}
+
+// timestampOption returns the timestamp option in Unix milliseconds, or the current time
+func timestampOption(timestamp *float64, max int64) (int64, error) {
+	if timestamp == nil {
+		return time.Now().UnixMilli(), nil
+	}
+	if *timestamp < 0 || *timestamp > float64(max) || *timestamp != math.Trunc(*timestamp) {
+		return 0, errors.New(localize("timestamp must be an integer number of milliseconds between 0 and %d", max))
+	}
+	return int64(*timestamp), nil
+}
+
+// uuidFormatter returns the function that writes UUIDs in the requested form
+func uuidFormatter(format string, uppercase bool) (func([16]byte) string, error) {
+	var write func([16]byte) string
+	switch format {
+	case "", "canonical":
+		write = formatUUID
+	case "compact":
+		write = func(id [16]byte) string { return hex.EncodeToString(id[:]) }
+	case "braces":
+		write = func(id [16]byte) string { return "{" + formatUUID(id) + "}" }
+	case "urn":
+		write = func(id [16]byte) string { return "urn:uuid:" + formatUUID(id) }
+	default:
+		return nil, errors.New(localize("unsupported format %q (supported: canonical, compact, braces, urn)", format))
+	}
+	if !uppercase {
+		return write, nil
+	}
+	return func(id [16]byte) string {
+		text := write(id)
+		if strings.HasPrefix(text, "urn:uuid:") {
+			return "urn:uuid:" + strings.ToUpper(text[9:])
+		}
+		return strings.ToUpper(text)
+	}, nil
+}
+
+// formatUUID writes a UUID in the canonical 8-4-4-4-12 form
+func formatUUID(id [16]byte) string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+	return string(buf[:])
+}
+
+// decodeUUID reads a UUID in canonical, compact, braced or urn:uuid: form
+func decodeUUID(text string) ([16]byte, error) {
+	var id [16]byte
+	text = strings.TrimSpace(text)
+	if len(text) >= 9 && strings.EqualFold(text[:9], "urn:uuid:") {
+		text = text[9:]
+	} else if strings.HasPrefix(text, "{") && strings.HasSuffix(text, "}") {
+		text = text[1 : len(text)-1]
+	}
+
+	switch len(text) {
+	case 36:
+		if text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+			return id, errors.New(localize("hyphens expected at positions 8, 13, 18 and 23"))
+		}
+		text = text[0:8] + text[9:13] + text[14:18] + text[19:23] + text[24:]
+	case 32:
+	default:
+		return id, errors.New(localize("expected 32 hexadecimal digits, got %d characters", utf8.RuneCountInString(text)))
+	}
+	if _, err := hex.Decode(id[:], []byte(text)); err != nil {
+		return id, errors.New(localize("%q is not hexadecimal", text))
+	}
+	return id, nil
+}
+
+// uuidVariant names the variant field of a UUID
+func uuidVariant(id [16]byte) string {
+	switch {
+	case id[8]&0x80 == 0:
+		return "NCS"
+	case id[8]&0xc0 == 0x80:
+		return "RFC 9562"
+	case id[8]&0xe0 == 0xc0:
+		return "Microsoft"
+	}
+	return "Future"
+}
+
+// setVersion writes the version and the RFC 9562 variant bits
+func setVersion(id *[16]byte, version byte) {
+	id[6] = id[6]&0x0f | version<<4
+	id[8] = id[8]&0x3f | 0x80
+}
+
+// newUUIDv4 returns a random UUID
+func newUUIDv4() ([16]byte, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return id, err
+	}
+	setVersion(&id, 4)
+	return id, nil
+}
+
+// newUUIDv7 returns a time-ordered UUID: 48-bit Unix milliseconds, then a 12-bit counter that keeps
+// UUIDs of the same millisecond in order (RFC 9562 method 3), then 62 random bits
+func newUUIDv7(timestamp *float64) ([16]byte, error) {
+	var id [16]byte
+	millis, err := timestampOption(timestamp, 1<<48-1)
+	if err != nil {
+		return id, err
+	}
+	if _, err := rand.Read(id[6:]); err != nil {
+		return id, err
+	}
+
+	if timestamp == nil && millis <= lastV7Millis {
+		// The counter starts at a random value below 2048 each millisecond; when it runs out, the
+		// timestamp moves one millisecond ahead of the clock
+		millis = lastV7Millis
+		if v7Counter++; v7Counter > 0x0fff {
+			millis++
+			v7Counter = binary.BigEndian.Uint16(id[6:8]) & 0x07ff
+		}
+	} else {
+		v7Counter = binary.BigEndian.Uint16(id[6:8]) & 0x07ff
+	}
+	if timestamp == nil {
+		lastV7Millis = millis
+	}
+
+	id[0], id[1] = byte(millis>>40), byte(millis>>32)
+	binary.BigEndian.PutUint32(id[2:6], uint32(millis))
+	binary.BigEndian.PutUint16(id[6:8], v7Counter)
+	setVersion(&id, 7)
+	return id, nil
+}
+
+// newUUIDv1 returns a UUID from the time in 100ns intervals since 1582-10-15, a clock sequence and a
+// node. UUIDs generated within the same interval use the next interval, so they never repeat.
+func newUUIDv1(timestamp *float64, node []byte) ([16]byte, error) {
+	var id [16]byte
+	millis, err := timestampOption(timestamp, (math.MaxInt64-gregorianOffset)/10000)
+	if err != nil {
+		return id, err
+	}
+	intervals := millis*10000 + gregorianOffset
+	if timestamp == nil {
+		intervals = time.Now().UnixNano()/100 + gregorianOffset
+	}
+
+	if clockSeq == 0 {
+		var seq [2]byte
+		if _, err := rand.Read(seq[:]); err != nil {
+			return id, err
+		}
+		clockSeq = binary.BigEndian.Uint16(seq[:])&0x3fff | 0x8000
+	}
+	if timestamp == nil {
+		if intervals <= lastV1Time {
+			intervals = lastV1Time + 1
+		}
+		lastV1Time = intervals
+	}
+
+	binary.BigEndian.PutUint32(id[0:4], uint32(intervals))
+	binary.BigEndian.PutUint16(id[4:6], uint16(intervals>>32))
+	binary.BigEndian.PutUint16(id[6:8], uint16(intervals>>48)&0x0fff)
+	binary.BigEndian.PutUint16(id[8:10], clockSeq&0x3fff)
+	copy(id[10:], node)
+	setVersion(&id, 1)
+	return id, nil
+}
+
+// nodeOption returns the node of v1 UUIDs: the one given as 12 hex digits (with optional colons or
+// hyphens), or a random node with the multicast bit set, kept for the life of the module
+func nodeOption(text string) ([]byte, error) {
+	if text != "" {
+		node, err := hex.DecodeString(strings.NewReplacer(":", "", "-", "").Replace(text))
+		if err != nil || len(node) != 6 {
+			return nil, errors.New(localize("node must be 6 bytes in hexadecimal, such as 01:23:45:67:89:ab"))
+		}
+		return node, nil
+	}
+	if v1Node == nil {
+		node := make([]byte, 6)
+		if _, err := rand.Read(node); err != nil {
+			return nil, err
+		}
+		node[0] |= 0x01
+		v1Node = node
+	}
+	return v1Node, nil
+}
+
+// formatNode writes a node as colon separated hex bytes
+func formatNode(node []byte) string {
+	parts := make([]string, len(node))
+	for i, b := range node {
+		parts[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.Join(parts, ":")
+}
+
+// namespaceOption returns the namespace of v5 UUIDs: dns, url, oid, x500 or any UUID
+func namespaceOption(text string) ([16]byte, error) {
+	if text == "" {
+		return [16]byte{}, errors.New(localize("UUID v5 requires a namespace (dns, url, oid, x500 or a UUID) and a name"))
+	}
+	if predefined, ok := uuidNamespaces[strings.ToLower(text)]; ok {
+		text = predefined
+	}
+	namespace, err := decodeUUID(text)
+	if err != nil {
+		return namespace, errors.New(localize("invalid namespace %q: %v", text, err))
+	}
+	return namespace, nil
+}
+
+// newUUIDv5 returns the name-based UUID of a name in a namespace
+func newUUIDv5(namespace [16]byte, name string) [16]byte {
+	hash := sha1.New()
+	hash.Write(namespace[:])
+	hash.Write([]byte(name))
+	var id [16]byte
+	copy(id[:], hash.Sum(nil))
+	setVersion(&id, 5)
+	return id
+}
+
+// addTimestamp adds timestamp (Unix milliseconds) and date (ISO 8601) to a result
+func addTimestamp(result map[string]interface{}, millis int64) {
+	result["timestamp"] = float64(millis)
+	result["date"] = time.UnixMilli(millis).UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// newULID returns a ULID for a millisecond. In the same millisecond as the previous one, the random
+// part is the previous one plus one.
+func newULID(millis int64) (string, error) {
+	var id [16]byte
+	id[0], id[1] = byte(millis>>40), byte(millis>>32)
+	binary.BigEndian.PutUint32(id[2:6], uint32(millis))
+
+	if millis == lastULIDTime {
+		next := lastULIDRand
+		for i := len(next) - 1; i >= 0; i-- {
+			if next[i]++; next[i] != 0 {
+				break
+			}
+			if i == 0 {
+				return "", errors.New(localize("too many ULIDs in millisecond %d", millis))
+			}
+		}
+		copy(id[6:], next[:])
+	} else if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+
+	lastULIDTime = millis
+	copy(lastULIDRand[:], id[6:])
+	return encodeULID(id), nil
+}
+
+// encodeULID writes 128 bits as 26 Crockford base32 characters, the first holding the top 3 bits
+func encodeULID(id [16]byte) string {
+	var buf [26]byte
+	value := new(big.Int).SetBytes(id[:])
+	mask := big.NewInt(31)
+	digit := new(big.Int)
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[digit.And(value, mask).Int64()]
+		value.Rsh(value, 5)
+	}
+	return string(buf[:])
+}
+
+// decodeULID reads 26 Crockford base32 characters
+func decodeULID(text string) ([16]byte, error) {
+	var id [16]byte
+	text = strings.TrimSpace(text)
+	if len(text) != 26 {
+		return id, errors.New(localize("expected 26 characters, got %d", utf8.RuneCountInString(text)))
+	}
+	if text[0] > '7' {
+		return id, errors.New(localize("the first character must be 0 to 7"))
+	}
+
+	value := new(big.Int)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		switch c {
+		case 'I', 'L':
+			c = '1'
+		case 'O':
+			c = '0'
+		}
+		digit := strings.IndexByte(crockfordAlphabet, c)
+		if digit < 0 {
+			return id, errors.New(localize("invalid character %q at position %d", text[i], i))
+		}
+		value.Lsh(value, 5)
+		value.Or(value, big.NewInt(int64(digit)))
+	}
+	value.FillBytes(id[:])
+	return id, nil
+}
+
+// encodeBase62 writes the 20 bytes of a KSUID as 27 base62 characters
+func encodeBase62(id [20]byte) string {
+	var buf [27]byte
+	value := new(big.Int).SetBytes(id[:])
+	base := big.NewInt(62)
+	digit := new(big.Int)
+	for i := len(buf) - 1; i >= 0; i-- {
+		value.DivMod(value, base, digit)
+		buf[i] = base62Alphabet[digit.Int64()]
+	}
+	return string(buf[:])
+}
+
+// decodeBase62 reads the 27 base62 characters of a KSUID
+func decodeBase62(text string) ([20]byte, error) {
+	var id [20]byte
+	text = strings.TrimSpace(text)
+	if len(text) != 27 {
+		return id, errors.New(localize("expected 27 characters, got %d", utf8.RuneCountInString(text)))
+	}
+
+	value := new(big.Int)
+	base := big.NewInt(62)
+	for i := 0; i < len(text); i++ {
+		digit := strings.IndexByte(base62Alphabet, text[i])
+		if digit < 0 {
+			return id, errors.New(localize("invalid character %q at position %d", text[i], i))
+		}
+		value.Mul(value, base)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+	if value.BitLen() > 160 {
+		return id, errors.New(localize("value exceeds 160 bits"))
+	}
+	value.FillBytes(id[:])
+	return id, nil
+}
+
+// snowflakeLayoutOption returns the layout of the options and its preset name, "custom" for a layout
+// given by epoch, workerBits and sequenceBits
+func snowflakeLayoutOption(options snowflakeOptions) (snowflakeLayout, string, error) {
+	if options.Epoch == nil && options.WorkerBits == nil && options.SequenceBits == nil {
+		name := strings.ToLower(options.Preset)
+		if name == "" {
+			name = "twitter"
+		}
+		layout, ok := snowflakePresets[name]
+		if !ok {
+			names := make([]string, 0, len(snowflakePresets))
+			for preset := range snowflakePresets {
+				names = append(names, preset)
+			}
+			sort.Strings(names)
+			return layout, "", errors.New(localize("unknown preset %q (available: %s)", options.Preset, strings.Join(names, ", ")))
+		}
+		return layout, name, nil
+	}
+
+	// A custom layout starts from the preset, Twitter's by default, and replaces what is given
+	base, ok := snowflakePresets[strings.ToLower(options.Preset)]
+	if !ok {
+		base = snowflakePresets["twitter"]
+	}
+	layout := snowflakeLayout{epoch: base.epoch}
+	if options.Epoch != nil {
+		if *options.Epoch < 0 || *options.Epoch != math.Trunc(*options.Epoch) {
+			return layout, "", errors.New(localize("epoch must be a non-negative integer number of milliseconds"))
+		}
+		layout.epoch = int64(*options.Epoch)
+	}
+	workerBits, sequenceBits := uint(10), uint(12)
+	if options.WorkerBits != nil {
+		workerBits = *options.WorkerBits
+	}
+	if options.SequenceBits != nil {
+		sequenceBits = *options.SequenceBits
+	}
+	if workerBits+sequenceBits > 63 {
+		return layout, "", errors.New(localize("workerBits and sequenceBits must add up to at most 63"))
+	}
+	layout.fields = []snowflakeField{{"workerId", workerBits}, {"sequence", sequenceBits}}
+	return layout, "custom", nil
+}
+
+// alphabetOption returns the characters of a named or custom alphabet
+func alphabetOption(name string) ([]rune, error) {
+	if name == "" {
+		name = "urlsafe"
+	}
+	text, ok := shortAlphabets[name]
+	if !ok {
+		text = name
+	}
+
+	alphabet := []rune(text)
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return nil, errors.New(localize("alphabet must have between 2 and 256 characters, got %d", len(alphabet)))
+	}
+	seen := map[rune]bool{}
+	for _, r := range alphabet {
+		if seen[r] {
+			return nil, errors.New(localize("alphabet repeats the character %q", r))
+		}
+		seen[r] = true
+	}
+	return alphabet, nil
+}
+
+// newShortID draws length characters from the alphabet. Random bytes are masked to the smallest
+// power of two covering the alphabet and out-of-range values are dropped, which avoids modulo bias.
+func newShortID(alphabet []rune, length int) (string, error) {
+	mask := 1
+	for mask < len(alphabet) {
+		mask <<= 1
+	}
+	mask--
+
+	id := make([]rune, 0, length)
+	random := make([]byte, length*2)
+	for len(id) < length {
+		if _, err := rand.Read(random); err != nil {
+			return "", err
+		}
+		for _, b := range random {
+			if index := int(b) & mask; index < len(alphabet) {
+				id = append(id, alphabet[index])
+				if len(id) == length {
+					break
+				}
+			}
+		}
+	}
+	return string(id), nil
+}
+
+// collisionThreshold returns how many IDs of the given entropy can be generated before a collision
+// has probability p, by the birthday bound
+func collisionThreshold(bits, p float64) float64 {
+	return math.Round(math.Sqrt(2*math.Log(1/(1-p))) * math.Pow(2, bits/2))
+}
+
+// decodeOptions reads an options argument given as a JS object or a JSON string, leaving defaults for missing keys
+func decodeOptions(value js.Value, target interface{}) error {
+	switch value.Type() {
+	case js.TypeUndefined, js.TypeNull:
+		return nil
+	case js.TypeObject:
+		value = js.Global().Get("JSON").Call("stringify", value)
+	}
+	return json.Unmarshal([]byte(value.String()), target)
+}
+
+// optionalValue returns the argument at index, or undefined when it was not passed
+func optionalValue(args []js.Value, index int) js.Value {
+	if len(args) > index {
+		return args[index]
+	}
+	return js.Undefined()
+}
+
+// Build metadata, injected by wasm-manager through -ldflags -X
+var (
+	moduleVersion = "0.1.0"
+	buildHash     = "dev"
+)
+
+// moduleFeatures lists the capabilities loaders can negotiate on
+var moduleFeatures = []interface{}{
+	"uuid-v1",
+	"uuid-v4",
+	"uuid-v5",
+	"uuid-v7",
+	"uuid-parsing",
+	"ulid",
+	"ksuid",
+	"snowflake",
+	"short-ids",
+}
+
+// registeredFunctions returns the advertised functions actually exposed on the global object
+func registeredFunctions(advertised js.Value) []interface{} {
+	functions := []interface{}{}
+	for i := 0; i < advertised.Length(); i++ {
+		name := advertised.Index(i).String()
+		if js.Global().Get(name).Type() == js.TypeFunction {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+// getMemoryStats - Report Go heap usage and the IDs generated so far
+func getMemoryStats(this js.Value, args []js.Value) interface{} {
+	handles := map[string]interface{}{}
+	for kind, count := range generated {
+		handles[kind] = count
+	}
+	return js.ValueOf(memoryStats(handles))
+}
+
+// releaseResources - Reset the generator state and return freed memory to the runtime. UUIDs v1 get
+// a new node and clock sequence afterwards.
+func releaseResources(this js.Value, args []js.Value) interface{} {
+	before := memoryStats(nil)["heapInUse"].(uint64)
+
+	released := map[string]interface{}{}
+	for kind, count := range generated {
+		released[kind] = count
+	}
+	generated = map[string]int{}
+	lastV1Time, clockSeq, v1Node = 0, 0, nil
+	lastV7Millis, v7Counter = 0, 0
+	lastULIDTime, lastULIDRand = 0, [10]byte{}
+
+	// The wasm linear memory never shrinks, but freed spans are reused by later allocations
+	debug.FreeOSMemory()
+	after := memoryStats(nil)["heapInUse"].(uint64)
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Released resources, heap in use %d -> %d bytes\n", before, after)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"released":        released,
+		"heapInUseBefore": before,
+		"heapInUseAfter":  after,
+	})
+}
+
+// memoryStats reads the Go runtime memory statistics along with the module's live handles
+func memoryStats(handles map[string]interface{}) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return map[string]interface{}{
+		"heapInUse":      m.HeapInuse,
+		"heapAlloc":      m.HeapAlloc,
+		"heapObjects":    m.HeapObjects,
+		"totalAllocated": m.TotalAlloc,
+		"sys":            m.Sys,
+		"gcCycles":       m.NumGC,
+		"handles":        handles,
+	}
+}
+
+// getModuleInfo - Describe the module version and capabilities for loaders
+func getModuleInfo(this js.Value, args []js.Value) interface{} {
+	silent := silentMode
+	silentMode = true
+	advertised := getAvailableFunctions(js.Undefined(), nil).(js.Value)
+	silentMode = silent
+
+	return js.ValueOf(map[string]interface{}{
+		"name":            "uuidid-wasm",
+		"version":         moduleVersion,
+		"description":     "Identifier toolkit: UUID v1/v4/v5/v7, ULID, KSUID, Snowflake decoding and short IDs",
+		"apiVersion":      1,
+		"buildHash":       buildHash,
+		"goVersion":       runtime.Version(),
+		"wasmExecVersion": runtime.Version(),
+		"features":        moduleFeatures,
+		"functions":       registeredFunctions(advertised),
+		"flags": map[string]interface{}{
+			"silentMode": silentMode,
+			"locale":     currentLocale,
+		},
+	})
+}
+
+// getAvailableFunctions returns all available functions
+func getAvailableFunctions(this js.Value, args []js.Value) interface{} {
+	functions := []interface{}{
+		"generateUUID",
+		"parseUUID",
+		"generateULID",
+		"parseULID",
+		"generateKSUID",
+		"parseKSUID",
+		"decodeSnowflake",
+		"generateShortID",
+		"getAvailableFunctions",
+		"getModuleInfo",
+		"getMemoryStats",
+		"releaseResources",
+		"setSilentMode",
+		"setLocale",
+	}
+
+	if !silentMode {
+		fmt.Printf("Go WASM: Available functions: %d\n", len(functions))
+	}
+
+	return js.ValueOf(functions)
+}
+
+func main() {
+	// Register identifier functions
+	js.Global().Set("generateUUID", js.FuncOf(generateUUID))
+	js.Global().Set("parseUUID", js.FuncOf(parseUUID))
+	js.Global().Set("generateULID", js.FuncOf(generateULID))
+	js.Global().Set("parseULID", js.FuncOf(parseULID))
+	js.Global().Set("generateKSUID", js.FuncOf(generateKSUID))
+	js.Global().Set("parseKSUID", js.FuncOf(parseKSUID))
+	js.Global().Set("decodeSnowflake", js.FuncOf(decodeSnowflake))
+	js.Global().Set("generateShortID", js.FuncOf(generateShortID))
+
+	// Register system functions
+	js.Global().Set("getAvailableFunctions", js.FuncOf(getAvailableFunctions))
+	js.Global().Set("getModuleInfo", js.FuncOf(getModuleInfo))
+	js.Global().Set("getMemoryStats", js.FuncOf(getMemoryStats))
+	js.Global().Set("releaseResources", js.FuncOf(releaseResources))
+	js.Global().Set("setSilentMode", js.FuncOf(setSilentMode))
+	js.Global().Set("setLocale", js.FuncOf(setLocale))
+
+	// Signal readiness for GoWM
+	js.Global().Set("__gowm_ready", js.ValueOf(true))
+
+	fmt.Println("Go WASM UUID/ID module ready!")
+	fmt.Println("Available functions: generateUUID, parseUUID, generateULID, parseULID, generateKSUID, parseKSUID, decodeSnowflake, generateShortID")
+
+	// Keep the program alive
+	select {}
+}